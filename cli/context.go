@@ -132,6 +132,12 @@ func (c *Context) NumFlags() int {
 	return c.flagSet.NFlag()
 }
 
+// Sets a context flag to a value, as if it had been given on the command line
+func (c *Context) Set(name, value string) error {
+	c.setFlags = nil
+	return c.flagSet.Set(name, value)
+}
+
 // Determines if the flag was actually set
 func (c *Context) IsSet(name string) bool {
 	if c.setFlags == nil {
@@ -64,6 +64,17 @@ func TestContext_Args(t *testing.T) {
 	expect(t, c.Bool("myflag"), true)
 }
 
+func TestContext_Set(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.Int("int", 5, "an int")
+	c := NewContext(nil, set, nil)
+
+	expect(t, c.IsSet("int"), false)
+	c.Set("int", "1")
+	expect(t, c.Int("int"), 1)
+	expect(t, c.IsSet("int"), true)
+}
+
 func TestContext_IsSet(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.Bool("myflag", false, "doc")
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/docker/machine/drivers/scaleway"
+	"github.com/docker/machine/libmachine/drivers/plugin"
+)
+
+func main() {
+	plugin.RegisterDriver(scaleway.NewDriver("", ""))
+}
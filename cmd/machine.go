@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"time"
 
 	"github.com/docker/machine/cli"
 	"github.com/docker/machine/commands"
@@ -80,9 +81,20 @@ func main() {
 		if c.GlobalBool("native-ssh") {
 			ssh.SetDefaultClient(ssh.Native)
 		}
+		ssh.SetKeepAlive(c.GlobalDuration("ssh-keep-alive"))
+		ssh.SetConnectionReuse(!c.GlobalBool("ssh-no-reuse"))
+		if err := ssh.SetProxy(c.GlobalString("ssh-proxy")); err != nil {
+			return err
+		}
+		if err := log.SetFormat(c.GlobalString("log-format")); err != nil {
+			return err
+		}
 		mcnutils.GithubApiToken = c.GlobalString("github-api-token")
 		mcndirs.BaseDir = c.GlobalString("storage-path")
-		return nil
+		if err := commands.ConfigureStore(c); err != nil {
+			return err
+		}
+		return commands.ConfigureWaits(c)
 	}
 
 	app.Commands = commands.Commands
@@ -103,6 +115,12 @@ func main() {
 			Value:  mcndirs.GetBaseDir(),
 			Usage:  "Configures storage path",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_STORE",
+			Name:   "store",
+			Usage:  "Store of machines to use, kept in the storage path (default: the one picked with 'store use')",
+			Value:  "",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_TLS_CA_CERT",
 			Name:   "tls-ca-cert",
@@ -133,11 +151,46 @@ func main() {
 			Usage:  "Token to use for requests to the Github API",
 			Value:  "",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_LOG_FORMAT",
+			Name:   "log-format",
+			Usage:  "Format of the log messages: text or json",
+			Value:  "text",
+		},
 		cli.BoolFlag{
 			EnvVar: "MACHINE_NATIVE_SSH",
 			Name:   "native-ssh",
 			Usage:  "Use the native (Go-based) SSH implementation.",
 		},
+		cli.DurationFlag{
+			EnvVar: "MACHINE_SSH_KEEP_ALIVE",
+			Name:   "ssh-keep-alive",
+			Usage:  "Interval of the keep-alives sent on idle SSH connections, 0 to disable them",
+			Value:  30 * time.Second,
+		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_SSH_NO_REUSE",
+			Name:   "ssh-no-reuse",
+			Usage:  "Open a new SSH connection for every command run on a machine, instead of sharing one",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_SSH_PROXY",
+			Name:   "ssh-proxy",
+			Usage:  "SOCKS5 or HTTP proxy to connect to the machines through, e.g. socks5://proxy:1080",
+			Value:  "",
+		},
+		cli.StringSliceFlag{
+			EnvVar: "MACHINE_WAIT_TIMEOUT",
+			Name:   "wait-timeout",
+			Usage:  "Timeout of a wait, as <wait>=<duration>, e.g. ssh=10m. Waits: daemon, ip, ssh, state",
+			Value:  &cli.StringSlice{},
+		},
+		cli.StringSliceFlag{
+			EnvVar: "MACHINE_WAIT_POLL_INTERVAL",
+			Name:   "wait-poll-interval",
+			Usage:  "Interval between the checks of a wait, as <wait>=<duration>, e.g. daemon=10s",
+			Value:  &cli.StringSlice{},
+		},
 	}
 
 	// TODO: Close plugin servers in case of client panic.
@@ -0,0 +1,359 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/spec"
+	"github.com/docker/machine/libmachine/swarm"
+	"golang.org/x/net/context"
+)
+
+var errNoSpecFile = errors.New("Error: Expected a spec file to be given with -f")
+
+// applyPlan is what "docker-machine apply" does to bring the store in line
+// with a spec.
+type applyPlan struct {
+	Create    []spec.Machine
+	Remove    []string
+	Unchanged []string
+
+	// Conflicts are machines which exist with a different driver than the
+	// one in the spec. They are left alone, since recreating them would
+	// throw away their state.
+	Conflicts map[string]string
+}
+
+func (p applyPlan) empty() bool {
+	return len(p.Create) == 0 && len(p.Remove) == 0
+}
+
+func cmdApply(c *cli.Context) error {
+	path := c.String("file")
+	if path == "" {
+		cli.ShowCommandHelp(c, "apply")
+		return errNoSpecFile
+	}
+
+	s, err := spec.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading spec %q: %s", path, err)
+	}
+
+	certInfo := getCertPathInfoFromContext(c)
+	store := &persist.Filestore{
+		Path:             c.GlobalString("storage-path"),
+		CaCertPath:       certInfo.CaCertPath,
+		CaPrivateKeyPath: certInfo.CaPrivateKeyPath,
+	}
+
+	existing, err := store.List()
+	if err != nil {
+		return fmt.Errorf("Error attempting to list hosts from store: %s", err)
+	}
+
+	plan := planApply(s, existing, c.Bool("prune"))
+	printApplyPlan(os.Stdout, plan)
+
+	if c.Bool("dry-run") || plan.empty() {
+		return nil
+	}
+
+	ctx, cancel := commandContext(0)
+	defer cancel()
+
+	return executeApplyPlan(ctx, store, certInfo, plan, c.Int("parallel"), c.String("package-cache"))
+}
+
+func planApply(s *spec.Spec, existing []*host.Host, prune bool) applyPlan {
+	plan := applyPlan{
+		Conflicts: map[string]string{},
+	}
+
+	existingDrivers := map[string]string{}
+	for _, h := range existing {
+		existingDrivers[h.Name] = h.DriverName
+	}
+
+	for _, m := range s.Machines {
+		driverName, ok := existingDrivers[m.Name]
+		switch {
+		case !ok:
+			plan.Create = append(plan.Create, m)
+		case driverName != m.Driver:
+			plan.Conflicts[m.Name] = driverName
+		default:
+			plan.Unchanged = append(plan.Unchanged, m.Name)
+		}
+	}
+
+	if prune {
+		for _, h := range existing {
+			if s.Machine(h.Name) == nil {
+				plan.Remove = append(plan.Remove, h.Name)
+			}
+		}
+		sort.Strings(plan.Remove)
+	}
+
+	return plan
+}
+
+func printApplyPlan(w io.Writer, plan applyPlan) {
+	for _, m := range plan.Create {
+		fmt.Fprintf(w, "+ %s (%s)\n", m.Name, m.Driver)
+	}
+
+	for _, name := range plan.Remove {
+		fmt.Fprintf(w, "- %s\n", name)
+	}
+
+	conflicts := []string{}
+	for name := range plan.Conflicts {
+		conflicts = append(conflicts, name)
+	}
+	sort.Strings(conflicts)
+	for _, name := range conflicts {
+		fmt.Fprintf(w, "! %s (exists with driver %s, remove it to recreate)\n", name, plan.Conflicts[name])
+	}
+
+	for _, name := range plan.Unchanged {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+
+	if plan.empty() {
+		fmt.Fprintln(w, "Nothing to do.")
+	}
+}
+
+func executeApplyPlan(ctx context.Context, store *persist.Filestore, certInfo cert.CertPathInfo, plan applyPlan, parallel int, packageCache string) error {
+	cfgs := []machineConfig{}
+	for _, m := range plan.Create {
+		cfgs = append(cfgs, specMachineConfig(m))
+	}
+
+	createErr := withPackageCache(store.Path, packageCache, cfgs, func() error {
+		return createMachines(ctx, store, certInfo, cfgs, parallel)
+	})
+
+	failed := []string{}
+	hosts := []*host.Host{}
+	for _, name := range plan.Remove {
+		h, err := loadHost(store, name)
+		if err != nil {
+			log.Errorf("Error removing %s: %s", name, err)
+			failed = append(failed, name)
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+
+	var mu sync.Mutex
+	forEachMachine(ctx, store, parallel, "removing", hosts, func(h *host.Host) error {
+		if err := removeMachine(store, h, false); err != nil {
+			log.Errorf("Error removing %s: %s", h.Name, err)
+			mu.Lock()
+			failed = append(failed, h.Name)
+			mu.Unlock()
+			return err
+		}
+		log.Infof("Successfully removed %s", h.Name)
+		return nil
+	})
+	sort.Strings(failed)
+
+	if createErr != nil {
+		return createErr
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Error removing %d of %d machines: %s", len(failed), len(plan.Remove), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// sharedCreateFlagDefault returns the default of one of the string flags of
+// "create", so that spec files get the same defaults as the command line.
+func sharedCreateFlagDefault(name string) string {
+	for _, f := range sharedCreateFlags {
+		sf, ok := f.(cli.StringFlag)
+		if !ok || strings.Split(sf.Name, ",")[0] != name {
+			continue
+		}
+		if sf.EnvVar != "" && os.Getenv(sf.EnvVar) != "" {
+			return os.Getenv(sf.EnvVar)
+		}
+		return sf.Value
+	}
+	return ""
+}
+
+func defaultString(value, flagName string) string {
+	if value != "" {
+		return value
+	}
+	return sharedCreateFlagDefault(flagName)
+}
+
+func specMachineConfig(m spec.Machine) machineConfig {
+	return machineConfig{
+		Name:       m.Name,
+		DriverName: m.Driver,
+		EngineOptions: &engine.EngineOptions{
+			ArbitraryFlags:   m.Engine.Opts,
+			Env:              m.Engine.Env,
+			InsecureRegistry: m.Engine.InsecureRegistries,
+			Labels:           m.EngineLabels(),
+			RegistryMirror:   m.Engine.RegistryMirrors,
+			StorageDriver:    m.Engine.StorageDriver,
+			TlsVerify:        true,
+			InstallURL:       defaultString(m.Engine.InstallURL, "engine-install-url"),
+			OptionsDir:       m.Engine.OptionsDir,
+			OptionsFile:      m.Engine.OptionsFile,
+		},
+		AuthOptions: auth.AuthOptions{
+			CaCertRemotePath:     m.Engine.CaCertPath,
+			ServerCertRemotePath: m.Engine.ServerCertPath,
+			ServerKeyRemotePath:  m.Engine.ServerKeyPath,
+		},
+		SwarmOptions: &swarm.SwarmOptions{
+			IsSwarm:        m.Swarm.Role != "",
+			Image:          defaultString(m.Swarm.Image, "swarm-image"),
+			Master:         m.Swarm.Role == spec.SwarmRoleMaster,
+			Discovery:      m.Swarm.Discovery,
+			Address:        m.Swarm.Addr,
+			Host:           defaultString(m.Swarm.Host, "swarm-host"),
+			Strategy:       defaultString(m.Swarm.Strategy, "swarm-strategy"),
+			ArbitraryFlags: m.Swarm.Opts,
+		},
+		DriverOpts: func(mcnFlags []mcnflag.Flag) (drivers.DriverOptions, error) {
+			return specDriverOpts(m, mcnFlags)
+		},
+		UserData: m.UserData,
+	}
+}
+
+// specDriverOpts builds the driver options of a machine in a spec. Options
+// which are not in the spec come from the flag's environment variable if it
+// is set, and from its default otherwise, like they would on the command
+// line.
+func specDriverOpts(m spec.Machine, mcnFlags []mcnflag.Flag) (drivers.DriverOptions, error) {
+	driverOpts := rpcdriver.RpcFlags{
+		Values: map[string]interface{}{drivers.StoreIDOption: ""},
+	}
+
+	known := map[string]bool{}
+
+	for _, f := range mcnFlags {
+		name := f.String()
+		known[name] = true
+
+		raw, inSpec := m.DriverOpts[name]
+		envVar := mcnFlagEnvVar(f)
+
+		if !inSpec && envVar != "" && os.Getenv(envVar) != "" {
+			raw, inSpec = os.Getenv(envVar), true
+		}
+
+		if !inSpec {
+			driverOpts.Values[name] = f.Default()
+
+			// Hardcoded logic for boolean, as in getDriverOpts
+			if f.Default() == nil {
+				driverOpts.Values[name] = false
+			}
+			continue
+		}
+
+		value, err := convertDriverOpt(f, raw)
+		if err != nil {
+			return nil, fmt.Errorf("Error in driver option %q of machine %q: %s", name, m.Name, err)
+		}
+		driverOpts.Values[name] = value
+	}
+
+	for name := range m.DriverOpts {
+		if !known[name] {
+			return nil, fmt.Errorf("Driver %q of machine %q has no option %q", m.Driver, m.Name, name)
+		}
+	}
+
+	return driverOpts, nil
+}
+
+// mcnFlagEnvVar returns the environment variable of a driver flag.
+func mcnFlagEnvVar(f mcnflag.Flag) string {
+	switch f := f.(type) {
+	case *mcnflag.BoolFlag:
+		return f.EnvVar
+	case *mcnflag.IntFlag:
+		return f.EnvVar
+	case *mcnflag.StringFlag:
+		return f.EnvVar
+	case *mcnflag.StringSliceFlag:
+		return f.EnvVar
+	}
+	return ""
+}
+
+// convertDriverOpt converts a value from a spec, or from the environment, to
+// the type of the flag it is given for.
+func convertDriverOpt(f mcnflag.Flag, raw interface{}) (interface{}, error) {
+	switch f.(type) {
+	case *mcnflag.BoolFlag:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case *mcnflag.IntFlag:
+		switch v := raw.(type) {
+		case float64:
+			if v == float64(int(v)) {
+				return int(v), nil
+			}
+		case string:
+			return strconv.Atoi(v)
+		}
+	case *mcnflag.StringFlag:
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case float64, bool:
+			return fmt.Sprint(v), nil
+		}
+	case *mcnflag.StringSliceFlag:
+		switch v := raw.(type) {
+		case string:
+			return strings.Split(v, ","), nil
+		case []interface{}:
+			values := []string{}
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+			return values, nil
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized flag type: %T", f)
+	}
+
+	return nil, fmt.Errorf("unexpected value %v", raw)
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/spec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanApply(t *testing.T) {
+	s := &spec.Spec{
+		Machines: []spec.Machine{
+			{Name: "new", Driver: "virtualbox"},
+			{Name: "same", Driver: "virtualbox"},
+			{Name: "moved", Driver: "virtualbox"},
+		},
+	}
+
+	existing := []*host.Host{
+		{Name: "same", DriverName: "virtualbox"},
+		{Name: "moved", DriverName: "digitalocean"},
+		{Name: "extra", DriverName: "virtualbox"},
+	}
+
+	plan := planApply(s, existing, false)
+	assert.Equal(t, 1, len(plan.Create))
+	assert.Equal(t, "new", plan.Create[0].Name)
+	assert.Equal(t, []string{"same"}, plan.Unchanged)
+	assert.Equal(t, map[string]string{"moved": "digitalocean"}, plan.Conflicts)
+	assert.Empty(t, plan.Remove)
+
+	plan = planApply(s, existing, true)
+	assert.Equal(t, []string{"extra"}, plan.Remove)
+}
+
+func TestSpecDriverOpts(t *testing.T) {
+	mcnFlags := []mcnflag.Flag{
+		&mcnflag.IntFlag{Name: "memory", Value: 1024},
+		&mcnflag.StringFlag{Name: "region", Value: "nyc3"},
+		&mcnflag.StringSliceFlag{Name: "tags"},
+		&mcnflag.BoolFlag{Name: "private"},
+	}
+
+	m := spec.Machine{
+		Name:   "dev",
+		Driver: "test",
+		DriverOpts: map[string]interface{}{
+			"memory": float64(2048),
+			"tags":   []interface{}{"a", "b"},
+		},
+	}
+
+	opts, err := specDriverOpts(m, mcnFlags)
+	assert.NoError(t, err)
+	assert.Equal(t, 2048, opts.Int("memory"))
+	assert.Equal(t, "nyc3", opts.String("region"))
+	assert.Equal(t, []string{"a", "b"}, opts.StringSlice("tags"))
+	assert.False(t, opts.Bool("private"))
+
+	m.DriverOpts["unknown"] = "value"
+	_, err = specDriverOpts(m, mcnFlags)
+	assert.Error(t, err)
+
+	delete(m.DriverOpts, "unknown")
+	m.DriverOpts["memory"] = "lots"
+	_, err = specDriverOpts(m, mcnFlags)
+	assert.Error(t, err)
+}
+
+func TestSpecMachineConfigRemotePaths(t *testing.T) {
+	cfg := specMachineConfig(spec.Machine{
+		Name:   "appliance",
+		Driver: "generic",
+		Engine: spec.Engine{
+			OptionsDir:    "/var/lib/docker-etc",
+			OptionsFile:   "/run/systemd/system/docker.service",
+			ServerKeyPath: "/secure/server-key.pem",
+		},
+	})
+
+	assert.Equal(t, "/var/lib/docker-etc", cfg.EngineOptions.OptionsDir)
+	assert.Equal(t, "/run/systemd/system/docker.service", cfg.EngineOptions.OptionsFile)
+	assert.Equal(t, "/secure/server-key.pem", cfg.AuthOptions.ServerKeyRemotePath)
+	assert.Empty(t, cfg.AuthOptions.CaCertRemotePath)
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/audit"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// auditMachinesFunc returns the names of the machines a command changes,
+// from its arguments.
+type auditMachinesFunc func(args []string) []string
+
+// machineArgs is the auditMachinesFunc of the commands whose arguments are
+// machine names.
+func machineArgs(args []string) []string {
+	return args
+}
+
+// firstArg is the auditMachinesFunc of the commands whose first argument is
+// a machine name, followed by others like a snapshot name.
+func firstArg(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[:1]
+}
+
+// noMachines is the auditMachinesFunc of the commands which don't name the
+// machines they change.
+func noMachines(args []string) []string {
+	return nil
+}
+
+// resumedMachine is the auditMachinesFunc of create --resume, whose flags
+// aren't parsed.
+func resumedMachine(name string) auditMachinesFunc {
+	return func(args []string) []string {
+		return []string{name}
+	}
+}
+
+// sshMachine is the auditMachinesFunc of ssh, whose flags aren't parsed: the
+// machine is the first argument which isn't a flag, followed by the command.
+func sshMachine(args []string) []string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return []string{arg}
+		}
+	}
+	return nil
+}
+
+// audited records every run of the command to the audit log of the store,
+// once it is done. Failing to record it only warns, not to get in the way of
+// operating the machines.
+func audited(name string, machines auditMachinesFunc, command func(context *cli.Context) error) func(context *cli.Context) error {
+	return func(c *cli.Context) error {
+		start := time.Now()
+		err := command(c)
+
+		entry := audit.NewEntry(start, name, machines(c.Args()), os.Args[1:], err)
+		if auditErr := audit.NewLog(c.GlobalString("storage-path")).Append(entry); auditErr != nil {
+			log.Warnf("Error recording %s to the audit log: %s", name, auditErr)
+		}
+
+		return err
+	}
+}
+
+func cmdAudit(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return fmt.Errorf("Error: audit takes no arguments, use --machine to select a machine")
+	}
+
+	filter := audit.Filter{
+		Machine: c.String("machine"),
+	}
+
+	if value := c.String("since"); value != "" {
+		since, err := parseSince(value, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.Since = since
+	}
+
+	entries, err := audit.NewLog(c.GlobalString("storage-path")).Read(filter)
+	if err != nil {
+		return err
+	}
+
+	printAuditEntries(os.Stdout, entries)
+	return nil
+}
+
+// parseSince parses the --since of audit: a duration before now, like 24h,
+// an RFC 3339 time or a date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid --since %q: expected a duration like 24h, a time like 2006-01-02T15:04:05Z or a date like 2006-01-02", value)
+}
+
+func printAuditEntries(out io.Writer, entries []audit.Entry) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "TIME\tUSER\tMACHINES\tARGUMENTS\tRESULT")
+	for _, entry := range entries {
+		machines := strings.Join(entry.Machines, ",")
+		if machines == "" {
+			machines = "-"
+		}
+		result := entry.Result
+		if entry.Error != "" {
+			result += ": " + strings.Replace(entry.Error, "\n", " ", -1)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			entry.Time.Local().Format(time.RFC3339), entry.User, machines, strings.Join(entry.Args, " "), result)
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudited(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	globalSet := flag.NewFlagSet("machine", flag.ContinueOnError)
+	globalSet.String("storage-path", dir, "")
+	set := flag.NewFlagSet("ssh", flag.ContinueOnError)
+	assert.NoError(t, set.Parse([]string{"--", "--ssh-agent-forwarding", "web", "uptime"}))
+	c := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	err = audited("ssh", sshMachine, func(c *cli.Context) error {
+		return errors.New("Host is not running")
+	})(c)
+	assert.EqualError(t, err, "Host is not running")
+
+	entries, err := audit.NewLog(dir).Read(audit.Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "ssh", entries[0].Command)
+	assert.Equal(t, []string{"web"}, entries[0].Machines)
+	assert.Equal(t, audit.ResultError, entries[0].Result)
+	assert.Equal(t, "Host is not running", entries[0].Error)
+}
+
+func TestAuditMachines(t *testing.T) {
+	assert.Equal(t, []string{"web", "db"}, machineArgs([]string{"web", "db"}))
+	assert.Equal(t, []string{"web"}, firstArg([]string{"web", "before-upgrade"}))
+	assert.Nil(t, firstArg([]string{}))
+	assert.Equal(t, []string{"web"}, sshMachine([]string{"--ssh-agent-forwarding", "web", "ls", "-l"}))
+	assert.Equal(t, []string{"web"}, resumedMachine("web")([]string{"--resume", "web"}))
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("24h", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), since)
+
+	since, err = parseSince("2026-03-01T08:00:00Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC), since)
+
+	since, err = parseSince("2026-03-01", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), since)
+
+	_, err = parseSince("yesterday", now)
+	assert.EqualError(t, err, `Invalid --since "yesterday": expected a duration like 24h, a time like 2006-01-02T15:04:05Z or a date like 2006-01-02`)
+}
+
+func TestPrintAuditEntries(t *testing.T) {
+	entries := []audit.Entry{
+		{Time: time.Now(), User: "alice", Command: "rm", Machines: []string{"web"}, Args: []string{"rm", "-f", "web"}, Result: audit.ResultOK},
+		{Time: time.Now(), User: "bob", Command: "gc", Args: []string{"gc"}, Result: audit.ResultError, Error: "Error removing 1 of 2\norphaned resources"},
+	}
+
+	out := &bytes.Buffer{}
+	printAuditEntries(out, entries)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, string(lines[1]), "alice   web        rm -f web   ok")
+	assert.Contains(t, string(lines[2]), "bob     -          gc          error: Error removing 1 of 2 orphaned resources")
+}
@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/persist"
+	"golang.org/x/net/context"
+)
+
+// batchResult is the outcome of creating one machine of a batch.
+type batchResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// createMachines creates several machines, at most parallel of them at the
+// same time, and prints a summary once all of them are done.
+func createMachines(ctx context.Context, store *persist.Filestore, certInfo cert.CertPathInfo, cfgs []machineConfig, parallel int) error {
+	if len(cfgs) == 0 {
+		return nil
+	}
+
+	if parallel < 1 {
+		return errInvalidParallel
+	}
+
+	// Do the work every machine of the batch would otherwise race to do
+	// on its own: generating the CA and downloading the ISO.
+	if err := cert.BootstrapCertificates(newAuthOptions(certInfo, cfgs[0].Name)); err != nil {
+		return fmt.Errorf("Error generating certificates: %s", err)
+	}
+
+	if err := warmISOCache(store.Path, cfgs[0]); err != nil {
+		log.Warnf("Error caching the boot2docker ISO, every machine will try on its own: %s", err)
+	}
+
+	first, joining := splitSwarmJoins(cfgs)
+	results := createBatch(ctx, store, certInfo, first, parallel)
+
+	failed := map[string]bool{}
+	for _, r := range results {
+		failed[r.Name] = r.Err != nil
+	}
+
+	ready := []machineConfig{}
+	for _, cfg := range joining {
+		if failed[cfg.SwarmOptions.JoinManager] {
+			err := fmt.Errorf("Swarm mode manager %s was not created", cfg.SwarmOptions.JoinManager)
+			log.Errorf("(%s) %s", cfg.Name, err)
+			results = append(results, batchResult{Name: cfg.Name, Err: err})
+			continue
+		}
+		ready = append(ready, cfg)
+	}
+
+	results = append(results, createBatch(ctx, store, certInfo, ready, parallel)...)
+
+	return summarizeBatch(results)
+}
+
+// splitSwarmJoins separates the machines joining the swarm mode cluster of
+// another machine of the batch, which can only join once it is created.
+func splitSwarmJoins(cfgs []machineConfig) ([]machineConfig, []machineConfig) {
+	names := map[string]bool{}
+	for _, cfg := range cfgs {
+		names[cfg.Name] = true
+	}
+
+	first, joining := []machineConfig{}, []machineConfig{}
+	for _, cfg := range cfgs {
+		if cfg.SwarmOptions.Mode && names[cfg.SwarmOptions.JoinManager] {
+			joining = append(joining, cfg)
+		} else {
+			first = append(first, cfg)
+		}
+	}
+
+	return first, joining
+}
+
+// createBatch creates machines, at most parallel of them at the same time.
+// The machines not started being created once ctx is done fail with its
+// error.
+func createBatch(ctx context.Context, store *persist.Filestore, certInfo cert.CertPathInfo, cfgs []machineConfig, parallel int) []batchResult {
+	results := make([]batchResult, len(cfgs))
+	names := make([]string, len(cfgs))
+	for i, cfg := range cfgs {
+		names[i] = cfg.Name
+	}
+	started := make([]bool, len(cfgs))
+
+	client := newClient(store)
+	client.Parallel = parallel
+
+	// The errors are in the results, the summary of which is the error of
+	// the batch.
+	client.ForEach(ctx, "creating", names, func(i int) error {
+		cfg := cfgs[i]
+		started[i] = true
+		logger := log.WithField("host", cfg.Name)
+
+		logger.Infof("(%s) Creating machine...", cfg.Name)
+		start := time.Now()
+		err := createMachine(ctx, store, certInfo, cfg)
+		results[i] = batchResult{
+			Name:     cfg.Name,
+			Err:      err,
+			Duration: time.Since(start),
+		}
+
+		if err != nil {
+			log.Errorf("(%s) %s", cfg.Name, err)
+			return err
+		}
+		logger.Infof("(%s) Machine created in %s", cfg.Name, results[i].Duration)
+		return nil
+	})
+
+	for i, cfg := range cfgs {
+		if !started[i] {
+			results[i] = batchResult{Name: cfg.Name, Err: ctx.Err()}
+		}
+	}
+
+	return results
+}
+
+// forEachMachine runs fn for every machine of hosts with the ForEach of a
+// client of the store, at most parallel of them at the same time, but for
+// the virtualbox ones, done one at a time meanwhile as in
+// runActionForeachMachine. The errors of fn are its to report.
+func forEachMachine(ctx context.Context, store persist.Store, parallel int, operation string, hosts []*host.Host, fn func(h *host.Host) error) {
+	concurrent, serial := []*host.Host{}, []*host.Host{}
+	for _, h := range hosts {
+		if h.DriverName == "virtualbox" {
+			serial = append(serial, h)
+		} else {
+			concurrent = append(concurrent, h)
+		}
+	}
+
+	client := newClient(store)
+	client.Parallel = parallel
+	serialClient := newClient(store)
+	serialClient.Parallel = 1
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.ForEach(ctx, operation, hostNames(concurrent), func(i int) error {
+			return fn(concurrent[i])
+		})
+	}()
+	serialClient.ForEach(ctx, operation, hostNames(serial), func(i int) error {
+		return fn(serial[i])
+	})
+	<-done
+}
+
+func hostNames(hosts []*host.Host) []string {
+	names := make([]string, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+	return names
+}
+
+func summarizeBatch(results []batchResult) error {
+	failed := printBatchResults(os.Stdout, results, "created")
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Error creating %d of %d machines: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// printBatchResults prints a table of the results of an action on several
+// machines, done being the result of the machines it succeeded on, and
+// returns the names of the machines it failed on.
+func printBatchResults(out io.Writer, results []batchResult, done string) []string {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tRESULT\tDURATION")
+
+	failed := []string{}
+	for _, r := range results {
+		result := done
+		if r.Err != nil {
+			result = "failed"
+			failed = append(failed, r.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, result, r.Duration/time.Second*time.Second)
+	}
+	w.Flush()
+
+	return failed
+}
+
+// warmISOCache downloads the default boot2docker ISO before a batch of
+// machines using it is created, instead of every machine finding it missing
+// and downloading it at the same time. Whether the driver uses the default
+// ISO is found out from its configuration, as the ISO upgrade does.
+func warmISOCache(storePath string, cfg machineConfig) error {
+	bareDriverData, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: cfg.Name,
+		StorePath:   storePath,
+	})
+	if err != nil {
+		return err
+	}
+
+	driver, err := newPluginDriver(cfg.DriverName, bareDriverData)
+	if err != nil {
+		return err
+	}
+
+	if rpcd, ok := driver.(*rpcdriver.RpcClientDriver); ok {
+		defer rpcd.Close()
+	}
+
+	driverOpts, err := cfg.DriverOpts(driver.GetCreateFlags())
+	if err != nil {
+		return err
+	}
+
+	if err := driver.SetConfigFromFlags(driverOpts); err != nil {
+		return err
+	}
+
+	jsonDriver, err := json.Marshal(driver)
+	if err != nil {
+		return err
+	}
+
+	var d struct {
+		Boot2DockerURL *string
+	}
+	if err := json.Unmarshal(jsonDriver, &d); err != nil {
+		return err
+	}
+
+	if d.Boot2DockerURL == nil || *d.Boot2DockerURL != "" {
+		return nil
+	}
+
+	return mcnutils.NewB2dUtils(storePath).CacheDefaultIso()
+}
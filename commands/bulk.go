@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/swarm"
+	"golang.org/x/net/context"
+)
+
+var (
+	errAllWithArgs       = errors.New("Error: --all takes the place of the machine names, expected no arguments")
+	errFilterWithoutAll  = errors.New("Error: --filter selects among all the machines, it requires --all")
+	bulkActionsPastTense = map[string]string{
+		"start":   "started",
+		"stop":    "stopped",
+		"restart": "restarted",
+	}
+	bulkActionsOperation = map[string]string{
+		"start":   "starting",
+		"stop":    "stopping",
+		"restart": "restarting",
+	}
+)
+
+// isSwarmManager tells whether the machine manages a cluster: a swarm mode
+// manager, or a Swarm master.
+func isSwarmManager(h *host.Host) bool {
+	if h.HostOptions == nil || h.HostOptions.SwarmOptions == nil {
+		return false
+	}
+
+	o := h.HostOptions.SwarmOptions
+	return (o.Mode && o.Role == swarm.RoleManager) || o.Master
+}
+
+// bulkTiers orders the machines in the tiers an action runs on one after the
+// other: the managers of the clusters before the other machines on start and
+// restart, for the workers to find their managers up, and after them on
+// stop, for the managers to see their workers leave.
+func bulkTiers(actionName string, hosts []*host.Host) [][]*host.Host {
+	managers, others := []*host.Host{}, []*host.Host{}
+	for _, h := range hosts {
+		if isSwarmManager(h) {
+			managers = append(managers, h)
+		} else {
+			others = append(others, h)
+		}
+	}
+
+	tiers := [][]*host.Host{managers, others}
+	if actionName == "stop" {
+		tiers = [][]*host.Host{others, managers}
+	}
+
+	nonEmpty := [][]*host.Host{}
+	for _, tier := range tiers {
+		if len(tier) > 0 {
+			nonEmpty = append(nonEmpty, tier)
+		}
+	}
+	return nonEmpty
+}
+
+// runBulkAction runs the action on the machines, tier after tier, at most
+// parallel machines of a tier at the same time but for the virtualbox ones,
+// done one at a time, see forEachMachine.
+func runBulkAction(store persist.Store, actionName string, hosts []*host.Host, parallel int) []batchResult {
+	results := []batchResult{}
+
+	for _, tier := range bulkTiers(actionName, hosts) {
+		index := map[*host.Host]int{}
+		for i, h := range tier {
+			index[h] = i
+		}
+
+		tierResults := make([]batchResult, len(tier))
+		forEachMachine(context.Background(), store, parallel, bulkActionsOperation[actionName], tier, func(h *host.Host) error {
+			start := time.Now()
+			errorChan := make(chan error, 1)
+			machineCommand(actionName, h, errorChan)
+			r := batchResult{Name: h.Name, Err: <-errorChan, Duration: time.Since(start)}
+			tierResults[index[h]] = r
+
+			if r.Err != nil {
+				log.Errorf("(%s) %s", h.Name, r.Err)
+			}
+			return r.Err
+		})
+
+		results = append(results, tierResults...)
+	}
+
+	return results
+}
+
+// runActionOnAll runs the action on every machine of the store matching the
+// filters, up to --parallel at the same time, see runBulkAction, saves the
+// machines and prints a summary.
+func runActionOnAll(actionName string, c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errAllWithArgs
+	}
+
+	parallel := c.Int("parallel")
+	if parallel < 1 {
+		return errInvalidParallel
+	}
+
+	filters, err := parseFilters(c.StringSlice("filter"))
+	if err != nil {
+		return err
+	}
+
+	store := getStore(c)
+	hosts, err := listHosts(store)
+	if err != nil {
+		return err
+	}
+	hosts = filterHosts(hosts, filters)
+
+	if len(hosts) == 0 {
+		log.Infof("No machine to %s", actionName)
+		return nil
+	}
+
+	results := runBulkAction(store, actionName, hosts, parallel)
+
+	bulkErr := mcnerror.ErrBulkOperation{
+		Operation: bulkActionsOperation[actionName],
+		Total:     len(results),
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			bulkErr.Errs = append(bulkErr.Errs, mcnerror.ErrHostOperation{Name: r.Name, Operation: bulkErr.Operation, Err: r.Err})
+			continue
+		}
+		if err := saveHost(store, findHost(hosts, r.Name)); err != nil {
+			return err
+		}
+	}
+
+	printBatchResults(os.Stdout, results, bulkActionsPastTense[actionName])
+
+	if len(bulkErr.Errs) > 0 {
+		return bulkErr
+	}
+	return nil
+}
+
+// findHost returns the machine called name among hosts.
+func findHost(hosts []*host.Host, name string) *host.Host {
+	for _, h := range hosts {
+		if h.Name == name {
+			return h
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func bulkTestHost(name string, swarmOptions *swarm.SwarmOptions) *host.Host {
+	return &host.Host{
+		Name:        name,
+		DriverName:  "fakedriver",
+		Driver:      &fakedriver.Driver{MockName: name, MockState: state.Running},
+		HostOptions: &host.HostOptions{SwarmOptions: swarmOptions},
+	}
+}
+
+func tierNames(tiers [][]*host.Host) [][]string {
+	names := [][]string{}
+	for _, tier := range tiers {
+		names = append(names, hostNames(tier))
+	}
+	return names
+}
+
+func TestBulkTiers(t *testing.T) {
+	hosts := []*host.Host{
+		bulkTestHost("worker", &swarm.SwarmOptions{Mode: true, Role: swarm.RoleWorker}),
+		bulkTestHost("manager", &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager}),
+		bulkTestHost("master", &swarm.SwarmOptions{IsSwarm: true, Master: true}),
+		bulkTestHost("standalone", nil),
+	}
+
+	assert.Equal(t, [][]string{{"manager", "master"}, {"worker", "standalone"}}, tierNames(bulkTiers("start", hosts)))
+	assert.Equal(t, [][]string{{"manager", "master"}, {"worker", "standalone"}}, tierNames(bulkTiers("restart", hosts)))
+	assert.Equal(t, [][]string{{"worker", "standalone"}, {"manager", "master"}}, tierNames(bulkTiers("stop", hosts)))
+}
+
+func TestBulkTiersSkipsEmptyTiers(t *testing.T) {
+	hosts := []*host.Host{bulkTestHost("standalone", nil)}
+
+	assert.Equal(t, [][]string{{"standalone"}}, tierNames(bulkTiers("stop", hosts)))
+}
+
+func TestRunBulkAction(t *testing.T) {
+	manager := bulkTestHost("manager", &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager})
+	worker := bulkTestHost("worker", &swarm.SwarmOptions{Mode: true, Role: swarm.RoleWorker})
+	serial := bulkTestHost("serial", nil)
+	serial.DriverName = "virtualbox"
+
+	results := runBulkAction(&persist.Filestore{}, "stop", []*host.Host{manager, worker, serial}, 2)
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, "manager", results[2].Name)
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+	}
+	for _, h := range []*host.Host{manager, worker, serial} {
+		s, _ := h.Driver.GetState()
+		assert.Equal(t, state.Stopped, s)
+	}
+}
+
+func TestForEachMachine(t *testing.T) {
+	hosts := []*host.Host{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		hosts = append(hosts, bulkTestHost(name, nil))
+	}
+	for _, name := range []string{"vbox-a", "vbox-b"} {
+		h := bulkTestHost(name, nil)
+		h.DriverName = "virtualbox"
+		hosts = append(hosts, h)
+	}
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	most := map[string]int{}
+	done := []string{}
+
+	forEachMachine(context.Background(), &persist.Filestore{}, 2, "testing", hosts, func(h *host.Host) error {
+		mu.Lock()
+		running[h.DriverName]++
+		if running[h.DriverName] > most[h.DriverName] {
+			most[h.DriverName] = running[h.DriverName]
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[h.DriverName]--
+		done = append(done, h.Name)
+		mu.Unlock()
+		return nil
+	})
+
+	assert.Len(t, done, len(hosts))
+	assert.Equal(t, 2, most["fakedriver"])
+	assert.Equal(t, 1, most["virtualbox"])
+}
+
+func TestPrintBatchResults(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	failed := printBatchResults(out, []batchResult{
+		{Name: "foo", Duration: 3 * time.Second},
+		{Name: "bar", Err: errors.New("boom"), Duration: time.Second},
+	}, "stopped")
+
+	assert.Equal(t, []string{"bar"}, failed)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"foo", "stopped", "3s"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"bar", "failed", "1s"}, strings.Fields(lines[2]))
+}
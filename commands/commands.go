@@ -4,20 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docker/machine/cli"
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/drivers/errdriver"
+	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/drivers/rpc"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/verify"
 )
 
 var (
@@ -39,12 +46,85 @@ func newPluginDriver(driverName string, rawContent []byte) (drivers.Driver, erro
 	return d, nil
 }
 
+// exitStatuses are the exit statuses of the commands failing, by category
+// of the failure, for scripts to react to the class of a failure. The
+// failures of other categories exit with 1.
+var exitStatuses = map[mcnerror.Category]int{
+	mcnerror.CategoryTransient:      3,
+	mcnerror.CategoryAuth:           4,
+	mcnerror.CategoryDriverQuota:    5,
+	mcnerror.CategorySSHUnreachable: 6,
+	mcnerror.CategoryProvision:      7,
+}
+
 func fatalOnError(command func(context *cli.Context) error) func(context *cli.Context) {
 	return func(context *cli.Context) {
 		if err := command(context); err != nil {
-			log.Fatal(err)
+			exitWithError(err)
+		}
+	}
+}
+
+// exitWithError reports the error a command failed with, then exits with
+// the exit status of its category.
+func exitWithError(err error) {
+	status, fields := errorExitStatus(err)
+	log.Exit(status, fields, err)
+}
+
+// errorExitStatus returns the exit status of a command failing with err, and
+// the fields telling about the failure in the JSON output: its category and
+// exit status, and the step and output of a failed provisioning step.
+func errorExitStatus(err error) (int, log.Fields) {
+	category := mcnerror.CategoryOf(err)
+	status, ok := exitStatuses[category]
+	if !ok {
+		status = 1
+	}
+
+	fields := log.Fields{
+		"category":    string(category),
+		"exit_status": status,
+	}
+
+	var stepErr mcnerror.ErrProvisionStep
+	if errors.As(err, &stepErr) {
+		fields["step"] = stepErr.Step
+		if stepErr.Output != "" {
+			fields["output"] = stepErr.Output
+		}
+	}
+
+	return status, fields
+}
+
+// exitStatusOnError is like fatalOnError, but exits with the exit status of
+// the command the action runs when it fails, for scripts to tell failures
+// apart. The command already reported the error.
+func exitStatusOnError(command func(context *cli.Context) error) func(context *cli.Context) {
+	return func(context *cli.Context) {
+		err := command(context)
+		if status, ok := exitStatus(err); ok {
+			os.Exit(status)
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+	}
+}
+
+// exitStatus returns the exit status of a command which failed, run locally
+// or over SSH.
+func exitStatus(err error) (int, bool) {
+	switch e := err.(type) {
+	case ssh.ExitError:
+		return e.Status, true
+	case *exec.ExitError:
+		if status, ok := e.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), true
 		}
 	}
+	return 0, false
 }
 
 func confirmInput(msg string) (bool, error) {
@@ -69,47 +149,33 @@ func getStore(c *cli.Context) persist.Store {
 	}
 }
 
-func listHosts(store persist.Store) ([]*host.Host, error) {
-	cliHosts := []*host.Host{}
+// newClient returns a client of the machines of the store, started with
+// their driver plugins.
+func newClient(store persist.Store) *libmachine.Client {
+	return libmachine.NewClient(store, newPluginDriver)
+}
 
-	hosts, err := store.List()
+func listHosts(store persist.Store) ([]*host.Host, error) {
+	hosts, err := newClient(store).List()
 	if err != nil {
-		return nil, fmt.Errorf("Error attempting to list hosts from store: %s", err)
-	}
-
-	for _, h := range hosts {
-		d, err := newPluginDriver(h.DriverName, h.RawDriver)
-		if err != nil {
-			return nil, fmt.Errorf("Error attempting to invoke binary for plugin '%s': %s", h.DriverName, err)
-		}
-
-		h.Driver = d
-
-		cliHosts = append(cliHosts, h)
+		return nil, fmt.Errorf("Error attempting to list hosts from store: %w", err)
 	}
 
-	return cliHosts, nil
+	return hosts, nil
 }
 
 func loadHost(store persist.Store, hostName string) (*host.Host, error) {
-	h, err := store.Load(hostName)
-	if err != nil {
-		return nil, fmt.Errorf("Loading host from store failed: %s", err)
-	}
-
-	d, err := newPluginDriver(h.DriverName, h.RawDriver)
+	h, err := newClient(store).Load(hostName)
 	if err != nil {
-		return nil, fmt.Errorf("Error attempting to invoke binary for plugin: %s", err)
+		return nil, fmt.Errorf("Loading host from store failed: %w", err)
 	}
 
-	h.Driver = d
-
 	return h, nil
 }
 
 func saveHost(store persist.Store, h *host.Host) error {
 	if err := store.Save(h); err != nil {
-		return fmt.Errorf("Error attempting to save host to store: %s", err)
+		return fmt.Errorf("Error attempting to save host to store: %w", err)
 	}
 
 	return nil
@@ -160,10 +226,54 @@ var Commands = []cli.Command{
 		Usage:  "Print which machine is active",
 		Action: fatalOnError(cmdActive),
 	},
+	{
+		Name:        "apply",
+		Usage:       "Create and remove machines to match a spec file",
+		Description: "Machines missing from the store are created in parallel.",
+		Action:      fatalOnError(audited("apply", noMachines, cmdApply)),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "Spec file describing the machines, in YAML or JSON",
+			},
+			cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Remove machines which are not in the spec",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only show what would be done",
+			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of machines to create at the same time",
+				Value: defaultParallelCreates,
+			},
+			cli.StringFlag{
+				Name:  "package-cache",
+				Usage: "Download the packages through a caching proxy: \"local\" to run one while the machines are created, or the host:port of one",
+			},
+		},
+	},
+	{
+		Name:   "audit",
+		Usage:  "Show the operations which changed machines",
+		Action: fatalOnError(cmdAudit),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "machine",
+				Usage: "Only show the operations of this machine",
+			},
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "Only show the operations since a duration ago like 24h, a time or a date",
+			},
+		},
+	},
 	{
 		Name:        "config",
 		Usage:       "Print the connection config for machine",
-		Description: "Argument is a machine name.",
+		Description: "Argument is a machine name, or \"defaults show\" to print the defaults of the create flags.",
 		Action:      fatalOnError(cmdConfig),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
@@ -179,6 +289,57 @@ var Commands = []cli.Command{
 		Action:          fatalOnError(cmdCreateOuter),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:        "doctor",
+		Usage:       "Diagnose the local environment and the machines, with the steps to fix the problems found",
+		Description: "Argument(s) are one or more machine names, every machine when none are given.",
+		Action:      fatalOnError(cmdDoctor),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "local",
+				Usage: "Only diagnose the local environment, not the machines",
+			},
+		},
+	},
+	{
+		Name:  "driver",
+		Usage: "Get information about drivers",
+		Subcommands: []cli.Command{
+			{
+				Name:        "inspect",
+				Usage:       "Inspect the capabilities of a driver",
+				Description: "Argument is a driver name.",
+				Action:      fatalOnError(cmdDriverInspect),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "format, f",
+						Usage: "Format the output using the given go template.",
+						Value: "",
+					},
+				},
+			},
+			{
+				Name:        "options",
+				Usage:       "List the regions, images and sizes a driver can create machines with",
+				Description: "Argument is a driver name.",
+				Action:      fatalOnError(cmdDriverOptions),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "region",
+						Usage: "Region to list the images and sizes of, instead of the default region of the driver",
+					},
+					cli.StringFlag{
+						Name:  "kind",
+						Usage: "Only list the regions, images or sizes",
+					},
+					cli.BoolFlag{
+						Name:  "quiet, q",
+						Usage: "Only print the values",
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        "env",
 		Usage:       "Display the commands to set up the environment for the Docker client",
@@ -189,6 +350,10 @@ var Commands = []cli.Command{
 				Name:  "swarm",
 				Usage: "Display the Swarm config instead of the Docker daemon",
 			},
+			cli.StringFlag{
+				Name:  "swarm-cluster",
+				Usage: "Display the config of the first manager which can be reached of the named swarm mode cluster, instead of a machine",
+			},
 			cli.StringFlag{
 				Name:  "shell",
 				Usage: "Force environment to be configured for specified shell",
@@ -201,6 +366,104 @@ var Commands = []cli.Command{
 				Name:  "no-proxy",
 				Usage: "Add machine IP to NO_PROXY environment variable",
 			},
+			cli.BoolFlag{
+				Name:  "ssh-tunnel",
+				Usage: "Reach the engine through an SSH tunnel to its unix socket, as always for the machines created with --engine-transport ssh",
+			},
+			cli.IntFlag{
+				Name:  "ssh-tunnel-port",
+				Usage: "Open the SSH tunnel on this local port rather than on a unix socket in the directory of the machine",
+			},
+		},
+	},
+	{
+		Name:            "exec",
+		Usage:           "Run a docker command against a machine, without setting up the environment",
+		Description:     "Arguments are [machine-name] -- [docker-command]",
+		Action:          exitStatusOnError(audited("exec", sshMachine, cmdExec)),
+		SkipFlagParsing: true,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "swarm",
+				Usage: "Run the command against the Swarm master of the machine, given before the machine name",
+			},
+			cli.BoolFlag{
+				Name:  "ssh-tunnel",
+				Usage: "Run the command through an SSH tunnel to the unix socket of the engine, given before the machine name",
+			},
+		},
+	},
+	{
+		Name:  "export",
+		Usage: "Export machines to other tools",
+		Subcommands: []cli.Command{
+			{
+				Name:        "terraform",
+				Usage:       "Print a Terraform configuration of the cloud resources of machines, and the commands importing them",
+				Description: "Argument(s) are one or more machine names.",
+				Action:      fatalOnError(cmdExportTerraform),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "all, a",
+						Usage: "Export every machine in the store whose driver supports it",
+					},
+					cli.BoolFlag{
+						Name:  "imports",
+						Usage: "Print the terraform import commands only",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:   "gc",
+		Usage:  "Remove the cloud resources of machines which are no longer in the store",
+		Action: fatalOnError(audited("gc", noMachines, cmdGc)),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "driver, d",
+				Usage: "Only scan the accounts of the machines using this driver",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the orphaned resources without removing them",
+			},
+			cli.BoolFlag{
+				Name:  "force, f",
+				Usage: "Remove the orphaned resources without prompting",
+			},
+		},
+	},
+	{
+		Name:        "healthcheck",
+		Usage:       "Check that machines are working, and optionally repair them",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      fatalOnError(cmdHealthcheck),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Check every machine in the store",
+			},
+			cli.BoolFlag{
+				Name:  "heal",
+				Usage: "Restart the daemon, push new certificates or rejoin the swarm when a check fails",
+			},
+		},
+	},
+	{
+		Name:  "hosts-file",
+		Usage: "Name the machines in the hosts file of the config file",
+		Subcommands: []cli.Command{
+			{
+				Name:   "sync",
+				Usage:  "Name every machine which has an IP, like dev.docker.local, removing the other entries",
+				Action: fatalOnError(cmdHostsFileSync),
+			},
+			{
+				Name:   "clean",
+				Usage:  "Remove the entries of the machines",
+				Action: fatalOnError(cmdHostsFileClean),
+			},
 		},
 	},
 	{
@@ -214,6 +477,11 @@ var Commands = []cli.Command{
 				Usage: "Format the output using the given go template.",
 				Value: "",
 			},
+			cli.BoolFlag{
+				Name:  "provision-log",
+				Usage: "Print the transcript of provisioning the machine, with the SSH commands run and their output",
+			},
+			pricingURLFlag,
 		},
 	},
 	{
@@ -221,12 +489,44 @@ var Commands = []cli.Command{
 		Usage:       "Get the IP address of a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      fatalOnError(cmdIp),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "List the name and the IP of every machine which has one",
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Format the machines listed by --all using the given go template.",
+				Value: "",
+			},
+		},
 	},
 	{
 		Name:        "kill",
 		Usage:       "Kill a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdKill),
+		Action:      fatalOnError(audited("kill", machineArgs, cmdKill)),
+	},
+	{
+		Name:        "logs",
+		Usage:       "Show the logs of the engine of a machine, or the transcript of provisioning it",
+		Description: "Argument is a machine name.",
+		Action:      fatalOnError(cmdLogs),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "provision",
+				Usage: "Show the transcript of provisioning the machine instead of the logs of its engine",
+			},
+			cli.BoolFlag{
+				Name:  "follow, f",
+				Usage: "Follow the logs",
+			},
+			cli.IntFlag{
+				Name:  "tail, n",
+				Usage: "Number of lines to show from the end of the logs, all of them with 0",
+				Value: 0,
+			},
+		},
 	},
 	{
 		Flags: []cli.Flag{
@@ -239,16 +539,153 @@ var Commands = []cli.Command{
 				Usage: "Filter output based on conditions provided",
 				Value: &cli.StringSlice{},
 			},
+			cli.BoolFlag{
+				Name:  "inventory",
+				Usage: "Show the engine and OS inventory recorded for machines",
+			},
+			cli.BoolFlag{
+				Name:  "show-cost",
+				Usage: "Show the estimated hourly and monthly cost of machines, and their total",
+			},
+			pricingURLFlag,
 		},
 		Name:   "ls",
 		Usage:  "List machines",
 		Action: fatalOnError(cmdLs),
 	},
+	{
+		Name:   "metrics",
+		Usage:  "Print or serve metrics about every machine in Prometheus format",
+		Action: fatalOnError(cmdMetrics),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "listen",
+				Usage: "Serve the metrics on this address, e.g. :9143, instead of printing them",
+			},
+		},
+	},
+	{
+		Name:  "nfs",
+		Usage: "Share local directories with a machine over NFS",
+		Subcommands: []cli.Command{
+			{
+				Name:        "enable",
+				Usage:       "Export a local directory over NFS and mount it in a machine",
+				Description: "Argument is a machine name.",
+				Action:      fatalOnError(audited("nfs enable", firstArg, cmdNFSEnable)),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "path",
+						Usage: "Local directory to share",
+					},
+					cli.StringFlag{
+						Name:  "mount-point",
+						Usage: "Where the machine mounts the directory (default: the path of the local directory)",
+					},
+					cli.StringFlag{
+						Name:  "host-ip",
+						Usage: "IP of the local host as seen by the machine (default: found out from its network)",
+					},
+				},
+			},
+			{
+				Name:        "disable",
+				Usage:       "Unmount the directories shared with a machine and stop exporting them",
+				Description: "Argument is a machine name.",
+				Action:      fatalOnError(audited("nfs disable", firstArg, cmdNFSDisable)),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "path",
+						Usage: "Local directory to stop sharing (default: every shared directory)",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "pause",
+		Usage:       "Pause a machine, saving its state so it can be resumed quickly",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      fatalOnError(audited("pause", machineArgs, cmdPause)),
+	},
+	{
+		Name:  "profile",
+		Usage: "Manage named sets of create flags",
+		Subcommands: []cli.Command{
+			{
+				Name:            "create",
+				Usage:           "Store create flags under a name",
+				Description:     "Arguments are a profile name followed by create flags, e.g. ci-worker -d virtualbox --virtualbox-memory 4096.",
+				Action:          fatalOnError(cmdProfileCreate),
+				SkipFlagParsing: true,
+			},
+			{
+				Name:   "ls",
+				Usage:  "List profiles",
+				Action: fatalOnError(cmdProfileLs),
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove profiles",
+				Description: "Arguments are one or more profile names.",
+				Action:      fatalOnError(cmdProfileRm),
+			},
+		},
+	},
+	{
+		Name:        "provision",
+		Usage:       "Run the stages of creating a machine again",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      fatalOnError(audited("provision", machineArgs, cmdProvision)),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "from",
+				Usage: "Stage to start from: ip-assigned, ssh-ready, provisioned or certs",
+				Value: string(host.StageProvisioned),
+			},
+			cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Give up provisioning after this long, e.g. 10m",
+			},
+		},
+	},
+	{
+		Name:   "reap",
+		Usage:  "Stop or remove the machines which expired, see create --ttl",
+		Action: fatalOnError(audited("reap", noMachines, cmdReap)),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "remove",
+				Usage: "Remove the machines which expired rather than stop them",
+			},
+			cli.StringSliceFlag{
+				Name:  "exempt-label",
+				Usage: "Never reap the machines with this engine label, as key=value or key",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the machines which expired, without stopping or removing them",
+			},
+		},
+	},
+	{
+		Name:        "refresh",
+		Usage:       "Collect again the engine and OS inventory of machines",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      fatalOnError(audited("refresh", machineArgs, cmdRefresh)),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Refresh all the running machines",
+			},
+		},
+	},
 	{
 		Name:        "regenerate-certs",
 		Usage:       "Regenerate TLS Certificates for a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdRegenerateCerts),
+		Action:      fatalOnError(audited("regenerate-certs", machineArgs, cmdRegenerateCerts)),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "force, f",
@@ -257,10 +694,91 @@ var Commands = []cli.Command{
 		},
 	},
 	{
+		Name:  "registry-cache",
+		Usage: "Run a pull-through registry cache on a machine for the other machines to pull images through",
+		Subcommands: []cli.Command{
+			{
+				Name:        "enable",
+				Usage:       "Run the registry cache on a machine and have the engines of the other machines use it as a mirror",
+				Description: "Argument is the name of the machine running the cache.",
+				Action:      fatalOnError(audited("registry-cache enable", firstArg, cmdRegistryCacheEnable)),
+				Flags: []cli.Flag{
+					cli.StringSliceFlag{
+						Name:  "machine, m",
+						Usage: "Machine whose engine uses the cache (can be repeated, default: every other machine)",
+						Value: &cli.StringSlice{},
+					},
+					cli.IntFlag{
+						Name:  "port",
+						Usage: "Port the cache listens on",
+						Value: 5000,
+					},
+					cli.StringFlag{
+						Name:  "remote-url",
+						Usage: "Registry the cache pulls the images from",
+						Value: "https://registry-1.docker.io",
+					},
+				},
+			},
+			{
+				Name:        "disable",
+				Usage:       "Have the engines stop using the registry cache of a machine, and remove it",
+				Description: "Argument is the name of the machine running the cache.",
+				Action:      fatalOnError(audited("registry-cache disable", firstArg, cmdRegistryCacheDisable)),
+			},
+		},
+	},
+	{
+		Name:        "resize",
+		Usage:       "Change the CPUs, memory, disk size or instance type of a machine",
+		Description: "Argument is a machine name. The machine is stopped to be resized, and started again if it was running.",
+		Action:      fatalOnError(audited("resize", firstArg, cmdResize)),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "Number of CPUs",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "Size of memory in MB",
+			},
+			cli.IntFlag{
+				Name:  "disk-size",
+				Usage: "Size of disk in MB, which can only grow",
+			},
+			cli.StringFlag{
+				Name:  "instance-type",
+				Usage: "Instance type, for the drivers of cloud providers",
+			},
+		},
+	},
+	{
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Restart all the machines, the swarm managers first",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "With --all, only restart the machines matching the filters, as in ls",
+				Value: &cli.StringSlice{},
+			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "With --all, maximum number of machines to restart at the same time",
+				Value: libmachine.DefaultParallel,
+			},
+		},
 		Name:        "restart",
 		Usage:       "Restart a machine",
+		Description: "Argument(s) are one or more machine names, or none with --all.",
+		Action:      fatalOnError(audited("restart", machineArgs, cmdRestart)),
+	},
+	{
+		Name:        "resume",
+		Usage:       "Resume a paused machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdRestart),
+		Action:      fatalOnError(audited("resume", machineArgs, cmdResume)),
 	},
 	{
 		Flags: []cli.Flag{
@@ -268,18 +786,115 @@ var Commands = []cli.Command{
 				Name:  "force, f",
 				Usage: "Remove local configuration even if machine cannot be removed",
 			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of machines to remove at the same time",
+				Value: libmachine.DefaultParallel,
+			},
 		},
 		Name:        "rm",
 		Usage:       "Remove a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdRm),
+		Action:      fatalOnError(audited("rm", machineArgs, cmdRm)),
+	},
+	{
+		Name:        "rsync",
+		Usage:       "Sync files between the local host and a machine",
+		Description: "Arguments are [machine:][path] [machine:][path].",
+		Action:      fatalOnError(cmdRsync),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "delete",
+				Usage: "Delete files on the destination which do not exist on the source",
+			},
+			cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "Exclude files matching the pattern (can be repeated)",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "install-rsync",
+				Usage: "Install rsync on the machine if it is missing instead of falling back to tar over SSH",
+			},
+			cli.BoolFlag{
+				Name:  "watch, w",
+				Usage: "Keep watching the local source and sync again whenever it changes",
+			},
+			cli.DurationFlag{
+				Name:  "watch-interval",
+				Usage: "How often to poll the local source for changes in watch mode",
+				Value: time.Second,
+			},
+		},
+	},
+	{
+		Name:  "snapshot",
+		Usage: "Manage snapshots of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "create",
+				Usage:       "Take a snapshot of a machine",
+				Description: "Arguments are [machine-name] [snapshot-name]. A name is generated if none is given.",
+				Action:      fatalOnError(audited("snapshot create", firstArg, cmdSnapshotCreate)),
+			},
+			{
+				Name:        "ls",
+				Usage:       "List the snapshots of a machine",
+				Description: "Argument is a machine name.",
+				Action:      fatalOnError(cmdSnapshotLs),
+			},
+			{
+				Name:        "restore",
+				Usage:       "Restore a machine to a snapshot",
+				Description: "Arguments are [machine-name] [snapshot-name].",
+				Action:      fatalOnError(audited("snapshot restore", firstArg, cmdSnapshotRestore)),
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove a snapshot of a machine",
+				Description: "Arguments are [machine-name] [snapshot-name].",
+				Action:      fatalOnError(audited("snapshot rm", firstArg, cmdSnapshotRm)),
+			},
+		},
 	},
 	{
 		Name:            "ssh",
 		Usage:           "Log into or run a command on a machine with SSH.",
 		Description:     "Arguments are [machine-name] [command]",
-		Action:          fatalOnError(cmdSsh),
+		Action:          exitStatusOnError(audited("ssh", sshMachine, cmdSsh)),
 		SkipFlagParsing: true,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "ssh-agent-forwarding",
+				Usage: "Forward the ssh-agent to the machine, given before the machine name",
+			},
+			cli.BoolFlag{
+				Name:  "tty, t",
+				Usage: "Allocate a terminal on the machine even when not run from one, given before the machine name",
+			},
+			cli.BoolFlag{
+				Name:  "no-tty, T",
+				Usage: "Don't allocate a terminal on the machine, for binary data to be piped as is, given before the machine name",
+			},
+		},
+	},
+	{
+		Name:  "ssh-keys",
+		Usage: "Manage the SSH key of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "rotate",
+				Usage:       "Replace the SSH key of a machine with a new one",
+				Description: "Argument is a machine name.",
+				Action:      fatalOnError(audited("ssh-keys rotate", firstArg, cmdSSHKeysRotate)),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "type",
+						Usage: "Type of the new key: rsa or ed25519, the type of the current key by default",
+					},
+				},
+			},
+		},
 	},
 	{
 		Name:        "scp",
@@ -291,13 +906,41 @@ var Commands = []cli.Command{
 				Name:  "recursive, r",
 				Usage: "Copy files recursively (required to copy directories)",
 			},
+			cli.BoolFlag{
+				Name:  "compress, C",
+				Usage: "Compress the data while it is being transferred",
+			},
+			cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Resume an interrupted copy of a single file instead of starting over",
+			},
+			cli.BoolFlag{
+				Name:  "quiet, q",
+				Usage: "Do not show transfer progress",
+			},
 		},
 	},
 	{
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Start all the machines, the swarm managers first",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "With --all, only start the machines matching the filters, as in ls",
+				Value: &cli.StringSlice{},
+			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "With --all, maximum number of machines to start at the same time",
+				Value: libmachine.DefaultParallel,
+			},
+		},
 		Name:        "start",
 		Usage:       "Start a machine",
-		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdStart),
+		Description: "Argument(s) are one or more machine names, or none with --all.",
+		Action:      fatalOnError(audited("start", machineArgs, cmdStart)),
 	},
 	{
 		Name:        "status",
@@ -306,16 +949,188 @@ var Commands = []cli.Command{
 		Action:      fatalOnError(cmdStatus),
 	},
 	{
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Stop all the machines, the swarm managers last",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "With --all, only stop the machines matching the filters, as in ls",
+				Value: &cli.StringSlice{},
+			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "With --all, maximum number of machines to stop at the same time",
+				Value: libmachine.DefaultParallel,
+			},
+		},
 		Name:        "stop",
 		Usage:       "Stop a machine",
-		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdStop),
+		Description: "Argument(s) are one or more machine names, or none with --all.",
+		Action:      fatalOnError(audited("stop", machineArgs, cmdStop)),
+	},
+	{
+		Name:  "store",
+		Usage: "Manage stores, separate sets of machines with their own certificates and config",
+		Subcommands: []cli.Command{
+			{
+				Name:   "ls",
+				Usage:  "List stores",
+				Action: fatalOnError(cmdStoreLs),
+			},
+			{
+				Name:        "create",
+				Usage:       "Create a store",
+				Description: "Argument is a store name.",
+				Action:      fatalOnError(cmdStoreCreate),
+			},
+			{
+				Name:        "use",
+				Usage:       "Use a store for the next commands",
+				Description: "Argument is a store name, default for the store of the storage path itself.",
+				Action:      fatalOnError(cmdStoreUse),
+			},
+			{
+				Name:        "backup",
+				Usage:       "Back up the store, encrypted, to object storage",
+				Description: "Argument is the URL of the backups, s3://bucket/prefix or file:///path.",
+				Action:      fatalOnError(cmdStoreBackup),
+				Flags:       storeBackupFlags,
+			},
+			{
+				Name:        "restore",
+				Usage:       "Restore the store from a backup",
+				Description: "Argument is the URL of the backups, s3://bucket/prefix or file:///path.",
+				Action:      fatalOnError(audited("store restore", noMachines, cmdStoreRestore)),
+				Flags: append([]cli.Flag{
+					cli.StringFlag{
+						Name:  "snapshot",
+						Usage: "ID of the backup to restore (default: the latest)",
+					},
+					cli.BoolFlag{
+						Name:  "list",
+						Usage: "List the IDs of the backups",
+					},
+					cli.BoolFlag{
+						Name:  "verify",
+						Usage: "Check the backup is whole, without restoring it",
+					},
+					cli.BoolFlag{
+						Name:  "force, f",
+						Usage: "Overwrite the files of a store which has machines",
+					},
+				}, storeBackupFlags...),
+			},
+		},
+	},
+	{
+		Name:  "swarm",
+		Usage: "Manage swarm mode clusters of machines",
+		Subcommands: []cli.Command{
+			{
+				Name:        "init",
+				Usage:       "Initialize a swarm mode cluster with a machine as its first manager",
+				Description: "Argument is a machine name.",
+				Action:      fatalOnError(audited("swarm init", firstArg, cmdSwarmInit)),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "advertise-addr",
+						Usage: "Address to advertise to the cluster (default: the machine's IP)",
+					},
+				},
+			},
+			{
+				Name:        "join",
+				Usage:       "Join machines to the swarm mode cluster of a manager",
+				Description: "Arguments are one or more machine names.",
+				Action:      fatalOnError(audited("swarm join", machineArgs, cmdSwarmJoin)),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "manager",
+						Usage: "Name of a manager machine of the cluster to join",
+					},
+					cli.StringFlag{
+						Name:  "role",
+						Usage: "Role of the machines in the cluster, manager or worker",
+						Value: "worker",
+					},
+					cli.StringFlag{
+						Name:  "advertise-addr",
+						Usage: "Address to advertise to the cluster (default: the machine's IP)",
+					},
+				},
+			},
+			{
+				Name:        "leave",
+				Usage:       "Take machines out of their swarm mode cluster",
+				Description: "Arguments are one or more machine names.",
+				Action:      fatalOnError(audited("swarm leave", machineArgs, cmdSwarmLeave)),
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "force, f",
+						Usage: "Leave even if the machine is a manager",
+					},
+				},
+			},
+			{
+				Name:        "rotate-tokens",
+				Usage:       "Replace the tokens to join a swarm mode cluster with",
+				Description: "Argument is the name of a cluster, which is the name of the machine which initialized it.",
+				Action:      fatalOnError(audited("swarm rotate-tokens", firstArg, cmdSwarmRotateTokens)),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "role",
+						Usage: "Only rotate the token of this role, manager or worker",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "update",
+		Usage:       "Change the settings of the SSH connections to a machine",
+		Description: "Argument is a machine name. The machine is logged in with the new settings before they are saved, if it's running.",
+		Action:      fatalOnError(audited("update", firstArg, cmdUpdate)),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "ssh-port",
+				Usage: "Port of the SSH server of the machine",
+			},
+			cli.StringFlag{
+				Name:  "ssh-user",
+				Usage: "User to log in to the machine as",
+			},
+			cli.StringSliceFlag{
+				Name:  "ssh-option",
+				Usage: "Option of ssh, as Key=Value, replacing the one of the same key, or removing it when the value is empty (can be repeated)",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "no-check",
+				Usage: "Save the new settings without logging in to the machine with them",
+			},
+		},
 	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Docker",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      fatalOnError(cmdUpgrade),
+		Action:      fatalOnError(audited("upgrade", machineArgs, cmdUpgrade)),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "engine-version",
+				Usage: "Install this engine version instead of the latest, downgrading if needed",
+			},
+			cli.StringFlag{
+				Name:  "channel",
+				Usage: "Release channel of the engine: stable, test or experimental",
+			},
+			cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Give up upgrading after this long, e.g. 10m",
+			},
+		},
 	},
 	{
 		Name:        "url",
@@ -323,15 +1138,83 @@ var Commands = []cli.Command{
 		Description: "Argument is a machine name.",
 		Action:      fatalOnError(cmdUrl),
 	},
+	{
+		Name:        "verify",
+		Usage:       "Verify that machines pull and run images, and measure how long it takes",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      fatalOnError(audited("verify", machineArgs, cmdVerify)),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "Verify every machine in the store",
+			},
+			cli.StringFlag{
+				Name:  "image",
+				Usage: "Image to pull and run",
+				Value: verify.DefaultImage,
+			},
+			cli.DurationFlag{
+				Name:  "max-pull",
+				Usage: "Fail when pulling the image takes longer, e.g. 30s",
+			},
+			cli.DurationFlag{
+				Name:  "max-run",
+				Usage: "Fail when running the image takes longer, e.g. 5s",
+			},
+		},
+	},
+	{
+		Name:   "watch",
+		Usage:  "Watch the machines drift from their recorded state, and correct it",
+		Action: fatalOnError(cmdWatch),
+		Flags: []cli.Flag{
+			cli.DurationFlag{
+				Name:  "interval",
+				Usage: "How often to compare the machines with their recorded state",
+				Value: time.Minute,
+			},
+			cli.BoolFlag{
+				Name:  "correct",
+				Usage: "Start the machines which stopped, and regenerate the certificates about to expire",
+			},
+			cli.BoolFlag{
+				Name:  "once",
+				Usage: "Compare the machines once, and fail if drift is left",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter the machines watched, as in ls",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringFlag{
+				Name:  "listen",
+				Usage: "Serve the metrics of the machines and of their drift on this address, e.g. :9143",
+			},
+			cli.BoolFlag{
+				Name:  "reap",
+				Usage: "Stop the machines which expired every round, as reap does",
+			},
+			cli.BoolFlag{
+				Name:  "reap-remove",
+				Usage: "Remove the machines which expired rather than stop them, with --reap",
+			},
+			cli.StringSliceFlag{
+				Name:  "reap-exempt-label",
+				Usage: "Never reap the machines with this engine label, as key=value or key, with --reap",
+				Value: &cli.StringSlice{},
+			},
+		},
+	},
 }
 
 func printIP(h *host.Host) func() error {
 	return func() error {
 		ip, err := h.Driver.GetIP()
 		if err != nil {
-			return fmt.Errorf("Error getting IP address: %s", err)
+			return fmt.Errorf("Error getting IP address: %w", err)
 		}
 		fmt.Println(ip)
+		recordIP(h.Name, ip)
 		return nil
 	}
 }
@@ -346,13 +1229,25 @@ func machineCommand(actionName string, host *host.Host, errorChan chan<- error)
 		"stop":          host.Stop,
 		"restart":       host.Restart,
 		"kill":          host.Kill,
+		"pause":         host.Pause,
+		"resume":        host.Resume,
 		"upgrade":       host.Upgrade,
 		"ip":            printIP(host),
 	}
 
 	log.Debugf("command=%s machine=%s", actionName, host.Name)
 
-	errorChan <- commands[actionName]()
+	err := commands[actionName]()
+	if err == nil {
+		switch actionName {
+		case "start", "restart", "resume":
+			updateHostsFile(host.Name, host.Driver)
+		case "stop", "kill", "pause":
+			updateHostsFile(host.Name, nil)
+		}
+	}
+
+	errorChan <- err
 }
 
 // runActionForeachMachine will run the command across multiple machines
@@ -405,6 +1300,11 @@ func runActionForeachMachine(actionName string, machines []*host.Host) []error {
 }
 
 func consolidateErrs(errs []error) error {
+	// A single error is kept as it is, for its category.
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
 	finalErr := ""
 	for _, err := range errs {
 		finalErr = fmt.Sprintf("%s\n%s", finalErr, err)
@@ -414,6 +1314,13 @@ func consolidateErrs(errs []error) error {
 }
 
 func runActionWithContext(actionName string, c *cli.Context) error {
+	if c.Bool("all") {
+		return runActionOnAll(actionName, c)
+	}
+	if len(c.StringSlice("filter")) > 0 {
+		return errFilterWithoutAll
+	}
+
 	store := getStore(c)
 
 	hosts, err := getHostsFromContext(c)
@@ -431,7 +1338,7 @@ func runActionWithContext(actionName string, c *cli.Context) error {
 
 	for _, h := range hosts {
 		if err := saveHost(store, h); err != nil {
-			return fmt.Errorf("Error saving host to store: %s", err)
+			return fmt.Errorf("Error saving host to store: %w", err)
 		}
 	}
 
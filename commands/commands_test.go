@@ -8,6 +8,7 @@ import (
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/persisttest"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/stretchr/testify/assert"
@@ -153,3 +154,19 @@ func TestConsolidateError(t *testing.T) {
 		assert.Equal(t, c.expectedErr, consolidateErrs(c.inputErrs))
 	}
 }
+
+func TestErrorExitStatus(t *testing.T) {
+	status, fields := errorExitStatus(errors.New("boom"))
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "unknown", fields["category"])
+
+	status, fields = errorExitStatus(mcnerror.ErrHostOperation{Name: "web", Operation: "creating", Err: mcnerror.ErrDriverQuota{Driver: "amazonec2", Err: errors.New("Instance limit exceeded")}})
+	assert.Equal(t, 5, status)
+	assert.Equal(t, "driver-quota", fields["category"])
+	assert.Equal(t, 5, fields["exit_status"])
+
+	status, fields = errorExitStatus(mcnerror.ErrProvisionStep{Step: "installing docker", Output: "No package docker available.", Err: errors.New("exit status 1")})
+	assert.Equal(t, 7, status)
+	assert.Equal(t, "installing docker", fields["step"])
+	assert.Equal(t, "No package docker available.", fields["output"])
+}
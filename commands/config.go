@@ -2,9 +2,9 @@ package commands
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"os"
-	"strings"
 
 	"github.com/docker/machine/cli"
 	"github.com/docker/machine/libmachine/auth"
@@ -32,6 +32,10 @@ func cmdConfig(c *cli.Context) error {
 	// being run (it is intended to be run in a subshell)
 	log.SetOutWriter(os.Stderr)
 
+	if len(c.Args()) == 2 && c.Args()[0] == "defaults" && c.Args()[1] == "show" {
+		return cmdConfigDefaultsShow(c)
+	}
+
 	if len(c.Args()) != 1 {
 		return ErrExpectedOneMachine
 	}
@@ -55,6 +59,13 @@ func cmdConfig(c *cli.Context) error {
 }
 
 func runConnectionBoilerplate(h *host.Host, c *cli.Context) (string, *auth.AuthOptions, error) {
+	return connectionSettings(h, c.Bool("swarm"))
+}
+
+// connectionSettings returns the URL of the engine of the running machine, or
+// of its swarm master when swarm is true, with the options authenticating to
+// it, once its certificates are checked.
+func connectionSettings(h *host.Host, swarm bool) (string, *auth.AuthOptions, error) {
 	hostState, err := h.Driver.GetState()
 	if err != nil {
 		// TODO: This is a common operation and should have a commonly
@@ -65,12 +76,16 @@ func runConnectionBoilerplate(h *host.Host, c *cli.Context) (string, *auth.AuthO
 		return "", &auth.AuthOptions{}, fmt.Errorf("%s is not running. Please start it in order to use the connection settings", h.Name)
 	}
 
+	if h.HostOptions != nil && h.HostOptions.EngineOptions.SSHTransport() {
+		return "", &auth.AuthOptions{}, fmt.Errorf("The engine of %s doesn't listen on TCP, reach it through an SSH tunnel with env or exec", h.Name)
+	}
+
 	dockerHost, err := h.Driver.GetURL()
 	if err != nil {
 		return "", &auth.AuthOptions{}, fmt.Errorf("Error getting driver URL: %s", err)
 	}
 
-	if c.Bool("swarm") {
+	if swarm {
 		var err error
 		dockerHost, err = parseSwarm(dockerHost, h)
 		if err != nil {
@@ -104,7 +119,6 @@ func checkCert(hostUrl string, authOptions *auth.AuthOptions) error {
 	return nil
 }
 
-// TODO: This could use a unit test.
 func parseSwarm(hostUrl string, h *host.Host) (string, error) {
 	swarmOptions := h.HostOptions.SwarmOptions
 
@@ -116,8 +130,10 @@ func parseSwarm(hostUrl string, h *host.Host) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("There was an error parsing the url: %s", err)
 	}
-	parts := strings.Split(u.Host, ":")
-	swarmPort := parts[1]
+	_, swarmPort, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return "", fmt.Errorf("There was an error parsing the url: %s", err)
+	}
 
 	// get IP of machine to replace in case swarm host is 0.0.0.0
 	mUrl, err := url.Parse(hostUrl)
@@ -125,10 +141,9 @@ func parseSwarm(hostUrl string, h *host.Host) (string, error) {
 		return "", fmt.Errorf("There was an error parsing the url: %s", err)
 	}
 
-	mParts := strings.Split(mUrl.Host, ":")
-	machineIp := mParts[0]
+	machineIp := mUrl.Hostname()
 
-	hostUrl = fmt.Sprintf("tcp://%s:%s", machineIp, swarmPort)
+	hostUrl = fmt.Sprintf("tcp://%s", net.JoinHostPort(machineIp, swarmPort))
 
 	return hostUrl, nil
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,3 +54,25 @@ func TestCheckCert(t *testing.T) {
 		assert.Equal(t, c.expectedErr, err)
 	}
 }
+
+func TestParseSwarm(t *testing.T) {
+	h := &host.Host{
+		Name: "master",
+		HostOptions: &host.HostOptions{
+			SwarmOptions: &swarm.SwarmOptions{Master: true, Host: "tcp://0.0.0.0:3376"},
+		},
+	}
+
+	hostURL, err := parseSwarm("tcp://192.168.99.100:2376", h)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://192.168.99.100:3376", hostURL)
+
+	h.HostOptions.SwarmOptions.Host = "tcp://[::]:3376"
+	hostURL, err = parseSwarm("tcp://[2001:db8::5]:2376", h)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://[2001:db8::5]:3376", hostURL)
+
+	h.HostOptions.SwarmOptions.Master = false
+	_, err = parseSwarm("tcp://192.168.99.100:2376", h)
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/hostsfile"
+	"github.com/docker/machine/libmachine/mcnyaml"
+	"github.com/docker/machine/libmachine/notify"
+)
+
+// config is the config file of the store, each of its sections decoded
+// into the field of the feature it configures.
+type config struct {
+	// Create are the values of the create flags when not given
+	Create createDefaults
+
+	// Notify are the sinks the events of the machines are sent to
+	Notify []notify.Sink
+
+	// Hooks are the commands run at each hook point
+	Hooks map[string][]notify.Command
+
+	// Waits are the timeouts and poll intervals of the waits
+	Waits waitSettings
+
+	// HostsFile is the hosts file naming the machines, nil without a
+	// hosts-file section
+	HostsFile *hostsfile.File
+}
+
+// configSections decode the sections of the config file into the config,
+// by name, from their value encoded in JSON. They are the only sections the
+// config file may have.
+var configSections = map[string]func(c *config, encoded []byte) error{
+	"create":     decodeCreateDefaults,
+	"notify":     decodeNotifySinks,
+	"hooks":      decodeHookCommands,
+	"wait":       decodeWaitSettings,
+	"hosts-file": decodeHostsFile,
+}
+
+func configFilePath() string {
+	return filepath.Join(mcndirs.GetBaseDir(), "config.yaml")
+}
+
+// readConfig reads the config file at path. A missing config file
+// configures nothing.
+func readConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return parseConfig(nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config file %q: %s", path, err)
+	}
+
+	return c, nil
+}
+
+func parseConfig(data []byte) (*config, error) {
+	c := &config{
+		Create: createDefaults{},
+		Waits:  waitSettings{},
+	}
+
+	raw, err := mcnyaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return c, nil
+	}
+
+	sections, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping at the top level")
+	}
+
+	names := []string{}
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		decode, ok := configSections[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown section %q", name)
+		}
+
+		// Going through JSON gives numbers the same type as in spec
+		// files.
+		encoded, err := json.Marshal(sections[name])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := decode(c, encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
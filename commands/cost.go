@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/pricing"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// pricingURLFlag gives the price provider the commands showing costs ask
+// before the static price tables.
+var pricingURLFlag = cli.StringFlag{
+	Name:   "pricing-url",
+	Usage:  "URL of a price provider to estimate costs with, see the ls docs",
+	EnvVar: "MACHINE_PRICING_URL",
+}
+
+// hostCost is the estimated cost of a machine in its current state, in USD.
+type hostCost struct {
+	Hourly  float64
+	Monthly float64
+	Source  string
+}
+
+// estimateHostCost returns the estimated cost of the host in state s, or nil
+// when its price isn't known.
+func estimateHostCost(provider pricing.Provider, h *host.Host, s state.State) *hostCost {
+	estimate, err := pricing.EstimateMachine(provider, h.DriverName, h.Driver)
+	if err == drivers.ErrPricingNotImplemented {
+		return nil
+	}
+	if err != nil {
+		log.Warnf("Error estimating the cost of %s: %s", h.Name, err)
+		return nil
+	}
+
+	return &hostCost{
+		Hourly:  estimate.Hourly(s),
+		Monthly: estimate.Monthly(s),
+		Source:  estimate.Source,
+	}
+}
+
+func formatHourlyCost(cost *hostCost) string {
+	if cost == nil {
+		return "-"
+	}
+	return fmt.Sprintf("$%.4f", cost.Hourly)
+}
+
+func formatMonthlyCost(cost *hostCost) string {
+	if cost == nil {
+		return "-"
+	}
+	return fmt.Sprintf("$%.2f", cost.Monthly)
+}
+
+// totalCost returns the sum of the known costs.
+func totalCost(costs []*hostCost) *hostCost {
+	total := &hostCost{}
+	for _, cost := range costs {
+		if cost != nil {
+			total.Hourly += cost.Hourly
+			total.Monthly += cost.Monthly
+		}
+	}
+	return total
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/pricing"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// pricedDriver is a fake driver of machines priced like a t2.micro.
+type pricedDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *pricedDriver) Pricing() (drivers.Pricing, error) {
+	return drivers.Pricing{
+		InstanceType: "t2.micro",
+		Storage:      []drivers.PricedStorage{{Type: "gp2", Size: 73}},
+	}, nil
+}
+
+func TestEstimateHostCost(t *testing.T) {
+	h := &host.Host{Name: "web", DriverName: "amazonec2", Driver: &pricedDriver{}}
+
+	cost := estimateHostCost(pricing.Static, h, state.Running)
+
+	assert.InDelta(t, 0.0216, cost.Hourly, 1e-9)
+	assert.InDelta(t, 15.768, cost.Monthly, 1e-9)
+	assert.Equal(t, "static", cost.Source)
+	assert.Equal(t, "$0.0216", formatHourlyCost(cost))
+	assert.Equal(t, "$15.77", formatMonthlyCost(cost))
+
+	stopped := estimateHostCost(pricing.Static, h, state.Stopped)
+
+	assert.Equal(t, "$7.30", formatMonthlyCost(stopped))
+	assert.Equal(t, "$23.07", formatMonthlyCost(totalCost([]*hostCost{cost, nil, stopped})))
+}
+
+func TestEstimateHostCostUnknown(t *testing.T) {
+	h := &host.Host{Name: "local", DriverName: "fakedriver", Driver: &fakedriver.Driver{}}
+
+	cost := estimateHostCost(pricing.Static, h, state.Running)
+
+	assert.Nil(t, cost)
+	assert.Equal(t, "-", formatHourlyCost(cost))
+	assert.Equal(t, "-", formatMonthlyCost(cost))
+}
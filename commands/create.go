@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"errors"
 
@@ -17,21 +18,44 @@ import (
 	"github.com/docker/machine/drivers/errdriver"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/rpc"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/hooks"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/notify"
 	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/swarm"
+	"golang.org/x/net/context"
 )
 
+const defaultParallelCreates = 4
+
 var (
-	errNoMachineName = errors.New("Error: No machine name specified")
+	errNoMachineName     = errors.New("Error: No machine name specified")
+	errNameAndCount      = errors.New("Error: A machine name cannot be given when creating several machines, use --name-template instead")
+	errNoNameTemplate    = errors.New("Error: --name-template is required when creating several machines")
+	errInvalidCount      = errors.New("Error: --count must be at least 1")
+	errInvalidParallel   = errors.New("Error: --parallel must be at least 1")
+	errInvalidNameFormat = errors.New("Error: --name-template must contain exactly one integer verb such as %d or %02d")
+	errManagerRole       = errors.New("Error: --swarm-manager cannot be used with --swarm-mode-role worker")
+	errEvenManagers      = errors.New("Error: A swarm mode cluster needs an odd number of managers, such as 3 or 5, to keep a quorum")
+	errUpgradeWindow     = errors.New("Error: --engine-upgrade-window requires --engine-auto-upgrade")
+	errEngineTransport   = errors.New("Error: --engine-transport must be tls or ssh")
+	errSSHTransportSwarm = errors.New("Error: Swarm reaches the engines on their TCP listener, it cannot be used with --engine-transport ssh, unlike swarm mode")
+	errSwarmModeBatch    = errors.New("Error: Machines created together in swarm mode need --swarm-manager to form a cluster, or --swarm-mode-join to join one")
 )
 
+// maxSwarmManagers is the number of managers above which raft consensus
+// slows a swarm mode cluster down more than the extra managers help.
+const maxSwarmManagers = 7
+
 var (
 	sharedCreateFlags = []cli.Flag{
 		cli.StringFlag{
@@ -39,7 +63,8 @@ var (
 			Usage: fmt.Sprintf(
 				"Driver to create machine with.",
 			),
-			Value: "none",
+			Value:  "none",
+			EnvVar: "MACHINE_DRIVER",
 		},
 		cli.StringFlag{
 			Name:   "engine-install-url",
@@ -48,37 +73,143 @@ var (
 			EnvVar: "MACHINE_DOCKER_INSTALL_URL",
 		},
 		cli.StringSliceFlag{
-			Name:  "engine-opt",
-			Usage: "Specify arbitrary flags to include with the created engine in the form flag=value",
-			Value: &cli.StringSlice{},
+			Name:   "engine-opt",
+			Usage:  "Specify arbitrary flags to include with the created engine in the form flag=value",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_OPT",
+		},
+		cli.StringSliceFlag{
+			Name:   "engine-insecure-registry",
+			Usage:  "Specify insecure registries to allow with the created engine",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_INSECURE_REGISTRY",
+		},
+		cli.StringSliceFlag{
+			Name:   "engine-registry-mirror",
+			Usage:  "Specify registry mirrors to use",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_REGISTRY_MIRROR",
 		},
 		cli.StringSliceFlag{
-			Name:  "engine-insecure-registry",
-			Usage: "Specify insecure registries to allow with the created engine",
-			Value: &cli.StringSlice{},
+			Name:   "engine-registry-ca",
+			Usage:  "Specify the CA certificate the engine trusts a registry or registry mirror with, as registry=path",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_REGISTRY_CA",
 		},
 		cli.StringSliceFlag{
-			Name:  "engine-registry-mirror",
-			Usage: "Specify registry mirrors to use",
-			Value: &cli.StringSlice{},
+			Name:   "engine-registry-auth",
+			Usage:  "Specify the credentials the docker CLI of the machine logs in to a registry or registry mirror with, as registry=user:password",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_REGISTRY_AUTH",
 		},
 		cli.StringSliceFlag{
-			Name:  "engine-label",
-			Usage: "Specify labels for the created engine",
-			Value: &cli.StringSlice{},
+			Name:   "engine-label",
+			Usage:  "Specify labels for the created engine",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_LABEL",
+		},
+		cli.StringFlag{
+			Name:   "engine-storage-driver",
+			Usage:  "Specify a storage driver to use with the engine",
+			EnvVar: "MACHINE_ENGINE_STORAGE_DRIVER",
+		},
+		cli.BoolFlag{
+			Name:   "engine-auto-upgrade",
+			Usage:  "Have the package manager of the machine upgrade the engine packages unattended, in the window of --engine-upgrade-window",
+			EnvVar: "MACHINE_ENGINE_AUTO_UPGRADE",
+		},
+		cli.StringFlag{
+			Name:   "engine-upgrade-window",
+			Usage:  "Maintenance window of --engine-auto-upgrade, in the time zone of the machine, as a time like 03:00 or days and a time like Sat,Sun 03:00 (default: Sun 03:00)",
+			EnvVar: "MACHINE_ENGINE_UPGRADE_WINDOW",
+		},
+		cli.StringFlag{
+			Name:   "engine-transport",
+			Usage:  "How the clients reach the engine: tls, on its TCP listener, or ssh, through SSH tunnels to its unix socket, the engine not listening on TCP",
+			Value:  engine.TransportTLS,
+			EnvVar: "MACHINE_ENGINE_TRANSPORT",
+		},
+		cli.StringFlag{
+			Name:   "engine-options-dir",
+			Usage:  "Specify the directory of the machine the engine options and certificates are written to, instead of the one of its OS, like /etc/docker",
+			EnvVar: "MACHINE_ENGINE_OPTIONS_DIR",
+		},
+		cli.StringFlag{
+			Name:   "engine-options-file",
+			Usage:  "Specify the file of the machine the engine options are written to, instead of the one of its OS, like /etc/default/docker",
+			EnvVar: "MACHINE_ENGINE_OPTIONS_FILE",
+		},
+		cli.StringFlag{
+			Name:  "engine-ca-cert-path",
+			Usage: "Specify the path of the CA certificate on the machine, in the engine options directory by default",
 		},
 		cli.StringFlag{
-			Name:  "engine-storage-driver",
-			Usage: "Specify a storage driver to use with the engine",
+			Name:  "engine-server-cert-path",
+			Usage: "Specify the path of the server certificate on the machine, in the engine options directory by default",
+		},
+		cli.StringFlag{
+			Name:  "engine-server-key-path",
+			Usage: "Specify the path of the server key on the machine, in the engine options directory by default",
+		},
+		cli.StringFlag{
+			Name:   "provision-hardening",
+			Usage:  "Harden the engine and its host with this profile while provisioning: cis",
+			EnvVar: "MACHINE_PROVISION_HARDENING",
+		},
+		cli.StringSliceFlag{
+			Name:   "engine-env",
+			Usage:  "Specify environment variables to set in the engine",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_ENGINE_ENV",
+		},
+		cli.StringFlag{
+			Name:   "ssh-bastion",
+			Usage:  "Jump host to reach the machine through over SSH, as [user@]host[:port], for machines without a reachable address",
+			EnvVar: "MACHINE_SSH_BASTION",
+		},
+		cli.StringFlag{
+			Name:   "ssh-bastion-key",
+			Usage:  "Private key to authenticate to the SSH bastion with, besides the key of the machine",
+			EnvVar: "MACHINE_SSH_BASTION_KEY",
 		},
 		cli.StringSliceFlag{
-			Name:  "engine-env",
-			Usage: "Specify environment variables to set in the engine",
-			Value: &cli.StringSlice{},
+			Name:   "network",
+			Usage:  "Network to attach the machine to besides its default one, which the driver maps to an extra network adapter, network or subnet. Can be specified multiple times",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_NETWORK",
+		},
+		cli.StringFlag{
+			Name:   "attach-volume",
+			Usage:  "Volume to create and attach to the machine besides its root disk, formatted and mounted before installing Docker, as size=<GB>[,type=ssd|hdd][,mount=<path>]. Mounted on /var/lib/docker by default",
+			Value:  "",
+			EnvVar: "MACHINE_ATTACH_VOLUME",
+		},
+		cli.StringSliceFlag{
+			Name:   "tag",
+			Usage:  "Tag, as key=value, of the cloud resources created for the machine, besides the machine-name and machine-version ones. Can be specified multiple times",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_TAG",
+		},
+		cli.DurationFlag{
+			Name:   "ttl",
+			Usage:  "Expire the machine after this long, e.g. 8h, for reap to stop or remove it",
+			EnvVar: "MACHINE_TTL",
+		},
+		cli.StringFlag{
+			Name:   "user-data",
+			Usage:  "File of user data, such as a cloud-init configuration, given to the machine by the drivers supporting it. The file is a template of the machine name, SSH public key, engine options and swarm role",
+			EnvVar: "MACHINE_USER_DATA",
+		},
+		cli.StringFlag{
+			Name:   "ssh-key-type",
+			Usage:  "Type of the SSH key generated for the machine: rsa or ed25519",
+			Value:  ssh.KeyTypeRSA,
+			EnvVar: "MACHINE_SSH_KEY_TYPE",
 		},
 		cli.BoolFlag{
-			Name:  "swarm",
-			Usage: "Configure Machine with Swarm",
+			Name:   "swarm",
+			Usage:  "Configure Machine with Swarm",
+			EnvVar: "MACHINE_SWARM",
 		},
 		cli.StringFlag{
 			Name:   "swarm-image",
@@ -87,107 +218,193 @@ var (
 			EnvVar: "MACHINE_SWARM_IMAGE",
 		},
 		cli.BoolFlag{
-			Name:  "swarm-master",
-			Usage: "Configure Machine to be a Swarm master",
+			Name:   "swarm-master",
+			Usage:  "Configure Machine to be a Swarm master",
+			EnvVar: "MACHINE_SWARM_MASTER",
 		},
 		cli.StringFlag{
-			Name:  "swarm-discovery",
-			Usage: "Discovery service to use with Swarm",
-			Value: "",
+			Name:   "swarm-discovery",
+			Usage:  "Discovery service to use with Swarm",
+			Value:  "",
+			EnvVar: "MACHINE_SWARM_DISCOVERY",
 		},
 		cli.StringFlag{
-			Name:  "swarm-strategy",
-			Usage: "Define a default scheduling strategy for Swarm",
-			Value: "spread",
+			Name:   "swarm-strategy",
+			Usage:  "Define a default scheduling strategy for Swarm",
+			Value:  "spread",
+			EnvVar: "MACHINE_SWARM_STRATEGY",
 		},
 		cli.StringSliceFlag{
-			Name:  "swarm-opt",
-			Usage: "Define arbitrary flags for swarm",
-			Value: &cli.StringSlice{},
+			Name:   "swarm-opt",
+			Usage:  "Define arbitrary flags for swarm",
+			Value:  &cli.StringSlice{},
+			EnvVar: "MACHINE_SWARM_OPT",
 		},
 		cli.StringFlag{
-			Name:  "swarm-host",
-			Usage: "ip/socket to listen on for Swarm master",
-			Value: "tcp://0.0.0.0:3376",
+			Name:   "swarm-host",
+			Usage:  "ip/socket to listen on for Swarm master",
+			Value:  "tcp://0.0.0.0:3376",
+			EnvVar: "MACHINE_SWARM_HOST",
 		},
 		cli.StringFlag{
-			Name:  "swarm-addr",
-			Usage: "addr to advertise for Swarm (default: detect and use the machine IP)",
-			Value: "",
+			Name:   "swarm-addr",
+			Usage:  "addr to advertise for Swarm (default: detect and use the machine IP)",
+			Value:  "",
+			EnvVar: "MACHINE_SWARM_ADDR",
+		},
+		cli.BoolFlag{
+			Name:   "swarm-mode",
+			Usage:  "Make the machine part of a cluster using the swarm mode built into the engine",
+			EnvVar: "MACHINE_SWARM_MODE",
+		},
+		cli.BoolFlag{
+			Name:   "swarm-manager",
+			Usage:  "Make the machines swarm mode managers, machines created together with --count form a highly available cluster",
+			EnvVar: "MACHINE_SWARM_MANAGER",
+		},
+		cli.StringFlag{
+			Name:   "swarm-mode-role",
+			Usage:  "Role of the machine in swarm mode, manager or worker (default: manager when initializing a cluster, worker when joining one)",
+			EnvVar: "MACHINE_SWARM_MODE_ROLE",
+		},
+		cli.StringFlag{
+			Name:   "swarm-mode-join",
+			Usage:  "Name of the manager machine whose swarm mode cluster to join, instead of initializing a new cluster",
+			EnvVar: "MACHINE_SWARM_MODE_JOIN",
+		},
+		cli.StringFlag{
+			Name:  "profile",
+			Usage: "Profile whose flags to create the machine with, see \"docker-machine profile\"",
+		},
+		cli.StringFlag{
+			Name:  "resume",
+			Usage: "Continue creating a machine whose creation failed part way",
+		},
+		cli.IntFlag{
+			Name:  "count",
+			Usage: "Number of identical machines to create, named after --name-template",
+			Value: 1,
+		},
+		cli.StringFlag{
+			Name:  "name-template",
+			Usage: "Template for the machine names when creating several, e.g. worker-%02d",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Usage: "Maximum number of machines to create at the same time",
+			Value: defaultParallelCreates,
+		},
+		cli.StringFlag{
+			Name:  "package-cache",
+			Usage: "Download the packages through a caching proxy: \"local\" to run one while the machines are created, or the host:port of one",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Give up creating after this long, e.g. 10m",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the resources the driver would allocate and how the machine would be provisioned, then exit without creating it",
+		},
+		cli.BoolFlag{
+			Name:  "interactive",
+			Usage: "Ask for the driver, its credentials and its options, listing those the driver can, then print the equivalent command before creating the machine",
 		},
 	}
 )
 
+// machineConfig is everything needed to create a machine, whether it was
+// given on the command line or read from a spec file.
+type machineConfig struct {
+	Name          string
+	DriverName    string
+	EngineOptions *engine.EngineOptions
+	SwarmOptions  *swarm.SwarmOptions
+
+	// AuthOptions only holds the paths of the certificates on the machine
+	// given for it, if any, the others being those of the store.
+	AuthOptions auth.AuthOptions
+
+	// DriverOpts turns the create flags supported by the driver into the
+	// options sent to it.
+	DriverOpts func(mcnFlags []mcnflag.Flag) (drivers.DriverOptions, error)
+
+	// UserData is the file of the user data template, see userdata.
+	UserData string
+
+	// TTL is how long after its creation the machine expires, never
+	// when 0.
+	TTL time.Duration
+}
+
 func cmdCreateInner(c *cli.Context) error {
 	if len(c.Args()) > 1 {
 		return fmt.Errorf("Invalid command line. Found extra arguments %v", c.Args()[1:])
 	}
 
-	name := c.Args().First()
-	driverName := c.String("driver")
-	certInfo := getCertPathInfoFromContext(c)
-
-	storePath := c.GlobalString("storage-path")
-
-	store := &persist.Filestore{
-		Path:             storePath,
-		CaCertPath:       certInfo.CaCertPath,
-		CaPrivateKeyPath: certInfo.CaPrivateKeyPath,
+	configFile, err := readConfig(configFilePath())
+	if err != nil {
+		return err
 	}
+	defaults := configFile.Create
 
-	if name == "" {
-		cli.ShowCommandHelp(c, "create")
-		return errNoMachineName
+	if profileName := c.String("profile"); profileName != "" {
+		profile, err := readProfile(profileName)
+		if err != nil {
+			return err
+		}
+		if err := applyProfile(c, profileName, profile); err != nil {
+			return err
+		}
 	}
 
-	validName := host.ValidateHostName(name)
-	if !validName {
-		return fmt.Errorf("Error creating machine: %s", mcnerror.ErrInvalidHostname)
+	if err := defaults.apply(c); err != nil {
+		return err
 	}
 
-	if err := validateSwarmDiscovery(c.String("swarm-discovery")); err != nil {
-		return fmt.Errorf("Error parsing swarm discovery: %s", err)
-	}
+	name := c.Args().First()
+	count := c.Int("count")
+	nameTemplate := c.String("name-template")
+	batch := count != 1 || nameTemplate != ""
 
-	// TODO: Fix hacky JSON solution
-	bareDriverData, err := json.Marshal(&drivers.BaseDriver{
-		MachineName: name,
-		StorePath:   c.GlobalString("storage-path"),
-	})
-	if err != nil {
-		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+	if name == "" && !batch {
+		cli.ShowCommandHelp(c, "create")
+		return errNoMachineName
 	}
 
-	driver, err := newPluginDriver(driverName, bareDriverData)
-	if err != nil {
-		return fmt.Errorf("Error loading driver %q: %s", driverName, err)
-	}
+	certInfo := getCertPathInfoFromContext(c)
 
-	h, err := store.NewHost(driver)
-	if err != nil {
-		return fmt.Errorf("Error getting new host: %s", err)
+	store := &persist.Filestore{
+		Path:             c.GlobalString("storage-path"),
+		CaCertPath:       certInfo.CaCertPath,
+		CaPrivateKeyPath: certInfo.CaPrivateKeyPath,
 	}
 
-	h.HostOptions = &host.HostOptions{
-		AuthOptions: &auth.AuthOptions{
-			CertDir:          mcndirs.GetMachineCertDir(),
-			CaCertPath:       certInfo.CaCertPath,
-			CaPrivateKeyPath: certInfo.CaPrivateKeyPath,
-			ClientCertPath:   certInfo.ClientCertPath,
-			ClientKeyPath:    certInfo.ClientKeyPath,
-			ServerCertPath:   filepath.Join(mcndirs.GetMachineDir(), name, "server.pem"),
-			ServerKeyPath:    filepath.Join(mcndirs.GetMachineDir(), name, "server-key.pem"),
-			StorePath:        filepath.Join(mcndirs.GetMachineDir(), name),
-		},
+	cfg := machineConfig{
+		Name:       name,
+		DriverName: c.String("driver"),
 		EngineOptions: &engine.EngineOptions{
 			ArbitraryFlags:   c.StringSlice("engine-opt"),
 			Env:              c.StringSlice("engine-env"),
 			InsecureRegistry: c.StringSlice("engine-insecure-registry"),
 			Labels:           c.StringSlice("engine-label"),
 			RegistryMirror:   c.StringSlice("engine-registry-mirror"),
+			RegistryCA:       c.StringSlice("engine-registry-ca"),
+			RegistryAuth:     c.StringSlice("engine-registry-auth"),
 			StorageDriver:    c.String("engine-storage-driver"),
 			TlsVerify:        true,
 			InstallURL:       c.String("engine-install-url"),
+			Hardening:        c.String("provision-hardening"),
+			AutoUpgrade:      c.Bool("engine-auto-upgrade"),
+			UpgradeWindow:    c.String("engine-upgrade-window"),
+			Transport:        c.String("engine-transport"),
+			OptionsDir:       c.String("engine-options-dir"),
+			OptionsFile:      c.String("engine-options-file"),
+		},
+		AuthOptions: auth.AuthOptions{
+			CaCertRemotePath:     c.String("engine-ca-cert-path"),
+			ServerCertRemotePath: c.String("engine-server-cert-path"),
+			ServerKeyRemotePath:  c.String("engine-server-key-path"),
 		},
 		SwarmOptions: &swarm.SwarmOptions{
 			IsSwarm:        c.Bool("swarm"),
@@ -198,15 +415,322 @@ func cmdCreateInner(c *cli.Context) error {
 			Host:           c.String("swarm-host"),
 			Strategy:       c.String("swarm-strategy"),
 			ArbitraryFlags: c.StringSlice("swarm-opt"),
+			Mode:           c.Bool("swarm-mode") || c.Bool("swarm-manager"),
+			Role:           c.String("swarm-mode-role"),
+			JoinManager:    c.String("swarm-mode-join"),
+		},
+		DriverOpts: func(mcnFlags []mcnflag.Flag) (drivers.DriverOptions, error) {
+			return getDriverOpts(c, mcnFlags), nil
 		},
+		UserData: c.String("user-data"),
+		TTL:      c.Duration("ttl"),
+	}
+
+	if cfg.TTL < 0 {
+		return fmt.Errorf("Error: --ttl must be positive, got %s", cfg.TTL)
+	}
+
+	if bastion := c.String("ssh-bastion"); bastion != "" {
+		if _, err := ssh.ParseBastion(bastion); err != nil {
+			return err
+		}
+	}
+
+	if err := ssh.ValidateKeyType(c.String("ssh-key-type")); err != nil {
+		return err
+	}
+
+	if c.Bool("swarm-manager") {
+		if cfg.SwarmOptions.Role == swarm.RoleWorker {
+			return errManagerRole
+		}
+		cfg.SwarmOptions.Role = swarm.RoleManager
+	}
+
+	ctx, cancel := commandContext(c.Duration("timeout"))
+	defer cancel()
+
+	if batch {
+		if name != "" {
+			return errNameAndCount
+		}
+
+		names, err := expandNameTemplate(nameTemplate, count)
+		if err != nil {
+			return err
+		}
+
+		cfgs, err := swarmModeBatch(cfg, names)
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("dry-run") {
+			return planMachines(store, certInfo, cfgs, os.Stdout)
+		}
+
+		return withPackageCache(store.Path, c.String("package-cache"), cfgs, func() error {
+			return createMachines(ctx, store, certInfo, cfgs, c.Int("parallel"))
+		})
+	}
+
+	if c.Bool("dry-run") {
+		return planMachines(store, certInfo, []machineConfig{cfg}, os.Stdout)
+	}
+
+	if err := withPackageCache(store.Path, c.String("package-cache"), []machineConfig{cfg}, func() error {
+		return createMachine(ctx, store, certInfo, cfg)
+	}); err != nil {
+		return err
+	}
+
+	log.Infof("To see how to connect Docker to this machine, run: %s", fmt.Sprintf("%s env %s", os.Args[0], name))
+
+	return nil
+}
+
+// withName returns a copy of cfg for a machine called name. The options are
+// copied too, so that machines created from the same configuration do not
+// share them.
+func (cfg machineConfig) withName(name string) machineConfig {
+	engineOptions := *cfg.EngineOptions
+	engineOptions.ArbitraryFlags = append([]string{}, engineOptions.ArbitraryFlags...)
+	engineOptions.Env = append([]string{}, engineOptions.Env...)
+	engineOptions.InsecureRegistry = append([]string{}, engineOptions.InsecureRegistry...)
+	engineOptions.Labels = append([]string{}, engineOptions.Labels...)
+	engineOptions.RegistryMirror = append([]string{}, engineOptions.RegistryMirror...)
+
+	swarmOptions := *cfg.SwarmOptions
+	swarmOptions.ArbitraryFlags = append([]string{}, swarmOptions.ArbitraryFlags...)
+
+	cfg.Name = name
+	cfg.EngineOptions = &engineOptions
+	cfg.SwarmOptions = &swarmOptions
+
+	return cfg
+}
+
+// swarmModeBatch returns the configurations of machines created together.
+// Managers forming a new swarm mode cluster bootstrap it from the first of
+// them, which the others join.
+func swarmModeBatch(cfg machineConfig, names []string) ([]machineConfig, error) {
+	cfgs := []machineConfig{}
+	for _, name := range names {
+		cfgs = append(cfgs, cfg.withName(name))
+	}
+
+	if !cfg.SwarmOptions.Mode || cfg.SwarmOptions.JoinManager != "" {
+		return cfgs, nil
+	}
+
+	if cfg.SwarmOptions.Role != swarm.RoleManager {
+		return nil, errSwarmModeBatch
+	}
+
+	if len(names)%2 == 0 {
+		return nil, errEvenManagers
+	}
+
+	if len(names) > maxSwarmManagers {
+		log.Warnf("More than %d managers slow down a swarm mode cluster, consider adding the other machines as workers", maxSwarmManagers)
+	}
+
+	for _, c := range cfgs[1:] {
+		c.SwarmOptions.JoinManager = names[0]
+	}
+
+	return cfgs, nil
+}
+
+// expandNameTemplate returns the names of count machines, formatting
+// nameTemplate with the numbers 0 to count-1.
+func expandNameTemplate(nameTemplate string, count int) ([]string, error) {
+	if count < 1 {
+		return nil, errInvalidCount
+	}
+
+	if nameTemplate == "" {
+		return nil, errNoNameTemplate
+	}
+
+	names := []string{}
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf(nameTemplate, i)
+		if strings.Contains(name, "%!") {
+			return nil, errInvalidNameFormat
+		}
+		names = append(names, name)
+	}
+
+	// A template without a verb formats every number to the same name.
+	if fmt.Sprintf(nameTemplate, 0) == fmt.Sprintf(nameTemplate, 1) {
+		return nil, errInvalidNameFormat
+	}
+
+	for _, name := range names {
+		if !host.ValidateHostName(name) {
+			return nil, fmt.Errorf("Error creating machine %q: %s", name, mcnerror.ErrInvalidHostname)
+		}
+	}
+
+	return names, nil
+}
+
+func createMachine(ctx context.Context, store *persist.Filestore, certInfo cert.CertPathInfo, cfg machineConfig) error {
+	h, driverOpts, err := prepareMachine(store, certInfo, cfg, nil)
+	if err != nil {
+		notifyMachine(notify.Error, cfg.Name, cfg.DriverName, nil, err)
+		return err
+	}
+
+	if err := runHook(ctx, hooks.PreCreate, h.Name, h.DriverName, nil); err != nil {
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+		return err
+	}
+
+	ctx, closeTranscript := withTranscript(ctx, h.Name)
+	defer closeTranscript()
+
+	if cfg.UserData != "" {
+		if err := writeUserData(store, h, cfg, driverOpts); err != nil {
+			removeUnsavedMachine(store, h.Name)
+			notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+			return err
+		}
+	}
+
+	if err := libmachine.CreateContext(ctx, store, h); err != nil {
+		if cfg.UserData != "" {
+			removeUnsavedMachine(store, h.Name)
+		}
+		if exists, _ := store.Exists(h.Name); exists {
+			log.Infof("To continue creating the machine once the problem is fixed, run: %s create --resume %s", os.Args[0], h.Name)
+		}
+		err = fmt.Errorf("Error creating machine: %w", err)
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+		return err
+	}
+
+	if err := saveHost(store, h); err != nil {
+		err = fmt.Errorf("Error attempting to save store: %w", err)
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+		return err
+	}
+
+	notifyMachine(notify.Created, h.Name, h.DriverName, h.Driver, nil)
+	updateHostsFile(h.Name, h.Driver)
+
+	return runHook(ctx, hooks.PostCreate, h.Name, h.DriverName, h.Driver)
+}
+
+// prepareMachine returns the host of a new machine, with its driver
+// configured from the flags, and the options it was given. Nothing is
+// created yet. The swarm mode manager the machine joins must exist, or be
+// one of the planned machines of a dry run.
+func prepareMachine(store *persist.Filestore, certInfo cert.CertPathInfo, cfg machineConfig, planned map[string]bool) (*host.Host, drivers.DriverOptions, error) {
+	name := cfg.Name
+
+	validName := host.ValidateHostName(name)
+	if !validName {
+		return nil, nil, fmt.Errorf("Error creating machine: %s", mcnerror.ErrInvalidHostname)
+	}
+
+	if err := validateSwarmDiscovery(cfg.SwarmOptions.Discovery); err != nil {
+		return nil, nil, fmt.Errorf("Error parsing swarm discovery: %w", err)
+	}
+
+	if err := cfg.SwarmOptions.ValidateMode(); err != nil {
+		return nil, nil, fmt.Errorf("Error in swarm mode options: %w", err)
+	}
+
+	if cfg.EngineOptions.Hardening != "" {
+		hardened, err := provision.HardenEngineOptions(*cfg.EngineOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error in --provision-hardening: %w", err)
+		}
+		cfg.EngineOptions = &hardened
+	}
+
+	registryOptions, err := provision.RegistryEngineOptions(*cfg.EngineOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error in --engine-registry-ca or --engine-registry-auth: %w", err)
+	}
+	cfg.EngineOptions = &registryOptions
+
+	if err := provision.CheckRemotePaths(*cfg.EngineOptions, cfg.AuthOptions); err != nil {
+		return nil, nil, fmt.Errorf("Error in the paths of the engine options and certificates: %w", err)
+	}
+
+	switch cfg.EngineOptions.Transport {
+	case "", engine.TransportTLS:
+	case engine.TransportSSH:
+		if cfg.SwarmOptions.IsSwarm && !cfg.SwarmOptions.Mode {
+			return nil, nil, errSSHTransportSwarm
+		}
+	default:
+		return nil, nil, errEngineTransport
+	}
+
+	if cfg.EngineOptions.UpgradeWindow != "" && !cfg.EngineOptions.AutoUpgrade {
+		return nil, nil, errUpgradeWindow
+	}
+
+	if _, err := provision.UpgradeWindow(*cfg.EngineOptions); err != nil {
+		return nil, nil, fmt.Errorf("Error in --engine-upgrade-window: %w", err)
+	}
+
+	// The engine and kernel versions of the machine are only known once it
+	// is created, provisioning checks the engine options against them.
+	if err := engine.CheckOptions(*cfg.EngineOptions, "", ""); err != nil {
+		return nil, nil, fmt.Errorf("Error in engine options: %w", err)
+	}
+
+	if cfg.SwarmOptions.JoinManager != "" && !planned[cfg.SwarmOptions.JoinManager] {
+		if exists, err := store.Exists(cfg.SwarmOptions.JoinManager); err != nil || !exists {
+			return nil, nil, fmt.Errorf("Error in swarm mode options: manager machine %q does not exist", cfg.SwarmOptions.JoinManager)
+		}
+	}
+
+	// TODO: Fix hacky JSON solution
+	bareDriverData, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: name,
+		StorePath:   store.Path,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error attempting to marshal bare driver data: %w", err)
+	}
+
+	driver, err := newPluginDriver(cfg.DriverName, bareDriverData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error loading driver %q: %s", cfg.DriverName, err)
+	}
+
+	h, err := store.NewHost(driver)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting new host: %w", err)
+	}
+
+	authOptions := newAuthOptions(certInfo, name)
+	authOptions.CaCertRemotePath = cfg.AuthOptions.CaCertRemotePath
+	authOptions.ServerCertRemotePath = cfg.AuthOptions.ServerCertRemotePath
+	authOptions.ServerKeyRemotePath = cfg.AuthOptions.ServerKeyRemotePath
+
+	h.HostOptions = &host.HostOptions{
+		AuthOptions:   authOptions,
+		EngineOptions: cfg.EngineOptions,
+		SwarmOptions:  cfg.SwarmOptions,
+	}
+
+	if cfg.TTL > 0 {
+		h.ExpiresAt = time.Now().Add(cfg.TTL)
 	}
 
 	exists, err := store.Exists(h.Name)
 	if err != nil {
-		return fmt.Errorf("Error checking if host exists: %s", err)
+		return nil, nil, fmt.Errorf("Error checking if host exists: %w", err)
 	}
 	if exists {
-		return mcnerror.ErrHostAlreadyExists{
+		return nil, nil, mcnerror.ErrHostAlreadyExists{
 			Name: h.Name,
 		}
 	}
@@ -215,18 +739,69 @@ func cmdCreateInner(c *cli.Context) error {
 	// driver parameters (an interface fulfilling drivers.DriverOptions,
 	// concrete type rpcdriver.RpcFlags).
 	mcnFlags := driver.GetCreateFlags()
-	driverOpts := getDriverOpts(c, mcnFlags)
+	driverOpts, err := cfg.DriverOpts(mcnFlags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.UserData != "" {
+		if err := prepareUserData(store, h, cfg, driverOpts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	storeID, err := store.ID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting the ID of the store: %s", err)
+	}
+	if flags, ok := driverOpts.(rpcdriver.RpcFlags); ok {
+		flags.Values[drivers.StoreIDOption] = storeID
+	}
 
 	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
-		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
+		return nil, nil, fmt.Errorf("Error setting machine configuration from flags provided: %w", err)
 	}
 
-	if err := libmachine.Create(store, h); err != nil {
-		return fmt.Errorf("Error creating machine: %s", err)
+	if len(driverOpts.StringSlice("network")) > 0 && !drivers.SupportsNetworks(h.Driver) {
+		return nil, nil, fmt.Errorf("Error setting machine configuration from flags provided: --network: %s", drivers.ErrNetworksNotImplemented)
 	}
 
-	if err := saveHost(store, h); err != nil {
-		return fmt.Errorf("Error attempting to save store: %s", err)
+	if driverOpts.String("attach-volume") != "" && !drivers.SupportsVolumes(h.Driver) {
+		return nil, nil, fmt.Errorf("Error setting machine configuration from flags provided: --attach-volume: %s", drivers.ErrVolumesNotImplemented)
+	}
+
+	if len(driverOpts.StringSlice("tag")) > 0 && !drivers.GetCapabilities(h.Driver).Tags {
+		return nil, nil, fmt.Errorf("Error setting machine configuration from flags provided: --tag: %s", drivers.ErrTagsNotImplemented)
+	}
+
+	return h, driverOpts, nil
+}
+
+func resumeCreate(c *cli.Context, name string, timeout time.Duration) error {
+	store := getStore(c)
+
+	h, err := loadHost(store, name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext(timeout)
+	defer cancel()
+
+	ctx, closeTranscript := withTranscript(ctx, h.Name)
+	defer closeTranscript()
+
+	if err := libmachine.ResumeCreateContext(ctx, store, h); err != nil {
+		err = fmt.Errorf("Error creating machine: %w", err)
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+		return err
+	}
+
+	notifyMachine(notify.Created, h.Name, h.DriverName, h.Driver, nil)
+	updateHostsFile(h.Name, h.Driver)
+
+	if err := runHook(ctx, hooks.PostCreate, h.Name, h.DriverName, h.Driver); err != nil {
+		return err
 	}
 
 	log.Infof("To see how to connect Docker to this machine, run: %s", fmt.Sprintf("%s env %s", os.Args[0], name))
@@ -234,6 +809,19 @@ func cmdCreateInner(c *cli.Context) error {
 	return nil
 }
 
+func newAuthOptions(certInfo cert.CertPathInfo, name string) *auth.AuthOptions {
+	return &auth.AuthOptions{
+		CertDir:          mcndirs.GetMachineCertDir(),
+		CaCertPath:       certInfo.CaCertPath,
+		CaPrivateKeyPath: certInfo.CaPrivateKeyPath,
+		ClientCertPath:   certInfo.ClientCertPath,
+		ClientKeyPath:    certInfo.ClientKeyPath,
+		ServerCertPath:   filepath.Join(mcndirs.GetMachineDir(), name, "server.pem"),
+		ServerKeyPath:    filepath.Join(mcndirs.GetMachineDir(), name, "server-key.pem"),
+		StorePath:        filepath.Join(mcndirs.GetMachineDir(), name),
+	}
+}
+
 // The following function is needed because the CLI acrobatics that we're doing
 // (with having an "outer" and "inner" function each with their own custom
 // settings and flag parsing needs) are not well supported by codegangsta/cli.
@@ -275,8 +863,50 @@ func cmdCreateOuter(c *cli.Context) error {
 		flagLookupMachineName = "flag-lookup"
 	)
 
+	// The wizard asks for the driver's flags itself, and runs create
+	// again with its answers.
+	for _, arg := range c.Args() {
+		if arg == "--interactive" || arg == "--interactive=true" {
+			return cmdCreateInteractive(c, c.Args())
+		}
+	}
+
+	// Resuming does not need the driver's flags, the machine already has
+	// its configuration.
+	if name := flagHackLookup("--resume"); name != "" {
+		var timeout time.Duration
+		if value := flagHackLookup("--timeout"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Invalid --timeout %q: %s", value, err)
+			}
+			timeout = d
+		}
+		return audited("create", resumedMachine(name), func(c *cli.Context) error {
+			return resumeCreate(c, name, timeout)
+		})(c)
+	}
+
+	configFile, err := readConfig(configFilePath())
+	if err != nil {
+		return err
+	}
+	defaults := configFile.Create
+
 	driverName := flagHackLookup("--driver")
 
+	if profileName := flagHackLookup("--profile"); profileName != "" && driverName == "" {
+		profile, err := readProfile(profileName)
+		if err != nil {
+			return err
+		}
+		driverName, _ = profile["driver"].(string)
+	}
+
+	if driverName == "" {
+		driverName = defaults.driverName()
+	}
+
 	// We didn't recognize the driver name.
 	if driverName == "" {
 		cli.ShowCommandHelp(c, "create")
@@ -288,7 +918,7 @@ func cmdCreateOuter(c *cli.Context) error {
 		MachineName: flagLookupMachineName,
 	})
 	if err != nil {
-		return fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+		return fmt.Errorf("Error attempting to marshal bare driver data: %w", err)
 	}
 
 	driver, err := newPluginDriver(driverName, bareDriverData)
@@ -311,7 +941,7 @@ func cmdCreateOuter(c *cli.Context) error {
 	// on the requested driver.
 	cliFlags, err := convertMcnFlagsToCliFlags(mcnFlags)
 	if err != nil {
-		return fmt.Errorf("Error trying to convert provided driver flags to cli flags: %s", err)
+		return fmt.Errorf("Error trying to convert provided driver flags to cli flags: %w", err)
 	}
 
 	for i := range c.App.Commands {
@@ -338,7 +968,7 @@ func getDriverOpts(c *cli.Context, mcnflags []mcnflag.Flag) drivers.DriverOption
 	// a machine over the wire (cli.Context is a no go since there is so
 	// much stuff in it).
 	driverOpts := rpcdriver.RpcFlags{
-		Values: make(map[string]interface{}),
+		Values: map[string]interface{}{drivers.StoreIDOption: ""},
 	}
 
 	for _, f := range mcnflags {
@@ -359,7 +989,15 @@ func getDriverOpts(c *cli.Context, mcnflags []mcnflag.Flag) drivers.DriverOption
 			driverOpts.Values[name] = c.StringSlice(name)
 			continue
 		}
-		driverOpts.Values[name] = getter.Get()
+		value := getter.Get()
+
+		// Durations, such as --timeout, are for the command itself, and
+		// cannot be sent to the driver over RPC.
+		if _, ok := value.(time.Duration); ok {
+			continue
+		}
+
+		driverOpts.Values[name] = value
 	}
 
 	return driverOpts
@@ -417,7 +1055,7 @@ func convertMcnFlagsToCliFlags(mcnFlags []mcnflag.Flag) ([]cli.Flag, error) {
 func addDriverFlagsToCommand(cliFlags []cli.Flag, cmd *cli.Command) *cli.Command {
 	cmd.Flags = append(sharedCreateFlags, cliFlags...)
 	cmd.SkipFlagParsing = false
-	cmd.Action = fatalOnError(cmdCreateInner)
+	cmd.Action = fatalOnError(audited("create", machineArgs, cmdCreateInner))
 	sort.Sort(ByFlagName(cmd.Flags))
 
 	return cmd
@@ -1,8 +1,14 @@
 package commands
 
 import (
+	"flag"
 	"testing"
 
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,3 +26,93 @@ func TestValidateSwarmDiscoveryAcceptsValidFormat(t *testing.T) {
 	err := validateSwarmDiscovery("token://deadbeefcafe")
 	assert.NoError(t, err)
 }
+
+func TestExpandNameTemplate(t *testing.T) {
+	names, err := expandNameTemplate("worker-%02d", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"worker-00", "worker-01", "worker-02"}, names)
+
+	_, err = expandNameTemplate("worker", 3)
+	assert.Equal(t, errInvalidNameFormat, err)
+
+	_, err = expandNameTemplate("worker-%s", 3)
+	assert.Equal(t, errInvalidNameFormat, err)
+
+	_, err = expandNameTemplate("", 3)
+	assert.Equal(t, errNoNameTemplate, err)
+
+	_, err = expandNameTemplate("worker-%d", 0)
+	assert.Equal(t, errInvalidCount, err)
+
+	_, err = expandNameTemplate("worker_%d!", 2)
+	assert.Error(t, err)
+}
+
+func TestMachineConfigWithNameCopiesOptions(t *testing.T) {
+	cfg := machineConfig{
+		Name:          "template",
+		EngineOptions: &engine.EngineOptions{Labels: []string{"a=b"}},
+		SwarmOptions:  &swarm.SwarmOptions{},
+	}
+
+	copied := cfg.withName("worker-00")
+	copied.EngineOptions.Labels[0] = "changed"
+
+	assert.Equal(t, "worker-00", copied.Name)
+	assert.Equal(t, "template", cfg.Name)
+	assert.Equal(t, []string{"a=b"}, cfg.EngineOptions.Labels)
+}
+
+func TestGetDriverOptsSkipsDurations(t *testing.T) {
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "swarm-host", Value: "tcp://0.0.0.0:3376"},
+		cli.DurationFlag{Name: "timeout"},
+	}
+
+	set := flag.NewFlagSet("create", flag.ContinueOnError)
+	for _, f := range flags {
+		f.Apply(set)
+	}
+	assert.NoError(t, set.Parse([]string{"--timeout", "10m"}))
+
+	c := cli.NewContext(nil, set, nil)
+	c.Command = cli.Command{Name: "create", Flags: flags}
+
+	driverOpts := getDriverOpts(c, []mcnflag.Flag{}).(rpcdriver.RpcFlags)
+	assert.Equal(t, map[string]interface{}{"swarm-host": "tcp://0.0.0.0:3376", "machine-store-id": ""}, driverOpts.Values)
+}
+
+func TestSwarmModeBatch(t *testing.T) {
+	cfg := machineConfig{
+		EngineOptions: &engine.EngineOptions{},
+		SwarmOptions:  &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager},
+	}
+
+	cfgs, err := swarmModeBatch(cfg, []string{"manager-0", "manager-1", "manager-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", cfgs[0].SwarmOptions.JoinManager)
+	assert.Equal(t, "manager-0", cfgs[1].SwarmOptions.JoinManager)
+	assert.Equal(t, "manager-0", cfgs[2].SwarmOptions.JoinManager)
+	assert.Equal(t, swarm.RoleManager, cfgs[2].SwarmOptions.Role)
+
+	first, joining := splitSwarmJoins(cfgs)
+	assert.Equal(t, 1, len(first))
+	assert.Equal(t, "manager-0", first[0].Name)
+	assert.Equal(t, 2, len(joining))
+
+	_, err = swarmModeBatch(cfg, []string{"manager-0", "manager-1"})
+	assert.Equal(t, errEvenManagers, err)
+
+	cfg.SwarmOptions.Role = ""
+	_, err = swarmModeBatch(cfg, []string{"node-0", "node-1", "node-2"})
+	assert.Equal(t, errSwarmModeBatch, err)
+
+	cfg.SwarmOptions.JoinManager = "manager-0"
+	cfgs, err = swarmModeBatch(cfg, []string{"worker-0", "worker-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "manager-0", cfgs[1].SwarmOptions.JoinManager)
+
+	first, joining = splitSwarmJoins(cfgs)
+	assert.Equal(t, 2, len(first))
+	assert.Equal(t, 0, len(joining))
+}
@@ -0,0 +1,301 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/drivers/errdriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+)
+
+// Where the default of a create flag comes from, from the highest
+// precedence to the lowest. Flags given on the command line take precedence
+// over all of them.
+const (
+	sourceEnv     = "env"
+	sourceConfig  = "config"
+	sourceDefault = "default"
+)
+
+// createDefaults are the values of create flags set in the config file,
+// keyed by flag name, e.g. "driver" or "virtualbox-memory".
+type createDefaults map[string]interface{}
+
+// flagDefault is the value a create flag gets when it is not given on the
+// command line.
+type flagDefault struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+func decodeCreateDefaults(c *config, encoded []byte) error {
+	if err := json.Unmarshal(encoded, &c.Create); err != nil {
+		return fmt.Errorf("expected \"create\" to be a mapping of flag names to values")
+	}
+
+	if c.Create == nil {
+		c.Create = createDefaults{}
+	}
+
+	return nil
+}
+
+// driverName returns the driver create uses when none is given on the
+// command line.
+func (d createDefaults) driverName() string {
+	if name := os.Getenv("MACHINE_DRIVER"); name != "" {
+		return name
+	}
+
+	values, err := configValues(d["driver"])
+	if err != nil || len(values) != 1 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// resolve returns the value f gets when it is not given on the command
+// line: from its environment variable, from the config file, or its
+// default, in that order.
+func (d createDefaults) resolve(f cli.Flag) (flagDefault, error) {
+	name := flagName(f)
+
+	for _, envVar := range strings.Split(flagEnvVar(f), ",") {
+		envVar = strings.TrimSpace(envVar)
+		if envVar != "" && os.Getenv(envVar) != "" {
+			return flagDefault{name, os.Getenv(envVar), sourceEnv}, nil
+		}
+	}
+
+	if raw, ok := d[name]; ok {
+		values, err := configValues(raw)
+		if err != nil {
+			return flagDefault{}, fmt.Errorf("Error in config file value of %q: %s", name, err)
+		}
+		if _, ok := f.(cli.StringSliceFlag); !ok && len(values) > 1 {
+			return flagDefault{}, fmt.Errorf("Error in config file value of %q: expected a single value", name)
+		}
+		return flagDefault{name, strings.Join(values, ","), sourceConfig}, nil
+	}
+
+	return flagDefault{name, flagDefaultValue(f), sourceDefault}, nil
+}
+
+// apply sets the flags of a create command line which are given neither on
+// the command line nor in the environment to their value in the config
+// file.
+func (d createDefaults) apply(c *cli.Context) error {
+	for _, f := range c.Command.Flags {
+		if flagIsSet(c, f) {
+			continue
+		}
+
+		resolved, err := d.resolve(f)
+		if err != nil {
+			return err
+		}
+		if resolved.Source != sourceConfig {
+			continue
+		}
+
+		if err := setFlag(c, resolved.Name, d[resolved.Name]); err != nil {
+			return fmt.Errorf("Error in config file value of %q: %s", resolved.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// setFlag sets a flag to a value from the config file or a profile, as if
+// it had been given on the command line.
+func setFlag(c *cli.Context, name string, raw interface{}) error {
+	values, err := configValues(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if err := c.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configValues turns a value from the config file into the strings a flag
+// would be given on the command line. Lists give several values, for the
+// flags which can be repeated.
+func configValues(raw interface{}) ([]string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			itemValues, err := configValues(item)
+			if err != nil {
+				return nil, err
+			}
+			if len(itemValues) != 1 {
+				return nil, fmt.Errorf("unexpected value %v in list", item)
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unexpected value %v", raw)
+}
+
+func flagName(f cli.Flag) string {
+	return strings.TrimSpace(strings.Split(flagNames(f), ",")[0])
+}
+
+// flagIsSet reports whether f was given on the command line under any of
+// its names.
+func flagIsSet(c *cli.Context, f cli.Flag) bool {
+	for _, name := range strings.Split(flagNames(f), ",") {
+		if c.IsSet(strings.TrimSpace(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func flagNames(f cli.Flag) string {
+	switch f := f.(type) {
+	case cli.StringFlag:
+		return f.Name
+	case cli.StringSliceFlag:
+		return f.Name
+	case cli.BoolFlag:
+		return f.Name
+	case cli.IntFlag:
+		return f.Name
+	case cli.DurationFlag:
+		return f.Name
+	}
+	return ""
+}
+
+func flagEnvVar(f cli.Flag) string {
+	switch f := f.(type) {
+	case cli.StringFlag:
+		return f.EnvVar
+	case cli.StringSliceFlag:
+		return f.EnvVar
+	case cli.BoolFlag:
+		return f.EnvVar
+	case cli.IntFlag:
+		return f.EnvVar
+	case cli.DurationFlag:
+		return f.EnvVar
+	}
+	return ""
+}
+
+func flagDefaultValue(f cli.Flag) string {
+	switch f := f.(type) {
+	case cli.StringFlag:
+		return f.Value
+	case cli.StringSliceFlag:
+		if f.Value == nil {
+			return ""
+		}
+		return strings.Join(f.Value.Value(), ",")
+	case cli.BoolFlag:
+		return "false"
+	case cli.IntFlag:
+		return strconv.Itoa(f.Value)
+	case cli.DurationFlag:
+		return f.Value.String()
+	}
+	return ""
+}
+
+// cmdConfigDefaultsShow prints the value every create flag gets when it is
+// not given on the command line, and where that value comes from.
+func cmdConfigDefaultsShow(c *cli.Context) error {
+	cfg, err := readConfig(configFilePath())
+	if err != nil {
+		return err
+	}
+	defaults := cfg.Create
+
+	flags := append([]cli.Flag{}, sharedCreateFlags...)
+
+	if driverName := defaults.driverName(); driverName != "" {
+		driverFlags, err := driverCreateFlags(driverName)
+		if err != nil {
+			return fmt.Errorf("Error loading driver %q: %s", driverName, err)
+		}
+		flags = append(flags, driverFlags...)
+	}
+
+	sort.Sort(ByFlagName(flags))
+
+	resolved := []flagDefault{}
+	for _, f := range flags {
+		r, err := defaults.resolve(f)
+		if err != nil {
+			return err
+		}
+		resolved = append(resolved, r)
+	}
+
+	fmt.Printf("Config file: %s\n\n", configFilePath())
+	printFlagDefaults(os.Stdout, resolved)
+
+	return nil
+}
+
+func printFlagDefaults(w io.Writer, resolved []flagDefault) {
+	tw := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(tw, "FLAG\tVALUE\tSOURCE")
+	for _, r := range resolved {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Value, r.Source)
+	}
+	tw.Flush()
+}
+
+// driverCreateFlags returns the create flags of a driver as cli flags.
+func driverCreateFlags(driverName string) ([]cli.Flag, error) {
+	bareDriverData, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: "flag-lookup",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := newPluginDriver(driverName, bareDriverData)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := driver.(*errdriver.Driver); ok {
+		return nil, errdriver.ErrDriverNotLoadable{Name: driverName}
+	}
+
+	if rpcd, ok := driver.(*rpcdriver.RpcClientDriver); ok {
+		defer rpcd.Close()
+	}
+
+	return convertMcnFlagsToCliFlags(driver.GetCreateFlags())
+}
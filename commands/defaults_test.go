@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+create:
+  driver: virtualbox
+  virtualbox-memory: 2048
+  engine-opt:
+    - log-driver=journald
+    - dns=8.8.8.8
+  swarm: true
+  swarm-strategy: binpack
+`
+
+func TestParseCreateDefaults(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+	d := cfg.Create
+
+	assert.Equal(t, "virtualbox", d.driverName())
+	assert.Equal(t, float64(2048), d["virtualbox-memory"])
+	assert.Equal(t, true, d["swarm"])
+}
+
+func TestParseCreateDefaultsEmpty(t *testing.T) {
+	cfg, err := parseConfig([]byte("# nothing yet\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, createDefaults{}, cfg.Create)
+
+	cfg, err = parseConfig([]byte("create:\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, createDefaults{}, cfg.Create)
+}
+
+func TestParseCreateDefaultsErrors(t *testing.T) {
+	_, err := parseConfig([]byte("- driver\n"))
+	assert.EqualError(t, err, "expected a mapping at the top level")
+
+	_, err = parseConfig([]byte("craete:\n  driver: virtualbox\n"))
+	assert.EqualError(t, err, `unknown section "craete"`)
+
+	_, err = parseConfig([]byte("create: virtualbox\n"))
+	assert.Error(t, err)
+}
+
+func TestReadConfigMissingFile(t *testing.T) {
+	cfg, err := readConfig("/nonexistent/config.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, createDefaults{}, cfg.Create)
+	assert.Empty(t, cfg.Notify)
+	assert.Nil(t, cfg.HostsFile)
+}
+
+func TestResolveFlagDefault(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+	d := cfg.Create
+
+	strategy := cli.StringFlag{Name: "swarm-strategy", Value: "spread", EnvVar: "TEST_SWARM_STRATEGY"}
+	host := cli.StringFlag{Name: "swarm-host", Value: "tcp://0.0.0.0:3376"}
+	opts := cli.StringSliceFlag{Name: "engine-opt", Value: &cli.StringSlice{}}
+
+	r, err := d.resolve(strategy)
+	assert.NoError(t, err)
+	assert.Equal(t, flagDefault{"swarm-strategy", "binpack", sourceConfig}, r)
+
+	os.Setenv("TEST_SWARM_STRATEGY", "random")
+	defer os.Unsetenv("TEST_SWARM_STRATEGY")
+
+	r, err = d.resolve(strategy)
+	assert.NoError(t, err)
+	assert.Equal(t, flagDefault{"swarm-strategy", "random", sourceEnv}, r)
+
+	r, err = d.resolve(host)
+	assert.NoError(t, err)
+	assert.Equal(t, flagDefault{"swarm-host", "tcp://0.0.0.0:3376", sourceDefault}, r)
+
+	r, err = d.resolve(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, flagDefault{"engine-opt", "log-driver=journald,dns=8.8.8.8", sourceConfig}, r)
+
+	_, err = createDefaults{"swarm-host": []interface{}{"a", "b"}}.resolve(host)
+	assert.EqualError(t, err, `Error in config file value of "swarm-host": expected a single value`)
+}
+
+func TestApplyCreateDefaults(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+	d := cfg.Create
+
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "driver, d", Value: "none"},
+		cli.IntFlag{Name: "virtualbox-memory", Value: 1024},
+		cli.StringSliceFlag{Name: "engine-opt", Value: &cli.StringSlice{}},
+		cli.BoolFlag{Name: "swarm"},
+		cli.StringFlag{Name: "swarm-strategy", Value: "spread"},
+		cli.StringFlag{Name: "swarm-host", Value: "tcp://0.0.0.0:3376"},
+	}
+
+	set := flag.NewFlagSet("create", flag.ContinueOnError)
+	for _, f := range flags {
+		f.Apply(set)
+	}
+	assert.NoError(t, set.Parse([]string{"--driver", "amazonec2", "--engine-opt", "debug=true", "dev"}))
+
+	c := cli.NewContext(nil, set, nil)
+	c.Command = cli.Command{Name: "create", Flags: flags}
+
+	assert.NoError(t, d.apply(c))
+
+	assert.Equal(t, "amazonec2", c.String("driver"))
+	assert.Equal(t, 2048, c.Int("virtualbox-memory"))
+	assert.Equal(t, []string{"debug=true"}, c.StringSlice("engine-opt"))
+	assert.True(t, c.Bool("swarm"))
+	assert.Equal(t, "binpack", c.String("swarm-strategy"))
+	assert.Equal(t, "tcp://0.0.0.0:3376", c.String("swarm-host"))
+	assert.Equal(t, []string{"dev"}, []string(c.Args()))
+}
+
+func TestParseNotifySinks(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig + `
+notify:
+  - webhook: https://hooks.example.com/machine
+    events: [created, removed]
+  - exec: /usr/local/bin/inventory
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []notify.Sink{
+		{Webhook: "https://hooks.example.com/machine", Events: []string{"created", "removed"}},
+		{Exec: notify.Command{"/usr/local/bin/inventory"}},
+	}, cfg.Notify)
+
+	cfg, err = parseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.Notify)
+
+	_, err = parseConfig([]byte(testConfig + "notify:\n  - exec: /usr/local/bin/inventory\n"))
+	assert.NoError(t, err)
+
+	_, err = parseConfig([]byte("notify:\n  - events: [created]\n"))
+	assert.EqualError(t, err, "notify sink 1: expected a sink to have either a webhook or an exec")
+}
+
+func TestParseHookCommands(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig + `
+hooks:
+  post-create:
+    - /usr/local/bin/register-dns
+    - [smoke-test, --quick]
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]notify.Command{
+		"post-create": {{"/usr/local/bin/register-dns"}, {"smoke-test", "--quick"}},
+	}, cfg.Hooks)
+
+	_, err = parseConfig([]byte(testConfig + "hooks:\n  pre-rm: [deregister-dns]\n"))
+	assert.NoError(t, err)
+
+	_, err = parseConfig([]byte("hooks:\n  post-rm: [deregister-dns]\n"))
+	assert.EqualError(t, err, `unknown hook "post-rm", expected one of [pre-create post-create pre-rm post-provision]`)
+}
@@ -0,0 +1,423 @@
+package commands
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/health"
+	"github.com/docker/machine/libmachine/host"
+)
+
+type diagnosisStatus string
+
+const (
+	diagnosisOK      diagnosisStatus = "ok"
+	diagnosisWarning diagnosisStatus = "warning"
+	diagnosisFailed  diagnosisStatus = "failed"
+)
+
+// diagnosis is the outcome of a check of the doctor command, with the
+// remedy of the problem it found.
+type diagnosis struct {
+	Check   string
+	Status  diagnosisStatus
+	Message string
+	Remedy  string
+}
+
+var (
+	doctorLookPath  = exec.LookPath
+	doctorGOOS      = runtime.GOOS
+	doctorCmdOutput = func(name string, args ...string) (string, error) {
+		output, err := exec.Command(name, args...).Output()
+		return string(output), err
+	}
+
+	procModulesPath = "/proc/modules"
+	kvmDevicePath   = "/dev/kvm"
+)
+
+// machineRemedies are the remedies of the health checks failing on a
+// machine, formatted with its name.
+var machineRemedies = map[string]string{
+	health.CheckRunning: "Start it with `docker-machine start %s`",
+	health.CheckSSH:     "Check the firewall of the machine lets SSH in, `docker-machine --debug ssh %s` shows why connecting fails",
+	health.CheckDaemon:  "Restart the engine with `docker-machine healthcheck --heal %s`",
+	health.CheckTLS:     "Regenerate the certificates with `docker-machine regenerate-certs %s`",
+	health.CheckDisk:    "Free some space with `docker-machine ssh %s docker system prune`",
+	health.CheckSwarm:   "Rejoin the swarm with `docker-machine healthcheck --heal %s`",
+}
+
+func cmdDoctor(c *cli.Context) error {
+	store := getStore(c)
+
+	stored, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	certInfo := getCertPathInfoFromContext(c)
+	diagnoses := []diagnosis{
+		diagnoseStore(c.GlobalString("storage-path")),
+		diagnoseCerts(certInfo, len(stored) > 0),
+		diagnoseSSH(c.GlobalBool("native-ssh")),
+		diagnoseHypervisor(stored),
+		diagnosePlugins(stored),
+	}
+
+	if !c.Bool("local") {
+		hosts, err := machinesToDiagnose(c)
+		if err != nil {
+			return err
+		}
+		for _, h := range hosts {
+			diagnoses = append(diagnoses, diagnoseMachine(h)...)
+		}
+	}
+
+	printDiagnoses(os.Stdout, diagnoses)
+
+	failed := 0
+	for _, d := range diagnoses {
+		if d.Status == diagnosisFailed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(diagnoses))
+	}
+
+	return nil
+}
+
+// machinesToDiagnose returns the machines named in the arguments, or every
+// machine whose driver can be loaded, the others being diagnosed by the
+// plugins check.
+func machinesToDiagnose(c *cli.Context) ([]*host.Host, error) {
+	if len(c.Args()) > 0 {
+		return getHostsFromContext(c)
+	}
+
+	hosts, _ := newClient(getStore(c)).List()
+	return hosts, nil
+}
+
+// diagnoseStore checks the storage path can be written, and that other
+// users can't write to it.
+func diagnoseStore(path string) diagnosis {
+	d := diagnosis{Check: "store", Status: diagnosisOK}
+
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		d.Message = fmt.Sprintf("%s will be created with the first machine", path)
+		return d
+	}
+	if err != nil {
+		return failedDiagnosis(d, err.Error(), fmt.Sprintf("Check the permissions of %s", path))
+	}
+	if !fi.IsDir() {
+		return failedDiagnosis(d, fmt.Sprintf("%s is not a directory", path), "Move it away, or give another storage path with --storage-path")
+	}
+
+	f, err := ioutil.TempFile(path, ".doctor")
+	if err != nil {
+		return failedDiagnosis(d, fmt.Sprintf("%s can't be written: %s", path, err), fmt.Sprintf("Give your user the ownership of %s", path))
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	if doctorGOOS != "windows" && fi.Mode().Perm()&0022 != 0 {
+		d.Status = diagnosisWarning
+		d.Message = fmt.Sprintf("%s can be written by other users", path)
+		d.Remedy = fmt.Sprintf("Restrict it with `chmod go-w %s`", path)
+		return d
+	}
+
+	d.Message = path
+	return d
+}
+
+// diagnoseCerts checks the CA and client certificates are there, match their
+// keys, haven't expired and that the client certificate is signed by the CA.
+// They are only needed once there are machines.
+func diagnoseCerts(info cert.CertPathInfo, hasMachines bool) diagnosis {
+	d := diagnosis{Check: "certs", Status: diagnosisOK}
+	regenerate := fmt.Sprintf("Move %s away, then run `docker-machine regenerate-certs --force` on every machine to create new certificates", filepath.Dir(info.CaCertPath))
+
+	if _, err := os.Stat(info.CaCertPath); os.IsNotExist(err) {
+		if !hasMachines {
+			d.Message = "the certificates will be created with the first machine"
+			return d
+		}
+		return failedDiagnosis(d, fmt.Sprintf("%s is missing", info.CaCertPath), regenerate)
+	}
+
+	ca, err := loadCertificate(info.CaCertPath, info.CaPrivateKeyPath)
+	if err != nil {
+		return failedDiagnosis(d, err.Error(), regenerate)
+	}
+	client, err := loadCertificate(info.ClientCertPath, info.ClientKeyPath)
+	if err != nil {
+		return failedDiagnosis(d, err.Error(), regenerate)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	if _, err := client.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		return failedDiagnosis(d, fmt.Sprintf("%s is not signed by %s: %s", info.ClientCertPath, info.CaCertPath, err), regenerate)
+	}
+
+	if doctorGOOS != "windows" {
+		for _, key := range []string{info.CaPrivateKeyPath, info.ClientKeyPath} {
+			if fi, err := os.Stat(key); err == nil && fi.Mode().Perm()&0077 != 0 {
+				d.Status = diagnosisWarning
+				d.Message = fmt.Sprintf("%s can be read by other users", key)
+				d.Remedy = fmt.Sprintf("Restrict it with `chmod 600 %s`", key)
+				return d
+			}
+		}
+	}
+
+	d.Message = fmt.Sprintf("the CA expires on %s", ca.NotAfter.Format("2006-01-02"))
+	return d
+}
+
+// loadCertificate returns the certificate of a certificate and key pair,
+// failing when they don't match or the certificate has expired.
+func loadCertificate(certPath, keyPath string) (*x509.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s and %s can't be loaded: %s", certPath, keyPath, err)
+	}
+
+	c, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s can't be parsed: %s", certPath, err)
+	}
+
+	if time.Now().After(c.NotAfter) {
+		return nil, fmt.Errorf("%s expired on %s", certPath, c.NotAfter.Format("2006-01-02"))
+	}
+
+	return c, nil
+}
+
+// diagnoseSSH checks the ssh binary used to connect to the machines is
+// there, unless the native client is used.
+func diagnoseSSH(native bool) diagnosis {
+	d := diagnosis{Check: "ssh", Status: diagnosisOK}
+
+	if native {
+		d.Message = "the native SSH client is used"
+		return d
+	}
+
+	path, err := doctorLookPath("ssh")
+	if err != nil {
+		d.Status = diagnosisWarning
+		d.Message = "no ssh binary found, the native SSH client is used instead"
+		d.Remedy = "Install an OpenSSH client for `docker-machine ssh` to use your SSH config and agent"
+		return d
+	}
+
+	d.Message = path
+	return d
+}
+
+// diagnoseHypervisor checks the hypervisors of the local drivers the
+// machines use are installed, and that VirtualBox isn't prevented from
+// running by another hypervisor.
+func diagnoseHypervisor(stored []*host.Host) diagnosis {
+	d := diagnosis{Check: "hypervisor", Status: diagnosisOK}
+	used := usedDrivers(stored)
+
+	vboxManage := findVBoxManage()
+
+	if vboxManage == "" {
+		if len(used["virtualbox"]) > 0 {
+			return failedDiagnosis(d, fmt.Sprintf("VBoxManage not found, used by %s", strings.Join(used["virtualbox"], ", ")), "Install VirtualBox, or add the directory of VBoxManage to PATH")
+		}
+	} else {
+		switch {
+		case doctorGOOS == "windows" && hyperVRunning():
+			return failedDiagnosis(d, "Hyper-V is running, which keeps VirtualBox from starting its machines",
+				"Turn Hyper-V off with `bcdedit /set hypervisorlaunchtype off` and reboot, or create the machines with the hyperv driver")
+		case doctorGOOS == "linux" && kvmLoaded():
+			d.Status = diagnosisWarning
+			d.Message = "the KVM modules are loaded, which VirtualBox before 6.1 can't run alongside"
+			d.Remedy = "Unload them with `sudo modprobe -r kvm_intel kvm_amd`, or create the machines with the kvm driver"
+			return d
+		}
+	}
+
+	if len(used["kvm"]) > 0 {
+		if _, err := os.Stat(kvmDevicePath); err != nil {
+			return failedDiagnosis(d, fmt.Sprintf("%s not found, used by %s", kvmDevicePath, strings.Join(used["kvm"], ", ")),
+				"Turn on virtualization in the BIOS and load the KVM modules with `sudo modprobe kvm_intel` or `sudo modprobe kvm_amd`")
+		}
+	}
+
+	if vboxManage != "" {
+		d.Message = fmt.Sprintf("VirtualBox found at %s", vboxManage)
+	}
+	return d
+}
+
+// findVBoxManage returns the path of VBoxManage, or "" when VirtualBox
+// isn't installed.
+func findVBoxManage() string {
+	if path, err := doctorLookPath("VBoxManage"); err == nil {
+		return path
+	}
+
+	if doctorGOOS == "windows" {
+		for _, dir := range []string{os.Getenv("VBOX_MSI_INSTALL_PATH"), os.Getenv("VBOX_INSTALL_PATH"), `C:\Program Files\Oracle\VirtualBox`} {
+			if dir == "" {
+				continue
+			}
+			if path, err := doctorLookPath(filepath.Join(dir, "VBoxManage")); err == nil {
+				return path
+			}
+		}
+	}
+
+	return ""
+}
+
+// hyperVRunning tells whether Windows runs under a hypervisor, which is
+// Hyper-V, systeminfo not listing the requirements of Hyper-V then.
+func hyperVRunning() bool {
+	output, err := doctorCmdOutput("systeminfo")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(output, "A hypervisor has been detected")
+}
+
+func kvmLoaded() bool {
+	modules, err := ioutil.ReadFile(procModulesPath)
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(modules), "\n") {
+		if strings.HasPrefix(line, "kvm_intel ") || strings.HasPrefix(line, "kvm_amd ") {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnosePlugins checks the plugin binaries of the drivers the machines
+// use are in the PATH.
+func diagnosePlugins(stored []*host.Host) diagnosis {
+	d := diagnosis{Check: "plugins", Status: diagnosisOK}
+	used := usedDrivers(stored)
+
+	names := []string{}
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	missing := []string{}
+	for _, name := range names {
+		if _, err := doctorLookPath(fmt.Sprintf("docker-machine-driver-%s", name)); err != nil {
+			missing = append(missing, fmt.Sprintf("docker-machine-driver-%s (used by %s)", name, strings.Join(used[name], ", ")))
+		}
+	}
+
+	if len(missing) > 0 {
+		return failedDiagnosis(d, fmt.Sprintf("not found: %s", strings.Join(missing, ", ")), "Install the driver plugins in a directory of PATH")
+	}
+
+	if len(names) == 0 {
+		d.Message = "no machines"
+	} else {
+		d.Message = fmt.Sprintf("found the plugins of %s", strings.Join(names, ", "))
+	}
+	return d
+}
+
+// usedDrivers returns the names of the machines using each driver.
+func usedDrivers(stored []*host.Host) map[string][]string {
+	used := map[string][]string{}
+	for _, h := range stored {
+		used[h.DriverName] = append(used[h.DriverName], h.Name)
+	}
+	return used
+}
+
+// diagnoseMachine runs the health checks of the machine, the skipped checks
+// being left out.
+func diagnoseMachine(h *host.Host) []diagnosis {
+	diagnoses := []diagnosis{}
+
+	for _, res := range health.Check(h).Results {
+		d := diagnosis{
+			Check:   fmt.Sprintf("%s/%s", h.Name, res.Check),
+			Message: res.Message,
+		}
+
+		switch res.Status {
+		case health.StatusSkipped:
+			continue
+		case health.StatusOK:
+			d.Status = diagnosisOK
+		default:
+			d.Status = diagnosisFailed
+			if remedy, ok := machineRemedies[res.Check]; ok {
+				d.Remedy = fmt.Sprintf(remedy, h.Name)
+			}
+		}
+
+		diagnoses = append(diagnoses, d)
+	}
+
+	return diagnoses
+}
+
+func failedDiagnosis(d diagnosis, message, remedy string) diagnosis {
+	d.Status = diagnosisFailed
+	d.Message = message
+	d.Remedy = remedy
+	return d
+}
+
+func printDiagnoses(out io.Writer, diagnoses []diagnosis) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tMESSAGE")
+	for _, d := range diagnoses {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Check, d.Status, d.Message)
+	}
+	w.Flush()
+
+	remedies := []diagnosis{}
+	for _, d := range diagnoses {
+		if d.Remedy != "" {
+			remedies = append(remedies, d)
+		}
+	}
+	if len(remedies) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, "\nTo fix the problems found:")
+	for _, d := range remedies {
+		fmt.Fprintf(out, "  %s: %s\n", d.Check, d.Remedy)
+	}
+}
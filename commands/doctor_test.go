@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/stretchr/testify/assert"
+)
+
+func withDoctorEnv(goos string, paths map[string]string) func() {
+	lookPath, savedGOOS := doctorLookPath, doctorGOOS
+
+	doctorGOOS = goos
+	doctorLookPath = func(file string) (string, error) {
+		if path, ok := paths[file]; ok {
+			return path, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	return func() {
+		doctorLookPath, doctorGOOS = lookPath, savedGOOS
+	}
+}
+
+func generateTestCerts(t *testing.T, dir string) cert.CertPathInfo {
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+
+	info := cert.CertPathInfo{
+		CaCertPath:       filepath.Join(dir, "ca.pem"),
+		CaPrivateKeyPath: filepath.Join(dir, "ca-key.pem"),
+		ClientCertPath:   filepath.Join(dir, "cert.pem"),
+		ClientKeyPath:    filepath.Join(dir, "key.pem"),
+	}
+
+	generator := cert.NewX509CertGenerator()
+	assert.NoError(t, generator.GenerateCACertificate(info.CaCertPath, info.CaPrivateKeyPath, "test", 2048))
+	assert.NoError(t, generator.GenerateCert([]string{""}, info.ClientCertPath, info.ClientKeyPath, info.CaCertPath, info.CaPrivateKeyPath, "test", 2048))
+	assert.NoError(t, os.Chmod(info.CaPrivateKeyPath, 0600))
+	assert.NoError(t, os.Chmod(info.ClientKeyPath, 0600))
+
+	return info
+}
+
+func TestDiagnoseStore(t *testing.T) {
+	defer withDoctorEnv("linux", nil)()
+
+	dir, err := ioutil.TempDir("", "machine-doctor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Equal(t, diagnosisOK, diagnoseStore(filepath.Join(dir, "missing")).Status)
+
+	assert.NoError(t, os.Chmod(dir, 0700))
+	assert.Equal(t, diagnosisOK, diagnoseStore(dir).Status)
+
+	assert.NoError(t, os.Chmod(dir, 0777))
+	d := diagnoseStore(dir)
+	assert.Equal(t, diagnosisWarning, d.Status)
+	assert.Contains(t, d.Remedy, "chmod go-w")
+}
+
+func TestDiagnoseCerts(t *testing.T) {
+	defer withDoctorEnv("linux", nil)()
+
+	dir, err := ioutil.TempDir("", "machine-doctor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	missing := cert.CertPathInfo{CaCertPath: filepath.Join(dir, "missing", "ca.pem")}
+	assert.Equal(t, diagnosisOK, diagnoseCerts(missing, false).Status)
+	assert.Equal(t, diagnosisFailed, diagnoseCerts(missing, true).Status)
+
+	info := generateTestCerts(t, filepath.Join(dir, "certs"))
+	assert.Equal(t, diagnosisOK, diagnoseCerts(info, true).Status)
+
+	other := generateTestCerts(t, filepath.Join(dir, "other"))
+	info.CaCertPath, info.CaPrivateKeyPath = other.CaCertPath, other.CaPrivateKeyPath
+	d := diagnoseCerts(info, true)
+	assert.Equal(t, diagnosisFailed, d.Status)
+	assert.Contains(t, d.Message, "is not signed by")
+}
+
+func TestDiagnosePlugins(t *testing.T) {
+	defer withDoctorEnv("linux", map[string]string{"docker-machine-driver-virtualbox": "/usr/bin/docker-machine-driver-virtualbox"})()
+
+	stored := []*host.Host{
+		{Name: "dev", DriverName: "virtualbox"},
+		{Name: "web", DriverName: "amazonec2"},
+		{Name: "db", DriverName: "amazonec2"},
+	}
+
+	d := diagnosePlugins(stored)
+	assert.Equal(t, diagnosisFailed, d.Status)
+	assert.Equal(t, "not found: docker-machine-driver-amazonec2 (used by web, db)", d.Message)
+
+	assert.Equal(t, diagnosisOK, diagnosePlugins(stored[:1]).Status)
+}
+
+func TestDiagnoseHypervisor(t *testing.T) {
+	stored := []*host.Host{{Name: "dev", DriverName: "virtualbox"}}
+
+	restore := withDoctorEnv("linux", nil)
+	d := diagnoseHypervisor(stored)
+	restore()
+	assert.Equal(t, diagnosisFailed, d.Status)
+	assert.Contains(t, d.Message, "VBoxManage not found, used by dev")
+
+	modules, err := ioutil.TempFile("", "machine-doctor")
+	assert.NoError(t, err)
+	defer os.Remove(modules.Name())
+	modules.WriteString("kvm_intel 245760 0 - Live 0x0000000000000000\nkvm 737280 1 kvm_intel, Live 0x0000000000000000\n")
+	modules.Close()
+
+	defer func(path string) { procModulesPath = path }(procModulesPath)
+	procModulesPath = modules.Name()
+
+	defer withDoctorEnv("linux", map[string]string{"VBoxManage": "/usr/bin/VBoxManage"})()
+	d = diagnoseHypervisor(stored)
+	assert.Equal(t, diagnosisWarning, d.Status)
+	assert.Contains(t, d.Remedy, "modprobe -r")
+}
+
+func TestPrintDiagnoses(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	printDiagnoses(out, []diagnosis{
+		{Check: "ssh", Status: diagnosisOK, Message: "/usr/bin/ssh"},
+		{Check: "dev/running", Status: diagnosisFailed, Message: "machine is Stopped", Remedy: "Start it with `docker-machine start dev`"},
+	})
+
+	assert.Equal(t, `CHECK         STATUS   MESSAGE
+ssh           ok       /usr/bin/ssh
+dev/running   failed   machine is Stopped
+
+To fix the problems found:
+  dev/running: Start it with `+"`docker-machine start dev`"+`
+`, out.String())
+}
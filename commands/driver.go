@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/drivers/errdriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/spec"
+)
+
+var (
+	errExpectedOneDriver = errors.New("Error: Expected one driver name as an argument")
+	errInvalidOptionKind = errors.New("Error: --kind must be regions, images or sizes")
+)
+
+// optionKinds are the kinds of values driver options lists, in order.
+var optionKinds = []string{"regions", "images", "sizes"}
+
+// inspectedDriver is the driver printed by driver inspect.
+type inspectedDriver struct {
+	Name         string
+	Capabilities drivers.Capabilities
+}
+
+func cmdDriverInspect(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "inspect")
+		return errExpectedOneDriver
+	}
+
+	driverName := c.Args().First()
+
+	driver, err := loadDriver(driverName)
+	if err != nil {
+		return err
+	}
+
+	if rpcd, ok := driver.(*rpcdriver.RpcClientDriver); ok {
+		defer rpcd.Close()
+	}
+
+	return printInspected(inspectedDriver{
+		Name:         driverName,
+		Capabilities: drivers.GetCapabilities(driver),
+	}, c.String("format"))
+}
+
+// loadDriver starts the plugin of the driver, without a machine.
+func loadDriver(driverName string) (drivers.Driver, error) {
+	bareDriverData, err := json.Marshal(&drivers.BaseDriver{})
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := newPluginDriver(driverName, bareDriverData)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading driver %q: %s", driverName, err)
+	}
+
+	if _, ok := driver.(*errdriver.Driver); ok {
+		return nil, errdriver.ErrDriverNotLoadable{Name: driverName}
+	}
+
+	return driver, nil
+}
+
+func cmdDriverOptions(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "options")
+		return errExpectedOneDriver
+	}
+
+	kinds := optionKinds
+	if kind := c.String("kind"); kind != "" {
+		if !isOptionKind(kind) {
+			return errInvalidOptionKind
+		}
+		kinds = []string{kind}
+	}
+
+	driverName := c.Args().First()
+
+	driver, err := loadDriver(driverName)
+	if err != nil {
+		return err
+	}
+
+	if rpcd, ok := driver.(*rpcdriver.RpcClientDriver); ok {
+		defer rpcd.Close()
+	}
+
+	if !drivers.SupportsDiscovery(driver) {
+		return fmt.Errorf("Error: Driver %q cannot list its regions, images and sizes: %s", driverName, drivers.ErrDiscoveryNotImplemented)
+	}
+
+	configFile, err := readConfig(configFilePath())
+	if err != nil {
+		return err
+	}
+	defaults := configFile.Create
+
+	flags := drivers.GetDiscoveryFlags(driver)
+
+	opts, err := discoveryDriverOpts(driver.GetCreateFlags(), defaults, flags.Region, c.String("region"))
+	if err != nil {
+		return err
+	}
+
+	// Listing needs no more than the credentials, which the driver is
+	// given before it checks its other options.
+	if err := driver.SetConfigFromFlags(opts); err != nil {
+		log.Debugf("Error configuring the driver to list its options: %s", err)
+	}
+
+	listed, err := listDriverOptions(driver, flags, opts.String(flags.Region), kinds)
+	if err != nil {
+		return err
+	}
+
+	printDriverOptions(os.Stdout, listed, c.Bool("quiet"))
+
+	return nil
+}
+
+func isOptionKind(kind string) bool {
+	for _, k := range optionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// discoveryDriverOpts returns the driver options a create without flags
+// would have, from the environment, the config file and the defaults, with
+// the region given, if any.
+func discoveryDriverOpts(mcnFlags []mcnflag.Flag, defaults createDefaults, regionFlag, region string) (drivers.DriverOptions, error) {
+	m := spec.Machine{DriverOpts: map[string]interface{}{}}
+
+	for _, f := range mcnFlags {
+		name := f.String()
+		if envVar := mcnFlagEnvVar(f); envVar != "" && os.Getenv(envVar) != "" {
+			continue
+		}
+		if raw, ok := defaults[name]; ok {
+			m.DriverOpts[name] = raw
+		}
+	}
+
+	if region != "" && regionFlag != "" {
+		m.DriverOpts[regionFlag] = region
+	}
+
+	return specDriverOpts(m, mcnFlags)
+}
+
+// listedOptions are the values of a flag driver options listed.
+type listedOptions struct {
+	Title   string
+	Options []drivers.Option
+}
+
+// listDriverOptions lists the values of the kinds, the images and sizes
+// being those of the region.
+func listDriverOptions(driver drivers.Driver, flags drivers.DiscoveryFlags, region string, kinds []string) ([]listedOptions, error) {
+	listed := []listedOptions{}
+
+	for _, kind := range kinds {
+		var (
+			l   listedOptions
+			err error
+		)
+
+		switch kind {
+		case "regions":
+			l.Title = fmt.Sprintf("Regions, for --%s:", flags.Region)
+			l.Options, err = drivers.ListRegions(driver)
+		case "images":
+			l.Title = fmt.Sprintf("Images in %s, for --%s:", region, flags.Image)
+			l.Options, err = drivers.ListImages(driver, region)
+		case "sizes":
+			l.Title = fmt.Sprintf("Sizes in %s, for --%s:", region, flags.Size)
+			l.Options, err = drivers.ListSizes(driver, region)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error listing the %s: %s", kind, err)
+		}
+
+		listed = append(listed, l)
+	}
+
+	return listed, nil
+}
+
+// printDriverOptions prints the values listed under their title, or only the
+// values when quiet.
+func printDriverOptions(out io.Writer, listed []listedOptions, quiet bool) {
+	if quiet {
+		for _, l := range listed {
+			for _, o := range l.Options {
+				fmt.Fprintln(out, o.Value)
+			}
+		}
+		return
+	}
+
+	for i, l := range listed {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, l.Title)
+
+		w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+		for _, o := range l.Options {
+			if o.Description == "" {
+				fmt.Fprintf(w, "  %s\n", o.Value)
+				continue
+			}
+			fmt.Fprintf(w, "  %s\t%s\n", o.Value, o.Description)
+		}
+		w.Flush()
+	}
+}
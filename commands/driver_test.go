@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// discoveryDriver lists two regions, and the images and sizes of us-east,
+// and needs a subnet on top of its key, like amazonec2 needs a VPC.
+type discoveryDriver struct {
+	*fakedriver.Driver
+	key, region string
+}
+
+func (d *discoveryDriver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		&mcnflag.StringFlag{Name: "disco-key", Usage: "Disco key", EnvVar: "DISCO_TEST_KEY"},
+		&mcnflag.StringFlag{Name: "disco-subnet", Usage: "Disco subnet"},
+		&mcnflag.StringFlag{Name: "disco-region", Usage: "Disco region", Value: "us-east"},
+		&mcnflag.StringFlag{Name: "disco-image", Usage: "Disco image", Value: "ubuntu"},
+		&mcnflag.StringFlag{Name: "disco-size", Usage: "Disco size", Value: "small"},
+	}
+}
+
+func (d *discoveryDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.key = flags.String("disco-key")
+	d.region = flags.String("disco-region")
+	if flags.String("disco-subnet") == "" {
+		return errors.New("disco driver requires the --disco-subnet option")
+	}
+	return nil
+}
+
+func (d *discoveryDriver) DiscoveryFlags() drivers.DiscoveryFlags {
+	return drivers.DiscoveryFlags{Region: "disco-region", Image: "disco-image", Size: "disco-size"}
+}
+
+func (d *discoveryDriver) ListRegions() ([]drivers.Option, error) {
+	if d.key != "secret" {
+		return nil, errors.New("401 Unauthorized")
+	}
+	return []drivers.Option{{Value: "us-east", Description: "Virginia"}, {Value: "eu-west", Description: "Ireland"}}, nil
+}
+
+func (d *discoveryDriver) ListImages(region string) ([]drivers.Option, error) {
+	if region != "us-east" {
+		return []drivers.Option{}, nil
+	}
+	return []drivers.Option{{Value: "ubuntu", Description: "Ubuntu 22.04"}, {Value: "debian"}}, nil
+}
+
+func (d *discoveryDriver) ListSizes(region string) ([]drivers.Option, error) {
+	if region != "us-east" {
+		return nil, errors.New("unknown region " + region)
+	}
+	return []drivers.Option{{Value: "small"}, {Value: "large"}}, nil
+}
+
+func TestDiscoveryDriverOpts(t *testing.T) {
+	d := &discoveryDriver{Driver: &fakedriver.Driver{}}
+	defaults := createDefaults{"disco-key": "from-config", "disco-region": "eu-west", "driver": "disco"}
+
+	opts, err := discoveryDriverOpts(d.GetCreateFlags(), defaults, "disco-region", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-config", opts.String("disco-key"))
+	assert.Equal(t, "eu-west", opts.String("disco-region"))
+	assert.Equal(t, "small", opts.String("disco-size"))
+
+	// The environment wins over the config file, and --region over both.
+	os.Setenv("DISCO_TEST_KEY", "from-env")
+	defer os.Unsetenv("DISCO_TEST_KEY")
+
+	opts, err = discoveryDriverOpts(d.GetCreateFlags(), defaults, "disco-region", "ap-south")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", opts.String("disco-key"))
+	assert.Equal(t, "ap-south", opts.String("disco-region"))
+}
+
+func TestListDriverOptions(t *testing.T) {
+	d := &discoveryDriver{Driver: &fakedriver.Driver{}, key: "secret"}
+
+	listed, err := listDriverOptions(d, d.DiscoveryFlags(), "us-east", optionKinds)
+	assert.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	printDriverOptions(out, listed, false)
+	assert.Equal(t, `Regions, for --disco-region:
+  us-east   Virginia
+  eu-west   Ireland
+
+Images in us-east, for --disco-image:
+  ubuntu   Ubuntu 22.04
+  debian
+
+Sizes in us-east, for --disco-size:
+  small
+  large
+`, out.String())
+
+	listed, err = listDriverOptions(d, d.DiscoveryFlags(), "us-east", []string{"images"})
+	assert.NoError(t, err)
+
+	out.Reset()
+	printDriverOptions(out, listed, true)
+	assert.Equal(t, "ubuntu\ndebian\n", out.String())
+
+	_, err = listDriverOptions(d, d.DiscoveryFlags(), "eu-west", []string{"sizes"})
+	assert.EqualError(t, err, "Error listing the sizes: unknown region eu-west")
+
+	_, err = listDriverOptions(&fakedriver.Driver{}, drivers.DiscoveryFlags{}, "", []string{"regions"})
+	assert.EqualError(t, err, "Error listing the regions: "+drivers.ErrDiscoveryNotImplemented.Error())
+}
+
+func TestIsOptionKind(t *testing.T) {
+	assert.True(t, isOptionKind("sizes"))
+	assert.False(t, isOptionKind("zones"))
+}
@@ -10,15 +10,19 @@ import (
 
 	"github.com/docker/machine/cli"
 	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
 )
 
 const (
-	envTmpl = `{{ .Prefix }}DOCKER_TLS_VERIFY{{ .Delimiter }}{{ .DockerTLSVerify }}{{ .Suffix }}{{ .Prefix }}DOCKER_HOST{{ .Delimiter }}{{ .DockerHost }}{{ .Suffix }}{{ .Prefix }}DOCKER_CERT_PATH{{ .Delimiter }}{{ .DockerCertPath }}{{ .Suffix }}{{ .Prefix }}DOCKER_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ if .NoProxyVar }}{{ .Prefix }}{{ .NoProxyVar }}{{ .Delimiter }}{{ .NoProxyValue }}{{ .Suffix }}{{end}}{{ .UsageHint }}`
+	envTmpl = `{{ .Prefix }}DOCKER_TLS_VERIFY{{ .Delimiter }}{{ .DockerTLSVerify }}{{ .Suffix }}{{ .Prefix }}DOCKER_HOST{{ .Delimiter }}{{ .DockerHost }}{{ .Suffix }}{{ .Prefix }}DOCKER_CERT_PATH{{ .Delimiter }}{{ .DockerCertPath }}{{ .Suffix }}{{ .Prefix }}DOCKER_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ if .ContentTrust }}{{ .Prefix }}DOCKER_CONTENT_TRUST{{ .Delimiter }}{{ .ContentTrust }}{{ .Suffix }}{{ end }}{{ if .NoProxyVar }}{{ .Prefix }}{{ .NoProxyVar }}{{ .Delimiter }}{{ .NoProxyValue }}{{ .Suffix }}{{end}}{{ .UsageHint }}`
 )
 
 var (
-	errImproperEnvArgs = errors.New("Error: Expected either one machine name, or -u flag to unset the variables in the arguments")
+	errImproperEnvArgs   = errors.New("Error: Expected either one machine name, or -u flag to unset the variables in the arguments")
+	errSwarmClusterArgs  = errors.New("Error: --swarm-cluster takes the place of the machine name, expected no arguments")
+	errSwarmClusterSwarm = errors.New("Error: --swarm-cluster and --swarm cannot be used together")
 )
 
 type ShellConfig struct {
@@ -32,6 +36,7 @@ type ShellConfig struct {
 	MachineName     string
 	NoProxyVar      string
 	NoProxyValue    string
+	ContentTrust    string
 }
 
 func cmdEnv(c *cli.Context) error {
@@ -39,18 +44,54 @@ func cmdEnv(c *cli.Context) error {
 	// being run (it is intended to be run in a subshell)
 	log.SetOutWriter(os.Stderr)
 
-	if len(c.Args()) != 1 && !c.Bool("unset") {
-		return errImproperEnvArgs
-	}
+	var (
+		host       *host.Host
+		dockerHost string
+		tunnel     bool
+		err        error
+	)
 
-	host, err := getFirstArgHost(c)
-	if err != nil {
-		return err
-	}
+	if cluster := c.String("swarm-cluster"); cluster != "" {
+		if len(c.Args()) != 0 {
+			return errSwarmClusterArgs
+		}
+		if c.Bool("swarm") {
+			return errSwarmClusterSwarm
+		}
+		if c.Bool("ssh-tunnel") {
+			return errSSHTunnelSwarm
+		}
 
-	dockerHost, _, err := runConnectionBoilerplate(host, c)
-	if err != nil {
-		return fmt.Errorf("Error running connection boilerplate: %s", err)
+		host, dockerHost, err = swarmClusterManager(getStore(c), cluster)
+		if err != nil {
+			return err
+		}
+	} else {
+		if len(c.Args()) != 1 && !c.Bool("unset") {
+			return errImproperEnvArgs
+		}
+
+		host, err = getFirstArgHost(c)
+		if err != nil {
+			return err
+		}
+
+		tunnel = usesSSHTunnel(host, c.Bool("ssh-tunnel"))
+		switch {
+		case tunnel && c.Bool("swarm"):
+			return errSSHTunnelSwarm
+		case tunnel && !c.Bool("unset"):
+			t := machineSSHTunnel(host, c.Int("ssh-tunnel-port"))
+			if err := openSSHTunnel(host, t); err != nil {
+				return err
+			}
+			dockerHost = t.dockerHost()
+		case !tunnel:
+			dockerHost, _, err = runConnectionBoilerplate(host, c)
+			if err != nil {
+				return fmt.Errorf("Error running connection boilerplate: %s", err)
+			}
+		}
 	}
 
 	userShell := c.String("shell")
@@ -74,6 +115,17 @@ func cmdEnv(c *cli.Context) error {
 		MachineName:     host.Name,
 	}
 
+	// The tunnel goes to the socket of the engine, without TLS.
+	if tunnel {
+		shellCfg.DockerCertPath = ""
+		shellCfg.DockerTLSVerify = ""
+	}
+
+	// Hardened machines only run signed images.
+	if host.HostOptions != nil && host.HostOptions.EngineOptions != nil && host.HostOptions.EngineOptions.Hardening != "" {
+		shellCfg.ContentTrust = "1"
+	}
+
 	if c.Bool("no-proxy") {
 		ip, err := host.Driver.GetIP()
 		if err != nil {
@@ -120,6 +172,7 @@ func cmdEnv(c *cli.Context) error {
 			shellCfg.DockerCertPath = ""
 			shellCfg.DockerHost = ""
 			shellCfg.DockerTLSVerify = ""
+			shellCfg.ContentTrust = ""
 			shellCfg.Prefix = "set "
 			shellCfg.Delimiter = "="
 			shellCfg.Suffix = "\n"
@@ -184,3 +237,34 @@ func generateUsageHint(userShell string, args []string) string {
 
 	return fmt.Sprintf("%s Run this command to configure your shell: \n%s %s\n", comment, comment, cmd)
 }
+
+// swarmClusterManager returns the first manager of the swarm mode cluster
+// whose engine answers, with its URL, for the environment to follow the
+// cluster rather than a machine which may be down.
+func swarmClusterManager(store persist.Store, cluster string) (*host.Host, string, error) {
+	hosts, err := listHosts(store)
+	if err != nil {
+		return nil, "", err
+	}
+
+	managers := swarmModeManagers(hosts, cluster)
+	if len(managers) == 0 {
+		return nil, "", fmt.Errorf("Error: No manager of a swarm mode cluster named %q", cluster)
+	}
+
+	return reachableManager(cluster, managers)
+}
+
+// reachableManager tries the managers in turn, returning the first one whose
+// engine answers, with its URL.
+func reachableManager(cluster string, managers []*host.Host) (*host.Host, string, error) {
+	for _, h := range managers {
+		dockerHost, _, err := connectionSettings(h, false)
+		if err == nil {
+			return h, dockerHost, nil
+		}
+		log.Warnf("Swarm mode manager %s can't be reached, trying the next one: %s", h.Name, err)
+	}
+
+	return nil, "", fmt.Errorf("Error: None of the %d managers of the swarm mode cluster %q can be reached", len(managers), cluster)
+}
@@ -5,6 +5,12 @@ import (
 
 	"strings"
 
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,3 +47,29 @@ func TestHints(t *testing.T) {
 		assert.Equal(t, test.expectedHints, hints)
 	}
 }
+
+func TestReachableManager(t *testing.T) {
+	cert.SetCertGenerator(FakeCertGenerator{fakeValidateCertificate: &FakeValidateCertificate{IsValid: true}})
+	defer cert.SetCertGenerator(cert.NewX509CertGenerator())
+
+	newManager := func(name string, s state.State) *host.Host {
+		return &host.Host{
+			Name:   name,
+			Driver: &fakedriver.Driver{MockState: s, MockURL: "tcp://" + name + ":2376"},
+			HostOptions: &host.HostOptions{
+				AuthOptions:  &auth.AuthOptions{},
+				SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager},
+			},
+		}
+	}
+
+	managers := []*host.Host{newManager("manager-0", state.Stopped), newManager("manager-1", state.Running), newManager("manager-2", state.Running)}
+
+	h, dockerHost, err := reachableManager("manager-0", managers)
+	assert.NoError(t, err)
+	assert.Equal(t, "manager-1", h.Name)
+	assert.Equal(t, "tcp://manager-1:2376", dockerHost)
+
+	_, _, err = reachableManager("manager-0", managers[:1])
+	assert.EqualError(t, err, `Error: None of the 1 managers of the swarm mode cluster "manager-0" can be reached`)
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errNoDockerCommand = errors.New("Error: No docker command specified, like: docker-machine exec dev -- ps -a")
+	errNoDockerCLI     = errors.New("Error: The docker CLI was not found in your PATH")
+)
+
+// execArgs are the arguments of exec, whose flags aren't parsed.
+type execArgs struct {
+	Name      string
+	Swarm     bool
+	SSHTunnel bool
+	Docker    []string
+}
+
+// parseExecArgs parses the arguments of exec: its flags, the machine name,
+// then the docker command, optionally after --.
+func parseExecArgs(args []string) (execArgs, error) {
+	parsed := execArgs{}
+
+	for len(args) > 0 && (args[0] == "--swarm" || args[0] == "--ssh-tunnel") {
+		if args[0] == "--swarm" {
+			parsed.Swarm = true
+		} else {
+			parsed.SSHTunnel = true
+		}
+		args = args[1:]
+	}
+
+	if len(args) == 0 || args[0] == "--" {
+		return parsed, ErrExpectedOneMachine
+	}
+	parsed.Name = args[0]
+	args = args[1:]
+
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return parsed, errNoDockerCommand
+	}
+	parsed.Docker = args
+
+	return parsed, nil
+}
+
+// dockerCLIArgs returns the arguments of the docker CLI running the command
+// against the engine, whatever the DOCKER_HOST and DOCKER_CERT_PATH of the
+// environment.
+func dockerCLIArgs(dockerHost string, authOptions *auth.AuthOptions, command []string) []string {
+	args := []string{
+		"--tlsverify",
+		"--tlscacert=" + authOptions.CaCertPath,
+		"--tlscert=" + authOptions.ClientCertPath,
+		"--tlskey=" + authOptions.ClientKeyPath,
+		"-H=" + dockerHost,
+	}
+	return append(args, command...)
+}
+
+// execSSHTunnel returns the tunnel exec runs the command through, on a
+// socket only the user can reach, in a directory the returned function
+// removes, or on a free port on Windows, where ssh forwards no unix socket.
+func execSSHTunnel() (sshTunnel, func(), error) {
+	if runtime.GOOS == "windows" {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return sshTunnel{}, nil, err
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		return sshTunnel{Port: port}, func() {}, nil
+	}
+
+	dir, err := ioutil.TempDir("", "docker-machine-exec")
+	if err != nil {
+		return sshTunnel{}, nil, err
+	}
+	return sshTunnel{Socket: filepath.Join(dir, "docker.sock")}, func() { os.RemoveAll(dir) }, nil
+}
+
+func cmdExec(c *cli.Context) error {
+	// Check for help flag -- Needed due to SkipFlagParsing
+	for _, arg := range c.Args() {
+		if arg == "--" {
+			break
+		}
+		if arg == "-help" || arg == "--help" || arg == "-h" {
+			cli.ShowCommandHelp(c, "exec")
+			return nil
+		}
+	}
+
+	args, err := parseExecArgs(c.Args())
+	if err != nil {
+		return err
+	}
+
+	docker, err := exec.LookPath("docker")
+	if err != nil {
+		return errNoDockerCLI
+	}
+
+	host, err := loadHost(getStore(c), args.Name)
+	if err != nil {
+		return err
+	}
+
+	var dockerArgs []string
+	if usesSSHTunnel(host, args.SSHTunnel) {
+		if args.Swarm {
+			return errSSHTunnelSwarm
+		}
+
+		t, cleanup, err := execSSHTunnel()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		closeTunnel, err := startSSHTunnel(host, t)
+		if err != nil {
+			return err
+		}
+		defer closeTunnel()
+
+		dockerArgs = append([]string{"-H=" + t.dockerHost()}, args.Docker...)
+	} else {
+		dockerHost, authOptions, err := connectionSettings(host, args.Swarm)
+		if err != nil {
+			return fmt.Errorf("Error running connection boilerplate: %s", err)
+		}
+		dockerArgs = dockerCLIArgs(dockerHost, authOptions, args.Docker)
+	}
+
+	cmd := exec.Command(docker, dockerArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if host.HostOptions != nil && host.HostOptions.EngineOptions != nil && host.HostOptions.EngineOptions.Hardening != "" {
+		cmd.Env = append(cmd.Env, "DOCKER_CONTENT_TRUST=1")
+	}
+
+	log.Debugf("Running %s %v", docker, cmd.Args[1:])
+
+	// The docker CLI handles the interrupts of the terminal, like detaching
+	// from docker logs -f, and exits on its own.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	return cmd.Run()
+}
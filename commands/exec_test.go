@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExecArgs(t *testing.T) {
+	var tests = []struct {
+		args     []string
+		expected execArgs
+	}{
+		{[]string{"dev", "ps", "-a"}, execArgs{Name: "dev", Docker: []string{"ps", "-a"}}},
+		{[]string{"dev", "--", "run", "--rm", "busybox"}, execArgs{Name: "dev", Docker: []string{"run", "--rm", "busybox"}}},
+		{[]string{"--swarm", "dev", "--", "info"}, execArgs{Name: "dev", Swarm: true, Docker: []string{"info"}}},
+		{[]string{"--ssh-tunnel", "dev", "--", "info"}, execArgs{Name: "dev", SSHTunnel: true, Docker: []string{"info"}}},
+		{[]string{"dev", "--", "logs", "--", "web"}, execArgs{Name: "dev", Docker: []string{"logs", "--", "web"}}},
+	}
+
+	for _, test := range tests {
+		parsed, err := parseExecArgs(test.args)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, parsed)
+	}
+}
+
+func TestParseExecArgsErrors(t *testing.T) {
+	_, err := parseExecArgs([]string{})
+	assert.Equal(t, ErrExpectedOneMachine, err)
+
+	_, err = parseExecArgs([]string{"--", "ps"})
+	assert.Equal(t, ErrExpectedOneMachine, err)
+
+	_, err = parseExecArgs([]string{"dev"})
+	assert.Equal(t, errNoDockerCommand, err)
+
+	_, err = parseExecArgs([]string{"dev", "--"})
+	assert.Equal(t, errNoDockerCommand, err)
+}
+
+func TestDockerCLIArgs(t *testing.T) {
+	authOptions := &auth.AuthOptions{
+		CaCertPath:     "/store/certs/ca.pem",
+		ClientCertPath: "/store/certs/cert.pem",
+		ClientKeyPath:  "/store/certs/key.pem",
+	}
+
+	args := dockerCLIArgs("tcp://1.2.3.4:2376", authOptions, []string{"ps", "-a"})
+
+	assert.Equal(t, []string{
+		"--tlsverify",
+		"--tlscacert=/store/certs/ca.pem",
+		"--tlscert=/store/certs/cert.pem",
+		"--tlskey=/store/certs/key.pem",
+		"-H=tcp://1.2.3.4:2376",
+		"ps",
+		"-a",
+	}, args)
+}
@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+)
+
+func cmdExportTerraform(c *cli.Context) error {
+	var (
+		hosts []*host.Host
+		err   error
+	)
+
+	if c.Bool("all") {
+		hosts, err = listHosts(getStore(c))
+	} else {
+		hosts, err = getHostsFromContext(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		if c.Bool("all") {
+			return nil
+		}
+		return ErrNoMachineSpecified
+	}
+
+	resources, err := terraformResources(hosts, c.Bool("all"))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("imports") {
+		printTerraformImports(os.Stdout, resources)
+		return nil
+	}
+
+	printTerraformConfig(os.Stdout, resources)
+	return nil
+}
+
+// terraformResources returns the resources of the hosts, once each as
+// machines share some, like the security group of amazonec2. The hosts of
+// drivers which can't export them are skipped when skipUnsupported is set.
+func terraformResources(hosts []*host.Host, skipUnsupported bool) ([]drivers.TerraformResource, error) {
+	resources := []drivers.TerraformResource{}
+	exported := map[string]drivers.TerraformResource{}
+
+	for _, h := range hosts {
+		if !drivers.SupportsTerraform(h.Driver) {
+			if skipUnsupported {
+				log.Warnf("Skipping machine %q: the %s driver can't export it to Terraform", h.Name, h.DriverName)
+				continue
+			}
+			return nil, fmt.Errorf("Error exporting machine %q: %s", h.Name, drivers.ErrTerraformNotImplemented)
+		}
+
+		hostResources, err := drivers.GetTerraformResources(h.Driver)
+		if err != nil {
+			return nil, fmt.Errorf("Error exporting machine %q: %s", h.Name, err)
+		}
+
+		for _, resource := range hostResources {
+			if other, ok := exported[resource.Address()]; ok {
+				if other.ID != resource.ID {
+					return nil, fmt.Errorf("Error exporting machine %q: %s is both %s and %s", h.Name, resource.Address(), other.ID, resource.ID)
+				}
+				continue
+			}
+
+			exported[resource.Address()] = resource
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources, nil
+}
+
+// printTerraformConfig prints the resources as an HCL configuration, followed
+// by the commands importing them.
+func printTerraformConfig(w io.Writer, resources []drivers.TerraformResource) {
+	for _, resource := range resources {
+		fmt.Fprintf(w, "resource %q %q {\n", resource.Type, resource.Name)
+		printTerraformBody(w, "  ", resource.Attributes, resource.Blocks)
+		fmt.Fprint(w, "}\n\n")
+	}
+
+	fmt.Fprintln(w, "# Import the existing resources into the Terraform state with:")
+	for _, resource := range resources {
+		fmt.Fprintf(w, "#   %s\n", terraformImportCommand(resource))
+	}
+}
+
+// printTerraformBody prints the attributes and nested blocks of a resource
+// or block, with their equal signs aligned as terraform fmt does.
+func printTerraformBody(w io.Writer, indent string, attributes []drivers.TerraformAttribute, blocks []drivers.TerraformBlock) {
+	width := 0
+	for _, attribute := range attributes {
+		if len(attribute.Name) > width {
+			width = len(attribute.Name)
+		}
+	}
+
+	for _, attribute := range attributes {
+		fmt.Fprintf(w, "%s%-*s = %s\n", indent, width, attribute.Name, attribute.Value)
+	}
+
+	for i, block := range blocks {
+		if i > 0 || len(attributes) > 0 {
+			fmt.Fprintln(w)
+		}
+		if len(block.Attributes) == 0 && len(block.Blocks) == 0 {
+			fmt.Fprintf(w, "%s%s {}\n", indent, block.Type)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s {\n", indent, block.Type)
+		printTerraformBody(w, indent+"  ", block.Attributes, block.Blocks)
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+}
+
+// printTerraformImports prints the commands importing the resources.
+func printTerraformImports(w io.Writer, resources []drivers.TerraformResource) {
+	for _, resource := range resources {
+		fmt.Fprintln(w, terraformImportCommand(resource))
+	}
+}
+
+func terraformImportCommand(resource drivers.TerraformResource) string {
+	return fmt.Sprintf("terraform import %s %s", resource.Address(), shellQuote(resource.ID))
+}
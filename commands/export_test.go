@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/stretchr/testify/assert"
+)
+
+// terraformDriver is a fake driver exporting the given resources.
+type terraformDriver struct {
+	*fakedriver.Driver
+	resources []drivers.TerraformResource
+}
+
+func (d *terraformDriver) TerraformResources() ([]drivers.TerraformResource, error) {
+	return d.resources, nil
+}
+
+var (
+	testSecurityGroup = drivers.TerraformResource{
+		Type: "aws_security_group",
+		Name: "docker-machine",
+		ID:   "sg-1",
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "name", Value: `"docker-machine"`},
+		},
+		Blocks: []drivers.TerraformBlock{
+			{
+				Type: "ingress",
+				Attributes: []drivers.TerraformAttribute{
+					{Name: "from_port", Value: "22"},
+					{Name: "to_port", Value: "22"},
+				},
+			},
+		},
+	}
+
+	testInstance = drivers.TerraformResource{
+		Type: "aws_instance",
+		Name: "web",
+		ID:   "i-1",
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "ami", Value: `"ami-1"`},
+			{Name: "vpc_security_group_ids", Value: "[aws_security_group.docker-machine.id]"},
+		},
+		Blocks: []drivers.TerraformBlock{
+			{
+				Type: "network_interface",
+				Blocks: []drivers.TerraformBlock{
+					{Type: "access_config"},
+				},
+			},
+		},
+	}
+)
+
+func TestTerraformResourcesDeduplicates(t *testing.T) {
+	other := testInstance
+	other.Name = "db"
+	other.ID = "i-2"
+
+	hosts := []*host.Host{
+		{Name: "web", Driver: &terraformDriver{resources: []drivers.TerraformResource{testSecurityGroup, testInstance}}},
+		{Name: "db", Driver: &terraformDriver{resources: []drivers.TerraformResource{testSecurityGroup, other}}},
+		{Name: "local", DriverName: "fakedriver", Driver: &fakedriver.Driver{}},
+	}
+
+	resources, err := terraformResources(hosts, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.TerraformResource{testSecurityGroup, testInstance, other}, resources)
+
+	_, err = terraformResources(hosts, false)
+	assert.EqualError(t, err, `Error exporting machine "local": Driver does not support exporting machines to Terraform`)
+}
+
+func TestTerraformResourcesConflict(t *testing.T) {
+	other := testSecurityGroup
+	other.ID = "sg-2"
+
+	hosts := []*host.Host{
+		{Name: "web", Driver: &terraformDriver{resources: []drivers.TerraformResource{testSecurityGroup}}},
+		{Name: "db", Driver: &terraformDriver{resources: []drivers.TerraformResource{other}}},
+	}
+
+	_, err := terraformResources(hosts, false)
+
+	assert.EqualError(t, err, `Error exporting machine "db": aws_security_group.docker-machine is both sg-1 and sg-2`)
+}
+
+func TestPrintTerraformConfig(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	printTerraformConfig(out, []drivers.TerraformResource{testSecurityGroup, testInstance})
+
+	assert.Equal(t, `resource "aws_security_group" "docker-machine" {
+  name = "docker-machine"
+
+  ingress {
+    from_port = 22
+    to_port   = 22
+  }
+}
+
+resource "aws_instance" "web" {
+  ami                    = "ami-1"
+  vpc_security_group_ids = [aws_security_group.docker-machine.id]
+
+  network_interface {
+    access_config {}
+  }
+}
+
+# Import the existing resources into the Terraform state with:
+#   terraform import aws_security_group.docker-machine 'sg-1'
+#   terraform import aws_instance.web 'i-1'
+`, out.String())
+}
+
+func TestPrintTerraformImports(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	printTerraformImports(out, []drivers.TerraformResource{testSecurityGroup, testInstance})
+
+	assert.Equal(t, "terraform import aws_security_group.docker-machine 'sg-1'\nterraform import aws_instance.web 'i-1'\n", out.String())
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+var errNoGarbageCollector = errors.New("No machine in the store has a driver which can find orphaned resources")
+
+// orphan is an orphaned resource, with the host whose driver found it and
+// removes it.
+type orphan struct {
+	Host *host.Host
+	drivers.OrphanedResource
+}
+
+func cmdGc(c *cli.Context) error {
+	store := getStore(c)
+
+	hosts, err := listHosts(store)
+	if err != nil {
+		return err
+	}
+
+	storeID, err := store.(*persist.Filestore).ID()
+	if err != nil {
+		return fmt.Errorf("Error getting the ID of the store: %s", err)
+	}
+
+	orphans, err := findOrphans(hosts, storeID, c.String("driver"))
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		log.Info("No orphaned resources found")
+		return nil
+	}
+
+	printOrphans(os.Stdout, orphans)
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+
+	if !c.Bool("force") {
+		ok, err := confirmInput(fmt.Sprintf("Remove these %d resources?", len(orphans)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	return removeOrphans(orphans)
+}
+
+// findOrphans asks the drivers of the hosts, or of those using the driver
+// driverName when given, for the resources tagged for the store of storeID
+// of machines which aren't in it. Machines of the same account find the same
+// resources, which are returned once each.
+func findOrphans(hosts []*host.Host, storeID, driverName string) ([]orphan, error) {
+	machines := []string{}
+	for _, h := range hosts {
+		machines = append(machines, h.Name)
+	}
+
+	orphans := []orphan{}
+	found := map[string]bool{}
+	scanned := false
+
+	for _, h := range hosts {
+		if driverName != "" && h.DriverName != driverName {
+			continue
+		}
+		if !drivers.SupportsGarbageCollection(h.Driver) {
+			continue
+		}
+		scanned = true
+
+		resources, err := drivers.GetOrphanedResources(h.Driver, storeID, machines)
+		if err != nil {
+			return nil, fmt.Errorf("Error finding orphaned resources with machine %q: %s", h.Name, err)
+		}
+
+		for _, resource := range resources {
+			key := h.DriverName + "/" + resource.Kind + "/" + resource.ID
+			if found[key] {
+				continue
+			}
+			found[key] = true
+			orphans = append(orphans, orphan{Host: h, OrphanedResource: resource})
+		}
+	}
+
+	if !scanned {
+		return nil, errNoGarbageCollector
+	}
+
+	return orphans, nil
+}
+
+func printOrphans(out io.Writer, orphans []orphan) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "DRIVER\tKIND\tID\tMACHINE")
+	for _, o := range orphans {
+		machine := o.Machine
+		if machine == "" {
+			machine = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.Host.DriverName, o.Kind, o.ID, machine)
+	}
+
+	w.Flush()
+}
+
+// removeOrphans removes the orphans in order, going on past the ones which
+// fail to be removed.
+func removeOrphans(orphans []orphan) error {
+	failed := 0
+
+	for _, o := range orphans {
+		log.Infof("Removing %s %s...", o.Kind, o.ID)
+		if err := drivers.RemoveOrphanedResource(o.Host.Driver, o.OrphanedResource); err != nil {
+			log.Errorf("Error removing %s %s: %s", o.Kind, o.ID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Error removing %d of %d orphaned resources", failed, len(orphans))
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/stretchr/testify/assert"
+)
+
+// collectorDriver is a fake driver finding the given orphaned resources,
+// and failing to remove those of kind "busy".
+type collectorDriver struct {
+	*fakedriver.Driver
+	resources []drivers.OrphanedResource
+	storeID   string
+	machines  []string
+	removed   []string
+}
+
+func (d *collectorDriver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	d.storeID = storeID
+	d.machines = machines
+	return d.resources, nil
+}
+
+func (d *collectorDriver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	if resource.Kind == "busy" {
+		return errors.New("in use")
+	}
+	d.removed = append(d.removed, resource.ID)
+	return nil
+}
+
+func TestFindOrphans(t *testing.T) {
+	instance := drivers.OrphanedResource{Kind: "instance", ID: "i-1", Machine: "old"}
+	group := drivers.OrphanedResource{Kind: "security-group", ID: "sg-1"}
+
+	web := &collectorDriver{resources: []drivers.OrphanedResource{instance, group}}
+	db := &collectorDriver{resources: []drivers.OrphanedResource{instance}}
+	hosts := []*host.Host{
+		{Name: "web", DriverName: "amazonec2", Driver: web},
+		{Name: "db", DriverName: "amazonec2", Driver: db},
+		{Name: "local", DriverName: "fakedriver", Driver: &fakedriver.Driver{}},
+	}
+
+	orphans, err := findOrphans(hosts, "0123abcd", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0123abcd", web.storeID)
+	assert.Equal(t, []string{"web", "db", "local"}, web.machines)
+	assert.Equal(t, []orphan{
+		{Host: hosts[0], OrphanedResource: instance},
+		{Host: hosts[0], OrphanedResource: group},
+	}, orphans)
+
+	out := &bytes.Buffer{}
+	printOrphans(out, orphans)
+	assert.Equal(t, "DRIVER      KIND             ID     MACHINE\n"+
+		"amazonec2   instance         i-1    old\n"+
+		"amazonec2   security-group   sg-1   -\n", out.String())
+
+	_, err = findOrphans(hosts, "0123abcd", "digitalocean")
+	assert.Equal(t, errNoGarbageCollector, err)
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	d := &collectorDriver{}
+	h := &host.Host{Name: "web", DriverName: "amazonec2", Driver: d}
+
+	err := removeOrphans([]orphan{
+		{Host: h, OrphanedResource: drivers.OrphanedResource{Kind: "busy", ID: "sg-1"}},
+		{Host: h, OrphanedResource: drivers.OrphanedResource{Kind: "instance", ID: "i-1"}},
+	})
+
+	assert.EqualError(t, err, "Error removing 1 of 2 orphaned resources")
+	assert.Equal(t, []string{"i-1"}, d.removed)
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/health"
+	"github.com/docker/machine/libmachine/host"
+)
+
+func cmdHealthcheck(c *cli.Context) error {
+	var (
+		hosts []*host.Host
+		err   error
+	)
+
+	if c.Bool("all") {
+		hosts, err = listHosts(getStore(c))
+	} else {
+		hosts, err = getHostsFromContext(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		if c.Bool("all") {
+			return nil
+		}
+		return ErrNoMachineSpecified
+	}
+
+	runCheck := health.Check
+	if c.Bool("heal") {
+		runCheck = health.Heal
+	}
+
+	reports := make([]*health.Report, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+			reports[i] = runCheck(h)
+		}(i, h)
+	}
+	wg.Wait()
+
+	printHealthReports(os.Stdout, reports)
+
+	unhealthy := 0
+	for _, r := range reports {
+		if !r.Healthy() {
+			unhealthy++
+		}
+	}
+
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d machines are unhealthy", unhealthy, len(reports))
+	}
+
+	return nil
+}
+
+func printHealthReports(out io.Writer, reports []*health.Report) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCHECK\tSTATUS\tMESSAGE")
+
+	for _, r := range reports {
+		for _, res := range r.Results {
+			status := string(res.Status)
+			if res.Healed {
+				status = "healed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Host, res.Check, status, res.Message)
+		}
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/hooks"
+	"golang.org/x/net/context"
+)
+
+// readHooks returns the hooks of the store: the executables of its hooks
+// directory, and the commands of the hooks section of the config file at
+// path.
+func readHooks(path string) (hooks.Hooks, error) {
+	h := hooks.Hooks{
+		Dir:    filepath.Join(mcndirs.GetBaseDir(), "hooks"),
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	cfg, err := readConfig(path)
+	if err != nil {
+		return h, err
+	}
+	h.Commands = cfg.Hooks
+
+	return h, nil
+}
+
+func decodeHookCommands(c *config, encoded []byte) error {
+	if err := json.Unmarshal(encoded, &c.Hooks); err != nil {
+		return fmt.Errorf("expected \"hooks\" to map hooks to lists of commands: %s", err)
+	}
+
+	return hooks.Hooks{Commands: c.Hooks}.Validate()
+}
+
+// runHook runs the hooks of the point for the machine. Its IP is looked up
+// with d, when given.
+func runHook(ctx context.Context, point, name, driverName string, d drivers.Driver) error {
+	h, err := readHooks(configFilePath())
+	if err != nil {
+		return fmt.Errorf("Error running %s hooks: %s", point, err)
+	}
+
+	m := hooks.Machine{
+		Name:      name,
+		Driver:    driverName,
+		StorePath: mcndirs.GetBaseDir(),
+	}
+
+	if d != nil {
+		if ip, err := d.GetIP(); err == nil {
+			m.IP = ip
+		}
+	}
+
+	return h.Run(ctx, point, m)
+}
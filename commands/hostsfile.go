@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/hostsfile"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var errNoHostsFile = errors.New("Error: No hosts-file section in the config file, add one to name the machines in a hosts file")
+
+// readHostsFile returns the hosts file of the config file at path, naming
+// the machines of the store. Without a hosts-file section, or without a
+// config file, no hosts file is maintained and nil is returned.
+func readHostsFile(path string) (*hostsfile.File, error) {
+	cfg, err := readConfig(path)
+	if err != nil || cfg.HostsFile == nil {
+		return nil, err
+	}
+
+	f := *cfg.HostsFile
+	f.Store = mcndirs.GetBaseDir()
+	return &f, nil
+}
+
+func decodeHostsFile(c *config, encoded []byte) error {
+	var section map[string]interface{}
+	if err := json.Unmarshal(encoded, &section); err != nil {
+		return fmt.Errorf("expected \"hosts-file\" to be a mapping with a path and a domain: %s", err)
+	}
+
+	f := &hostsfile.File{}
+	for key, value := range section {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected the %s of \"hosts-file\" to be a string", key)
+		}
+
+		switch key {
+		case "path":
+			f.Path = s
+		case "domain":
+			f.Domain = s
+		default:
+			return fmt.Errorf("unknown setting %q of \"hosts-file\", expected path or domain", key)
+		}
+	}
+
+	if f.Path == "" {
+		f.Path = hostsfile.DefaultPath()
+	}
+	c.HostsFile = f
+
+	return nil
+}
+
+// updateHostsFile names the machine with its IP in the hosts file of the
+// config file, if any, or removes its entry when it isn't running, given a
+// nil driver. Errors are only warned about, the machine working without.
+func updateHostsFile(name string, d drivers.Driver) {
+	f, err := readHostsFile(configFilePath())
+	if err != nil || f == nil {
+		if err != nil {
+			log.Warnf("Error updating the hosts file entry of %s: %s", name, err)
+		}
+		return
+	}
+
+	ip := ""
+	if d != nil {
+		if ip, err = d.GetIP(); err != nil {
+			log.Warnf("Error updating the hosts file entry of %s: %s", name, err)
+			return
+		}
+	}
+
+	if err := f.Set(name, ip); err != nil {
+		log.Warnf("Error updating %s with the entry of %s: %s", f.Path, name, err)
+	}
+}
+
+// recordIP updates the hosts file entry of the machine when its IP changed,
+// if it has one.
+func recordIP(name, ip string) {
+	f, err := readHostsFile(configFilePath())
+	if err != nil || f == nil {
+		return
+	}
+
+	entries, err := f.Entries()
+	if err != nil {
+		return
+	}
+
+	if recorded, ok := entries[name]; ok && recorded != ip {
+		log.Debugf("The IP of %s changed from %s to %s", name, recorded, ip)
+		if err := f.Set(name, ip); err != nil {
+			log.Warnf("Error updating %s with the entry of %s: %s", f.Path, name, err)
+		}
+	}
+}
+
+func cmdHostsFileSync(c *cli.Context) error {
+	f, err := readHostsFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return errNoHostsFile
+	}
+
+	hosts, err := listHosts(getStore(c))
+	if err != nil {
+		return err
+	}
+
+	ips := map[string]string{}
+	for _, m := range machineIPs(hosts) {
+		ips[m.Name] = m.IP
+		fmt.Printf("%s\t%s\n", m.IP, f.Hostname(m.Name))
+	}
+
+	if err := f.Sync(ips); err != nil {
+		return fmt.Errorf("Error updating %s: %s", f.Path, err)
+	}
+
+	return nil
+}
+
+func cmdHostsFileClean(c *cli.Context) error {
+	f, err := readHostsFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return errNoHostsFile
+	}
+
+	if err := f.Sync(nil); err != nil {
+		return fmt.Errorf("Error updating %s: %s", f.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/hostsfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHostsFile(t *testing.T) {
+	cfg, err := parseConfig([]byte("hosts-file:\n  path: /tmp/hosts\n  domain: lan\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, &hostsfile.File{Path: "/tmp/hosts", Domain: "lan"}, cfg.HostsFile)
+
+	cfg, err = parseConfig([]byte("hosts-file:\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, &hostsfile.File{Path: hostsfile.DefaultPath()}, cfg.HostsFile)
+
+	cfg, err = parseConfig([]byte("create:\n  driver: virtualbox\n"))
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.HostsFile)
+
+	_, err = parseConfig([]byte("hosts-file:\n  file: /tmp/hosts\n"))
+	assert.EqualError(t, err, `unknown setting "file" of "hosts-file", expected path or domain`)
+
+	_, err = parseConfig([]byte("hosts-file:\n  - /tmp/hosts\n"))
+	assert.Error(t, err)
+}
+
+func TestPrintMachineIPs(t *testing.T) {
+	ips := []machineIP{
+		{Name: "dev", IP: "192.168.99.100"},
+		{Name: "web", IP: "10.0.0.5", Addresses: []drivers.Address{{Network: "default", IP: "10.0.0.5"}, {Network: "data", IP: "10.1.0.5"}}},
+	}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printMachineIPs(out, ips, ""))
+	assert.Equal(t, "dev\t192.168.99.100\nweb\t10.0.0.5\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, printMachineIPs(out, ips, "{{json .}}"))
+	assert.Equal(t, `{"Name":"dev","IP":"192.168.99.100"}
+{"Name":"web","IP":"10.0.0.5","Addresses":[{"Network":"default","IP":"10.0.0.5"},{"Network":"data","IP":"10.1.0.5"}]}
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, printMachineIPs(out, ips, "{{.IP}} {{.Name}}.docker.local"))
+	assert.Equal(t, "192.168.99.100 dev.docker.local\n10.0.0.5 web.docker.local\n", out.String())
+}
@@ -2,13 +2,22 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"text/template"
 
 	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/pricing"
+	"github.com/docker/machine/libmachine/state"
+	"golang.org/x/net/context"
 )
 
+var errProvisionLogFormat = errors.New("Error: --provision-log cannot be used with --format")
+
 var funcMap = template.FuncMap{
 	"json": func(v interface{}) string {
 		a, _ := json.Marshal(v)
@@ -20,6 +29,36 @@ var funcMap = template.FuncMap{
 	},
 }
 
+// inspectedHost is the host printed by inspect, with the addresses of the
+// machine on its networks and its estimated cost, which aren't stored.
+type inspectedHost struct {
+	*host.Host
+	Addresses []drivers.Address `json:",omitempty"`
+	Cost      *hostCost         `json:",omitempty"`
+}
+
+// inspectHost returns the host to print, with its estimated cost if its
+// price is known, and its addresses if it is running.
+func inspectHost(h *host.Host, provider pricing.Provider) inspectedHost {
+	inspected := inspectedHost{Host: h}
+
+	s, err := h.Driver.GetState()
+	inspected.Cost = estimateHostCost(provider, h, s)
+
+	if err != nil || s != state.Running {
+		return inspected
+	}
+
+	addresses, err := drivers.GetAddresses(h.Driver)
+	if err != nil {
+		log.Debugf("Error getting the addresses of %s: %s", h.Name, err)
+		return inspected
+	}
+	inspected.Addresses = addresses
+
+	return inspected
+}
+
 func cmdInspect(c *cli.Context) error {
 	if len(c.Args()) == 0 {
 		cli.ShowCommandHelp(c, "inspect")
@@ -31,7 +70,19 @@ func cmdInspect(c *cli.Context) error {
 		return err
 	}
 
-	tmplString := c.String("format")
+	if c.Bool("provision-log") {
+		if c.String("format") != "" {
+			return errProvisionLogFormat
+		}
+		return printTranscript(context.Background(), os.Stdout, transcriptPath(host.Name), 0, false)
+	}
+
+	return printInspected(inspectHost(host, pricing.NewProvider(c.String("pricing-url"))), c.String("format"))
+}
+
+// printInspected prints v as indented JSON, or with the template given with
+// --format, which gets v as a map.
+func printInspected(v interface{}, tmplString string) error {
 	if tmplString != "" {
 		var tmpl *template.Template
 		var err error
@@ -39,13 +90,13 @@ func cmdInspect(c *cli.Context) error {
 			return fmt.Errorf("Template parsing error: %v\n", err)
 		}
 
-		jsonHost, err := json.Marshal(host)
+		jsonValue, err := json.Marshal(v)
 		if err != nil {
 			return err
 		}
 
 		obj := make(map[string]interface{})
-		if err := json.Unmarshal(jsonHost, &obj); err != nil {
+		if err := json.Unmarshal(jsonValue, &obj); err != nil {
 			return err
 		}
 
@@ -55,7 +106,7 @@ func cmdInspect(c *cli.Context) error {
 
 		os.Stdout.Write([]byte{'\n'})
 	} else {
-		prettyJSON, err := json.MarshalIndent(host, "", "    ")
+		prettyJSON, err := json.MarshalIndent(v, "", "    ")
 		if err != nil {
 			return err
 		}
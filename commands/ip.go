@@ -1,7 +1,113 @@
 package commands
 
-import "github.com/docker/machine/cli"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/template"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errIPAllWithNames = errors.New("Error: --all lists every machine, it cannot be given machine names")
+	errIPFormat       = errors.New("Error: --format can only be used with --all")
+)
+
+// machineIP is the IP of a machine, as listed by ip --all, with its
+// addresses on its networks when it has several.
+type machineIP struct {
+	Name      string
+	IP        string
+	Addresses []drivers.Address `json:",omitempty"`
+}
 
 func cmdIp(c *cli.Context) error {
-	return runActionWithContext("ip", c)
+	if !c.Bool("all") {
+		if c.String("format") != "" {
+			return errIPFormat
+		}
+		return runActionWithContext("ip", c)
+	}
+
+	if len(c.Args()) > 0 {
+		return errIPAllWithNames
+	}
+
+	hosts, err := listHosts(getStore(c))
+	if err != nil {
+		return err
+	}
+
+	ips := machineIPs(hosts)
+	for _, m := range ips {
+		recordIP(m.Name, m.IP)
+	}
+
+	return printMachineIPs(os.Stdout, ips, c.String("format"))
+}
+
+// machineIPs returns the IPs of the hosts, found out in parallel, leaving
+// out the hosts which have none, like stopped machines.
+func machineIPs(hosts []*host.Host) []machineIP {
+	ips := make([]*machineIP, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+
+			ip, err := h.Driver.GetIP()
+			if err != nil || ip == "" {
+				log.Debugf("Not listing the IP of %s: %v", h.Name, err)
+				return
+			}
+
+			m := &machineIP{Name: h.Name, IP: ip}
+			if addresses, err := drivers.GetAddresses(h.Driver); err == nil && len(addresses) > 1 {
+				m.Addresses = addresses
+			}
+			ips[i] = m
+		}(i, h)
+	}
+	wg.Wait()
+
+	listed := []machineIP{}
+	for _, m := range ips {
+		if m != nil {
+			listed = append(listed, *m)
+		}
+	}
+	return listed
+}
+
+// printMachineIPs prints a line per machine: its name and IP separated by a
+// tab, or the machine formatted with the template.
+func printMachineIPs(w io.Writer, ips []machineIP, tmplString string) error {
+	if tmplString == "" {
+		for _, m := range ips {
+			fmt.Fprintf(w, "%s\t%s\n", m.Name, m.IP)
+		}
+		return nil
+	}
+
+	tmpl, err := template.New("").Funcs(funcMap).Parse(tmplString)
+	if err != nil {
+		return fmt.Errorf("Template parsing error: %v\n", err)
+	}
+
+	for _, m := range ips {
+		if err := tmpl.Execute(w, m); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
 }
@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/audit"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+	"golang.org/x/net/context"
+)
+
+// transcriptFollowInterval is how often logs --provision --follow checks for
+// new lines in the transcript.
+var transcriptFollowInterval = 500 * time.Millisecond
+
+// transcriptFile is the provisioning transcript of a machine, opened on the
+// first write, once the directory of the machine is created, and starting
+// with the command recording it.
+type transcriptFile struct {
+	path   string
+	header string
+	f      *os.File
+}
+
+func (t *transcriptFile) Write(p []byte) (int, error) {
+	if t.f == nil {
+		f, err := os.OpenFile(t.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return 0, err
+		}
+		t.f = f
+
+		if _, err := t.f.WriteString(t.header); err != nil {
+			return 0, err
+		}
+	}
+	return t.f.Write(p)
+}
+
+func (t *transcriptFile) Close() error {
+	if t.f == nil {
+		return nil
+	}
+	return t.f.Close()
+}
+
+func transcriptPath(name string) string {
+	return filepath.Join(mcndirs.GetMachineDir(), name, drivers.TranscriptFileName)
+}
+
+// withTranscript returns a copy of ctx recording the transcript of
+// provisioning the machine, appended to the previous ones, and the function
+// closing it.
+func withTranscript(ctx context.Context, name string) (context.Context, func()) {
+	t := &transcriptFile{
+		path: transcriptPath(name),
+		header: fmt.Sprintf("[%s] docker-machine %s\n",
+			time.Now().UTC().Format(time.RFC3339), strings.Join(audit.RedactArgs(os.Args[1:]), " ")),
+	}
+
+	return drivers.WithTranscript(ctx, t), func() {
+		t.Close()
+	}
+}
+
+func cmdLogs(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	lines := c.Int("tail")
+	if lines < 0 {
+		return fmt.Errorf("Error: --tail must be at least 0")
+	}
+
+	host, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("provision") {
+		ctx, cancel := commandContext(0)
+		defer cancel()
+
+		return printTranscript(ctx, os.Stdout, transcriptPath(host.Name), lines, c.Bool("follow"))
+	}
+
+	currentState, err := host.Driver.GetState()
+	if err != nil {
+		return err
+	}
+	if currentState != state.Running {
+		return fmt.Errorf("Error: Cannot get the engine logs: Host %q is not running", host.Name)
+	}
+
+	provisioner, err := provision.DetectProvisioner(host.Driver)
+	if err != nil {
+		return err
+	}
+
+	client, err := host.CreateSSHClient()
+	if err != nil {
+		return err
+	}
+
+	command := provision.EngineLogsCommand(provisioner, c.Bool("follow"), lines)
+	return ssh.WithTTY(client, ssh.TTYNone).Shell(command)
+}
+
+// printTranscript copies the transcript at path to w, starting with its last
+// lines unless lines is 0. When follow is true, it then copies the lines
+// added to the transcript until ctx is done.
+func printTranscript(ctx context.Context, w io.Writer, path string, lines int, follow bool) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Error: No provisioning transcript, the machine was provisioned before they were recorded")
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lastLines(data, lines)); err != nil {
+		return err
+	}
+
+	if !follow {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(int64(len(data)), os.SEEK_SET); err != nil {
+		return err
+	}
+
+	for {
+		if _, err := io.Copy(w, f); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(transcriptFollowInterval):
+		}
+	}
+}
+
+// lastLines returns the last lines of data, or all of it when lines is 0.
+func lastLines(data []byte, lines int) []byte {
+	if lines == 0 {
+		return data
+	}
+
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+
+	for i := 0; i < lines; i++ {
+		nl := bytes.LastIndexByte(data[:end], '\n')
+		if nl < 0 {
+			return data
+		}
+		end = nl
+	}
+
+	return data[end+1:]
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLastLines(t *testing.T) {
+	data := []byte("one\ntwo\nthree\n")
+
+	assert.Equal(t, "one\ntwo\nthree\n", string(lastLines(data, 0)))
+	assert.Equal(t, "three\n", string(lastLines(data, 1)))
+	assert.Equal(t, "two\nthree\n", string(lastLines(data, 2)))
+	assert.Equal(t, "one\ntwo\nthree\n", string(lastLines(data, 5)))
+	assert.Equal(t, "", string(lastLines([]byte{}, 2)))
+}
+
+func TestTranscriptFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-transcript")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dev", "provision.log")
+	transcript := &transcriptFile{path: path, header: "docker-machine create dev\n"}
+
+	// The directory of the machine isn't created until the machine is
+	// saved, nor is the transcript.
+	assert.NoError(t, transcript.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "dev"), 0700))
+	_, err = transcript.Write([]byte("Running stage created\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, transcript.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "docker-machine create dev\nRunning stage created\n", string(data))
+}
+
+func TestPrintTranscript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-transcript")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "provision.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("one\ntwo\n"), 0600))
+
+	var out bytes.Buffer
+	assert.NoError(t, printTranscript(context.Background(), &out, path, 1, false))
+	assert.Equal(t, "two\n", out.String())
+
+	err = printTranscript(context.Background(), &out, filepath.Join(dir, "missing.log"), 0, false)
+	assert.EqualError(t, err, "Error: No provisioning transcript, the machine was provisioned before they were recorded")
+}
+
+func TestPrintTranscriptFollow(t *testing.T) {
+	defer func(interval time.Duration) { transcriptFollowInterval = interval }(transcriptFollowInterval)
+	transcriptFollowInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "machine-transcript")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "provision.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("one\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &bytes.Buffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- printTranscript(ctx, out, path, 0, true)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	f.WriteString("two\n")
+	f.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, "one\ntwo\n", out.String())
+}
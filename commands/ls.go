@@ -13,7 +13,9 @@ import (
 	"github.com/docker/machine/cli"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/inventory"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/pricing"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/skarademir/naturalsort"
@@ -29,6 +31,8 @@ type FilterOptions struct {
 	DriverName []string
 	State      []string
 	Name       []string
+	Label      []string
+	Inventory  []inventory.Filter
 }
 
 type HostListItem struct {
@@ -53,6 +57,7 @@ func cmdLs(c *cli.Context) error {
 		return err
 	}
 
+	swarmModeClusters := getSwarmModeClusters(hostList)
 	hostList = filterHosts(hostList, filters)
 
 	// Just print out the names if we're being quiet
@@ -66,10 +71,27 @@ func cmdLs(c *cli.Context) error {
 	swarmMasters := make(map[string]string)
 	swarmInfo := make(map[string]string)
 
+	showCost := c.Bool("show-cost")
+	provider := pricing.NewProvider(c.String("pricing-url"))
+	hosts := map[string]*host.Host{}
+	costs := []*hostCost{}
+
+	showInventory := c.Bool("inventory")
+
+	header := "NAME\tACTIVE\tDRIVER\tSTATE\tURL\tSWARM"
+	if showInventory {
+		header += "\t" + inventoryHeader
+	}
+	if showCost {
+		header += "\tHOURLY\tMONTHLY"
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
-	fmt.Fprintln(w, "NAME\tACTIVE\tDRIVER\tSTATE\tURL\tSWARM")
+	fmt.Fprintln(w, header)
 
 	for _, host := range hostList {
+		hosts[host.Name] = host
+
 		swarmOptions := host.HostOptions.SwarmOptions
 		if swarmOptions.Master {
 			swarmMasters[swarmOptions.Discovery] = host.Name
@@ -98,8 +120,31 @@ func cmdLs(c *cli.Context) error {
 				swarmInfo = fmt.Sprintf("%s (master)", swarmInfo)
 			}
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+
+		if item.SwarmOptions.Mode {
+			swarmInfo = fmt.Sprintf("%s (%s)", swarmModeClusters[item.Name], item.SwarmOptions.Role)
+		}
+
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s",
 			item.Name, activeString, item.DriverName, item.State, item.URL, swarmInfo)
+
+		if showInventory {
+			row += "\t" + inventoryColumns(hosts[item.Name].Inventory)
+		}
+
+		if showCost {
+			cost := estimateHostCost(provider, hosts[item.Name], item.State)
+			costs = append(costs, cost)
+			row += fmt.Sprintf("\t%s\t%s", formatHourlyCost(cost), formatMonthlyCost(cost))
+		}
+
+		fmt.Fprintln(w, row)
+	}
+
+	if showCost {
+		total := totalCost(costs)
+		padding := strings.Repeat("\t", strings.Count(header, "\t")-2)
+		fmt.Fprintf(w, "TOTAL%s\t%s\t%s\n", padding, formatHourlyCost(total), formatMonthlyCost(total))
 	}
 
 	w.Flush()
@@ -107,14 +152,51 @@ func cmdLs(c *cli.Context) error {
 	return nil
 }
 
+// inventoryHeader is the header of the columns of inventoryColumns.
+const inventoryHeader = "ENGINE\tAPI\tOS\tKERNEL\tARCH\tSTORAGE\tFREE DISK"
+
+// inventoryColumns returns the inventory recorded for a machine as columns,
+// - for what is unknown.
+func inventoryColumns(inv *inventory.Inventory) string {
+	if inv == nil {
+		inv = &inventory.Inventory{}
+	}
+
+	freeDisk := ""
+	if inv.FreeDisk > 0 {
+		freeDisk = formatBytes(inv.FreeDisk)
+	}
+
+	columns := []string{inv.EngineVersion, inv.APIVersion, inv.OS, inv.Kernel, inv.Architecture, inv.StorageDriver, freeDisk}
+	for i, column := range columns {
+		if column == "" {
+			columns[i] = "-"
+		}
+	}
+
+	return strings.Join(columns, "\t")
+}
+
 func parseFilters(filters []string) (FilterOptions, error) {
 	options := FilterOptions{}
 	for _, f := range filters {
-		kv := strings.SplitN(f, "=", 2)
-		if len(kv) != 2 {
+		key, op, value := splitFilter(f)
+		if op == "" {
 			return options, errors.New("Unsupported filter syntax.")
 		}
-		key, value := kv[0], kv[1]
+
+		if inventory.IsKey(key) {
+			filter, err := inventory.NewFilter(key, op, value)
+			if err != nil {
+				return options, err
+			}
+			options.Inventory = append(options.Inventory, filter)
+			continue
+		}
+
+		if op != "=" && isFilterKey(key) {
+			return options, fmt.Errorf("The %s filter can only be compared with =", key)
+		}
 
 		switch key {
 		case "swarm":
@@ -125,6 +207,8 @@ func parseFilters(filters []string) (FilterOptions, error) {
 			options.State = append(options.State, value)
 		case "name":
 			options.Name = append(options.Name, value)
+		case "label":
+			options.Label = append(options.Label, value)
 		default:
 			return options, fmt.Errorf("Unsupported filter key '%s'", key)
 		}
@@ -132,19 +216,48 @@ func parseFilters(filters []string) (FilterOptions, error) {
 	return options, nil
 }
 
+func isFilterKey(key string) bool {
+	switch key {
+	case "swarm", "driver", "state", "name", "label":
+		return true
+	}
+	return false
+}
+
+// splitFilter splits a filter at its first operator, like engine-version<19.03
+// into engine-version, < and 19.03, the operator being empty when there is
+// none.
+func splitFilter(f string) (string, string, string) {
+	i := strings.IndexAny(f, "<>!=")
+	if i < 0 {
+		return f, "", ""
+	}
+
+	for _, op := range inventory.Operators {
+		if strings.HasPrefix(f[i:], op) {
+			return f[:i], op, f[i+len(op):]
+		}
+	}
+
+	return f, "", ""
+}
+
 func filterHosts(hosts []*host.Host, filters FilterOptions) []*host.Host {
 	if len(filters.SwarmName) == 0 &&
 		len(filters.DriverName) == 0 &&
 		len(filters.State) == 0 &&
-		len(filters.Name) == 0 {
+		len(filters.Name) == 0 &&
+		len(filters.Label) == 0 &&
+		len(filters.Inventory) == 0 {
 		return hosts
 	}
 
 	filteredHosts := []*host.Host{}
 	swarmMasters := getSwarmMasters(hosts)
+	swarmModeClusters := getSwarmModeClusters(hosts)
 
 	for _, h := range hosts {
-		if filterHost(h, filters, swarmMasters) {
+		if filterHost(h, filters, swarmMasters, swarmModeClusters) {
 			filteredHosts = append(filteredHosts, h)
 		}
 	}
@@ -162,16 +275,47 @@ func getSwarmMasters(hosts []*host.Host) map[string]string {
 	return swarmMasters
 }
 
-func filterHost(host *host.Host, filters FilterOptions, swarmMasters map[string]string) bool {
-	swarmMatches := matchesSwarmName(host, filters.SwarmName, swarmMasters)
+// getSwarmModeClusters returns the cluster of every machine in swarm mode,
+// named after the machine which initialized it.
+func getSwarmModeClusters(hosts []*host.Host) map[string]string {
+	joinManagers := make(map[string]string)
+	for _, h := range hosts {
+		swarmOptions := h.HostOptions.SwarmOptions
+		if swarmOptions != nil && swarmOptions.Mode {
+			joinManagers[h.Name] = swarmOptions.JoinManager
+		}
+	}
+
+	clusters := make(map[string]string)
+	for name := range joinManagers {
+		cluster := name
+		// Follow the managers joined up to the machine which initialized
+		// the cluster, or to one which left it, bounded in case of a loop.
+		for i := 0; i < len(joinManagers); i++ {
+			manager, ok := joinManagers[cluster]
+			if !ok || manager == "" {
+				break
+			}
+			cluster = manager
+		}
+		clusters[name] = cluster
+	}
+
+	return clusters
+}
+
+func filterHost(host *host.Host, filters FilterOptions, swarmMasters, swarmModeClusters map[string]string) bool {
+	swarmMatches := matchesSwarmName(host, filters.SwarmName, swarmMasters, swarmModeClusters)
 	driverMatches := matchesDriverName(host, filters.DriverName)
 	stateMatches := matchesState(host, filters.State)
 	nameMatches := matchesName(host, filters.Name)
+	labelMatches := matchesLabel(host, filters.Label)
+	inventoryMatches := matchesInventory(host, filters.Inventory)
 
-	return swarmMatches && driverMatches && stateMatches && nameMatches
+	return swarmMatches && driverMatches && stateMatches && nameMatches && labelMatches && inventoryMatches
 }
 
-func matchesSwarmName(host *host.Host, swarmNames []string, swarmMasters map[string]string) bool {
+func matchesSwarmName(host *host.Host, swarmNames []string, swarmMasters, swarmModeClusters map[string]string) bool {
 	if len(swarmNames) == 0 {
 		return true
 	}
@@ -180,6 +324,9 @@ func matchesSwarmName(host *host.Host, swarmNames []string, swarmMasters map[str
 			if n == swarmMasters[host.HostOptions.SwarmOptions.Discovery] {
 				return true
 			}
+			if host.HostOptions.SwarmOptions.Mode && n == swarmModeClusters[host.Name] {
+				return true
+			}
 		}
 	}
 	return false
@@ -230,6 +377,37 @@ func matchesName(host *host.Host, names []string) bool {
 	return false
 }
 
+// matchesLabel tells whether the engine of the machine has one of the labels,
+// given as key=value or as a key matching whatever the value.
+func matchesLabel(host *host.Host, labels []string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+	if host.HostOptions == nil || host.HostOptions.EngineOptions == nil {
+		return false
+	}
+	for _, n := range labels {
+		for _, l := range host.HostOptions.EngineOptions.Labels {
+			if l == n || (!strings.Contains(n, "=") && strings.SplitN(l, "=", 2)[0] == n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesInventory tells whether the inventory recorded for the machine
+// satisfies all the filters, for ranges like engine-version>=18.09 and
+// engine-version<19.03 to be given as two filters.
+func matchesInventory(host *host.Host, filters []inventory.Filter) bool {
+	for _, f := range filters {
+		if !f.Matches(host.Inventory) {
+			return false
+		}
+	}
+	return true
+}
+
 func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 	stateCh := make(chan state.State)
 	urlCh := make(chan string)
@@ -7,7 +7,9 @@ import (
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/inventory"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
@@ -102,6 +104,98 @@ func TestFilterHostsReturnsEmptyGivenNonMatchingFilters(t *testing.T) {
 	assert.Empty(t, filterHosts(hosts, opts))
 }
 
+func TestParseFiltersLabel(t *testing.T) {
+	actual, _ := parseFilters([]string{"label=env=prod"})
+	assert.Equal(t, actual, FilterOptions{Label: []string{"env=prod"}})
+}
+
+func TestFilterHostsByLabel(t *testing.T) {
+	prod := &host.Host{
+		Name: "prod",
+		HostOptions: &host.HostOptions{
+			EngineOptions: &engine.EngineOptions{Labels: []string{"env=prod", "team=web"}},
+		},
+	}
+	staging := &host.Host{
+		Name: "staging",
+		HostOptions: &host.HostOptions{
+			EngineOptions: &engine.EngineOptions{Labels: []string{"env=staging"}},
+		},
+	}
+	unlabeled := &host.Host{
+		Name:        "unlabeled",
+		HostOptions: &host.HostOptions{},
+	}
+	hosts := []*host.Host{prod, staging, unlabeled}
+
+	assert.Equal(t, []*host.Host{prod}, filterHosts(hosts, FilterOptions{Label: []string{"env=prod"}}))
+	assert.Equal(t, []*host.Host{prod, staging}, filterHosts(hosts, FilterOptions{Label: []string{"env"}}))
+	assert.Equal(t, []*host.Host{prod, staging}, filterHosts(hosts, FilterOptions{Label: []string{"team", "env=staging"}}))
+	assert.Empty(t, filterHosts(hosts, FilterOptions{Label: []string{"env=dev"}}))
+}
+
+func TestParseFiltersInventory(t *testing.T) {
+	actual, err := parseFilters([]string{"engine-version<19.03", "free-disk>=10GB", "name=dev"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, actual.Name)
+	assert.Len(t, actual.Inventory, 2)
+	assert.Equal(t, "engine-version<19.03", actual.Inventory[0].String())
+	assert.Equal(t, "free-disk>=10GB", actual.Inventory[1].String())
+}
+
+func TestParseFiltersInventoryErrors(t *testing.T) {
+	_, err := parseFilters([]string{"name<dev"})
+	assert.EqualError(t, err, "The name filter can only be compared with =")
+
+	_, err = parseFilters([]string{"os<ubuntu"})
+	assert.EqualError(t, err, "The os filter can only be compared with = or !=")
+
+	_, err = parseFilters([]string{"engine-version<latest"})
+	assert.EqualError(t, err, `Invalid engine-version filter: invalid version "latest"`)
+}
+
+func TestFilterHostsByInventory(t *testing.T) {
+	old := &host.Host{
+		Name:        "old",
+		HostOptions: &host.HostOptions{},
+		Inventory:   &inventory.Inventory{EngineVersion: "18.09.7", OS: "Ubuntu 18.04.6 LTS", FreeDisk: 5 << 30},
+	}
+	recent := &host.Host{
+		Name:        "recent",
+		HostOptions: &host.HostOptions{},
+		Inventory:   &inventory.Inventory{EngineVersion: "20.10.24", OS: "Ubuntu 22.04.3 LTS", FreeDisk: 50 << 30},
+	}
+	unknown := &host.Host{
+		Name:        "unknown",
+		HostOptions: &host.HostOptions{},
+	}
+	hosts := []*host.Host{old, recent, unknown}
+
+	filter := func(filters ...string) []*host.Host {
+		options, err := parseFilters(filters)
+		assert.NoError(t, err)
+		return filterHosts(hosts, options)
+	}
+
+	assert.Equal(t, []*host.Host{old}, filter("engine-version<19.03"))
+	assert.Equal(t, []*host.Host{recent}, filter("engine-version>=18.09", "free-disk>10GB"))
+	assert.Equal(t, []*host.Host{old}, filter("os=ubuntu 18.04.6 lts"))
+	assert.Equal(t, []*host.Host{old, recent}, filter("engine-version>=18.09"))
+}
+
+func TestInventoryColumns(t *testing.T) {
+	assert.Equal(t, "-\t-\t-\t-\t-\t-\t-", inventoryColumns(nil))
+	assert.Equal(t, "20.10.24\t1.41\tUbuntu 22.04.3 LTS\t5.15.0-91-generic\tx86_64\toverlay2\t50.0GB", inventoryColumns(&inventory.Inventory{
+		EngineVersion: "20.10.24",
+		APIVersion:    "1.41",
+		OS:            "Ubuntu 22.04.3 LTS",
+		Kernel:        "5.15.0-91-generic",
+		Architecture:  "x86_64",
+		StorageDriver: "overlay2",
+		FreeDisk:      50 << 30,
+	}))
+}
+
 func TestFilterHostsBySwarmName(t *testing.T) {
 	opts := FilterOptions{
 		SwarmName: []string{"master"},
@@ -465,3 +559,23 @@ func TestGetHostListItemsEnvDockerHostUnset(t *testing.T) {
 		}
 	}
 }
+
+func TestGetSwarmModeClusters(t *testing.T) {
+	hosts := []*host.Host{
+		{Name: "manager-0", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager}}},
+		{Name: "manager-1", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager, JoinManager: "manager-0"}}},
+		{Name: "worker-0", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleWorker, JoinManager: "manager-1"}}},
+		{Name: "legacy", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{IsSwarm: true, Master: true, Discovery: "foo"}}},
+	}
+
+	assert.Equal(t, map[string]string{
+		"manager-0": "manager-0",
+		"manager-1": "manager-0",
+		"worker-0":  "manager-0",
+	}, getSwarmModeClusters(hosts))
+
+	opts := FilterOptions{
+		SwarmName: []string{"manager-0"},
+	}
+	assert.EqualValues(t, hosts[:3], filterHosts(hosts, opts))
+}
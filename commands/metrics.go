@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/metrics"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+func cmdMetrics(c *cli.Context) error {
+	store := getStore(c)
+
+	addr := c.String("listen")
+	if addr == "" {
+		machines, err := collectMetrics(store)
+		if err != nil {
+			return err
+		}
+		return metrics.Write(os.Stdout, machines, time.Now())
+	}
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		machines, err := collectMetrics(store)
+		if err != nil {
+			log.Errorf("Error collecting metrics: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := metrics.Write(&buf, machines, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		buf.WriteTo(w)
+	})
+
+	log.Infof("Serving metrics on http://%s/metrics", addr)
+
+	return http.ListenAndServe(addr, nil)
+}
+
+// collectMetrics gathers the metrics of every machine in the store. The
+// driver plugins started for them are closed afterwards, since a metrics
+// server would otherwise leave a set of them behind on every scrape.
+func collectMetrics(store persist.Store) ([]metrics.Machine, error) {
+	hosts, err := listHosts(store)
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make([]metrics.Machine, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+			machines[i] = metrics.Collect(h)
+
+			if rpcd, ok := h.Driver.(*rpcdriver.RpcClientDriver); ok {
+				rpcd.Close()
+			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	return machines, nil
+}
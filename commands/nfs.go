@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/nfs"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// sshClientIPCommand prints the IP the SSH connection comes from, the IP of
+// the local host as seen by a machine on another network.
+const sshClientIPCommand = `echo "${SSH_CLIENT%% *}"`
+
+var (
+	errNFSPathRequired = errors.New("Error: --path is required, the local directory to share")
+
+	// nfsExports returns the exports file the shares of the machines of
+	// the store are written in.
+	nfsExports = func() nfs.Exports {
+		return nfs.Exports{Path: nfs.DefaultExportsPath, Store: mcndirs.GetBaseDir()}
+	}
+
+	// interfaceAddrs lists the addresses of the local network interfaces.
+	interfaceAddrs = net.InterfaceAddrs
+)
+
+// localIPFor returns the IP of the local network interface on the network
+// of the machine IP, like the host-only network of a local VM, if any.
+func localIPFor(machineIP string) (string, error) {
+	ip := net.ParseIP(machineIP)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP %q", machineIP)
+	}
+
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.Contains(ip) && !ipNet.IP.Equal(ip) {
+			return ipNet.IP.String(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// hostIPFor returns the IP of the local host as seen by the machine: the
+// IP of the local network interface on its network, or else the IP its SSH
+// connections come from.
+func hostIPFor(d drivers.Driver, machineIP string) (string, error) {
+	ip, err := localIPFor(machineIP)
+	if err != nil || ip != "" {
+		return ip, err
+	}
+
+	output, err := drivers.RunSSHCommandFromDriver(d, sshClientIPCommand)
+	if err != nil {
+		return "", err
+	}
+
+	ip = strings.TrimSpace(output)
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("unexpected SSH client IP %q", ip)
+	}
+	return ip, nil
+}
+
+// withShare returns the shares with share added, replacing a share of the
+// same local directory or on the same mount point.
+func withShare(shares []nfs.Share, share nfs.Share) []nfs.Share {
+	updated := []nfs.Share{}
+	for _, s := range shares {
+		if s.Path != share.Path && s.MountPoint != share.MountPoint {
+			updated = append(updated, s)
+		}
+	}
+	return append(updated, share)
+}
+
+// removeNFSExports stops exporting the shares of a removed machine.
+func removeNFSExports(h *host.Host) {
+	if len(h.NFSShares) == 0 {
+		return
+	}
+
+	if err := nfsExports().Remove(h.Name); err != nil {
+		log.Warnf("Error removing the NFS exports of %s: %s", h.Name, err)
+	}
+}
+
+func cmdNFSEnable(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+	if c.String("path") == "" {
+		return errNFSPathRequired
+	}
+
+	path, err := filepath.Abs(c.String("path"))
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		return fmt.Errorf("Error: %s isn't a local directory", path)
+	}
+
+	mountPoint := c.String("mount-point")
+	if mountPoint == "" {
+		mountPoint = filepath.ToSlash(path)
+	}
+
+	store := getStore(c)
+
+	h, err := loadHost(store, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if err := h.RefuseAdopted("share a directory with"); err != nil {
+		return err
+	}
+
+	if s, err := h.Driver.GetState(); err != nil || s != state.Running {
+		return fmt.Errorf("Error: Cannot share %s: Host %q is not running", path, h.Name)
+	}
+
+	machineIP, err := h.Driver.GetIP()
+	if err != nil {
+		return fmt.Errorf("Error getting the IP address of %s: %s", h.Name, err)
+	}
+
+	hostIP := c.String("host-ip")
+	if hostIP == "" {
+		if hostIP, err = hostIPFor(h.Driver, machineIP); err != nil {
+			return fmt.Errorf("Error finding out the IP of the local host as seen by %s, give it with --host-ip: %s", h.Name, err)
+		}
+	}
+
+	share := nfs.Share{Path: path, MountPoint: mountPoint, HostIP: hostIP}
+	if err := share.Validate(); err != nil {
+		return err
+	}
+
+	shares := withShare(h.NFSShares, share)
+
+	log.Infof("Exporting %s to %s...", path, h.Name)
+	if err := nfsExports().Set(h.Name, machineIP, shares); err != nil {
+		return err
+	}
+
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	if err := provision.MountNFS(provisioner, share.Source(), share.MountPoint); err != nil {
+		return err
+	}
+
+	h.NFSShares = shares
+	return saveHost(store, h)
+}
+
+func cmdNFSDisable(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	store := getStore(c)
+
+	h, err := loadHost(store, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	removed, kept := h.NFSShares, []nfs.Share{}
+	if c.String("path") != "" {
+		path, err := filepath.Abs(c.String("path"))
+		if err != nil {
+			return err
+		}
+
+		removed = []nfs.Share{}
+		for _, s := range h.NFSShares {
+			if s.Path == path {
+				removed = append(removed, s)
+			} else {
+				kept = append(kept, s)
+			}
+		}
+
+		if len(removed) == 0 {
+			return fmt.Errorf("Error: %s isn't shared with %s", path, h.Name)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if s, err := h.Driver.GetState(); err != nil || s != state.Running {
+		if len(kept) > 0 {
+			return fmt.Errorf("Error: Cannot unshare %s: Host %q is not running", removed[0].Path, h.Name)
+		}
+		log.Warnf("%s isn't running, its shares are no longer exported but it still tries to mount them on boot", h.Name)
+	} else {
+		provisioner, err := provision.DetectProvisioner(h.Driver)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range removed {
+			if err := provision.UnmountNFS(provisioner, s.MountPoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	machineIP := ""
+	if len(kept) > 0 {
+		if machineIP, err = h.Driver.GetIP(); err != nil {
+			return fmt.Errorf("Error getting the IP address of %s: %s", h.Name, err)
+		}
+	}
+
+	if err := nfsExports().Set(h.Name, machineIP, kept); err != nil {
+		return err
+	}
+
+	h.NFSShares = kept
+	return saveHost(store, h)
+}
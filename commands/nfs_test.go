@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"net"
+	"testing"
+
+	"github.com/docker/machine/libmachine/nfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalIPFor(t *testing.T) {
+	defer func() { interfaceAddrs = net.InterfaceAddrs }()
+
+	interfaceAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{
+			&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+			&net.IPNet{IP: net.ParseIP("10.0.0.12"), Mask: net.CIDRMask(24, 32)},
+			&net.IPNet{IP: net.ParseIP("192.168.99.1"), Mask: net.CIDRMask(24, 32)},
+		}, nil
+	}
+
+	ip, err := localIPFor("192.168.99.100")
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.99.1", ip)
+
+	ip, err = localIPFor("54.12.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "", ip)
+
+	_, err = localIPFor("dev.docker.local")
+	assert.Error(t, err)
+}
+
+func TestWithShare(t *testing.T) {
+	src := nfs.Share{Path: "/home/me/src", MountPoint: "/home/me/src", HostIP: "192.168.99.1"}
+	www := nfs.Share{Path: "/home/me/www", MountPoint: "/var/www", HostIP: "192.168.99.1"}
+
+	shares := withShare(withShare(nil, src), www)
+	assert.Equal(t, []nfs.Share{src, www}, shares)
+
+	moved := nfs.Share{Path: "/home/me/src", MountPoint: "/src", HostIP: "192.168.99.1"}
+	assert.Equal(t, []nfs.Share{www, moved}, withShare(shares, moved))
+
+	replaced := nfs.Share{Path: "/home/me/site", MountPoint: "/var/www", HostIP: "192.168.99.1"}
+	assert.Equal(t, []nfs.Share{src, replaced}, withShare(shares, replaced))
+}
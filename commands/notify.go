@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/audit"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/notify"
+)
+
+func decodeNotifySinks(c *config, encoded []byte) error {
+	if err := json.Unmarshal(encoded, &c.Notify); err != nil {
+		return fmt.Errorf("expected \"notify\" to be a list of sinks: %s", err)
+	}
+
+	for i, sink := range c.Notify {
+		if err := sink.Validate(); err != nil {
+			return fmt.Errorf("notify sink %d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// notifyMachine sends the event of the machine to the sinks of the config
+// file, with the error of the operation for error events. The IP of the
+// machine is looked up with d, when given, for the other events.
+func notifyMachine(event, name, driverName string, d drivers.Driver, err error) {
+	sinks := eventSinks(event, name)
+	if len(sinks) == 0 {
+		return
+	}
+
+	e := notify.Event{
+		Event:   event,
+		Machine: name,
+		Driver:  driverName,
+		Time:    time.Now().UTC(),
+		User:    audit.CurrentUser(),
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	} else if d != nil {
+		if ip, err := d.GetIP(); err == nil {
+			e.IP = ip
+		}
+	}
+
+	notify.Send(sinks, e)
+}
+
+// eventSinks returns the sinks of the config file the event of the machine
+// is sent to, none when the config file can't be read.
+func eventSinks(event, name string) []notify.Sink {
+	cfg, err := readConfig(configFilePath())
+	if err != nil {
+		log.Warnf("Error sending the %s event of %s: %s", event, name, err)
+		return nil
+	}
+	return cfg.Notify
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/pkgcache"
+)
+
+// packageCacheLocal is the --package-cache running the package cache in
+// docker-machine while the machines are created.
+const packageCacheLocal = "local"
+
+var errPackageCache = errors.New(`Error: --package-cache must be "local" or the host:port of a caching proxy`)
+
+// withPackageCache runs create with the machines of cfgs downloading their
+// packages through the package cache: the one docker-machine runs until
+// create returns for "local", caching the packages in the store, or else
+// the caching proxy at the address of cache, like one running on a machine
+// set aside for it.
+func withPackageCache(storePath, cache string, cfgs []machineConfig, create func() error) error {
+	if cache == "" {
+		return create()
+	}
+
+	address := cache
+	if cache == packageCacheLocal {
+		l, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return fmt.Errorf("Error running the package cache: %s", err)
+		}
+
+		proxy := pkgcache.NewProxy(filepath.Join(storePath, "package-cache"))
+		server := &http.Server{Handler: privateOnly(proxy)}
+		go server.Serve(l)
+
+		defer func() {
+			server.Close()
+			hits, misses := proxy.Stats()
+			log.Infof("The package cache served %d packages, %d of them without downloading them", hits+misses, hits)
+		}()
+
+		address = fmt.Sprintf(":%d", l.Addr().(*net.TCPAddr).Port)
+	} else if _, _, err := net.SplitHostPort(cache); err != nil {
+		return errPackageCache
+	}
+
+	for _, cfg := range cfgs {
+		cfg.EngineOptions.PackageCache = address
+	}
+
+	return create()
+}
+
+// privateOnly only lets the requests of the private networks, the ones of
+// local machines, through the handler, for the package cache not to be an
+// open proxy.
+func privateOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		ip := net.ParseIP(host)
+		if err != nil || ip == nil || !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()) {
+			http.Error(w, "docker-machine package cache: only the machines of private networks are served", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
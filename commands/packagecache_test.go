@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPackageCacheLocal(t *testing.T) {
+	cfgs := []machineConfig{{Name: "a", EngineOptions: &engine.EngineOptions{}}, {Name: "b", EngineOptions: &engine.EngineOptions{}}}
+
+	dir, err := ioutil.TempDir("", "machine-package-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = withPackageCache(dir, "local", cfgs, func() error {
+		for _, cfg := range cfgs {
+			assert.True(t, strings.HasPrefix(cfg.EngineOptions.PackageCache, ":"))
+			resp, err := http.Get("http://127.0.0.1" + cfg.EngineOptions.PackageCache + "/")
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestWithPackageCacheAddress(t *testing.T) {
+	cfgs := []machineConfig{{Name: "a", EngineOptions: &engine.EngineOptions{}}}
+
+	assert.NoError(t, withPackageCache("", "10.0.0.5:3142", cfgs, func() error { return nil }))
+	assert.Equal(t, "10.0.0.5:3142", cfgs[0].EngineOptions.PackageCache)
+
+	assert.Equal(t, errPackageCache, withPackageCache("", "cache", cfgs, func() error { return nil }))
+}
+
+func TestPrivateOnly(t *testing.T) {
+	handler := privateOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for addr, status := range map[string]int{
+		"127.0.0.1:40000":    http.StatusOK,
+		"192.168.99.100:400": http.StatusOK,
+		"10.0.2.15:400":      http.StatusOK,
+		"203.0.113.7:400":    http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "http://example.com/", nil)
+		r.RemoteAddr = addr
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, status, w.Code, addr)
+	}
+}
@@ -0,0 +1,7 @@
+package commands
+
+import "github.com/docker/machine/cli"
+
+func cmdPause(c *cli.Context) error {
+	return runActionWithContext("pause", c)
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// secretFlagPattern matches the names of the driver flags whose values are
+// masked in plans.
+var secretFlagPattern = regexp.MustCompile(`secret|password|token|access-key|api-key`)
+
+// planMachines prints what creating the machines of cfgs would do, for
+// create --dry-run, without creating anything. The driver of every machine
+// is still configured from the flags, for them to be checked.
+func planMachines(store *persist.Filestore, certInfo cert.CertPathInfo, cfgs []machineConfig, w io.Writer) error {
+	planned := map[string]bool{}
+
+	for i, cfg := range cfgs {
+		h, driverOpts, err := prepareMachine(store, certInfo, cfg, planned)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		err = printPlan(w, h, driverOpts)
+
+		if rpcd, ok := h.Driver.(*rpcdriver.RpcClientDriver); ok {
+			rpcd.Close()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		planned[cfg.Name] = true
+	}
+
+	return nil
+}
+
+// printPlan prints the resources the driver of h would allocate, the stages
+// of creating the machine and how it would be provisioned.
+func printPlan(w io.Writer, h *host.Host, driverOpts drivers.DriverOptions) error {
+	resources, err := planResources(h.Driver, driverOpts)
+	if err != nil {
+		return fmt.Errorf("Error planning machine %q: %s", h.Name, err)
+	}
+
+	fmt.Fprintf(w, "Machine %q would be created with the %s driver.\n\n", h.Name, h.DriverName)
+
+	tw := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tDESCRIPTION")
+	for _, resource := range resources {
+		fmt.Fprintf(tw, "%s\t%s\n", resource.Kind, resource.Description)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tDESCRIPTION")
+	for _, stage := range host.CreateStages {
+		fmt.Fprintf(tw, "%s\t%s\n", stage, stageDescription(h.Driver, stage))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "PROVISIONING")
+	for _, step := range provisioningPlan(h) {
+		fmt.Fprintf(w, "- %s\n", step)
+	}
+
+	return nil
+}
+
+// planResources returns the resources the driver would allocate, or, for
+// the drivers which can't tell, the options it was given.
+func planResources(d drivers.Driver, driverOpts drivers.DriverOptions) ([]drivers.PlannedResource, error) {
+	if drivers.SupportsPlan(d) {
+		return drivers.GetPlan(d)
+	}
+
+	return flagsPlan(d.GetCreateFlags(), driverOpts), nil
+}
+
+// flagsPlan returns the options given to a driver which can't plan the
+// creation of machines, skipping the empty ones and masking the secret ones.
+func flagsPlan(mcnFlags []mcnflag.Flag, driverOpts drivers.DriverOptions) []drivers.PlannedResource {
+	resources := []drivers.PlannedResource{}
+
+	for _, f := range mcnFlags {
+		name := f.String()
+		value := ""
+
+		switch f.(type) {
+		case *mcnflag.StringFlag:
+			value = driverOpts.String(name)
+		case *mcnflag.IntFlag:
+			value = fmt.Sprint(driverOpts.Int(name))
+		case *mcnflag.BoolFlag:
+			if driverOpts.Bool(name) {
+				value = "true"
+			}
+		case *mcnflag.StringSliceFlag:
+			value = strings.Join(driverOpts.StringSlice(name), ",")
+		}
+
+		if value == "" {
+			continue
+		}
+
+		if secretFlagPattern.MatchString(name) {
+			value = "********"
+		}
+
+		resources = append(resources, drivers.PlannedResource{
+			Kind:        "option",
+			Description: fmt.Sprintf("--%s %s", name, value),
+		})
+	}
+
+	return resources
+}
+
+// stageDescription tells what creating the machine does at stage, with how
+// long the stages waiting for the machine may last.
+func stageDescription(d drivers.Driver, stage host.CreateStage) string {
+	timeouts := drivers.GetWaitTimeouts(d)
+
+	switch stage {
+	case host.StageCreated:
+		return "Allocate the resources and create the machine"
+	case host.StageIPAssigned:
+		return fmt.Sprintf("Wait up to %s for the machine to run and get an IP address", timeouts.Running)
+	case host.StageSSHReady:
+		return fmt.Sprintf("Wait up to %s for SSH to be available", timeouts.SSH)
+	case host.StageProvisioned:
+		return "Detect the operating system and provision the machine"
+	case host.StageCerts:
+		return "Check the certificates of the Docker engine"
+	}
+
+	return ""
+}
+
+// provisioningPlan returns the steps of provisioning the machine of h.
+func provisioningPlan(h *host.Host) []string {
+	steps := []string{}
+
+	if volume := drivers.GetVolume(h.Driver); volume != nil {
+		steps = append(steps, fmt.Sprintf("Format and mount the %dGB %s volume on %s", volume.Size, volume.Type, volume.Mount))
+	}
+
+	engineOptions := h.HostOptions.EngineOptions
+	steps = append(steps, fmt.Sprintf("Install Docker from %s, unless the operating system comes with it", engineOptions.InstallURL))
+
+	if engineOptions.StorageDriver != "" {
+		steps = append(steps, fmt.Sprintf("Use the %s storage driver", engineOptions.StorageDriver))
+	}
+	if len(engineOptions.Labels) > 0 {
+		steps = append(steps, fmt.Sprintf("Label the engine with %s", strings.Join(engineOptions.Labels, ", ")))
+	}
+	if len(engineOptions.RegistryMirror) > 0 {
+		steps = append(steps, fmt.Sprintf("Pull through the registry mirrors %s", strings.Join(engineOptions.RegistryMirror, ", ")))
+	}
+	if len(engineOptions.RegistryCA) > 0 {
+		steps = append(steps, fmt.Sprintf("Trust the CA certificates of the registries %s", strings.Join(registryHosts(engineOptions.RegistryCA), ", ")))
+	}
+	if len(engineOptions.RegistryAuth) > 0 {
+		steps = append(steps, fmt.Sprintf("Log the docker CLI in to the registries %s", strings.Join(registryHosts(engineOptions.RegistryAuth), ", ")))
+	}
+	if len(engineOptions.InsecureRegistry) > 0 {
+		steps = append(steps, fmt.Sprintf("Allow the insecure registries %s", strings.Join(engineOptions.InsecureRegistry, ", ")))
+	}
+	if len(engineOptions.ArbitraryFlags) > 0 {
+		steps = append(steps, fmt.Sprintf("Run the engine with --%s", strings.Join(engineOptions.ArbitraryFlags, ", --")))
+	}
+	if len(engineOptions.Env) > 0 {
+		steps = append(steps, fmt.Sprintf("Run the engine with the environment %s", strings.Join(engineOptions.Env, ", ")))
+	}
+	if engineOptions.OptionsDir != "" {
+		steps = append(steps, fmt.Sprintf("Write the engine options and certificates to %s", engineOptions.OptionsDir))
+	}
+	if engineOptions.OptionsFile != "" {
+		steps = append(steps, fmt.Sprintf("Write the engine options to %s", engineOptions.OptionsFile))
+	}
+
+	authOptions := h.HostOptions.AuthOptions
+	steps = append(steps, fmt.Sprintf("Generate a server certificate signed by the CA in %s for the engine to use TLS", filepath.Dir(authOptions.CaCertPath)))
+
+	swarmOptions := h.HostOptions.SwarmOptions
+	switch {
+	case swarmOptions.Mode && swarmOptions.JoinManager != "":
+		steps = append(steps, fmt.Sprintf("Join the swarm mode cluster of %s as a %s", swarmOptions.JoinManager, swarmOptions.Role))
+	case swarmOptions.Mode:
+		steps = append(steps, "Initialize a swarm mode cluster")
+	case swarmOptions.IsSwarm && swarmOptions.Master:
+		steps = append(steps, fmt.Sprintf("Run the swarm master and agent from %s, with discovery %s", swarmOptions.Image, swarmOptions.Discovery))
+	case swarmOptions.IsSwarm:
+		steps = append(steps, fmt.Sprintf("Run the swarm agent from %s, with discovery %s", swarmOptions.Image, swarmOptions.Discovery))
+	}
+
+	return steps
+}
+
+// registryHosts returns the registries of registry options of the engine
+// options, like --engine-registry-auth, without their values, which may be
+// secrets.
+func registryHosts(options []string) []string {
+	hosts := []string{}
+	for _, option := range options {
+		hosts = append(hosts, strings.SplitN(option, "=", 2)[0])
+	}
+	return hosts
+}
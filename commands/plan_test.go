@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagsPlan(t *testing.T) {
+	mcnFlags := []mcnflag.Flag{
+		&mcnflag.StringFlag{Name: "fake-region"},
+		&mcnflag.StringFlag{Name: "fake-image"},
+		&mcnflag.StringFlag{Name: "fake-access-token"},
+		&mcnflag.IntFlag{Name: "fake-disk-size"},
+		&mcnflag.BoolFlag{Name: "fake-ipv6"},
+		&mcnflag.StringSliceFlag{Name: "fake-tag"},
+	}
+	driverOpts := rpcdriver.RpcFlags{Values: map[string]interface{}{
+		"fake-region":       "us-east",
+		"fake-image":        "",
+		"fake-access-token": "s3cr3t",
+		"fake-disk-size":    20,
+		"fake-ipv6":         false,
+		"fake-tag":          []string{"a", "b"},
+	}}
+
+	assert.Equal(t, []drivers.PlannedResource{
+		{Kind: "option", Description: "--fake-region us-east"},
+		{Kind: "option", Description: "--fake-access-token ********"},
+		{Kind: "option", Description: "--fake-disk-size 20"},
+		{Kind: "option", Description: "--fake-tag a,b"},
+	}, flagsPlan(mcnFlags, driverOpts))
+}
+
+func TestProvisioningPlan(t *testing.T) {
+	h := &host.Host{
+		Driver: &fakedriver.Driver{},
+		HostOptions: &host.HostOptions{
+			EngineOptions: &engine.EngineOptions{
+				InstallURL: "https://get.docker.com",
+				Labels:     []string{"env=ci"},
+			},
+			AuthOptions: &auth.AuthOptions{CaCertPath: "/store/certs/ca.pem"},
+			SwarmOptions: &swarm.SwarmOptions{
+				Mode:        true,
+				Role:        swarm.RoleWorker,
+				JoinManager: "manager-0",
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"Install Docker from https://get.docker.com, unless the operating system comes with it",
+		"Label the engine with env=ci",
+		"Generate a server certificate signed by the CA in /store/certs for the engine to use TLS",
+		"Join the swarm mode cluster of manager-0 as a worker",
+	}, provisioningPlan(h))
+}
+
+func TestProvisioningPlanHidesRegistryCredentials(t *testing.T) {
+	h := &host.Host{
+		Driver: &fakedriver.Driver{},
+		HostOptions: &host.HostOptions{
+			EngineOptions: &engine.EngineOptions{
+				RegistryMirror: []string{"https://mirror.example.com"},
+				RegistryCA:     []string{"mirror.example.com=/certs/mirror-ca.pem"},
+				RegistryAuth:   []string{"mirror.example.com=ci:secret"},
+			},
+			AuthOptions:  &auth.AuthOptions{CaCertPath: "/store/certs/ca.pem"},
+			SwarmOptions: &swarm.SwarmOptions{},
+		},
+	}
+
+	plan := provisioningPlan(h)
+
+	assert.Contains(t, plan, "Trust the CA certificates of the registries mirror.example.com")
+	assert.Contains(t, plan, "Log the docker CLI in to the registries mirror.example.com")
+	for _, step := range plan {
+		assert.NotContains(t, step, "secret")
+	}
+}
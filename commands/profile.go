@@ -0,0 +1,264 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errExpectedProfileName = errors.New("Error: Expected a profile name as the first argument")
+	errInvalidProfileName  = errors.New("Invalid profile name: it may only contain the characters [a-zA-Z0-9-_.]")
+)
+
+// Flags which are about a single run of create rather than the machines it
+// creates, and so are not stored in profiles.
+var profileExcludedFlags = map[string]bool{
+	"profile": true,
+	"resume":  true,
+}
+
+func profilePath(name string) string {
+	return filepath.Join(mcndirs.GetProfileDir(), name+".json")
+}
+
+// readProfile reads the create flags stored in a profile, keyed by flag
+// name like in the config file.
+func readProfile(name string) (createDefaults, error) {
+	data, err := ioutil.ReadFile(profilePath(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Profile %q does not exist", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := createDefaults{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("Error reading profile %q: %s", name, err)
+	}
+
+	return p, nil
+}
+
+func saveProfile(name string, p createDefaults) error {
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(mcndirs.GetProfileDir(), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(profilePath(name), data, 0600)
+}
+
+func listProfiles() ([]string, error) {
+	files, err := ioutil.ReadDir(mcndirs.GetProfileDir())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// applyProfile sets the flags of a create command line which are not given
+// on the command line to their value in the profile. Profiles are picked
+// explicitly, so they take precedence over the environment and the config
+// file.
+func applyProfile(c *cli.Context, name string, p createDefaults) error {
+	for _, f := range c.Command.Flags {
+		flagName := flagName(f)
+		raw, ok := p[flagName]
+		if !ok || flagIsSet(c, f) {
+			continue
+		}
+
+		if err := setFlag(c, flagName, raw); err != nil {
+			return fmt.Errorf("Error in value of %q in profile %q: %s", flagName, name, err)
+		}
+	}
+
+	return nil
+}
+
+// parseProfileFlags parses the create flags given to "profile create" and
+// returns the ones which are set. Flags are parsed without their environment
+// variables, so that only what is on the command line ends up in the
+// profile.
+func parseProfileFlags(cliFlags []cli.Flag, args []string) (createDefaults, error) {
+	set := flag.NewFlagSet("profile create", flag.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+
+	primary := map[string]string{}
+	for _, f := range cliFlags {
+		name := flagName(f)
+		if profileExcludedFlags[name] {
+			continue
+		}
+
+		for _, alias := range strings.Split(flagNames(f), ",") {
+			alias = strings.TrimSpace(alias)
+			primary[alias] = name
+
+			switch f.(type) {
+			case cli.BoolFlag:
+				set.Bool(alias, false, "")
+			case cli.IntFlag:
+				set.Int(alias, 0, "")
+			case cli.DurationFlag:
+				set.Duration(alias, 0, "")
+			case cli.StringSliceFlag:
+				set.Var(&cli.StringSlice{}, alias, "")
+			default:
+				set.String(alias, "", "")
+			}
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if len(set.Args()) > 0 {
+		return nil, fmt.Errorf("Invalid command line. Found extra arguments %v", set.Args())
+	}
+
+	p := createDefaults{}
+	set.Visit(func(f *flag.Flag) {
+		if slice, ok := f.Value.(*cli.StringSlice); ok {
+			p[primary[f.Name]] = slice.Value()
+			return
+		}
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if b, ok := getter.Get().(bool); ok {
+				p[primary[f.Name]] = b
+				return
+			}
+		}
+		p[primary[f.Name]] = f.Value.String()
+	})
+
+	return p, nil
+}
+
+func cmdProfileCreate(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errExpectedProfileName
+	}
+
+	name := args[0]
+	if !host.ValidateHostName(name) {
+		return errInvalidProfileName
+	}
+
+	cliFlags := append([]cli.Flag{}, sharedCreateFlags...)
+
+	if driverName := lookupArg(args[1:], "--driver", "-d"); driverName != "" {
+		driverFlags, err := driverCreateFlags(driverName)
+		if err != nil {
+			return fmt.Errorf("Error loading driver %q: %s", driverName, err)
+		}
+		cliFlags = append(cliFlags, driverFlags...)
+	}
+
+	p, err := parseProfileFlags(cliFlags, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err := saveProfile(name, p); err != nil {
+		return fmt.Errorf("Error saving profile %q: %s", name, err)
+	}
+
+	log.Infof("Saved profile %q, create machines from it with: %s create --profile %s NAME", name, os.Args[0], name)
+	return nil
+}
+
+func cmdProfileLs(c *cli.Context) error {
+	names, err := listProfiles()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDRIVER\tFLAGS")
+	for _, name := range names {
+		p, err := readProfile(name)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+
+		driverName, _ := p["driver"].(string)
+		fmt.Fprintf(w, "%s\t%s\t%d\n", name, driverName, len(p))
+	}
+
+	return w.Flush()
+}
+
+func cmdProfileRm(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		return errExpectedProfileName
+	}
+
+	failed := false
+	for _, name := range c.Args() {
+		if err := os.Remove(profilePath(name)); err != nil {
+			if os.IsNotExist(err) {
+				err = fmt.Errorf("Profile %q does not exist", name)
+			}
+			log.Error(err)
+			failed = true
+			continue
+		}
+		fmt.Println(name)
+	}
+
+	if failed {
+		return errors.New("Error removing profiles")
+	}
+
+	return nil
+}
+
+// lookupArg returns the value of a flag in args given as "--flag value" or
+// "--flag=value", under any of its names.
+func lookupArg(args []string, names ...string) string {
+	for i, arg := range args {
+		for _, name := range names {
+			if arg == name && i+1 < len(args) {
+				return args[i+1]
+			}
+			if strings.HasPrefix(arg, name+"=") {
+				return strings.TrimPrefix(arg, name+"=")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProfileFlags = []cli.Flag{
+	cli.StringFlag{Name: "driver, d", Value: "none", EnvVar: "TEST_PROFILE_DRIVER"},
+	cli.IntFlag{Name: "virtualbox-memory", Value: 1024},
+	cli.StringSliceFlag{Name: "engine-opt", Value: &cli.StringSlice{}},
+	cli.BoolFlag{Name: "swarm"},
+	cli.StringFlag{Name: "swarm-strategy", Value: "spread"},
+	cli.StringFlag{Name: "resume"},
+}
+
+func TestParseProfileFlags(t *testing.T) {
+	os.Setenv("TEST_PROFILE_DRIVER", "amazonec2")
+	defer os.Unsetenv("TEST_PROFILE_DRIVER")
+
+	p, err := parseProfileFlags(testProfileFlags, []string{
+		"-d", "virtualbox",
+		"--virtualbox-memory", "4096",
+		"--engine-opt", "a=b",
+		"--engine-opt", "c=d",
+		"--swarm",
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, createDefaults{
+		"driver":            "virtualbox",
+		"virtualbox-memory": "4096",
+		"engine-opt":        []string{"a=b", "c=d"},
+		"swarm":             true,
+	}, p)
+}
+
+func TestParseProfileFlagsErrors(t *testing.T) {
+	_, err := parseProfileFlags(testProfileFlags, []string{"--virtualbox-memory", "lots"})
+	assert.Error(t, err)
+
+	_, err = parseProfileFlags(testProfileFlags, []string{"--resume", "dev"})
+	assert.Error(t, err)
+
+	_, err = parseProfileFlags(testProfileFlags, []string{"--swarm", "extra"})
+	assert.EqualError(t, err, "Invalid command line. Found extra arguments [extra]")
+}
+
+func TestSaveReadListProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-profiles")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mcndirs.BaseDir = dir
+	defer func() { mcndirs.BaseDir = "" }()
+
+	names, err := listProfiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+
+	assert.NoError(t, saveProfile("ci-worker", createDefaults{"driver": "virtualbox", "swarm": true}))
+	assert.NoError(t, saveProfile("build", createDefaults{}))
+
+	names, err = listProfiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"build", "ci-worker"}, names)
+
+	p, err := readProfile("ci-worker")
+	assert.NoError(t, err)
+	assert.Equal(t, createDefaults{"driver": "virtualbox", "swarm": true}, p)
+
+	_, err = readProfile("missing")
+	assert.EqualError(t, err, `Profile "missing" does not exist`)
+}
+
+func TestApplyProfile(t *testing.T) {
+	set := flag.NewFlagSet("create", flag.ContinueOnError)
+	for _, f := range testProfileFlags {
+		f.Apply(set)
+	}
+	assert.NoError(t, set.Parse([]string{"--swarm-strategy", "binpack", "dev"}))
+
+	c := cli.NewContext(nil, set, nil)
+	c.Command = cli.Command{Name: "create", Flags: testProfileFlags}
+
+	p := createDefaults{
+		"driver":            "virtualbox",
+		"virtualbox-memory": "4096",
+		"swarm":             true,
+		"swarm-strategy":    "random",
+		"unknown-flag":      "ignored",
+	}
+	assert.NoError(t, applyProfile(c, "ci-worker", p))
+
+	assert.Equal(t, "virtualbox", c.String("driver"))
+	assert.Equal(t, 4096, c.Int("virtualbox-memory"))
+	assert.True(t, c.Bool("swarm"))
+	assert.Equal(t, "binpack", c.String("swarm-strategy"))
+}
+
+func TestLookupArg(t *testing.T) {
+	assert.Equal(t, "virtualbox", lookupArg([]string{"--swarm", "-d", "virtualbox"}, "--driver", "-d"))
+	assert.Equal(t, "amazonec2", lookupArg([]string{"--driver=amazonec2"}, "--driver", "-d"))
+	assert.Equal(t, "", lookupArg([]string{"--swarm", "--driver"}, "--driver", "-d"))
+}
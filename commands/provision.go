@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/hooks"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/notify"
+)
+
+func cmdProvision(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		cli.ShowCommandHelp(c, "provision")
+		return ErrNoMachineSpecified
+	}
+
+	from, err := host.ParseCreateStage(c.String("from"))
+	if err != nil {
+		return err
+	}
+
+	store := getStore(c)
+
+	ctx, cancel := commandContext(c.Duration("timeout"))
+	defer cancel()
+
+	for _, hostName := range c.Args() {
+		h, err := loadHost(store, hostName)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Running stages of %s from %s...", hostName, from)
+		transcriptCtx, closeTranscript := withTranscript(ctx, hostName)
+		err = libmachine.ReprovisionContext(transcriptCtx, store, h, from)
+		closeTranscript()
+		if err != nil {
+			notifyMachine(notify.Error, hostName, h.DriverName, nil, fmt.Errorf("Error provisioning: %w", err))
+			return fmt.Errorf("Error provisioning %q: %s", hostName, err)
+		}
+
+		notifyMachine(notify.Provisioned, hostName, h.DriverName, h.Driver, nil)
+
+		if err := runHook(ctx, hooks.PostProvision, hostName, h.DriverName, h.Driver); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
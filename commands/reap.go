@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/notify"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+)
+
+func cmdReap(c *cli.Context) error {
+	r := &reaper{
+		store:  getStore(c),
+		remove: c.Bool("remove"),
+		exempt: c.StringSlice("exempt-label"),
+		dryRun: c.Bool("dry-run"),
+	}
+
+	failed, err := r.reap(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Error: %d expired machine(s) couldn't be reaped, see above", failed)
+	}
+
+	return nil
+}
+
+// reaper stops or removes the machines of the store which expired, see
+// host.ExpiresAt, but those with one of the exempt engine labels.
+type reaper struct {
+	store  persist.Store
+	remove bool
+	exempt []string
+	dryRun bool
+}
+
+// expiredHosts returns the hosts which expired at now, and aren't exempt.
+func (r *reaper) expiredHosts(hosts []*host.Host, now time.Time) []*host.Host {
+	expired := []*host.Host{}
+	for _, h := range hosts {
+		if !h.Expired(now) {
+			continue
+		}
+		if len(r.exempt) > 0 && matchesLabel(h, r.exempt) {
+			log.Debugf("%s expired, but is exempt", h.Name)
+			continue
+		}
+		expired = append(expired, h)
+	}
+	return expired
+}
+
+// reap stops or removes the machines which expired at now, one at a time,
+// and returns how many of them failed to be.
+func (r *reaper) reap(now time.Time) (int, error) {
+	hosts, err := listHosts(r.store)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, h := range r.expiredHosts(hosts, now) {
+		expiredFor := now.Sub(h.ExpiresAt).Round(time.Second)
+
+		if r.dryRun {
+			log.Infof("%s expired %s ago, and would be reaped", h.Name, expiredFor)
+			continue
+		}
+
+		reaped, err := r.reapHost(h, expiredFor)
+		if err != nil {
+			log.Errorf("%s: Error reaping the expired machine: %s", h.Name, err)
+			failed++
+			continue
+		}
+		if reaped {
+			notifyMachine(notify.Expired, h.Name, h.DriverName, nil, nil)
+		}
+	}
+
+	return failed, nil
+}
+
+// reapHost stops or removes the expired machine, and tells whether it had
+// to, stopped machines being left as they are when only stopping them.
+// Failures are notified, as removing a machine with rm notifies them.
+func (r *reaper) reapHost(h *host.Host, expiredFor time.Duration) (bool, error) {
+	if r.remove {
+		log.Infof("Removing %s, expired %s ago...", h.Name, expiredFor)
+		return true, removeMachine(r.store, h, false)
+	}
+
+	s, err := h.Driver.GetState()
+	if err != nil {
+		return false, err
+	}
+	if s == state.Stopped {
+		return false, nil
+	}
+
+	log.Infof("Stopping %s, expired %s ago...", h.Name, expiredFor)
+
+	if err := h.Stop(); err != nil {
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, fmt.Errorf("Error stopping the expired machine: %s", err))
+		return false, err
+	}
+
+	return true, saveHost(r.store, h)
+}
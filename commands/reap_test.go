@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func reapTestHost(name string, expiresAt time.Time, labels ...string) *host.Host {
+	return &host.Host{
+		Name:        name,
+		DriverName:  "fakedriver",
+		Driver:      &fakedriver.Driver{MockName: name, MockState: state.Running},
+		HostOptions: &host.HostOptions{EngineOptions: &engine.EngineOptions{Labels: labels}},
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func TestReaperExpiredHosts(t *testing.T) {
+	now := time.Now()
+
+	expired := reapTestHost("ci-1", now.Add(-time.Hour))
+	kept := reapTestHost("ci-2", now.Add(-time.Hour), "keep=true")
+	fresh := reapTestHost("ci-3", now.Add(time.Hour))
+	forever := reapTestHost("dev", time.Time{})
+	hosts := []*host.Host{expired, kept, fresh, forever}
+
+	r := &reaper{}
+	assert.Equal(t, []*host.Host{expired, kept}, r.expiredHosts(hosts, now))
+
+	r.exempt = []string{"keep"}
+	assert.Equal(t, []*host.Host{expired}, r.expiredHosts(hosts, now))
+}
+
+func TestReaperStopsExpiredHost(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	h := reapTestHost("ci-1", time.Now().Add(-time.Hour))
+	r := &reaper{store: store}
+
+	reaped, err := r.reapHost(h, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, reaped)
+
+	s, _ := h.Driver.GetState()
+	assert.Equal(t, state.Stopped, s)
+	assert.Equal(t, state.Stopped, h.DesiredState)
+
+	exists, err := store.Exists("ci-1")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	reaped, err = r.reapHost(h, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, reaped)
+}
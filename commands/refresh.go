@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// refreshed is the outcome of refreshing the inventory of a machine: the
+// machine, with its inventory, and why it couldn't be collected.
+type refreshed struct {
+	Host *host.Host
+	Err  error
+}
+
+func cmdRefresh(c *cli.Context) error {
+	var (
+		hosts []*host.Host
+		err   error
+	)
+
+	store := getStore(c)
+
+	if c.Bool("all") {
+		hosts, err = listHosts(store)
+	} else {
+		hosts, err = getHostsFromContext(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		if c.Bool("all") {
+			return nil
+		}
+		return ErrNoMachineSpecified
+	}
+
+	results := make([]refreshed, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+			results[i] = refreshed{Host: h, Err: refreshHost(h, c.Bool("all"))}
+			if results[i].Err == errNotRefreshed {
+				return
+			}
+			if err := saveHost(store, h); err != nil && results[i].Err == nil {
+				results[i].Err = err
+			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	printRefreshed(os.Stdout, results)
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil && r.Err != errNotRefreshed {
+			log.Errorf("%s: %s", r.Host.Name, r.Err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d machines couldn't be refreshed", failed, len(results))
+	}
+
+	return nil
+}
+
+// errNotRefreshed is the outcome of the machines which aren't running, when
+// refreshing them all.
+var errNotRefreshed = errors.New("not running")
+
+// refreshHost collects the inventory of the machine of h, which must be
+// running, unless skipping it is fine.
+func refreshHost(h *host.Host, skipNotRunning bool) error {
+	s, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+	if s != state.Running {
+		if skipNotRunning {
+			return errNotRefreshed
+		}
+		return fmt.Errorf("Error: Cannot refresh the machine: Host %q is not running", h.Name)
+	}
+
+	return h.CollectInventory()
+}
+
+func printRefreshed(out io.Writer, results []refreshed) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\t"+inventoryHeader)
+
+	for _, r := range results {
+		if r.Err == errNotRefreshed || r.Host.Inventory == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", r.Host.Name, inventoryColumns(r.Host.Inventory))
+	}
+
+	w.Flush()
+}
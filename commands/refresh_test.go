@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/inventory"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshHostNotRunning(t *testing.T) {
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Stopped}}
+
+	assert.EqualError(t, refreshHost(h, false), `Error: Cannot refresh the machine: Host "dev" is not running`)
+	assert.Equal(t, errNotRefreshed, refreshHost(h, true))
+	assert.Nil(t, h.Inventory)
+}
+
+func TestPrintRefreshed(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	printRefreshed(out, []refreshed{
+		{Host: &host.Host{Name: "dev", Inventory: &inventory.Inventory{
+			EngineVersion: "20.10.24",
+			APIVersion:    "1.41",
+			OS:            "Ubuntu 22.04.3 LTS",
+			Kernel:        "5.15.0-91-generic",
+			Architecture:  "x86_64",
+			StorageDriver: "overlay2",
+			FreeDisk:      50 << 30,
+		}}},
+		{Host: &host.Host{Name: "stopped"}, Err: errNotRefreshed},
+	})
+
+	assert.Equal(t, `NAME   ENGINE     API    OS                   KERNEL              ARCH     STORAGE    FREE DISK
+dev    20.10.24   1.41   Ubuntu 22.04.3 LTS   5.15.0-91-generic   x86_64   overlay2   50.0GB
+`, out.String())
+}
@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	// registryCacheContainer is the container of the registry cache, and
+	// the volume of the images it cached.
+	registryCacheContainer = "docker-machine-registry-cache"
+
+	registryCacheImage = "registry:2"
+)
+
+var errRegistryCacheOfItself = errors.New("Error: The machine running the registry cache cannot be given to --machine, its engine pulls the images itself")
+
+// registryCacheCommand runs the registry cache on its machine, replacing
+// the container of a previous run, the images already cached being kept
+// in the volume.
+func registryCacheCommand(port int, remoteURL string) string {
+	return fmt.Sprintf("sh -c 'docker rm -f %[1]s >/dev/null 2>&1; docker run -d --restart=always --name %[1]s -p %[2]d:5000 -e REGISTRY_PROXY_REMOTEURL=%[3]s -v %[1]s:/var/lib/registry %[4]s'",
+		registryCacheContainer, port, remoteURL, registryCacheImage)
+}
+
+// setRegistryCache has the engine options use the registry cache of the
+// machine at address as a mirror, replacing the one they used before, if
+// any. The cache being served over plain HTTP, it is an insecure registry
+// too.
+func setRegistryCache(options *engine.EngineOptions, cache, address string) {
+	unsetRegistryCache(options)
+
+	options.RegistryMirror = append(options.RegistryMirror, "http://"+address)
+	options.InsecureRegistry = append(options.InsecureRegistry, address)
+	options.RegistryCache = cache
+	options.RegistryCacheAddress = address
+}
+
+// unsetRegistryCache removes the registry cache from the engine options.
+func unsetRegistryCache(options *engine.EngineOptions) {
+	if options.RegistryCacheAddress != "" {
+		options.RegistryMirror = without(options.RegistryMirror, "http://"+options.RegistryCacheAddress)
+		options.InsecureRegistry = without(options.InsecureRegistry, options.RegistryCacheAddress)
+	}
+
+	options.RegistryCache = ""
+	options.RegistryCacheAddress = ""
+}
+
+func without(values []string, value string) []string {
+	kept := []string{}
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// registryCacheTargets returns the machines of names, or else every machine
+// of the store but the cache.
+func registryCacheTargets(store persist.Store, cache string, names []string) ([]*host.Host, error) {
+	if len(names) == 0 {
+		hosts, err := listHosts(store)
+		if err != nil {
+			return nil, err
+		}
+
+		targets := []*host.Host{}
+		for _, h := range hosts {
+			if h.Name != cache {
+				targets = append(targets, h)
+			}
+		}
+		return targets, nil
+	}
+
+	targets := []*host.Host{}
+	for _, name := range names {
+		if name == cache {
+			return nil, errRegistryCacheOfItself
+		}
+
+		h, err := loadHost(store, name)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, h)
+	}
+	return targets, nil
+}
+
+// reprovisionEngineOptions changes and saves the engine options of the
+// machine, and provisions it again for its engine to use them, unless it
+// isn't running.
+func reprovisionEngineOptions(store persist.Store, h *host.Host, change func(*engine.EngineOptions)) error {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return fmt.Errorf("Machine %q has no engine options", h.Name)
+	}
+
+	change(h.HostOptions.EngineOptions)
+	if err := saveHost(store, h); err != nil {
+		return err
+	}
+
+	if s, err := h.Driver.GetState(); err != nil || s != state.Running {
+		log.Warnf("%s isn't running, once started run 'docker-machine provision %s' for its engine to use its new options", h.Name, h.Name)
+		return nil
+	}
+
+	log.Infof("Provisioning %s again to configure its engine...", h.Name)
+	if err := libmachine.Reprovision(store, h, host.StageProvisioned); err != nil {
+		return fmt.Errorf("Error provisioning %s: %s", h.Name, err)
+	}
+
+	return nil
+}
+
+func cmdRegistryCacheEnable(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	port := c.Int("port")
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("Error: --port must be between 1 and 65535")
+	}
+
+	store := getStore(c)
+
+	cache, err := loadHost(store, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	targets, err := registryCacheTargets(store, cache.Name, c.StringSlice("machine"))
+	if err != nil {
+		return err
+	}
+
+	if err := cache.RefuseAdopted("run the registry cache on"); err != nil {
+		return err
+	}
+
+	if s, err := cache.Driver.GetState(); err != nil || s != state.Running {
+		return fmt.Errorf("Error: Cannot run the registry cache: Host %q is not running", cache.Name)
+	}
+
+	ip, err := cache.Driver.GetIP()
+	if err != nil {
+		return fmt.Errorf("Error getting the IP address of %s: %s", cache.Name, err)
+	}
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	log.Infof("Running the registry cache on %s...", cache.Name)
+	if _, err := drivers.RunSSHCommandFromDriver(cache.Driver, cache.Driver.SSHSudo(registryCacheCommand(port, c.String("remote-url")))); err != nil {
+		return fmt.Errorf("Error running the registry cache on %s: %s", cache.Name, err)
+	}
+
+	errs := []error{}
+	for _, h := range targets {
+		if h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.Hardening != "" {
+			log.Warnf("Skipping %s, whose hardening profile forbids the insecure registry of the cache", h.Name)
+			continue
+		}
+		if h.Adopted() {
+			log.Warnf("Skipping %s, which was adopted as it is", h.Name)
+			continue
+		}
+
+		if err := reprovisionEngineOptions(store, h, func(options *engine.EngineOptions) {
+			setRegistryCache(options, cache.Name, address)
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	log.Infof("The engines pull through the registry cache at http://%s", address)
+	return nil
+}
+
+func cmdRegistryCacheDisable(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	store := getStore(c)
+
+	cache, err := loadHost(store, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	hosts, err := listHosts(store)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, h := range hosts {
+		if h.HostOptions == nil || h.HostOptions.EngineOptions == nil || h.HostOptions.EngineOptions.RegistryCache != cache.Name {
+			continue
+		}
+
+		if err := reprovisionEngineOptions(store, h, unsetRegistryCache); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s, err := cache.Driver.GetState(); err == nil && s == state.Running {
+		log.Infof("Removing the registry cache from %s...", cache.Name)
+		if _, err := drivers.RunSSHCommandFromDriver(cache.Driver, cache.Driver.SSHSudo("docker rm -f "+registryCacheContainer)); err != nil {
+			errs = append(errs, fmt.Errorf("Error removing the registry cache from %s: %s", cache.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
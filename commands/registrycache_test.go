@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryCacheCommand(t *testing.T) {
+	assert.Equal(t,
+		"sh -c 'docker rm -f docker-machine-registry-cache >/dev/null 2>&1; docker run -d --restart=always --name docker-machine-registry-cache -p 5000:5000 -e REGISTRY_PROXY_REMOTEURL=https://registry-1.docker.io -v docker-machine-registry-cache:/var/lib/registry registry:2'",
+		registryCacheCommand(5000, "https://registry-1.docker.io"))
+}
+
+func TestSetRegistryCache(t *testing.T) {
+	options := &engine.EngineOptions{
+		RegistryMirror:   []string{"https://mirror.example.com"},
+		InsecureRegistry: []string{"10.1.1.1:5000"},
+	}
+
+	setRegistryCache(options, "cache", "10.0.0.5:5000")
+	assert.Equal(t, []string{"https://mirror.example.com", "http://10.0.0.5:5000"}, options.RegistryMirror)
+	assert.Equal(t, []string{"10.1.1.1:5000", "10.0.0.5:5000"}, options.InsecureRegistry)
+	assert.Equal(t, "cache", options.RegistryCache)
+	assert.Equal(t, "10.0.0.5:5000", options.RegistryCacheAddress)
+
+	setRegistryCache(options, "cache", "10.0.0.6:5000")
+	assert.Equal(t, []string{"https://mirror.example.com", "http://10.0.0.6:5000"}, options.RegistryMirror)
+	assert.Equal(t, []string{"10.1.1.1:5000", "10.0.0.6:5000"}, options.InsecureRegistry)
+
+	unsetRegistryCache(options)
+	assert.Equal(t, &engine.EngineOptions{
+		RegistryMirror:   []string{"https://mirror.example.com"},
+		InsecureRegistry: []string{"10.1.1.1:5000"},
+	}, options)
+}
+
+func TestRegistryCacheTargetsOfItself(t *testing.T) {
+	_, err := registryCacheTargets(nil, "cache", []string{"cache"})
+	assert.Equal(t, errRegistryCacheOfItself, err)
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+func cmdResize(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	opts := drivers.ResizeOptions{
+		CPUs:         c.Int("cpus"),
+		Memory:       c.Int("memory"),
+		DiskSize:     c.Int("disk-size"),
+		InstanceType: c.String("instance-type"),
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Resize(opts); err != nil {
+		return err
+	}
+
+	if err := saveHost(getStore(c), h); err != nil {
+		return err
+	}
+
+	log.Infof("%q has been resized. It may have a new IP address, you may need to re-run the `docker-machine env` command.", h.Name)
+	return nil
+}
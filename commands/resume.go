@@ -0,0 +1,7 @@
+package commands
+
+import "github.com/docker/machine/cli"
+
+func cmdResume(c *cli.Context) error {
+	return runActionWithContext("resume", c)
+}
@@ -5,7 +5,12 @@ import (
 	"fmt"
 
 	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/hooks"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/notify"
+	"github.com/docker/machine/libmachine/persist"
+	"golang.org/x/net/context"
 )
 
 func cmdRm(c *cli.Context) error {
@@ -15,27 +20,59 @@ func cmdRm(c *cli.Context) error {
 	}
 
 	force := c.Bool("force")
+	parallel := c.Int("parallel")
+	if parallel < 1 {
+		return errInvalidParallel
+	}
 	store := getStore(c)
 
+	hosts := []*host.Host{}
 	for _, hostName := range c.Args() {
 		h, err := loadHost(store, hostName)
 		if err != nil {
 			return fmt.Errorf("Error removing host %q: %s", hostName, err)
 		}
+		hosts = append(hosts, h)
+	}
 
-		if err := h.Driver.Remove(); err != nil {
-			if !force {
-				log.Errorf("Provider error removing machine %q: %s", hostName, err)
-				continue
-			}
+	forEachMachine(context.Background(), store, parallel, "removing", hosts, func(h *host.Host) error {
+		if err := removeMachine(store, h, force); err != nil {
+			log.Errorf("Error removing machine %q: %s", h.Name, err)
+			return err
 		}
+		log.Infof("Successfully removed %s", h.Name)
+		return nil
+	})
+
+	return nil
+}
 
-		if err := store.Remove(hostName); err != nil {
-			log.Errorf("Error removing machine %q from store: %s", hostName, err)
-		} else {
-			log.Infof("Successfully removed %s", hostName)
+// removeMachine removes the machine with its driver, then from the store.
+// With force, it is removed from the store even when its pre-rm hooks or
+// its driver failed.
+func removeMachine(store persist.Store, h *host.Host, force bool) error {
+	if err := runHook(context.Background(), hooks.PreRm, h.Name, h.DriverName, h.Driver); err != nil {
+		if !force {
+			return err
 		}
+		log.Warnf("Removing machine %q anyway: %s", h.Name, err)
 	}
 
+	if err := h.Driver.Remove(); err != nil && !force {
+		err = fmt.Errorf("Provider error removing machine: %s", err)
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+		return err
+	}
+
+	if err := store.Remove(h.Name); err != nil {
+		err = fmt.Errorf("Error removing machine from store: %s", err)
+		notifyMachine(notify.Error, h.Name, h.DriverName, nil, err)
+		return err
+	}
+
+	notifyMachine(notify.Removed, h.Name, h.DriverName, nil, nil)
+	updateHostsFile(h.Name, nil)
+	removeNFSExports(h)
+
 	return nil
 }
@@ -0,0 +1,319 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+var (
+	errRsyncNeedsOneMachine = errors.New("Error: Exactly one of the arguments must be a machine path")
+	errWatchNeedsLocalSrc   = errors.New("Error: --watch can only be used when the source is a local path")
+)
+
+// syncOptions are the knobs shared by the rsync and the tar fallback
+// implementations of "docker-machine rsync".
+type syncOptions struct {
+	Delete   bool
+	Excludes []string
+}
+
+func cmdRsync(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		cli.ShowCommandHelp(c, "rsync")
+		return errWrongNumberArguments
+	}
+
+	src := args[0]
+	dest := args[1]
+
+	store := getStore(c)
+	hostInfoLoader := &storeHostInfoLoader{store}
+
+	srcHost, srcPath, _, err := getInfoForScpArg(src, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	destHost, destPath, _, err := getInfoForScpArg(dest, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	if (srcHost == nil) == (destHost == nil) {
+		return errRsyncNeedsOneMachine
+	}
+
+	remote := srcHost
+	if remote == nil {
+		remote = destHost
+	}
+
+	if c.Bool("watch") && srcHost != nil {
+		return errWatchNeedsLocalSrc
+	}
+
+	opts := syncOptions{
+		Delete:   c.Bool("delete"),
+		Excludes: c.StringSlice("exclude"),
+	}
+
+	useRsync, err := prepareRsync(remote, c.Bool("install-rsync"))
+	if err != nil {
+		return err
+	}
+
+	sync := func() error {
+		if useRsync {
+			cmd, err := getRsyncCmd(src, dest, opts, hostInfoLoader)
+			if err != nil {
+				return err
+			}
+			return runCmdWithStdIo(*cmd)
+		}
+
+		return tarSync(transferEndpoint{srcHost, srcPath}, transferEndpoint{destHost, destPath}, opts)
+	}
+
+	if err := sync(); err != nil {
+		return err
+	}
+
+	if !c.Bool("watch") {
+		return nil
+	}
+
+	interval := c.Duration("watch-interval")
+	log.Infof("Watching %s for changes, press Ctrl-C to stop...", srcPath)
+
+	return watchAndSync(srcPath, opts.Excludes, interval, sync)
+}
+
+// prepareRsync reports whether rsync can be used to talk to the machine,
+// optionally installing it with the machine's provisioner first. A false
+// return with no error means the tar fallback should be used.
+func prepareRsync(hostInfo HostInfo, install bool) (bool, error) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		log.Debug("rsync binary not found locally, falling back to tar over SSH")
+		return false, nil
+	}
+
+	d, ok := hostInfo.(drivers.Driver)
+	if !ok {
+		return true, nil
+	}
+
+	if _, err := drivers.RunSSHCommandFromDriver(d, "command -v rsync"); err == nil {
+		return true, nil
+	}
+
+	if !install {
+		log.Infof("rsync is not installed on %q, falling back to tar over SSH", d.GetMachineName())
+		return false, nil
+	}
+
+	log.Infof("Installing rsync on %q...", d.GetMachineName())
+
+	provisioner, err := provision.DetectProvisioner(d)
+	if err != nil {
+		return false, fmt.Errorf("Error detecting OS: %s", err)
+	}
+
+	if err := provisioner.Package("rsync", pkgaction.Install); err != nil {
+		return false, fmt.Errorf("Error installing rsync: %s", err)
+	}
+
+	if _, err := drivers.RunSSHCommandFromDriver(d, "command -v rsync"); err != nil {
+		log.Warnf("rsync could not be installed on %q, falling back to tar over SSH", d.GetMachineName())
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func getRsyncCmd(src, dest string, opts syncOptions, hostInfoLoader HostInfoLoader) (*exec.Cmd, error) {
+	cmdPath, err := exec.LookPath("rsync")
+	if err != nil {
+		return nil, errors.New("Error: You must have a copy of the rsync binary locally to use the rsync feature.")
+	}
+
+	srcHost, srcPath, srcOpts, err := getInfoForScpArg(src, hostInfoLoader)
+	if err != nil {
+		return nil, err
+	}
+
+	destHost, destPath, destOpts, err := getInfoForScpArg(dest, hostInfoLoader)
+	if err != nil {
+		return nil, err
+	}
+
+	// rsync does not copy between two remotes, so the -e ssh command line
+	// only ever needs the private key of the one machine involved.
+	sshCmd := append([]string{"ssh"}, baseSSHArgs...)
+	sshCmd = append(sshCmd, srcOpts...)
+	sshCmd = append(sshCmd, destOpts...)
+
+	rsyncArgs := []string{"-az", "-e", shellJoin(sshCmd)}
+	if opts.Delete {
+		rsyncArgs = append(rsyncArgs, "--delete")
+	}
+	for _, exclude := range opts.Excludes {
+		rsyncArgs = append(rsyncArgs, "--exclude", exclude)
+	}
+
+	locationArg, err := generateLocationArg(srcHost, srcPath)
+	if err != nil {
+		return nil, err
+	}
+	rsyncArgs = append(rsyncArgs, locationArg)
+
+	locationArg, err = generateLocationArg(destHost, destPath)
+	if err != nil {
+		return nil, err
+	}
+	rsyncArgs = append(rsyncArgs, locationArg)
+
+	cmd := exec.Command(cmdPath, rsyncArgs...)
+	log.Debug(*cmd)
+	return cmd, nil
+}
+
+// shellJoin joins the arguments into a command line for rsync -e, quoting
+// the ones which would otherwise be split or interpreted.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if arg == "" || strings.IndexFunc(arg, needsQuoting) >= 0 {
+			quoted[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func needsQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("@%+=:,./_-", r)
+}
+
+// tarSync is the fallback used when rsync is not available. It always copies
+// every file, and with --delete it then deletes what is not in the source
+// from the directory synced, leaving the rest of the destination alone.
+func tarSync(from, to transferEndpoint, opts syncOptions) error {
+	// Mirror rsync: "dir/" copies the contents of dir, "dir" copies dir itself.
+	contents := strings.HasSuffix(from.Path, "/") || strings.HasSuffix(from.Path, string(filepath.Separator))
+
+	source := from.tarSource(contents, opts.Excludes, false)
+	sink := to.tarSink(opts.Excludes, false)
+
+	if err := runTransfer(source, sink, nil); err != nil {
+		return err
+	}
+
+	if !opts.Delete {
+		return nil
+	}
+
+	synced := to
+	if !contents {
+		synced.Path = to.join(from.base())
+	}
+
+	srcPaths, err := from.listTree()
+	if err != nil {
+		return err
+	}
+
+	destPaths, err := synced.listTree()
+	if err != nil {
+		return err
+	}
+
+	return synced.remove(prunePaths(srcPaths, destPaths, opts.Excludes))
+}
+
+// treeSnapshot records the size and modification time of every file below
+// root which is not excluded, so that changes can be detected by polling.
+func treeSnapshot(root string, excludes []string) (map[string]string, error) {
+	snapshot := map[string]string{}
+
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && isExcluded(rel, excludes) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		snapshot[rel] = fmt.Sprintf("%d:%d", fi.Size(), fi.ModTime().UnixNano())
+		return nil
+	})
+
+	return snapshot, err
+}
+
+func snapshotsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// watchAndSync polls root for changes and calls sync whenever something
+// changed. It only returns if taking a snapshot fails.
+func watchAndSync(root string, excludes []string, interval time.Duration, sync func() error) error {
+	last, err := treeSnapshot(root, excludes)
+	if err != nil {
+		return err
+	}
+
+	for {
+		time.Sleep(interval)
+
+		current, err := treeSnapshot(root, excludes)
+		if err != nil {
+			return err
+		}
+
+		if snapshotsEqual(last, current) {
+			continue
+		}
+
+		log.Infof("Change detected in %s, syncing...", root)
+		if err := sync(); err != nil {
+			log.Errorf("Error syncing: %s", err)
+			continue
+		}
+
+		last = current
+	}
+}
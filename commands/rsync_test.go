@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRsyncCmd(t *testing.T) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("rsync binary not available")
+	}
+
+	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
+		ip:          "12.34.56.78",
+		sshUsername: "root",
+		sshKeyPath:  "/fake/keypath/id_rsa",
+	}}
+
+	opts := syncOptions{
+		Delete:   true,
+		Excludes: []string{".git", "*.o"},
+	}
+
+	cmd, err := getRsyncCmd("/tmp/foo/", "myfunhost:/home/docker/foo", opts, &hostInfoLoader)
+	assert.NoError(t, err)
+
+	sshCmd := append([]string{"ssh"}, baseSSHArgs...)
+	sshCmd = append(sshCmd, "-i", "/fake/keypath/id_rsa")
+
+	expectedArgs := []string{
+		"-az",
+		"-e", shellJoin(sshCmd),
+		"--delete",
+		"--exclude", ".git",
+		"--exclude", "*.o",
+		"/tmp/foo/",
+		"root@12.34.56.78:/home/docker/foo",
+	}
+
+	assert.Equal(t, expectedArgs, cmd.Args[1:])
+}
+
+func TestShellJoin(t *testing.T) {
+	assert.Equal(t, "ssh -o StrictHostKeyChecking=no -i '/home/me/My Keys/id_rsa' ''", shellJoin([]string{"ssh", "-o", "StrictHostKeyChecking=no", "-i", "/home/me/My Keys/id_rsa", ""}))
+	assert.Equal(t, `ssh -i '/tmp/it'\''s;rm'`, shellJoin([]string{"ssh", "-i", "/tmp/it's;rm"}))
+}
+
+func TestTreeSnapshotDetectsChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-rsync-watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	before, err := treeSnapshot(dir, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ignored.o"), []byte("o"), 0644))
+
+	after, err := treeSnapshot(dir, []string{"*.o"})
+	assert.NoError(t, err)
+
+	assert.False(t, snapshotsEqual(before, after))
+	assert.Len(t, after, 2)
+}
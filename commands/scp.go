@@ -14,6 +14,7 @@ import (
 
 var (
 	errWrongNumberArguments = errors.New("Improper number of arguments")
+	errResumeRecursive      = errors.New("Error: --resume cannot be combined with --recursive")
 
 	// TODO: possibly move this to ssh package
 	baseSSHArgs = []string{
@@ -53,6 +54,14 @@ func (s *storeHostInfoLoader) load(name string) (HostInfo, error) {
 	return host.Driver, nil
 }
 
+// scpOptions holds the flags which change how files are copied.
+type scpOptions struct {
+	Recursive bool
+	Compress  bool
+	Resume    bool
+	Quiet     bool
+}
+
 func cmdScp(c *cli.Context) error {
 	args := c.Args()
 	if len(args) != 2 {
@@ -66,19 +75,42 @@ func cmdScp(c *cli.Context) error {
 	store := getStore(c)
 	hostInfoLoader := &storeHostInfoLoader{store}
 
-	cmd, err := getScpCmd(src, dest, c.Bool("recursive"), hostInfoLoader)
-	if err != nil {
-		return err
+	opts := scpOptions{
+		Recursive: c.Bool("recursive"),
+		Compress:  c.Bool("compress"),
+		Resume:    c.Bool("resume"),
+		Quiet:     c.Bool("quiet"),
 	}
 
-	if err := runCmdWithStdIo(*cmd); err != nil {
+	if useNativeScp(c.GlobalBool("native-ssh"), opts) {
+		return nativeScp(src, dest, opts, hostInfoLoader)
+	}
+
+	cmd, err := getScpCmd(src, dest, opts, hostInfoLoader)
+	if err != nil {
 		return err
 	}
 
 	return runCmdWithStdIo(*cmd)
 }
 
-func getScpCmd(src, dest string, recursive bool, hostInfoLoader HostInfoLoader) (*exec.Cmd, error) {
+// useNativeScp reports whether the copy should be done by Machine itself
+// instead of by the scp binary, which can neither resume transfers nor be
+// relied upon to exist (e.g. on Windows).
+func useNativeScp(nativeSSH bool, opts scpOptions) bool {
+	if nativeSSH || opts.Resume {
+		return true
+	}
+
+	if _, err := exec.LookPath("scp"); err != nil {
+		log.Debug("scp binary not found, using native copy")
+		return true
+	}
+
+	return false
+}
+
+func getScpCmd(src, dest string, opts scpOptions, hostInfoLoader HostInfoLoader) (*exec.Cmd, error) {
 	cmdPath, err := exec.LookPath("scp")
 	if err != nil {
 		return nil, errors.New("Error: You must have a copy of the scp binary locally to use the scp feature.")
@@ -98,9 +130,15 @@ func getScpCmd(src, dest string, recursive bool, hostInfoLoader HostInfoLoader)
 	// It is on every system I've checked, but the manual mentioned it's "newer"
 	sshArgs := baseSSHArgs
 	sshArgs = append(sshArgs, "-3")
-	if recursive {
+	if opts.Recursive {
 		sshArgs = append(sshArgs, "-r")
 	}
+	if opts.Compress {
+		sshArgs = append(sshArgs, "-C")
+	}
+	if opts.Quiet {
+		sshArgs = append(sshArgs, "-q")
+	}
 
 	// Append needed -i / private key flags to command.
 	sshArgs = append(sshArgs, srcOpts...)
@@ -124,6 +162,100 @@ func getScpCmd(src, dest string, recursive bool, hostInfoLoader HostInfoLoader)
 	return cmd, nil
 }
 
+// nativeScp copies files by streaming them over SSH connections made by
+// Machine. Copies between two machines go through the local host.
+func nativeScp(src, dest string, opts scpOptions, hostInfoLoader HostInfoLoader) error {
+	srcHost, srcPath, _, err := getInfoForScpArg(src, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	destHost, destPath, _, err := getInfoForScpArg(dest, hostInfoLoader)
+	if err != nil {
+		return err
+	}
+
+	from := transferEndpoint{srcHost, srcPath}
+	to := transferEndpoint{destHost, destPath}
+
+	srcIsDir, err := from.isDir()
+	if err != nil {
+		return err
+	}
+
+	if !srcIsDir {
+		return copyFile(from, to, opts)
+	}
+
+	if !opts.Recursive {
+		return fmt.Errorf("%s is a directory, use --recursive to copy it", from)
+	}
+
+	if opts.Resume {
+		return errResumeRecursive
+	}
+
+	// Like scp -r: copy into the destination if it is an existing
+	// directory, otherwise create the destination as the copy.
+	destIsDir, err := to.isDir()
+	if err != nil {
+		return err
+	}
+
+	source := from.tarSource(!destIsDir, nil, opts.Compress)
+	sink := to.tarSink(nil, opts.Compress)
+
+	return runTransfer(source, sink, newProgressReader(from.String(), -1, 0, opts.Quiet))
+}
+
+func copyFile(from, to transferEndpoint, opts scpOptions) error {
+	size, err := from.size()
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		return fmt.Errorf("%s: No such file or directory", from)
+	}
+
+	destIsDir, err := to.isDir()
+	if err != nil {
+		return err
+	}
+	if destIsDir {
+		to.Path = to.join(from.base())
+	}
+
+	var offset int64
+	if opts.Resume {
+		existing, err := to.size()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case existing == size:
+			log.Infof("%s is already complete", to)
+			return nil
+		case existing > 0 && existing < size:
+			log.Infof("Resuming copy to %s at byte %d of %d", to, existing, size)
+			offset = existing
+		}
+	}
+
+	source := from.fileSource(offset, opts.Compress)
+	sink := to.fileSink(offset > 0, opts.Compress)
+
+	// With compression the bytes going through the client do not match
+	// the size of the file, so only the amount transferred is shown.
+	total := size
+	if opts.Compress {
+		total = -1
+		offset = 0
+	}
+
+	return runTransfer(source, sink, newProgressReader(from.String(), total, offset, opts.Quiet))
+}
+
 func getInfoForScpArg(hostAndPath string, hostInfoLoader HostInfoLoader) (HostInfo, string, []string, error) {
 	// Local path.  e.g. "/tmp/foo"
 	if !strings.Contains(hostAndPath, ":") {
@@ -161,6 +293,11 @@ func generateLocationArg(hostInfo HostInfo, path string) (string, error) {
 		return "", err
 	}
 
+	// scp and rsync tell IPv6 addresses from the path by their brackets.
+	if ip = strings.Trim(ip, "[]"); strings.Contains(ip, ":") {
+		ip = "[" + ip + "]"
+	}
+
 	location := fmt.Sprintf("%s@%s:%s", hostInfo.GetSSHUsername(), ip, path)
 	return location, nil
 }
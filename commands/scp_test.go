@@ -113,6 +113,18 @@ func TestRemoteLocation(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestRemoteLocationIPv6(t *testing.T) {
+	hostInfo := MockHostInfo{
+		ip:          "2001:db8::5",
+		sshUsername: "root",
+	}
+
+	arg, err := generateLocationArg(&hostInfo, "/home/docker/foo")
+
+	assert.Equal(t, "root@[2001:db8::5]:/home/docker/foo", arg)
+	assert.NoError(t, err)
+}
+
 func TestGetScpCmd(t *testing.T) {
 	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
 		ip:          "12.34.56.78",
@@ -120,7 +132,7 @@ func TestGetScpCmd(t *testing.T) {
 		sshKeyPath:  "/fake/keypath/id_rsa",
 	}}
 
-	cmd, err := getScpCmd("/tmp/foo", "myfunhost:/home/docker/foo", true, &hostInfoLoader)
+	cmd, err := getScpCmd("/tmp/foo", "myfunhost:/home/docker/foo", scpOptions{Recursive: true}, &hostInfoLoader)
 
 	expectedArgs := append(
 		baseSSHArgs,
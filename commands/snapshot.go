@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var errExpectedSnapshotName = errors.New("Error: Expected a machine name and a snapshot name as arguments")
+
+func cmdSnapshotCreate(c *cli.Context) error {
+	if len(c.Args()) < 1 || len(c.Args()) > 2 {
+		return ErrExpectedOneMachine
+	}
+
+	name := c.Args().Get(1)
+	if name == "" {
+		name = "snapshot-" + time.Now().Format("20060102-150405")
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Taking snapshot %q of %q...", name, h.Name)
+	if err := h.CreateSnapshot(name); err != nil {
+		return err
+	}
+
+	if err := saveHost(getStore(c), h); err != nil {
+		return err
+	}
+
+	fmt.Println(name)
+	return nil
+}
+
+func cmdSnapshotLs(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED")
+	for _, s := range h.Snapshots {
+		fmt.Fprintf(w, "%s\t%s\n", s.Name, s.CreatedAt.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+func cmdSnapshotRestore(c *cli.Context) error {
+	if len(c.Args()) != 2 {
+		return errExpectedSnapshotName
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().Get(1)
+	log.Infof("Restoring snapshot %q of %q...", name, h.Name)
+	return h.RestoreSnapshot(name)
+}
+
+func cmdSnapshotRm(c *cli.Context) error {
+	if len(c.Args()) != 2 {
+		return errExpectedSnapshotName
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().Get(1)
+	log.Infof("Removing snapshot %q of %q...", name, h.Name)
+	if err := h.RemoveSnapshot(name); err != nil {
+		return err
+	}
+
+	return saveHost(getStore(c), h)
+}
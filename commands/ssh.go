@@ -2,11 +2,50 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
 
+// sshArgs are the arguments of ssh, whose flags aren't parsed.
+type sshArgs struct {
+	Name         string
+	Command      []string
+	ForwardAgent bool
+	TTY          ssh.TTYMode
+}
+
+// parseSSHArgs parses the arguments of ssh: its flags, the machine name,
+// then the command, if any.
+func parseSSHArgs(args []string) (sshArgs, error) {
+	parsed := sshArgs{}
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "--ssh-agent-forwarding":
+			parsed.ForwardAgent = true
+		case "--tty", "-t":
+			parsed.TTY = ssh.TTYForce
+		case "--no-tty", "-T":
+			parsed.TTY = ssh.TTYNone
+		default:
+			return parsed, fmt.Errorf("Error: Unknown flag %s, flags are given before the machine name", args[0])
+		}
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return parsed, ErrExpectedOneMachine
+	}
+
+	parsed.Name = args[0]
+	parsed.Command = args[1:]
+
+	return parsed, nil
+}
+
 func cmdSsh(c *cli.Context) error {
 	// Check for help flag -- Needed due to SkipFlagParsing
 	for _, arg := range c.Args() {
@@ -16,13 +55,13 @@ func cmdSsh(c *cli.Context) error {
 		}
 	}
 
-	name := c.Args().First()
-	if name == "" {
-		return ErrExpectedOneMachine
+	args, err := parseSSHArgs(c.Args())
+	if err != nil {
+		return err
 	}
 
 	store := getStore(c)
-	host, err := loadHost(store, name)
+	host, err := loadHost(store, args.Name)
 	if err != nil {
 		return err
 	}
@@ -36,10 +75,15 @@ func cmdSsh(c *cli.Context) error {
 		return fmt.Errorf("Error: Cannot run SSH command: Host %q is not running", host.Name)
 	}
 
-	client, err := host.CreateSSHClient()
+	createSSHClient := host.CreateSSHClient
+	if args.ForwardAgent {
+		createSSHClient = host.CreateAgentForwardingSSHClient
+	}
+
+	client, err := createSSHClient()
 	if err != nil {
 		return err
 	}
 
-	return client.Shell(c.Args().Tail()...)
+	return ssh.WithTTY(client, args.TTY).Shell(args.Command...)
 }
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSSHArgs(t *testing.T) {
+	var tests = []struct {
+		args     []string
+		expected sshArgs
+	}{
+		{[]string{"dev"}, sshArgs{Name: "dev", Command: []string{}}},
+		{[]string{"dev", "tee", "/tmp/f"}, sshArgs{Name: "dev", Command: []string{"tee", "/tmp/f"}}},
+		{[]string{"--ssh-agent-forwarding", "dev", "ls", "-l"}, sshArgs{Name: "dev", Command: []string{"ls", "-l"}, ForwardAgent: true}},
+		{[]string{"-t", "dev", "top"}, sshArgs{Name: "dev", Command: []string{"top"}, TTY: ssh.TTYForce}},
+		{[]string{"--no-tty", "--ssh-agent-forwarding", "dev", "cat", "-"}, sshArgs{Name: "dev", Command: []string{"cat", "-"}, ForwardAgent: true, TTY: ssh.TTYNone}},
+	}
+
+	for _, test := range tests {
+		parsed, err := parseSSHArgs(test.args)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, parsed)
+	}
+}
+
+func TestParseSSHArgsErrors(t *testing.T) {
+	_, err := parseSSHArgs([]string{})
+	assert.Equal(t, ErrExpectedOneMachine, err)
+
+	_, err = parseSSHArgs([]string{"--tty"})
+	assert.Equal(t, ErrExpectedOneMachine, err)
+
+	_, err = parseSSHArgs([]string{"--swarm", "dev"})
+	assert.EqualError(t, err, "Error: Unknown flag --swarm, flags are given before the machine name")
+}
+
+func TestExitStatus(t *testing.T) {
+	status, ok := exitStatus(ssh.ExitError{Status: 3})
+	assert.True(t, ok)
+	assert.Equal(t, 3, status)
+
+	_, ok = exitStatus(ErrExpectedOneMachine)
+	assert.False(t, ok)
+
+	_, ok = exitStatus(nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+func cmdSSHKeysRotate(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	keyType := c.String("type")
+	if keyType != "" {
+		if err := ssh.ValidateKeyType(keyType); err != nil {
+			return err
+		}
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Rotating the SSH key of %q...", h.Name)
+	if err := h.RotateSSHKey(keyType); err != nil {
+		return err
+	}
+
+	log.Infof("The SSH key of %q has been rotated", h.Name)
+	return nil
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 )
 
@@ -22,5 +23,14 @@ func cmdStatus(c *cli.Context) error {
 
 	log.Info(currentState)
 
+	if reasoner, ok := host.Driver.(drivers.StateReasoner); ok {
+		reason, err := reasoner.GetStateReason()
+		if err != nil {
+			log.Debugf("error getting state reason for host %s: %s", host.Name, err)
+		} else if reason != "" {
+			log.Warn(reason)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	// defaultStore is the store of the storage path itself, which the
+	// named stores are kept in.
+	defaultStore = "default"
+
+	currentStoreFile = "current-store"
+)
+
+var (
+	errExpectedStoreName = errors.New("Error: Expected a store name as an argument")
+	errInvalidStoreName  = errors.New("Invalid store name: it must start with a letter or a digit, and may only contain letters, digits, '-', '_' and '.'")
+
+	// validStoreName matches the names of the stores, which are
+	// directories of the stores directory.
+	validStoreName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+	// storeRoot is the storage path given with --storage-path, which the
+	// named stores are kept in.
+	storeRoot string
+
+	// activeStore is the name of the store the commands use.
+	activeStore = defaultStore
+)
+
+// storeInfo is a store of machines, as listed by store ls.
+type storeInfo struct {
+	Name     string
+	Path     string
+	Active   bool
+	Machines int
+}
+
+// storesDir returns the directory the named stores are kept in.
+func storesDir(root string) string {
+	return filepath.Join(root, "stores")
+}
+
+// storeDir returns the directory of the store: the storage path itself for
+// the default store.
+func storeDir(root, name string) string {
+	if name == "" || name == defaultStore {
+		return root
+	}
+	return filepath.Join(storesDir(root), name)
+}
+
+func storeExists(root, name string) bool {
+	fi, err := os.Stat(storeDir(root, name))
+	return err == nil && fi.IsDir()
+}
+
+// currentStore returns the store picked with store use, the default store
+// unless one was.
+func currentStore(root string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, currentStoreFile))
+	if os.IsNotExist(err) {
+		return defaultStore, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return defaultStore, nil
+	}
+	return name, nil
+}
+
+func setCurrentStore(root, name string) error {
+	path := filepath.Join(root, currentStoreFile)
+	if name == defaultStore {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(name+"\n"), 0600)
+}
+
+// resolveStore returns the name and the directory of the store to use: the
+// one given with --store, or else the one picked with store use. A store
+// picked with store use which was removed since falls back to the default
+// store, for store use to still work. Names are checked like store create
+// does, for them not to reach outside of the stores directory.
+func resolveStore(root, flagStore string) (string, string, error) {
+	if flagStore != "" {
+		if !validStoreName.MatchString(flagStore) {
+			return "", "", errInvalidStoreName
+		}
+		if !storeExists(root, flagStore) && flagStore != defaultStore {
+			return "", "", fmt.Errorf("Error: Store %q does not exist, create it with 'docker-machine store create %s'", flagStore, flagStore)
+		}
+		return flagStore, storeDir(root, flagStore), nil
+	}
+
+	name, err := currentStore(root)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !validStoreName.MatchString(name) {
+		log.Warnf("The store %q in use has an invalid name, using the default store", name)
+		name = defaultStore
+	}
+
+	if name != defaultStore && !storeExists(root, name) {
+		log.Warnf("The store %q in use does not exist, using the default store", name)
+		name = defaultStore
+	}
+
+	return name, storeDir(root, name), nil
+}
+
+// ConfigureStore has the commands use the store given with --store or
+// MACHINE_STORE, or else the one picked with store use, in the storage path.
+// Each store has its own machines, certificates, config file, hooks and
+// profiles.
+func ConfigureStore(c *cli.Context) error {
+	storeRoot = c.GlobalString("storage-path")
+
+	name, dir, err := resolveStore(storeRoot, c.GlobalString("store"))
+	if err != nil {
+		return err
+	}
+
+	activeStore = name
+	mcndirs.BaseDir = dir
+	return c.Set("storage-path", dir)
+}
+
+func getStoreRoot() string {
+	if storeRoot == "" {
+		return mcndirs.GetBaseDir()
+	}
+	return storeRoot
+}
+
+// listStores returns the default store and the named stores of the storage
+// path, with how many machines each has.
+func listStores(root, active string) ([]storeInfo, error) {
+	names := []string{defaultStore}
+
+	files, err := ioutil.ReadDir(storesDir(root))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	named := []string{}
+	for _, f := range files {
+		if f.IsDir() {
+			named = append(named, f.Name())
+		}
+	}
+	sort.Strings(named)
+	names = append(names, named...)
+
+	stores := []storeInfo{}
+	for _, name := range names {
+		dir := storeDir(root, name)
+
+		machines, err := ioutil.ReadDir(filepath.Join(dir, "machines"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		stores = append(stores, storeInfo{
+			Name:     name,
+			Path:     dir,
+			Active:   name == active,
+			Machines: len(machines),
+		})
+	}
+
+	return stores, nil
+}
+
+func printStores(out io.Writer, stores []storeInfo) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tACTIVE\tMACHINES\tPATH")
+	for _, s := range stores {
+		active := "-"
+		if s.Active {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.Name, active, s.Machines, s.Path)
+	}
+	w.Flush()
+}
+
+func cmdStoreLs(c *cli.Context) error {
+	stores, err := listStores(getStoreRoot(), activeStore)
+	if err != nil {
+		return err
+	}
+
+	printStores(os.Stdout, stores)
+	return nil
+}
+
+func cmdStoreCreate(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return errExpectedStoreName
+	}
+
+	name := c.Args().First()
+	if !validStoreName.MatchString(name) {
+		return errInvalidStoreName
+	}
+
+	root := getStoreRoot()
+	if name == defaultStore || storeExists(root, name) {
+		return fmt.Errorf("Error: Store %q already exists", name)
+	}
+
+	if err := os.MkdirAll(storeDir(root, name), 0700); err != nil {
+		return fmt.Errorf("Error creating store %q: %s", name, err)
+	}
+
+	log.Infof("Created store %q, use it with: %s store use %s", name, os.Args[0], name)
+	return nil
+}
+
+func cmdStoreUse(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return errExpectedStoreName
+	}
+
+	name := c.Args().First()
+	if !validStoreName.MatchString(name) {
+		return errInvalidStoreName
+	}
+
+	root := getStoreRoot()
+	if name != defaultStore && !storeExists(root, name) {
+		return fmt.Errorf("Error: Store %q does not exist, create it with 'docker-machine store create %s'", name, name)
+	}
+
+	if err := setCurrentStore(root, name); err != nil {
+		return fmt.Errorf("Error using store %q: %s", name, err)
+	}
+
+	if flagStore := c.GlobalString("store"); flagStore != "" && flagStore != name {
+		log.Warnf("--store or MACHINE_STORE still picks the store %q", flagStore)
+	}
+
+	log.Infof("Using store %q", name)
+	return nil
+}
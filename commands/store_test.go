@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreDir(t *testing.T) {
+	assert.Equal(t, "/root", storeDir("/root", ""))
+	assert.Equal(t, "/root", storeDir("/root", defaultStore))
+	assert.Equal(t, filepath.Join("/root", "stores", "work"), storeDir("/root", "work"))
+}
+
+func TestResolveStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "machine-stores-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	name, dir, err := resolveStore(root, "")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStore, name)
+	assert.Equal(t, root, dir)
+
+	_, _, err = resolveStore(root, "work")
+	assert.EqualError(t, err, `Error: Store "work" does not exist, create it with 'docker-machine store create work'`)
+
+	assert.NoError(t, os.MkdirAll(storeDir(root, "work"), 0700))
+	assert.NoError(t, os.MkdirAll(storeDir(root, "ci"), 0700))
+	assert.NoError(t, setCurrentStore(root, "work"))
+
+	name, dir, err = resolveStore(root, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "work", name)
+	assert.Equal(t, storeDir(root, "work"), dir)
+
+	name, _, err = resolveStore(root, "ci")
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", name, "--store wins over store use")
+
+	name, _, err = resolveStore(root, defaultStore)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStore, name)
+
+	assert.NoError(t, os.RemoveAll(storeDir(root, "work")))
+	name, _, err = resolveStore(root, "")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStore, name, "a removed store in use falls back to the default store")
+
+	assert.NoError(t, setCurrentStore(root, defaultStore))
+	_, err = os.Stat(filepath.Join(root, currentStoreFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestResolveStoreInvalidName(t *testing.T) {
+	root, err := ioutil.TempDir("", "machine-stores-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"..", "../..", "work/../..", "/etc", ".hidden"} {
+		_, _, err := resolveStore(root, name)
+		assert.Equal(t, errInvalidStoreName, err, name)
+	}
+
+	for _, name := range []string{"-work", "my store", "work/ci"} {
+		_, _, err := resolveStore(root, name)
+		assert.Equal(t, errInvalidStoreName, err, name)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, currentStoreFile), []byte("../..\n"), 0600))
+	name, dir, err := resolveStore(root, "")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStore, name, "an invalid store in use falls back to the default store")
+	assert.Equal(t, root, dir)
+}
+
+func TestListStores(t *testing.T) {
+	root, err := ioutil.TempDir("", "machine-stores-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "machines", "dev"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(storeDir(root, "work"), "machines", "web"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(storeDir(root, "work"), "machines", "db"), 0700))
+	assert.NoError(t, os.MkdirAll(storeDir(root, "ci"), 0700))
+
+	stores, err := listStores(root, "work")
+	assert.NoError(t, err)
+	assert.Equal(t, []storeInfo{
+		{Name: defaultStore, Path: root, Machines: 1},
+		{Name: "ci", Path: storeDir(root, "ci")},
+		{Name: "work", Path: storeDir(root, "work"), Active: true, Machines: 2},
+	}, stores)
+
+	var out bytes.Buffer
+	printStores(&out, stores[2:])
+	assert.Equal(t, "NAME   ACTIVE   MACHINES   PATH\nwork   *        2          "+storeDir(root, "work")+"\n", out.String())
+}
+
+func TestCmdStoreUseInvalidName(t *testing.T) {
+	root, err := ioutil.TempDir("", "machine-stores-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	storeRoot = root
+	defer func() { storeRoot = "" }()
+
+	set := flag.NewFlagSet("use", flag.ContinueOnError)
+	assert.NoError(t, set.Parse([]string{"../.."}))
+	c := cli.NewContext(nil, set, cli.NewContext(nil, flag.NewFlagSet("machine", flag.ContinueOnError), nil))
+
+	assert.Equal(t, errInvalidStoreName, cmdStoreUse(c))
+	_, err = os.Stat(filepath.Join(root, currentStoreFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestResolveStoreValidNames(t *testing.T) {
+	root, err := ioutil.TempDir("", "machine-stores-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"my_store", "ci-2.1", "Work"} {
+		assert.NoError(t, os.MkdirAll(storeDir(root, name), 0700))
+
+		got, dir, err := resolveStore(root, name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, name, got)
+		assert.Equal(t, storeDir(root, name), dir)
+	}
+}
@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/amazonec2/amz"
+	"github.com/docker/machine/libmachine/backup"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	errExpectedBackupURL = errors.New("Error: Expected the URL of the backups as an argument, s3://bucket/prefix or file:///path")
+	errNoBackupKey       = errors.New("Error: The backups are encrypted with a key, give its file with --key-file or MACHINE_BACKUP_KEY_FILE, generate one with 'openssl rand -base64 32'")
+
+	// storeBackupFlags are the flags of store backup and store restore.
+	storeBackupFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "key-file",
+			Usage:  "File of the key the backups are encrypted with, kept apart from the store",
+			EnvVar: "MACHINE_BACKUP_KEY_FILE",
+		},
+		cli.StringFlag{
+			Name:   "region",
+			Usage:  "Region of the S3 bucket",
+			Value:  "us-east-1",
+			EnvVar: "AWS_DEFAULT_REGION",
+		},
+	}
+)
+
+// s3Bucket is a bucket of backups in the prefix of an S3 bucket.
+type s3Bucket struct {
+	client *amz.S3
+	bucket string
+	prefix string
+}
+
+func (b *s3Bucket) key(k string) string {
+	if b.prefix == "" {
+		return k
+	}
+	return b.prefix + "/" + k
+}
+
+func (b *s3Bucket) Put(key string, data []byte) error {
+	return b.client.PutObject(b.bucket, b.key(key), data)
+}
+
+func (b *s3Bucket) Get(key string) ([]byte, error) {
+	return b.client.GetObject(b.bucket, b.key(key))
+}
+
+func (b *s3Bucket) List(prefix string) ([]string, error) {
+	keys, err := b.client.ListObjects(b.bucket, b.key(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, b.key(""))
+	}
+	return keys, nil
+}
+
+// openBackupBucket returns the bucket of an s3:// or file:// URL. The S3
+// credentials are the ones of the AWS environment variables, or of the
+// shared credentials files.
+func openBackupBucket(rawurl, region string) (backup.Bucket, error) {
+	switch {
+	case strings.HasPrefix(rawurl, "s3://"):
+		bucket, prefix, err := amz.ParseS3URL(rawurl)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials := amz.NewCredentials(amz.CredentialsConfig{
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			Profile:      os.Getenv("AWS_PROFILE"),
+			Region:       region,
+		})
+		return &s3Bucket{client: amz.NewS3(credentials, region), bucket: bucket, prefix: prefix}, nil
+	case strings.HasPrefix(rawurl, "file://"):
+		dir := strings.TrimPrefix(rawurl, "file://")
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("Invalid backup URL %q: expected file:///path, with an absolute path", rawurl)
+		}
+		return &backup.DirBucket{Dir: dir}, nil
+	}
+
+	return nil, fmt.Errorf("Invalid backup URL %q: expected s3://bucket/prefix or file:///path", rawurl)
+}
+
+// backupArgs returns the bucket of the URL given as argument, and the key
+// of the key file.
+func backupArgs(c *cli.Context) (backup.Bucket, *backup.Key, error) {
+	if len(c.Args()) != 1 {
+		return nil, nil, errExpectedBackupURL
+	}
+
+	if c.String("key-file") == "" {
+		return nil, nil, errNoBackupKey
+	}
+	key, err := backup.ReadKey(c.String("key-file"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bucket, err := openBackupBucket(c.Args().First(), c.String("region"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bucket, key, nil
+}
+
+func cmdStoreBackup(c *cli.Context) error {
+	bucket, key, err := backupArgs(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := backup.Backup(mcndirs.GetBaseDir(), bucket, key)
+	if err != nil {
+		return fmt.Errorf("Error backing up store %q: %s", activeStore, err)
+	}
+
+	log.Infof("Backed up store %q as %s: %d files, of which %d changed since the previous backups were uploaded (%s)",
+		activeStore, result.Manifest.ID, len(result.Manifest.Files), result.Uploaded, formatBytes(result.UploadedBytes))
+	return nil
+}
+
+func cmdStoreRestore(c *cli.Context) error {
+	bucket, key, err := backupArgs(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("list") {
+		ids, err := backup.Snapshots(bucket)
+		if err != nil {
+			return err
+		}
+		printSnapshots(os.Stdout, ids)
+		return nil
+	}
+
+	if c.Bool("verify") {
+		manifest, err := backup.Verify(bucket, key, c.String("snapshot"))
+		if err != nil {
+			return err
+		}
+
+		log.Infof("The backup %s and its %d files are whole", manifest.ID, len(manifest.Files))
+		return nil
+	}
+
+	dir := mcndirs.GetBaseDir()
+	machines, err := storeMachines(dir)
+	if err != nil {
+		return err
+	}
+	if len(machines) > 0 && !c.Bool("force") {
+		return fmt.Errorf("Error: Store %q has machines, restore into an empty store created with 'docker-machine store create', or overwrite its files with --force", activeStore)
+	}
+
+	manifest, err := backup.Restore(dir, bucket, key, c.String("snapshot"))
+	if err != nil {
+		return fmt.Errorf("Error restoring store %q: %s", activeStore, err)
+	}
+
+	log.Infof("Restored the %d files of the backup %s to store %q", len(manifest.Files), manifest.ID, activeStore)
+	return nil
+}
+
+// storeMachines returns the names of the machines of the store in dir.
+func storeMachines(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(dir, "machines"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	machines := []string{}
+	for _, f := range files {
+		if f.IsDir() {
+			machines = append(machines, f.Name())
+		}
+	}
+	return machines, nil
+}
+
+func printSnapshots(out io.Writer, ids []string) {
+	if len(ids) == 0 {
+		fmt.Fprintln(out, "No backups")
+		return
+	}
+
+	for _, id := range ids {
+		fmt.Fprintln(out, id)
+	}
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/amazonec2/amz"
+	"github.com/docker/machine/libmachine/backup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenBackupBucket(t *testing.T) {
+	bucket, err := openBackupBucket("s3://backups/machine/laptop", "eu-west-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "backups", bucket.(*s3Bucket).bucket)
+	assert.Equal(t, "machine/laptop", bucket.(*s3Bucket).prefix)
+	assert.Equal(t, "https://s3.eu-west-1.amazonaws.com", bucket.(*s3Bucket).client.Endpoint)
+
+	bucket, err = openBackupBucket("file:///mnt/backups", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &backup.DirBucket{Dir: "/mnt/backups"}, bucket)
+
+	_, err = openBackupBucket("file://backups", "")
+	assert.EqualError(t, err, `Invalid backup URL "file://backups": expected file:///path, with an absolute path`)
+
+	_, err = openBackupBucket("gs://backups", "")
+	assert.EqualError(t, err, `Invalid backup URL "gs://backups": expected s3://bucket/prefix or file:///path`)
+}
+
+func TestS3BucketPrefix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/backups/", r.URL.Path)
+		assert.Equal(t, "laptop/snapshots/", r.URL.Query().Get("prefix"))
+		fmt.Fprint(w, `<ListBucketResult><Contents><Key>laptop/snapshots/20261001T120000.000000000Z</Key></Contents></ListBucketResult>`)
+	}))
+	defer server.Close()
+
+	bucket := &s3Bucket{
+		client: &amz.S3{
+			Endpoint:    server.URL,
+			Credentials: amz.NewCredentials(amz.CredentialsConfig{AccessKey: "key", SecretKey: "secret"}),
+			Client:      server.Client(),
+		},
+		bucket: "backups",
+		prefix: "laptop",
+	}
+
+	ids, err := backup.Snapshots(bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20261001T120000.000000000Z"}, ids)
+
+	bucket.prefix = ""
+	assert.Equal(t, "blobs/a", bucket.key("blobs/a"))
+}
+
+func TestStoreMachines(t *testing.T) {
+	root, err := ioutil.TempDir("", "machine-stores-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	machines, err := storeMachines(root)
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "machines", "dev"), 0700))
+
+	machines, err = storeMachines(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, machines)
+}
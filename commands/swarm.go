@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+var (
+	errNoSwarmManager       = errors.New("Error: Expected the manager to join given with --manager")
+	errExpectedSwarmCluster = errors.New("Error: Expected the name of a swarm mode cluster, which is the name of the machine which initialized it")
+)
+
+// configureSwarmModeHost sets the swarm mode options of a machine and makes
+// it part of its cluster, saving the options once it is.
+func configureSwarmModeHost(store persist.Store, h *host.Host, role, joinManager, advertiseAddr string) error {
+	if h.HostOptions.SwarmOptions == nil {
+		h.HostOptions.SwarmOptions = &swarm.SwarmOptions{}
+	}
+
+	swarmOptions := *h.HostOptions.SwarmOptions
+	swarmOptions.Mode = true
+	swarmOptions.Role = role
+	swarmOptions.JoinManager = joinManager
+	if advertiseAddr != "" {
+		swarmOptions.Address = advertiseAddr
+	}
+
+	if err := swarmOptions.ValidateMode(); err != nil {
+		return fmt.Errorf("Error in swarm mode options of %s: %s", h.Name, err)
+	}
+
+	h.HostOptions.SwarmOptions = &swarmOptions
+	if err := libmachine.ConfigureSwarmMode(store, h); err != nil {
+		return fmt.Errorf("Error configuring swarm mode on %s: %s", h.Name, err)
+	}
+
+	return saveHost(store, h)
+}
+
+func cmdSwarmInit(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	store := getStore(c)
+
+	h, err := loadHost(store, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if err := configureSwarmModeHost(store, h, swarm.RoleManager, "", c.String("advertise-addr")); err != nil {
+		return err
+	}
+
+	log.Infof("To add machines to the cluster, run: docker-machine swarm join --manager %s MACHINE...", h.Name)
+	return nil
+}
+
+func cmdSwarmJoin(c *cli.Context) error {
+	manager := c.String("manager")
+	if manager == "" {
+		return errNoSwarmManager
+	}
+
+	if len(c.Args()) == 0 {
+		return ErrNoMachineSpecified
+	}
+
+	store := getStore(c)
+
+	errs := []error{}
+	for _, name := range c.Args() {
+		h, err := loadHost(store, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := configureSwarmModeHost(store, h, c.String("role"), manager, c.String("advertise-addr")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+func cmdSwarmLeave(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		return ErrNoMachineSpecified
+	}
+
+	store := getStore(c)
+
+	errs := []error{}
+	for _, name := range c.Args() {
+		h, err := loadHost(store, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := libmachine.LeaveSwarmMode(h, c.Bool("force")); err != nil {
+			errs = append(errs, fmt.Errorf("Error removing %s from its swarm mode cluster: %s", h.Name, err))
+			continue
+		}
+
+		if err := saveHost(store, h); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+func cmdSwarmRotateTokens(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return errExpectedSwarmCluster
+	}
+	cluster := c.Args().First()
+
+	roles := []string{}
+	switch role := c.String("role"); role {
+	case "":
+	case swarm.RoleManager, swarm.RoleWorker:
+		roles = append(roles, role)
+	default:
+		return swarm.ErrUnknownRole
+	}
+
+	store := getStore(c)
+
+	hosts, err := listHosts(store)
+	if err != nil {
+		return err
+	}
+
+	managers := swarmModeManagers(hosts, cluster)
+	if len(managers) == 0 {
+		return fmt.Errorf("Error: No manager of a swarm mode cluster named %q", cluster)
+	}
+
+	return libmachine.RotateSwarmJoinTokens(managers[0], managers, roles...)
+}
+
+// swarmModeManagers returns the managers of a swarm mode cluster, the
+// machine which initialized it first if it still is one.
+func swarmModeManagers(hosts []*host.Host, cluster string) []*host.Host {
+	clusters := getSwarmModeClusters(hosts)
+
+	managers := []*host.Host{}
+	for _, h := range hosts {
+		swarmOptions := h.HostOptions.SwarmOptions
+		if clusters[h.Name] != cluster || swarmOptions.Role != swarm.RoleManager {
+			continue
+		}
+
+		if h.Name == cluster {
+			managers = append([]*host.Host{h}, managers...)
+		} else {
+			managers = append(managers, h)
+		}
+	}
+
+	return managers
+}
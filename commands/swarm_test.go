@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwarmModeManagers(t *testing.T) {
+	hosts := []*host.Host{
+		{Name: "manager-1", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager, JoinManager: "manager-0"}}},
+		{Name: "manager-0", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager}}},
+		{Name: "worker-0", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleWorker, JoinManager: "manager-0"}}},
+		{Name: "other", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{Mode: true, Role: swarm.RoleManager}}},
+		{Name: "plain", HostOptions: &host.HostOptions{SwarmOptions: &swarm.SwarmOptions{}}},
+	}
+
+	managers := swarmModeManagers(hosts, "manager-0")
+	assert.Equal(t, 2, len(managers))
+	assert.Equal(t, "manager-0", managers[0].Name)
+	assert.Equal(t, "manager-1", managers[1].Name)
+
+	assert.Empty(t, swarmModeManagers(hosts, "plain"))
+	assert.Empty(t, swarmModeManagers(hosts, "worker-0"))
+}
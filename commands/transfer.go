@@ -0,0 +1,696 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/term"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// transferSource writes the data being copied to w.
+type transferSource func(w io.Writer) error
+
+// transferSink consumes the data being copied from r.
+type transferSink func(r io.Reader) error
+
+// transferEndpoint is one side of a copy done by Machine itself rather than
+// by an external binary. Host is nil for the local host.
+type transferEndpoint struct {
+	Host HostInfo
+	Path string
+}
+
+func (e transferEndpoint) String() string {
+	if e.Host == nil {
+		return e.Path
+	}
+	return fmt.Sprintf("%s:%s", e.Host.GetMachineName(), e.Path)
+}
+
+func (e transferEndpoint) base() string {
+	if e.Host == nil {
+		return filepath.Base(e.Path)
+	}
+	return path.Base(e.Path)
+}
+
+func (e transferEndpoint) join(name string) string {
+	if e.Host == nil {
+		return filepath.Join(e.Path, name)
+	}
+	return path.Join(e.Path, name)
+}
+
+func (e transferEndpoint) output(command string) (string, error) {
+	client, err := newHostInfoSSHClient(e.Host)
+	if err != nil {
+		return "", err
+	}
+	return client.Output(command)
+}
+
+// size returns the size of the file in bytes, or -1 if it does not exist.
+func (e transferEndpoint) size() (int64, error) {
+	if e.Host == nil {
+		fi, err := os.Stat(e.Path)
+		if os.IsNotExist(err) {
+			return -1, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+
+	p := shellQuote(e.Path)
+	out, err := e.output(fmt.Sprintf("if [ -f %s ]; then wc -c < %s; else echo -1; fi", p, p))
+	if err != nil {
+		return 0, fmt.Errorf("Error getting size of %s: %s", e, err)
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+}
+
+func (e transferEndpoint) isDir() (bool, error) {
+	if e.Host == nil {
+		fi, err := os.Stat(e.Path)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return fi.IsDir(), nil
+	}
+
+	out, err := e.output(fmt.Sprintf("if [ -d %s ]; then echo dir; fi", shellQuote(e.Path)))
+	if err != nil {
+		return false, fmt.Errorf("Error checking %s: %s", e, err)
+	}
+
+	return strings.TrimSpace(out) == "dir", nil
+}
+
+// fileSource reads a single file starting at offset.
+func (e transferEndpoint) fileSource(offset int64, compress bool) transferSource {
+	if e.Host != nil {
+		command := fmt.Sprintf("cat %s", shellQuote(e.Path))
+		if offset > 0 {
+			command = fmt.Sprintf("tail -c +%d %s", offset+1, shellQuote(e.Path))
+		}
+		if compress {
+			command += " | gzip -c"
+		}
+		return remoteSource(e.Host, command)
+	}
+
+	return func(w io.Writer) error {
+		f, err := os.Open(e.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := f.Seek(offset, os.SEEK_SET); err != nil {
+			return err
+		}
+
+		return compressTo(w, compress, func(w io.Writer) error {
+			_, err := io.Copy(w, f)
+			return err
+		})
+	}
+}
+
+// fileSink writes a single file, appending to it when resuming.
+func (e transferEndpoint) fileSink(resume, compress bool) transferSink {
+	if e.Host != nil {
+		redirect := ">"
+		if resume {
+			redirect = ">>"
+		}
+		command := fmt.Sprintf("cat %s %s", redirect, shellQuote(e.Path))
+		if compress {
+			command = fmt.Sprintf("gzip -dc %s %s", redirect, shellQuote(e.Path))
+		}
+		return remoteSink(e.Host, command)
+	}
+
+	return func(r io.Reader) error {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if resume {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+
+		f, err := os.OpenFile(e.Path, flags, 0644)
+		if err != nil {
+			return err
+		}
+
+		err = decompressFrom(r, compress, func(r io.Reader) error {
+			_, err := io.Copy(f, r)
+			return err
+		})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+// tarSource archives the directory. If contents is true only what is inside
+// of the directory is archived, otherwise the directory itself is.
+func (e transferEndpoint) tarSource(contents bool, excludes []string, compress bool) transferSource {
+	if e.Host != nil {
+		flags := "-cf"
+		if compress {
+			flags = "-czf"
+		}
+
+		if contents {
+			return remoteSource(e.Host, fmt.Sprintf("tar %s - -C %s .", flags, shellQuote(e.Path)))
+		}
+
+		dir, base := path.Split(strings.TrimSuffix(e.Path, "/"))
+		if dir == "" {
+			dir = "."
+		}
+		return remoteSource(e.Host, fmt.Sprintf("tar %s - -C %s %s", flags, shellQuote(dir), shellQuote(base)))
+	}
+
+	src := strings.TrimSuffix(e.Path, string(filepath.Separator))
+	if contents {
+		src += string(filepath.Separator)
+	}
+
+	return func(w io.Writer) error {
+		return compressTo(w, compress, func(w io.Writer) error {
+			return writeTar(w, src, excludes)
+		})
+	}
+}
+
+// tarSink unpacks an archive into the directory, creating it if needed.
+func (e transferEndpoint) tarSink(excludes []string, compress bool) transferSink {
+	if e.Host != nil {
+		return remoteSink(e.Host, remoteUntarCmd(e.Path, compress))
+	}
+
+	return func(r io.Reader) error {
+		if err := os.MkdirAll(e.Path, 0755); err != nil {
+			return err
+		}
+
+		return decompressFrom(r, compress, func(r io.Reader) error {
+			return readTar(r, e.Path, excludes)
+		})
+	}
+}
+
+// remoteUntarCmd builds the shell command which unpacks a tar stream read
+// on stdin into dest on a machine.
+func remoteUntarCmd(dest string, compress bool) string {
+	dest = shellQuote(dest)
+
+	flags := "-xf"
+	if compress {
+		flags = "-xzf"
+	}
+
+	return fmt.Sprintf("mkdir -p %s && tar %s - -C %s", dest, flags, dest)
+}
+
+// listTree returns the slash separated paths of everything below the
+// directory, or nothing if it is not a directory.
+func (e transferEndpoint) listTree() ([]string, error) {
+	paths := []string{}
+
+	if e.Host != nil {
+		out, err := e.output(fmt.Sprintf("if cd %s 2>/dev/null; then find . -print0; fi", shellQuote(e.Path)))
+		if err != nil {
+			return nil, fmt.Errorf("Error listing %s: %s", e, err)
+		}
+
+		for _, p := range strings.Split(out, "\x00") {
+			p = strings.TrimPrefix(p, "./")
+			if p != "" && p != "." {
+				paths = append(paths, p)
+			}
+		}
+		return paths, nil
+	}
+
+	fi, err := os.Stat(e.Path)
+	if os.IsNotExist(err) || (err == nil && !fi.IsDir()) {
+		return paths, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(e.Path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(e.Path, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	return paths, err
+}
+
+// remove removes the paths, relative to the directory, and everything below
+// them.
+func (e transferEndpoint) remove(paths []string) error {
+	if e.Host == nil {
+		for _, p := range paths {
+			if err := os.RemoveAll(filepath.Join(e.Path, filepath.FromSlash(p))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Keep the command lines short enough for any shell.
+	const batch = 100
+
+	for len(paths) > 0 {
+		n := batch
+		if len(paths) < n {
+			n = len(paths)
+		}
+
+		quoted := make([]string, n)
+		for i, p := range paths[:n] {
+			quoted[i] = shellQuote(p)
+		}
+		paths = paths[n:]
+
+		if _, err := e.output(fmt.Sprintf("cd %s && rm -rf -- %s", shellQuote(e.Path), strings.Join(quoted, " "))); err != nil {
+			return fmt.Errorf("Error deleting from %s: %s", e, err)
+		}
+	}
+
+	return nil
+}
+
+// prunePaths returns what to delete from the destination of a sync so that
+// it matches the source, both given as the paths below the synced
+// directory. Like rsync, excluded paths are neither copied nor deleted, and
+// neither are the directories holding them.
+func prunePaths(src, dest []string, excludes []string) []string {
+	keep := map[string]bool{}
+	for _, p := range src {
+		keep[p] = true
+	}
+
+	for _, p := range dest {
+		if !keep[p] && !isExcludedPath(p, excludes) {
+			continue
+		}
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			keep[dir] = true
+		}
+	}
+
+	sorted := append([]string{}, dest...)
+	sort.Strings(sorted)
+
+	removed := map[string]bool{}
+	prune := []string{}
+	for _, p := range sorted {
+		if removed[path.Dir(p)] {
+			removed[p] = true
+			continue
+		}
+		if keep[p] || isExcludedPath(p, excludes) {
+			continue
+		}
+		removed[p] = true
+		prune = append(prune, p)
+	}
+
+	return prune
+}
+
+// newHostInfoSSHClient returns an SSH client for the machine. The driver's
+// SSH settings are used when available since the SSH port is not always 22.
+func newHostInfoSSHClient(hostInfo HostInfo) (ssh.Client, error) {
+	if d, ok := hostInfo.(drivers.Driver); ok {
+		return drivers.GetSSHClientFromDriver(d)
+	}
+
+	ip, err := hostInfo.GetIP()
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &ssh.Auth{
+		Keys: []string{hostInfo.GetSSHKeyPath()},
+	}
+
+	return ssh.NewClient(hostInfo.GetSSHUsername(), ip, 22, auth)
+}
+
+func remoteSource(hostInfo HostInfo, command string) transferSource {
+	return func(w io.Writer) error {
+		client, err := newHostInfoSSHClient(hostInfo)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("Reading from %s with: %s", hostInfo.GetMachineName(), command)
+
+		if err := client.Stream(command, nil, w); err != nil {
+			return fmt.Errorf("Error reading from %s: %s", hostInfo.GetMachineName(), err)
+		}
+		return nil
+	}
+}
+
+func remoteSink(hostInfo HostInfo, command string) transferSink {
+	return func(r io.Reader) error {
+		client, err := newHostInfoSSHClient(hostInfo)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("Writing to %s with: %s", hostInfo.GetMachineName(), command)
+
+		if err := client.Stream(command, r, nil); err != nil {
+			return fmt.Errorf("Error writing to %s: %s", hostInfo.GetMachineName(), err)
+		}
+		return nil
+	}
+}
+
+func compressTo(w io.Writer, compress bool, write func(w io.Writer) error) error {
+	if !compress {
+		return write(w)
+	}
+
+	gw := gzip.NewWriter(w)
+	if err := write(gw); err != nil {
+		gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+func decompressFrom(r io.Reader, compress bool, read func(r io.Reader) error) error {
+	if !compress {
+		return read(r)
+	}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	return read(gr)
+}
+
+// runTransfer connects source to sink. Data always flows through the local
+// host, so that two machines do not need to be able to reach each other.
+func runTransfer(source transferSource, sink transferSink, progress *progressReader) error {
+	pr, pw := io.Pipe()
+	sourceErrCh := make(chan error, 1)
+
+	go func() {
+		err := source(pw)
+		pw.CloseWithError(err)
+		sourceErrCh <- err
+	}()
+
+	var r io.Reader = pr
+	if progress != nil {
+		progress.r = pr
+		r = progress
+	}
+
+	sinkErr := sink(r)
+
+	// Unblock the source if the sink stopped reading early.
+	pr.Close()
+	sourceErr := <-sourceErrCh
+
+	if progress != nil {
+		progress.finish()
+	}
+
+	if sourceErr != nil && sourceErr != io.ErrClosedPipe {
+		return sourceErr
+	}
+
+	return sinkErr
+}
+
+// progressReader prints how much of a transfer is done to out as data is
+// read through it.
+type progressReader struct {
+	r         io.Reader
+	out       io.Writer
+	name      string
+	total     int64
+	count     int64
+	lastPrint time.Time
+}
+
+// newProgressReader returns nil when progress should not be shown, which
+// runTransfer treats as "no progress reporting". A total below zero means
+// the size of the transfer is not known in advance.
+func newProgressReader(name string, total, offset int64, quiet bool) *progressReader {
+	if quiet || !term.IsTerminal(os.Stderr.Fd()) {
+		return nil
+	}
+
+	return &progressReader{
+		out:   os.Stderr,
+		name:  name,
+		total: total,
+		count: offset,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.count += int64(n)
+
+	if time.Since(p.lastPrint) > 200*time.Millisecond {
+		p.print()
+	}
+
+	return n, err
+}
+
+func (p *progressReader) String() string {
+	if p.total > 0 {
+		return fmt.Sprintf("%-40s %3d%% %10s", p.name, p.count*100/p.total, formatBytes(p.count))
+	}
+	return fmt.Sprintf("%-40s %10s", p.name, formatBytes(p.count))
+}
+
+func (p *progressReader) print() {
+	p.lastPrint = time.Now()
+	fmt.Fprintf(p.out, "\r%s", p)
+}
+
+func (p *progressReader) finish() {
+	p.print()
+	fmt.Fprintln(p.out)
+}
+
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d%s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
+
+// isExcluded reports whether the slash separated relative path matches one
+// of the exclude patterns, either as a whole or by its base name.
+func isExcluded(rel string, excludes []string) bool {
+	for _, pattern := range excludes {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcludedPath reports whether the path or one of its parent directories
+// is excluded.
+func isExcludedPath(rel string, excludes []string) bool {
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if isExcluded(p, excludes) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTar writes src to w as a tar stream. Like rsync, a trailing slash on
+// src means the contents of the directory rather than the directory itself.
+func writeTar(w io.Writer, src string, excludes []string) error {
+	root := filepath.Clean(src)
+	prefix := filepath.Base(root)
+	if strings.HasSuffix(src, "/") || strings.HasSuffix(src, string(filepath.Separator)) {
+		prefix = ""
+	}
+
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && isExcluded(rel, excludes) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := path.Join(prefix, rel)
+		if name == "." || name == "" {
+			return nil
+		}
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// readTar unpacks the tar stream in r below dest.
+func readTar(r io.Reader, dest string, excludes []string) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("Refusing to unpack %q outside of %s", hdr.Name, dest)
+		}
+		if isExcluded(name, excludes) {
+			continue
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			log.Debugf("Skipping unsupported tar entry %q", hdr.Name)
+		}
+	}
+}
+
+// shellQuote single quotes s for use in a POSIX shell command line.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsExcluded(t *testing.T) {
+	excludes := []string{".git/", "*.o", "build/out"}
+
+	assert.True(t, isExcluded(".git", excludes))
+	assert.True(t, isExcluded("src/main.o", excludes))
+	assert.True(t, isExcluded("build/out", excludes))
+	assert.False(t, isExcluded("build", excludes))
+	assert.False(t, isExcluded("src/main.go", excludes))
+}
+
+func TestRemoteUntarCmd(t *testing.T) {
+	assert.Equal(t, "mkdir -p '/home/docker/it'\\''s' && tar -xf - -C '/home/docker/it'\\''s'", remoteUntarCmd("/home/docker/it's", false))
+	assert.Equal(t, "mkdir -p '/src' && tar -xzf - -C '/src'", remoteUntarCmd("/src", true))
+}
+
+func TestPrunePaths(t *testing.T) {
+	src := []string{"a.txt", "sub", "sub/b.txt"}
+	dest := []string{"a.txt", "old", "old/x", "old/y", "sub", "sub/b.txt", "sub/c.txt", "obj", "obj/main.o", "gone.o"}
+
+	assert.Equal(t, []string{"old", "sub/c.txt"}, prunePaths(src, dest, []string{"*.o"}))
+	assert.Equal(t, []string{"gone.o", "obj", "old", "sub/c.txt"}, prunePaths(src, dest, nil))
+	assert.Empty(t, prunePaths(src, nil, nil))
+}
+
+func TestWriteAndReadTar(t *testing.T) {
+	src, err := ioutil.TempDir("", "machine-rsync-src")
+	assert.NoError(t, err)
+	defer os.RemoveAll(src)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, ".git"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "sub", "b.o"), []byte("o"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref"), 0644))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeTar(buf, src+"/", []string{".git", "*.o"}))
+
+	names := []string{}
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"a.txt", "sub", "sub/b.txt"}, names)
+
+	dest, err := ioutil.TempDir("", "machine-rsync-dest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dest)
+
+	assert.NoError(t, readTar(bytes.NewReader(buf.Bytes()), dest, nil))
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "sub", "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512B", formatBytes(512))
+	assert.Equal(t, "1.5KB", formatBytes(1536))
+	assert.Equal(t, "3.0MB", formatBytes(3*1024*1024))
+}
+
+func TestCopyFileLocalResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-scp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dest := filepath.Join(dir, "dest")
+
+	assert.NoError(t, ioutil.WriteFile(src, []byte("hello, world"), 0644))
+	assert.NoError(t, ioutil.WriteFile(dest, []byte("hello"), 0644))
+
+	opts := scpOptions{Resume: true, Quiet: true}
+	assert.NoError(t, copyFile(transferEndpoint{nil, src}, transferEndpoint{nil, dest}, opts))
+
+	content, err := ioutil.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, world", string(content))
+}
+
+func TestCopyFileLocalCompressedIntoDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-scp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src.txt")
+	destDir := filepath.Join(dir, "out")
+
+	assert.NoError(t, ioutil.WriteFile(src, []byte("compressed"), 0644))
+	assert.NoError(t, os.Mkdir(destDir, 0755))
+
+	opts := scpOptions{Compress: true, Quiet: true}
+	assert.NoError(t, copyFile(transferEndpoint{nil, src}, transferEndpoint{nil, destDir}, opts))
+
+	content, err := ioutil.ReadFile(filepath.Join(destDir, "src.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", string(content))
+}
+
+func TestTarSyncDeleteOnlyPrunesSyncedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-rsync")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dest := filepath.Join(dir, "home")
+
+	assert.NoError(t, os.MkdirAll(src, 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dest, "src"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dest, "other.txt"), []byte("other"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dest, "src", "stale.txt"), []byte("stale"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dest, "src", "main.o"), []byte("o"), 0644))
+
+	opts := syncOptions{Delete: true, Excludes: []string{"*.o"}}
+	assert.NoError(t, tarSync(transferEndpoint{nil, src}, transferEndpoint{nil, dest}, opts))
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		return err == nil
+	}
+
+	assert.True(t, exists("other.txt"))
+	assert.True(t, exists("src/a.txt"))
+	assert.True(t, exists("src/main.o"))
+	assert.False(t, exists("src/stale.txt"))
+
+	// With a trailing slash the contents are synced into the destination
+	// itself, which is then what gets pruned.
+	assert.NoError(t, tarSync(transferEndpoint{nil, src + "/"}, transferEndpoint{nil, dest}, opts))
+
+	assert.True(t, exists("a.txt"))
+	assert.False(t, exists("other.txt"))
+	assert.False(t, exists("src/a.txt"))
+	assert.True(t, exists("src/main.o"))
+}
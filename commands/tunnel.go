@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+var (
+	errNoSSHBinary    = errors.New("Error: The ssh binary was not found in your PATH, it opens the SSH tunnels")
+	errSSHTunnelSwarm = errors.New("Error: An SSH tunnel reaches the engine of the machine, it cannot be used with --swarm or --swarm-cluster")
+)
+
+// sshTunnelTimeout is how long the SSH tunnels have to come up.
+const sshTunnelTimeout = 15 * time.Second
+
+// sshTunnel is the local end of an SSH tunnel to the unix socket of the
+// engine of a machine, for the clients to reach the engine through SSH
+// rather than on its TLS TCP listener: the unix socket Socket, or when Port
+// isn't 0, this port of the loopback interface.
+type sshTunnel struct {
+	Socket string
+	Port   int
+}
+
+// machineSSHTunnel returns the tunnel env opens to the machine, on port when
+// it isn't 0, else on a socket in the directory of the machine.
+func machineSSHTunnel(h *host.Host, port int) sshTunnel {
+	if port != 0 {
+		return sshTunnel{Port: port}
+	}
+	return sshTunnel{Socket: filepath.Join(mcndirs.GetMachineDir(), h.Name, "docker.sock")}
+}
+
+func (t sshTunnel) local() string {
+	if t.Port != 0 {
+		return net.JoinHostPort("127.0.0.1", strconv.Itoa(t.Port))
+	}
+	return t.Socket
+}
+
+// dockerHost returns the DOCKER_HOST of the clients going through the
+// tunnel.
+func (t sshTunnel) dockerHost() string {
+	if t.Port != 0 {
+		return "tcp://" + t.local()
+	}
+	return "unix://" + t.Socket
+}
+
+// up tells whether the tunnel accepts connections.
+func (t sshTunnel) up() bool {
+	network := "unix"
+	if t.Port != 0 {
+		network = "tcp"
+	}
+
+	conn, err := net.DialTimeout(network, t.local(), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// usesSSHTunnel tells whether the clients reach the engine of the machine
+// through an SSH tunnel, when asked to, or when the engine doesn't listen on
+// TCP.
+func usesSSHTunnel(h *host.Host, asked bool) bool {
+	return asked || (h.HostOptions != nil && h.HostOptions.EngineOptions.SSHTransport())
+}
+
+// allowDockerSocket adds the SSH user of the machine to the docker group
+// when it can't use the socket of the engine, as the tunnels connect to it
+// as this user.
+func allowDockerSocket(h *host.Host) error {
+	command := fmt.Sprintf("test -w %s || %s", provision.DockerSocket, h.Driver.SSHSudo("usermod -aG docker "+h.Driver.GetSSHUsername()))
+	if _, err := h.RunSSHCommand(command); err != nil {
+		return fmt.Errorf("Error giving %s access to the socket of the engine: %s", h.Driver.GetSSHUsername(), err)
+	}
+	return nil
+}
+
+// sshTunnelCmd returns the command of the ssh binary forwarding the local end
+// of the tunnel to the socket of the engine, see ssh.ExternalClient.Forward.
+func sshTunnelCmd(h *host.Host, t sshTunnel, background bool) (*exec.Cmd, error) {
+	if err := h.RefuseWithoutSSH("open an SSH tunnel to"); err != nil {
+		return nil, err
+	}
+
+	sshBinaryPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return nil, errNoSSHBinary
+	}
+
+	if err := allowDockerSocket(h); err != nil {
+		return nil, err
+	}
+
+	client, err := drivers.GetExternalSSHClientFromDriver(h.Driver, sshBinaryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := client.Forward(t.local(), provision.DockerSocket, background)
+	cmd.Stderr = os.Stderr
+	log.Debug(cmd)
+
+	return cmd, nil
+}
+
+// openSSHTunnel opens the tunnel to the machine in the background, for it to
+// last once docker-machine exits, unless it is up already.
+func openSSHTunnel(h *host.Host, t sshTunnel) error {
+	if t.up() {
+		return nil
+	}
+
+	cmd, err := sshTunnelCmd(h, t, true)
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error opening the SSH tunnel to %s: %s", h.Name, err)
+	}
+
+	return waitForSSHTunnel(h, t, nil)
+}
+
+// startSSHTunnel opens the tunnel to the machine for as long as
+// docker-machine runs, and returns the function closing it.
+func startSSHTunnel(h *host.Host, t sshTunnel) (func(), error) {
+	cmd, err := sshTunnelCmd(h, t, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error opening the SSH tunnel to %s: %s", h.Name, err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	closeTunnel := func() {
+		cmd.Process.Kill()
+		<-exited
+	}
+
+	if err := waitForSSHTunnel(h, t, exited); err != nil {
+		closeTunnel()
+		return nil, err
+	}
+
+	return closeTunnel, nil
+}
+
+// waitForSSHTunnel waits for the tunnel to accept connections, unless ssh
+// exits first.
+func waitForSSHTunnel(h *host.Host, t sshTunnel, exited <-chan struct{}) error {
+	deadline := time.Now().Add(sshTunnelTimeout)
+
+	for !t.up() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Error opening the SSH tunnel to %s: %s doesn't accept connections after %s", h.Name, t.local(), sshTunnelTimeout)
+		}
+
+		select {
+		case <-exited:
+			return fmt.Errorf("Error opening the SSH tunnel to %s: ssh exited", h.Name)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
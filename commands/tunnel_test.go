@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSHTunnelDockerHost(t *testing.T) {
+	assert.Equal(t, "unix:///tmp/dev/docker.sock", sshTunnel{Socket: "/tmp/dev/docker.sock"}.dockerHost())
+	assert.Equal(t, "tcp://127.0.0.1:12376", sshTunnel{Port: 12376}.dockerHost())
+	assert.Equal(t, "127.0.0.1:12376", sshTunnel{Port: 12376}.local())
+}
+
+func TestSSHTunnelUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tunnel := sshTunnel{Socket: filepath.Join(dir, "docker.sock")}
+	assert.False(t, tunnel.up())
+
+	l, err := net.Listen("unix", tunnel.Socket)
+	assert.NoError(t, err)
+	defer l.Close()
+
+	assert.True(t, tunnel.up())
+}
+
+func TestUsesSSHTunnel(t *testing.T) {
+	h := &host.Host{HostOptions: &host.HostOptions{EngineOptions: &engine.EngineOptions{Transport: engine.TransportTLS}}}
+	assert.False(t, usesSSHTunnel(h, false))
+	assert.True(t, usesSSHTunnel(h, true))
+
+	h.HostOptions.EngineOptions.Transport = engine.TransportSSH
+	assert.True(t, usesSSHTunnel(h, false))
+
+	assert.False(t, usesSSHTunnel(&host.Host{}, false))
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+var errNoUpdate = errors.New("Error: Expected at least one of --ssh-port, --ssh-user or --ssh-option")
+
+// sshSettingsFromFlags returns the SSH settings given to update, validated.
+func sshSettingsFromFlags(c *cli.Context) (drivers.SSHSettings, error) {
+	settings := drivers.SSHSettings{
+		Port:    c.Int("ssh-port"),
+		User:    c.String("ssh-user"),
+		Options: c.StringSlice("ssh-option"),
+	}
+
+	if settings.Port == 0 && settings.User == "" && len(settings.Options) == 0 {
+		return settings, errNoUpdate
+	}
+
+	if settings.Port < 0 || settings.Port > 65535 {
+		return settings, fmt.Errorf("Invalid SSH port %d, expected a port between 1 and 65535", settings.Port)
+	}
+
+	if strings.ContainsAny(settings.User, " \t\r\n@:") {
+		return settings, fmt.Errorf("Invalid SSH user %q", settings.User)
+	}
+
+	for _, opt := range settings.Options {
+		if err := ssh.ValidateOption(opt); err != nil {
+			return settings, err
+		}
+	}
+
+	return settings, nil
+}
+
+func cmdUpdate(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	settings, err := sshSettingsFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	h, err := getFirstArgHost(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.UpdateSSHSettings(settings, !c.Bool("no-check")); err != nil {
+		return err
+	}
+
+	if err := saveHost(getStore(c), h); err != nil {
+		return err
+	}
+
+	log.Infof("The SSH settings of %q have been updated", h.Name)
+	return nil
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func updateContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("update", flag.ContinueOnError)
+	for _, f := range []cli.Flag{
+		cli.IntFlag{Name: "ssh-port"},
+		cli.StringFlag{Name: "ssh-user"},
+		cli.StringSliceFlag{Name: "ssh-option", Value: &cli.StringSlice{}},
+	} {
+		f.Apply(set)
+	}
+	assert.NoError(t, set.Parse(args))
+
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestSSHSettingsFromFlags(t *testing.T) {
+	settings, err := sshSettingsFromFlags(updateContext(t, "--ssh-port", "2222", "--ssh-user", "ops", "--ssh-option", "Compression=yes", "--ssh-option", "Ciphers="))
+
+	assert.NoError(t, err)
+	assert.Equal(t, drivers.SSHSettings{Port: 2222, User: "ops", Options: []string{"Compression=yes", "Ciphers="}}, settings)
+}
+
+func TestSSHSettingsFromFlagsInvalid(t *testing.T) {
+	_, err := sshSettingsFromFlags(updateContext(t))
+	assert.Equal(t, errNoUpdate, err)
+
+	_, err = sshSettingsFromFlags(updateContext(t, "--ssh-port", "70000"))
+	assert.EqualError(t, err, "Invalid SSH port 70000, expected a port between 1 and 65535")
+
+	_, err = sshSettingsFromFlags(updateContext(t, "--ssh-user", "ops@bastion"))
+	assert.EqualError(t, err, `Invalid SSH user "ops@bastion"`)
+
+	_, err = sshSettingsFromFlags(updateContext(t, "--ssh-option", "Compression"))
+	assert.EqualError(t, err, `Invalid SSH option "Compression", expected Key=Value`)
+}
@@ -1,7 +1,47 @@
 package commands
 
-import "github.com/docker/machine/cli"
+import (
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/provision"
+)
 
 func cmdUpgrade(c *cli.Context) error {
-	return runActionWithContext("upgrade", c)
+	target := provision.EngineTarget{
+		Version: c.String("engine-version"),
+		Channel: c.String("channel"),
+	}
+
+	if err := target.Validate(); err != nil {
+		return err
+	}
+
+	timeout := c.Duration("timeout")
+	if target.IsLatestStable() && timeout == 0 {
+		return runActionWithContext("upgrade", c)
+	}
+
+	hosts, err := getHostsFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		return ErrNoMachineSpecified
+	}
+
+	ctx, cancel := commandContext(timeout)
+	defer cancel()
+
+	errs := []error{}
+	for _, h := range hosts {
+		if err := h.UpgradeEngineContext(ctx, target); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
 }
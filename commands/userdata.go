@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/userdata"
+)
+
+// placeholderSSHPublicKey is the public key user data templates are checked
+// with before the key of the machine is generated.
+const placeholderSSHPublicKey = "ssh-rsa PLACEHOLDER"
+
+// userDataPath is the file the user data of a machine is rendered to.
+func userDataPath(store *persist.Filestore, name string) string {
+	return filepath.Join(store.Path, "machines", name, "user-data")
+}
+
+// userDataVars returns the variables the user data template of the machine
+// of h is rendered with, but for the SSH public key.
+func userDataVars(h *host.Host, cfg machineConfig) userdata.Vars {
+	return userdata.Vars{
+		MachineName:   h.Name,
+		EngineOptions: cfg.EngineOptions,
+		SwarmRole:     userdata.SwarmRole(cfg.SwarmOptions),
+	}
+}
+
+// prepareUserData checks the user data template of cfg renders for the
+// machine of h, and gives the driver the file it will be rendered to with
+// the user-data option. Nothing is written yet, for dry runs.
+func prepareUserData(store *persist.Filestore, h *host.Host, cfg machineConfig, driverOpts drivers.DriverOptions) error {
+	if !drivers.GetCapabilities(h.Driver).UserData {
+		return fmt.Errorf("Error setting machine configuration from flags provided: --user-data: %s", drivers.ErrUserDataNotImplemented)
+	}
+
+	tmpl, err := ioutil.ReadFile(cfg.UserData)
+	if err != nil {
+		return fmt.Errorf("Error reading the user data template: %s", err)
+	}
+
+	vars := userDataVars(h, cfg)
+	vars.SSHPublicKey = placeholderSSHPublicKey
+	if _, err := userdata.Render(cfg.UserData, tmpl, vars); err != nil {
+		return err
+	}
+
+	if flags, ok := driverOpts.(rpcdriver.RpcFlags); ok {
+		flags.Values["user-data"] = userDataPath(store, h.Name)
+	}
+
+	return nil
+}
+
+// writeUserData renders the user data template of cfg for the machine of h.
+// The SSH key of the machine is generated first for its public key to be
+// rendered, the driver keeping the key it finds.
+func writeUserData(store *persist.Filestore, h *host.Host, cfg machineConfig, driverOpts drivers.DriverOptions) error {
+	tmpl, err := ioutil.ReadFile(cfg.UserData)
+	if err != nil {
+		return fmt.Errorf("Error reading the user data template: %s", err)
+	}
+
+	keyPath := h.Driver.GetSSHKeyPath()
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return err
+	}
+
+	keyType := ""
+	if flags, ok := driverOpts.(rpcdriver.RpcFlags); ok {
+		keyType, _ = flags.Get("ssh-key-type").(string)
+	}
+	if err := ssh.GenerateSSHKeyOfType(keyPath, keyType); err != nil {
+		return err
+	}
+
+	publicKey, err := ssh.ReadPublicKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	vars := userDataVars(h, cfg)
+	vars.SSHPublicKey = string(publicKey)
+	data, err := userdata.Render(cfg.UserData, tmpl, vars)
+	if err != nil {
+		return err
+	}
+
+	path := userDataPath(store, h.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// removeUnsavedMachine removes the directory of a machine whose creation
+// failed before it was saved, holding only its user data and SSH key, for
+// the machine not to be taken as existing.
+func removeUnsavedMachine(store *persist.Filestore, name string) {
+	dir := filepath.Join(store.Path, "machines", name)
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); !os.IsNotExist(err) {
+		return
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warnf("Error removing the directory of machine %q: %s", name, err)
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+// sshKeyDriver is a fake driver keeping the SSH key of the machine in the
+// store, like the drivers do.
+type sshKeyDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *sshKeyDriver) GetSSHKeyPath() string {
+	return d.BaseDriver.GetSSHKeyPath()
+}
+
+func TestUserData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-userdata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	template := filepath.Join(dir, "user-data.tmpl")
+	assert.NoError(t, ioutil.WriteFile(template, []byte("{{.MachineName}} {{.SwarmRole}} {{join \",\" .EngineOptions.Labels}}\n{{.SSHPublicKey}}\n"), 0600))
+
+	store := &persist.Filestore{Path: dir}
+	h := &host.Host{
+		Name:   "worker-01",
+		Driver: &sshKeyDriver{&fakedriver.Driver{BaseDriver: &drivers.BaseDriver{MachineName: "worker-01", StorePath: dir}}},
+	}
+	cfg := machineConfig{
+		EngineOptions: &engine.EngineOptions{Labels: []string{"tier=web"}},
+		SwarmOptions:  &swarm.SwarmOptions{Mode: true, Role: swarm.RoleWorker},
+		UserData:      template,
+	}
+	driverOpts := rpcdriver.RpcFlags{Values: map[string]interface{}{"ssh-key-type": "ed25519"}}
+
+	assert.NoError(t, writeUserData(store, h, cfg, driverOpts))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "machines", "worker-01", "user-data"))
+	assert.NoError(t, err)
+	lines := strings.Split(string(data), "\n")
+	assert.Equal(t, "worker-01 worker tier=web", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "ssh-ed25519 "))
+
+	removeUnsavedMachine(store, "worker-01")
+	_, err = os.Stat(filepath.Join(dir, "machines", "worker-01"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPrepareUserDataNotSupported(t *testing.T) {
+	h := &host.Host{
+		Name:   "worker-01",
+		Driver: &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}},
+	}
+
+	err := prepareUserData(&persist.Filestore{}, h, machineConfig{UserData: "user-data.tmpl"}, rpcdriver.RpcFlags{})
+	assert.EqualError(t, err, "Error setting machine configuration from flags provided: --user-data: Driver does not support giving user data to machines")
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/verify"
+)
+
+// verification is the outcome of verifying a machine: its report, or why it
+// couldn't be verified.
+type verification struct {
+	Name   string
+	Report *verify.Report
+	Err    error
+}
+
+func cmdVerify(c *cli.Context) error {
+	var (
+		hosts []*host.Host
+		err   error
+	)
+
+	store := getStore(c)
+
+	if c.Bool("all") {
+		hosts, err = listHosts(store)
+	} else {
+		hosts, err = getHostsFromContext(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		if c.Bool("all") {
+			return nil
+		}
+		return ErrNoMachineSpecified
+	}
+
+	opts := verify.Options{
+		Image:   c.String("image"),
+		MaxPull: c.Duration("max-pull"),
+		MaxRun:  c.Duration("max-run"),
+	}
+
+	verifications := make([]verification, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+
+			report, err := verifyHost(h, opts)
+			if err == nil {
+				h.Verification = report
+				err = saveHost(store, h)
+			}
+			verifications[i] = verification{Name: h.Name, Report: report, Err: err}
+		}(i, h)
+	}
+	wg.Wait()
+
+	printVerifications(os.Stdout, verifications)
+
+	failed := 0
+	for _, v := range verifications {
+		if v.Err != nil || !v.Report.Passed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d machines failed verification", failed, len(verifications))
+	}
+
+	return nil
+}
+
+// verifyHost verifies the machine of h with the options, completed with
+// what was recorded for it.
+func verifyHost(h *host.Host, opts verify.Options) (*verify.Report, error) {
+	s, err := h.Driver.GetState()
+	if err != nil {
+		return nil, err
+	}
+	if s != state.Running {
+		return nil, fmt.Errorf("Error: Cannot verify the machine: Host %q is not running", h.Name)
+	}
+
+	opts.Sudo = h.Driver.SSHSudo
+	opts.AuthOptions = verifyAuthOptions(h)
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		opts.StorageDriver = h.HostOptions.EngineOptions.StorageDriver
+	}
+
+	return verify.Run(h, opts), nil
+}
+
+// verifyAuthOptions returns the certificates the client reaches the engine
+// of the machine with, none when it is reached through SSH tunnels.
+func verifyAuthOptions(h *host.Host) *auth.AuthOptions {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions.SSHTransport() {
+		return nil
+	}
+	return h.HostOptions.AuthOptions
+}
+
+func printVerifications(out io.Writer, verifications []verification) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCHECK\tSTATUS\tDURATION\tMESSAGE")
+
+	for _, v := range verifications {
+		if v.Err != nil && v.Report == nil {
+			fmt.Fprintf(w, "%s\t\t%s\t\t%s\n", v.Name, verify.StatusFailed, v.Err)
+			continue
+		}
+
+		for _, res := range v.Report.Results {
+			duration := ""
+			if res.Duration > 0 {
+				duration = res.Duration.Round(time.Millisecond).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Name, res.Check, res.Status, duration, res.Message)
+		}
+
+		if v.Err != nil {
+			fmt.Fprintf(w, "%s\t\t%s\t\t%s\n", v.Name, verify.StatusFailed, v.Err)
+		}
+	}
+
+	w.Flush()
+}
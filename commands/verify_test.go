@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/verify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyHostNotRunning(t *testing.T) {
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Stopped}}
+
+	report, err := verifyHost(h, verify.Options{})
+
+	assert.Nil(t, report)
+	assert.EqualError(t, err, `Error: Cannot verify the machine: Host "dev" is not running`)
+}
+
+func TestVerifyAuthOptions(t *testing.T) {
+	authOptions := &auth.AuthOptions{CaCertPath: "/certs/ca.pem"}
+
+	h := &host.Host{HostOptions: &host.HostOptions{AuthOptions: authOptions, EngineOptions: &engine.EngineOptions{}}}
+	assert.Equal(t, authOptions, verifyAuthOptions(h))
+
+	h.HostOptions.EngineOptions.Transport = engine.TransportSSH
+	assert.Nil(t, verifyAuthOptions(h))
+}
+
+func TestPrintVerifications(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	printVerifications(out, []verification{
+		{Name: "dev", Report: &verify.Report{Results: []verify.Result{
+			{Check: verify.CheckStorage, Status: verify.StatusPassed, Message: "overlay2"},
+			{Check: verify.CheckPull, Status: verify.StatusPassed, Duration: 2345678 * time.Microsecond},
+		}}},
+		{Name: "stopped", Err: errors.New("not running")},
+	})
+
+	assert.Equal(t, `NAME      CHECK            STATUS   DURATION   MESSAGE
+dev       storage-driver   passed              overlay2
+dev       pull             passed   2.346s     
+stopped                    failed              not running
+`, out.String())
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// waitSettings are the timeouts and poll intervals of the waits, by stage.
+type waitSettings map[mcnutils.WaitStage]mcnutils.WaitSettings
+
+// ConfigureWaits sets the timeouts and poll intervals of the waits from the
+// wait section of the config file, then from the --wait-timeout and
+// --wait-poll-interval global flags, which win over it.
+func ConfigureWaits(c *cli.Context) error {
+	cfg, err := readConfig(configFilePath())
+	if err != nil {
+		return err
+	}
+	fromFile := cfg.Waits
+
+	fromFlags, err := parseWaitFlags(c.GlobalStringSlice("wait-timeout"), c.GlobalStringSlice("wait-poll-interval"))
+	if err != nil {
+		return err
+	}
+
+	for _, settings := range []waitSettings{fromFile, fromFlags} {
+		for stage, s := range settings {
+			mcnutils.ConfigureWait(stage, s)
+		}
+	}
+
+	return nil
+}
+
+func decodeWaitSettings(c *config, encoded []byte) error {
+	var wait map[string]map[string]string
+	if err := json.Unmarshal(encoded, &wait); err != nil {
+		return fmt.Errorf("expected \"wait\" to map waits to their timeout and poll-interval: %s", err)
+	}
+
+	for name, values := range wait {
+		stage, err := mcnutils.ParseWaitStage(name)
+		if err != nil {
+			return err
+		}
+
+		s := mcnutils.WaitSettings{}
+		for key, value := range values {
+			d, err := parseWaitDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s %s of the %s wait: %s", key, value, stage, err)
+			}
+
+			switch key {
+			case "timeout":
+				s.Timeout = d
+			case "poll-interval":
+				s.PollInterval = d
+			default:
+				return fmt.Errorf("unknown setting %q of the %s wait, expected timeout or poll-interval", key, stage)
+			}
+		}
+		c.Waits[stage] = s
+	}
+
+	return nil
+}
+
+// parseWaitFlags parses the values of --wait-timeout and
+// --wait-poll-interval, given as <wait>=<duration>.
+func parseWaitFlags(timeouts, pollIntervals []string) (waitSettings, error) {
+	settings := waitSettings{}
+
+	for _, flag := range []struct {
+		name   string
+		values []string
+		set    func(*mcnutils.WaitSettings, time.Duration)
+	}{
+		{"wait-timeout", timeouts, func(s *mcnutils.WaitSettings, d time.Duration) { s.Timeout = d }},
+		{"wait-poll-interval", pollIntervals, func(s *mcnutils.WaitSettings, d time.Duration) { s.PollInterval = d }},
+	} {
+		for _, value := range flag.values {
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Error: --%s %s: expected <wait>=<duration>, like ssh=10m", flag.name, value)
+			}
+
+			stage, err := mcnutils.ParseWaitStage(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("Error: --%s %s: %s", flag.name, value, err)
+			}
+
+			d, err := parseWaitDuration(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("Error: --%s %s: %s", flag.name, value, err)
+			}
+
+			s := settings[stage]
+			flag.set(&s, d)
+			settings[stage] = s
+		}
+	}
+
+	return settings, nil
+}
+
+func parseWaitDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("expected a duration greater than 0")
+	}
+	return d, nil
+}
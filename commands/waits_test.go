@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWaitSettings(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+create:
+  driver: generic
+wait:
+  ssh:
+    timeout: 10m
+  daemon:
+    timeout: 15m
+    poll-interval: 10s
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, waitSettings{
+		mcnutils.WaitSSH:    {Timeout: 10 * time.Minute},
+		mcnutils.WaitDaemon: {Timeout: 15 * time.Minute, PollInterval: 10 * time.Second},
+	}, cfg.Waits)
+
+	cfg, err = parseConfig([]byte("create:\n  driver: generic\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, waitSettings{}, cfg.Waits)
+}
+
+func TestParseWaitSettingsErrors(t *testing.T) {
+	for _, config := range []string{
+		"wait:\n  boot:\n    timeout: 10m\n",
+		"wait:\n  ssh:\n    interval: 10s\n",
+		"wait:\n  ssh:\n    timeout: forever\n",
+		"wait:\n  ssh:\n    timeout: 0s\n",
+		"wait:\n  - ssh\n",
+	} {
+		_, err := parseConfig([]byte(config))
+		assert.Error(t, err, config)
+	}
+}
+
+func TestParseWaitFlags(t *testing.T) {
+	settings, err := parseWaitFlags([]string{"ssh=10m", "daemon=15m"}, []string{"daemon=10s"})
+	assert.NoError(t, err)
+	assert.Equal(t, waitSettings{
+		mcnutils.WaitSSH:    {Timeout: 10 * time.Minute},
+		mcnutils.WaitDaemon: {Timeout: 15 * time.Minute, PollInterval: 10 * time.Second},
+	}, settings)
+
+	_, err = parseWaitFlags([]string{"10m"}, nil)
+	assert.EqualError(t, err, "Error: --wait-timeout 10m: expected <wait>=<duration>, like ssh=10m")
+
+	_, err = parseWaitFlags(nil, []string{"boot=10s"})
+	assert.Error(t, err)
+
+	_, err = parseWaitFlags([]string{"ssh=-1m"}, nil)
+	assert.EqualError(t, err, "Error: --wait-timeout ssh=-1m: expected a duration greater than 0")
+}
@@ -0,0 +1,327 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/metrics"
+	"github.com/docker/machine/libmachine/notify"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/reconcile"
+)
+
+func cmdWatch(c *cli.Context) error {
+	filters, err := parseFilters(c.StringSlice("filter"))
+	if err != nil {
+		return err
+	}
+
+	w := newWatcher(getStore(c), filters, c.Bool("correct"))
+
+	if c.Bool("reap") {
+		w.reaper = &reaper{
+			store:  w.store,
+			remove: c.Bool("reap-remove"),
+			exempt: c.StringSlice("reap-exempt-label"),
+		}
+	}
+
+	if c.Bool("once") {
+		drifts, err := w.reconcile()
+		if err != nil {
+			return err
+		}
+		if len(drifts) > 0 {
+			return fmt.Errorf("Error: %d drift(s) left, see above", len(drifts))
+		}
+		return nil
+	}
+
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("Error: --interval must be positive, got %s", interval)
+	}
+
+	if addr := c.String("listen"); addr != "" {
+		http.HandleFunc("/metrics", func(rw http.ResponseWriter, r *http.Request) {
+			var buf bytes.Buffer
+			if err := w.writeMetrics(&buf, time.Now()); err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			buf.WriteTo(rw)
+		})
+
+		log.Infof("Serving metrics on http://%s/metrics", addr)
+
+		go func() {
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				log.Errorf("Error serving metrics: %s", err)
+			}
+		}()
+	}
+
+	log.Infof("Watching the machines every %s, press Ctrl-C to stop...", interval)
+
+	for {
+		if _, err := w.reconcile(); err != nil {
+			log.Error(err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// watcher compares the machines with what the store recorded for them, one
+// round after the other, and corrects their drift when asked to. It keeps
+// the drift of the last round, for a drift to be reported once rather than
+// every round, and to serve it as metrics with those of the machines.
+type watcher struct {
+	store   persist.Store
+	filters FilterOptions
+	correct bool
+
+	// reaper stops or removes the machines which expired before every
+	// round, when watch was asked to.
+	reaper *reaper
+
+	// virtualbox is held while correcting a virtualbox machine, for them to
+	// be corrected one at a time, as in runActionForeachMachine.
+	virtualbox sync.Mutex
+
+	mu          sync.Mutex
+	machines    []metrics.Machine
+	drifts      map[string]reconcile.Drift
+	drivers     map[string]string
+	corrections map[string]correction
+}
+
+type correction struct {
+	reconcile.Drift
+	count int
+}
+
+func newWatcher(store persist.Store, filters FilterOptions, correct bool) *watcher {
+	return &watcher{
+		store:       store,
+		filters:     filters,
+		correct:     correct,
+		drifts:      map[string]reconcile.Drift{},
+		drivers:     map[string]string{},
+		corrections: map[string]correction{},
+	}
+}
+
+func driftKey(d reconcile.Drift) string {
+	return d.Machine + "/" + d.Kind
+}
+
+// watchResult is what a round found out about a machine.
+type watchResult struct {
+	machine   metrics.Machine
+	drifts    []reconcile.Drift
+	corrected []reconcile.Drift
+}
+
+// reconcile runs a round over the machines of the store matching the
+// filters, and returns the drift left.
+func (w *watcher) reconcile() ([]reconcile.Drift, error) {
+	if w.reaper != nil {
+		if _, err := w.reaper.reap(time.Now()); err != nil {
+			log.Errorf("Error reaping the expired machines: %s", err)
+		}
+	}
+
+	hosts, err := listHosts(w.store)
+	if err != nil {
+		return nil, err
+	}
+	hosts = filterHosts(hosts, w.filters)
+
+	w.mu.Lock()
+	reported := w.drifts
+	w.mu.Unlock()
+
+	results := make([]watchResult, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h *host.Host) {
+			defer wg.Done()
+			results[i] = w.reconcileHost(h, reported)
+
+			if rpcd, ok := h.Driver.(*rpcdriver.RpcClientDriver); ok {
+				rpcd.Close()
+			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	machines := []metrics.Machine{}
+	drifts := map[string]reconcile.Drift{}
+	left := []reconcile.Drift{}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, r := range results {
+		seen[r.machine.Name] = true
+		machines = append(machines, r.machine)
+		w.drivers[r.machine.Name] = r.machine.Driver
+
+		for _, d := range r.drifts {
+			drifts[driftKey(d)] = d
+			left = append(left, d)
+		}
+		for _, d := range r.corrected {
+			c := w.corrections[driftKey(d)]
+			c.Drift = d
+			c.count++
+			w.corrections[driftKey(d)] = c
+		}
+	}
+
+	for key, d := range reported {
+		if _, ok := drifts[key]; !ok && seen[d.Machine] {
+			log.Infof("%s: %s is %s again", d.Machine, d.Kind, d.Desired)
+		}
+	}
+
+	w.machines = machines
+	w.drifts = drifts
+
+	return left, nil
+}
+
+// reconcileHost detects the drift of the machine, reports the drift which
+// wasn't reported yet, and corrects it when asked to.
+func (w *watcher) reconcileHost(h *host.Host, reported map[string]reconcile.Drift) watchResult {
+	result := watchResult{machine: metrics.Collect(h)}
+
+	for _, d := range reconcile.Detect(h, result.machine, time.Now()) {
+		if _, ok := reported[driftKey(d)]; !ok {
+			log.Warnf("%s: %s", h.Name, d)
+			notifyDrift(notify.Drifted, h, d, nil)
+		}
+
+		if !w.correct || !d.Correctable() {
+			result.drifts = append(result.drifts, d)
+			continue
+		}
+
+		if err := w.correctHost(h, d); err != nil {
+			log.Errorf("%s: Error correcting the drift: %s", h.Name, err)
+			notifyDrift(notify.Error, h, d, err)
+			result.drifts = append(result.drifts, d)
+			continue
+		}
+
+		log.Infof("%s: Corrected, %s is %s", h.Name, d.Kind, d.Desired)
+		notifyDrift(notify.Corrected, h, d, nil)
+		result.corrected = append(result.corrected, d)
+	}
+
+	return result
+}
+
+func (w *watcher) correctHost(h *host.Host, d reconcile.Drift) error {
+	if h.DriverName == "virtualbox" {
+		w.virtualbox.Lock()
+		defer w.virtualbox.Unlock()
+	}
+
+	log.Infof("%s: Correcting the drift, %s", h.Name, d)
+
+	if err := reconcile.Correct(h, d); err != nil {
+		return err
+	}
+
+	return saveHost(w.store, h)
+}
+
+// notifyDrift sends the event of the drift of the machine to the sinks of
+// the config file, with the error correcting it for error events.
+func notifyDrift(event string, h *host.Host, d reconcile.Drift, err error) {
+	sinks := eventSinks(event, h.Name)
+	if len(sinks) == 0 {
+		return
+	}
+
+	e := notify.Event{
+		Event:   event,
+		Machine: h.Name,
+		Driver:  h.DriverName,
+		Time:    time.Now().UTC(),
+		Drift:   d.String(),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	notify.Send(sinks, e)
+}
+
+// writeMetrics writes the metrics of the machines of the last round, with
+// their drift and the corrections made so far.
+func (w *watcher) writeMetrics(out io.Writer, now time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := metrics.Write(out, w.machines, now); err != nil {
+		return err
+	}
+
+	drifts := []metrics.Sample{}
+	for _, key := range sortedDriftKeys(w.drifts) {
+		d := w.drifts[key]
+		drifts = append(drifts, metrics.Sample{Labels: w.driftLabels(d), Value: 1})
+	}
+	if err := metrics.WriteFamily(out, "docker_machine_drift", "gauge", "Whether the machine drifted from its recorded state, by kind of drift.", drifts); err != nil {
+		return err
+	}
+
+	corrections := []metrics.Sample{}
+	for _, key := range sortedCorrectionKeys(w.corrections) {
+		c := w.corrections[key]
+		corrections = append(corrections, metrics.Sample{Labels: w.driftLabels(c.Drift), Value: float64(c.count)})
+	}
+	return metrics.WriteFamily(out, "docker_machine_drift_corrections_total", "counter", "Drift of the machine corrected since watch started, by kind of drift.", corrections)
+}
+
+func (w *watcher) driftLabels(d reconcile.Drift) map[string]string {
+	return map[string]string{
+		"machine": d.Machine,
+		"driver":  w.drivers[d.Machine],
+		"kind":    d.Kind,
+	}
+}
+
+func sortedDriftKeys(m map[string]reconcile.Drift) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedCorrectionKeys(m map[string]correction) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/metrics"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/reconcile"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func watchTestStore(t *testing.T) (persist.Store, func()) {
+	dir, err := ioutil.TempDir("", "machine-watch")
+	assert.NoError(t, err)
+
+	mcndirs.BaseDir = dir
+
+	return &persist.Filestore{Path: dir}, func() {
+		mcndirs.BaseDir = ""
+		os.RemoveAll(dir)
+	}
+}
+
+func watchTestHost(s state.State) *host.Host {
+	return &host.Host{
+		Name:         "dev",
+		DriverName:   "fakedriver",
+		Driver:       &fakedriver.Driver{MockName: "dev", MockState: s},
+		HostOptions:  &host.HostOptions{},
+		DesiredState: state.Running,
+	}
+}
+
+func TestWatcherReportsDrift(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	h := watchTestHost(state.Stopped)
+	result := newWatcher(store, FilterOptions{}, false).reconcileHost(h, map[string]reconcile.Drift{})
+
+	assert.Equal(t, []reconcile.Drift{{Machine: "dev", Kind: reconcile.DriftState, Desired: "Running", Actual: "Stopped"}}, result.drifts)
+	assert.Empty(t, result.corrected)
+
+	s, _ := h.Driver.GetState()
+	assert.Equal(t, state.Stopped, s)
+}
+
+func TestWatcherCorrectsDrift(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	h := watchTestHost(state.Stopped)
+	result := newWatcher(store, FilterOptions{}, true).reconcileHost(h, map[string]reconcile.Drift{})
+
+	assert.Empty(t, result.drifts)
+	assert.Equal(t, []reconcile.Drift{{Machine: "dev", Kind: reconcile.DriftState, Desired: "Running", Actual: "Stopped"}}, result.corrected)
+
+	s, _ := h.Driver.GetState()
+	assert.Equal(t, state.Running, s)
+
+	exists, err := store.Exists("dev")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestWatcherLeavesSavedMachines(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	h := watchTestHost(state.Saved)
+	result := newWatcher(store, FilterOptions{}, true).reconcileHost(h, map[string]reconcile.Drift{})
+
+	assert.Equal(t, []reconcile.Drift{{Machine: "dev", Kind: reconcile.DriftState, Desired: "Running", Actual: "Saved"}}, result.drifts)
+	assert.Empty(t, result.corrected)
+}
+
+func TestWatcherWriteMetrics(t *testing.T) {
+	w := newWatcher(nil, FilterOptions{}, true)
+	w.machines = []metrics.Machine{{Name: "dev", Driver: "virtualbox", State: state.Running}}
+	w.drivers["dev"] = "virtualbox"
+	w.drifts["dev/engine-version"] = reconcile.Drift{Machine: "dev", Kind: reconcile.DriftEngineVersion, Desired: "24.0.5", Actual: "24.0.7"}
+	w.corrections["dev/state"] = correction{reconcile.Drift{Machine: "dev", Kind: reconcile.DriftState}, 2}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, w.writeMetrics(out, time.Now()))
+
+	assert.Contains(t, out.String(), `docker_machine_state{driver="virtualbox",machine="dev",state="Running"} 1`)
+	assert.Contains(t, out.String(), "# TYPE docker_machine_drift gauge\n"+`docker_machine_drift{driver="virtualbox",kind="engine-version",machine="dev"} 1`)
+	assert.Contains(t, out.String(), "# TYPE docker_machine_drift_corrections_total counter\n"+`docker_machine_drift_corrections_total{driver="virtualbox",kind="state",machine="dev"} 2`)
+}
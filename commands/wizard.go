@@ -0,0 +1,663 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/drivers/errdriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/spec"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// maxCredentialAttempts is how many times the wizard asks for credentials the
+// provider refuses before giving up.
+const maxCredentialAttempts = 3
+
+var (
+	errNoDriverPlugins    = errors.New("Error: No driver plugin found in the PATH, docker-machine-driver-<name> binaries are needed to create machines")
+	errInteractiveArgs    = errors.New("Error: --interactive only takes --driver and a machine name, the other options are asked for")
+	errInteractiveAborted = errors.New("Error: Machine creation aborted")
+)
+
+// cmdCreateInteractive runs the create wizard, then creates the machine with
+// the create flags it came up with, as if they had been given.
+func cmdCreateInteractive(c *cli.Context, args []string) error {
+	driverName, name, err := parseInteractiveArgs(args)
+	if err != nil {
+		return err
+	}
+
+	store := getStore(c)
+	w := &wizard{
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+		store:   store,
+		drivers: localbinary.ListDrivers(),
+		loadDriver: func(driverName, name string) (drivers.Driver, error) {
+			return loadWizardDriver(store, driverName, name)
+		},
+	}
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		w.readSecret = func() (string, error) {
+			secret, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Fprintln(w.out)
+			return string(secret), err
+		}
+	}
+
+	answers, err := w.run(driverName, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w.out, "\nThe equivalent command is:")
+	fmt.Fprintf(w.out, "\n    %s\n\n", answers.command(os.Args[0]))
+	if envVars := answers.secretEnvVars(); len(envVars) > 0 {
+		fmt.Fprintf(w.out, "with %s exported.\n\n", strings.Join(envVars, ", "))
+	}
+
+	if answers.Err != nil {
+		fmt.Fprintf(w.out, "The driver needs more options than were asked for, add them to the command: %s\n", answers.Err)
+		return nil
+	}
+
+	create, err := w.confirm("Create the machine now?")
+	if err != nil {
+		return err
+	}
+	if !create {
+		return nil
+	}
+
+	createCtx, err := answers.context(c)
+	if err != nil {
+		return err
+	}
+	return audited("create", machineArgs, cmdCreateInner)(createCtx)
+}
+
+// parseInteractiveArgs returns the driver and the name of the machine given
+// to create --interactive, if any.
+func parseInteractiveArgs(args []string) (string, string, error) {
+	driverName, name := "", ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--interactive" || arg == "--interactive=true":
+		case arg == "--driver" || arg == "-d":
+			if i+1 == len(args) {
+				return "", "", fmt.Errorf("Error: %s needs a driver name", arg)
+			}
+			i++
+			driverName = args[i]
+		case strings.HasPrefix(arg, "--driver=") || strings.HasPrefix(arg, "-d="):
+			driverName = arg[strings.Index(arg, "=")+1:]
+		case strings.HasPrefix(arg, "-") || name != "":
+			return "", "", errInteractiveArgs
+		default:
+			name = arg
+		}
+	}
+
+	return driverName, name, nil
+}
+
+// loadWizardDriver starts the plugin of the driver, for a machine of the
+// given name.
+func loadWizardDriver(store persist.Store, driverName, name string) (drivers.Driver, error) {
+	storePath := ""
+	if filestore, ok := store.(*persist.Filestore); ok {
+		storePath = filestore.Path
+	}
+
+	bareDriverData, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: name,
+		StorePath:   storePath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := newPluginDriver(driverName, bareDriverData)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading driver %q: %s", driverName, err)
+	}
+
+	if _, ok := driver.(*errdriver.Driver); ok {
+		return nil, errdriver.ErrDriverNotLoadable{Name: driverName}
+	}
+
+	return driver, nil
+}
+
+// wizard asks the questions of create --interactive on out, and reads the
+// answers from in, a line each.
+type wizard struct {
+	in    *bufio.Reader
+	out   io.Writer
+	store persist.Store
+
+	// drivers are the drivers to choose from
+	drivers []string
+
+	// loadDriver loads the chosen driver, for a machine of the given name
+	loadDriver func(driverName, name string) (drivers.Driver, error)
+
+	// readSecret reads a credential without echoing it, when in is a
+	// terminal
+	readSecret func() (string, error)
+}
+
+// wizardAnswers are the create flags the wizard came up with.
+type wizardAnswers struct {
+	Driver string
+	Name   string
+	Flags  []wizardFlag
+
+	// mcnFlags are the flags of the driver, which create has besides its
+	// own
+	mcnFlags []mcnflag.Flag
+
+	// Err is why the driver refuses the answers, such as an option it
+	// needs which the wizard didn't ask for
+	Err error
+}
+
+// wizardFlag is a driver flag given a value other than its default.
+type wizardFlag struct {
+	Name  string
+	Value string
+
+	// EnvVar is the environment variable of a credential typed in, which
+	// the equivalent command reads it from instead of printing it
+	EnvVar string
+}
+
+// args returns the arguments of the create command with the answers.
+func (a *wizardAnswers) args() []string {
+	args := []string{"create", "--driver", a.Driver}
+	for _, f := range a.Flags {
+		args = append(args, "--"+f.Name+"="+f.Value)
+	}
+	return append(args, a.Name)
+}
+
+// context returns the context of the create command with the answers, its
+// flags being those of create and of the driver, as when create is given
+// the driver on the command line.
+func (a *wizardAnswers) context(parent *cli.Context) (*cli.Context, error) {
+	cliFlags, err := convertMcnFlagsToCliFlags(a.mcnFlags)
+	if err != nil {
+		return nil, fmt.Errorf("Error trying to convert provided driver flags to cli flags: %w", err)
+	}
+	flags := append(append([]cli.Flag{}, sharedCreateFlags...), cliFlags...)
+
+	set := flag.NewFlagSet("create", flag.ContinueOnError)
+	for _, f := range flags {
+		f.Apply(set)
+	}
+	if err := set.Parse(a.args()[1:]); err != nil {
+		return nil, err
+	}
+
+	c := cli.NewContext(parent.App, set, parent)
+	c.Command = cli.Command{Name: "create", Flags: flags}
+	return c, nil
+}
+
+// command returns the create command with the answers, for a shell, with the
+// credentials which were typed in read from their environment variable.
+func (a *wizardAnswers) command(program string) string {
+	words := []string{program, "create", "--driver", quoteArg(a.Driver)}
+	for _, f := range a.Flags {
+		value := quoteArg(f.Value)
+		if f.EnvVar != "" {
+			value = `"$` + f.EnvVar + `"`
+		}
+		words = append(words, "--"+f.Name, value)
+	}
+	return strings.Join(append(words, quoteArg(a.Name)), " ")
+}
+
+// secretEnvVars returns the environment variables the command reads
+// credentials from.
+func (a *wizardAnswers) secretEnvVars() []string {
+	envVars := []string{}
+	for _, f := range a.Flags {
+		if f.EnvVar != "" {
+			envVars = append(envVars, f.EnvVar)
+		}
+	}
+	return envVars
+}
+
+var plainArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quoteArg quotes s for a shell, when it needs to be.
+func quoteArg(s string) string {
+	if plainArg.MatchString(s) {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// run asks for the driver and the name of the machine, unless they were
+// given, then for the credentials and the options of the driver, and returns
+// the answers, with why the driver refuses them, if it does.
+func (w *wizard) run(driverName, name string) (*wizardAnswers, error) {
+	if driverName == "" {
+		if len(w.drivers) == 0 {
+			return nil, errNoDriverPlugins
+		}
+
+		defaultDriver := w.drivers[0]
+		for _, d := range w.drivers {
+			if d == "virtualbox" {
+				defaultDriver = d
+			}
+		}
+
+		options := []drivers.Option{}
+		for _, d := range w.drivers {
+			options = append(options, drivers.Option{Value: d})
+		}
+
+		var err error
+		if driverName, err = w.choose("Driver", options, defaultDriver); err != nil {
+			return nil, err
+		}
+	}
+
+	if name == "" {
+		var err error
+		if name, err = w.askName(); err != nil {
+			return nil, err
+		}
+	} else if err := w.checkName(name); err != nil {
+		return nil, err
+	}
+
+	driver, err := w.loadDriver(driverName, name)
+	if err != nil {
+		return nil, err
+	}
+	if rpcd, ok := driver.(*rpcdriver.RpcClientDriver); ok {
+		defer rpcd.Close()
+	}
+
+	g := &guided{
+		wizard:  w,
+		driver:  driver,
+		machine: spec.Machine{Name: name, Driver: driverName, DriverOpts: map[string]interface{}{}},
+		flags:   map[string]mcnflag.Flag{},
+	}
+	for _, f := range driver.GetCreateFlags() {
+		g.mcnFlags = append(g.mcnFlags, f)
+		g.flags[f.String()] = f
+	}
+
+	switch {
+	case drivers.SupportsGuide(driver):
+		guideFlags := drivers.GetGuideFlags(driver)
+
+		if err := g.askCredentials(guideFlags.Credentials); err != nil {
+			return nil, err
+		}
+
+		for _, flag := range guideFlags.Options {
+			if err := g.askOption(flag); err != nil {
+				return nil, err
+			}
+		}
+	case drivers.SupportsDiscovery(driver):
+		// The credentials are those of the environment and the config
+		// file, which the driver may not need all of its flags for.
+		discoveryFlags := drivers.GetDiscoveryFlags(driver)
+
+		for _, flag := range []string{discoveryFlags.Region, discoveryFlags.Size, discoveryFlags.Image} {
+			if flag == "" {
+				continue
+			}
+			if err := g.askOption(flag); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		fmt.Fprintf(w.out, "The %s driver cannot list its options, see '%s create --driver %s --help' for them.\n", driverName, os.Args[0], driverName)
+	}
+
+	answers := g.answers()
+	if err := g.configure(); err != nil {
+		answers.Err = err
+	}
+
+	return answers, nil
+}
+
+// askName asks for the name of the machine until it is valid and free.
+func (w *wizard) askName() (string, error) {
+	for {
+		name, err := w.ask("Machine name", "default")
+		if err != nil {
+			return "", err
+		}
+
+		if err := w.checkName(name); err != nil {
+			fmt.Fprintln(w.out, err)
+			continue
+		}
+
+		return name, nil
+	}
+}
+
+func (w *wizard) checkName(name string) error {
+	if !host.ValidateHostName(name) {
+		return fmt.Errorf("Error: Invalid machine name %q, it may only contain letters, digits, dots and dashes", name)
+	}
+
+	exists, err := w.store.Exists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("Error: Machine %q already exists", name)
+	}
+
+	return nil
+}
+
+// ask asks the question, and returns the answer, or the default value when
+// none is given.
+func (w *wizard) ask(question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+
+	line, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", errInteractiveAborted
+		}
+		return "", err
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return defaultValue, nil
+}
+
+// askSecret asks for a credential, which mustn't be empty.
+func (w *wizard) askSecret(question string) (string, error) {
+	for {
+		var (
+			answer string
+			err    error
+		)
+		if w.readSecret != nil {
+			fmt.Fprintf(w.out, "%s: ", question)
+			answer, err = w.readSecret()
+		} else {
+			answer, err = w.ask(question, "")
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if answer = strings.TrimSpace(answer); answer != "" {
+			return answer, nil
+		}
+	}
+}
+
+// choose asks to choose one of the options, by number or value, and returns
+// its value.
+func (w *wizard) choose(question string, options []drivers.Option, defaultValue string) (string, error) {
+	fmt.Fprintf(w.out, "%s:\n", question)
+	for i, o := range options {
+		if o.Description != "" {
+			fmt.Fprintf(w.out, "  %2d) %s - %s\n", i+1, o.Value, o.Description)
+		} else {
+			fmt.Fprintf(w.out, "  %2d) %s\n", i+1, o.Value)
+		}
+	}
+
+	for {
+		answer, err := w.ask("Choose", defaultValue)
+		if err != nil {
+			return "", err
+		}
+
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return options[n-1].Value, nil
+		}
+		for _, o := range options {
+			if o.Value == answer {
+				return answer, nil
+			}
+		}
+
+		fmt.Fprintf(w.out, "%q is not one of the choices.\n", answer)
+	}
+}
+
+// confirm asks a yes or no question.
+func (w *wizard) confirm(question string) (bool, error) {
+	answer, err := w.ask(question+" (y/n)", "y")
+	if err != nil {
+		return false, err
+	}
+	return strings.Index(strings.ToLower(answer), "y") == 0, nil
+}
+
+// guided asks for the options of a driver which can guide the wizard,
+// configuring it with the answers given so far before checking or listing
+// anything.
+type guided struct {
+	*wizard
+
+	driver   drivers.Driver
+	machine  spec.Machine
+	mcnFlags []mcnflag.Flag
+	flags    map[string]mcnflag.Flag
+
+	// asked are the flags asked for, in order, and secret those of the
+	// credentials typed in
+	asked  []string
+	secret map[string]bool
+}
+
+// configure configures the driver with the answers, and the environment and
+// defaults for the other flags, as apply does with the options of a spec.
+func (g *guided) configure() error {
+	opts, err := specDriverOpts(g.machine, g.mcnFlags)
+	if err != nil {
+		return err
+	}
+	return g.driver.SetConfigFromFlags(opts)
+}
+
+// current returns the value the flag has before it is asked for: from its
+// environment variable, or else its default.
+func (g *guided) current(flag string) string {
+	f, ok := g.flags[flag]
+	if !ok {
+		return ""
+	}
+
+	if envVar := mcnFlagEnvVar(f); envVar != "" && os.Getenv(envVar) != "" {
+		return os.Getenv(envVar)
+	}
+
+	if f.Default() == nil {
+		return ""
+	}
+	return fmt.Sprint(f.Default())
+}
+
+func (g *guided) usage(flag string) string {
+	switch f := g.flags[flag].(type) {
+	case *mcnflag.StringFlag:
+		return f.Usage
+	case *mcnflag.IntFlag:
+		return f.Usage
+	}
+	return flag
+}
+
+// set records the answer for the flag.
+func (g *guided) set(flag, value string) {
+	if _, ok := g.machine.DriverOpts[flag]; !ok {
+		g.asked = append(g.asked, flag)
+	}
+	g.machine.DriverOpts[flag] = value
+}
+
+// askCredentials asks for the credentials until the driver validates them,
+// those set in the environment being only asked for again when they fail.
+func (g *guided) askCredentials(flags []string) error {
+	g.secret = map[string]bool{}
+
+	for attempt := 1; ; attempt++ {
+		for _, flag := range flags {
+			value := g.current(flag)
+			if _, answered := g.machine.DriverOpts[flag]; answered || value == "" || attempt > 1 {
+				typed, err := g.askSecret(g.usage(flag))
+				if err != nil {
+					return err
+				}
+				value = typed
+				g.secret[flag] = true
+			}
+			g.set(flag, value)
+		}
+
+		fmt.Fprintln(g.out, "Checking the credentials...")
+
+		err := g.configure()
+		if err == nil {
+			err = drivers.ValidateCredentials(g.driver)
+		}
+		if err == nil {
+			return nil
+		}
+
+		if attempt == maxCredentialAttempts {
+			return fmt.Errorf("Error validating the credentials: %s", err)
+		}
+		fmt.Fprintf(g.out, "Error validating the credentials: %s\n", err)
+	}
+}
+
+// askOption lets the flag be chosen among the values the driver lists, or be
+// typed in when it can't list them.
+func (g *guided) askOption(flag string) error {
+	defaultValue := g.current(flag)
+
+	options, err := g.listOptions(flag)
+	if err != nil || len(options) == 0 {
+		if err != nil {
+			fmt.Fprintf(g.out, "Error listing the values of --%s: %s\n", flag, err)
+		}
+		value, err := g.ask(g.usage(flag), defaultValue)
+		if err != nil {
+			return err
+		}
+		g.set(flag, value)
+		return nil
+	}
+
+	listed := false
+	for _, o := range options {
+		if o.Value == defaultValue {
+			listed = true
+		}
+	}
+	if !listed {
+		defaultValue = options[0].Value
+	}
+
+	value, err := g.choose(g.usage(flag), options, defaultValue)
+	if err != nil {
+		return err
+	}
+	g.set(flag, value)
+	return nil
+}
+
+func (g *guided) listOptions(flag string) ([]drivers.Option, error) {
+	if drivers.SupportsGuide(g.driver) {
+		if err := g.configure(); err != nil {
+			return nil, err
+		}
+		return drivers.ListOptions(g.driver, flag)
+	}
+
+	// Listing needs no more than the credentials, which the driver is
+	// given before it checks its other options.
+	if err := g.configure(); err != nil {
+		log.Debugf("Error configuring the driver to list the values of --%s: %s", flag, err)
+	}
+
+	discoveryFlags := drivers.GetDiscoveryFlags(g.driver)
+	region := g.value(discoveryFlags.Region)
+
+	switch flag {
+	case discoveryFlags.Region:
+		return drivers.ListRegions(g.driver)
+	case discoveryFlags.Image:
+		return drivers.ListImages(g.driver, region)
+	case discoveryFlags.Size:
+		return drivers.ListSizes(g.driver, region)
+	}
+	return nil, drivers.ErrDiscoveryNotImplemented
+}
+
+// value returns the answer for the flag, or else its current value.
+func (g *guided) value(flag string) string {
+	if value, ok := g.machine.DriverOpts[flag]; ok {
+		return fmt.Sprint(value)
+	}
+	return g.current(flag)
+}
+
+// answers returns the flags asked for which differ from what the create
+// command would use without them.
+func (g *guided) answers() *wizardAnswers {
+	answers := &wizardAnswers{Driver: g.machine.Driver, Name: g.machine.Name, mcnFlags: g.mcnFlags}
+
+	for _, flag := range g.asked {
+		value := fmt.Sprint(g.machine.DriverOpts[flag])
+		if value == g.current(flag) && !g.secret[flag] {
+			continue
+		}
+
+		f := wizardFlag{Name: flag, Value: value}
+		if sf, ok := g.flags[flag].(*mcnflag.StringFlag); ok && g.secret[flag] {
+			f.EnvVar = sf.EnvVar
+		}
+		answers.Flags = append(answers.Flags, f)
+	}
+
+	return answers
+}
@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/cli"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+// guideDriver accepts the token "good", and lists regions, and the sizes
+// available in its region.
+type guideDriver struct {
+	*fakedriver.Driver
+	token, region, size string
+}
+
+func (d *guideDriver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		&mcnflag.StringFlag{Name: "guide-token", Usage: "Guide token", EnvVar: "GUIDE_TEST_TOKEN"},
+		&mcnflag.StringFlag{Name: "guide-region", Usage: "Guide region", Value: "nyc3"},
+		&mcnflag.StringFlag{Name: "guide-size", Usage: "Guide size", Value: "small"},
+		&mcnflag.BoolFlag{Name: "guide-ipv6", Usage: "Guide IPv6"},
+	}
+}
+
+func (d *guideDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.token = flags.String("guide-token")
+	d.region = flags.String("guide-region")
+	d.size = flags.String("guide-size")
+	if d.token == "" {
+		return errors.New("guide driver requires the --guide-token option")
+	}
+	return nil
+}
+
+func (d *guideDriver) GuideFlags() drivers.GuideFlags {
+	return drivers.GuideFlags{
+		Credentials: []string{"guide-token"},
+		Options:     []string{"guide-region", "guide-size"},
+	}
+}
+
+func (d *guideDriver) ValidateCredentials() error {
+	if d.token != "good" {
+		return errors.New("401 Unauthorized")
+	}
+	return nil
+}
+
+func (d *guideDriver) ListOptions(flag string) ([]drivers.Option, error) {
+	switch flag {
+	case "guide-region":
+		return []drivers.Option{{Value: "nyc3", Description: "New York"}, {Value: "sfo2", Description: "San Francisco"}}, nil
+	case "guide-size":
+		if d.region == "sfo2" {
+			return []drivers.Option{{Value: "large", Description: "4 vCPU"}}, nil
+		}
+		return []drivers.Option{{Value: "small", Description: "1 vCPU"}, {Value: "large", Description: "4 vCPU"}}, nil
+	}
+	return nil, fmt.Errorf("cannot list --%s", flag)
+}
+
+func newTestWizard(store persist.Store, input string) (*wizard, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &wizard{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     out,
+		store:   store,
+		drivers: []string{"guide", "plain", "virtualbox"},
+		loadDriver: func(driverName, name string) (drivers.Driver, error) {
+			switch driverName {
+			case "guide":
+				return &guideDriver{Driver: &fakedriver.Driver{}}, nil
+			case "disco":
+				return &discoveryDriver{Driver: &fakedriver.Driver{}}, nil
+			}
+			return &fakedriver.Driver{}, nil
+		},
+	}, out
+}
+
+func TestWizardGuided(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	// The driver, the default name, a refused then a good token, the
+	// second region, and the default size, not listed in that region.
+	w, out := newTestWizard(store, "guide\n\nbad\ngood\n2\n\n")
+
+	answers, err := w.run("", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "guide", answers.Driver)
+	assert.Equal(t, "default", answers.Name)
+	assert.Equal(t, []wizardFlag{
+		{Name: "guide-token", Value: "good", EnvVar: "GUIDE_TEST_TOKEN"},
+		{Name: "guide-region", Value: "sfo2"},
+		{Name: "guide-size", Value: "large"},
+	}, answers.Flags)
+
+	assert.Contains(t, out.String(), "Driver:\n   1) guide\n   2) plain\n   3) virtualbox\nChoose [virtualbox]: ")
+	assert.Contains(t, out.String(), "Error validating the credentials: 401 Unauthorized\n")
+	assert.Contains(t, out.String(), "Guide size:\n   1) large - 4 vCPU\nChoose [large]: ")
+
+	assert.Equal(t, []string{"create", "--driver", "guide", "--guide-token=good", "--guide-region=sfo2", "--guide-size=large", "default"}, answers.args())
+	assert.Equal(t, `docker-machine create --driver guide --guide-token "$GUIDE_TEST_TOKEN" --guide-region sfo2 --guide-size large default`, answers.command("docker-machine"))
+	assert.Equal(t, []string{"GUIDE_TEST_TOKEN"}, answers.secretEnvVars())
+
+	// The create command runs with the flags of create and of the driver.
+	app := cli.NewApp()
+	c, err := answers.context(cli.NewContext(app, flag.NewFlagSet("machine", flag.ContinueOnError), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "guide", c.String("driver"))
+	assert.Equal(t, "good", c.String("guide-token"))
+	assert.Equal(t, "sfo2", c.String("guide-region"))
+	assert.False(t, c.Bool("guide-ipv6"))
+	assert.Equal(t, "https://get.docker.com", c.String("engine-install-url"))
+	assert.Equal(t, cli.Args{"default"}, c.Args())
+}
+
+func TestWizardCredentialsFromEnvironment(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	os.Setenv("GUIDE_TEST_TOKEN", "good")
+	defer os.Unsetenv("GUIDE_TEST_TOKEN")
+
+	// The defaults are kept, and left out of the command.
+	w, out := newTestWizard(store, "\n\n")
+
+	answers, err := w.run("guide", "dev")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "dev", answers.Name)
+	assert.Empty(t, answers.Flags)
+	assert.NotContains(t, out.String(), "Guide token")
+}
+
+func TestWizardRefusedCredentials(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	w, _ := newTestWizard(store, "bad\nworse\nworst\n")
+
+	_, err := w.run("guide", "dev")
+	assert.EqualError(t, err, "Error validating the credentials: 401 Unauthorized")
+}
+
+func TestWizardDiscovery(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	os.Setenv("DISCO_TEST_KEY", "secret")
+	defer os.Unsetenv("DISCO_TEST_KEY")
+
+	// The region, size and image are listed without the subnet, which
+	// the driver refuses the answers without.
+	w, out := newTestWizard(store, "eu-west\n\n\n")
+
+	answers, err := w.run("disco", "dev")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []wizardFlag{{Name: "disco-region", Value: "eu-west"}}, answers.Flags)
+	assert.EqualError(t, answers.Err, "disco driver requires the --disco-subnet option")
+
+	// Sizes can't be listed in that region, and it has no images: they are
+	// typed in, their defaults being kept.
+	assert.Contains(t, out.String(), "Error listing the values of --disco-size: unknown region eu-west\nDisco size [small]: ")
+	assert.Contains(t, out.String(), "Disco image [ubuntu]: ")
+}
+
+func TestWizardName(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	assert.NoError(t, store.Save(&host.Host{Name: "taken", DriverName: "fakedriver", Driver: &fakedriver.Driver{}}))
+
+	w, out := newTestWizard(store, "taken\nin valid\ndev\n")
+
+	answers, err := w.run("plain", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "dev", answers.Name)
+	assert.Empty(t, answers.Flags)
+	assert.Contains(t, out.String(), `Error: Machine "taken" already exists`)
+	assert.Contains(t, out.String(), `Error: Invalid machine name "in valid"`)
+	assert.Contains(t, out.String(), "The plain driver cannot list its options")
+
+	_, err = w.run("plain", "taken")
+	assert.EqualError(t, err, `Error: Machine "taken" already exists`)
+}
+
+func TestWizardAborted(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	w, _ := newTestWizard(store, "")
+
+	_, err := w.run("", "")
+	assert.Equal(t, errInteractiveAborted, err)
+}
+
+func TestWizardNoDrivers(t *testing.T) {
+	store, cleanup := watchTestStore(t)
+	defer cleanup()
+
+	w, _ := newTestWizard(store, "")
+	w.drivers = nil
+
+	_, err := w.run("", "")
+	assert.Equal(t, errNoDriverPlugins, err)
+}
+
+func TestParseInteractiveArgs(t *testing.T) {
+	driverName, name, err := parseInteractiveArgs([]string{"--interactive"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", driverName)
+	assert.Equal(t, "", name)
+
+	driverName, name, err = parseInteractiveArgs([]string{"-d", "digitalocean", "--interactive", "dev"})
+	assert.NoError(t, err)
+	assert.Equal(t, "digitalocean", driverName)
+	assert.Equal(t, "dev", name)
+
+	driverName, _, err = parseInteractiveArgs([]string{"--interactive", "--driver=amazonec2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "amazonec2", driverName)
+
+	_, _, err = parseInteractiveArgs([]string{"--interactive", "--engine-label", "a=b"})
+	assert.Equal(t, errInteractiveArgs, err)
+
+	_, _, err = parseInteractiveArgs([]string{"--interactive", "one", "two"})
+	assert.Equal(t, errInteractiveArgs, err)
+
+	_, _, err = parseInteractiveArgs([]string{"--interactive", "--driver"})
+	assert.EqualError(t, err, "Error: --driver needs a driver name")
+}
+
+func TestQuoteArg(t *testing.T) {
+	assert.Equal(t, "ubuntu-22-04-x64", quoteArg("ubuntu-22-04-x64"))
+	assert.Equal(t, "'my image'", quoteArg("my image"))
+	assert.Equal(t, `'it'\''s'`, quoteArg("it's"))
+}
@@ -22,19 +22,89 @@ _docker-machine-active() {
     fi
 }
 
+_docker-machine-apply() {
+    case "${prev}" in
+        -f|--file)
+            _filedir
+            ;;
+        *)
+            COMPREPLY=($(compgen -W "--file --prune --dry-run --parallel --help" -- "${cur}"))
+    esac
+}
+
 _docker-machine-config() {
-    if [[ "${cur}" == -* ]]; then
-        COMPREPLY=($(compgen -W "--swarm --help" -- "${cur}"))
-    else
-        COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
-    fi
+    case "${prev}" in
+        defaults)
+            COMPREPLY=($(compgen -W "show" -- "${cur}"))
+            ;;
+        *)
+            if [[ "${cur}" == -* ]]; then
+                COMPREPLY=($(compgen -W "--swarm --help" -- "${cur}"))
+            else
+                COMPREPLY=($(compgen -W "defaults $(docker-machine ls -q)" -- "${cur}"))
+            fi
+    esac
+}
+
+# __docker_machine_create_option prints the value of an option given to create
+__docker_machine_create_option() {
+    local i name
+    for (( i=1; i < ${cword}; ++i)); do
+        for name in "$@"; do
+            if [[ ${words[i]} == "${name}" ]]; then
+                echo "${words[i+1]}"
+                return
+            elif [[ ${words[i]} == "${name}="* ]]; then
+                echo "${words[i]#*=}"
+                return
+            fi
+        done
+    done
 }
 
 _docker-machine-create() {
+    # the regions, images and sizes are listed by the drivers which can
+    local driver kind region
+    driver=$(__docker_machine_create_option --driver -d)
+    case "${prev}" in
+        --*-region)
+            kind=regions
+            ;;
+        --*-image|--*-ami)
+            kind=images
+            ;;
+        --*-size|--*-instance-type)
+            kind=sizes
+            ;;
+    esac
+    if [[ -n ${driver} && -n ${kind} ]]; then
+        region=$(__docker_machine_create_option "--${driver}-region")
+        COMPREPLY=($(compgen -W "$(docker-machine driver options --kind "${kind}" ${region:+--region "${region}"} --quiet "${driver}" 2>/dev/null)" -- "${cur}"))
+        return
+    fi
+
     # cheating, b/c there are approximately one zillion options to create
     COMPREPLY=($(compgen -W "$(docker-machine create --help | grep '^   -' | sed 's/^   //; s/[^a-z0-9-].*$//')" -- "${cur}"))
 }
 
+_docker-machine-driver() {
+    case "${prev}" in
+        driver)
+            COMPREPLY=($(compgen -W "inspect options" -- "${cur}"))
+            ;;
+        --kind)
+            COMPREPLY=($(compgen -W "regions images sizes" -- "${cur}"))
+            ;;
+        --format|-f|--region)
+            COMPREPLY=()
+            ;;
+        *)
+            if [[ "${cur}" == -* ]]; then
+                COMPREPLY=($(compgen -W "--format --region --kind --quiet --help" -- "${cur}"))
+            fi
+    esac
+}
+
 _docker-machine-env() {
     case "${prev}" in
         --shell)
@@ -59,6 +129,14 @@ _docker-machine-use() {
     fi
 }
 
+_docker-machine-healthcheck() {
+    if [[ "${cur}" == -* ]]; then
+        COMPREPLY=($(compgen -W "--all -a --heal --help" -- "${cur}"))
+    else
+        COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
+    fi
+}
+
 _docker-machine-inspect() {
     case "${prev}" in
         -f|--format)
@@ -126,6 +204,25 @@ _docker-machine-rm() {
     fi
 }
 
+_docker-machine-rsync() {
+    if [[ "${cur}" == -* ]]; then
+        COMPREPLY=($(compgen -W "--help --delete --exclude --install-rsync --watch --watch-interval" -- "${cur}"))
+    else
+        _filedir
+        COMPREPLY=($(compgen -W "$(docker-machine ls -q | sed 's/$/:/')" -- "${cur}") "${COMPREPLY[@]}")
+    fi
+}
+
+_docker-machine-snapshot() {
+    if [[ "${cur}" == -* ]]; then
+        COMPREPLY=($(compgen -W "--help" -- "${cur}"))
+    elif [[ "${prev}" == snapshot ]]; then
+        COMPREPLY=($(compgen -W "create ls restore rm" -- "${cur}"))
+    else
+        COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
+    fi
+}
+
 _docker-machine-ssh() {
     if [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "--help" -- "${cur}"))
@@ -136,7 +233,7 @@ _docker-machine-ssh() {
 
 _docker-machine-scp() {
     if [[ "${cur}" == -* ]]; then
-        COMPREPLY=($(compgen -W "--help --recursive" -- "${cur}"))
+        COMPREPLY=($(compgen -W "--help --recursive --compress --resume --quiet" -- "${cur}"))
     else
         _filedir
         # It would be really nice to ssh to the machine and ls to complete
@@ -145,7 +242,17 @@ _docker-machine-scp() {
     fi
 }
 
-_docker-machine-start() {
+_docker-machine-metrics() {
+    case "${prev}" in
+        --listen)
+            COMPREPLY=()
+            ;;
+        *)
+            COMPREPLY=($(compgen -W "--listen --help" -- "${cur}"))
+    esac
+}
+
+_docker-machine-pause() {
     if [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "--help" -- "${cur}"))
     else
@@ -153,7 +260,37 @@ _docker-machine-start() {
     fi
 }
 
-_docker-machine-status() {
+_docker-machine-profile() {
+    case "${prev}" in
+        profile)
+            COMPREPLY=($(compgen -W "create ls rm" -- "${cur}"))
+            ;;
+        rm)
+            COMPREPLY=($(compgen -W "$(docker-machine profile ls | tail -n +2 | awk '{print $1}')" -- "${cur}"))
+            ;;
+        *)
+            COMPREPLY=($(compgen -W "--help" -- "${cur}"))
+    esac
+}
+
+_docker-machine-provision() {
+    case "${prev}" in
+        --from)
+            COMPREPLY=($(compgen -W "ip-assigned ssh-ready provisioned certs" -- "${cur}"))
+            ;;
+        --timeout)
+            COMPREPLY=()
+            ;;
+        *)
+            if [[ "${cur}" == -* ]]; then
+                COMPREPLY=($(compgen -W "--from --timeout --help" -- "${cur}"))
+            else
+                COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
+            fi
+    esac
+}
+
+_docker-machine-resume() {
     if [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "--help" -- "${cur}"))
     else
@@ -161,7 +298,7 @@ _docker-machine-status() {
     fi
 }
 
-_docker-machine-stop() {
+_docker-machine-start() {
     if [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "--help" -- "${cur}"))
     else
@@ -169,7 +306,7 @@ _docker-machine-stop() {
     fi
 }
 
-_docker-machine-upgrade() {
+_docker-machine-status() {
     if [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "--help" -- "${cur}"))
     else
@@ -177,6 +314,51 @@ _docker-machine-upgrade() {
     fi
 }
 
+_docker-machine-stop() {
+    if [[ "${cur}" == -* ]]; then
+        COMPREPLY=($(compgen -W "--help" -- "${cur}"))
+    else
+        COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
+    fi
+}
+
+_docker-machine-swarm() {
+    case "${prev}" in
+        swarm)
+            COMPREPLY=($(compgen -W "init join leave rotate-tokens" -- "${cur}"))
+            ;;
+        --role)
+            COMPREPLY=($(compgen -W "manager worker" -- "${cur}"))
+            ;;
+        --advertise-addr)
+            COMPREPLY=()
+            ;;
+        *)
+            if [[ "${cur}" == -* ]]; then
+                COMPREPLY=($(compgen -W "--manager --role --advertise-addr --force --help" -- "${cur}"))
+            else
+                COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
+            fi
+    esac
+}
+
+_docker-machine-upgrade() {
+    case "${prev}" in
+        --channel)
+            COMPREPLY=($(compgen -W "stable test experimental" -- "${cur}"))
+            ;;
+        --engine-version|--timeout)
+            COMPREPLY=()
+            ;;
+        *)
+            if [[ "${cur}" == -* ]]; then
+                COMPREPLY=($(compgen -W "--engine-version --channel --timeout --help" -- "${cur}"))
+            else
+                COMPREPLY=($(compgen -W "$(docker-machine ls -q)" -- "${cur}"))
+            fi
+    esac
+}
+
 _docker-machine-url() {
     if [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "--help" -- "${cur}"))
@@ -198,6 +380,8 @@ _docker-machine-docker-machine() {
         _filedir
     elif [[ " ${wants_dir[*]} " =~ " ${prev} " ]]; then
         _filedir -d
+    elif [[ ${prev} == --log-format ]]; then
+        COMPREPLY=($(compgen -W "text json" -- "${cur}"))
     elif [[ "${cur}" == -* ]]; then
         COMPREPLY=($(compgen -W "${flags[*]} ${wants_dir[*]} ${wants_file[*]}" -- "${cur}"))
     else
@@ -207,9 +391,9 @@ _docker-machine-docker-machine() {
 
 _docker-machine() {
     COMPREPLY=()
-    local commands=(active config create env inspect ip kill ls regenerate-certs restart rm ssh scp start status stop upgrade url help)
+    local commands=(active apply config create driver env healthcheck inspect ip kill ls metrics pause profile provision regenerate-certs restart resume rm rsync snapshot ssh scp start status stop swarm upgrade url help)
 
-    local flags=(--debug --native-ssh --help --version)
+    local flags=(--debug --log-format --native-ssh --help --version)
     local wants_dir=(--storage-path)
     local wants_file=(--tls-ca-cert --tls-ca-key --tls-client-cert --tls-client-key)
 
@@ -225,10 +409,10 @@ _docker-machine() {
 
     for (( i=1; i < ${cword}; ++i)); do
         local word=${words[i]}
-        if [[ " ${wants_file[*]} ${wants_dir[*]} " =~ " ${word} " ]]; then
+        if [[ " ${wants_file[*]} ${wants_dir[*]} --log-format " =~ " ${word} " ]]; then
             # skip the next option
             (( ++i ))
-        elif [[ " ${commands[*]} " =~ " ${word} " ]]; then
+        elif [[ ${command} == docker-machine && " ${commands[*]} " =~ " ${word} " ]]; then
             command=${word}
         fi
     done
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -28,10 +29,15 @@ const (
 	defaultRegion            = "us-east-1"
 	defaultInstanceType      = "t2.micro"
 	defaultRootSize          = 16
+	volumeDeviceName         = "/dev/sdf"
 	defaultZone              = "a"
 	defaultSecurityGroup     = machineSecurityGroupName
 	defaultSSHUser           = "ubuntu"
 	defaultSpotPrice         = "0.50"
+	defaultSpotRequestType   = "one-time"
+	defaultSpotTimeout       = 300
+	defaultMetadataToken     = "optional"
+	defaultMetadataHopLimit  = 1
 )
 
 var (
@@ -45,6 +51,8 @@ type Driver struct {
 	AccessKey           string
 	SecretKey           string
 	SessionToken        string
+	Profile             string
+	RoleArn             string
 	Region              string
 	AMI                 string
 	SSHKeyID            int
@@ -63,9 +71,21 @@ type Driver struct {
 	keyPath             string
 	RequestSpotInstance bool
 	SpotPrice           string
+	SpotRequestType     string
+	SpotTimeout         int
+	SpotFallback        bool
+	SpotRequestId       string
 	PrivateIPOnly       bool
 	UsePrivateIP        bool
 	Monitoring          bool
+	MetadataToken       string
+	MetadataHopLimit    int
+
+	// credentials caches the credentials of the API calls.
+	credentials *amz.Credentials
+
+	// endpoint overrides the EC2 endpoint of the region, for tests.
+	endpoint string
 }
 
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
@@ -85,6 +105,16 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "AWS Session Token",
 			EnvVar: "AWS_SESSION_TOKEN",
 		},
+		mcnflag.StringFlag{
+			Name:   "amazonec2-profile",
+			Usage:  "AWS shared config profile, used when no access key is given",
+			EnvVar: "AWS_PROFILE",
+		},
+		mcnflag.StringFlag{
+			Name:   "amazonec2-role-arn",
+			Usage:  "ARN of an AWS IAM role to assume",
+			EnvVar: "AWS_ROLE_ARN",
+		},
 		mcnflag.StringFlag{
 			Name:   "amazonec2-ami",
 			Usage:  "AWS machine image",
@@ -141,15 +171,33 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  defaultSSHUser,
 			EnvVar: "AWS_SSH_USER",
 		},
+		mcnflag.BoolFlag{
+			Name:  "amazonec2-spot",
+			Usage: "Set this flag to request a spot instance",
+		},
 		mcnflag.BoolFlag{
 			Name:  "amazonec2-request-spot-instance",
-			Usage: "Set this flag to request spot instance",
+			Usage: "Deprecated, use --amazonec2-spot",
 		},
 		mcnflag.StringFlag{
 			Name:  "amazonec2-spot-price",
-			Usage: "AWS spot instance bid price (in dollar)",
+			Usage: "AWS spot instance maximum price (in dollar per hour)",
 			Value: defaultSpotPrice,
 		},
+		mcnflag.StringFlag{
+			Name:  "amazonec2-spot-request-type",
+			Usage: "AWS spot instance request type, one-time or persistent",
+			Value: defaultSpotRequestType,
+		},
+		mcnflag.IntFlag{
+			Name:  "amazonec2-spot-timeout",
+			Usage: "Seconds to wait for the spot instance request to be fulfilled",
+			Value: defaultSpotTimeout,
+		},
+		mcnflag.BoolFlag{
+			Name:  "amazonec2-spot-fallback",
+			Usage: "Launch an on-demand instance if the spot instance request cannot be fulfilled",
+		},
 		mcnflag.StringFlag{
 			Name:  "amazonec2-private-address-only",
 			Usage: "Only use a private IP address",
@@ -158,10 +206,25 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:  "amazonec2-use-private-address",
 			Usage: "Force the usage of private IP address",
 		},
+		mcnflag.StringFlag{
+			Name:   "amazonec2-ssh-bastion",
+			Usage:  "Jump host to reach the instance through over SSH, as [user@]host[:port], e.g. with --amazonec2-private-address-only",
+			EnvVar: "AWS_SSH_BASTION",
+		},
 		mcnflag.BoolFlag{
 			Name:  "amazonec2-monitoring",
 			Usage: "Set this flag to enable CloudWatch monitoring",
 		},
+		mcnflag.StringFlag{
+			Name:  "amazonec2-metadata-token",
+			Usage: "Whether the instance metadata service of the instance requires session tokens (IMDSv2), optional or required",
+			Value: defaultMetadataToken,
+		},
+		mcnflag.IntFlag{
+			Name:  "amazonec2-metadata-token-response-hop-limit",
+			Usage: "Number of network hops the session tokens of the instance metadata service may travel (1-64)",
+			Value: defaultMetadataHopLimit,
+		},
 	}
 }
 
@@ -176,6 +239,10 @@ func NewDriver(hostName, storePath string) drivers.Driver {
 		Zone:              defaultZone,
 		SecurityGroupName: defaultSecurityGroup,
 		SpotPrice:         defaultSpotPrice,
+		SpotRequestType:   defaultSpotRequestType,
+		SpotTimeout:       defaultSpotTimeout,
+		MetadataToken:     defaultMetadataToken,
+		MetadataHopLimit:  defaultMetadataHopLimit,
 		BaseDriver: &drivers.BaseDriver{
 			SSHUser:     defaultSSHUser,
 			MachineName: hostName,
@@ -198,10 +265,15 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AccessKey = flags.String("amazonec2-access-key")
 	d.SecretKey = flags.String("amazonec2-secret-key")
 	d.SessionToken = flags.String("amazonec2-session-token")
+	d.Profile = flags.String("amazonec2-profile")
+	d.RoleArn = flags.String("amazonec2-role-arn")
 	d.Region = region
 	d.AMI = image
-	d.RequestSpotInstance = flags.Bool("amazonec2-request-spot-instance")
+	d.RequestSpotInstance = flags.Bool("amazonec2-spot") || flags.Bool("amazonec2-request-spot-instance")
 	d.SpotPrice = flags.String("amazonec2-spot-price")
+	d.SpotRequestType = flags.String("amazonec2-spot-request-type")
+	d.SpotTimeout = flags.Int("amazonec2-spot-timeout")
+	d.SpotFallback = flags.Bool("amazonec2-spot-fallback")
 	d.InstanceType = flags.String("amazonec2-instance-type")
 	d.VpcId = flags.String("amazonec2-vpc-id")
 	d.SubnetId = flags.String("amazonec2-subnet-id")
@@ -213,20 +285,59 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	if bastion := flags.String("amazonec2-ssh-bastion"); bastion != "" {
+		d.SSHBastion = bastion
+	}
 	d.SSHUser = flags.String("amazonec2-ssh-user")
 	d.SSHPort = 22
 	d.PrivateIPOnly = flags.Bool("amazonec2-private-address-only")
 	d.UsePrivateIP = flags.Bool("amazonec2-use-private-address")
 	d.Monitoring = flags.Bool("amazonec2-monitoring")
+	d.MetadataToken = flags.String("amazonec2-metadata-token")
+	d.MetadataHopLimit = flags.Int("amazonec2-metadata-token-response-hop-limit")
+	d.credentials = nil
 
-	if d.AccessKey == "" {
+	volume, err := drivers.ParseVolume(flags.String("attach-volume"))
+	if err != nil {
+		return err
+	}
+	d.AttachVolume = volume
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+
+	if d.AccessKey == "" && d.SecretKey != "" {
 		return fmt.Errorf("amazonec2 driver requires the --amazonec2-access-key option")
 	}
 
-	if d.SecretKey == "" {
+	if d.AccessKey != "" && d.SecretKey == "" {
 		return fmt.Errorf("amazonec2 driver requires the --amazonec2-secret-key option")
 	}
 
+	if d.MetadataToken != "optional" && d.MetadataToken != "required" {
+		return fmt.Errorf("amazonec2 driver requires --amazonec2-metadata-token to be optional or required")
+	}
+
+	if d.MetadataHopLimit < 1 || d.MetadataHopLimit > 64 {
+		return fmt.Errorf("amazonec2 driver requires --amazonec2-metadata-token-response-hop-limit to be between 1 and 64")
+	}
+
+	if d.SpotRequestType != "one-time" && d.SpotRequestType != "persistent" {
+		return fmt.Errorf("amazonec2 driver requires --amazonec2-spot-request-type to be one-time or persistent")
+	}
+
+	if d.SSHBastion != "" {
+		if _, err := ssh.ParseBastion(d.SSHBastion); err != nil {
+			return err
+		}
+	}
+
 	if d.SubnetId == "" && d.VpcId == "" {
 		return fmt.Errorf("amazonec2 driver requires either the --amazonec2-subnet-id or --amazonec2-vpc-id option")
 	}
@@ -252,7 +363,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	if d.isSwarmMaster() {
 		u, err := url.Parse(d.SwarmHost)
 		if err != nil {
-			return fmt.Errorf("error parsing swarm host: %s", err)
+			return fmt.Errorf("error parsing swarm host: %w", err)
 		}
 
 		parts := strings.Split(u.Host, ":")
@@ -271,6 +382,200 @@ func (d *Driver) DriverName() string {
 	return driverName
 }
 
+// Capabilities adds the spot instances of the driver to those of its
+// optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.Spot = true
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the instance type of the machine and its EBS volumes.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	pricing := drivers.Pricing{
+		Region:       d.Region,
+		InstanceType: d.InstanceType,
+		Spot:         d.RequestSpotInstance,
+	}
+
+	for _, bdm := range d.blockDeviceMappings() {
+		pricing.Storage = append(pricing.Storage, drivers.PricedStorage{Type: bdm.VolumeType, Size: int(bdm.VolumeSize)})
+	}
+
+	return pricing, nil
+}
+
+// Plan returns the resources Create would allocate, from the configuration
+// only: the existing key pairs, subnets and security groups aren't looked up.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	instance := fmt.Sprintf("%s from %s in %s%s", d.InstanceType, d.AMI, d.Region, d.Zone)
+	if d.RequestSpotInstance {
+		instance = fmt.Sprintf("%s, as a %s spot instance at up to $%s an hour", instance, d.SpotRequestType, d.SpotPrice)
+	}
+
+	resources := []drivers.PlannedResource{
+		{Kind: "instance", Description: instance},
+	}
+
+	network := fmt.Sprintf("default subnet of %s%s", d.Region, d.Zone)
+	if d.SubnetId != "" {
+		network = "subnet " + d.SubnetId
+	}
+	if d.VpcId != "" {
+		network += " in " + d.VpcId
+	}
+	if d.PrivateIPOnly {
+		network += ", with no public IP address"
+	}
+	resources = append(resources, drivers.PlannedResource{Kind: "network", Description: network})
+
+	resources = append(resources, drivers.PlannedResource{Kind: "key-pair", Description: d.MachineName})
+
+	for _, perm := range d.configureSecurityGroupPermissions(&amz.SecurityGroup{}) {
+		resources = append(resources, drivers.PlannedResource{
+			Kind:        "security-group",
+			Description: fmt.Sprintf("%s, allowing %s port %d from %s", d.SecurityGroupName, perm.IpProtocol, perm.FromPort, perm.IpRange),
+		})
+	}
+
+	for _, bdm := range d.blockDeviceMappings() {
+		resources = append(resources, drivers.PlannedResource{
+			Kind:        "disk",
+			Description: fmt.Sprintf("%dGB %s EBS volume on %s", bdm.VolumeSize, bdm.VolumeType, bdm.DeviceName),
+		})
+	}
+
+	if d.IamInstanceProfile != "" {
+		resources = append(resources, drivers.PlannedResource{Kind: "iam-profile", Description: d.IamInstanceProfile})
+	}
+
+	return resources, nil
+}
+
+// TerraformResources returns the instance, key pair and security group
+// Create made for the host.
+func (d *Driver) TerraformResources() ([]drivers.TerraformResource, error) {
+	publicKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return nil, err
+	}
+
+	keyPair := drivers.TerraformResource{
+		Type: "aws_key_pair",
+		Name: drivers.TerraformName(d.KeyName),
+		ID:   d.KeyName,
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "key_name", Value: drivers.TerraformString(d.KeyName)},
+			{Name: "public_key", Value: drivers.TerraformString(strings.TrimSpace(string(publicKey)))},
+		},
+	}
+
+	instance := drivers.TerraformResource{
+		Type: "aws_instance",
+		Name: drivers.TerraformName(d.MachineName),
+		ID:   d.InstanceId,
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "ami", Value: drivers.TerraformString(d.AMI)},
+			{Name: "instance_type", Value: drivers.TerraformString(d.InstanceType)},
+			{Name: "availability_zone", Value: drivers.TerraformString(d.Region + d.Zone)},
+			{Name: "subnet_id", Value: drivers.TerraformString(d.SubnetId)},
+			{Name: "key_name", Value: drivers.TerraformReference(keyPair, "key_name")},
+		},
+	}
+
+	resources := []drivers.TerraformResource{keyPair}
+
+	if d.SecurityGroupId != "" {
+		// The description is escaped twice by CreateSecurityGroup, and
+		// changing it would have Terraform replace the group.
+		securityGroup := drivers.TerraformResource{
+			Type: "aws_security_group",
+			Name: drivers.TerraformName(d.SecurityGroupName),
+			ID:   d.SecurityGroupId,
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "name", Value: drivers.TerraformString(d.SecurityGroupName)},
+				{Name: "description", Value: drivers.TerraformString("Docker+Machine")},
+				{Name: "vpc_id", Value: drivers.TerraformString(d.VpcId)},
+			},
+		}
+
+		for _, perm := range d.configureSecurityGroupPermissions(&amz.SecurityGroup{}) {
+			securityGroup.Blocks = append(securityGroup.Blocks, drivers.TerraformBlock{
+				Type: "ingress",
+				Attributes: []drivers.TerraformAttribute{
+					{Name: "protocol", Value: drivers.TerraformString(perm.IpProtocol)},
+					{Name: "from_port", Value: drivers.TerraformNumber(int64(perm.FromPort))},
+					{Name: "to_port", Value: drivers.TerraformNumber(int64(perm.ToPort))},
+					{Name: "cidr_blocks", Value: drivers.TerraformList(drivers.TerraformString(perm.IpRange))},
+				},
+			})
+		}
+
+		securityGroup.Blocks = append(securityGroup.Blocks, drivers.TerraformBlock{
+			Type: "egress",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "protocol", Value: drivers.TerraformString("-1")},
+				{Name: "from_port", Value: drivers.TerraformNumber(0)},
+				{Name: "to_port", Value: drivers.TerraformNumber(0)},
+				{Name: "cidr_blocks", Value: drivers.TerraformList(drivers.TerraformString(ipRange))},
+			},
+		})
+
+		resources = append(resources, securityGroup)
+		instance.Attributes = append(instance.Attributes, drivers.TerraformAttribute{
+			Name:  "vpc_security_group_ids",
+			Value: drivers.TerraformList(drivers.TerraformReference(securityGroup, "id")),
+		})
+	}
+
+	if d.PrivateIPOnly {
+		instance.Attributes = append(instance.Attributes, drivers.TerraformAttribute{Name: "associate_public_ip_address", Value: drivers.TerraformBool(false)})
+	}
+	if d.IamInstanceProfile != "" {
+		instance.Attributes = append(instance.Attributes, drivers.TerraformAttribute{Name: "iam_instance_profile", Value: drivers.TerraformString(d.IamInstanceProfile)})
+	}
+	if d.Monitoring {
+		instance.Attributes = append(instance.Attributes, drivers.TerraformAttribute{Name: "monitoring", Value: drivers.TerraformBool(true)})
+	}
+	tags := d.resourceTags()
+	tags["Name"] = d.MachineName
+	instance.Attributes = append(instance.Attributes, drivers.TerraformAttribute{
+		Name:  "tags",
+		Value: drivers.TerraformMap(tags),
+	})
+
+	for _, bdm := range d.blockDeviceMappings() {
+		block := drivers.TerraformBlock{
+			Type: "root_block_device",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "volume_size", Value: drivers.TerraformNumber(bdm.VolumeSize)},
+				{Name: "volume_type", Value: drivers.TerraformString(bdm.VolumeType)},
+				{Name: "delete_on_termination", Value: drivers.TerraformBool(bdm.DeleteOnTermination)},
+			},
+		}
+		if bdm.DeviceName == volumeDeviceName {
+			block.Type = "ebs_block_device"
+			block.Attributes = append([]drivers.TerraformAttribute{
+				{Name: "device_name", Value: drivers.TerraformString(bdm.DeviceName)},
+			}, block.Attributes...)
+		}
+		instance.Blocks = append(instance.Blocks, block)
+	}
+
+	if options := d.metadataOptions(); options != nil {
+		instance.Blocks = append(instance.Blocks, drivers.TerraformBlock{
+			Type: "metadata_options",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "http_tokens", Value: drivers.TerraformString(options.HttpTokens)},
+				{Name: "http_put_response_hop_limit", Value: drivers.TerraformNumber(int64(options.HttpPutResponseHopLimit))},
+			},
+		})
+	}
+
+	return append(resources, instance), nil
+}
+
 func (d *Driver) checkPrereqs() error {
 	// check for existing keypair
 	key, err := d.getClient().GetKeyPair(d.MachineName)
@@ -345,47 +650,27 @@ func (d *Driver) Create() error {
 	log.Infof("Launching instance...")
 
 	if err := d.createKeyPair(); err != nil {
-		return fmt.Errorf("unable to create key pair: %s", err)
+		return fmt.Errorf("unable to create key pair: %w", err)
 	}
 
 	if err := d.configureSecurityGroup(d.SecurityGroupName); err != nil {
 		return err
 	}
 
-	bdm := &amz.BlockDeviceMapping{
-		DeviceName:          "/dev/sda1",
-		VolumeSize:          d.RootSize,
-		DeleteOnTermination: true,
-		VolumeType:          "gp2",
-	}
+	bdms := d.blockDeviceMappings()
 
 	log.Debugf("launching instance in subnet %s", d.SubnetId)
 	var instance amz.EC2Instance
 	if d.RequestSpotInstance {
-		spotInstanceRequestId, err := d.getClient().RequestSpotInstances(d.AMI, d.InstanceType, d.Zone, 1, d.SecurityGroupId, d.KeyName, d.SubnetId, bdm, d.IamInstanceProfile, d.SpotPrice, d.Monitoring)
+		inst, err := d.launchSpotInstance(bdms)
 		if err != nil {
-			return fmt.Errorf("Error request spot instance: %s", err)
-		}
-		var instanceId string
-		var spotInstanceRequestStatus string
-		log.Info("Waiting for spot instance...")
-		// check until fulfilled
-		for instanceId == "" {
-			time.Sleep(time.Second * 5)
-			spotInstanceRequestStatus, instanceId, err = d.getClient().DescribeSpotInstanceRequests(spotInstanceRequestId)
-			if err != nil {
-				return fmt.Errorf("Error describe spot instance request: %s", err)
-			}
-			log.Debugf("spot instance request status: %s", spotInstanceRequestStatus)
-		}
-		instance, err = d.getClient().GetInstance(instanceId)
-		if err != nil {
-			return fmt.Errorf("Error get instance: %s", err)
+			return err
 		}
+		instance = inst
 	} else {
-		inst, err := d.getClient().RunInstance(d.AMI, d.InstanceType, d.Zone, 1, 1, d.SecurityGroupId, d.KeyName, d.SubnetId, bdm, d.IamInstanceProfile, d.PrivateIPOnly, d.Monitoring)
+		inst, err := d.launchOnDemandInstance(bdms)
 		if err != nil {
-			return fmt.Errorf("Error launching instance: %s", err)
+			return err
 		}
 		instance = inst
 	}
@@ -410,17 +695,235 @@ func (d *Driver) Create() error {
 	)
 
 	log.Debug("Settings tags for instance")
-	tags := map[string]string{
-		"Name": d.MachineName,
+	tags := d.resourceTags()
+	tags["Name"] = d.MachineName
+	if d.SpotRequestId != "" {
+		tags["docker-machine-spot-request"] = d.SpotRequestId
 	}
 
 	if err := d.getClient().CreateTags(d.InstanceId, tags); err != nil {
 		return err
 	}
 
+	return d.tagVolumes()
+}
+
+// resourceTags returns a copy of the tags given with --tag, for the
+// resources created for the host.
+func (d *Driver) resourceTags() map[string]string {
+	tags := map[string]string{}
+	for k, v := range d.ResourceTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// tagVolumes tags the EBS volumes of the instance, which RunInstances
+// creates untagged.
+func (d *Driver) tagVolumes() error {
+	if len(d.ResourceTags) == 0 {
+		return nil
+	}
+
+	instance, err := d.getInstance()
+	if err != nil {
+		return err
+	}
+
+	for _, bdm := range instance.BlockDeviceMapping {
+		if bdm.Ebs.VolumeId == "" {
+			continue
+		}
+		if err := d.getClient().CreateTags(bdm.Ebs.VolumeId, d.resourceTags()); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// blockDeviceMappings returns the root volume of the instance, and the volume
+// given with --attach-volume if any. Both are deleted with the instance.
+func (d *Driver) blockDeviceMappings() []amz.BlockDeviceMapping {
+	bdms := []amz.BlockDeviceMapping{
+		{
+			DeviceName:          "/dev/sda1",
+			VolumeSize:          d.RootSize,
+			DeleteOnTermination: true,
+			VolumeType:          "gp2",
+		},
+	}
+
+	if d.AttachVolume != nil {
+		volumeType := "gp2"
+		if d.AttachVolume.Type == drivers.VolumeTypeHDD {
+			volumeType = "standard"
+		}
+
+		bdms = append(bdms, amz.BlockDeviceMapping{
+			DeviceName:          volumeDeviceName,
+			VolumeSize:          int64(d.AttachVolume.Size),
+			DeleteOnTermination: true,
+			VolumeType:          volumeType,
+		})
+	}
+
+	return bdms
+}
+
+// GetVolumeDevices returns the NVMe device of the EBS volume attached with
+// --attach-volume, as on Nitro instances, and the names the volume has on
+// Xen ones.
+func (d *Driver) GetVolumeDevices() ([]string, error) {
+	instance, err := d.getInstance()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []string{}
+	for _, bdm := range instance.BlockDeviceMapping {
+		if bdm.DeviceName == volumeDeviceName && bdm.Ebs.VolumeId != "" {
+			devices = append(devices, "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_"+strings.Replace(bdm.Ebs.VolumeId, "-", "", 1))
+		}
+	}
+
+	return append(devices, volumeDeviceName, "/dev/xvdf"), nil
+}
+
+func (d *Driver) launchOnDemandInstance(bdms []amz.BlockDeviceMapping) (amz.EC2Instance, error) {
+	inst, err := d.getClient().RunInstance(d.AMI, d.InstanceType, d.Zone, 1, 1, d.SecurityGroupId, d.KeyName, d.SubnetId, bdms, d.IamInstanceProfile, d.PrivateIPOnly, d.Monitoring, d.metadataOptions())
+	if err != nil {
+		return inst, fmt.Errorf("Error launching instance: %w", err)
+	}
+	return inst, nil
+}
+
+// launchSpotInstance requests a spot instance and waits for the request to
+// be fulfilled. When it can't be, the request is canceled, and an on-demand
+// instance is launched instead if the driver is set to fall back to one.
+func (d *Driver) launchSpotInstance(bdms []amz.BlockDeviceMapping) (amz.EC2Instance, error) {
+	c := d.getClient()
+
+	spotInstanceRequestId, err := c.RequestSpotInstances(d.AMI, d.InstanceType, d.Zone, 1, d.SecurityGroupId, d.KeyName, d.SubnetId, bdms, d.IamInstanceProfile, d.SpotPrice, d.SpotRequestType, d.Monitoring)
+	if err != nil {
+		return amz.EC2Instance{}, fmt.Errorf("Error request spot instance: %w", err)
+	}
+	d.SpotRequestId = spotInstanceRequestId
+
+	tags := d.resourceTags()
+	tags["Name"] = d.MachineName
+	if err := c.CreateTags(spotInstanceRequestId, tags); err != nil {
+		log.Debugf("Error tagging spot instance request: %s", err)
+	}
+
+	log.Infof("Waiting for spot instance request %s to be fulfilled...", spotInstanceRequestId)
+	instanceId, waitErr := d.waitForSpotRequest()
+	if waitErr != nil {
+		log.Infof("Spot instance request %s could not be fulfilled: %s", spotInstanceRequestId, waitErr)
+
+		if err := c.CancelSpotInstanceRequests(spotInstanceRequestId); err != nil {
+			return amz.EC2Instance{}, err
+		}
+
+		// The request may have been fulfilled while it was being canceled.
+		request, err := c.GetSpotInstanceRequest(spotInstanceRequestId)
+		if err != nil {
+			return amz.EC2Instance{}, err
+		}
+		instanceId = request.InstanceId
+	}
+
+	if instanceId == "" {
+		d.SpotRequestId = ""
+		if !d.SpotFallback {
+			return amz.EC2Instance{}, fmt.Errorf("Error waiting for spot instance: %s", waitErr)
+		}
+
+		log.Info("Launching an on-demand instance instead...")
+		return d.launchOnDemandInstance(bdms)
+	}
+
+	// Spot instance requests have no metadata options, so they are changed
+	// once the instance is launched.
+	if options := d.metadataOptions(); options != nil {
+		if err := c.ModifyInstanceMetadataOptions(instanceId, *options); err != nil {
+			return amz.EC2Instance{}, err
+		}
+	}
+
+	instance, err := c.GetInstance(instanceId)
+	if err != nil {
+		return instance, fmt.Errorf("Error get instance: %w", err)
+	}
+	return instance, nil
+}
+
+// metadataOptions returns the instance metadata options of the instance, or
+// nil when it uses the defaults of EC2.
+func (d *Driver) metadataOptions() *amz.InstanceMetadataOptions {
+	if (d.MetadataToken == "" || d.MetadataToken == defaultMetadataToken) && (d.MetadataHopLimit == 0 || d.MetadataHopLimit == defaultMetadataHopLimit) {
+		return nil
+	}
+	return &amz.InstanceMetadataOptions{
+		HttpTokens:              d.MetadataToken,
+		HttpPutResponseHopLimit: d.MetadataHopLimit,
+	}
+}
+
+// waitForSpotRequest waits for the spot instance request to be fulfilled
+// and returns the ID of its instance. It gives up after SpotTimeout seconds,
+// or as soon as the request fails for good.
+func (d *Driver) waitForSpotRequest() (string, error) {
+	var instanceId string
+
+	err := mcnutils.WaitForSpecificOrError(func() (bool, error) {
+		request, err := d.getClient().GetSpotInstanceRequest(d.SpotRequestId)
+		if err != nil {
+			// New requests are not always visible right away.
+			log.Debugf("Error describing spot instance request: %s", err)
+			return false, nil
+		}
+
+		log.Debugf("spot instance request status: %s", request.Status.Code)
+		switch {
+		case request.Status.Code == "fulfilled" && request.InstanceId != "":
+			instanceId = request.InstanceId
+			return true, nil
+		case spotRequestFailed(request):
+			return false, fmt.Errorf("%s: %s", request.Status.Code, request.Status.Message)
+		}
+		return false, nil
+	}, spotWaitAttempts(d.SpotTimeout), spotPollInterval)
+
+	return instanceId, err
+}
+
+// spotRequestFailed reports whether a spot instance request will never be
+// fulfilled. Requests held because the price is too low or capacity is
+// missing can still be, and are waited for until the timeout.
+func spotRequestFailed(request amz.SpotInstanceRequest) bool {
+	if request.State == "cancelled" || request.State == "failed" || request.State == "closed" {
+		return true
+	}
+
+	switch request.Status.Code {
+	case "bad-parameters", "canceled-before-fulfillment", "constraint-not-fulfillable", "schedule-expired", "system-error":
+		return true
+	}
+	return false
+}
+
+func spotWaitAttempts(timeout int) int {
+	attempts := int(time.Duration(timeout) * time.Second / spotPollInterval)
+	if attempts < 1 {
+		return 1
+	}
+	return attempts
+}
+
+// spotPollInterval is how often the status of spot requests is checked.
+var spotPollInterval = 5 * time.Second
+
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -429,7 +932,7 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
-	return fmt.Sprintf("tcp://%s:%d", ip, dockerPort), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(dockerPort))), nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -470,6 +973,27 @@ func (d *Driver) GetState() (state.State, error) {
 	}
 }
 
+// GetStateReason tells when a spot instance has been interrupted, or is
+// about to be, by EC2 reclaiming its capacity or the price going over the
+// maximum price.
+func (d *Driver) GetStateReason() (string, error) {
+	if d.SpotRequestId == "" {
+		return "", nil
+	}
+
+	request, err := d.getClient().GetSpotInstanceRequest(d.SpotRequestId)
+	if err != nil {
+		return "", err
+	}
+
+	code := request.Status.Code
+	if strings.HasPrefix(code, "instance-stopped-") || strings.HasPrefix(code, "instance-terminated-") || strings.HasPrefix(code, "marked-for-") {
+		return fmt.Sprintf("Spot instance interrupted (%s): %s", code, request.Status.Message), nil
+	}
+
+	return "", nil
+}
+
 // GetSSHHostname -
 func (d *Driver) GetSSHHostname() (string, error) {
 	// TODO: use @nathanleclaire retry func here (ehazlett)
@@ -503,23 +1027,159 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+// Resize changes the instance type of the instance, which must be stopped.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if opts.CPUs > 0 || opts.Memory > 0 || opts.DiskSize > 0 {
+		return drivers.ErrResizeInstanceTypeOnly
+	}
+
+	if err := d.getClient().ModifyInstanceType(d.InstanceId, opts.InstanceType); err != nil {
+		return err
+	}
+
+	d.InstanceType = opts.InstanceType
+	return nil
+}
+
 func (d *Driver) Remove() error {
+	// A persistent request would launch a new instance once this one is
+	// terminated.
+	if d.SpotRequestId != "" {
+		if err := d.getClient().CancelSpotInstanceRequests(d.SpotRequestId); err != nil {
+			return fmt.Errorf("unable to cancel spot instance request: %w", err)
+		}
+	}
 
 	if err := d.terminate(); err != nil {
-		return fmt.Errorf("unable to terminate instance: %s", err)
+		return fmt.Errorf("unable to terminate instance: %w", err)
 	}
 
 	// remove keypair
 	if err := d.deleteKeyPair(); err != nil {
-		return fmt.Errorf("unable to remove key pair: %s", err)
+		return fmt.Errorf("unable to remove key pair: %w", err)
 	}
 
 	return nil
 }
 
+// orphanKinds are the kinds of the tagged resources OrphanedResources returns,
+// by resource type, in the order to remove them in: the spot requests
+// before the instances they would replace, and the instances before the
+// security groups they are in. The volumes, deleted with their instance,
+// are left out.
+var orphanKinds = []struct {
+	resourceType string
+	kind         string
+}{
+	{"spot-instances-request", "spot-request"},
+	{"instance", "instance"},
+	{"key-pair", "key-pair"},
+	{"security-group", "security-group"},
+}
+
+// OrphanedResources returns the spot requests, instances, key pairs and
+// security groups of the region tagged for machines of the store which
+// aren't one of machines. Cancelled requests and terminated instances are
+// left out.
+func (d *Driver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	client := d.getClient()
+
+	tags, err := client.DescribeTags([]amz.Filter{{Name: "key", Value: drivers.TagMachineName}})
+	if err != nil {
+		return nil, err
+	}
+
+	storeTags, err := client.DescribeTags([]amz.Filter{{Name: "key", Value: drivers.TagMachineStore}, {Name: "value", Value: storeID}})
+	if err != nil {
+		return nil, err
+	}
+
+	inStore := map[string]bool{}
+	for _, tag := range storeTags {
+		inStore[tag.ResourceId] = true
+	}
+
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[machine] = true
+	}
+
+	resources := []drivers.OrphanedResource{}
+	for _, orphanKind := range orphanKinds {
+		for _, tag := range tags {
+			if tag.ResourceType != orphanKind.resourceType || known[tag.Value] || !inStore[tag.ResourceId] {
+				continue
+			}
+
+			switch orphanKind.kind {
+			case "spot-request":
+				request, err := client.GetSpotInstanceRequest(tag.ResourceId)
+				if err != nil {
+					return nil, err
+				}
+				if request.State == "cancelled" || request.State == "closed" {
+					continue
+				}
+			case "instance":
+				instance, err := client.GetInstance(tag.ResourceId)
+				if err != nil {
+					return nil, err
+				}
+				if instance.InstanceState.Name == "terminated" || instance.InstanceState.Name == "shutting-down" {
+					continue
+				}
+			}
+
+			resources = append(resources, drivers.OrphanedResource{
+				Kind:    orphanKind.kind,
+				ID:      tag.ResourceId,
+				Machine: tag.Value,
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+// RemoveOrphanedResource removes a resource OrphanedResources returned.
+// Instances are waited for to be terminated, for their security groups to be
+// removable.
+func (d *Driver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	client := d.getClient()
+
+	switch resource.Kind {
+	case "spot-request":
+		return client.CancelSpotInstanceRequests(resource.ID)
+	case "instance":
+		if err := client.TerminateInstance(resource.ID); err != nil {
+			return err
+		}
+		return mcnutils.WaitForSpecificOrError(func() (bool, error) {
+			instance, err := client.GetInstance(resource.ID)
+			if err != nil {
+				return false, err
+			}
+			return instance.InstanceState.Name == "terminated", nil
+		}, terminateWaitAttempts, terminatePollInterval)
+	case "key-pair":
+		return client.DeleteKeyPairById(resource.ID)
+	case "security-group":
+		return client.DeleteSecurityGroup(resource.ID)
+	}
+
+	return fmt.Errorf("Unknown kind of resource %q", resource.Kind)
+}
+
+// terminatePollInterval is how often the state of instances being terminated
+// is checked, for up to terminateWaitAttempts times.
+var (
+	terminatePollInterval = 5 * time.Second
+	terminateWaitAttempts = 60
+)
+
 func (d *Driver) Restart() error {
 	if err := d.getClient().RestartInstance(d.InstanceId); err != nil {
-		return fmt.Errorf("unable to restart instance: %s", err)
+		return fmt.Errorf("unable to restart instance: %w", err)
 	}
 	return nil
 }
@@ -532,8 +1192,37 @@ func (d *Driver) Kill() error {
 }
 
 func (d *Driver) getClient() *amz.EC2 {
+	return d.getRegionClient(d.Region)
+}
+
+// getRegionClient returns a client of the API of the region, with the
+// credentials of the driver.
+func (d *Driver) getRegionClient(region string) *amz.EC2 {
 	auth := amz.GetAuth(d.AccessKey, d.SecretKey, d.SessionToken)
-	return amz.NewEC2(auth, d.Region)
+	client := amz.NewEC2(auth, region)
+	client.Credentials = d.getCredentials()
+	if d.endpoint != "" {
+		client.Endpoint = d.endpoint
+	}
+	return client
+}
+
+// getCredentials returns the credentials of the API calls: the access keys
+// given, or those of the profile or the instance profile, with the role
+// assumed if one is given.
+func (d *Driver) getCredentials() *amz.Credentials {
+	if d.credentials == nil {
+		d.credentials = amz.NewCredentials(amz.CredentialsConfig{
+			AccessKey:       d.AccessKey,
+			SecretKey:       d.SecretKey,
+			SessionToken:    d.SessionToken,
+			Profile:         d.Profile,
+			RoleArn:         d.RoleArn,
+			RoleSessionName: "docker-machine-" + d.MachineName,
+			Region:          d.Region,
+		})
+	}
+	return d.credentials
 }
 
 func (d *Driver) getInstance() (*amz.EC2Instance, error) {
@@ -566,7 +1255,7 @@ func (d *Driver) waitForInstance() error {
 
 func (d *Driver) createKeyPair() error {
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
@@ -579,7 +1268,7 @@ func (d *Driver) createKeyPair() error {
 
 	log.Debugf("creating key pair: %s", keyName)
 
-	if err := d.getClient().ImportKeyPair(keyName, string(publicKey)); err != nil {
+	if err := d.getClient().ImportKeyPair(keyName, string(publicKey), d.ResourceTags); err != nil {
 		return err
 	}
 
@@ -594,7 +1283,7 @@ func (d *Driver) terminate() error {
 
 	log.Debugf("terminating instance: %s", d.InstanceId)
 	if err := d.getClient().TerminateInstance(d.InstanceId); err != nil {
-		return fmt.Errorf("unable to terminate instance: %s", err)
+		return fmt.Errorf("unable to terminate instance: %w", err)
 	}
 
 	return nil
@@ -646,6 +1335,12 @@ func (d *Driver) configureSecurityGroup(groupName string) error {
 		if err := mcnutils.WaitFor(d.securityGroupAvailableFunc(group.GroupId)); err != nil {
 			return err
 		}
+
+		if len(d.ResourceTags) > 0 {
+			if err := d.getClient().CreateTags(group.GroupId, d.resourceTags()); err != nil {
+				return err
+			}
+		}
 	}
 
 	d.SecurityGroupId = securityGroup.GroupId
@@ -1,12 +1,19 @@
 package amazonec2
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/drivers/amazonec2/amz"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -70,10 +77,18 @@ func getDefaultTestDriverFlags() *DriverOptionsMock {
 			"swarm-host":                      "",
 			"swarm-master":                    false,
 			"swarm-discovery":                 "",
+			"ssh-bastion":                     "",
+			"ssh-bastion-key":                 "",
+			"ssh-key-type":                    "rsa",
+			"attach-volume":                   "",
+			"tag":                             []string{},
+			"machine-store-id":                "",
 			"amazonec2-ami":                   "ami-12345",
 			"amazonec2-access-key":            "abcdefg",
 			"amazonec2-secret-key":            "12345",
 			"amazonec2-session-token":         "",
+			"amazonec2-profile":               "",
+			"amazonec2-role-arn":              "",
 			"amazonec2-instance-type":         "t1.micro",
 			"amazonec2-vpc-id":                "vpc-12345",
 			"amazonec2-subnet-id":             "subnet-12345",
@@ -83,11 +98,18 @@ func getDefaultTestDriverFlags() *DriverOptionsMock {
 			"amazonec2-root-size":             10,
 			"amazonec2-iam-instance-profile":  "",
 			"amazonec2-ssh-user":              "ubuntu",
+			"amazonec2-spot":                  false,
 			"amazonec2-request-spot-instance": false,
 			"amazonec2-spot-price":            "",
+			"amazonec2-spot-request-type":     "one-time",
+			"amazonec2-spot-timeout":          300,
+			"amazonec2-spot-fallback":         false,
 			"amazonec2-private-address-only":  false,
 			"amazonec2-use-private-address":   false,
+			"amazonec2-ssh-bastion":           "",
 			"amazonec2-monitoring":            false,
+			"amazonec2-metadata-token":        "optional",
+			"amazonec2-metadata-token-response-hop-limit": 1,
 		},
 	}
 }
@@ -235,3 +257,615 @@ func TestValidateAwsRegionInvalid(t *testing.T) {
 		}
 	}
 }
+
+// fakeEC2 answers EC2 API calls with canned XML responses keyed by action,
+// and records the calls it got.
+type fakeEC2 struct {
+	responses map[string][]string
+	calls     []url.Values
+}
+
+func (f *fakeEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.calls = append(f.calls, query)
+
+	action := query.Get("Action")
+	responses := f.responses[action]
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<Response><Errors><Error><Code>InvalidAction</Code><Message>unexpected %s</Message></Error></Errors></Response>`, action)
+		return
+	}
+
+	// The last response is repeated for the calls after it.
+	if len(responses) > 1 {
+		f.responses[action] = responses[1:]
+	}
+	fmt.Fprint(w, responses[0])
+}
+
+func (f *fakeEC2) actions() []string {
+	actions := []string{}
+	for _, call := range f.calls {
+		actions = append(actions, call.Get("Action"))
+	}
+	return actions
+}
+
+func spotRequestResponse(state, code, instanceId string) string {
+	return fmt.Sprintf(`<DescribeSpotInstanceRequestsResponse><spotInstanceRequestSet><item>
+		<spotInstanceRequestId>sir-1</spotInstanceRequestId>
+		<state>%s</state>
+		<status><code>%s</code><message>status of the request</message></status>
+		<instanceId>%s</instanceId>
+	</item></spotInstanceRequestSet></DescribeSpotInstanceRequestsResponse>`, state, code, instanceId)
+}
+
+const (
+	requestSpotResponse = `<RequestSpotInstancesResponse><spotInstanceRequestSet><item>
+		<spotInstanceRequestId>sir-1</spotInstanceRequestId><state>open</state>
+	</item></spotInstanceRequestSet></RequestSpotInstancesResponse>`
+	describeInstanceResponse = `<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+		<instanceId>%s</instanceId>
+	</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`
+	runInstancesResponse = `<RunInstancesResponse><instancesSet><item>
+		<instanceId>i-ondemand</instanceId>
+	</item></instancesSet></RunInstancesResponse>`
+)
+
+func newSpotTestDriver(responses map[string][]string) (*Driver, *fakeEC2, func()) {
+	fake := &fakeEC2{responses: responses}
+	server := httptest.NewServer(fake)
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	d.endpoint = server.URL
+	d.AccessKey = "abcdefg"
+	d.SecretKey = "12345"
+	d.RequestSpotInstance = true
+	d.SpotTimeout = 1
+	spotPollInterval = time.Millisecond
+
+	return d, fake, server.Close
+}
+
+func TestSetConfigFromFlagsSpot(t *testing.T) {
+	flags := getDefaultTestDriverFlags()
+	flags.Data["amazonec2-subnet-id"] = ""
+	flags.Data["amazonec2-spot"] = true
+	flags.Data["amazonec2-spot-request-type"] = "persistent"
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.True(t, d.RequestSpotInstance)
+	assert.Equal(t, "persistent", d.SpotRequestType)
+
+	flags.Data["amazonec2-spot-request-type"] = "forever"
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "amazonec2 driver requires --amazonec2-spot-request-type to be one-time or persistent")
+}
+
+func TestLaunchSpotInstance(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"RequestSpotInstances": {requestSpotResponse},
+		"CreateTags":           {`<CreateTagsResponse><return>true</return></CreateTagsResponse>`},
+		"DescribeSpotInstanceRequests": {
+			spotRequestResponse("open", "pending-evaluation", ""),
+			spotRequestResponse("active", "fulfilled", "i-spot"),
+		},
+		"DescribeInstances": {fmt.Sprintf(describeInstanceResponse, "i-spot")},
+	})
+	defer done()
+	d.SpotRequestType = "persistent"
+
+	instance, err := d.launchSpotInstance(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-spot", instance.InstanceId)
+	assert.Equal(t, "sir-1", d.SpotRequestId)
+	assert.Equal(t, "persistent", fake.calls[0].Get("Type"))
+	assert.Equal(t, "stop", fake.calls[0].Get("InstanceInterruptionBehavior"))
+	assert.Equal(t, "sir-1", fake.calls[1].Get("ResourceId.1"))
+}
+
+func TestLaunchSpotInstanceFallsBackToOnDemand(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"RequestSpotInstances":         {requestSpotResponse},
+		"CreateTags":                   {`<CreateTagsResponse><return>true</return></CreateTagsResponse>`},
+		"DescribeSpotInstanceRequests": {spotRequestResponse("open", "capacity-not-available", "")},
+		"CancelSpotInstanceRequests":   {`<CancelSpotInstanceRequestsResponse/>`},
+		"RunInstances":                 {runInstancesResponse},
+	})
+	defer done()
+	d.SpotFallback = true
+	d.SpotTimeout = 0
+
+	instance, err := d.launchSpotInstance(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-ondemand", instance.InstanceId)
+	assert.Empty(t, d.SpotRequestId)
+	assert.Equal(t, "RunInstances", fake.actions()[len(fake.calls)-1])
+	assert.Contains(t, fake.actions(), "CancelSpotInstanceRequests")
+}
+
+func TestLaunchSpotInstanceFailure(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"RequestSpotInstances":         {requestSpotResponse},
+		"CreateTags":                   {`<CreateTagsResponse><return>true</return></CreateTagsResponse>`},
+		"DescribeSpotInstanceRequests": {spotRequestResponse("closed", "bad-parameters", "")},
+		"CancelSpotInstanceRequests":   {`<CancelSpotInstanceRequestsResponse/>`},
+	})
+	defer done()
+	d.SpotTimeout = 3600
+
+	_, err := d.launchSpotInstance(nil)
+
+	assert.EqualError(t, err, "Error waiting for spot instance: bad-parameters: status of the request")
+	assert.Equal(t, []string{
+		"RequestSpotInstances",
+		"CreateTags",
+		"DescribeSpotInstanceRequests",
+		"CancelSpotInstanceRequests",
+		"DescribeSpotInstanceRequests",
+	}, fake.actions())
+}
+
+func TestLaunchSpotInstanceFulfilledWhileCanceling(t *testing.T) {
+	d, _, done := newSpotTestDriver(map[string][]string{
+		"RequestSpotInstances": {requestSpotResponse},
+		"CreateTags":           {`<CreateTagsResponse><return>true</return></CreateTagsResponse>`},
+		"DescribeSpotInstanceRequests": {
+			spotRequestResponse("open", "price-too-low", ""),
+			spotRequestResponse("cancelled", "request-canceled-and-instance-running", "i-spot"),
+		},
+		"CancelSpotInstanceRequests": {`<CancelSpotInstanceRequestsResponse/>`},
+		"DescribeInstances":          {fmt.Sprintf(describeInstanceResponse, "i-spot")},
+	})
+	defer done()
+	d.SpotTimeout = 0
+
+	instance, err := d.launchSpotInstance(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-spot", instance.InstanceId)
+}
+
+func TestGetStateReason(t *testing.T) {
+	var tests = []struct {
+		code   string
+		reason string
+	}{
+		{"fulfilled", ""},
+		{"marked-for-stop", "Spot instance interrupted (marked-for-stop): status of the request"},
+		{"instance-stopped-no-capacity", "Spot instance interrupted (instance-stopped-no-capacity): status of the request"},
+		{"instance-terminated-by-price", "Spot instance interrupted (instance-terminated-by-price): status of the request"},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newSpotTestDriver(map[string][]string{
+			"DescribeSpotInstanceRequests": {spotRequestResponse("active", expected.code, "i-spot")},
+		})
+		d.SpotRequestId = "sir-1"
+
+		reason, err := d.GetStateReason()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.reason, reason)
+	}
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	reason, err := d.GetStateReason()
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+}
+
+func TestSetConfigFromFlagsCredentials(t *testing.T) {
+	flags := getDefaultTestDriverFlags()
+	flags.Data["amazonec2-subnet-id"] = ""
+	flags.Data["amazonec2-access-key"] = ""
+	flags.Data["amazonec2-secret-key"] = ""
+	flags.Data["amazonec2-profile"] = "dev"
+	flags.Data["amazonec2-role-arn"] = "arn:aws:iam::123456789012:role/machine"
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.Equal(t, "dev", d.Profile)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/machine", d.RoleArn)
+
+	flags.Data["amazonec2-secret-key"] = "12345"
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "amazonec2 driver requires the --amazonec2-access-key option")
+
+	flags.Data["amazonec2-access-key"] = "abcdefg"
+	flags.Data["amazonec2-secret-key"] = ""
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "amazonec2 driver requires the --amazonec2-secret-key option")
+}
+
+func TestSetConfigFromFlagsMetadataOptions(t *testing.T) {
+	flags := getDefaultTestDriverFlags()
+	flags.Data["amazonec2-subnet-id"] = ""
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.Nil(t, d.metadataOptions())
+
+	flags.Data["amazonec2-metadata-token"] = "required"
+	flags.Data["amazonec2-metadata-token-response-hop-limit"] = 2
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.Equal(t, &amz.InstanceMetadataOptions{HttpTokens: "required", HttpPutResponseHopLimit: 2}, d.metadataOptions())
+
+	flags.Data["amazonec2-metadata-token"] = "always"
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "amazonec2 driver requires --amazonec2-metadata-token to be optional or required")
+
+	flags.Data["amazonec2-metadata-token"] = "required"
+	flags.Data["amazonec2-metadata-token-response-hop-limit"] = 0
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "amazonec2 driver requires --amazonec2-metadata-token-response-hop-limit to be between 1 and 64")
+}
+
+func TestLaunchInstanceMetadataOptions(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"RunInstances": {runInstancesResponse},
+	})
+	defer done()
+	d.MetadataToken = "required"
+
+	_, err := d.launchOnDemandInstance(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "required", fake.calls[0].Get("MetadataOptions.HttpTokens"))
+	assert.Equal(t, "1", fake.calls[0].Get("MetadataOptions.HttpPutResponseHopLimit"))
+	assert.Equal(t, "2016-11-15", fake.calls[0].Get("Version"))
+}
+
+func TestSetConfigFromFlagsAttachVolume(t *testing.T) {
+	flags := getDefaultTestDriverFlags()
+	flags.Data["amazonec2-subnet-id"] = ""
+	flags.Data["attach-volume"] = "size=100,type=hdd"
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.Equal(t, &drivers.Volume{Size: 100, Type: "hdd", Mount: "/var/lib/docker"}, d.AttachVolume)
+
+	flags.Data["attach-volume"] = "type=ssd"
+	assert.EqualError(t, d.SetConfigFromFlags(flags), `Invalid --attach-volume "type=ssd", its size is required`)
+}
+
+func TestLaunchInstanceAttachVolume(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"RunInstances": {runInstancesResponse},
+	})
+	defer done()
+	d.RootSize = 16
+	d.AttachVolume = &drivers.Volume{Size: 100, Type: "hdd", Mount: "/var/lib/docker"}
+
+	_, err := d.launchOnDemandInstance(d.blockDeviceMappings())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/sda1", fake.calls[0].Get("BlockDeviceMapping.0.DeviceName"))
+	assert.Equal(t, "16", fake.calls[0].Get("BlockDeviceMapping.0.Ebs.VolumeSize"))
+	assert.Equal(t, "/dev/sdf", fake.calls[0].Get("BlockDeviceMapping.1.DeviceName"))
+	assert.Equal(t, "100", fake.calls[0].Get("BlockDeviceMapping.1.Ebs.VolumeSize"))
+	assert.Equal(t, "standard", fake.calls[0].Get("BlockDeviceMapping.1.Ebs.VolumeType"))
+	assert.Equal(t, "1", fake.calls[0].Get("BlockDeviceMapping.1.Ebs.DeleteOnTermination"))
+}
+
+func TestSetConfigFromFlagsTags(t *testing.T) {
+	flags := getDefaultTestDriverFlags()
+	flags.Data["amazonec2-subnet-id"] = ""
+	flags.Data["tag"] = []string{"team=infra", "cost-center=42"}
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.Equal(t, "infra", d.ResourceTags["team"])
+	assert.Equal(t, "42", d.ResourceTags["cost-center"])
+	assert.Equal(t, machineTestName, d.ResourceTags[drivers.TagMachineName])
+
+	flags.Data["tag"] = []string{"team"}
+	assert.EqualError(t, d.SetConfigFromFlags(flags), `Invalid --tag "team", expected key=value`)
+}
+
+func TestTagVolumes(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"DescribeInstances": {`<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+			<instanceId>i-1</instanceId>
+			<blockDeviceMapping>
+				<item><deviceName>/dev/sda1</deviceName><ebs><volumeId>vol-0root</volumeId></ebs></item>
+				<item><deviceName>/dev/sdf</deviceName><ebs><volumeId>vol-0data</volumeId></ebs></item>
+			</blockDeviceMapping>
+		</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`},
+		"CreateTags": {`<CreateTagsResponse><return>true</return></CreateTagsResponse>`},
+	})
+	defer done()
+	d.InstanceId = "i-1"
+	d.ResourceTags = map[string]string{"team": "infra"}
+
+	assert.NoError(t, d.tagVolumes())
+	assert.Equal(t, []string{"DescribeInstances", "CreateTags", "CreateTags"}, fake.actions())
+	assert.Equal(t, "vol-0root", fake.calls[1].Get("ResourceId.1"))
+	assert.Equal(t, "vol-0data", fake.calls[2].Get("ResourceId.1"))
+	assert.Equal(t, "team", fake.calls[2].Get("Tag.1.Key"))
+	assert.Equal(t, "infra", fake.calls[2].Get("Tag.1.Value"))
+}
+
+func instanceStateResponse(instanceId, state string) string {
+	return fmt.Sprintf(`<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+		<instanceId>%s</instanceId>
+		<instanceState><name>%s</name></instanceState>
+	</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`, instanceId, state)
+}
+
+func TestOrphanedResources(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"DescribeTags": {`<DescribeTagsResponse><tagSet>
+			<item><resourceId>sg-1</resourceId><resourceType>security-group</resourceType><key>machine-name</key><value>failed</value></item>
+			<item><resourceId>i-1</resourceId><resourceType>instance</resourceType><key>machine-name</key><value>failed</value></item>
+			<item><resourceId>i-2</resourceId><resourceType>instance</resourceType><key>machine-name</key><value>web</value></item>
+			<item><resourceId>i-3</resourceId><resourceType>instance</resourceType><key>machine-name</key><value>removed</value></item>
+			<item><resourceId>key-1</resourceId><resourceType>key-pair</resourceType><key>machine-name</key><value>failed</value></item>
+			<item><resourceId>vol-1</resourceId><resourceType>volume</resourceType><key>machine-name</key><value>failed</value></item>
+			<item><resourceId>i-4</resourceId><resourceType>instance</resourceType><key>machine-name</key><value>other</value></item>
+		</tagSet></DescribeTagsResponse>`, `<DescribeTagsResponse><tagSet>
+			<item><resourceId>sg-1</resourceId><resourceType>security-group</resourceType><key>machine-store</key><value>0123abcd</value></item>
+			<item><resourceId>i-1</resourceId><resourceType>instance</resourceType><key>machine-store</key><value>0123abcd</value></item>
+			<item><resourceId>i-2</resourceId><resourceType>instance</resourceType><key>machine-store</key><value>0123abcd</value></item>
+			<item><resourceId>i-3</resourceId><resourceType>instance</resourceType><key>machine-store</key><value>0123abcd</value></item>
+			<item><resourceId>key-1</resourceId><resourceType>key-pair</resourceType><key>machine-store</key><value>0123abcd</value></item>
+		</tagSet></DescribeTagsResponse>`},
+		"DescribeInstances": {
+			instanceStateResponse("i-1", "running"),
+			instanceStateResponse("i-3", "terminated"),
+		},
+	})
+	defer done()
+
+	resources, err := d.OrphanedResources("0123abcd", []string{"web"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.OrphanedResource{
+		{Kind: "instance", ID: "i-1", Machine: "failed"},
+		{Kind: "key-pair", ID: "key-1", Machine: "failed"},
+		{Kind: "security-group", ID: "sg-1", Machine: "failed"},
+	}, resources)
+	assert.Equal(t, "key", fake.calls[0].Get("Filter.1.Name"))
+	assert.Equal(t, "machine-name", fake.calls[0].Get("Filter.1.Value.1"))
+	assert.Equal(t, "machine-store", fake.calls[1].Get("Filter.1.Value.1"))
+	assert.Equal(t, "value", fake.calls[1].Get("Filter.2.Name"))
+	assert.Equal(t, "0123abcd", fake.calls[1].Get("Filter.2.Value.1"))
+}
+
+func TestRemoveOrphanedInstance(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"TerminateInstances": {`<TerminateInstancesResponse/>`},
+		"DescribeInstances": {
+			instanceStateResponse("i-1", "shutting-down"),
+			instanceStateResponse("i-1", "terminated"),
+		},
+		"DeleteKeyPair": {`<DeleteKeyPairResponse><return>true</return></DeleteKeyPairResponse>`},
+	})
+	defer done()
+	terminatePollInterval = time.Millisecond
+
+	assert.NoError(t, d.RemoveOrphanedResource(drivers.OrphanedResource{Kind: "instance", ID: "i-1"}))
+	assert.NoError(t, d.RemoveOrphanedResource(drivers.OrphanedResource{Kind: "key-pair", ID: "key-1"}))
+	assert.Equal(t, []string{"TerminateInstances", "DescribeInstances", "DescribeInstances", "DeleteKeyPair"}, fake.actions())
+	assert.Equal(t, "key-1", fake.calls[3].Get("KeyPairId"))
+}
+
+func TestGetVolumeDevices(t *testing.T) {
+	d, _, done := newSpotTestDriver(map[string][]string{
+		"DescribeInstances": {`<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+			<instanceId>i-1</instanceId>
+			<blockDeviceMapping>
+				<item><deviceName>/dev/sda1</deviceName><ebs><volumeId>vol-0root</volumeId></ebs></item>
+				<item><deviceName>/dev/sdf</deviceName><ebs><volumeId>vol-0123abc</volumeId></ebs></item>
+			</blockDeviceMapping>
+		</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`},
+	})
+	defer done()
+	d.InstanceId = "i-1"
+
+	devices, err := d.GetVolumeDevices()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol0123abc", "/dev/sdf", "/dev/xvdf"}, devices)
+}
+
+func TestPlan(t *testing.T) {
+	flags := getDefaultTestDriverFlags()
+	flags.Data["amazonec2-subnet-id"] = ""
+	flags.Data["attach-volume"] = "size=100,type=hdd"
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+
+	resources, err := d.Plan()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PlannedResource{
+		{Kind: "instance", Description: "t1.micro from ami-12345 in us-east-1e"},
+		{Kind: "network", Description: "default subnet of us-east-1e in vpc-12345"},
+		{Kind: "key-pair", Description: machineTestName},
+		{Kind: "security-group", Description: "docker-machine-test, allowing tcp port 22 from 0.0.0.0/0"},
+		{Kind: "security-group", Description: "docker-machine-test, allowing tcp port 2376 from 0.0.0.0/0"},
+		{Kind: "disk", Description: "10GB gp2 EBS volume on /dev/sda1"},
+		{Kind: "disk", Description: "100GB standard EBS volume on /dev/sdf"},
+	}, resources)
+}
+
+func TestPricing(t *testing.T) {
+	d := NewDriver(machineTestName, "").(*Driver)
+	d.Region = "us-east-1"
+	d.InstanceType = "t3.small"
+	d.RequestSpotInstance = true
+	d.AttachVolume = &drivers.Volume{Size: 100, Type: drivers.VolumeTypeHDD}
+
+	pricing, err := d.Pricing()
+
+	assert.NoError(t, err)
+	assert.Equal(t, drivers.Pricing{
+		Region:       "us-east-1",
+		InstanceType: "t3.small",
+		Spot:         true,
+		Storage: []drivers.PricedStorage{
+			{Type: "gp2", Size: defaultRootSize},
+			{Type: "standard", Size: 100},
+		},
+	}, pricing)
+}
+
+func TestTerraformResources(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "amazonec2-terraform")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	d := NewDriver(machineTestName, storePath).(*Driver)
+	d.KeyName = machineTestName
+	d.InstanceId = "i-1"
+	d.SecurityGroupId = "sg-1"
+	d.VpcId = "vpc-1"
+	d.SubnetId = "subnet-1"
+	d.AMI = "ami-1"
+	d.Region = "us-east-1"
+	d.AttachVolume = &drivers.Volume{Size: 100, Type: "ssd", Mount: "/var/lib/docker"}
+
+	assert.NoError(t, os.MkdirAll(storePath+"/machines/"+machineTestName, 0700))
+	assert.NoError(t, ioutil.WriteFile(d.GetSSHKeyPath()+".pub", []byte("ssh-rsa AAAA\n"), 0600))
+
+	resources, err := d.TerraformResources()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(resources))
+
+	keyPair, securityGroup, instance := resources[0], resources[1], resources[2]
+	assert.Equal(t, "aws_key_pair.test-host", keyPair.Address())
+	assert.Equal(t, machineTestName, keyPair.ID)
+	assert.Equal(t, drivers.TerraformAttribute{Name: "public_key", Value: `"ssh-rsa AAAA"`}, keyPair.Attributes[1])
+
+	assert.Equal(t, "aws_security_group.docker-machine", securityGroup.Address())
+	assert.Equal(t, "sg-1", securityGroup.ID)
+	assert.Equal(t, []string{"ingress", "ingress", "egress"}, []string{securityGroup.Blocks[0].Type, securityGroup.Blocks[1].Type, securityGroup.Blocks[2].Type})
+
+	assert.Equal(t, "aws_instance.test-host", instance.Address())
+	assert.Equal(t, "i-1", instance.ID)
+	assert.Contains(t, instance.Attributes, drivers.TerraformAttribute{Name: "key_name", Value: "aws_key_pair.test-host.key_name"})
+	assert.Contains(t, instance.Attributes, drivers.TerraformAttribute{Name: "vpc_security_group_ids", Value: "[aws_security_group.docker-machine.id]"})
+	assert.Contains(t, instance.Attributes, drivers.TerraformAttribute{Name: "availability_zone", Value: `"us-east-1a"`})
+	assert.Equal(t, "root_block_device", instance.Blocks[0].Type)
+	assert.Equal(t, "ebs_block_device", instance.Blocks[1].Type)
+	assert.Equal(t, drivers.TerraformAttribute{Name: "device_name", Value: `"/dev/sdf"`}, instance.Blocks[1].Attributes[0])
+}
+
+func TestLaunchSpotInstanceMetadataOptions(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"RequestSpotInstances":          {requestSpotResponse},
+		"CreateTags":                    {`<CreateTagsResponse><return>true</return></CreateTagsResponse>`},
+		"DescribeSpotInstanceRequests":  {spotRequestResponse("active", "fulfilled", "i-spot")},
+		"ModifyInstanceMetadataOptions": {`<ModifyInstanceMetadataOptionsResponse/>`},
+		"DescribeInstances":             {fmt.Sprintf(describeInstanceResponse, "i-spot")},
+	})
+	defer done()
+	d.MetadataToken = "required"
+	d.MetadataHopLimit = 2
+
+	_, err := d.launchSpotInstance(nil)
+
+	assert.NoError(t, err)
+	modify := fake.calls[3]
+	assert.Equal(t, "ModifyInstanceMetadataOptions", modify.Get("Action"))
+	assert.Equal(t, "i-spot", modify.Get("InstanceId"))
+	assert.Equal(t, "required", modify.Get("HttpTokens"))
+	assert.Equal(t, "2", modify.Get("HttpPutResponseHopLimit"))
+}
+
+func TestResize(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"ModifyInstanceAttribute": {`<ModifyInstanceAttributeResponse><return>true</return></ModifyInstanceAttributeResponse>`},
+	})
+	defer done()
+	d.InstanceId = "i-resized"
+
+	err := d.Resize(drivers.ResizeOptions{InstanceType: "m4.large"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "m4.large", d.InstanceType)
+	assert.Equal(t, "i-resized", fake.calls[0].Get("InstanceId"))
+	assert.Equal(t, "m4.large", fake.calls[0].Get("InstanceType.Value"))
+
+	err = d.Resize(drivers.ResizeOptions{CPUs: 4})
+	assert.Equal(t, drivers.ErrResizeInstanceTypeOnly, err)
+}
+
+func TestDiscovery(t *testing.T) {
+	d, fake, done := newSpotTestDriver(map[string][]string{
+		"DescribeRegions": {`<DescribeRegionsResponse><regionInfo>
+			<item><regionName>us-west-2</regionName><regionEndpoint>ec2.us-west-2.amazonaws.com</regionEndpoint></item>
+			<item><regionName>eu-west-1</regionName><regionEndpoint>ec2.eu-west-1.amazonaws.com</regionEndpoint></item>
+			<item><regionName>eu-south-2</regionName><regionEndpoint>ec2.eu-south-2.amazonaws.com</regionEndpoint></item>
+		</regionInfo></DescribeRegionsResponse>`},
+		"DescribeImages": {`<DescribeImagesResponse><imagesSet>
+			<item><imageId>ami-old</imageId><name>ubuntu/images/hvm-ssd/ubuntu-focal-20.04-amd64-server-20200423</name><creationDate>2020-04-23T12:00:00.000Z</creationDate></item>
+			<item><imageId>ami-new</imageId><name>ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240101</name><creationDate>2024-01-01T12:00:00.000Z</creationDate></item>
+		</imagesSet></DescribeImagesResponse>`},
+		"DescribeInstanceTypeOfferings": {
+			`<DescribeInstanceTypeOfferingsResponse><instanceTypeOfferingSet>
+				<item><instanceType>t3.micro</instanceType><location>eu-west-1</location></item>
+			</instanceTypeOfferingSet><nextToken>page2</nextToken></DescribeInstanceTypeOfferingsResponse>`,
+			`<DescribeInstanceTypeOfferingsResponse><instanceTypeOfferingSet>
+				<item><instanceType>m5.large</instanceType><location>eu-west-1</location></item>
+			</instanceTypeOfferingSet></DescribeInstanceTypeOfferingsResponse>`,
+		},
+	})
+	defer done()
+
+	regions, err := d.ListRegions()
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{
+		{Value: "eu-west-1", Description: "default AMI ami-45d8a532"},
+		{Value: "us-west-2", Description: "default AMI ami-7f675e4f"},
+	}, regions)
+
+	images, err := d.ListImages("eu-west-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{
+		{Value: "ami-45d8a532", Description: "default of the driver"},
+		{Value: "ami-new", Description: "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240101"},
+		{Value: "ami-old", Description: "ubuntu/images/hvm-ssd/ubuntu-focal-20.04-amd64-server-20200423"},
+	}, images)
+
+	sizes, err := d.ListSizes("eu-west-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "m5.large"}, {Value: "t3.micro"}}, sizes)
+
+	assert.Equal(t, "page2", fake.calls[len(fake.calls)-1].Get("NextToken"))
+	assert.Equal(t, canonicalOwnerID, fake.calls[1].Get("Owner.1"))
+
+	_, err = d.ListSizes("mars-north-1")
+	assert.Equal(t, errInvalidRegion, err)
+}
+
+func TestGuide(t *testing.T) {
+	os.Setenv("AWS_CONFIG_FILE", "/nonexistent/config")
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent/credentials")
+	defer os.Unsetenv("AWS_CONFIG_FILE")
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	d, _, done := newSpotTestDriver(map[string][]string{
+		"DescribeRegions": {`<DescribeRegionsResponse><regionInfo>
+			<item><regionName>eu-west-1</regionName><regionEndpoint>ec2.eu-west-1.amazonaws.com</regionEndpoint></item>
+		</regionInfo></DescribeRegionsResponse>`},
+	})
+	defer done()
+
+	assert.Equal(t, drivers.GuideFlags{
+		Credentials: []string{"amazonec2-access-key", "amazonec2-secret-key"},
+		Options:     []string{"amazonec2-region", "amazonec2-instance-type", "amazonec2-ami"},
+	}, d.GuideFlags())
+
+	assert.NoError(t, d.ValidateCredentials())
+
+	regions, err := d.ListOptions("amazonec2-region")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "eu-west-1", Description: "default AMI ami-45d8a532"}}, regions)
+
+	_, err = d.ListOptions("amazonec2-vpc-id")
+	assert.EqualError(t, err, "amazonec2 driver cannot list the values of --amazonec2-vpc-id")
+}
@@ -1,9 +1,20 @@
 package amz
 
+import "time"
+
 type Auth struct {
 	AccessKey, SecretKey, SessionToken string
+
+	// Expiration is when temporary credentials expire, zero for long-term
+	// credentials.
+	Expiration time.Time
 }
 
 func GetAuth(accessKey, secretKey, sessionToken string) Auth {
-	return Auth{accessKey, secretKey, sessionToken}
+	return Auth{AccessKey: accessKey, SecretKey: secretKey, SessionToken: sessionToken}
+}
+
+// expired reports whether temporary credentials are expired, or about to.
+func (a Auth) expired() bool {
+	return !a.Expiration.IsZero() && time.Now().Add(5*time.Minute).After(a.Expiration)
 }
@@ -0,0 +1,453 @@
+package amz
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	awsauth "github.com/smartystreets/go-aws-auth"
+)
+
+var (
+	// imdsEndpoint is the instance metadata service of EC2 instances.
+	imdsEndpoint = "http://169.254.169.254"
+
+	// stsEndpoint and ssoEndpoint are formatted with the region.
+	stsEndpoint = "https://sts.%s.amazonaws.com"
+	ssoEndpoint = "https://portal.sso.%s.amazonaws.com"
+)
+
+// CredentialsConfig tells where the credentials of the API calls come from.
+type CredentialsConfig struct {
+	// AccessKey, SecretKey and SessionToken are static credentials, used
+	// first when set.
+	AccessKey, SecretKey, SessionToken string
+
+	// Profile is the profile of the shared config and credentials files to
+	// use when there are no static credentials. The default profile is used
+	// when it exists, and the instance profile of the EC2 instance Machine
+	// runs on otherwise.
+	Profile string
+
+	// RoleArn is a role to assume with the credentials found.
+	RoleArn string
+
+	// RoleSessionName names the sessions of the assumed roles.
+	RoleSessionName string
+
+	Region string
+}
+
+// Credentials resolves the credentials of the API calls, and caches them
+// until they expire.
+type Credentials struct {
+	config CredentialsConfig
+
+	mu   sync.Mutex
+	auth *Auth
+}
+
+func NewCredentials(config CredentialsConfig) *Credentials {
+	if config.RoleSessionName == "" {
+		config.RoleSessionName = "docker-machine"
+	}
+	return &Credentials{config: config}
+}
+
+// Get returns the credentials, resolving them again once they expired.
+func (c *Credentials) Get() (Auth, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.auth != nil && !c.auth.expired() {
+		return *c.auth, nil
+	}
+
+	auth, err := c.resolve()
+	if err != nil {
+		return Auth{}, fmt.Errorf("Error getting AWS credentials: %s", err)
+	}
+
+	c.auth = &auth
+	return auth, nil
+}
+
+func (c *Credentials) resolve() (Auth, error) {
+	auth, err := c.baseAuth()
+	if err != nil {
+		return auth, err
+	}
+
+	if c.config.RoleArn == "" {
+		return auth, nil
+	}
+
+	log.Debugf("Assuming role %s", c.config.RoleArn)
+	return c.assumeRole(auth, c.config.RoleArn, "")
+}
+
+func (c *Credentials) baseAuth() (Auth, error) {
+	if c.config.AccessKey != "" {
+		return GetAuth(c.config.AccessKey, c.config.SecretKey, c.config.SessionToken), nil
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return Auth{}, err
+	}
+
+	name := c.config.Profile
+	if name == "" {
+		if _, ok := profiles["default"]; !ok {
+			log.Debug("No AWS credentials or profile given, using the instance profile")
+			return instanceProfileAuth()
+		}
+		name = "default"
+	}
+
+	return c.profileAuth(profiles, name, 0)
+}
+
+// profileAuth returns the credentials of a profile, which has either static
+// credentials, a role to assume or an IAM Identity Center (SSO) account.
+func (c *Credentials) profileAuth(profiles map[string]map[string]string, name string, depth int) (Auth, error) {
+	if depth > 5 {
+		return Auth{}, fmt.Errorf("Too many source profiles chained from profile %q", name)
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return Auth{}, fmt.Errorf("No AWS profile named %q", name)
+	}
+
+	switch {
+	case profile["aws_access_key_id"] != "":
+		return GetAuth(profile["aws_access_key_id"], profile["aws_secret_access_key"], profile["aws_session_token"]), nil
+
+	case profile["role_arn"] != "":
+		var source Auth
+		var err error
+		switch {
+		case profile["source_profile"] != "":
+			source, err = c.profileAuth(profiles, profile["source_profile"], depth+1)
+		case profile["credential_source"] == "Ec2InstanceMetadata":
+			source, err = instanceProfileAuth()
+		case profile["credential_source"] == "Environment":
+			source = GetAuth(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"))
+		default:
+			err = fmt.Errorf("AWS profile %q needs a source_profile or a supported credential_source to assume its role", name)
+		}
+		if err != nil {
+			return Auth{}, err
+		}
+		return c.assumeRole(source, profile["role_arn"], profile["external_id"])
+
+	case profile["sso_account_id"] != "":
+		return ssoAuth(profiles, profile)
+	}
+
+	return Auth{}, fmt.Errorf("AWS profile %q has no credentials", name)
+}
+
+// HasProfile tells whether the shared config and credentials files have the
+// profile, the default one when name is empty, to take credentials from.
+func HasProfile(name string) bool {
+	if name == "" {
+		name = "default"
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return false
+	}
+	_, ok := profiles[name]
+	return ok
+}
+
+// loadProfiles reads the profiles of the shared credentials and config
+// files, along with the sso-session sections of the config file, keyed by
+// "sso-session <name>".
+func loadProfiles() (map[string]map[string]string, error) {
+	profiles := map[string]map[string]string{}
+
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = filepath.Join(mcnutils.GetHomeDir(), ".aws", "config")
+	}
+	if err := readIniFile(configFile, profiles, func(section string) string {
+		if section == "default" || strings.HasPrefix(section, "sso-session ") {
+			return section
+		}
+		if strings.HasPrefix(section, "profile ") {
+			return strings.TrimSpace(strings.TrimPrefix(section, "profile "))
+		}
+		return ""
+	}); err != nil {
+		return nil, err
+	}
+
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		credentialsFile = filepath.Join(mcnutils.GetHomeDir(), ".aws", "credentials")
+	}
+	if err := readIniFile(credentialsFile, profiles, func(section string) string {
+		return section
+	}); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// readIniFile adds the keys of the sections of an INI file to profiles,
+// under the name returned by profileName, skipping the sections for which
+// it is empty. A missing file has no profiles.
+func readIniFile(path string, profiles map[string]map[string]string, profileName func(section string) string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var current map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = nil
+			if name := profileName(strings.TrimSpace(line[1 : len(line)-1])); name != "" {
+				if profiles[name] == nil {
+					profiles[name] = map[string]string{}
+				}
+				current = profiles[name]
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if current == nil || len(parts) != 2 {
+			continue
+		}
+		current[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return scanner.Err()
+}
+
+// instanceProfileAuth gets the credentials of the instance profile of the
+// EC2 instance Machine runs on, using IMDSv2.
+func instanceProfileAuth() (Auth, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	req, err := http.NewRequest("PUT", imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Auth{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := readResponse(client, req)
+	if err != nil {
+		return Auth{}, fmt.Errorf("No AWS credentials given, and the instance metadata service is not available: %s", err)
+	}
+
+	get := func(path string) (string, error) {
+		req, err := http.NewRequest("GET", imdsEndpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+		return readResponse(client, req)
+	}
+
+	roles, err := get("")
+	if err != nil {
+		return Auth{}, fmt.Errorf("No instance profile found: %s", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+
+	body, err := get(role)
+	if err != nil {
+		return Auth{}, err
+	}
+
+	creds := struct {
+		Code            string
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}{}
+	if err := json.Unmarshal([]byte(body), &creds); err != nil {
+		return Auth{}, err
+	}
+	if creds.Code != "Success" {
+		return Auth{}, fmt.Errorf("Error getting the credentials of instance profile %s: %s", role, creds.Code)
+	}
+
+	return Auth{
+		AccessKey:    creds.AccessKeyId,
+		SecretKey:    creds.SecretAccessKey,
+		SessionToken: creds.Token,
+		Expiration:   creds.Expiration,
+	}, nil
+}
+
+// assumeRole gets temporary credentials for a role with STS.
+func (c *Credentials) assumeRole(source Auth, roleArn, externalId string) (Auth, error) {
+	region := c.config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	v := url.Values{}
+	v.Set("Action", "AssumeRole")
+	v.Set("Version", "2011-06-15")
+	v.Set("RoleArn", roleArn)
+	v.Set("RoleSessionName", c.config.RoleSessionName)
+	if externalId != "" {
+		v.Set("ExternalId", externalId)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf(stsEndpoint, region)+"/?"+v.Encode(), nil)
+	if err != nil {
+		return Auth{}, err
+	}
+	awsauth.Sign4(req, awsauth.Credentials{
+		AccessKeyID:     source.AccessKey,
+		SecretAccessKey: source.SecretKey,
+		SecurityToken:   source.SessionToken,
+	})
+
+	body, err := readResponse(&http.Client{Timeout: 30 * time.Second}, req)
+	if err != nil {
+		return Auth{}, fmt.Errorf("Error assuming role %s: %s", roleArn, err)
+	}
+
+	resp := struct {
+		Credentials struct {
+			AccessKeyId     string
+			SecretAccessKey string
+			SessionToken    string
+			Expiration      time.Time
+		} `xml:"AssumeRoleResult>Credentials"`
+	}{}
+	if err := xml.Unmarshal([]byte(body), &resp); err != nil {
+		return Auth{}, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+	}
+
+	return Auth{
+		AccessKey:    resp.Credentials.AccessKeyId,
+		SecretKey:    resp.Credentials.SecretAccessKey,
+		SessionToken: resp.Credentials.SessionToken,
+		Expiration:   resp.Credentials.Expiration,
+	}, nil
+}
+
+// ssoAuth gets the credentials of the account and role of an IAM Identity
+// Center (SSO) profile, with the token cached by "aws sso login".
+func ssoAuth(profiles map[string]map[string]string, profile map[string]string) (Auth, error) {
+	startURL := profile["sso_start_url"]
+	region := profile["sso_region"]
+	cacheKey := startURL
+
+	if session := profile["sso_session"]; session != "" {
+		sessionConfig, ok := profiles["sso-session "+session]
+		if !ok {
+			return Auth{}, fmt.Errorf("No AWS sso-session named %q", session)
+		}
+		startURL = sessionConfig["sso_start_url"]
+		region = sessionConfig["sso_region"]
+		cacheKey = session
+	}
+
+	hash := sha1.Sum([]byte(cacheKey))
+	cacheFile := filepath.Join(mcnutils.GetHomeDir(), ".aws", "sso", "cache", hex.EncodeToString(hash[:])+".json")
+	data, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return Auth{}, fmt.Errorf("No cached SSO token for %s, run \"aws sso login\": %s", startURL, err)
+	}
+
+	token := struct {
+		AccessToken string    `json:"accessToken"`
+		ExpiresAt   time.Time `json:"expiresAt"`
+	}{}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return Auth{}, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return Auth{}, fmt.Errorf("The SSO token for %s expired, run \"aws sso login\"", startURL)
+	}
+
+	v := url.Values{}
+	v.Set("account_id", profile["sso_account_id"])
+	v.Set("role_name", profile["sso_role_name"])
+	req, err := http.NewRequest("GET", fmt.Sprintf(ssoEndpoint, region)+"/federation/credentials?"+v.Encode(), nil)
+	if err != nil {
+		return Auth{}, err
+	}
+	req.Header.Set("x-amz-sso_bearer_token", token.AccessToken)
+
+	body, err := readResponse(&http.Client{Timeout: 30 * time.Second}, req)
+	if err != nil {
+		return Auth{}, fmt.Errorf("Error getting SSO credentials: %s", err)
+	}
+
+	resp := struct {
+		RoleCredentials struct {
+			AccessKeyId     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+			Expiration      int64  `json:"expiration"`
+		} `json:"roleCredentials"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return Auth{}, err
+	}
+
+	creds := resp.RoleCredentials
+	return Auth{
+		AccessKey:    creds.AccessKeyId,
+		SecretKey:    creds.SecretAccessKey,
+		SessionToken: creds.SessionToken,
+		Expiration:   time.Unix(0, creds.Expiration*int64(time.Millisecond)),
+	}, nil
+}
+
+func readResponse(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, strconv.Quote(strings.TrimSpace(string(body))))
+	}
+
+	return string(body), nil
+}
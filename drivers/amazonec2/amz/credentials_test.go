@@ -0,0 +1,229 @@
+package amz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withAWSHome points the shared config files and the home directory to a
+// temporary directory holding config and credentials, and returns a function
+// restoring them.
+func withAWSHome(t *testing.T, config, credentials string) (string, func()) {
+	home, err := ioutil.TempDir("", "machine-aws-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	awsDir := filepath.Join(home, ".aws")
+	if err := os.MkdirAll(filepath.Join(awsDir, "sso", "cache"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(awsDir, "config"), []byte(config), 0600)
+	ioutil.WriteFile(filepath.Join(awsDir, "credentials"), []byte(credentials), 0600)
+
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	os.Unsetenv("AWS_CONFIG_FILE")
+	os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	return home, func() {
+		os.Setenv("HOME", oldHome)
+		os.RemoveAll(home)
+	}
+}
+
+func TestCredentialsStatic(t *testing.T) {
+	auth, err := NewCredentials(CredentialsConfig{AccessKey: "key", SecretKey: "secret", SessionToken: "token"}).Get()
+
+	assert.NoError(t, err)
+	assert.Equal(t, GetAuth("key", "secret", "token"), auth)
+}
+
+func TestCredentialsProfile(t *testing.T) {
+	_, done := withAWSHome(t, `
+[default]
+region = us-east-1
+
+[profile dev]
+aws_access_key_id = config-key
+`, `
+# comment
+[dev]
+aws_access_key_id = dev-key
+aws_secret_access_key = dev-secret
+
+[default]
+aws_access_key_id = default-key
+aws_secret_access_key = default-secret
+`)
+	defer done()
+
+	auth, err := NewCredentials(CredentialsConfig{Profile: "dev"}).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, GetAuth("dev-key", "dev-secret", ""), auth)
+
+	auth, err = NewCredentials(CredentialsConfig{}).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, GetAuth("default-key", "default-secret", ""), auth)
+
+	_, err = NewCredentials(CredentialsConfig{Profile: "prod"}).Get()
+	assert.EqualError(t, err, `Error getting AWS credentials: No AWS profile named "prod"`)
+}
+
+func TestHasProfile(t *testing.T) {
+	_, done := withAWSHome(t, "[profile dev]\nregion = eu-west-1\n", "")
+	defer done()
+
+	assert.True(t, HasProfile("dev"))
+	assert.False(t, HasProfile("prod"))
+	assert.False(t, HasProfile(""))
+}
+
+func TestCredentialsAssumeRole(t *testing.T) {
+	var query map[string][]string
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		authorization = r.Header.Get("Authorization")
+		fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+			<AccessKeyId>role-key</AccessKeyId>
+			<SecretAccessKey>role-secret</SecretAccessKey>
+			<SessionToken>role-token</SessionToken>
+			<Expiration>2099-01-01T00:00:00Z</Expiration>
+		</Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+	}))
+	defer server.Close()
+	defer func(endpoint string) { stsEndpoint = endpoint }(stsEndpoint)
+	stsEndpoint = server.URL + "/%s"
+
+	_, done := withAWSHome(t, `
+[profile admin]
+role_arn = arn:aws:iam::123456789012:role/admin
+source_profile = base
+external_id = abc
+`, `
+[base]
+aws_access_key_id = base-key
+aws_secret_access_key = base-secret
+`)
+	defer done()
+
+	auth, err := NewCredentials(CredentialsConfig{Profile: "admin", Region: "eu-west-1"}).Get()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "role-key", auth.AccessKey)
+	assert.Equal(t, "role-secret", auth.SecretKey)
+	assert.Equal(t, "role-token", auth.SessionToken)
+	assert.Equal(t, time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), auth.Expiration)
+	assert.Equal(t, "AssumeRole", query["Action"][0])
+	assert.Equal(t, "arn:aws:iam::123456789012:role/admin", query["RoleArn"][0])
+	assert.Equal(t, "abc", query["ExternalId"][0])
+	assert.Equal(t, "docker-machine", query["RoleSessionName"][0])
+	assert.Contains(t, authorization, "Credential=base-key/")
+
+	auth, err = NewCredentials(CredentialsConfig{
+		AccessKey: "key",
+		SecretKey: "secret",
+		RoleArn:   "arn:aws:iam::123456789012:role/machine",
+	}).Get()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "role-key", auth.AccessKey)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/machine", query["RoleArn"][0])
+	assert.Contains(t, authorization, "Credential=key/")
+}
+
+func TestCredentialsInstanceProfile(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == "PUT" {
+			fmt.Fprint(w, "imds-token")
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			fmt.Fprint(w, "machine-role\n")
+		case "/latest/meta-data/iam/security-credentials/machine-role":
+			fmt.Fprint(w, `{"Code": "Success", "AccessKeyId": "instance-key", "SecretAccessKey": "instance-secret", "Token": "instance-token", "Expiration": "2099-01-01T00:00:00Z"}`)
+		}
+	}))
+	defer server.Close()
+	defer func(endpoint string) { imdsEndpoint = endpoint }(imdsEndpoint)
+	imdsEndpoint = server.URL
+
+	_, done := withAWSHome(t, "", "")
+	defer done()
+
+	credentials := NewCredentials(CredentialsConfig{})
+	auth, err := credentials.Get()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "instance-key", auth.AccessKey)
+	assert.Equal(t, "instance-token", auth.SessionToken)
+	assert.Equal(t, []string{
+		"PUT /latest/api/token",
+		"GET /latest/meta-data/iam/security-credentials/",
+		"GET /latest/meta-data/iam/security-credentials/machine-role",
+	}, requests)
+
+	// The credentials are cached until they expire.
+	_, err = credentials.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(requests))
+}
+
+func TestCredentialsSSO(t *testing.T) {
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		if r.Header.Get("x-amz-sso_bearer_token") != "sso-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"roleCredentials": {"accessKeyId": "sso-key", "secretAccessKey": "sso-secret", "sessionToken": "sso-session-token", "expiration": 4070908800000}}`)
+	}))
+	defer server.Close()
+	defer func(endpoint string) { ssoEndpoint = endpoint }(ssoEndpoint)
+	ssoEndpoint = server.URL + "/%s"
+
+	home, done := withAWSHome(t, `
+[profile sso]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+`, "")
+	defer done()
+
+	_, err := NewCredentials(CredentialsConfig{Profile: "sso"}).Get()
+	assert.Contains(t, err.Error(), `run "aws sso login"`)
+
+	// The token cached by "aws sso login" is named after the SHA-1 of the
+	// session name.
+	cacheFile := filepath.Join(home, ".aws", "sso", "cache", "ee0bfd2552fbd840c02cc48b6e823320543c450f.json")
+	ioutil.WriteFile(cacheFile, []byte(`{"accessToken": "sso-token", "expiresAt": "2099-01-01T00:00:00Z"}`), 0600)
+
+	auth, err := NewCredentials(CredentialsConfig{Profile: "sso"}).Get()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sso-key", auth.AccessKey)
+	assert.Equal(t, "sso-session-token", auth.SessionToken)
+	assert.Equal(t, time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), auth.Expiration.UTC())
+	assert.Equal(t, "123456789012", query["account_id"][0])
+	assert.Equal(t, "Admin", query["role_name"][0])
+}
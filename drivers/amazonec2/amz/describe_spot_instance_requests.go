@@ -1,11 +1,21 @@
 package amz
 
+type SpotInstanceRequest struct {
+	SpotInstanceRequestId string `xml:"spotInstanceRequestId"`
+	State                 string `xml:"state"`
+	Type                  string `xml:"type"`
+	Status                struct {
+		Code    string `xml:"code"`
+		Message string `xml:"message"`
+	} `xml:"status"`
+	InstanceId string `xml:"instanceId"`
+}
+
 type DescribeSpotInstanceRequestsResponse struct {
-	RequestId              string `xml:"requestId"`
-	SpotInstanceRequestSet []struct {
-		Status struct {
-			Code string `xml:"code"`
-		} `xml:"status"`
-		InstanceId string `xml:"instanceId"`
-	} `xml:"spotInstanceRequestSet>item"`
+	RequestId              string                `xml:"requestId"`
+	SpotInstanceRequestSet []SpotInstanceRequest `xml:"spotInstanceRequestSet>item"`
+}
+
+type CancelSpotInstanceRequestsResponse struct {
+	RequestId string `xml:"requestId"`
 }
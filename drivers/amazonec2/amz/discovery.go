@@ -0,0 +1,35 @@
+package amz
+
+type DescribeRegionsResponse struct {
+	RequestId string   `xml:"requestId"`
+	Regions   []Region `xml:"regionInfo>item"`
+}
+
+type Region struct {
+	RegionName     string `xml:"regionName"`
+	RegionEndpoint string `xml:"regionEndpoint"`
+}
+
+type DescribeImagesResponse struct {
+	RequestId string  `xml:"requestId"`
+	Images    []Image `xml:"imagesSet>item"`
+}
+
+type Image struct {
+	ImageId      string `xml:"imageId"`
+	Name         string `xml:"name"`
+	Description  string `xml:"description"`
+	CreationDate string `xml:"creationDate"`
+	Architecture string `xml:"architecture"`
+}
+
+type DescribeInstanceTypeOfferingsResponse struct {
+	RequestId string                 `xml:"requestId"`
+	Offerings []InstanceTypeOffering `xml:"instanceTypeOfferingSet>item"`
+	NextToken string                 `xml:"nextToken"`
+}
+
+type InstanceTypeOffering struct {
+	InstanceType string `xml:"instanceType"`
+	Location     string `xml:"location"`
+}
@@ -10,15 +10,35 @@ import (
 	"strconv"
 
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	awsauth "github.com/smartystreets/go-aws-auth"
 )
 
+// recentApiVersion is the version of the API used for spot instance requests,
+// instance metadata options, the tags of key pairs and the instance type
+// offerings, which is recent enough for persistent requests to stop their
+// instances and for key pairs to have IDs.
+const recentApiVersion = "2016-11-15"
+
 type (
 	EC2 struct {
 		Endpoint string
 		Auth     Auth
 		Region   string
+
+		// Credentials, when set, resolves the credentials used instead of
+		// Auth.
+		Credentials *Credentials
+	}
+
+	// InstanceMetadataOptions configures the instance metadata service of
+	// an instance. HttpTokens is "required" for instances only allowing
+	// IMDSv2, and HttpPutResponseHopLimit the number of network hops its
+	// session tokens may travel.
+	InstanceMetadataOptions struct {
+		HttpTokens              string
+		HttpPutResponseHopLimit int
 	}
 
 	Instance struct {
@@ -61,10 +81,16 @@ type (
 			Code    string `xml:"code"`
 			Message string `xml:"message"`
 		} `xml:"stateReason"`
-		Architecture        string `xml:"architecture"`
-		RootDeviceType      string `xml:"rootDeviceType"`
-		RootDeviceName      string `xml:"rootDeviceName"`
-		BlockDeviceMapping  string `xml:"blockDeviceMapping"`
+		Architecture       string `xml:"architecture"`
+		RootDeviceType     string `xml:"rootDeviceType"`
+		RootDeviceName     string `xml:"rootDeviceName"`
+		BlockDeviceMapping []struct {
+			DeviceName string `xml:"deviceName"`
+			Ebs        struct {
+				VolumeId string `xml:"volumeId"`
+				Status   string `xml:"status"`
+			} `xml:"ebs"`
+		} `xml:"blockDeviceMapping>item"`
 		VirtualizationType  string `xml:"virtualizationType"`
 		ClientToken         string `xml:"clientToken"`
 		Hypervisor          string `xml:"hypervisor"`
@@ -115,26 +141,40 @@ type (
 	}
 )
 
+// newAwsApiResponseError returns the error of a response of the API, as the
+// mcnerror error of the category of its codes, or else of its status.
 func newAwsApiResponseError(r http.Response) error {
 	var errorResponse ErrorResponse
 	if err := getDecodedResponse(r, &errorResponse); err != nil {
-		return fmt.Errorf("Error decoding error response: %s", err)
+		return fmt.Errorf("Error decoding error response: %w", err)
 	}
 	msg := ""
 	for _, e := range errorResponse.Errors {
 		msg += fmt.Sprintf("%s\n", e.Message)
 	}
-	return fmt.Errorf("Non-200 API response: code=%d message=%s", r.StatusCode, msg)
+	err := fmt.Errorf("Non-200 API response: code=%d message=%s", r.StatusCode, msg)
+
+	for _, e := range errorResponse.Errors {
+		switch {
+		case quotaErrorCodes[e.Code]:
+			return mcnerror.ErrDriverQuota{Driver: "amazonec2", Err: err}
+		case transientErrorCodes[e.Code]:
+			return mcnerror.ErrTransient{Err: err}
+		case authErrorCodes[e.Code]:
+			return mcnerror.ErrAuth{Err: err}
+		}
+	}
+	return mcnerror.ForHTTPStatus(r.StatusCode, err)
 }
 
 func newAwsApiCallError(err error) error {
-	return fmt.Errorf("Problem with AWS API call: %s", err)
+	return fmt.Errorf("Problem with AWS API call: %w", err)
 }
 
 func getDecodedResponse(r http.Response, into interface{}) error {
 	defer r.Body.Close()
 	if err := xml.NewDecoder(r.Body).Decode(into); err != nil {
-		return fmt.Errorf("Error decoding error response: %s", err)
+		return fmt.Errorf("Error decoding error response: %w", err)
 	}
 	return nil
 }
@@ -149,7 +189,9 @@ func NewEC2(auth Auth, region string) *EC2 {
 }
 
 func (e *EC2) awsApiCall(v url.Values) (*http.Response, error) {
-	v.Set("Version", "2014-06-15")
+	if v.Get("Version") == "" {
+		v.Set("Version", "2014-06-15")
+	}
 	log.Debug("Making AWS API call with values:")
 	mcnutils.DumpVal(v)
 	client := &http.Client{}
@@ -160,15 +202,22 @@ func (e *EC2) awsApiCall(v url.Values) (*http.Response, error) {
 	}
 	req.Header.Add("Content-type", "application/json")
 
+	auth := e.Auth
+	if e.Credentials != nil {
+		if auth, err = e.Credentials.Get(); err != nil {
+			return &http.Response{}, err
+		}
+	}
+
 	awsauth.Sign4(req, awsauth.Credentials{
-		AccessKeyID:     e.Auth.AccessKey,
-		SecretAccessKey: e.Auth.SecretKey,
-		SecurityToken:   e.Auth.SessionToken,
+		AccessKeyID:     auth.AccessKey,
+		SecretAccessKey: auth.SecretKey,
+		SecurityToken:   auth.SessionToken,
 	})
 	resp, err := client.Do(req)
 	if err != nil {
 		fmt.Printf("client encountered error while doing the request: %s", err.Error())
-		return resp, fmt.Errorf("client encountered error while doing the request: %s", err)
+		return resp, fmt.Errorf("client encountered error while doing the request: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -177,7 +226,7 @@ func (e *EC2) awsApiCall(v url.Values) (*http.Response, error) {
 	return resp, nil
 }
 
-func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, role string, privateIPOnly bool, monitoring bool) (EC2Instance, error) {
+func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdms []BlockDeviceMapping, role string, privateIPOnly bool, monitoring bool, metadataOptions *InstanceMetadataOptions) (EC2Instance, error) {
 	instance := Instance{}
 	v := url.Values{}
 	v.Set("Action", "RunInstances")
@@ -205,16 +254,11 @@ func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCou
 		v.Set("IamInstanceProfile.Name", role)
 	}
 
-	if bdm != nil {
-		v.Set("BlockDeviceMapping.0.DeviceName", bdm.DeviceName)
-		v.Set("BlockDeviceMapping.0.VirtualName", bdm.VirtualName)
-		v.Set("BlockDeviceMapping.0.Ebs.VolumeSize", strconv.FormatInt(bdm.VolumeSize, 10))
-		v.Set("BlockDeviceMapping.0.Ebs.VolumeType", bdm.VolumeType)
-		deleteOnTerm := 0
-		if bdm.DeleteOnTermination {
-			deleteOnTerm = 1
-		}
-		v.Set("BlockDeviceMapping.0.Ebs.DeleteOnTermination", strconv.Itoa(deleteOnTerm))
+	setBlockDeviceMappings(v, "BlockDeviceMapping.", bdms)
+
+	if metadataOptions != nil {
+		v.Set("Version", recentApiVersion)
+		setMetadataOptions(v, "MetadataOptions.", metadataOptions)
 	}
 
 	resp, err := e.awsApiCall(v)
@@ -231,16 +275,24 @@ func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCou
 	unmarshalledResponse := RunInstancesResponse{}
 	err = xml.Unmarshal(contents, &unmarshalledResponse)
 	if err != nil {
-		return instance.info, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return instance.info, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	instance.info = unmarshalledResponse.Instances[0]
 	return instance.info, nil
 }
 
-func (e *EC2) RequestSpotInstances(amiId string, instanceType string, zone string, instanceCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, role string, spotPrice string, monitoring bool) (string, error) {
+// RequestSpotInstances requests one-time or persistent spot instances.
+// Persistent requests stop their instances when they are interrupted, rather
+// than terminating them, so that they come back with the same volumes.
+func (e *EC2) RequestSpotInstances(amiId string, instanceType string, zone string, instanceCount int, securityGroup string, keyName string, subnetId string, bdms []BlockDeviceMapping, role string, spotPrice string, requestType string, monitoring bool) (string, error) {
 	v := url.Values{}
 	v.Set("Action", "RequestSpotInstances")
+	v.Set("Version", recentApiVersion)
+	v.Set("Type", requestType)
+	if requestType == "persistent" {
+		v.Set("InstanceInterruptionBehavior", "stop")
+	}
 	v.Set("LaunchSpecification.ImageId", amiId)
 	v.Set("LaunchSpecification.Placement.AvailabilityZone", e.Region+zone)
 	v.Set("InstanceCount", strconv.Itoa(instanceCount))
@@ -261,17 +313,7 @@ func (e *EC2) RequestSpotInstances(amiId string, instanceType string, zone strin
 		v.Set("LaunchSpecification.IamInstanceProfile.Name", role)
 	}
 
-	if bdm != nil {
-		v.Set("LaunchSpecification.BlockDeviceMapping.0.DeviceName", bdm.DeviceName)
-		v.Set("LaunchSpecification.BlockDeviceMapping.0.VirtualName", bdm.VirtualName)
-		v.Set("LaunchSpecification.BlockDeviceMapping.0.Ebs.VolumeSize", strconv.FormatInt(bdm.VolumeSize, 10))
-		v.Set("LaunchSpecification.BlockDeviceMapping.0.Ebs.VolumeType", bdm.VolumeType)
-		deleteOnTerm := 0
-		if bdm.DeleteOnTermination {
-			deleteOnTerm = 1
-		}
-		v.Set("LaunchSpecification.BlockDeviceMapping.0.Ebs.DeleteOnTermination", strconv.Itoa(deleteOnTerm))
-	}
+	setBlockDeviceMappings(v, "LaunchSpecification.BlockDeviceMapping.", bdms)
 
 	resp, err := e.awsApiCall(v)
 
@@ -286,36 +328,112 @@ func (e *EC2) RequestSpotInstances(amiId string, instanceType string, zone strin
 	unmarshalledResponse := RequestSpotInstancesResponse{}
 	err = xml.Unmarshal(contents, &unmarshalledResponse)
 	if err != nil {
-		return "", fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return "", fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 	return unmarshalledResponse.SpotInstanceRequestSet[0].SpotInstanceRequestId, nil
 }
 
 func (e *EC2) DescribeSpotInstanceRequests(spotInstanceRequestId string) (string, string, error) {
+	request, err := e.GetSpotInstanceRequest(spotInstanceRequestId)
+	if err != nil {
+		return "", "", err
+	}
+	if code := request.Status.Code; code != "fulfilled" {
+		return code, "", nil
+	}
+	return "fulfilled", request.InstanceId, nil
+}
+
+func (e *EC2) GetSpotInstanceRequest(spotInstanceRequestId string) (SpotInstanceRequest, error) {
 	v := url.Values{}
 	v.Set("Action", "DescribeSpotInstanceRequests")
-	v.Set("SpotInstanceRequestId.0", spotInstanceRequestId)
+	v.Set("Version", recentApiVersion)
+	v.Set("SpotInstanceRequestId.1", spotInstanceRequestId)
 
 	resp, err := e.awsApiCall(v)
 
 	if err != nil {
-		return "", "", newAwsApiCallError(err)
+		return SpotInstanceRequest{}, newAwsApiCallError(err)
 	}
 	defer resp.Body.Close()
 
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", "", fmt.Errorf("Error reading AWS response body")
+		return SpotInstanceRequest{}, fmt.Errorf("Error reading AWS response body")
 	}
 	unmarshalledResponse := DescribeSpotInstanceRequestsResponse{}
 	err = xml.Unmarshal(contents, &unmarshalledResponse)
 	if err != nil {
-		return "", "", fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return SpotInstanceRequest{}, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
-	if code := unmarshalledResponse.SpotInstanceRequestSet[0].Status.Code; code != "fulfilled" {
-		return code, "", nil
+	if len(unmarshalledResponse.SpotInstanceRequestSet) == 0 {
+		return SpotInstanceRequest{}, fmt.Errorf("Spot instance request %s not found", spotInstanceRequestId)
+	}
+	return unmarshalledResponse.SpotInstanceRequestSet[0], nil
+}
+
+func (e *EC2) CancelSpotInstanceRequests(spotInstanceRequestId string) error {
+	v := url.Values{}
+	v.Set("Action", "CancelSpotInstanceRequests")
+	v.Set("SpotInstanceRequestId.1", spotInstanceRequestId)
+
+	if _, err := e.awsApiCall(v); err != nil {
+		return fmt.Errorf("Error making API call to cancel spot instance request: %w", err)
+	}
+	return nil
+}
+
+// ModifyInstanceMetadataOptions changes the instance metadata options of an
+// instance, e.g. of spot instances which cannot be requested with them.
+func (e *EC2) ModifyInstanceMetadataOptions(instanceId string, options InstanceMetadataOptions) error {
+	v := url.Values{}
+	v.Set("Action", "ModifyInstanceMetadataOptions")
+	v.Set("Version", recentApiVersion)
+	v.Set("InstanceId", instanceId)
+	setMetadataOptions(v, "", &options)
+
+	if _, err := e.awsApiCall(v); err != nil {
+		return fmt.Errorf("Error making API call to modify instance metadata options: %w", err)
+	}
+	return nil
+}
+
+// ModifyInstanceType changes the instance type of a stopped instance.
+func (e *EC2) ModifyInstanceType(instanceId string, instanceType string) error {
+	v := url.Values{}
+	v.Set("Action", "ModifyInstanceAttribute")
+	v.Set("InstanceId", instanceId)
+	v.Set("InstanceType.Value", instanceType)
+
+	if _, err := e.awsApiCall(v); err != nil {
+		return fmt.Errorf("Error making API call to modify instance type: %w", err)
+	}
+	return nil
+}
+
+func setBlockDeviceMappings(v url.Values, prefix string, bdms []BlockDeviceMapping) {
+	for i, bdm := range bdms {
+		p := prefix + strconv.Itoa(i) + "."
+		v.Set(p+"DeviceName", bdm.DeviceName)
+		v.Set(p+"VirtualName", bdm.VirtualName)
+		v.Set(p+"Ebs.VolumeSize", strconv.FormatInt(bdm.VolumeSize, 10))
+		v.Set(p+"Ebs.VolumeType", bdm.VolumeType)
+		deleteOnTerm := 0
+		if bdm.DeleteOnTermination {
+			deleteOnTerm = 1
+		}
+		v.Set(p+"Ebs.DeleteOnTermination", strconv.Itoa(deleteOnTerm))
+	}
+}
+
+func setMetadataOptions(v url.Values, prefix string, options *InstanceMetadataOptions) {
+	if options.HttpTokens != "" {
+		v.Set(prefix+"HttpTokens", options.HttpTokens)
 	}
-	return "fulfilled", unmarshalledResponse.SpotInstanceRequestSet[0].InstanceId, nil
+	if options.HttpPutResponseHopLimit > 0 {
+		v.Set(prefix+"HttpPutResponseHopLimit", strconv.Itoa(options.HttpPutResponseHopLimit))
+	}
+	v.Set(prefix+"HttpEndpoint", "enabled")
 }
 
 func (e *EC2) DeleteKeyPair(name string) error {
@@ -325,7 +443,21 @@ func (e *EC2) DeleteKeyPair(name string) error {
 
 	_, err := e.awsApiCall(v)
 	if err != nil {
-		return fmt.Errorf("Error making API call to delete keypair :%s", err)
+		return fmt.Errorf("Error making API call to delete keypair :%w", err)
+	}
+	return nil
+}
+
+// DeleteKeyPairById deletes a key pair by ID, as the resources of tags are.
+func (e *EC2) DeleteKeyPairById(id string) error {
+	v := url.Values{}
+	v.Set("Action", "DeleteKeyPair")
+	v.Set("Version", recentApiVersion)
+	v.Set("KeyPairId", id)
+
+	_, err := e.awsApiCall(v)
+	if err != nil {
+		return fmt.Errorf("Error making API call to delete keypair :%w", err)
 	}
 	return nil
 }
@@ -336,7 +468,7 @@ func (e *EC2) CreateKeyPair(name string) ([]byte, error) {
 	v.Set("KeyName", name)
 	resp, err := e.awsApiCall(v)
 	if err != nil {
-		return nil, fmt.Errorf("Error trying API call to create keypair: %s", err)
+		return nil, fmt.Errorf("Error trying API call to create keypair: %w", err)
 	}
 	defer resp.Body.Close()
 	contents, err := ioutil.ReadAll(resp.Body)
@@ -346,7 +478,7 @@ func (e *EC2) CreateKeyPair(name string) ([]byte, error) {
 
 	unmarshalledResponse := CreateKeyPairResponse{}
 	if xml.Unmarshal(contents, &unmarshalledResponse); err != nil {
-		return nil, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return nil, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	key := unmarshalledResponse.KeyMaterial
@@ -354,7 +486,7 @@ func (e *EC2) CreateKeyPair(name string) ([]byte, error) {
 	return key, nil
 }
 
-func (e *EC2) ImportKeyPair(name, publicKey string) error {
+func (e *EC2) ImportKeyPair(name, publicKey string, tags map[string]string) error {
 	keyMaterial := base64.StdEncoding.EncodeToString([]byte(publicKey))
 
 	v := url.Values{}
@@ -362,9 +494,22 @@ func (e *EC2) ImportKeyPair(name, publicKey string) error {
 	v.Set("KeyName", name)
 	v.Set("PublicKeyMaterial", keyMaterial)
 
+	if len(tags) > 0 {
+		v.Set("Version", recentApiVersion)
+		v.Set("TagSpecification.1.ResourceType", "key-pair")
+
+		counter := 1
+		for k, val := range tags {
+			v.Set(fmt.Sprintf("TagSpecification.1.Tag.%d.Key", counter), k)
+			v.Set(fmt.Sprintf("TagSpecification.1.Tag.%d.Value", counter), val)
+
+			counter += 1
+		}
+	}
+
 	resp, err := e.awsApiCall(v)
 	if err != nil {
-		return fmt.Errorf("Error trying API call to create keypair: %s", err)
+		return fmt.Errorf("Error trying API call to create keypair: %w", err)
 	}
 
 	defer resp.Body.Close()
@@ -375,7 +520,7 @@ func (e *EC2) ImportKeyPair(name, publicKey string) error {
 
 	unmarshalledResponse := ImportKeyPairResponse{}
 	if xml.Unmarshal(contents, &unmarshalledResponse); err != nil {
-		return fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	return nil
@@ -403,12 +548,120 @@ func (e *EC2) CreateTags(id string, tags map[string]string) error {
 	createTagsResponse := &CreateTagsResponse{}
 
 	if err := getDecodedResponse(*resp, &createTagsResponse); err != nil {
-		return fmt.Errorf("Error decoding create tags response: %s", err)
+		return fmt.Errorf("Error decoding create tags response: %w", err)
 	}
 
 	return nil
 }
 
+// DescribeTags returns the tags matching the filters, with the resources
+// they are on.
+func (e *EC2) DescribeTags(filters []Filter) ([]Tag, error) {
+	v := url.Values{}
+	v.Set("Action", "DescribeTags")
+	v.Set("Version", recentApiVersion)
+
+	for idx, filter := range filters {
+		n := idx + 1 // amazon starts counting from 1 not 0
+		v.Set(fmt.Sprintf("Filter.%d.Name", n), filter.Name)
+		v.Set(fmt.Sprintf("Filter.%d.Value.1", n), filter.Value)
+	}
+
+	resp, err := e.awsApiCall(v)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	unmarshalledResponse := DescribeTagsResponse{}
+	if err := getDecodedResponse(*resp, &unmarshalledResponse); err != nil {
+		return nil, fmt.Errorf("Error decoding describe tags response: %w", err)
+	}
+
+	return unmarshalledResponse.TagSet, nil
+}
+
+// DescribeRegions returns the regions enabled for the account.
+func (e *EC2) DescribeRegions() ([]Region, error) {
+	resp, err := e.performStandardAction("DescribeRegions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	unmarshalledResponse := DescribeRegionsResponse{}
+	if err := getDecodedResponse(*resp, &unmarshalledResponse); err != nil {
+		return nil, fmt.Errorf("Error decoding describe regions response: %w", err)
+	}
+
+	return unmarshalledResponse.Regions, nil
+}
+
+// DescribeImages returns the images of the owner matching the filters, each
+// filter being given a single value.
+func (e *EC2) DescribeImages(owner string, filters []Filter) ([]Image, error) {
+	v := url.Values{}
+	v.Set("Action", "DescribeImages")
+	v.Set("Owner.1", owner)
+
+	for idx, filter := range filters {
+		n := idx + 1 // amazon starts counting from 1 not 0
+		v.Set(fmt.Sprintf("Filter.%d.Name", n), filter.Name)
+		v.Set(fmt.Sprintf("Filter.%d.Value.1", n), filter.Value)
+	}
+
+	resp, err := e.awsApiCall(v)
+	if err != nil {
+		return nil, newAwsApiCallError(err)
+	}
+	defer resp.Body.Close()
+
+	unmarshalledResponse := DescribeImagesResponse{}
+	if err := getDecodedResponse(*resp, &unmarshalledResponse); err != nil {
+		return nil, fmt.Errorf("Error decoding describe images response: %w", err)
+	}
+
+	return unmarshalledResponse.Images, nil
+}
+
+// DescribeInstanceTypeOfferings returns the instance types offered in the
+// region of the client, going through every page of them.
+func (e *EC2) DescribeInstanceTypeOfferings() ([]string, error) {
+	instanceTypes := []string{}
+	nextToken := ""
+
+	for {
+		v := url.Values{}
+		v.Set("Action", "DescribeInstanceTypeOfferings")
+		v.Set("Version", recentApiVersion)
+		v.Set("LocationType", "region")
+		if nextToken != "" {
+			v.Set("NextToken", nextToken)
+		}
+
+		resp, err := e.awsApiCall(v)
+		if err != nil {
+			return nil, newAwsApiCallError(err)
+		}
+
+		unmarshalledResponse := DescribeInstanceTypeOfferingsResponse{}
+		err = getDecodedResponse(*resp, &unmarshalledResponse)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding describe instance type offerings response: %w", err)
+		}
+
+		for _, offering := range unmarshalledResponse.Offerings {
+			instanceTypes = append(instanceTypes, offering.InstanceType)
+		}
+
+		if unmarshalledResponse.NextToken == "" {
+			return instanceTypes, nil
+		}
+		nextToken = unmarshalledResponse.NextToken
+	}
+}
+
 func (e *EC2) CreateSecurityGroup(name string, description string, vpcId string) (*SecurityGroup, error) {
 	v := url.Values{}
 	v.Set("Action", "CreateSecurityGroup")
@@ -423,19 +676,19 @@ func (e *EC2) CreateSecurityGroup(name string, description string, vpcId string)
 		if resp.StatusCode == http.StatusBadRequest {
 			var errorResponse ErrorResponse
 			if err := getDecodedResponse(*resp, &errorResponse); err != nil {
-				return nil, fmt.Errorf("Error decoding error response: %s", err)
+				return nil, fmt.Errorf("Error decoding error response: %w", err)
 			}
 			if errorResponse.Errors[0].Code == ErrorDuplicateGroup {
 				return nil, nil
 			}
 		}
-		return nil, fmt.Errorf("Error making API call to create security group: %s", err)
+		return nil, fmt.Errorf("Error making API call to create security group: %w", err)
 	}
 
 	createSecurityGroupResponse := CreateSecurityGroupResponse{}
 
 	if err := getDecodedResponse(*resp, &createSecurityGroupResponse); err != nil {
-		return nil, fmt.Errorf("Error decoding create security groups response: %s", err)
+		return nil, fmt.Errorf("Error decoding create security groups response: %w", err)
 	}
 
 	group := &SecurityGroup{
@@ -460,7 +713,7 @@ func (e *EC2) AuthorizeSecurityGroup(groupId string, permissions []IpPermission)
 	resp, err := e.awsApiCall(v)
 	defer resp.Body.Close()
 	if err != nil {
-		return fmt.Errorf("Error making API call to authorize security group ingress: %s", err)
+		return fmt.Errorf("Error making API call to authorize security group ingress: %w", err)
 	}
 	return nil
 }
@@ -473,13 +726,13 @@ func (e *EC2) DeleteSecurityGroup(groupId string) error {
 	resp, err := e.awsApiCall(v)
 	defer resp.Body.Close()
 	if err != nil {
-		return fmt.Errorf("Error making API call to delete security group: %s", err)
+		return fmt.Errorf("Error making API call to delete security group: %w", err)
 	}
 
 	deleteSecurityGroupResponse := DeleteSecurityGroupResponse{}
 
 	if err := getDecodedResponse(*resp, &deleteSecurityGroupResponse); err != nil {
-		return fmt.Errorf("Error decoding delete security groups response: %s", err)
+		return fmt.Errorf("Error decoding delete security groups response: %w", err)
 	}
 
 	return nil
@@ -494,12 +747,12 @@ func (e *EC2) GetSecurityGroups() ([]SecurityGroup, error) {
 	defer resp.Body.Close()
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return sgs, fmt.Errorf("Error reading AWS response body: %s", err)
+		return sgs, fmt.Errorf("Error reading AWS response body: %w", err)
 	}
 
 	unmarshalledResponse := DescribeSecurityGroupsResponse{}
 	if err = xml.Unmarshal(contents, &unmarshalledResponse); err != nil {
-		return sgs, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return sgs, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	sgs = unmarshalledResponse.SecurityGroupInfo
@@ -537,12 +790,12 @@ func (e *EC2) GetSubnets(filters []Filter) ([]Subnet, error) {
 
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return subnets, fmt.Errorf("Error reading AWS response body: %s", err)
+		return subnets, fmt.Errorf("Error reading AWS response body: %w", err)
 	}
 
 	unmarshalledResponse := DescribeSubnetsResponse{}
 	if err = xml.Unmarshal(contents, &unmarshalledResponse); err != nil {
-		return subnets, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return subnets, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	subnets = unmarshalledResponse.SubnetSet
@@ -559,12 +812,12 @@ func (e *EC2) GetKeyPairs() ([]KeyPair, error) {
 	defer resp.Body.Close()
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return keyPairs, fmt.Errorf("Error reading AWS response body: %s", err)
+		return keyPairs, fmt.Errorf("Error reading AWS response body: %w", err)
 	}
 
 	unmarshalledResponse := DescribeKeyPairsResponse{}
 	if err = xml.Unmarshal(contents, &unmarshalledResponse); err != nil {
-		return keyPairs, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return keyPairs, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	keyPairs = unmarshalledResponse.KeySet
@@ -595,12 +848,12 @@ func (e *EC2) GetInstance(instanceId string) (EC2Instance, error) {
 	defer resp.Body.Close()
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return ec2Instance, fmt.Errorf("Error reading AWS response body: %s", err)
+		return ec2Instance, fmt.Errorf("Error reading AWS response body: %w", err)
 	}
 
 	unmarshalledResponse := DescribeInstancesResponse{}
 	if err = xml.Unmarshal(contents, &unmarshalledResponse); err != nil {
-		return ec2Instance, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+		return ec2Instance, fmt.Errorf("Error unmarshalling AWS response XML: %w", err)
 	}
 
 	if len(unmarshalledResponse.ReservationSet) > 0 {
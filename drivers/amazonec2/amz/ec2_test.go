@@ -0,0 +1,38 @@
+package amz
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func apiResponse(status int, code string) http.Response {
+	body := `<Response><Errors><Error><Code>` + code + `</Code><Message>` + code + ` message</Message></Error></Errors><RequestID>id</RequestID></Response>`
+	return http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(body))}
+}
+
+func TestNewAwsApiResponseErrorCategory(t *testing.T) {
+	cases := []struct {
+		status   int
+		code     string
+		expected mcnerror.Category
+	}{
+		{400, "InstanceLimitExceeded", mcnerror.CategoryDriverQuota},
+		{400, "VcpuLimitExceeded", mcnerror.CategoryDriverQuota},
+		{503, "RequestLimitExceeded", mcnerror.CategoryTransient},
+		{500, "InsufficientInstanceCapacity", mcnerror.CategoryTransient},
+		{401, "AuthFailure", mcnerror.CategoryAuth},
+		{403, "SomethingForbidden", mcnerror.CategoryAuth},
+		{400, "InvalidAMIID.NotFound", mcnerror.CategoryUnknown},
+	}
+
+	for _, c := range cases {
+		err := newAwsApiResponseError(apiResponse(c.status, c.code))
+		assert.Equal(t, c.expected, mcnerror.CategoryOf(err), c.code)
+		assert.Contains(t, err.Error(), c.code+" message")
+	}
+}
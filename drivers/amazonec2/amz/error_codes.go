@@ -3,3 +3,32 @@ package amz
 const (
 	ErrorDuplicateGroup = "InvalidGroup.Duplicate"
 )
+
+// quotaErrorCodes are the codes of the errors of the limits of the account
+// being reached.
+var quotaErrorCodes = map[string]bool{
+	"InstanceLimitExceeded":              true,
+	"VcpuLimitExceeded":                  true,
+	"MaxSpotInstanceCountExceeded":       true,
+	"AddressLimitExceeded":               true,
+	"VolumeLimitExceeded":                true,
+	"SecurityGroupLimitExceeded":         true,
+	"RulesPerSecurityGroupLimitExceeded": true,
+}
+
+// transientErrorCodes are the codes of the errors worth retrying.
+var transientErrorCodes = map[string]bool{
+	"RequestLimitExceeded":         true,
+	"InsufficientInstanceCapacity": true,
+	"Unavailable":                  true,
+	"InternalError":                true,
+	"ServiceUnavailable":           true,
+}
+
+// authErrorCodes are the codes of the errors of the credentials.
+var authErrorCodes = map[string]bool{
+	"AuthFailure":           true,
+	"UnauthorizedOperation": true,
+	"InvalidClientTokenId":  true,
+	"SignatureDoesNotMatch": true,
+}
@@ -0,0 +1,196 @@
+package amz
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	awsauth "github.com/smartystreets/go-aws-auth"
+)
+
+// S3 is a client of the objects of S3 buckets, addressed path-style.
+type S3 struct {
+	Endpoint    string
+	Credentials *Credentials
+	Client      *http.Client
+}
+
+type (
+	s3ErrorResponse struct {
+		Code    string
+		Message string
+	}
+
+	listBucketResult struct {
+		IsTruncated           bool
+		NextContinuationToken string
+		Contents              []struct {
+			Key string
+		}
+	}
+)
+
+// NewS3 returns a client of the buckets of a region.
+func NewS3(credentials *Credentials, region string) *S3 {
+	return &S3{
+		Endpoint:    fmt.Sprintf("https://s3.%s.amazonaws.com", region),
+		Credentials: credentials,
+		Client:      &http.Client{},
+	}
+}
+
+// newS3ResponseError returns the error of a response of S3, as the mcnerror
+// error of its status.
+func newS3ResponseError(r *http.Response) error {
+	defer r.Body.Close()
+
+	var errorResponse s3ErrorResponse
+	if err := xml.NewDecoder(r.Body).Decode(&errorResponse); err != nil {
+		errorResponse.Message = http.StatusText(r.StatusCode)
+	}
+	err := fmt.Errorf("Non-200 S3 response: code=%d message=%s", r.StatusCode, errorResponse.Message)
+
+	if authErrorCodes[errorResponse.Code] {
+		return mcnerror.ErrAuth{Err: err}
+	}
+	return mcnerror.ForHTTPStatus(r.StatusCode, err)
+}
+
+// escapeS3Key escapes the segments of the key for the path of its URL, all
+// their bytes but the unreserved characters, as the path is signed.
+func escapeS3Key(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		var b strings.Builder
+		for j := 0; j < len(segment); j++ {
+			c := segment[j]
+			if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+				b.WriteByte(c)
+				continue
+			}
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *S3) call(method, bucket, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := fmt.Sprintf("%s/%s/%s", s.Endpoint, bucket, escapeS3Key(key))
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	log.Debugf("Making S3 call %s %s", method, u)
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request from client: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	auth, err := s.Credentials.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	awsauth.Sign4(req, awsauth.Credentials{
+		AccessKeyID:     auth.AccessKey,
+		SecretAccessKey: auth.SecretKey,
+		SecurityToken:   auth.SessionToken,
+	})
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, newAwsApiCallError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newS3ResponseError(resp)
+	}
+	return resp, nil
+}
+
+// PutObject stores the data as the object of the key in the bucket.
+func (s *S3) PutObject(bucket, key string, data []byte) error {
+	resp, err := s.call("PUT", bucket, key, nil, data)
+	if err != nil {
+		return fmt.Errorf("Error putting s3://%s/%s: %w", bucket, key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject returns the data of the object of the key in the bucket.
+func (s *S3) GetObject(bucket, key string) ([]byte, error) {
+	resp, err := s.call("GET", bucket, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting s3://%s/%s: %w", bucket, key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting s3://%s/%s: %w", bucket, key, err)
+	}
+	return data, nil
+}
+
+// ListObjects returns the keys of the objects of the bucket starting with
+// the prefix, following the pages of the listing.
+func (s *S3) ListObjects(bucket, prefix string) ([]string, error) {
+	keys := []string{}
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.call("GET", bucket, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing s3://%s/%s: %w", bucket, prefix, err)
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding the listing of s3://%s/%s: %w", bucket, prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// ParseS3URL returns the bucket and the prefix of an s3://bucket/prefix URL.
+func ParseS3URL(rawurl string) (string, string, error) {
+	if !strings.HasPrefix(rawurl, "s3://") {
+		return "", "", fmt.Errorf("Invalid S3 URL %q: expected s3://bucket/prefix", rawurl)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(rawurl, "s3://"), "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("Invalid S3 URL %q: expected s3://bucket/prefix", rawurl)
+	}
+
+	prefix := ""
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+	return parts[0], prefix, nil
+}
@@ -0,0 +1,112 @@
+package amz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestS3(handler http.HandlerFunc) (*S3, func()) {
+	server := httptest.NewServer(handler)
+	s := &S3{
+		Endpoint:    server.URL,
+		Credentials: NewCredentials(CredentialsConfig{AccessKey: "key", SecretKey: "secret"}),
+		Client:      server.Client(),
+	}
+	return s, server.Close
+}
+
+func TestS3Objects(t *testing.T) {
+	objects := map[string]string{}
+	s, cleanup := newTestS3(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=key/")
+
+		switch {
+		case r.Method == "PUT":
+			data, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = string(data)
+		case r.URL.Query().Get("list-type") == "2":
+			assert.Equal(t, "/bucket/", r.URL.Path)
+			assert.Equal(t, "backups/", r.URL.Query().Get("prefix"))
+			if r.URL.Query().Get("continuation-token") == "" {
+				fmt.Fprint(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>next</NextContinuationToken><Contents><Key>backups/a</Key></Contents></ListBucketResult>`)
+				return
+			}
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated><Contents><Key>backups/b</Key></Contents></ListBucketResult>`)
+		default:
+			if r.URL.Path == "/private/key" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+				return
+			}
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			fmt.Fprint(w, data)
+		}
+	})
+	defer cleanup()
+
+	assert.NoError(t, s.PutObject("bucket", "backups/a", []byte("data")))
+
+	data, err := s.GetObject("bucket", "backups/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	keys, err := s.ListObjects("bucket", "backups/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backups/a", "backups/b"}, keys)
+
+	_, err = s.GetObject("bucket", "backups/missing")
+	assert.EqualError(t, err, "Error getting s3://bucket/backups/missing: Non-200 S3 response: code=404 message=The specified key does not exist.")
+
+	_, err = s.GetObject("private", "key")
+	assert.Equal(t, mcnerror.CategoryAuth, mcnerror.CategoryOf(err))
+}
+
+func TestS3EscapedKeys(t *testing.T) {
+	objects := map[string]string{}
+	s, cleanup := newTestS3(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bucket/backups/my%20store/a%2Bb%3Fc%23d", r.URL.EscapedPath())
+
+		if r.Method == "PUT" {
+			data, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = string(data)
+			return
+		}
+		fmt.Fprint(w, objects[r.URL.Path])
+	})
+	defer cleanup()
+
+	assert.NoError(t, s.PutObject("bucket", "backups/my store/a+b?c#d", []byte("data")))
+
+	data, err := s.GetObject("bucket", "backups/my store/a+b?c#d")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestParseS3URL(t *testing.T) {
+	bucket, prefix, err := ParseS3URL("s3://backups/machine/laptop/")
+	assert.NoError(t, err)
+	assert.Equal(t, "backups", bucket)
+	assert.Equal(t, "machine/laptop", prefix)
+
+	bucket, prefix, err = ParseS3URL("s3://backups")
+	assert.NoError(t, err)
+	assert.Equal(t, "backups", bucket)
+	assert.Equal(t, "", prefix)
+
+	_, _, err = ParseS3URL("https://backups")
+	assert.EqualError(t, err, `Invalid S3 URL "https://backups": expected s3://bucket/prefix`)
+
+	_, _, err = ParseS3URL("s3:///prefix")
+	assert.Error(t, err)
+}
@@ -4,3 +4,15 @@ type CreateTagsResponse struct {
 	RequestId string `xml:"requestId"`
 	Return    bool   `xml:"return"`
 }
+
+type DescribeTagsResponse struct {
+	RequestId string `xml:"requestId"`
+	TagSet    []Tag  `xml:"tagSet>item"`
+}
+
+type Tag struct {
+	ResourceId   string `xml:"resourceId"`
+	ResourceType string `xml:"resourceType"`
+	Key          string `xml:"key"`
+	Value        string `xml:"value"`
+}
@@ -0,0 +1,93 @@
+package amazonec2
+
+import (
+	"sort"
+
+	"github.com/docker/machine/drivers/amazonec2/amz"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+const (
+	// canonicalOwnerID is the account publishing the Ubuntu images.
+	canonicalOwnerID = "099720720109"
+
+	// maxListedImages is how many of the most recent Ubuntu images are
+	// listed.
+	maxListedImages = 10
+)
+
+func (d *Driver) DiscoveryFlags() drivers.DiscoveryFlags {
+	return drivers.DiscoveryFlags{
+		Region: "amazonec2-region",
+		Image:  "amazonec2-ami",
+		Size:   "amazonec2-instance-type",
+	}
+}
+
+// ListRegions lists the regions enabled for the account which the driver
+// has a default AMI for, the others being refused by --amazonec2-region.
+func (d *Driver) ListRegions() ([]drivers.Option, error) {
+	regions, err := d.getClient().DescribeRegions()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, r := range regions {
+		if details, ok := regionDetails[r.RegionName]; ok {
+			options = append(options, drivers.Option{Value: r.RegionName, Description: "default AMI " + details.AmiId})
+		}
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Value < options[j].Value })
+
+	return options, nil
+}
+
+// ListImages lists the default AMI of the region, then the most recent
+// Ubuntu server images Canonical published in it.
+func (d *Driver) ListImages(region string) ([]drivers.Option, error) {
+	if _, err := validateAwsRegion(region); err != nil {
+		return nil, err
+	}
+
+	images, err := d.getRegionClient(region).DescribeImages(canonicalOwnerID, []amz.Filter{
+		{Name: "name", Value: "ubuntu/images/hvm-ssd/ubuntu-*-server-*"},
+		{Name: "state", Value: "available"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The creation dates are in ISO 8601, and sort as strings.
+	sort.Slice(images, func(i, j int) bool { return images[i].CreationDate > images[j].CreationDate })
+	if len(images) > maxListedImages {
+		images = images[:maxListedImages]
+	}
+
+	options := []drivers.Option{{Value: regionDetails[region].AmiId, Description: "default of the driver"}}
+	for _, image := range images {
+		options = append(options, drivers.Option{Value: image.ImageId, Description: image.Name})
+	}
+
+	return options, nil
+}
+
+// ListSizes lists the instance types offered in the region.
+func (d *Driver) ListSizes(region string) ([]drivers.Option, error) {
+	if _, err := validateAwsRegion(region); err != nil {
+		return nil, err
+	}
+
+	instanceTypes, err := d.getRegionClient(region).DescribeInstanceTypeOfferings()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(instanceTypes)
+
+	options := []drivers.Option{}
+	for _, instanceType := range instanceTypes {
+		options = append(options, drivers.Option{Value: instanceType})
+	}
+
+	return options, nil
+}
@@ -0,0 +1,49 @@
+package amazonec2
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/machine/drivers/amazonec2/amz"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// GuideFlags asks for the access keys, unless the profile of AWS_PROFILE,
+// or the default profile, of the shared config and credentials files has
+// credentials, then for the region, the instance type and the AMI, the
+// instance types and AMIs depending on the region.
+func (d *Driver) GuideFlags() drivers.GuideFlags {
+	flags := drivers.GuideFlags{
+		Options: []string{"amazonec2-region", "amazonec2-instance-type", "amazonec2-ami"},
+	}
+
+	if !amz.HasProfile(os.Getenv("AWS_PROFILE")) {
+		flags.Credentials = []string{"amazonec2-access-key", "amazonec2-secret-key"}
+	}
+
+	return flags
+}
+
+// ValidateCredentials lists the regions enabled for the account, which
+// needs valid credentials.
+func (d *Driver) ValidateCredentials() error {
+	if _, err := d.getClient().DescribeRegions(); err != nil {
+		return fmt.Errorf("AWS refused the credentials: %w", err)
+	}
+	return nil
+}
+
+// ListOptions lists the regions, and the instance types and images offered
+// in the region of the driver, see the discovery of the driver.
+func (d *Driver) ListOptions(flag string) ([]drivers.Option, error) {
+	switch flag {
+	case "amazonec2-region":
+		return d.ListRegions()
+	case "amazonec2-instance-type":
+		return d.ListSizes(d.Region)
+	case "amazonec2-ami":
+		return d.ListImages(d.Region)
+	}
+
+	return nil, fmt.Errorf("amazonec2 driver cannot list the values of --%s", flag)
+}
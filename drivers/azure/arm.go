@@ -0,0 +1,508 @@
+package azure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultResourceGroup      = "docker-machine"
+	defaultARMSize            = "Standard_D2s_v3"
+	defaultARMImage           = "Canonical:UbuntuServer:16.04-LTS:latest"
+	defaultDiskSKU            = "Standard_LRS"
+	defaultDataDiskSKU        = "Premium_LRS"
+	defaultSpotEvictionPolicy = "Deallocate"
+	virtualNetworkName        = "docker-machine-vnet"
+	subnetName                = "docker-machine"
+)
+
+// diskSKUs are the storage account types of managed disks.
+var diskSKUs = []string{"Standard_LRS", "StandardSSD_LRS", "StandardSSD_ZRS", "Premium_LRS", "Premium_ZRS", "UltraSSD_LRS"}
+
+// usesResourceManager reports whether the machine is deployed with Azure
+// Resource Manager, rather than as a classic cloud service.
+func (d *Driver) usesResourceManager() bool {
+	return d.ClientID != ""
+}
+
+// setVolumeDataDisk makes the volume given with --attach-volume the data disk
+// of the machine, on a Standard_LRS disk for HDD volumes and on one of the
+// SKU of --azure-data-disk-sku otherwise.
+func (d *Driver) setVolumeDataDisk() error {
+	if d.AttachVolume == nil {
+		return nil
+	}
+
+	if d.DataDiskSize > 0 {
+		return fmt.Errorf("Please specify either --attach-volume or --azure-data-disk-size, the volume is the data disk")
+	}
+
+	d.DataDiskSize = d.AttachVolume.Size
+	if d.AttachVolume.Type == drivers.VolumeTypeHDD {
+		d.DataDiskSKU = "Standard_LRS"
+	}
+	return nil
+}
+
+// validateResourceManagerConfig checks the options of machines deployed with
+// Azure Resource Manager.
+func (d *Driver) validateResourceManagerConfig() error {
+	if d.SubscriptionID == "" || d.ClientSecret == "" || d.TenantID == "" {
+		return fmt.Errorf("Please specify azure service principal params using options: --azure-subscription-id, --azure-tenant-id, --azure-client-id and --azure-client-secret")
+	}
+
+	if len(strings.Split(d.Image, ":")) != 4 {
+		return fmt.Errorf("Please specify the Azure image as publisher:offer:sku:version using option --azure-image")
+	}
+
+	if !validDiskSKU(d.DiskSKU) || !validDiskSKU(d.DataDiskSKU) {
+		return fmt.Errorf("Please specify one of %s as disk SKU", strings.Join(diskSKUs, ", "))
+	}
+
+	if d.DiskSKU == "UltraSSD_LRS" {
+		return fmt.Errorf("UltraSSD_LRS disks can only be data disks, use --azure-data-disk-sku")
+	}
+
+	if d.DataDiskSKU == "UltraSSD_LRS" && d.DataDiskSize > 0 && d.AvailabilityZone == "" {
+		return fmt.Errorf("UltraSSD_LRS disks require an availability zone, use --azure-availability-zone")
+	}
+
+	if d.SpotEvictionPolicy != "Deallocate" && d.SpotEvictionPolicy != "Delete" {
+		return fmt.Errorf("Please specify Deallocate or Delete using option --azure-spot-eviction-policy")
+	}
+
+	return nil
+}
+
+func validDiskSKU(sku string) bool {
+	for _, valid := range diskSKUs {
+		if sku == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) getARMClient() *armClient {
+	if d.armClient == nil {
+		d.armClient = newARMClient(d)
+	}
+	return d.armClient
+}
+
+// armLocation returns the location as Resource Manager names it, e.g.
+// "westus" for "West US".
+func (d *Driver) armLocation() string {
+	return strings.ToLower(strings.Replace(d.Location, " ", "", -1))
+}
+
+func (d *Driver) resourcePath(resource string) string {
+	return d.getARMClient().resourceGroupPath(d.ResourceGroup, resource)
+}
+
+func (d *Driver) vmPath() string {
+	return d.resourcePath("Microsoft.Compute/virtualMachines/" + d.MachineName)
+}
+
+func (d *Driver) publicIPPath() string {
+	return d.resourcePath("Microsoft.Network/publicIPAddresses/" + d.MachineName + "-ip")
+}
+
+func (d *Driver) nicPath() string {
+	return d.resourcePath("Microsoft.Network/networkInterfaces/" + d.MachineName + "-nic")
+}
+
+func (d *Driver) nsgPath() string {
+	return d.resourcePath("Microsoft.Network/networkSecurityGroups/" + d.MachineName + "-nsg")
+}
+
+func (d *Driver) osDiskName() string {
+	return d.MachineName + "-osdisk"
+}
+
+func (d *Driver) dataDiskName() string {
+	return d.MachineName + "-datadisk"
+}
+
+func (d *Driver) zones() []string {
+	if d.AvailabilityZone == "" {
+		return nil
+	}
+	return []string{d.AvailabilityZone}
+}
+
+// armPreCreateCheck checks that the credentials work, and that the machine
+// doesn't exist already.
+func (d *Driver) armPreCreateCheck() error {
+	err := d.getARMClient().do("GET", d.vmPath(), computeAPIVersion, nil, nil)
+	if err == nil {
+		return fmt.Errorf("Azure virtual machine %s already exists in resource group %s", d.MachineName, d.ResourceGroup)
+	}
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *Driver) armCreate() error {
+	c := d.getARMClient()
+
+	log.Infof("Creating resource group %s...", d.ResourceGroup)
+	if err := c.do("PUT", d.resourcePath(""), resourcesAPIVersion, map[string]interface{}{
+		"location": d.armLocation(),
+	}, nil); err != nil {
+		return err
+	}
+
+	subnetID, err := d.ensureSubnet()
+	if err != nil {
+		return err
+	}
+
+	log.Info("Creating network security group...")
+	if err := c.do("PUT", d.nsgPath(), networkAPIVersion, d.tagged(d.nsgRequest()), nil); err != nil {
+		return err
+	}
+
+	log.Info("Creating public IP address...")
+	if err := c.do("PUT", d.publicIPPath(), networkAPIVersion, d.tagged(map[string]interface{}{
+		"location": d.armLocation(),
+		"zones":    d.zones(),
+		"sku":      map[string]string{"name": "Standard"},
+		"properties": map[string]interface{}{
+			"publicIPAllocationMethod": "Static",
+		},
+	}), nil); err != nil {
+		return err
+	}
+
+	log.Info("Creating network interface...")
+	if err := c.do("PUT", d.nicPath(), networkAPIVersion, d.tagged(map[string]interface{}{
+		"location": d.armLocation(),
+		"properties": map[string]interface{}{
+			"networkSecurityGroup": map[string]string{"id": d.nsgPath()},
+			"ipConfigurations": []interface{}{
+				map[string]interface{}{
+					"name": "ipconfig",
+					"properties": map[string]interface{}{
+						"privateIPAllocationMethod": "Dynamic",
+						"subnet":                    map[string]string{"id": subnetID},
+						"publicIPAddress":           map[string]string{"id": d.publicIPPath()},
+					},
+				},
+			},
+		},
+	}), nil); err != nil {
+		return err
+	}
+
+	log.Info("Generating SSH key...")
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+	publicKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return err
+	}
+
+	log.Info("Creating virtual machine...")
+	if err := c.do("PUT", d.vmPath(), computeAPIVersion, d.tagged(d.vmRequest(string(publicKey))), nil); err != nil {
+		return err
+	}
+
+	if err := d.tagDisks(); err != nil {
+		return err
+	}
+
+	d.IPAddress, err = d.armPublicIP()
+	return err
+}
+
+// tagged adds the tags given with --tag to a resource to create. The resource
+// group and virtual network, which machines may share, are left untagged.
+func (d *Driver) tagged(request map[string]interface{}) map[string]interface{} {
+	if len(d.ResourceTags) > 0 {
+		request["tags"] = d.ResourceTags
+	}
+	return request
+}
+
+// tagDisks tags the managed disks of the virtual machine, which are created
+// with it without its tags.
+func (d *Driver) tagDisks() error {
+	if len(d.ResourceTags) == 0 {
+		return nil
+	}
+
+	disks := []string{d.osDiskName()}
+	if d.DataDiskSize > 0 {
+		disks = append(disks, d.dataDiskName())
+	}
+
+	for _, disk := range disks {
+		if err := d.getARMClient().do("PATCH", d.resourcePath("Microsoft.Compute/disks/"+disk), computeAPIVersion, map[string]interface{}{
+			"tags": d.ResourceTags,
+		}, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureSubnet returns the ID of the subnet of the machines, creating the
+// virtual network they share if it doesn't exist.
+func (d *Driver) ensureSubnet() (string, error) {
+	c := d.getARMClient()
+	vnetPath := d.resourcePath("Microsoft.Network/virtualNetworks/" + virtualNetworkName)
+	subnetID := vnetPath + "/subnets/" + subnetName
+
+	err := c.do("GET", vnetPath, networkAPIVersion, nil, nil)
+	if err == nil {
+		return subnetID, nil
+	}
+	if !isNotFound(err) {
+		return "", err
+	}
+
+	log.Infof("Creating virtual network %s...", virtualNetworkName)
+	return subnetID, c.do("PUT", vnetPath, networkAPIVersion, map[string]interface{}{
+		"location": d.armLocation(),
+		"properties": map[string]interface{}{
+			"addressSpace": map[string]interface{}{
+				"addressPrefixes": []string{"10.0.0.0/16"},
+			},
+			"subnets": []interface{}{
+				map[string]interface{}{
+					"name": subnetName,
+					"properties": map[string]string{
+						"addressPrefix": "10.0.0.0/24",
+					},
+				},
+			},
+		},
+	}, nil)
+}
+
+// nsgRequest returns the network security group of the machine, opening the
+// SSH, Docker and Swarm master ports.
+func (d *Driver) nsgRequest() map[string]interface{} {
+	ports := []int{22, d.DockerPort}
+	if d.SwarmMaster {
+		ports = append(ports, d.DockerSwarmMasterPort)
+	}
+
+	rules := []interface{}{}
+	for i, port := range ports {
+		rules = append(rules, map[string]interface{}{
+			"name": fmt.Sprintf("allow-%d", port),
+			"properties": map[string]interface{}{
+				"priority":                 1000 + i,
+				"direction":                "Inbound",
+				"access":                   "Allow",
+				"protocol":                 "Tcp",
+				"sourceAddressPrefix":      "*",
+				"sourcePortRange":          "*",
+				"destinationAddressPrefix": "*",
+				"destinationPortRange":     fmt.Sprint(port),
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"location": d.armLocation(),
+		"properties": map[string]interface{}{
+			"securityRules": rules,
+		},
+	}
+}
+
+// vmRequest returns the virtual machine to create.
+func (d *Driver) vmRequest(publicKey string) map[string]interface{} {
+	image := strings.Split(d.Image, ":")
+
+	osDisk := map[string]interface{}{
+		"name":         d.osDiskName(),
+		"createOption": "FromImage",
+		"managedDisk":  map[string]string{"storageAccountType": d.DiskSKU},
+	}
+	if d.DiskSize > 0 {
+		osDisk["diskSizeGB"] = d.DiskSize
+	}
+
+	storageProfile := map[string]interface{}{
+		"imageReference": map[string]string{
+			"publisher": image[0],
+			"offer":     image[1],
+			"sku":       image[2],
+			"version":   image[3],
+		},
+		"osDisk": osDisk,
+	}
+
+	properties := map[string]interface{}{
+		"hardwareProfile": map[string]string{"vmSize": d.Size},
+		"storageProfile":  storageProfile,
+		"osProfile": map[string]interface{}{
+			"computerName":  d.MachineName,
+			"adminUsername": d.GetSSHUsername(),
+			"linuxConfiguration": map[string]interface{}{
+				"disablePasswordAuthentication": true,
+				"ssh": map[string]interface{}{
+					"publicKeys": []interface{}{
+						map[string]string{
+							"path":    fmt.Sprintf("/home/%s/.ssh/authorized_keys", d.GetSSHUsername()),
+							"keyData": publicKey,
+						},
+					},
+				},
+			},
+		},
+		"networkProfile": map[string]interface{}{
+			"networkInterfaces": []interface{}{
+				map[string]string{"id": d.nicPath()},
+			},
+		},
+	}
+
+	if d.DataDiskSize > 0 {
+		storageProfile["dataDisks"] = []interface{}{
+			map[string]interface{}{
+				"lun":          0,
+				"name":         d.dataDiskName(),
+				"createOption": "Empty",
+				"diskSizeGB":   d.DataDiskSize,
+				"managedDisk":  map[string]string{"storageAccountType": d.DataDiskSKU},
+			},
+		}
+		if d.DataDiskSKU == "UltraSSD_LRS" {
+			properties["additionalCapabilities"] = map[string]bool{"ultraSSDEnabled": true}
+		}
+	}
+
+	if d.Spot {
+		properties["priority"] = "Spot"
+		properties["evictionPolicy"] = d.SpotEvictionPolicy
+		properties["billingProfile"] = map[string]float64{"maxPrice": d.SpotMaxPrice}
+	}
+
+	request := map[string]interface{}{
+		"location":   d.armLocation(),
+		"properties": properties,
+	}
+	if zones := d.zones(); zones != nil {
+		request["zones"] = zones
+	}
+	return request
+}
+
+func (d *Driver) armPublicIP() (string, error) {
+	ip := struct {
+		Properties struct {
+			IPAddress string `json:"ipAddress"`
+		} `json:"properties"`
+	}{}
+	if err := d.getARMClient().do("GET", d.publicIPPath(), networkAPIVersion, nil, &ip); err != nil {
+		return "", err
+	}
+	if ip.Properties.IPAddress == "" {
+		return "", fmt.Errorf("Azure public IP address of %s is not assigned yet", d.MachineName)
+	}
+	return ip.Properties.IPAddress, nil
+}
+
+func (d *Driver) armGetState() (state.State, error) {
+	view := struct {
+		Statuses []struct {
+			Code string `json:"code"`
+		} `json:"statuses"`
+	}{}
+	if err := d.getARMClient().do("GET", d.vmPath()+"/instanceView", computeAPIVersion, nil, &view); err != nil {
+		if isNotFound(err) {
+			return state.Error, fmt.Errorf("Azure virtual machine %s was not found", d.MachineName)
+		}
+		return state.Error, err
+	}
+
+	for _, status := range view.Statuses {
+		switch status.Code {
+		case "PowerState/running":
+			return state.Running, nil
+		case "PowerState/starting":
+			return state.Starting, nil
+		case "PowerState/stopping", "PowerState/deallocating":
+			return state.Stopping, nil
+		case "PowerState/stopped", "PowerState/deallocated":
+			return state.Stopped, nil
+		}
+	}
+	return state.None, nil
+}
+
+func (d *Driver) armAction(action string) error {
+	return d.getARMClient().do("POST", d.vmPath()+"/"+action, computeAPIVersion, nil, nil)
+}
+
+// armResize changes the size of the virtual machine.
+func (d *Driver) armResize(size string) error {
+	body := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"hardwareProfile": map[string]string{"vmSize": size},
+		},
+	}
+	return d.getARMClient().do("PATCH", d.vmPath(), computeAPIVersion, body, nil)
+}
+
+// armRemove deletes the virtual machine and the resources created with it.
+// The resource group and the virtual network are shared by the machines, and
+// are kept.
+func (d *Driver) armRemove() error {
+	c := d.getARMClient()
+
+	resources := []struct {
+		kind, path, apiVersion string
+	}{
+		{"virtual machine", d.vmPath(), computeAPIVersion},
+		{"OS disk", d.resourcePath("Microsoft.Compute/disks/" + d.osDiskName()), disksAPIVersion},
+		{"data disk", d.resourcePath("Microsoft.Compute/disks/" + d.dataDiskName()), disksAPIVersion},
+		{"network interface", d.nicPath(), networkAPIVersion},
+		{"network security group", d.nsgPath(), networkAPIVersion},
+		{"public IP address", d.publicIPPath(), networkAPIVersion},
+	}
+
+	for _, resource := range resources {
+		log.Debugf("Deleting Azure %s...", resource.kind)
+		if err := c.do("DELETE", resource.path, resource.apiVersion, nil, nil); err != nil {
+			if isNotFound(err) {
+				log.Infof("Azure %s doesn't exist, assuming it is already deleted", resource.kind)
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// armIP returns the public IP address of the machine.
+func (d *Driver) armIP() (string, error) {
+	if d.IPAddress != "" {
+		return d.IPAddress, nil
+	}
+
+	s, err := d.armGetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	return d.armPublicIP()
+}
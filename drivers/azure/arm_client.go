@@ -0,0 +1,245 @@
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	defaultLoginEndpoint      = "https://login.microsoftonline.com"
+	defaultManagementEndpoint = "https://management.azure.com"
+
+	resourcesAPIVersion = "2021-04-01"
+	networkAPIVersion   = "2021-02-01"
+	computeAPIVersion   = "2021-07-01"
+	disksAPIVersion     = "2021-04-01"
+)
+
+// armClient talks to the Azure Resource Manager API, authenticated as a
+// service principal.
+type armClient struct {
+	loginEndpoint      string
+	managementEndpoint string
+	tenantID           string
+	clientID           string
+	clientSecret       string
+	subscriptionID     string
+	http               *http.Client
+
+	token       string
+	tokenExpiry time.Time
+}
+
+// armError is an error returned by the Azure Resource Manager API.
+type armError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *armError) Error() string {
+	return fmt.Sprintf("Azure API error (%s): %s", e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*armError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+func newARMClient(d *Driver) *armClient {
+	return &armClient{
+		loginEndpoint:      defaultLoginEndpoint,
+		managementEndpoint: defaultManagementEndpoint,
+		tenantID:           d.TenantID,
+		clientID:           d.ClientID,
+		clientSecret:       d.ClientSecret,
+		subscriptionID:     d.SubscriptionID,
+		http:               &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// authorize gets an access token for the service principal, unless the one
+// it has is still valid.
+func (c *armClient) authorize() error {
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+	form.Set("resource", defaultManagementEndpoint+"/")
+
+	res, err := c.http.PostForm(fmt.Sprintf("%s/%s/oauth2/token", c.loginEndpoint, c.tenantID), form)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	token := struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresOn        string `json:"expires_on"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return fmt.Errorf("Error decoding Azure token response: %s", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error authenticating with Azure: %s", token.ErrorDescription)
+	}
+
+	expiresOn, _ := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	c.token = token.AccessToken
+	c.tokenExpiry = time.Unix(expiresOn, 0).Add(-5 * time.Minute)
+	return nil
+}
+
+// resourceGroupPath returns the path of a resource of the resource group,
+// e.g. "Microsoft.Network/publicIPAddresses/default-ip".
+func (c *armClient) resourceGroupPath(resourceGroup, resource string) string {
+	path := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", c.subscriptionID, resourceGroup)
+	if resource != "" {
+		path += "/providers/" + resource
+	}
+	return path
+}
+
+// do sends a request to the API, waits for the operation it starts if it
+// is a long running one, and decodes the response into out when it is not
+// nil.
+func (c *armClient) do(method, path, apiVersion string, body interface{}, out interface{}) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	res, err := c.send(method, c.managementEndpoint+path+separator+"api-version="+apiVersion, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out != nil && res.StatusCode != http.StatusAccepted && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return err
+		}
+	}
+
+	if operation := res.Header.Get("Azure-AsyncOperation"); operation != "" {
+		return c.waitOperation(operation)
+	}
+	if location := res.Header.Get("Location"); location != "" && res.StatusCode == http.StatusAccepted {
+		return c.waitLocation(location)
+	}
+	return nil
+}
+
+func (c *armClient) send(method, url string, body interface{}) (*http.Response, error) {
+	if err := c.authorize(); err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Debugf("Azure request: %s %s", method, strings.SplitN(url, "?", 2)[0])
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		e := struct {
+			Error armError `json:"error"`
+		}{}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error.Message == "" {
+			e.Error.Code = "Unknown"
+			e.Error.Message = res.Status
+		}
+		e.Error.StatusCode = res.StatusCode
+		return nil, &e.Error
+	}
+
+	return res, nil
+}
+
+// waitOperation polls the status of an asynchronous operation until it is
+// done.
+func (c *armClient) waitOperation(operation string) error {
+	for {
+		res, err := c.send("GET", operation, nil)
+		if err != nil {
+			return err
+		}
+
+		status := struct {
+			Status string   `json:"status"`
+			Error  armError `json:"error"`
+		}{}
+		err = json.NewDecoder(res.Body).Decode(&status)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		switch status.Status {
+		case "Succeeded":
+			return nil
+		case "Failed", "Canceled":
+			if status.Error.Message == "" {
+				status.Error.Message = "operation " + strings.ToLower(status.Status)
+			}
+			return &status.Error
+		}
+
+		time.Sleep(armPollInterval)
+	}
+}
+
+// waitLocation polls the location of an asynchronous operation until it is
+// not accepted anymore.
+func (c *armClient) waitLocation(location string) error {
+	for {
+		res, err := c.send("GET", location, nil)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusAccepted {
+			return nil
+		}
+
+		time.Sleep(armPollInterval)
+	}
+}
+
+// armPollInterval is how often the status of asynchronous operations is
+// checked.
+var armPollInterval = 5 * time.Second
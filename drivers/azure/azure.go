@@ -3,8 +3,10 @@ package azure
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	azure "github.com/MSOpenTech/azure-sdk-for-go"
@@ -29,6 +31,22 @@ type Driver struct {
 	Image                   string
 	DockerPort              int
 	DockerSwarmMasterPort   int
+
+	// The options of machines deployed with Azure Resource Manager.
+	TenantID           string
+	ClientID           string
+	ClientSecret       string
+	ResourceGroup      string
+	AvailabilityZone   string
+	DiskSKU            string
+	DiskSize           int
+	DataDiskSKU        string
+	DataDiskSize       int
+	Spot               bool
+	SpotEvictionPolicy string
+	SpotMaxPrice       float64
+
+	armClient *armClient
 }
 
 const (
@@ -100,6 +118,71 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "Azure username",
 			Value: defaultSSHUsername,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_TENANT_ID",
+			Name:   "azure-tenant-id",
+			Usage:  "Azure Active Directory tenant ID of the service principal",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_CLIENT_ID",
+			Name:   "azure-client-id",
+			Usage:  "Azure service principal client ID, to deploy with Azure Resource Manager",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_CLIENT_SECRET",
+			Name:   "azure-client-secret",
+			Usage:  "Azure service principal client secret",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_RESOURCE_GROUP",
+			Name:   "azure-resource-group",
+			Usage:  "Azure resource group of the machine",
+			Value:  defaultResourceGroup,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_AVAILABILITY_ZONE",
+			Name:   "azure-availability-zone",
+			Usage:  "Azure availability zone of the machine (e.g. 1, 2 or 3)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_DISK_SKU",
+			Name:   "azure-disk-sku",
+			Usage:  "Azure managed OS disk SKU (Standard_LRS, StandardSSD_LRS, Premium_LRS...)",
+			Value:  defaultDiskSKU,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "AZURE_DISK_SIZE",
+			Name:   "azure-disk-size",
+			Usage:  "Azure managed OS disk size in GB. Defaults to the size of the image",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_DATA_DISK_SKU",
+			Name:   "azure-data-disk-sku",
+			Usage:  "Azure managed data disk SKU (Premium_LRS, UltraSSD_LRS...)",
+			Value:  defaultDataDiskSKU,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "AZURE_DATA_DISK_SIZE",
+			Name:   "azure-data-disk-size",
+			Usage:  "Azure managed data disk size in GB. No data disk is attached when 0",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "AZURE_SPOT",
+			Name:   "azure-spot",
+			Usage:  "Create an Azure spot virtual machine",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_SPOT_EVICTION_POLICY",
+			Name:   "azure-spot-eviction-policy",
+			Usage:  "Azure spot virtual machine eviction policy, Deallocate or Delete",
+			Value:  defaultSpotEvictionPolicy,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "AZURE_SPOT_MAX_PRICE",
+			Name:   "azure-spot-max-price",
+			Usage:  "Azure spot virtual machine maximum price in US dollars per hour, -1 to pay up to the on-demand price",
+			Value:  "-1",
+		},
 	}
 }
 
@@ -109,6 +192,11 @@ func NewDriver(hostName, storePath string) drivers.Driver {
 		DockerSwarmMasterPort: defaultSwarmMasterPort,
 		Location:              defaultLocation,
 		Size:                  defaultSize,
+		ResourceGroup:         defaultResourceGroup,
+		DiskSKU:               defaultDiskSKU,
+		DataDiskSKU:           defaultDataDiskSKU,
+		SpotEvictionPolicy:    defaultSpotEvictionPolicy,
+		SpotMaxPrice:          -1,
 		BaseDriver: &drivers.BaseDriver{
 			SSHPort:     defaultSSHPort,
 			SSHUser:     defaultSSHUsername,
@@ -135,6 +223,15 @@ func (d *Driver) DriverName() string {
 	return "azure"
 }
 
+// Capabilities adds the spot virtual machines of Resource Manager of the
+// driver to those of its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.Spot = d.usesResourceManager()
+	capabilities.Tags = d.usesResourceManager()
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SubscriptionID = flags.String("azure-subscription-id")
 
@@ -157,18 +254,27 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		d.PublishSettingsFilePath = publishSettings
 	}
 
-	if (d.SubscriptionID == "" || d.SubscriptionCert == "") && d.PublishSettingsFilePath == "" {
+	d.TenantID = flags.String("azure-tenant-id")
+	d.ClientID = flags.String("azure-client-id")
+	d.ClientSecret = flags.String("azure-client-secret")
+
+	if !d.usesResourceManager() && (d.SubscriptionID == "" || d.SubscriptionCert == "") && d.PublishSettingsFilePath == "" {
 		return errors.New("Please specify azure subscription params using options: --azure-subscription-id and --azure-subscription-cert or --azure-publish-settings-file")
 	}
 
-	if image == "" {
-		d.Image = "b39f27a8b8c64d52b05eac6a62ebad85__Ubuntu-14_04_1-LTS-amd64-server-20140927-en-us-30GB"
-	} else {
+	if image != "" {
 		d.Image = image
+	} else if d.usesResourceManager() {
+		d.Image = defaultARMImage
+	} else {
+		d.Image = "b39f27a8b8c64d52b05eac6a62ebad85__Ubuntu-14_04_1-LTS-amd64-server-20140927-en-us-30GB"
 	}
 
 	d.Location = flags.String("azure-location")
 	d.Size = flags.String("azure-size")
+	if d.usesResourceManager() && d.Size == defaultSize {
+		d.Size = defaultARMSize
+	}
 
 	if strings.ToLower(username) == "docker" {
 		return errors.New("'docker' is not valid user name for docker host. Please specify another user name")
@@ -182,11 +288,60 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+
+	d.ResourceGroup = flags.String("azure-resource-group")
+	d.AvailabilityZone = flags.String("azure-availability-zone")
+	d.DiskSKU = flags.String("azure-disk-sku")
+	d.DiskSize = flags.Int("azure-disk-size")
+	d.DataDiskSKU = flags.String("azure-data-disk-sku")
+	d.DataDiskSize = flags.Int("azure-data-disk-size")
+	d.Spot = flags.Bool("azure-spot")
+	d.SpotEvictionPolicy = flags.String("azure-spot-eviction-policy")
+
+	maxPrice, err := strconv.ParseFloat(flags.String("azure-spot-max-price"), 64)
+	if err != nil {
+		return fmt.Errorf("Please specify a price in US dollars using option --azure-spot-max-price")
+	}
+	d.SpotMaxPrice = maxPrice
+
+	volume, err := drivers.ParseVolume(flags.String("attach-volume"))
+	if err != nil {
+		return err
+	}
+	d.AttachVolume = volume
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+
+	if d.usesResourceManager() {
+		// Resource Manager machines have their own IP address, with SSH
+		// on its default port.
+		d.SSHPort = defaultSSHPort
+		if err := d.setVolumeDataDisk(); err != nil {
+			return err
+		}
+		return d.validateResourceManagerConfig()
+	}
+
+	// The management certificate of classic machines is made from their key.
+	if d.SSHKeyType != "" && d.SSHKeyType != ssh.KeyTypeRSA {
+		return errors.New("Azure classic machines require RSA SSH keys, use --ssh-key-type rsa")
+	}
 
 	return nil
 }
 
 func (d *Driver) PreCreateCheck() error {
+	if d.usesResourceManager() {
+		return d.armPreCreateCheck()
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return err
 	}
@@ -205,6 +360,10 @@ func (d *Driver) PreCreateCheck() error {
 }
 
 func (d *Driver) Create() error {
+	if d.usesResourceManager() {
+		return d.armCreate()
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return err
 	}
@@ -240,15 +399,30 @@ func (d *Driver) Create() error {
 }
 
 func (d *Driver) GetURL() (string, error) {
+	if d.usesResourceManager() {
+		ip, err := d.armIP()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(d.DockerPort))), nil
+	}
+
 	url := fmt.Sprintf("tcp://%s:%v", d.getHostname(), d.DockerPort)
 	return url, nil
 }
 
 func (d *Driver) GetIP() (string, error) {
+	if d.usesResourceManager() {
+		return d.armIP()
+	}
 	return d.getHostname(), nil
 }
 
 func (d *Driver) GetState() (state.State, error) {
+	if d.usesResourceManager() {
+		return d.armGetState()
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return state.Error, err
 	}
@@ -276,6 +450,10 @@ func (d *Driver) GetState() (state.State, error) {
 }
 
 func (d *Driver) Start() error {
+	if d.usesResourceManager() {
+		return d.armAction("start")
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return err
 	}
@@ -298,7 +476,13 @@ func (d *Driver) Start() error {
 	return err
 }
 
+// Stop deallocates Resource Manager machines, so that their compute
+// resources are not billed anymore.
 func (d *Driver) Stop() error {
+	if d.usesResourceManager() {
+		return d.armAction("deallocate")
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return err
 	}
@@ -320,7 +504,78 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+// SupportsResize reports whether the machine is a Resource Manager one, as
+// classic ones can't change size.
+func (d *Driver) SupportsResize() bool {
+	return d.usesResourceManager()
+}
+
+// SupportsVolumes reports whether the machine is a Resource Manager one, as
+// volumes are their data disk.
+func (d *Driver) SupportsVolumes() bool {
+	return d.usesResourceManager()
+}
+
+// SupportsPricing reports whether the machine is a Resource Manager one, as
+// classic sizes are retired.
+func (d *Driver) SupportsPricing() bool {
+	return d.usesResourceManager()
+}
+
+// Pricing returns the size of the VM and its managed disks. The OS disk
+// is sized after the image, 30GB for the Linux images, unless given.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	diskSize := d.DiskSize
+	if diskSize == 0 {
+		diskSize = 30
+	}
+
+	pricing := drivers.Pricing{
+		Region:       d.Location,
+		InstanceType: d.Size,
+		Spot:         d.Spot,
+		Storage: []drivers.PricedStorage{
+			{Type: d.DiskSKU, Size: diskSize},
+		},
+	}
+
+	if d.DataDiskSize > 0 {
+		pricing.Storage = append(pricing.Storage, drivers.PricedStorage{Type: d.DataDiskSKU, Size: d.DataDiskSize})
+	}
+
+	return pricing, nil
+}
+
+// GetVolumeDevices returns the path of the data disk, at LUN 0, which the
+// Azure Linux agent links.
+func (d *Driver) GetVolumeDevices() ([]string, error) {
+	return []string{"/dev/disk/azure/scsi1/lun0"}, nil
+}
+
+// Resize changes the size of the virtual machine, which must be
+// deallocated.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if !d.usesResourceManager() {
+		return drivers.ErrResizeNotImplemented
+	}
+
+	if opts.CPUs > 0 || opts.Memory > 0 || opts.DiskSize > 0 {
+		return drivers.ErrResizeInstanceTypeOnly
+	}
+
+	if err := d.armResize(opts.InstanceType); err != nil {
+		return err
+	}
+
+	d.Size = opts.InstanceType
+	return nil
+}
+
 func (d *Driver) Remove() error {
+	if d.usesResourceManager() {
+		return d.armRemove()
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return err
 	}
@@ -336,6 +591,10 @@ func (d *Driver) Remove() error {
 }
 
 func (d *Driver) Restart() error {
+	if d.usesResourceManager() {
+		return d.armAction("restart")
+	}
+
 	err := d.setUserSubscription()
 	if err != nil {
 		return err
@@ -357,6 +616,10 @@ func (d *Driver) Restart() error {
 }
 
 func (d *Driver) Kill() error {
+	if d.usesResourceManager() {
+		return d.armAction("powerOff?skipShutdown=true")
+	}
+
 	if err := d.setUserSubscription(); err != nil {
 		return err
 	}
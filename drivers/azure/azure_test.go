@@ -0,0 +1,435 @@
+package azure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const testVMPath = "/subscriptions/sub/resourceGroups/docker-machine/providers/Microsoft.Compute/virtualMachines/default"
+
+// fakeAzure answers requests to the Azure Resource Manager API with canned
+// responses keyed by method and path, after those of its login endpoint.
+type fakeAzure struct {
+	*jsonapitest.API
+	url string
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/tenant/oauth2/token" {
+		r.ParseForm()
+		if r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error_description": "invalid client secret"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "token", "expires_on": "4070908800"}`)
+		return
+	}
+
+	f.API.ServeHTTP(w, r)
+}
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+const (
+	serverID = "11111111-1111-1111-1111-111111111111"
+	imageID  = "22222222-2222-2222-2222-222222222222"
+	ipID     = "33333333-3333-3333-3333-333333333333"
+)
+
+func newTestDriver(responses map[string]string) (*Driver, *fakeAzure, func()) {
+	armPollInterval = 0
+
+	fake := &fakeAzure{API: jsonapitest.New(responses)}
+	fake.ByPath = true
+	fake.Authorized = func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer token" }
+	fake.NotFound = `{"error": {"code": "ResourceNotFound", "message": "resource not found"}}`
+	server := httptest.NewServer(fake)
+	fake.url = server.URL
+
+	d := NewDriver("default", "").(*Driver)
+	d.SubscriptionID = "sub"
+	d.TenantID = "tenant"
+	d.ClientID = "client"
+	d.ClientSecret = "secret"
+	d.Image = defaultARMImage
+	d.Size = defaultARMSize
+	d.armClient = newARMClient(d)
+	d.armClient.loginEndpoint = server.URL
+	d.armClient.managementEndpoint = server.URL
+	d.armClient.http = server.Client()
+
+	return d, fake, server.Close
+}
+
+func resourceManagerFlags() DriverOptionsMock {
+	return DriverOptionsMock{
+		Data: map[string]interface{}{
+			"azure-subscription-id":      "sub",
+			"azure-tenant-id":            "tenant",
+			"azure-client-id":            "client",
+			"azure-client-secret":        "secret",
+			"azure-location":             "West Europe",
+			"azure-size":                 defaultSize,
+			"azure-username":             "ubuntu",
+			"azure-ssh-port":             2222,
+			"azure-docker-port":          2376,
+			"azure-resource-group":       "docker-machine",
+			"azure-disk-sku":             defaultDiskSKU,
+			"azure-data-disk-sku":        defaultDataDiskSKU,
+			"azure-spot-eviction-policy": defaultSpotEvictionPolicy,
+			"azure-spot-max-price":       "-1",
+		},
+	}
+}
+
+func TestSetConfigFromFlagsResourceManager(t *testing.T) {
+	d := NewDriver("default", "").(*Driver)
+
+	err := d.SetConfigFromFlags(resourceManagerFlags())
+
+	assert.NoError(t, err)
+	assert.True(t, d.usesResourceManager())
+	assert.Equal(t, defaultARMImage, d.Image)
+	assert.Equal(t, defaultARMSize, d.Size)
+	assert.Equal(t, 22, d.SSHPort)
+	assert.Equal(t, float64(-1), d.SpotMaxPrice)
+	assert.Equal(t, "westeurope", d.armLocation())
+}
+
+func TestSetConfigFromFlagsResourceManagerErrors(t *testing.T) {
+	var tests = []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"azure-tenant-id", "", "Please specify azure service principal params using options: --azure-subscription-id, --azure-tenant-id, --azure-client-id and --azure-client-secret"},
+		{"azure-image", "Canonical:UbuntuServer", "Please specify the Azure image as publisher:offer:sku:version using option --azure-image"},
+		{"azure-disk-sku", "Premium", "Please specify one of Standard_LRS, StandardSSD_LRS, StandardSSD_ZRS, Premium_LRS, Premium_ZRS, UltraSSD_LRS as disk SKU"},
+		{"azure-disk-sku", "UltraSSD_LRS", "UltraSSD_LRS disks can only be data disks, use --azure-data-disk-sku"},
+		{"azure-spot-eviction-policy", "Stop", "Please specify Deallocate or Delete using option --azure-spot-eviction-policy"},
+		{"azure-spot-max-price", "cheap", "Please specify a price in US dollars using option --azure-spot-max-price"},
+	}
+
+	for _, expected := range tests {
+		flags := resourceManagerFlags()
+		flags.Data[expected.key] = expected.value
+
+		err := NewDriver("default", "").(*Driver).SetConfigFromFlags(flags)
+
+		assert.EqualError(t, err, expected.err)
+	}
+
+	flags := resourceManagerFlags()
+	flags.Data["azure-data-disk-sku"] = "UltraSSD_LRS"
+	flags.Data["azure-data-disk-size"] = 64
+	err := NewDriver("default", "").(*Driver).SetConfigFromFlags(flags)
+	assert.EqualError(t, err, "UltraSSD_LRS disks require an availability zone, use --azure-availability-zone")
+
+	flags.Data["azure-availability-zone"] = "2"
+	assert.NoError(t, NewDriver("default", "").(*Driver).SetConfigFromFlags(flags))
+}
+
+func TestSetConfigFromFlagsAttachVolume(t *testing.T) {
+	flags := resourceManagerFlags()
+	flags.Data["attach-volume"] = "size=100,type=hdd"
+	d := NewDriver("default", "").(*Driver)
+
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.True(t, d.SupportsVolumes())
+	assert.Equal(t, 100, d.DataDiskSize)
+	assert.Equal(t, "Standard_LRS", d.DataDiskSKU)
+
+	flags.Data["attach-volume"] = "size=100"
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+	assert.Equal(t, defaultDataDiskSKU, d.DataDiskSKU)
+
+	flags.Data["azure-data-disk-size"] = 64
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "Please specify either --attach-volume or --azure-data-disk-size, the volume is the data disk")
+}
+
+func TestVMRequest(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.AvailabilityZone = "2"
+	d.DiskSKU = "Premium_LRS"
+	d.DiskSize = 64
+	d.DataDiskSKU = "UltraSSD_LRS"
+	d.DataDiskSize = 256
+	d.Spot = true
+	d.SpotEvictionPolicy = "Delete"
+	d.SpotMaxPrice = 0.05
+
+	request := d.vmRequest("ssh-rsa AAAA")
+	properties := request["properties"].(map[string]interface{})
+	storage := properties["storageProfile"].(map[string]interface{})
+
+	assert.Equal(t, []string{"2"}, request["zones"])
+	assert.Equal(t, map[string]interface{}{
+		"name":         "default-osdisk",
+		"createOption": "FromImage",
+		"diskSizeGB":   64,
+		"managedDisk":  map[string]string{"storageAccountType": "Premium_LRS"},
+	}, storage["osDisk"])
+	assert.Equal(t, map[string]string{
+		"publisher": "Canonical",
+		"offer":     "UbuntuServer",
+		"sku":       "16.04-LTS",
+		"version":   "latest",
+	}, storage["imageReference"])
+	assert.Equal(t, 1, len(storage["dataDisks"].([]interface{})))
+	assert.Equal(t, map[string]bool{"ultraSSDEnabled": true}, properties["additionalCapabilities"])
+	assert.Equal(t, "Spot", properties["priority"])
+	assert.Equal(t, "Delete", properties["evictionPolicy"])
+	assert.Equal(t, map[string]float64{"maxPrice": 0.05}, properties["billingProfile"])
+
+	d.AvailabilityZone = ""
+	d.DataDiskSize = 0
+	d.Spot = false
+	request = d.vmRequest("ssh-rsa AAAA")
+	properties = request["properties"].(map[string]interface{})
+
+	_, zones := request["zones"]
+	_, dataDisks := properties["storageProfile"].(map[string]interface{})["dataDisks"]
+	_, priority := properties["priority"]
+	_, capabilities := properties["additionalCapabilities"]
+	assert.False(t, zones)
+	assert.False(t, dataDisks)
+	assert.False(t, priority)
+	assert.False(t, capabilities)
+}
+
+func TestCreate(t *testing.T) {
+	rg := "/subscriptions/sub/resourceGroups/docker-machine"
+	d, fake, done := newTestDriver(map[string]string{
+		"PUT " + rg: `{}`,
+		"PUT " + rg + "/providers/Microsoft.Network/virtualNetworks/docker-machine-vnet": `{}`,
+		"PUT " + rg + "/providers/Microsoft.Network/networkSecurityGroups/default-nsg":   `{}`,
+		"PUT " + rg + "/providers/Microsoft.Network/publicIPAddresses/default-ip":        `{}`,
+		"GET " + rg + "/providers/Microsoft.Network/publicIPAddresses/default-ip":        `{"properties": {"ipAddress": "203.0.113.10"}}`,
+		"PUT " + rg + "/providers/Microsoft.Network/networkInterfaces/default-nic":       `{}`,
+		"PUT " + testVMPath: `{"properties": {"provisioningState": "Creating"}}`,
+		"GET /operations/1": `{"status": "Succeeded"}`,
+	})
+	defer done()
+	fake.Headers["PUT "+testVMPath] = http.Header{"Azure-Asyncoperation": {fake.url + "/operations/1"}}
+
+	storePath, err := ioutil.TempDir("", "azure-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+	d.StorePath = storePath
+	os.MkdirAll(filepath.Join(storePath, "machines", "default"), 0700)
+
+	assert.NoError(t, d.Create())
+	assert.Equal(t, "203.0.113.10", d.IPAddress)
+	assert.Equal(t, []string{
+		"PUT " + rg,
+		"GET " + rg + "/providers/Microsoft.Network/virtualNetworks/docker-machine-vnet",
+		"PUT " + rg + "/providers/Microsoft.Network/virtualNetworks/docker-machine-vnet",
+		"PUT " + rg + "/providers/Microsoft.Network/networkSecurityGroups/default-nsg",
+		"PUT " + rg + "/providers/Microsoft.Network/publicIPAddresses/default-ip",
+		"PUT " + rg + "/providers/Microsoft.Network/networkInterfaces/default-nic",
+		"PUT " + testVMPath,
+		"GET /operations/1",
+		"GET " + rg + "/providers/Microsoft.Network/publicIPAddresses/default-ip",
+	}, fake.Requests)
+
+	nic := fake.Bodies["PUT "+rg+"/providers/Microsoft.Network/networkInterfaces/default-nic"]
+	assert.Equal(t, rg+"/providers/Microsoft.Network/networkSecurityGroups/default-nsg", nic["properties"].(map[string]interface{})["networkSecurityGroup"].(map[string]interface{})["id"])
+}
+
+func TestAsyncOperationFailure(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST " + testVMPath + "/start": "",
+		"GET /operations/2":             `{"status": "Failed", "error": {"code": "AllocationFailed", "message": "no capacity"}}`,
+	})
+	defer done()
+	fake.Statuses["POST "+testVMPath+"/start"] = http.StatusAccepted
+	fake.Headers["POST "+testVMPath+"/start"] = http.Header{"Azure-Asyncoperation": {fake.url + "/operations/2"}}
+
+	assert.EqualError(t, d.Start(), "Azure API error (AllocationFailed): no capacity")
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		code  string
+		state state.State
+	}{
+		{"PowerState/running", state.Running},
+		{"PowerState/starting", state.Starting},
+		{"PowerState/deallocating", state.Stopping},
+		{"PowerState/deallocated", state.Stopped},
+		{"PowerState/stopped", state.Stopped},
+		{"PowerState/unknown", state.None},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(map[string]string{
+			"GET " + testVMPath + "/instanceView": fmt.Sprintf(`{"statuses": [{"code": "ProvisioningState/succeeded"}, {"code": %q}]}`, expected.code),
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestKill(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST " + testVMPath + "/powerOff": "",
+		"GET /operations/3":                `{}`,
+	})
+	defer done()
+	fake.Statuses["POST "+testVMPath+"/powerOff"] = http.StatusAccepted
+	fake.Headers["POST "+testVMPath+"/powerOff"] = http.Header{"Location": {fake.url + "/operations/3"}}
+
+	assert.NoError(t, d.Kill())
+	assert.Equal(t, []string{"POST " + testVMPath + "/powerOff", "GET /operations/3"}, fake.Requests)
+}
+
+func TestRemove(t *testing.T) {
+	rg := "/subscriptions/sub/resourceGroups/docker-machine/providers"
+	d, fake, done := newTestDriver(map[string]string{
+		"DELETE " + testVMPath: `{}`,
+		"DELETE " + rg + "/Microsoft.Compute/disks/default-osdisk":              `{}`,
+		"DELETE " + rg + "/Microsoft.Network/networkInterfaces/default-nic":     `{}`,
+		"DELETE " + rg + "/Microsoft.Network/networkSecurityGroups/default-nsg": `{}`,
+		"DELETE " + rg + "/Microsoft.Network/publicIPAddresses/default-ip":      `{}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{
+		"DELETE " + testVMPath,
+		"DELETE " + rg + "/Microsoft.Compute/disks/default-osdisk",
+		"DELETE " + rg + "/Microsoft.Compute/disks/default-datadisk",
+		"DELETE " + rg + "/Microsoft.Network/networkInterfaces/default-nic",
+		"DELETE " + rg + "/Microsoft.Network/networkSecurityGroups/default-nsg",
+		"DELETE " + rg + "/Microsoft.Network/publicIPAddresses/default-ip",
+	}, fake.Requests)
+}
+
+func TestResize(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"PATCH " + testVMPath: `{}`,
+	})
+	defer done()
+
+	assert.True(t, d.SupportsResize())
+	assert.NoError(t, d.Resize(drivers.ResizeOptions{InstanceType: "Standard_D4s_v3"}))
+	assert.Equal(t, "Standard_D4s_v3", d.Size)
+	assert.Equal(t, map[string]interface{}{
+		"hardwareProfile": map[string]interface{}{"vmSize": "Standard_D4s_v3"},
+	}, fake.Bodies["PATCH "+testVMPath]["properties"])
+
+	assert.Equal(t, drivers.ErrResizeInstanceTypeOnly, d.Resize(drivers.ResizeOptions{Memory: 8192}))
+}
+
+func TestTagDisks(t *testing.T) {
+	rg := "/subscriptions/sub/resourceGroups/docker-machine"
+	d, fake, done := newTestDriver(map[string]string{
+		"PATCH " + rg + "/providers/Microsoft.Compute/disks/default-osdisk":   `{}`,
+		"PATCH " + rg + "/providers/Microsoft.Compute/disks/default-datadisk": `{}`,
+	})
+	defer done()
+	d.DataDiskSize = 100
+	d.ResourceTags = map[string]string{"team": "infra"}
+
+	assert.NoError(t, d.tagDisks())
+	assert.Equal(t, []string{
+		"PATCH " + rg + "/providers/Microsoft.Compute/disks/default-osdisk",
+		"PATCH " + rg + "/providers/Microsoft.Compute/disks/default-datadisk",
+	}, fake.Requests)
+	assert.Equal(t, map[string]interface{}{"team": "infra"}, fake.Bodies["PATCH "+rg+"/providers/Microsoft.Compute/disks/default-osdisk"]["tags"])
+	assert.Equal(t, map[string]string{"team": "infra"}, d.tagged(map[string]interface{}{})["tags"])
+}
+
+func TestCapabilities(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+
+	capabilities := d.Capabilities()
+	assert.True(t, capabilities.Spot)
+	assert.True(t, capabilities.Resize)
+	assert.True(t, capabilities.Volumes)
+	assert.True(t, capabilities.Tags)
+
+	classic := NewDriver("default", "").(*Driver)
+	assert.Equal(t, drivers.Capabilities{}, classic.Capabilities())
+}
+
+func TestPricing(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.Location = "eastus"
+	d.Size = "Standard_B2s"
+	d.DataDiskSize = 100
+
+	pricing, err := drivers.GetPricing(d)
+
+	assert.NoError(t, err)
+	assert.Equal(t, drivers.Pricing{
+		Region:       "eastus",
+		InstanceType: "Standard_B2s",
+		Storage: []drivers.PricedStorage{
+			{Type: "Standard_LRS", Size: 30},
+			{Type: "Premium_LRS", Size: 100},
+		},
+	}, pricing)
+
+	classic := NewDriver("default", "").(*Driver)
+	_, err = drivers.GetPricing(classic)
+	assert.Equal(t, drivers.ErrPricingNotImplemented, err)
+}
+
+func TestAuthenticationError(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.armClient.clientSecret = "wrong"
+
+	_, err := d.GetState()
+
+	assert.EqualError(t, err, "Error authenticating with Azure: invalid client secret")
+}
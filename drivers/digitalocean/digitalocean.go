@@ -3,6 +3,9 @@ package digitalocean
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"code.google.com/p/goauth2/oauth"
@@ -26,6 +29,15 @@ type Driver struct {
 	IPv6              bool
 	Backups           bool
 	PrivateNetworking bool
+	Volumes           []string
+	VolumeSize        int
+	VolumeID          string
+	VPC               string
+	ReservedIP        string
+	IPv6Address       string
+
+	// client overrides the client of the API, for tests.
+	client *godo.Client
 }
 
 const (
@@ -82,6 +94,26 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "digitalocean-backups",
 			Usage:  "enable backups for droplet",
 		},
+		mcnflag.StringSliceFlag{
+			Name:  "digitalocean-volume",
+			Usage: "Name or ID of a block storage volume to attach to the droplet",
+			Value: []string{},
+		},
+		mcnflag.IntFlag{
+			EnvVar: "DIGITALOCEAN_VOLUME_SIZE",
+			Name:   "digitalocean-volume-size",
+			Usage:  "Size in GB of a block storage volume to create and attach to the droplet, deleted with it",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "DIGITALOCEAN_VPC",
+			Name:   "digitalocean-vpc",
+			Usage:  "Name or UUID of the VPC of the droplet",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "DIGITALOCEAN_RESERVED_IP",
+			Name:   "digitalocean-reserved-ip",
+			Usage:  "Reserved IP to assign to the droplet, used as its address",
+		},
 	}
 }
 
@@ -105,6 +137,140 @@ func (d *Driver) DriverName() string {
 	return "digitalocean"
 }
 
+// Capabilities adds the reserved IPs of the driver to those of its optional
+// interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.StaticIP = true
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the size of the droplet, whose disk is included, and the
+// volume created with it.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	pricing := drivers.Pricing{
+		Region:       d.Region,
+		InstanceType: d.Size,
+	}
+
+	if d.VolumeSize > 0 {
+		pricing.Storage = append(pricing.Storage, drivers.PricedStorage{Type: "volume", Size: d.VolumeSize})
+	}
+
+	return pricing, nil
+}
+
+// Plan returns the resources Create would allocate, the existing volumes and
+// VPC being only looked up by Create.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	droplet := fmt.Sprintf("%s from %s in %s", d.Size, d.Image, d.Region)
+	if d.Backups {
+		droplet += ", with backups"
+	}
+
+	resources := []drivers.PlannedResource{
+		{Kind: "droplet", Description: droplet},
+		{Kind: "ssh-key", Description: d.MachineName},
+	}
+
+	for _, volume := range d.Volumes {
+		resources = append(resources, drivers.PlannedResource{Kind: "volume", Description: "existing volume " + volume})
+	}
+	if d.VolumeSize > 0 {
+		resources = append(resources, drivers.PlannedResource{Kind: "volume", Description: fmt.Sprintf("%dGB volume %s", d.VolumeSize, d.volumeName())})
+	}
+
+	network := "public network"
+	if d.IPv6 {
+		network += ", with IPv6"
+	}
+	if d.PrivateNetworking {
+		network += ", with private networking"
+	}
+	if d.VPC != "" {
+		network += ", in VPC " + d.VPC
+	}
+	resources = append(resources, drivers.PlannedResource{Kind: "network", Description: network})
+
+	if d.ReservedIP != "" {
+		resources = append(resources, drivers.PlannedResource{Kind: "reserved-ip", Description: d.ReservedIP})
+	}
+
+	return resources, nil
+}
+
+// TerraformResources returns the droplet, SSH key and volume Create made for
+// the host. The existing volumes, VPC and reserved IP it was given are left
+// out, Terraform referring to them by IDs the driver doesn't keep.
+func (d *Driver) TerraformResources() ([]drivers.TerraformResource, error) {
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return nil, err
+	}
+
+	sshKey := drivers.TerraformResource{
+		Type: "digitalocean_ssh_key",
+		Name: drivers.TerraformName(d.MachineName),
+		ID:   strconv.Itoa(d.SSHKeyID),
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "name", Value: drivers.TerraformString(d.MachineName)},
+			{Name: "public_key", Value: drivers.TerraformString(strings.TrimSpace(string(publicKey)))},
+		},
+	}
+
+	droplet := drivers.TerraformResource{
+		Type: "digitalocean_droplet",
+		Name: drivers.TerraformName(d.MachineName),
+		ID:   strconv.Itoa(d.DropletID),
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "name", Value: drivers.TerraformString(d.MachineName)},
+			{Name: "image", Value: drivers.TerraformString(d.Image)},
+			{Name: "region", Value: drivers.TerraformString(d.Region)},
+			{Name: "size", Value: drivers.TerraformString(d.Size)},
+			{Name: "ipv6", Value: drivers.TerraformBool(d.IPv6)},
+			{Name: "backups", Value: drivers.TerraformBool(d.Backups)},
+			{Name: "ssh_keys", Value: drivers.TerraformList(drivers.TerraformReference(sshKey, "id"))},
+		},
+	}
+
+	tags := []string{}
+	for _, tag := range d.resourceTags() {
+		tags = append(tags, drivers.TerraformString(tag))
+	}
+	if len(tags) > 0 {
+		droplet.Attributes = append(droplet.Attributes, drivers.TerraformAttribute{Name: "tags", Value: drivers.TerraformList(tags...)})
+	}
+
+	resources := []drivers.TerraformResource{sshKey}
+
+	if d.VolumeID != "" {
+		volume := drivers.TerraformResource{
+			Type: "digitalocean_volume",
+			Name: drivers.TerraformName(d.volumeName()),
+			ID:   d.VolumeID,
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "name", Value: drivers.TerraformString(d.volumeName())},
+				{Name: "region", Value: drivers.TerraformString(d.Region)},
+				{Name: "size", Value: drivers.TerraformNumber(int64(d.VolumeSize))},
+				{Name: "initial_filesystem_type", Value: drivers.TerraformString("ext4")},
+				{Name: "description", Value: drivers.TerraformString("Docker Machine volume of " + d.MachineName)},
+			},
+		}
+		if len(tags) > 0 {
+			volume.Attributes = append(volume.Attributes, drivers.TerraformAttribute{Name: "tags", Value: drivers.TerraformList(tags...)})
+		}
+		resources = append(resources, volume)
+
+		droplet.Attributes = append(droplet.Attributes, drivers.TerraformAttribute{
+			Name:  "volume_ids",
+			Value: drivers.TerraformList(drivers.TerraformReference(volume, "id")),
+		})
+	}
+
+	return append(resources, droplet), nil
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AccessToken = flags.String("digitalocean-access-token")
 	d.Image = flags.String("digitalocean-image")
@@ -113,9 +279,16 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.IPv6 = flags.Bool("digitalocean-ipv6")
 	d.PrivateNetworking = flags.Bool("digitalocean-private-networking")
 	d.Backups = flags.Bool("digitalocean-backups")
+	d.Volumes = flags.StringSlice("digitalocean-volume")
+	d.VolumeSize = flags.Int("digitalocean-volume-size")
+	d.VPC = flags.String("digitalocean-vpc")
+	d.ReservedIP = flags.String("digitalocean-reserved-ip")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
 	d.SSHUser = flags.String("digitalocean-ssh-user")
 	d.SSHPort = 22
 
@@ -123,22 +296,86 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		return fmt.Errorf("digitalocean driver requires the --digitalocean-access-token option")
 	}
 
+	if d.VolumeSize < 0 {
+		return fmt.Errorf("digitalocean driver requires a positive --digitalocean-volume-size")
+	}
+
+	if d.ReservedIP != "" && net.ParseIP(d.ReservedIP) == nil {
+		return fmt.Errorf("digitalocean driver requires --digitalocean-reserved-ip to be an IP address")
+	}
+
+	volume, err := drivers.ParseVolume(flags.String("attach-volume"))
+	if err != nil {
+		return err
+	}
+	d.AttachVolume = volume
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+
+	// The volume given with --attach-volume is the one created with the
+	// droplet, Digital Ocean volumes all being SSDs.
+	if d.AttachVolume != nil {
+		if d.VolumeSize > 0 {
+			return fmt.Errorf("digitalocean driver requires either --attach-volume or --digitalocean-volume-size")
+		}
+		if d.AttachVolume.Type == drivers.VolumeTypeHDD {
+			return fmt.Errorf("digitalocean driver only supports ssd volumes with --attach-volume")
+		}
+		d.VolumeSize = d.AttachVolume.Size
+	}
+
 	return nil
 }
 
+// GetVolumeDevices returns the path Digital Ocean gives the volume created
+// with the droplet from its name.
+func (d *Driver) GetVolumeDevices() ([]string, error) {
+	return []string{"/dev/disk/by-id/scsi-0DO_Volume_" + d.volumeName()}, nil
+}
+
 func (d *Driver) PreCreateCheck() error {
 	client := d.getClient()
 	regions, _, err := client.Regions.List(nil)
 	if err != nil {
 		return err
 	}
+	validRegion := false
 	for _, region := range regions {
 		if region.Slug == d.Region {
-			return nil
+			validRegion = true
+		}
+	}
+	if !validRegion {
+		return fmt.Errorf("digitalocean requires a valid region")
+	}
+
+	for _, volume := range d.Volumes {
+		if _, err := d.volumeID(volume); err != nil {
+			return err
+		}
+	}
+
+	if d.VPC != "" {
+		if _, err := d.vpcID(d.VPC); err != nil {
+			return err
+		}
+	}
+
+	if d.ReservedIP != "" {
+		ip, err := d.getReservedIP()
+		if err != nil {
+			return err
+		}
+		if ip.Region.Slug != d.Region {
+			return fmt.Errorf("Digital Ocean reserved IP %s is in region %s, not %s", d.ReservedIP, ip.Region.Slug, d.Region)
 		}
 	}
 
-	return fmt.Errorf("digitalocean requires a valid region")
+	return nil
 }
 
 func (d *Driver) Create() error {
@@ -151,23 +388,17 @@ func (d *Driver) Create() error {
 
 	d.SSHKeyID = key.ID
 
+	createRequest, err := d.dropletCreateRequest()
+	if err != nil {
+		return err
+	}
+
 	log.Infof("Creating Digital Ocean droplet...")
 
 	client := d.getClient()
 
-	createRequest := &godo.DropletCreateRequest{
-		Image:             d.Image,
-		Name:              d.MachineName,
-		Region:            d.Region,
-		Size:              d.Size,
-		IPv6:              d.IPv6,
-		PrivateNetworking: d.PrivateNetworking,
-		Backups:           d.Backups,
-		SSHKeys:           []interface{}{d.SSHKeyID},
-	}
-
-	newDroplet, _, err := client.Droplets.Create(createRequest)
-	if err != nil {
+	newDroplet := &godo.DropletRoot{}
+	if _, err := d.apiRequest("POST", "v2/droplets", createRequest, newDroplet); err != nil {
 		return err
 	}
 
@@ -179,19 +410,27 @@ func (d *Driver) Create() error {
 		if err != nil {
 			return err
 		}
-		for _, network := range newDroplet.Droplet.Networks.V4 {
-			if network.Type == "public" {
-				d.IPAddress = network.IPAddress
-			}
+		d.IPAddress = publicIP(newDroplet.Droplet.Networks.V4)
+		if d.IPv6 {
+			d.IPv6Address = publicIP(newDroplet.Droplet.Networks.V6)
 		}
 
-		if d.IPAddress != "" {
+		// Reserved IPs can only be assigned to active droplets.
+		if d.IPAddress != "" && (d.ReservedIP == "" || newDroplet.Droplet.Status == "active") {
 			break
 		}
 
 		time.Sleep(1 * time.Second)
 	}
 
+	if d.ReservedIP != "" {
+		log.Infof("Assigning reserved IP %s to the Droplet...", d.ReservedIP)
+		if err := d.assignReservedIP(); err != nil {
+			return err
+		}
+		d.IPAddress = d.ReservedIP
+	}
+
 	log.Debugf("Created droplet ID %d, IP address %s",
 		newDroplet.Droplet.ID,
 		d.IPAddress)
@@ -199,8 +438,55 @@ func (d *Driver) Create() error {
 	return nil
 }
 
+// dropletCreateRequest returns the request creating the droplet, with the
+// volumes and VPC resolved to their IDs, and the volume of the droplet
+// created if it has one.
+func (d *Driver) dropletCreateRequest() (*dropletCreateRequest, error) {
+	request := &dropletCreateRequest{
+		DropletCreateRequest: godo.DropletCreateRequest{
+			Image:             d.Image,
+			Name:              d.MachineName,
+			Region:            d.Region,
+			Size:              d.Size,
+			IPv6:              d.IPv6,
+			PrivateNetworking: d.PrivateNetworking,
+			Backups:           d.Backups,
+			SSHKeys:           []interface{}{d.SSHKeyID},
+		},
+		Tags: d.resourceTags(),
+	}
+
+	for _, volume := range d.Volumes {
+		id, err := d.volumeID(volume)
+		if err != nil {
+			return nil, err
+		}
+		request.Volumes = append(request.Volumes, id)
+	}
+
+	if d.VolumeSize > 0 {
+		log.Infof("Creating %d GB volume...", d.VolumeSize)
+		id, err := d.createVolume()
+		if err != nil {
+			return nil, err
+		}
+		d.VolumeID = id
+		request.Volumes = append(request.Volumes, id)
+	}
+
+	if d.VPC != "" {
+		id, err := d.vpcID(d.VPC)
+		if err != nil {
+			return nil, err
+		}
+		request.VPCUUID = id
+	}
+
+	return request, nil
+}
+
 func (d *Driver) createSSHKey() (*godo.Key, error) {
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return nil, err
 	}
 
@@ -227,7 +513,7 @@ func (d *Driver) GetURL() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -237,6 +523,22 @@ func (d *Driver) GetIP() (string, error) {
 	return d.IPAddress, nil
 }
 
+// GetIPv6 returns the public IPv6 address of the droplet, if it has IPv6.
+func (d *Driver) GetIPv6() (string, error) {
+	return d.IPv6Address, nil
+}
+
+// publicIP returns the address of the droplet on the public network, if
+// it's already assigned.
+func publicIP(networks []godo.Network) string {
+	for _, network := range networks {
+		if network.Type == "public" {
+			return network.IPAddress
+		}
+	}
+	return ""
+}
+
 func (d *Driver) GetState() (state.State, error) {
 	droplet, _, err := d.getClient().Droplets.Get(d.DropletID)
 	if err != nil {
@@ -263,6 +565,20 @@ func (d *Driver) Stop() error {
 	return err
 }
 
+// Resize changes the size of the droplet, which must be powered off.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if opts.CPUs > 0 || opts.Memory > 0 || opts.DiskSize > 0 {
+		return drivers.ErrResizeInstanceTypeOnly
+	}
+
+	if err := d.resizeDroplet(opts.InstanceType); err != nil {
+		return err
+	}
+
+	d.Size = opts.InstanceType
+	return nil
+}
+
 func (d *Driver) Remove() error {
 	client := d.getClient()
 	if resp, err := client.Keys.DeleteByID(d.SSHKeyID); err != nil {
@@ -279,9 +595,97 @@ func (d *Driver) Remove() error {
 			return err
 		}
 	}
+	if d.VolumeID != "" {
+		return d.deleteVolume(d.VolumeID)
+	}
 	return nil
 }
 
+// deleteVolume deletes a volume created with a droplet, once the droplet
+// deletion detached it.
+func (d *Driver) deleteVolume(id string) error {
+	for attempt := 0; ; attempt++ {
+		resp, err := d.apiRequest("DELETE", "v2/volumes/"+id, nil, nil)
+		switch {
+		case err == nil:
+			return nil
+		case isNotFound(resp):
+			log.Infof("Digital Ocean volume doesn't exist, assuming it is already deleted")
+			return nil
+		case resp != nil && (resp.StatusCode == 409 || resp.StatusCode == 422) && attempt < volumeDeleteAttempts:
+			log.Debugf("Digital Ocean volume is still attached: %s", err)
+			time.Sleep(actionPollInterval)
+		default:
+			return err
+		}
+	}
+}
+
+// volumeDeleteAttempts is how many times deleting the volume is attempted
+// while it is still attached to the deleted droplet.
+const volumeDeleteAttempts = 60
+
+// OrphanedResources returns the droplets and volumes tagged for machines of
+// the store which aren't one of machines, the droplets first for their
+// volumes to be detached when removed. The SSH keys, which have no tags, are
+// left out.
+func (d *Driver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	storeTag := tagInvalidChars.ReplaceAllString(drivers.TagMachineStore+":"+storeID, "_")
+
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[tagInvalidChars.ReplaceAllString(machine, "_")] = true
+	}
+
+	droplets := struct {
+		Droplets []struct {
+			ID   int      `json:"id"`
+			Tags []string `json:"tags"`
+		} `json:"droplets"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/droplets?per_page=200", nil, &droplets); err != nil {
+		return nil, err
+	}
+
+	volumes := struct {
+		Volumes []volume `json:"volumes"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/volumes?per_page=200", nil, &volumes); err != nil {
+		return nil, err
+	}
+
+	resources := []drivers.OrphanedResource{}
+	for _, droplet := range droplets.Droplets {
+		if machine := machineTag(droplet.Tags); machine != "" && !known[machine] && hasTag(droplet.Tags, storeTag) {
+			resources = append(resources, drivers.OrphanedResource{Kind: "droplet", ID: strconv.Itoa(droplet.ID), Machine: machine})
+		}
+	}
+	for _, volume := range volumes.Volumes {
+		if machine := machineTag(volume.Tags); machine != "" && !known[machine] && hasTag(volume.Tags, storeTag) {
+			resources = append(resources, drivers.OrphanedResource{Kind: "volume", ID: volume.ID, Machine: machine})
+		}
+	}
+
+	return resources, nil
+}
+
+// RemoveOrphanedResource removes a resource OrphanedResources returned.
+func (d *Driver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	switch resource.Kind {
+	case "droplet":
+		id, err := strconv.Atoi(resource.ID)
+		if err != nil {
+			return err
+		}
+		_, err = d.getClient().Droplets.Delete(id)
+		return err
+	case "volume":
+		return d.deleteVolume(resource.ID)
+	}
+
+	return fmt.Errorf("Unknown kind of resource %q", resource.Kind)
+}
+
 func (d *Driver) Restart() error {
 	_, _, err := d.getClient().DropletActions.Reboot(d.DropletID)
 	return err
@@ -293,6 +697,10 @@ func (d *Driver) Kill() error {
 }
 
 func (d *Driver) getClient() *godo.Client {
+	if d.client != nil {
+		return d.client
+	}
+
 	t := &oauth.Transport{
 		Token: &oauth.Token{AccessToken: d.AccessToken},
 	}
@@ -0,0 +1,399 @@
+package digitalocean
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+func newTestDriver(responses map[string]string) (*Driver, *jsonapitest.API, func()) {
+	fake := jsonapitest.New(responses)
+	fake.NotFound = `{"id": "not_found", "message": "The resource you were accessing could not be found."}`
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.AccessToken = "token"
+	d.DropletID = 42
+	d.SSHKeyID = 99
+	d.Region = "nyc3"
+	d.client = godo.NewClient(server.Client())
+	d.client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	return d, fake, server.Close
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"digitalocean-access-token": "token",
+			"digitalocean-volume":       []string{"data", "logs"},
+			"digitalocean-volume-size":  10,
+			"digitalocean-vpc":          "backend",
+			"digitalocean-reserved-ip":  "203.0.113.10",
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data", "logs"}, d.Volumes)
+	assert.Equal(t, 10, d.VolumeSize)
+	assert.Equal(t, "backend", d.VPC)
+	assert.Equal(t, "203.0.113.10", d.ReservedIP)
+}
+
+func TestSetConfigFromFlagsErrors(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"digitalocean-access-token": "token",
+			"digitalocean-volume-size":  -1,
+		},
+	})
+	assert.EqualError(t, err, "digitalocean driver requires a positive --digitalocean-volume-size")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"digitalocean-access-token": "token",
+			"digitalocean-reserved-ip":  "stable",
+		},
+	})
+	assert.EqualError(t, err, "digitalocean driver requires --digitalocean-reserved-ip to be an IP address")
+}
+
+func TestSetConfigFromFlagsAttachVolume(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"digitalocean-access-token": "token",
+			"attach-volume":             "size=100",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, d.VolumeSize)
+
+	devices, err := d.GetVolumeDevices()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dev/disk/by-id/scsi-0DO_Volume_default-volume"}, devices)
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"digitalocean-access-token": "token",
+			"attach-volume":             "size=100,type=hdd",
+		},
+	})
+	assert.EqualError(t, err, "digitalocean driver only supports ssd volumes with --attach-volume")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"digitalocean-access-token": "token",
+			"digitalocean-volume-size":  10,
+			"attach-volume":             "size=100",
+		},
+	})
+	assert.EqualError(t, err, "digitalocean driver requires either --attach-volume or --digitalocean-volume-size")
+}
+
+func TestDropletCreateRequestTags(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /v2/volumes": `{"volume": {"id": "22222222-2222-2222-2222-222222222222", "name": "default-volume"}}`,
+	})
+	defer done()
+
+	d.VolumeSize = 10
+	d.ResourceTags = map[string]string{"team": "infra", "machine-version": "0.5.0"}
+
+	request, err := d.dropletCreateRequest()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"machine-version:0_5_0", "team:infra"}, request.Tags)
+	assert.Equal(t, []interface{}{"machine-version:0_5_0", "team:infra"}, fake.Bodies["POST /v2/volumes"]["tags"])
+}
+
+func TestDropletCreateRequest(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /v2/volumes?name=data&region=nyc3": `{"volumes": [{"id": "11111111-1111-1111-1111-111111111111", "name": "data"}]}`,
+		"GET /v2/volumes?name=logs&region=nyc3": `{"volumes": []}`,
+		"POST /v2/volumes":                      `{"volume": {"id": "22222222-2222-2222-2222-222222222222", "name": "default-volume"}}`,
+		"GET /v2/vpcs?per_page=200":             `{"vpcs": [{"id": "33333333-3333-3333-3333-333333333333", "name": "backend", "region": "sfo2"}, {"id": "44444444-4444-4444-4444-444444444444", "name": "backend", "region": "nyc3"}]}`,
+	})
+	defer done()
+
+	d.Volumes = []string{"data", "55555555-5555-5555-5555-555555555555"}
+	d.VolumeSize = 10
+	d.VPC = "backend"
+
+	request, err := d.dropletCreateRequest()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"11111111-1111-1111-1111-111111111111",
+		"55555555-5555-5555-5555-555555555555",
+		"22222222-2222-2222-2222-222222222222",
+	}, request.Volumes)
+	assert.Equal(t, "44444444-4444-4444-4444-444444444444", request.VPCUUID)
+	assert.Equal(t, "22222222-2222-2222-2222-222222222222", d.VolumeID)
+	assert.Equal(t, map[string]interface{}{
+		"name":            "default-volume",
+		"region":          "nyc3",
+		"size_gigabytes":  float64(10),
+		"filesystem_type": "ext4",
+		"description":     "Docker Machine volume of default",
+	}, fake.Bodies["POST /v2/volumes"])
+
+	d.Volumes = []string{"logs"}
+	_, err = d.dropletCreateRequest()
+	assert.EqualError(t, err, `No Digital Ocean volume named "logs" in region nyc3`)
+}
+
+func TestPreCreateCheckReservedIP(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /v2/regions":                   `{"regions": [{"slug": "nyc3"}]}`,
+		"GET /v2/reserved_ips/203.0.113.10": `{"reserved_ip": {"ip": "203.0.113.10", "region": {"slug": "sfo2"}}}`,
+	})
+	defer done()
+
+	d.ReservedIP = "203.0.113.10"
+	assert.EqualError(t, d.PreCreateCheck(), "Digital Ocean reserved IP 203.0.113.10 is in region sfo2, not nyc3")
+
+	d.ReservedIP = "203.0.113.11"
+	assert.EqualError(t, d.PreCreateCheck(), "No Digital Ocean reserved IP 203.0.113.11")
+}
+
+func TestAssignReservedIP(t *testing.T) {
+	actionPollInterval = 0
+
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /v2/reserved_ips/203.0.113.10":          `{"reserved_ip": {"ip": "203.0.113.10", "region": {"slug": "nyc3"}, "droplet": {"id": 7}}}`,
+		"POST /v2/reserved_ips/203.0.113.10/actions": `{"action": {"id": 1, "status": "in-progress", "type": "assign_ip"}}`,
+		"GET /v2/actions/1":                          `{"action": {"id": 1, "status": "completed", "type": "assign_ip"}}`,
+	})
+	defer done()
+
+	d.ReservedIP = "203.0.113.10"
+
+	assert.NoError(t, d.assignReservedIP())
+	assert.Equal(t, []string{
+		"GET /v2/reserved_ips/203.0.113.10",
+		"POST /v2/reserved_ips/203.0.113.10/actions",
+		"GET /v2/actions/1",
+	}, fake.Requests)
+	assert.Equal(t, map[string]interface{}{
+		"type":       "assign",
+		"droplet_id": float64(42),
+	}, fake.Bodies["POST /v2/reserved_ips/203.0.113.10/actions"])
+}
+
+func TestResize(t *testing.T) {
+	actionPollInterval = 0
+
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /v2/droplets/42/actions": `{"action": {"id": 2, "status": "in-progress", "type": "resize"}}`,
+		"GET /v2/actions/2":            `{"action": {"id": 2, "status": "completed", "type": "resize"}}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.Resize(drivers.ResizeOptions{InstanceType: "s-2vcpu-4gb"}))
+	assert.Equal(t, "s-2vcpu-4gb", d.Size)
+	assert.Equal(t, map[string]interface{}{
+		"type": "resize",
+		"size": "s-2vcpu-4gb",
+		"disk": false,
+	}, fake.Bodies["POST /v2/droplets/42/actions"])
+
+	assert.Equal(t, drivers.ErrResizeInstanceTypeOnly, d.Resize(drivers.ResizeOptions{CPUs: 2}))
+}
+
+func TestPublicIP(t *testing.T) {
+	assert.Equal(t, "203.0.113.5", publicIP([]godo.Network{
+		{IPAddress: "10.132.0.5", Type: "private"},
+		{IPAddress: "203.0.113.5", Type: "public"},
+	}))
+	assert.Equal(t, "", publicIP([]godo.Network{{IPAddress: "10.132.0.5", Type: "private"}}))
+	assert.Equal(t, "", publicIP(nil))
+}
+
+func TestRemoveVolume(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"DELETE /v2/account/keys/99":                              "",
+		"DELETE /v2/droplets/42":                                  "",
+		"DELETE /v2/volumes/22222222-2222-2222-2222-222222222222": "",
+	})
+	defer done()
+
+	d.VolumeID = "22222222-2222-2222-2222-222222222222"
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{
+		"DELETE /v2/account/keys/99",
+		"DELETE /v2/droplets/42",
+		"DELETE /v2/volumes/22222222-2222-2222-2222-222222222222",
+	}, fake.Requests)
+}
+
+func TestPlan(t *testing.T) {
+	d := NewDriver("test", "")
+	d.Size = "s-1vcpu-1gb"
+	d.Image = "ubuntu-20-04-x64"
+	d.Region = "nyc3"
+	d.Volumes = []string{"data"}
+	d.VolumeSize = 100
+	d.IPv6 = true
+	d.VPC = "default-nyc3"
+	d.ReservedIP = "203.0.113.10"
+
+	resources, err := d.Plan()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PlannedResource{
+		{Kind: "droplet", Description: "s-1vcpu-1gb from ubuntu-20-04-x64 in nyc3"},
+		{Kind: "ssh-key", Description: "test"},
+		{Kind: "volume", Description: "existing volume data"},
+		{Kind: "volume", Description: "100GB volume test-volume"},
+		{Kind: "network", Description: "public network, with IPv6, in VPC default-nyc3"},
+		{Kind: "reserved-ip", Description: "203.0.113.10"},
+	}, resources)
+}
+
+func TestOrphanedResources(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /v2/droplets?per_page=200": `{"droplets": [
+			{"id": 1, "tags": ["machine-name:web_1", "machine-store:0123abcd", "team:infra"]},
+			{"id": 2, "tags": ["machine-name:failed", "machine-store:0123abcd"]},
+			{"id": 3, "tags": []},
+			{"id": 4, "tags": ["machine-name:other", "machine-store:4567ef01"]}
+		]}`,
+		"GET /v2/volumes?per_page=200": `{"volumes": [
+			{"id": "506f78a4-e098-11e5-ad9f-000f53306ae1", "name": "failed-volume", "tags": ["machine-name:failed", "machine-store:0123abcd"]},
+			{"id": "606f78a4-e098-11e5-ad9f-000f53306ae1", "name": "untagged-volume", "tags": ["machine-name:failed"]}
+		]}`,
+		"DELETE /v2/droplets/2":                                   ``,
+		"DELETE /v2/volumes/506f78a4-e098-11e5-ad9f-000f53306ae1": ``,
+	})
+	defer done()
+
+	resources, err := d.OrphanedResources("0123abcd", []string{"web.1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.OrphanedResource{
+		{Kind: "droplet", ID: "2", Machine: "failed"},
+		{Kind: "volume", ID: "506f78a4-e098-11e5-ad9f-000f53306ae1", Machine: "failed"},
+	}, resources)
+
+	for _, resource := range resources {
+		assert.NoError(t, d.RemoveOrphanedResource(resource))
+	}
+	assert.Equal(t, []string{
+		"GET /v2/droplets?per_page=200",
+		"GET /v2/volumes?per_page=200",
+		"DELETE /v2/droplets/2",
+		"DELETE /v2/volumes/506f78a4-e098-11e5-ad9f-000f53306ae1",
+	}, fake.Requests)
+}
+
+func TestAPIError(t *testing.T) {
+	apiErr := func(status int, message string) error {
+		return &godo.ErrorResponse{
+			Response: &http.Response{StatusCode: status, Request: &http.Request{Method: "POST", URL: &url.URL{Path: "/v2/droplets"}}},
+			Message:  message,
+		}
+	}
+
+	assert.Equal(t, mcnerror.CategoryDriverQuota, mcnerror.CategoryOf(apiError(apiErr(422, "creating this droplet will exceed your droplet limit"))))
+	assert.Equal(t, mcnerror.CategoryUnknown, mcnerror.CategoryOf(apiError(apiErr(422, "size is not available in this region"))))
+	assert.Equal(t, mcnerror.CategoryAuth, mcnerror.CategoryOf(apiError(apiErr(401, "Unable to authenticate you"))))
+	assert.Equal(t, mcnerror.CategoryTransient, mcnerror.CategoryOf(apiError(apiErr(429, "API Rate limit exceeded"))))
+	assert.Nil(t, apiError(nil))
+}
+
+func TestValidateCredentials(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /v2/account": `{"account": {"email": "jane@example.com", "status": "active"}}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.ValidateCredentials())
+
+	d, _, done = newTestDriver(map[string]string{
+		"GET /v2/account": `{"account": {"email": "jane@example.com", "status": "locked"}}`,
+	})
+	defer done()
+
+	assert.EqualError(t, d.ValidateCredentials(), "Digital Ocean account jane@example.com is locked")
+
+	d, _, done = newTestDriver(nil)
+	defer done()
+
+	assert.Error(t, d.ValidateCredentials())
+}
+
+func TestListOptions(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /v2/regions?per_page=200": `{"regions": [{"slug": "nyc3", "name": "New York 3", "available": true}, {"slug": "ams1", "name": "Amsterdam 1", "available": false}]}`,
+		"GET /v2/sizes?per_page=200": `{"sizes": [
+			{"slug": "s-1vcpu-1gb", "memory": 1024, "vcpus": 1, "disk": 25, "price_monthly": 5, "available": true, "regions": ["nyc3", "sfo2"]},
+			{"slug": "s-8vcpu-16gb", "memory": 16384, "vcpus": 8, "disk": 320, "price_monthly": 80, "available": true, "regions": ["sfo2"]}
+		]}`,
+		"GET /v2/images?type=distribution&per_page=200": `{"images": [
+			{"slug": "ubuntu-22-04-x64", "name": "22.04 (LTS) x64", "distribution": "Ubuntu", "regions": ["nyc3"]},
+			{"slug": "", "name": "custom", "distribution": "Ubuntu", "regions": ["nyc3"]}
+		]}`,
+	})
+	defer done()
+
+	regions, err := d.ListOptions("digitalocean-region")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "nyc3", Description: "New York 3"}}, regions)
+
+	sizes, err := d.ListOptions("digitalocean-size")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "s-1vcpu-1gb", Description: "1 vCPU, 1024MB memory, 25GB disk, $5.00/month"}}, sizes)
+
+	images, err := d.ListOptions("digitalocean-image")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "ubuntu-22-04-x64", Description: "Ubuntu 22.04 (LTS) x64"}}, images)
+
+	_, err = d.ListOptions("digitalocean-ssh-user")
+	assert.EqualError(t, err, "digitalocean driver cannot list the values of --digitalocean-ssh-user")
+}
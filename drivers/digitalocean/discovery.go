@@ -0,0 +1,101 @@
+package digitalocean
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func (d *Driver) DiscoveryFlags() drivers.DiscoveryFlags {
+	return drivers.DiscoveryFlags{
+		Region: "digitalocean-region",
+		Image:  "digitalocean-image",
+		Size:   "digitalocean-size",
+	}
+}
+
+// ListRegions lists the available regions.
+func (d *Driver) ListRegions() ([]drivers.Option, error) {
+	root := struct {
+		Regions []struct {
+			Slug      string `json:"slug"`
+			Name      string `json:"name"`
+			Available bool   `json:"available"`
+		} `json:"regions"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/regions?per_page=200", nil, &root); err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, region := range root.Regions {
+		if region.Available {
+			options = append(options, drivers.Option{Value: region.Slug, Description: region.Name})
+		}
+	}
+	return options, nil
+}
+
+// ListSizes lists the sizes available in the region, with their price.
+func (d *Driver) ListSizes(region string) ([]drivers.Option, error) {
+	root := struct {
+		Sizes []struct {
+			Slug         string   `json:"slug"`
+			Memory       int      `json:"memory"`
+			Vcpus        int      `json:"vcpus"`
+			Disk         int      `json:"disk"`
+			PriceMonthly float64  `json:"price_monthly"`
+			Available    bool     `json:"available"`
+			Regions      []string `json:"regions"`
+		} `json:"sizes"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/sizes?per_page=200", nil, &root); err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, size := range root.Sizes {
+		if !size.Available || !contains(size.Regions, region) {
+			continue
+		}
+		options = append(options, drivers.Option{
+			Value:       size.Slug,
+			Description: fmt.Sprintf("%d vCPU, %dMB memory, %dGB disk, $%.2f/month", size.Vcpus, size.Memory, size.Disk, size.PriceMonthly),
+		})
+	}
+	return options, nil
+}
+
+// ListImages lists the distribution images available in the region, the
+// custom and application images being left out.
+func (d *Driver) ListImages(region string) ([]drivers.Option, error) {
+	root := struct {
+		Images []struct {
+			Slug         string   `json:"slug"`
+			Name         string   `json:"name"`
+			Distribution string   `json:"distribution"`
+			Regions      []string `json:"regions"`
+		} `json:"images"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/images?type=distribution&per_page=200", nil, &root); err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, image := range root.Images {
+		if image.Slug == "" || !contains(image.Regions, region) {
+			continue
+		}
+		options = append(options, drivers.Option{Value: image.Slug, Description: image.Distribution + " " + image.Name})
+	}
+	return options, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package digitalocean
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// GuideFlags asks for the access token, then for the region, the size and
+// the image, the sizes and images available depending on the region.
+func (d *Driver) GuideFlags() drivers.GuideFlags {
+	return drivers.GuideFlags{
+		Credentials: []string{"digitalocean-access-token"},
+		Options:     []string{"digitalocean-region", "digitalocean-size", "digitalocean-image"},
+	}
+}
+
+// ValidateCredentials reads the account of the access token.
+func (d *Driver) ValidateCredentials() error {
+	account := struct {
+		Account struct {
+			Email  string `json:"email"`
+			Status string `json:"status"`
+		} `json:"account"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/account", nil, &account); err != nil {
+		return fmt.Errorf("Digital Ocean refused the access token: %w", err)
+	}
+
+	if account.Account.Status != "" && account.Account.Status != "active" {
+		return fmt.Errorf("Digital Ocean account %s is %s", account.Account.Email, account.Account.Status)
+	}
+
+	return nil
+}
+
+// ListOptions lists the available regions, and the sizes and distribution
+// images available in the region of the driver.
+func (d *Driver) ListOptions(flag string) ([]drivers.Option, error) {
+	switch flag {
+	case "digitalocean-region":
+		return d.ListRegions()
+	case "digitalocean-size":
+		return d.ListSizes(d.Region)
+	case "digitalocean-image":
+		return d.ListImages(d.Region)
+	}
+
+	return nil, fmt.Errorf("digitalocean driver cannot list the values of --%s", flag)
+}
@@ -0,0 +1,263 @@
+package digitalocean
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+// The vendored godo client predates volumes, VPCs and reserved IPs, so they
+// are managed with plain requests through it.
+
+// dropletCreateRequest is a droplet create request with the fields godo
+// doesn't know about.
+type dropletCreateRequest struct {
+	godo.DropletCreateRequest
+	Volumes []string `json:"volumes,omitempty"`
+	VPCUUID string   `json:"vpc_uuid,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+type volume struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type vpc struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Region string `json:"region"`
+}
+
+type reservedIP struct {
+	IP     string `json:"ip"`
+	Region struct {
+		Slug string `json:"slug"`
+	} `json:"region"`
+	Droplet *struct {
+		ID int `json:"id"`
+	} `json:"droplet"`
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+var tagInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9:_-]`)
+
+// resourceTags returns the tags given with --tag as Digital Ocean tags,
+// which are plain strings of letters, digits, colons, dashes and
+// underscores, e.g. "machine-version:0_5_0".
+func (d *Driver) resourceTags() []string {
+	tags := []string{}
+	for _, tag := range drivers.TagList(d.ResourceTags, ":") {
+		tags = append(tags, tagInvalidChars.ReplaceAllString(tag, "_"))
+	}
+	return tags
+}
+
+// machineTag returns the machine a resource with the tags is tagged for, as
+// in its machine-name tag, if any.
+func machineTag(tags []string) string {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, drivers.TagMachineName+":") {
+			return strings.TrimPrefix(tag, drivers.TagMachineName+":")
+		}
+	}
+	return ""
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// apiRequest sends a request to the API, and decodes its response into out
+// when it is not nil. The errors of the API are classified with apiError.
+func (d *Driver) apiRequest(method, path string, body, out interface{}) (*godo.Response, error) {
+	client := d.getClient()
+	req, err := client.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req, out)
+	return resp, apiError(err)
+}
+
+// apiError returns an error of the API as the mcnerror error of its
+// category: the API refuses the requests going over the limits of the
+// account, like its number of droplets, with a 422 telling about a limit.
+func apiError(err error) error {
+	errResp, ok := err.(*godo.ErrorResponse)
+	if !ok || errResp.Response == nil {
+		return err
+	}
+
+	status := errResp.Response.StatusCode
+	if status == 422 && strings.Contains(strings.ToLower(errResp.Message), "limit") {
+		return mcnerror.ErrDriverQuota{Driver: "digitalocean", Err: err}
+	}
+	return mcnerror.ForHTTPStatus(status, err)
+}
+
+func isNotFound(resp *godo.Response) bool {
+	return resp != nil && resp.StatusCode == 404
+}
+
+// volumeID returns the ID of the volume called nameOrID in the region of the
+// droplet, which may also be its ID already.
+func (d *Driver) volumeID(nameOrID string) (string, error) {
+	if uuidPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+
+	root := struct {
+		Volumes []volume `json:"volumes"`
+	}{}
+	query := url.Values{"name": {nameOrID}, "region": {d.Region}}
+	if _, err := d.apiRequest("GET", "v2/volumes?"+query.Encode(), nil, &root); err != nil {
+		return "", err
+	}
+
+	if len(root.Volumes) == 0 {
+		return "", fmt.Errorf("No Digital Ocean volume named %q in region %s", nameOrID, d.Region)
+	}
+	return root.Volumes[0].ID, nil
+}
+
+func (d *Driver) volumeName() string {
+	return d.MachineName + "-volume"
+}
+
+// createVolume creates the volume of the droplet, formatted as ext4.
+func (d *Driver) createVolume() (string, error) {
+	root := struct {
+		Volume volume `json:"volume"`
+	}{}
+	request := map[string]interface{}{
+		"name":            d.volumeName(),
+		"region":          d.Region,
+		"size_gigabytes":  d.VolumeSize,
+		"filesystem_type": "ext4",
+		"description":     "Docker Machine volume of " + d.MachineName,
+	}
+	if tags := d.resourceTags(); len(tags) > 0 {
+		request["tags"] = tags
+	}
+	if _, err := d.apiRequest("POST", "v2/volumes", request, &root); err != nil {
+		return "", err
+	}
+	return root.Volume.ID, nil
+}
+
+// vpcID returns the ID of the VPC called nameOrID in the region of the
+// droplet, which may also be its ID already.
+func (d *Driver) vpcID(nameOrID string) (string, error) {
+	if uuidPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+
+	root := struct {
+		VPCs []vpc `json:"vpcs"`
+	}{}
+	if _, err := d.apiRequest("GET", "v2/vpcs?per_page=200", nil, &root); err != nil {
+		return "", err
+	}
+
+	for _, v := range root.VPCs {
+		if v.Name == nameOrID && v.Region == d.Region {
+			return v.ID, nil
+		}
+	}
+	return "", fmt.Errorf("No Digital Ocean VPC named %q in region %s", nameOrID, d.Region)
+}
+
+func (d *Driver) getReservedIP() (*reservedIP, error) {
+	root := struct {
+		ReservedIP reservedIP `json:"reserved_ip"`
+	}{}
+	resp, err := d.apiRequest("GET", "v2/reserved_ips/"+d.ReservedIP, nil, &root)
+	if isNotFound(resp) {
+		return nil, fmt.Errorf("No Digital Ocean reserved IP %s", d.ReservedIP)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &root.ReservedIP, nil
+}
+
+// assignReservedIP assigns the reserved IP to the droplet, taking it from
+// the droplet it was assigned to, e.g. the one of a previous machine.
+func (d *Driver) assignReservedIP() error {
+	ip, err := d.getReservedIP()
+	if err != nil {
+		return err
+	}
+	if ip.Droplet != nil {
+		if ip.Droplet.ID == d.DropletID {
+			return nil
+		}
+		log.Infof("Reassigning reserved IP %s from droplet %d...", d.ReservedIP, ip.Droplet.ID)
+	}
+
+	root := struct {
+		Action godo.Action `json:"action"`
+	}{}
+	if _, err := d.apiRequest("POST", "v2/reserved_ips/"+d.ReservedIP+"/actions", map[string]interface{}{
+		"type":       "assign",
+		"droplet_id": d.DropletID,
+	}, &root); err != nil {
+		return err
+	}
+
+	return d.waitForAction(root.Action)
+}
+
+// resizeDroplet changes the size of the droplet, leaving its disk as is for
+// the resize to be reversible. godo sends the size nested in params, which
+// the API ignores.
+func (d *Driver) resizeDroplet(size string) error {
+	root := struct {
+		Action godo.Action `json:"action"`
+	}{}
+	if _, err := d.apiRequest("POST", fmt.Sprintf("v2/droplets/%d/actions", d.DropletID), map[string]interface{}{
+		"type": "resize",
+		"size": size,
+		"disk": false,
+	}, &root); err != nil {
+		return err
+	}
+
+	return d.waitForAction(root.Action)
+}
+
+// waitForAction blocks until the action is done.
+func (d *Driver) waitForAction(action godo.Action) error {
+	for action.Status == "in-progress" {
+		time.Sleep(actionPollInterval)
+
+		a, _, err := d.getClient().Actions.Get(action.ID)
+		if err != nil {
+			return err
+		}
+		action = *a
+	}
+
+	if action.Status == "errored" {
+		return fmt.Errorf("Digital Ocean action %d (%s) failed", action.ID, action.Type)
+	}
+	return nil
+}
+
+// actionPollInterval is how often the status of actions is checked.
+var actionPollInterval = 1 * time.Second
@@ -0,0 +1,63 @@
+package equinixmetal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/machine/libmachine/jsonapi"
+)
+
+const defaultEndpoint = "https://api.equinix.com/metal/v1"
+
+// client talks to the Equinix Metal API.
+type client struct {
+	*jsonapi.Client
+}
+
+// apiError is an error returned by the Equinix Metal API.
+type apiError struct {
+	StatusCode int
+	Errors     []string `json:"errors"`
+	Message    string   `json:"error"`
+}
+
+func (e *apiError) Error() string {
+	messages := e.Errors
+	if e.Message != "" {
+		messages = append([]string{e.Message}, messages...)
+	}
+	return fmt.Sprintf("Equinix Metal API error (%d): %s", e.StatusCode, strings.Join(messages, ", "))
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+type device struct {
+	ID          string `json:"id"`
+	Hostname    string `json:"hostname"`
+	State       string `json:"state"`
+	IPAddresses []struct {
+		Address       string `json:"address"`
+		AddressFamily int    `json:"address_family"`
+		Public        bool   `json:"public"`
+	} `json:"ip_addresses"`
+}
+
+func newClient(token string) *client {
+	return &client{jsonapi.NewClient("Equinix Metal", defaultEndpoint, http.Header{"X-Auth-Token": []string{token}}, decodeError)}
+}
+
+// decodeError returns the apiError of a response.
+func decodeError(res *http.Response) error {
+	e := apiError{}
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || (e.Message == "" && len(e.Errors) == 0) {
+		e.Message = res.Status
+		e.Errors = nil
+	}
+	e.StatusCode = res.StatusCode
+	return &e
+}
@@ -0,0 +1,422 @@
+package equinixmetal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultPlan        = "c3.small.x86"
+	defaultMetro       = "sv"
+	defaultOS          = "ubuntu_16_04"
+	defaultBootTimeout = 20
+	defaultSSHTimeout  = 10
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	client       *client
+	APIKey       string
+	ProjectID    string
+	Plan         string
+	Metro        string
+	OS           string
+	UserDataFile string
+	SpotInstance bool
+	SpotPriceMax float64
+	Tags         []string
+	BootTimeout  int
+	SSHTimeout   int
+	DeviceID     string
+	SSHKeyID     string
+}
+
+// NewDriver creates a new Equinix Metal driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Plan:        defaultPlan,
+		Metro:       defaultMetro,
+		OS:          defaultOS,
+		BootTimeout: defaultBootTimeout,
+		SSHTimeout:  defaultSSHTimeout,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "METAL_AUTH_TOKEN",
+			Name:   "equinixmetal-api-key",
+			Usage:  "Equinix Metal API key",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_PROJECT_ID",
+			Name:   "equinixmetal-project-id",
+			Usage:  "Equinix Metal project ID",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_PLAN",
+			Name:   "equinixmetal-plan",
+			Usage:  "Equinix Metal server plan",
+			Value:  defaultPlan,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_METRO",
+			Name:   "equinixmetal-metro",
+			Usage:  "Equinix Metal metro",
+			Value:  defaultMetro,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_OS",
+			Name:   "equinixmetal-os",
+			Usage:  "Equinix Metal operating system",
+			Value:  defaultOS,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_USERDATA",
+			Name:   "equinixmetal-userdata",
+			Usage:  "Path of a file with the user data of the server",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "METAL_SPOT_INSTANCE",
+			Name:   "equinixmetal-spot-instance",
+			Usage:  "Request a server on the spot market",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_SPOT_PRICE_MAX",
+			Name:   "equinixmetal-spot-price-max",
+			Usage:  "Maximum hourly price to bid for a spot market server, in USD",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "equinixmetal-tag",
+			Usage: "Tag of the server",
+			Value: []string{},
+		},
+		mcnflag.IntFlag{
+			EnvVar: "METAL_BOOT_TIMEOUT",
+			Name:   "equinixmetal-boot-timeout",
+			Usage:  "Minutes to wait for the server to be provisioned",
+			Value:  defaultBootTimeout,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "METAL_SSH_TIMEOUT",
+			Name:   "equinixmetal-ssh-timeout",
+			Usage:  "Minutes to wait for SSH to be available once the server is provisioned",
+			Value:  defaultSSHTimeout,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "METAL_SSH_USER",
+			Name:   "equinixmetal-ssh-user",
+			Usage:  "Equinix Metal SSH username",
+			Value:  "root",
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) DriverName() string {
+	return "equinixmetal"
+}
+
+// Capabilities adds the user data and spot instances of the driver to those
+// of its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.UserData = true
+	capabilities.Spot = true
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the plan of the server, whose disks are included.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	return drivers.Pricing{
+		Region:       d.Metro,
+		InstanceType: d.Plan,
+		Spot:         d.SpotInstance,
+	}, nil
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.APIKey = flags.String("equinixmetal-api-key")
+	d.ProjectID = flags.String("equinixmetal-project-id")
+	d.Plan = flags.String("equinixmetal-plan")
+	d.Metro = flags.String("equinixmetal-metro")
+	d.OS = flags.String("equinixmetal-os")
+	d.UserDataFile = flags.String("equinixmetal-userdata")
+	if d.UserDataFile == "" {
+		d.UserDataFile = flags.String("user-data")
+	}
+	d.SpotInstance = flags.Bool("equinixmetal-spot-instance")
+	d.Tags = flags.StringSlice("equinixmetal-tag")
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+	d.BootTimeout = flags.Int("equinixmetal-boot-timeout")
+	d.SSHTimeout = flags.Int("equinixmetal-ssh-timeout")
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHUser = flags.String("equinixmetal-ssh-user")
+	d.SSHPort = 22
+
+	if d.APIKey == "" {
+		return fmt.Errorf("equinixmetal driver requires the --equinixmetal-api-key option")
+	}
+
+	if d.ProjectID == "" {
+		return fmt.Errorf("equinixmetal driver requires the --equinixmetal-project-id option")
+	}
+
+	d.SpotPriceMax = 0
+	if price := flags.String("equinixmetal-spot-price-max"); price != "" {
+		if !d.SpotInstance {
+			return fmt.Errorf("equinixmetal driver requires --equinixmetal-spot-instance to bid with --equinixmetal-spot-price-max")
+		}
+
+		spotPriceMax, err := strconv.ParseFloat(price, 64)
+		if err != nil || spotPriceMax <= 0 {
+			return fmt.Errorf("Invalid Equinix Metal spot price %q, expected a positive number of USD", price)
+		}
+		d.SpotPriceMax = spotPriceMax
+	}
+
+	return nil
+}
+
+// WaitTimeouts makes Machine wait longer than for virtual machines, as
+// bare-metal servers take several minutes to be provisioned and to boot.
+func (d *Driver) WaitTimeouts() drivers.WaitTimeouts {
+	return drivers.WaitTimeouts{
+		Running: time.Duration(d.BootTimeout) * time.Minute,
+		SSH:     time.Duration(d.SSHTimeout) * time.Minute,
+	}
+}
+
+func (d *Driver) getClient() *client {
+	if d.client == nil {
+		d.client = newClient(d.APIKey)
+	}
+	return d.client
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.UserDataFile != "" {
+		if _, err := ioutil.ReadFile(d.UserDataFile); err != nil {
+			return fmt.Errorf("Cannot read the Equinix Metal user data: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Create requests the server. Provisioning it takes several minutes, which
+// Machine waits for as long as WaitTimeouts asks.
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+
+	if err := d.createSSHKey(); err != nil {
+		return err
+	}
+
+	request, err := d.deviceRequest()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating Equinix Metal server...")
+
+	dev := device{}
+	if err := d.getClient().Do("POST", fmt.Sprintf("/projects/%s/devices", d.ProjectID), request, &dev); err != nil {
+		return err
+	}
+
+	d.DeviceID = dev.ID
+
+	log.Debugf("Created server ID %s", d.DeviceID)
+
+	return nil
+}
+
+// deviceRequest returns the request creating the server.
+func (d *Driver) deviceRequest() (map[string]interface{}, error) {
+	request := map[string]interface{}{
+		"hostname":         d.MachineName,
+		"plan":             d.Plan,
+		"metro":            d.Metro,
+		"operating_system": d.OS,
+		"project_ssh_keys": []string{d.SSHKeyID},
+		"tags":             append(append([]string{}, d.Tags...), drivers.TagList(d.ResourceTags, "=")...),
+	}
+
+	if d.UserDataFile != "" {
+		userData, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return nil, err
+		}
+		request["userdata"] = string(userData)
+	}
+
+	if d.SpotInstance {
+		request["spot_instance"] = true
+		if d.SpotPriceMax > 0 {
+			request["spot_price_max"] = d.SpotPriceMax
+		}
+	}
+
+	return request, nil
+}
+
+func (d *Driver) createSSHKey() error {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	resp := struct {
+		ID string `json:"id"`
+	}{}
+	if err := d.getClient().Do("POST", fmt.Sprintf("/projects/%s/ssh-keys", d.ProjectID), map[string]string{
+		"label": fmt.Sprintf("%s-%d", d.MachineName, time.Now().Unix()),
+		"key":   string(publicKey),
+	}, &resp); err != nil {
+		return err
+	}
+
+	d.SSHKeyID = resp.ID
+	return nil
+}
+
+func (d *Driver) getDevice() (device, error) {
+	dev := device{}
+	err := d.getClient().Do("GET", "/devices/"+d.DeviceID, nil, &dev)
+	return dev, err
+}
+
+// publicIPv4 returns the public IPv4 address of the server, which it only
+// gets once provisioned.
+func publicIPv4(dev device) string {
+	for _, ip := range dev.IPAddresses {
+		if ip.Public && ip.AddressFamily == 4 {
+			return ip.Address
+		}
+	}
+	return ""
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.IPAddress != "" {
+		return d.IPAddress, nil
+	}
+
+	dev, err := d.getDevice()
+	if err != nil {
+		return "", err
+	}
+
+	d.IPAddress = publicIPv4(dev)
+	if d.IPAddress == "" {
+		return "", fmt.Errorf("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	dev, err := d.getDevice()
+	if err != nil {
+		return state.Error, err
+	}
+	switch dev.State {
+	case "queued", "provisioning", "reinstalling", "powering_on":
+		return state.Starting, nil
+	case "active":
+		return state.Running, nil
+	case "powering_off":
+		return state.Stopping, nil
+	case "inactive":
+		return state.Stopped, nil
+	case "failed":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) deviceAction(name string) error {
+	return d.getClient().Do("POST", fmt.Sprintf("/devices/%s/actions", d.DeviceID), map[string]string{
+		"type": name,
+	}, nil)
+}
+
+func (d *Driver) Start() error {
+	return d.deviceAction("power_on")
+}
+
+func (d *Driver) Stop() error {
+	return d.deviceAction("power_off")
+}
+
+func (d *Driver) Remove() error {
+	c := d.getClient()
+	if err := c.Do("DELETE", "/devices/"+d.DeviceID, nil, nil); err != nil {
+		if isNotFound(err) {
+			log.Infof("Equinix Metal server doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	if err := c.Do("DELETE", "/ssh-keys/"+d.SSHKeyID, nil, nil); err != nil {
+		if isNotFound(err) {
+			log.Infof("Equinix Metal SSH key doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) Restart() error {
+	return d.deviceAction("reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.deviceAction("power_off")
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
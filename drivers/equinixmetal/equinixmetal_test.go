@@ -0,0 +1,283 @@
+package equinixmetal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+const (
+	serverID = "11111111-1111-1111-1111-111111111111"
+	imageID  = "22222222-2222-2222-2222-222222222222"
+	ipID     = "33333333-3333-3333-3333-333333333333"
+)
+
+func newTestDriver(responses map[string]string) (*Driver, *jsonapitest.API, func()) {
+	fake := jsonapitest.New(responses)
+	fake.Authorized = func(r *http.Request) bool { return r.Header.Get("X-Auth-Token") == "key" }
+	fake.Unauthorized = `{"errors": ["Invalid authentication token"]}`
+	fake.NotFound = `{"errors": ["Not found"]}`
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.APIKey = "key"
+	d.ProjectID = "proj"
+	d.DeviceID = "dev"
+	d.client = newClient("key")
+	d.client.Endpoint = server.URL
+	d.client.HTTP = server.Client()
+
+	return d, fake, server.Close
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "equinixmetal", NewDriver("default", "").DriverName())
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"equinixmetal-api-key":        "key",
+			"equinixmetal-project-id":     "proj",
+			"equinixmetal-spot-instance":  true,
+			"equinixmetal-spot-price-max": "0.35",
+			"equinixmetal-boot-timeout":   30,
+			"equinixmetal-ssh-timeout":    5,
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0.35, d.SpotPriceMax)
+	assert.Equal(t, 30, d.BootTimeout)
+	assert.Equal(t, 5, d.SSHTimeout)
+}
+
+func TestSetConfigFromFlagsErrors(t *testing.T) {
+	var tests = []struct {
+		flags map[string]interface{}
+		err   string
+	}{
+		{
+			map[string]interface{}{},
+			"equinixmetal driver requires the --equinixmetal-api-key option",
+		},
+		{
+			map[string]interface{}{"equinixmetal-api-key": "key"},
+			"equinixmetal driver requires the --equinixmetal-project-id option",
+		},
+		{
+			map[string]interface{}{
+				"equinixmetal-api-key":        "key",
+				"equinixmetal-project-id":     "proj",
+				"equinixmetal-spot-price-max": "0.35",
+			},
+			"equinixmetal driver requires --equinixmetal-spot-instance to bid with --equinixmetal-spot-price-max",
+		},
+		{
+			map[string]interface{}{
+				"equinixmetal-api-key":        "key",
+				"equinixmetal-project-id":     "proj",
+				"equinixmetal-spot-instance":  true,
+				"equinixmetal-spot-price-max": "cheap",
+			},
+			`Invalid Equinix Metal spot price "cheap", expected a positive number of USD`,
+		},
+	}
+
+	for _, expected := range tests {
+		d := NewDriver("default", "")
+		assert.EqualError(t, d.SetConfigFromFlags(DriverOptionsMock{Data: expected.flags}), expected.err)
+	}
+}
+
+func TestWaitTimeouts(t *testing.T) {
+	d := NewDriver("default", "")
+	d.BootTimeout = 25
+
+	expected := drivers.WaitTimeouts{Running: 25 * time.Minute, SSH: 10 * time.Minute}
+	assert.Equal(t, expected, drivers.GetWaitTimeouts(d))
+	assert.Equal(t, expected, drivers.GetWaitTimeouts(drivers.WithContext(context.Background(), d)))
+
+	d.SSHTimeout = 0
+	assert.Equal(t, drivers.DefaultWaitTimeout, drivers.GetWaitTimeouts(d).SSH)
+}
+
+func TestDeviceRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "equinixmetal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	userData := filepath.Join(dir, "user-data")
+	assert.NoError(t, ioutil.WriteFile(userData, []byte("#cloud-config\n"), 0644))
+
+	d := NewDriver("default", "")
+	d.SSHKeyID = "key-1"
+	d.Tags = []string{"ci"}
+
+	request, err := d.deviceRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"hostname":         "default",
+		"plan":             "c3.small.x86",
+		"metro":            "sv",
+		"operating_system": "ubuntu_16_04",
+		"project_ssh_keys": []string{"key-1"},
+		"tags":             []string{"ci"},
+	}, request)
+
+	d.UserDataFile = userData
+	d.SpotInstance = true
+	d.SpotPriceMax = 0.35
+
+	request, err = d.deviceRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, "#cloud-config\n", request["userdata"])
+	assert.Equal(t, true, request["spot_instance"])
+	assert.Equal(t, 0.35, request["spot_price_max"])
+}
+
+func TestCreate(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /projects/proj/ssh-keys": `{"id": "key-1"}`,
+		"POST /projects/proj/devices":  `{"id": "dev-1", "state": "queued"}`,
+	})
+	defer done()
+
+	dir, err := ioutil.TempDir("", "equinixmetal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	d.StorePath = dir
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "default"), 0700))
+
+	assert.NoError(t, d.Create())
+	assert.Equal(t, "key-1", d.SSHKeyID)
+	assert.Equal(t, "dev-1", d.DeviceID)
+	assert.Equal(t, []interface{}{"key-1"}, fake.Bodies["POST /projects/proj/devices"]["project_ssh_keys"])
+}
+
+func TestGetIP(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /devices/dev": `{"id": "dev", "state": "provisioning", "ip_addresses": []}`,
+	})
+	defer done()
+
+	_, err := d.GetIP()
+	assert.EqualError(t, err, "IP address is not set")
+
+	fake.Responses["GET /devices/dev"] = `{"id": "dev", "state": "active", "ip_addresses": [
+		{"address": "2604:1380::1", "address_family": 6, "public": true},
+		{"address": "10.0.0.3", "address_family": 4, "public": false},
+		{"address": "147.75.0.10", "address_family": 4, "public": true}
+	]}`
+
+	ip, err := d.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "147.75.0.10", ip)
+
+	ip, err = d.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "147.75.0.10", ip)
+	assert.Equal(t, 2, len(fake.Requests))
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		status string
+		state  state.State
+	}{
+		{"queued", state.Starting},
+		{"provisioning", state.Starting},
+		{"active", state.Running},
+		{"powering_off", state.Stopping},
+		{"inactive", state.Stopped},
+		{"failed", state.Error},
+		{"deprovisioning", state.None},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(map[string]string{
+			"GET /devices/dev": fmt.Sprintf(`{"id": "dev", "state": %q}`, expected.status),
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestActions(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /devices/dev/actions": "",
+	})
+	defer done()
+
+	assert.NoError(t, d.Restart())
+	assert.Equal(t, map[string]interface{}{"type": "reboot"}, fake.Bodies["POST /devices/dev/actions"])
+}
+
+func TestRemoveMissingResources(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{})
+	defer done()
+
+	d.SSHKeyID = "key-1"
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{"DELETE /devices/dev", "DELETE /ssh-keys/key-1"}, fake.Requests)
+}
+
+func TestAPIError(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.client.Header = http.Header{"X-Auth-Token": []string{"wrong"}}
+
+	_, err := d.GetState()
+
+	assert.EqualError(t, err, "Equinix Metal API error (401): Invalid authentication token")
+}
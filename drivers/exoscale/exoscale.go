@@ -2,8 +2,13 @@ package exoscale
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -115,6 +120,14 @@ func (d *Driver) DriverName() string {
 	return "exoscale"
 }
 
+// Capabilities adds the tags of the virtual machines of the driver to those
+// of its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.Tags = true
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.URL = flags.String("exoscale-endpoint")
 	d.ApiKey = flags.String("exoscale-api-key")
@@ -131,6 +144,14 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
 
 	if d.URL == "" {
 		d.URL = "https://api.exoscale.ch/compute"
@@ -147,7 +168,7 @@ func (d *Driver) GetURL() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -323,9 +344,80 @@ func (d *Driver) Create() error {
 	d.IPAddress = vm.Nic[0].Ipaddress
 	d.Id = vm.Id
 
+	if len(d.ResourceTags) > 0 {
+		log.Infof("Tagging exoscale host...")
+		if err := d.tagVirtualMachine(client, d.Id); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// tagVirtualMachine tags the virtual machine of id with the resource tags of
+// the driver, which egoscale has no call for.
+func (d *Driver) tagVirtualMachine(client *egoscale.Client, id string) error {
+	params := url.Values{}
+	params.Set("resourceids", id)
+	params.Set("resourcetype", "UserVm")
+	for i, tag := range drivers.TagList(d.ResourceTags, "=") {
+		kv := strings.SplitN(tag, "=", 2)
+		params.Set("tags["+strconv.Itoa(i)+"].key", kv[0])
+		params.Set("tags["+strconv.Itoa(i)+"].value", kv[1])
+	}
+
+	resp, err := client.Request("createTags", params)
+	if err != nil {
+		return err
+	}
+
+	var job struct {
+		JobID string `json:"jobid"`
+	}
+	if err := json.Unmarshal(resp, &job); err != nil {
+		return err
+	}
+
+	return d.waitForJob(client, job.JobID)
+}
+
+// listTags returns the values of the key tag of virtual machines by their
+// ID, only for those tagged with value if it isn't empty.
+func listTags(client *egoscale.Client, key, value string) (map[string]string, error) {
+	params := url.Values{}
+	params.Set("resourcetype", "UserVm")
+	params.Set("key", key)
+	if value != "" {
+		params.Set("value", value)
+	}
+	params.Set("listall", "true")
+
+	resp, err := client.Request("listTags", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Tags []struct {
+			Key        string `json:"key"`
+			Value      string `json:"value"`
+			ResourceID string `json:"resourceid"`
+		} `json:"tag"`
+	}
+	if err := json.Unmarshal(resp, &list); err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range list.Tags {
+		if tag.Key == key && (value == "" || tag.Value == value) {
+			tags[tag.ResourceID] = tag.Value
+		}
+	}
+
+	return tags, nil
+}
+
 func (d *Driver) Start() error {
 	vmstate, err := d.GetState()
 	if err != nil {
@@ -387,6 +479,64 @@ func (d *Driver) Remove() error {
 	return nil
 }
 
+// OrphanedResources returns the virtual machines tagged for machines of the
+// store which aren't one of machines, followed by their key pairs, which
+// can't be tagged.
+func (d *Driver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	client := egoscale.NewClient(d.URL, d.ApiKey, d.ApiSecretKey)
+
+	stored, err := listTags(client, drivers.TagMachineStore, storeID)
+	if err != nil {
+		return nil, err
+	}
+	names, err := listTags(client, drivers.TagMachineName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[machine] = true
+	}
+
+	ids := []string{}
+	for id := range stored {
+		if machine := names[id]; machine != "" && !known[machine] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	resources := []drivers.OrphanedResource{}
+	keyPairs := []drivers.OrphanedResource{}
+	for _, id := range ids {
+		machine := names[id]
+		resources = append(resources, drivers.OrphanedResource{Kind: "virtual-machine", ID: id, Machine: machine})
+		keyPairs = append(keyPairs, drivers.OrphanedResource{Kind: "key-pair", ID: fmt.Sprintf("docker-machine-%s", machine), Machine: machine})
+	}
+
+	return append(resources, keyPairs...), nil
+}
+
+// RemoveOrphanedResource removes a resource OrphanedResources returned.
+func (d *Driver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	client := egoscale.NewClient(d.URL, d.ApiKey, d.ApiSecretKey)
+
+	switch resource.Kind {
+	case "virtual-machine":
+		dvmresp, err := client.DestroyVirtualMachine(resource.ID)
+		if err != nil {
+			return err
+		}
+		return d.waitForJob(client, dvmresp)
+	case "key-pair":
+		_, err := client.DeleteKeypair(resource.ID)
+		return err
+	}
+
+	return fmt.Errorf("Unknown kind of resource %q", resource.Kind)
+}
+
 func (d *Driver) Restart() error {
 	vmstate, err := d.GetState()
 	if err != nil {
@@ -0,0 +1,87 @@
+package exoscale
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/pyr/egoscale/src/egoscale"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer returns a CloudStack API answering the commands of responses,
+// which records the queries it gets.
+func newTestServer(responses map[string]string) (*httptest.Server, *[]url.Values) {
+	queries := []url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		queries = append(queries, query)
+
+		command := query.Get("command")
+		if command == "queryAsyncJobResult" {
+			fmt.Fprint(w, `{"queryasyncjobresultresponse": {"jobstatus": 1}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"%sresponse": %s}`, command, responses[command])
+	}))
+
+	return server, &queries
+}
+
+func TestTagVirtualMachine(t *testing.T) {
+	server, queries := newTestServer(map[string]string{"createTags": `{"jobid": "1"}`})
+	defer server.Close()
+
+	d := NewDriver("web", "").(*Driver)
+	d.URL = server.URL
+	d.ResourceTags = map[string]string{"team": "infra", "machine-name": "web"}
+
+	assert.NoError(t, d.tagVirtualMachine(egoscale.NewClient(d.URL, d.ApiKey, d.ApiSecretKey), "vm-1"))
+
+	query := (*queries)[0]
+	assert.Equal(t, "createTags", query.Get("command"))
+	assert.Equal(t, "vm-1", query.Get("resourceids"))
+	assert.Equal(t, "UserVm", query.Get("resourcetype"))
+	assert.Equal(t, "machine-name", query.Get("tags[0].key"))
+	assert.Equal(t, "web", query.Get("tags[0].value"))
+	assert.Equal(t, "team", query.Get("tags[1].key"))
+	assert.Equal(t, "infra", query.Get("tags[1].value"))
+}
+
+func TestOrphanedResources(t *testing.T) {
+	server, queries := newTestServer(map[string]string{
+		"listTags": `{"count": 3, "tag": [
+			{"key": "machine-name", "value": "web", "resourceid": "vm-1"},
+			{"key": "machine-name", "value": "db", "resourceid": "vm-2"},
+			{"key": "machine-name", "value": "old", "resourceid": "vm-3"},
+			{"key": "machine-store", "value": "s1", "resourceid": "vm-1"},
+			{"key": "machine-store", "value": "s1", "resourceid": "vm-2"}
+		]}`,
+		"destroyVirtualMachine": `{"jobid": "2"}`,
+		"deleteSSHKeyPair":      `{"success": "true"}`,
+	})
+	defer server.Close()
+
+	d := NewDriver("default", "").(*Driver)
+	d.URL = server.URL
+
+	resources, err := d.OrphanedResources("s1", []string{"db"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.OrphanedResource{
+		{Kind: "virtual-machine", ID: "vm-1", Machine: "web"},
+		{Kind: "key-pair", ID: "docker-machine-web", Machine: "web"},
+	}, resources)
+	assert.Equal(t, "machine-store", (*queries)[0].Get("key"))
+	assert.Equal(t, "s1", (*queries)[0].Get("value"))
+
+	*queries = nil
+	for _, resource := range resources {
+		assert.NoError(t, d.RemoveOrphanedResource(resource))
+	}
+	assert.Equal(t, "vm-1", (*queries)[0].Get("id"))
+	assert.Equal(t, "docker-machine-web", (*queries)[len(*queries)-1].Get("name"))
+}
@@ -1,6 +1,8 @@
 package fakedriver
 
 import (
+	"errors"
+
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
@@ -75,6 +77,35 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+func (d *Driver) Suspend() error {
+	d.MockState = state.Saved
+	return nil
+}
+
+func (d *Driver) Resume() error {
+	d.MockState = state.Running
+	return nil
+}
+
+func (d *Driver) CreateSnapshot(name string) error {
+	return nil
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	return nil
+}
+
+func (d *Driver) RemoveSnapshot(name string) error {
+	return nil
+}
+
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if d.MockState == state.Running {
+		return errors.New("Host must be stopped to be resized")
+	}
+	return nil
+}
+
 func (d *Driver) Restart() error {
 	return nil
 }
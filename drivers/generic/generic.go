@@ -2,31 +2,69 @@ package generic
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/winrm"
 )
 
 type Driver struct {
 	*drivers.BaseDriver
-	SSHKey string
+	SSHKey     string
+	EnginePort int
+
+	// Adopt registers the host as it is, with the certificates its engine
+	// already accepts, see drivers.Adopter.
+	Adopt               bool
+	AdoptCertPath       string
+	AdoptRemoteCertPath string
+
+	// Local provisions the machine Machine runs on, running the commands
+	// directly rather than over SSH, see drivers.Transporter.
+	Local bool
+
+	// Transport is how the commands are run on the machine otherwise
+	// than over SSH or locally: over WinRM or on a serial console.
+	Transport     string
+	WinRMPort     int
+	WinRMUser     string
+	WinRMPassword string
+	WinRMHTTPS    bool
+	WinRMInsecure bool
+	SerialConsole string
 }
 
 const (
-	defaultSSHUser = "root"
-	defaultSSHPort = 22
-	defaultTimeout = 1 * time.Second
+	defaultSSHUser    = "root"
+	defaultSSHPort    = 22
+	defaultEnginePort = 2376
+	defaultTimeout    = 1 * time.Second
+
+	defaultAdoptRemoteCertPath = ".docker"
+
+	transportSSH    = "ssh"
+	transportLocal  = "local"
+	transportWinRM  = "winrm"
+	transportSerial = "serial"
 )
 
 var (
 	defaultSSHKey = filepath.Join(mcnutils.GetHomeDir(), ".ssh", "id_rsa")
+
+	// adoptedCerts are the files of the certificates of the engine of an
+	// adopted host, named like in DOCKER_CERT_PATH.
+	adoptedCerts = []string{"ca.pem", "cert.pem", "key.pem"}
 )
 
 // GetCreateFlags registers the flags this driver adds to
@@ -35,7 +73,7 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	return []mcnflag.Flag{
 		mcnflag.StringFlag{
 			Name:  "generic-ip-address",
-			Usage: "IP Address of machine",
+			Usage: "IP Address of machine, IPv4 or IPv6",
 		},
 		mcnflag.StringFlag{
 			Name:  "generic-ssh-user",
@@ -57,13 +95,70 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "SSH port",
 			Value: defaultSSHPort,
 		},
+		mcnflag.StringFlag{
+			Name:  "generic-ssh-bastion",
+			Usage: "Jump host to reach the machine through over SSH, as [user@]host[:port]",
+		},
+		mcnflag.IntFlag{
+			Name:  "generic-engine-port",
+			Usage: "Docker engine port",
+			Value: defaultEnginePort,
+		},
+		mcnflag.BoolFlag{
+			Name:  "generic-adopt",
+			Usage: "Register the machine, already running a Docker engine with TLS, as it is, without provisioning it or restarting its engine",
+		},
+		mcnflag.StringFlag{
+			Name:  "generic-adopt-cert-path",
+			Usage: "Local directory with the ca.pem, cert.pem and key.pem the engine of an adopted machine accepts (default: fetched from the machine)",
+		},
+		mcnflag.StringFlag{
+			Name:  "generic-adopt-remote-cert-path",
+			Usage: "Directory of the machine the ca.pem, cert.pem and key.pem of an adopted machine are fetched from, relative to the home of the SSH user",
+			Value: defaultAdoptRemoteCertPath,
+		},
+		mcnflag.BoolFlag{
+			Name:  "generic-local",
+			Usage: "Provision the machine docker-machine runs on, running the commands directly rather than over SSH, as --generic-transport local",
+		},
+		mcnflag.StringFlag{
+			Name:  "generic-transport",
+			Usage: "How the commands provisioning the machine are run on it: ssh, local, winrm or serial",
+			Value: transportSSH,
+		},
+		mcnflag.IntFlag{
+			Name:  "generic-winrm-port",
+			Usage: "WinRM port (default: 5985, 5986 with --generic-winrm-https)",
+		},
+		mcnflag.StringFlag{
+			Name:  "generic-winrm-user",
+			Usage: "WinRM user, authenticated with basic authentication",
+		},
+		mcnflag.StringFlag{
+			Name:  "generic-winrm-password",
+			Usage: "WinRM password",
+		},
+		mcnflag.BoolFlag{
+			Name:  "generic-winrm-https",
+			Usage: "Connect to WinRM over HTTPS",
+		},
+		mcnflag.BoolFlag{
+			Name:  "generic-winrm-insecure",
+			Usage: "Don't check the certificate of WinRM over HTTPS",
+		},
+		mcnflag.StringFlag{
+			Name:  "generic-serial-console",
+			Usage: "Serial console of the machine, logged in to a shell: unix:PATH, tcp:HOST:PORT or the path of a terminal device",
+		},
 	}
 }
 
 // NewDriver creates and returns a new instance of the driver
 func NewDriver(hostName, storePath string) drivers.Driver {
 	return &Driver{
-		SSHKey: defaultSSHKey,
+		SSHKey:              defaultSSHKey,
+		EnginePort:          defaultEnginePort,
+		AdoptRemoteCertPath: defaultAdoptRemoteCertPath,
 		BaseDriver: &drivers.BaseDriver{
 			SSHUser:     defaultSSHUser,
 			SSHPort:     defaultSSHPort,
@@ -86,39 +181,191 @@ func (d *Driver) GetSSHUsername() string {
 }
 
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
-	d.IPAddress = flags.String("generic-ip-address")
+	// IPv6 addresses may be given bracketed, as in URLs.
+	d.IPAddress = strings.Trim(flags.String("generic-ip-address"), "[]")
 	d.SSHUser = flags.String("generic-ssh-user")
 	d.SSHPass = flags.String("generic-ssh-pass")
 	d.SSHKey = flags.String("generic-ssh-key")
 	d.SSHPort = flags.Int("generic-ssh-port")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	if bastion := flags.String("generic-ssh-bastion"); bastion != "" {
+		d.SSHBastion = bastion
+	}
+	d.EnginePort = flags.Int("generic-engine-port")
+	d.Adopt = flags.Bool("generic-adopt")
+	d.AdoptCertPath = flags.String("generic-adopt-cert-path")
+	d.AdoptRemoteCertPath = flags.String("generic-adopt-remote-cert-path")
+	d.Transport = flags.String("generic-transport")
+	d.WinRMPort = flags.Int("generic-winrm-port")
+	d.WinRMUser = flags.String("generic-winrm-user")
+	d.WinRMPassword = flags.String("generic-winrm-password")
+	d.WinRMHTTPS = flags.Bool("generic-winrm-https")
+	d.WinRMInsecure = flags.Bool("generic-winrm-insecure")
+	d.SerialConsole = flags.String("generic-serial-console")
+
+	if d.Transport == "" {
+		d.Transport = transportSSH
+	}
+	if flags.Bool("generic-local") {
+		if d.Transport != transportSSH && d.Transport != transportLocal {
+			return fmt.Errorf("--generic-local can't be used with --generic-transport %s", d.Transport)
+		}
+		d.Transport = transportLocal
+	}
+	d.Local = d.Transport == transportLocal
 
 	if d.IPAddress == "" {
 		return fmt.Errorf("generic driver requires the --generic-ip-address option")
 	}
 
-	if d.SSHKey == "" {
+	switch d.Transport {
+	case transportSSH, transportLocal:
+	case transportWinRM:
+		if d.WinRMUser == "" {
+			return fmt.Errorf("--generic-transport winrm requires the --generic-winrm-user option")
+		}
+		if d.WinRMPort == 0 {
+			d.WinRMPort = winrm.DefaultPort
+			if d.WinRMHTTPS {
+				d.WinRMPort = winrm.DefaultHTTPSPort
+			}
+		}
+	case transportSerial:
+		if d.SerialConsole == "" {
+			return fmt.Errorf("--generic-transport serial requires the --generic-serial-console option")
+		}
+	default:
+		return fmt.Errorf("Invalid --generic-transport %q, expected ssh, local, winrm or serial", d.Transport)
+	}
+
+	if d.SSHBastion != "" {
+		if _, err := ssh.ParseBastion(d.SSHBastion); err != nil {
+			return err
+		}
+	}
+
+	if d.Local && d.SSHBastion != "" {
+		return fmt.Errorf("--generic-local can't be used with a bastion")
+	}
+	if d.Transport != transportSSH && d.Transport != transportLocal && d.SSHBastion != "" {
+		return fmt.Errorf("--generic-transport %s can't be used with a bastion", d.Transport)
+	}
+
+	if d.SSHKey == "" && d.Transport == transportSSH {
 		return fmt.Errorf("generic driver requires the --generic-ssh-key option")
 	}
 
+	if d.AdoptCertPath != "" && !d.Adopt {
+		return fmt.Errorf("--generic-adopt-cert-path can only be used with --generic-adopt")
+	}
+
 	return nil
 }
 
+// Adopted tells whether the host was registered as it is, with
+// --generic-adopt.
+func (d *Driver) Adopted() bool {
+	return d.Adopt
+}
+
+// transport returns the transport the commands are run with otherwise than
+// over SSH, nil for the machines reached over SSH. The machines created
+// before --generic-transport only have Local set.
+func (d *Driver) transport() drivers.Transport {
+	switch {
+	case d.Local:
+		return drivers.LocalTransport{}
+	case d.Transport == transportWinRM:
+		return drivers.WinRMTransport{Client: winrm.NewClient(d.IPAddress, d.WinRMPort, d.WinRMHTTPS, d.WinRMInsecure, d.WinRMUser, d.WinRMPassword)}
+	case d.Transport == transportSerial:
+		return drivers.SerialTransport{Address: d.SerialConsole}
+	}
+	return nil
+}
+
+// SupportsTransport tells whether the commands are run otherwise than over
+// SSH, with --generic-local or --generic-transport.
+func (d *Driver) SupportsTransport() bool {
+	return d.transport() != nil
+}
+
+// RunCommand runs the command on the machine with the transport of the
+// machine, for the machines not reached over SSH.
+func (d *Driver) RunCommand(command string) (string, error) {
+	return d.RunCommandWithInput(command, nil)
+}
+
+func (d *Driver) RunCommandWithInput(command string, input []byte) (string, error) {
+	transport := d.transport()
+	if transport == nil {
+		return "", drivers.ErrTransportNotImplemented
+	}
+	return transport.RunCommandWithInput(command, input)
+}
+
 func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
 func (d *Driver) Create() error {
+	if err := d.importSSHKey(); err != nil {
+		return err
+	}
+
+	log.Debugf("IP: %s", d.IPAddress)
+
+	if d.Adopt {
+		return d.adoptCerts()
+	}
+
+	return nil
+}
+
+// importSSHKey copies the SSH key to the directory of the machine, which
+// machines provisioned otherwise than over SSH are not reached with.
+func (d *Driver) importSSHKey() error {
+	if d.SupportsTransport() {
+		return nil
+	}
+
 	log.Infof("Importing SSH key...")
 
 	if err := mcnutils.CopyFile(d.SSHKey, d.GetSSHKeyPath()); err != nil {
 		return fmt.Errorf("unable to copy ssh key: %s", err)
 	}
 
-	if err := os.Chmod(d.GetSSHKeyPath(), 0600); err != nil {
-		return err
-	}
+	return os.Chmod(d.GetSSHKeyPath(), 0600)
+}
 
-	log.Debugf("IP: %s", d.IPAddress)
+// adoptCerts copies the certificates the engine of the adopted host accepts
+// to the directory of the machine, from the local directory given or else
+// from the host.
+func (d *Driver) adoptCerts() error {
+	for _, name := range adoptedCerts {
+		var data []byte
+		if d.AdoptCertPath != "" {
+			log.Infof("Importing %s...", name)
+
+			content, err := ioutil.ReadFile(filepath.Join(d.AdoptCertPath, name))
+			if err != nil {
+				return fmt.Errorf("unable to read the certificates of the engine: %s", err)
+			}
+			data = content
+		} else {
+			log.Infof("Fetching %s from the machine...", name)
+
+			output, err := drivers.RunCommandFromDriver(d, fmt.Sprintf("cat %s/%s", d.AdoptRemoteCertPath, name))
+			if err != nil {
+				return fmt.Errorf("unable to fetch the certificates of the engine from %s, give them with --generic-adopt-cert-path: %s", d.AdoptRemoteCertPath, err)
+			}
+			data = []byte(output)
+		}
+
+		if err := ioutil.WriteFile(d.ResolveStorePath(name), data, 0600); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -128,7 +375,11 @@ func (d *Driver) GetURL() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	port := d.EnginePort
+	if port == 0 {
+		port = defaultEnginePort
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(port))), nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -139,7 +390,28 @@ func (d *Driver) GetIP() (string, error) {
 }
 
 func (d *Driver) GetState() (state.State, error) {
-	addr := fmt.Sprintf("%s:%d", d.IPAddress, d.SSHPort)
+	// The machine runs this very code.
+	if d.Local {
+		return state.Running, nil
+	}
+
+	// The machine is running when it runs commands, when it is reached
+	// otherwise than over SSH, or behind a bastion, through which only it
+	// can be reached.
+	if d.SupportsTransport() {
+		if _, err := d.RunCommand("exit 0"); err != nil {
+			return state.Stopped, nil
+		}
+		return state.Running, nil
+	}
+	if d.SSHBastion != "" {
+		if _, err := drivers.RunSSHCommandFromDriver(d, "exit 0"); err != nil {
+			return state.Stopped, nil
+		}
+		return state.Running, nil
+	}
+
+	addr := net.JoinHostPort(d.IPAddress, strconv.Itoa(d.SSHPort))
 	_, err := net.DialTimeout("tcp", addr, defaultTimeout)
 	var st state.State
 	if err != nil {
@@ -163,11 +435,15 @@ func (d *Driver) Remove() error {
 }
 
 func (d *Driver) Restart() error {
+	if d.Adopt {
+		return fmt.Errorf("generic driver does not restart adopted hosts")
+	}
+
 	log.Debug("Restarting...")
 
 	command := "shutdown -r now"
 	command = d.SSHSudo(command)
-	if _, err := drivers.RunSSHCommandFromDriver(d, command); err != nil {
+	if _, err := drivers.RunCommandFromDriver(d, command); err != nil {
 		return err
 	}
 
@@ -175,11 +451,15 @@ func (d *Driver) Restart() error {
 }
 
 func (d *Driver) Kill() error {
+	if d.Adopt {
+		return fmt.Errorf("generic driver does not kill adopted hosts")
+	}
+
 	log.Debug("Killing...")
 
 	command := "shutdown -P now"
 	command = d.SSHSudo(command)
-	if _, err := drivers.RunSSHCommandFromDriver(d, command); err != nil {
+	if _, err := drivers.RunCommandFromDriver(d, command); err != nil {
 		return err
 	}
 
@@ -0,0 +1,217 @@
+package generic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+func adoptFlags(data map[string]interface{}) DriverOptionsMock {
+	flags := DriverOptionsMock{Data: map[string]interface{}{
+		"generic-ip-address":  "10.0.0.5",
+		"generic-ssh-user":    "root",
+		"generic-ssh-key":     "/home/me/.ssh/id_rsa",
+		"generic-ssh-port":    22,
+		"generic-engine-port": 2376,
+	}}
+	for key, value := range data {
+		flags.Data[key] = value
+	}
+	return flags
+}
+
+func TestSetConfigFromFlagsAdopt(t *testing.T) {
+	d := NewDriver("prod", "/store").(*Driver)
+
+	assert.NoError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-adopt":                  true,
+		"generic-adopt-remote-cert-path": "/etc/docker/client",
+		"generic-engine-port":            2377,
+	})))
+	assert.True(t, d.Adopted())
+	assert.True(t, drivers.IsAdopted(d))
+	assert.Equal(t, "/etc/docker/client", d.AdoptRemoteCertPath)
+
+	url, err := d.GetURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://10.0.0.5:2377", url)
+}
+
+func TestSetConfigFromFlagsAdoptCertPathWithoutAdopt(t *testing.T) {
+	d := NewDriver("prod", "/store").(*Driver)
+
+	assert.EqualError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-adopt-cert-path": "/home/me/certs",
+	})), "--generic-adopt-cert-path can only be used with --generic-adopt")
+}
+
+func TestGetURLOfOlderConfig(t *testing.T) {
+	d := &Driver{BaseDriver: &drivers.BaseDriver{IPAddress: "10.0.0.5"}}
+
+	url, err := d.GetURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://10.0.0.5:2376", url)
+	assert.False(t, d.Adopted())
+}
+
+func TestAdoptCertsFromLocalDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-generic-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certs := filepath.Join(dir, "certs")
+	assert.NoError(t, os.MkdirAll(certs, 0700))
+	for _, name := range adoptedCerts {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(certs, name), []byte(name+" content"), 0600))
+	}
+
+	d := NewDriver("prod", dir).(*Driver)
+	d.Adopt = true
+	d.AdoptCertPath = certs
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "machines", "prod"), 0700))
+
+	assert.NoError(t, d.adoptCerts())
+	for _, name := range adoptedCerts {
+		data, err := ioutil.ReadFile(d.ResolveStorePath(name))
+		assert.NoError(t, err)
+		assert.Equal(t, name+" content", string(data))
+	}
+
+	d.AdoptCertPath = filepath.Join(dir, "missing")
+	assert.Error(t, d.adoptCerts())
+}
+
+func TestAdoptedHostIsLeftAsItIs(t *testing.T) {
+	d := NewDriver("prod", "/store").(*Driver)
+	d.Adopt = true
+
+	assert.EqualError(t, d.Restart(), "generic driver does not restart adopted hosts")
+	assert.EqualError(t, d.Kill(), "generic driver does not kill adopted hosts")
+}
+
+func TestLocal(t *testing.T) {
+	d := NewDriver("local", "/store").(*Driver)
+
+	assert.NoError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-local": true,
+	})))
+	assert.True(t, drivers.SupportsTransport(d))
+
+	st, err := d.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, "Running", st.String())
+
+	output, err := drivers.RunCommandFromDriver(d, "echo hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", output)
+}
+
+func TestLocalWithBastion(t *testing.T) {
+	d := NewDriver("local", "/store").(*Driver)
+
+	assert.EqualError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-local":       true,
+		"generic-ssh-bastion": "jump.example.com",
+	})), "--generic-local can't be used with a bastion")
+}
+
+func TestNotLocal(t *testing.T) {
+	d := NewDriver("remote", "/store").(*Driver)
+
+	assert.NoError(t, d.SetConfigFromFlags(adoptFlags(nil)))
+	assert.False(t, drivers.SupportsTransport(d))
+	assert.IsType(t, drivers.SSHTransport{}, drivers.GetTransport(d))
+}
+
+func TestTransport(t *testing.T) {
+	d := NewDriver("windows", "/store").(*Driver)
+
+	assert.NoError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-transport":      "winrm",
+		"generic-winrm-user":     "admin",
+		"generic-winrm-password": "secret",
+		"generic-winrm-https":    true,
+	})))
+	assert.True(t, drivers.SupportsTransport(d))
+	transport := d.transport().(drivers.WinRMTransport)
+	assert.Equal(t, "https://10.0.0.5:5986/wsman", transport.Client.Endpoint)
+	assert.Equal(t, "admin", transport.Client.User)
+
+	d = NewDriver("console", "/store").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-transport":      "serial",
+		"generic-serial-console": "unix:/var/run/console.sock",
+	})))
+	assert.Equal(t, drivers.SerialTransport{Address: "unix:/var/run/console.sock"}, d.transport())
+
+	d = NewDriver("local", "/store").(*Driver)
+	assert.NoError(t, d.SetConfigFromFlags(adoptFlags(map[string]interface{}{
+		"generic-transport": "local",
+	})))
+	assert.True(t, d.Local)
+	assert.Equal(t, drivers.LocalTransport{}, d.transport())
+}
+
+func TestTransportErrors(t *testing.T) {
+	tests := []struct {
+		flags map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{"generic-transport": "telnet"}, `Invalid --generic-transport "telnet", expected ssh, local, winrm or serial`},
+		{map[string]interface{}{"generic-transport": "winrm"}, "--generic-transport winrm requires the --generic-winrm-user option"},
+		{map[string]interface{}{"generic-transport": "serial"}, "--generic-transport serial requires the --generic-serial-console option"},
+		{map[string]interface{}{"generic-transport": "serial", "generic-local": true}, "--generic-local can't be used with --generic-transport serial"},
+		{map[string]interface{}{"generic-transport": "winrm", "generic-winrm-user": "admin", "generic-ssh-bastion": "jump.example.com"}, "--generic-transport winrm can't be used with a bastion"},
+	}
+
+	for _, test := range tests {
+		d := NewDriver("remote", "/store").(*Driver)
+		assert.EqualError(t, d.SetConfigFromFlags(adoptFlags(test.flags)), test.err)
+	}
+}
+
+func TestSerialTransportState(t *testing.T) {
+	d := NewDriver("console", "/store").(*Driver)
+	d.Transport = transportSerial
+	d.SerialConsole = filepath.Join(os.TempDir(), "missing-console")
+
+	st, err := d.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, "Stopped", st.String())
+}
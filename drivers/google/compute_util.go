@@ -1,16 +1,22 @@
 package google
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/ssh"
 	raw "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -27,6 +33,7 @@ type ComputeUtil struct {
 	preemptible   bool
 	useInternalIP bool
 	service       *raw.Service
+	client        *http.Client
 	zoneURL       string
 	globalURL     string
 	ipAddress     string
@@ -41,6 +48,7 @@ const (
 	firewallTargetTag  = "docker-machine"
 	dockerStartCommand = "sudo service docker start"
 	dockerStopCommand  = "sudo service docker stop"
+	volumeDeviceName   = "docker-volume"
 )
 
 // NewComputeUtil creates and initializes a ComputeUtil.
@@ -65,6 +73,7 @@ func newComputeUtil(driver *Driver) (*ComputeUtil, error) {
 		preemptible:   driver.Preemptible,
 		useInternalIP: driver.UseInternalIP,
 		service:       service,
+		client:        client,
 		zoneURL:       apiURL + driver.Project + "/zones/" + driver.Zone,
 		globalURL:     apiURL + driver.Project + "/global",
 		SwarmMaster:   driver.SwarmMaster,
@@ -97,6 +106,60 @@ func (c *ComputeUtil) deleteDisk() error {
 	return c.waitForRegionalOp(op.Name)
 }
 
+func (c *ComputeUtil) volumeDiskName() string {
+	return c.instanceName + "-volume"
+}
+
+// volumeDisk returns the persistent disk of the volume given with
+// --attach-volume.
+func (c *ComputeUtil) volumeDisk() (*raw.Disk, error) {
+	return c.service.Disks.Get(c.project, c.zone, c.volumeDiskName()).Do()
+}
+
+// deleteVolumeDisk deletes the persistent disk of the volume.
+func (c *ComputeUtil) deleteVolumeDisk() error {
+	log.Infof("Deleting volume disk.")
+	op, err := c.service.Disks.Delete(c.project, c.zone, c.volumeDiskName()).Do()
+	if err != nil {
+		return err
+	}
+	log.Infof("Waiting for volume disk to delete.")
+	return c.waitForRegionalOp(op.Name)
+}
+
+// volumeAttachedDisk returns the disk of the volume to attach to the
+// instance, created from scratch or the existing one, which is kept with the
+// boot disk when the instance is deleted. Its device name gives it a stable
+// path on the instance, see Driver.GetVolumeDevices.
+func volumeAttachedDisk(volume *drivers.Volume, zoneURL, diskName string, exists bool) *raw.AttachedDisk {
+	disk := &raw.AttachedDisk{
+		AutoDelete: false,
+		Type:       "PERSISTENT",
+		Mode:       "READ_WRITE",
+		DeviceName: volumeDeviceName,
+	}
+
+	if exists {
+		disk.Source = zoneURL + "/disks/" + diskName
+		return disk
+	}
+
+	disk.InitializeParams = &raw.AttachedDiskInitializeParams{
+		DiskName:   diskName,
+		DiskSizeGb: int64(volume.Size),
+		DiskType:   zoneURL + "/diskTypes/" + volumeDiskType(volume),
+	}
+	return disk
+}
+
+// volumeDiskType returns the type of the persistent disk of volume.
+func volumeDiskType(volume *drivers.Volume) string {
+	if volume.Type == drivers.VolumeTypeHDD {
+		return "pd-standard"
+	}
+	return "pd-ssd"
+}
+
 // staticAddress returns the external static IP address.
 func (c *ComputeUtil) staticAddress() (string, error) {
 	// is the address a name?
@@ -219,6 +282,32 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		},
 	}
 
+	for i := 0; i < d.LocalSSDCount; i++ {
+		instance.Disks = append(instance.Disks, &raw.AttachedDisk{
+			AutoDelete: true,
+			Type:       "SCRATCH",
+			Mode:       "READ_WRITE",
+			Interface:  d.LocalSSDInterface,
+			InitializeParams: &raw.AttachedDiskInitializeParams{
+				DiskType: c.zoneURL + "/diskTypes/local-ssd",
+			},
+		})
+	}
+
+	accelerators, err := parseAccelerators(d.Accelerators, c.zoneURL)
+	if err != nil {
+		return err
+	}
+	if len(accelerators) > 0 {
+		// Instances with GPUs can't be live migrated.
+		instance.Scheduling.OnHostMaintenance = "TERMINATE"
+	}
+
+	networks, err := parseNetworks(d.Networks, c.globalURL, apiURL+c.project+"/regions/"+c.region())
+	if err != nil {
+		return err
+	}
+
 	if c.address != "" {
 		staticAddress, err := c.staticAddress()
 		if err != nil {
@@ -240,7 +329,23 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 	} else {
 		instance.Disks[0].Source = c.zoneURL + "/disks/" + c.instanceName + "-disk"
 	}
-	op, err := c.service.Instances.Insert(c.project, c.zone, instance).Do()
+
+	if d.AttachVolume != nil {
+		volume, err := c.volumeDisk()
+		exists := volume != nil && err == nil
+		instance.Disks = append(instance.Disks, volumeAttachedDisk(d.AttachVolume, c.zoneURL, c.volumeDiskName(), exists))
+	}
+
+	labels := gceLabels(d.ResourceTags)
+
+	op, err := c.insertInstance(&instanceRequest{
+		Instance:               instance,
+		Disks:                  labeledDisks(instance.Disks, labels),
+		NetworkInterfaces:      append([]*networkInterface{{NetworkInterface: instance.NetworkInterfaces[0]}}, networks...),
+		GuestAccelerators:      accelerators,
+		ShieldedInstanceConfig: shieldedConfig(d),
+		Labels:                 labels,
+	})
 
 	if err != nil {
 		return err
@@ -279,6 +384,234 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 	return c.waitForRegionalOp(op.Name)
 }
 
+// instanceRequest is an instance with the fields of the API the vendored
+// client doesn't know about.
+type instanceRequest struct {
+	*raw.Instance
+	Disks                  []*attachedDisk         `json:"disks,omitempty"`
+	NetworkInterfaces      []*networkInterface     `json:"networkInterfaces,omitempty"`
+	GuestAccelerators      []*acceleratorConfig    `json:"guestAccelerators,omitempty"`
+	ShieldedInstanceConfig *shieldedInstanceConfig `json:"shieldedInstanceConfig,omitempty"`
+	Labels                 map[string]string       `json:"labels,omitempty"`
+}
+
+type attachedDisk struct {
+	*raw.AttachedDisk
+	InitializeParams *diskInitializeParams `json:"initializeParams,omitempty"`
+}
+
+type diskInitializeParams struct {
+	*raw.AttachedDiskInitializeParams
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type networkInterface struct {
+	*raw.NetworkInterface
+	Subnetwork string `json:"subnetwork,omitempty"`
+}
+
+type acceleratorConfig struct {
+	AcceleratorType  string `json:"acceleratorType"`
+	AcceleratorCount int64  `json:"acceleratorCount"`
+}
+
+type shieldedInstanceConfig struct {
+	EnableSecureBoot          bool `json:"enableSecureBoot"`
+	EnableVtpm                bool `json:"enableVtpm"`
+	EnableIntegrityMonitoring bool `json:"enableIntegrityMonitoring"`
+}
+
+// insertInstance creates an instance, like Instances.Insert does.
+func (c *ComputeUtil) insertInstance(instance *instanceRequest) (*raw.Operation, error) {
+	return c.postZoneOp("instances", instance)
+}
+
+// setMachineType changes the machine type of the stopped instance, which
+// the vendored API has no Instances.SetMachineType for.
+func (c *ComputeUtil) setMachineType(machineType string) error {
+	log.Infof("Changing the machine type of the instance to %s.", machineType)
+	op, err := c.postZoneOp("instances/"+c.instanceName+"/setMachineType", map[string]string{
+		"machineType": c.zoneURL + "/machineTypes/" + machineType,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Waiting for the machine type to change.")
+	return c.waitForRegionalOp(op.Name)
+}
+
+// postZoneOp posts body to the path of the zone of the instance, like the
+// generated calls returning an operation do.
+func (c *ComputeUtil) postZoneOp(path string, body interface{}) (*raw.Operation, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := googleapi.ResolveRelative(c.service.BasePath, "{project}/zones/{zone}/"+path) + "?alt=json"
+	req, err := http.NewRequest("POST", urls, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	googleapi.Expand(req.URL, map[string]string{
+		"project": c.project,
+		"zone":    c.zone,
+	})
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	var op *raw.Operation
+	if err := json.NewDecoder(res.Body).Decode(&op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// labeledDisks returns the disks of an instance, with labels on the
+// persistent disks created with it.
+func labeledDisks(disks []*raw.AttachedDisk, labels map[string]string) []*attachedDisk {
+	labeled := []*attachedDisk{}
+
+	for _, disk := range disks {
+		ad := &attachedDisk{AttachedDisk: disk}
+		if disk.InitializeParams != nil {
+			ad.InitializeParams = &diskInitializeParams{AttachedDiskInitializeParams: disk.InitializeParams}
+			if disk.Type == "PERSISTENT" {
+				ad.InitializeParams.Labels = labels
+			}
+		}
+		labeled = append(labeled, ad)
+	}
+
+	return labeled
+}
+
+var gceLabelInvalidChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// gceLabels returns tags as GCE labels, whose keys and values only have
+// lowercase letters, digits, underscores and dashes, and up to 63 of them.
+func gceLabels(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for k, v := range tags {
+		labels[gceLabel(k)] = gceLabel(v)
+	}
+	return labels
+}
+
+func gceLabel(s string) string {
+	s = gceLabelInvalidChars.ReplaceAllString(strings.ToLower(s), "_")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
+
+// parseAccelerators parses accelerators given as "[type=]<type>[,count=<n>]",
+// e.g. "nvidia-tesla-t4,count=1".
+func parseAccelerators(accelerators []string, zoneURL string) ([]*acceleratorConfig, error) {
+	configs := []*acceleratorConfig{}
+
+	for _, accelerator := range accelerators {
+		config := &acceleratorConfig{AcceleratorCount: 1}
+
+		for i, part := range strings.Split(accelerator, ",") {
+			kv := strings.SplitN(part, "=", 2)
+			switch {
+			case len(kv) == 1 && i == 0:
+				config.AcceleratorType = kv[0]
+			case len(kv) == 2 && kv[0] == "type":
+				config.AcceleratorType = kv[1]
+			case len(kv) == 2 && kv[0] == "count":
+				count, err := strconv.ParseInt(kv[1], 10, 64)
+				if err != nil || count < 1 {
+					return nil, fmt.Errorf("Invalid accelerator count in %q", accelerator)
+				}
+				config.AcceleratorCount = count
+			default:
+				return nil, fmt.Errorf("Invalid accelerator %q, expected <type>[,count=<n>]", accelerator)
+			}
+		}
+
+		if config.AcceleratorType == "" {
+			return nil, fmt.Errorf("Invalid accelerator %q, expected <type>[,count=<n>]", accelerator)
+		}
+		config.AcceleratorType = zoneURL + "/acceleratorTypes/" + config.AcceleratorType
+
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// parseNetworks returns the network interfaces attaching the instance to
+// networks given as "<network>[/<subnetwork>]", e.g. "data/data-us-east1".
+// They have no external address.
+func parseNetworks(networks []string, globalURL, regionURL string) ([]*networkInterface, error) {
+	interfaces := []*networkInterface{}
+
+	for _, network := range networks {
+		parts := strings.Split(network, "/")
+		if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			return nil, fmt.Errorf("Invalid network %q, expected <network>[/<subnetwork>]", network)
+		}
+
+		iface := &networkInterface{
+			NetworkInterface: &raw.NetworkInterface{Network: globalURL + "/networks/" + parts[0]},
+		}
+		if len(parts) == 2 {
+			iface.Subnetwork = regionURL + "/subnetworks/" + parts[1]
+		}
+
+		interfaces = append(interfaces, iface)
+	}
+
+	return interfaces, nil
+}
+
+// instanceAddresses returns ip, the address of the instance on its first
+// network interface, then the internal addresses of the others, named after
+// their networks.
+func instanceAddresses(ip string, interfaces []*raw.NetworkInterface) []drivers.Address {
+	addresses := []drivers.Address{}
+
+	for i, iface := range interfaces {
+		network := iface.Network[strings.LastIndex(iface.Network, "/")+1:]
+		if i == 0 {
+			addresses = append(addresses, drivers.Address{Network: network, IP: ip})
+			continue
+		}
+		addresses = append(addresses, drivers.Address{Network: network, IP: iface.NetworkIP})
+	}
+
+	return addresses
+}
+
+// shieldedConfig returns the shielded VM options of the instance, or
+// nil when none is enabled.
+func shieldedConfig(d *Driver) *shieldedInstanceConfig {
+	if !d.ShieldedSecureBoot && !d.ShieldedVtpm && !d.ShieldedIntegrityMonitoring {
+		return nil
+	}
+	return &shieldedInstanceConfig{
+		EnableSecureBoot:          d.ShieldedSecureBoot,
+		EnableVtpm:                d.ShieldedVtpm,
+		EnableIntegrityMonitoring: d.ShieldedIntegrityMonitoring,
+	}
+}
+
 // parseTags computes the tags for the instance.
 func parseTags(d *Driver) []string {
 	tags := []string{firewallTargetTag}
@@ -375,6 +708,21 @@ func (c *ComputeUtil) waitForGlobalOp(name string) error {
 	})
 }
 
+// addresses returns the addresses of the instance on its networks.
+func (c *ComputeUtil) addresses() ([]drivers.Address, error) {
+	ip, err := c.ip()
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := c.instance()
+	if err != nil {
+		return nil, err
+	}
+
+	return instanceAddresses(ip, instance.NetworkInterfaces), nil
+}
+
 // ip retrieves and returns the external IP address of the instance.
 func (c *ComputeUtil) ip() (string, error) {
 	if c.ipAddress == "" {
@@ -1,9 +1,12 @@
 package google
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
+	raw "google.golang.org/api/compute/v1"
 )
 
 func TestDefaultTag(t *testing.T) {
@@ -23,3 +26,190 @@ func TestAdditionalTags(t *testing.T) {
 
 	assert.Equal(t, []string{"docker-machine", "tag1", "tag2"}, tags)
 }
+
+func TestParseAccelerators(t *testing.T) {
+	accelerators, err := parseAccelerators([]string{"nvidia-tesla-t4,count=2", "type=nvidia-tesla-k80"}, "zone")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*acceleratorConfig{
+		{AcceleratorType: "zone/acceleratorTypes/nvidia-tesla-t4", AcceleratorCount: 2},
+		{AcceleratorType: "zone/acceleratorTypes/nvidia-tesla-k80", AcceleratorCount: 1},
+	}, accelerators)
+}
+
+func TestParseAcceleratorsInvalid(t *testing.T) {
+	_, err := parseAccelerators([]string{"nvidia-tesla-t4,count=0"}, "zone")
+	assert.EqualError(t, err, `Invalid accelerator count in "nvidia-tesla-t4,count=0"`)
+
+	_, err = parseAccelerators([]string{"count=1"}, "zone")
+	assert.EqualError(t, err, `Invalid accelerator "count=1", expected <type>[,count=<n>]`)
+
+	_, err = parseAccelerators([]string{"nvidia-tesla-t4,size=1"}, "zone")
+	assert.EqualError(t, err, `Invalid accelerator "nvidia-tesla-t4,size=1", expected <type>[,count=<n>]`)
+}
+
+func TestParseNetworks(t *testing.T) {
+	interfaces, err := parseNetworks([]string{"data", "overlay/overlay-us-central1"}, "global", "region")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*networkInterface{
+		{NetworkInterface: &raw.NetworkInterface{Network: "global/networks/data"}},
+		{NetworkInterface: &raw.NetworkInterface{Network: "global/networks/overlay"}, Subnetwork: "region/subnetworks/overlay-us-central1"},
+	}, interfaces)
+
+	for _, network := range []string{"", "/subnet", "data/", "data/subnet/extra"} {
+		_, err := parseNetworks([]string{network}, "global", "region")
+		assert.EqualError(t, err, `Invalid network "`+network+`", expected <network>[/<subnetwork>]`)
+	}
+}
+
+func TestInstanceAddresses(t *testing.T) {
+	addresses := instanceAddresses("203.0.113.5", []*raw.NetworkInterface{
+		{Network: "https://www.googleapis.com/compute/v1/projects/p/global/networks/default", NetworkIP: "10.128.0.2"},
+		{Network: "https://www.googleapis.com/compute/v1/projects/p/global/networks/data", NetworkIP: "10.2.0.2"},
+	})
+
+	assert.Equal(t, []drivers.Address{
+		{Network: "default", IP: "203.0.113.5"},
+		{Network: "data", IP: "10.2.0.2"},
+	}, addresses)
+}
+
+func TestShieldedConfig(t *testing.T) {
+	assert.Nil(t, shieldedConfig(&Driver{}))
+	assert.Equal(t, &shieldedInstanceConfig{EnableSecureBoot: true}, shieldedConfig(&Driver{ShieldedSecureBoot: true}))
+}
+
+func TestInstanceRequest(t *testing.T) {
+	request, err := json.Marshal(&instanceRequest{
+		Instance: &raw.Instance{Name: "default"},
+		GuestAccelerators: []*acceleratorConfig{
+			{AcceleratorType: "nvidia-tesla-t4", AcceleratorCount: 1},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"default","guestAccelerators":[{"acceleratorType":"nvidia-tesla-t4","acceleratorCount":1}]}`, string(request))
+}
+
+func TestInstanceRequestNetworkInterfaces(t *testing.T) {
+	request, err := json.Marshal(&instanceRequest{
+		Instance: &raw.Instance{
+			Name:              "default",
+			NetworkInterfaces: []*raw.NetworkInterface{{Network: "networks/default"}},
+		},
+		NetworkInterfaces: []*networkInterface{
+			{NetworkInterface: &raw.NetworkInterface{Network: "networks/default"}},
+			{NetworkInterface: &raw.NetworkInterface{Network: "networks/data"}, Subnetwork: "subnetworks/data"},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"default","networkInterfaces":[{"network":"networks/default"},{"network":"networks/data","subnetwork":"subnetworks/data"}]}`, string(request))
+}
+
+func TestInstanceRequestLabels(t *testing.T) {
+	labels := gceLabels(map[string]string{"machine-name": "Web.1", "machine-version": "0.5.0"})
+	assert.Equal(t, map[string]string{"machine-name": "web_1", "machine-version": "0_5_0"}, labels)
+
+	request, err := json.Marshal(&instanceRequest{
+		Instance: &raw.Instance{Name: "default"},
+		Disks: labeledDisks([]*raw.AttachedDisk{
+			{Boot: true, Type: "PERSISTENT", InitializeParams: &raw.AttachedDiskInitializeParams{DiskName: "default-disk"}},
+			{Type: "SCRATCH", InitializeParams: &raw.AttachedDiskInitializeParams{DiskType: "local-ssd"}},
+		}, map[string]string{"team": "infra"}),
+		Labels: map[string]string{"team": "infra"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"default","disks":[{"boot":true,"type":"PERSISTENT","initializeParams":{"diskName":"default-disk","labels":{"team":"infra"}}},{"type":"SCRATCH","initializeParams":{"diskType":"local-ssd"}}],"labels":{"team":"infra"}}`, string(request))
+}
+
+func TestVolumeAttachedDisk(t *testing.T) {
+	volume := &drivers.Volume{Size: 100, Type: "ssd", Mount: "/var/lib/docker"}
+
+	disk := volumeAttachedDisk(volume, "zones/us-central1-a", "default-volume", false)
+	assert.Equal(t, "docker-volume", disk.DeviceName)
+	assert.False(t, disk.AutoDelete)
+	assert.Empty(t, disk.Source)
+	assert.Equal(t, &raw.AttachedDiskInitializeParams{
+		DiskName:   "default-volume",
+		DiskSizeGb: 100,
+		DiskType:   "zones/us-central1-a/diskTypes/pd-ssd",
+	}, disk.InitializeParams)
+
+	volume.Type = "hdd"
+	assert.Equal(t, "zones/us-central1-a/diskTypes/pd-standard", volumeAttachedDisk(volume, "zones/us-central1-a", "default-volume", false).InitializeParams.DiskType)
+
+	disk = volumeAttachedDisk(volume, "zones/us-central1-a", "default-volume", true)
+	assert.Equal(t, "zones/us-central1-a/disks/default-volume", disk.Source)
+	assert.Nil(t, disk.InitializeParams)
+}
+
+func TestPlan(t *testing.T) {
+	d := NewDriver("test", "")
+	d.MachineType = "n1-standard-1"
+	d.MachineImage = "ubuntu-2004"
+	d.Zone = "us-central1-a"
+	d.DiskSize = 20
+	d.DiskType = "pd-standard"
+	d.Preemptible = true
+	d.LocalSSDCount = 1
+	d.LocalSSDInterface = "NVME"
+	d.Accelerators = []string{"nvidia-tesla-t4,count=2"}
+	d.Networks = []string{"data/data-us-central1"}
+	d.AttachVolume = &drivers.Volume{Size: 100, Type: "hdd", Mount: "/var/lib/docker"}
+	d.SwarmMaster = true
+	d.SwarmHost = "tcp://0.0.0.0:3376"
+
+	resources, err := d.Plan()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PlannedResource{
+		{Kind: "instance", Description: "n1-standard-1 from ubuntu-2004 in us-central1-a, preemptible"},
+		{Kind: "disk", Description: "20GB pd-standard boot disk test-disk"},
+		{Kind: "disk", Description: "NVME local SSD"},
+		{Kind: "disk", Description: "100GB pd-standard volume disk test-volume"},
+		{Kind: "accelerator", Description: "2 nvidia-tesla-t4"},
+		{Kind: "network", Description: "default, with an ephemeral external address"},
+		{Kind: "network", Description: "data/data-us-central1, with an internal address only"},
+		{Kind: "firewall-rule", Description: "docker-machines, allowing tcp to 2376, 3376 from 0.0.0.0/0 for the instances tagged docker-machine, unless it exists"},
+	}, resources)
+}
+
+func TestTerraformResources(t *testing.T) {
+	d := NewDriver("test", "")
+	d.Project = "project"
+	d.Zone = "us-central1-a"
+	d.Address = "static"
+	d.IPAddress = "203.0.113.10"
+	d.AttachVolume = &drivers.Volume{Size: 100, Type: "ssd", Mount: "/var/lib/docker"}
+
+	resources, err := d.TerraformResources()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(resources))
+	assert.Equal(t, "google_compute_disk.test-disk", resources[0].Address())
+	assert.Equal(t, "projects/project/zones/us-central1-a/disks/test-disk", resources[0].ID)
+	assert.Equal(t, "google_compute_disk.test-volume", resources[1].Address())
+	assert.Equal(t, "google_compute_firewall.docker-machines", resources[2].Address())
+	assert.Equal(t, "projects/project/global/firewalls/docker-machines", resources[2].ID)
+
+	instance := resources[3]
+	assert.Equal(t, "projects/project/zones/us-central1-a/instances/test", instance.ID)
+	assert.Equal(t, drivers.TerraformBlock{
+		Type: "boot_disk",
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "source", Value: "google_compute_disk.test-disk.self_link"},
+			{Name: "auto_delete", Value: "false"},
+		},
+	}, instance.Blocks[0])
+	assert.Equal(t, "attached_disk", instance.Blocks[1].Type)
+	assert.Equal(t, drivers.TerraformBlock{
+		Type:       "network_interface",
+		Attributes: []drivers.TerraformAttribute{{Name: "network", Value: `"default"`}},
+		Blocks: []drivers.TerraformBlock{
+			{Type: "access_config", Attributes: []drivers.TerraformAttribute{{Name: "nat_ip", Value: `"203.0.113.10"`}}},
+		},
+	}, instance.Blocks[2])
+}
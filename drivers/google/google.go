@@ -2,6 +2,8 @@ package google
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"strings"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -25,6 +27,13 @@ type Driver struct {
 	DiskSize      int
 	Project       string
 	Tags          string
+
+	Accelerators                []string
+	LocalSSDCount               int
+	LocalSSDInterface           string
+	ShieldedSecureBoot          bool
+	ShieldedVtpm                bool
+	ShieldedIntegrityMonitoring bool
 }
 
 const (
@@ -35,6 +44,9 @@ const (
 	defaultScopes      = "https://www.googleapis.com/auth/devstorage.read_only,https://www.googleapis.com/auth/logging.write"
 	defaultDiskType    = "pd-standard"
 	defaultDiskSize    = 10
+
+	defaultLocalSSDInterface = "SCSI"
+	maxLocalSSDCount         = 24
 )
 
 // GetCreateFlags registers the flags this driver adds to
@@ -109,6 +121,37 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Use internal GCE Instance IP rather than public one",
 			EnvVar: "GOOGLE_USE_INTERNAL_IP",
 		},
+		mcnflag.StringSliceFlag{
+			Name:  "google-accelerator",
+			Usage: "GCE Instance accelerator, as <type>[,count=<n>] (e.g. nvidia-tesla-t4,count=1)",
+			Value: []string{},
+		},
+		mcnflag.IntFlag{
+			Name:   "google-local-ssd-count",
+			Usage:  "GCE Instance number of local SSDs (375 GB each)",
+			EnvVar: "GOOGLE_LOCAL_SSD_COUNT",
+		},
+		mcnflag.StringFlag{
+			Name:   "google-local-ssd-interface",
+			Usage:  "GCE Instance local SSD interface, SCSI or NVME",
+			Value:  defaultLocalSSDInterface,
+			EnvVar: "GOOGLE_LOCAL_SSD_INTERFACE",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-shielded-secure-boot",
+			Usage:  "Enable Secure Boot on the shielded GCE Instance",
+			EnvVar: "GOOGLE_SHIELDED_SECURE_BOOT",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-shielded-vtpm",
+			Usage:  "Enable the virtual TPM of the shielded GCE Instance",
+			EnvVar: "GOOGLE_SHIELDED_VTPM",
+		},
+		mcnflag.BoolFlag{
+			Name:   "google-shielded-integrity-monitoring",
+			Usage:  "Enable integrity monitoring of the shielded GCE Instance",
+			EnvVar: "GOOGLE_SHIELDED_INTEGRITY_MONITORING",
+		},
 	}
 }
 
@@ -121,6 +164,8 @@ func NewDriver(machineName string, storePath string) *Driver {
 		MachineType:  defaultMachineType,
 		MachineImage: defaultImageName,
 		Scopes:       defaultScopes,
+
+		LocalSSDInterface: defaultLocalSSDInterface,
 		BaseDriver: &drivers.BaseDriver{
 			SSHUser:     defaultUser,
 			MachineName: machineName,
@@ -147,6 +192,283 @@ func (d *Driver) DriverName() string {
 	return "google"
 }
 
+// Capabilities adds the static addresses and preemptible instances of the
+// driver to those of its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.StaticIP = true
+	capabilities.Spot = true
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the machine type of the machine and its persistent disks.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	pricing := drivers.Pricing{
+		Region:       d.Zone,
+		InstanceType: d.MachineType,
+		Spot:         d.Preemptible,
+		Storage: []drivers.PricedStorage{
+			{Type: d.DiskType, Size: d.DiskSize},
+		},
+	}
+
+	if d.AttachVolume != nil {
+		pricing.Storage = append(pricing.Storage, drivers.PricedStorage{Type: volumeDiskType(d.AttachVolume), Size: d.AttachVolume.Size})
+	}
+
+	return pricing, nil
+}
+
+// Plan returns the resources Create would allocate, without checking which
+// of them, like the firewall rule, already exist.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	instance := fmt.Sprintf("%s from %s in %s", d.MachineType, d.MachineImage, d.Zone)
+	if d.Preemptible {
+		instance += ", preemptible"
+	}
+
+	resources := []drivers.PlannedResource{
+		{Kind: "instance", Description: instance},
+		{Kind: "disk", Description: fmt.Sprintf("%dGB %s boot disk %s-disk", d.DiskSize, d.DiskType, d.MachineName)},
+	}
+
+	for i := 0; i < d.LocalSSDCount; i++ {
+		resources = append(resources, drivers.PlannedResource{Kind: "disk", Description: fmt.Sprintf("%s local SSD", d.LocalSSDInterface)})
+	}
+
+	if d.AttachVolume != nil {
+		resources = append(resources, drivers.PlannedResource{
+			Kind:        "disk",
+			Description: fmt.Sprintf("%dGB %s volume disk %s-volume", d.AttachVolume.Size, volumeDiskType(d.AttachVolume), d.MachineName),
+		})
+	}
+
+	accelerators, err := parseAccelerators(d.Accelerators, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, accelerator := range accelerators {
+		resources = append(resources, drivers.PlannedResource{
+			Kind:        "accelerator",
+			Description: fmt.Sprintf("%d %s", accelerator.AcceleratorCount, strings.TrimPrefix(accelerator.AcceleratorType, "/acceleratorTypes/")),
+		})
+	}
+
+	external := "ephemeral external address"
+	if d.Address != "" {
+		external = "external address " + d.Address
+	}
+	resources = append(resources, drivers.PlannedResource{Kind: "network", Description: "default, with an " + external})
+	for _, network := range d.Networks {
+		resources = append(resources, drivers.PlannedResource{Kind: "network", Description: network + ", with an internal address only"})
+	}
+
+	ports := []string{port}
+	if d.SwarmMaster {
+		if u, err := url.Parse(d.SwarmHost); err == nil {
+			if _, swarmPort, err := net.SplitHostPort(u.Host); err == nil {
+				ports = append(ports, swarmPort)
+			}
+		}
+	}
+	resources = append(resources, drivers.PlannedResource{
+		Kind:        "firewall-rule",
+		Description: fmt.Sprintf("%s, allowing tcp to %s from 0.0.0.0/0 for the instances tagged %s, unless it exists", firewallRule, strings.Join(ports, ", "), firewallTargetTag),
+	})
+
+	return resources, nil
+}
+
+// TerraformResources returns the instance, its disks and the firewall rule
+// Create made for the host.
+func (d *Driver) TerraformResources() ([]drivers.TerraformResource, error) {
+	zoneID := "projects/" + d.Project + "/zones/" + d.Zone
+
+	bootDisk := drivers.TerraformResource{
+		Type: "google_compute_disk",
+		Name: drivers.TerraformName(d.MachineName + "-disk"),
+		ID:   zoneID + "/disks/" + d.MachineName + "-disk",
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "name", Value: drivers.TerraformString(d.MachineName + "-disk")},
+			{Name: "zone", Value: drivers.TerraformString(d.Zone)},
+			{Name: "type", Value: drivers.TerraformString(d.DiskType)},
+			{Name: "size", Value: drivers.TerraformNumber(int64(d.DiskSize))},
+			{Name: "image", Value: drivers.TerraformString(d.MachineImage)},
+		},
+	}
+
+	labels := gceLabels(d.ResourceTags)
+	if len(labels) > 0 {
+		bootDisk.Attributes = append(bootDisk.Attributes, drivers.TerraformAttribute{Name: "labels", Value: drivers.TerraformMap(labels)})
+	}
+
+	ports := []string{drivers.TerraformString(port)}
+	if d.SwarmMaster {
+		if u, err := url.Parse(d.SwarmHost); err == nil {
+			if _, swarmPort, err := net.SplitHostPort(u.Host); err == nil {
+				ports = append(ports, drivers.TerraformString(swarmPort))
+			}
+		}
+	}
+
+	firewall := drivers.TerraformResource{
+		Type: "google_compute_firewall",
+		Name: drivers.TerraformName(firewallRule),
+		ID:   "projects/" + d.Project + "/global/firewalls/" + firewallRule,
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "name", Value: drivers.TerraformString(firewallRule)},
+			{Name: "network", Value: drivers.TerraformString("default")},
+			{Name: "source_ranges", Value: drivers.TerraformList(drivers.TerraformString("0.0.0.0/0"))},
+			{Name: "target_tags", Value: drivers.TerraformList(drivers.TerraformString(firewallTargetTag))},
+		},
+		Blocks: []drivers.TerraformBlock{
+			{
+				Type: "allow",
+				Attributes: []drivers.TerraformAttribute{
+					{Name: "protocol", Value: drivers.TerraformString("tcp")},
+					{Name: "ports", Value: drivers.TerraformList(ports...)},
+				},
+			},
+		},
+	}
+
+	tags := []string{}
+	for _, tag := range parseTags(d) {
+		tags = append(tags, drivers.TerraformString(tag))
+	}
+
+	scopes := []string{}
+	for _, scope := range strings.Split(d.Scopes, ",") {
+		scopes = append(scopes, drivers.TerraformString(scope))
+	}
+
+	instance := drivers.TerraformResource{
+		Type: "google_compute_instance",
+		Name: drivers.TerraformName(d.MachineName),
+		ID:   zoneID + "/instances/" + d.MachineName,
+		Attributes: []drivers.TerraformAttribute{
+			{Name: "name", Value: drivers.TerraformString(d.MachineName)},
+			{Name: "description", Value: drivers.TerraformString("docker host vm")},
+			{Name: "machine_type", Value: drivers.TerraformString(d.MachineType)},
+			{Name: "zone", Value: drivers.TerraformString(d.Zone)},
+			{Name: "tags", Value: drivers.TerraformList(tags...)},
+		},
+		Blocks: []drivers.TerraformBlock{
+			{
+				Type: "boot_disk",
+				Attributes: []drivers.TerraformAttribute{
+					{Name: "source", Value: drivers.TerraformReference(bootDisk, "self_link")},
+					{Name: "auto_delete", Value: drivers.TerraformBool(false)},
+				},
+			},
+		},
+	}
+
+	if len(labels) > 0 {
+		instance.Attributes = append(instance.Attributes, drivers.TerraformAttribute{Name: "labels", Value: drivers.TerraformMap(labels)})
+	}
+
+	resources := []drivers.TerraformResource{bootDisk}
+
+	if d.AttachVolume != nil {
+		volumeDisk := drivers.TerraformResource{
+			Type: "google_compute_disk",
+			Name: drivers.TerraformName(d.MachineName + "-volume"),
+			ID:   zoneID + "/disks/" + d.MachineName + "-volume",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "name", Value: drivers.TerraformString(d.MachineName + "-volume")},
+				{Name: "zone", Value: drivers.TerraformString(d.Zone)},
+				{Name: "type", Value: drivers.TerraformString(volumeDiskType(d.AttachVolume))},
+				{Name: "size", Value: drivers.TerraformNumber(int64(d.AttachVolume.Size))},
+			},
+		}
+		if len(labels) > 0 {
+			volumeDisk.Attributes = append(volumeDisk.Attributes, drivers.TerraformAttribute{Name: "labels", Value: drivers.TerraformMap(labels)})
+		}
+		resources = append(resources, volumeDisk)
+
+		instance.Blocks = append(instance.Blocks, drivers.TerraformBlock{
+			Type: "attached_disk",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "source", Value: drivers.TerraformReference(volumeDisk, "self_link")},
+				{Name: "device_name", Value: drivers.TerraformString(volumeDeviceName)},
+			},
+		})
+	}
+
+	for i := 0; i < d.LocalSSDCount; i++ {
+		instance.Blocks = append(instance.Blocks, drivers.TerraformBlock{
+			Type:       "scratch_disk",
+			Attributes: []drivers.TerraformAttribute{{Name: "interface", Value: drivers.TerraformString(d.LocalSSDInterface)}},
+		})
+	}
+
+	accessConfig := drivers.TerraformBlock{Type: "access_config"}
+	if d.Address != "" {
+		accessConfig.Attributes = []drivers.TerraformAttribute{{Name: "nat_ip", Value: drivers.TerraformString(d.IPAddress)}}
+	}
+	instance.Blocks = append(instance.Blocks, drivers.TerraformBlock{
+		Type:       "network_interface",
+		Attributes: []drivers.TerraformAttribute{{Name: "network", Value: drivers.TerraformString("default")}},
+		Blocks:     []drivers.TerraformBlock{accessConfig},
+	})
+
+	for _, network := range d.Networks {
+		parts := strings.SplitN(network, "/", 2)
+		networkInterface := drivers.TerraformBlock{
+			Type:       "network_interface",
+			Attributes: []drivers.TerraformAttribute{{Name: "network", Value: drivers.TerraformString(parts[0])}},
+		}
+		if len(parts) == 2 {
+			networkInterface.Attributes = append(networkInterface.Attributes, drivers.TerraformAttribute{Name: "subnetwork", Value: drivers.TerraformString(parts[1])})
+		}
+		instance.Blocks = append(instance.Blocks, networkInterface)
+	}
+
+	accelerators, err := parseAccelerators(d.Accelerators, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, accelerator := range accelerators {
+		instance.Blocks = append(instance.Blocks, drivers.TerraformBlock{
+			Type: "guest_accelerator",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "type", Value: drivers.TerraformString(strings.TrimPrefix(accelerator.AcceleratorType, "/acceleratorTypes/"))},
+				{Name: "count", Value: drivers.TerraformNumber(accelerator.AcceleratorCount)},
+			},
+		})
+	}
+
+	scheduling := []drivers.TerraformAttribute{{Name: "preemptible", Value: drivers.TerraformBool(d.Preemptible)}}
+	if d.Preemptible {
+		scheduling = append(scheduling, drivers.TerraformAttribute{Name: "automatic_restart", Value: drivers.TerraformBool(false)})
+	}
+	if len(accelerators) > 0 {
+		scheduling = append(scheduling, drivers.TerraformAttribute{Name: "on_host_maintenance", Value: drivers.TerraformString("TERMINATE")})
+	}
+	instance.Blocks = append(instance.Blocks,
+		drivers.TerraformBlock{Type: "scheduling", Attributes: scheduling},
+		drivers.TerraformBlock{
+			Type:       "service_account",
+			Attributes: []drivers.TerraformAttribute{{Name: "scopes", Value: drivers.TerraformList(scopes...)}},
+		},
+	)
+
+	if shielded := shieldedConfig(d); shielded != nil {
+		instance.Blocks = append(instance.Blocks, drivers.TerraformBlock{
+			Type: "shielded_instance_config",
+			Attributes: []drivers.TerraformAttribute{
+				{Name: "enable_secure_boot", Value: drivers.TerraformBool(shielded.EnableSecureBoot)},
+				{Name: "enable_vtpm", Value: drivers.TerraformBool(shielded.EnableVtpm)},
+				{Name: "enable_integrity_monitoring", Value: drivers.TerraformBool(shielded.EnableIntegrityMonitoring)},
+			},
+		})
+	}
+
+	return append(resources, firewall, instance), nil
+}
+
 // SetConfigFromFlags initializes the driver based on the command line flags.
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.Project = flags.String("google-project")
@@ -164,12 +486,55 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.UseInternalIP = flags.Bool("google-use-internal-ip")
 	d.Scopes = flags.String("google-scopes")
 	d.Tags = flags.String("google-tags")
+	d.Accelerators = flags.StringSlice("google-accelerator")
+	d.LocalSSDCount = flags.Int("google-local-ssd-count")
+	d.LocalSSDInterface = strings.ToUpper(flags.String("google-local-ssd-interface"))
+	d.ShieldedSecureBoot = flags.Bool("google-shielded-secure-boot")
+	d.ShieldedVtpm = flags.Bool("google-shielded-vtpm")
+	d.ShieldedIntegrityMonitoring = flags.Bool("google-shielded-integrity-monitoring")
+	d.Networks = flags.StringSlice("network")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
 	d.SSHUser = flags.String("google-username")
 	d.SSHPort = 22
 
+	volume, err := drivers.ParseVolume(flags.String("attach-volume"))
+	if err != nil {
+		return err
+	}
+	d.AttachVolume = volume
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	for k := range gceLabels(tags) {
+		if k[0] < 'a' || k[0] > 'z' {
+			return fmt.Errorf("Invalid --tag key %q, GCE labels start with a lowercase letter", k)
+		}
+	}
+	d.ResourceTags = tags
+
+	if _, err := parseAccelerators(d.Accelerators, ""); err != nil {
+		return err
+	}
+
+	if _, err := parseNetworks(d.Networks, "", ""); err != nil {
+		return err
+	}
+
+	if d.LocalSSDCount < 0 || d.LocalSSDCount > maxLocalSSDCount {
+		return fmt.Errorf("Please specify between 0 and %d local SSDs using the option --google-local-ssd-count.", maxLocalSSDCount)
+	}
+
+	if d.LocalSSDInterface != "SCSI" && d.LocalSSDInterface != "NVME" {
+		return fmt.Errorf("Please specify SCSI or NVME using the option --google-local-ssd-interface.")
+	}
+
 	return nil
 }
 
@@ -203,7 +568,7 @@ func (d *Driver) PreCreateCheck() error {
 func (d *Driver) Create() error {
 	log.Infof("Generating SSH Key")
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
@@ -223,7 +588,7 @@ func (d *Driver) GetURL() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	url := fmt.Sprintf("tcp://%s:2376", ip)
+	url := fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376"))
 	return url, nil
 }
 
@@ -236,6 +601,16 @@ func (d *Driver) GetIP() (string, error) {
 	return c.ip()
 }
 
+// GetAddresses returns the IP address of the GCE instance, then its
+// internal addresses on the networks it is attached to with --network.
+func (d *Driver) GetAddresses() ([]drivers.Address, error) {
+	c, err := newComputeUtil(d)
+	if err != nil {
+		return nil, err
+	}
+	return c.addresses()
+}
+
 // GetState returns a docker.hosts.state.State value representing the current state of the host.
 func (d *Driver) GetState() (state.State, error) {
 	c, err := newComputeUtil(d)
@@ -313,7 +688,26 @@ func (d *Driver) Kill() error {
 	return d.Stop()
 }
 
-// Remove deletes the GCE instance and the disk.
+// Resize changes the machine type of the instance, which must be stopped.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if opts.CPUs > 0 || opts.Memory > 0 || opts.DiskSize > 0 {
+		return drivers.ErrResizeInstanceTypeOnly
+	}
+
+	c, err := newComputeUtil(d)
+	if err != nil {
+		return err
+	}
+
+	if err := c.setMachineType(opts.InstanceType); err != nil {
+		return err
+	}
+
+	d.MachineType = opts.InstanceType
+	return nil
+}
+
+// Remove deletes the GCE instance, its disk and the disk of its volume.
 func (d *Driver) Remove() error {
 	c, err := newComputeUtil(d)
 	if err != nil {
@@ -328,7 +722,20 @@ func (d *Driver) Remove() error {
 			return err
 		}
 	}
-	return c.deleteDisk()
+	if err := c.deleteDisk(); err != nil {
+		return err
+	}
+
+	if d.AttachVolume != nil {
+		return c.deleteVolumeDisk()
+	}
+	return nil
+}
+
+// GetVolumeDevices returns the path GCE gives the disk of the volume from its
+// device name.
+func (d *Driver) GetVolumeDevices() ([]string, error) {
+	return []string{"/dev/disk/by-id/google-" + volumeDeviceName}, nil
 }
 
 func (d *Driver) Restart() error {
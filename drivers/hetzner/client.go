@@ -0,0 +1,133 @@
+package hetzner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/docker/machine/libmachine/jsonapi"
+)
+
+const defaultEndpoint = "https://api.hetzner.cloud/v1"
+
+// client talks to the Hetzner Cloud API.
+type client struct {
+	*jsonapi.Client
+}
+
+// apiError is an error returned by the Hetzner Cloud API.
+type apiError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Hetzner Cloud API error (%s): %s", e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+type action struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type server struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	PublicNet struct {
+		IPv4 struct {
+			IP string `json:"ip"`
+		} `json:"ipv4"`
+	} `json:"public_net"`
+	PrivateNet []struct {
+		Network int    `json:"network"`
+		IP      string `json:"ip"`
+	} `json:"private_net"`
+}
+
+func newClient(token string) *client {
+	return &client{jsonapi.NewClient("Hetzner Cloud", defaultEndpoint, jsonapi.BearerToken(token), decodeError)}
+}
+
+// decodeError returns the apiError of a response.
+func decodeError(res *http.Response) error {
+	e := struct {
+		Error apiError `json:"error"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error.Message == "" {
+		e.Error.Code = "unknown"
+		e.Error.Message = res.Status
+	}
+	e.Error.StatusCode = res.StatusCode
+	return &e.Error
+}
+
+// waitAction blocks until the action is done.
+func (c *client) waitAction(a action) error {
+	for a.Status == "running" {
+		time.Sleep(actionPollInterval)
+
+		resp := struct {
+			Action action `json:"action"`
+		}{}
+		if err := c.Do("GET", fmt.Sprintf("/actions/%d", a.ID), nil, &resp); err != nil {
+			return err
+		}
+		a = resp.Action
+	}
+
+	if a.Status == "error" && a.Error != nil {
+		return fmt.Errorf("Hetzner Cloud action %d failed: %s", a.ID, a.Error.Message)
+	}
+
+	return nil
+}
+
+// doAction sends a request starting an action and waits for it.
+func (c *client) doAction(method, path string, body interface{}) error {
+	resp := struct {
+		Action action `json:"action"`
+	}{}
+	if err := c.Do(method, path, body, &resp); err != nil {
+		return err
+	}
+
+	return c.waitAction(resp.Action)
+}
+
+// lookupID returns the ID of the resource of kind, e.g. "networks", called
+// nameOrID, which may also be its ID already.
+func (c *client) lookupID(kind, nameOrID string) (int, error) {
+	resp := map[string][]struct {
+		ID int `json:"id"`
+	}{}
+	if err := c.Do("GET", fmt.Sprintf("/%s?name=%s", kind, url.QueryEscape(nameOrID)), nil, &resp); err != nil {
+		return 0, err
+	}
+
+	if found := resp[kind]; len(found) > 0 {
+		return found[0].ID, nil
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(nameOrID, "%d", &id); err == nil && fmt.Sprint(id) == nameOrID {
+		return id, nil
+	}
+
+	return 0, fmt.Errorf("No %s named %q", kind, nameOrID)
+}
+
+// actionPollInterval is how often the status of running actions is checked.
+var actionPollInterval = 1 * time.Second
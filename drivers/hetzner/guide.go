@@ -0,0 +1,155 @@
+package hetzner
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// listPageSize is how many locations, server types or images are listed,
+// more than Hetzner Cloud has of each.
+const listPageSize = 50
+
+type serverType struct {
+	Name         string  `json:"name"`
+	Cores        int     `json:"cores"`
+	Memory       float64 `json:"memory"`
+	Disk         int     `json:"disk"`
+	Architecture string  `json:"architecture"`
+	Deprecated   bool    `json:"deprecated"`
+	Prices       []struct {
+		Location     string `json:"location"`
+		PriceMonthly struct {
+			Gross string `json:"gross"`
+		} `json:"price_monthly"`
+	} `json:"prices"`
+}
+
+// GuideFlags asks for the API token, then for the location, the server type
+// and the image, the server types depending on the location, and the images
+// on the architecture of the server type.
+func (d *Driver) GuideFlags() drivers.GuideFlags {
+	return drivers.GuideFlags{
+		Credentials: []string{"hetzner-api-token"},
+		Options:     []string{"hetzner-location", "hetzner-server-type", "hetzner-image"},
+	}
+}
+
+// ValidateCredentials lists the locations, which needs a valid API token.
+func (d *Driver) ValidateCredentials() error {
+	if _, err := d.listLocations(); err != nil {
+		return fmt.Errorf("Hetzner Cloud refused the API token: %w", err)
+	}
+	return nil
+}
+
+// ListOptions lists the locations, the server types offered in the location
+// of the driver, and the system images of the architecture of its server
+// type.
+func (d *Driver) ListOptions(flag string) ([]drivers.Option, error) {
+	switch flag {
+	case "hetzner-location":
+		return d.listLocations()
+	case "hetzner-server-type":
+		return d.listServerTypes()
+	case "hetzner-image":
+		return d.listImages()
+	}
+
+	return nil, fmt.Errorf("hetzner driver cannot list the values of --%s", flag)
+}
+
+func (d *Driver) listLocations() ([]drivers.Option, error) {
+	resp := struct {
+		Locations []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		} `json:"locations"`
+	}{}
+	if err := d.getClient().Do("GET", fmt.Sprintf("/locations?per_page=%d", listPageSize), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, l := range resp.Locations {
+		options = append(options, drivers.Option{Value: l.Name, Description: l.Description})
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Value < options[j].Value })
+
+	return options, nil
+}
+
+func (d *Driver) listServerTypes() ([]drivers.Option, error) {
+	resp := struct {
+		ServerTypes []serverType `json:"server_types"`
+	}{}
+	if err := d.getClient().Do("GET", fmt.Sprintf("/server_types?per_page=%d", listPageSize), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, t := range resp.ServerTypes {
+		if t.Deprecated {
+			continue
+		}
+
+		description := fmt.Sprintf("%d vCPU, %gGB memory, %dGB disk", t.Cores, t.Memory, t.Disk)
+		offered := d.Location == ""
+		for _, p := range t.Prices {
+			if p.Location != d.Location {
+				continue
+			}
+			offered = true
+			if price, err := strconv.ParseFloat(p.PriceMonthly.Gross, 64); err == nil {
+				description += fmt.Sprintf(", €%.2f/month", price)
+			}
+		}
+
+		if offered {
+			options = append(options, drivers.Option{Value: t.Name, Description: description})
+		}
+	}
+
+	return options, nil
+}
+
+func (d *Driver) listImages() ([]drivers.Option, error) {
+	query := url.Values{
+		"type":     {"system"},
+		"status":   {"available"},
+		"per_page": {strconv.Itoa(listPageSize)},
+	}
+
+	if d.ServerType != "" {
+		resp := struct {
+			ServerTypes []serverType `json:"server_types"`
+		}{}
+		if err := d.getClient().Do("GET", "/server_types?name="+url.QueryEscape(d.ServerType), nil, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.ServerTypes) > 0 && resp.ServerTypes[0].Architecture != "" {
+			query.Set("architecture", resp.ServerTypes[0].Architecture)
+		}
+	}
+
+	resp := struct {
+		Images []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		} `json:"images"`
+	}{}
+	if err := d.getClient().Do("GET", "/images?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	options := []drivers.Option{}
+	for _, image := range resp.Images {
+		options = append(options, drivers.Option{Value: image.Name, Description: image.Description})
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Value < options[j].Value })
+
+	return options, nil
+}
@@ -0,0 +1,486 @@
+package hetzner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/jsonapi"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultServerType = "cx11"
+	defaultImage      = "ubuntu-16.04"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	client            *client
+	APIToken          string
+	ServerType        string
+	Image             string
+	Location          string
+	Networks          []string
+	UsePrivateNetwork bool
+	PlacementGroup    string
+	Labels            map[string]string
+	ServerID          int
+	SSHKeyID          int
+}
+
+// NewDriver creates a new Hetzner Cloud driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		ServerType: defaultServerType,
+		Image:      defaultImage,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_API_TOKEN",
+			Name:   "hetzner-api-token",
+			Usage:  "Hetzner Cloud API token",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SERVER_TYPE",
+			Name:   "hetzner-server-type",
+			Usage:  "Hetzner Cloud server type",
+			Value:  defaultServerType,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_IMAGE",
+			Name:   "hetzner-image",
+			Usage:  "Hetzner Cloud image",
+			Value:  defaultImage,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_LOCATION",
+			Name:   "hetzner-location",
+			Usage:  "Hetzner Cloud location. Defaults to one the server type is available in",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "hetzner-network",
+			Usage: "Name or ID of a private network to attach the server to",
+			Value: []string{},
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_USE_PRIVATE_NETWORK",
+			Name:   "hetzner-use-private-network",
+			Usage:  "Connect to the server through its address in the first private network",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PLACEMENT_GROUP",
+			Name:   "hetzner-placement-group",
+			Usage:  "Name or ID of the placement group to add the server to",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "hetzner-label",
+			Usage: "Label of the server, as key=value",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SSH_USER",
+			Name:   "hetzner-ssh-user",
+			Usage:  "Hetzner Cloud SSH username",
+			Value:  "root",
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) DriverName() string {
+	return "hetzner"
+}
+
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the server type of the machine, whose disk is included.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	return drivers.Pricing{
+		Region:       d.Location,
+		InstanceType: d.ServerType,
+	}, nil
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.APIToken = flags.String("hetzner-api-token")
+	d.ServerType = flags.String("hetzner-server-type")
+	d.Image = flags.String("hetzner-image")
+	d.Location = flags.String("hetzner-location")
+	d.Networks = flags.StringSlice("hetzner-network")
+	d.UsePrivateNetwork = flags.Bool("hetzner-use-private-network")
+	d.PlacementGroup = flags.String("hetzner-placement-group")
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHUser = flags.String("hetzner-ssh-user")
+	d.SSHPort = 22
+
+	if d.APIToken == "" {
+		return fmt.Errorf("hetzner driver requires the --hetzner-api-token option")
+	}
+
+	// The driver is configured again with each token create --interactive
+	// is given.
+	if d.client != nil {
+		d.client.Header = jsonapi.BearerToken(d.APIToken)
+	}
+
+	if d.UsePrivateNetwork && len(d.Networks) == 0 {
+		return fmt.Errorf("hetzner driver requires a --hetzner-network to use a private network")
+	}
+
+	labels, err := parseLabels(flags.StringSlice("hetzner-label"))
+	if err != nil {
+		return err
+	}
+	d.Labels = labels
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+
+	return nil
+}
+
+// labels returns the labels of the server and SSH key: those given with
+// --hetzner-label, and the tags given with --tag.
+func (d *Driver) labels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range d.Labels {
+		labels[k] = v
+	}
+	for k, v := range d.ResourceTags {
+		labels[k] = v
+	}
+	return labels
+}
+
+// parseLabels converts key=value labels to a map.
+func parseLabels(labels []string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid Hetzner Cloud label %q, expected key=value", label)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+func (d *Driver) getClient() *client {
+	if d.client == nil {
+		d.client = newClient(d.APIToken)
+	}
+	return d.client
+}
+
+// PreCreateCheck checks that the server type, the networks and the
+// placement group exist.
+func (d *Driver) PreCreateCheck() error {
+	c := d.getClient()
+
+	if _, err := c.lookupID("server_types", d.ServerType); err != nil {
+		return fmt.Errorf("hetzner requires a valid server type: %s", err)
+	}
+
+	for _, network := range d.Networks {
+		if _, err := c.lookupID("networks", network); err != nil {
+			return err
+		}
+	}
+
+	if d.PlacementGroup != "" {
+		if _, err := c.lookupID("placement_groups", d.PlacementGroup); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+
+	if err := d.createSSHKey(); err != nil {
+		return err
+	}
+
+	request, err := d.serverRequest()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating Hetzner Cloud server...")
+
+	c := d.getClient()
+
+	resp := struct {
+		Server server `json:"server"`
+		Action action `json:"action"`
+	}{}
+	if err := c.Do("POST", "/servers", request, &resp); err != nil {
+		return err
+	}
+
+	d.ServerID = resp.Server.ID
+
+	if err := c.waitAction(resp.Action); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for IP address to be assigned to the server...")
+	for {
+		srv, err := d.getServer()
+		if err != nil {
+			return err
+		}
+
+		d.IPAddress = serverIP(srv, d.UsePrivateNetwork)
+		if d.IPAddress != "" && srv.Status == "running" {
+			break
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	log.Debugf("Created server ID %d, IP address %s",
+		d.ServerID,
+		d.IPAddress)
+
+	return nil
+}
+
+// serverRequest returns the request creating the server, with the names of
+// the networks and placement group resolved to their IDs.
+func (d *Driver) serverRequest() (map[string]interface{}, error) {
+	c := d.getClient()
+
+	request := map[string]interface{}{
+		"name":        d.MachineName,
+		"server_type": d.ServerType,
+		"image":       d.Image,
+		"ssh_keys":    []int{d.SSHKeyID},
+		"labels":      d.labels(),
+	}
+
+	if d.Location != "" {
+		request["location"] = d.Location
+	}
+
+	if len(d.Networks) > 0 {
+		networks := []int{}
+		for _, network := range d.Networks {
+			id, err := c.lookupID("networks", network)
+			if err != nil {
+				return nil, err
+			}
+			networks = append(networks, id)
+		}
+		request["networks"] = networks
+	}
+
+	if d.PlacementGroup != "" {
+		id, err := c.lookupID("placement_groups", d.PlacementGroup)
+		if err != nil {
+			return nil, err
+		}
+		request["placement_group"] = id
+	}
+
+	return request, nil
+}
+
+func (d *Driver) createSSHKey() error {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	resp := struct {
+		SSHKey struct {
+			ID int `json:"id"`
+		} `json:"ssh_key"`
+	}{}
+	if err := d.getClient().Do("POST", "/ssh_keys", map[string]interface{}{
+		"name":       fmt.Sprintf("%s-%d", d.MachineName, time.Now().Unix()),
+		"public_key": string(publicKey),
+		"labels":     d.labels(),
+	}, &resp); err != nil {
+		return err
+	}
+
+	d.SSHKeyID = resp.SSHKey.ID
+	return nil
+}
+
+func (d *Driver) getServer() (server, error) {
+	resp := struct {
+		Server server `json:"server"`
+	}{}
+	err := d.getClient().Do("GET", fmt.Sprintf("/servers/%d", d.ServerID), nil, &resp)
+	return resp.Server, err
+}
+
+// serverIP returns the public IPv4 address of the server, or its address in
+// its first private network.
+func serverIP(srv server, private bool) string {
+	if !private {
+		return srv.PublicNet.IPv4.IP
+	}
+	if len(srv.PrivateNet) > 0 {
+		return srv.PrivateNet[0].IP
+	}
+	return ""
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.IPAddress == "" {
+		return "", fmt.Errorf("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	srv, err := d.getServer()
+	if err != nil {
+		return state.Error, err
+	}
+	switch srv.Status {
+	case "initializing", "starting":
+		return state.Starting, nil
+	case "running":
+		return state.Running, nil
+	case "stopping":
+		return state.Stopping, nil
+	case "off":
+		return state.Stopped, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) serverAction(name string) error {
+	return d.getClient().doAction("POST", fmt.Sprintf("/servers/%d/actions/%s", d.ServerID, name), nil)
+}
+
+func (d *Driver) Start() error {
+	return d.serverAction("poweron")
+}
+
+func (d *Driver) Stop() error {
+	return d.serverAction("shutdown")
+}
+
+func (d *Driver) Remove() error {
+	c := d.getClient()
+	if err := c.Do("DELETE", fmt.Sprintf("/ssh_keys/%d", d.SSHKeyID), nil, nil); err != nil {
+		if isNotFound(err) {
+			log.Infof("Hetzner Cloud SSH key doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	if err := c.doAction("DELETE", fmt.Sprintf("/servers/%d", d.ServerID), nil); err != nil {
+		if isNotFound(err) {
+			log.Infof("Hetzner Cloud server doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// OrphanedResources returns the servers and SSH keys labeled for machines
+// of the store which aren't one of machines.
+func (d *Driver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[machine] = true
+	}
+
+	resources := []drivers.OrphanedResource{}
+	for _, kind := range []string{"servers", "ssh_keys"} {
+		resp := map[string][]struct {
+			ID     int               `json:"id"`
+			Labels map[string]string `json:"labels"`
+		}{}
+		selector := drivers.TagMachineName + "," + drivers.TagMachineStore + "=" + storeID
+		if err := d.getClient().Do("GET", "/"+kind+"?per_page=50&label_selector="+url.QueryEscape(selector), nil, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, r := range resp[kind] {
+			if machine := r.Labels[drivers.TagMachineName]; !known[machine] {
+				resources = append(resources, drivers.OrphanedResource{Kind: strings.TrimSuffix(kind, "s"), ID: strconv.Itoa(r.ID), Machine: machine})
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// RemoveOrphanedResource removes a resource OrphanedResources returned.
+func (d *Driver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	switch resource.Kind {
+	case "server":
+		return d.getClient().doAction("DELETE", "/servers/"+resource.ID, nil)
+	case "ssh_key":
+		return d.getClient().Do("DELETE", "/ssh_keys/"+resource.ID, nil, nil)
+	}
+
+	return fmt.Errorf("Unknown kind of resource %q", resource.Kind)
+}
+
+func (d *Driver) Restart() error {
+	return d.serverAction("reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.serverAction("poweroff")
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
@@ -0,0 +1,288 @@
+package hetzner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/jsonapi"
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+func newTestDriver(responses map[string]string) (*Driver, *jsonapitest.API, func()) {
+	fake := jsonapitest.New(responses)
+	fake.Authorized = func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer token" }
+	fake.Unauthorized = `{"error": {"code": "unauthorized", "message": "unable to authenticate"}}`
+	fake.NotFound = `{"error": {"code": "not_found", "message": "resource not found"}}`
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.APIToken = "token"
+	d.ServerID = 42
+	d.client = newClient("token")
+	d.client.Endpoint = server.URL
+	d.client.HTTP = server.Client()
+
+	return d, fake, server.Close
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "hetzner", NewDriver("default", "").DriverName())
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"hetzner-api-token": "token",
+			"hetzner-ssh-user":  "root",
+			"hetzner-label":     []string{"env=ci", "team=infra=ops"},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "ci", "team": "infra=ops"}, d.Labels)
+	assert.Equal(t, "root", d.GetSSHUsername())
+}
+
+func TestSetConfigFromFlagsErrors(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{Data: map[string]interface{}{}})
+	assert.EqualError(t, err, "hetzner driver requires the --hetzner-api-token option")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"hetzner-api-token":           "token",
+			"hetzner-use-private-network": true,
+		},
+	})
+	assert.EqualError(t, err, "hetzner driver requires a --hetzner-network to use a private network")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"hetzner-api-token": "token",
+			"hetzner-label":     []string{"env"},
+		},
+	})
+	assert.EqualError(t, err, `Invalid Hetzner Cloud label "env", expected key=value`)
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		status string
+		state  state.State
+	}{
+		{"initializing", state.Starting},
+		{"starting", state.Starting},
+		{"running", state.Running},
+		{"stopping", state.Stopping},
+		{"off", state.Stopped},
+		{"rebuilding", state.None},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(map[string]string{
+			"GET /servers/42": fmt.Sprintf(`{"server": {"id": 42, "status": %q}}`, expected.status),
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestServerRequest(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /networks?name=backend":        `{"networks": [{"id": 7}]}`,
+		"GET /networks?name=12":             `{"networks": []}`,
+		"GET /networks?name=frontend":       `{"networks": []}`,
+		"GET /placement_groups?name=spread": `{"placement_groups": [{"id": 3}]}`,
+	})
+	defer done()
+
+	d.SSHKeyID = 99
+	d.Location = "fsn1"
+	d.Networks = []string{"backend", "12"}
+	d.PlacementGroup = "spread"
+	d.Labels = map[string]string{"env": "ci"}
+	d.ResourceTags = map[string]string{"machine-name": "default"}
+
+	request, err := d.serverRequest()
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name":            "default",
+		"server_type":     "cx11",
+		"image":           "ubuntu-16.04",
+		"location":        "fsn1",
+		"ssh_keys":        []int{99},
+		"networks":        []int{7, 12},
+		"placement_group": 3,
+		"labels":          map[string]string{"env": "ci", "machine-name": "default"},
+	}, request)
+
+	d.Networks = []string{"frontend"}
+	_, err = d.serverRequest()
+	assert.EqualError(t, err, `No networks named "frontend"`)
+}
+
+func TestServerIP(t *testing.T) {
+	srv := server{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"public_net": {"ipv4": {"ip": "203.0.113.10"}},
+		"private_net": [{"network": 7, "ip": "10.0.0.2"}]
+	}`), &srv))
+
+	assert.Equal(t, "203.0.113.10", serverIP(srv, false))
+	assert.Equal(t, "10.0.0.2", serverIP(srv, true))
+	assert.Equal(t, "", serverIP(server{}, true))
+}
+
+func TestActions(t *testing.T) {
+	actionPollInterval = 0
+
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /servers/42/actions/poweron":  `{"action": {"id": 1, "status": "running"}}`,
+		"GET /actions/1":                    `{"action": {"id": 1, "status": "success"}}`,
+		"POST /servers/42/actions/shutdown": `{"action": {"id": 2, "status": "running"}}`,
+		"GET /actions/2":                    `{"action": {"id": 2, "status": "error", "error": {"code": "action_failed", "message": "server is locked"}}}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.Start())
+	assert.Equal(t, []string{"POST /servers/42/actions/poweron", "GET /actions/1"}, fake.Requests)
+
+	assert.EqualError(t, d.Stop(), "Hetzner Cloud action 2 failed: server is locked")
+}
+
+func TestRemoveMissingResources(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{})
+	defer done()
+
+	d.SSHKeyID = 99
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{"DELETE /ssh_keys/99", "DELETE /servers/42"}, fake.Requests)
+}
+
+func TestAPIError(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.client.Header = jsonapi.BearerToken("wrong")
+
+	_, err := d.GetState()
+
+	assert.EqualError(t, err, "Hetzner Cloud API error (unauthorized): unable to authenticate")
+}
+
+func TestOrphanedResources(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /servers?per_page=50&label_selector=machine-name%2Cmachine-store%3D0123abcd": `{"servers": [
+			{"id": 1, "labels": {"machine-name": "web"}},
+			{"id": 2, "labels": {"machine-name": "failed"}}
+		]}`,
+		"GET /ssh_keys?per_page=50&label_selector=machine-name%2Cmachine-store%3D0123abcd": `{"ssh_keys": [{"id": 7, "labels": {"machine-name": "failed"}}]}`,
+		"DELETE /servers/2":  `{"action": {"id": 1, "status": "success"}}`,
+		"DELETE /ssh_keys/7": ``,
+	})
+	defer done()
+
+	resources, err := d.OrphanedResources("0123abcd", []string{"web"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.OrphanedResource{
+		{Kind: "server", ID: "2", Machine: "failed"},
+		{Kind: "ssh_key", ID: "7", Machine: "failed"},
+	}, resources)
+
+	for _, resource := range resources {
+		assert.NoError(t, d.RemoveOrphanedResource(resource))
+	}
+	assert.Equal(t, []string{"DELETE /servers/2", "DELETE /ssh_keys/7"}, fake.Requests[2:])
+}
+
+func TestGuide(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /locations?per_page=50": `{"locations": [{"name": "nbg1", "description": "Nuremberg DC Park 1"}, {"name": "fsn1", "description": "Falkenstein DC Park 1"}]}`,
+		"GET /server_types?per_page=50": `{"server_types": [
+			{"name": "cx11", "cores": 1, "memory": 2, "disk": 20, "deprecated": true, "prices": [{"location": "fsn1", "price_monthly": {"gross": "3.9000"}}]},
+			{"name": "cx22", "cores": 2, "memory": 4, "disk": 40, "architecture": "x86", "prices": [{"location": "fsn1", "price_monthly": {"gross": "4.5129"}}, {"location": "nbg1", "price_monthly": {"gross": "4.5129"}}]},
+			{"name": "cax11", "cores": 2, "memory": 4, "disk": 40, "architecture": "arm", "prices": [{"location": "nbg1", "price_monthly": {"gross": "4.5129"}}]}
+		]}`,
+		"GET /server_types?name=cax11":                                          `{"server_types": [{"name": "cax11", "architecture": "arm"}]}`,
+		"GET /images?architecture=arm&per_page=50&status=available&type=system": `{"images": [{"name": "ubuntu-24.04", "description": "Ubuntu 24.04"}, {"name": "debian-12", "description": "Debian 12"}]}`,
+	})
+	defer done()
+
+	assert.NoError(t, drivers.ValidateCredentials(d))
+
+	options, err := drivers.ListOptions(d, "hetzner-location")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "fsn1", Description: "Falkenstein DC Park 1"}, {Value: "nbg1", Description: "Nuremberg DC Park 1"}}, options)
+
+	d.Location = "fsn1"
+	options, err = drivers.ListOptions(d, "hetzner-server-type")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "cx22", Description: "2 vCPU, 4GB memory, 40GB disk, €4.51/month"}}, options)
+
+	d.ServerType = "cax11"
+	options, err = drivers.ListOptions(d, "hetzner-image")
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Option{{Value: "debian-12", Description: "Debian 12"}, {Value: "ubuntu-24.04", Description: "Ubuntu 24.04"}}, options)
+
+	assert.Equal(t, "GET /images?architecture=arm&per_page=50&status=available&type=system", fake.Requests[len(fake.Requests)-1])
+}
+
+func TestGuideRefusedToken(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+
+	assert.NoError(t, d.SetConfigFromFlags(DriverOptionsMock{Data: map[string]interface{}{
+		"hetzner-api-token":   "wrong",
+		"hetzner-server-type": "cx22",
+		"hetzner-image":       "ubuntu-24.04",
+	}}))
+
+	assert.EqualError(t, drivers.ValidateCredentials(d), "Hetzner Cloud refused the API token: Hetzner Cloud API error (unauthorized): unable to authenticate")
+}
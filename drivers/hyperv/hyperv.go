@@ -3,11 +3,17 @@ package hyperv
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/docker/machine/libmachine/cloudinit"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
@@ -24,17 +30,43 @@ type Driver struct {
 	diskImage      string
 	DiskSize       int
 	MemSize        int
+	// CPU is the number of virtual processors the VM was resized to, the
+	// Hyper-V default when zero.
+	CPU        int
+	Generation int
+	SecureBoot bool
+	// Dynamic memory is enabled when its bounds are set.
+	DynamicMemoryMin     int
+	DynamicMemoryMax     int
+	NestedVirtualization bool
+	CreateNATSwitch      bool
+	NATCIDR              string
+	// NAT switches have no DHCP server, so machines get a static address,
+	// the one given with --hyperv-static-ip or else the first free one.
+	StaticIPAddress string
+	// CloudImageURL is the stock cloud image booted instead of boot2docker,
+	// configured by cloud-init.
+	CloudImageURL string
 }
 
 const (
-	defaultDiskSize = 20000
-	defaultMemory   = 1024
+	defaultDiskSize   = 20000
+	defaultMemory     = 1024
+	defaultGeneration = 1
+	defaultNATSwitch  = "DockerMachineNAT"
+	defaultNATCIDR    = "192.168.250.1/24"
+	defaultNATDNS     = "8.8.8.8"
+
+	isoFilename  = "boot2docker.iso"
+	seedFilename = "seed.iso"
 )
 
 func NewDriver(hostName, storePath string) drivers.Driver {
 	return &Driver{
-		DiskSize: defaultDiskSize,
-		MemSize:  defaultMemory,
+		DiskSize:   defaultDiskSize,
+		MemSize:    defaultMemory,
+		Generation: defaultGeneration,
+		NATCIDR:    defaultNATCIDR,
 		BaseDriver: &drivers.BaseDriver{
 			MachineName: hostName,
 			StorePath:   storePath,
@@ -54,6 +86,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:  "hyperv-boot2docker-location",
 			Usage: "Hyper-V local boot2docker iso. Overrides URL.",
 		},
+		mcnflag.StringFlag{
+			Name:  "hyperv-cloud-image-url",
+			Usage: "Hyper-V URL of a cloud image, e.g. Ubuntu or Fedora, in VHD or VHDX format to boot instead of boot2docker.",
+		},
 		mcnflag.StringFlag{
 			Name:  "hyperv-virtual-switch",
 			Usage: "Hyper-V virtual switch name. Defaults to first found.",
@@ -68,23 +104,105 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "Hyper-V memory size for host in MB.",
 			Value: defaultMemory,
 		},
+		mcnflag.IntFlag{
+			Name:  "hyperv-generation",
+			Usage: "Hyper-V generation of the VM, 1 or 2.",
+			Value: defaultGeneration,
+		},
+		mcnflag.BoolFlag{
+			Name:  "hyperv-secure-boot",
+			Usage: "Hyper-V enable secure boot on a generation 2 VM.",
+		},
+		mcnflag.IntFlag{
+			Name:  "hyperv-dynamic-memory-min",
+			Usage: "Hyper-V minimum memory size for host in MB, enables dynamic memory.",
+		},
+		mcnflag.IntFlag{
+			Name:  "hyperv-dynamic-memory-max",
+			Usage: "Hyper-V maximum memory size for host in MB, enables dynamic memory.",
+		},
+		mcnflag.BoolFlag{
+			Name:  "hyperv-nested-virtualization",
+			Usage: "Hyper-V expose the virtualization extensions to the VM.",
+		},
+		mcnflag.BoolFlag{
+			Name:  "hyperv-create-nat-switch",
+			Usage: "Hyper-V create an internal virtual switch with NAT if it doesn't exist, instead of using an existing switch.",
+		},
+		mcnflag.StringFlag{
+			Name:  "hyperv-nat-cidr",
+			Usage: "Hyper-V host address and network of the NAT switch.",
+			Value: defaultNATCIDR,
+		},
+		mcnflag.StringFlag{
+			Name:  "hyperv-static-ip",
+			Usage: "Hyper-V IP address of the VM on the NAT switch. Defaults to the first free one.",
+		},
 	}
 }
 
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.boot2DockerURL = flags.String("hyperv-boot2docker-url")
 	d.boot2DockerLoc = flags.String("hyperv-boot2docker-location")
+	d.CloudImageURL = flags.String("hyperv-cloud-image-url")
 	d.vSwitch = flags.String("hyperv-virtual-switch")
 	d.DiskSize = flags.Int("hyperv-disk-size")
 	d.MemSize = flags.Int("hyperv-memory")
+	d.Generation = flags.Int("hyperv-generation")
+	d.SecureBoot = flags.Bool("hyperv-secure-boot")
+	d.DynamicMemoryMin = flags.Int("hyperv-dynamic-memory-min")
+	d.DynamicMemoryMax = flags.Int("hyperv-dynamic-memory-max")
+	d.NestedVirtualization = flags.Bool("hyperv-nested-virtualization")
+	d.CreateNATSwitch = flags.Bool("hyperv-create-nat-switch")
+	d.NATCIDR = flags.String("hyperv-nat-cidr")
+	d.StaticIPAddress = flags.String("hyperv-static-ip")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
 	d.SSHUser = "docker"
 	d.SSHPort = 22
+
+	if d.Generation != 1 && d.Generation != 2 {
+		return fmt.Errorf("hyperv driver requires --hyperv-generation to be 1 or 2")
+	}
+	if d.SecureBoot && d.Generation != 2 {
+		return fmt.Errorf("hyperv driver only supports --hyperv-secure-boot on generation 2 VMs")
+	}
+	if d.dynamicMemory() {
+		if d.DynamicMemoryMin <= 0 || d.DynamicMemoryMax <= 0 {
+			return fmt.Errorf("hyperv driver requires both --hyperv-dynamic-memory-min and --hyperv-dynamic-memory-max")
+		}
+		if d.DynamicMemoryMin > d.MemSize || d.MemSize > d.DynamicMemoryMax {
+			return fmt.Errorf("hyperv driver requires --hyperv-memory to be between the dynamic memory minimum and maximum")
+		}
+		if d.NestedVirtualization {
+			return fmt.Errorf("hyperv driver doesn't support dynamic memory with nested virtualization")
+		}
+	}
+	if d.CreateNATSwitch {
+		hostIP, network, err := parseNATCIDR(d.NATCIDR)
+		if err != nil {
+			return err
+		}
+		if d.StaticIPAddress != "" {
+			if err := checkNATAddress(d.StaticIPAddress, hostIP, network, nil); err != nil {
+				return err
+			}
+		}
+	} else if d.StaticIPAddress != "" {
+		return fmt.Errorf("hyperv driver only supports --hyperv-static-ip with --hyperv-create-nat-switch")
+	}
+
 	return nil
 }
 
+func (d *Driver) dynamicMemory() bool {
+	return d.DynamicMemoryMin != 0 || d.DynamicMemoryMax != 0
+}
+
 func (d *Driver) GetSSHHostname() (string, error) {
 	return d.GetIP()
 }
@@ -109,7 +227,7 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) GetState() (state.State, error) {
@@ -133,6 +251,8 @@ func (d *Driver) GetState() (state.State, error) {
 		return state.Running, nil
 	case "Off":
 		return state.Stopped, nil
+	case "Saved":
+		return state.Saved, nil
 	}
 	return state.None, nil
 }
@@ -146,42 +266,59 @@ func (d *Driver) Create() error {
 	d.setMachineNameIfNotSet()
 
 	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyIsoToMachineDir(d.boot2DockerURL, d.MachineName); err != nil {
-		return err
+	if d.CloudImageURL == "" {
+		if err := b2dutils.CopyIsoToMachineDir(d.boot2DockerURL, d.MachineName); err != nil {
+			return err
+		}
 	}
 
 	log.Infof("Creating SSH key...")
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
 	log.Infof("Creating VM...")
 
-	virtualSwitch, err := d.chooseVirtualSwitch()
+	var virtualSwitch string
+	if d.CreateNATSwitch {
+		virtualSwitch, err = d.createNATSwitch()
+	} else {
+		virtualSwitch, err = d.chooseVirtualSwitch()
+	}
 	if err != nil {
 		return err
 	}
 
-	err = d.generateDiskImage()
+	// Cloud images find their network interface by MAC address, to
+	// configure the static IP address of the VM on the NAT switch.
+	var macAddress string
+	if d.CloudImageURL != "" {
+		if d.StaticIPAddress != "" {
+			macAddress = generateMACAddress()
+		}
+		err = d.generateCloudDisk(b2dutils, macAddress)
+	} else {
+		err = d.generateDiskImage()
+	}
 	if err != nil {
 		return err
 	}
 
+	// The cloud-init seed isn't bootable.
+	dvdPath, bootDevice := d.ResolveStorePath(isoFilename), "Get-VMDvdDrive"
+	if d.CloudImageURL != "" {
+		dvdPath, bootDevice = d.ResolveStorePath(seedFilename), "Get-VMHardDiskDrive"
+	}
+
 	command := []string{
 		"New-VM",
 		"-Name", d.MachineName,
 		"-Path", fmt.Sprintf("'%s'", d.ResolveStorePath(".")),
 		"-MemoryStartupBytes", fmt.Sprintf("%dMB", d.MemSize)}
-	_, err = execute(command)
-	if err != nil {
-		return err
+	if d.Generation == 2 {
+		command = append(command, "-Generation", "2")
 	}
-
-	command = []string{
-		"Set-VMDvdDrive",
-		"-VMName", d.MachineName,
-		"-Path", fmt.Sprintf("'%s'", d.ResolveStorePath("boot2docker.iso"))}
 	_, err = execute(command)
 	if err != nil {
 		return err
@@ -196,6 +333,77 @@ func (d *Driver) Create() error {
 		return err
 	}
 
+	if d.Generation == 2 {
+		// Generation 2 VMs have no DVD drive, and boot from the network
+		// first.
+		command = []string{
+			"Add-VMDvdDrive",
+			"-VMName", d.MachineName,
+			"-Path", fmt.Sprintf("'%s'", dvdPath)}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+
+		command = []string{
+			"Set-VMFirmware",
+			"-VMName", d.MachineName,
+			"-FirstBootDevice", "(" + bootDevice, "-VMName", d.MachineName, ")"}
+		if d.SecureBoot {
+			command = append(command, "-EnableSecureBoot", "On", "-SecureBootTemplate", "MicrosoftUEFICertificateAuthority")
+		} else {
+			command = append(command, "-EnableSecureBoot", "Off")
+		}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+	} else {
+		command = []string{
+			"Set-VMDvdDrive",
+			"-VMName", d.MachineName,
+			"-Path", fmt.Sprintf("'%s'", dvdPath)}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.dynamicMemory() {
+		command = []string{
+			"Set-VMMemory",
+			"-VMName", d.MachineName,
+			"-DynamicMemoryEnabled", "$true",
+			"-MinimumBytes", fmt.Sprintf("%dMB", d.DynamicMemoryMin),
+			"-MaximumBytes", fmt.Sprintf("%dMB", d.DynamicMemoryMax)}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.NestedVirtualization {
+		command = []string{
+			"Set-VMProcessor",
+			"-VMName", d.MachineName,
+			"-ExposeVirtualizationExtensions", "$true"}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+
+		// Nested VMs and containers send frames with their own MAC
+		// addresses.
+		command = []string{
+			"Set-VMNetworkAdapter",
+			"-VMName", d.MachineName,
+			"-MacAddressSpoofing", "On"}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+	}
+
 	command = []string{
 		"Connect-VMNetworkAdapter",
 		"-VMName", d.MachineName,
@@ -205,6 +413,17 @@ func (d *Driver) Create() error {
 		return err
 	}
 
+	if macAddress != "" {
+		command = []string{
+			"Set-VMNetworkAdapter",
+			"-VMName", d.MachineName,
+			"-StaticMacAddress", macAddress}
+		_, err = execute(command)
+		if err != nil {
+			return err
+		}
+	}
+
 	log.Infof("Starting  VM...")
 	if err := d.Start(); err != nil {
 		return err
@@ -231,6 +450,113 @@ func (d *Driver) chooseVirtualSwitch() (string, error) {
 	return "", fmt.Errorf("no vswitch found")
 }
 
+// createNATSwitch creates the internal switch with NAT to the host network,
+// unless it exists already, and chooses the static IP address of the VM on
+// its network, unless one was given.
+func (d *Driver) createNATSwitch() (string, error) {
+	virtualSwitch := d.vSwitch
+	if virtualSwitch == "" {
+		virtualSwitch = defaultNATSwitch
+	}
+
+	hostIP, network, err := parseNATCIDR(d.NATCIDR)
+	if err != nil {
+		return "", err
+	}
+	prefixLength, _ := network.Mask.Size()
+
+	command := []string{
+		"if", "(-not", "(Get-VMSwitch", "-Name", fmt.Sprintf("'%s'", virtualSwitch), "-ErrorAction", "SilentlyContinue))", "{",
+		"New-VMSwitch", "-Name", fmt.Sprintf("'%s'", virtualSwitch), "-SwitchType", "Internal", "|", "Out-Null;",
+		"New-NetIPAddress", "-IPAddress", hostIP.String(), "-PrefixLength", fmt.Sprintf("%d", prefixLength),
+		"-InterfaceAlias", fmt.Sprintf("'vEthernet (%s)'", virtualSwitch), "|", "Out-Null;",
+		"New-NetNat", "-Name", fmt.Sprintf("'%s'", virtualSwitch),
+		"-InternalIPInterfaceAddressPrefix", network.String(), "|", "Out-Null",
+		"}"}
+	if _, err := execute(command); err != nil {
+		return "", err
+	}
+
+	command = []string{
+		"@(Get-VMNetworkAdapter", "-All", "|",
+		"Where-Object", "{", "$_.SwitchName", "-eq", fmt.Sprintf("'%s'", virtualSwitch), "}", ").IPAddresses"}
+	stdout, err := execute(command)
+	if err != nil {
+		return "", err
+	}
+
+	if d.StaticIPAddress != "" {
+		err = checkNATAddress(d.StaticIPAddress, hostIP, network, parseStdout(stdout))
+	} else {
+		d.StaticIPAddress, err = chooseNATAddress(hostIP, network, parseStdout(stdout))
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Using switch %s with IP address %s", virtualSwitch, d.StaticIPAddress)
+
+	return virtualSwitch, nil
+}
+
+func parseNATCIDR(cidr string) (net.IP, *net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return nil, nil, fmt.Errorf("Invalid --hyperv-nat-cidr %q, expected an IPv4 CIDR like %s", cidr, defaultNATCIDR)
+	}
+	if ip.Equal(network.IP) {
+		return nil, nil, fmt.Errorf("--hyperv-nat-cidr must be specified with a host address, not a network address")
+	}
+	return ip.To4(), network, nil
+}
+
+// chooseNATAddress returns the first address of the network which is
+// neither the host address nor used.
+func chooseNATAddress(hostIP net.IP, network *net.IPNet, used []string) (string, error) {
+	taken := map[string]bool{hostIP.String(): true}
+	for _, ip := range used {
+		taken[ip] = true
+	}
+
+	ones, bits := network.Mask.Size()
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	// The first and last addresses are the network and broadcast ones.
+	for n := uint32(1); n < 1<<uint(bits-ones)-1; n++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, base+n)
+		if !taken[ip.String()] {
+			return ip.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("No IP address left on network %s", network)
+}
+
+// checkNATAddress checks the VM can have the IP address on the network: it
+// is neither the host address, the network or broadcast one, nor used.
+func checkNATAddress(address string, hostIP net.IP, network *net.IPNet, used []string) error {
+	ip := net.ParseIP(address).To4()
+	if ip == nil {
+		return fmt.Errorf("Invalid --hyperv-static-ip %q, expected an IPv4 address", address)
+	}
+	if !network.Contains(ip) {
+		return fmt.Errorf("--hyperv-static-ip %s is not on the network %s of the NAT switch", ip, network)
+	}
+
+	ones, bits := network.Mask.Size()
+	n := binary.BigEndian.Uint32(ip) - binary.BigEndian.Uint32(network.IP.To4())
+	if ip.Equal(hostIP) || n == 0 || n == 1<<uint(bits-ones)-1 {
+		return fmt.Errorf("--hyperv-static-ip %s is the address of the host, of the network or its broadcast address", ip)
+	}
+
+	for _, u := range used {
+		if u == ip.String() {
+			return fmt.Errorf("IP address %s is used by another VM on the NAT switch", ip)
+		}
+	}
+
+	return nil
+}
+
 func (d *Driver) wait() error {
 	log.Infof("Waiting for host to start...")
 	for {
@@ -283,6 +609,128 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+// Suspend saves the state of the VM to disk and stops it.
+func (d *Driver) Suspend() error {
+	command := []string{
+		"Save-VM",
+		"-Name", d.MachineName}
+	_, err := execute(command)
+	return err
+}
+
+// Resume starts the VM again from the state saved by Suspend.
+func (d *Driver) Resume() error {
+	command := []string{
+		"Start-VM",
+		"-Name", d.MachineName}
+	if _, err := execute(command); err != nil {
+		return err
+	}
+
+	return d.wait()
+}
+
+// CreateSnapshot takes a checkpoint of the VM.
+func (d *Driver) CreateSnapshot(name string) error {
+	command := []string{
+		"Checkpoint-VM",
+		"-Name", d.MachineName,
+		"-SnapshotName", fmt.Sprintf("'%s'", name)}
+	_, err := execute(command)
+	return err
+}
+
+// RestoreSnapshot reverts the VM to a checkpoint. Restoring a checkpoint
+// leaves the VM in the state it was in when the checkpoint was taken, so a
+// VM which was running is started again.
+func (d *Driver) RestoreSnapshot(name string) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	command := []string{
+		"Restore-VMSnapshot",
+		"-VMName", d.MachineName,
+		"-Name", fmt.Sprintf("'%s'", name),
+		"-Confirm:$false"}
+	if _, err := execute(command); err != nil {
+		return err
+	}
+
+	if s != state.Running {
+		return nil
+	}
+
+	if current, err := d.GetState(); err != nil || current == state.Running {
+		return err
+	}
+
+	return d.Resume()
+}
+
+// RemoveSnapshot deletes a checkpoint of the VM.
+func (d *Driver) RemoveSnapshot(name string) error {
+	command := []string{
+		"Remove-VMSnapshot",
+		"-VMName", d.MachineName,
+		"-Name", fmt.Sprintf("'%s'", name),
+		"-Confirm:$false"}
+	_, err := execute(command)
+	return err
+}
+
+// Resize changes the virtual processors, memory and disk size of the VM,
+// which must be stopped.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if opts.InstanceType != "" {
+		return drivers.ErrResizeNoInstanceTypes
+	}
+
+	if opts.DiskSize > 0 && opts.DiskSize < d.DiskSize {
+		return fmt.Errorf("Cannot shrink the disk of %s from %d MB to %d MB", d.MachineName, d.DiskSize, opts.DiskSize)
+	}
+
+	if opts.Memory > 0 && d.dynamicMemory() && (opts.Memory < d.DynamicMemoryMin || opts.Memory > d.DynamicMemoryMax) {
+		return fmt.Errorf("Cannot resize the memory of %s to %d MB, outside of its dynamic memory bounds of %d MB to %d MB", d.MachineName, opts.Memory, d.DynamicMemoryMin, d.DynamicMemoryMax)
+	}
+
+	if opts.CPUs > 0 {
+		command := []string{
+			"Set-VMProcessor",
+			"-VMName", d.MachineName,
+			"-Count", strconv.Itoa(opts.CPUs)}
+		if _, err := execute(command); err != nil {
+			return err
+		}
+		d.CPU = opts.CPUs
+	}
+
+	if opts.Memory > 0 {
+		command := []string{
+			"Set-VMMemory",
+			"-VMName", d.MachineName,
+			"-StartupBytes", fmt.Sprintf("%dMB", opts.Memory)}
+		if _, err := execute(command); err != nil {
+			return err
+		}
+		d.MemSize = opts.Memory
+	}
+
+	if opts.DiskSize > d.DiskSize {
+		command := []string{
+			"Resize-VHD",
+			"-Path", fmt.Sprintf("'%s'", d.diskImagePath()),
+			"-SizeBytes", fmt.Sprintf("%dMB", opts.DiskSize)}
+		if _, err := execute(command); err != nil {
+			return err
+		}
+		d.DiskSize = opts.DiskSize
+	}
+
+	return nil
+}
+
 func (d *Driver) Remove() error {
 	s, err := d.GetState()
 	if err != nil {
@@ -361,11 +809,88 @@ func (d *Driver) publicSSHKeyPath() string {
 	return d.GetSSHKeyPath() + ".pub"
 }
 
+// diskImagePath returns the path of the disk of the VM.
+func (d *Driver) diskImagePath() string {
+	// Generation 2 VMs only support VHDX disks.
+	if d.Generation == 2 {
+		return d.ResolveStorePath("disk.vhdx")
+	}
+	return d.ResolveStorePath("disk.vhd")
+}
+
+// generateCloudDisk makes the disk of the VM from the cloud image, resized
+// to the disk size, and the cloud-init seed letting in the SSH key and
+// configuring the static IP address of the VM, if any, on the interface with
+// the given MAC address.
+func (d *Driver) generateCloudDisk(b2dutils *mcnutils.B2dUtils, macAddress string) error {
+	image, err := b2dutils.DownloadImage(d.CloudImageURL, d.ResolveStorePath("."))
+	if err != nil {
+		return err
+	}
+
+	d.diskImage = d.diskImagePath()
+	log.Infof("Creating VHD from %s", image)
+	command := []string{
+		"Convert-VHD",
+		"-Path", fmt.Sprintf("'%s'", image),
+		"-DestinationPath", fmt.Sprintf("'%s'", d.diskImage),
+		"-VHDType", "Dynamic"}
+	_, err = execute(command)
+	if err != nil {
+		return err
+	}
+	if filepath.Dir(image) == d.ResolveStorePath(".") {
+		os.Remove(image)
+	}
+
+	command = []string{
+		"Resize-VHD",
+		"-Path", fmt.Sprintf("'%s'", d.diskImage),
+		"-SizeBytes", fmt.Sprintf("%dMB", d.DiskSize)}
+	_, err = execute(command)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	config := &cloudinit.Config{
+		Hostname:      d.MachineName,
+		User:          d.GetSSHUsername(),
+		AuthorizedKey: string(pubKey),
+	}
+	if d.StaticIPAddress != "" {
+		hostIP, network, err := parseNATCIDR(d.NATCIDR)
+		if err != nil {
+			return err
+		}
+		prefixLength, _ := network.Mask.Size()
+		config.Interfaces = []cloudinit.Interface{{
+			Name:       "eth0",
+			MACAddress: macAddress,
+			Address:    fmt.Sprintf("%s/%d", d.StaticIPAddress, prefixLength),
+			Gateway:    hostIP.String(),
+			Nameserver: defaultNATDNS,
+		}}
+	}
+
+	return cloudinit.WriteSeedISO(d.ResolveStorePath(seedFilename), config)
+}
+
+// generateMACAddress returns a random MAC address in the range of Hyper-V,
+// as written for Set-VMNetworkAdapter -StaticMacAddress.
+func generateMACAddress() string {
+	return fmt.Sprintf("00155D%06X", rand.Intn(1<<24))
+}
+
 func (d *Driver) generateDiskImage() error {
 	// Create a small fixed vhd, put the tar in,
 	// convert to dynamic, then resize
 
-	d.diskImage = d.ResolveStorePath("disk.vhd")
+	d.diskImage = d.diskImagePath()
 	fixed := d.ResolveStorePath("fixed.vhd")
 	log.Infof("Creating VHD")
 	command := []string{
@@ -455,8 +980,37 @@ func (d *Driver) generateTar() (*bytes.Buffer, error) {
 	if _, err := tw.Write([]byte(pubKey)); err != nil {
 		return nil, err
 	}
+	if d.StaticIPAddress != "" {
+		script, err := d.bootsyncScript()
+		if err != nil {
+			return nil, err
+		}
+		file = &tar.Header{Name: "bootsync.sh", Size: int64(len(script)), Mode: 0755}
+		if err := tw.WriteHeader(file); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(script)); err != nil {
+			return nil, err
+		}
+	}
 	if err := tw.Close(); err != nil {
 		return nil, err
 	}
 	return buf, nil
 }
+
+// bootsyncScript returns the script boot2docker runs at boot to configure
+// the static IP address of the VM on the NAT switch.
+func (d *Driver) bootsyncScript() (string, error) {
+	hostIP, network, err := parseNATCIDR(d.NATCIDR)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`#!/bin/sh
+[ -f /var/run/udhcpc.eth0.pid ] && kill $(cat /var/run/udhcpc.eth0.pid)
+ifconfig eth0 %s netmask %s up
+route add default gw %s eth0
+echo "nameserver %s" > /etc/resolv.conf
+`, d.StaticIPAddress, net.IP(network.Mask), hostIP, defaultNATDNS), nil
+}
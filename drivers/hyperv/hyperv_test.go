@@ -0,0 +1,153 @@
+package hyperv
+
+import (
+	"net"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+func newTestFlags(data map[string]interface{}) DriverOptionsMock {
+	flags := map[string]interface{}{
+		"hyperv-memory":     defaultMemory,
+		"hyperv-generation": defaultGeneration,
+		"hyperv-nat-cidr":   defaultNATCIDR,
+	}
+	for key, value := range data {
+		flags[key] = value
+	}
+	return DriverOptionsMock{Data: flags}
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	d := NewDriver("default", "").(*Driver)
+
+	err := d.SetConfigFromFlags(newTestFlags(map[string]interface{}{
+		"hyperv-generation":         2,
+		"hyperv-secure-boot":        true,
+		"hyperv-dynamic-memory-min": 512,
+		"hyperv-dynamic-memory-max": 4096,
+		"hyperv-create-nat-switch":  true,
+		"hyperv-static-ip":          "192.168.250.10",
+		"hyperv-cloud-image-url":    "/images/focal.vhdx",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, d.Generation)
+	assert.Equal(t, "/images/focal.vhdx", d.CloudImageURL)
+	assert.Equal(t, d.ResolveStorePath("disk.vhdx"), d.diskImagePath())
+	assert.True(t, d.SecureBoot)
+	assert.True(t, d.dynamicMemory())
+	assert.True(t, d.CreateNATSwitch)
+	assert.Equal(t, "192.168.250.10", d.StaticIPAddress)
+}
+
+func TestSetConfigFromFlagsErrors(t *testing.T) {
+	var tests = []struct {
+		flags map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{"hyperv-generation": 3}, "hyperv driver requires --hyperv-generation to be 1 or 2"},
+		{map[string]interface{}{"hyperv-secure-boot": true}, "hyperv driver only supports --hyperv-secure-boot on generation 2 VMs"},
+		{map[string]interface{}{"hyperv-dynamic-memory-max": 4096}, "hyperv driver requires both --hyperv-dynamic-memory-min and --hyperv-dynamic-memory-max"},
+		{map[string]interface{}{"hyperv-dynamic-memory-min": 2048, "hyperv-dynamic-memory-max": 4096}, "hyperv driver requires --hyperv-memory to be between the dynamic memory minimum and maximum"},
+		{map[string]interface{}{"hyperv-dynamic-memory-min": 512, "hyperv-dynamic-memory-max": 4096, "hyperv-nested-virtualization": true}, "hyperv driver doesn't support dynamic memory with nested virtualization"},
+		{map[string]interface{}{"hyperv-create-nat-switch": true, "hyperv-nat-cidr": "192.168.250.0/24"}, "--hyperv-nat-cidr must be specified with a host address, not a network address"},
+		{map[string]interface{}{"hyperv-create-nat-switch": true, "hyperv-nat-cidr": "nat"}, `Invalid --hyperv-nat-cidr "nat", expected an IPv4 CIDR like 192.168.250.1/24`},
+		{map[string]interface{}{"hyperv-static-ip": "192.168.250.10"}, "hyperv driver only supports --hyperv-static-ip with --hyperv-create-nat-switch"},
+		{map[string]interface{}{"hyperv-create-nat-switch": true, "hyperv-static-ip": "10.0.0.2"}, "--hyperv-static-ip 10.0.0.2 is not on the network 192.168.250.0/24 of the NAT switch"},
+	}
+
+	for _, expected := range tests {
+		d := NewDriver("default", "").(*Driver)
+
+		err := d.SetConfigFromFlags(newTestFlags(expected.flags))
+
+		assert.EqualError(t, err, expected.err)
+	}
+}
+
+func TestChooseNATAddress(t *testing.T) {
+	hostIP, network, _ := net.ParseCIDR("192.168.250.1/24")
+
+	ip, err := chooseNATAddress(hostIP, network, []string{"192.168.250.2", "fe80::1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.250.3", ip)
+
+	hostIP, network, _ = net.ParseCIDR("10.0.0.1/30")
+	_, err = chooseNATAddress(hostIP, network, []string{"10.0.0.2"})
+	assert.EqualError(t, err, "No IP address left on network 10.0.0.0/30")
+}
+
+func TestCheckNATAddress(t *testing.T) {
+	hostIP, network, _ := net.ParseCIDR("192.168.250.1/24")
+
+	assert.NoError(t, checkNATAddress("192.168.250.10", hostIP, network, []string{"192.168.250.2"}))
+	assert.EqualError(t, checkNATAddress("192.168.250.2", hostIP, network, []string{"192.168.250.2"}), "IP address 192.168.250.2 is used by another VM on the NAT switch")
+	assert.EqualError(t, checkNATAddress("192.168.250.255", hostIP, network, nil), "--hyperv-static-ip 192.168.250.255 is the address of the host, of the network or its broadcast address")
+	assert.EqualError(t, checkNATAddress("192.168.250.1", hostIP, network, nil), "--hyperv-static-ip 192.168.250.1 is the address of the host, of the network or its broadcast address")
+	assert.EqualError(t, checkNATAddress("fe80::1", hostIP, network, nil), `Invalid --hyperv-static-ip "fe80::1", expected an IPv4 address`)
+}
+
+func TestBootsyncScript(t *testing.T) {
+	d := NewDriver("default", "").(*Driver)
+	d.StaticIPAddress = "192.168.250.3"
+
+	script, err := d.bootsyncScript()
+
+	assert.NoError(t, err)
+	assert.Contains(t, script, "ifconfig eth0 192.168.250.3 netmask 255.255.255.0 up\n")
+	assert.Contains(t, script, "route add default gw 192.168.250.1 eth0\n")
+}
+
+func TestGenerateMACAddress(t *testing.T) {
+	assert.Regexp(t, "^00155D[0-9A-F]{6}$", generateMACAddress())
+}
+
+func TestResizeErrors(t *testing.T) {
+	d := NewDriver("default", "").(*Driver)
+	d.DynamicMemoryMin = 512
+	d.DynamicMemoryMax = 2048
+
+	err := d.Resize(drivers.ResizeOptions{InstanceType: "large"})
+	assert.Equal(t, drivers.ErrResizeNoInstanceTypes, err)
+
+	err = d.Resize(drivers.ResizeOptions{DiskSize: 10000})
+	assert.EqualError(t, err, "Cannot shrink the disk of default from 20000 MB to 10000 MB")
+
+	err = d.Resize(drivers.ResizeOptions{Memory: 4096})
+	assert.EqualError(t, err, "Cannot resize the memory of default to 4096 MB, outside of its dynamic memory bounds of 512 MB to 2048 MB")
+}
@@ -0,0 +1,164 @@
+package kvm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/state"
+)
+
+var (
+	reDomainState  = regexp.MustCompile(`(?m)^State:\s+(.+?)\s*$`)
+	reManagedSave  = regexp.MustCompile(`(?m)^Managed save:\s+(\w+)`)
+	reNoSuchDomain = regexp.MustCompile(`failed to get domain|Domain not found`)
+)
+
+var domainTemplate = template.Must(template.New("domain").Funcs(template.FuncMap{"escape": escapeXML}).Parse(`<domain type='kvm'>
+  <name>{{escape .MachineName}}</name>
+  <memory unit='MiB'>{{.Memory}}</memory>
+  <vcpu>{{.CPU}}</vcpu>
+  <features>
+    <acpi/>
+    <apic/>
+    <pae/>
+  </features>
+  <cpu mode='host-passthrough'/>
+  <os>
+    <type>hvm</type>
+    <boot dev='cdrom'/>
+    <boot dev='hd'/>
+    <bootmenu enable='no'/>
+  </os>
+  <devices>
+    <disk type='volume' device='cdrom'>
+      <source pool='{{escape .StoragePool}}' volume='{{escape .isoVolume}}'/>
+      <target dev='hdc' bus='ide'/>
+      <readonly/>
+    </disk>
+    <disk type='volume' device='disk'>
+      <driver name='qemu' type='raw' cache='default' io='threads'/>
+      <source pool='{{escape .StoragePool}}' volume='{{escape .diskVolume}}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <interface type='network'>
+      <source network='{{escape .Network}}'/>
+      <mac address='{{.MACAddress}}'/>
+      <model type='virtio'/>
+    </interface>
+{{- range .Bridges}}
+    <interface type='bridge'>
+      <source bridge='{{escape .}}'/>
+      <model type='virtio'/>
+    </interface>
+{{- end}}
+    <serial type='pty'>
+      <log file='{{escape .consoleLog}}' append='on'/>
+      <target port='0'/>
+    </serial>
+    <console type='pty'>
+      <target type='serial' port='0'/>
+    </console>
+    <rng model='virtio'>
+      <backend model='random'>/dev/random</backend>
+    </rng>
+  </devices>
+</domain>
+`))
+
+// defaultNetworkXML defines the NAT network libvirt usually ships with, for
+// hosts on which it was removed.
+const defaultNetworkXML = `<network>
+  <name>default</name>
+  <forward mode='nat'/>
+  <bridge name='virbr0' stp='on' delay='0'/>
+  <ip address='192.168.122.1' netmask='255.255.255.0'>
+    <dhcp>
+      <range start='192.168.122.2' end='192.168.122.254'/>
+    </dhcp>
+  </ip>
+</network>
+`
+
+func escapeXML(s string) (string, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// domainXML returns the libvirt definition of the domain of the machine.
+func (d *Driver) domainXML() (string, error) {
+	var buf bytes.Buffer
+	err := domainTemplate.Execute(&buf, map[string]interface{}{
+		"MachineName": d.MachineName,
+		"Memory":      d.Memory,
+		"CPU":         d.CPU,
+		"StoragePool": d.StoragePool,
+		"Network":     d.Network,
+		"MACAddress":  d.MACAddress,
+		"Bridges":     d.Bridges,
+		"isoVolume":   d.isoVolume(),
+		"diskVolume":  d.diskVolume(),
+		"consoleLog":  d.consoleLogPath(),
+	})
+	return buf.String(), err
+}
+
+// parseDomainState converts the output of `virsh dominfo` to a state.
+func parseDomainState(out string) state.State {
+	groups := reDomainState.FindStringSubmatch(out)
+	if len(groups) < 2 {
+		return state.None
+	}
+
+	switch groups[1] {
+	case "running", "idle", "blocked":
+		return state.Running
+	case "paused", "pmsuspended":
+		return state.Paused
+	case "in shutdown":
+		return state.Stopping
+	case "shut off":
+		if saved := reManagedSave.FindStringSubmatch(out); len(saved) == 2 && saved[1] == "yes" {
+			return state.Saved
+		}
+		return state.Stopped
+	case "crashed":
+		return state.Error
+	}
+	return state.None
+}
+
+// parseLeaseIP returns the IPv4 address leased to the interface with mac
+// from the output of `virsh net-dhcp-leases`, the last one if there are
+// several.
+func parseLeaseIP(out, mac string) string {
+	ip := ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if !strings.EqualFold(field, mac) {
+				continue
+			}
+			if i+2 < len(fields) && fields[i+1] == "ipv4" {
+				ip = strings.SplitN(fields[i+2], "/", 2)[0]
+			}
+		}
+	}
+	return ip
+}
+
+// generateMACAddress returns a random MAC address in the range QEMU uses.
+func generateMACAddress() (string, error) {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", buf[0], buf[1], buf[2]), nil
+}
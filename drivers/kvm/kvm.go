@@ -0,0 +1,492 @@
+package kvm
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	isoFilename            = "boot2docker.iso"
+	defaultURI             = "qemu:///system"
+	defaultCPU             = 1
+	defaultMemory          = 1024
+	defaultDiskSize        = 20000
+	defaultStoragePool     = "default"
+	defaultStoragePoolPath = "/var/lib/libvirt/images"
+	defaultNetwork         = "default"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	virshManager    VirshManager
+	URI             string
+	CPU             int
+	Memory          int
+	DiskSize        int
+	Boot2DockerURL  string
+	StoragePool     string
+	StoragePoolPath string
+	Network         string
+	Bridges         []string
+	MACAddress      string
+}
+
+// NewDriver creates a new KVM driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+		URI:             defaultURI,
+		CPU:             defaultCPU,
+		Memory:          defaultMemory,
+		DiskSize:        defaultDiskSize,
+		StoragePool:     defaultStoragePool,
+		StoragePoolPath: defaultStoragePoolPath,
+		Network:         defaultNetwork,
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "KVM_CONNECTION_URI",
+			Name:   "kvm-connection-uri",
+			Usage:  "URI of the libvirt daemon to connect to",
+			Value:  defaultURI,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "KVM_MEMORY_SIZE",
+			Name:   "kvm-memory",
+			Usage:  "Size of memory for host in MB",
+			Value:  defaultMemory,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "KVM_CPU_COUNT",
+			Name:   "kvm-cpu-count",
+			Usage:  "Number of CPUs for the machine",
+			Value:  defaultCPU,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "KVM_DISK_SIZE",
+			Name:   "kvm-disk-size",
+			Usage:  "Size of disk for host in MB",
+			Value:  defaultDiskSize,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "KVM_BOOT2DOCKER_URL",
+			Name:   "kvm-boot2docker-url",
+			Usage:  "The URL of the boot2docker image. Defaults to the latest available version",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "KVM_STORAGE_POOL",
+			Name:   "kvm-storage-pool",
+			Usage:  "Name of the libvirt storage pool to create the disks of the machine in",
+			Value:  defaultStoragePool,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "KVM_STORAGE_POOL_PATH",
+			Name:   "kvm-storage-pool-path",
+			Usage:  "Directory of the storage pool, if it has to be created",
+			Value:  defaultStoragePoolPath,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "KVM_NETWORK",
+			Name:   "kvm-network",
+			Usage:  "Name of the libvirt NAT network to connect the machine to",
+			Value:  defaultNetwork,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "kvm-bridge",
+			Usage: "Name of a host bridge to connect an additional network interface of the machine to",
+			Value: []string{},
+		},
+	}
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.URI = flags.String("kvm-connection-uri")
+	d.Memory = flags.Int("kvm-memory")
+	d.CPU = flags.Int("kvm-cpu-count")
+	d.DiskSize = flags.Int("kvm-disk-size")
+	d.Boot2DockerURL = flags.String("kvm-boot2docker-url")
+	d.StoragePool = flags.String("kvm-storage-pool")
+	d.StoragePoolPath = flags.String("kvm-storage-pool-path")
+	d.Network = flags.String("kvm-network")
+	d.Bridges = flags.StringSlice("kvm-bridge")
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHUser = "docker"
+	d.SSHPort = 22
+
+	if d.CPU < 1 {
+		return fmt.Errorf("kvm-cpu-count must be at least 1, got %d", d.CPU)
+	}
+
+	return nil
+}
+
+func (d *Driver) DriverName() string {
+	return "kvm"
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) GetSSHUsername() string {
+	if d.SSHUser == "" {
+		d.SSHUser = "docker"
+	}
+
+	return d.SSHUser
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+// PreCreateCheck checks that virsh exists and can connect to libvirt.
+func (d *Driver) PreCreateCheck() error {
+	if err := d.virsh("version"); err != nil {
+		return err
+	}
+
+	if _, err := d.virshOut("dominfo", d.MachineName); err == nil {
+		return fmt.Errorf("libvirt domain %q already exists", d.MachineName)
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
+	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+		return err
+	}
+
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	if err := d.setupStoragePool(); err != nil {
+		return fmt.Errorf("Error setting up storage pool %s: %s", d.StoragePool, err)
+	}
+
+	if err := d.setupNetwork(); err != nil {
+		return fmt.Errorf("Error setting up network %s: %s", d.Network, err)
+	}
+
+	log.Infof("Creating disk volumes...")
+	if err := d.createISOVolume(); err != nil {
+		return err
+	}
+
+	if err := d.generateDiskImage(); err != nil {
+		return err
+	}
+
+	mac, err := generateMACAddress()
+	if err != nil {
+		return err
+	}
+	d.MACAddress = mac
+
+	domain, err := d.domainXML()
+	if err != nil {
+		return err
+	}
+
+	domainPath := d.ResolveStorePath("domain.xml")
+	if err := ioutil.WriteFile(domainPath, []byte(domain), 0644); err != nil {
+		return err
+	}
+
+	log.Infof("Creating KVM domain...")
+	if err := d.virsh("define", domainPath); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+func (d *Driver) Start() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Stopped, state.Saved:
+		// The network may not be started after the host rebooted.
+		if err := d.setupNetwork(); err != nil {
+			return fmt.Errorf("Error setting up network %s on machine start: %s", d.Network, err)
+		}
+		log.Infof("Starting VM...")
+		if err := d.virsh("start", d.MachineName); err != nil {
+			return err
+		}
+	case state.Paused:
+		log.Infof("Resuming VM...")
+		if err := d.virsh("resume", d.MachineName); err != nil {
+			return err
+		}
+	default:
+		log.Infof("VM not in restartable state")
+	}
+
+	return d.waitForIP()
+}
+
+func (d *Driver) waitForIP() error {
+	log.Infof("Waiting for the VM to get an IP address...")
+	if err := mcnutils.WaitForSpecific(d.ipAvailable, 60, 2*time.Second); err != nil {
+		return err
+	}
+
+	var err error
+	if d.IPAddress, err = d.GetIP(); err != nil {
+		return err
+	}
+
+	return drivers.WaitForSSH(d)
+}
+
+func (d *Driver) ipAvailable() bool {
+	ip, err := d.GetIP()
+	if err != nil {
+		log.Debugf("No IP address yet: %s", err)
+		return false
+	}
+	return ip != ""
+}
+
+func (d *Driver) Stop() error {
+	if err := d.virsh("shutdown", d.MachineName); err != nil {
+		return err
+	}
+	for {
+		s, err := d.GetState()
+		if err != nil {
+			return err
+		}
+		if s == state.Running || s == state.Stopping {
+			time.Sleep(1 * time.Second)
+		} else {
+			break
+		}
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+// Suspend saves the state of the VM to disk and stops it.
+func (d *Driver) Suspend() error {
+	return d.virsh("managedsave", d.MachineName)
+}
+
+// Resume starts the VM again from the state saved by Suspend.
+func (d *Driver) Resume() error {
+	if err := d.virsh("start", d.MachineName); err != nil {
+		return err
+	}
+
+	return drivers.WaitForSSH(d)
+}
+
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err != nil && err != ErrMachineNotExist {
+		return err
+	}
+
+	if err == ErrMachineNotExist {
+		log.Infof("machine does not exist, assuming it has been removed already")
+	} else {
+		if s == state.Running || s == state.Paused || s == state.Stopping {
+			if err := d.Kill(); err != nil {
+				return err
+			}
+		}
+		if err := d.virsh("undefine", d.MachineName, "--managed-save"); err != nil {
+			return err
+		}
+	}
+
+	if err := d.removeVolume(d.diskVolume()); err != nil {
+		return err
+	}
+
+	return d.removeVolume(d.isoVolume())
+}
+
+func (d *Driver) Restart() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running {
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+	return d.Start()
+}
+
+func (d *Driver) Kill() error {
+	if err := d.virsh("destroy", d.MachineName); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	stdout, stderr, err := d.virshOutErr("dominfo", d.MachineName)
+	if err != nil {
+		if reNoSuchDomain.MatchString(stderr) {
+			return state.Error, ErrMachineNotExist
+		}
+		return state.Error, err
+	}
+
+	return parseDomainState(stdout), nil
+}
+
+// GetIP returns the address the NAT network leased to the machine.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	out, err := d.virshOut("net-dhcp-leases", d.Network, "--mac", d.MACAddress)
+	if err != nil {
+		return "", err
+	}
+
+	ip := parseLeaseIP(out, d.MACAddress)
+	if ip == "" {
+		return "", fmt.Errorf("No IP address leased to %s in network %s", d.MACAddress, d.Network)
+	}
+
+	return ip, nil
+}
+
+func (d *Driver) consoleLogPath() string {
+	return d.ResolveStorePath("console.log")
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
+
+// generateDiskImage creates the disk volume of the machine, with a tar
+// archive at its start holding the SSH key. boot2docker formats the disk
+// and extracts the archive on first boot.
+// See https://github.com/boot2docker/boot2docker/blob/master/rootfs/rootfs/etc/rc.d/automount
+func (d *Driver) generateDiskImage() error {
+	log.Debugf("Creating %d MB hard disk image...", d.DiskSize)
+
+	magicString := "boot2docker, please format-me"
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	// magicString first so the automount script knows to format the disk
+	file := &tar.Header{Name: magicString, Size: int64(len(magicString))}
+	if err := tw.WriteHeader(file); err != nil {
+		return err
+	}
+	if _, err := tw.Write([]byte(magicString)); err != nil {
+		return err
+	}
+	// .ssh/key.pub => authorized_keys
+	file = &tar.Header{Name: ".ssh", Typeflag: tar.TypeDir, Mode: 0700}
+	if err := tw.WriteHeader(file); err != nil {
+		return err
+	}
+	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+	file = &tar.Header{Name: ".ssh/authorized_keys", Size: int64(len(pubKey)), Mode: 0644}
+	if err := tw.WriteHeader(file); err != nil {
+		return err
+	}
+	if _, err := tw.Write([]byte(pubKey)); err != nil {
+		return err
+	}
+	file = &tar.Header{Name: ".ssh/authorized_keys2", Size: int64(len(pubKey)), Mode: 0644}
+	if err := tw.WriteHeader(file); err != nil {
+		return err
+	}
+	if _, err := tw.Write([]byte(pubKey)); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	tarPath := d.ResolveStorePath("disk.tar")
+	if err := ioutil.WriteFile(tarPath, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	defer os.Remove(tarPath)
+
+	return d.createVolume(d.diskVolume(), int64(d.DiskSize), tarPath)
+}
+
+func (d *Driver) manager() VirshManager {
+	if d.virshManager == nil {
+		d.virshManager = &VirshCmdManager{URI: d.URI}
+	}
+	return d.virshManager
+}
+
+func (d *Driver) virsh(args ...string) error {
+	return d.manager().virsh(args...)
+}
+
+func (d *Driver) virshOut(args ...string) (string, error) {
+	return d.manager().virshOut(args...)
+}
+
+func (d *Driver) virshOutErr(args ...string) (string, string, error) {
+	return d.manager().virshOutErr(args...)
+}
@@ -0,0 +1,144 @@
+package kvm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type VirshManagerMock struct {
+	VirshCmdManager
+	args   string
+	stdOut string
+	stdErr string
+	err    error
+}
+
+func (v *VirshManagerMock) virsh(args ...string) error {
+	_, _, err := v.virshOutErr(args...)
+	return err
+}
+
+func (v *VirshManagerMock) virshOut(args ...string) (string, error) {
+	stdout, _, err := v.virshOutErr(args...)
+	return stdout, err
+}
+
+func (v *VirshManagerMock) virshOutErr(args ...string) (string, string, error) {
+	if strings.Join(args, " ") == v.args {
+		return v.stdOut, v.stdErr, v.err
+	}
+	return "", "", errors.New("Invalid args")
+}
+
+func newTestDriver(name string) *Driver {
+	return NewDriver(name, "/store")
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "kvm", newTestDriver("default").DriverName())
+}
+
+func TestDefaultSSHUsername(t *testing.T) {
+	assert.Equal(t, "docker", newTestDriver("default").GetSSHUsername())
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		stdOut string
+		state  state.State
+	}{
+		{"Id:             3\nName:           default\nState:          running\n", state.Running},
+		{"State:          idle\n", state.Running},
+		{"State:          paused\n", state.Paused},
+		{"State:          in shutdown\n", state.Stopping},
+		{"State:          shut off\nManaged save:   no\n", state.Stopped},
+		{"State:          shut off\nManaged save:   yes\n", state.Saved},
+		{"State:          crashed\n", state.Error},
+		{"State:          whatever\n", state.None},
+		{"", state.None},
+	}
+
+	for _, expected := range tests {
+		driver := newTestDriver("default")
+		driver.virshManager = &VirshManagerMock{
+			args:   "dominfo default",
+			stdOut: expected.stdOut,
+		}
+
+		machineState, err := driver.GetState()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestStateMachineNotExist(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.virshManager = &VirshManagerMock{
+		args:   "dominfo default",
+		stdErr: "error: failed to get domain 'default'\nerror: Domain not found: no domain with matching name 'default'\n",
+		err:    errors.New("exit status 1"),
+	}
+
+	machineState, err := driver.GetState()
+
+	assert.Equal(t, ErrMachineNotExist, err)
+	assert.Equal(t, state.Error, machineState)
+}
+
+func TestGetIPNotRunning(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.virshManager = &VirshManagerMock{
+		args:   "dominfo default",
+		stdOut: "State:          shut off\n",
+	}
+
+	ip, err := driver.GetIP()
+
+	assert.Empty(t, ip)
+	assert.Equal(t, drivers.ErrHostIsNotRunning, err)
+}
+
+func TestParseLeaseIP(t *testing.T) {
+	out := ` Expiry Time          MAC address        Protocol  IP address                Hostname        Client ID or DUID
+-------------------------------------------------------------------------------------------------------------------
+ 2016-03-01 10:00:00  52:54:00:12:34:56  ipv4      192.168.122.10/24         boot2docker     -
+ 2016-03-01 10:05:00  52:54:00:ab:cd:ef  ipv4      192.168.122.11/24         boot2docker     -
+ 2016-03-01 10:10:00  52:54:00:12:34:56  ipv4      192.168.122.12/24         boot2docker     -
+`
+
+	assert.Equal(t, "192.168.122.12", parseLeaseIP(out, "52:54:00:12:34:56"))
+	assert.Equal(t, "192.168.122.11", parseLeaseIP(out, "52:54:00:AB:CD:EF"))
+	assert.Equal(t, "", parseLeaseIP(out, "52:54:00:00:00:00"))
+}
+
+func TestGenerateMACAddress(t *testing.T) {
+	mac, err := generateMACAddress()
+
+	assert.NoError(t, err)
+	assert.Regexp(t, `^52:54:00:[0-9a-f]{2}:[0-9a-f]{2}:[0-9a-f]{2}$`, mac)
+}
+
+func TestDomainXML(t *testing.T) {
+	driver := newTestDriver("default")
+	driver.MACAddress = "52:54:00:12:34:56"
+	driver.Bridges = []string{"br0", "br<1>"}
+
+	domain, err := driver.domainXML()
+
+	assert.NoError(t, err)
+	assert.Contains(t, domain, "<name>default</name>")
+	assert.Contains(t, domain, "<memory unit='MiB'>1024</memory>")
+	assert.Contains(t, domain, "<source pool='default' volume='default.img'/>")
+	assert.Contains(t, domain, "<source pool='default' volume='default-boot2docker.iso'/>")
+	assert.Contains(t, domain, "<source network='default'/>")
+	assert.Contains(t, domain, "<mac address='52:54:00:12:34:56'/>")
+	assert.Contains(t, domain, "<source bridge='br0'/>")
+	assert.Contains(t, domain, "<source bridge='br&lt;1&gt;'/>")
+	assert.Contains(t, domain, "<log file='/store/machines/default/console.log' append='on'/>")
+}
@@ -0,0 +1,51 @@
+package kvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var reNoSuchNetwork = regexp.MustCompile(`Network not found|failed to get network`)
+
+// setupNetwork makes sure the NAT network of the machine exists and is
+// active. Only the default network is created when it is missing, other
+// networks have to be defined beforehand.
+func (d *Driver) setupNetwork() error {
+	out, stderr, err := d.virshOutErr("net-info", d.Network)
+	if err != nil {
+		if !reNoSuchNetwork.MatchString(stderr) {
+			return err
+		}
+		if d.Network != defaultNetwork {
+			return fmt.Errorf("libvirt network %q does not exist", d.Network)
+		}
+
+		log.Infof("Creating libvirt network %s...", d.Network)
+		if err := d.defineDefaultNetwork(); err != nil {
+			return err
+		}
+		out = ""
+	}
+
+	if groups := reActive.FindStringSubmatch(out); len(groups) == 2 && groups[1] == "yes" {
+		return nil
+	}
+
+	return d.virsh("net-start", d.Network)
+}
+
+func (d *Driver) defineDefaultNetwork() error {
+	path := d.ResolveStorePath("network.xml")
+	if err := ioutil.WriteFile(path, []byte(defaultNetworkXML), 0644); err != nil {
+		return err
+	}
+
+	if err := d.virsh("net-define", path); err != nil {
+		return err
+	}
+
+	return d.virsh("net-autostart", d.Network)
+}
@@ -0,0 +1,84 @@
+package kvm
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	reActive       = regexp.MustCompile(`(?m)^Active:\s+(\w+)`)
+	reNoSuchPool   = regexp.MustCompile(`Storage pool not found|failed to get pool`)
+	reNoSuchVolume = regexp.MustCompile(`Storage volume not found|failed to get vol`)
+)
+
+// setupStoragePool makes sure the storage pool of the machine exists and is
+// active. A missing pool is created as a directory pool at StoragePoolPath.
+func (d *Driver) setupStoragePool() error {
+	out, stderr, err := d.virshOutErr("pool-info", d.StoragePool)
+	if err != nil {
+		if !reNoSuchPool.MatchString(stderr) {
+			return err
+		}
+
+		log.Infof("Creating storage pool %s in %s...", d.StoragePool, d.StoragePoolPath)
+		if err := d.virsh("pool-define-as", d.StoragePool, "dir", "--target", d.StoragePoolPath); err != nil {
+			return err
+		}
+		if err := d.virsh("pool-build", d.StoragePool); err != nil {
+			return err
+		}
+		if err := d.virsh("pool-autostart", d.StoragePool); err != nil {
+			return err
+		}
+		out = ""
+	}
+
+	if groups := reActive.FindStringSubmatch(out); len(groups) == 2 && groups[1] == "yes" {
+		return nil
+	}
+
+	return d.virsh("pool-start", d.StoragePool)
+}
+
+// createVolume creates a raw volume of size MB in the storage pool of the
+// machine and uploads the content of the file at path to its start.
+func (d *Driver) createVolume(name string, size int64, path string) error {
+	if err := d.virsh("vol-create-as", d.StoragePool, name, fmt.Sprintf("%dM", size), "--format", "raw"); err != nil {
+		return err
+	}
+
+	return d.virsh("vol-upload", "--pool", d.StoragePool, name, path)
+}
+
+// createISOVolume uploads the boot2docker ISO in the machine directory to
+// the storage pool, so that libvirt does not need access to the store.
+func (d *Driver) createISOVolume() error {
+	fi, err := os.Stat(d.ResolveStorePath(isoFilename))
+	if err != nil {
+		return err
+	}
+
+	// Round the size of the volume up to the next MB.
+	size := (fi.Size() + 1<<20 - 1) >> 20
+	return d.createVolume(d.isoVolume(), size, d.ResolveStorePath(isoFilename))
+}
+
+func (d *Driver) removeVolume(name string) error {
+	_, stderr, err := d.virshOutErr("vol-delete", "--pool", d.StoragePool, name)
+	if err != nil && reNoSuchVolume.MatchString(stderr) {
+		log.Debugf("Volume %s does not exist, assuming it has been removed already", name)
+		return nil
+	}
+	return err
+}
+
+func (d *Driver) isoVolume() string {
+	return d.MachineName + "-" + isoFilename
+}
+
+func (d *Driver) diskVolume() string {
+	return d.MachineName + ".img"
+}
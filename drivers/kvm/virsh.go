@@ -0,0 +1,69 @@
+package kvm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	ErrMachineNotExist = errors.New("machine does not exist")
+	ErrVirshNotFound   = errors.New("virsh not found. Make sure libvirt is installed and virsh is in the path")
+)
+
+// VirshManager defines the interface to communicate with libvirt.
+type VirshManager interface {
+	virsh(args ...string) error
+
+	virshOut(args ...string) (string, error)
+
+	virshOutErr(args ...string) (string, string, error)
+}
+
+// VirshCmdManager communicates with libvirt through the commandline using
+// `virsh`, connected to the libvirt daemon at URI.
+type VirshCmdManager struct {
+	URI string
+}
+
+func (v *VirshCmdManager) virsh(args ...string) error {
+	_, _, err := v.virshOutErr(args...)
+	return err
+}
+
+func (v *VirshCmdManager) virshOut(args ...string) (string, error) {
+	stdout, _, err := v.virshOutErr(args...)
+	return stdout, err
+}
+
+func (v *VirshCmdManager) virshOutErr(args ...string) (string, string, error) {
+	if v.URI != "" {
+		args = append([]string{"--connect", v.URI}, args...)
+	}
+
+	cmd := exec.Command("virsh", args...)
+	log.Debugf("COMMAND: virsh %v", strings.Join(args, " "))
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	stderrStr := stderr.String()
+	log.Debugf("STDOUT:\n{\n%v}", stdout.String())
+	log.Debugf("STDERR:\n{\n%v}", stderrStr)
+
+	if err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
+			return "", "", ErrVirshNotFound
+		}
+		if strings.Contains(stderrStr, "error:") {
+			err = fmt.Errorf("virsh %v failed:\n%v", strings.Join(args, " "), stderrStr)
+		}
+	}
+
+	return stdout.String(), stderrStr, err
+}
@@ -0,0 +1,192 @@
+package lxd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const unixScheme = "unix://"
+
+// response is the envelope of every reply of the LXD REST API.
+type response struct {
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code"`
+	Operation  string          `json:"operation"`
+	ErrorCode  int             `json:"error_code"`
+	Error      string          `json:"error"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// operation is the metadata of an asynchronous request.
+type operation struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	Err        string `json:"err"`
+}
+
+// apiError is an error returned by the LXD daemon.
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("LXD error (%d): %s", e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.Code == http.StatusNotFound
+}
+
+// client talks to the REST API of a local or remote LXD daemon.
+type client struct {
+	url  string
+	http *http.Client
+}
+
+// newClient returns a client for the LXD daemon at remote, which is either
+// the path of its unix socket as unix:///path or an https:// URL. Remote
+// daemons authenticate the client with its certificate, and are trusted if
+// their certificate is serverCert or is signed by a known authority when no
+// serverCert is given.
+func newClient(remote, clientCert, clientKey, serverCert string) (*client, error) {
+	if strings.HasPrefix(remote, unixScheme) {
+		socket := strings.TrimPrefix(remote, unixScheme)
+		return &client{
+			url: "http://unix.socket",
+			http: &http.Client{
+				Transport: &http.Transport{
+					Dial: func(network, addr string) (net.Conn, error) {
+						return net.Dial("unix", socket)
+					},
+				},
+			},
+		}, nil
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("LXD remote must be a unix:// socket or an https:// URL, got %q", remote)
+	}
+
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the LXD client certificate: %s", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if serverCert != "" {
+		pem, err := ioutil.ReadFile(serverCert)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the LXD server certificate: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %s", serverCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &client{
+		url: strings.TrimSuffix(remote, "/"),
+		http: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// do sends a request to the API and waits for it to complete, decoding the
+// metadata of the response into out when it is not nil.
+func (c *client) do(method, path string, body interface{}, out interface{}) error {
+	resp, err := c.request(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	if resp.Type == "async" {
+		return c.wait(resp.Operation)
+	}
+
+	if out != nil && len(resp.Metadata) > 0 {
+		return json.Unmarshal(resp.Metadata, out)
+	}
+
+	return nil
+}
+
+func (c *client) request(method, path string, body interface{}) (*response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Debugf("LXD request: %s %s", method, path)
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resp := &response{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return nil, fmt.Errorf("Error decoding LXD response to %s %s: %s", method, path, err)
+	}
+
+	if resp.Type == "error" {
+		return nil, &apiError{Code: resp.ErrorCode, Message: resp.Error}
+	}
+
+	return resp, nil
+}
+
+// wait blocks until the background operation at path is done.
+func (c *client) wait(path string) error {
+	resp, err := c.request("GET", path+"/wait", nil)
+	if err != nil {
+		return err
+	}
+
+	op := operation{}
+	if err := json.Unmarshal(resp.Metadata, &op); err != nil {
+		return err
+	}
+
+	if op.StatusCode != http.StatusOK {
+		return &apiError{Code: op.StatusCode, Message: op.Err}
+	}
+
+	return nil
+}
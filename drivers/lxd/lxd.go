@@ -0,0 +1,528 @@
+package lxd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultRemote      = "unix:///var/lib/lxd/unix.socket"
+	defaultImageServer = "https://cloud-images.ubuntu.com/releases"
+	defaultImage       = "16.04"
+	defaultSSHUser     = "ubuntu"
+
+	// dockerProfile is the LXD profile holding the settings dockerd needs
+	// to run in a container.
+	dockerProfile = "docker-machine"
+
+	// stopTimeout is how long LXD waits for a container to stop gracefully,
+	// in seconds.
+	stopTimeout = 30
+)
+
+var ErrMachineNotExist = errors.New("machine does not exist")
+
+// dockerProfileConfig lets dockerd run containers of its own, and loads the
+// kernel modules it needs which containers cannot load themselves.
+var dockerProfileConfig = map[string]string{
+	"security.nesting":     "true",
+	"linux.kernel_modules": "overlay,nf_nat,ip_tables,ip6_tables,netlink_diag,br_netfilter,xt_conntrack,nf_conntrack,ip_vs,vxlan",
+}
+
+type Driver struct {
+	*drivers.BaseDriver
+	client      *client
+	Remote      string
+	ClientCert  string
+	ClientKey   string
+	ServerCert  string
+	ImageServer string
+	Image       string
+	Profiles    []string
+	Privileged  bool
+}
+
+type containerState struct {
+	Status  string                      `json:"status"`
+	Network map[string]containerNetwork `json:"network"`
+}
+
+type containerNetwork struct {
+	Addresses []containerAddress `json:"addresses"`
+}
+
+type containerAddress struct {
+	Family  string `json:"family"`
+	Address string `json:"address"`
+	Scope   string `json:"scope"`
+}
+
+// NewDriver creates a new LXD driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     defaultSSHUser,
+		},
+		Remote:      defaultRemote,
+		ImageServer: defaultImageServer,
+		Image:       defaultImage,
+	}
+}
+
+func lxcConfigPath(file string) string {
+	return filepath.Join(mcnutils.GetHomeDir(), ".config", "lxc", file)
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "LXD_REMOTE",
+			Name:   "lxd-remote",
+			Usage:  "LXD daemon to create the container on, as unix:///path/to/socket or https://host:8443",
+			Value:  defaultRemote,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_CLIENT_CERT",
+			Name:   "lxd-client-cert",
+			Usage:  "Certificate to authenticate with to a remote LXD daemon",
+			Value:  lxcConfigPath("client.crt"),
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_CLIENT_KEY",
+			Name:   "lxd-client-key",
+			Usage:  "Key of the certificate to authenticate with to a remote LXD daemon",
+			Value:  lxcConfigPath("client.key"),
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_SERVER_CERT",
+			Name:   "lxd-server-cert",
+			Usage:  "Certificate of a remote LXD daemon, if it is not signed by a known authority",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_IMAGE_SERVER",
+			Name:   "lxd-image-server",
+			Usage:  "Simplestreams server to get the image from, empty for an image of the LXD daemon",
+			Value:  defaultImageServer,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_IMAGE",
+			Name:   "lxd-image",
+			Usage:  "Alias of the image to create the container from",
+			Value:  defaultImage,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "lxd-profile",
+			Usage: "Additional LXD profile to apply to the container",
+			Value: []string{},
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "LXD_PRIVILEGED",
+			Name:   "lxd-privileged",
+			Usage:  "Create a privileged container",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "LXD_SSH_USER",
+			Name:   "lxd-ssh-user",
+			Usage:  "SSH user of the image",
+			Value:  defaultSSHUser,
+		},
+	}
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Remote = flags.String("lxd-remote")
+	d.ClientCert = flags.String("lxd-client-cert")
+	d.ClientKey = flags.String("lxd-client-key")
+	d.ServerCert = flags.String("lxd-server-cert")
+	d.ImageServer = flags.String("lxd-image-server")
+	d.Image = flags.String("lxd-image")
+	d.Profiles = flags.StringSlice("lxd-profile")
+	d.Privileged = flags.Bool("lxd-privileged")
+	d.SSHUser = flags.String("lxd-ssh-user")
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHPort = 22
+
+	return nil
+}
+
+func (d *Driver) DriverName() string {
+	return "lxd"
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) lxd() (*client, error) {
+	if d.client == nil {
+		c, err := newClient(d.Remote, d.ClientCert, d.ClientKey, d.ServerCert)
+		if err != nil {
+			return nil, err
+		}
+		d.client = c
+	}
+	return d.client, nil
+}
+
+func (d *Driver) containerPath(parts ...string) string {
+	path := "/1.0/containers/" + url.QueryEscape(d.MachineName)
+	for _, part := range parts {
+		path += "/" + url.QueryEscape(part)
+	}
+	return path
+}
+
+// PreCreateCheck checks that the LXD daemon can be reached and that it
+// trusts the client.
+func (d *Driver) PreCreateCheck() error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	server := struct {
+		Auth string `json:"auth"`
+	}{}
+	if err := c.do("GET", "/1.0", nil, &server); err != nil {
+		return fmt.Errorf("Error connecting to LXD at %s: %s", d.Remote, err)
+	}
+	if server.Auth != "trusted" {
+		return fmt.Errorf("The LXD daemon at %s does not trust the client certificate %s", d.Remote, d.ClientCert)
+	}
+
+	return nil
+}
+
+// setupDockerProfile creates the profile dockerd needs if it is missing.
+func (d *Driver) setupDockerProfile() error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	err = c.do("GET", "/1.0/profiles/"+dockerProfile, nil, nil)
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+
+	log.Infof("Creating LXD profile %s...", dockerProfile)
+	return c.do("POST", "/1.0/profiles", map[string]interface{}{
+		"name":        dockerProfile,
+		"description": "Settings for Docker Machine containers running dockerd",
+		"config":      dockerProfileConfig,
+	}, nil)
+}
+
+// containerConfig returns the request creating the container of the
+// machine, which lets in the SSH key through cloud-init.
+func (d *Driver) containerConfig(pubKey string) map[string]interface{} {
+	config := map[string]string{
+		"user.user-data": fmt.Sprintf("#cloud-config\nssh_authorized_keys:\n  - %s\n", pubKey),
+	}
+	if d.Privileged {
+		config["security.privileged"] = "true"
+	}
+
+	source := map[string]string{
+		"type":  "image",
+		"alias": d.Image,
+	}
+	if d.ImageServer != "" {
+		source["mode"] = "pull"
+		source["server"] = d.ImageServer
+		source["protocol"] = "simplestreams"
+	}
+
+	return map[string]interface{}{
+		"name":     d.MachineName,
+		"profiles": append([]string{"default", dockerProfile}, d.Profiles...),
+		"config":   config,
+		"source":   source,
+	}
+}
+
+func (d *Driver) Create() error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	pubKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return err
+	}
+
+	if err := d.setupDockerProfile(); err != nil {
+		return fmt.Errorf("Error setting up LXD profile %s: %s", dockerProfile, err)
+	}
+
+	log.Infof("Creating LXD container from image %s...", d.Image)
+	if err := c.do("POST", "/1.0/containers", d.containerConfig(strings.TrimSpace(string(pubKey))), nil); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+func (d *Driver) changeState(action string, force bool) error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	return c.do("PUT", d.containerPath("state"), map[string]interface{}{
+		"action":  action,
+		"timeout": stopTimeout,
+		"force":   force,
+	}, nil)
+}
+
+func (d *Driver) Start() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Stopped:
+		log.Infof("Starting container...")
+		if err := d.changeState("start", false); err != nil {
+			return err
+		}
+	case state.Paused:
+		log.Infof("Unfreezing container...")
+		if err := d.changeState("unfreeze", false); err != nil {
+			return err
+		}
+	default:
+		log.Infof("Container not in restartable state")
+	}
+
+	log.Infof("Waiting for the container to get an IP address...")
+	if err := mcnutils.WaitForSpecific(d.ipAvailable, 60, 1*time.Second); err != nil {
+		return err
+	}
+
+	if d.IPAddress, err = d.GetIP(); err != nil {
+		return err
+	}
+
+	return drivers.WaitForSSH(d)
+}
+
+func (d *Driver) ipAvailable() bool {
+	ip, err := d.GetIP()
+	if err != nil {
+		log.Debugf("No IP address yet: %s", err)
+		return false
+	}
+	return ip != ""
+}
+
+func (d *Driver) Stop() error {
+	if err := d.changeState("stop", false); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+func (d *Driver) Kill() error {
+	if err := d.changeState("stop", true); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+func (d *Driver) Restart() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running {
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+	return d.Start()
+}
+
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err == ErrMachineNotExist {
+		log.Infof("machine does not exist, assuming it has been removed already")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running || s == state.Paused {
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	return c.do("DELETE", d.containerPath(), nil, nil)
+}
+
+// CreateSnapshot takes a snapshot of the container's filesystem.
+func (d *Driver) CreateSnapshot(name string) error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	return c.do("POST", d.containerPath("snapshots"), map[string]interface{}{
+		"name":     name,
+		"stateful": false,
+	}, nil)
+}
+
+// RestoreSnapshot reverts the container to a snapshot.
+func (d *Driver) RestoreSnapshot(name string) error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	return c.do("PUT", d.containerPath(), map[string]string{"restore": name}, nil)
+}
+
+// RemoveSnapshot deletes a snapshot of the container.
+func (d *Driver) RemoveSnapshot(name string) error {
+	c, err := d.lxd()
+	if err != nil {
+		return err
+	}
+
+	return c.do("DELETE", d.containerPath("snapshots", name), nil, nil)
+}
+
+func (d *Driver) containerState() (*containerState, error) {
+	c, err := d.lxd()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &containerState{}
+	if err := c.do("GET", d.containerPath("state"), nil, cs); err != nil {
+		if isNotFound(err) {
+			return nil, ErrMachineNotExist
+		}
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	cs, err := d.containerState()
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch cs.Status {
+	case "Running":
+		return state.Running, nil
+	case "Frozen", "Freezing":
+		return state.Paused, nil
+	case "Stopped":
+		return state.Stopped, nil
+	case "Starting":
+		return state.Starting, nil
+	case "Stopping", "Aborting":
+		return state.Stopping, nil
+	case "Error":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+// GetIP returns the global IPv4 address of the container, on eth0 if it
+// has one.
+func (d *Driver) GetIP() (string, error) {
+	cs, err := d.containerState()
+	if err != nil {
+		return "", err
+	}
+	if cs.Status != "Running" {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	ip := containerIP(cs.Network)
+	if ip == "" {
+		return "", fmt.Errorf("No IP address found for container %s", d.MachineName)
+	}
+
+	return ip, nil
+}
+
+func containerIP(network map[string]containerNetwork) string {
+	names := []string{}
+	for name := range network {
+		if name != "eth0" && name != "lo" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range append([]string{"eth0"}, names...) {
+		for _, addr := range network[name].Addresses {
+			if addr.Family == "inet" && addr.Scope == "global" {
+				return addr.Address
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package lxd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDriver(responses map[string]string) (*Driver, *jsonapitest.API, func()) {
+	fake := jsonapitest.New(responses)
+	fake.ByPath = true
+	fake.NotFoundStatus = http.StatusOK
+	fake.NotFound = `{"type": "error", "error": "not found", "error_code": 404}`
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.client = &client{url: server.URL, http: server.Client()}
+
+	return d, fake, server.Close
+}
+
+func stateResponse(status string) string {
+	return fmt.Sprintf(`{"type": "sync", "status_code": 200, "metadata": {
+		"status": %q,
+		"network": {
+			"lo": {"addresses": [{"family": "inet", "address": "127.0.0.1", "scope": "local"}]},
+			"eth0": {"addresses": [
+				{"family": "inet6", "address": "fd42::1", "scope": "global"},
+				{"family": "inet", "address": "10.0.3.27", "scope": "global"}
+			]}
+		}
+	}}`, status)
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "lxd", NewDriver("default", "").DriverName())
+}
+
+func TestDefaultSSHUsername(t *testing.T) {
+	assert.Equal(t, "ubuntu", NewDriver("default", "").GetSSHUsername())
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		status string
+		state  state.State
+	}{
+		{"Running", state.Running},
+		{"Frozen", state.Paused},
+		{"Stopped", state.Stopped},
+		{"Starting", state.Starting},
+		{"Stopping", state.Stopping},
+		{"Error", state.Error},
+		{"Whatever", state.None},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(map[string]string{
+			"GET /1.0/containers/default/state": stateResponse(expected.status),
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestStateMachineNotExist(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+
+	machineState, err := d.GetState()
+
+	assert.Equal(t, ErrMachineNotExist, err)
+	assert.Equal(t, state.Error, machineState)
+}
+
+func TestGetIP(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /1.0/containers/default/state": stateResponse("Running"),
+	})
+	defer done()
+
+	ip, err := d.GetIP()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.3.27", ip)
+}
+
+func TestGetIPNotRunning(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /1.0/containers/default/state": stateResponse("Stopped"),
+	})
+	defer done()
+
+	ip, err := d.GetIP()
+
+	assert.Empty(t, ip)
+	assert.Equal(t, drivers.ErrHostIsNotRunning, err)
+}
+
+func TestAsyncOperation(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"PUT /1.0/containers/default/state": `{"type": "async", "status_code": 100, "operation": "/1.0/operations/1234"}`,
+		"GET /1.0/operations/1234/wait":     `{"type": "sync", "status_code": 200, "metadata": {"id": "1234", "status_code": 200}}`,
+		"DELETE /1.0/containers/default":    `{"type": "async", "status_code": 100, "operation": "/1.0/operations/5678"}`,
+		"GET /1.0/operations/5678/wait":     `{"type": "sync", "status_code": 200, "metadata": {"id": "5678", "status_code": 400, "err": "container is running"}}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.Kill())
+	assert.Equal(t, []string{"PUT /1.0/containers/default/state", "GET /1.0/operations/1234/wait"}, fake.Requests)
+	assert.Equal(t, map[string]interface{}{"action": "stop", "timeout": float64(stopTimeout), "force": true}, fake.Bodies["PUT /1.0/containers/default/state"])
+
+	c, _ := d.lxd()
+	assert.EqualError(t, c.do("DELETE", d.containerPath(), nil, nil), "LXD error (400): container is running")
+}
+
+func TestSetupDockerProfile(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /1.0/profiles": `{"type": "sync", "status_code": 200}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.setupDockerProfile())
+	assert.Equal(t, []string{"GET /1.0/profiles/docker-machine", "POST /1.0/profiles"}, fake.Requests)
+	assert.Equal(t, "docker-machine", fake.Bodies["POST /1.0/profiles"]["name"])
+	assert.Equal(t, map[string]interface{}{
+		"security.nesting":     "true",
+		"linux.kernel_modules": dockerProfileConfig["linux.kernel_modules"],
+	}, fake.Bodies["POST /1.0/profiles"]["config"])
+}
+
+func TestContainerConfig(t *testing.T) {
+	d := NewDriver("default", "")
+	d.Profiles = []string{"macvlan"}
+	d.Privileged = true
+
+	config := d.containerConfig("ssh-rsa AAAA")
+
+	assert.Equal(t, "default", config["name"])
+	assert.Equal(t, []string{"default", "docker-machine", "macvlan"}, config["profiles"])
+	assert.Equal(t, map[string]string{
+		"user.user-data":      "#cloud-config\nssh_authorized_keys:\n  - ssh-rsa AAAA\n",
+		"security.privileged": "true",
+	}, config["config"])
+	assert.Equal(t, map[string]string{
+		"type":     "image",
+		"alias":    "16.04",
+		"mode":     "pull",
+		"server":   "https://cloud-images.ubuntu.com/releases",
+		"protocol": "simplestreams",
+	}, config["source"])
+
+	d.ImageServer = ""
+	assert.Equal(t, map[string]string{"type": "image", "alias": "16.04"}, d.containerConfig("")["source"])
+}
+
+func TestNewClientRejectsPlainHTTP(t *testing.T) {
+	_, err := newClient("http://lxd:8443", "", "", "")
+
+	assert.EqualError(t, err, `LXD remote must be a unix:// socket or an https:// URL, got "http://lxd:8443"`)
+}
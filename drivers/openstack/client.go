@@ -18,6 +18,7 @@ import (
 	"github.com/rackspace/gophercloud/openstack/compute/v2/images"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/servers"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/rackspace/gophercloud/openstack/networking/v2/extensions/security/groups"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/networks"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
 	"github.com/rackspace/gophercloud/pagination"
@@ -34,17 +35,23 @@ type Client interface {
 	StopInstance(d *Driver) error
 	RestartInstance(d *Driver) error
 	DeleteInstance(d *Driver) error
+	ListInstances(d *Driver) ([]servers.Server, error)
+	DeleteServer(d *Driver, id string) error
 	WaitForInstanceStatus(d *Driver, status string) error
 	GetInstanceIpAddresses(d *Driver) ([]IpAddress, error)
 	CreateKeyPair(d *Driver, name string, publicKey string) error
 	DeleteKeyPair(d *Driver, name string) error
 	GetNetworkId(d *Driver) (string, error)
+	FindNetworkId(d *Driver, nameOrId string) (string, error)
 	GetFlavorId(d *Driver) (string, error)
 	GetImageId(d *Driver) (string, error)
 	AssignFloatingIP(d *Driver, floatingIp *FloatingIp) error
 	GetFloatingIPs(d *Driver) ([]FloatingIp, error)
 	GetFloatingIpPoolId(d *Driver) (string, error)
 	GetInstancePortId(d *Driver) (string, error)
+	GetPortId(d *Driver, nameOrId string) (string, error)
+	GetSecurityGroupId(d *Driver, nameOrId string) (string, error)
+	SetPortSecurityGroups(d *Driver, portId string, securityGroupIds []string) error
 }
 
 type GenericClient struct {
@@ -54,31 +61,110 @@ type GenericClient struct {
 }
 
 func (c *GenericClient) CreateInstance(d *Driver) (string, error) {
+	body, err := createOpts(d).ToServerCreateMap()
+	if err != nil {
+		return "", err
+	}
+
+	requestOpts := &gophercloud.RequestOpts{OkCodes: []int{201, 202}}
+	if d.BootFromVolume && d.VolumeType != "" {
+		// Block devices only take a volume type since the 2.67 microversion.
+		requestOpts.MoreHeaders = map[string]string{"X-OpenStack-Nova-API-Version": "2.67"}
+	}
+
+	log.Info("Creating machine...")
+
+	var result servers.CreateResult
+	_, result.Err = c.Compute.Post(c.Compute.ServiceURL("servers"), body, &result.Body, requestOpts)
+	server, err := result.Extract()
+	if err != nil {
+		return "", err
+	}
+	return server.ID, nil
+}
+
+// createOpts returns the options creating the instance of the driver.
+func createOpts(d *Driver) servers.CreateOptsBuilder {
 	serverOpts := servers.CreateOpts{
 		Name:             d.MachineName,
 		FlavorRef:        d.FlavorId,
 		ImageRef:         d.ImageId,
-		SecurityGroups:   d.SecurityGroups,
 		AvailabilityZone: d.AvailabilityZone,
 	}
+	if len(d.ResourceTags) > 0 {
+		serverOpts.Metadata = d.ResourceTags
+	}
+	// The security groups of pre-created ports are set on the ports.
+	if len(d.PortIds) == 0 {
+		serverOpts.SecurityGroups = d.SecurityGroups
+	}
 	if d.NetworkId != "" {
-		serverOpts.Networks = []servers.Network{
-			{
-				UUID: d.NetworkId,
-			},
+		serverOpts.Networks = append(serverOpts.Networks, servers.Network{
+			UUID: d.NetworkId,
+		})
+	}
+	for _, networkId := range d.NetworkIds {
+		serverOpts.Networks = append(serverOpts.Networks, servers.Network{
+			UUID: networkId,
+		})
+	}
+	for _, portId := range d.PortIds {
+		serverOpts.Networks = append(serverOpts.Networks, servers.Network{
+			Port: portId,
+		})
+	}
+
+	var opts servers.CreateOptsBuilder = serverOpts
+	if d.BootFromVolume {
+		opts = bootVolumeOpts{
+			CreateOptsBuilder:   opts,
+			ImageId:             d.ImageId,
+			Size:                d.VolumeSize,
+			Type:                d.VolumeType,
+			DeleteOnTermination: !d.VolumeKeep,
 		}
 	}
 
-	log.Info("Creating machine...")
+	return keypairs.CreateOptsExt{
+		CreateOptsBuilder: opts,
+		KeyName:           d.KeyPairName,
+	}
+}
 
-	server, err := servers.Create(c.Compute, keypairs.CreateOptsExt{
-		serverOpts,
-		d.KeyPairName,
-	}).Extract()
+// bootVolumeOpts extends the server creation options to boot from a volume
+// created from the image, which the bootfromvolume extension can't give a
+// type to.
+type bootVolumeOpts struct {
+	servers.CreateOptsBuilder
+	ImageId             string
+	Size                int
+	Type                string
+	DeleteOnTermination bool
+}
+
+func (opts bootVolumeOpts) ToServerCreateMap() (map[string]interface{}, error) {
+	base, err := opts.CreateOptsBuilder.ToServerCreateMap()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return server.ID, nil
+
+	blockDevice := map[string]interface{}{
+		"boot_index":            0,
+		"source_type":           "image",
+		"destination_type":      "volume",
+		"uuid":                  opts.ImageId,
+		"volume_size":           opts.Size,
+		"delete_on_termination": opts.DeleteOnTermination,
+	}
+	if opts.Type != "" {
+		blockDevice["volume_type"] = opts.Type
+	}
+
+	serverMap := base["server"].(map[string]interface{})
+	serverMap["imageRef"] = ""
+	serverMap["block_device_mapping_v2"] = []map[string]interface{}{blockDevice}
+
+	return base, nil
 }
 
 const (
@@ -139,6 +225,25 @@ func (c *GenericClient) DeleteInstance(d *Driver) error {
 	return nil
 }
 
+// ListInstances returns the servers of the tenant in the region, with their
+// metadata.
+func (c *GenericClient) ListInstances(d *Driver) ([]servers.Server, error) {
+	page, err := servers.List(c.Compute, nil).AllPages()
+	if err != nil {
+		return nil, err
+	}
+	return servers.ExtractServers(page)
+}
+
+// DeleteServer deletes the server of id, which needn't be the one of the
+// driver.
+func (c *GenericClient) DeleteServer(d *Driver, id string) error {
+	if result := servers.Delete(c.Compute, id); result.Err != nil {
+		return result.Err
+	}
+	return nil
+}
+
 func (c *GenericClient) WaitForInstanceStatus(d *Driver, status string) error {
 	return mcnutils.WaitForSpecificOrError(func() (bool, error) {
 		current, err := servers.Get(c.Compute, d.MachineId).Extract()
@@ -198,6 +303,30 @@ func (c *GenericClient) GetNetworkId(d *Driver) (string, error) {
 	return c.getNetworkId(d, d.NetworkName)
 }
 
+// FindNetworkId returns the id of the network with the given id or name.
+func (c *GenericClient) FindNetworkId(d *Driver, nameOrId string) (string, error) {
+	networkId := ""
+
+	for _, opts := range []networks.ListOpts{{ID: nameOrId}, {Name: nameOrId}} {
+		err := networks.List(c.Network, opts).EachPage(func(page pagination.Page) (bool, error) {
+			networkList, err := networks.ExtractNetworks(page)
+			if err != nil {
+				return false, err
+			}
+			for _, n := range networkList {
+				networkId = n.ID
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil || networkId != "" {
+			return networkId, err
+		}
+	}
+
+	return "", nil
+}
+
 func (c *GenericClient) GetFloatingIpPoolId(d *Driver) (string, error) {
 	return c.getNetworkId(d, d.FloatingIpPool)
 }
@@ -432,6 +561,59 @@ func (c *GenericClient) GetInstancePortId(d *Driver) (string, error) {
 	return portId, nil
 }
 
+func (c *GenericClient) GetPortId(d *Driver, nameOrId string) (string, error) {
+	portId := ""
+
+	for _, opts := range []ports.ListOpts{{ID: nameOrId}, {Name: nameOrId}} {
+		err := ports.List(c.Network, opts).EachPage(func(page pagination.Page) (bool, error) {
+			portList, err := ports.ExtractPorts(page)
+			if err != nil {
+				return false, err
+			}
+			for _, port := range portList {
+				portId = port.ID
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil || portId != "" {
+			return portId, err
+		}
+	}
+
+	return "", nil
+}
+
+func (c *GenericClient) GetSecurityGroupId(d *Driver, nameOrId string) (string, error) {
+	securityGroupId := ""
+
+	for _, opts := range []groups.ListOpts{{ID: nameOrId}, {Name: nameOrId}} {
+		err := groups.List(c.Network, opts).EachPage(func(page pagination.Page) (bool, error) {
+			groupList, err := groups.ExtractGroups(page)
+			if err != nil {
+				return false, err
+			}
+			for _, group := range groupList {
+				securityGroupId = group.ID
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil || securityGroupId != "" {
+			return securityGroupId, err
+		}
+	}
+
+	return "", nil
+}
+
+func (c *GenericClient) SetPortSecurityGroups(d *Driver, portId string, securityGroupIds []string) error {
+	_, err := ports.Update(c.Network, portId, ports.UpdateOpts{
+		SecurityGroups: securityGroupIds,
+	}).Extract()
+	return err
+}
+
 func (c *GenericClient) InitComputeClient(d *Driver) error {
 	if c.Compute != nil {
 		return nil
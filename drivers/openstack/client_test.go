@@ -0,0 +1,200 @@
+package openstack
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/servers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateOpts(t *testing.T) {
+	d := NewDerivedDriver("default", "")
+	d.KeyPairName = "default-key"
+	d.FlavorId = "flavor"
+	d.ImageId = "image"
+	d.NetworkId = "network"
+	d.SecurityGroups = []string{"docker"}
+
+	opts, err := createOpts(d).ToServerCreateMap()
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"server": map[string]interface{}{
+			"name":            "default",
+			"imageRef":        "image",
+			"flavorRef":       "flavor",
+			"key_name":        "default-key",
+			"security_groups": []map[string]interface{}{{"name": "docker"}},
+			"networks":        []map[string]interface{}{{"uuid": "network"}},
+		},
+	}, opts)
+}
+
+func TestCreateOptsMetadata(t *testing.T) {
+	d := NewDerivedDriver("default", "")
+	d.ResourceTags = map[string]string{"team": "infra", "machine-name": "default"}
+
+	opts, err := createOpts(d).ToServerCreateMap()
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "infra", "machine-name": "default"}, opts["server"].(map[string]interface{})["metadata"])
+}
+
+type orphanClient struct {
+	Client
+	servers []servers.Server
+	deleted []string
+}
+
+func (c *orphanClient) Authenticate(d *Driver) error      { return nil }
+func (c *orphanClient) InitComputeClient(d *Driver) error { return nil }
+
+func (c *orphanClient) ListInstances(d *Driver) ([]servers.Server, error) {
+	return c.servers, nil
+}
+
+func (c *orphanClient) DeleteServer(d *Driver, id string) error {
+	c.deleted = append(c.deleted, "server "+id)
+	return nil
+}
+
+func (c *orphanClient) DeleteKeyPair(d *Driver, name string) error {
+	c.deleted = append(c.deleted, "key-pair "+name)
+	return nil
+}
+
+func TestOrphanedResources(t *testing.T) {
+	client := &orphanClient{servers: []servers.Server{
+		{ID: "1", KeyName: "web-abc", Metadata: map[string]interface{}{"machine-name": "web", "machine-store": "s1"}},
+		{ID: "2", KeyName: "db-def", Metadata: map[string]interface{}{"machine-name": "db", "machine-store": "s1"}},
+		{ID: "3", KeyName: "old-ghi", Metadata: map[string]interface{}{"machine-name": "old", "machine-store": "s2"}},
+		{ID: "4", KeyName: "mine", Metadata: map[string]interface{}{}},
+	}}
+	d := NewDerivedDriver("default", "")
+	d.client = client
+
+	resources, err := d.OrphanedResources("s1", []string{"db"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.OrphanedResource{
+		{Kind: "server", ID: "1", Machine: "web"},
+		{Kind: "key-pair", ID: "web-abc", Machine: "web"},
+	}, resources)
+
+	for _, resource := range resources {
+		assert.NoError(t, d.RemoveOrphanedResource(resource))
+	}
+	assert.Equal(t, []string{"server 1", "key-pair web-abc"}, client.deleted)
+}
+
+func TestCreateOptsBootFromVolumeAndPorts(t *testing.T) {
+	d := NewDerivedDriver("default", "")
+	d.KeyPairName = "default-key"
+	d.FlavorId = "flavor"
+	d.ImageId = "image"
+	d.SecurityGroups = []string{"docker"}
+	d.PortIds = []string{"port-1", "port-2"}
+	d.BootFromVolume = true
+	d.VolumeSize = 40
+	d.VolumeType = "ssd"
+
+	opts, err := createOpts(d).ToServerCreateMap()
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"server": map[string]interface{}{
+			"name":      "default",
+			"imageRef":  "",
+			"flavorRef": "flavor",
+			"key_name":  "default-key",
+			"networks":  []map[string]interface{}{{"port": "port-1"}, {"port": "port-2"}},
+			"block_device_mapping_v2": []map[string]interface{}{{
+				"boot_index":            0,
+				"source_type":           "image",
+				"destination_type":      "volume",
+				"uuid":                  "image",
+				"volume_size":           40,
+				"volume_type":           "ssd",
+				"delete_on_termination": true,
+			}},
+		},
+	}, opts)
+}
+
+func TestCreateOptsNetworks(t *testing.T) {
+	d := NewDerivedDriver("default", "")
+	d.KeyPairName = "default-key"
+	d.FlavorId = "flavor"
+	d.ImageId = "image"
+	d.NetworkId = "management"
+	d.NetworkIds = []string{"data", "overlay"}
+
+	opts, err := createOpts(d).ToServerCreateMap()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"uuid": "management"}, {"uuid": "data"}, {"uuid": "overlay"}}, opts["server"].(map[string]interface{})["networks"])
+}
+
+func TestMachineAddresses(t *testing.T) {
+	addresses := []IpAddress{
+		{Network: "overlay", AddressType: Fixed, Address: "10.1.0.5", Version: 4},
+		{Network: "data", AddressType: Fixed, Address: "10.2.0.5", Version: 4},
+		{Network: "data", AddressType: Fixed, Address: "fd00::5", Version: 6},
+		{Network: "management", AddressType: Fixed, Address: "10.0.0.5", Version: 4},
+		{Network: "management", AddressType: Floating, Address: "203.0.113.5", Version: 4},
+	}
+
+	assert.Equal(t, []drivers.Address{
+		{Network: "management", IP: "203.0.113.5"},
+		{Network: "data", IP: "10.2.0.5"},
+		{Network: "management", IP: "10.0.0.5"},
+		{Network: "overlay", IP: "10.1.0.5"},
+	}, machineAddresses("203.0.113.5", 4, addresses))
+
+	assert.Equal(t, []drivers.Address{
+		{Network: drivers.DefaultNetwork, IP: "10.9.9.9"},
+	}, machineAddresses("10.9.9.9", 6, addresses[:2]))
+}
+
+func TestFixedIPv6(t *testing.T) {
+	addresses := []IpAddress{
+		{Network: "public", AddressType: Floating, Address: "2001:db8::10", Version: 6},
+		{Network: "public", AddressType: Fixed, Address: "203.0.113.5", Version: 4},
+		{Network: "public", AddressType: Fixed, Address: "2001:db8::5", Version: 6},
+	}
+
+	assert.Equal(t, "2001:db8::5", fixedIPv6(addresses))
+	assert.Equal(t, "", fixedIPv6(addresses[:2]))
+}
+
+func TestCheckConfigVolumeAndPorts(t *testing.T) {
+	d := NewDerivedDriver("default", "")
+	d.AuthUrl = "http://keystone:5000/v2.0"
+	d.Username = "user"
+	d.Password = "password"
+	d.TenantName = "tenant"
+	d.FlavorId = "flavor"
+	d.ImageId = "image"
+
+	d.BootFromVolume = true
+	assert.EqualError(t, d.checkConfig(), "Volume size must be specified using the CLI option --openstack-volume-size")
+
+	d.BootFromVolume = false
+	d.VolumeType = "ssd"
+	assert.EqualError(t, d.checkConfig(), "--openstack-volume-type can only be used with --openstack-boot-from-volume")
+
+	d.VolumeType = ""
+	d.Ports = []string{"port-1"}
+	d.ComputeNetwork = true
+	assert.EqualError(t, d.checkConfig(), "Ports can only be used with neutron, not with --openstack-nova-network")
+
+	d.ComputeNetwork = false
+	assert.NoError(t, d.checkConfig())
+
+	d.SSHBastion = "jump@bastion:ssh"
+	assert.EqualError(t, d.checkConfig(), `Invalid SSH bastion "jump@bastion:ssh", bad port "ssh"`)
+
+	d.SSHBastion = "jump@bastion:2222"
+	assert.NoError(t, d.checkConfig())
+}
@@ -3,6 +3,8 @@ package openstack
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,7 +38,15 @@ type Driver struct {
 	KeyPairName      string
 	NetworkName      string
 	NetworkId        string
+	NetworkIds       []string
 	SecurityGroups   []string
+	SecurityGroupIds []string
+	Ports            []string
+	PortIds          []string
+	BootFromVolume   bool
+	VolumeSize       int
+	VolumeType       string
+	VolumeKeep       bool
 	FloatingIpPool   string
 	ComputeNetwork   bool
 	FloatingIpPoolId string
@@ -159,6 +169,33 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack comma separated security groups for the machine",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_PORTS",
+			Name:   "openstack-ports",
+			Usage:  "OpenStack comma separated names or ids of existing ports to attach the machine to",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "OS_BOOT_FROM_VOLUME",
+			Name:   "openstack-boot-from-volume",
+			Usage:  "Boot the machine from a volume created from the image instead of a local disk",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "OS_VOLUME_SIZE",
+			Name:   "openstack-volume-size",
+			Usage:  "OpenStack size in GB of the boot volume",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_VOLUME_TYPE",
+			Name:   "openstack-volume-type",
+			Usage:  "OpenStack volume type of the boot volume",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "OS_VOLUME_KEEP",
+			Name:   "openstack-volume-keep",
+			Usage:  "Keep the boot volume when the machine is removed",
+		},
 		mcnflag.BoolFlag{
 			EnvVar: "OS_NOVA_NETWORK",
 			Name:   "openstack-nova-network",
@@ -188,6 +225,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack SSH port",
 			Value:  defaultSSHPort,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_SSH_BASTION",
+			Name:   "openstack-ssh-bastion",
+			Usage:  "Jump host to reach the instance through over SSH, as [user@]host[:port], e.g. without a floating IP",
+			Value:  "",
+		},
 		mcnflag.IntFlag{
 			EnvVar: "OS_ACTIVE_TIMEOUT",
 			Name:   "openstack-active-timeout",
@@ -222,6 +265,15 @@ func (d *Driver) DriverName() string {
 	return "openstack"
 }
 
+// Capabilities adds the floating IPs and server metadata tags of the driver
+// to those of its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.StaticIP = true
+	capabilities.Tags = true
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AuthUrl = flags.String("openstack-auth-url")
 	d.ActiveTimeout = flags.Int("openstack-active-timeout")
@@ -241,9 +293,17 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.ImageName = flags.String("openstack-image-name")
 	d.NetworkId = flags.String("openstack-net-id")
 	d.NetworkName = flags.String("openstack-net-name")
+	d.Networks = flags.StringSlice("network")
 	if flags.String("openstack-sec-groups") != "" {
 		d.SecurityGroups = strings.Split(flags.String("openstack-sec-groups"), ",")
 	}
+	if flags.String("openstack-ports") != "" {
+		d.Ports = strings.Split(flags.String("openstack-ports"), ",")
+	}
+	d.BootFromVolume = flags.Bool("openstack-boot-from-volume")
+	d.VolumeSize = flags.Int("openstack-volume-size")
+	d.VolumeType = flags.String("openstack-volume-type")
+	d.VolumeKeep = flags.Bool("openstack-volume-keep")
 	d.FloatingIpPool = flags.String("openstack-floatingip-pool")
 	d.IpVersion = flags.Int("openstack-ip-version")
 	d.ComputeNetwork = flags.Bool("openstack-nova-network")
@@ -252,6 +312,18 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	if bastion := flags.String("openstack-ssh-bastion"); bastion != "" {
+		d.SSHBastion = bastion
+	}
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
 
 	return d.checkConfig()
 }
@@ -264,7 +336,7 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -299,6 +371,87 @@ func (d *Driver) GetIP() (string, error) {
 	return "", fmt.Errorf("No IP found for the machine")
 }
 
+// GetIPv6 returns the fixed IPv6 address of dual-stack machines, whose IP is
+// an IPv4 one.
+func (d *Driver) GetIPv6() (string, error) {
+	if d.IpVersion != 4 {
+		return "", nil
+	}
+
+	if err := d.initCompute(); err != nil {
+		return "", err
+	}
+
+	addresses, err := d.client.GetInstanceIpAddresses(d)
+	if err != nil {
+		return "", err
+	}
+
+	return fixedIPv6(addresses), nil
+}
+
+// fixedIPv6 returns the first fixed IPv6 address, or an empty string if
+// there is none.
+func fixedIPv6(addresses []IpAddress) string {
+	for _, a := range addresses {
+		if a.AddressType == Fixed && a.Version == 6 {
+			return a.Address
+		}
+	}
+	return ""
+}
+
+// GetAddresses returns the address of the machine, on the network it has,
+// then its fixed addresses on the other networks, by network name.
+func (d *Driver) GetAddresses() ([]drivers.Address, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.initCompute(); err != nil {
+		return nil, err
+	}
+
+	addresses, err := d.client.GetInstanceIpAddresses(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return machineAddresses(ip, d.IpVersion, addresses), nil
+}
+
+type byNetwork []drivers.Address
+
+func (a byNetwork) Len() int      { return len(a) }
+func (a byNetwork) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byNetwork) Less(i, j int) bool {
+	if a[i].Network != a[j].Network {
+		return a[i].Network < a[j].Network
+	}
+	return a[i].IP < a[j].IP
+}
+
+// machineAddresses returns the address ip first, then the other fixed
+// addresses of the IP version sorted by network.
+func machineAddresses(ip string, ipVersion int, addresses []IpAddress) []drivers.Address {
+	primary := drivers.Address{Network: drivers.DefaultNetwork, IP: ip}
+	var others []drivers.Address
+	for _, a := range addresses {
+		if a.Address == ip {
+			primary.Network = a.Network
+			continue
+		}
+		if a.AddressType == Fixed && a.Version == ipVersion {
+			others = append(others, drivers.Address{Network: a.Network, IP: a.Address})
+		}
+	}
+
+	sort.Sort(byNetwork(others))
+
+	return append([]drivers.Address{primary}, others...)
+}
+
 func (d *Driver) GetState() (state.State, error) {
 	log.WithField("MachineId", d.MachineId).Debug("Get status for OpenStack instance...")
 	if err := d.initCompute(); err != nil {
@@ -341,6 +494,9 @@ func (d *Driver) Create() error {
 	if err := d.createSSHKey(); err != nil {
 		return err
 	}
+	if err := d.setPortSecurityGroups(); err != nil {
+		return err
+	}
 	if err := d.createMachine(); err != nil {
 		return err
 	}
@@ -390,6 +546,9 @@ func (d *Driver) Remove() error {
 	if err := d.client.DeleteInstance(d); err != nil {
 		return err
 	}
+	if d.BootFromVolume && d.VolumeKeep {
+		log.Info("Keeping the boot volume of the OpenStack instance")
+	}
 	log.WithField("Name", d.KeyPairName).Debug("deleting key pair...")
 	if err := d.client.DeleteKeyPair(d, d.KeyPairName); err != nil {
 		return err
@@ -397,6 +556,58 @@ func (d *Driver) Remove() error {
 	return nil
 }
 
+// OrphanedResources returns the servers with the metadata of machines of the
+// store which aren't one of machines, followed by their key pairs, which have
+// no metadata of their own.
+func (d *Driver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	if err := d.initCompute(); err != nil {
+		return nil, err
+	}
+
+	list, err := d.client.ListInstances(d)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[machine] = true
+	}
+
+	resources := []drivers.OrphanedResource{}
+	keyPairs := []drivers.OrphanedResource{}
+	for _, server := range list {
+		machine, _ := server.Metadata[drivers.TagMachineName].(string)
+		store, _ := server.Metadata[drivers.TagMachineStore].(string)
+		if machine == "" || store != storeID || known[machine] {
+			continue
+		}
+
+		resources = append(resources, drivers.OrphanedResource{Kind: "server", ID: server.ID, Machine: machine})
+		if strings.HasPrefix(server.KeyName, machine+"-") {
+			keyPairs = append(keyPairs, drivers.OrphanedResource{Kind: "key-pair", ID: server.KeyName, Machine: machine})
+		}
+	}
+
+	return append(resources, keyPairs...), nil
+}
+
+// RemoveOrphanedResource removes a resource OrphanedResources returned.
+func (d *Driver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	if err := d.initCompute(); err != nil {
+		return err
+	}
+
+	switch resource.Kind {
+	case "server":
+		return d.client.DeleteServer(d, resource.ID)
+	case "key-pair":
+		return d.client.DeleteKeyPair(d, resource.ID)
+	}
+
+	return fmt.Errorf("Unknown kind of resource %q", resource.Kind)
+}
+
 func (d *Driver) Restart() error {
 	log.WithField("MachineId", d.MachineId).Info("Restarting OpenStack instance...")
 	if err := d.initCompute(); err != nil {
@@ -421,6 +632,10 @@ const (
 	errorUnknownFlavorName       string = "Unable to find flavor named %s"
 	errorUnknownImageName        string = "Unable to find image named %s"
 	errorUnknownNetworkName      string = "Unable to find network named %s"
+	errorUnknownPortName         string = "Unable to find port named %s"
+	errorUnknownSecurityGroup    string = "Unable to find security group named %s"
+	errorPortsWithNovaNetwork    string = "Ports can only be used with neutron, not with --openstack-nova-network"
+	errorVolumeWithoutBoot       string = "%s can only be used with --openstack-boot-from-volume"
 )
 
 func (d *Driver) checkConfig() error {
@@ -454,9 +669,26 @@ func (d *Driver) checkConfig() error {
 	if d.NetworkName != "" && d.NetworkId != "" {
 		return fmt.Errorf(errorExclusiveOptions, "Network name", "Network id")
 	}
+	if d.BootFromVolume && d.VolumeSize <= 0 {
+		return fmt.Errorf(errorMandatoryOption, "Volume size", "--openstack-volume-size")
+	}
+	if !d.BootFromVolume && d.VolumeSize != 0 {
+		return fmt.Errorf(errorVolumeWithoutBoot, "--openstack-volume-size")
+	}
+	if !d.BootFromVolume && d.VolumeType != "" {
+		return fmt.Errorf(errorVolumeWithoutBoot, "--openstack-volume-type")
+	}
+	if len(d.Ports) > 0 && d.ComputeNetwork {
+		return fmt.Errorf(errorPortsWithNovaNetwork)
+	}
 	if d.EndpointType != "" && (d.EndpointType != "publicURL" && d.EndpointType != "adminURL" && d.EndpointType != "internalURL") {
 		return fmt.Errorf(errorWrongEndpointType)
 	}
+	if d.SSHBastion != "" {
+		if _, err := ssh.ParseBastion(d.SSHBastion); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -525,6 +757,68 @@ func (d *Driver) resolveIds() error {
 		}).Debug("Found image id using its name")
 	}
 
+	if len(d.Networks) > 0 {
+		// Nova networks are only given by id.
+		d.NetworkIds = d.Networks
+		if !d.ComputeNetwork {
+			if err := d.initNetwork(); err != nil {
+				return err
+			}
+
+			d.NetworkIds = nil
+			for _, network := range d.Networks {
+				networkId, err := d.client.FindNetworkId(d, network)
+				if err != nil {
+					return err
+				}
+				if networkId == "" {
+					return fmt.Errorf(errorUnknownNetworkName, network)
+				}
+				d.NetworkIds = append(d.NetworkIds, networkId)
+			}
+		}
+		log.WithFields(log.Fields{
+			"Names": d.Networks,
+			"IDs":   d.NetworkIds,
+		}).Debug("Found the ids of the networks to attach to")
+	}
+
+	if len(d.Ports) > 0 {
+		if err := d.initNetwork(); err != nil {
+			return err
+		}
+
+		d.PortIds = nil
+		for _, port := range d.Ports {
+			portId, err := d.client.GetPortId(d, port)
+			if err != nil {
+				return err
+			}
+			if portId == "" {
+				return fmt.Errorf(errorUnknownPortName, port)
+			}
+			d.PortIds = append(d.PortIds, portId)
+		}
+		log.WithFields(log.Fields{
+			"Names": d.Ports,
+			"IDs":   d.PortIds,
+		}).Debug("Found port ids using their names")
+
+		// The security groups of pre-created ports are set through neutron,
+		// which needs their ids.
+		d.SecurityGroupIds = nil
+		for _, securityGroup := range d.SecurityGroups {
+			securityGroupId, err := d.client.GetSecurityGroupId(d, securityGroup)
+			if err != nil {
+				return err
+			}
+			if securityGroupId == "" {
+				return fmt.Errorf(errorUnknownSecurityGroup, securityGroup)
+			}
+			d.SecurityGroupIds = append(d.SecurityGroupIds, securityGroupId)
+		}
+	}
+
 	if d.FloatingIpPool != "" && !d.ComputeNetwork {
 		if err := d.initNetwork(); err != nil {
 			return err
@@ -571,7 +865,7 @@ func (d *Driver) initNetwork() error {
 
 func (d *Driver) createSSHKey() error {
 	log.WithField("Name", d.KeyPairName).Debug("Creating Key Pair...")
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
@@ -588,6 +882,26 @@ func (d *Driver) createSSHKey() error {
 	return nil
 }
 
+func (d *Driver) setPortSecurityGroups() error {
+	if len(d.SecurityGroupIds) == 0 {
+		return nil
+	}
+
+	if err := d.initNetwork(); err != nil {
+		return err
+	}
+	for _, portId := range d.PortIds {
+		log.WithFields(log.Fields{
+			"PortId":         portId,
+			"SecurityGroups": d.SecurityGroupIds,
+		}).Debug("Setting the security groups of the port...")
+		if err := d.client.SetPortSecurityGroups(d, portId, d.SecurityGroupIds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *Driver) createMachine() error {
 	log.WithFields(log.Fields{
 		"FlavorId": d.FlavorId,
@@ -0,0 +1,206 @@
+package proxmox
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// client talks to the REST API of a Proxmox VE cluster.
+type client struct {
+	url  string
+	http *http.Client
+
+	// Either an API token, sent with every request...
+	tokenID     string
+	tokenSecret string
+
+	// ...or the ticket of a user logged in with a password, along with the
+	// token write requests must carry to prevent CSRF.
+	username  string
+	password  string
+	ticket    string
+	csrfToken string
+}
+
+// apiError is an error returned by the Proxmox API.
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Proxmox API error (%d): %s", e.Code, e.Message)
+}
+
+func newClient(host, caCert string, insecure bool) (*client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("Proxmox host must be an https:// URL, got %q", host)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caCert != "" {
+		pem, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the Proxmox CA certificate: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &client{
+		url: strings.TrimSuffix(host, "/") + "/api2/json",
+		http: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   60 * time.Second,
+		},
+	}, nil
+}
+
+// login gets a ticket for the user, unless the client uses an API token.
+func (c *client) login() error {
+	if c.tokenID != "" || c.ticket != "" {
+		return nil
+	}
+
+	auth := struct {
+		Ticket    string `json:"ticket"`
+		CSRFToken string `json:"CSRFPreventionToken"`
+	}{}
+	if err := c.send("POST", "/access/ticket", url.Values{
+		"username": {c.username},
+		"password": {c.password},
+	}, &auth); err != nil {
+		return fmt.Errorf("Error logging in to Proxmox as %s: %s", c.username, err)
+	}
+
+	c.ticket = auth.Ticket
+	c.csrfToken = auth.CSRFToken
+	return nil
+}
+
+// do sends an authenticated request to the API and decodes its data into
+// out when it is not nil.
+func (c *client) do(method, path string, params url.Values, out interface{}) error {
+	if err := c.login(); err != nil {
+		return err
+	}
+	return c.send(method, path, params, out)
+}
+
+func (c *client) send(method, path string, params url.Values, out interface{}) error {
+	var req *http.Request
+	var err error
+
+	if method == "GET" || method == "DELETE" {
+		u := c.url + path
+		if len(params) > 0 {
+			u += "?" + params.Encode()
+		}
+		req, err = http.NewRequest(method, u, nil)
+	} else {
+		req, err = http.NewRequest(method, c.url+path, strings.NewReader(params.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.tokenID != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", c.tokenID, c.tokenSecret))
+	} else if c.ticket != "" {
+		req.AddCookie(&http.Cookie{Name: "PVEAuthCookie", Value: c.ticket})
+		if method != "GET" {
+			req.Header.Set("CSRFPreventionToken", c.csrfToken)
+		}
+	}
+
+	log.Debugf("Proxmox request: %s %s", method, path)
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body := struct {
+		Data   json.RawMessage   `json:"data"`
+		Errors map[string]string `json:"errors"`
+	}{}
+	decodeErr := json.NewDecoder(res.Body).Decode(&body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		message := strings.TrimSpace(strings.TrimPrefix(res.Status, fmt.Sprint(res.StatusCode)))
+		for param, reason := range body.Errors {
+			message += fmt.Sprintf(", %s: %s", param, strings.TrimSpace(reason))
+		}
+		return &apiError{Code: res.StatusCode, Message: message}
+	}
+
+	if decodeErr != nil {
+		return fmt.Errorf("Error decoding Proxmox response to %s %s: %s", method, path, decodeErr)
+	}
+
+	if out != nil && len(body.Data) > 0 && string(body.Data) != "null" {
+		return json.Unmarshal(body.Data, out)
+	}
+
+	return nil
+}
+
+// doTask sends a request starting a task on node and waits for the task to
+// finish.
+func (c *client) doTask(node, method, path string, params url.Values) error {
+	upid := ""
+	if err := c.do(method, path, params, &upid); err != nil {
+		return err
+	}
+	if upid == "" {
+		return nil
+	}
+
+	return c.waitTask(node, upid)
+}
+
+func (c *client) waitTask(node, upid string) error {
+	status := struct {
+		Status     string `json:"status"`
+		ExitStatus string `json:"exitstatus"`
+	}{}
+
+	for {
+		if err := c.do("GET", fmt.Sprintf("/nodes/%s/tasks/%s/status", url.QueryEscape(node), url.QueryEscape(upid)), nil, &status); err != nil {
+			return err
+		}
+
+		if status.Status == "stopped" {
+			break
+		}
+		time.Sleep(taskPollInterval)
+	}
+
+	if status.ExitStatus != "OK" {
+		return fmt.Errorf("Proxmox task %s failed: %s", upid, status.ExitStatus)
+	}
+
+	return nil
+}
+
+// taskPollInterval is how often the status of running tasks is checked.
+var taskPollInterval = 1 * time.Second
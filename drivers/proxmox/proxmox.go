@@ -0,0 +1,647 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultCores    = 1
+	defaultMemory   = 1024
+	defaultDiskSize = 20
+	defaultDisk     = "scsi0"
+	defaultBridge   = "vmbr0"
+	defaultSSHUser  = "docker"
+)
+
+var (
+	ErrMachineNotExist = errors.New("machine does not exist")
+	errNoCredentials   = errors.New("Either a Proxmox username and password or an API token ID and secret are required")
+	errNoTemplate      = errors.New("The VMID of a cloud-init template to clone is required")
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	client      *client
+	Host        string
+	Node        string
+	Username    string
+	Password    string
+	TokenID     string
+	TokenSecret string
+	CACert      string
+	Insecure    bool
+	Template    int
+	LinkedClone bool
+	Storage     string
+	Cores       int
+	Memory      int
+	DiskSize    int
+	Disk        string
+	Bridge      string
+	VLAN        int
+	VMID        int
+}
+
+type agentInterface struct {
+	Name        string `json:"name"`
+	IPAddresses []struct {
+		Type    string `json:"ip-address-type"`
+		Address string `json:"ip-address"`
+	} `json:"ip-addresses"`
+}
+
+// NewDriver creates a new Proxmox VE driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     defaultSSHUser,
+		},
+		Cores:    defaultCores,
+		Memory:   defaultMemory,
+		DiskSize: defaultDiskSize,
+		Disk:     defaultDisk,
+		Bridge:   defaultBridge,
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_HOST",
+			Name:   "proxmox-host",
+			Usage:  "URL of the Proxmox VE API, e.g. https://pve.example.com:8006",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_NODE",
+			Name:   "proxmox-node",
+			Usage:  "Proxmox VE node to create the VM on",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_USERNAME",
+			Name:   "proxmox-username",
+			Usage:  "Proxmox VE user, e.g. root@pam",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_PASSWORD",
+			Name:   "proxmox-password",
+			Usage:  "Password of the Proxmox VE user",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_TOKEN_ID",
+			Name:   "proxmox-token-id",
+			Usage:  "ID of a Proxmox VE API token to use instead of a password, e.g. root@pam!machine",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_TOKEN_SECRET",
+			Name:   "proxmox-token-secret",
+			Usage:  "Secret of the Proxmox VE API token",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_CA_CERT",
+			Name:   "proxmox-ca-cert",
+			Usage:  "Certificate of the authority which signed the certificate of the API",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "PROXMOX_INSECURE",
+			Name:   "proxmox-insecure",
+			Usage:  "Do not verify the certificate of the API",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_TEMPLATE",
+			Name:   "proxmox-template",
+			Usage:  "VMID of the cloud-init template to clone",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "PROXMOX_LINKED_CLONE",
+			Name:   "proxmox-linked-clone",
+			Usage:  "Create a linked clone of the template instead of a full clone",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_STORAGE",
+			Name:   "proxmox-storage",
+			Usage:  "Storage for the disks of a full clone. Defaults to the storage of the template",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_CORES",
+			Name:   "proxmox-cores",
+			Usage:  "Number of CPU cores for the machine",
+			Value:  defaultCores,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_MEMORY",
+			Name:   "proxmox-memory",
+			Usage:  "Size of memory for host in MB",
+			Value:  defaultMemory,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_DISK_SIZE",
+			Name:   "proxmox-disk-size",
+			Usage:  "Size of the disk for host in GB, if larger than the one of the template",
+			Value:  defaultDiskSize,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_DISK",
+			Name:   "proxmox-disk",
+			Usage:  "Disk of the template to resize",
+			Value:  defaultDisk,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_BRIDGE",
+			Name:   "proxmox-bridge",
+			Usage:  "Bridge to attach the network interface of the machine to",
+			Value:  defaultBridge,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "PROXMOX_VLAN",
+			Name:   "proxmox-vlan",
+			Usage:  "VLAN tag of the network interface of the machine",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "PROXMOX_SSH_USER",
+			Name:   "proxmox-ssh-user",
+			Usage:  "User cloud-init creates to log in with over SSH",
+			Value:  defaultSSHUser,
+		},
+	}
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Host = flags.String("proxmox-host")
+	d.Node = flags.String("proxmox-node")
+	d.Username = flags.String("proxmox-username")
+	d.Password = flags.String("proxmox-password")
+	d.TokenID = flags.String("proxmox-token-id")
+	d.TokenSecret = flags.String("proxmox-token-secret")
+	d.CACert = flags.String("proxmox-ca-cert")
+	d.Insecure = flags.Bool("proxmox-insecure")
+	d.Template = flags.Int("proxmox-template")
+	d.LinkedClone = flags.Bool("proxmox-linked-clone")
+	d.Storage = flags.String("proxmox-storage")
+	d.Cores = flags.Int("proxmox-cores")
+	d.Memory = flags.Int("proxmox-memory")
+	d.DiskSize = flags.Int("proxmox-disk-size")
+	d.Disk = flags.String("proxmox-disk")
+	d.Bridge = flags.String("proxmox-bridge")
+	d.VLAN = flags.Int("proxmox-vlan")
+	d.SSHUser = flags.String("proxmox-ssh-user")
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHPort = 22
+
+	if d.Host == "" {
+		return fmt.Errorf("proxmox driver requires the --proxmox-host option")
+	}
+	if d.Node == "" {
+		return fmt.Errorf("proxmox driver requires the --proxmox-node option")
+	}
+	if (d.Username == "" || d.Password == "") && (d.TokenID == "" || d.TokenSecret == "") {
+		return errNoCredentials
+	}
+	if d.Template == 0 {
+		return errNoTemplate
+	}
+
+	return nil
+}
+
+func (d *Driver) DriverName() string {
+	return "proxmox"
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) api() (*client, error) {
+	if d.client == nil {
+		c, err := newClient(d.Host, d.CACert, d.Insecure)
+		if err != nil {
+			return nil, err
+		}
+		c.username = d.Username
+		c.password = d.Password
+		c.tokenID = d.TokenID
+		c.tokenSecret = d.TokenSecret
+		d.client = c
+	}
+	return d.client, nil
+}
+
+func (d *Driver) nodePath(parts ...string) string {
+	return "/nodes/" + url.QueryEscape(d.Node) + "/" + strings.Join(parts, "/")
+}
+
+func (d *Driver) vmPath(parts ...string) string {
+	return d.nodePath(append([]string{"qemu", strconv.Itoa(d.VMID)}, parts...)...)
+}
+
+// PreCreateCheck checks that the API can be reached with the credentials
+// given and that the template exists.
+func (d *Driver) PreCreateCheck() error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	template := struct {
+		Template int `json:"template"`
+	}{}
+	if err := c.do("GET", d.nodePath("qemu", strconv.Itoa(d.Template), "config"), nil, &template); err != nil {
+		return fmt.Errorf("Error reading template %d on node %s: %s", d.Template, d.Node, err)
+	}
+	if template.Template != 1 {
+		return fmt.Errorf("VM %d on node %s is not a template", d.Template, d.Node)
+	}
+
+	return nil
+}
+
+// netConfig returns the configuration of the network interface of the
+// machine.
+func (d *Driver) netConfig() string {
+	net0 := "virtio,bridge=" + d.Bridge
+	if d.VLAN > 0 {
+		net0 += fmt.Sprintf(",tag=%d", d.VLAN)
+	}
+	return net0
+}
+
+// vmConfig returns the configuration the clone of the template gets.
+// cloud-init creates the SSH user with the key of the machine, and the
+// guest agent reports the IP address of the machine.
+func (d *Driver) vmConfig(pubKey string) url.Values {
+	return url.Values{
+		"cores":     {strconv.Itoa(d.Cores)},
+		"memory":    {strconv.Itoa(d.Memory)},
+		"net0":      {d.netConfig()},
+		"ipconfig0": {"ip=dhcp"},
+		"agent":     {"1"},
+		"ciuser":    {d.SSHUser},
+		// The API expects the keys encoded once more, with spaces as %20.
+		"sshkeys": {strings.Replace(url.QueryEscape(pubKey), "+", "%20", -1)},
+	}
+}
+
+func (d *Driver) Create() error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	pubKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return err
+	}
+
+	nextID := ""
+	if err := c.do("GET", "/cluster/nextid", nil, &nextID); err != nil {
+		return err
+	}
+	if d.VMID, err = strconv.Atoi(nextID); err != nil {
+		return fmt.Errorf("Invalid VMID %q from Proxmox: %s", nextID, err)
+	}
+
+	log.Infof("Cloning template %d to VM %d...", d.Template, d.VMID)
+	clone := url.Values{
+		"newid": {strconv.Itoa(d.VMID)},
+		"name":  {d.MachineName},
+	}
+	if !d.LinkedClone {
+		clone.Set("full", "1")
+		if d.Storage != "" {
+			clone.Set("storage", d.Storage)
+		}
+	}
+	if err := c.doTask(d.Node, "POST", d.nodePath("qemu", strconv.Itoa(d.Template), "clone"), clone); err != nil {
+		return err
+	}
+
+	log.Infof("Configuring VM %d...", d.VMID)
+	if err := c.do("POST", d.vmPath("config"), d.vmConfig(strings.TrimSpace(string(pubKey))), nil); err != nil {
+		return err
+	}
+
+	if err := d.resizeDisk(); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+// resizeDisk grows the disk of the machine to DiskSize, unless the disk of
+// the template is larger already.
+func (d *Driver) resizeDisk() error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	config := map[string]interface{}{}
+	if err := c.do("GET", d.vmPath("config"), nil, &config); err != nil {
+		return err
+	}
+
+	disk, _ := config[d.Disk].(string)
+	if disk == "" {
+		return fmt.Errorf("VM %d has no disk %s", d.VMID, d.Disk)
+	}
+
+	if size := diskSizeGB(disk); size >= d.DiskSize {
+		log.Debugf("Disk %s of VM %d is %d GB already", d.Disk, d.VMID, size)
+		return nil
+	}
+
+	log.Infof("Resizing disk %s to %d GB...", d.Disk, d.DiskSize)
+	return c.do("PUT", d.vmPath("resize"), url.Values{
+		"disk": {d.Disk},
+		"size": {fmt.Sprintf("%dG", d.DiskSize)},
+	}, nil)
+}
+
+// diskSizeGB returns the size in GB of a disk from its configuration, e.g.
+// "local-lvm:vm-100-disk-0,size=10G", rounded down.
+func diskSizeGB(disk string) int {
+	for _, option := range strings.Split(disk, ",") {
+		if !strings.HasPrefix(option, "size=") {
+			continue
+		}
+
+		size := strings.TrimPrefix(option, "size=")
+		units := map[string]float64{"K": 1.0 / (1 << 20), "M": 1.0 / (1 << 10), "G": 1, "T": 1 << 10}
+		unit, ok := units[size[len(size)-1:]]
+		if ok {
+			size = size[:len(size)-1]
+		} else {
+			unit = 1.0 / (1 << 30)
+		}
+
+		n, err := strconv.ParseFloat(size, 64)
+		if err != nil {
+			return 0
+		}
+		return int(n * unit)
+	}
+	return 0
+}
+
+func (d *Driver) changeStatus(action string) error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	return c.doTask(d.Node, "POST", d.vmPath("status", action), url.Values{})
+}
+
+func (d *Driver) Start() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Stopped:
+		log.Infof("Starting VM...")
+		if err := d.changeStatus("start"); err != nil {
+			return err
+		}
+	case state.Paused:
+		log.Infof("Resuming VM...")
+		if err := d.changeStatus("resume"); err != nil {
+			return err
+		}
+	default:
+		log.Infof("VM not in restartable state")
+	}
+
+	log.Infof("Waiting for the guest agent to report an IP address...")
+	if err := mcnutils.WaitForSpecific(d.ipAvailable, 60, 3*time.Second); err != nil {
+		return err
+	}
+
+	if d.IPAddress, err = d.GetIP(); err != nil {
+		return err
+	}
+
+	return drivers.WaitForSSH(d)
+}
+
+func (d *Driver) ipAvailable() bool {
+	ip, err := d.GetIP()
+	if err != nil {
+		log.Debugf("No IP address yet: %s", err)
+		return false
+	}
+	return ip != ""
+}
+
+func (d *Driver) Stop() error {
+	if err := d.changeStatus("shutdown"); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+func (d *Driver) Kill() error {
+	if err := d.changeStatus("stop"); err != nil {
+		return err
+	}
+
+	d.IPAddress = ""
+
+	return nil
+}
+
+func (d *Driver) Restart() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running {
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+	return d.Start()
+}
+
+func (d *Driver) Remove() error {
+	if d.VMID == 0 {
+		log.Infof("machine was never cloned, nothing to remove")
+		return nil
+	}
+
+	s, err := d.GetState()
+	if err == ErrMachineNotExist {
+		log.Infof("machine does not exist, assuming it has been removed already")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running || s == state.Paused {
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	return c.doTask(d.Node, "DELETE", d.vmPath(), url.Values{"purge": {"1"}})
+}
+
+// CreateSnapshot takes a snapshot of the VM.
+func (d *Driver) CreateSnapshot(name string) error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	return c.doTask(d.Node, "POST", d.vmPath("snapshot"), url.Values{"snapname": {name}})
+}
+
+// RestoreSnapshot rolls the VM back to a snapshot.
+func (d *Driver) RestoreSnapshot(name string) error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	return c.doTask(d.Node, "POST", d.vmPath("snapshot", url.QueryEscape(name), "rollback"), url.Values{})
+}
+
+// RemoveSnapshot deletes a snapshot of the VM.
+func (d *Driver) RemoveSnapshot(name string) error {
+	c, err := d.api()
+	if err != nil {
+		return err
+	}
+
+	return c.doTask(d.Node, "DELETE", d.vmPath("snapshot", url.QueryEscape(name)), nil)
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	c, err := d.api()
+	if err != nil {
+		return state.Error, err
+	}
+
+	status := struct {
+		Status    string `json:"status"`
+		QMPStatus string `json:"qmpstatus"`
+	}{}
+	if err := c.do("GET", d.vmPath("status", "current"), nil, &status); err != nil {
+		// Proxmox answers with a server error for VMs which do not exist.
+		if e, ok := err.(*apiError); ok && e.Code == http.StatusInternalServerError && strings.Contains(e.Message, "does not exist") {
+			return state.Error, ErrMachineNotExist
+		}
+		return state.Error, err
+	}
+
+	switch status.Status {
+	case "running":
+		if status.QMPStatus == "paused" {
+			return state.Paused, nil
+		}
+		return state.Running, nil
+	case "stopped":
+		return state.Stopped, nil
+	}
+	return state.None, nil
+}
+
+// GetIP returns the first IPv4 address the guest agent reports on an
+// interface other than the loopback.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	c, err := d.api()
+	if err != nil {
+		return "", err
+	}
+
+	result := struct {
+		Result []agentInterface `json:"result"`
+	}{}
+	if err := c.do("GET", d.vmPath("agent", "network-get-interfaces"), nil, &result); err != nil {
+		return "", err
+	}
+
+	ip := agentIP(result.Result)
+	if ip == "" {
+		return "", fmt.Errorf("The guest agent of VM %d reports no IP address", d.VMID)
+	}
+
+	return ip, nil
+}
+
+func agentIP(interfaces []agentInterface) string {
+	for _, iface := range interfaces {
+		// Skip the loopback and the bridges of the engine.
+		if iface.Name == "lo" || iface.Name == "docker0" || strings.HasPrefix(iface.Name, "br-") || strings.HasPrefix(iface.Name, "veth") {
+			continue
+		}
+		for _, addr := range iface.IPAddresses {
+			if addr.Type != "ipv4" {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+				return addr.Address
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProxmox answers requests to the Proxmox API with canned responses
+// keyed by method and path, and records the requests it got.
+type fakeProxmox struct {
+	responses map[string]string
+	requests  []*http.Request
+	forms     map[string]url.Values
+}
+
+func (f *fakeProxmox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	key := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, r)
+	f.forms[key] = r.Form
+
+	resp, ok := f.responses[key]
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"data": null}`)
+		return
+	}
+	fmt.Fprint(w, resp)
+}
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+func newTestDriver(responses map[string]string) (*Driver, *fakeProxmox, func()) {
+	fake := &fakeProxmox{responses: responses, forms: map[string]url.Values{}}
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.Node = "pve"
+	d.VMID = 105
+	d.client = &client{url: server.URL + "/api2/json", http: server.Client(), tokenID: "root@pam!machine", tokenSecret: "secret"}
+
+	return d, fake, server.Close
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "proxmox", NewDriver("default", "").DriverName())
+}
+
+func TestDefaultSSHUsername(t *testing.T) {
+	assert.Equal(t, "docker", NewDriver("default", "").GetSSHUsername())
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		response string
+		state    state.State
+	}{
+		{`{"data": {"status": "running", "qmpstatus": "running"}}`, state.Running},
+		{`{"data": {"status": "running", "qmpstatus": "paused"}}`, state.Paused},
+		{`{"data": {"status": "stopped", "qmpstatus": "stopped"}}`, state.Stopped},
+		{`{"data": {"status": "whatever"}}`, state.None},
+	}
+
+	for _, expected := range tests {
+		d, fake, done := newTestDriver(map[string]string{
+			"GET /api2/json/nodes/pve/qemu/105/status/current": expected.response,
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+		assert.Equal(t, "PVEAPIToken=root@pam!machine=secret", fake.requests[0].Header.Get("Authorization"))
+	}
+}
+
+func TestGetIP(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /api2/json/nodes/pve/qemu/105/status/current": `{"data": {"status": "running"}}`,
+		"GET /api2/json/nodes/pve/qemu/105/agent/network-get-interfaces": `{"data": {"result": [
+			{"name": "lo", "ip-addresses": [{"ip-address-type": "ipv4", "ip-address": "127.0.0.1"}]},
+			{"name": "docker0", "ip-addresses": [{"ip-address-type": "ipv4", "ip-address": "172.17.0.1"}]},
+			{"name": "eth0", "ip-addresses": [
+				{"ip-address-type": "ipv6", "ip-address": "fe80::1"},
+				{"ip-address-type": "ipv4", "ip-address": "192.168.1.50"}
+			]}
+		]}}`,
+	})
+	defer done()
+
+	ip, err := d.GetIP()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.50", ip)
+}
+
+func TestGetIPNotRunning(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /api2/json/nodes/pve/qemu/105/status/current": `{"data": {"status": "stopped"}}`,
+	})
+	defer done()
+
+	ip, err := d.GetIP()
+
+	assert.Empty(t, ip)
+	assert.Equal(t, drivers.ErrHostIsNotRunning, err)
+}
+
+func TestLoginWithPassword(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /api2/json/access/ticket":                    `{"data": {"ticket": "PVE:ticket", "CSRFPreventionToken": "csrf"}}`,
+		"POST /api2/json/nodes/pve/qemu/105/status/stop":   `{"data": "UPID:pve:1"}`,
+		"GET /api2/json/nodes/pve/tasks/UPID:pve:1/status": `{"data": {"status": "stopped", "exitstatus": "OK"}}`,
+	})
+	defer done()
+	d.client.tokenID = ""
+	d.client.username = "root@pam"
+	d.client.password = "hunter2"
+
+	assert.NoError(t, d.Kill())
+
+	assert.Equal(t, 3, len(fake.requests))
+	assert.Equal(t, "root@pam", fake.forms["POST /api2/json/access/ticket"].Get("username"))
+	assert.Equal(t, "hunter2", fake.forms["POST /api2/json/access/ticket"].Get("password"))
+
+	stop := fake.requests[1]
+	cookie, err := stop.Cookie("PVEAuthCookie")
+	assert.NoError(t, err)
+	assert.Equal(t, "PVE:ticket", cookie.Value)
+	assert.Equal(t, "csrf", stop.Header.Get("CSRFPreventionToken"))
+}
+
+func TestTaskFailure(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"POST /api2/json/nodes/pve/qemu/105/status/start":  `{"data": "UPID:pve:2"}`,
+		"GET /api2/json/nodes/pve/tasks/UPID:pve:2/status": `{"data": {"status": "stopped", "exitstatus": "not enough memory"}}`,
+	})
+	defer done()
+
+	assert.EqualError(t, d.changeStatus("start"), "Proxmox task UPID:pve:2 failed: not enough memory")
+}
+
+func TestAPIError(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+
+	_, err := d.GetState()
+
+	assert.EqualError(t, err, "Proxmox API error (500): Internal Server Error")
+}
+
+func TestVMConfig(t *testing.T) {
+	d := NewDriver("default", "")
+	d.Cores = 2
+	d.Memory = 4096
+	d.VLAN = 20
+
+	config := d.vmConfig("ssh-rsa AAAA+/= docker@host")
+
+	assert.Equal(t, "2", config.Get("cores"))
+	assert.Equal(t, "4096", config.Get("memory"))
+	assert.Equal(t, "virtio,bridge=vmbr0,tag=20", config.Get("net0"))
+	assert.Equal(t, "ip=dhcp", config.Get("ipconfig0"))
+	assert.Equal(t, "1", config.Get("agent"))
+	assert.Equal(t, "docker", config.Get("ciuser"))
+	assert.Equal(t, "ssh-rsa%20AAAA%2B%2F%3D%20docker%40host", config.Get("sshkeys"))
+
+	d.VLAN = 0
+	assert.Equal(t, "virtio,bridge=vmbr0", d.netConfig())
+}
+
+func TestDiskSizeGB(t *testing.T) {
+	assert.Equal(t, 10, diskSizeGB("local-lvm:vm-105-disk-0,size=10G"))
+	assert.Equal(t, 2, diskSizeGB("local:105/vm-105-disk-0.qcow2,discard=on,size=2252M"))
+	assert.Equal(t, 1024, diskSizeGB("ceph:vm-105-disk-0,size=1T,ssd=1"))
+	assert.Equal(t, 0, diskSizeGB("local-lvm:vm-105-disk-0"))
+}
+
+func TestSetConfigFromFlagsRequiresCredentials(t *testing.T) {
+	d := NewDriver("default", "")
+	flags := DriverOptionsMock{
+		Data: map[string]interface{}{
+			"proxmox-host":     "https://pve:8006",
+			"proxmox-node":     "pve",
+			"proxmox-username": "root@pam",
+			"proxmox-template": 9000,
+		},
+	}
+
+	assert.Equal(t, errNoCredentials, d.SetConfigFromFlags(flags))
+
+	flags.Data["proxmox-password"] = "hunter2"
+	assert.NoError(t, d.SetConfigFromFlags(flags))
+}
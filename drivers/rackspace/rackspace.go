@@ -93,6 +93,12 @@ func NewDriver(machineName, storePath string) drivers.Driver {
 	}
 }
 
+// SupportsNetworks reports that Rackspace servers are only attached to the
+// public network, whatever the OpenStack driver does.
+func (d *Driver) SupportsNetworks() bool {
+	return false
+}
+
 // DriverName is the user-visible name of this driver.
 func (d *Driver) DriverName() string {
 	return "rackspace"
@@ -120,6 +126,15 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
 
 	if d.Region == "" {
 		return missingEnvOrOption("Region", "OS_REGION_NAME", "--rackspace-region")
@@ -0,0 +1,106 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/docker/machine/libmachine/jsonapi"
+)
+
+const defaultEndpoint = "https://api.scaleway.com/instance/v1/zones"
+
+// client talks to the Scaleway instance API of a zone.
+type client struct {
+	*jsonapi.Client
+}
+
+// apiError is an error returned by the Scaleway API.
+type apiError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Scaleway API error (%s): %s", e.Type, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+type task struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type server struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Volumes map[string]struct {
+		ID string `json:"id"`
+	} `json:"volumes"`
+}
+
+type flexibleIP struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+}
+
+func newClient(zone, token string) *client {
+	return &client{jsonapi.NewClient("Scaleway", fmt.Sprintf("%s/%s", defaultEndpoint, zone), http.Header{"X-Auth-Token": []string{token}}, decodeError)}
+}
+
+// decodeError returns the apiError of a response.
+func decodeError(res *http.Response) error {
+	e := apiError{}
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == "" {
+		e.Type = "unknown"
+		e.Message = res.Status
+	}
+	e.StatusCode = res.StatusCode
+	return &e
+}
+
+// waitTask blocks until the task is done.
+func (c *client) waitTask(t task) error {
+	for t.Status == "pending" || t.Status == "started" {
+		time.Sleep(taskPollInterval)
+
+		resp := struct {
+			Task task `json:"task"`
+		}{}
+		if err := c.Do("GET", "/tasks/"+t.ID, nil, &resp); err != nil {
+			return err
+		}
+		t = resp.Task
+	}
+
+	if t.Status == "failure" {
+		return fmt.Errorf("Scaleway task %s failed", t.ID)
+	}
+
+	return nil
+}
+
+// serverAction runs an action such as "poweron" on a server and waits for
+// it.
+func (c *client) serverAction(id, action string) error {
+	resp := struct {
+		Task task `json:"task"`
+	}{}
+	if err := c.Do("POST", fmt.Sprintf("/servers/%s/action", id), map[string]string{
+		"action": action,
+	}, &resp); err != nil {
+		return err
+	}
+
+	return c.waitTask(resp.Task)
+}
+
+// taskPollInterval is how often the status of running tasks is checked.
+var taskPollInterval = 1 * time.Second
@@ -0,0 +1,535 @@
+package scaleway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultZone           = "fr-par-1"
+	defaultCommercialType = "DEV1-S"
+	defaultImage          = "Ubuntu Xenial"
+	gigabyte              = 1000 * 1000 * 1000
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+type Driver struct {
+	*drivers.BaseDriver
+	client          *client
+	Token           string
+	Project         string
+	Zone            string
+	CommercialType  string
+	Image           string
+	Arch            string
+	ImageID         string
+	RootVolumeSize  int
+	BlockVolumeSize int
+	IP              string
+	IPID            string
+	ReleaseIP       bool
+	Tags            []string
+	ServerID        string
+}
+
+// NewDriver creates a new Scaleway driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Zone:           defaultZone,
+		CommercialType: defaultCommercialType,
+		Image:          defaultImage,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_TOKEN",
+			Name:   "scaleway-token",
+			Usage:  "Scaleway API secret key",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_PROJECT",
+			Name:   "scaleway-project",
+			Usage:  "Scaleway project ID",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_ZONE",
+			Name:   "scaleway-zone",
+			Usage:  "Scaleway zone",
+			Value:  defaultZone,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_COMMERCIAL_TYPE",
+			Name:   "scaleway-commercial-type",
+			Usage:  "Scaleway instance type, x86 or ARM",
+			Value:  defaultCommercialType,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_IMAGE",
+			Name:   "scaleway-image",
+			Usage:  "Name or ID of the Scaleway image. Names are matched against images for the architecture of the instance type",
+			Value:  defaultImage,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SCALEWAY_ROOT_VOLUME_SIZE",
+			Name:   "scaleway-root-volume-size",
+			Usage:  "Size of the root volume in GB. Defaults to the size of the image",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SCALEWAY_BLOCK_VOLUME_SIZE",
+			Name:   "scaleway-block-volume-size",
+			Usage:  "Size in GB of a block storage volume to attach to the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_IP",
+			Name:   "scaleway-ip",
+			Usage:  "ID or address of an existing flexible IP to use. A new one is reserved if not set",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "scaleway-tag",
+			Usage: "Tag of the instance",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SCALEWAY_SSH_USER",
+			Name:   "scaleway-ssh-user",
+			Usage:  "Scaleway SSH username",
+			Value:  "root",
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) DriverName() string {
+	return "scaleway"
+}
+
+// Capabilities adds the flexible IPs of the driver to those of its optional
+// interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.StaticIP = true
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the commercial type of the instance, whose local volume is
+// included, and the block volume created with it.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	pricing := drivers.Pricing{
+		Region:       d.Zone,
+		InstanceType: d.CommercialType,
+	}
+
+	if d.BlockVolumeSize > 0 {
+		pricing.Storage = append(pricing.Storage, drivers.PricedStorage{Type: "b_ssd", Size: d.BlockVolumeSize})
+	}
+
+	return pricing, nil
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Token = flags.String("scaleway-token")
+	d.Project = flags.String("scaleway-project")
+	d.Zone = flags.String("scaleway-zone")
+	d.CommercialType = flags.String("scaleway-commercial-type")
+	d.Image = flags.String("scaleway-image")
+	d.RootVolumeSize = flags.Int("scaleway-root-volume-size")
+	d.BlockVolumeSize = flags.Int("scaleway-block-volume-size")
+	d.IP = flags.String("scaleway-ip")
+	d.Tags = flags.StringSlice("scaleway-tag")
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHUser = flags.String("scaleway-ssh-user")
+	d.SSHPort = 22
+
+	if d.Token == "" {
+		return fmt.Errorf("scaleway driver requires the --scaleway-token option")
+	}
+
+	if d.Project == "" {
+		return fmt.Errorf("scaleway driver requires the --scaleway-project option")
+	}
+
+	if d.RootVolumeSize < 0 || d.BlockVolumeSize < 0 {
+		return fmt.Errorf("scaleway driver requires volume sizes to be positive")
+	}
+
+	return nil
+}
+
+func (d *Driver) getClient() *client {
+	if d.client == nil {
+		d.client = newClient(d.Zone, d.Token)
+	}
+	return d.client
+}
+
+// PreCreateCheck finds the architecture of the instance type, and the image
+// to use for that architecture.
+func (d *Driver) PreCreateCheck() error {
+	arch, err := d.commercialTypeArch()
+	if err != nil {
+		return err
+	}
+	d.Arch = arch
+
+	imageID, err := d.findImage()
+	if err != nil {
+		return err
+	}
+	d.ImageID = imageID
+
+	log.Debugf("Using image %s for %s instance type %s", d.ImageID, d.Arch, d.CommercialType)
+
+	return nil
+}
+
+// commercialTypeArch returns the CPU architecture of the instance type, e.g.
+// "x86_64" or "arm64".
+func (d *Driver) commercialTypeArch() (string, error) {
+	resp := struct {
+		Servers map[string]struct {
+			Arch string `json:"arch"`
+		} `json:"servers"`
+	}{}
+	if err := d.getClient().Do("GET", "/products/servers?per_page=100", nil, &resp); err != nil {
+		return "", err
+	}
+
+	product, ok := resp.Servers[d.CommercialType]
+	if !ok {
+		return "", fmt.Errorf("Scaleway instance type %q is not available in zone %s", d.CommercialType, d.Zone)
+	}
+
+	return product.Arch, nil
+}
+
+// findImage returns the ID of the image, looking it up by name among the
+// images built for the architecture of the instance type if it is not an ID
+// already.
+func (d *Driver) findImage() (string, error) {
+	if uuidRegexp.MatchString(d.Image) {
+		return d.Image, nil
+	}
+
+	resp := struct {
+		Images []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			Arch string `json:"arch"`
+		} `json:"images"`
+	}{}
+	query := url.Values{}
+	query.Set("name", d.Image)
+	query.Set("arch", d.Arch)
+	if err := d.getClient().Do("GET", "/images?"+query.Encode(), nil, &resp); err != nil {
+		return "", err
+	}
+
+	for _, image := range resp.Images {
+		if image.Arch == d.Arch {
+			return image.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("No Scaleway image named %q for the %s architecture", d.Image, d.Arch)
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	if err := d.setupIP(); err != nil {
+		return err
+	}
+
+	log.Infof("Creating Scaleway instance...")
+
+	c := d.getClient()
+
+	resp := struct {
+		Server server `json:"server"`
+	}{}
+	if err := c.Do("POST", "/servers", d.serverRequest(string(publicKey)), &resp); err != nil {
+		return err
+	}
+
+	d.ServerID = resp.Server.ID
+
+	log.Infof("Starting Scaleway instance...")
+
+	if err := c.serverAction(d.ServerID, "poweron"); err != nil {
+		return err
+	}
+
+	for {
+		srv, err := d.getServer()
+		if err != nil {
+			return err
+		}
+
+		if srv.State == "running" {
+			break
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	log.Debugf("Created instance ID %s, IP address %s",
+		d.ServerID,
+		d.IPAddress)
+
+	return nil
+}
+
+// setupIP reserves a new flexible IP for the instance, or finds the one given
+// by ID or address.
+func (d *Driver) setupIP() error {
+	c := d.getClient()
+
+	if d.IP == "" {
+		log.Infof("Reserving flexible IP...")
+
+		resp := struct {
+			IP flexibleIP `json:"ip"`
+		}{}
+		request := map[string]interface{}{
+			"project": d.Project,
+		}
+		if len(d.ResourceTags) > 0 {
+			request["tags"] = drivers.TagList(d.ResourceTags, "=")
+		}
+		if err := c.Do("POST", "/ips", request, &resp); err != nil {
+			return err
+		}
+
+		d.IPID = resp.IP.ID
+		d.IPAddress = resp.IP.Address
+		d.ReleaseIP = true
+		return nil
+	}
+
+	if uuidRegexp.MatchString(d.IP) {
+		resp := struct {
+			IP flexibleIP `json:"ip"`
+		}{}
+		if err := c.Do("GET", "/ips/"+d.IP, nil, &resp); err != nil {
+			return err
+		}
+
+		d.IPID = resp.IP.ID
+		d.IPAddress = resp.IP.Address
+		return nil
+	}
+
+	resp := struct {
+		IPs []flexibleIP `json:"ips"`
+	}{}
+	if err := c.Do("GET", "/ips?per_page=100&project="+url.QueryEscape(d.Project), nil, &resp); err != nil {
+		return err
+	}
+
+	for _, candidate := range resp.IPs {
+		if candidate.Address == d.IP {
+			d.IPID = candidate.ID
+			d.IPAddress = candidate.Address
+			return nil
+		}
+	}
+
+	return fmt.Errorf("No Scaleway flexible IP %q in project %s", d.IP, d.Project)
+}
+
+// serverRequest returns the request creating the instance. The public key
+// is passed in an AUTHORIZED_KEY tag, which Scaleway images add to the
+// authorized keys of root.
+func (d *Driver) serverRequest(publicKey string) map[string]interface{} {
+	tags := append([]string{}, d.Tags...)
+	tags = append(tags, drivers.TagList(d.ResourceTags, "=")...)
+	tags = append(tags, "AUTHORIZED_KEY="+strings.Replace(strings.TrimSpace(publicKey), " ", "_", -1))
+
+	request := map[string]interface{}{
+		"name":                d.MachineName,
+		"project":             d.Project,
+		"commercial_type":     d.CommercialType,
+		"image":               d.ImageID,
+		"public_ip":           d.IPID,
+		"dynamic_ip_required": false,
+		"tags":                tags,
+	}
+
+	volumes := map[string]interface{}{}
+	if d.RootVolumeSize > 0 {
+		volumes["0"] = map[string]interface{}{
+			"size": d.RootVolumeSize * gigabyte,
+		}
+	}
+	if d.BlockVolumeSize > 0 {
+		volumes["1"] = map[string]interface{}{
+			"name":        d.MachineName + "-data",
+			"size":        d.BlockVolumeSize * gigabyte,
+			"volume_type": "b_ssd",
+		}
+	}
+	if len(volumes) > 0 {
+		request["volumes"] = volumes
+	}
+
+	return request
+}
+
+func (d *Driver) getServer() (server, error) {
+	resp := struct {
+		Server server `json:"server"`
+	}{}
+	err := d.getClient().Do("GET", "/servers/"+d.ServerID, nil, &resp)
+	return resp.Server, err
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.IPAddress == "" {
+		return "", fmt.Errorf("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	srv, err := d.getServer()
+	if err != nil {
+		return state.Error, err
+	}
+	switch srv.State {
+	case "starting":
+		return state.Starting, nil
+	case "running":
+		return state.Running, nil
+	case "stopping":
+		return state.Stopping, nil
+	case "stopped", "stopped in place":
+		return state.Stopped, nil
+	case "locked":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	return d.getClient().serverAction(d.ServerID, "poweron")
+}
+
+func (d *Driver) Stop() error {
+	return d.getClient().serverAction(d.ServerID, "poweroff")
+}
+
+// Remove deletes the instance along with its volumes, and releases the
+// flexible IP if it was reserved for this machine.
+func (d *Driver) Remove() error {
+	c := d.getClient()
+
+	if err := d.removeServer(); err != nil {
+		if isNotFound(err) {
+			log.Infof("Scaleway instance doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+
+	if d.ReleaseIP && d.IPID != "" {
+		if err := c.Do("DELETE", "/ips/"+d.IPID, nil, nil); err != nil {
+			if isNotFound(err) {
+				log.Infof("Scaleway flexible IP doesn't exist, assuming it is already released")
+			} else {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) removeServer() error {
+	c := d.getClient()
+
+	srv, err := d.getServer()
+	if err != nil {
+		return err
+	}
+
+	if srv.State != "stopped" {
+		if err := c.serverAction(d.ServerID, "poweroff"); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Do("DELETE", "/servers/"+d.ServerID, nil, nil); err != nil {
+		return err
+	}
+
+	for _, volume := range srv.Volumes {
+		if err := c.Do("DELETE", "/volumes/"+volume.ID, nil, nil); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) Restart() error {
+	return d.getClient().serverAction(d.ServerID, "reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.Stop()
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
@@ -0,0 +1,296 @@
+package scaleway
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+const (
+	serverID = "11111111-1111-1111-1111-111111111111"
+	imageID  = "22222222-2222-2222-2222-222222222222"
+	ipID     = "33333333-3333-3333-3333-333333333333"
+)
+
+func newTestDriver(responses map[string]string) (*Driver, *jsonapitest.API, func()) {
+	fake := jsonapitest.New(responses)
+	fake.Authorized = func(r *http.Request) bool { return r.Header.Get("X-Auth-Token") == "token" }
+	fake.Unauthorized = `{"type": "denied_authentication", "message": "authentication is denied"}`
+	fake.NotFound = `{"type": "not_found", "message": "resource is not found"}`
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.Token = "token"
+	d.Project = "project"
+	d.ServerID = serverID
+	d.client = newClient("", "token")
+	d.client.Endpoint = server.URL
+	d.client.HTTP = server.Client()
+
+	return d, fake, server.Close
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "scaleway", NewDriver("default", "").DriverName())
+}
+
+func TestSetConfigFromFlagsErrors(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{Data: map[string]interface{}{}})
+	assert.EqualError(t, err, "scaleway driver requires the --scaleway-token option")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"scaleway-token": "token",
+		},
+	})
+	assert.EqualError(t, err, "scaleway driver requires the --scaleway-project option")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"scaleway-token":             "token",
+			"scaleway-project":           "project",
+			"scaleway-block-volume-size": -1,
+		},
+	})
+	assert.EqualError(t, err, "scaleway driver requires volume sizes to be positive")
+}
+
+func TestPreCreateCheckPicksImageForArch(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{
+		"GET /products/servers?per_page=100": `{"servers": {
+			"DEV1-S": {"arch": "x86_64"},
+			"AMP2-C1": {"arch": "arm64"}
+		}}`,
+		"GET /images?arch=arm64&name=Ubuntu+Xenial": `{"images": [
+			{"id": "` + imageID + `", "name": "Ubuntu Xenial", "arch": "arm64"}
+		]}`,
+		"GET /images?arch=x86_64&name=Ubuntu+Xenial": `{"images": []}`,
+	})
+	defer done()
+
+	d.CommercialType = "AMP2-C1"
+
+	assert.NoError(t, d.PreCreateCheck())
+	assert.Equal(t, "arm64", d.Arch)
+	assert.Equal(t, imageID, d.ImageID)
+
+	d.CommercialType = "DEV1-S"
+	assert.EqualError(t, d.PreCreateCheck(), `No Scaleway image named "Ubuntu Xenial" for the x86_64 architecture`)
+
+	d.CommercialType = "GP1-XL"
+	assert.EqualError(t, d.PreCreateCheck(), `Scaleway instance type "GP1-XL" is not available in zone fr-par-1`)
+}
+
+func TestPreCreateCheckImageID(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /products/servers?per_page=100": `{"servers": {"DEV1-S": {"arch": "x86_64"}}}`,
+	})
+	defer done()
+
+	d.Image = imageID
+
+	assert.NoError(t, d.PreCreateCheck())
+	assert.Equal(t, imageID, d.ImageID)
+	assert.Equal(t, []string{"GET /products/servers?per_page=100"}, fake.Requests)
+}
+
+func TestServerRequest(t *testing.T) {
+	d := NewDriver("default", "")
+	d.Project = "project"
+	d.ImageID = imageID
+	d.IPID = ipID
+	d.RootVolumeSize = 20
+	d.BlockVolumeSize = 50
+	d.Tags = []string{"env=ci"}
+
+	request := d.serverRequest("ssh-rsa AAAA docker@host\n")
+
+	assert.Equal(t, map[string]interface{}{
+		"name":                "default",
+		"project":             "project",
+		"commercial_type":     "DEV1-S",
+		"image":               imageID,
+		"public_ip":           ipID,
+		"dynamic_ip_required": false,
+		"tags":                []string{"env=ci", "AUTHORIZED_KEY=ssh-rsa_AAAA_docker@host"},
+		"volumes": map[string]interface{}{
+			"0": map[string]interface{}{
+				"size": 20 * gigabyte,
+			},
+			"1": map[string]interface{}{
+				"name":        "default-data",
+				"size":        50 * gigabyte,
+				"volume_type": "b_ssd",
+			},
+		},
+	}, request)
+	assert.Equal(t, []string{"env=ci"}, d.Tags)
+}
+
+func TestSetupIP(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /ips":                             `{"ip": {"id": "` + ipID + `", "address": "51.15.0.10"}}`,
+		"GET /ips?per_page=100&project=project": `{"ips": [{"id": "` + ipID + `", "address": "51.15.0.11"}]}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.setupIP())
+	assert.Equal(t, "51.15.0.10", d.IPAddress)
+	assert.True(t, d.ReleaseIP)
+	assert.Equal(t, map[string]interface{}{"project": "project"}, fake.Bodies["POST /ips"])
+
+	d.ReleaseIP = false
+	d.IP = "51.15.0.11"
+	assert.NoError(t, d.setupIP())
+	assert.Equal(t, ipID, d.IPID)
+	assert.Equal(t, "51.15.0.11", d.IPAddress)
+	assert.False(t, d.ReleaseIP)
+
+	d.IP = "51.15.0.12"
+	assert.EqualError(t, d.setupIP(), `No Scaleway flexible IP "51.15.0.12" in project project`)
+}
+
+func TestSetupIPTags(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /ips": `{"ip": {"id": "` + ipID + `", "address": "51.15.0.10"}}`,
+	})
+	defer done()
+
+	d.ResourceTags = map[string]string{"team": "infra", "machine-name": "default"}
+
+	assert.NoError(t, d.setupIP())
+	assert.Equal(t, map[string]interface{}{
+		"project": "project",
+		"tags":    []interface{}{"machine-name=default", "team=infra"},
+	}, fake.Bodies["POST /ips"])
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		status string
+		state  state.State
+	}{
+		{"starting", state.Starting},
+		{"running", state.Running},
+		{"stopping", state.Stopping},
+		{"stopped", state.Stopped},
+		{"stopped in place", state.Stopped},
+		{"locked", state.Error},
+		{"unknown", state.None},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(map[string]string{
+			"GET /servers/" + serverID: fmt.Sprintf(`{"server": {"id": %q, "state": %q}}`, serverID, expected.status),
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestActions(t *testing.T) {
+	taskPollInterval = 0
+
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /servers/" + serverID + "/action": `{"task": {"id": "t1", "status": "pending"}}`,
+		"GET /tasks/t1":                         `{"task": {"id": "t1", "status": "success"}}`,
+	})
+	defer done()
+
+	assert.NoError(t, d.Start())
+	assert.Equal(t, []string{"POST /servers/" + serverID + "/action", "GET /tasks/t1"}, fake.Requests)
+	assert.Equal(t, map[string]interface{}{"action": "poweron"}, fake.Bodies["POST /servers/"+serverID+"/action"])
+
+	fake.Responses["GET /tasks/t1"] = `{"task": {"id": "t1", "status": "failure"}}`
+	assert.EqualError(t, d.Stop(), "Scaleway task t1 failed")
+}
+
+func TestRemove(t *testing.T) {
+	taskPollInterval = 0
+
+	d, fake, done := newTestDriver(map[string]string{
+		"GET /servers/" + serverID: `{"server": {"id": "` + serverID + `", "state": "running", "volumes": {
+			"0": {"id": "v0"}
+		}}}`,
+		"POST /servers/" + serverID + "/action": `{"task": {"id": "t1", "status": "success"}}`,
+		"DELETE /servers/" + serverID:           ``,
+		"DELETE /volumes/v0":                    ``,
+		"DELETE /ips/" + ipID:                   ``,
+	})
+	defer done()
+
+	d.IPID = ipID
+	d.ReleaseIP = true
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{
+		"GET /servers/" + serverID,
+		"POST /servers/" + serverID + "/action",
+		"DELETE /servers/" + serverID,
+		"DELETE /volumes/v0",
+		"DELETE /ips/" + ipID,
+	}, fake.Requests)
+}
+
+func TestRemoveKeepsGivenIP(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{})
+	defer done()
+
+	d.IPID = ipID
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{"GET /servers/" + serverID}, fake.Requests)
+}
+
+func TestAPIError(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.client.Header = http.Header{"X-Auth-Token": []string{"wrong"}}
+
+	_, err := d.GetState()
+
+	assert.EqualError(t, err, "Scaleway API error (denied_authentication): authentication is denied")
+}
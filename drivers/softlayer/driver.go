@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -219,6 +221,9 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
 	d.SSHUser = "root"
 	d.SSHPort = 22
 
@@ -245,6 +250,17 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		d.deviceConfig.Hostname = d.GetMachineName()
 	}
 
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	for k, v := range tags {
+		if strings.Contains(k+v, ",") {
+			return fmt.Errorf("Invalid --tag %s=%s, SoftLayer tags can't have commas", k, v)
+		}
+	}
+	d.ResourceTags = tags
+
 	return validateDeviceConfig(d.deviceConfig)
 }
 
@@ -256,6 +272,14 @@ func (d *Driver) DriverName() string {
 	return "softlayer"
 }
 
+// Capabilities adds the tags of the virtual guests of the driver to those of
+// its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.Tags = true
+	return capabilities
+}
+
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -393,6 +417,14 @@ func (d *Driver) Create() error {
 		return fmt.Errorf("Error creating host: %q", err)
 	}
 	d.Id = id
+
+	if len(d.ResourceTags) > 0 {
+		log.Infof("Tagging SoftLayer instance %d...", id)
+		if err := d.getClient().VirtualGuest().SetTags(id, drivers.TagList(d.ResourceTags, ":")); err != nil {
+			return err
+		}
+	}
+
 	d.getIp()
 	d.waitForStart()
 	d.waitForSetupTransactions()
@@ -434,7 +466,7 @@ func (d *Driver) buildHostSpec() *HostSpec {
 }
 
 func (d *Driver) createSSHKey() (*SshKey, error) {
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return nil, err
 	}
 
@@ -480,6 +512,55 @@ func (d *Driver) Remove() error {
 
 	return nil
 }
+
+// OrphanedResources returns the virtual guests tagged for machines of the
+// store which aren't one of machines. Their SSH keys can't be tagged, and are
+// left behind.
+func (d *Driver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	guests, err := d.getClient().VirtualGuest().Tagged()
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, machine := range machines {
+		known[machine] = true
+	}
+
+	resources := []drivers.OrphanedResource{}
+	for _, guest := range guests {
+		machine, stored := "", false
+		for _, tag := range guest.Tags {
+			if strings.HasPrefix(tag, drivers.TagMachineName+":") {
+				machine = strings.TrimPrefix(tag, drivers.TagMachineName+":")
+			}
+			if tag == drivers.TagMachineStore+":"+storeID {
+				stored = true
+			}
+		}
+
+		if machine != "" && stored && !known[machine] {
+			resources = append(resources, drivers.OrphanedResource{Kind: "virtual-guest", ID: strconv.Itoa(guest.Id), Machine: machine})
+		}
+	}
+
+	return resources, nil
+}
+
+// RemoveOrphanedResource removes a resource OrphanedResources returned.
+func (d *Driver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	if resource.Kind != "virtual-guest" {
+		return fmt.Errorf("Unknown kind of resource %q", resource.Kind)
+	}
+
+	id, err := strconv.Atoi(resource.ID)
+	if err != nil {
+		return err
+	}
+
+	return d.getClient().VirtualGuest().Cancel(id)
+}
+
 func (d *Driver) Restart() error {
 	return d.getClient().VirtualGuest().Reboot(d.Id)
 }
@@ -1,11 +1,15 @@
 package softlayer
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -101,3 +105,44 @@ func TestHostnameDefaultsToMachineName(t *testing.T) {
 		assert.Equal(t, machineTestName, d.deviceConfig.Hostname)
 	}
 }
+
+func TestSetConfigFromFlagsRejectsTagsWithCommas(t *testing.T) {
+	d := NewDriver(machineTestName, "")
+	flags := getDefaultTestDriverFlags()
+	flags.Data["tag"] = []string{"teams=infra,web"}
+
+	assert.EqualError(t, d.SetConfigFromFlags(flags), "Invalid --tag teams=infra,web, SoftLayer tags can't have commas")
+}
+
+func TestTagsAndOrphanedResources(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+
+		if r.URL.Path == "/SoftLayer_Account/getVirtualGuests.json" {
+			assert.Equal(t, "mask[id,tagReferences[tag[name]]]", r.URL.Query().Get("objectMask"))
+			fmt.Fprint(w, `[
+				{"id": 1, "tagReferences": [{"tag": {"name": "machine-name:web"}}, {"tag": {"name": "machine-store:s1"}}]},
+				{"id": 2, "tagReferences": [{"tag": {"name": "machine-name:db"}}, {"tag": {"name": "machine-store:s1"}}]},
+				{"id": 3, "tagReferences": [{"tag": {"name": "machine-name:old"}}, {"tag": {"name": "machine-store:s2"}}]},
+				{"id": 4}
+			]`)
+		}
+	}))
+	defer server.Close()
+
+	d := NewDriver(machineTestName, "").(*Driver)
+	d.Client = NewClient("user", "key", server.URL)
+
+	assert.NoError(t, d.getClient().VirtualGuest().SetTags(1, []string{"machine-name:web", "team:infra"}))
+	assert.Equal(t, `POST /SoftLayer_Virtual_Guest/1/setTags.json {"parameters":["machine-name:web,team:infra"]}`, requests[0])
+
+	resources, err := d.OrphanedResources("s1", []string{"db"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.OrphanedResource{{Kind: "virtual-guest", ID: "1", Machine: "web"}}, resources)
+
+	assert.NoError(t, d.RemoveOrphanedResource(resources[0]))
+	assert.Equal(t, "DELETE /SoftLayer_Virtual_Guest/1 ", requests[len(requests)-1])
+}
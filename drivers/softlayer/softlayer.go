@@ -55,6 +55,12 @@ type DiskImage struct {
 	Capacity int `json:"capacity"`
 }
 
+// TaggedGuest is a virtual guest of the account with its tags.
+type TaggedGuest struct {
+	Id   int
+	Tags []string
+}
+
 type Datacenter struct {
 	Name string `json:"name"`
 }
@@ -272,6 +278,56 @@ func (c *virtualGuest) Cancel(id int) error {
 	return nil
 }
 
+// SetTags replaces the tags of the virtual guest, which can't have commas.
+func (c *virtualGuest) SetTags(id int, tags []string) error {
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("%s/%v/setTags.json", c.namespace(), id)
+	)
+
+	_, err := c.newRequest(method, uri, map[string]interface{}{"parameters": []interface{}{strings.Join(tags, ",")}})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Tagged returns the virtual guests of the account with their tags.
+func (c *virtualGuest) Tagged() ([]TaggedGuest, error) {
+	var (
+		method = "GET"
+		uri    = "SoftLayer_Account/getVirtualGuests.json?objectMask=mask[id,tagReferences[tag[name]]]"
+	)
+
+	data, err := c.newRequest(method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var guests []struct {
+		Id            int `json:"id"`
+		TagReferences []struct {
+			Tag struct {
+				Name string `json:"name"`
+			} `json:"tag"`
+		} `json:"tagReferences"`
+	}
+	if err := json.Unmarshal(data, &guests); err != nil {
+		return nil, err
+	}
+
+	tagged := []TaggedGuest{}
+	for _, guest := range guests {
+		tags := []string{}
+		for _, ref := range guest.TagReferences {
+			tags = append(tags, ref.Tag.Name)
+		}
+		tagged = append(tagged, TaggedGuest{Id: guest.Id, Tags: tags})
+	}
+
+	return tagged, nil
+}
+
 func (c *virtualGuest) PowerOn(id int) error {
 	var (
 		method = "GET"
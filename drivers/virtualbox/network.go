@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"regexp"
 	"strconv"
@@ -285,3 +286,101 @@ func parseIPv4Mask(s string) net.IPMask {
 	}
 	return net.IPv4Mask(mask[12], mask[13], mask[14], mask[15])
 }
+
+// NAT port forwarding rule, written hostPort:guestPort[/protocol] with an
+// optional host IP in front, like docker run -p.
+type portForward struct {
+	HostIP    string
+	HostPort  int
+	GuestPort int
+	Protocol  string
+}
+
+func parsePortForward(spec string) (*portForward, error) {
+	pf := &portForward{Protocol: "tcp"}
+
+	ports := spec
+	if i := strings.LastIndex(spec, "/"); i != -1 {
+		ports, pf.Protocol = spec[:i], spec[i+1:]
+	}
+	if pf.Protocol != "tcp" && pf.Protocol != "udp" {
+		return nil, fmt.Errorf("Invalid protocol in NAT port forward %q, expected tcp or udp", spec)
+	}
+
+	parts := strings.Split(ports, ":")
+	switch len(parts) {
+	case 2:
+	case 3:
+		pf.HostIP, parts = parts[0], parts[1:]
+		if net.ParseIP(pf.HostIP) == nil {
+			return nil, fmt.Errorf("Invalid host IP in NAT port forward %q", spec)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid NAT port forward %q, expected [hostIP:]hostPort:guestPort[/protocol]", spec)
+	}
+
+	var err error
+	if pf.HostPort, err = parsePort(parts[0]); err != nil {
+		return nil, fmt.Errorf("Invalid host port in NAT port forward %q", spec)
+	}
+	if pf.GuestPort, err = parsePort(parts[1]); err != nil {
+		return nil, fmt.Errorf("Invalid guest port in NAT port forward %q", spec)
+	}
+
+	return pf, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err == nil && (port < 1 || port > 65535) {
+		err = fmt.Errorf("port %d out of range", port)
+	}
+	return port, err
+}
+
+// Name of the rule, unique per host port.
+func (pf *portForward) Name() string {
+	return fmt.Sprintf("%s-%d", pf.Protocol, pf.HostPort)
+}
+
+// Rule returns the rule as written for VBoxManage modifyvm --natpf.
+func (pf *portForward) Rule() string {
+	return fmt.Sprintf("%s,%s,%s,%d,,%d", pf.Name(), pf.Protocol, pf.HostIP, pf.HostPort, pf.GuestPort)
+}
+
+// getHostOnlyNetworkByName returns the host-only network with the given
+// interface name, e.g. vboxnet1.
+func getHostOnlyNetworkByName(nets map[string]*hostOnlyNetwork, name string) *hostOnlyNetwork {
+	for _, n := range nets {
+		if n.Name == name {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// generateMACAddress returns a random MAC address in the range of
+// VirtualBox, as written for VBoxManage modifyvm --macaddress.
+func generateMACAddress() string {
+	return fmt.Sprintf("080027%06X", rand.Intn(1<<24))
+}
+
+// pinHostOnlyIP makes the DHCP server of the host-only network always lease
+// ip to the adapter with the given MAC address. Fixed addresses need
+// VirtualBox 6.1 or later.
+func pinHostOnlyIP(ifname, mac string, ip net.IP) error {
+	return vbm("dhcpserver", "modify",
+		"--netname", "HostInterfaceNetworking-"+ifname,
+		"--mac-address", formatMACAddress(mac),
+		"--fixed-address", ip.String())
+}
+
+// formatMACAddress adds the colons VBoxManage modifyvm leaves out.
+func formatMACAddress(mac string) string {
+	parts := []string{}
+	for i := 0; i+2 <= len(mac); i += 2 {
+		parts = append(parts, mac[i:i+2])
+	}
+	return strings.ToLower(strings.Join(parts, ":"))
+}
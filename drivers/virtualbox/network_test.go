@@ -3,6 +3,7 @@ package virtualbox
 import (
 	"net"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -82,3 +83,74 @@ func TestGetHostOnlyNetworkWindows10Bug(t *testing.T) {
 		t.Fatalf("Expected result of calling getHostOnlyNetwork to be the same as expected but it was not:\nexpected: %+v\nactual: %+v\n", expectedHostOnlyNetwork, n)
 	}
 }
+
+func TestParsePortForward(t *testing.T) {
+	var tests = []struct {
+		spec string
+		pf   portForward
+		rule string
+	}{
+		{"8080:80", portForward{"", 8080, 80, "tcp"}, "tcp-8080,tcp,,8080,,80"},
+		{"5353:53/udp", portForward{"", 5353, 53, "udp"}, "udp-5353,udp,,5353,,53"},
+		{"127.0.0.1:8443:443/tcp", portForward{"127.0.0.1", 8443, 443, "tcp"}, "tcp-8443,tcp,127.0.0.1,8443,,443"},
+	}
+
+	for _, expected := range tests {
+		pf, err := parsePortForward(expected.spec)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", expected.spec, err)
+		}
+		if !reflect.DeepEqual(*pf, expected.pf) {
+			t.Fatalf("Expected %q to be parsed as %+v but got %+v", expected.spec, expected.pf, *pf)
+		}
+		if pf.Rule() != expected.rule {
+			t.Fatalf("Expected rule %q but got %q", expected.rule, pf.Rule())
+		}
+	}
+}
+
+func TestParsePortForwardErrors(t *testing.T) {
+	var tests = []struct {
+		spec string
+		err  string
+	}{
+		{"8080", `Invalid NAT port forward "8080", expected [hostIP:]hostPort:guestPort[/protocol]`},
+		{"8080:80/sctp", `Invalid protocol in NAT port forward "8080:80/sctp", expected tcp or udp`},
+		{"localhost:8080:80", `Invalid host IP in NAT port forward "localhost:8080:80"`},
+		{"http:80", `Invalid host port in NAT port forward "http:80"`},
+		{"8080:70000", `Invalid guest port in NAT port forward "8080:70000"`},
+	}
+
+	for _, expected := range tests {
+		_, err := parsePortForward(expected.spec)
+		if err == nil || err.Error() != expected.err {
+			t.Fatalf("Expected error %q parsing %q but got %v", expected.err, expected.spec, err)
+		}
+	}
+}
+
+func TestGetHostOnlyNetworkByName(t *testing.T) {
+	expectedHostOnlyNetwork := &hostOnlyNetwork{Name: "vboxnet1"}
+	vboxNets := map[string]*hostOnlyNetwork{
+		"HostInterfaceNetworking-vboxnet0": {Name: "vboxnet0"},
+		"HostInterfaceNetworking-vboxnet1": expectedHostOnlyNetwork,
+	}
+
+	if n := getHostOnlyNetworkByName(vboxNets, "vboxnet1"); n != expectedHostOnlyNetwork {
+		t.Fatalf("Expected vboxnet1 to be found but got %+v\n", n)
+	}
+	if n := getHostOnlyNetworkByName(vboxNets, "vboxnet2"); n != nil {
+		t.Fatalf("Expected vboxnet2 not to be found but got %+v\n", n)
+	}
+}
+
+func TestGenerateMACAddress(t *testing.T) {
+	mac := generateMACAddress()
+
+	if !regexp.MustCompile(`^080027[0-9A-F]{6}$`).MatchString(mac) {
+		t.Fatalf("Expected a VirtualBox MAC address but got %q", mac)
+	}
+	if formatted := formatMACAddress("080027AB01CD"); formatted != "08:00:27:ab:01:cd" {
+		t.Fatalf("Expected 08:00:27:ab:01:cd but got %q", formatted)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/docker/machine/libmachine/cloudinit"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
@@ -29,6 +30,8 @@ import (
 
 const (
 	isoFilename                = "boot2docker.iso"
+	seedFilename               = "seed.iso"
+	vdiFilename                = "disk.vdi"
 	defaultCPU                 = 1
 	defaultMemory              = 1024
 	defaultBoot2DockerURL      = ""
@@ -38,6 +41,10 @@ const (
 	defaultHostOnlyPromiscMode = "deny"
 	defaultNoShare             = false
 	defaultDiskSize            = 20000
+
+	// maxNetworks is how many networks a VM is attached to with --network,
+	// on the adapters 3 to 8 after the NAT and host-only ones.
+	maxNetworks = 6
 )
 
 var (
@@ -54,9 +61,17 @@ type Driver struct {
 	DiskSize            int
 	Boot2DockerURL      string
 	Boot2DockerImportVM string
+	// CloudImageURL is the stock cloud image booted instead of boot2docker,
+	// configured by cloud-init.
+	CloudImageURL       string
 	HostOnlyCIDR        string
 	HostOnlyNicType     string
 	HostOnlyPromiscMode string
+	HostOnlyNetwork     string
+	HostOnlyPin         bool
+	HostOnlyMAC         string
+	StaticIP            string
+	NATPortForwards     []string
 	NoShare             bool
 }
 
@@ -105,6 +120,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "The URL of the boot2docker image. Defaults to the latest available version",
 			Value:  defaultBoot2DockerURL,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "VIRTUALBOX_CLOUD_IMAGE_URL",
+			Name:   "virtualbox-cloud-image-url",
+			Usage:  "The URL of a cloud image, e.g. Ubuntu or Fedora, in VMDK, VDI or VHD format to boot instead of boot2docker",
+		},
 		mcnflag.StringFlag{
 			Name:  "virtualbox-import-boot2docker-vm",
 			Usage: "The name of a Boot2Docker VM to import",
@@ -128,6 +148,26 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  defaultHostOnlyPromiscMode,
 			EnvVar: "VIRTUALBOX_HOSTONLY_NIC_PROMISC",
 		},
+		mcnflag.StringFlag{
+			Name:   "virtualbox-hostonly-network",
+			Usage:  "Name of an existing Host Only network interface to use, e.g. vboxnet1",
+			EnvVar: "VIRTUALBOX_HOSTONLY_NETWORK",
+		},
+		mcnflag.BoolFlag{
+			Name:   "virtualbox-hostonly-pin",
+			Usage:  "Keep the same Host Only network, MAC address and IP address across restarts",
+			EnvVar: "VIRTUALBOX_HOSTONLY_PIN",
+		},
+		mcnflag.StringFlag{
+			Name:   "virtualbox-static-ip",
+			Usage:  "IP address of the machine on the Host Only network, kept across restarts",
+			EnvVar: "VIRTUALBOX_STATIC_IP",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "virtualbox-nat-port-forward",
+			Usage: "NAT port forwarding rule, [hostIP:]hostPort:guestPort[/protocol]",
+			Value: []string{},
+		},
 		mcnflag.BoolFlag{
 			Name:  "virtualbox-no-share",
 			Usage: "Disable the mount of your home directory",
@@ -159,7 +199,7 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
@@ -170,12 +210,48 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
 	d.SSHUser = "docker"
 	d.Boot2DockerImportVM = flags.String("virtualbox-import-boot2docker-vm")
+	d.CloudImageURL = flags.String("virtualbox-cloud-image-url")
 	d.HostOnlyCIDR = flags.String("virtualbox-hostonly-cidr")
 	d.HostOnlyNicType = flags.String("virtualbox-hostonly-nictype")
 	d.HostOnlyPromiscMode = flags.String("virtualbox-hostonly-nicpromisc")
+	d.HostOnlyNetwork = flags.String("virtualbox-hostonly-network")
+	d.HostOnlyPin = flags.Bool("virtualbox-hostonly-pin")
+	d.StaticIP = flags.String("virtualbox-static-ip")
+	d.NATPortForwards = flags.StringSlice("virtualbox-nat-port-forward")
 	d.NoShare = flags.Bool("virtualbox-no-share")
+	d.Networks = flags.StringSlice("network")
+
+	if len(d.Networks) > maxNetworks {
+		return fmt.Errorf("virtualbox driver can attach a VM to at most %d networks with --network", maxNetworks)
+	}
+
+	if d.CloudImageURL != "" && d.Boot2DockerImportVM != "" {
+		return fmt.Errorf("virtualbox driver can't import a boot2docker VM with --virtualbox-cloud-image-url")
+	}
+
+	if d.StaticIP != "" {
+		if err := validateStaticIP(d.StaticIP, d.HostOnlyCIDR); err != nil {
+			return err
+		}
+		d.HostOnlyPin = true
+	}
+
+	hostPorts := map[string]bool{}
+	for _, spec := range d.NATPortForwards {
+		pf, err := parsePortForward(spec)
+		if err != nil {
+			return err
+		}
+		if hostPorts[pf.Name()] {
+			return fmt.Errorf("Host port %d/%s is forwarded twice", pf.HostPort, pf.Protocol)
+		}
+		hostPorts[pf.Name()] = true
+	}
 
 	return nil
 }
@@ -228,8 +304,10 @@ func (d *Driver) IsVTXDisabledInTheVM() (bool, error) {
 
 func (d *Driver) Create() error {
 	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
-		return err
+	if d.CloudImageURL == "" {
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+			return err
+		}
 	}
 
 	if d.IsVTXDisabled() {
@@ -242,6 +320,19 @@ func (d *Driver) Create() error {
 
 	log.Infof("Creating VirtualBox VM...")
 
+	// Cloud images find their network interfaces by MAC address.
+	var natMAC string
+	networkMACs := make([]string, len(d.Networks))
+	if d.CloudImageURL != "" {
+		natMAC = generateMACAddress()
+		if d.HostOnlyMAC == "" {
+			d.HostOnlyMAC = generateMACAddress()
+		}
+		for i := range networkMACs {
+			networkMACs[i] = generateMACAddress()
+		}
+	}
+
 	// import b2d VM if requested
 	if d.Boot2DockerImportVM != "" {
 		name := d.Boot2DockerImportVM
@@ -278,13 +369,19 @@ func (d *Driver) Create() error {
 		}
 	} else {
 		log.Infof("Creating SSH key...")
-		if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 			return err
 		}
 
-		log.Debugf("Creating disk image...")
-		if err := d.generateDiskImage(d.DiskSize); err != nil {
-			return err
+		if d.CloudImageURL != "" {
+			if err := d.generateCloudDisk(b2dutils, natMAC, networkMACs); err != nil {
+				return err
+			}
+		} else {
+			log.Debugf("Creating disk image...")
+			if err := d.generateDiskImage(d.DiskSize); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -298,6 +395,12 @@ func (d *Driver) Create() error {
 	log.Debugf("VM CPUS: %d", d.CPU)
 	log.Debugf("VM Memory: %d", d.Memory)
 
+	// The cloud-init seed isn't bootable.
+	bootDevice, dvdPath := "dvd", d.ResolveStorePath(isoFilename)
+	if d.CloudImageURL != "" {
+		bootDevice, dvdPath = "disk", d.ResolveStorePath(seedFilename)
+	}
+
 	cpus := d.CPU
 	if cpus < 1 {
 		cpus = int(runtime.NumCPU())
@@ -328,21 +431,50 @@ func (d *Driver) Create() error {
 		"--largepages", "on",
 		"--vtxvpid", "on",
 		"--accelerate3d", "off",
-		"--boot1", "dvd"); err != nil {
+		"--boot1", bootDevice); err != nil {
 		return err
 	}
 
-	if err := d.vbm("modifyvm", d.MachineName,
+	natArgs := []string{"modifyvm", d.MachineName,
 		"--nic1", "nat",
 		"--nictype1", "82540EM",
-		"--cableconnected1", "on"); err != nil {
+		"--cableconnected1", "on"}
+	if natMAC != "" {
+		natArgs = append(natArgs, "--macaddress1", natMAC)
+	}
+	if err := d.vbm(natArgs...); err != nil {
 		return err
 	}
 
+	for _, spec := range d.NATPortForwards {
+		pf, err := parsePortForward(spec)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Forwarding host port %d/%s to guest port %d", pf.HostPort, pf.Protocol, pf.GuestPort)
+		if err := d.vbm("modifyvm", d.MachineName, "--natpf1", pf.Rule()); err != nil {
+			return err
+		}
+	}
+
+	if d.HostOnlyPin && d.HostOnlyMAC == "" {
+		d.HostOnlyMAC = generateMACAddress()
+	}
+
 	if err := d.setupHostOnlyNetwork(d.MachineName); err != nil {
 		return err
 	}
 
+	if d.StaticIP != "" {
+		if err := d.reserveStaticIP(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.attachNetworks(networkMACs); err != nil {
+		return err
+	}
+
 	if err := d.vbm("storagectl", d.MachineName,
 		"--name", "SATA",
 		"--add", "sata",
@@ -355,7 +487,7 @@ func (d *Driver) Create() error {
 		"--port", "0",
 		"--device", "0",
 		"--type", "dvddrive",
-		"--medium", d.ResolveStorePath("boot2docker.iso")); err != nil {
+		"--medium", dvdPath); err != nil {
 		return err
 	}
 
@@ -387,7 +519,8 @@ func (d *Driver) Create() error {
 		// TODO "linux"
 	}
 
-	if shareDir != "" && !d.NoShare {
+	// Stock cloud images have no guest additions to mount the share.
+	if shareDir != "" && !d.NoShare && d.CloudImageURL == "" {
 		log.Debugf("setting up shareDir")
 		if _, err := os.Stat(shareDir); err != nil && !os.IsNotExist(err) {
 			return err
@@ -442,6 +575,13 @@ func (d *Driver) Start() error {
 		if err := d.setupHostOnlyNetwork(d.MachineName); err != nil {
 			return fmt.Errorf("Error setting up host only network on machine start: %s", err)
 		}
+
+		// A host only network created again has lost the reservation.
+		if d.StaticIP != "" {
+			if err := d.reserveStaticIP(); err != nil {
+				log.Warnf("%s, the machine might get another IP address", err)
+			}
+		}
 	}
 
 	switch s {
@@ -484,8 +624,24 @@ func (d *Driver) Start() error {
 	}
 
 	d.IPAddress, err = d.GetIP()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case d.StaticIP != "":
+		if d.IPAddress != d.StaticIP {
+			log.Warnf("The machine got IP address %s instead of %s, which may be leased to another VM of host only network %s", d.IPAddress, d.StaticIP, d.HostOnlyNetwork)
+		}
+	case d.HostOnlyPin:
+		// The DHCP leases don't survive host reboots, so the address is
+		// reserved for the MAC address of the adapter.
+		if err := pinHostOnlyIP(d.HostOnlyNetwork, d.HostOnlyMAC, net.ParseIP(d.IPAddress)); err != nil {
+			log.Warnf("Unable to reserve IP address %s on host only network %s, it might change when the host reboots: %s", d.IPAddress, d.HostOnlyNetwork, err)
+		}
+	}
 
-	return err
+	return nil
 }
 
 func (d *Driver) Stop() error {
@@ -509,6 +665,131 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+// Suspend saves the state of the VM to disk and stops it.
+func (d *Driver) Suspend() error {
+	return d.vbm("controlvm", d.MachineName, "savestate")
+}
+
+// Resume starts the VM again from the state saved by Suspend.
+func (d *Driver) Resume() error {
+	if err := d.vbm("startvm", d.MachineName, "--type", "headless"); err != nil {
+		return err
+	}
+
+	return drivers.WaitForSSH(d)
+}
+
+// CreateSnapshot takes a live snapshot of the VM.
+func (d *Driver) CreateSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "take", name)
+}
+
+// RestoreSnapshot reverts the VM to a snapshot. VirtualBox can only restore
+// a VM which is not running, so a running VM is powered off first and
+// started again afterwards.
+func (d *Driver) RestoreSnapshot(name string) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running {
+		if err := d.vbm("controlvm", d.MachineName, "poweroff"); err != nil {
+			return err
+		}
+	}
+
+	if err := d.vbm("snapshot", d.MachineName, "restore", name); err != nil {
+		return err
+	}
+
+	if s != state.Running {
+		return nil
+	}
+
+	return d.Resume()
+}
+
+// RemoveSnapshot deletes a snapshot of the VM.
+func (d *Driver) RemoveSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "delete", name)
+}
+
+// Resize changes the CPUs, memory and disk size of the VM, which must be
+// stopped. VirtualBox can only grow VDI disks, so the VMDK disk of boot2docker
+// is converted to VDI the first time it grows.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if opts.InstanceType != "" {
+		return drivers.ErrResizeNoInstanceTypes
+	}
+
+	if opts.DiskSize > 0 && opts.DiskSize < d.DiskSize {
+		return fmt.Errorf("Cannot shrink the disk of %s from %d MB to %d MB", d.MachineName, d.DiskSize, opts.DiskSize)
+	}
+
+	args := []string{"modifyvm", d.MachineName}
+	if opts.CPUs > 0 {
+		args = append(args, "--cpus", strconv.Itoa(opts.CPUs))
+	}
+	if opts.Memory > 0 {
+		args = append(args, "--memory", strconv.Itoa(opts.Memory))
+	}
+	if len(args) > 2 {
+		if err := d.vbm(args...); err != nil {
+			return err
+		}
+	}
+
+	if opts.DiskSize > d.DiskSize {
+		if err := d.resizeDisk(opts.DiskSize); err != nil {
+			return err
+		}
+	}
+
+	if opts.CPUs > 0 {
+		d.CPU = opts.CPUs
+	}
+	if opts.Memory > 0 {
+		d.Memory = opts.Memory
+	}
+	if opts.DiskSize > 0 {
+		d.DiskSize = opts.DiskSize
+	}
+
+	return nil
+}
+
+// resizeDisk grows the disk of the VM to size MB, converting it to VDI
+// first if needed.
+func (d *Driver) resizeDisk(size int) error {
+	disk := d.diskPath()
+	if filepath.Ext(disk) == ".vmdk" {
+		vdi := d.ResolveStorePath(vdiFilename)
+
+		log.Infof("Converting the disk of %s to VDI to resize it...", d.MachineName)
+		if err := d.vbm("clonehd", disk, vdi, "--format", "VDI"); err != nil {
+			return err
+		}
+
+		if err := d.vbm("storageattach", d.MachineName,
+			"--storagectl", "SATA",
+			"--port", "1",
+			"--device", "0",
+			"--type", "hdd",
+			"--medium", vdi); err != nil {
+			return err
+		}
+
+		if err := d.vbm("closemedium", "disk", disk, "--delete"); err != nil {
+			log.Debugf("Error removing %s: %s", disk, err)
+		}
+
+		disk = vdi
+	}
+
+	return d.vbm("modifyhd", disk, "--resize", strconv.Itoa(size))
+}
+
 func (d *Driver) Remove() error {
 	s, err := d.GetState()
 	if err != nil {
@@ -603,14 +884,133 @@ func (d *Driver) GetIP() (string, error) {
 	return "", fmt.Errorf("No IP address found %s", output)
 }
 
+// SupportsNetworks reports that the driver attaches VMs to the host-only
+// networks given with --network.
+func (d *Driver) SupportsNetworks() bool {
+	return true
+}
+
+// GetAddresses returns the addresses of the VM on its host-only network, and
+// on the networks it is attached to with --network which gave it one.
+func (d *Driver) GetAddresses() ([]drivers.Address, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return nil, err
+	}
+	if s != state.Running {
+		return nil, drivers.ErrHostIsNotRunning
+	}
+
+	output, err := drivers.RunSSHCommandFromDriver(d, "ip -4 -o addr show")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("SSH returned: %s\nEND SSH\n", output)
+
+	ips := parseInterfaceAddresses(output)
+	ip, ok := ips["eth1"]
+	if !ok {
+		return nil, fmt.Errorf("No IP address found %s", output)
+	}
+
+	addresses := []drivers.Address{{Network: drivers.DefaultNetwork, IP: ip}}
+	for i, network := range d.Networks {
+		if ip, ok := ips[fmt.Sprintf("eth%d", i+2)]; ok {
+			addresses = append(addresses, drivers.Address{Network: network, IP: ip})
+		}
+	}
+
+	return addresses, nil
+}
+
+// parseInterfaceAddresses returns the first IPv4 address of each interface
+// listed by ip -4 -o addr show, as in:
+// 3: eth1    inet 192.168.99.100/24 brd 192.168.99.255 scope global eth1
+func parseInterfaceAddresses(output string) map[string]string {
+	ips := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "inet" {
+			continue
+		}
+
+		iface := strings.TrimSuffix(fields[1], ":")
+		if _, ok := ips[iface]; ok {
+			continue
+		}
+		ips[iface] = strings.SplitN(fields[3], "/", 2)[0]
+	}
+
+	return ips
+}
+
 func (d *Driver) publicSSHKeyPath() string {
 	return d.GetSSHKeyPath() + ".pub"
 }
 
+// diskPath returns the VDI disk of the VMs booting a cloud image, or whose
+// disk grew, and the VMDK disk of the others.
 func (d *Driver) diskPath() string {
+	vdi := d.ResolveStorePath(vdiFilename)
+	if d.CloudImageURL != "" {
+		return vdi
+	}
+	if _, err := os.Stat(vdi); err == nil {
+		return vdi
+	}
 	return d.ResolveStorePath("disk.vmdk")
 }
 
+// generateCloudDisk makes the disk of the VM from the cloud image, resized
+// to the disk size, and the cloud-init seed letting in the SSH key and
+// naming the NAT and host-only interfaces eth0 and eth1 like boot2docker,
+// and those of the networks eth2 and up.
+func (d *Driver) generateCloudDisk(b2dutils *mcnutils.B2dUtils, natMAC string, networkMACs []string) error {
+	image, err := b2dutils.DownloadImage(d.CloudImageURL, d.ResolveStorePath("."))
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Creating %d MB hard disk image from %s...", d.DiskSize, image)
+	if err := d.vbm("clonehd", image, d.diskPath(), "--format", "VDI"); err != nil {
+		return err
+	}
+
+	// Cloning registered the image, which may be removed or shared by other
+	// machines.
+	if err := d.vbm("closemedium", "disk", image); err != nil {
+		log.Debugf("Error unregistering %s: %s", image, err)
+	}
+	if filepath.Dir(image) == d.ResolveStorePath(".") {
+		os.Remove(image)
+	}
+
+	if err := d.vbm("modifyhd", d.diskPath(), "--resize", strconv.Itoa(d.DiskSize)); err != nil {
+		return err
+	}
+
+	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	interfaces := []cloudinit.Interface{
+		{Name: "eth0", MACAddress: natMAC},
+		{Name: "eth1", MACAddress: d.HostOnlyMAC},
+	}
+	for i, mac := range networkMACs {
+		interfaces = append(interfaces, cloudinit.Interface{Name: fmt.Sprintf("eth%d", i+2), MACAddress: mac})
+	}
+
+	return cloudinit.WriteSeedISO(d.ResolveStorePath(seedFilename), &cloudinit.Config{
+		Hostname:      d.MachineName,
+		User:          d.GetSSHUsername(),
+		AuthorizedKey: string(pubKey),
+		Interfaces:    interfaces,
+	})
+}
+
 // Make a boot2docker VM disk image.
 func (d *Driver) generateDiskImage(size int) error {
 	log.Debugf("Creating %d MB hard disk image...", size)
@@ -683,23 +1083,125 @@ func (d *Driver) setupHostOnlyNetwork(machineName string) error {
 
 	log.Debugf("using %s for dhcp address", dhcpAddr)
 
-	hostOnlyNetwork, err := getOrCreateHostOnlyNetwork(
-		ip,
-		network.Mask,
-		dhcpAddr,
-		lowerDHCPIP,
-		upperDHCPIP,
-	)
-	if err != nil {
-		return err
+	var hostOnlyNetwork *hostOnlyNetwork
+	if d.HostOnlyNetwork != "" {
+		nets, err := listHostOnlyNetworks()
+		if err != nil {
+			return err
+		}
+		hostOnlyNetwork = getHostOnlyNetworkByName(nets, d.HostOnlyNetwork)
+		// A pinned network which went away is created again.
+		if hostOnlyNetwork == nil && !d.HostOnlyPin {
+			return fmt.Errorf("Host only network %s doesn't exist", d.HostOnlyNetwork)
+		}
+	}
+	if hostOnlyNetwork == nil {
+		hostOnlyNetwork, err = getOrCreateHostOnlyNetwork(
+			ip,
+			network.Mask,
+			dhcpAddr,
+			lowerDHCPIP,
+			upperDHCPIP,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.HostOnlyPin {
+		d.HostOnlyNetwork = hostOnlyNetwork.Name
 	}
 
-	return d.vbm("modifyvm", machineName,
+	args := []string{"modifyvm", machineName,
 		"--nic2", "hostonly",
 		"--nictype2", d.HostOnlyNicType,
 		"--nicpromisc2", d.HostOnlyPromiscMode,
 		"--hostonlyadapter2", hostOnlyNetwork.Name,
-		"--cableconnected2", "on")
+		"--cableconnected2", "on"}
+	if d.HostOnlyMAC != "" {
+		args = append(args, "--macaddress2", d.HostOnlyMAC)
+	}
+
+	return d.vbm(args...)
+}
+
+// attachNetworks attaches the VM to the existing host-only networks given
+// with --network, on the adapters after the host-only one, with the given
+// MAC addresses when set.
+func (d *Driver) attachNetworks(macs []string) error {
+	if len(d.Networks) == 0 {
+		return nil
+	}
+
+	nets, err := listHostOnlyNetworks()
+	if err != nil {
+		return err
+	}
+
+	for i, name := range d.Networks {
+		if getHostOnlyNetworkByName(nets, name) == nil {
+			return fmt.Errorf("Host only network %s doesn't exist", name)
+		}
+
+		nic := strconv.Itoa(i + 3)
+		args := []string{"modifyvm", d.MachineName,
+			"--nic" + nic, "hostonly",
+			"--nictype" + nic, d.HostOnlyNicType,
+			"--nicpromisc" + nic, d.HostOnlyPromiscMode,
+			"--hostonlyadapter" + nic, name,
+			"--cableconnected" + nic, "on"}
+		if macs[i] != "" {
+			args = append(args, "--macaddress"+nic, macs[i])
+		}
+
+		if err := d.vbm(args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveStaticIP reserves the static IP address for the MAC address of the
+// host-only adapter on the DHCP server of its network, for the VM to get it
+// from its first boot on.
+func (d *Driver) reserveStaticIP() error {
+	if err := pinHostOnlyIP(d.HostOnlyNetwork, d.HostOnlyMAC, net.ParseIP(d.StaticIP)); err != nil {
+		return fmt.Errorf("Error reserving IP address %s on host only network %s, which needs VirtualBox 6.1 or later: %s", d.StaticIP, d.HostOnlyNetwork, err)
+	}
+	return nil
+}
+
+// validateStaticIP checks the VM can have the static IP address on the
+// host-only network: it is neither the address of the host, nor the network
+// or broadcast one.
+func validateStaticIP(staticIP, hostOnlyCIDR string) error {
+	if hostOnlyCIDR == "" {
+		hostOnlyCIDR = defaultHostOnlyCIDR
+	}
+
+	ip := net.ParseIP(staticIP).To4()
+	if ip == nil {
+		return fmt.Errorf("Invalid --virtualbox-static-ip %q, expected an IPv4 address", staticIP)
+	}
+
+	hostIP, network, err := parseAndValidateCIDR(hostOnlyCIDR)
+	if err != nil {
+		return err
+	}
+	if !network.Contains(ip) {
+		return fmt.Errorf("--virtualbox-static-ip %s is not on the host only network %s", ip, network)
+	}
+
+	broadcast := make(net.IP, net.IPv4len)
+	for i, b := range network.IP.To4() {
+		broadcast[i] = b | ^network.Mask[len(network.Mask)-net.IPv4len+i]
+	}
+	if ip.Equal(hostIP) || ip.Equal(network.IP) || ip.Equal(broadcast) {
+		return fmt.Errorf("--virtualbox-static-ip %s is the address of the host, of the network or its broadcast address", ip)
+	}
+
+	return nil
 }
 
 func parseAndValidateCIDR(hostOnlyCIDR string) (net.IP, *net.IPNet, error) {
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/stretchr/testify/assert"
 )
@@ -177,6 +178,193 @@ func TestInvalidNetworkIpCIDR(t *testing.T) {
 	assert.Nil(t, network)
 }
 
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+func TestSetConfigFromFlagsNetworking(t *testing.T) {
+	driver := newTestDriver("default")
+
+	err := driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"virtualbox-hostonly-network": "vboxnet1",
+			"virtualbox-hostonly-pin":     true,
+			"virtualbox-nat-port-forward": []string{"8080:80", "8080:80/udp"},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "vboxnet1", driver.HostOnlyNetwork)
+	assert.True(t, driver.HostOnlyPin)
+	assert.Equal(t, []string{"8080:80", "8080:80/udp"}, driver.NATPortForwards)
+
+	err = driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"virtualbox-nat-port-forward": []string{"8080:80", "127.0.0.1:8080:8080"},
+		},
+	})
+
+	assert.EqualError(t, err, "Host port 8080/tcp is forwarded twice")
+}
+
+func TestSetConfigFromFlagsStaticIP(t *testing.T) {
+	driver := newTestDriver("default")
+
+	err := driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"virtualbox-static-ip": "192.168.99.50",
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.99.50", driver.StaticIP)
+	assert.True(t, driver.HostOnlyPin, "a static IP pins the host only adapter")
+
+	err = driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"virtualbox-hostonly-cidr": "10.10.0.1/24",
+			"virtualbox-static-ip":     "192.168.99.50",
+		},
+	})
+
+	assert.EqualError(t, err, "--virtualbox-static-ip 192.168.99.50 is not on the host only network 10.10.0.0/24")
+}
+
+func TestValidateStaticIP(t *testing.T) {
+	assert.NoError(t, validateStaticIP("192.168.99.100", "192.168.99.1/24"))
+	assert.EqualError(t, validateStaticIP("192.168.99.1", "192.168.99.1/24"), "--virtualbox-static-ip 192.168.99.1 is the address of the host, of the network or its broadcast address")
+	assert.EqualError(t, validateStaticIP("192.168.99.255", "192.168.99.1/24"), "--virtualbox-static-ip 192.168.99.255 is the address of the host, of the network or its broadcast address")
+	assert.EqualError(t, validateStaticIP("192.168.99.0", ""), "--virtualbox-static-ip 192.168.99.0 is the address of the host, of the network or its broadcast address")
+	assert.EqualError(t, validateStaticIP("dev", "192.168.99.1/24"), `Invalid --virtualbox-static-ip "dev", expected an IPv4 address`)
+}
+
+func TestSetConfigFromFlagsCloudImage(t *testing.T) {
+	driver := newTestDriver("default")
+
+	err := driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"virtualbox-cloud-image-url": "https://cloud-images.ubuntu.com/focal/current/focal-server-cloudimg-amd64.vmdk",
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cloud-images.ubuntu.com/focal/current/focal-server-cloudimg-amd64.vmdk", driver.CloudImageURL)
+	assert.Equal(t, driver.ResolveStorePath("disk.vdi"), driver.diskPath())
+
+	err = driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"virtualbox-cloud-image-url":       "/images/focal.vmdk",
+			"virtualbox-import-boot2docker-vm": "boot2docker-vm",
+		},
+	})
+
+	assert.EqualError(t, err, "virtualbox driver can't import a boot2docker VM with --virtualbox-cloud-image-url")
+}
+
+func TestSetConfigFromFlagsNetworks(t *testing.T) {
+	driver := newTestDriver("default")
+
+	err := driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"network": []string{"vboxnet1", "vboxnet2"},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vboxnet1", "vboxnet2"}, driver.Networks)
+	assert.True(t, driver.SupportsNetworks())
+
+	err = driver.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"network": []string{"vboxnet1", "vboxnet2", "vboxnet3", "vboxnet4", "vboxnet5", "vboxnet6", "vboxnet7"},
+		},
+	})
+
+	assert.EqualError(t, err, "virtualbox driver can attach a VM to at most 6 networks with --network")
+}
+
+func TestParseInterfaceAddresses(t *testing.T) {
+	output := `1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+2: eth0    inet 10.0.2.15/24 brd 10.0.2.255 scope global eth0\       valid_lft forever preferred_lft forever
+3: eth1    inet 192.168.99.100/24 brd 192.168.99.255 scope global eth1\       valid_lft forever preferred_lft forever
+4: eth2    inet 192.168.56.101/24 brd 192.168.56.255 scope global eth2\       valid_lft forever preferred_lft forever
+4: eth2    inet 192.168.56.200/24 scope global secondary eth2\       valid_lft forever preferred_lft forever
+5: docker0    inet 172.17.0.1/16 scope global docker0\       valid_lft forever preferred_lft forever
+`
+
+	assert.Equal(t, map[string]string{
+		"lo":      "127.0.0.1",
+		"eth0":    "10.0.2.15",
+		"eth1":    "192.168.99.100",
+		"eth2":    "192.168.56.101",
+		"docker0": "172.17.0.1",
+	}, parseInterfaceAddresses(output))
+}
+
+type VBoxManagerRecorder struct {
+	VBoxCmdManager
+	commands []string
+}
+
+func (v *VBoxManagerRecorder) vbm(args ...string) error {
+	v.commands = append(v.commands, strings.Join(args, " "))
+	return nil
+}
+
+func TestResize(t *testing.T) {
+	driver := NewDriver("default", "/store")
+	recorder := &VBoxManagerRecorder{}
+	driver.VBoxManager = recorder
+
+	err := driver.Resize(drivers.ResizeOptions{CPUs: 2, Memory: 2048, DiskSize: 40000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"modifyvm default --cpus 2 --memory 2048",
+		"clonehd /store/machines/default/disk.vmdk /store/machines/default/disk.vdi --format VDI",
+		"storageattach default --storagectl SATA --port 1 --device 0 --type hdd --medium /store/machines/default/disk.vdi",
+		"closemedium disk /store/machines/default/disk.vmdk --delete",
+		"modifyhd /store/machines/default/disk.vdi --resize 40000",
+	}, recorder.commands)
+	assert.Equal(t, 2, driver.CPU)
+	assert.Equal(t, 2048, driver.Memory)
+	assert.Equal(t, 40000, driver.DiskSize)
+
+	err = driver.Resize(drivers.ResizeOptions{DiskSize: 30000})
+	assert.EqualError(t, err, "Cannot shrink the disk of default from 40000 MB to 30000 MB")
+
+	err = driver.Resize(drivers.ResizeOptions{InstanceType: "large"})
+	assert.Equal(t, drivers.ErrResizeNoInstanceTypes, err)
+}
+
 func newTestDriver(name string) *Driver {
 	return NewDriver(name, "")
 }
@@ -11,12 +11,16 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/docker/machine/libmachine/cloudinit"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
@@ -31,6 +35,7 @@ const (
 	B2DPass        = "tcuser"
 	isoFilename    = "boot2docker.iso"
 	isoConfigDrive = "configdrive.iso"
+	isoSeed        = "seed.iso"
 )
 
 // Driver for VMware Fusion
@@ -46,6 +51,14 @@ type Driver struct {
 	SSHPassword    string
 	ConfigDriveISO string
 	ConfigDriveURL string
+
+	// CloudImageURL is the stock cloud image booted instead of boot2docker,
+	// configured by cloud-init.
+	CloudImageURL string
+
+	Network       string
+	SharedFolders []string
+	NoShare       bool
 }
 
 const (
@@ -54,6 +67,7 @@ const (
 	defaultDiskSize = 20000
 	defaultCpus     = 1
 	defaultMemory   = 1024
+	defaultNetwork  = "nat"
 )
 
 // GetCreateFlags registers the flags this driver adds to
@@ -72,6 +86,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Fusion URL for cloud-init configdrive",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "FUSION_CLOUD_IMAGE_URL",
+			Name:   "vmwarefusion-cloud-image-url",
+			Usage:  "Fusion URL of a cloud image, e.g. Ubuntu or Fedora, in VMDK format to boot instead of boot2docker",
+			Value:  "",
+		},
 		mcnflag.IntFlag{
 			EnvVar: "FUSION_CPU_COUNT",
 			Name:   "vmwarefusion-cpu-count",
@@ -102,6 +122,22 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "SSH password",
 			Value:  defaultSSHPass,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "FUSION_NETWORK",
+			Name:   "vmwarefusion-network",
+			Usage:  "Fusion network of the VM: nat, bridged, hostonly or a custom vmnet, e.g. vmnet2",
+			Value:  defaultNetwork,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "vmwarefusion-share-folder",
+			Usage: "Fusion folder to share with the VM, hostPath[:guestPath]",
+			Value: []string{},
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "FUSION_NO_SHARE",
+			Name:   "vmwarefusion-no-share",
+			Usage:  "Disable the share of /Users",
+		},
 	}
 }
 
@@ -111,6 +147,7 @@ func NewDriver(hostName, storePath string) drivers.Driver {
 		Memory:      defaultMemory,
 		DiskSize:    defaultDiskSize,
 		SSHPassword: defaultSSHPass,
+		Network:     defaultNetwork,
 		BaseDriver: &drivers.BaseDriver{
 			SSHUser:     defaultSSHUser,
 			MachineName: hostName,
@@ -135,6 +172,14 @@ func (d *Driver) DriverName() string {
 	return "vmwarefusion"
 }
 
+// Capabilities adds the cloud-init configdrives of the driver to those of
+// its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.UserData = true
+	return capabilities
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.Memory = flags.Int("vmwarefusion-memory-size")
 	d.CPU = flags.Int("vmwarefusion-cpu-count")
@@ -143,12 +188,42 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.ConfigDriveURL = flags.String("vmwarefusion-configdrive-url")
 	d.ISO = d.ResolveStorePath(isoFilename)
 	d.ConfigDriveISO = d.ResolveStorePath(isoConfigDrive)
+	d.CloudImageURL = flags.String("vmwarefusion-cloud-image-url")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
 	d.SSHUser = flags.String("vmwarefusion-ssh-user")
 	d.SSHPassword = flags.String("vmwarefusion-ssh-password")
 	d.SSHPort = 22
+	d.Network = flags.String("vmwarefusion-network")
+	d.SharedFolders = flags.StringSlice("vmwarefusion-share-folder")
+	d.NoShare = flags.Bool("vmwarefusion-no-share")
+
+	// The user data of the VMs is the config drive they boot with.
+	if flags.String("user-data") != "" {
+		return fmt.Errorf("vmwarefusion driver takes the user data of the VM as a config drive, with --vmwarefusion-configdrive-url, instead of --user-data")
+	}
+
+	if d.CloudImageURL != "" {
+		if d.ConfigDriveURL != "" {
+			return fmt.Errorf("vmwarefusion driver can't use --vmwarefusion-configdrive-url with --vmwarefusion-cloud-image-url")
+		}
+		// The VM boots from its disk, with the cloud-init seed in the
+		// CD-ROM drive.
+		d.ISO = d.ResolveStorePath(isoSeed)
+	}
+
+	if _, _, err := parseNetwork(d.Network); err != nil {
+		return err
+	}
+	for _, spec := range d.SharedFolders {
+		if _, err := parseSharedFolder(spec); err != nil {
+			return err
+		}
+	}
 
 	// We support a maximum of 16 cpu to be consistent with Virtual Hardware 10
 	// specs.
@@ -170,7 +245,7 @@ func (d *Driver) GetURL() (string, error) {
 	if ip == "" {
 		return "", nil
 	}
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
 func (d *Driver) GetIP() (string, error) {
@@ -182,7 +257,7 @@ func (d *Driver) GetIP() (string, error) {
 		return "", drivers.ErrHostIsNotRunning
 	}
 
-	ip, err := d.getIPfromDHCPLease()
+	ip, err := d.getIPAddress()
 	if err != nil {
 		return "", err
 	}
@@ -195,13 +270,21 @@ func (d *Driver) GetState() (state.State, error) {
 	if stdout, _, _ := vmrun("list"); strings.Contains(stdout, d.vmxPath()) {
 		return state.Running, nil
 	}
+
+	// A suspended VM keeps its memory in a .vmss file next to the .vmx
+	if vmss, _ := filepath.Glob(d.ResolveStorePath("*.vmss")); len(vmss) > 0 {
+		return state.Saved, nil
+	}
+
 	return state.Stopped, nil
 }
 
 func (d *Driver) Create() error {
 	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
-		return err
+	if d.CloudImageURL == "" {
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, d.MachineName); err != nil {
+			return err
+		}
 	}
 
 	// download cloud-init config drive
@@ -213,7 +296,7 @@ func (d *Driver) Create() error {
 	}
 
 	log.Infof("Creating SSH key...")
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
@@ -226,13 +309,18 @@ func (d *Driver) Create() error {
 		return ErrMachineExist
 	}
 
+	connectionType, vnet, err := parseNetwork(d.Network)
+	if err != nil {
+		return err
+	}
+
 	// Generate vmx config file from template
 	vmxt := template.Must(template.New("vmx").Parse(vmx))
 	vmxfile, err := os.Create(d.vmxPath())
 	if err != nil {
 		return err
 	}
-	vmxt.Execute(vmxfile, d)
+	vmxt.Execute(vmxfile, vmxConfig{d, connectionType, vnet})
 
 	// Generate vmdk file
 	diskImg := d.ResolveStorePath(fmt.Sprintf("%s.vmdk", d.MachineName))
@@ -241,7 +329,11 @@ func (d *Driver) Create() error {
 			return err
 		}
 
-		if err := vdiskmanager(diskImg, d.DiskSize); err != nil {
+		if d.CloudImageURL != "" {
+			if err := d.generateCloudDisk(b2dutils, diskImg); err != nil {
+				return err
+			}
+		} else if err := vdiskmanager(diskImg, d.DiskSize); err != nil {
 			return err
 		}
 	}
@@ -253,7 +345,7 @@ func (d *Driver) Create() error {
 
 	log.Infof("Waiting for VM to come online...")
 	for i := 1; i <= 60; i++ {
-		ip, err = d.getIPfromDHCPLease()
+		ip, err = d.getIPAddress()
 		if err != nil {
 			log.Debugf("Not there yet %d/%d, error: %s", i, 60, err)
 			time.Sleep(2 * time.Second)
@@ -280,6 +372,13 @@ func (d *Driver) Create() error {
 	// we got an IP, let's copy ssh keys over
 	d.IPAddress = ip
 
+	// cloud-init let in the SSH key, and the rest of the configuration is
+	// boot2docker specific.
+	if d.CloudImageURL != "" {
+		log.Debugf("Leaving create sequence early, cloud image found")
+		return nil
+	}
+
 	// Do not execute the rest of boot2docker specific configuration
 	// The uplaod of the public ssh key uses a ssh connection,
 	// this works without installed vmware client tools
@@ -335,54 +434,66 @@ func (d *Driver) Create() error {
 	// Enable Shared Folders
 	vmrun("-gu", B2DUser, "-gp", B2DPass, "enableSharedFolders", d.vmxPath())
 
-	var shareName, shareDir string // TODO configurable at some point
-	switch runtime.GOOS {
-	case "darwin":
-		shareName = "Users"
-		shareDir = "/Users"
-		// TODO "linux" and "windows"
+	shares, err := d.sharedFolders()
+	if err != nil {
+		return err
 	}
 
-	if shareDir != "" {
-		if _, err := os.Stat(shareDir); err != nil && !os.IsNotExist(err) {
-			return err
-		} else if !os.IsNotExist(err) {
-			// add shared folder, create mountpoint and mount it.
-			vmrun("-gu", B2DUser, "-gp", B2DPass, "addSharedFolder", d.vmxPath(), shareName, shareDir)
-			command := "[ ! -d " + shareDir + " ]&& sudo mkdir " + shareDir + "; [ -f /usr/local/bin/vmhgfs-fuse ]&& sudo /usr/local/bin/vmhgfs-fuse -o allow_other .host:/" + shareName + " " + shareDir + " || sudo mount -t vmhgfs .host:/" + shareName + " " + shareDir
-			vmrun("-gu", B2DUser, "-gp", B2DPass, "runScriptInGuest", d.vmxPath(), "/bin/sh", command)
-		}
+	for _, share := range shares {
+		// add shared folder, create mountpoint and mount it.
+		vmrun("-gu", B2DUser, "-gp", B2DPass, "addSharedFolder", d.vmxPath(), share.Name, share.HostPath)
+		vmrun("-gu", B2DUser, "-gp", B2DPass, "runScriptInGuest", d.vmxPath(), "/bin/sh", share.mountCommand())
 	}
 	return nil
 }
 
+// generateCloudDisk makes the disk of the VM at diskImg from the cloud
+// image, and the cloud-init seed letting in the SSH key.
+func (d *Driver) generateCloudDisk(b2dutils *mcnutils.B2dUtils, diskImg string) error {
+	image, err := b2dutils.DownloadImage(d.CloudImageURL, d.ResolveStorePath("."))
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating %d MB disk from %s...", d.DiskSize, image)
+	if err := vdiskmanagerClone(image, diskImg, d.DiskSize); err != nil {
+		return err
+	}
+	if filepath.Dir(image) == d.ResolveStorePath(".") {
+		os.Remove(image)
+	}
+
+	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	return cloudinit.WriteSeedISO(d.ISO, &cloudinit.Config{
+		Hostname:      d.MachineName,
+		User:          d.GetSSHUsername(),
+		AuthorizedKey: string(pubKey),
+	})
+}
+
 func (d *Driver) Start() error {
 	log.Infof("Starting %s...", d.MachineName)
 	vmrun("start", d.vmxPath(), "nogui")
 
 	// Do not execute the rest of boot2docker specific configuration, exit here
-	if d.ConfigDriveURL != "" {
-		log.Debugf("Leaving start sequence early, configdrive found")
+	if d.ConfigDriveURL != "" || d.CloudImageURL != "" {
+		log.Debugf("Leaving start sequence early, configdrive or cloud image found")
 		return nil
 	}
 
 	log.Debugf("Mounting Shared Folders...")
-	var shareName, shareDir string // TODO configurable at some point
-	switch runtime.GOOS {
-	case "darwin":
-		shareName = "Users"
-		shareDir = "/Users"
-		// TODO "linux" and "windows"
+	shares, err := d.sharedFolders()
+	if err != nil {
+		return err
 	}
 
-	if shareDir != "" {
-		if _, err := os.Stat(shareDir); err != nil && !os.IsNotExist(err) {
-			return err
-		} else if !os.IsNotExist(err) {
-			// create mountpoint and mount shared folder
-			command := "[ ! -d " + shareDir + " ]&& sudo mkdir " + shareDir + "; [ -f /usr/local/bin/vmhgfs-fuse ]&& sudo /usr/local/bin/vmhgfs-fuse -o allow_other .host:/" + shareName + " " + shareDir + " || sudo mount -t vmhgfs .host:/" + shareName + " " + shareDir
-			vmrun("-gu", B2DUser, "-gp", B2DPass, "runScriptInGuest", d.vmxPath(), "/bin/sh", command)
-		}
+	for _, share := range shares {
+		// create mountpoint and mount shared folder
+		vmrun("-gu", B2DUser, "-gp", B2DPass, "runScriptInGuest", d.vmxPath(), "/bin/sh", share.mountCommand())
 	}
 
 	return nil
@@ -394,6 +505,120 @@ func (d *Driver) Stop() error {
 	return nil
 }
 
+// Suspend saves the state of the VM to disk and stops it.
+func (d *Driver) Suspend() error {
+	log.Infof("Suspending %s...", d.MachineName)
+	_, _, err := vmrun("suspend", d.vmxPath())
+	return err
+}
+
+// Resume starts the VM again from the state saved by Suspend.
+func (d *Driver) Resume() error {
+	log.Infof("Resuming %s...", d.MachineName)
+	_, _, err := vmrun("start", d.vmxPath(), "nogui")
+	return err
+}
+
+// CreateSnapshot takes a snapshot of the VM.
+func (d *Driver) CreateSnapshot(name string) error {
+	log.Infof("Taking snapshot %s of %s...", name, d.MachineName)
+	_, _, err := vmrun("snapshot", d.vmxPath(), name)
+	return err
+}
+
+// RestoreSnapshot reverts the VM to a snapshot.
+func (d *Driver) RestoreSnapshot(name string) error {
+	log.Infof("Restoring snapshot %s of %s...", name, d.MachineName)
+	_, _, err := vmrun("revertToSnapshot", d.vmxPath(), name)
+	return err
+}
+
+// RemoveSnapshot deletes a snapshot of the VM.
+func (d *Driver) RemoveSnapshot(name string) error {
+	log.Infof("Removing snapshot %s of %s...", name, d.MachineName)
+	_, _, err := vmrun("deleteSnapshot", d.vmxPath(), name)
+	return err
+}
+
+// Resize changes the CPUs, memory and disk size of the VM, which must be
+// stopped, in its vmx file and its vmdk disk.
+func (d *Driver) Resize(opts drivers.ResizeOptions) error {
+	if opts.InstanceType != "" {
+		return drivers.ErrResizeNoInstanceTypes
+	}
+
+	if opts.CPUs > 16 {
+		return fmt.Errorf("Cannot resize %s to %d CPUs, the maximum is 16", d.MachineName, opts.CPUs)
+	}
+
+	if opts.DiskSize > 0 && opts.DiskSize < d.DiskSize {
+		return fmt.Errorf("Cannot shrink the disk of %s from %d MB to %d MB", d.MachineName, d.DiskSize, opts.DiskSize)
+	}
+
+	values := map[string]string{}
+	if opts.CPUs > 0 {
+		values["numvcpus"] = strconv.Itoa(opts.CPUs)
+	}
+	if opts.Memory > 0 {
+		values["memsize"] = strconv.Itoa(opts.Memory)
+	}
+	if len(values) > 0 {
+		content, err := ioutil.ReadFile(d.vmxPath())
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(d.vmxPath(), []byte(setVmxValues(string(content), values)), 0644); err != nil {
+			return err
+		}
+	}
+
+	if opts.DiskSize > d.DiskSize {
+		if err := runVdiskmanager("-x", fmt.Sprintf("%dMB", opts.DiskSize), d.vmdkPath()); err != nil {
+			return fmt.Errorf("Error growing %s to %d MB: %s", d.vmdkPath(), opts.DiskSize, err)
+		}
+	}
+
+	if opts.CPUs > 0 {
+		d.CPU = opts.CPUs
+	}
+	if opts.Memory > 0 {
+		d.Memory = opts.Memory
+	}
+	if opts.DiskSize > 0 {
+		d.DiskSize = opts.DiskSize
+	}
+
+	return nil
+}
+
+// setVmxValues returns the content of a vmx file with the values of keys
+// replaced, or added when missing.
+func setVmxValues(content string, values map[string]string) string {
+	set := map[string]bool{}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, line := range lines {
+		key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+		if value, ok := values[key]; ok {
+			lines[i] = fmt.Sprintf("%s = %q", key, value)
+			set[key] = true
+		}
+	}
+
+	keys := []string{}
+	for key := range values {
+		if !set[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s = %q", key, values[key]))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
 func (d *Driver) Remove() error {
 
 	s, _ := d.GetState()
@@ -431,7 +656,31 @@ func (d *Driver) vmdkPath() string {
 	return d.ResolveStorePath(fmt.Sprintf("%s.vmdk", d.MachineName))
 }
 
-func (d *Driver) getIPfromDHCPLease() (string, error) {
+// getIPAddress returns the IP address of the VM, from the DHCP leases of
+// the vmnet it is on or, as bridged networks have no VMware DHCP server, from
+// the VMware tools of the guest.
+func (d *Driver) getIPAddress() (string, error) {
+	connectionType, vnet, err := parseNetwork(d.Network)
+	if err != nil {
+		return "", err
+	}
+
+	if connectionType == "bridged" {
+		stdout, _, err := vmrun("getGuestIPAddress", d.vmxPath())
+		if err != nil {
+			return "", err
+		}
+		ip := strings.TrimSpace(stdout)
+		if net.ParseIP(ip) == nil {
+			return "", fmt.Errorf("IP not found for the VM: %s", ip)
+		}
+		return ip, nil
+	}
+
+	return d.getIPfromDHCPLease(fmt.Sprintf("/var/db/vmware/vmnet-dhcpd-%s.leases", vnet))
+}
+
+func (d *Driver) getIPfromDHCPLease(dhcpfile string) (string, error) {
 	var vmxfh *os.File
 	var dhcpfh *os.File
 	var vmxcontent []byte
@@ -443,9 +692,6 @@ func (d *Driver) getIPfromDHCPLease() (string, error) {
 	var lastleaseendtime time.Time
 	var currentleadeendtime time.Time
 
-	// DHCP lease table for NAT vmnet interface
-	var dhcpfile = "/var/db/vmware/vmnet-dhcpd-vmnet8.leases"
-
 	if vmxfh, err = os.Open(d.vmxPath()); err != nil {
 		return "", err
 	}
@@ -585,13 +831,13 @@ func executeSSHCommand(command string, d *Driver) error {
 
 	client, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", d.IPAddress, d.SSHPort), config)
 	if err != nil {
-		log.Debugf("Failed to dial:", err)
+		log.Debugf("Failed to dial: %s", err)
 		return err
 	}
 
 	session, err := client.NewSession()
 	if err != nil {
-		log.Debugf("Failed to create session: " + err.Error())
+		log.Debugf("Failed to create session: %s", err)
 		return err
 	}
 	defer session.Close()
@@ -600,10 +846,91 @@ func executeSSHCommand(command string, d *Driver) error {
 	session.Stdout = &b
 
 	if err := session.Run(command); err != nil {
-		log.Debugf("Failed to run: " + err.Error())
+		log.Debugf("Failed to run: %s", err)
 		return err
 	}
 	log.Debugf("Stdout from executeSSHCommand: %s", b.String())
 
 	return nil
 }
+
+// vmxConfig is what the vmx template is executed with.
+type vmxConfig struct {
+	*Driver
+	ConnectionType string
+	VNet           string
+}
+
+// parseNetwork returns the connection type of the network of the VM, and the
+// vmnet it is on, if any.
+func parseNetwork(network string) (string, string, error) {
+	switch network {
+	case "", "nat":
+		return "nat", "vmnet8", nil
+	case "hostonly":
+		return "hostonly", "vmnet1", nil
+	case "bridged":
+		return "bridged", "", nil
+	}
+
+	if regexp.MustCompile(`^vmnet[0-9]+$`).MatchString(network) {
+		return "custom", network, nil
+	}
+
+	return "", "", fmt.Errorf("Invalid --vmwarefusion-network %q, expected nat, bridged, hostonly or a vmnet", network)
+}
+
+// A folder of the host shared with the VM.
+type sharedFolder struct {
+	Name      string
+	HostPath  string
+	GuestPath string
+}
+
+// parseSharedFolder parses a shared folder written hostPath[:guestPath]. The
+// guest path defaults to the host path.
+func parseSharedFolder(spec string) (*sharedFolder, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	share := &sharedFolder{HostPath: filepath.Clean(parts[0]), GuestPath: parts[0]}
+	if len(parts) == 2 {
+		share.GuestPath = parts[1]
+	}
+
+	if !filepath.IsAbs(share.HostPath) || !strings.HasPrefix(share.GuestPath, "/") {
+		return nil, fmt.Errorf("Invalid shared folder %q, expected absolute paths hostPath[:guestPath]", spec)
+	}
+	share.GuestPath = filepath.Clean(share.GuestPath)
+	share.Name = strings.Replace(strings.Trim(share.HostPath, "/"), "/", "-", -1)
+
+	return share, nil
+}
+
+// sharedFolders returns the folders shared with the VM: /Users on OS X,
+// unless disabled, and the ones of --vmwarefusion-share-folder.
+func (d *Driver) sharedFolders() ([]*sharedFolder, error) {
+	shares := []*sharedFolder{}
+	if runtime.GOOS == "darwin" && !d.NoShare {
+		if _, err := os.Stat("/Users"); err == nil {
+			shares = append(shares, &sharedFolder{Name: "Users", HostPath: "/Users", GuestPath: "/Users"})
+		}
+	}
+
+	for _, spec := range d.SharedFolders {
+		share, err := parseSharedFolder(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(share.HostPath); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+// mountCommand returns the command creating the mountpoint of the shared
+// folder in the guest and mounting it.
+func (s *sharedFolder) mountCommand() string {
+	return "[ ! -d " + s.GuestPath + " ]&& sudo mkdir -p " + s.GuestPath + "; [ -f /usr/local/bin/vmhgfs-fuse ]&& sudo /usr/local/bin/vmhgfs-fuse -o allow_other .host:/" + s.Name + " " + s.GuestPath + " || sudo mount -t vmhgfs .host:/" + s.Name + " " + s.GuestPath
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2014 VMware, Inc.  All rights reserved.  Licensed under the Apache v2 License.
+ */
+
+package vmwarefusion
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNetwork(t *testing.T) {
+	var tests = []struct {
+		network        string
+		connectionType string
+		vnet           string
+	}{
+		{"nat", "nat", "vmnet8"},
+		{"hostonly", "hostonly", "vmnet1"},
+		{"bridged", "bridged", ""},
+		{"vmnet2", "custom", "vmnet2"},
+	}
+
+	for _, expected := range tests {
+		connectionType, vnet, err := parseNetwork(expected.network)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.connectionType, connectionType)
+		assert.Equal(t, expected.vnet, vnet)
+	}
+
+	_, _, err := parseNetwork("wifi")
+	assert.EqualError(t, err, `Invalid --vmwarefusion-network "wifi", expected nat, bridged, hostonly or a vmnet`)
+}
+
+func TestParseSharedFolder(t *testing.T) {
+	share, err := parseSharedFolder("/Users/dev/src")
+	assert.NoError(t, err)
+	assert.Equal(t, &sharedFolder{Name: "Users-dev-src", HostPath: "/Users/dev/src", GuestPath: "/Users/dev/src"}, share)
+
+	share, err = parseSharedFolder("/Users/dev/src/:/src")
+	assert.NoError(t, err)
+	assert.Equal(t, &sharedFolder{Name: "Users-dev-src", HostPath: "/Users/dev/src", GuestPath: "/src"}, share)
+
+	_, err = parseSharedFolder("src:/src")
+	assert.EqualError(t, err, `Invalid shared folder "src:/src", expected absolute paths hostPath[:guestPath]`)
+}
+
+func TestSharedFolders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fusion-share")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := NewDriver("default", "").(*Driver)
+	d.NoShare = true
+	d.SharedFolders = []string{dir + ":/src"}
+
+	shares, err := d.sharedFolders()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(shares))
+	assert.Equal(t, "/src", shares[0].GuestPath)
+	assert.Contains(t, shares[0].mountCommand(), "sudo mount -t vmhgfs .host:/"+shares[0].Name+" /src")
+
+	d.SharedFolders = []string{dir + "/missing"}
+	_, err = d.sharedFolders()
+	assert.Error(t, err)
+}
+
+func TestVmxNetwork(t *testing.T) {
+	d := NewDriver("default", "").(*Driver)
+
+	var buf bytes.Buffer
+	err := template.Must(template.New("vmx").Parse(vmx)).Execute(&buf, vmxConfig{d, "custom", "vmnet2"})
+
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(buf.String(), "ethernet0.connectionType = \"custom\"\n"))
+	assert.True(t, strings.Contains(buf.String(), "ethernet0.vnet = \"vmnet2\"\n"))
+}
+
+func TestSetVmxValues(t *testing.T) {
+	content := `displayName = "default"
+memsize = "1024"
+numvcpus = "1"
+`
+
+	assert.Equal(t, `displayName = "default"
+memsize = "4096"
+numvcpus = "2"
+`, setVmxValues(content, map[string]string{"numvcpus": "2", "memsize": "4096"}))
+
+	assert.Equal(t, `displayName = "default"
+memsize = "1024"
+numvcpus = "1"
+mem.hotadd = "TRUE"
+`, setVmxValues(content, map[string]string{"mem.hotadd": "TRUE"}))
+}
@@ -72,3 +72,36 @@ func vdiskmanager(dest string, size int) error {
 	}
 	return nil
 }
+
+// Make a vmdk disk image from the disk image at src, grown to the given size
+// (in MB).
+func vdiskmanagerClone(src, dest string, size int) error {
+	if err := runVdiskmanager("-r", src, "-t", "0", dest); err != nil {
+		return fmt.Errorf("Error converting %s: %s", src, err)
+	}
+
+	if err := runVdiskmanager("-x", fmt.Sprintf("%dMB", size), dest); err != nil {
+		return fmt.Errorf("Error growing %s to %d MB: %s", dest, size, err)
+	}
+
+	return nil
+}
+
+func runVdiskmanager(args ...string) error {
+	cmd := exec.Command(vdiskmanbin, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	log.Debugf("executing: %v %v", vdiskmanbin, strings.Join(args, " "))
+
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee == exec.ErrNotFound {
+			return ErrVMRUNNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -9,8 +9,10 @@ const vmx = `
 config.version = "8"
 displayName = "{{.MachineName}}"
 ethernet0.present = "TRUE"
-ethernet0.connectionType = "nat"
-ethernet0.virtualDev = "vmxnet3"
+ethernet0.connectionType = "{{.ConnectionType}}"
+{{ if eq .ConnectionType "custom" }}
+ethernet0.vnet = "{{.VNet}}"
+{{ end }}ethernet0.virtualDev = "vmxnet3"
 ethernet0.wakeOnPcktRcv = "FALSE"
 ethernet0.addressType = "generated"
 ethernet0.linkStatePropagation.enable = "TRUE"
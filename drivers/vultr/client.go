@@ -0,0 +1,55 @@
+package vultr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/machine/libmachine/jsonapi"
+)
+
+const defaultEndpoint = "https://api.vultr.com/v2"
+
+// client talks to the Vultr API.
+type client struct {
+	*jsonapi.Client
+}
+
+// apiError is an error returned by the Vultr API.
+type apiError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Vultr API error (%d): %s", e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+type instance struct {
+	ID           string `json:"id"`
+	Label        string `json:"label"`
+	MainIP       string `json:"main_ip"`
+	InternalIP   string `json:"internal_ip"`
+	Status       string `json:"status"`
+	PowerStatus  string `json:"power_status"`
+	ServerStatus string `json:"server_status"`
+}
+
+func newClient(token string) *client {
+	return &client{jsonapi.NewClient("Vultr", defaultEndpoint, jsonapi.BearerToken(token), decodeError)}
+}
+
+// decodeError returns the apiError of a response.
+func decodeError(res *http.Response) error {
+	e := apiError{}
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == "" {
+		e.Message = res.Status
+	}
+	e.StatusCode = res.StatusCode
+	return &e
+}
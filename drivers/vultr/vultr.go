@@ -0,0 +1,553 @@
+package vultr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultRegion = "ewr"
+	defaultPlan   = "vc2-1c-1gb"
+	defaultOS     = "Ubuntu 16.04 x64"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	client        *client
+	APIKey        string
+	Region        string
+	Plan          string
+	OS            string
+	OSID          int
+	ReservedIP    string
+	ReservedIPID  string
+	VPCs          []string
+	VPCIDs        []string
+	StartupScript string
+	ScriptID      string
+	UserDataFile  string
+	Tags          []string
+	InstanceID    string
+	SSHKeyID      string
+}
+
+// NewDriver creates a new Vultr driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Region: defaultRegion,
+		Plan:   defaultPlan,
+		OS:     defaultOS,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_API_KEY",
+			Name:   "vultr-api-key",
+			Usage:  "Vultr API key",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_REGION",
+			Name:   "vultr-region",
+			Usage:  "Vultr region",
+			Value:  defaultRegion,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_PLAN",
+			Name:   "vultr-plan",
+			Usage:  "Vultr plan",
+			Value:  defaultPlan,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_OS",
+			Name:   "vultr-os",
+			Usage:  "Name or ID of the Vultr operating system",
+			Value:  defaultOS,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_RESERVED_IP",
+			Name:   "vultr-reserved-ip",
+			Usage:  "ID or address of a reserved IP to attach to the instance",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "vultr-vpc",
+			Usage: "Description or ID of a VPC to attach the instance to",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_STARTUP_SCRIPT",
+			Name:   "vultr-startup-script",
+			Usage:  "Path of a script to run when the instance boots",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "vultr-tag",
+			Usage: "Tag of the instance",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_SSH_USER",
+			Name:   "vultr-ssh-user",
+			Usage:  "Vultr SSH username",
+			Value:  "root",
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) DriverName() string {
+	return "vultr"
+}
+
+// Capabilities adds the reserved IPs, startup scripts and user data of the
+// driver to those of its optional interfaces.
+func (d *Driver) Capabilities() drivers.Capabilities {
+	capabilities := drivers.ProbeCapabilities(d)
+	capabilities.StaticIP = true
+	capabilities.UserData = true
+	capabilities.Tags = true
+	return capabilities
+}
+
+// Pricing returns the plan of the instance, whose disk is included.
+func (d *Driver) Pricing() (drivers.Pricing, error) {
+	return drivers.Pricing{
+		Region:       d.Region,
+		InstanceType: d.Plan,
+	}, nil
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.APIKey = flags.String("vultr-api-key")
+	d.Region = flags.String("vultr-region")
+	d.Plan = flags.String("vultr-plan")
+	d.OS = flags.String("vultr-os")
+	d.ReservedIP = flags.String("vultr-reserved-ip")
+	d.VPCs = flags.StringSlice("vultr-vpc")
+	d.StartupScript = flags.String("vultr-startup-script")
+	d.UserDataFile = flags.String("user-data")
+	d.Tags = flags.StringSlice("vultr-tag")
+	tags, err := drivers.ParseResourceTags(flags, d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ResourceTags = tags
+	d.SwarmMaster = flags.Bool("swarm-master")
+	d.SwarmHost = flags.String("swarm-host")
+	d.SwarmDiscovery = flags.String("swarm-discovery")
+	d.SSHBastion = flags.String("ssh-bastion")
+	d.SSHBastionKey = flags.String("ssh-bastion-key")
+	d.SSHKeyType = flags.String("ssh-key-type")
+	d.SSHUser = flags.String("vultr-ssh-user")
+	d.SSHPort = 22
+
+	if d.APIKey == "" {
+		return fmt.Errorf("vultr driver requires the --vultr-api-key option")
+	}
+
+	return nil
+}
+
+func (d *Driver) getClient() *client {
+	if d.client == nil {
+		d.client = newClient(d.APIKey)
+	}
+	return d.client
+}
+
+// PreCreateCheck checks that the plan is available in the region, and
+// resolves the operating system, the reserved IP and the VPCs to their IDs.
+func (d *Driver) PreCreateCheck() error {
+	if d.StartupScript != "" {
+		if _, err := ioutil.ReadFile(d.StartupScript); err != nil {
+			return fmt.Errorf("Cannot read the Vultr startup script: %s", err)
+		}
+	}
+
+	if err := d.checkPlan(); err != nil {
+		return err
+	}
+
+	osID, err := d.findOS()
+	if err != nil {
+		return err
+	}
+	d.OSID = osID
+
+	if d.ReservedIP != "" {
+		id, err := d.findReservedIP()
+		if err != nil {
+			return err
+		}
+		d.ReservedIPID = id
+	}
+
+	d.VPCIDs = []string{}
+	for _, vpc := range d.VPCs {
+		id, err := d.findVPC(vpc)
+		if err != nil {
+			return err
+		}
+		d.VPCIDs = append(d.VPCIDs, id)
+	}
+
+	return nil
+}
+
+func (d *Driver) checkPlan() error {
+	resp := struct {
+		Plans []struct {
+			ID        string   `json:"id"`
+			Locations []string `json:"locations"`
+		} `json:"plans"`
+	}{}
+	if err := d.getClient().Do("GET", "/plans?per_page=500", nil, &resp); err != nil {
+		return err
+	}
+
+	for _, plan := range resp.Plans {
+		if plan.ID != d.Plan {
+			continue
+		}
+		for _, location := range plan.Locations {
+			if location == d.Region {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("Vultr plan %q is not available in region %s", d.Plan, d.Region)
+}
+
+// findOS returns the ID of the operating system, looking it up by name if it
+// is not an ID already.
+func (d *Driver) findOS() (int, error) {
+	if id, err := strconv.Atoi(d.OS); err == nil {
+		return id, nil
+	}
+
+	resp := struct {
+		OS []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"os"`
+	}{}
+	if err := d.getClient().Do("GET", "/os?per_page=500", nil, &resp); err != nil {
+		return 0, err
+	}
+
+	for _, os := range resp.OS {
+		if os.Name == d.OS {
+			return os.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No Vultr operating system named %q", d.OS)
+}
+
+// findReservedIP returns the ID of the reserved IP given by ID or address,
+// which must be in the region of the instance.
+func (d *Driver) findReservedIP() (string, error) {
+	resp := struct {
+		ReservedIPs []struct {
+			ID     string `json:"id"`
+			Subnet string `json:"subnet"`
+			Region string `json:"region"`
+		} `json:"reserved_ips"`
+	}{}
+	if err := d.getClient().Do("GET", "/reserved-ips?per_page=500", nil, &resp); err != nil {
+		return "", err
+	}
+
+	for _, ip := range resp.ReservedIPs {
+		if ip.ID != d.ReservedIP && ip.Subnet != d.ReservedIP {
+			continue
+		}
+		if ip.Region != d.Region {
+			return "", fmt.Errorf("Vultr reserved IP %q is in region %s, not %s", d.ReservedIP, ip.Region, d.Region)
+		}
+		return ip.ID, nil
+	}
+
+	return "", fmt.Errorf("No Vultr reserved IP %q", d.ReservedIP)
+}
+
+// findVPC returns the ID of the VPC given by description or ID, which must
+// be in the region of the instance.
+func (d *Driver) findVPC(nameOrID string) (string, error) {
+	resp := struct {
+		VPCs []struct {
+			ID          string `json:"id"`
+			Description string `json:"description"`
+			Region      string `json:"region"`
+		} `json:"vpcs"`
+	}{}
+	if err := d.getClient().Do("GET", "/vpcs?per_page=500", nil, &resp); err != nil {
+		return "", err
+	}
+
+	for _, vpc := range resp.VPCs {
+		if vpc.ID != nameOrID && vpc.Description != nameOrID {
+			continue
+		}
+		if vpc.Region != d.Region {
+			return "", fmt.Errorf("Vultr VPC %q is in region %s, not %s", nameOrID, vpc.Region, d.Region)
+		}
+		return vpc.ID, nil
+	}
+
+	return "", fmt.Errorf("No Vultr VPC %q", nameOrID)
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+
+	if err := d.createSSHKey(); err != nil {
+		return err
+	}
+
+	if d.StartupScript != "" {
+		log.Infof("Uploading startup script...")
+
+		if err := d.createStartupScript(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Creating Vultr instance...")
+
+	resp := struct {
+		Instance instance `json:"instance"`
+	}{}
+	request := d.instanceRequest()
+	if d.UserDataFile != "" {
+		userData, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		request["user_data"] = base64.StdEncoding.EncodeToString(userData)
+	}
+
+	if err := d.getClient().Do("POST", "/instances", request, &resp); err != nil {
+		return err
+	}
+
+	d.InstanceID = resp.Instance.ID
+
+	log.Info("Waiting for the instance to be running...")
+	for {
+		inst, err := d.getInstance()
+		if err != nil {
+			return err
+		}
+
+		if inst.Status == "active" && inst.PowerStatus == "running" && inst.MainIP != "0.0.0.0" {
+			d.IPAddress = inst.MainIP
+			break
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	log.Debugf("Created instance ID %s, IP address %s",
+		d.InstanceID,
+		d.IPAddress)
+
+	return nil
+}
+
+// instanceRequest returns the request creating the instance.
+func (d *Driver) instanceRequest() map[string]interface{} {
+	request := map[string]interface{}{
+		"label":     d.MachineName,
+		"hostname":  d.MachineName,
+		"region":    d.Region,
+		"plan":      d.Plan,
+		"os_id":     d.OSID,
+		"sshkey_id": []string{d.SSHKeyID},
+		"tags":      append(append([]string{}, d.Tags...), drivers.TagList(d.ResourceTags, "=")...),
+	}
+
+	if d.ReservedIPID != "" {
+		request["reserved_ipv4"] = d.ReservedIPID
+	}
+
+	if len(d.VPCIDs) > 0 {
+		request["attach_vpc"] = d.VPCIDs
+	}
+
+	if d.ScriptID != "" {
+		request["script_id"] = d.ScriptID
+	}
+
+	return request
+}
+
+func (d *Driver) createSSHKey() error {
+	if err := ssh.GenerateSSHKeyOfType(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	resp := struct {
+		SSHKey struct {
+			ID string `json:"id"`
+		} `json:"ssh_key"`
+	}{}
+	if err := d.getClient().Do("POST", "/ssh-keys", map[string]string{
+		"name":    fmt.Sprintf("%s-%d", d.MachineName, time.Now().Unix()),
+		"ssh_key": string(publicKey),
+	}, &resp); err != nil {
+		return err
+	}
+
+	d.SSHKeyID = resp.SSHKey.ID
+	return nil
+}
+
+// createStartupScript uploads the startup script, to be run by the instance
+// when it boots.
+func (d *Driver) createStartupScript() error {
+	script, err := ioutil.ReadFile(d.StartupScript)
+	if err != nil {
+		return err
+	}
+
+	resp := struct {
+		StartupScript struct {
+			ID string `json:"id"`
+		} `json:"startup_script"`
+	}{}
+	if err := d.getClient().Do("POST", "/startup-scripts", map[string]string{
+		"name":   fmt.Sprintf("%s-%d", d.MachineName, time.Now().Unix()),
+		"type":   "boot",
+		"script": base64.StdEncoding.EncodeToString(script),
+	}, &resp); err != nil {
+		return err
+	}
+
+	d.ScriptID = resp.StartupScript.ID
+	return nil
+}
+
+func (d *Driver) getInstance() (instance, error) {
+	resp := struct {
+		Instance instance `json:"instance"`
+	}{}
+	err := d.getClient().Do("GET", "/instances/"+d.InstanceID, nil, &resp)
+	return resp.Instance, err
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.IPAddress == "" {
+		return "", fmt.Errorf("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	inst, err := d.getInstance()
+	if err != nil {
+		return state.Error, err
+	}
+	switch inst.Status {
+	case "pending", "resizing":
+		return state.Starting, nil
+	case "suspended":
+		return state.Error, nil
+	case "active":
+		switch inst.PowerStatus {
+		case "running":
+			return state.Running, nil
+		case "stopped":
+			return state.Stopped, nil
+		}
+	}
+	return state.None, nil
+}
+
+func (d *Driver) instanceAction(name string) error {
+	return d.getClient().Do("POST", fmt.Sprintf("/instances/%s/%s", d.InstanceID, name), nil, nil)
+}
+
+func (d *Driver) Start() error {
+	return d.instanceAction("start")
+}
+
+func (d *Driver) Stop() error {
+	return d.instanceAction("halt")
+}
+
+// Remove deletes the instance, along with the SSH key and startup script
+// uploaded for it. A reserved IP is left in place, and can be attached to
+// another instance.
+func (d *Driver) Remove() error {
+	c := d.getClient()
+	if err := c.Do("DELETE", "/instances/"+d.InstanceID, nil, nil); err != nil {
+		if isNotFound(err) {
+			log.Infof("Vultr instance doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	if err := c.Do("DELETE", "/ssh-keys/"+d.SSHKeyID, nil, nil); err != nil {
+		if isNotFound(err) {
+			log.Infof("Vultr SSH key doesn't exist, assuming it is already deleted")
+		} else {
+			return err
+		}
+	}
+	if d.ScriptID != "" {
+		if err := c.Do("DELETE", "/startup-scripts/"+d.ScriptID, nil, nil); err != nil {
+			if isNotFound(err) {
+				log.Infof("Vultr startup script doesn't exist, assuming it is already deleted")
+			} else {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Driver) Restart() error {
+	return d.instanceAction("reboot")
+}
+
+func (d *Driver) Kill() error {
+	return d.instanceAction("halt")
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
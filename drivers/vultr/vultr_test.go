@@ -0,0 +1,282 @@
+package vultr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/jsonapi"
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type DriverOptionsMock struct {
+	Data map[string]interface{}
+}
+
+func (d DriverOptionsMock) String(key string) string {
+	if value, ok := d.Data[key]; ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if value, ok := d.Data[key]; ok {
+		return value.([]string)
+	}
+	return []string{}
+}
+
+func (d DriverOptionsMock) Int(key string) int {
+	if value, ok := d.Data[key]; ok {
+		return value.(int)
+	}
+	return 0
+}
+
+func (d DriverOptionsMock) Bool(key string) bool {
+	if value, ok := d.Data[key]; ok {
+		return value.(bool)
+	}
+	return false
+}
+
+const (
+	serverID = "11111111-1111-1111-1111-111111111111"
+	imageID  = "22222222-2222-2222-2222-222222222222"
+	ipID     = "33333333-3333-3333-3333-333333333333"
+)
+
+func newTestDriver(responses map[string]string) (*Driver, *jsonapitest.API, func()) {
+	fake := jsonapitest.New(responses)
+	fake.Authorized = func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer key" }
+	fake.Unauthorized = `{"error": "Invalid API token.", "status": 401}`
+	fake.NotFound = `{"error": "Not found", "status": 404}`
+	server := httptest.NewServer(fake)
+
+	d := NewDriver("default", "/store")
+	d.APIKey = "key"
+	d.InstanceID = "inst"
+	d.client = newClient("key")
+	d.client.Endpoint = server.URL
+	d.client.HTTP = server.Client()
+
+	return d, fake, server.Close
+}
+
+var lookups = map[string]string{
+	"GET /plans?per_page=500": `{"plans": [
+		{"id": "vc2-1c-1gb", "locations": ["ewr", "ams"]},
+		{"id": "vhf-2c-4gb", "locations": ["ams"]}
+	]}`,
+	"GET /os?per_page=500": `{"os": [
+		{"id": 215, "name": "Ubuntu 16.04 x64"},
+		{"id": 387, "name": "Ubuntu 20.04 x64"}
+	]}`,
+	"GET /reserved-ips?per_page=500": `{"reserved_ips": [
+		{"id": "rip-1", "subnet": "192.0.2.10", "region": "ewr"},
+		{"id": "rip-2", "subnet": "192.0.2.11", "region": "ams"}
+	]}`,
+	"GET /vpcs?per_page=500": `{"vpcs": [
+		{"id": "vpc-1", "description": "backend", "region": "ewr"},
+		{"id": "vpc-2", "description": "frontend", "region": "ams"},
+		{"id": "vpc-3", "description": "cache", "region": "ewr"}
+	]}`,
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "vultr", NewDriver("default", "").DriverName())
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	d := NewDriver("default", "")
+
+	err := d.SetConfigFromFlags(DriverOptionsMock{Data: map[string]interface{}{}})
+	assert.EqualError(t, err, "vultr driver requires the --vultr-api-key option")
+
+	err = d.SetConfigFromFlags(DriverOptionsMock{
+		Data: map[string]interface{}{
+			"vultr-api-key":  "key",
+			"vultr-vpc":      []string{"backend"},
+			"vultr-ssh-user": "root",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backend"}, d.VPCs)
+	assert.Equal(t, "root", d.GetSSHUsername())
+}
+
+func TestPreCreateCheck(t *testing.T) {
+	d, _, done := newTestDriver(lookups)
+	defer done()
+
+	d.OS = "Ubuntu 20.04 x64"
+	d.ReservedIP = "192.0.2.10"
+	d.VPCs = []string{"backend", "vpc-3"}
+
+	assert.NoError(t, d.PreCreateCheck())
+	assert.Equal(t, 387, d.OSID)
+	assert.Equal(t, "rip-1", d.ReservedIPID)
+	assert.Equal(t, []string{"vpc-1", "vpc-3"}, d.VPCIDs)
+}
+
+func TestPreCreateCheckErrors(t *testing.T) {
+	var tests = []struct {
+		configure func(d *Driver)
+		err       string
+	}{
+		{func(d *Driver) { d.Plan = "vhf-2c-4gb" }, `Vultr plan "vhf-2c-4gb" is not available in region ewr`},
+		{func(d *Driver) { d.OS = "Plan 9" }, `No Vultr operating system named "Plan 9"`},
+		{func(d *Driver) { d.ReservedIP = "rip-2" }, `Vultr reserved IP "rip-2" is in region ams, not ewr`},
+		{func(d *Driver) { d.ReservedIP = "198.51.100.1" }, `No Vultr reserved IP "198.51.100.1"`},
+		{func(d *Driver) { d.VPCs = []string{"frontend"} }, `Vultr VPC "frontend" is in region ams, not ewr`},
+		{func(d *Driver) { d.VPCs = []string{"storage"} }, `No Vultr VPC "storage"`},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(lookups)
+		expected.configure(d)
+
+		err := d.PreCreateCheck()
+		done()
+
+		assert.EqualError(t, err, expected.err)
+	}
+}
+
+func TestOSByID(t *testing.T) {
+	d, fake, done := newTestDriver(lookups)
+	defer done()
+
+	d.OS = "477"
+
+	assert.NoError(t, d.PreCreateCheck())
+	assert.Equal(t, 477, d.OSID)
+	assert.NotContains(t, fake.Requests, "GET /os?per_page=500")
+}
+
+func TestInstanceRequest(t *testing.T) {
+	d := NewDriver("default", "")
+	d.OSID = 215
+	d.SSHKeyID = "key-1"
+	d.Tags = []string{"ci"}
+
+	assert.Equal(t, map[string]interface{}{
+		"label":     "default",
+		"hostname":  "default",
+		"region":    "ewr",
+		"plan":      "vc2-1c-1gb",
+		"os_id":     215,
+		"sshkey_id": []string{"key-1"},
+		"tags":      []string{"ci"},
+	}, d.instanceRequest())
+
+	d.ReservedIPID = "rip-1"
+	d.VPCIDs = []string{"vpc-1"}
+	d.ScriptID = "script-1"
+
+	request := d.instanceRequest()
+	assert.Equal(t, "rip-1", request["reserved_ipv4"])
+	assert.Equal(t, []string{"vpc-1"}, request["attach_vpc"])
+	assert.Equal(t, "script-1", request["script_id"])
+}
+
+func TestCreateStartupScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "startup.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\necho hello\n"), 0644))
+
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /startup-scripts": `{"startup_script": {"id": "script-1"}}`,
+	})
+	defer done()
+
+	d.StartupScript = script
+
+	assert.NoError(t, d.createStartupScript())
+	assert.Equal(t, "script-1", d.ScriptID)
+	assert.Equal(t, "boot", fake.Bodies["POST /startup-scripts"]["type"])
+	assert.Equal(t, "IyEvYmluL3NoCmVjaG8gaGVsbG8K", fake.Bodies["POST /startup-scripts"]["script"])
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		status      string
+		powerStatus string
+		state       state.State
+	}{
+		{"pending", "stopped", state.Starting},
+		{"active", "running", state.Running},
+		{"active", "stopped", state.Stopped},
+		{"suspended", "stopped", state.Error},
+		{"closed", "", state.None},
+	}
+
+	for _, expected := range tests {
+		d, _, done := newTestDriver(map[string]string{
+			"GET /instances/inst": fmt.Sprintf(`{"instance": {"id": "inst", "status": %q, "power_status": %q}}`, expected.status, expected.powerStatus),
+		})
+
+		machineState, err := d.GetState()
+		done()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestActions(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"POST /instances/inst/start":  "",
+		"POST /instances/inst/halt":   "",
+		"POST /instances/inst/reboot": "",
+	})
+	defer done()
+
+	assert.NoError(t, d.Start())
+	assert.NoError(t, d.Stop())
+	assert.NoError(t, d.Restart())
+	assert.Equal(t, []string{
+		"POST /instances/inst/start",
+		"POST /instances/inst/halt",
+		"POST /instances/inst/reboot",
+	}, fake.Requests)
+}
+
+func TestRemove(t *testing.T) {
+	d, fake, done := newTestDriver(map[string]string{
+		"DELETE /instances/inst":         "",
+		"DELETE /startup-scripts/script": "",
+	})
+	defer done()
+
+	d.SSHKeyID = "key-1"
+	d.ScriptID = "script"
+	d.ReservedIPID = "rip-1"
+
+	assert.NoError(t, d.Remove())
+	assert.Equal(t, []string{
+		"DELETE /instances/inst",
+		"DELETE /ssh-keys/key-1",
+		"DELETE /startup-scripts/script",
+	}, fake.Requests)
+}
+
+func TestAPIError(t *testing.T) {
+	d, _, done := newTestDriver(map[string]string{})
+	defer done()
+	d.client.Header = jsonapi.BearerToken("wrong")
+
+	_, err := d.GetState()
+
+	assert.EqualError(t, err, "Vultr API error (401): Invalid API token.")
+}
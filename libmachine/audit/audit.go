@@ -0,0 +1,241 @@
+// Package audit records the operations changing machines to an append-only
+// log in the store, for the teams sharing a store to know who did what.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the audit log in the store.
+const FileName = "audit.log"
+
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// secretFlags are the flags of the core and the drivers in this tree whose
+// values are redacted from the arguments.
+var secretFlags = map[string]bool{
+	"amazonec2-access-key":      true,
+	"amazonec2-secret-key":      true,
+	"amazonec2-session-token":   true,
+	"azure-client-secret":       true,
+	"azure-password":            true,
+	"digitalocean-access-token": true,
+	"engine-registry-auth":      true,
+	"equinixmetal-api-key":      true,
+	"exoscale-api-key":          true,
+	"exoscale-api-secret-key":   true,
+	"generic-ssh-pass":          true,
+	"generic-winrm-password":    true,
+	"hetzner-api-token":         true,
+	"openstack-password":        true,
+	"proxmox-password":          true,
+	"proxmox-token-secret":      true,
+	"rackspace-api-key":         true,
+	"scaleway-token":            true,
+	"softlayer-api-key":         true,
+	"vmwarefusion-ssh-password": true,
+	"vmwarevcloudair-password":  true,
+	"vmwarevsphere-password":    true,
+	"vultr-api-key":             true,
+}
+
+// secretFlagSuffixes are the endings of the names of the flags of plugin
+// drivers outside this tree whose values are redacted too.
+var secretFlagSuffixes = []string{"-secret", "-secret-key", "-token", "-password", "-pass", "-access-key", "-api-key", "-credentials"}
+
+// Entry is an operation in the audit log.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Command  string    `json:"command"`
+	Machines []string  `json:"machines,omitempty"`
+	Args     []string  `json:"args"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Filter selects entries of the audit log. Its zero value selects them all.
+type Filter struct {
+	// Machine only selects the entries of this machine
+	Machine string
+
+	// Since only selects the entries from this time on
+	Since time.Time
+}
+
+func (f Filter) match(entry Entry) bool {
+	if entry.Time.Before(f.Since) {
+		return false
+	}
+	if f.Machine == "" {
+		return true
+	}
+	for _, machine := range entry.Machines {
+		if machine == f.Machine {
+			return true
+		}
+	}
+	return false
+}
+
+// Log is the audit log of a store.
+type Log struct {
+	Path string
+}
+
+// NewLog returns the audit log of the store at storePath.
+func NewLog(storePath string) *Log {
+	return &Log{Path: filepath.Join(storePath, FileName)}
+}
+
+// Append adds the entry at the end of the log. Entries are single lines,
+// which concurrent commands append without mixing them up.
+func (l *Log) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Error opening the audit log: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Error writing to the audit log: %s", err)
+	}
+
+	return nil
+}
+
+// Read returns the entries of the log the filter selects, oldest first. A
+// missing log has no entries.
+func (l *Log) Read(filter Filter) ([]Entry, error) {
+	entries := []Entry{}
+
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the audit log: %s", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("Error reading line %d of the audit log: %s", line, err)
+		}
+
+		if filter.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading the audit log: %s", err)
+	}
+
+	return entries, nil
+}
+
+// NewEntry returns the entry of the command run by the current user at t,
+// with the result of err.
+func NewEntry(t time.Time, command string, machines []string, args []string, err error) Entry {
+	entry := Entry{
+		Time:     t.UTC(),
+		User:     CurrentUser(),
+		Command:  command,
+		Machines: machines,
+		Args:     RedactArgs(args),
+		Result:   ResultOK,
+	}
+
+	if err != nil {
+		entry.Result = ResultError
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+// CurrentUser returns the name of the user running the command.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// RedactArgs returns the args with the values of the flags holding secrets
+// replaced, given as --flag value or --flag=value.
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+
+	for i := 0; i < len(redacted); i++ {
+		arg := redacted[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		value := ""
+		hasValue := false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+
+		if !isSecretFlag(name) {
+			continue
+		}
+
+		if hasValue {
+			if value != "" {
+				redacted[i] = arg[:len(arg)-len(value)] + "REDACTED"
+			}
+			continue
+		}
+
+		if i+1 < len(redacted) && !strings.HasPrefix(redacted[i+1], "-") {
+			redacted[i+1] = "REDACTED"
+			i++
+		}
+	}
+
+	return redacted
+}
+
+func isSecretFlag(name string) bool {
+	name = strings.ToLower(name)
+	if secretFlags[name] {
+		return true
+	}
+	for _, suffix := range secretFlagSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
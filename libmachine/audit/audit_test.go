@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{
+		"create", "-d", "amazonec2",
+		"--amazonec2-access-key", "AKIA1",
+		"--amazonec2-secret-key=s3cr3t",
+		"--digitalocean-access-token=",
+		"--amazonec2-region", "us-east-1",
+		"--generic-ssh-pass", "hunter2",
+		"--engine-registry-auth", "registry.example.com=ci:s3cr3t",
+		"--engine-registry-auth=quay.io=bot:t0k3n",
+		"--swarm-discovery", "token://abc",
+		"--myplugin-api-token", "t0k3n",
+		"web",
+	}
+
+	assert.Equal(t, []string{
+		"create", "-d", "amazonec2",
+		"--amazonec2-access-key", "REDACTED",
+		"--amazonec2-secret-key=REDACTED",
+		"--digitalocean-access-token=",
+		"--amazonec2-region", "us-east-1",
+		"--generic-ssh-pass", "REDACTED",
+		"--engine-registry-auth", "REDACTED",
+		"--engine-registry-auth=REDACTED",
+		"--swarm-discovery", "token://abc",
+		"--myplugin-api-token", "REDACTED",
+		"web",
+	}, RedactArgs(args))
+	assert.Equal(t, "AKIA1", args[4])
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	log := NewLog(dir)
+
+	entries, err := log.Read(Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	create := NewEntry(start, "create", []string{"web"}, []string{"create", "web"}, nil)
+	rm := NewEntry(start.Add(time.Hour), "rm", []string{"web", "db"}, []string{"rm", "web", "db"}, errors.New("Host does not exist: \"db\""))
+	ssh := NewEntry(start.Add(2*time.Hour), "ssh", []string{"db"}, []string{"ssh", "db", "uptime"}, nil)
+
+	for _, entry := range []Entry{create, rm, ssh} {
+		assert.NoError(t, log.Append(entry))
+	}
+
+	assert.Equal(t, ResultError, rm.Result)
+	assert.Equal(t, CurrentUser(), rm.User)
+
+	entries, err = log.Read(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{create, rm, ssh}, entries)
+
+	entries, err = log.Read(Filter{Machine: "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{create, rm}, entries)
+
+	entries, err = log.Read(Filter{Machine: "db", Since: start.Add(90 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{ssh}, entries)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, FileName), []byte("{\n"), 0600))
+	_, err = log.Read(Filter{})
+	assert.EqualError(t, err, "Error reading line 1 of the audit log: unexpected end of JSON input")
+}
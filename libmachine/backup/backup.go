@@ -0,0 +1,535 @@
+// Package backup backs up a store of machines to a bucket of object storage,
+// encrypted with a key of the user, and restores it. The files of the store
+// are uploaded as blobs named after their content, which the backups share,
+// so a backup only uploads the files changed since the previous ones. The
+// snapshot listing the files of a backup is uploaded once all its blobs are,
+// so an interrupted backup leaves the previous ones whole.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	blobsPrefix     = "blobs/"
+	snapshotsPrefix = "snapshots/"
+
+	// snapshotIDFormat formats the time of a backup as its ID, for the IDs
+	// to sort in the order of the backups. It has nanoseconds, and trailing
+	// zeros, for two backups in the same second not to have the same ID.
+	snapshotIDFormat = "20060102T150405.000000000Z"
+
+	manifestVersion = 1
+)
+
+var (
+	// backedUpDirs are the directories of the store backed up with all
+	// their files. Of the machines directory, only the files of the
+	// directories of the machines are, their disk images aside.
+	backedUpDirs = []string{"certs", "hooks", "profiles", "templates"}
+
+	// skippedFiles are the files of the store which are not backed up,
+	// being about the storage path rather than the store.
+	skippedFiles = []string{"current-store"}
+
+	// diskImageExtensions are the extensions of the disk images of the
+	// machines, which can be downloaded or recreated again.
+	diskImageExtensions = []string{".iso", ".img", ".raw", ".qcow2", ".vdi", ".vmdk", ".vhd", ".vhdx"}
+
+	// snapshotAttempts is how many times the store is read, when it changes
+	// while being read, before giving up.
+	snapshotAttempts   = 5
+	snapshotRetryDelay = time.Second
+
+	// ErrNoBackups is returned restoring from a bucket without backups.
+	ErrNoBackups = errors.New("There are no backups in the bucket")
+
+	errStoreChanging = errors.New("The store kept changing while being backed up, back it up again once its commands are done")
+
+	now = time.Now
+)
+
+// Bucket is where the backups are uploaded, whose keys are relative to the
+// prefix given by the user.
+type Bucket interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	List(prefix string) ([]string, error)
+}
+
+// File is a file of the store in a backup.
+type File struct {
+	// Path is the slash separated path of the file in the store.
+	Path string
+	Mode os.FileMode
+	Size int64
+
+	// Blob is the name of the blob of its content.
+	Blob string
+}
+
+// Manifest lists the files of a backup, uploaded as its snapshot.
+type Manifest struct {
+	Version int
+	ID      string
+	Created time.Time
+
+	// Dir is the directory of the store backed up, which the configs of
+	// its machines have paths in.
+	Dir   string
+	Files []File
+}
+
+// Result is a backup, and what was uploaded for it.
+type Result struct {
+	Manifest      *Manifest
+	Uploaded      int
+	UploadedBytes int64
+}
+
+type storeFile struct {
+	File
+	modTime time.Time
+	data    []byte
+}
+
+// Backup backs up the store in dir to the bucket, uploading the files the
+// previous backups didn't, and then the snapshot of the backup.
+func Backup(dir string, bucket Bucket, key *Key) (*Result, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := readStore(dir, key)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := bucket.List(blobsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := map[string]bool{}
+	for _, k := range keys {
+		uploaded[k] = true
+	}
+
+	created := now().UTC()
+	result := &Result{
+		Manifest: &Manifest{
+			Version: manifestVersion,
+			ID:      created.Format(snapshotIDFormat),
+			Created: created,
+			Dir:     dir,
+			Files:   []File{},
+		},
+	}
+
+	for _, f := range files {
+		result.Manifest.Files = append(result.Manifest.Files, f.File)
+
+		name := blobsPrefix + f.Blob
+		if uploaded[name] {
+			continue
+		}
+
+		log.Debugf("Uploading %s", f.Path)
+		sealed, err := key.seal(name, f.data)
+		if err != nil {
+			return nil, err
+		}
+		if err := bucket.Put(name, sealed); err != nil {
+			return nil, err
+		}
+
+		uploaded[name] = true
+		result.Uploaded++
+		result.UploadedBytes += f.Size
+	}
+
+	data, err := json.Marshal(result.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	name := snapshotsPrefix + result.Manifest.ID
+	sealed, err := key.seal(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := bucket.Put(name, sealed); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Snapshots returns the IDs of the backups in the bucket, the latest last.
+func Snapshots(bucket Bucket) ([]string, error) {
+	keys, err := bucket.List(snapshotsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, k := range keys {
+		ids = append(ids, strings.TrimPrefix(k, snapshotsPrefix))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Verify downloads the backup of the ID, the latest when empty, and checks
+// its files are whole.
+func Verify(bucket Bucket, key *Key, id string) (*Manifest, error) {
+	manifest, _, err := fetch(bucket, key, id)
+	return manifest, err
+}
+
+// Restore restores the backup of the ID, the latest when empty, to the store
+// in dir. The backup is downloaded and verified whole first. The configs of
+// the machines are written last, for a restore interrupted to leave no
+// machine without its certificates, and have the paths of the store backed
+// up replaced with the ones of dir.
+func Restore(dir string, bucket Bucket, key *Key, id string) (*Manifest, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, contents, err := fetch(bucket, key, id)
+	if err != nil {
+		return nil, err
+	}
+
+	files := append([]File{}, manifest.Files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		return !isMachineFile(files[i].Path) && isMachineFile(files[j].Path)
+	})
+
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return nil, err
+		}
+
+		data := contents[f.Blob]
+		if isMachineFile(f.Path) {
+			data = relocate(data, manifest.Dir, dir)
+		}
+		if err := writeFileAtomic(target, data, f.Mode.Perm()); err != nil {
+			return nil, fmt.Errorf("Error restoring %s: %s", f.Path, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// fetch downloads the snapshot of the ID, the latest when empty, and the
+// blobs of its files, checking they are the ones backed up.
+func fetch(bucket Bucket, key *Key, id string) (*Manifest, map[string][]byte, error) {
+	if id == "" {
+		ids, err := Snapshots(bucket)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(ids) == 0 {
+			return nil, nil, ErrNoBackups
+		}
+		id = ids[len(ids)-1]
+	}
+
+	name := snapshotsPrefix + id
+	sealed, err := bucket.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := key.open(name, sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, nil, fmt.Errorf("Error decoding the backup %s: %s", id, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, nil, fmt.Errorf("The backup %s has version %d, which this version of Machine cannot restore", id, manifest.Version)
+	}
+
+	contents := map[string][]byte{}
+	for _, f := range manifest.Files {
+		if !validPath(f.Path) {
+			return nil, nil, fmt.Errorf("Error verifying the backup %s: invalid path %q", id, f.Path)
+		}
+		if _, ok := contents[f.Blob]; ok {
+			continue
+		}
+
+		name := blobsPrefix + f.Blob
+		sealed, err := bucket.Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := key.open(name, sealed)
+		if err != nil {
+			return nil, nil, err
+		}
+		if int64(len(data)) != f.Size || key.blobName(data) != f.Blob {
+			return nil, nil, fmt.Errorf("Error verifying %s: its content is not the one backed up", f.Path)
+		}
+
+		contents[f.Blob] = data
+	}
+
+	return manifest, contents, nil
+}
+
+// readStore reads the files of the store in dir, again while they change,
+// for the backup to be of the store between two of its commands.
+func readStore(dir string, key *Key) ([]*storeFile, error) {
+	for attempt := 1; ; attempt++ {
+		files, consistent, err := readFiles(dir, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if consistent {
+			if consistent, err = unchanged(dir, files); err != nil {
+				return nil, err
+			}
+		}
+		if consistent {
+			return files, nil
+		}
+
+		if attempt == snapshotAttempts {
+			return nil, errStoreChanging
+		}
+		log.Debugf("The store changed while being read, reading it again")
+		time.Sleep(snapshotRetryDelay)
+	}
+}
+
+// readFiles reads the files of the store, and tells whether they were read
+// whole: a file removed, or written, while being read wasn't.
+func readFiles(dir string, key *Key) ([]*storeFile, bool, error) {
+	paths, err := storePaths(dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	files := []*storeFile{}
+	for _, p := range paths {
+		name := filepath.Join(dir, filepath.FromSlash(p))
+
+		fi, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if int64(len(data)) != fi.Size() || (isMachineFile(p) && !json.Valid(data)) {
+			return nil, false, nil
+		}
+
+		files = append(files, &storeFile{
+			File: File{
+				Path: p,
+				Mode: fi.Mode(),
+				Size: fi.Size(),
+				Blob: key.blobName(data),
+			},
+			modTime: fi.ModTime(),
+			data:    data,
+		})
+	}
+
+	return files, true, nil
+}
+
+// unchanged tells whether the files of the store are still the ones read.
+func unchanged(dir string, files []*storeFile) (bool, error) {
+	paths, err := storePaths(dir)
+	if err != nil {
+		return false, err
+	}
+	if len(paths) != len(files) {
+		return false, nil
+	}
+
+	for i, f := range files {
+		if paths[i] != f.Path {
+			return false, nil
+		}
+
+		fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if fi.Size() != f.Size || !fi.ModTime().Equal(f.modTime) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// storePaths returns the sorted, slash separated paths of the files of the
+// store which are backed up.
+func storePaths(dir string) ([]string, error) {
+	paths := []string{}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		switch {
+		case e.Mode().IsRegular():
+			if !contains(skippedFiles, e.Name()) && !strings.HasSuffix(e.Name(), ".lock") {
+				paths = append(paths, e.Name())
+			}
+		case e.IsDir() && contains(backedUpDirs, e.Name()):
+			err := filepath.Walk(filepath.Join(dir, e.Name()), func(name string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if fi.Mode().IsRegular() {
+					rel, err := filepath.Rel(dir, name)
+					if err != nil {
+						return err
+					}
+					paths = append(paths, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		case e.IsDir() && e.Name() == "machines":
+			machinePaths, err := machinesPaths(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, machinePaths...)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// machinesPaths returns the paths of the files of the directories of the
+// machines, their disk images and the directories of their VMs aside.
+func machinesPaths(dir string) ([]string, error) {
+	paths := []string{}
+
+	machines, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range machines {
+		if !m.IsDir() {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(dir, m.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if f.Mode().IsRegular() && !isDiskImage(f.Name()) && !strings.HasSuffix(f.Name(), ".lock") {
+				paths = append(paths, path.Join("machines", m.Name(), f.Name()))
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// relocate replaces the paths in the directory from in the JSON config of a
+// machine with the ones in the directory to.
+func relocate(config []byte, from, to string) []byte {
+	if from == "" || from == to {
+		return config
+	}
+
+	quote := func(s string) string {
+		data, _ := json.Marshal(s)
+		return strings.Trim(string(data), `"`)
+	}
+	oldDir, newDir := quote(from), quote(to)
+	sep := quote(string(filepath.Separator))
+
+	replacer := strings.NewReplacer(
+		`"`+oldDir+`"`, `"`+newDir+`"`,
+		`"`+oldDir+sep, `"`+newDir+sep,
+	)
+	return []byte(replacer.Replace(string(config)))
+}
+
+// isMachineFile tells whether the path is the config of a machine, which
+// the machine is listed by.
+func isMachineFile(p string) bool {
+	parts := strings.Split(p, "/")
+	return len(parts) == 3 && parts[0] == "machines" && parts[2] == "config.json"
+}
+
+func isDiskImage(name string) bool {
+	return contains(diskImageExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// validPath tells whether the path of a file of a backup is in the store.
+func validPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && path.Clean(p) == p && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp := name + ".restoring"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
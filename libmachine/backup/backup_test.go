@@ -0,0 +1,242 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "Vx2Qk8s1c7TqLJrV9oJm8l3nq0b4cYt7Zs2e1u5hA9M="
+
+func writeFile(t *testing.T, dir, name, content string, perm os.FileMode) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), perm))
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+// newTestStore returns a store with a machine, and a bucket to back it up
+// to, in temporary directories.
+func newTestStore(t *testing.T) (string, *DirBucket, func()) {
+	dir, err := ioutil.TempDir("", "machine-backup")
+	assert.NoError(t, err)
+
+	store := filepath.Join(dir, "store")
+	writeFile(t, store, "certs/ca.pem", "ca", 0600)
+	writeFile(t, store, "certs/ca-key.pem", "ca key", 0600)
+	writeFile(t, store, "config.yaml", "driver: amazonec2\n", 0644)
+	writeFile(t, store, "current-store", "work\n", 0600)
+	writeFile(t, store, "cache/boot2docker.iso", "iso", 0644)
+	writeFile(t, store, "stores/work/config.yaml", "", 0644)
+	writeFile(t, store, "machines/dev/config.json", `{"Name": "dev"}`, 0600)
+	writeFile(t, store, "machines/dev/id_rsa", "key", 0600)
+	writeFile(t, store, "machines/dev/disk.vmdk", "disk", 0600)
+	writeFile(t, store, "machines/dev/dev/dev.vbox", "vm", 0600)
+
+	return store, &DirBucket{Dir: filepath.Join(dir, "bucket")}, func() { os.RemoveAll(dir) }
+}
+
+func TestBackupRestore(t *testing.T) {
+	store, bucket, cleanup := newTestStore(t)
+	defer cleanup()
+
+	key, err := NewKey([]byte(testSecret + "\n"))
+	assert.NoError(t, err)
+
+	config := `{"Name": "dev", "StorePath": "` + store + `/machines/dev", "CertDir": "` + store + `/certs"}`
+	writeFile(t, store, "machines/dev/config.json", config, 0600)
+
+	now = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	result, err := Backup(store, bucket, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "20261001T120000.000000000Z", result.Manifest.ID)
+	assert.Equal(t, 5, result.Uploaded)
+
+	paths := []string{}
+	for _, f := range result.Manifest.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"certs/ca-key.pem", "certs/ca.pem", "config.yaml", "machines/dev/config.json", "machines/dev/id_rsa"}, paths)
+
+	// The next backup only uploads the file changed.
+	writeFile(t, store, "config.yaml", "driver: digitalocean\n", 0644)
+	now = func() time.Time { return time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC) }
+
+	result, err = Backup(store, bucket, key)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Uploaded)
+	assert.Equal(t, int64(len("driver: digitalocean\n")), result.UploadedBytes)
+
+	ids, err := Snapshots(bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20261001T120000.000000000Z", "20261002T120000.000000000Z"}, ids)
+
+	// The objects are encrypted.
+	keys, err := bucket.List("blobs/")
+	assert.NoError(t, err)
+	for _, k := range keys {
+		assert.NotContains(t, readFile(t, bucket.Dir, k), "driver")
+	}
+
+	restored := filepath.Join(filepath.Dir(store), "restored")
+
+	manifest, err := Restore(restored, bucket, key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "20261002T120000.000000000Z", manifest.ID)
+	assert.Equal(t, "driver: digitalocean\n", readFile(t, restored, "config.yaml"))
+	assert.Equal(t, `{"Name": "dev", "StorePath": "`+restored+`/machines/dev", "CertDir": "`+restored+`/certs"}`, readFile(t, restored, "machines/dev/config.json"))
+
+	fi, err := os.Stat(filepath.Join(restored, "machines", "dev", "id_rsa"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	_, err = Restore(restored, bucket, key, "20261001T120000.000000000Z")
+	assert.NoError(t, err)
+	assert.Equal(t, "driver: amazonec2\n", readFile(t, restored, "config.yaml"))
+
+	_, err = os.Stat(filepath.Join(restored, "machines", "dev", "disk.vmdk"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackupSameSecond(t *testing.T) {
+	store, bucket, cleanup := newTestStore(t)
+	defer cleanup()
+
+	key, err := NewKey([]byte(testSecret))
+	assert.NoError(t, err)
+
+	now = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 100000000, time.UTC) }
+	defer func() { now = time.Now }()
+
+	first, err := Backup(store, bucket, key)
+	assert.NoError(t, err)
+
+	writeFile(t, store, "config.yaml", "driver: digitalocean\n", 0644)
+	now = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 900000000, time.UTC) }
+
+	second, err := Backup(store, bucket, key)
+	assert.NoError(t, err)
+
+	ids, err := Snapshots(bucket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20261001T120000.100000000Z", "20261001T120000.900000000Z"}, ids)
+	assert.Equal(t, []string{first.Manifest.ID, second.Manifest.ID}, ids)
+
+	// Both backups are kept, the latest being restored by default.
+	restored := filepath.Join(filepath.Dir(store), "restored")
+
+	manifest, err := Restore(restored, bucket, key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, second.Manifest.ID, manifest.ID)
+	assert.Equal(t, "driver: digitalocean\n", readFile(t, restored, "config.yaml"))
+
+	_, err = Verify(bucket, key, first.Manifest.ID)
+	assert.NoError(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	store, bucket, cleanup := newTestStore(t)
+	defer cleanup()
+
+	key, err := NewKey([]byte(testSecret))
+	assert.NoError(t, err)
+
+	_, err = Verify(bucket, key, "")
+	assert.Equal(t, ErrNoBackups, err)
+
+	result, err := Backup(store, bucket, key)
+	assert.NoError(t, err)
+
+	manifest, err := Verify(bucket, key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, result.Manifest.ID, manifest.ID)
+
+	otherKey, err := NewKey([]byte("another secret of at least 32 characters"))
+	assert.NoError(t, err)
+
+	_, err = Verify(bucket, otherKey, "")
+	assert.EqualError(t, err, "Error decrypting snapshots/"+manifest.ID+": it was not backed up with this key, or was altered since")
+
+	// A blob swapped for another one is detected.
+	blob := func(name string) string {
+		for _, f := range manifest.Files {
+			if f.Path == name {
+				return filepath.Join(bucket.Dir, "blobs", f.Blob)
+			}
+		}
+		return ""
+	}
+	data, err := ioutil.ReadFile(blob("certs/ca.pem"))
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(blob("certs/ca-key.pem"), data, 0600))
+
+	_, err = Verify(bucket, key, "")
+	assert.Contains(t, err.Error(), "it was not backed up with this key, or was altered since")
+}
+
+func TestBackupStoreChanging(t *testing.T) {
+	store, bucket, cleanup := newTestStore(t)
+	defer cleanup()
+
+	key, err := NewKey([]byte(testSecret))
+	assert.NoError(t, err)
+
+	defer func(attempts int, delay time.Duration) {
+		snapshotAttempts, snapshotRetryDelay = attempts, delay
+	}(snapshotAttempts, snapshotRetryDelay)
+	snapshotAttempts, snapshotRetryDelay = 2, 0
+
+	// A config of a machine being written is not whole.
+	writeFile(t, store, "machines/dev/config.json", `{"Name": `, 0600)
+
+	_, err = Backup(store, bucket, key)
+	assert.Equal(t, errStoreChanging, err)
+
+	ids, err := Snapshots(bucket)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestNewKey(t *testing.T) {
+	_, err := NewKey([]byte("  short  \n"))
+	assert.Equal(t, errKeyTooShort, err)
+
+	key, err := NewKey([]byte(testSecret))
+	assert.NoError(t, err)
+
+	sealed, err := key.seal("blobs/a", []byte("data"))
+	assert.NoError(t, err)
+
+	data, err := key.open("blobs/a", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	_, err = key.open("blobs/b", sealed)
+	assert.EqualError(t, err, "Error decrypting blobs/b: it was not backed up with this key, or was altered since")
+}
+
+func TestRelocate(t *testing.T) {
+	config := []byte(`{"StorePath": "/home/me/.docker/machine", "CertDir": "/home/me/.docker/machine/certs", "Other": "/home/me/.docker/machine-old"}`)
+
+	assert.Equal(t, `{"StorePath": "/root", "CertDir": "/root/certs", "Other": "/home/me/.docker/machine-old"}`, string(relocate(config, "/home/me/.docker/machine", "/root")))
+	assert.Equal(t, string(config), string(relocate(config, "", "/root")))
+}
+
+func TestValidPath(t *testing.T) {
+	assert.True(t, validPath("machines/dev/config.json"))
+	assert.False(t, validPath("../config.json"))
+	assert.False(t, validPath("/etc/passwd"))
+	assert.False(t, validPath("certs/../../key.pem"))
+	assert.False(t, validPath(""))
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const uploadingSuffix = ".uploading"
+
+// DirBucket is a bucket in a directory, like a mounted network share.
+type DirBucket struct {
+	Dir string
+}
+
+// Put writes the object to a temporary file first, for an interrupted
+// backup not to leave a partial object.
+func (b *DirBucket) Put(key string, data []byte) error {
+	name := filepath.Join(b.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+
+	tmp := name + uploadingSuffix
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (b *DirBucket) Get(key string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(b.Dir, filepath.FromSlash(key)))
+}
+
+func (b *DirBucket) List(prefix string) ([]string, error) {
+	keys := []string{}
+
+	err := filepath.Walk(b.Dir, func(name string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() || strings.HasSuffix(name, uploadingSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.Dir, name)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,115 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// minKeyLength is the length of the shortest secret a key is made of, the
+// length of 32 random bytes encoded in hex or base64 being more.
+const minKeyLength = 32
+
+var errKeyTooShort = fmt.Errorf("The backup key is too short, it needs at least %d characters, generate one with 'openssl rand -base64 32'", minKeyLength)
+
+// Key encrypts the objects of the backups, and names their blobs. It is
+// made of a secret of the user, which the backups can't be restored
+// without.
+type Key struct {
+	encryption []byte
+	blobs      []byte
+}
+
+// NewKey returns the key made of a secret, whose surrounding spaces are
+// ignored.
+func NewKey(secret []byte) (*Key, error) {
+	secret = bytes.TrimSpace(secret)
+	if len(secret) < minKeyLength {
+		return nil, errKeyTooShort
+	}
+
+	return &Key{
+		encryption: deriveKey(secret, "encryption"),
+		blobs:      deriveKey(secret, "blobs"),
+	}, nil
+}
+
+// ReadKey returns the key made of the secret in a file.
+func ReadKey(path string) (*Key, error) {
+	secret, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the backup key: %s", err)
+	}
+
+	key, err := NewKey(secret)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the backup key %s: %s", path, err)
+	}
+	return key, nil
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("docker-machine store backup " + purpose))
+	return mac.Sum(nil)
+}
+
+// blobName returns the name of the blob of the data, an HMAC of it for the
+// same files to share a blob without the bucket learning their hashes.
+func (k *Key) blobName(data []byte) string {
+	mac := hmac.New(sha256.New, k.blobs)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k *Key) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.encryption)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the data of the object of a key of the bucket, and
+// authenticates it with that key, for objects not to be swapped.
+func (k *Key) seal(name string, data []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, []byte(name)), nil
+}
+
+// open decrypts the data of the object of a key of the bucket, checking it
+// was sealed with the key, for that object, and not altered since.
+func (k *Key) open(name string, sealed []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	errCorrupted := fmt.Errorf("Error decrypting %s: it was not backed up with this key, or was altered since", name)
+	if len(sealed) < aead.NonceSize() {
+		return nil, errCorrupted
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return nil, errCorrupted
+	}
+	return data, nil
+}
@@ -7,10 +7,12 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"net"
 	"os"
+	"strings"
 	"time"
 
 	"errors"
@@ -157,7 +159,7 @@ func (xcg *X509CertGenerator) GenerateCert(hosts []string, certFile, keyFile, ca
 	} else { // server
 		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
 		for _, h := range hosts {
-			if ip := net.ParseIP(h); ip != nil {
+			if ip := net.ParseIP(strings.Trim(h, "[]")); ip != nil {
 				template.IPAddresses = append(template.IPAddresses, ip)
 			} else {
 				template.DNSNames = append(template.DNSNames, h)
@@ -244,3 +246,24 @@ func (xcg *X509CertGenerator) ValidateCertificate(addr string, authOptions *auth
 
 	return true, nil
 }
+
+// ReadCertificateExpiry returns when the PEM encoded certificate at path
+// expires.
+func ReadCertificateExpiry(path string) (time.Time, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("No certificate found in %s", path)
+	}
+
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return c.NotAfter, nil
+}
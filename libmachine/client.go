@@ -0,0 +1,325 @@
+package libmachine
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+	"golang.org/x/net/context"
+)
+
+// DefaultParallel is the number of machines the bulk operations of a Client
+// work on at the same time, unless its Parallel says otherwise.
+const DefaultParallel = 4
+
+// DriverFactory returns the driver of a machine loaded from a store, given
+// the name of the driver and its raw config, like by starting the driver
+// plugin.
+type DriverFactory func(driverName string, rawDriver []byte) (drivers.Driver, error)
+
+// Client manages the machines of a store for the programs embedding
+// libmachine, as the docker-machine commands do. It loads the machines with
+// their drivers and caches them, and runs the operations on several machines
+// on at most Parallel of them at the same time. It is safe for concurrent
+// use.
+//
+// The errors of an operation on a machine are mcnerror.ErrHostOperation,
+// except for the mcnerror.ErrHostDoesNotExist and
+// mcnerror.ErrHostAlreadyExists errors, and the errors of the operations on
+// several machines are mcnerror.ErrBulkOperation.
+type Client struct {
+	Store persist.Store
+
+	// NewDriver gives the machines loaded from the store their driver,
+	// which they otherwise only have the raw config of. Without it, the
+	// machines keep the driver the store loaded them with.
+	NewDriver DriverFactory
+
+	// Parallel is the maximum number of machines the bulk operations work
+	// on at the same time, DefaultParallel when not set.
+	Parallel int
+
+	mu    sync.Mutex
+	hosts map[string]*host.Host
+}
+
+// NewClient returns a client of the machines of store, giving them their
+// driver with newDriver.
+func NewClient(store persist.Store, newDriver DriverFactory) *Client {
+	return &Client{
+		Store:     store,
+		NewDriver: newDriver,
+	}
+}
+
+// Load returns the machine, loaded from the store the first time.
+func (c *Client) Load(name string) (*host.Host, error) {
+	if h := c.cached(name); h != nil {
+		return h, nil
+	}
+
+	h, err := c.Store.Load(name)
+	if err != nil {
+		if _, ok := err.(mcnerror.ErrHostDoesNotExist); ok {
+			return nil, err
+		}
+		return nil, mcnerror.ErrHostOperation{Name: name, Operation: "loading", Err: err}
+	}
+
+	if err := c.loadDriver(h); err != nil {
+		return nil, err
+	}
+
+	return c.cache(h), nil
+}
+
+// List returns the machines of the store, starting the drivers of at most
+// Parallel of them at the same time. The machines whose driver can't be
+// loaded are left out, their errors returned with the other machines in an
+// mcnerror.ErrBulkOperation.
+func (c *Client) List() ([]*host.Host, error) {
+	stored, err := c.Store.List()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the machines of the store: %s", err)
+	}
+
+	names := make([]string, len(stored))
+	for i, h := range stored {
+		names[i] = h.Name
+	}
+
+	loaded := make([]*host.Host, len(stored))
+	bulkErr := c.ForEach(context.Background(), "loading", names, func(i int) error {
+		if h := c.cached(names[i]); h != nil {
+			loaded[i] = h
+			return nil
+		}
+
+		if err := c.loadDriver(stored[i]); err != nil {
+			return err
+		}
+		loaded[i] = c.cache(stored[i])
+		return nil
+	})
+
+	hosts := []*host.Host{}
+	for _, h := range loaded {
+		if h != nil {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts, bulkErr
+}
+
+// Save persists the machine in the store.
+func (c *Client) Save(h *host.Host) error {
+	if err := c.Store.Save(h); err != nil {
+		return mcnerror.ErrHostOperation{Name: h.Name, Operation: "saving", Err: err}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setHost(h)
+
+	return nil
+}
+
+// Forget drops the machine from the cache, the next Load reading it from the
+// store again.
+func (c *Client) Forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.hosts, name)
+}
+
+// Create creates, provisions and saves the machine, made with the NewHost
+// of the store. It gives up as soon as ctx is done, the creation being
+// resumable with ResumeCreateContext.
+func (c *Client) Create(ctx context.Context, h *host.Host) error {
+	exists, err := c.Store.Exists(h.Name)
+	if err != nil {
+		return mcnerror.ErrHostOperation{Name: h.Name, Operation: "creating", Err: err}
+	}
+	if exists {
+		return mcnerror.ErrHostAlreadyExists{Name: h.Name}
+	}
+
+	if err := CreateContext(ctx, c.Store, h); err != nil {
+		return mcnerror.ErrHostOperation{Name: h.Name, Operation: "creating", Err: err}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setHost(h)
+
+	return nil
+}
+
+// CreateMany creates the machines, at most Parallel of them at the same
+// time.
+func (c *Client) CreateMany(ctx context.Context, hosts []*host.Host) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	// The certificates are shared by the machines, which would otherwise
+	// race to generate them.
+	if err := cert.BootstrapCertificates(hosts[0].HostOptions.AuthOptions); err != nil {
+		return fmt.Errorf("Error generating certificates: %s", err)
+	}
+
+	names := make([]string, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+
+	return c.ForEach(ctx, "creating", names, func(i int) error {
+		return c.Create(ctx, hosts[i])
+	})
+}
+
+// Remove removes the machine with its driver, then from the store.
+func (c *Client) Remove(name string) error {
+	h, err := c.Load(name)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Driver.Remove(); err != nil {
+		return mcnerror.ErrHostOperation{Name: name, Operation: "removing", Err: fmt.Errorf("Provider error removing machine: %s", err)}
+	}
+
+	if err := c.Store.Remove(name); err != nil {
+		return mcnerror.ErrHostOperation{Name: name, Operation: "removing", Err: err}
+	}
+
+	c.Forget(name)
+
+	return nil
+}
+
+// RemoveMany removes the machines, at most Parallel of them at the same
+// time. The machines not started being removed once ctx is done are left.
+func (c *Client) RemoveMany(ctx context.Context, names []string) error {
+	return c.ForEach(ctx, "removing", names, func(i int) error {
+		return c.Remove(names[i])
+	})
+}
+
+// ForEach runs fn for every machine of names, at most Parallel at the same
+// time, and gathers the errors in an mcnerror.ErrBulkOperation. The machines
+// fn isn't started for once ctx is done get its error. It is how the bulk
+// operations of the Client, and of its users, bound their concurrency.
+func (c *Client) ForEach(ctx context.Context, operation string, names []string, fn func(i int) error) error {
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = DefaultParallel
+	}
+
+	errs := make([]error, len(names))
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i := range names {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	bulkErr := mcnerror.ErrBulkOperation{
+		Operation: operation,
+		Total:     len(names),
+	}
+	for i, err := range errs {
+		switch err := err.(type) {
+		case nil:
+		case mcnerror.ErrHostOperation:
+			bulkErr.Errs = append(bulkErr.Errs, err)
+		default:
+			bulkErr.Errs = append(bulkErr.Errs, mcnerror.ErrHostOperation{Name: names[i], Operation: operation, Err: err})
+		}
+	}
+
+	if len(bulkErr.Errs) == 0 {
+		return nil
+	}
+	return bulkErr
+}
+
+func (c *Client) loadDriver(h *host.Host) error {
+	if c.NewDriver == nil {
+		return nil
+	}
+
+	d, err := c.NewDriver(h.DriverName, h.RawDriver)
+	if err != nil {
+		return mcnerror.ErrHostOperation{Name: h.Name, Operation: "loading the driver of", Err: err}
+	}
+
+	h.Driver = d
+
+	return nil
+}
+
+func (c *Client) cached(name string) *host.Host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hosts[name]
+}
+
+// cache returns the machine cached with the name of h, caching h unless
+// another load of the machine was faster, whose driver is then kept.
+func (c *Client) cache(h *host.Host) *host.Host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.hosts[h.Name]; ok {
+		closeDriver(h)
+		return cached
+	}
+
+	c.setHost(h)
+
+	return h
+}
+
+// setHost caches h, with c.mu held.
+func (c *Client) setHost(h *host.Host) {
+	if c.hosts == nil {
+		c.hosts = map[string]*host.Host{}
+	}
+	c.hosts[h.Name] = h
+}
+
+// closeDriver stops the plugin of the driver of a machine loaded twice.
+func closeDriver(h *host.Host) {
+	if closer, ok := h.Driver.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Debugf("Error closing the driver of %s: %s", h.Name, err)
+		}
+	}
+}
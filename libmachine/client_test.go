@@ -0,0 +1,222 @@
+package libmachine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/persisttest"
+	"golang.org/x/net/context"
+)
+
+func saveTestHosts(t *testing.T, store persist.Store, names ...string) {
+	for _, name := range names {
+		h, err := hosttest.GetDefaultTestHost()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Name = name
+
+		if h.RawDriver, err = json.Marshal(&drivers.BaseDriver{MachineName: name}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Save(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fakeDriverFactory(driverName string, rawDriver []byte) (drivers.Driver, error) {
+	return &fakedriver.Driver{}, nil
+}
+
+func TestClientLoadCachesHosts(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	saveTestHosts(t, store, "web")
+
+	loads := 0
+	client := NewClient(store, func(driverName string, rawDriver []byte) (drivers.Driver, error) {
+		loads++
+		return fakeDriverFactory(driverName, rawDriver)
+	})
+
+	first, err := client.Load("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.Load("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Fatal("Expected the host to be loaded once")
+	}
+	if loads != 1 {
+		t.Fatalf("Expected the driver to be loaded once, got %d", loads)
+	}
+	if _, ok := first.Driver.(*fakedriver.Driver); !ok {
+		t.Fatalf("Expected the driver of the factory, got %T", first.Driver)
+	}
+
+	client.Forget("web")
+	if third, err := client.Load("web"); err != nil || third == first {
+		t.Fatalf("Expected the forgotten host to be loaded again, got %v", err)
+	}
+}
+
+func TestClientLoadMissingHost(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	_, err := NewClient(store, fakeDriverFactory).Load("missing")
+	if _, ok := err.(mcnerror.ErrHostDoesNotExist); !ok {
+		t.Fatalf("Expected an ErrHostDoesNotExist, got %v", err)
+	}
+}
+
+func TestClientListLeavesOutHostsWithoutDriver(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	saveTestHosts(t, store, "db", "web")
+
+	client := NewClient(store, func(driverName string, rawDriver []byte) (drivers.Driver, error) {
+		if bytes.Contains(rawDriver, []byte(`"MachineName":"web"`)) {
+			return nil, errors.New("plugin not found")
+		}
+		return &fakedriver.Driver{}, nil
+	})
+
+	hosts, err := client.List()
+	if len(hosts) != 1 || hosts[0].Name != "db" {
+		t.Fatalf("Expected db to be listed, got %v", hosts)
+	}
+
+	bulkErr, ok := err.(mcnerror.ErrBulkOperation)
+	if !ok {
+		t.Fatalf("Expected an ErrBulkOperation, got %v", err)
+	}
+	if bulkErr.Total != 2 || len(bulkErr.Errs) != 1 || bulkErr.Errs[0].Name != "web" {
+		t.Fatalf("Expected the driver of web to fail, got %v", bulkErr)
+	}
+}
+
+func TestClientListParallel(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	saveTestHosts(t, store, "a", "b", "c", "d", "e", "f")
+
+	var (
+		mu             sync.Mutex
+		running, most  int
+		release        = make(chan struct{})
+		startedLoading = make(chan struct{}, 6)
+	)
+
+	client := NewClient(store, func(driverName string, rawDriver []byte) (drivers.Driver, error) {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+
+		startedLoading <- struct{}{}
+		<-release
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		return &fakedriver.Driver{}, nil
+	})
+	client.Parallel = 2
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if hosts, err := client.List(); err != nil || len(hosts) != 6 {
+			t.Errorf("Expected the 6 hosts to be listed, got %d: %v", len(hosts), err)
+		}
+	}()
+
+	for i := 0; i < 6; i++ {
+		<-startedLoading
+		release <- struct{}{}
+	}
+	<-done
+
+	if most > 2 {
+		t.Fatalf("Expected at most 2 drivers loaded at the same time, got %d", most)
+	}
+}
+
+func TestClientRemoveMany(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	saveTestHosts(t, store, "db", "web")
+
+	client := NewClient(store, fakeDriverFactory)
+	err := client.RemoveMany(context.Background(), []string{"db", "missing", "web"})
+
+	bulkErr, ok := err.(mcnerror.ErrBulkOperation)
+	if !ok {
+		t.Fatalf("Expected an ErrBulkOperation, got %v", err)
+	}
+	if bulkErr.Total != 3 || len(bulkErr.Errs) != 1 || bulkErr.Errs[0].Name != "missing" {
+		t.Fatalf("Expected removing missing to fail, got %v", bulkErr)
+	}
+
+	for _, name := range []string{"db", "web"} {
+		if exists, _ := store.Exists(name); exists {
+			t.Fatalf("Expected %s to be removed", name)
+		}
+	}
+}
+
+func TestClientRemoveManyCanceled(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	saveTestHosts(t, store, "web")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewClient(store, fakeDriverFactory).RemoveMany(ctx, []string{"web"})
+	bulkErr, ok := err.(mcnerror.ErrBulkOperation)
+	if !ok || len(bulkErr.Errs) != 1 || bulkErr.Errs[0].Err != context.Canceled {
+		t.Fatalf("Expected removing web to be canceled, got %v", err)
+	}
+
+	if exists, _ := store.Exists("web"); !exists {
+		t.Fatal("Expected web to be left")
+	}
+}
+
+func TestClientCreateExistingHost(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	saveTestHosts(t, store, "web")
+
+	h := &host.Host{Name: "web"}
+	err := NewClient(store, fakeDriverFactory).Create(context.Background(), h)
+	if _, ok := err.(mcnerror.ErrHostAlreadyExists); !ok {
+		t.Fatalf("Expected an ErrHostAlreadyExists, got %v", err)
+	}
+}
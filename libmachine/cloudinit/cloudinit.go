@@ -0,0 +1,137 @@
+// Package cloudinit writes the seed ISOs stock cloud images are booted with
+// on local hypervisors, which have no metadata service: cloud-init reads the
+// machine's user, SSH key and network configuration from the NoCloud data
+// source, a volume labeled cidata.
+// See https://cloudinit.readthedocs.io/en/latest/reference/datasources/nocloud.html
+package cloudinit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Label is the volume label cloud-init looks for the seed on.
+const Label = "cidata"
+
+// Interface is a network interface found by its MAC address and renamed, so
+// that the drivers find it by name whatever the distribution calls it.
+type Interface struct {
+	Name       string
+	MACAddress string
+
+	// Address is the static address of the interface in CIDR notation,
+	// with its Gateway and Nameserver, configured instead of DHCP when set.
+	Address    string
+	Gateway    string
+	Nameserver string
+}
+
+// Config is what the machine is configured with.
+type Config struct {
+	Hostname string
+
+	// User is created with password-less sudo, and logged in to with
+	// AuthorizedKey.
+	User          string
+	AuthorizedKey string
+
+	// Interfaces are configured instead of the defaults of the image when
+	// set.
+	Interfaces []Interface
+}
+
+// UserData returns the cloud-config creating the user.
+func (c *Config) UserData() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#cloud-config\n")
+	fmt.Fprintf(&buf, "hostname: %s\n", c.Hostname)
+	fmt.Fprintf(&buf, "manage_etc_hosts: true\n")
+	fmt.Fprintf(&buf, "users:\n")
+	fmt.Fprintf(&buf, "  - name: %s\n", c.User)
+	fmt.Fprintf(&buf, "    sudo: ALL=(ALL) NOPASSWD:ALL\n")
+	fmt.Fprintf(&buf, "    shell: /bin/bash\n")
+	fmt.Fprintf(&buf, "    ssh_authorized_keys:\n")
+	fmt.Fprintf(&buf, "      - %s\n", strings.TrimSpace(c.AuthorizedKey))
+	return buf.Bytes()
+}
+
+// MetaData returns the meta-data of the instance.
+func (c *Config) MetaData() []byte {
+	return []byte(fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", c.Hostname, c.Hostname))
+}
+
+// NetworkConfig returns the network configuration, version 2, of the
+// interfaces, or nil if there are none.
+func (c *Config) NetworkConfig() []byte {
+	if len(c.Interfaces) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "version: 2\n")
+	fmt.Fprintf(&buf, "ethernets:\n")
+	for _, iface := range c.Interfaces {
+		fmt.Fprintf(&buf, "  %s:\n", iface.Name)
+		fmt.Fprintf(&buf, "    match:\n")
+		fmt.Fprintf(&buf, "      macaddress: \"%s\"\n", FormatMACAddress(iface.MACAddress))
+		fmt.Fprintf(&buf, "    set-name: %s\n", iface.Name)
+		if iface.Address == "" {
+			fmt.Fprintf(&buf, "    dhcp4: true\n")
+			continue
+		}
+		fmt.Fprintf(&buf, "    addresses: [%s]\n", iface.Address)
+		if iface.Gateway != "" {
+			fmt.Fprintf(&buf, "    gateway4: %s\n", iface.Gateway)
+		}
+		if iface.Nameserver != "" {
+			fmt.Fprintf(&buf, "    nameservers:\n")
+			fmt.Fprintf(&buf, "      addresses: [%s]\n", iface.Nameserver)
+		}
+	}
+	return buf.Bytes()
+}
+
+// FormatMACAddress returns a MAC address in the lowercase, colon separated
+// form cloud-init matches, given in that form or as 12 hexadecimal digits
+// as VirtualBox has it.
+func FormatMACAddress(mac string) string {
+	mac = strings.ToLower(strings.NewReplacer(":", "", "-", "").Replace(mac))
+	if len(mac) != 12 {
+		return mac
+	}
+
+	var parts []string
+	for i := 0; i < len(mac); i += 2 {
+		parts = append(parts, mac[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// WriteSeedISO writes the seed ISO configuring the machine to path.
+func WriteSeedISO(path string, c *Config) error {
+	if c.User == "" || c.AuthorizedKey == "" {
+		return fmt.Errorf("The cloud-init seed requires a user and an SSH key")
+	}
+
+	files := map[string][]byte{
+		"user-data": c.UserData(),
+		"meta-data": c.MetaData(),
+	}
+	if networkConfig := c.NetworkConfig(); networkConfig != nil {
+		files["network-config"] = networkConfig
+	}
+
+	var buf bytes.Buffer
+	if err := writeISO(&buf, Label, files, time.Now()); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Error writing the cloud-init seed %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package cloudinit
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readISO returns the label of the ISO at path, and its files by their
+// Joliet names.
+func readISO(t *testing.T, path string) (string, map[string]string) {
+	image, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sector := func(n uint32) []byte {
+		return image[n*sectorSize : (n+1)*sectorSize]
+	}
+
+	primary := sector(primaryDescriptorSector)
+	assert.Equal(t, "CD001", string(primary[1:6]))
+	label := strings.TrimSpace(string(primary[40:72]))
+
+	joliet := sector(jolietDescriptorSector)
+	assert.Equal(t, byte(2), joliet[0])
+	assert.Equal(t, "%/E", string(joliet[88:91]))
+	assert.Equal(t, uint32(len(image)/sectorSize), binary.LittleEndian.Uint32(joliet[80:]))
+
+	files := map[string]string{}
+	dir := sector(binary.LittleEndian.Uint32(joliet[156+2:]))
+	for offset := 0; offset < len(dir) && dir[offset] != 0; offset += int(dir[offset]) {
+		record := dir[offset:]
+		identifier := record[33 : 33+record[32]]
+		if record[25]&directoryFlag != 0 {
+			continue
+		}
+
+		var name []uint16
+		for i := 0; i < len(identifier); i += 2 {
+			name = append(name, binary.BigEndian.Uint16(identifier[i:]))
+		}
+		start := binary.LittleEndian.Uint32(record[2:]) * sectorSize
+		size := binary.LittleEndian.Uint32(record[10:])
+		files[string(utf16.Decode(name))] = string(image[start : start+size])
+	}
+
+	return label, files
+}
+
+func TestWriteSeedISO(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudinit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "seed.iso")
+	err = WriteSeedISO(path, &Config{
+		Hostname:      "default",
+		User:          "docker",
+		AuthorizedKey: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKey docker\n",
+		Interfaces: []Interface{
+			{Name: "eth0", MACAddress: "080027AABBCC"},
+			{Name: "eth1", MACAddress: "08:00:27:dd:ee:ff"},
+		},
+	})
+	assert.NoError(t, err)
+
+	label, files := readISO(t, path)
+	assert.Equal(t, "cidata", label)
+	assert.Equal(t, 3, len(files))
+	assert.Equal(t, "instance-id: default\nlocal-hostname: default\n", files["meta-data;1"])
+
+	userData := files["user-data;1"]
+	assert.True(t, strings.HasPrefix(userData, "#cloud-config\n"))
+	assert.Contains(t, userData, "  - name: docker\n")
+	assert.Contains(t, userData, "      - ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKey docker\n")
+
+	networkConfig := files["network-config;1"]
+	assert.Contains(t, networkConfig, "      macaddress: \"08:00:27:aa:bb:cc\"\n    set-name: eth0\n")
+	assert.Contains(t, networkConfig, "      macaddress: \"08:00:27:dd:ee:ff\"\n    set-name: eth1\n")
+}
+
+func TestWriteSeedISOWithoutInterfaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudinit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "seed.iso")
+	assert.NoError(t, WriteSeedISO(path, &Config{Hostname: "default", User: "docker", AuthorizedKey: "ssh-rsa AAAA"}))
+
+	_, files := readISO(t, path)
+	assert.Equal(t, 2, len(files))
+	_, ok := files["network-config;1"]
+	assert.False(t, ok)
+
+	assert.Error(t, WriteSeedISO(path, &Config{Hostname: "default", User: "docker"}))
+}
+
+func TestNetworkConfigStatic(t *testing.T) {
+	c := &Config{
+		Interfaces: []Interface{
+			{Name: "eth0", MACAddress: "00155D010203", Address: "192.168.250.2/24", Gateway: "192.168.250.1", Nameserver: "8.8.8.8"},
+		},
+	}
+
+	assert.Equal(t, `version: 2
+ethernets:
+  eth0:
+    match:
+      macaddress: "00:15:5d:01:02:03"
+    set-name: eth0
+    addresses: [192.168.250.2/24]
+    gateway4: 192.168.250.1
+    nameservers:
+      addresses: [8.8.8.8]
+`, string(c.NetworkConfig()))
+}
+
+func TestFormatMACAddress(t *testing.T) {
+	assert.Equal(t, "08:00:27:aa:bb:cc", FormatMACAddress("080027AABBCC"))
+	assert.Equal(t, "00:15:5d:01:02:03", FormatMACAddress("00-15-5D-01-02-03"))
+	assert.Equal(t, "08:00:27:aa:bb:cc", FormatMACAddress("08:00:27:aa:bb:cc"))
+}
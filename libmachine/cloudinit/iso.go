@@ -0,0 +1,254 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// The seed ISO is written by hand rather than with genisoimage or mkisofs,
+// which few hosts have: an ISO 9660 image, see ECMA-119, with the Joliet
+// extension for the lowercase names cloud-init looks for, all files in its
+// root directory.
+
+const (
+	sectorSize = 2048
+
+	// The sectors of the image: the system area, the volume descriptors,
+	// the path tables and the root directories of both name spaces, then
+	// the files.
+	primaryDescriptorSector   = 16
+	jolietDescriptorSector    = 17
+	terminatorSector          = 18
+	primaryLPathTableSector   = 19
+	primaryMPathTableSector   = 20
+	jolietLPathTableSector    = 21
+	jolietMPathTableSector    = 22
+	primaryRootSector         = 23
+	jolietRootSector          = 24
+	firstFileSector           = 25
+	pathTableSize             = 10
+	directoryRecordHeaderSize = 33
+	directoryFlag             = 2
+)
+
+// isoFile is a file of the root directory of an ISO.
+type isoFile struct {
+	name   string
+	data   []byte
+	sector uint32
+}
+
+// writeISO writes an ISO image with the given volume label and files, by
+// name, to w.
+func writeISO(w io.Writer, label string, files map[string][]byte, modTime time.Time) error {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sector := uint32(firstFileSector)
+	var entries []isoFile
+	for _, name := range names {
+		data := files[name]
+		entries = append(entries, isoFile{name: name, data: data, sector: sector})
+		sector += sectors(len(data))
+	}
+	totalSectors := sector
+
+	image := make([]byte, int(totalSectors)*sectorSize)
+	sectorAt := func(n uint32) []byte {
+		return image[int(n)*sectorSize : int(n+1)*sectorSize]
+	}
+
+	primaryRoot, err := directory(entries, primaryRootSector, modTime, primaryName)
+	if err != nil {
+		return err
+	}
+	jolietRoot, err := directory(entries, jolietRootSector, modTime, jolietName)
+	if err != nil {
+		return err
+	}
+	copy(sectorAt(primaryRootSector), primaryRoot)
+	copy(sectorAt(jolietRootSector), jolietRoot)
+
+	volumeDescriptor(sectorAt(primaryDescriptorSector), 1, label, totalSectors, primaryLPathTableSector, primaryMPathTableSector, primaryRootSector, modTime)
+	volumeDescriptor(sectorAt(jolietDescriptorSector), 2, label, totalSectors, jolietLPathTableSector, jolietMPathTableSector, jolietRootSector, modTime)
+
+	terminator := sectorAt(terminatorSector)
+	terminator[0] = 255
+	copy(terminator[1:], "CD001")
+	terminator[6] = 1
+
+	pathTable(sectorAt(primaryLPathTableSector), binary.LittleEndian, primaryRootSector)
+	pathTable(sectorAt(primaryMPathTableSector), binary.BigEndian, primaryRootSector)
+	pathTable(sectorAt(jolietLPathTableSector), binary.LittleEndian, jolietRootSector)
+	pathTable(sectorAt(jolietMPathTableSector), binary.BigEndian, jolietRootSector)
+
+	for _, entry := range entries {
+		copy(image[int(entry.sector)*sectorSize:], entry.data)
+	}
+
+	_, err = w.Write(image)
+	return err
+}
+
+// sectors returns the number of sectors size bytes take.
+func sectors(size int) uint32 {
+	return uint32((size + sectorSize - 1) / sectorSize)
+}
+
+// primaryName returns the identifier of a file in the ISO 9660 name space,
+// where names are uppercase.
+func primaryName(name string) []byte {
+	upper := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+	return []byte(upper + ".;1")
+}
+
+// jolietName returns the identifier of a file in the Joliet name space,
+// where names are in UCS-2.
+func jolietName(name string) []byte {
+	return ucs2(name + ";1")
+}
+
+func ucs2(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return b
+}
+
+// directory returns the root directory with the files, named in one of the
+// name spaces.
+func directory(entries []isoFile, sector uint32, modTime time.Time, name func(string) []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(directoryRecord([]byte{0}, sector, sectorSize, directoryFlag, modTime))
+	buf.Write(directoryRecord([]byte{1}, sector, sectorSize, directoryFlag, modTime))
+	for _, entry := range entries {
+		buf.Write(directoryRecord(name(entry.name), entry.sector, uint32(len(entry.data)), 0, modTime))
+	}
+
+	if buf.Len() > sectorSize {
+		return nil, fmt.Errorf("Too many files for an ISO image: %d", len(entries))
+	}
+	return buf.Bytes(), nil
+}
+
+// directoryRecord returns the record of a file or directory.
+func directoryRecord(identifier []byte, sector, size uint32, flags byte, modTime time.Time) []byte {
+	length := directoryRecordHeaderSize + len(identifier)
+	if length%2 == 1 {
+		length++
+	}
+
+	record := make([]byte, length)
+	record[0] = byte(length)
+	bothEndian32(record[2:], sector)
+	bothEndian32(record[10:], size)
+	recordingTime(record[18:], modTime)
+	record[25] = flags
+	bothEndian16(record[28:], 1)
+	record[32] = byte(len(identifier))
+	copy(record[33:], identifier)
+
+	return record
+}
+
+// volumeDescriptor writes the primary (kind 1) or Joliet supplementary
+// (kind 2) volume descriptor to b.
+func volumeDescriptor(b []byte, kind byte, label string, totalSectors, lPathTable, mPathTable, rootSector uint32, modTime time.Time) {
+	text := func(s string, size int) []byte {
+		return []byte(fmt.Sprintf("%-*s", size, s))
+	}
+	if kind == 2 {
+		text = func(s string, size int) []byte {
+			return ucs2(fmt.Sprintf("%-*s", size/2, s))
+		}
+	}
+
+	b[0] = kind
+	copy(b[1:], "CD001")
+	b[6] = 1
+	copy(b[8:40], text("LINUX", 32))
+	copy(b[40:72], text(label, 32))
+	bothEndian32(b[80:], totalSectors)
+	if kind == 2 {
+		// UCS-2 level 3.
+		copy(b[88:], "%/E")
+	}
+	bothEndian16(b[120:], 1)
+	bothEndian16(b[124:], 1)
+	bothEndian16(b[128:], sectorSize)
+	bothEndian32(b[132:], pathTableSize)
+	binary.LittleEndian.PutUint32(b[140:], lPathTable)
+	binary.BigEndian.PutUint32(b[148:], mPathTable)
+	copy(b[156:190], directoryRecord([]byte{0}, rootSector, sectorSize, directoryFlag, modTime))
+	copy(b[190:318], text("", 128))
+	copy(b[318:446], text("", 128))
+	copy(b[446:574], text("", 128))
+	copy(b[574:702], text("DOCKER MACHINE", 128))
+	copy(b[702:739], text("", 37))
+	copy(b[739:776], text("", 37))
+	copy(b[776:813], text("", 37))
+	volumeTime(b[813:], modTime)
+	volumeTime(b[830:], modTime)
+	volumeTime(b[847:], time.Time{})
+	volumeTime(b[864:], time.Time{})
+	b[881] = 1
+}
+
+// pathTable writes the path table of the root directory to b.
+func pathTable(b []byte, order binary.ByteOrder, rootSector uint32) {
+	b[0] = 1
+	order.PutUint32(b[2:], rootSector)
+	order.PutUint16(b[6:], 1)
+}
+
+func bothEndian16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b, v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+func bothEndian32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
+
+// recordingTime writes the time of a directory record to b.
+func recordingTime(b []byte, t time.Time) {
+	t = t.UTC()
+	b[0] = byte(t.Year() - 1900)
+	b[1] = byte(t.Month())
+	b[2] = byte(t.Day())
+	b[3] = byte(t.Hour())
+	b[4] = byte(t.Minute())
+	b[5] = byte(t.Second())
+	b[6] = 0
+}
+
+// volumeTime writes the time of a volume descriptor to b, all zeros for
+// the zero time.
+func volumeTime(b []byte, t time.Time) {
+	if t.IsZero() {
+		copy(b, "0000000000000000")
+		b[16] = 0
+		return
+	}
+	copy(b, t.UTC().Format("20060102150405")+"00")
+	b[16] = 0
+}
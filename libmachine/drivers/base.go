@@ -12,11 +12,26 @@ type BaseDriver struct {
 	SSHUser        string
 	SSHPass        string
 	SSHPort        int
+	SSHBastion     string
+	SSHBastionKey  string
+	SSHKeyType     string
 	MachineName    string
 	SwarmMaster    bool
 	SwarmHost      string
 	SwarmDiscovery string
 	StorePath      string
+	// Networks are the networks the host is attached to besides its
+	// default one, with the --network flag, see NetworkAttacher.
+	Networks []string
+	// AttachVolume is the volume created with the host and attached to it,
+	// with the --attach-volume flag, see VolumeAttacher.
+	AttachVolume *Volume
+	// ResourceTags are the tags of the cloud resources created for the
+	// host, given with the --tag flag, see ParseResourceTags.
+	ResourceTags map[string]string
+	// SSHOptions are options of ssh, as Key=Value, set with
+	// `docker-machine update --ssh-option`, see SSHOptionsGetter.
+	SSHOptions []string
 }
 
 // GetSSHKeyPath -
@@ -62,6 +77,28 @@ func (d *BaseDriver) GetSSHPassword() string {
 	return d.SSHPass
 }
 
+// GetVolume returns the volume given with --attach-volume, if any.
+func (d *BaseDriver) GetVolume() *Volume {
+	return d.AttachVolume
+}
+
+// GetSSHBastion returns the jump host to connect to the host through, if
+// any
+func (d *BaseDriver) GetSSHBastion() string {
+	return d.SSHBastion
+}
+
+// GetSSHBastionKeyPath returns the key to authenticate to the jump host
+// with, if it doesn't accept the one of the host
+func (d *BaseDriver) GetSSHBastionKeyPath() string {
+	return d.SSHBastionKey
+}
+
+// GetSSHOptions returns the options of ssh to connect to the host with
+func (d *BaseDriver) GetSSHOptions() []string {
+	return d.SSHOptions
+}
+
 // SSHSudo formats the command to pipe the password to sudo
 func (d *BaseDriver) SSHSudo(command string) string {
 	sudo := "sudo"
@@ -0,0 +1,79 @@
+package drivers
+
+// Capabilities are the optional features of a driver, for its users to tell
+// what they can do with its hosts before trying. Some depend on the options
+// of the driver, such as the kind of Azure machines, in which case they are
+// those of its hosts with the options given.
+type Capabilities struct {
+	// Suspend tells whether hosts can be suspended and resumed
+	Suspend bool
+
+	// Snapshots tells whether hosts can have snapshots taken and restored
+	Snapshots bool
+
+	// Resize tells whether the resources of hosts can be changed
+	Resize bool
+
+	// Networks tells whether hosts can be attached to several networks
+	Networks bool
+
+	// Volumes tells whether volumes can be attached to hosts
+	Volumes bool
+
+	// IPv6 tells whether hosts can have an IPv6 address
+	IPv6 bool
+
+	// StaticIP tells whether hosts can keep an address across restarts
+	// and recreations, such as an elastic, reserved or floating IP
+	StaticIP bool
+
+	// UserData tells whether hosts can be given user data, such as a
+	// cloud-init configuration
+	UserData bool
+
+	// Spot tells whether hosts can run on spot or preemptible instances
+	Spot bool
+
+	// Tags tells whether the cloud resources created for hosts are tagged
+	// with the tags given with --tag
+	Tags bool
+}
+
+// CapabilitiesGetter is an optional interface for drivers with capabilities
+// which are options of theirs, rather than optional interfaces, such as
+// spot instances. They start from ProbeCapabilities.
+type CapabilitiesGetter interface {
+	Capabilities() Capabilities
+}
+
+// GetCapabilities returns the capabilities of the driver.
+func GetCapabilities(d Driver) Capabilities {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if getter, ok := d.(CapabilitiesGetter); ok {
+		return getter.Capabilities()
+	}
+
+	return ProbeCapabilities(d)
+}
+
+// ProbeCapabilities returns the capabilities of the driver which come from the
+// optional interfaces it implements.
+func ProbeCapabilities(d Driver) Capabilities {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	_, ipv6 := d.(IPv6Getter)
+
+	return Capabilities{
+		Suspend:   SupportsSuspend(d),
+		Snapshots: SupportsSnapshots(d),
+		Resize:    SupportsResize(d),
+		Networks:  SupportsNetworks(d),
+		Volumes:   SupportsVolumes(d),
+		IPv6:      ipv6,
+	}
+}
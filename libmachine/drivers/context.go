@@ -0,0 +1,85 @@
+package drivers
+
+import (
+	"encoding/json"
+
+	"golang.org/x/net/context"
+)
+
+// contextDriver is a driver bound to a context, see WithContext.
+type contextDriver struct {
+	Driver
+	ctx context.Context
+}
+
+// WithContext returns a driver which gives up on the operations which can
+// take a long time, such as Create, Start or the SSH commands run with
+// RunSSHCommandFromDriver, as soon as ctx is done. An SSH command which is
+// given up on is killed. Driver plugins are not told about it, so a call
+// given up on keeps going in the plugin until the plugin is closed.
+func WithContext(ctx context.Context, d Driver) Driver {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	return &contextDriver{
+		Driver: d,
+		ctx:    ctx,
+	}
+}
+
+// contextOf returns the context a driver was bound to with WithContext.
+func contextOf(d Driver) context.Context {
+	if cd, ok := d.(*contextDriver); ok {
+		return cd.ctx
+	}
+	return context.Background()
+}
+
+// MarshalJSON hides the wrapper from code inspecting the configuration of
+// the driver.
+func (d *contextDriver) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Driver)
+}
+
+func (d *contextDriver) run(f func() error) error {
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- f()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	}
+}
+
+func (d *contextDriver) Create() error {
+	return d.run(d.Driver.Create)
+}
+
+func (d *contextDriver) Start() error {
+	return d.run(d.Driver.Start)
+}
+
+func (d *contextDriver) Stop() error {
+	return d.run(d.Driver.Stop)
+}
+
+func (d *contextDriver) Restart() error {
+	return d.run(d.Driver.Restart)
+}
+
+func (d *contextDriver) Kill() error {
+	return d.run(d.Driver.Kill)
+}
+
+func (d *contextDriver) Remove() error {
+	return d.run(d.Driver.Remove)
+}
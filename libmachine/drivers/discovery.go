@@ -0,0 +1,108 @@
+package drivers
+
+// Discoverer is an optional interface for drivers which can list the regions
+// they create hosts in, and the images and sizes available in a region, for
+// the account they are configured with, for users to find out the values of
+// their flags before a create fails.
+type Discoverer interface {
+	// DiscoveryFlags returns the create flags the values listed are for
+	DiscoveryFlags() DiscoveryFlags
+
+	// ListRegions returns the regions hosts can be created in
+	ListRegions() ([]Option, error)
+
+	// ListImages returns the images hosts can be created from in the
+	// region
+	ListImages(region string) ([]Option, error)
+
+	// ListSizes returns the sizes, instance types or plans hosts can have
+	// in the region
+	ListSizes(region string) ([]Option, error)
+}
+
+// DiscoveryChecker is the Discoverer counterpart of SuspendChecker.
+type DiscoveryChecker interface {
+	SupportsDiscovery() bool
+}
+
+// DiscoveryFlags are the create flags of the region, the image and the size
+// of the hosts of a driver, e.g. "amazonec2-region", "amazonec2-ami" and
+// "amazonec2-instance-type".
+type DiscoveryFlags struct {
+	Region string
+	Image  string
+	Size   string
+}
+
+// SupportsDiscovery reports whether the driver can list its regions, images
+// and sizes.
+func SupportsDiscovery(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(Discoverer); !ok {
+		return false
+	}
+
+	if checker, ok := d.(DiscoveryChecker); ok {
+		return checker.SupportsDiscovery()
+	}
+
+	return true
+}
+
+func getDiscoverer(d Driver) (Discoverer, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	discoverer, ok := d.(Discoverer)
+	if !ok || !SupportsDiscovery(d) {
+		return nil, ErrDiscoveryNotImplemented
+	}
+
+	return discoverer, nil
+}
+
+// GetDiscoveryFlags returns the create flags of the region, the image and the
+// size of the hosts, none for the drivers which can't list them.
+func GetDiscoveryFlags(d Driver) DiscoveryFlags {
+	discoverer, err := getDiscoverer(d)
+	if err != nil {
+		return DiscoveryFlags{}
+	}
+
+	return discoverer.DiscoveryFlags()
+}
+
+// ListRegions returns the regions the driver can create hosts in.
+func ListRegions(d Driver) ([]Option, error) {
+	discoverer, err := getDiscoverer(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return discoverer.ListRegions()
+}
+
+// ListImages returns the images the driver can create hosts from in the
+// region.
+func ListImages(d Driver, region string) ([]Option, error) {
+	discoverer, err := getDiscoverer(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return discoverer.ListImages(region)
+}
+
+// ListSizes returns the sizes the driver can create hosts with in the region.
+func ListSizes(d Driver, region string) ([]Option, error) {
+	discoverer, err := getDiscoverer(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return discoverer.ListSizes(region)
+}
@@ -2,9 +2,12 @@ package drivers
 
 import (
 	"errors"
+	"time"
 
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
 
@@ -78,7 +81,678 @@ type Driver interface {
 	Stop() error
 }
 
-var ErrHostIsNotRunning = errors.New("Host is not running")
+// Suspender is an optional interface for drivers which can save the
+// state of a running host to disk and later resume it exactly where it
+// left off, which is much faster than a full stop and start.
+type Suspender interface {
+	// Suspend saves the state of the host and stops it
+	Suspend() error
+
+	// Resume restores a suspended host
+	Resume() error
+}
+
+// SuspendChecker is implemented by drivers which always satisfy Suspender,
+// such as the RPC client, but have to ask the driver they wrap whether it
+// actually supports it.
+type SuspendChecker interface {
+	SupportsSuspend() bool
+}
+
+// Snapshotter is an optional interface for drivers which can checkpoint a
+// host with the hypervisor's own snapshot facility.
+type Snapshotter interface {
+	// CreateSnapshot saves the current state of the host under name
+	CreateSnapshot(name string) error
+
+	// RestoreSnapshot reverts the host to the snapshot called name
+	RestoreSnapshot(name string) error
+
+	// RemoveSnapshot deletes the snapshot called name
+	RemoveSnapshot(name string) error
+}
+
+// SnapshotChecker is the Snapshotter counterpart of SuspendChecker.
+type SnapshotChecker interface {
+	SupportsSnapshots() bool
+}
+
+// StateReasoner is an optional interface for drivers whose hosts can change
+// state without the user asking, such as spot instances reclaimed by their
+// cloud provider, to tell why.
+type StateReasoner interface {
+	// GetStateReason returns why the host is in its current state, or an
+	// empty string when there is nothing to tell
+	GetStateReason() (string, error)
+}
+
+// IPv6Getter is an optional interface for drivers of dual-stack hosts, which
+// have an IPv6 address besides the IPv4 one GetIP returns, for it to be in
+// their certificate too. The drivers of IPv6-only hosts return their IPv6
+// address from GetIP.
+type IPv6Getter interface {
+	// GetIPv6 returns the IPv6 address of the host, or an empty string if
+	// it has none
+	GetIPv6() (string, error)
+}
+
+// WaitTuner is an optional interface for drivers whose hosts take much
+// longer than usual to boot, such as bare-metal servers, to ask for longer
+// waits for them to be running and reachable over SSH.
+type WaitTuner interface {
+	WaitTimeouts() WaitTimeouts
+}
+
+// SSHBastionGetter is implemented by drivers whose hosts may only be
+// reachable over SSH through a jump host, such as the ones on private
+// subnets. BaseDriver implements it with the --ssh-bastion flag.
+type SSHBastionGetter interface {
+	// GetSSHBastion returns the jump host, as [user@]host[:port], or an
+	// empty string to connect directly
+	GetSSHBastion() string
+
+	// GetSSHBastionKeyPath returns the key to authenticate to the jump
+	// host with besides the one of the host, if any
+	GetSSHBastionKeyPath() string
+}
+
+// SSHOptionsGetter is implemented by drivers whose hosts are connected to
+// with options of ssh, given by the user, such as ones their SSH servers
+// need. BaseDriver implements it with the options set with
+// `docker-machine update --ssh-option`.
+type SSHOptionsGetter interface {
+	// GetSSHOptions returns the options, as Key=Value
+	GetSSHOptions() []string
+}
+
+// NetworkAttacher is an optional interface for drivers which can attach
+// hosts to networks besides their default one, given with the --network
+// flag, such as a data network next to the management network of a swarm.
+// Drivers map the networks to what their hypervisor or provider has: extra
+// network adapters, networks or subnets.
+type NetworkAttacher interface {
+	// GetAddresses returns the addresses of the host on its networks, the
+	// default one first
+	GetAddresses() ([]Address, error)
+}
+
+// NetworkChecker is the NetworkAttacher counterpart of SuspendChecker.
+type NetworkChecker interface {
+	SupportsNetworks() bool
+}
+
+// VolumeAttacher is an optional interface for drivers which can create the
+// volume given with --attach-volume and attach it to hosts, for the
+// provisioner to format and mount it before installing the engine. Drivers
+// create the volume in Create, and delete it with the host. BaseDriver
+// implements GetVolume.
+type VolumeAttacher interface {
+	// GetVolume returns the volume attached to the host, or nil if there
+	// is none
+	GetVolume() *Volume
+
+	// GetVolumeDevices returns the paths the block device of the volume
+	// may have on the host, as they depend on its hardware with some
+	// providers. The first one existing is used.
+	GetVolumeDevices() ([]string, error)
+}
+
+// VolumeChecker is the VolumeAttacher counterpart of SuspendChecker.
+type VolumeChecker interface {
+	SupportsVolumes() bool
+}
+
+// Planner is an optional interface for drivers which can tell the resources
+// they would allocate to create a host once configured from flags, without
+// allocating any, for create --dry-run.
+type Planner interface {
+	Plan() ([]PlannedResource, error)
+}
+
+// PlanChecker is the Planner counterpart of SuspendChecker.
+type PlanChecker interface {
+	SupportsPlan() bool
+}
+
+// PlannedResource is a resource a driver would allocate to create a host.
+type PlannedResource struct {
+	// Kind is the kind of resource, e.g. "instance" or "disk"
+	Kind string
+
+	// Description tells what the resource would be, e.g. its type, image
+	// or size
+	Description string
+}
+
+// TerraformExporter is an optional interface for drivers which can describe
+// the cloud resources they created for a host as Terraform resources, for
+// teams to import them into Terraform without recreating the host.
+type TerraformExporter interface {
+	TerraformResources() ([]TerraformResource, error)
+}
+
+// TerraformChecker is the TerraformExporter counterpart of SuspendChecker.
+type TerraformChecker interface {
+	SupportsTerraform() bool
+}
+
+// Adopter is an optional interface for drivers which can register a host
+// already running an engine, without provisioning it, for its engine to be
+// used with the certificates it already accepts. Machine leaves adopted
+// hosts as they are: it neither provisions, restarts nor stops them.
+type Adopter interface {
+	// Adopted tells whether the host was adopted
+	Adopted() bool
+}
+
+// GarbageCollector is an optional interface for drivers which can find the
+// cloud resources tagged with the machine-name tag in the account and region
+// they are configured for, for gc to remove those left behind by machines
+// which no longer exist, such as the ones of failed creates. Only the
+// resources tagged for the store gc runs on are collected, the account being
+// possibly shared with other stores.
+type GarbageCollector interface {
+	// OrphanedResources returns the resources tagged for the store of
+	// storeID whose machine isn't one of machines, in the order to remove
+	// them in
+	OrphanedResources(storeID string, machines []string) ([]OrphanedResource, error)
+
+	// RemoveOrphanedResource removes a resource OrphanedResources returned
+	RemoveOrphanedResource(resource OrphanedResource) error
+}
+
+// GarbageCollectorChecker is the GarbageCollector counterpart of
+// SuspendChecker.
+type GarbageCollectorChecker interface {
+	SupportsGarbageCollection() bool
+}
+
+// OrphanedResource is a cloud resource tagged for a machine which no longer
+// exists.
+type OrphanedResource struct {
+	// Kind is the kind of resource, e.g. "instance" or "key-pair"
+	Kind string
+
+	// ID identifies the resource for RemoveOrphanedResource
+	ID string
+
+	// Machine is the machine the resource is tagged for
+	Machine string
+}
+
+// Pricer is an optional interface for drivers whose hosts are billed by
+// their provider, telling what the price of a host depends on, for ls
+// --show-cost to estimate it.
+type Pricer interface {
+	Pricing() (Pricing, error)
+}
+
+// PricingChecker is the Pricer counterpart of SuspendChecker.
+type PricingChecker interface {
+	SupportsPricing() bool
+}
+
+// Pricing is what the price of a host depends on.
+type Pricing struct {
+	// Region is where the host runs, e.g. "us-east-1"
+	Region string
+
+	// InstanceType is the instance type, machine type, size or plan of the
+	// host
+	InstanceType string
+
+	// Spot tells whether the host runs on a spot or preemptible instance
+	Spot bool
+
+	// Storage is the storage billed apart from the instance, such as its
+	// disks and volumes
+	Storage []PricedStorage
+}
+
+// PricedStorage is storage billed by its size.
+type PricedStorage struct {
+	// Type is the storage type of the provider, e.g. "gp2"
+	Type string
+
+	// Size is in GB
+	Size int
+}
+
+// Resizer is an optional interface for drivers which can change the
+// resources of a host after its creation: the CPUs, memory and disk of a VM,
+// or the instance type of a cloud instance. Hosts are stopped before being
+// resized.
+type Resizer interface {
+	// Resize changes the resources of the host given in opts, and updates
+	// the configuration of the driver to match
+	Resize(opts ResizeOptions) error
+}
+
+// ResizeChecker is the Resizer counterpart of SuspendChecker.
+type ResizeChecker interface {
+	SupportsResize() bool
+}
+
+// ResizeOptions are the resources to resize a host to. Zero values are left
+// unchanged.
+type ResizeOptions struct {
+	CPUs int
+
+	// Memory is in MB
+	Memory int
+
+	// DiskSize is in MB. Disks can only grow.
+	DiskSize int
+
+	InstanceType string
+}
+
+// IsZero reports whether the options leave everything unchanged.
+func (o ResizeOptions) IsZero() bool {
+	return o.CPUs == 0 && o.Memory == 0 && o.DiskSize == 0 && o.InstanceType == ""
+}
+
+// Address is the address of a host on one of its networks.
+type Address struct {
+	Network string
+	IP      string
+}
+
+// DefaultNetwork names the network the address of a host is on, for drivers
+// which don't name it.
+const DefaultNetwork = "default"
+
+// WaitTimeouts are how long to wait for a host to be running, and then for
+// SSH to be available on it. A zero value stands for DefaultWaitTimeout.
+type WaitTimeouts struct {
+	Running time.Duration
+	SSH     time.Duration
+}
+
+// DefaultWaitTimeout is how long the waits of drivers which don't tune them
+// last.
+const DefaultWaitTimeout = 3 * time.Minute
+
+var (
+	ErrHostIsNotRunning        = errors.New("Host is not running")
+	ErrSuspendNotImplemented   = errors.New("Driver does not support suspend and resume")
+	ErrSnapshotNotImplemented  = errors.New("Driver does not support snapshots")
+	ErrNetworksNotImplemented  = errors.New("Driver does not support attaching machines to several networks")
+	ErrResizeNotImplemented    = errors.New("Driver does not support resizing machines")
+	ErrVolumesNotImplemented   = errors.New("Driver does not support attaching volumes")
+	ErrPlanNotImplemented      = errors.New("Driver does not support planning the creation of machines")
+	ErrTerraformNotImplemented = errors.New("Driver does not support exporting machines to Terraform")
+	ErrTagsNotImplemented      = errors.New("Driver does not support tagging the resources of machines")
+	ErrUserDataNotImplemented  = errors.New("Driver does not support giving user data to machines")
+	ErrPricingNotImplemented   = errors.New("Driver does not support estimating the cost of machines")
+	ErrGCNotImplemented        = errors.New("Driver does not support finding the orphaned resources of machines")
+	ErrTransportNotImplemented = errors.New("Driver does not support running commands on machines otherwise than over SSH")
+	ErrGuideNotImplemented     = errors.New("Driver does not support guiding the interactive creation of machines")
+	ErrDiscoveryNotImplemented = errors.New("Driver does not support listing its regions, images and sizes")
+
+	// ErrResizeInstanceTypeOnly is returned by the Resizers of cloud
+	// drivers, which resize hosts by changing their instance type only.
+	ErrResizeInstanceTypeOnly = errors.New("Driver resizes machines by changing their instance type only")
+
+	// ErrResizeNoInstanceTypes is returned by the Resizers of drivers
+	// which have no instance types to resize hosts to.
+	ErrResizeNoInstanceTypes = errors.New("Driver has no instance types, resize machines by CPUs, memory or disk size instead")
+)
+
+// SupportsSuspend reports whether the driver can suspend and resume hosts.
+func SupportsSuspend(d Driver) bool {
+	if _, ok := d.(Suspender); !ok {
+		return false
+	}
+
+	if checker, ok := d.(SuspendChecker); ok {
+		return checker.SupportsSuspend()
+	}
+
+	return true
+}
+
+// SupportsSnapshots reports whether the driver can take snapshots of hosts.
+func SupportsSnapshots(d Driver) bool {
+	if _, ok := d.(Snapshotter); !ok {
+		return false
+	}
+
+	if checker, ok := d.(SnapshotChecker); ok {
+		return checker.SupportsSnapshots()
+	}
+
+	return true
+}
+
+// SupportsResize reports whether the driver can resize hosts.
+func SupportsResize(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(Resizer); !ok {
+		return false
+	}
+
+	if checker, ok := d.(ResizeChecker); ok {
+		return checker.SupportsResize()
+	}
+
+	return true
+}
+
+// SupportsPlan reports whether the driver can tell the resources it would
+// allocate to create a host.
+func SupportsPlan(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(Planner); !ok {
+		return false
+	}
+
+	if checker, ok := d.(PlanChecker); ok {
+		return checker.SupportsPlan()
+	}
+
+	return true
+}
+
+// GetPlan returns the resources the driver would allocate to create a host.
+func GetPlan(d Driver) ([]PlannedResource, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	planner, ok := d.(Planner)
+	if !ok || !SupportsPlan(d) {
+		return nil, ErrPlanNotImplemented
+	}
+
+	return planner.Plan()
+}
+
+// SupportsTerraform reports whether the driver can describe the resources of
+// hosts as Terraform resources.
+func SupportsTerraform(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(TerraformExporter); !ok {
+		return false
+	}
+
+	if checker, ok := d.(TerraformChecker); ok {
+		return checker.SupportsTerraform()
+	}
+
+	return true
+}
+
+// GetTerraformResources returns the resources the driver created for the
+// host, as Terraform resources.
+func GetTerraformResources(d Driver) ([]TerraformResource, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	exporter, ok := d.(TerraformExporter)
+	if !ok || !SupportsTerraform(d) {
+		return nil, ErrTerraformNotImplemented
+	}
+
+	return exporter.TerraformResources()
+}
+
+// SupportsGarbageCollection reports whether the driver can find the
+// orphaned resources of machines.
+func SupportsGarbageCollection(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(GarbageCollector); !ok {
+		return false
+	}
+
+	if checker, ok := d.(GarbageCollectorChecker); ok {
+		return checker.SupportsGarbageCollection()
+	}
+
+	return true
+}
+
+// GetOrphanedResources returns the resources tagged for machines of the
+// store of storeID which aren't one of machines.
+func GetOrphanedResources(d Driver, storeID string, machines []string) ([]OrphanedResource, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	collector, ok := d.(GarbageCollector)
+	if !ok || !SupportsGarbageCollection(d) {
+		return nil, ErrGCNotImplemented
+	}
+
+	return collector.OrphanedResources(storeID, machines)
+}
+
+// RemoveOrphanedResource removes a resource GetOrphanedResources returned.
+func RemoveOrphanedResource(d Driver, resource OrphanedResource) error {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	collector, ok := d.(GarbageCollector)
+	if !ok || !SupportsGarbageCollection(d) {
+		return ErrGCNotImplemented
+	}
+
+	return collector.RemoveOrphanedResource(resource)
+}
+
+// SupportsPricing reports whether the driver can tell what the price of
+// hosts depends on.
+func SupportsPricing(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(Pricer); !ok {
+		return false
+	}
+
+	if checker, ok := d.(PricingChecker); ok {
+		return checker.SupportsPricing()
+	}
+
+	return true
+}
+
+// GetPricing returns what the price of the host depends on.
+func GetPricing(d Driver) (Pricing, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	pricer, ok := d.(Pricer)
+	if !ok || !SupportsPricing(d) {
+		return Pricing{}, ErrPricingNotImplemented
+	}
+
+	return pricer.Pricing()
+}
+
+// SupportsVolumes reports whether the driver can attach volumes to hosts.
+func SupportsVolumes(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(VolumeAttacher); !ok {
+		return false
+	}
+
+	if checker, ok := d.(VolumeChecker); ok {
+		return checker.SupportsVolumes()
+	}
+
+	return true
+}
+
+// GetVolume returns the volume attached to the host of the driver, or nil
+// if there is none or the driver can't attach volumes.
+func GetVolume(d Driver) *Volume {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if attacher, ok := d.(VolumeAttacher); ok && SupportsVolumes(d) {
+		return attacher.GetVolume()
+	}
+
+	return nil
+}
+
+// GetVolumeDevices returns the paths the block device of the volume attached
+// to the host of the driver may have.
+func GetVolumeDevices(d Driver) ([]string, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	attacher, ok := d.(VolumeAttacher)
+	if !ok || !SupportsVolumes(d) {
+		return nil, ErrVolumesNotImplemented
+	}
+
+	return attacher.GetVolumeDevices()
+}
+
+// SupportsNetworks reports whether the driver can attach hosts to several
+// networks.
+func SupportsNetworks(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(NetworkAttacher); !ok {
+		return false
+	}
+
+	if checker, ok := d.(NetworkChecker); ok {
+		return checker.SupportsNetworks()
+	}
+
+	return true
+}
+
+// GetAddresses returns the addresses of the host of the driver on its
+// networks, only the one on the default network for drivers which can't
+// attach hosts to several networks.
+func GetAddresses(d Driver) ([]Address, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if attacher, ok := d.(NetworkAttacher); ok && SupportsNetworks(d) {
+		return attacher.GetAddresses()
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return nil, err
+	}
+
+	return []Address{{Network: DefaultNetwork, IP: ip}}, nil
+}
+
+// GetIPv6 returns the IPv6 address of the host of the driver besides its IPv4
+// one, or an empty string if it has none or the driver doesn't tell.
+func GetIPv6(d Driver) (string, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if getter, ok := d.(IPv6Getter); ok {
+		return getter.GetIPv6()
+	}
+
+	return "", nil
+}
+
+// GetWaitTimeouts returns how long to wait for the hosts of the driver: the
+// timeouts of the state and ssh waits set with mcnutils.ConfigureWait, or
+// else the ones the driver asks for.
+func GetWaitTimeouts(d Driver) WaitTimeouts {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	timeouts := WaitTimeouts{}
+	if tuner, ok := d.(WaitTuner); ok {
+		timeouts = tuner.WaitTimeouts()
+	}
+
+	if timeouts.Running <= 0 {
+		timeouts.Running = DefaultWaitTimeout
+	}
+	if timeouts.SSH <= 0 {
+		timeouts.SSH = DefaultWaitTimeout
+	}
+
+	timeouts.Running = mcnutils.StageWaitSettings(mcnutils.WaitState, mcnutils.WaitSettings{Timeout: timeouts.Running}).Timeout
+	timeouts.SSH = mcnutils.StageWaitSettings(mcnutils.WaitSSH, mcnutils.WaitSettings{Timeout: timeouts.SSH}).Timeout
+
+	return timeouts
+}
+
+// IsAdopted tells whether the host of the driver was adopted, see Adopter.
+func IsAdopted(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	adopter, ok := d.(Adopter)
+	return ok && adopter.Adopted()
+}
+
+// GetSSHBastion returns the jump host to connect to the hosts of the driver
+// through, or nil to connect to them directly.
+func GetSSHBastion(d Driver) (*ssh.Bastion, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	getter, ok := d.(SSHBastionGetter)
+	if !ok || getter.GetSSHBastion() == "" {
+		return nil, nil
+	}
+
+	bastion, err := ssh.ParseBastion(getter.GetSSHBastion())
+	if err != nil {
+		return nil, err
+	}
+
+	bastion.KeyPath = getter.GetSSHBastionKeyPath()
+	return bastion, nil
+}
+
+// GetSSHOptions returns the options of ssh to connect to the hosts of the
+// driver with, if any.
+func GetSSHOptions(d Driver) []string {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if getter, ok := d.(SSHOptionsGetter); ok {
+		return getter.GetSSHOptions()
+	}
+	return nil
+}
 
 type DriverOptions interface {
 	String(key string) string
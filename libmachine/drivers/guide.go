@@ -0,0 +1,107 @@
+package drivers
+
+// Guide is an optional interface for drivers which can guide create
+// --interactive once configured from flags: checking the credentials they
+// were given without creating anything, and listing the values the flags
+// sizing hosts can take for the account, such as its regions, images and
+// sizes.
+type Guide interface {
+	// GuideFlags returns the create flags to ask for
+	GuideFlags() GuideFlags
+
+	// ValidateCredentials checks the provider accepts the credentials
+	ValidateCredentials() error
+
+	// ListOptions returns the values one of the option flags can take,
+	// which may depend on the value of the flags listed before it
+	ListOptions(flag string) ([]Option, error)
+}
+
+// GuideChecker is the Guide counterpart of SuspendChecker.
+type GuideChecker interface {
+	SupportsGuide() bool
+}
+
+// GuideFlags are the create flags of a driver which create --interactive
+// asks for.
+type GuideFlags struct {
+	// Credentials are the flags of the credentials, asked for first
+	Credentials []string
+
+	// Options are the flags whose values ListOptions lists, in the order
+	// to ask for them
+	Options []string
+}
+
+// Option is a value a create flag can take.
+type Option struct {
+	// Value is the value of the flag, e.g. "nyc3"
+	Value string
+
+	// Description tells what the value is, e.g. "New York 3", or is empty
+	Description string
+}
+
+// SupportsGuide reports whether the driver can guide the interactive
+// creation of hosts.
+func SupportsGuide(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(Guide); !ok {
+		return false
+	}
+
+	if checker, ok := d.(GuideChecker); ok {
+		return checker.SupportsGuide()
+	}
+
+	return true
+}
+
+func getGuide(d Driver) (Guide, error) {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	guide, ok := d.(Guide)
+	if !ok || !SupportsGuide(d) {
+		return nil, ErrGuideNotImplemented
+	}
+
+	return guide, nil
+}
+
+// GetGuideFlags returns the create flags create --interactive asks for, none
+// for the drivers which can't guide it.
+func GetGuideFlags(d Driver) GuideFlags {
+	guide, err := getGuide(d)
+	if err != nil {
+		return GuideFlags{}
+	}
+
+	return guide.GuideFlags()
+}
+
+// ValidateCredentials checks the provider accepts the credentials the driver
+// was configured with.
+func ValidateCredentials(d Driver) error {
+	guide, err := getGuide(d)
+	if err != nil {
+		return err
+	}
+
+	return guide.ValidateCredentials()
+}
+
+// ListOptions returns the values the create flag can take for the account
+// the driver was configured with.
+func ListOptions(d Driver, flag string) ([]Option, error) {
+	guide, err := getGuide(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return guide.ListOptions(flag)
+}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -84,6 +86,42 @@ func (e ErrPluginBinaryNotFound) Error() string {
 	return fmt.Sprintf("Driver %q not found. Do you have the plugin binary accessible in your PATH?", e.driverName)
 }
 
+// ListDrivers returns the names of the drivers whose plugin binary is in the
+// PATH, sorted.
+func ListDrivers() []string {
+	const prefix = "docker-machine-driver-"
+
+	found := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+
+		paths, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		if err != nil {
+			continue
+		}
+
+		for _, path := range paths {
+			if _, err := exec.LookPath(path); err != nil {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), ".exe")
+			if name != "" {
+				found[name] = true
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 func NewLocalBinaryPlugin(driverName string) (*LocalBinaryPlugin, error) {
 	binaryPath, err := exec.LookPath(fmt.Sprintf("docker-machine-driver-%s", driverName))
 	if err != nil {
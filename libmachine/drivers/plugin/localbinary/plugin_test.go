@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -153,3 +156,38 @@ func TestExecServer(t *testing.T) {
 		t.Fatalf("Error serving: %s", err)
 	}
 }
+
+func TestListDrivers(t *testing.T) {
+	first, err := ioutil.TempDir("", "machine-drivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(first)
+
+	second, err := ioutil.TempDir("", "machine-drivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(second)
+
+	files := map[string]os.FileMode{
+		filepath.Join(first, "docker-machine-driver-virtualbox"):     0755,
+		filepath.Join(first, "docker-machine-driver-notexecutable"):  0644,
+		filepath.Join(second, "docker-machine-driver-digitalocean"):  0755,
+		filepath.Join(second, "docker-machine-driver-virtualbox"):    0755,
+		filepath.Join(second, "docker-machine-something-else-again"): 0755,
+	}
+	for path, mode := range files {
+		if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	expected := []string{"digitalocean", "virtualbox"}
+	if drivers := ListDrivers(); !reflect.DeepEqual(drivers, expected) {
+		t.Fatalf("Expected %q, got %q", expected, drivers)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
 )
@@ -37,7 +38,9 @@ func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply int
 	if serviceMethod != "RpcServerDriver.Heartbeat" {
 		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
 	}
-	return ic.RpcClient.Call(serviceMethod, args, reply)
+	// The errors of the driver come as messages, from which the category
+	// they were encoded with is restored.
+	return mcnerror.Decode(ic.RpcClient.Call(serviceMethod, args, reply))
 }
 
 func NewInternalClient(rpcclient *rpc.Client) *InternalClient {
@@ -296,6 +299,318 @@ func (c *RpcClientDriver) Kill() error {
 	return c.Client.Call("RpcServerDriver.Kill", struct{}{}, nil)
 }
 
+func (c *RpcClientDriver) SupportsSuspend() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsSuspend", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for suspend support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) Suspend() error {
+	return c.Client.Call("RpcServerDriver.Suspend", struct{}{}, nil)
+}
+
+func (c *RpcClientDriver) Resume() error {
+	return c.Client.Call("RpcServerDriver.Resume", struct{}{}, nil)
+}
+
+func (c *RpcClientDriver) SupportsSnapshots() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsSnapshots", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for snapshot support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) SupportsNetworks() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsNetworks", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for network support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) SupportsResize() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsResize", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for resize support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) Resize(opts drivers.ResizeOptions) error {
+	return c.Client.Call("RpcServerDriver.Resize", opts, nil)
+}
+
+func (c *RpcClientDriver) SupportsPlan() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsPlan", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for plan support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) Plan() ([]drivers.PlannedResource, error) {
+	var resources []drivers.PlannedResource
+
+	if err := c.Client.Call("RpcServerDriver.Plan", struct{}{}, &resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+func (c *RpcClientDriver) SupportsTerraform() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsTerraform", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for Terraform support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) TerraformResources() ([]drivers.TerraformResource, error) {
+	var resources []drivers.TerraformResource
+
+	if err := c.Client.Call("RpcServerDriver.TerraformResources", struct{}{}, &resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+func (c *RpcClientDriver) SupportsGarbageCollection() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsGarbageCollection", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for garbage collection support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) OrphanedResources(storeID string, machines []string) ([]drivers.OrphanedResource, error) {
+	var resources []drivers.OrphanedResource
+
+	args := OrphanedResourcesArgs{StoreID: storeID, Machines: machines}
+	if err := c.Client.Call("RpcServerDriver.OrphanedResources", args, &resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+func (c *RpcClientDriver) RemoveOrphanedResource(resource drivers.OrphanedResource) error {
+	return c.Client.Call("RpcServerDriver.RemoveOrphanedResource", resource, nil)
+}
+
+func (c *RpcClientDriver) SupportsPricing() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsPricing", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for pricing support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) Pricing() (drivers.Pricing, error) {
+	var pricing drivers.Pricing
+
+	if err := c.Client.Call("RpcServerDriver.Pricing", struct{}{}, &pricing); err != nil {
+		return drivers.Pricing{}, err
+	}
+
+	return pricing, nil
+}
+
+func (c *RpcClientDriver) SupportsVolumes() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsVolumes", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for volume support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) GetVolume() *drivers.Volume {
+	var volume drivers.Volume
+
+	if err := c.Client.Call("RpcServerDriver.GetVolume", struct{}{}, &volume); err != nil {
+		log.Debugf("Error attempting call to get the volume: %s", err)
+		return nil
+	}
+
+	// The server replies a zero volume when there is none, as gob can't
+	// encode nil pointers.
+	if volume.Size == 0 {
+		return nil
+	}
+
+	return &volume
+}
+
+func (c *RpcClientDriver) GetVolumeDevices() ([]string, error) {
+	var devices []string
+
+	if err := c.Client.Call("RpcServerDriver.GetVolumeDevices", struct{}{}, &devices); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+func (c *RpcClientDriver) GetAddresses() ([]drivers.Address, error) {
+	var addresses []drivers.Address
+
+	if err := c.Client.Call("RpcServerDriver.GetAddresses", struct{}{}, &addresses); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// GetStateReason asks the plugin why the host is in its state. Plugins built
+// before drivers could tell have nothing to say.
+func (c *RpcClientDriver) GetStateReason() (string, error) {
+	var reason string
+
+	if err := c.Client.Call("RpcServerDriver.GetStateReason", struct{}{}, &reason); err != nil {
+		log.Debugf("Error attempting call to get the state reason: %s", err)
+		return "", nil
+	}
+
+	return reason, nil
+}
+
+// GetIPv6 asks the plugin for the IPv6 address of a dual-stack host. Plugins
+// built before drivers could tell report none.
+func (c *RpcClientDriver) GetIPv6() (string, error) {
+	var ip string
+
+	if err := c.Client.Call("RpcServerDriver.GetIPv6", struct{}{}, &ip); err != nil {
+		log.Debugf("Error attempting call to get the IPv6 address: %s", err)
+		return "", nil
+	}
+
+	return ip, nil
+}
+
+// Capabilities asks the plugin for the capabilities of its driver. Plugins
+// built before drivers could tell are asked for each optional interface
+// instead, and have none of the other capabilities.
+func (c *RpcClientDriver) Capabilities() drivers.Capabilities {
+	var capabilities drivers.Capabilities
+
+	if err := c.Client.Call("RpcServerDriver.Capabilities", struct{}{}, &capabilities); err != nil {
+		log.Debugf("Error attempting call to get the capabilities: %s", err)
+		return drivers.Capabilities{
+			Suspend:   c.SupportsSuspend(),
+			Snapshots: c.SupportsSnapshots(),
+			Resize:    c.SupportsResize(),
+			Networks:  c.SupportsNetworks(),
+			Volumes:   c.SupportsVolumes(),
+		}
+	}
+
+	return capabilities
+}
+
+// WaitTimeouts asks the plugin how long to wait for its hosts. Plugins built
+// before drivers could tune the waits get the defaults.
+func (c *RpcClientDriver) WaitTimeouts() drivers.WaitTimeouts {
+	var timeouts drivers.WaitTimeouts
+
+	if err := c.Client.Call("RpcServerDriver.WaitTimeouts", struct{}{}, &timeouts); err != nil {
+		log.Debugf("Error attempting call to get the wait timeouts: %s", err)
+		return drivers.WaitTimeouts{}
+	}
+
+	return timeouts
+}
+
+// GetSSHBastion asks the plugin for the jump host to connect through.
+// Plugins built before drivers could have one connect directly.
+func (c *RpcClientDriver) GetSSHBastion() string {
+	bastion, err := c.rpcStringCall("RpcServerDriver.GetSSHBastion")
+	if err != nil {
+		log.Debugf("Error attempting call to get the SSH bastion: %s", err)
+		return ""
+	}
+
+	return bastion
+}
+
+func (c *RpcClientDriver) GetSSHBastionKeyPath() string {
+	keyPath, err := c.rpcStringCall("RpcServerDriver.GetSSHBastionKeyPath")
+	if err != nil {
+		log.Debugf("Error attempting call to get the SSH bastion key path: %s", err)
+		return ""
+	}
+
+	return keyPath
+}
+
+// GetSSHOptions asks the plugin for the options of ssh to connect with.
+// Plugins built before drivers could have some connect without.
+func (c *RpcClientDriver) GetSSHOptions() []string {
+	var opts []string
+
+	if err := c.Client.Call("RpcServerDriver.GetSSHOptions", struct{}{}, &opts); err != nil {
+		log.Debugf("Error attempting call to get the SSH options: %s", err)
+		return nil
+	}
+
+	return opts
+}
+
+// Adopted asks the plugin whether the host was adopted. Plugins built
+// before drivers could adopt hosts never did.
+func (c *RpcClientDriver) Adopted() bool {
+	var adopted bool
+
+	if err := c.Client.Call("RpcServerDriver.Adopted", struct{}{}, &adopted); err != nil {
+		log.Debugf("Error attempting call to check whether the host was adopted: %s", err)
+		return false
+	}
+
+	return adopted
+}
+
+func (c *RpcClientDriver) CreateSnapshot(name string) error {
+	return c.Client.Call("RpcServerDriver.CreateSnapshot", name, nil)
+}
+
+func (c *RpcClientDriver) RestoreSnapshot(name string) error {
+	return c.Client.Call("RpcServerDriver.RestoreSnapshot", name, nil)
+}
+
+func (c *RpcClientDriver) RemoveSnapshot(name string) error {
+	return c.Client.Call("RpcServerDriver.RemoveSnapshot", name, nil)
+}
+
 func (c *RpcClientDriver) LocalArtifactPath(file string) string {
 	var path string
 
@@ -318,3 +633,133 @@ func (c *RpcClientDriver) GlobalArtifactPath() string {
 func (c *RpcClientDriver) Upgrade() error {
 	return c.Client.Call("RpcServerDriver.Upgrade", struct{}{}, nil)
 }
+
+// SupportsTransport asks the plugin whether its driver runs the commands on
+// its hosts itself. Plugins built before drivers could have them reached
+// over SSH.
+func (c *RpcClientDriver) SupportsTransport() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsTransport", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for transport support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) RunCommand(command string) (string, error) {
+	var output string
+
+	if err := c.Client.Call("RpcServerDriver.RunCommand", command, &output); err != nil {
+		return output, err
+	}
+
+	return output, nil
+}
+
+// RunCommandWithInput runs the command with the transport of the driver of
+// the plugin, writing input to its stdin. Plugins built before it was added
+// can't, their secret commands fail.
+func (c *RpcClientDriver) RunCommandWithInput(command string, input []byte) (string, error) {
+	var output string
+
+	args := &RunCommandWithInputArgs{Command: command, Input: input}
+	if err := c.Client.Call("RpcServerDriver.RunCommandWithInput", args, &output); err != nil {
+		return output, err
+	}
+
+	return output, nil
+}
+
+// SupportsGuide asks the plugin whether its driver can guide create
+// --interactive. Plugins built before it was added can't.
+func (c *RpcClientDriver) SupportsGuide() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsGuide", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for guide support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) GuideFlags() drivers.GuideFlags {
+	var flags drivers.GuideFlags
+
+	if err := c.Client.Call("RpcServerDriver.GuideFlags", struct{}{}, &flags); err != nil {
+		log.Debugf("Error attempting call to get the guide flags: %s", err)
+		return drivers.GuideFlags{}
+	}
+
+	return flags
+}
+
+func (c *RpcClientDriver) ValidateCredentials() error {
+	return c.Client.Call("RpcServerDriver.ValidateCredentials", struct{}{}, nil)
+}
+
+func (c *RpcClientDriver) ListOptions(flag string) ([]drivers.Option, error) {
+	var options []drivers.Option
+
+	if err := c.Client.Call("RpcServerDriver.ListOptions", flag, &options); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// SupportsDiscovery asks the plugin whether its driver can list its regions,
+// images and sizes. Plugins built before it was added can't.
+func (c *RpcClientDriver) SupportsDiscovery() bool {
+	var supported bool
+
+	if err := c.Client.Call("RpcServerDriver.SupportsDiscovery", struct{}{}, &supported); err != nil {
+		log.Debugf("Error attempting call to check for discovery support: %s", err)
+		return false
+	}
+
+	return supported
+}
+
+func (c *RpcClientDriver) DiscoveryFlags() drivers.DiscoveryFlags {
+	var flags drivers.DiscoveryFlags
+
+	if err := c.Client.Call("RpcServerDriver.DiscoveryFlags", struct{}{}, &flags); err != nil {
+		log.Debugf("Error attempting call to get the discovery flags: %s", err)
+		return drivers.DiscoveryFlags{}
+	}
+
+	return flags
+}
+
+func (c *RpcClientDriver) ListRegions() ([]drivers.Option, error) {
+	var options []drivers.Option
+
+	if err := c.Client.Call("RpcServerDriver.ListRegions", struct{}{}, &options); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+func (c *RpcClientDriver) ListImages(region string) ([]drivers.Option, error) {
+	var options []drivers.Option
+
+	if err := c.Client.Call("RpcServerDriver.ListImages", region, &options); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+func (c *RpcClientDriver) ListSizes(region string) ([]drivers.Option, error) {
+	var options []drivers.Option
+
+	if err := c.Client.Call("RpcServerDriver.ListSizes", region, &options); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/version"
@@ -109,7 +110,7 @@ func (r *RpcServerDriver) SetConfigRaw(data []byte, _ *struct{}) error {
 }
 
 func (r *RpcServerDriver) Create(_, _ *struct{}) error {
-	return r.ActualDriver.Create()
+	return mcnerror.Encode(r.ActualDriver.Create())
 }
 
 func (r *RpcServerDriver) DriverName(_ *struct{}, reply *string) error {
@@ -120,7 +121,7 @@ func (r *RpcServerDriver) DriverName(_ *struct{}, reply *string) error {
 func (r *RpcServerDriver) GetIP(_ *struct{}, reply *string) error {
 	ip, err := r.ActualDriver.GetIP()
 	*reply = ip
-	return err
+	return mcnerror.Encode(err)
 }
 
 func (r *RpcServerDriver) GetMachineName(_ *struct{}, reply *string) error {
@@ -131,7 +132,7 @@ func (r *RpcServerDriver) GetMachineName(_ *struct{}, reply *string) error {
 func (r *RpcServerDriver) GetSSHHostname(_ *struct{}, reply *string) error {
 	hostname, err := r.ActualDriver.GetSSHHostname()
 	*reply = hostname
-	return err
+	return mcnerror.Encode(err)
 }
 
 func (r *RpcServerDriver) GetSSHKeyPath(_ *struct{}, reply *string) error {
@@ -163,29 +164,29 @@ func (r *RpcServerDriver) SSHSudo(command string) string {
 func (r *RpcServerDriver) GetURL(_ *struct{}, reply *string) error {
 	info, err := r.ActualDriver.GetURL()
 	*reply = info
-	return err
+	return mcnerror.Encode(err)
 }
 
 func (r *RpcServerDriver) GetState(_ *struct{}, reply *state.State) error {
 	s, err := r.ActualDriver.GetState()
 	*reply = s
-	return err
+	return mcnerror.Encode(err)
 }
 
 func (r *RpcServerDriver) Kill(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Kill()
+	return mcnerror.Encode(r.ActualDriver.Kill())
 }
 
 func (r *RpcServerDriver) PreCreateCheck(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.PreCreateCheck()
+	return mcnerror.Encode(r.ActualDriver.PreCreateCheck())
 }
 
 func (r *RpcServerDriver) Remove(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Remove()
+	return mcnerror.Encode(r.ActualDriver.Remove())
 }
 
 func (r *RpcServerDriver) Restart(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Restart()
+	return mcnerror.Encode(r.ActualDriver.Restart())
 }
 
 func (r *RpcServerDriver) SetConfigFromFlags(flags *drivers.DriverOptions, _ *struct{}) error {
@@ -193,11 +194,328 @@ func (r *RpcServerDriver) SetConfigFromFlags(flags *drivers.DriverOptions, _ *st
 }
 
 func (r *RpcServerDriver) Start(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Start()
+	return mcnerror.Encode(r.ActualDriver.Start())
 }
 
 func (r *RpcServerDriver) Stop(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Stop()
+	return mcnerror.Encode(r.ActualDriver.Stop())
+}
+
+func (r *RpcServerDriver) SupportsSuspend(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsSuspend(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) Suspend(_ *struct{}, _ *struct{}) error {
+	suspender, ok := r.ActualDriver.(drivers.Suspender)
+	if !ok {
+		return drivers.ErrSuspendNotImplemented
+	}
+	return suspender.Suspend()
+}
+
+func (r *RpcServerDriver) Resume(_ *struct{}, _ *struct{}) error {
+	suspender, ok := r.ActualDriver.(drivers.Suspender)
+	if !ok {
+		return drivers.ErrSuspendNotImplemented
+	}
+	return suspender.Resume()
+}
+
+func (r *RpcServerDriver) SupportsSnapshots(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsSnapshots(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsNetworks(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsNetworks(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsResize(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsResize(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) Resize(opts drivers.ResizeOptions, _ *struct{}) error {
+	resizer, ok := r.ActualDriver.(drivers.Resizer)
+	if !ok {
+		return drivers.ErrResizeNotImplemented
+	}
+	return resizer.Resize(opts)
+}
+
+func (r *RpcServerDriver) SupportsPlan(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsPlan(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) Plan(_ *struct{}, reply *[]drivers.PlannedResource) error {
+	resources, err := drivers.GetPlan(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	*reply = resources
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsTerraform(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsTerraform(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) TerraformResources(_ *struct{}, reply *[]drivers.TerraformResource) error {
+	resources, err := drivers.GetTerraformResources(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	*reply = resources
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsGarbageCollection(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsGarbageCollection(r.ActualDriver)
+	return nil
+}
+
+// OrphanedResourcesArgs are the arguments of OrphanedResources.
+type OrphanedResourcesArgs struct {
+	StoreID  string
+	Machines []string
+}
+
+func (r *RpcServerDriver) OrphanedResources(args OrphanedResourcesArgs, reply *[]drivers.OrphanedResource) error {
+	resources, err := drivers.GetOrphanedResources(r.ActualDriver, args.StoreID, args.Machines)
+	if err != nil {
+		return err
+	}
+	*reply = resources
+	return nil
+}
+
+func (r *RpcServerDriver) RemoveOrphanedResource(resource drivers.OrphanedResource, _ *struct{}) error {
+	return drivers.RemoveOrphanedResource(r.ActualDriver, resource)
+}
+
+func (r *RpcServerDriver) SupportsPricing(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsPricing(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) Pricing(_ *struct{}, reply *drivers.Pricing) error {
+	pricing, err := drivers.GetPricing(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	*reply = pricing
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsVolumes(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsVolumes(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) GetVolume(_ *struct{}, reply *drivers.Volume) error {
+	if volume := drivers.GetVolume(r.ActualDriver); volume != nil {
+		*reply = *volume
+	}
+	return nil
+}
+
+func (r *RpcServerDriver) GetVolumeDevices(_ *struct{}, reply *[]string) error {
+	devices, err := drivers.GetVolumeDevices(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	*reply = devices
+	return nil
+}
+
+func (r *RpcServerDriver) GetAddresses(_ *struct{}, reply *[]drivers.Address) error {
+	attacher, ok := r.ActualDriver.(drivers.NetworkAttacher)
+	if !ok {
+		return drivers.ErrNetworksNotImplemented
+	}
+
+	addresses, err := attacher.GetAddresses()
+	*reply = addresses
+	return err
+}
+
+func (r *RpcServerDriver) GetStateReason(_ *struct{}, reply *string) error {
+	reasoner, ok := r.ActualDriver.(drivers.StateReasoner)
+	if !ok {
+		return nil
+	}
+
+	reason, err := reasoner.GetStateReason()
+	*reply = reason
+	return err
+}
+
+func (r *RpcServerDriver) GetIPv6(_ *struct{}, reply *string) error {
+	ip, err := drivers.GetIPv6(r.ActualDriver)
+	*reply = ip
+	return err
+}
+
+func (r *RpcServerDriver) Capabilities(_ *struct{}, reply *drivers.Capabilities) error {
+	*reply = drivers.GetCapabilities(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) WaitTimeouts(_ *struct{}, reply *drivers.WaitTimeouts) error {
+	*reply = drivers.GetWaitTimeouts(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) GetSSHBastion(_ *struct{}, reply *string) error {
+	if getter, ok := r.ActualDriver.(drivers.SSHBastionGetter); ok {
+		*reply = getter.GetSSHBastion()
+	}
+	return nil
+}
+
+func (r *RpcServerDriver) GetSSHBastionKeyPath(_ *struct{}, reply *string) error {
+	if getter, ok := r.ActualDriver.(drivers.SSHBastionGetter); ok {
+		*reply = getter.GetSSHBastionKeyPath()
+	}
+	return nil
+}
+
+func (r *RpcServerDriver) GetSSHOptions(_ *struct{}, reply *[]string) error {
+	*reply = drivers.GetSSHOptions(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) Adopted(_ *struct{}, reply *bool) error {
+	*reply = drivers.IsAdopted(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsTransport(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsTransport(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) RunCommand(command string, reply *string) error {
+	transporter, ok := r.ActualDriver.(drivers.Transporter)
+	if !ok {
+		return drivers.ErrTransportNotImplemented
+	}
+
+	output, err := transporter.RunCommand(command)
+	*reply = output
+	return err
+}
+
+type RunCommandWithInputArgs struct {
+	Command string
+	Input   []byte
+}
+
+func (r *RpcServerDriver) RunCommandWithInput(args *RunCommandWithInputArgs, reply *string) error {
+	transporter, ok := r.ActualDriver.(drivers.Transporter)
+	if !ok {
+		return drivers.ErrTransportNotImplemented
+	}
+
+	output, err := transporter.RunCommandWithInput(args.Command, args.Input)
+	*reply = output
+	return err
+}
+
+func (r *RpcServerDriver) SupportsGuide(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsGuide(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) GuideFlags(_ *struct{}, reply *drivers.GuideFlags) error {
+	*reply = drivers.GetGuideFlags(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) ValidateCredentials(_ *struct{}, _ *struct{}) error {
+	return drivers.ValidateCredentials(r.ActualDriver)
+}
+
+func (r *RpcServerDriver) ListOptions(flag string, reply *[]drivers.Option) error {
+	options, err := drivers.ListOptions(r.ActualDriver, flag)
+	if err != nil {
+		return err
+	}
+	*reply = options
+	return nil
+}
+
+func (r *RpcServerDriver) SupportsDiscovery(_ *struct{}, reply *bool) error {
+	*reply = drivers.SupportsDiscovery(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) DiscoveryFlags(_ *struct{}, reply *drivers.DiscoveryFlags) error {
+	*reply = drivers.GetDiscoveryFlags(r.ActualDriver)
+	return nil
+}
+
+func (r *RpcServerDriver) ListRegions(_ *struct{}, reply *[]drivers.Option) error {
+	options, err := drivers.ListRegions(r.ActualDriver)
+	if err != nil {
+		return err
+	}
+	*reply = options
+	return nil
+}
+
+func (r *RpcServerDriver) ListImages(region string, reply *[]drivers.Option) error {
+	options, err := drivers.ListImages(r.ActualDriver, region)
+	if err != nil {
+		return err
+	}
+	*reply = options
+	return nil
+}
+
+func (r *RpcServerDriver) ListSizes(region string, reply *[]drivers.Option) error {
+	options, err := drivers.ListSizes(r.ActualDriver, region)
+	if err != nil {
+		return err
+	}
+	*reply = options
+	return nil
+}
+
+func (r *RpcServerDriver) snapshotter() (drivers.Snapshotter, error) {
+	snapshotter, ok := r.ActualDriver.(drivers.Snapshotter)
+	if !ok {
+		return nil, drivers.ErrSnapshotNotImplemented
+	}
+	return snapshotter, nil
+}
+
+func (r *RpcServerDriver) CreateSnapshot(name string, _ *struct{}) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+	return snapshotter.CreateSnapshot(name)
+}
+
+func (r *RpcServerDriver) RestoreSnapshot(name string, _ *struct{}) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+	return snapshotter.RestoreSnapshot(name)
+}
+
+func (r *RpcServerDriver) RemoveSnapshot(name string, _ *struct{}) error {
+	snapshotter, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+	return snapshotter.RemoveSnapshot(name)
 }
 
 func (r *RpcServerDriver) Heartbeat(_ *struct{}, _ *struct{}) error {
@@ -0,0 +1,75 @@
+package drivers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// SSHSettings are the settings of the SSH connections to a host which can be
+// changed once it was created, such as when its SSH server was reconfigured.
+// The zero values leave the settings unchanged.
+type SSHSettings struct {
+	Port int
+	User string
+
+	// Options are options of ssh, as Key=Value, replacing the ones of the
+	// same key, or removing them when the value is empty.
+	Options []string
+}
+
+// UpdateSSHSettings changes the SSH settings in the configuration of the
+// driver, the one of its plugin for a plugin driver, for them to be saved
+// with the host. It fails when the driver doesn't keep the settings, as
+// BaseDriver does.
+func UpdateSSHSettings(d Driver, settings SSHSettings) error {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	set := func(key, setting string, value interface{}) error {
+		if _, ok := config[key]; !ok {
+			return fmt.Errorf("The %s driver doesn't keep the SSH %s", d.DriverName(), setting)
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		config[key] = raw
+		return nil
+	}
+
+	if settings.Port != 0 {
+		if err := set("SSHPort", "port", settings.Port); err != nil {
+			return err
+		}
+	}
+
+	if settings.User != "" {
+		if err := set("SSHUser", "user", settings.User); err != nil {
+			return err
+		}
+	}
+
+	if len(settings.Options) > 0 {
+		if err := set("SSHOptions", "options", ssh.MergeOptions(GetSSHOptions(d), settings.Options)); err != nil {
+			return err
+		}
+	}
+
+	if data, err = json.Marshal(config); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, d)
+}
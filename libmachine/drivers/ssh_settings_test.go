@@ -0,0 +1,52 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sshSettingsDriver struct {
+	nopDriver
+	*BaseDriver
+}
+
+func (d *sshSettingsDriver) DriverName() string {
+	return "test"
+}
+
+type noSSHSettingsDriver struct {
+	nopDriver
+}
+
+func (d *noSSHSettingsDriver) DriverName() string {
+	return "nop"
+}
+
+func TestUpdateSSHSettings(t *testing.T) {
+	d := &sshSettingsDriver{BaseDriver: &BaseDriver{SSHPort: 22, SSHUser: "docker", SSHOptions: []string{"Compression=no", "Ciphers=aes256-ctr"}}}
+
+	err := UpdateSSHSettings(d, SSHSettings{Port: 2222, User: "ops", Options: []string{"compression=yes", "Ciphers=", "ServerAliveInterval=5"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2222, d.SSHPort)
+	assert.Equal(t, "ops", d.SSHUser)
+	assert.Equal(t, []string{"compression=yes", "ServerAliveInterval=5"}, d.SSHOptions)
+}
+
+func TestUpdateSSHSettingsLeavesZeroValues(t *testing.T) {
+	d := &sshSettingsDriver{BaseDriver: &BaseDriver{SSHPort: 2022, SSHUser: "docker", MachineName: "default"}}
+
+	err := UpdateSSHSettings(d, SSHSettings{User: "ops"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2022, d.SSHPort)
+	assert.Equal(t, "ops", d.SSHUser)
+	assert.Equal(t, "default", d.MachineName)
+}
+
+func TestUpdateSSHSettingsNotKept(t *testing.T) {
+	err := UpdateSSHSettings(&noSSHSettingsDriver{}, SSHSettings{Port: 2222})
+
+	assert.EqualError(t, err, "The nop driver doesn't keep the SSH port")
+}
@@ -0,0 +1,65 @@
+package drivers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/version"
+)
+
+// The tags every resource a driver creates for a host has, for orphaned
+// resources to be traced back to their machine, and to the store it is in.
+const (
+	TagMachineName    = "machine-name"
+	TagMachineVersion = "machine-version"
+	TagMachineStore   = "machine-store"
+)
+
+// StoreIDOption is the driver option the ID of the store the machine is
+// created in is given with, for the machine-store tag.
+const StoreIDOption = "machine-store-id"
+
+// ParseResourceTags parses the tags given with --tag as "key=value", and adds
+// the machine-name, machine-version and machine-store tags, which can't be
+// overridden.
+func ParseResourceTags(flags DriverOptions, machineName string) (map[string]string, error) {
+	tags := map[string]string{}
+
+	for _, spec := range flags.StringSlice("tag") {
+		kv := strings.SplitN(spec, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Invalid --tag %q, expected key=value", spec)
+		}
+		if kv[0] == TagMachineName || kv[0] == TagMachineVersion || kv[0] == TagMachineStore {
+			return nil, fmt.Errorf("Invalid --tag %q, the %s tag is set by Machine", spec, kv[0])
+		}
+		tags[kv[0]] = kv[1]
+	}
+
+	tags[TagMachineName] = machineName
+	tags[TagMachineVersion] = version.Version
+
+	if storeID := flags.String(StoreIDOption); storeID != "" {
+		tags[TagMachineStore] = storeID
+	}
+
+	return tags, nil
+}
+
+// TagList returns tags as "<key><sep><value>" strings sorted by key, for
+// providers whose tags are plain strings.
+func TagList(tags map[string]string, sep string) []string {
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := []string{}
+	for _, k := range keys {
+		list = append(list, k+sep+tags[k])
+	}
+
+	return list
+}
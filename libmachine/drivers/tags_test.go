@@ -0,0 +1,50 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/docker/machine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+type tagOptions map[string]interface{}
+
+func (o tagOptions) String(key string) string {
+	s, _ := o[key].(string)
+	return s
+}
+
+func (o tagOptions) StringSlice(key string) []string {
+	s, _ := o[key].([]string)
+	return s
+}
+
+func (o tagOptions) Int(key string) int {
+	return 0
+}
+
+func (o tagOptions) Bool(key string) bool {
+	return false
+}
+
+func TestParseResourceTags(t *testing.T) {
+	tags, err := ParseResourceTags(tagOptions{"tag": []string{"team=infra"}, StoreIDOption: "0123abcd"}, "web")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"team":            "infra",
+		TagMachineName:    "web",
+		TagMachineVersion: version.Version,
+		TagMachineStore:   "0123abcd",
+	}, tags)
+
+	tags, err = ParseResourceTags(tagOptions{}, "web")
+	assert.NoError(t, err)
+	_, ok := tags[TagMachineStore]
+	assert.False(t, ok)
+
+	_, err = ParseResourceTags(tagOptions{"tag": []string{"machine-store=other"}}, "web")
+	assert.EqualError(t, err, `Invalid --tag "machine-store=other", the machine-store tag is set by Machine`)
+
+	_, err = ParseResourceTags(tagOptions{"tag": []string{"team"}}, "web")
+	assert.EqualError(t, err, `Invalid --tag "team", expected key=value`)
+}
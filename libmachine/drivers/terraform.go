@@ -0,0 +1,106 @@
+package drivers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TerraformResource is a cloud resource a driver created for a host,
+// described as a Terraform resource, for export terraform.
+type TerraformResource struct {
+	// Type is the Terraform resource type, e.g. "aws_instance"
+	Type string
+
+	// Name is the local name of the resource in the configuration, see
+	// TerraformName
+	Name string
+
+	// ID is what terraform import takes to import the resource
+	ID string
+
+	Attributes []TerraformAttribute
+	Blocks     []TerraformBlock
+}
+
+// Address returns the address of the resource in the configuration, e.g.
+// "aws_instance.web".
+func (r TerraformResource) Address() string {
+	return r.Type + "." + r.Name
+}
+
+// TerraformAttribute is an argument of a resource, whose value is an HCL
+// expression made with TerraformString and the like.
+type TerraformAttribute struct {
+	Name  string
+	Value string
+}
+
+// TerraformBlock is a nested block of a resource, e.g. the ingress rules of
+// a security group.
+type TerraformBlock struct {
+	Type       string
+	Attributes []TerraformAttribute
+	Blocks     []TerraformBlock
+}
+
+var terraformNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// TerraformName returns name as a valid local name for a resource, which
+// starts with a letter or an underscore.
+func TerraformName(name string) string {
+	name = terraformNameInvalidChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '-' {
+		name = "_" + name
+	}
+	return name
+}
+
+// TerraformString returns s as an HCL string.
+func TerraformString(s string) string {
+	return strconv.Quote(strings.Replace(s, "${", "$${", -1))
+}
+
+// TerraformNumber returns n as an HCL number.
+func TerraformNumber(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+// TerraformBool returns b as an HCL bool.
+func TerraformBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// TerraformList returns an HCL list of the given expressions.
+func TerraformList(values ...string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// TerraformMap returns an HCL map of strings, sorted by key.
+func TerraformMap(m map[string]string) string {
+	if len(m) == 0 {
+		return "{}"
+	}
+
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []string{}
+	for _, k := range keys {
+		items = append(items, fmt.Sprintf("%s = %s", TerraformString(k), TerraformString(m[k])))
+	}
+
+	return "{ " + strings.Join(items, ", ") + " }"
+}
+
+// TerraformReference returns a reference to an attribute of another
+// resource, e.g. aws_security_group.docker-machine.id, for Terraform to know
+// the resources depend on each other.
+func TerraformReference(resource TerraformResource, attribute string) string {
+	return resource.Address() + "." + attribute
+}
@@ -0,0 +1,88 @@
+package drivers
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"golang.org/x/net/context"
+)
+
+// TranscriptFileName is the file in the directory of a machine the
+// transcript of provisioning it is recorded to.
+const TranscriptFileName = "provision.log"
+
+var (
+	// sudoPasswordPattern matches the password SSHSudo pipes to sudo.
+	sudoPasswordPattern = regexp.MustCompile(`echo "(?:[^"\\]|\\.)*" \| sudo -SE`)
+
+	// privateKeyPattern matches the private keys copied to the machine.
+	privateKeyPattern = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+
+	// swarmSecretPattern matches the tokens to join swarm mode clusters,
+	// and the keys to unlock their managers.
+	swarmSecretPattern = regexp.MustCompile(`SWM(TKN|KEY)-[0-9A-Za-z+/=-]+`)
+)
+
+type transcriptKey struct{}
+
+type transcript struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// WithTranscript returns a copy of ctx recording the SSH commands run with
+// the drivers bound to it with WithContext, and their output, to w.
+func WithTranscript(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, transcriptKey{}, transcript{
+		mu: &sync.Mutex{},
+		w:  w,
+	})
+}
+
+// Transcribe records the message to the transcript of ctx, if it has one.
+func Transcribe(ctx context.Context, format string, args ...interface{}) {
+	t, ok := ctx.Value(transcriptKey{}).(transcript)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintf(t.w, "[%s] %s\n", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// TranscribeSSHCommand records the SSH command run with the driver, with its
+// output, error and duration, to the transcript of the context of the
+// driver. Private keys, sudo passwords and swarm join tokens are left out.
+func TranscribeSSHCommand(d Driver, command, output string, err error, duration time.Duration) {
+	ctx := contextOf(d)
+
+	command = redactSecrets(command)
+	output = strings.TrimRight(redactSecrets(output), "\n")
+
+	if err != nil {
+		Transcribe(ctx, "$ %s\n%s\nError after %.3fs: %s", command, output, duration.Seconds(), err)
+		return
+	}
+	Transcribe(ctx, "$ %s\n%s\nDone in %.3fs", command, output, duration.Seconds())
+}
+
+// redactSecrets leaves the secrets out of a command run on a machine, or of
+// its output, for them to be logged and transcribed.
+func redactSecrets(s string) string {
+	s = sudoPasswordPattern.ReplaceAllString(s, `echo "REDACTED" | sudo -SE`)
+	s = swarmSecretPattern.ReplaceAllString(s, "SWM${1}-REDACTED")
+	return privateKeyPattern.ReplaceAllString(s, "REDACTED PRIVATE KEY")
+}
+
+// redactedCommandError returns the error of a command run on a machine, with
+// the secrets of the command and its output left out.
+func redactedCommandError(command, output string, err error) error {
+	return mcnerror.ErrSSHCommand{Command: redactSecrets(command), Output: redactSecrets(output), Err: err}
+}
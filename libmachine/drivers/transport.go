@@ -0,0 +1,244 @@
+package drivers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/serial"
+	"github.com/docker/machine/libmachine/winrm"
+)
+
+// Transport runs the commands provisioning a host on it.
+type Transport interface {
+	// RunCommand runs the shell command on the host, and returns its output
+	RunCommand(command string) (string, error)
+
+	// RunCommandWithInput is RunCommand, writing input to the stdin of the
+	// command, for secrets not to be given on its command line
+	RunCommandWithInput(command string, input []byte) (string, error)
+}
+
+// Transporter is an optional interface for drivers whose hosts are reached
+// otherwise than over SSH to be provisioned, such as directly for the host
+// Machine runs on, over WinRM, or on a serial console, see LocalTransport,
+// WinRMTransport and SerialTransport. The commands are POSIX shell
+// commands, as the provisioners run them on Linux hosts. The hosts of the
+// other drivers are reached over SSH.
+type Transporter interface {
+	Transport
+}
+
+// TransportChecker is the Transporter counterpart of SuspendChecker.
+type TransportChecker interface {
+	SupportsTransport() bool
+}
+
+// SupportsTransport reports whether the driver runs the commands on its
+// hosts itself, rather than over SSH.
+func SupportsTransport(d Driver) bool {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+
+	if _, ok := d.(Transporter); !ok {
+		return false
+	}
+
+	if checker, ok := d.(TransportChecker); ok {
+		return checker.SupportsTransport()
+	}
+
+	return true
+}
+
+// SSHTransport runs the commands on the host of the driver over SSH.
+type SSHTransport struct {
+	Driver Driver
+}
+
+func (t SSHTransport) RunCommand(command string) (string, error) {
+	return RunSSHCommandFromDriver(t.Driver, command)
+}
+
+func (t SSHTransport) RunCommandWithInput(command string, input []byte) (string, error) {
+	return RunSecretCommandFromDriver(t.Driver, command, input)
+}
+
+// driverTransport runs the commands with the Transporter of the driver,
+// transcribing them as the SSH commands are.
+type driverTransport struct {
+	Driver Driver
+}
+
+func (t driverTransport) RunCommand(command string) (string, error) {
+	if err := contextOf(t.Driver).Err(); err != nil {
+		return "", err
+	}
+
+	log.Debugf("About to run command:\n%s", redactSecrets(command))
+
+	start := time.Now()
+	output, err := transporterOf(t.Driver).RunCommand(command)
+	log.Debugf("Command err, output: %v: %s", err, redactSecrets(output))
+	TranscribeSSHCommand(t.Driver, command, output, err, time.Since(start))
+	if err != nil {
+		return "", redactedCommandError(command, output, err)
+	}
+
+	return output, nil
+}
+
+func (t driverTransport) RunCommandWithInput(command string, input []byte) (string, error) {
+	return RunSecretCommandFromDriver(t.Driver, command, input)
+}
+
+// transporterOf returns the Transporter of the driver, which it must be.
+func transporterOf(d Driver) Transporter {
+	if cd, ok := d.(*contextDriver); ok {
+		d = cd.Driver
+	}
+	return d.(Transporter)
+}
+
+// GetTransport returns the transport the commands provisioning the host of
+// the driver are run with: the driver itself when it is a Transporter, SSH
+// otherwise.
+func GetTransport(d Driver) Transport {
+	if SupportsTransport(d) {
+		return driverTransport{Driver: d}
+	}
+	return SSHTransport{Driver: d}
+}
+
+// RunCommandFromDriver runs the command on the host of the driver, with the
+// transport of the driver.
+func RunCommandFromDriver(d Driver, command string) (string, error) {
+	return GetTransport(d).RunCommand(command)
+}
+
+// RunSecretCommandFromDriver runs the command on the host of the driver, with
+// the transport of the driver, reading its stdin from input, for secrets to
+// be written on the host without being given on the command line. The
+// command is logged and transcribed as the others are, but neither its input
+// nor its output, for secrets to be read on the host too.
+func RunSecretCommandFromDriver(d Driver, command string, input []byte) (string, error) {
+	log.Debugf("About to run command, leaving its input and output out:\n%s", redactSecrets(command))
+
+	start := time.Now()
+	output, err := runSecretCommand(d, command, input)
+	TranscribeSSHCommand(d, command, "(output left out)", err, time.Since(start))
+	if err != nil {
+		return "", redactedCommandError(command, "", err)
+	}
+
+	return output, nil
+}
+
+func runSecretCommand(d Driver, command string, input []byte) (string, error) {
+	if SupportsTransport(d) {
+		if err := contextOf(d).Err(); err != nil {
+			return "", err
+		}
+		return transporterOf(d).RunCommandWithInput(command, input)
+	}
+
+	client, err := GetSSHClientFromDriver(d)
+	if err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	err = client.Stream(command, bytes.NewReader(input), &output)
+	return output.String(), err
+}
+
+// WaitForTransport waits for the host of the driver to answer commands, see
+// WaitForSSH, which it is for the hosts reached over SSH.
+func WaitForTransport(d Driver) error {
+	if !SupportsTransport(d) {
+		return WaitForSSH(d)
+	}
+
+	var lastErr error
+	available := func() bool {
+		if _, err := RunCommandFromDriver(d, "exit 0"); err != nil {
+			log.Debugf("Error running command 'exit 0' : %s", err)
+			lastErr = err
+			return false
+		}
+		return true
+	}
+
+	if err := WaitForStage(d, mcnutils.WaitSSH, mcnutils.WaitSettings{Timeout: GetWaitTimeouts(d).SSH}, available); err != nil {
+		if lastErr != nil {
+			err = lastErr
+		}
+		return fmt.Errorf("Too many retries waiting for the machine to answer commands.  Last error: %s", err)
+	}
+	return nil
+}
+
+// LocalTransport runs the commands with sh on the machine Machine runs on,
+// for the drivers provisioning that machine.
+type LocalTransport struct{}
+
+func (t LocalTransport) RunCommand(command string) (string, error) {
+	return t.RunCommandWithInput(command, nil)
+}
+
+func (t LocalTransport) RunCommandWithInput(command string, input []byte) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// WinRMTransport runs the commands over WinRM, with the shell of the WinRM
+// service of the host, which must be a POSIX shell.
+type WinRMTransport struct {
+	Client *winrm.Client
+}
+
+func (t WinRMTransport) RunCommand(command string) (string, error) {
+	return t.RunCommandWithInput(command, nil)
+}
+
+func (t WinRMTransport) RunCommandWithInput(command string, input []byte) (string, error) {
+	var stdin io.Reader
+	if input != nil {
+		stdin = bytes.NewReader(input)
+	}
+
+	var output bytes.Buffer
+	err := t.Client.Run(command, stdin, &output, &output)
+	return output.String(), err
+}
+
+// SerialTransport runs the commands on the serial console at Address, see
+// serial.Dial, which must be logged in to a POSIX shell. The console is
+// connected to for each command.
+type SerialTransport struct {
+	Address string
+}
+
+func (t SerialTransport) RunCommand(command string) (string, error) {
+	return t.RunCommandWithInput(command, nil)
+}
+
+func (t SerialTransport) RunCommandWithInput(command string, input []byte) (string, error) {
+	conn, err := serial.Dial(t.Address)
+	if err != nil {
+		return "", fmt.Errorf("Error connecting to the serial console %s: %s", t.Address, err)
+	}
+	defer conn.Close()
+
+	return serial.NewConsole(conn).Run(command, input)
+}
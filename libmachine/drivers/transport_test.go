@@ -0,0 +1,86 @@
+package drivers
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type transportDriver struct {
+	nopDriver
+	supported bool
+	output    string
+	err       error
+}
+
+func (d transportDriver) SupportsTransport() bool {
+	return d.supported
+}
+
+func (d transportDriver) RunCommand(command string) (string, error) {
+	return d.output + command, d.err
+}
+
+func (d transportDriver) RunCommandWithInput(command string, input []byte) (string, error) {
+	return d.output + command + " < " + string(input), d.err
+}
+
+func TestGetTransport(t *testing.T) {
+	assert.IsType(t, SSHTransport{}, GetTransport(nopDriver{}))
+	assert.IsType(t, SSHTransport{}, GetTransport(transportDriver{supported: false}))
+	assert.IsType(t, driverTransport{}, GetTransport(transportDriver{supported: true}))
+	assert.IsType(t, driverTransport{}, GetTransport(WithContext(context.Background(), transportDriver{supported: true})))
+}
+
+func TestRunCommandFromDriver(t *testing.T) {
+	var transcript bytes.Buffer
+	d := WithContext(WithTranscript(context.Background(), &transcript), transportDriver{supported: true, output: "ran "})
+
+	output, err := RunCommandFromDriver(d, "uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "ran uptime", output)
+	assert.Contains(t, transcript.String(), "$ uptime\nran uptime\nDone in")
+}
+
+func TestRunCommandFromDriverError(t *testing.T) {
+	d := transportDriver{supported: true, err: errors.New("exit status 1")}
+
+	_, err := RunCommandFromDriver(d, "false")
+	assert.IsType(t, mcnerror.ErrSSHCommand{}, err)
+}
+
+func TestRunCommandFromDriverCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := RunCommandFromDriver(WithContext(ctx, transportDriver{supported: true}), "uptime")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRunSecretCommandFromDriver(t *testing.T) {
+	var transcript bytes.Buffer
+	d := WithContext(WithTranscript(context.Background(), &transcript), transportDriver{supported: true, output: "ran "})
+
+	output, err := RunSecretCommandFromDriver(d, "cat > /etc/secret", []byte("s3cr3t"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ran cat > /etc/secret < s3cr3t", output)
+	assert.Contains(t, transcript.String(), "$ cat > /etc/secret\n(output left out)\n")
+	assert.NotContains(t, transcript.String(), "s3cr3t")
+}
+
+func TestLocalTransport(t *testing.T) {
+	output, err := LocalTransport{}.RunCommand("echo hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", output)
+
+	output, err = LocalTransport{}.RunCommandWithInput("cat", []byte("s3cr3t"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", output)
+
+	_, err = LocalTransport{}.RunCommand("exit 2")
+	assert.EqualError(t, err, "exit status 2")
+}
@@ -2,30 +2,62 @@ package drivers
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/ssh"
 )
 
 func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
-	address, err := d.GetSSHHostname()
+	address, port, auth, err := sshConnection(d)
 	if err != nil {
 		return nil, err
 	}
 
+	client, err := ssh.NewClient(d.GetSSHUsername(), address, port, auth)
+	return client, err
+
+}
+
+// GetExternalSSHClientFromDriver returns the client of the machine running
+// the ssh binary at sshBinaryPath, whatever the default client, for what
+// only the ssh binary does, like forwarding sockets.
+func GetExternalSSHClientFromDriver(d Driver, sshBinaryPath string) (ssh.ExternalClient, error) {
+	address, port, auth, err := sshConnection(d)
+	if err != nil {
+		return ssh.ExternalClient{}, err
+	}
+
+	return ssh.NewExternalClient(sshBinaryPath, d.GetSSHUsername(), address, port, auth)
+}
+
+// sshConnection returns where the machine is reached over SSH, and how to
+// authenticate to it.
+func sshConnection(d Driver) (string, int, *ssh.Auth, error) {
+	address, err := d.GetSSHHostname()
+	if err != nil {
+		return "", 0, nil, err
+	}
+
 	port, err := d.GetSSHPort()
 	if err != nil {
-		return nil, err
+		return "", 0, nil, err
 	}
 
-	auth := &ssh.Auth{
-		Keys: []string{d.GetSSHKeyPath()},
+	bastion, err := GetSSHBastion(d)
+	if err != nil {
+		return "", 0, nil, err
 	}
 
-	client, err := ssh.NewClient(d.GetSSHUsername(), address, port, auth)
-	return client, err
+	auth := &ssh.Auth{
+		Keys:    []string{d.GetSSHKeyPath()},
+		Bastion: bastion,
+		Options: GetSSHOptions(d),
+	}
 
+	return address, port, auth, nil
 }
 
 func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
@@ -34,36 +66,55 @@ func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
 		return "", err
 	}
 
-	log.Debugf("About to run SSH command:\n%s", command)
+	log.Debugf("About to run SSH command:\n%s", redactSecrets(command))
 
-	output, err := client.Output(command)
-	log.Debugf("SSH cmd err, output: %v: %s", err, output)
+	start := time.Now()
+	output, err := client.OutputContext(contextOf(d), command)
+	log.Debugf("SSH cmd err, output: %v: %s", err, redactSecrets(output))
+	TranscribeSSHCommand(d, command, output, err, time.Since(start))
 	if err != nil {
-		return "", fmt.Errorf(`Something went wrong running an SSH command!
-command : %s
-err     : %v
-output  : %s
-`, command, err, output)
+		return "", redactedCommandError(command, output, err)
 	}
 
 	return output, nil
 }
 
-func sshAvailableFunc(d Driver) func() bool {
+// sshAvailableFunc returns the function telling whether SSH is available on
+// the host, which records the error of the attempts failing in lastErr.
+func sshAvailableFunc(d Driver, lastErr *error) func() bool {
 	return func() bool {
 		log.Debug("Getting to WaitForSSH function...")
 		if _, err := RunSSHCommandFromDriver(d, "exit 0"); err != nil {
 			log.Debugf("Error getting ssh command 'exit 0' : %s", err)
+			*lastErr = err
 			return false
 		}
 		return true
 	}
 }
 
+// WaitForSSH waits for SSH to be available on the host, for as long as
+// configured for the ssh wait, or else as the driver asks for with
+// WaitTuner. Giving up is an mcnerror.ErrAuth when the host refused the
+// credentials, and an mcnerror.ErrSSHUnreachable otherwise.
 func WaitForSSH(d Driver) error {
-	// Try to dial SSH for 30 seconds before timing out.
-	if err := mcnutils.WaitFor(sshAvailableFunc(d)); err != nil {
-		return fmt.Errorf("Too many retries waiting for SSH to be available.  Last error: %s", err)
+	var lastErr error
+	if err := WaitForStage(d, mcnutils.WaitSSH, mcnutils.WaitSettings{Timeout: GetWaitTimeouts(d).SSH}, sshAvailableFunc(d, &lastErr)); err != nil {
+		err = fmt.Errorf("Too many retries waiting for SSH to be available.  Last error: %s", err)
+		if contextOf(d).Err() != nil {
+			return err
+		}
+		if mcnerror.CategoryOf(lastErr) == mcnerror.CategoryAuth {
+			return mcnerror.ErrAuth{Err: err}
+		}
+		return mcnerror.ErrSSHUnreachable{Host: d.GetMachineName(), Err: err}
 	}
 	return nil
 }
+
+// WaitForStage waits for f to return true, for as long and as often as
+// configured for the stage, or else as defaults ask for, giving up as soon
+// as the context of the driver is done.
+func WaitForStage(d Driver, stage mcnutils.WaitStage, defaults mcnutils.WaitSettings, f func() bool) error {
+	return mcnutils.WaitForStage(contextOf(d), stage, defaults, f)
+}
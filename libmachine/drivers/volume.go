@@ -0,0 +1,79 @@
+package drivers
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Types of volumes, mapped by drivers to the kinds of storage of their
+// provider.
+const (
+	VolumeTypeSSD = "ssd"
+	VolumeTypeHDD = "hdd"
+)
+
+// DefaultVolumeMount is where volumes are mounted when no mount point is
+// given, for the images and containers of the engine to land on them.
+const DefaultVolumeMount = "/var/lib/docker"
+
+// Volume is a block device created with a host and attached to it besides
+// its root disk, given with --attach-volume.
+type Volume struct {
+	// Size is in GB
+	Size int
+
+	// Type is VolumeTypeSSD or VolumeTypeHDD
+	Type string
+
+	// Mount is the absolute path the provisioner mounts the volume on
+	Mount string
+}
+
+// ParseVolume parses a volume given as "size=<GB>[,type=ssd|hdd][,mount=<path>]",
+// returning nil for an empty spec.
+func ParseVolume(spec string) (*Volume, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	volume := &Volume{
+		Type:  VolumeTypeSSD,
+		Mount: DefaultVolumeMount,
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid --attach-volume %q, expected size=<GB>[,type=ssd|hdd][,mount=<path>]", spec)
+		}
+
+		switch key, value := parts[0], parts[1]; key {
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("Invalid --attach-volume size %q, expected a positive number of GB", value)
+			}
+			volume.Size = size
+		case "type":
+			if value != VolumeTypeSSD && value != VolumeTypeHDD {
+				return nil, fmt.Errorf("Invalid --attach-volume type %q, expected ssd or hdd", value)
+			}
+			volume.Type = value
+		case "mount":
+			if !path.IsAbs(value) || path.Clean(value) == "/" || strings.ContainsAny(value, " \t'\"") {
+				return nil, fmt.Errorf("Invalid --attach-volume mount %q, expected an absolute path other than /, without spaces or quotes", value)
+			}
+			volume.Mount = path.Clean(value)
+		default:
+			return nil, fmt.Errorf("Invalid --attach-volume key %q, expected size, type or mount", key)
+		}
+	}
+
+	if volume.Size == 0 {
+		return nil, fmt.Errorf("Invalid --attach-volume %q, its size is required", spec)
+	}
+
+	return volume, nil
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionPattern matches the numbers a version of the engine or of a kernel
+// starts with, like 17.05 in "17.05.0-ce" or 4.15.0 in "4.15.0-1054-aws".
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// rhel7KernelPattern matches the release of a RHEL 7 or CentOS 7 kernel,
+// like 1160 in "3.10.0-1160.el7.x86_64".
+var rhel7KernelPattern = regexp.MustCompile(`^3\.10\.0-(\d+)[.\d]*\.el7`)
+
+// Version is a version of the engine or of a kernel.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses the numbers a version of the engine or of a kernel
+// starts with, ignoring what follows them.
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	v := Version{}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+
+	return v, nil
+}
+
+// Less tells whether v is older than o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// requirement is what an engine option needs to work: the engine versions
+// which have it, and the kernel it needs, as versions like "17.05".
+type requirement struct {
+	// MinEngine is the first engine version with the option
+	MinEngine string
+
+	// RemovedIn is the first engine version without the option
+	RemovedIn string
+
+	// MinKernel is the oldest kernel the option works on, except for the
+	// RHEL 7 kernels from the release RHEL7Kernel on, which have it
+	// backported
+	MinKernel   string
+	RHEL7Kernel int
+}
+
+var (
+	// storageDrivers are the storage drivers of the engine. Unknown
+	// storage drivers are refused, the engine not starting with them.
+	storageDrivers = map[string]requirement{
+		"aufs":           {RemovedIn: "24.0"},
+		"btrfs":          {},
+		"devicemapper":   {RemovedIn: "25.0"},
+		"fuse-overlayfs": {MinEngine: "19.03"},
+		"overlay":        {MinEngine: "1.4", RemovedIn: "24.0", MinKernel: "3.18", RHEL7Kernel: 327},
+		"overlay2":       {MinEngine: "1.12", MinKernel: "4.0", RHEL7Kernel: 514},
+		"vfs":            {},
+		"zfs":            {MinEngine: "1.7"},
+	}
+
+	// logDrivers are the log drivers built in the engine. Other log drivers
+	// are left to the engine, as they may be plugins.
+	logDrivers = map[string]requirement{
+		"awslogs":    {MinEngine: "1.9"},
+		"fluentd":    {MinEngine: "1.8"},
+		"gcplogs":    {MinEngine: "1.12"},
+		"gelf":       {MinEngine: "1.8"},
+		"journald":   {MinEngine: "1.7"},
+		"json-file":  {},
+		"local":      {MinEngine: "18.09"},
+		"logentries": {MinEngine: "1.13"},
+		"none":       {},
+		"splunk":     {MinEngine: "1.10"},
+		"syslog":     {MinEngine: "1.6"},
+	}
+
+	// engineFlags are the engine flags which some engine versions don't
+	// have. Other flags are left to the engine.
+	engineFlags = map[string]requirement{
+		"api-enable-cors":         {RemovedIn: "17.09"},
+		"cluster-advertise":       {RemovedIn: "23.0"},
+		"cluster-store":           {RemovedIn: "23.0"},
+		"cluster-store-opt":       {RemovedIn: "23.0"},
+		"data-root":               {MinEngine: "17.05"},
+		"default-address-pool":    {MinEngine: "18.09"},
+		"disable-legacy-registry": {RemovedIn: "17.12"},
+		"g":                       {RemovedIn: "23.0"},
+		"graph":                   {RemovedIn: "23.0"},
+		"init":                    {MinEngine: "1.13"},
+		"live-restore":            {MinEngine: "1.12"},
+		"userns-remap":            {MinEngine: "1.10"},
+	}
+)
+
+// IncompatibleOptionsError lists the engine options which can't work with
+// the engine version and the kernel of a host.
+type IncompatibleOptionsError struct {
+	EngineVersion string
+	KernelVersion string
+	Problems      []string
+}
+
+func (e *IncompatibleOptionsError) Error() string {
+	on := ""
+	if e.EngineVersion != "" {
+		on += " " + e.EngineVersion
+	}
+	if e.KernelVersion != "" {
+		on += " on kernel " + e.KernelVersion
+	}
+	return fmt.Sprintf("The engine%s would not start with its options: %s", on, strings.Join(e.Problems, "; "))
+}
+
+// CheckOptions returns an *IncompatibleOptionsError when the storage
+// driver, the log driver or the flags of the options can't work with the
+// engine version on the kernel version. An empty or unparsable version
+// isn't checked against.
+func CheckOptions(options EngineOptions, engineVersion, kernelVersion string) error {
+	c := checker{kernel: kernelVersion}
+	if v, err := ParseVersion(engineVersion); err == nil {
+		c.engine = &v
+	}
+	if v, err := ParseVersion(kernelVersion); err == nil {
+		c.kernelVersion = &v
+	}
+
+	storageDriver := options.StorageDriver
+	for _, flag := range options.ArbitraryFlags {
+		name, value := splitFlag(flag)
+		switch name {
+		case "storage-driver", "s":
+			storageDriver = value
+		case "log-driver":
+			if req, ok := logDrivers[value]; ok {
+				c.check("--log-driver "+value, req)
+			}
+		default:
+			if req, ok := engineFlags[name]; ok {
+				c.check("--"+name, req)
+			}
+		}
+	}
+
+	if storageDriver != "" {
+		if req, ok := storageDrivers[storageDriver]; ok {
+			c.check("--storage-driver "+storageDriver, req)
+		} else {
+			c.problems = append(c.problems, fmt.Sprintf("unknown storage driver %q, expected one of %s", storageDriver, strings.Join(names(storageDrivers), ", ")))
+		}
+	}
+
+	if len(c.problems) == 0 {
+		return nil
+	}
+
+	return &IncompatibleOptionsError{
+		EngineVersion: engineVersion,
+		KernelVersion: kernelVersion,
+		Problems:      c.problems,
+	}
+}
+
+type checker struct {
+	engine        *Version
+	kernel        string
+	kernelVersion *Version
+	problems      []string
+}
+
+func (c *checker) check(option string, req requirement) {
+	if c.engine != nil {
+		if req.MinEngine != "" && c.engine.Less(mustParseVersion(req.MinEngine)) {
+			c.problems = append(c.problems, fmt.Sprintf("%s needs engine %s or later", option, req.MinEngine))
+		}
+		if req.RemovedIn != "" && !c.engine.Less(mustParseVersion(req.RemovedIn)) {
+			c.problems = append(c.problems, fmt.Sprintf("%s was removed in engine %s", option, req.RemovedIn))
+		}
+	}
+
+	if c.kernelVersion != nil && req.MinKernel != "" && c.kernelVersion.Less(mustParseVersion(req.MinKernel)) {
+		if m := rhel7KernelPattern.FindStringSubmatch(c.kernel); m != nil && req.RHEL7Kernel > 0 {
+			if release, _ := strconv.Atoi(m[1]); release >= req.RHEL7Kernel {
+				return
+			}
+		}
+		c.problems = append(c.problems, fmt.Sprintf("%s needs kernel %s or later", option, req.MinKernel))
+	}
+}
+
+func mustParseVersion(s string) Version {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// splitFlag splits an engine flag of EngineOptions.ArbitraryFlags, like
+// "log-driver=journald", into its name and value.
+func splitFlag(flag string) (string, string) {
+	parts := strings.SplitN(strings.TrimLeft(flag, "-"), "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func names(requirements map[string]requirement) []string {
+	list := []string{}
+	for name := range requirements {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
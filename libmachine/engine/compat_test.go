@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	for s, expected := range map[string]Version{
+		"1.12.6":                 {1, 12, 6},
+		"17.05.0-ce":             {17, 5, 0},
+		"v24.0.5":                {24, 0, 5},
+		"4.15.0-1054-aws":        {4, 15, 0},
+		"3.10.0-1160.el7.x86_64": {3, 10, 0},
+		"4.19.130-boot2docker\n": {4, 19, 130},
+		"23.0":                   {23, 0, 0},
+	} {
+		v, err := ParseVersion(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, v, s)
+	}
+
+	_, err := ParseVersion("Cannot connect to the Docker daemon")
+	assert.Error(t, err)
+}
+
+func TestVersionLess(t *testing.T) {
+	assert.True(t, Version{1, 12, 6}.Less(Version{17, 5, 0}))
+	assert.True(t, Version{17, 5, 0}.Less(Version{17, 12, 0}))
+	assert.True(t, Version{4, 15, 0}.Less(Version{4, 15, 1}))
+	assert.False(t, Version{24, 0, 0}.Less(Version{24, 0, 0}))
+}
+
+func TestCheckOptions(t *testing.T) {
+	options := EngineOptions{
+		StorageDriver:  "overlay2",
+		ArbitraryFlags: []string{"log-driver=journald", "live-restore", "log-opt=tag=web"},
+	}
+
+	assert.NoError(t, CheckOptions(options, "20.10.7", "5.4.0-1045-aws"))
+	assert.NoError(t, CheckOptions(options, "", ""))
+	assert.NoError(t, CheckOptions(options, "Cannot connect to the Docker daemon", "unknown"))
+
+	err := CheckOptions(options, "1.11.2", "3.16.0-4-amd64")
+	assert.EqualError(t, err, "The engine 1.11.2 on kernel 3.16.0-4-amd64 would not start with its options: "+
+		"--live-restore needs engine 1.12 or later; --storage-driver overlay2 needs engine 1.12 or later; --storage-driver overlay2 needs kernel 4.0 or later")
+
+	incompatible, ok := err.(*IncompatibleOptionsError)
+	assert.True(t, ok)
+	assert.Equal(t, "1.11.2", incompatible.EngineVersion)
+	assert.Len(t, incompatible.Problems, 3)
+}
+
+func TestCheckOptionsRemoved(t *testing.T) {
+	err := CheckOptions(EngineOptions{
+		StorageDriver:  "aufs",
+		ArbitraryFlags: []string{"graph=/data/docker", "cluster-store=consul://10.0.0.2:8500"},
+	}, "24.0.5", "")
+	assert.EqualError(t, err, "The engine 24.0.5 would not start with its options: "+
+		"--graph was removed in engine 23.0; --cluster-store was removed in engine 23.0; --storage-driver aufs was removed in engine 24.0")
+
+	assert.NoError(t, CheckOptions(EngineOptions{StorageDriver: "aufs"}, "18.09.7", ""))
+}
+
+func TestCheckOptionsStorageDriverFlag(t *testing.T) {
+	err := CheckOptions(EngineOptions{ArbitraryFlags: []string{"--storage-driver=devicemapper"}}, "25.0.0", "")
+	assert.EqualError(t, err, "The engine 25.0.0 would not start with its options: --storage-driver devicemapper was removed in engine 25.0")
+}
+
+func TestCheckOptionsUnknownStorageDriver(t *testing.T) {
+	err := CheckOptions(EngineOptions{StorageDriver: "overlay3"}, "", "")
+	assert.EqualError(t, err, `The engine would not start with its options: unknown storage driver "overlay3", expected one of aufs, btrfs, devicemapper, fuse-overlayfs, overlay, overlay2, vfs, zfs`)
+}
+
+func TestCheckOptionsLogDrivers(t *testing.T) {
+	assert.NoError(t, CheckOptions(EngineOptions{ArbitraryFlags: []string{"log-driver=my/log-plugin:latest"}}, "1.6.2", ""))
+
+	err := CheckOptions(EngineOptions{ArbitraryFlags: []string{"log-driver=local"}}, "18.06.1-ce", "")
+	assert.EqualError(t, err, "The engine 18.06.1-ce would not start with its options: --log-driver local needs engine 18.09 or later")
+}
+
+func TestCheckOptionsRHEL7Backport(t *testing.T) {
+	options := EngineOptions{StorageDriver: "overlay2"}
+
+	assert.NoError(t, CheckOptions(options, "19.03.5", "3.10.0-1160.el7.x86_64"))
+	assert.Error(t, CheckOptions(options, "19.03.5", "3.10.0-327.el7.x86_64"))
+	assert.NoError(t, CheckOptions(EngineOptions{StorageDriver: "overlay"}, "19.03.5", "3.10.0-327.el7.x86_64"))
+}
@@ -1,5 +1,15 @@
 package engine
 
+const (
+	// TransportTLS has the clients reach the engine on its TCP listener,
+	// over TLS.
+	TransportTLS = "tls"
+
+	// TransportSSH has the clients reach the unix socket of the engine
+	// through SSH tunnels, the engine not listening on TCP at all.
+	TransportSSH = "ssh"
+)
+
 type EngineOptions struct {
 	ArbitraryFlags   []string
 	Dns              []string
@@ -14,4 +24,52 @@ type EngineOptions struct {
 	TlsVerify        bool
 	RegistryMirror   []string
 	InstallURL       string
+
+	// Hardening is the hardening profile the engine and its host are
+	// provisioned with, if any
+	Hardening string
+
+	// AutoUpgrade has the host upgrade the engine packages unattended, in
+	// the maintenance window UpgradeWindow
+	AutoUpgrade   bool
+	UpgradeWindow string
+
+	// RegistryCache is the machine running the pull-through registry cache
+	// the engine uses as a mirror, at RegistryCacheAddress, as set by
+	// registry-cache enable
+	RegistryCache        string
+	RegistryCacheAddress string
+
+	// RegistryCA are the CA certificates the engine trusts registries with,
+	// like registry mirrors with a private CA, as registry=path of the
+	// certificate
+	RegistryCA []string
+
+	// RegistryAuth are the credentials the docker CLI of the host logs in to
+	// registries with, as registry=user:password
+	RegistryAuth []string
+
+	// PackageCache is the address of the caching proxy the package managers
+	// of the host download through while it is provisioned, the host of
+	// its SSH client when it has no host, like ":3142" for the cache
+	// docker-machine runs itself. It only lasts for the creation, so it
+	// isn't saved.
+	PackageCache string `json:"-"`
+
+	// Transport is how the clients reach the engine, TransportTLS when
+	// empty, as for the machines created before it existed
+	Transport string
+
+	// OptionsDir is the directory of the host the engine options and
+	// certificates are written to, and OptionsFile the file the engine
+	// options are written to, instead of those of the provisioner, like
+	// /etc/docker and /etc/default/docker, for hosts with a read-only /etc
+	OptionsDir  string `json:",omitempty"`
+	OptionsFile string `json:",omitempty"`
+}
+
+// SSHTransport tells whether the clients reach the engine through SSH
+// tunnels rather than on its TCP listener.
+func (o *EngineOptions) SSHTransport() bool {
+	return o != nil && o.Transport == TransportSSH
 }
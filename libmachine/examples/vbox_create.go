@@ -8,6 +8,7 @@ import (
 	"github.com/docker/machine/drivers/virtualbox"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/net/context"
 )
 
 func main() {
@@ -36,7 +37,12 @@ func main() {
 
 	h.HostOptions.EngineOptions.StorageDriver = "overlay"
 
-	if err := libmachine.Create(store, h); err != nil {
+	// The client of the store caches the machines it loads and creates,
+	// and runs the operations on several machines with CreateMany and
+	// RemoveMany.
+	client := libmachine.NewClient(store, nil)
+
+	if err := client.Create(context.Background(), h); err != nil {
 		log.Fatal(err)
 	}
 
@@ -0,0 +1,394 @@
+// Package health checks that machines are in working order, and repairs the
+// problems it knows how to.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/state"
+)
+
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+const (
+	CheckRunning = "running"
+	CheckSSH     = "ssh"
+	CheckDaemon  = "daemon"
+	CheckTLS     = "tls"
+	CheckDisk    = "disk"
+	CheckSwarm   = "swarm"
+)
+
+var (
+	// DiskUsageThreshold is the percentage of the disk holding the
+	// engine's data above which the disk check fails.
+	DiskUsageThreshold = 90
+
+	// CertExpiryThreshold is how long before the server certificate
+	// expires the TLS check starts failing.
+	CertExpiryThreshold = 7 * 24 * time.Hour
+
+	dialTimeout = 5 * time.Second
+
+	errNotSwarm = errors.New("not part of a swarm")
+)
+
+// Result is the outcome of a single check. Healed is set when the check
+// failed at first and passed after repairing the machine, in which case
+// Message is the original failure.
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+	Healed  bool   `json:"healed,omitempty"`
+}
+
+// Report holds the results of every check run against a machine, in the
+// order they were run.
+type Report struct {
+	Host    string   `json:"host"`
+	Results []Result `json:"results"`
+}
+
+// Healthy reports whether none of the checks failed.
+func (r *Report) Healthy() bool {
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// Result returns the result of the named check, or nil if it was not run.
+func (r *Report) Result(check string) *Result {
+	for i := range r.Results {
+		if r.Results[i].Check == check {
+			return &r.Results[i]
+		}
+	}
+	return nil
+}
+
+type check struct {
+	name string
+
+	// needs are the checks which must pass for this one to be run.
+	needs []string
+
+	run func(h *host.Host) error
+
+	// heal repairs the machine after the check failed. Checks without it
+	// have nothing which can be done automatically.
+	heal func(h *host.Host) error
+}
+
+var checks = []check{
+	{
+		name: CheckRunning,
+		run:  checkRunning,
+	},
+	{
+		name:  CheckSSH,
+		needs: []string{CheckRunning},
+		run:   checkSSH,
+	},
+	{
+		name:  CheckDaemon,
+		needs: []string{CheckRunning},
+		run:   checkDaemon,
+		heal:  restartDaemon,
+	},
+	{
+		name:  CheckTLS,
+		needs: []string{CheckDaemon},
+		run:   checkTLS,
+		heal:  regenerateCerts,
+	},
+	{
+		name:  CheckDisk,
+		needs: []string{CheckSSH},
+		run:   checkDisk,
+	},
+	{
+		name:  CheckSwarm,
+		needs: []string{CheckSSH, CheckDaemon},
+		run:   checkSwarm,
+		heal:  rejoinSwarm,
+	},
+}
+
+// Check runs every check against the machine without changing anything.
+func Check(h *host.Host) *Report {
+	return run(h, false)
+}
+
+// Heal runs every check against the machine, and tries to repair the ones
+// which fail: restarting the daemon, pushing new certificates, or rejoining
+// the swarm.
+func Heal(h *host.Host) *Report {
+	return run(h, true)
+}
+
+func run(h *host.Host, heal bool) *Report {
+	report := &Report{
+		Host: h.Name,
+	}
+
+	for _, c := range checks {
+		report.Results = append(report.Results, runCheck(h, c, report, heal))
+	}
+
+	return report
+}
+
+func runCheck(h *host.Host, c check, report *Report, heal bool) Result {
+	for _, need := range c.needs {
+		if res := report.Result(need); res == nil || res.Status != StatusOK {
+			return Result{
+				Check:   c.name,
+				Status:  StatusSkipped,
+				Message: fmt.Sprintf("%s check did not pass", need),
+			}
+		}
+	}
+
+	err := c.run(h)
+	if err == errNotSwarm {
+		return Result{Check: c.name, Status: StatusSkipped, Message: err.Error()}
+	}
+	if err == nil {
+		return Result{Check: c.name, Status: StatusOK}
+	}
+
+	res := Result{
+		Check:   c.name,
+		Status:  StatusFailed,
+		Message: err.Error(),
+	}
+
+	if !heal || c.heal == nil {
+		return res
+	}
+
+	if h.Adopted() {
+		res.Message = fmt.Sprintf("%s (not healing: %s)", err, host.ErrAdopted)
+		return res
+	}
+
+	log.Infof("(%s) %s check failed, healing: %s", h.Name, c.name, err)
+	if healErr := c.heal(h); healErr != nil {
+		res.Message = fmt.Sprintf("%s (healing failed: %s)", err, healErr)
+		return res
+	}
+
+	if recheckErr := c.run(h); recheckErr != nil {
+		res.Message = fmt.Sprintf("%s (still failing after healing: %s)", err, recheckErr)
+		return res
+	}
+
+	res.Status = StatusOK
+	res.Healed = true
+	return res
+}
+
+func checkRunning(h *host.Host) error {
+	s, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s != state.Running {
+		return fmt.Errorf("machine is %s", s)
+	}
+
+	return nil
+}
+
+func checkSSH(h *host.Host) error {
+	_, err := h.RunSSHCommand("exit 0")
+	return err
+}
+
+func daemonAddr(h *host.Host) (string, error) {
+	rawURL, err := h.GetURL()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Host, nil
+}
+
+// checkDaemon makes sure the engine listens on its port and answers
+// requests on its socket.
+func checkDaemon(h *host.Host) error {
+	// With the SSH transport, the engine doesn't listen on TCP.
+	if h.HostOptions == nil || !h.HostOptions.EngineOptions.SSHTransport() {
+		addr, err := daemonAddr(h)
+		if err != nil {
+			return err
+		}
+
+		conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+		if err != nil {
+			return fmt.Errorf("engine is not listening on %s: %s", addr, err)
+		}
+		conn.Close()
+	}
+
+	if _, err := h.RunSSHCommand(h.Driver.SSHSudo("docker version")); err != nil {
+		return fmt.Errorf("engine is not responding: %s", err)
+	}
+
+	return nil
+}
+
+func restartDaemon(h *host.Host) error {
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	return provisioner.Service("docker", serviceaction.Restart)
+}
+
+// checkTLS makes sure the server certificate is not about to expire and is
+// the one the engine uses.
+func checkTLS(h *host.Host) error {
+	authOptions := h.HostOptions.AuthOptions
+
+	// Adopted machines have no server certificate of Machine's to check.
+	if authOptions.ServerCertPath != "" {
+		notAfter, err := cert.ReadCertificateExpiry(authOptions.ServerCertPath)
+		if err != nil {
+			return err
+		}
+
+		if err := checkCertExpiry(notAfter, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	addr, err := daemonAddr(h)
+	if err != nil {
+		return err
+	}
+
+	if _, err := cert.ValidateCertificate(addr, authOptions); err != nil {
+		return fmt.Errorf("engine does not accept the machine's certificates: %s", err)
+	}
+
+	return nil
+}
+
+func checkCertExpiry(notAfter, now time.Time) error {
+	if now.After(notAfter) {
+		return fmt.Errorf("server certificate expired on %s", notAfter.Format(time.RFC3339))
+	}
+
+	if notAfter.Sub(now) < CertExpiryThreshold {
+		return fmt.Errorf("server certificate expires on %s", notAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func regenerateCerts(h *host.Host) error {
+	return h.ConfigureAuth()
+}
+
+func checkDisk(h *host.Host) error {
+	out, err := h.RunSSHCommand("df -P /var/lib/docker")
+	if err != nil {
+		return err
+	}
+
+	usage, err := parseDiskUsage(out)
+	if err != nil {
+		return err
+	}
+
+	if usage >= DiskUsageThreshold {
+		return fmt.Errorf("disk holding /var/lib/docker is %d%% full", usage)
+	}
+
+	return nil
+}
+
+// parseDiskUsage returns the percentage used in the output of "df -P" for a
+// single path.
+func parseDiskUsage(out string) (int, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected df output: %q", out)
+	}
+
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 5 {
+		return 0, fmt.Errorf("unexpected df output: %q", out)
+	}
+
+	usage, err := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output: %q", out)
+	}
+
+	return usage, nil
+}
+
+// checkSwarm makes sure the swarm containers of the machine are running.
+func checkSwarm(h *host.Host) error {
+	swarmOptions := h.HostOptions.SwarmOptions
+	if swarmOptions == nil || !swarmOptions.IsSwarm {
+		return errNotSwarm
+	}
+
+	containers := []string{"swarm-agent"}
+	if swarmOptions.Master {
+		containers = append(containers, "swarm-agent-master")
+	}
+
+	for _, name := range containers {
+		command := h.Driver.SSHSudo(fmt.Sprintf("docker inspect -f '{{.State.Running}}' %s", name))
+		out, err := h.RunSSHCommand(command)
+		if err != nil {
+			return fmt.Errorf("%s container is missing", name)
+		}
+		if strings.TrimSpace(out) != "true" {
+			return fmt.Errorf("%s container is not running", name)
+		}
+	}
+
+	return nil
+}
+
+func rejoinSwarm(h *host.Host) error {
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	return provision.RejoinSwarm(provisioner, *h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions)
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/drivers/generic"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestCheckStoppedMachine(t *testing.T) {
+	h := &host.Host{
+		Name:   "stopped",
+		Driver: &fakedriver.Driver{MockState: state.Stopped},
+	}
+
+	report := Check(h)
+
+	if report.Healthy() {
+		t.Fatal("Expected a stopped machine to be unhealthy")
+	}
+
+	if len(report.Results) != len(checks) {
+		t.Fatalf("Expected a result for every check, got %d", len(report.Results))
+	}
+
+	if res := report.Result(CheckRunning); res.Status != StatusFailed {
+		t.Fatalf("Expected the running check to fail, got %s", res.Status)
+	}
+
+	for _, res := range report.Results[1:] {
+		if res.Status != StatusSkipped {
+			t.Fatalf("Expected the %s check to be skipped, got %s", res.Check, res.Status)
+		}
+	}
+}
+
+func TestRunCheckHeals(t *testing.T) {
+	healed := false
+	c := check{
+		name: "flaky",
+		run: func(h *host.Host) error {
+			if !healed {
+				return errors.New("flaky check failed")
+			}
+			return nil
+		},
+		heal: func(h *host.Host) error {
+			healed = true
+			return nil
+		},
+	}
+
+	res := runCheck(&host.Host{}, c, &Report{}, false)
+	if res.Status != StatusFailed {
+		t.Fatalf("Expected the check to fail without healing, got %s", res.Status)
+	}
+
+	res = runCheck(&host.Host{}, c, &Report{}, true)
+	if res.Status != StatusOK || !res.Healed {
+		t.Fatalf("Expected the check to be healed, got %+v", res)
+	}
+}
+
+func TestRunCheckDoesNotHealAdoptedHost(t *testing.T) {
+	c := check{
+		name: "broken",
+		run: func(h *host.Host) error {
+			return errors.New("broken check failed")
+		},
+		heal: func(h *host.Host) error {
+			t.Fatal("Expected an adopted host not to be healed")
+			return nil
+		},
+	}
+
+	h := &host.Host{Driver: &generic.Driver{Adopt: true}}
+	res := runCheck(h, c, &Report{}, true)
+	if res.Status != StatusFailed || !strings.Contains(res.Message, "not healing") {
+		t.Fatalf("Expected the check to fail without healing, got %+v", res)
+	}
+}
+
+func TestCheckSwarmNotInSwarm(t *testing.T) {
+	h := &host.Host{
+		HostOptions: &host.HostOptions{
+			SwarmOptions: &swarm.SwarmOptions{},
+		},
+	}
+
+	if err := checkSwarm(h); err != errNotSwarm {
+		t.Fatalf("Expected errNotSwarm, got %v", err)
+	}
+}
+
+func TestParseDiskUsage(t *testing.T) {
+	out := `Filesystem     1024-blocks    Used Available Capacity Mounted on
+/dev/sda1         19049892 1503540  16555216      9% /mnt/sda1
+`
+
+	usage, err := parseDiskUsage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage != 9 {
+		t.Fatalf("Expected 9%% usage, got %d", usage)
+	}
+
+	if _, err := parseDiskUsage("df: /var/lib/docker: No such file or directory"); err == nil {
+		t.Fatal("Expected an error for unexpected df output")
+	}
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	now := time.Now()
+
+	if err := checkCertExpiry(now.Add(365*24*time.Hour), now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkCertExpiry(now.Add(time.Hour), now); err == nil {
+		t.Fatal("Expected a certificate about to expire to fail the check")
+	}
+
+	if err := checkCertExpiry(now.Add(-time.Hour), now); err == nil {
+		t.Fatal("Expected an expired certificate to fail the check")
+	}
+}
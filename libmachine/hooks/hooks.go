@@ -0,0 +1,135 @@
+// Package hooks runs the commands users hook to points of the lifecycle of
+// machines, like registering DNS records once a machine is created.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/notify"
+	"golang.org/x/net/context"
+)
+
+// The points of the lifecycle of machines hooks run at.
+const (
+	PreCreate     = "pre-create"
+	PostCreate    = "post-create"
+	PreRm         = "pre-rm"
+	PostProvision = "post-provision"
+)
+
+// Points are the points hooks run at.
+var Points = []string{PreCreate, PostCreate, PreRm, PostProvision}
+
+// Machine is what hooks are told about the machine, as JSON on their
+// standard input.
+type Machine struct {
+	Hook      string `json:"hook"`
+	Name      string `json:"name"`
+	Driver    string `json:"driver"`
+	IP        string `json:"ip,omitempty"`
+	StorePath string `json:"store_path"`
+}
+
+// env returns the environment variables hooks are run with, telling them
+// about the machine too.
+func (m Machine) env() []string {
+	return append(os.Environ(),
+		"MACHINE_HOOK="+m.Hook,
+		"MACHINE_NAME="+m.Name,
+		"MACHINE_DRIVER="+m.Driver,
+		"MACHINE_IP="+m.IP,
+		"MACHINE_STORAGE_PATH="+m.StorePath,
+	)
+}
+
+// Hooks are the hooks of a store: the executables named after a point in
+// Dir, and the commands configured for each point, run in that order.
+type Hooks struct {
+	Dir      string
+	Commands map[string][]notify.Command
+
+	// Stdout and Stderr are where the output of the hooks goes
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Validate checks that the commands are configured for known points.
+func (h Hooks) Validate() error {
+	for point, commands := range h.Commands {
+		if !isPoint(point) {
+			return fmt.Errorf("unknown hook %q, expected one of %v", point, Points)
+		}
+		for _, command := range commands {
+			if len(command) == 0 {
+				return fmt.Errorf("empty command for hook %q", point)
+			}
+		}
+	}
+	return nil
+}
+
+// commands returns the commands hooked to the point.
+func (h Hooks) commands(point string) []notify.Command {
+	commands := []notify.Command{}
+
+	if h.Dir != "" {
+		path := filepath.Join(h.Dir, point)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			if fi.Mode()&0111 == 0 {
+				log.Warnf("Skipping hook %s: it is not executable", path)
+			} else {
+				commands = append(commands, notify.Command{path})
+			}
+		}
+	}
+
+	return append(commands, h.Commands[point]...)
+}
+
+// Run runs the hooks of the point for the machine, one after the other,
+// stopping at the first one which fails.
+func (h Hooks) Run(ctx context.Context, point string, m Machine) error {
+	m.Hook = point
+
+	commands := h.commands(point)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		log.Debugf("Running %s hook %v", point, command)
+
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = h.Stdout
+		cmd.Stderr = h.Stderr
+		cmd.Env = m.env()
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("Error running %s hook %s: %s", point, command[0], err)
+		}
+	}
+
+	return nil
+}
+
+func isPoint(point string) bool {
+	for _, p := range Points {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/notify"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, PostCreate), []byte("#!/bin/sh\necho \"dir $MACHINE_HOOK $MACHINE_NAME $MACHINE_IP\"\n"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, PreRm), []byte("#!/bin/sh\necho never\n"), 0644))
+
+	out := &bytes.Buffer{}
+	h := Hooks{
+		Dir: dir,
+		Commands: map[string][]notify.Command{
+			PostCreate: {{"sh", "-c", "cat"}},
+		},
+		Stdout: out,
+		Stderr: out,
+	}
+
+	m := Machine{Name: "web", Driver: "amazonec2", IP: "203.0.113.10", StorePath: dir}
+
+	assert.NoError(t, h.Run(context.Background(), PostCreate, m))
+
+	m.Hook = PostCreate
+	payload, _ := json.Marshal(m)
+	assert.Equal(t, "dir post-create web 203.0.113.10\n"+string(payload), out.String())
+
+	out.Reset()
+	assert.NoError(t, h.Run(context.Background(), PreRm, m))
+	assert.Equal(t, "", out.String())
+
+	assert.NoError(t, h.Run(context.Background(), PostProvision, m))
+}
+
+func TestRunStopsAtFailure(t *testing.T) {
+	out := &bytes.Buffer{}
+	h := Hooks{
+		Commands: map[string][]notify.Command{
+			PreCreate: {
+				{"sh", "-c", "echo over quota >&2; exit 1"},
+				{"sh", "-c", "echo never"},
+			},
+		},
+		Stdout: out,
+		Stderr: out,
+	}
+
+	err := h.Run(context.Background(), PreCreate, Machine{Name: "web"})
+
+	assert.EqualError(t, err, "Error running pre-create hook sh: exit status 1")
+	assert.Equal(t, "over quota\n", out.String())
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Hooks{Commands: map[string][]notify.Command{PreRm: {{"true"}}}}.Validate())
+	assert.EqualError(t, Hooks{Commands: map[string][]notify.Command{"post-rm": {{"true"}}}}.Validate(),
+		`unknown hook "post-rm", expected one of [pre-create post-create pre-rm post-provision]`)
+	assert.EqualError(t, Hooks{Commands: map[string][]notify.Command{PreRm: {{}}}}.Validate(), `empty command for hook "pre-rm"`)
+}
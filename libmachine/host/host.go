@@ -3,25 +3,42 @@ package host
 import (
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/inventory"
+	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/nfs"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/verify"
+	"golang.org/x/net/context"
 )
 
 var (
 	validHostNameChars                = `^[a-zA-Z0-9][a-zA-Z0-9\-\.]*$`
 	validHostNamePattern              = regexp.MustCompile(validHostNameChars)
 	errMachineMustBeRunningForUpgrade = errors.New("Error: machine must be running to upgrade.")
+	errMachineMustBePausedForResume   = errors.New("Error: machine must be paused to resume.")
+
+	// ErrAdopted is returned when changing a machine which was adopted,
+	// see drivers.Adopter.
+	ErrAdopted = errors.New("it was adopted, Machine leaves it as it is")
+
+	// ErrNoSSH is returned when connecting over SSH to a machine whose
+	// driver runs the commands itself, see drivers.Transporter.
+	ErrNoSSH = errors.New("its commands are run by its driver rather than over SSH")
 )
 
 type Host struct {
@@ -31,6 +48,47 @@ type Host struct {
 	HostOptions   *HostOptions
 	Name          string
 	RawDriver     []byte
+
+	// CreateStage is the last stage of creating the machine which completed.
+	CreateStage CreateStage
+
+	// Provisioning is set while the machine is being provisioned, during
+	// which its state on disk is inconsistent.
+	Provisioning bool
+
+	// Snapshots lists the snapshots taken with the driver, oldest first.
+	Snapshots []Snapshot
+
+	// NFSShares lists the local directories exported to the machine over
+	// NFS, which it mounts.
+	NFSShares []nfs.Share `json:",omitempty"`
+
+	// ProvisionedAt is when the machine was last provisioned successfully.
+	ProvisionedAt time.Time
+
+	// Hardening tells what the hardening profile of the engine options
+	// applied when the machine was last provisioned.
+	Hardening *provision.HardeningReport `json:",omitempty"`
+
+	// Verification is the report of the last verify run on the machine.
+	Verification *verify.Report `json:",omitempty"`
+
+	// DesiredState is the state the machine was last created, started,
+	// stopped or paused into, the one watch expects it to be in.
+	DesiredState state.State `json:",omitempty"`
+
+	// EngineVersion is the version of the engine when the machine was last
+	// provisioned or upgraded, the one watch expects it to run.
+	EngineVersion string `json:",omitempty"`
+
+	// Inventory is what the machine was found to run when it was last
+	// provisioned or refreshed.
+	Inventory *inventory.Inventory `json:",omitempty"`
+
+	// ExpiresAt is when the machine expires, after the TTL it was created
+	// with, for reap to stop or remove it. Machines created without a TTL
+	// never expire.
+	ExpiresAt time.Time
 }
 
 type HostOptions struct {
@@ -52,11 +110,23 @@ func ValidateHostName(name string) bool {
 	return validHostNamePattern.MatchString(name)
 }
 
+// RunSSHCommand runs the command on the machine, over SSH unless its driver
+// runs the commands itself, see drivers.GetTransport.
 func (h *Host) RunSSHCommand(command string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(h.Driver, command)
+	return drivers.RunCommandFromDriver(h.Driver, command)
 }
 
 func (h *Host) CreateSSHClient() (ssh.Client, error) {
+	return h.createSSHClient(h.Driver.GetSSHKeyPath(), false)
+}
+
+// CreateAgentForwardingSSHClient creates an SSH client forwarding the
+// ssh-agent of the user to the machine.
+func (h *Host) CreateAgentForwardingSSHClient() (ssh.Client, error) {
+	return h.createSSHClient(h.Driver.GetSSHKeyPath(), true)
+}
+
+func (h *Host) createSSHClient(keyPath string, forwardAgent bool) (ssh.Client, error) {
 	addr, err := h.Driver.GetSSHHostname()
 	if err != nil {
 		return ssh.ExternalClient{}, err
@@ -67,13 +137,50 @@ func (h *Host) CreateSSHClient() (ssh.Client, error) {
 		return ssh.ExternalClient{}, err
 	}
 
+	bastion, err := drivers.GetSSHBastion(h.Driver)
+	if err != nil {
+		return ssh.ExternalClient{}, err
+	}
+
 	auth := &ssh.Auth{
-		Keys: []string{h.Driver.GetSSHKeyPath()},
+		Keys:         []string{keyPath},
+		Bastion:      bastion,
+		ForwardAgent: forwardAgent,
+		Options:      drivers.GetSSHOptions(h.Driver),
 	}
 
 	return ssh.NewClient(h.Driver.GetSSHUsername(), addr, port, auth)
 }
 
+// Adopted tells whether the machine was adopted as it is, see
+// drivers.Adopter.
+func (h *Host) Adopted() bool {
+	return drivers.IsAdopted(h.Driver)
+}
+
+// Expired tells whether the machine expired at now, see ExpiresAt.
+func (h *Host) Expired(now time.Time) bool {
+	return !h.ExpiresAt.IsZero() && !now.Before(h.ExpiresAt)
+}
+
+// RefuseAdopted returns an error telling the action can't be done when the
+// machine was adopted.
+func (h *Host) RefuseAdopted(action string) error {
+	if h.Adopted() {
+		return fmt.Errorf("Cannot %s machine %q: %s", action, h.Name, ErrAdopted)
+	}
+	return nil
+}
+
+// RefuseWithoutSSH returns an error telling the action can't be done when
+// the machine isn't reached over SSH.
+func (h *Host) RefuseWithoutSSH(action string) error {
+	if drivers.SupportsTransport(h.Driver) {
+		return fmt.Errorf("Cannot %s machine %q: %s", action, h.Name, ErrNoSSH)
+	}
+	return nil
+}
+
 func (h *Host) runActionForState(action func() error, desiredState state.State) error {
 	if drivers.MachineInState(h.Driver, desiredState)() {
 		return fmt.Errorf("Machine %q is already %s.", h.Name, strings.ToLower(desiredState.String()))
@@ -82,8 +189,14 @@ func (h *Host) runActionForState(action func() error, desiredState state.State)
 	if err := action(); err != nil {
 		return err
 	}
+	h.DesiredState = desiredState
+
+	timeout := drivers.DefaultWaitTimeout
+	if desiredState == state.Running {
+		timeout = drivers.GetWaitTimeouts(h.Driver).Running
+	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
+	return drivers.WaitForStage(h.Driver, mcnutils.WaitState, mcnutils.WaitSettings{Timeout: timeout}, drivers.MachineInState(h.Driver, desiredState))
 }
 
 func (h *Host) Start() error {
@@ -98,13 +211,47 @@ func (h *Host) Kill() error {
 	return h.runActionForState(h.Driver.Kill, state.Stopped)
 }
 
+func (h *Host) suspender() (drivers.Suspender, error) {
+	suspender, ok := h.Driver.(drivers.Suspender)
+	if !ok || !drivers.SupportsSuspend(h.Driver) {
+		return nil, fmt.Errorf("Machine %q cannot be paused: %s", h.Name, drivers.ErrSuspendNotImplemented)
+	}
+
+	return suspender, nil
+}
+
+// Pause saves the state of a running machine and stops it, so that it can
+// be resumed later with all of its containers still running.
+func (h *Host) Pause() error {
+	suspender, err := h.suspender()
+	if err != nil {
+		return err
+	}
+
+	return h.runActionForState(suspender.Suspend, state.Saved)
+}
+
+// Resume restores a machine paused with Pause.
+func (h *Host) Resume() error {
+	suspender, err := h.suspender()
+	if err != nil {
+		return err
+	}
+
+	if !drivers.MachineInState(h.Driver, state.Saved)() {
+		return errMachineMustBePausedForResume
+	}
+
+	return h.runActionForState(suspender.Resume, state.Running)
+}
+
 func (h *Host) Restart() error {
 	if drivers.MachineInState(h.Driver, state.Running)() {
 		if err := h.Stop(); err != nil {
 			return err
 		}
 
-		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Stopped)); err != nil {
+		if err := drivers.WaitForStage(h.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(h.Driver, state.Stopped)); err != nil {
 			return err
 		}
 	}
@@ -113,7 +260,7 @@ func (h *Host) Restart() error {
 		return err
 	}
 
-	if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+	if err := drivers.WaitForStage(h.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(h.Driver, state.Running)); err != nil {
 		return err
 	}
 
@@ -121,6 +268,25 @@ func (h *Host) Restart() error {
 }
 
 func (h *Host) Upgrade() error {
+	return h.UpgradeEngine(provision.EngineTarget{})
+}
+
+// UpgradeEngine installs the target engine on the machine, upgrading or
+// downgrading it as needed, and checks the engine running afterwards.
+func (h *Host) UpgradeEngine(target provision.EngineTarget) error {
+	return h.UpgradeEngineContext(context.Background(), target)
+}
+
+// UpgradeEngineContext is UpgradeEngine, giving up as soon as ctx is done.
+func (h *Host) UpgradeEngineContext(ctx context.Context, target provision.EngineTarget) error {
+	if err := target.Validate(); err != nil {
+		return err
+	}
+
+	if err := h.RefuseAdopted("upgrade"); err != nil {
+		return err
+	}
+
 	machineState, err := h.Driver.GetState()
 	if err != nil {
 		return err
@@ -130,26 +296,105 @@ func (h *Host) Upgrade() error {
 		return errMachineMustBeRunningForUpgrade
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := provision.DetectProvisioner(drivers.WithContext(ctx, h.Driver))
 	if err != nil {
 		return err
 	}
 
-	if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
-		return err
+	if target.IsLatestStable() {
+		if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
+			return err
+		}
+	} else {
+		upgrader, ok := provisioner.(provision.EngineUpgrader)
+		if !ok {
+			osName := "this operating system"
+			if info, err := provisioner.GetOsReleaseInfo(); err == nil && info != nil {
+				osName = info.Id
+			}
+			return fmt.Errorf("Upgrading to a specific engine version or channel is not supported on %s", osName)
+		}
+
+		log.Infof("Upgrading the engine of %s to %s...", h.Name, target)
+		if err := upgrader.UpgradeEngine(target); err != nil {
+			return err
+		}
+	}
+
+	// The upgrade of boot2docker replaces its /etc.
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		if err := provision.ConfigureRegistries(provisioner, *h.HostOptions.EngineOptions); err != nil {
+			return err
+		}
 	}
 
 	if err := provisioner.Service("docker", serviceaction.Restart); err != nil {
 		return err
 	}
+
+	return h.checkEngineVersion(ctx, provisioner, target)
+}
+
+// checkEngineVersion waits for the engine to come back up after an upgrade
+// and makes sure it runs the version wanted.
+func (h *Host) checkEngineVersion(ctx context.Context, provisioner provision.Provisioner, target provision.EngineTarget) error {
+	var version string
+	if err := mcnutils.WaitForStage(ctx, mcnutils.WaitDaemon, mcnutils.WaitSettings{Timeout: 30 * time.Second}, func() bool {
+		v, err := provision.EngineVersion(provisioner)
+		if err != nil || v == "" {
+			return false
+		}
+		version = v
+		return true
+	}); err != nil {
+		return fmt.Errorf("Error checking the engine version after the upgrade: %w", err)
+	}
+
+	if !target.Matches(version) {
+		return fmt.Errorf("Engine version %s is running after the upgrade, expected %s", version, target.Version)
+	}
+	h.EngineVersion = version
+
+	log.Infof("%s is running engine version %s", h.Name, version)
 	return nil
 }
 
+// GetURL returns the URL the engine is reached at, the one of its TCP
+// listener, or with the SSH transport, the ssh:// URL of the machine its
+// unix socket is reached through.
 func (h *Host) GetURL() (string, error) {
-	return h.Driver.GetURL()
+	if h.HostOptions == nil || !h.HostOptions.EngineOptions.SSHTransport() {
+		return h.Driver.GetURL()
+	}
+
+	address, err := h.Driver.GetSSHHostname()
+	if err != nil {
+		return "", err
+	}
+
+	port, err := h.Driver.GetSSHPort()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("ssh://%s@%s", h.Driver.GetSSHUsername(), net.JoinHostPort(address, strconv.Itoa(port))), nil
+}
+
+// CollectInventory collects the inventory of the machine and records it,
+// even partial when the engine can't be asked, with the error.
+func (h *Host) CollectInventory() error {
+	inv, err := inventory.Collect(drivers.GetTransport(h.Driver), h.Driver.SSHSudo)
+	if inv != nil {
+		h.Inventory = inv
+	}
+	return err
 }
 
 func (h *Host) ConfigureAuth() error {
+	if err := h.RefuseAdopted("regenerate the certificates of"); err != nil {
+		return err
+	}
+
 	provisioner, err := provision.DetectProvisioner(h.Driver)
 	if err != nil {
 		return err
@@ -164,5 +409,7 @@ func (h *Host) ConfigureAuth() error {
 		return err
 	}
 
+	h.ProvisionedAt = time.Now()
+
 	return nil
 }
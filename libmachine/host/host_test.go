@@ -2,8 +2,14 @@ package host
 
 import (
 	"testing"
+	"time"
 
-	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/drivers/generic"
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/state"
 )
 
 func TestValidateHostnameValid(t *testing.T) {
@@ -35,3 +41,268 @@ func TestValidateHostnameInvalid(t *testing.T) {
 		}
 	}
 }
+
+func TestPauseAndResume(t *testing.T) {
+	h := &Host{
+		Name:   "paused",
+		Driver: &fakedriver.Driver{MockState: state.Running},
+	}
+
+	if err := h.Resume(); err != errMachineMustBePausedForResume {
+		t.Fatalf("Expected resuming a running machine to fail, got: %v", err)
+	}
+
+	if err := h.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := h.Driver.GetState(); s != state.Saved {
+		t.Fatalf("Expected machine to be saved after pausing, got: %s", s)
+	}
+
+	if err := h.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := h.Driver.GetState(); s != state.Running {
+		t.Fatalf("Expected machine to be running after resuming, got: %s", s)
+	}
+}
+
+func TestPauseNotSupported(t *testing.T) {
+	h := &Host{
+		Name:   "unpausable",
+		Driver: none.NewDriver("unpausable", "/tmp/artifacts"),
+	}
+
+	if err := h.Pause(); err == nil {
+		t.Fatal("Expected pausing with a driver without suspend support to fail")
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	h := &Host{
+		Name:   "snapshotted",
+		Driver: &fakedriver.Driver{MockState: state.Running},
+	}
+
+	if err := h.CreateSnapshot("clean"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.CreateSnapshot("clean"); err == nil {
+		t.Fatal("Expected taking a snapshot with a duplicate name to fail")
+	}
+
+	if len(h.Snapshots) != 1 || h.Snapshots[0].Name != "clean" {
+		t.Fatalf("Expected one snapshot named clean, got: %v", h.Snapshots)
+	}
+
+	if err := h.RestoreSnapshot("missing"); err == nil {
+		t.Fatal("Expected restoring an unknown snapshot to fail")
+	}
+
+	h.Provisioning = true
+	if err := h.RestoreSnapshot("clean"); err != errMachineProvisioning {
+		t.Fatalf("Expected restoring while provisioning to fail, got: %v", err)
+	}
+
+	h.Provisioning = false
+	if err := h.RestoreSnapshot("clean"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.RemoveSnapshot("clean"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.Snapshots) != 0 {
+		t.Fatalf("Expected no snapshots after removal, got: %v", h.Snapshots)
+	}
+}
+
+func TestSnapshotsNotSupported(t *testing.T) {
+	h := &Host{
+		Name:   "unsnapshottable",
+		Driver: none.NewDriver("unsnapshottable", "/tmp/artifacts"),
+	}
+
+	if err := h.CreateSnapshot("clean"); err == nil {
+		t.Fatal("Expected taking a snapshot with a driver without snapshot support to fail")
+	}
+}
+
+func TestResize(t *testing.T) {
+	h := &Host{
+		Name:   "resized",
+		Driver: &fakedriver.Driver{MockState: state.Running},
+	}
+
+	if err := h.Resize(drivers.ResizeOptions{}); err != errNothingToResize {
+		t.Fatalf("Expected resizing nothing to fail, got: %v", err)
+	}
+
+	h.Provisioning = true
+	if err := h.Resize(drivers.ResizeOptions{CPUs: 2}); err != errMachineProvisioningState {
+		t.Fatalf("Expected resizing while provisioning to fail, got: %v", err)
+	}
+
+	h.Provisioning = false
+	if err := h.Resize(drivers.ResizeOptions{CPUs: 2, Memory: 2048}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := h.Driver.GetState(); s != state.Running {
+		t.Fatalf("Expected machine to be running again after resizing, got: %s", s)
+	}
+
+	h.Driver.Stop()
+	if err := h.Resize(drivers.ResizeOptions{CPUs: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := h.Driver.GetState(); s != state.Stopped {
+		t.Fatalf("Expected machine to stay stopped after resizing, got: %s", s)
+	}
+}
+
+func TestResizeNotSupported(t *testing.T) {
+	h := &Host{
+		Name:   "unresizable",
+		Driver: none.NewDriver("unresizable", "/tmp/artifacts"),
+	}
+
+	if err := h.Resize(drivers.ResizeOptions{CPUs: 2}); err == nil {
+		t.Fatal("Expected resizing with a driver without resize support to fail")
+	}
+}
+
+func TestCreateStages(t *testing.T) {
+	if stage, err := ParseCreateStage("ssh-ready"); err != nil || stage != StageSSHReady {
+		t.Fatalf("Expected to parse ssh-ready, got %q, %v", stage, err)
+	}
+
+	if _, err := ParseCreateStage("finished"); err == nil {
+		t.Fatal("Expected parsing an unknown stage to fail")
+	}
+
+	if next := StageNew.Next(); next != StageCreated {
+		t.Fatalf("Expected the first stage to be created, got %q", next)
+	}
+
+	if next := StageProvisioned.Next(); next != StageCerts {
+		t.Fatalf("Expected provisioned to be followed by certs, got %q", next)
+	}
+
+	if next := StageCerts.Next(); next != "" {
+		t.Fatalf("Expected certs to be the last stage, got %q", next)
+	}
+
+	h := &Host{CreateStage: StageSSHReady}
+	if h.CreateComplete() {
+		t.Fatal("Expected a host stopped at ssh-ready not to be complete")
+	}
+
+	h.CreateStage = ""
+	if !h.CreateComplete() {
+		t.Fatal("Expected a host without stages to be complete")
+	}
+}
+
+func TestAuthorizedKey(t *testing.T) {
+	lines := map[string]string{
+		"ssh-ed25519 AAAAC3Nza\n":                            "ssh-ed25519 AAAAC3Nza",
+		"ssh-rsa AAAAB3Nza docker@dev\n":                     "ssh-rsa AAAAB3Nza",
+		`no-pty,command="true" ssh-rsa AAAAB3Nza docker@dev`: "ssh-rsa AAAAB3Nza",
+	}
+
+	for line, expected := range lines {
+		key, err := authorizedKey([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != expected {
+			t.Fatalf("Expected %q, got %q", expected, key)
+		}
+	}
+
+	if _, err := authorizedKey([]byte("garbage")); err == nil {
+		t.Fatal("Expected an error for an invalid key")
+	}
+}
+
+// sshDriver is a fake driver reached over SSH.
+type sshDriver struct {
+	*fakedriver.Driver
+}
+
+func (d sshDriver) GetSSHHostname() (string, error) {
+	return "fe80::1", nil
+}
+
+func (d sshDriver) GetSSHPort() (int, error) {
+	return 2222, nil
+}
+
+func (d sshDriver) GetSSHUsername() string {
+	return "docker"
+}
+
+func TestGetURL(t *testing.T) {
+	h := &Host{
+		Driver:      sshDriver{&fakedriver.Driver{MockURL: "tcp://1.2.3.4:2376"}},
+		HostOptions: &HostOptions{EngineOptions: &engine.EngineOptions{}},
+	}
+
+	if url, err := h.GetURL(); err != nil || url != "tcp://1.2.3.4:2376" {
+		t.Fatalf("Expected the URL of the TCP listener, got %q, %v", url, err)
+	}
+
+	h.HostOptions.EngineOptions.Transport = engine.TransportSSH
+
+	if url, err := h.GetURL(); err != nil || url != "ssh://docker@[fe80::1]:2222" {
+		t.Fatalf("Expected the ssh:// URL of the machine, got %q, %v", url, err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+
+	h := &Host{}
+	if h.Expired(now) {
+		t.Fatal("Expected a machine without a TTL never to expire")
+	}
+
+	h.ExpiresAt = now.Add(time.Minute)
+	if h.Expired(now) {
+		t.Fatal("Expected the machine not to have expired yet")
+	}
+	if !h.Expired(now.Add(time.Minute)) {
+		t.Fatal("Expected the machine to have expired")
+	}
+}
+
+func TestRunSSHCommandWithLocalTransport(t *testing.T) {
+	d := generic.NewDriver("local", "/store").(*generic.Driver)
+	d.IPAddress = "10.0.0.5"
+	d.Local = true
+	h := &Host{Name: "local", Driver: d}
+
+	out, err := h.RunSSHCommand("echo hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hello\n" {
+		t.Fatalf("Expected the command to run locally, got: %q", out)
+	}
+
+	expected := `Cannot rotate the SSH key of machine "local": ` + ErrNoSSH.Error()
+	if err := h.RotateSSHKey(""); err == nil || err.Error() != expected {
+		t.Fatalf("Expected %q, got: %v", expected, err)
+	}
+
+	expected = `Cannot change the SSH settings of machine "local": ` + ErrNoSSH.Error()
+	if err := h.UpdateSSHSettings(drivers.SSHSettings{Port: 2222}, true); err == nil || err.Error() != expected {
+		t.Fatalf("Expected %q, got: %v", expected, err)
+	}
+}
@@ -0,0 +1,90 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+)
+
+var (
+	errNothingToResize          = errors.New("Error: nothing to resize, expected CPUs, memory, disk size or instance type")
+	errMachineProvisioningState = errors.New("Error: machine is being provisioned, refusing to resize it")
+)
+
+// Resize changes the resources of the machine given in opts. The machine is
+// stopped to be resized, then started again if it was running or if its disk
+// grew, for the provisioner to grow its filesystem too. The caller is
+// responsible for saving the host afterwards, as the driver updates its
+// configuration.
+func (h *Host) Resize(opts drivers.ResizeOptions) error {
+	resizer, ok := h.Driver.(drivers.Resizer)
+	if !ok || !drivers.SupportsResize(h.Driver) {
+		return fmt.Errorf("Cannot resize machine %q: %s", h.Name, drivers.ErrResizeNotImplemented)
+	}
+
+	if opts.IsZero() {
+		return errNothingToResize
+	}
+
+	if h.Provisioning {
+		return errMachineProvisioningState
+	}
+
+	currentState, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+
+	wasRunning := currentState == state.Running
+	if wasRunning {
+		log.Infof("Stopping %q to resize it...", h.Name)
+		if err := h.Stop(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Resizing %q...", h.Name)
+	if err := resizer.Resize(opts); err != nil {
+		return err
+	}
+
+	if !wasRunning && opts.DiskSize == 0 {
+		return nil
+	}
+
+	log.Infof("Starting %q...", h.Name)
+	if err := h.Start(); err != nil {
+		return err
+	}
+
+	if opts.DiskSize > 0 {
+		if err := h.growFilesystem(); err != nil {
+			return err
+		}
+	}
+
+	if !wasRunning {
+		log.Infof("Stopping %q again...", h.Name)
+		return h.Stop()
+	}
+
+	return nil
+}
+
+func (h *Host) growFilesystem() error {
+	if err := drivers.WaitForTransport(h.Driver); err != nil {
+		return err
+	}
+
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Growing the filesystem of %q...", h.Name)
+	return provision.GrowFilesystem(provisioner)
+}
@@ -0,0 +1,106 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+var errMachineProvisioning = errors.New("Error: machine is being provisioned, refusing to restore a snapshot")
+
+// Snapshot is the metadata Machine keeps about a snapshot taken with the
+// hypervisor. The snapshot itself is stored by the hypervisor.
+type Snapshot struct {
+	Name      string
+	CreatedAt time.Time
+}
+
+func (h *Host) snapshotter() (drivers.Snapshotter, error) {
+	snapshotter, ok := h.Driver.(drivers.Snapshotter)
+	if !ok || !drivers.SupportsSnapshots(h.Driver) {
+		return nil, fmt.Errorf("Cannot snapshot machine %q: %s", h.Name, drivers.ErrSnapshotNotImplemented)
+	}
+
+	return snapshotter, nil
+}
+
+// GetSnapshot returns the snapshot called name, or nil if there is none.
+func (h *Host) GetSnapshot(name string) *Snapshot {
+	for i := range h.Snapshots {
+		if h.Snapshots[i].Name == name {
+			return &h.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// CreateSnapshot takes a snapshot of the machine called name. The caller
+// is responsible for saving the host afterwards.
+func (h *Host) CreateSnapshot(name string) error {
+	snapshotter, err := h.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	if h.GetSnapshot(name) != nil {
+		return fmt.Errorf("Snapshot %q of machine %q already exists", name, h.Name)
+	}
+
+	if err := snapshotter.CreateSnapshot(name); err != nil {
+		return err
+	}
+
+	h.Snapshots = append(h.Snapshots, Snapshot{
+		Name:      name,
+		CreatedAt: time.Now(),
+	})
+
+	return nil
+}
+
+// RestoreSnapshot reverts the machine to the snapshot called name.
+func (h *Host) RestoreSnapshot(name string) error {
+	snapshotter, err := h.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	if h.Provisioning {
+		return errMachineProvisioning
+	}
+
+	if h.GetSnapshot(name) == nil {
+		return fmt.Errorf("Snapshot %q of machine %q does not exist", name, h.Name)
+	}
+
+	return snapshotter.RestoreSnapshot(name)
+}
+
+// RemoveSnapshot deletes the snapshot called name. The caller is
+// responsible for saving the host afterwards.
+func (h *Host) RemoveSnapshot(name string) error {
+	snapshotter, err := h.snapshotter()
+	if err != nil {
+		return err
+	}
+
+	if h.GetSnapshot(name) == nil {
+		return fmt.Errorf("Snapshot %q of machine %q does not exist", name, h.Name)
+	}
+
+	if err := snapshotter.RemoveSnapshot(name); err != nil {
+		return err
+	}
+
+	snapshots := []Snapshot{}
+	for _, s := range h.Snapshots {
+		if s.Name != name {
+			snapshots = append(snapshots, s)
+		}
+	}
+	h.Snapshots = snapshots
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// UpdateSSHSettings changes the settings of the SSH connections to the
+// machine, see drivers.SSHSettings. With check, the machine is logged in
+// with the new settings when it's running, the current ones being kept when
+// that fails. The host has to be saved for the settings to be kept. The
+// connections opened with the current settings are not reused for the
+// check.
+func (h *Host) UpdateSSHSettings(settings drivers.SSHSettings, check bool) error {
+	if err := h.RefuseWithoutSSH("change the SSH settings of"); err != nil {
+		return err
+	}
+
+	current, err := json.Marshal(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	if err := drivers.UpdateSSHSettings(h.Driver, settings); err != nil {
+		return err
+	}
+
+	if !check {
+		return nil
+	}
+
+	if s, err := h.Driver.GetState(); err != nil || s != state.Running {
+		log.Warnf("Machine %q is not running, the new SSH settings can't be checked", h.Name)
+		return nil
+	}
+
+	log.Info("Logging in with the new SSH settings...")
+	if _, err := h.RunSSHCommand("exit 0"); err != nil {
+		if err := json.Unmarshal(current, h.Driver); err != nil {
+			log.Warnf("Error restoring the current SSH settings: %s", err)
+		}
+		return fmt.Errorf("Error logging in with the new SSH settings, keeping the current ones: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package host
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// authorizedKey returns the type and the key of an authorized_keys line,
+// without its options or comment.
+func authorizedKey(line []byte) (string, error) {
+	fields := strings.Fields(string(line))
+	for i, field := range fields {
+		if strings.HasPrefix(field, "ssh-") && i+1 < len(fields) {
+			return field + " " + fields[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("Invalid SSH public key %q", strings.TrimSpace(string(line)))
+}
+
+// addAuthorizedKeyCommand returns the command appending key to the
+// authorized_keys of the user.
+func addAuthorizedKeyCommand(key string) string {
+	return fmt.Sprintf("mkdir -p ~/.ssh && chmod 700 ~/.ssh && echo '%s' >> ~/.ssh/authorized_keys && chmod 600 ~/.ssh/authorized_keys", key)
+}
+
+// removeAuthorizedKeyCommand returns the command removing the lines of key
+// from the authorized_keys of the user.
+func removeAuthorizedKeyCommand(key string) string {
+	return fmt.Sprintf("{ grep -vF '%s' ~/.ssh/authorized_keys || true; } > ~/.ssh/authorized_keys.tmp && chmod 600 ~/.ssh/authorized_keys.tmp && mv ~/.ssh/authorized_keys.tmp ~/.ssh/authorized_keys", key)
+}
+
+// RotateSSHKey replaces the SSH key of the machine with a new one of the
+// given type, the type of the current key when empty. The new key is
+// installed on the machine and logged in with before the current one is
+// removed from the machine and the store, so that a failure leaves the
+// current key working. The connections opened with the current key are not
+// reused to log in with the new one, see ssh.Auth.
+func (h *Host) RotateSSHKey(keyType string) error {
+	if err := h.RefuseAdopted("rotate the SSH key of"); err != nil {
+		return err
+	}
+	if err := h.RefuseWithoutSSH("rotate the SSH key of"); err != nil {
+		return err
+	}
+
+	keyPath := h.Driver.GetSSHKeyPath()
+	if keyPath == "" {
+		return fmt.Errorf("Machine %q has no SSH key to rotate", h.Name)
+	}
+
+	oldPublicKey, err := ssh.ReadPublicKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("Error reading the SSH key of machine %q: %s", h.Name, err)
+	}
+	oldKey, err := authorizedKey(oldPublicKey)
+	if err != nil {
+		return err
+	}
+
+	if keyType == "" {
+		keyType = ssh.KeyTypeRSA
+		if strings.HasPrefix(oldKey, "ssh-ed25519 ") {
+			keyType = ssh.KeyTypeEd25519
+		}
+	}
+
+	newKeyPath := keyPath + ".new"
+	os.Remove(newKeyPath)
+	os.Remove(newKeyPath + ".pub")
+
+	log.Infof("Generating a new %s SSH key...", keyType)
+	kp, err := ssh.NewKeyPairOfType(keyType)
+	if err != nil {
+		return err
+	}
+	if err := kp.WriteToFile(newKeyPath, newKeyPath+".pub"); err != nil {
+		return fmt.Errorf("Error writing the new SSH key: %s", err)
+	}
+	newKey, err := authorizedKey(kp.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Installing the new SSH key on the machine...")
+	if _, err := h.RunSSHCommand(addAuthorizedKeyCommand(newKey)); err != nil {
+		os.Remove(newKeyPath)
+		os.Remove(newKeyPath + ".pub")
+		return fmt.Errorf("Error installing the new SSH key: %s", err)
+	}
+
+	log.Info("Logging in with the new SSH key...")
+	client, err := h.createSSHClient(newKeyPath, false)
+	if err == nil {
+		_, err = client.Output("exit 0")
+	}
+	if err != nil {
+		if _, err := h.RunSSHCommand(removeAuthorizedKeyCommand(newKey)); err != nil {
+			log.Warnf("Error removing the new SSH key from the machine: %s", err)
+		}
+		os.Remove(newKeyPath)
+		os.Remove(newKeyPath + ".pub")
+		return fmt.Errorf("Error logging in with the new SSH key, keeping the current one: %s", err)
+	}
+
+	log.Info("Removing the old SSH key from the machine...")
+	if _, err := client.Output(removeAuthorizedKeyCommand(oldKey)); err != nil {
+		return fmt.Errorf("Error removing the old SSH key, both keys are authorized and the new one is %s: %s", newKeyPath, err)
+	}
+
+	if err := os.Rename(newKeyPath, keyPath); err != nil {
+		return fmt.Errorf("Error replacing the SSH key, the new one is %s: %s", newKeyPath, err)
+	}
+	if err := os.Rename(newKeyPath+".pub", keyPath+".pub"); err != nil {
+		return fmt.Errorf("Error replacing the SSH public key, the new one is %s.pub: %s", newKeyPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package host
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CreateStage is a step of creating a machine. Hosts record the last stage
+// they completed, so that a creation which failed part way can be resumed
+// instead of leaving a machine which can only be removed.
+type CreateStage string
+
+const (
+	// StageNew is recorded before any stage ran.
+	StageNew CreateStage = "new"
+
+	StageCreated     CreateStage = "created"
+	StageIPAssigned  CreateStage = "ip-assigned"
+	StageSSHReady    CreateStage = "ssh-ready"
+	StageProvisioned CreateStage = "provisioned"
+	StageCerts       CreateStage = "certs"
+)
+
+// CreateStages are the stages of creating a machine, in the order they run.
+var CreateStages = []CreateStage{
+	StageCreated,
+	StageIPAssigned,
+	StageSSHReady,
+	StageProvisioned,
+	StageCerts,
+}
+
+// ParseCreateStage returns the stage called name.
+func ParseCreateStage(name string) (CreateStage, error) {
+	for _, stage := range CreateStages {
+		if string(stage) == name {
+			return stage, nil
+		}
+	}
+
+	names := []string{}
+	for _, stage := range CreateStages {
+		names = append(names, string(stage))
+	}
+
+	return "", fmt.Errorf("Unknown stage %q, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// Index returns the position of the stage in CreateStages, -1 for StageNew
+// and for unknown stages.
+func (s CreateStage) Index() int {
+	for i, stage := range CreateStages {
+		if stage == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// Next returns the stage following s, or "" if s is the last one.
+func (s CreateStage) Next() CreateStage {
+	i := s.Index() + 1
+	if i >= len(CreateStages) {
+		return ""
+	}
+	return CreateStages[i]
+}
+
+// CreateComplete reports whether every stage of creating the machine
+// completed. Hosts created before stages were recorded have none, and are
+// considered complete.
+func (h *Host) CreateComplete() bool {
+	return h.CreateStage == "" || h.CreateStage == StageCerts
+}
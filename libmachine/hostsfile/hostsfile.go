@@ -0,0 +1,213 @@
+// Package hostsfile maintains entries naming machines, like
+// dev.docker.local, in a block of a hosts file per store, such as /etc/hosts
+// or a file read by a local DNS server.
+package hostsfile
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultDomain is the domain the names of the machines are in.
+	DefaultDomain = "docker.local"
+
+	beginMarker = "# BEGIN docker-machine"
+	endMarker   = "# END docker-machine"
+)
+
+// mu serializes the updates of the machines created or started in
+// parallel.
+var mu sync.Mutex
+
+// DefaultPath returns the hosts file of the OS.
+func DefaultPath() string {
+	if runtime.GOOS == "windows" {
+		return os.Getenv("SystemRoot") + `\System32\drivers\etc\hosts`
+	}
+	return "/etc/hosts"
+}
+
+// File is a hosts file in which a block of entries names the machines of
+// the store in Domain. The rest of the file, blocks of other stores
+// included, is left as it is.
+type File struct {
+	Path   string `json:"path"`
+	Domain string `json:"domain"`
+
+	// Store is the directory of the store the machines belong to.
+	Store string `json:"-"`
+}
+
+// Hostname returns the name of the machine in the file.
+func (f File) Hostname(machine string) string {
+	domain := f.Domain
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	return machine + "." + domain
+}
+
+// Entries returns the IPs of the machines in the block of the file, by
+// machine name.
+func (f File) Entries() (map[string]string, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, block, _ := f.split(data)
+	return f.parseBlock(block), nil
+}
+
+// Set names the machine with its IP in the file, replacing its previous
+// entry. An empty IP removes the entry.
+func (f File) Set(machine, ip string) error {
+	return f.update(func(entries map[string]string) {
+		if ip == "" {
+			delete(entries, machine)
+		} else {
+			entries[machine] = ip
+		}
+	})
+}
+
+// Remove removes the entry of the machine from the file.
+func (f File) Remove(machine string) error {
+	return f.Set(machine, "")
+}
+
+// Sync replaces the entries of the store in the file with ips, by machine
+// name. No ips remove the block of the store from the file.
+func (f File) Sync(ips map[string]string) error {
+	return f.update(func(entries map[string]string) {
+		for machine := range entries {
+			delete(entries, machine)
+		}
+		for machine, ip := range ips {
+			entries[machine] = ip
+		}
+	})
+}
+
+func (f File) update(change func(map[string]string)) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	before, block, after := f.split(data)
+	entries := f.parseBlock(block)
+	change(entries)
+
+	updated := f.render(before, entries, after)
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(f.Path); err == nil {
+		mode = fi.Mode()
+	}
+
+	// The file is written in place, /etc/hosts often being a mount point
+	// it can't be renamed over, like in containers.
+	return ioutil.WriteFile(f.Path, updated, mode)
+}
+
+// markers returns the lines the block of the store begins and ends with.
+// The store is identified by a hash of its directory, which may have spaces.
+func (f File) markers() (string, string) {
+	id := fmt.Sprintf("%x", sha1.Sum([]byte(f.Store)))[:12]
+	return beginMarker + " " + id, endMarker + " " + id
+}
+
+// split returns the lines of data before the block of the store, the lines
+// of the block and the lines after it.
+func (f File) split(data []byte) ([]string, []string, []string) {
+	beginLine, endLine := f.markers()
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case beginLine:
+			if begin < 0 {
+				begin = i
+			}
+		case endLine:
+			if begin >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+
+	if begin < 0 || end < 0 {
+		return lines, nil, nil
+	}
+
+	return lines[:begin], lines[begin+1 : end], lines[end+1:]
+}
+
+func (f File) parseBlock(block []string) map[string]string {
+	suffix := f.Hostname("")
+	entries := map[string]string{}
+
+	for _, line := range block {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if machine := strings.TrimSuffix(fields[1], suffix); machine != fields[1] && machine != "" {
+			entries[machine] = fields[0]
+		}
+	}
+
+	return entries
+}
+
+func (f File) render(before []string, entries map[string]string, after []string) []byte {
+	var buf bytes.Buffer
+
+	for _, line := range before {
+		fmt.Fprintln(&buf, line)
+	}
+
+	if len(entries) > 0 {
+		machines := []string{}
+		for machine := range entries {
+			machines = append(machines, machine)
+		}
+		sort.Strings(machines)
+
+		beginLine, endLine := f.markers()
+		fmt.Fprintln(&buf, beginLine)
+		for _, machine := range machines {
+			fmt.Fprintf(&buf, "%s\t%s\n", entries[machine], f.Hostname(machine))
+		}
+		fmt.Fprintln(&buf, endLine)
+	}
+
+	for _, line := range after {
+		fmt.Fprintln(&buf, line)
+	}
+
+	return buf.Bytes()
+}
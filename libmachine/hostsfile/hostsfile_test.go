@@ -0,0 +1,119 @@
+package hostsfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const systemHosts = `127.0.0.1	localhost
+::1	localhost ip6-localhost
+`
+
+func testFile(t *testing.T, content string) (File, func()) {
+	dir, err := ioutil.TempDir("", "machine-hostsfile-")
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "hosts")
+	if content != "" {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	return File{Path: path, Store: "/home/me/.docker/machine"}, func() { os.RemoveAll(dir) }
+}
+
+// block returns the block of the store of f with lines.
+func block(f File, lines string) string {
+	begin, end := f.markers()
+	return begin + "\n" + lines + end + "\n"
+}
+
+func read(t *testing.T, f File) string {
+	data, err := ioutil.ReadFile(f.Path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestSet(t *testing.T) {
+	f, cleanup := testFile(t, systemHosts)
+	defer cleanup()
+
+	assert.NoError(t, f.Set("web", "10.0.0.5"))
+	assert.NoError(t, f.Set("dev", "192.168.99.100"))
+	assert.Equal(t, systemHosts+block(f, `192.168.99.100	dev.docker.local
+10.0.0.5	web.docker.local
+`), read(t, f))
+
+	assert.NoError(t, f.Set("dev", "192.168.99.101"))
+	entries, err := f.Entries()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"dev": "192.168.99.101", "web": "10.0.0.5"}, entries)
+}
+
+func TestRemove(t *testing.T) {
+	f, cleanup := testFile(t, "")
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(f.Path, []byte(systemHosts+block(f, "10.0.0.5\tweb.docker.local\n")+"10.1.1.1\tbuild.example.com\n"), 0644))
+
+	assert.NoError(t, f.Remove("web"))
+	assert.Equal(t, systemHosts+"10.1.1.1\tbuild.example.com\n", read(t, f))
+
+	assert.NoError(t, f.Remove("web"))
+	assert.Equal(t, systemHosts+"10.1.1.1\tbuild.example.com\n", read(t, f))
+}
+
+func TestSync(t *testing.T) {
+	f, cleanup := testFile(t, "")
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(f.Path, []byte(systemHosts+block(f, "10.0.0.5\tweb.docker.local\n")), 0644))
+
+	f.Domain = "machines.test"
+	assert.NoError(t, f.Sync(map[string]string{"dev": "192.168.99.100"}))
+	assert.Equal(t, systemHosts+block(f, "192.168.99.100\tdev.machines.test\n"), read(t, f))
+
+	assert.NoError(t, f.Sync(nil))
+	assert.Equal(t, systemHosts, read(t, f))
+}
+
+func TestMissingFile(t *testing.T) {
+	f, cleanup := testFile(t, "")
+	defer cleanup()
+
+	entries, err := f.Entries()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, f.Set("dev", "192.168.99.100"))
+	assert.Equal(t, block(f, "192.168.99.100\tdev.docker.local\n"), read(t, f))
+}
+
+func TestOtherStore(t *testing.T) {
+	f, cleanup := testFile(t, systemHosts)
+	defer cleanup()
+
+	other := File{Path: f.Path, Store: "/srv/ci/machine"}
+
+	assert.NoError(t, f.Set("dev", "192.168.99.100"))
+	assert.NoError(t, other.Set("dev", "10.0.0.5"))
+	assert.NoError(t, other.Set("build", "10.0.0.6"))
+
+	entries, err := f.Entries()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"dev": "192.168.99.100"}, entries)
+
+	assert.NoError(t, f.Sync(map[string]string{"web": "192.168.99.101"}))
+	assert.NoError(t, f.Remove("dev"))
+	assert.Equal(t, systemHosts+block(f, "192.168.99.101\tweb.docker.local\n")+
+		block(other, "10.0.0.6\tbuild.docker.local\n10.0.0.5\tdev.docker.local\n"), read(t, f))
+
+	assert.NoError(t, f.Sync(nil))
+	assert.Equal(t, systemHosts+block(other, "10.0.0.6\tbuild.docker.local\n10.0.0.5\tdev.docker.local\n"), read(t, f))
+}
+
+func TestHostname(t *testing.T) {
+	assert.Equal(t, "dev.docker.local", File{}.Hostname("dev"))
+	assert.Equal(t, "dev.lan", File{Domain: "lan"}.Hostname("dev"))
+}
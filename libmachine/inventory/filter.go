@@ -0,0 +1,194 @@
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+// Keys are the filter keys of the inventory, see Filter.
+var Keys = []string{"engine-version", "api-version", "kernel", "os", "arch", "storage-driver", "free-disk"}
+
+// Operators are the comparisons of filters, longest first for them to be
+// found in a filter before their prefixes.
+var Operators = []string{"<=", ">=", "!=", "<", ">", "="}
+
+// Filter compares a field of the inventory with a value: versions number by
+// number, the free disk as a size like 10GB, and the others as strings,
+// which are only told equal or not.
+type Filter struct {
+	Key   string
+	Op    string
+	Value string
+
+	version engine.Version
+	size    int64
+}
+
+// IsKey tells whether key is a filter key of the inventory.
+func IsKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFilter checks the filter, and returns it.
+func NewFilter(key, op, value string) (Filter, error) {
+	f := Filter{Key: key, Op: op, Value: value}
+
+	if !validOperator(op) {
+		return f, fmt.Errorf("Unsupported filter operator '%s'", op)
+	}
+
+	switch key {
+	case "engine-version", "api-version", "kernel":
+		v, err := engine.ParseVersion(value)
+		if err != nil {
+			return f, fmt.Errorf("Invalid %s filter: %s", key, err)
+		}
+		f.version = v
+	case "free-disk":
+		size, err := ParseSize(value)
+		if err != nil {
+			return f, fmt.Errorf("Invalid %s filter: %s", key, err)
+		}
+		f.size = size
+	case "os", "arch", "storage-driver":
+		if op != "=" && op != "!=" {
+			return f, fmt.Errorf("The %s filter can only be compared with = or !=", key)
+		}
+	default:
+		return f, fmt.Errorf("Unsupported filter key '%s'", key)
+	}
+
+	return f, nil
+}
+
+func validOperator(op string) bool {
+	for _, o := range Operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) String() string {
+	return f.Key + f.Op + f.Value
+}
+
+// Matches tells whether the inventory satisfies the filter, which no
+// machine without an inventory, or without the field of the filter, does.
+func (f Filter) Matches(inv *Inventory) bool {
+	if inv == nil {
+		return false
+	}
+
+	switch f.Key {
+	case "engine-version":
+		return f.matchesVersion(inv.EngineVersion)
+	case "api-version":
+		return f.matchesVersion(inv.APIVersion)
+	case "kernel":
+		return f.matchesVersion(inv.Kernel)
+	case "free-disk":
+		return inv.FreeDisk != 0 && compare(f.Op, cmpInt64(inv.FreeDisk, f.size))
+	case "os":
+		return inv.OS != "" && f.matchesString(inv.OS)
+	case "arch":
+		return inv.Architecture != "" && f.matchesString(inv.Architecture)
+	case "storage-driver":
+		return inv.StorageDriver != "" && f.matchesString(inv.StorageDriver)
+	}
+
+	return false
+}
+
+func (f Filter) matchesVersion(s string) bool {
+	v, err := engine.ParseVersion(s)
+	if err != nil {
+		return false
+	}
+
+	c := 0
+	switch {
+	case v.Less(f.version):
+		c = -1
+	case f.version.Less(v):
+		c = 1
+	}
+	return compare(f.Op, c)
+}
+
+func (f Filter) matchesString(s string) bool {
+	equal := strings.EqualFold(s, f.Value)
+	if f.Op == "!=" {
+		return !equal
+	}
+	return equal
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare tells whether the result c of comparing a field with the value of
+// a filter satisfies the operator.
+func compare(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "!=":
+		return c != 0
+	}
+	return c == 0
+}
+
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"TB", 1 << 40}, {"T", 1 << 40},
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes, or with a unit like 10GB or 512M, in
+// powers of 1024.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	factor := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			factor = u.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return int64(n * float64(factor)), nil
+}
@@ -0,0 +1,93 @@
+package inventory
+
+import "testing"
+
+func TestFilterMatches(t *testing.T) {
+	inv := &Inventory{
+		EngineVersion: "18.09.7",
+		APIVersion:    "1.39",
+		Kernel:        "4.15.0-1054-aws",
+		OS:            "Ubuntu 18.04.6 LTS",
+		Architecture:  "x86_64",
+		StorageDriver: "overlay2",
+		FreeDisk:      5 << 30,
+	}
+
+	cases := []struct {
+		key, op, value string
+		expected       bool
+	}{
+		{"engine-version", "<", "19.03", true},
+		{"engine-version", ">=", "19.03", false},
+		{"engine-version", "=", "18.09.7", true},
+		{"engine-version", "!=", "18.09", true},
+		{"api-version", ">=", "1.40", false},
+		{"kernel", "<", "5.4", true},
+		{"os", "=", "ubuntu 18.04.6 lts", true},
+		{"arch", "!=", "x86_64", false},
+		{"storage-driver", "=", "devicemapper", false},
+		{"free-disk", "<", "10GB", true},
+		{"free-disk", ">=", "5G", true},
+		{"free-disk", ">", "5120M", false},
+	}
+
+	for _, c := range cases {
+		f, err := NewFilter(c.key, c.op, c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := f.Matches(inv); actual != c.expected {
+			t.Errorf("Expected %s to be %t, got %t", f, c.expected, actual)
+		}
+	}
+}
+
+func TestFilterWithoutInventory(t *testing.T) {
+	f, err := NewFilter("engine-version", "!=", "19.03")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Matches(nil) || f.Matches(&Inventory{}) {
+		t.Fatal("Expected machines without an engine version not to match")
+	}
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	cases := []struct {
+		key, op, value string
+		expected       string
+	}{
+		{"os", "<", "ubuntu", "The os filter can only be compared with = or !="},
+		{"kernel", "=", "latest", `Invalid kernel filter: invalid version "latest"`},
+		{"free-disk", ">", "lots", `Invalid free-disk filter: invalid size "lots"`},
+		{"engine-version", "=<", "19.03", "Unsupported filter operator '=<'"},
+	}
+
+	for _, c := range cases {
+		if _, err := NewFilter(c.key, c.op, c.value); err == nil || err.Error() != c.expected {
+			t.Errorf("Expected %q, got %v", c.expected, err)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":   512,
+		"10GB":  10 << 30,
+		"1.5g":  3 << 29,
+		"512M":  512 << 20,
+		"2 TB":  2 << 40,
+		"100KB": 100 << 10,
+	}
+
+	for s, expected := range cases {
+		size, err := ParseSize(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != expected {
+			t.Errorf("Expected %s to be %d bytes, got %d", s, expected, size)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package inventory records what a machine runs: the versions of its engine,
+// kernel and distribution, its architecture, the storage driver of its engine
+// and the disk left for it, for fleets to be audited without connecting to
+// every machine.
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+// Inventory is what a machine was found to run when it was last collected.
+type Inventory struct {
+	Time          time.Time
+	EngineVersion string `json:",omitempty"`
+	APIVersion    string `json:",omitempty"`
+	Kernel        string `json:",omitempty"`
+	OS            string `json:",omitempty"`
+	Architecture  string `json:",omitempty"`
+	StorageDriver string `json:",omitempty"`
+
+	// FreeDisk is the space left, in bytes, on the disk of the root
+	// directory of the engine.
+	FreeDisk int64 `json:",omitempty"`
+}
+
+// Collect collects the inventory of the machine the commands are run on,
+// sudo returning the command run as root, for the docker CLI of the
+// machine. When the engine can't be asked, the inventory of the machine
+// itself is still returned, with the error.
+func Collect(t drivers.Transport, sudo func(command string) string) (*Inventory, error) {
+	inv := &Inventory{Time: time.Now()}
+
+	out, err := t.RunCommand("uname -rm")
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the kernel: %s", err)
+	}
+	inv.Kernel, inv.Architecture = parseFields(out)
+
+	out, err = t.RunCommand("cat /etc/os-release")
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the distribution: %s", err)
+	}
+	inv.OS, err = parseOsRelease(out)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = t.RunCommand(sudo("docker version --format '{{.Server.Version}} {{.Server.APIVersion}}'"))
+	if err != nil {
+		return inv, fmt.Errorf("Error reading the engine version: %s", err)
+	}
+	inv.EngineVersion, inv.APIVersion = parseFields(out)
+
+	out, err = t.RunCommand(sudo("docker info --format '{{.Driver}} {{.DockerRootDir}}'"))
+	if err != nil {
+		return inv, fmt.Errorf("Error reading the engine info: %s", err)
+	}
+	storageDriver, rootDir := parseFields(out)
+	inv.StorageDriver = storageDriver
+
+	out, err = t.RunCommand(fmt.Sprintf("df -Pk %s", rootDir))
+	if err != nil {
+		return inv, fmt.Errorf("Error reading the free disk: %s", err)
+	}
+	inv.FreeDisk, err = parseDf(out)
+	if err != nil {
+		return inv, err
+	}
+
+	return inv, nil
+}
+
+// parseFields returns the first two fields of out.
+func parseFields(out string) (string, string) {
+	fields := strings.Fields(out)
+	for len(fields) < 2 {
+		fields = append(fields, "")
+	}
+	return fields[0], fields[1]
+}
+
+// parseOsRelease returns the name the distribution gives itself, or else its
+// id and version.
+func parseOsRelease(out string) (string, error) {
+	info, err := provision.NewOsRelease([]byte(out))
+	if err != nil {
+		return "", fmt.Errorf("Error parsing /etc/os-release file: %s", err)
+	}
+
+	if info.PrettyName != "" {
+		return info.PrettyName, nil
+	}
+	return strings.TrimSpace(info.Id + " " + info.VersionId), nil
+}
+
+// parseDf returns the space available, in bytes, in the output of df -Pk
+// for a single directory.
+func parseDf(out string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("Error reading the free disk: unexpected output %q", out)
+	}
+
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("Error reading the free disk: unexpected output %q", out)
+	}
+
+	available, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Error reading the free disk: %s", err)
+	}
+
+	return available * 1024, nil
+}
@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeTransport answers the commands starting with the keys of outputs, and
+// fails the others.
+type fakeTransport struct {
+	outputs map[string]string
+}
+
+func (t *fakeTransport) RunCommand(command string) (string, error) {
+	for prefix, out := range t.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return out, nil
+		}
+	}
+	return "", errors.New("exit status 1")
+}
+
+func (t *fakeTransport) RunCommandWithInput(command string, input []byte) (string, error) {
+	return t.RunCommand(command)
+}
+
+func sudo(command string) string {
+	return "sudo " + command
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{outputs: map[string]string{
+		"uname -rm":              "5.15.0-91-generic x86_64\n",
+		"cat /etc/os-release":    "NAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"22.04\"\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\n",
+		"sudo docker version":    "20.10.24 1.41\n",
+		"sudo docker info":       "overlay2 /var/lib/docker\n",
+		"df -Pk /var/lib/docker": "Filesystem     1024-blocks     Used Available Capacity Mounted on\n/dev/sda1         82083148 29633736  52432636      37% /\n",
+	}}
+}
+
+func TestCollect(t *testing.T) {
+	inv, err := Collect(newFakeTransport(), sudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Inventory{
+		Time:          inv.Time,
+		EngineVersion: "20.10.24",
+		APIVersion:    "1.41",
+		Kernel:        "5.15.0-91-generic",
+		OS:            "Ubuntu 22.04.3 LTS",
+		Architecture:  "x86_64",
+		StorageDriver: "overlay2",
+		FreeDisk:      52432636 * 1024,
+	}
+	if *inv != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, *inv)
+	}
+}
+
+func TestCollectEngineDown(t *testing.T) {
+	transport := newFakeTransport()
+	delete(transport.outputs, "sudo docker version")
+
+	inv, err := Collect(transport, sudo)
+	if err == nil {
+		t.Fatal("Expected an error reading the engine version")
+	}
+	if inv == nil || inv.Kernel != "5.15.0-91-generic" || inv.EngineVersion != "" {
+		t.Fatalf("Expected the inventory of the machine without the engine, got %+v", inv)
+	}
+}
+
+func TestCollectUnreachable(t *testing.T) {
+	if inv, err := Collect(&fakeTransport{}, sudo); err == nil || inv != nil {
+		t.Fatalf("Expected an error and no inventory, got %+v, %v", inv, err)
+	}
+}
+
+func TestParseOsReleaseWithoutPrettyName(t *testing.T) {
+	os, err := parseOsRelease("ID=alpine\nVERSION_ID=3.18.4\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os != "alpine 3.18.4" {
+		t.Fatalf("Expected alpine 3.18.4, got %q", os)
+	}
+}
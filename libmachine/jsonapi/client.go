@@ -0,0 +1,88 @@
+// Package jsonapi is a client of the JSON REST APIs of the cloud providers
+// whose drivers have no SDK.
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// Client sends requests to a JSON API.
+type Client struct {
+	// Name names the API in the logs, e.g. "Vultr"
+	Name string
+
+	// Endpoint is the URL the paths of requests are relative to
+	Endpoint string
+
+	// Header is added to every request, such as for authentication
+	Header http.Header
+
+	// DecodeError returns the error of a response whose status isn't a
+	// success from its body, which may not even be JSON
+	DecodeError func(res *http.Response) error
+
+	HTTP *http.Client
+}
+
+// NewClient returns a client of the API at endpoint.
+func NewClient(name, endpoint string, header http.Header, decodeError func(res *http.Response) error) *Client {
+	return &Client{
+		Name:        name,
+		Endpoint:    endpoint,
+		Header:      header,
+		DecodeError: decodeError,
+		HTTP:        &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// BearerToken returns the header authenticating requests with token.
+func BearerToken(token string) http.Header {
+	return http.Header{"Authorization": []string{"Bearer " + token}}
+}
+
+// Do sends a request to the API, and decodes its response into out when it
+// is not nil.
+func (c *Client) Do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.Endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.Header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Debugf("%s request: %s %s", c.Name, method, path)
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return c.DecodeError(res)
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
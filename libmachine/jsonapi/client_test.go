@@ -0,0 +1,54 @@
+package jsonapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/jsonapi/jsonapitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(responses map[string]string) (*Client, *jsonapitest.API, func()) {
+	api := jsonapitest.New(responses)
+	api.Authorized = func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer token" }
+	server := httptest.NewServer(api)
+
+	c := NewClient("Test", server.URL, BearerToken("token"), func(res *http.Response) error {
+		return errors.New(res.Status)
+	})
+	c.HTTP = server.Client()
+
+	return c, api, server.Close
+}
+
+func TestDo(t *testing.T) {
+	c, api, done := newTestClient(map[string]string{
+		"POST /servers?zone=a": `{"server": {"id": 42}}`,
+		"DELETE /servers/42":   "",
+	})
+	defer done()
+
+	resp := struct {
+		Server struct {
+			ID int `json:"id"`
+		} `json:"server"`
+	}{}
+	assert.NoError(t, c.Do("POST", "/servers?zone=a", map[string]string{"name": "web"}, &resp))
+	assert.Equal(t, 42, resp.Server.ID)
+	assert.Equal(t, map[string]interface{}{"name": "web"}, api.Bodies["POST /servers?zone=a"])
+
+	assert.NoError(t, c.Do("DELETE", "/servers/42", nil, &resp))
+	assert.Equal(t, []string{"POST /servers?zone=a", "DELETE /servers/42"}, api.Requests)
+}
+
+func TestDoError(t *testing.T) {
+	c, _, done := newTestClient(map[string]string{})
+	defer done()
+
+	assert.EqualError(t, c.Do("GET", "/servers/42", nil, nil), "404 Not Found")
+
+	c.Header = BearerToken("wrong")
+	assert.EqualError(t, c.Do("GET", "/servers", nil, nil), "401 Unauthorized")
+}
@@ -0,0 +1,97 @@
+// Package jsonapitest fakes the JSON APIs of cloud providers for the tests of
+// their drivers.
+package jsonapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// API answers requests with canned responses keyed by method and URI, and
+// records the requests and the bodies it got. Serve it with httptest.
+type API struct {
+	// Responses are the bodies of the responses by "<method> <URI>". An
+	// empty one is answered with 204 No Content.
+	Responses map[string]string
+
+	// Headers are the headers of the responses by "<method> <URI>"
+	Headers map[string]http.Header
+
+	// Statuses are the status codes of the responses by "<method> <URI>",
+	// 200 OK by default
+	Statuses map[string]int
+
+	// Requests are the "<method> <URI>" of the requests, in order
+	Requests []string
+
+	// Bodies are the JSON bodies of the requests by "<method> <URI>"
+	Bodies map[string]map[string]interface{}
+
+	// Authorized tells whether a request is authenticated, when it is not
+	// nil. The others are answered with 401 Unauthorized and Unauthorized.
+	Authorized   func(r *http.Request) bool
+	Unauthorized string
+
+	// NotFoundStatus and NotFound answer the requests without a response,
+	// with 404 Not Found by default
+	NotFoundStatus int
+	NotFound       string
+
+	// ByPath keys responses and requests by "<method> <path>", leaving out
+	// the query
+	ByPath bool
+}
+
+// New returns an API answering with responses.
+func New(responses map[string]string) *API {
+	return &API{
+		Responses:      responses,
+		Headers:        map[string]http.Header{},
+		Statuses:       map[string]int{},
+		Bodies:         map[string]map[string]interface{}{},
+		NotFoundStatus: http.StatusNotFound,
+	}
+}
+
+// Key returns the key of the request in the responses, requests and bodies.
+func (a *API) Key(r *http.Request) string {
+	if a.ByPath {
+		return r.Method + " " + r.URL.Path
+	}
+	return r.Method + " " + r.URL.RequestURI()
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := a.Key(r)
+	a.Requests = append(a.Requests, key)
+
+	if a.Authorized != nil && !a.Authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, a.Unauthorized)
+		return
+	}
+
+	body := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+		a.Bodies[key] = body
+	}
+
+	resp, ok := a.Responses[key]
+	if !ok {
+		w.WriteHeader(a.NotFoundStatus)
+		fmt.Fprint(w, a.NotFound)
+		return
+	}
+	for name, values := range a.Headers[key] {
+		w.Header()[name] = values
+	}
+	status := a.Statuses[key]
+	if status == 0 && resp == "" {
+		status = http.StatusNoContent
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+	fmt.Fprint(w, resp)
+}
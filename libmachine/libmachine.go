@@ -2,16 +2,22 @@ package libmachine
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"path/filepath"
+	"time"
 
+	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/persist"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
+	"golang.org/x/net/context"
 )
 
 func GetDefaultStore() *persist.Filestore {
@@ -27,55 +33,279 @@ func GetDefaultStore() *persist.Filestore {
 // Create is the wrapper method which covers all of the boilerplate around
 // actually creating, provisioning, and persisting an instance in the store.
 func Create(store persist.Store, h *host.Host) error {
+	return CreateContext(context.Background(), store, h)
+}
+
+// CreateContext is Create, giving up as soon as ctx is done. The stages
+// completed by then are recorded, so that the creation can be resumed.
+func CreateContext(ctx context.Context, store persist.Store, h *host.Host) error {
 	if err := cert.BootstrapCertificates(h.HostOptions.AuthOptions); err != nil {
-		return fmt.Errorf("Error generating certificates: %s", err)
+		return fmt.Errorf("Error generating certificates: %w", err)
 	}
 
-	log.Info("Running pre-create checks...")
+	stageLogger(h, host.StageNew).Infof("Running pre-create checks...")
 
 	if err := h.Driver.PreCreateCheck(); err != nil {
-		return fmt.Errorf("Error with pre-create check: %s", err)
+		return fmt.Errorf("Error with pre-create check: %w", err)
 	}
 
+	h.CreateStage = host.StageNew
 	if err := store.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store before attempting creation: %s", err)
+		return fmt.Errorf("Error saving host to store before attempting creation: %w", err)
 	}
 
-	log.Info("Creating machine...")
+	return runCreateStages(ctx, store, h, host.StageCreated)
+}
+
+// ResumeCreate continues creating a host whose creation failed part way,
+// starting with the stage after the last one it completed.
+func ResumeCreate(store persist.Store, h *host.Host) error {
+	return ResumeCreateContext(context.Background(), store, h)
+}
 
-	if err := h.Driver.Create(); err != nil {
-		return fmt.Errorf("Error in driver during machine creation: %s", err)
+// ResumeCreateContext is ResumeCreate, giving up as soon as ctx is done.
+func ResumeCreateContext(ctx context.Context, store persist.Store, h *host.Host) error {
+	if h.CreateComplete() {
+		return fmt.Errorf("Machine %q was created successfully, there is nothing to resume", h.Name)
 	}
 
-	if err := store.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store after attempting creation: %s", err)
+	if err := cert.BootstrapCertificates(h.HostOptions.AuthOptions); err != nil {
+		return fmt.Errorf("Error generating certificates: %w", err)
+	}
+
+	next := h.CreateStage.Next()
+	log.Infof("Resuming creation of %s at stage %s...", h.Name, next)
+
+	return runCreateStages(ctx, store, h, next)
+}
+
+// Reprovision runs the stages of creating a host again, starting with from.
+// The machine itself is never created again, so from cannot be the first
+// stage.
+func Reprovision(store persist.Store, h *host.Host, from host.CreateStage) error {
+	return ReprovisionContext(context.Background(), store, h, from)
+}
+
+// ReprovisionContext is Reprovision, giving up as soon as ctx is done.
+func ReprovisionContext(ctx context.Context, store persist.Store, h *host.Host, from host.CreateStage) error {
+	if from.Index() < 1 {
+		return fmt.Errorf("Cannot run stage %s again, remove the machine and create it instead", host.StageCreated)
+	}
+
+	if err := h.RefuseAdopted("provision"); err != nil {
+		return err
+	}
+
+	if !h.CreateComplete() && from.Index() > h.CreateStage.Index()+1 {
+		return fmt.Errorf("Machine %q only completed stage %s, cannot skip to stage %s", h.Name, h.CreateStage, from)
+	}
+
+	if err := cert.BootstrapCertificates(h.HostOptions.AuthOptions); err != nil {
+		return fmt.Errorf("Error generating certificates: %w", err)
 	}
 
+	return runCreateStages(ctx, store, h, from)
+}
+
+func runCreateStages(ctx context.Context, store persist.Store, h *host.Host, from host.CreateStage) error {
+	for _, stage := range host.CreateStages[from.Index():] {
+		drivers.Transcribe(ctx, "Running stage %s", stage)
+		start := time.Now()
+		if err := runCreateStage(ctx, store, h, stage); err != nil {
+			drivers.Transcribe(ctx, "Stage %s failed after %.3fs: %s", stage, time.Since(start).Seconds(), err)
+			if ctx.Err() == nil {
+				return err
+			}
+
+			// The stage was interrupted rather than failing, save what
+			// is known about the machine for the creation to be resumed.
+			if saveErr := store.Save(h); saveErr != nil {
+				log.Warnf("Error saving host to store after interruption: %s", saveErr)
+			}
+			return fmt.Errorf("Interrupted during stage %s: %s", stage, ctx.Err())
+		}
+
+		drivers.Transcribe(ctx, "Stage %s done in %.3fs", stage, time.Since(start).Seconds())
+
+		h.CreateStage = stage
+		if err := store.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store after stage %s: %s", stage, err)
+		}
+	}
+
+	log.Debug("Reticulating splines...")
+
+	return nil
+}
+
+func runCreateStage(ctx context.Context, store persist.Store, h *host.Host, stage host.CreateStage) error {
 	// TODO: Not really a fan of just checking "none" here.
-	if h.Driver.DriverName() != "none" {
-		log.Info("Waiting for machine to be running, this may take a few minutes...")
-		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
-			return fmt.Errorf("Error waiting for machine to be running: %s", err)
+	if stage != host.StageCreated && h.Driver.DriverName() == "none" {
+		return nil
+	}
+
+	logger := stageLogger(h, stage)
+	d := drivers.WithContext(ctx, h.Driver)
+
+	switch stage {
+	case host.StageCreated:
+		logger.Infof("Creating machine...")
+		if err := d.Create(); err != nil {
+			return fmt.Errorf("Error in driver during machine creation: %w", err)
 		}
 
-		log.Info("Machine is running, waiting for SSH to be available...")
-		if err := drivers.WaitForSSH(h.Driver); err != nil {
-			return fmt.Errorf("Error waiting for SSH: %s", err)
+	case host.StageIPAssigned:
+		logger.Infof("Waiting for machine to be running, this may take a few minutes...")
+		if err := drivers.WaitForStage(d, mcnutils.WaitState, mcnutils.WaitSettings{Timeout: drivers.GetWaitTimeouts(d).Running}, drivers.MachineInState(d, state.Running)); err != nil {
+			return fmt.Errorf("Error waiting for machine to be running: %w", err)
 		}
 
-		log.Info("Detecting operating system of created instance...")
-		provisioner, err := provision.DetectProvisioner(h.Driver)
+		if err := drivers.WaitForStage(d, mcnutils.WaitIP, mcnutils.WaitSettings{}, hasIP(d)); err != nil {
+			return fmt.Errorf("Error waiting for machine to get an IP address: %w", err)
+		}
+
+	case host.StageSSHReady:
+		logger.Infof("Machine is running, waiting for SSH to be available...")
+		if err := drivers.WaitForTransport(d); err != nil {
+			return fmt.Errorf("Error waiting for SSH: %w", err)
+		}
+
+	case host.StageProvisioned:
+		if drivers.IsAdopted(d) {
+			logger.Infof("Adopting the machine as it is, without provisioning it...")
+			adoptCerts(h.HostOptions.AuthOptions)
+			return nil
+		}
+
+		logger.Infof("Detecting operating system of created instance...")
+		provisioner, err := provision.DetectProvisioner(d)
 		if err != nil {
-			return fmt.Errorf("Error detecting OS: %s", err)
+			return fmt.Errorf("Error detecting OS: %w", err)
+		}
+
+		h.Provisioning = true
+		if err := store.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store before provisioning: %w", err)
 		}
 
-		log.Info("Provisioning created instance...")
+		if err := provision.MountVolume(provisioner); err != nil {
+			return err
+		}
+
+		if cache := h.HostOptions.EngineOptions.PackageCache; cache != "" {
+			logger.Infof("Downloading the packages through the package cache at %s...", cache)
+			if err := provision.ConfigurePackageCache(provisioner, cache); err != nil {
+				logger.Warnf("Error configuring the package cache, downloading the packages directly: %s", err)
+			}
+			defer func() {
+				if err := provision.RemovePackageCache(provisioner); err != nil {
+					logger.Warnf("Error removing the package cache from the package managers: %s", err)
+				}
+			}()
+		}
+
+		logger.Infof("Provisioning created instance...")
 		if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
-			return fmt.Errorf("Error running provisioning: %s", err)
+			return mcnerror.NewErrProvisionStep("running provisioning", err)
+		}
+
+		if err := configureSwarmMode(store, h, provisioner); err != nil {
+			return err
+		}
+
+		if h.HostOptions.EngineOptions.Hardening != "" {
+			logger.Infof("Hardening created instance with the %s profile...", h.HostOptions.EngineOptions.Hardening)
+			report, err := provision.Harden(provisioner, *h.HostOptions.EngineOptions)
+			if err != nil {
+				return fmt.Errorf("Error hardening: %w", err)
+			}
+			h.Hardening = report
+		}
+
+		if h.HostOptions.EngineOptions.AutoUpgrade {
+			if err := provision.ConfigureAutoUpgrades(provisioner, *h.HostOptions.EngineOptions); err != nil {
+				return fmt.Errorf("Error configuring automatic engine upgrades: %w", err)
+			}
+		}
+
+		if version, err := provision.EngineVersion(provisioner); err != nil {
+			logger.Debugf("Could not get the version of the engine provisioned: %s", err)
+		} else {
+			h.EngineVersion = version
+		}
+
+		if err := h.CollectInventory(); err != nil {
+			logger.Debugf("Could not collect the inventory of the machine provisioned: %s", err)
+		}
+
+		h.Provisioning = false
+		h.ProvisionedAt = time.Now()
+		h.DesiredState = state.Running
+
+	case host.StageCerts:
+		return checkCerts(h, logger)
+	}
+
+	return nil
+}
+
+// adoptCerts has the auth options use the certificates of the engine of an
+// adopted machine, which its driver put in the directory of the machine,
+// instead of the ones Machine generates.
+func adoptCerts(authOptions *auth.AuthOptions) {
+	authOptions.CaCertPath = filepath.Join(authOptions.StorePath, "ca.pem")
+	authOptions.CaPrivateKeyPath = ""
+	authOptions.ClientCertPath = filepath.Join(authOptions.StorePath, "cert.pem")
+	authOptions.ClientKeyPath = filepath.Join(authOptions.StorePath, "key.pem")
+	authOptions.ServerCertPath = ""
+	authOptions.ServerKeyPath = ""
+}
+
+// stageLogger returns the logger for the messages of a stage, which records
+// carry the host and stage in when the output is structured. The text output
+// only shows fields for unformatted messages, which is why the messages of
+// the stages all go through Infof.
+func stageLogger(h *host.Host, stage host.CreateStage) log.Logger {
+	return log.WithFields(log.Fields{
+		"host": h.Name,
+		"step": string(stage),
+	})
+}
+
+func hasIP(d drivers.Driver) func() bool {
+	return func() bool {
+		ip, err := d.GetIP()
+		if err != nil {
+			log.Debugf("Error getting IP address waiting for machine: %s", err)
+			return false
 		}
+		return ip != ""
 	}
+}
 
-	log.Debug("Reticulating splines...")
+// checkCerts checks that the engine accepts the certificates Machine
+// generated for it. An engine which cannot be reached at all only gets a
+// warning, since that is often down to the network between here and the
+// machine rather than to the machine itself.
+func checkCerts(h *host.Host, logger log.Logger) error {
+	engineURL, err := h.Driver.GetURL()
+	if err != nil {
+		return fmt.Errorf("Error getting URL of the engine: %w", err)
+	}
+
+	u, err := url.Parse(engineURL)
+	if err != nil {
+		return fmt.Errorf("Error parsing URL of the engine %q: %s", engineURL, err)
+	}
+
+	logger.Infof("Checking that the engine accepts the certificates...")
+	if _, err := cert.ValidateCertificate(u.Host, h.HostOptions.AuthOptions); err != nil {
+		if _, ok := err.(net.Error); ok {
+			logger.Warnf("Could not connect to the engine at %s to check its certificates: %s", u.Host, err)
+			return nil
+		}
+		return fmt.Errorf("Error checking the certificates of the engine at %s: %s", u.Host, err)
+	}
 
 	return nil
 }
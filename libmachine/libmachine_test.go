@@ -0,0 +1,113 @@
+package libmachine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/drivers/generic"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/persisttest"
+	"github.com/docker/machine/libmachine/state"
+	"golang.org/x/net/context"
+)
+
+func TestResumeCreateCompleteHost(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.CreateStage = host.StageCerts
+	if err := ResumeCreate(store, h); err == nil {
+		t.Fatal("Expected resuming a complete host to fail")
+	}
+}
+
+func TestReprovisionStages(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Reprovision(store, h, host.StageCreated); err == nil {
+		t.Fatal("Expected running the created stage again to fail")
+	}
+
+	h.CreateStage = host.StageIPAssigned
+	if err := Reprovision(store, h, host.StageProvisioned); err == nil {
+		t.Fatal("Expected skipping the ssh-ready stage to fail")
+	}
+}
+
+func TestRunCreateStagesCanceled(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Driver = &fakedriver.Driver{MockState: state.Stopped}
+	h.CreateStage = host.StageNew
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = runCreateStages(ctx, store, h, host.StageCreated)
+	if err == nil || !strings.Contains(err.Error(), "Interrupted") {
+		t.Fatalf("Expected the creation to be interrupted, got %v", err)
+	}
+
+	if h.CreateStage != host.StageNew {
+		t.Fatalf("Expected no stage to be recorded, got %s", h.CreateStage)
+	}
+}
+
+func TestReprovisionAdoptedHost(t *testing.T) {
+	store, _ := persisttest.GetDefaultTestStore()
+	defer persisttest.Cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Driver = &generic.Driver{BaseDriver: &drivers.BaseDriver{}, Adopt: true}
+	h.CreateStage = host.StageCerts
+
+	err = Reprovision(store, h, host.StageProvisioned)
+	if err == nil || !strings.Contains(err.Error(), host.ErrAdopted.Error()) {
+		t.Fatalf("Expected provisioning an adopted host to be refused, got %v", err)
+	}
+}
+
+func TestAdoptCerts(t *testing.T) {
+	authOptions := &auth.AuthOptions{
+		StorePath:        "/store/machines/prod",
+		CaCertPath:       "/store/certs/ca.pem",
+		CaPrivateKeyPath: "/store/certs/ca-key.pem",
+		ServerCertPath:   "/store/machines/prod/server.pem",
+	}
+
+	adoptCerts(authOptions)
+
+	if authOptions.CaCertPath != filepath.Join("/store/machines/prod", "ca.pem") || authOptions.ClientKeyPath != filepath.Join("/store/machines/prod", "key.pem") {
+		t.Fatalf("Expected the certificates of the machine directory, got %+v", authOptions)
+	}
+
+	if authOptions.CaPrivateKeyPath != "" || authOptions.ServerCertPath != "" {
+		t.Fatalf("Expected no CA key nor server certificate, got %+v", authOptions)
+	}
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -34,10 +35,14 @@ type Logger interface {
 }
 
 var (
-	l = StandardLogger{
+	std = StandardLogger{
 		mu: &sync.Mutex{},
 	}
-	IsDebug bool = false
+
+	// l is the logger the package level functions use, either std or one
+	// writing to the sink set with SetSink.
+	l       Logger = &std
+	IsDebug bool   = false
 )
 
 type Fields map[string]interface{}
@@ -50,11 +55,11 @@ func init() {
 }
 
 func SetOutWriter(w io.Writer) {
-	l.OutWriter = w
+	std.OutWriter = w
 }
 
 func SetErrWriter(w io.Writer) {
-	l.ErrWriter = w
+	std.ErrWriter = w
 }
 
 func Debug(args ...interface{}) {
@@ -74,7 +79,7 @@ func Errorf(fmtString string, args ...interface{}) {
 }
 
 func Errorln(args ...interface{}) {
-	l.Errorln(args...)
+	l.Error(args...)
 }
 
 func Info(args ...interface{}) {
@@ -86,7 +91,7 @@ func Infof(fmtString string, args ...interface{}) {
 }
 
 func Infoln(args ...interface{}) {
-	l.Infoln(args...)
+	l.Info(args...)
 }
 
 func Fatal(args ...interface{}) {
@@ -97,6 +102,21 @@ func Fatalf(fmtString string, args ...interface{}) {
 	l.Fatalf(fmtString, args...)
 }
 
+// exit is os.Exit, replaced in the tests.
+var exit = os.Exit
+
+// Exit logs args as a fatal error and exits with status. The fields only go
+// to the structured output, like the JSON one, the text output showing the
+// message alone.
+func Exit(status int, fields Fields, args ...interface{}) {
+	if s, ok := l.(sinkLogger); ok {
+		s.WithFields(fields).(sinkLogger).write(LevelFatal, fmt.Sprint(args...))
+	} else {
+		l.Error(args...)
+	}
+	exit(status)
+}
+
 func Print(args ...interface{}) {
 	l.Print(args...)
 }
@@ -0,0 +1,191 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type Level string
+
+const (
+	LevelDebug Level = "debug"
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warn"
+	LevelError Level = "error"
+	LevelFatal Level = "fatal"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Record is a single log message, with the fields it was logged with.
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  Fields
+}
+
+// A Sink receives every record logged through the package, in place of the
+// human readable output, e.g. to send them to a log aggregator.
+type Sink interface {
+	Write(Record) error
+}
+
+// SetSink sends every record logged from now on to sink. A nil sink
+// switches back to the human readable output.
+func SetSink(sink Sink) {
+	if sink == nil {
+		l = &std
+		return
+	}
+
+	l = sinkLogger{
+		sink: sink,
+		mu:   &sync.Mutex{},
+	}
+}
+
+// SetFormat switches the output to one of the formats the package knows,
+// FormatText or FormatJSON.
+func SetFormat(format string) error {
+	switch format {
+	case "", FormatText:
+		SetSink(nil)
+	case FormatJSON:
+		SetSink(JSONSink{})
+	default:
+		return fmt.Errorf("Unknown log format %q, expected %q or %q", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// JSONSink writes records as JSON objects, one per line, with the time,
+// level and msg keys and one key per field. Info records go to the writer
+// set with SetOutWriter and the others to the one set with SetErrWriter, as
+// with the human readable output.
+type JSONSink struct{}
+
+func (JSONSink) Write(r Record) error {
+	obj := map[string]interface{}{}
+	for k, v := range r.Fields {
+		switch k {
+		case "time", "level", "msg":
+			k = "fields." + k
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		obj[k] = v
+	}
+
+	obj["time"] = r.Time.Format(time.RFC3339Nano)
+	obj["level"] = r.Level
+	obj["msg"] = r.Message
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	w := std.ErrWriter
+	if r.Level == LevelInfo {
+		w = std.OutWriter
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// sinkLogger is the Logger handing records to a Sink.
+type sinkLogger struct {
+	sink   Sink
+	fields Fields
+	mu     *sync.Mutex
+}
+
+func (s sinkLogger) write(level Level, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.sink.Write(Record{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  s.fields,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing log record: %s\n", err)
+	}
+}
+
+func (s sinkLogger) Debug(args ...interface{}) {
+	if IsDebug {
+		s.write(LevelDebug, fmt.Sprint(args...))
+	}
+}
+
+func (s sinkLogger) Debugf(fmtString string, args ...interface{}) {
+	if IsDebug {
+		s.write(LevelDebug, fmt.Sprintf(fmtString, args...))
+	}
+}
+
+func (s sinkLogger) Error(args ...interface{}) {
+	s.write(LevelError, fmt.Sprint(args...))
+}
+
+func (s sinkLogger) Errorf(fmtString string, args ...interface{}) {
+	s.write(LevelError, fmt.Sprintf(fmtString, args...))
+}
+
+func (s sinkLogger) Info(args ...interface{}) {
+	s.write(LevelInfo, fmt.Sprint(args...))
+}
+
+func (s sinkLogger) Infof(fmtString string, args ...interface{}) {
+	s.write(LevelInfo, fmt.Sprintf(fmtString, args...))
+}
+
+func (s sinkLogger) Fatal(args ...interface{}) {
+	s.write(LevelFatal, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+func (s sinkLogger) Fatalf(fmtString string, args ...interface{}) {
+	s.write(LevelFatal, fmt.Sprintf(fmtString, args...))
+	os.Exit(1)
+}
+
+func (s sinkLogger) Print(args ...interface{}) {
+	s.write(LevelInfo, fmt.Sprint(args...))
+}
+
+func (s sinkLogger) Printf(fmtString string, args ...interface{}) {
+	s.write(LevelInfo, fmt.Sprintf(fmtString, args...))
+}
+
+func (s sinkLogger) Warn(args ...interface{}) {
+	s.write(LevelWarn, fmt.Sprint(args...))
+}
+
+func (s sinkLogger) Warnf(fmtString string, args ...interface{}) {
+	s.write(LevelWarn, fmt.Sprintf(fmtString, args...))
+}
+
+func (s sinkLogger) WithFields(fields Fields) Logger {
+	merged := Fields{}
+	for k, v := range s.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	s.fields = merged
+	return s
+}
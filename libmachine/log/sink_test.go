@@ -0,0 +1,132 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+type recordingSink struct {
+	records []Record
+}
+
+func (s *recordingSink) Write(r Record) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+func TestSetSink(t *testing.T) {
+	sink := &recordingSink{}
+	SetSink(sink)
+	defer SetSink(nil)
+
+	WithFields(Fields{"host": "dev"}).Infof("Creating %s", "machine")
+	Warn("careful")
+
+	if len(sink.records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(sink.records))
+	}
+
+	r := sink.records[0]
+	if r.Level != LevelInfo || r.Message != "Creating machine" || r.Fields["host"] != "dev" {
+		t.Fatalf("Unexpected record: %+v", r)
+	}
+
+	if sink.records[1].Level != LevelWarn || len(sink.records[1].Fields) != 0 {
+		t.Fatalf("Unexpected record: %+v", sink.records[1])
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var out, errOut bytes.Buffer
+	SetOutWriter(&out)
+	SetErrWriter(&errOut)
+	defer func() {
+		SetOutWriter(os.Stdout)
+		SetErrWriter(os.Stderr)
+	}()
+
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSink(nil)
+
+	WithFields(Fields{"host": "dev", "step": "created", "msg": "clash"}).Info("Creating machine...")
+	Error("boom")
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"level":      "info",
+		"msg":        "Creating machine...",
+		"host":       "dev",
+		"step":       "created",
+		"fields.msg": "clash",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Fatalf("Expected %s to be %q, got %v", k, v, record[k])
+		}
+	}
+
+	if _, ok := record["time"]; !ok {
+		t.Fatal("Expected the record to have a time")
+	}
+
+	if err := json.Unmarshal(errOut.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "error" || record["msg"] != "boom" {
+		t.Fatalf("Unexpected error record: %v", record)
+	}
+}
+
+func TestSetFormatUnknown(t *testing.T) {
+	if err := SetFormat("xml"); err == nil {
+		t.Fatal("Expected an error for an unknown format")
+	}
+}
+
+func TestExit(t *testing.T) {
+	sink := &recordingSink{}
+	SetSink(sink)
+	defer SetSink(nil)
+
+	status := 0
+	exit = func(code int) { status = code }
+	defer func() { exit = os.Exit }()
+
+	Exit(4, Fields{"category": "auth"}, "Permission denied")
+
+	if status != 4 {
+		t.Fatalf("Expected exit status 4, got %d", status)
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(sink.records))
+	}
+
+	r := sink.records[0]
+	if r.Level != LevelFatal || r.Message != "Permission denied" || r.Fields["category"] != "auth" {
+		t.Fatalf("Unexpected record: %+v", r)
+	}
+}
+
+func TestExitText(t *testing.T) {
+	var errOut bytes.Buffer
+	SetErrWriter(&errOut)
+	defer SetErrWriter(os.Stderr)
+
+	exit = func(int) {}
+	defer func() { exit = os.Exit }()
+
+	Exit(4, Fields{"category": "auth"}, "Permission denied")
+
+	if errOut.String() != "Permission denied\n" {
+		t.Fatalf("Expected the message alone, got %q", errOut.String())
+	}
+}
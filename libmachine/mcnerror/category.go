@@ -0,0 +1,210 @@
+package mcnerror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Category is the class of a failure, for scripts and retry logic to react
+// to without matching messages.
+type Category string
+
+const (
+	CategoryUnknown        Category = "unknown"
+	CategoryTransient      Category = "transient"
+	CategoryAuth           Category = "auth"
+	CategoryDriverQuota    Category = "driver-quota"
+	CategorySSHUnreachable Category = "ssh-unreachable"
+	CategoryProvision      Category = "provision"
+)
+
+// categorized is implemented by the errors of a category.
+type categorized interface {
+	Category() Category
+}
+
+// ErrTransient is a failure which may not happen again, like a rate limit or
+// a provider error, worth retrying.
+type ErrTransient struct {
+	Err error
+}
+
+func (e ErrTransient) Error() string      { return e.Err.Error() }
+func (e ErrTransient) Unwrap() error      { return e.Err }
+func (e ErrTransient) Category() Category { return CategoryTransient }
+
+// ErrAuth is a failure of the credentials, refused by the provider or by
+// the SSH server of a machine.
+type ErrAuth struct {
+	Err error
+}
+
+func (e ErrAuth) Error() string      { return e.Err.Error() }
+func (e ErrAuth) Unwrap() error      { return e.Err }
+func (e ErrAuth) Category() Category { return CategoryAuth }
+
+// ErrDriverQuota is a limit of the account with the provider of the driver,
+// like a number of instances, which was reached.
+type ErrDriverQuota struct {
+	Driver string
+	Err    error
+}
+
+func (e ErrDriverQuota) Error() string      { return e.Err.Error() }
+func (e ErrDriverQuota) Unwrap() error      { return e.Err }
+func (e ErrDriverQuota) Category() Category { return CategoryDriverQuota }
+
+// ErrSSHUnreachable is a machine which couldn't be connected to over SSH.
+type ErrSSHUnreachable struct {
+	Host string
+	Err  error
+}
+
+func (e ErrSSHUnreachable) Error() string      { return e.Err.Error() }
+func (e ErrSSHUnreachable) Unwrap() error      { return e.Err }
+func (e ErrSSHUnreachable) Category() Category { return CategorySSHUnreachable }
+
+// ErrSSHCommand is a command which failed on a machine, with its output.
+type ErrSSHCommand struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e ErrSSHCommand) Error() string {
+	return fmt.Sprintf(`Something went wrong running an SSH command!
+command : %s
+err     : %v
+output  : %s
+`, e.Command, e.Err, e.Output)
+}
+
+func (e ErrSSHCommand) Unwrap() error { return e.Err }
+
+// ErrProvisionStep is a step of provisioning a machine which failed, like
+// installing the engine, with the output of the command which failed.
+type ErrProvisionStep struct {
+	Step   string
+	Output string
+	Err    error
+}
+
+func (e ErrProvisionStep) Error() string {
+	return fmt.Sprintf("Error %s: %s", e.Step, e.Err)
+}
+
+func (e ErrProvisionStep) Unwrap() error      { return e.Err }
+func (e ErrProvisionStep) Category() Category { return CategoryProvision }
+
+// NewErrProvisionStep returns err as the failure of a step of provisioning,
+// with the output of the SSH command which failed, unless it's nil or
+// already the failure of a step.
+func NewErrProvisionStep(step string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var stepErr ErrProvisionStep
+	if errors.As(err, &stepErr) {
+		return err
+	}
+
+	e := ErrProvisionStep{Step: step, Err: err}
+	var cmdErr ErrSSHCommand
+	if errors.As(err, &cmdErr) {
+		e.Output = cmdErr.Output
+	}
+	return e
+}
+
+func (e ErrHostOperation) Unwrap() error { return e.Err }
+
+// Category is the category the errors of all the machines have, or
+// CategoryUnknown when they differ.
+func (e ErrBulkOperation) Category() Category {
+	category := CategoryUnknown
+	for i, err := range e.Errs {
+		c := CategoryOf(err)
+		if i > 0 && c != category {
+			return CategoryUnknown
+		}
+		category = c
+	}
+	return category
+}
+
+// CategoryOf returns the category of the failure err is, or wraps,
+// CategoryUnknown when it isn't classified. A wrapped error of a category
+// wins over the errors wrapping it, like a machine which couldn't be reached
+// failing a step of provisioning.
+func CategoryOf(err error) Category {
+	category := CategoryUnknown
+	for ; err != nil; err = errors.Unwrap(err) {
+		if c, ok := err.(categorized); ok && c.Category() != CategoryUnknown {
+			category = c.Category()
+		}
+	}
+	return category
+}
+
+// ForHTTPStatus returns the error of an API call of a driver as the error of
+// the category its HTTP status tells: ErrAuth for 401 and 403, ErrTransient
+// for 429 and the server errors, and err itself for the others.
+func ForHTTPStatus(status int, err error) error {
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return ErrAuth{Err: err}
+	case status == http.StatusTooManyRequests, status >= 500:
+		return ErrTransient{Err: err}
+	}
+	return err
+}
+
+// encodedPrefix starts the messages of the errors encoded with Encode, the
+// category following it up to the next "] ".
+const encodedPrefix = "[mcnerror:"
+
+// Encode returns err as an error whose message keeps its category, for the
+// category to survive being sent as a message, like from a driver plugin.
+// Errors without a category are returned as they are.
+func Encode(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	category := CategoryOf(err)
+	if category == CategoryUnknown {
+		return err
+	}
+	return fmt.Errorf("%s%s] %s", encodedPrefix, category, err)
+}
+
+// Decode returns the error of the category of a message made by Encode, or
+// err when it wasn't.
+func Decode(err error) error {
+	if err == nil || !strings.HasPrefix(err.Error(), encodedPrefix) {
+		return err
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(err.Error(), encodedPrefix), "] ", 2)
+	if len(parts) != 2 {
+		return err
+	}
+
+	msg := errors.New(parts[1])
+	switch Category(parts[0]) {
+	case CategoryTransient:
+		return ErrTransient{Err: msg}
+	case CategoryAuth:
+		return ErrAuth{Err: msg}
+	case CategoryDriverQuota:
+		return ErrDriverQuota{Err: msg}
+	case CategorySSHUnreachable:
+		return ErrSSHUnreachable{Err: msg}
+	case CategoryProvision:
+		return ErrProvisionStep{Err: msg}
+	}
+	return msg
+}
@@ -0,0 +1,100 @@
+package mcnerror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCategoryOf(t *testing.T) {
+	unreachable := ErrSSHUnreachable{Host: "web", Err: errors.New("connection refused")}
+
+	cases := []struct {
+		err      error
+		expected Category
+	}{
+		{nil, CategoryUnknown},
+		{errors.New("boom"), CategoryUnknown},
+		{ErrAuth{Err: errors.New("denied")}, CategoryAuth},
+		{fmt.Errorf("Error creating machine: %w", ErrDriverQuota{Err: errors.New("limit")}), CategoryDriverQuota},
+		{ErrProvisionStep{Step: "installing docker", Err: errors.New("exit status 1")}, CategoryProvision},
+		{NewErrProvisionStep("installing docker", unreachable), CategorySSHUnreachable},
+		{ErrHostOperation{Name: "web", Operation: "creating", Err: ErrTransient{Err: errors.New("rate limited")}}, CategoryTransient},
+	}
+
+	for _, c := range cases {
+		if category := CategoryOf(c.err); category != c.expected {
+			t.Errorf("Expected %v to be %s, got %s", c.err, c.expected, category)
+		}
+	}
+}
+
+func TestBulkOperationCategory(t *testing.T) {
+	auth := ErrHostOperation{Name: "db", Err: ErrAuth{Err: errors.New("denied")}}
+	transient := ErrHostOperation{Name: "web", Err: ErrTransient{Err: errors.New("rate limited")}}
+
+	if category := CategoryOf(ErrBulkOperation{Errs: []ErrHostOperation{auth, auth}}); category != CategoryAuth {
+		t.Fatalf("Expected the common category, got %s", category)
+	}
+	if category := CategoryOf(ErrBulkOperation{Errs: []ErrHostOperation{auth, transient}}); category != CategoryUnknown {
+		t.Fatalf("Expected differing categories to be unknown, got %s", category)
+	}
+}
+
+func TestNewErrProvisionStep(t *testing.T) {
+	if err := NewErrProvisionStep("installing docker", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cmdErr := ErrSSHCommand{Command: "yum install docker", Output: "No package docker available.", Err: errors.New("exit status 1")}
+	err := NewErrProvisionStep("installing docker", fmt.Errorf("Error installing: %w", cmdErr))
+
+	var stepErr ErrProvisionStep
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected an ErrProvisionStep, got %v", err)
+	}
+	if stepErr.Step != "installing docker" || stepErr.Output != "No package docker available." {
+		t.Fatalf("Expected the step and output of the command, got %+v", stepErr)
+	}
+
+	if outer := NewErrProvisionStep("running provisioning", err); outer != err {
+		t.Fatalf("Expected the failed step to be kept, got %v", outer)
+	}
+}
+
+func TestForHTTPStatus(t *testing.T) {
+	err := errors.New("API error")
+
+	cases := []struct {
+		status   int
+		expected Category
+	}{
+		{401, CategoryAuth},
+		{403, CategoryAuth},
+		{429, CategoryTransient},
+		{503, CategoryTransient},
+		{404, CategoryUnknown},
+	}
+
+	for _, c := range cases {
+		if category := CategoryOf(ForHTTPStatus(c.status, err)); category != c.expected {
+			t.Errorf("Expected status %d to be %s, got %s", c.status, c.expected, category)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	err := Decode(Encode(ErrDriverQuota{Driver: "amazonec2", Err: errors.New("Instance limit exceeded")}))
+
+	if category := CategoryOf(err); category != CategoryDriverQuota {
+		t.Fatalf("Expected the category to survive, got %s", category)
+	}
+	if err.Error() != "Instance limit exceeded" {
+		t.Fatalf("Expected the message to survive, got %q", err)
+	}
+
+	plain := errors.New("boom")
+	if Decode(Encode(plain)) != plain {
+		t.Fatal("Expected an error without category to be kept")
+	}
+}
@@ -3,6 +3,7 @@ package mcnerror
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -25,3 +26,31 @@ type ErrHostAlreadyExists struct {
 func (e ErrHostAlreadyExists) Error() string {
 	return fmt.Sprintf("Host already exists: %q", e.Name)
 }
+
+// ErrHostOperation is the error of an operation, like "creating", on a
+// machine.
+type ErrHostOperation struct {
+	Name      string
+	Operation string
+	Err       error
+}
+
+func (e ErrHostOperation) Error() string {
+	return fmt.Sprintf("Error %s %q: %s", e.Operation, e.Name, e.Err)
+}
+
+// ErrBulkOperation gathers the errors of an operation on several machines,
+// in the order the machines were given.
+type ErrBulkOperation struct {
+	Operation string
+	Total     int
+	Errs      []ErrHostOperation
+}
+
+func (e ErrBulkOperation) Error() string {
+	failed := []string{}
+	for _, err := range e.Errs {
+		failed = append(failed, fmt.Sprintf("%s: %s", err.Name, err.Err))
+	}
+	return fmt.Sprintf("Error %s %d of %d machines: %s", e.Operation, len(e.Errs), e.Total, strings.Join(failed, "; "))
+}
@@ -3,14 +3,13 @@ package mcnutils
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/docker/machine/libmachine/log"
@@ -18,12 +17,38 @@ import (
 
 var (
 	GithubApiToken string
+
+	// Boot2DockerMirrors are the base URLs boot2docker releases are
+	// downloaded from before GitHub, as <mirror>/<tag>/boot2docker.iso, from
+	// the comma separated MACHINE_BOOT2DOCKER_MIRROR.
+	Boot2DockerMirrors = splitList(os.Getenv("MACHINE_BOOT2DOCKER_MIRROR"))
+
+	// Boot2DockerVersion is the boot2docker release machines are created
+	// with by default instead of the latest one, from
+	// MACHINE_BOOT2DOCKER_VERSION.
+	Boot2DockerVersion = os.Getenv("MACHINE_BOOT2DOCKER_VERSION")
+
+	// releaseDownloadURL matches the URLs of the files of GitHub (enterprise)
+	// releases.
+	releaseDownloadURL = regexp.MustCompile("^https?://[^/]+/([^/]+)/([^/]+)/releases/download/([^/]+)/([^/]+)$")
 )
 
 const (
 	timeout = time.Second * 5
+
+	boot2DockerRepo = "boot2docker/boot2docker"
 )
 
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func defaultTimeout(network, addr string) (net.Conn, error) {
 	return net.DialTimeout(network, addr, timeout)
 }
@@ -60,6 +85,7 @@ func NewB2dUtils(storePath string) *B2dUtils {
 		isoFilename:   isoFilename,
 		imgCachePath:  imgCachePath,
 		commonIsoPath: filepath.Join(imgCachePath, isoFilename),
+		githubBaseUrl: "https://github.com",
 	}
 }
 
@@ -76,10 +102,39 @@ func (b *B2dUtils) getReleasesRequest(apiUrl string) (*http.Request, error) {
 	return req, nil
 }
 
+// latestReleaseTag returns the tag of the latest release of a GitHub
+// repository from where its releases/latest page redirects to, which unlike
+// the GitHub API isn't rate limited.
+func (b *B2dUtils) latestReleaseTag(org, repo string) (string, error) {
+	client := getClient()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	rsp, err := client.Head(fmt.Sprintf("%s/%s/%s/releases/latest", b.githubBaseUrl, org, repo))
+	if err != nil {
+		return "", err
+	}
+	rsp.Body.Close()
+
+	location := rsp.Header.Get("Location")
+	i := strings.LastIndex(location, "/releases/tag/")
+	if i < 0 {
+		return "", fmt.Errorf("unexpected redirection to %q", location)
+	}
+
+	return location[i+len("/releases/tag/"):], nil
+}
+
 // Get the latest boot2docker release tag name (e.g. "v0.6.0").
-// FIXME: find or create some other way to get the "latest release" of boot2docker since the GitHub API has a pretty low rate limit on API requests
+// The latest release of github.com repositories is found without the
+// GitHub API, which has a pretty low rate limit on API requests, unless
+// that fails.
 func (b *B2dUtils) GetLatestBoot2DockerReleaseURL(apiUrl string) (string, error) {
 	if apiUrl == "" {
+		if Boot2DockerVersion != "" {
+			return fmt.Sprintf("%s/%s/releases/download/%s/boot2docker.iso", b.githubBaseUrl, boot2DockerRepo, Boot2DockerVersion), nil
+		}
 		apiUrl = "https://api.github.com/repos/boot2docker/boot2docker/releases"
 	}
 	isoUrl := ""
@@ -93,6 +148,12 @@ func (b *B2dUtils) GetLatestBoot2DockerReleaseURL(apiUrl string) (string, error)
 		org := matches[4]
 		repo := matches[5]
 		if host == "api.github.com" {
+			tag, err := b.latestReleaseTag(org, repo)
+			if err == nil {
+				log.Infof("Latest release for github.com/%s/%s is %s\n", org, repo, tag)
+				return fmt.Sprintf("%s/%s/%s/releases/download/%s/boot2docker.iso", b.githubBaseUrl, org, repo, tag), nil
+			}
+			log.Debugf("Error finding the latest release without the GitHub API: %s", err)
 			host = "github.com"
 		}
 		client := getClient()
@@ -136,62 +197,161 @@ func removeFileIfExists(name string) error {
 	return nil
 }
 
+// downloadURLs returns the URLs to download the file at srcURL from in
+// turn: the mirrors first for boot2docker releases.
+func downloadURLs(srcURL string) []string {
+	matches := releaseDownloadURL.FindStringSubmatch(srcURL)
+	if matches == nil || matches[1]+"/"+matches[2] != boot2DockerRepo {
+		return []string{srcURL}
+	}
+
+	var urls []string
+	for _, mirror := range Boot2DockerMirrors {
+		urls = append(urls, fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(mirror, "/"), matches[3], matches[4]))
+	}
+	return append(urls, srcURL)
+}
+
 // Download boot2docker ISO image for the given tag and save it at dest.
+// Its SHA256 checksum is verified when the URL ends with
+// #sha256=<checksum>, or when one is published next to it in a .sha256 file.
 func (b *B2dUtils) DownloadISO(dir, file, isoUrl string) error {
+	return b.downloadISO(dir, file, isoUrl, false)
+}
+
+// downloadISO is DownloadISO, keeping the partial download on errors when
+// resume is set, for the next download to pick it up.
+func (b *B2dUtils) downloadISO(dir, file, isoUrl string, resume bool) error {
 	u, err := url.Parse(isoUrl)
-	var src io.ReadCloser
+	if err != nil {
+		return err
+	}
+
+	checksum, err := isoChecksum(u)
+	if err != nil {
+		return err
+	}
+	u.Fragment = ""
+
+	// Dest is the final path of the boot2docker.iso file.
+	dest := filepath.Join(dir, file)
+
+	// Download to a partial file first then rename it to avoid partial
+	// download.
+	part := dest + ".part"
+
 	if u.Scheme == "file" || u.Scheme == "" {
-		s, err := os.Open(u.Path)
-		if err != nil {
+		if err := CopyFile(u.Path, part); err != nil {
 			return err
 		}
-		src = s
 	} else {
-		client := getClient()
-		s, err := client.Get(isoUrl)
+		var srcURL string
+		for _, srcURL = range downloadURLs(u.String()) {
+			if err = download(part, srcURL, resume); err == nil {
+				break
+			}
+			log.Warnf("Error downloading %s: %s", srcURL, err)
+		}
 		if err != nil {
-			return err
+			return fmt.Errorf("Error downloading %s: %s", u, err)
+		}
+
+		if checksum == "" {
+			checksum = remoteChecksum(srcURL)
 		}
-		src = s.Body
 	}
 
-	defer src.Close()
+	if checksum != "" {
+		if err := verifyChecksum(part, checksum); err != nil {
+			removeFileIfExists(part)
+			return fmt.Errorf("Error verifying %s: %s", isoUrl, err)
+		}
+		log.Debugf("SHA256 checksum of %s verified", isoUrl)
+	}
 
-	// Download to a temp file first then rename it to avoid partial download.
-	f, err := ioutil.TempFile(dir, file+".tmp")
-	if err != nil {
+	// Windows can't rename in place, so remove the old file before
+	// renaming the temporary downloaded file.
+	if err := removeFileIfExists(dest); err != nil {
 		return err
 	}
 
-	defer func() {
-		if err := removeFileIfExists(f.Name()); err != nil {
-			log.Warnf("Error removing file: %s", err)
-		}
-	}()
+	return os.Rename(part, dest)
+}
 
-	if _, err := io.Copy(f, src); err != nil {
-		// TODO: display download progress?
-		return err
+// isoCacheDir returns the directory the ISO at isoUrl is kept in, within
+// the image cache, after its version and checksum. ISOs with neither could
+// change, so they aren't cached and "" is returned.
+func (b *B2dUtils) isoCacheDir(isoUrl string) string {
+	return b.cacheDir("isos", isoUrl)
+}
+
+// cacheDir returns the directory the file at srcUrl is kept in, within the
+// kind directory of the image cache, or "" if it isn't cached.
+func (b *B2dUtils) cacheDir(kind, srcUrl string) string {
+	u, err := url.Parse(srcUrl)
+	if err != nil || u.Scheme == "file" || u.Scheme == "" {
+		return ""
 	}
 
-	if err := f.Close(); err != nil {
-		return err
+	checksum, err := isoChecksum(u)
+	if err != nil {
+		return ""
 	}
+	u.Fragment = ""
 
-	// Dest is the final path of the boot2docker.iso file.
-	dest := filepath.Join(dir, file)
+	var key []string
+	if matches := releaseDownloadURL.FindStringSubmatch(u.String()); matches != nil {
+		key = append(key, matches[1], matches[2], matches[3])
+	}
+	if checksum != "" {
+		key = append(key, "sha256", checksum)
+	}
+	if len(key) == 0 {
+		return ""
+	}
 
-	// Windows can't rename in place, so remove the old file before
-	// renaming the temporary downloaded file.
-	if err := removeFileIfExists(dest); err != nil {
-		return err
+	name := regexp.MustCompile("[^A-Za-z0-9._-]").ReplaceAllString(strings.Join(key, "-"), "_")
+	return filepath.Join(b.imgCachePath, kind, name)
+}
+
+// cacheISO downloads the ISO at isoUrl to the image cache, unless it is
+// already there, and returns its path in the cache. Interrupted downloads
+// are resumed, and processes downloading the same ISO wait for each other.
+func (b *B2dUtils) cacheISO(isoUrl string) (string, error) {
+	return b.cacheFile("isos", isoUrl, b.isoFilename)
+}
+
+// cacheFile downloads the file at srcUrl to the kind directory of the image
+// cache as file, like cacheISO.
+func (b *B2dUtils) cacheFile(kind, srcUrl, file string) (string, error) {
+	dir := b.cacheDir(kind, srcUrl)
+	if dir == "" {
+		return "", fmt.Errorf("%s has neither a version nor a checksum to be cached by", srcUrl)
 	}
 
-	if err := os.Rename(f.Name(), dest); err != nil {
-		return err
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
 	}
 
-	return nil
+	path := filepath.Join(dir, file)
+
+	unlock, err := lock(path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		log.Infof("Using %s from the cache", srcUrl)
+		return path, nil
+	}
+
+	log.Infof("Downloading %s to %s...", srcUrl, path)
+	if err := b.downloadISO(dir, file, srcUrl, true); err != nil {
+		return "", err
+	}
+
+	return path, nil
 }
 
 func (b *B2dUtils) DownloadLatestBoot2Docker(apiUrl string) error {
@@ -204,6 +364,14 @@ func (b *B2dUtils) DownloadLatestBoot2Docker(apiUrl string) error {
 }
 
 func (b *B2dUtils) DownloadISOFromURL(latestReleaseUrl string) error {
+	if b.isoCacheDir(latestReleaseUrl) != "" {
+		cachedIsoPath, err := b.cacheISO(latestReleaseUrl)
+		if err != nil {
+			return err
+		}
+		return CopyFile(cachedIsoPath, b.commonIsoPath)
+	}
+
 	log.Infof("Downloading %s to %s...", latestReleaseUrl, b.commonIsoPath)
 	if err := b.DownloadISO(b.imgCachePath, b.isoFilename, latestReleaseUrl); err != nil {
 		return err
@@ -221,7 +389,7 @@ func (b *B2dUtils) CopyIsoToMachineDir(isoURL, machineName string) error {
 	// check for it and recreate it if it's gone
 	if _, err := os.Stat(b.imgCachePath); os.IsNotExist(err) {
 		log.Infof("Image cache does not exist, creating it at %s...", b.imgCachePath)
-		if err := os.Mkdir(b.imgCachePath, 0700); err != nil {
+		if err := os.MkdirAll(b.imgCachePath, 0700); err != nil {
 			return err
 		}
 	}
@@ -235,12 +403,22 @@ func (b *B2dUtils) CopyIsoToMachineDir(isoURL, machineName string) error {
 	} else {
 		//if ISO is specified, check if it matches a github releases url or fallback
 		//to a direct download
-		if downloadUrl, err := b.GetLatestBoot2DockerReleaseURL(isoURL); err == nil {
-			log.Infof("Downloading %s from %s...", b.isoFilename, downloadUrl)
-			if err := b.DownloadISO(machineDir, b.isoFilename, downloadUrl); err != nil {
+		downloadUrl, err := b.GetLatestBoot2DockerReleaseURL(isoURL)
+		if err != nil {
+			return err
+		}
+
+		// Versioned ISOs are shared by the machines through the cache.
+		if b.isoCacheDir(downloadUrl) != "" {
+			cachedIsoPath, err := b.cacheISO(downloadUrl)
+			if err != nil {
 				return err
 			}
-		} else {
+			return CopyFile(cachedIsoPath, machineIsoPath)
+		}
+
+		log.Infof("Downloading %s from %s...", b.isoFilename, downloadUrl)
+		if err := b.DownloadISO(machineDir, b.isoFilename, downloadUrl); err != nil {
 			return err
 		}
 	}
@@ -248,15 +426,50 @@ func (b *B2dUtils) CopyIsoToMachineDir(isoURL, machineName string) error {
 	return nil
 }
 
-func (b *B2dUtils) copyDefaultIsoToMachine(machineIsoPath string) error {
+// CacheDefaultIso downloads the latest boot2docker release to the image
+// cache, unless it is already there, or the release pinned with
+// MACHINE_BOOT2DOCKER_VERSION.
+func (b *B2dUtils) CacheDefaultIso() error {
+	if Boot2DockerVersion != "" {
+		_, err := b.cacheDefaultIso()
+		return err
+	}
+
 	if _, err := os.Stat(b.commonIsoPath); os.IsNotExist(err) {
 		log.Info("No default boot2docker iso found locally, downloading the latest release...")
-		if err := b.DownloadLatestBoot2Docker(""); err != nil {
+		if err := os.MkdirAll(b.imgCachePath, 0700); err != nil {
 			return err
 		}
+		return b.DownloadLatestBoot2Docker("")
+	}
+
+	return nil
+}
+
+// cacheDefaultIso caches the boot2docker release pinned with
+// MACHINE_BOOT2DOCKER_VERSION, and returns its path.
+func (b *B2dUtils) cacheDefaultIso() (string, error) {
+	isoUrl, err := b.GetLatestBoot2DockerReleaseURL("")
+	if err != nil {
+		return "", err
+	}
+
+	return b.cacheISO(isoUrl)
+}
+
+func (b *B2dUtils) copyDefaultIsoToMachine(machineIsoPath string) error {
+	isoPath := b.commonIsoPath
+	if Boot2DockerVersion != "" {
+		cachedIsoPath, err := b.cacheDefaultIso()
+		if err != nil {
+			return err
+		}
+		isoPath = cachedIsoPath
+	} else if err := b.CacheDefaultIso(); err != nil {
+		return err
 	}
 
-	if err := CopyFile(b.commonIsoPath, machineIsoPath); err != nil {
+	if err := CopyFile(isoPath, machineIsoPath); err != nil {
 		return err
 	}
 
@@ -1,12 +1,16 @@
 package mcnutils
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetLatestBoot2DockerReleaseUrl(t *testing.T) {
@@ -84,3 +88,222 @@ func TestGetReleasesRequest(t *testing.T) {
 		t.Fatal("Header was not set as expected: ", req.Header.Get("Authorization"))
 	}
 }
+
+func TestGetLatestBoot2DockerReleaseURLWithoutAPI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/boot2docker/boot2docker/releases/latest" {
+			t.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+		http.Redirect(w, r, "/boot2docker/boot2docker/releases/tag/v1.9.1", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	b := NewB2dUtils("/tmp/isos")
+	b.githubBaseUrl = ts.URL
+
+	isoUrl, err := b.GetLatestBoot2DockerReleaseURL("https://api.github.com/repos/boot2docker/boot2docker/releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedUrl := ts.URL + "/boot2docker/boot2docker/releases/download/v1.9.1/boot2docker.iso"
+	if isoUrl != expectedUrl {
+		t.Fatalf("expected url %s; received %s", expectedUrl, isoUrl)
+	}
+
+	Boot2DockerVersion = "v1.8.0"
+	defer func() { Boot2DockerVersion = "" }()
+
+	isoUrl, err = b.GetLatestBoot2DockerReleaseURL("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedUrl = ts.URL + "/boot2docker/boot2docker/releases/download/v1.8.0/boot2docker.iso"
+	if isoUrl != expectedUrl {
+		t.Fatalf("expected url %s; received %s", expectedUrl, isoUrl)
+	}
+}
+
+func TestDownloadIsoChecksum(t *testing.T) {
+	testData := "test-download"
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(testData)))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/published.iso.sha256":
+			fmt.Fprintf(w, "%s  published.iso\n", strings.Repeat("0", 64))
+		case "/published.iso", "/test.iso":
+			w.Write([]byte(testData))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	b := NewB2dUtils(tmpDir)
+	if err := b.DownloadISO(tmpDir, "ok", ts.URL+"/test.iso#sha256="+checksum); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.DownloadISO(tmpDir, "bad", ts.URL+"/test.iso#sha256="+strings.Repeat("0", 64)); err == nil {
+		t.Fatal("expected a checksum mismatch")
+	}
+
+	if err := b.DownloadISO(tmpDir, "published", ts.URL+"/published.iso"); err == nil {
+		t.Fatal("expected a mismatch with the published checksum")
+	}
+
+	for _, file := range []string{"bad", "bad.part", "published", "published.part"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, file)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed", file)
+		}
+	}
+
+	if err := b.DownloadISO(tmpDir, "missing", ts.URL+"/missing.iso"); err == nil {
+		t.Fatal("expected an error downloading a missing file")
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	testData := "test-download-resumed"
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "boot2docker.iso", time.Time{}, strings.NewReader(testData))
+	}))
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	part := filepath.Join(tmpDir, "boot2docker.iso.part")
+	ioutil.WriteFile(part, []byte(testData[:5]), 0600)
+
+	if err := download(part, ts.URL, true); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(part)
+	if string(data) != testData {
+		t.Fatalf("expected data %q; received %q", testData, string(data))
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=5-" {
+		t.Fatalf("expected the download to resume at 5 bytes, got ranges %q", ranges)
+	}
+}
+
+func TestCopyIsoToMachineDirCache(t *testing.T) {
+	testData := "test-cached"
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, "/mirror/") {
+			w.Write([]byte(testData))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	Boot2DockerMirrors = []string{ts.URL + "/broken", ts.URL + "/mirror/"}
+	defer func() { Boot2DockerMirrors = nil }()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	b := NewB2dUtils(storePath)
+	isoUrl := ts.URL + "/boot2docker/boot2docker/releases/download/v1.9.1/boot2docker.iso"
+
+	for _, machine := range []string{"one", "two"} {
+		os.MkdirAll(filepath.Join(storePath, "machines", machine), 0700)
+		if err := b.CopyIsoToMachineDir(isoUrl, machine); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(storePath, "machines", machine, "boot2docker.iso"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != testData {
+			t.Fatalf("expected data %q; received %q", testData, string(data))
+		}
+	}
+
+	expected := []string{
+		"/broken/v1.9.1/boot2docker.iso",
+		"/mirror/v1.9.1/boot2docker.iso",
+		"/mirror/v1.9.1/boot2docker.iso.sha256",
+	}
+	if strings.Join(requests, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected requests %q; received %q", expected, requests)
+	}
+
+	if _, err := os.Stat(filepath.Join(storePath, "cache", "isos", "boot2docker-boot2docker-v1.9.1", "boot2docker.iso")); err != nil {
+		t.Fatalf("expected the ISO to be cached: %s", err)
+	}
+}
+
+func TestDownloadImage(t *testing.T) {
+	testData := "test-image"
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(testData)))
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path == "/focal.img" {
+			w.Write([]byte(testData))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	b := NewB2dUtils(storePath)
+
+	for i := 0; i < 2; i++ {
+		path, err := b.DownloadImage(ts.URL+"/focal.img#sha256="+checksum, storePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := filepath.Join(storePath, "cache", "images", "sha256-"+checksum, "focal.img")
+		if path != expected {
+			t.Fatalf("expected the image at %s; got %s", expected, path)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected the image to be downloaded once; received %q", requests)
+	}
+
+	path, err := b.DownloadImage(ts.URL+"/focal.img", storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(storePath, "focal.img") {
+		t.Fatalf("expected the image to be downloaded to the directory; got %s", path)
+	}
+
+	local, err := b.DownloadImage(path, storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local != path {
+		t.Fatalf("expected the local image to be used in place; got %s", local)
+	}
+}
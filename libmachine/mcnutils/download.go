@@ -0,0 +1,228 @@
+package mcnutils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	// downloadAttempts is how many times an interrupted download is
+	// resumed before giving up.
+	downloadAttempts = 5
+
+	// lockTimeout is how long to wait for another process downloading the
+	// same file, after which its lock is considered stale.
+	lockTimeout = 30 * time.Minute
+)
+
+// interruptedError is the error of a download which broke off, and can be
+// resumed.
+type interruptedError struct {
+	err error
+}
+
+func (e interruptedError) Error() string {
+	return e.err.Error()
+}
+
+// isoChecksum returns the SHA256 checksum given in the fragment of the URL
+// of an ISO, as in https://example.com/boot2docker.iso#sha256=..., if any.
+func isoChecksum(u *url.URL) (string, error) {
+	if u.Fragment == "" {
+		return "", nil
+	}
+
+	if !strings.HasPrefix(u.Fragment, "sha256=") {
+		return "", fmt.Errorf("Invalid ISO URL fragment %q, expected sha256=<checksum>", u.Fragment)
+	}
+
+	checksum := strings.ToLower(strings.TrimPrefix(u.Fragment, "sha256="))
+	if !isSHA256(checksum) {
+		return "", fmt.Errorf("Invalid SHA256 checksum %q", checksum)
+	}
+
+	return checksum, nil
+}
+
+func isSHA256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+// remoteChecksum returns the SHA256 checksum published next to the file at
+// srcURL, in srcURL.sha256 as written by sha256sum, or "" if there is none.
+func remoteChecksum(srcURL string) string {
+	resp, err := getClient().Get(srcURL + ".sha256")
+	if err != nil {
+		log.Debugf("No SHA256 checksum for %s: %s", srcURL, err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Debugf("No SHA256 checksum for %s: %s", srcURL, resp.Status)
+		return ""
+	}
+
+	line, _ := bufio.NewReader(io.LimitReader(resp.Body, 1024)).ReadString('\n')
+	fields := strings.Fields(line)
+	if len(fields) == 0 || !isSHA256(strings.ToLower(fields[0])) {
+		log.Debugf("Ignoring the invalid SHA256 checksum of %s", srcURL)
+		return ""
+	}
+
+	return strings.ToLower(fields[0])
+}
+
+// fileChecksum returns the SHA256 checksum of the file at path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum returns an error if the file at path doesn't have the
+// given SHA256 checksum.
+func verifyChecksum(path, checksum string) error {
+	actual, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+
+	if actual != checksum {
+		return fmt.Errorf("SHA256 checksum mismatch, expected %s but got %s", checksum, actual)
+	}
+
+	return nil
+}
+
+// fetch downloads srcURL to the file at part, resuming the download from
+// where the file ends if it isn't empty and the server supports it.
+func fetch(part, srcURL string) error {
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", srcURL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := getClient().Do(req)
+	if err != nil {
+		return interruptedError{err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		log.Infof("Resuming the download at %d bytes...", offset)
+	case http.StatusOK:
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The file was downloaded entirely already.
+		return nil
+	default:
+		return errors.New(resp.Status)
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return interruptedError{err}
+	}
+
+	return f.Close()
+}
+
+// download downloads srcURL to the file at part, resuming it when it is
+// interrupted. The partial file is kept on errors when resume is set, for
+// the next download to pick it up.
+func download(part, srcURL string, resume bool) error {
+	if !resume {
+		if err := removeFileIfExists(part); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		err = fetch(part, srcURL)
+		if _, ok := err.(interruptedError); !ok {
+			break
+		}
+		log.Warnf("Download of %s interrupted (%d/%d): %s", srcURL, attempt, downloadAttempts, err)
+	}
+
+	if err != nil && !resume {
+		removeFileIfExists(part)
+	}
+
+	return err
+}
+
+// lock takes the lock of the file at path, waiting for another process
+// holding it, and returns the function releasing it.
+func lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	start := time.Now()
+
+	for waited := false; ; waited = true {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			log.Warnf("Removing the stale lock %s", lockPath)
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Since(start) > lockTimeout {
+			return nil, fmt.Errorf("Timed out waiting for the lock %s", lockPath)
+		}
+
+		if !waited {
+			log.Infof("Waiting for another download of %s...", path)
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,46 @@
+package mcnutils
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// DownloadImage returns the local path of the disk image at imageURL, such
+// as a stock cloud image the local drivers boot instead of boot2docker.
+// Local files are used in place. Images with a version or a SHA256 checksum,
+// as in https://example.com/disk.img#sha256=..., are downloaded once to the
+// image cache and shared by the machines, while other images could change so
+// they are downloaded to dir.
+func (b *B2dUtils) DownloadImage(imageURL, dir string) (string, error) {
+	if _, err := os.Stat(imageURL); err == nil {
+		return imageURL, nil
+	}
+
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "file" || u.Scheme == "" {
+		return u.Path, nil
+	}
+
+	file := path.Base(u.Path)
+	if file == "/" || file == "." {
+		file = "image"
+	}
+
+	if b.cacheDir("images", imageURL) != "" {
+		return b.cacheFile("images", imageURL, file)
+	}
+
+	log.Infof("Downloading %s from %s...", file, imageURL)
+	if err := b.downloadISO(dir, file, imageURL, false); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, file), nil
+}
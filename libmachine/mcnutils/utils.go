@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/net/context"
 )
 
 // TODO: Having this here just strikes me as dangerous, but some of the drivers
@@ -71,7 +72,16 @@ func CopyFile(src, dst string) error {
 }
 
 func WaitForSpecificOrError(f func() (bool, error), maxAttempts int, waitInterval time.Duration) error {
+	return WaitForSpecificOrErrorContext(context.Background(), f, maxAttempts, waitInterval)
+}
+
+// WaitForSpecificOrErrorContext is WaitForSpecificOrError, giving up as soon
+// as ctx is done.
+func WaitForSpecificOrErrorContext(ctx context.Context, f func() (bool, error), maxAttempts int, waitInterval time.Duration) error {
 	for i := 0; i < maxAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		stop, err := f()
 		if err != nil {
 			return err
@@ -79,19 +89,46 @@ func WaitForSpecificOrError(f func() (bool, error), maxAttempts int, waitInterva
 		if stop {
 			return nil
 		}
-		time.Sleep(waitInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitInterval):
+		}
 	}
 	return fmt.Errorf("Maximum number of retries (%d) exceeded", maxAttempts)
 }
 
 func WaitForSpecific(f func() bool, maxAttempts int, waitInterval time.Duration) error {
-	return WaitForSpecificOrError(func() (bool, error) {
+	return WaitForSpecificContext(context.Background(), f, maxAttempts, waitInterval)
+}
+
+func WaitForSpecificContext(ctx context.Context, f func() bool, maxAttempts int, waitInterval time.Duration) error {
+	return WaitForSpecificOrErrorContext(ctx, func() (bool, error) {
 		return f(), nil
 	}, maxAttempts, waitInterval)
 }
 
 func WaitFor(f func() bool) error {
-	return WaitForSpecific(f, 60, 3*time.Second)
+	return WaitForContext(context.Background(), f)
+}
+
+func WaitForContext(ctx context.Context, f func() bool) error {
+	return WaitForSpecificContext(ctx, f, 60, 3*time.Second)
+}
+
+// WaitForTimeout is WaitFor, trying for timeout rather than 3 minutes.
+func WaitForTimeout(f func() bool, timeout time.Duration) error {
+	return WaitForTimeoutContext(context.Background(), f, timeout)
+}
+
+// WaitForTimeoutContext is WaitForTimeout, giving up as soon as ctx is done.
+func WaitForTimeoutContext(ctx context.Context, f func() bool, timeout time.Duration) error {
+	waitInterval := 3 * time.Second
+	maxAttempts := int(timeout / waitInterval)
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return WaitForSpecificContext(ctx, f, maxAttempts, waitInterval)
 }
 
 func DumpVal(vals ...interface{}) {
@@ -6,6 +6,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
 func TestCopyFile(t *testing.T) {
@@ -99,3 +102,22 @@ func TestShortenIdInvalid(t *testing.T) {
 		t.Fatalf("Id returned is incorrect: truncate on %s returned %s", id, truncID)
 	}
 }
+
+func TestWaitForContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	err := WaitForSpecificContext(ctx, func() bool {
+		attempts++
+		cancel()
+		return false
+	}, 10, time.Hour)
+
+	if err != context.Canceled {
+		t.Fatalf("Expected the wait to be canceled, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expected a single attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,134 @@
+package mcnutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// WaitStage is something Machine waits for, whose timeout and poll interval
+// can be configured with ConfigureWait.
+type WaitStage string
+
+const (
+	// WaitDaemon is the wait for the Docker daemon to respond, after it's
+	// installed, configured or upgraded.
+	WaitDaemon WaitStage = "daemon"
+
+	// WaitIP is the wait for a machine to get an IP address.
+	WaitIP WaitStage = "ip"
+
+	// WaitSSH is the wait for SSH to be available on a machine.
+	WaitSSH WaitStage = "ssh"
+
+	// WaitState is the wait for a machine to be running, stopped or paused
+	// once the driver was asked to.
+	WaitState WaitStage = "state"
+)
+
+// WaitStages are the stages whose waits can be configured.
+var WaitStages = []WaitStage{WaitDaemon, WaitIP, WaitSSH, WaitState}
+
+const (
+	// DefaultWaitTimeout is how long the waits which don't set their own
+	// timeout last.
+	DefaultWaitTimeout = 3 * time.Minute
+
+	// DefaultPollInterval is how often the waits which don't set their own
+	// poll interval check whether they are over.
+	DefaultPollInterval = 3 * time.Second
+)
+
+// WaitSettings are how long a wait lasts and how often it checks whether it
+// is over. Zero values stand for the defaults.
+type WaitSettings struct {
+	Timeout      time.Duration
+	PollInterval time.Duration
+}
+
+var (
+	waitSettingsMu sync.Mutex
+	waitSettings   = map[WaitStage]WaitSettings{}
+)
+
+// ParseWaitStage returns the stage named s.
+func ParseWaitStage(s string) (WaitStage, error) {
+	for _, stage := range WaitStages {
+		if string(stage) == s {
+			return stage, nil
+		}
+	}
+	return "", fmt.Errorf("unknown wait %q, expected one of %v", s, WaitStages)
+}
+
+// ConfigureWait sets the timeout and the poll interval of the waits of the
+// stage. Its zero values leave the ones of the waits.
+func ConfigureWait(stage WaitStage, settings WaitSettings) {
+	waitSettingsMu.Lock()
+	defer waitSettingsMu.Unlock()
+
+	configured := waitSettings[stage]
+	if settings.Timeout > 0 {
+		configured.Timeout = settings.Timeout
+	}
+	if settings.PollInterval > 0 {
+		configured.PollInterval = settings.PollInterval
+	}
+	waitSettings[stage] = configured
+}
+
+// ResetWaits forgets the timeouts and poll intervals set with ConfigureWait.
+func ResetWaits() {
+	waitSettingsMu.Lock()
+	defer waitSettingsMu.Unlock()
+
+	waitSettings = map[WaitStage]WaitSettings{}
+}
+
+// StageWaitSettings returns the settings of a wait of the stage: the ones
+// set with ConfigureWait, or else defaults, or else DefaultWaitTimeout and
+// DefaultPollInterval.
+func StageWaitSettings(stage WaitStage, defaults WaitSettings) WaitSettings {
+	waitSettingsMu.Lock()
+	configured := waitSettings[stage]
+	waitSettingsMu.Unlock()
+
+	settings := defaults
+	if configured.Timeout > 0 {
+		settings.Timeout = configured.Timeout
+	}
+	if configured.PollInterval > 0 {
+		settings.PollInterval = configured.PollInterval
+	}
+
+	if settings.Timeout <= 0 {
+		settings.Timeout = DefaultWaitTimeout
+	}
+	if settings.PollInterval <= 0 {
+		settings.PollInterval = DefaultPollInterval
+	}
+
+	return settings
+}
+
+// WaitForStage waits for f to return true, for as long and as often as the
+// settings of the stage ask for, giving up as soon as ctx is done.
+func WaitForStage(ctx context.Context, stage WaitStage, defaults WaitSettings, f func() bool) error {
+	settings := StageWaitSettings(stage, defaults)
+
+	maxAttempts := int((settings.Timeout + settings.PollInterval - 1) / settings.PollInterval)
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	if err := WaitForSpecificContext(ctx, f, maxAttempts, settings.PollInterval); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("Timed out after %s, the timeout of the %s wait", settings.Timeout, stage)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package mcnutils
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestStageWaitSettings(t *testing.T) {
+	defer ResetWaits()
+
+	settings := StageWaitSettings(WaitSSH, WaitSettings{})
+	if settings.Timeout != DefaultWaitTimeout || settings.PollInterval != DefaultPollInterval {
+		t.Fatalf("expected the default settings, got %+v", settings)
+	}
+
+	settings = StageWaitSettings(WaitSSH, WaitSettings{Timeout: 10 * time.Minute})
+	if settings.Timeout != 10*time.Minute || settings.PollInterval != DefaultPollInterval {
+		t.Fatalf("expected the timeout of the wait, got %+v", settings)
+	}
+
+	ConfigureWait(WaitSSH, WaitSettings{Timeout: 20 * time.Minute})
+	ConfigureWait(WaitSSH, WaitSettings{PollInterval: 10 * time.Second})
+
+	settings = StageWaitSettings(WaitSSH, WaitSettings{Timeout: 10 * time.Minute})
+	if settings.Timeout != 20*time.Minute || settings.PollInterval != 10*time.Second {
+		t.Fatalf("expected the configured settings, got %+v", settings)
+	}
+
+	settings = StageWaitSettings(WaitDaemon, WaitSettings{Timeout: 15 * time.Second})
+	if settings.Timeout != 15*time.Second {
+		t.Fatalf("expected the settings of other waits to be left, got %+v", settings)
+	}
+}
+
+func TestWaitForStage(t *testing.T) {
+	defer ResetWaits()
+
+	ConfigureWait(WaitIP, WaitSettings{Timeout: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+
+	attempts := 0
+	err := WaitForStage(context.Background(), WaitIP, WaitSettings{}, func() bool {
+		attempts++
+		return false
+	})
+	if err == nil || err.Error() != "Timed out after 50ms, the timeout of the ip wait" {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if attempts != 5 {
+		t.Fatalf("expected 5 attempts, got %d", attempts)
+	}
+
+	attempts = 0
+	if err := WaitForStage(context.Background(), WaitIP, WaitSettings{}, func() bool {
+		attempts++
+		return attempts == 3
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForStageCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := WaitForStage(ctx, WaitState, WaitSettings{}, func() bool { return false }); err != context.Canceled {
+		t.Fatalf("expected the wait to be cancelled, got %v", err)
+	}
+}
+
+func TestParseWaitStage(t *testing.T) {
+	stage, err := ParseWaitStage("daemon")
+	if err != nil || stage != WaitDaemon {
+		t.Fatalf("expected the daemon wait, got %q, %v", stage, err)
+	}
+
+	if _, err := ParseWaitStage("boot"); err == nil {
+		t.Fatal("expected an error for an unknown wait")
+	}
+}
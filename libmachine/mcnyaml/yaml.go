@@ -0,0 +1,474 @@
+// Package mcnyaml parses the subset of YAML used by machine's spec and
+// config files.
+package mcnyaml
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	floatRegexp       = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+	blockScalarRegexp = regexp.MustCompile(`^[|>][-+]?$`)
+)
+
+// The parser below understands the subset of YAML that machine's files need:
+// block mappings and sequences, flow sequences and mappings on a single
+// line, plain and quoted scalars, literal (|) and folded (>) block scalars
+// and comments. Anchors, aliases, tags and multiple documents are not
+// supported, and are errors rather than read as plain scalars.
+
+type yamlLine struct {
+	num     int
+	indent  int
+	content string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// Parse parses a YAML document into maps, slices, strings, int64s, float64s,
+// bools and nils.
+func Parse(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+
+	started := false
+	// The line break ending the last line doesn't start another one.
+	for i, raw := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		raw = strings.TrimRight(raw, " \t\r")
+		content := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		if raw == "---" && started {
+			return nil, fmt.Errorf("line %d: multiple documents are not supported", i+1)
+		}
+		if stripComment(content) != "" {
+			started = true
+		}
+		p.lines = append(p.lines, yamlLine{
+			num:     i + 1,
+			indent:  len(raw) - len(content),
+			content: content,
+		})
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].content == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+
+	v, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected content %q", p.lines[p.pos].num, p.lines[p.pos].content)
+	}
+
+	return v, nil
+}
+
+// skipBlank moves past empty lines and comments.
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		c := stripComment(p.lines[p.pos].content)
+		if c != "" {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *yamlParser) current() (yamlLine, bool) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return yamlLine{}, false
+	}
+	l := p.lines[p.pos]
+	l.content = stripComment(l.content)
+	return l, true
+}
+
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	l, ok := p.current()
+	if !ok {
+		return nil, nil
+	}
+
+	if isSequenceItem(l.content) {
+		return p.parseSequence(indent)
+	}
+
+	if _, _, ok := splitMappingEntry(l.content); ok {
+		return p.parseMapping(indent)
+	}
+
+	p.pos++
+	return parseFlow(l.content, l.num)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	seq := []interface{}{}
+
+	for {
+		l, ok := p.current()
+		if !ok || l.indent != indent || !isSequenceItem(l.content) {
+			return seq, nil
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(l.content, "-"), " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+
+		// "- key: value" and "- - item" start a mapping or a sequence
+		// indented to the position of key or of the inner item, so rewrite
+		// the line as such and parse the block from there.
+		if _, _, ok := splitMappingEntry(rest); ok || isSequenceItem(rest) {
+			p.lines[p.pos].indent = indent + len(l.content) - len(rest)
+			p.lines[p.pos].content = rest
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+
+		p.pos++
+		v, err := p.parseValue(rest, l.num, indent)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+
+	for {
+		l, ok := p.current()
+		if !ok || l.indent != indent || isSequenceItem(l.content) {
+			return m, nil
+		}
+
+		key, value, ok := splitMappingEntry(l.content)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", l.num, l.content)
+		}
+		if _, exists := m[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+
+		if value != "" {
+			v, err := p.parseValue(value, l.num, indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		// A sequence may be indented at the same level as its key.
+		if next, ok := p.current(); ok && next.indent == indent && isSequenceItem(next.content) {
+			v, err := p.parseSequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		v, err := p.parseNested(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+}
+
+// parseNested parses the block following a line at indent, or returns nil
+// if the next line is not indented any further.
+func (p *yamlParser) parseNested(indent int) (interface{}, error) {
+	next, ok := p.current()
+	if !ok || next.indent <= indent {
+		return nil, nil
+	}
+	return p.parseBlock(next.indent)
+}
+
+func (p *yamlParser) parseValue(value string, num, indent int) (interface{}, error) {
+	if blockScalarRegexp.MatchString(value) {
+		return p.parseBlockScalar(value, indent), nil
+	}
+	if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+		return nil, fmt.Errorf("line %d: unsupported block scalar header %q", num, value)
+	}
+	return parseFlow(value, num)
+}
+
+// parseBlockScalar collects the lines indented further than indent. Comments
+// are part of the text here, so lines are read without stripping them.
+func (p *yamlParser) parseBlockScalar(style string, indent int) string {
+	lines := []string{}
+	blockIndent := -1
+
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.content != "" {
+			if l.indent <= indent {
+				break
+			}
+			if blockIndent < 0 {
+				blockIndent = l.indent
+			}
+		}
+		if l.content == "" || blockIndent < 0 {
+			lines = append(lines, "")
+		} else {
+			lines = append(lines, strings.Repeat(" ", l.indent-blockIndent)+l.content)
+		}
+		p.pos++
+	}
+
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if strings.HasPrefix(style, ">") {
+		text = foldLines(lines)
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	// Strip (-) drops the final line break, keep (+) also keeps the blank
+	// lines at the end.
+	if !strings.HasSuffix(style, "-") && text != "" {
+		text += "\n"
+	}
+	if strings.HasSuffix(style, "+") {
+		text += strings.Repeat("\n", trailing)
+	}
+
+	return text
+}
+
+func foldLines(lines []string) string {
+	var b bytes.Buffer
+	// Lines are joined with spaces, and each blank line between them is a
+	// line break.
+	for i, line := range lines {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		if i > 0 && lines[i-1] != "" {
+			b.WriteString(" ")
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// stripComment removes a trailing comment, taking care not to cut quoted
+// strings in half.
+func stripComment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && startsToken(s, i):
+			quote = r
+		case r == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
+
+// startsToken reports whether s[i] is at the start of a scalar, where a
+// quote opens a quoted string rather than being part of a plain one.
+func startsToken(s string, i int) bool {
+	return i == 0 || strings.ContainsRune(" [{,:", rune(s[i-1]))
+}
+
+// splitMappingEntry splits "key: value" into its parts. The key may be
+// quoted, in which case a colon inside it does not count.
+func splitMappingEntry(s string) (string, string, bool) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		return "", "", false
+	}
+
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && i == 0:
+			quote = r
+		case r == ':' && (i == len(s)-1 || s[i+1] == ' '):
+			key, err := parseScalar(strings.TrimSpace(s[:i]), 0)
+			if err != nil {
+				return "", "", false
+			}
+			return fmt.Sprint(key), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+
+	return "", "", false
+}
+
+// parseFlow parses a scalar or a single line flow collection.
+func parseFlow(s string, num int) (interface{}, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "[") {
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence %q", num, s)
+		}
+		items, err := splitFlow(s[1:len(s)-1], num)
+		if err != nil {
+			return nil, err
+		}
+		seq := []interface{}{}
+		for _, item := range items {
+			v, err := parseFlow(item, num)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+		}
+		return seq, nil
+	}
+
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("line %d: unterminated flow mapping %q", num, s)
+		}
+		items, err := splitFlow(s[1:len(s)-1], num)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]interface{}{}
+		for _, item := range items {
+			key, value, ok := splitMappingEntry(item)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\" in flow mapping, got %q", num, item)
+			}
+			v, err := parseFlow(value, num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	}
+
+	return parseScalar(s, num)
+}
+
+// splitFlow splits the inside of a flow collection on the commas which are
+// not nested in another collection or a quoted string.
+func splitFlow(s string, num int) ([]string, error) {
+	items := []string{}
+	depth := 0
+	start := 0
+	var quote rune
+
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && startsToken(s, i):
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	if quote != 0 || depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced flow collection %q", num, s)
+	}
+
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+
+	return items, nil
+}
+
+func parseScalar(s string, num int) (interface{}, error) {
+	switch {
+	case s == "" || s == "~" || s == "null":
+		return nil, nil
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case strings.HasPrefix(s, "\""):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid double quoted string %s", num, s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("line %d: invalid single quoted string %s", num, s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "!"):
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported, quote %s if it is a string", num, s)
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+
+	if floatRegexp.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,160 @@
+package mcnyaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+type (
+	mapping  = map[string]interface{}
+	sequence = []interface{}
+)
+
+func TestParseBlockScalars(t *testing.T) {
+	v, err := Parse([]byte("literal: |\n  line one\n    # not a comment\n\nfolded: >-\n  a\n  b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := v.(map[string]interface{})
+	if m["literal"] != "line one\n  # not a comment\n" {
+		t.Fatalf("Unexpected literal block: %q", m["literal"])
+	}
+	if m["folded"] != "a b" {
+		t.Fatalf("Unexpected folded block: %q", m["folded"])
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"empty", "", nil},
+		{"document start", "---\na: 1\n", mapping{"a": int64(1)}},
+		{"empty value", "a:\n", mapping{"a": nil}},
+		{"plain scalar document", "a b\n", "a b"},
+		{
+			"scalars",
+			"a: true\nb: false\nc: ~\nd: null\ne: 1.5\nf: -3\ng: 1e3\nh: '1'\ni: no\nj: 0x10\n",
+			mapping{"a": true, "b": false, "c": nil, "d": nil, "e": 1.5, "f": int64(-3), "g": 1000.0, "h": "1", "i": "no", "j": "0x10"},
+		},
+		{
+			"single quotes",
+			"a: 'it''s'\nb: '#not a comment'\nc: 'x: y'\n",
+			mapping{"a": "it's", "b": "#not a comment", "c": "x: y"},
+		},
+		{
+			"double quotes",
+			`a: "tab\tline\n \"q\" \u00e9"` + "\nb: \"# not a comment\"\n",
+			mapping{"a": "tab\tline\n \"q\" é", "b": "# not a comment"},
+		},
+		{
+			"quoted keys",
+			"\"quoted key\": 1\n'k:2': 3\n",
+			mapping{"quoted key": int64(1), "k:2": int64(3)},
+		},
+		{
+			"comments",
+			"# top\na: 1 # trailing\n# between\nb:\n  # inner\n  c: 2\nd: a#b\ne: \"x\" # after quotes\n",
+			mapping{"a": int64(1), "b": mapping{"c": int64(2)}, "d": "a#b", "e": "x"},
+		},
+		{
+			"flow sequence",
+			`a: [1, two, "th,ree", 'fo]ur', [x, y], {k: v}]`,
+			mapping{"a": sequence{int64(1), "two", "th,ree", "fo]ur", sequence{"x", "y"}, mapping{"k": "v"}}},
+		},
+		{
+			"flow mapping",
+			"a: {k: v, n: 1, l: [1, 2], q: 'x, y'}",
+			mapping{"a": mapping{"k": "v", "n": int64(1), "l": sequence{int64(1), int64(2)}, "q": "x, y"}},
+		},
+		{"empty flow collections", "a: []\nb: {}\n", mapping{"a": sequence{}, "b": mapping{}}},
+		{
+			"flow collections in a sequence",
+			"- [a, b]\n- {c: d}\n",
+			sequence{sequence{"a", "b"}, mapping{"c": "d"}},
+		},
+		{
+			"nested maps",
+			"a:\n  b:\n    c:\n      - 1\n      - 2\n  e: f\n",
+			mapping{"a": mapping{"b": mapping{"c": sequence{int64(1), int64(2)}}, "e": "f"}},
+		},
+		{
+			"sequence at the indentation of its key",
+			"a:\n- 1\n- 2\nb: 3\n",
+			mapping{"a": sequence{int64(1), int64(2)}, "b": int64(3)},
+		},
+		{
+			"mappings in a sequence",
+			"- a\n- b: c\n  d: e\n-\n  f: g\n",
+			sequence{"a", mapping{"b": "c", "d": "e"}, mapping{"f": "g"}},
+		},
+		{
+			"nested sequences",
+			"a:\n  - b: 1\n    c: [2]\n  - - x\n    - - y\n      - z\n",
+			mapping{"a": sequence{mapping{"b": int64(1), "c": sequence{int64(2)}}, sequence{"x", sequence{"y", "z"}}}},
+		},
+		{
+			"literal block",
+			"a: |\n  x\n    # not a comment\n\nb: 1\n",
+			mapping{"a": "x\n  # not a comment\n", "b": int64(1)},
+		},
+		{
+			"folded block",
+			"a: >\n  x\n  y\n\n  z\n\n\n  w\n",
+			mapping{"a": "x y\nz\n\nw\n"},
+		},
+		{
+			"block chomping",
+			"strip: |-\n  x\n\nclip: |\n  x\n\nkeep: |+\n  x\n\n",
+			mapping{"strip": "x", "clip": "x\n", "keep": "x\n\n"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := Parse([]byte(test.in))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%s: expected %#v, got %#v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs are not allowed for indentation"},
+		{"over indented key", "a:\n  b: 1\n   c: 2\n", `line 3: unexpected content "c: 2"`},
+		{"under indented key", "a:\n    b: 1\n  c: 2\n", `line 3: unexpected content "c: 2"`},
+		{"indented after a scalar", "a: 1\n  b: 2\n", `line 2: unexpected content "b: 2"`},
+		{"mapping after a sequence", "- a\nb: c\n", `line 2: unexpected content "b: c"`},
+		{"not a mapping entry", "a: 1\nb\n", `line 2: expected "key: value", got "b"`},
+		{"duplicate key", "a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"unterminated flow sequence", "a: [1, 2\n", `line 1: unterminated flow sequence "[1, 2"`},
+		{"unterminated flow mapping", "a: {k: v\n", `line 1: unterminated flow mapping "{k: v"`},
+		{"unbalanced flow collection", "a: [1, 2]]\n", `line 1: unbalanced flow collection "1, 2]"`},
+		{"unquoted flow string", "a: [\"x]\n", `line 1: unbalanced flow collection "\"x"`},
+		{"flow mapping without value", "a: {k}\n", `line 1: expected "key: value" in flow mapping, got "k"`},
+		{"unterminated single quotes", "a: 'x\n", "line 1: invalid single quoted string 'x"},
+		{"bad escape", `a: "\q"` + "\n", `line 1: invalid double quoted string "\q"`},
+		{"block indentation indicator", "a: |2\n  x\n", `line 1: unsupported block scalar header "|2"`},
+		{"anchor", "a: &x 1\n", "line 1: anchors, aliases and tags are not supported, quote &x 1 if it is a string"},
+		{"alias", "a: *x\n", "line 1: anchors, aliases and tags are not supported, quote *x if it is a string"},
+		{"tag", "a: !!str 1\n", "line 1: anchors, aliases and tags are not supported, quote !!str 1 if it is a string"},
+		{"multiple documents", "a: 1\n---\nb: 2\n", "line 2: multiple documents are not supported"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.in))
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
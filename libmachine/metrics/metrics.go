@@ -0,0 +1,241 @@
+// Package metrics gathers gauges about machines and writes them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// States are the machine states reported by the state gauge, so that every
+// machine has a series for each of them and alerts can match on a value of
+// 0 as well as 1.
+var States = []state.State{
+	state.Running,
+	state.Paused,
+	state.Saved,
+	state.Stopped,
+	state.Stopping,
+	state.Starting,
+	state.Error,
+	state.Timeout,
+}
+
+// buildTimeLayouts are the formats engines have reported their build time
+// in.
+var buildTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.UnixDate,
+}
+
+// Machine holds what is known about a single machine. Fields which could not
+// be found out are left at their zero value and their gauges are omitted.
+type Machine struct {
+	Name          string
+	Driver        string
+	State         state.State
+	EngineVersion string
+	EngineBuilt   time.Time
+	CertExpiry    time.Time
+	ProvisionedAt time.Time
+}
+
+// Collect gathers the metrics of a machine. The engine is only asked for its
+// version when the machine is running.
+func Collect(h *host.Host) Machine {
+	m := Machine{
+		Name:          h.Name,
+		Driver:        h.DriverName,
+		ProvisionedAt: h.ProvisionedAt,
+	}
+
+	s, err := h.Driver.GetState()
+	if err != nil {
+		log.Debugf("(%s) Error getting state for metrics: %s", h.Name, err)
+		s = state.Error
+	}
+	m.State = s
+
+	if h.HostOptions != nil && h.HostOptions.AuthOptions != nil {
+		if expiry, err := cert.ReadCertificateExpiry(h.HostOptions.AuthOptions.ServerCertPath); err == nil {
+			m.CertExpiry = expiry
+		} else {
+			log.Debugf("(%s) Error reading server certificate for metrics: %s", h.Name, err)
+		}
+	}
+
+	if m.State == state.Running && h.DriverName != "none" {
+		out, err := h.RunSSHCommand(h.Driver.SSHSudo("docker version --format '{{.Server.Version}}|{{.Server.BuildTime}}'"))
+		if err != nil {
+			log.Debugf("(%s) Error getting engine version for metrics: %s", h.Name, err)
+		} else {
+			m.EngineVersion, m.EngineBuilt = parseEngineVersion(out)
+		}
+	}
+
+	return m
+}
+
+func parseEngineVersion(out string) (string, time.Time) {
+	parts := strings.SplitN(strings.TrimSpace(out), "|", 2)
+	if len(parts) != 2 {
+		return parts[0], time.Time{}
+	}
+
+	for _, layout := range buildTimeLayouts {
+		if built, err := time.Parse(layout, parts[1]); err == nil {
+			return parts[0], built
+		}
+	}
+
+	return parts[0], time.Time{}
+}
+
+type metric struct {
+	name string
+	help string
+	// values returns the samples of the metric for a machine, none if it
+	// does not apply to it.
+	values func(m Machine, now time.Time) []sample
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+var metrics = []metric{
+	{
+		name: "docker_machine_state",
+		help: "Whether the machine is in the given state.",
+		values: func(m Machine, now time.Time) []sample {
+			samples := []sample{}
+			for _, s := range States {
+				value := 0.0
+				if s == m.State {
+					value = 1
+				}
+				samples = append(samples, sample{
+					labels: map[string]string{"state": s.String()},
+					value:  value,
+				})
+			}
+			return samples
+		},
+	},
+	{
+		name: "docker_machine_engine_version_age_seconds",
+		help: "Time since the engine running on the machine was built.",
+		values: func(m Machine, now time.Time) []sample {
+			if m.EngineBuilt.IsZero() {
+				return nil
+			}
+			return []sample{{
+				labels: map[string]string{"version": m.EngineVersion},
+				value:  now.Sub(m.EngineBuilt).Seconds(),
+			}}
+		},
+	},
+	{
+		name: "docker_machine_cert_expiry_days",
+		help: "Days until the server certificate of the machine expires.",
+		values: func(m Machine, now time.Time) []sample {
+			if m.CertExpiry.IsZero() {
+				return nil
+			}
+			return []sample{{value: m.CertExpiry.Sub(now).Hours() / 24}}
+		},
+	},
+	{
+		name: "docker_machine_last_provision_timestamp_seconds",
+		help: "When the machine was last provisioned successfully, as a Unix timestamp.",
+		values: func(m Machine, now time.Time) []sample {
+			if m.ProvisionedAt.IsZero() {
+				return nil
+			}
+			return []sample{{value: float64(m.ProvisionedAt.Unix())}}
+		},
+	},
+}
+
+// Write writes the metrics of the machines in the Prometheus text format.
+func Write(w io.Writer, machines []Machine, now time.Time) error {
+	sorted := make([]Machine, len(machines))
+	copy(sorted, machines)
+	sort.Sort(byName(sorted))
+
+	for _, metric := range metrics {
+		samples := []Sample{}
+		for _, m := range sorted {
+			for _, s := range metric.values(m, now) {
+				labels := map[string]string{
+					"machine": m.Name,
+					"driver":  m.Driver,
+				}
+				for k, v := range s.labels {
+					labels[k] = v
+				}
+				samples = append(samples, Sample{Labels: labels, Value: s.value})
+			}
+		}
+
+		if err := WriteFamily(w, metric.name, "gauge", metric.help, samples); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sample is a value of a metric, with its labels.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// WriteFamily writes the samples of the metric name, of the Prometheus type
+// typ, like gauge or counter, in the Prometheus text format.
+func WriteFamily(w io.Writer, name, typ, help string, samples []Sample) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ); err != nil {
+		return err
+	}
+
+	for _, s := range samples {
+		if _, err := fmt.Fprintf(w, "%s{%s} %g\n", name, formatLabels(s.Labels), s.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := []string{}
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", k, labelEscaper.Replace(labels[k])))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type byName []Machine
+
+func (m byName) Len() int           { return len(m) }
+func (m byName) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byName) Less(i, j int) bool { return m[i].Name < m[j].Name }
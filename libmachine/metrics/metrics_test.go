@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/state"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	machines := []Machine{
+		{
+			Name:          "web",
+			Driver:        "virtualbox",
+			State:         state.Running,
+			EngineVersion: "1.10.2",
+			EngineBuilt:   now.Add(-time.Hour),
+			CertExpiry:    now.Add(48 * time.Hour),
+			ProvisionedAt: time.Unix(1456000000, 0),
+		},
+		{
+			Name:   "db",
+			Driver: "none",
+			State:  state.Stopped,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, machines, now); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE docker_machine_state gauge",
+		`docker_machine_state{driver="none",machine="db",state="Stopped"} 1`,
+		`docker_machine_state{driver="none",machine="db",state="Running"} 0`,
+		`docker_machine_state{driver="virtualbox",machine="web",state="Running"} 1`,
+		`docker_machine_engine_version_age_seconds{driver="virtualbox",machine="web",version="1.10.2"} 3600`,
+		`docker_machine_cert_expiry_days{driver="virtualbox",machine="web"} 2`,
+		`docker_machine_last_provision_timestamp_seconds{driver="virtualbox",machine="web"} 1.456e+09`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("Expected %q in the output:\n%s", line, out)
+		}
+	}
+
+	if strings.Contains(out, `docker_machine_cert_expiry_days{driver="none"`) {
+		t.Fatalf("Expected no certificate expiry for a machine without certificates:\n%s", out)
+	}
+}
+
+func TestFormatLabelsEscapes(t *testing.T) {
+	labels := formatLabels(map[string]string{"machine": "a\"b\\c"})
+	if labels != `machine="a\"b\\c"` {
+		t.Fatalf("Unexpected labels: %s", labels)
+	}
+}
+
+func TestParseEngineVersion(t *testing.T) {
+	version, built := parseEngineVersion("1.10.2|2016-02-22T21:37:01.910365059+00:00\n")
+	if version != "1.10.2" || built.IsZero() {
+		t.Fatalf("Unexpected version %q built %s", version, built)
+	}
+
+	version, built = parseEngineVersion("1.9.1|Fri Nov 20 13:12:04 UTC 2015")
+	if version != "1.9.1" || built.Year() != 2015 {
+		t.Fatalf("Unexpected version %q built %s", version, built)
+	}
+
+	version, built = parseEngineVersion("1.9.1|")
+	if version != "1.9.1" || !built.IsZero() {
+		t.Fatalf("Unexpected version %q built %s", version, built)
+	}
+}
@@ -0,0 +1,208 @@
+// Package nfs exports local directories over the NFS server of the local
+// host for machines to mount them, in a block of the exports file per
+// machine.
+package nfs
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultExportsPath is the exports file of the NFS server.
+const DefaultExportsPath = "/etc/exports"
+
+var (
+	// ErrUnsupported is returned on the OSes whose NFS server isn't
+	// configured with an exports file.
+	ErrUnsupported = fmt.Errorf("Error: Exporting directories over NFS isn't supported on %s", runtime.GOOS)
+
+	errNoMachineIP = errors.New("Error: The IP the shares are exported to is missing")
+
+	// pathPattern matches the paths which can be written as they are in the
+	// exports file, the fstab of a machine and the commands mounting them.
+	pathPattern = regexp.MustCompile(`^[^\s'"|\\]+$`)
+
+	// goos is the OS whose exports file syntax and NFS server are used.
+	goos = runtime.GOOS
+
+	// reload has the NFS server read the exports file again.
+	reload = reloadServer
+
+	// mu serializes the updates of the exports file.
+	mu sync.Mutex
+)
+
+// Share is a local directory exported to a machine, which mounts it.
+type Share struct {
+	// Path is the absolute path of the local directory
+	Path string
+
+	// MountPoint is where the machine mounts the directory
+	MountPoint string
+
+	// HostIP is the IP of the local host as seen by the machine
+	HostIP string
+}
+
+// Source is what the machine mounts, like 192.168.99.1:/home/me/src.
+func (s Share) Source() string {
+	return s.HostIP + ":" + s.Path
+}
+
+// Validate returns an error when the share can't be exported and mounted.
+func (s Share) Validate() error {
+	for _, path := range []string{s.Path, s.MountPoint} {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("Error: %q isn't an absolute path", path)
+		}
+		if !pathPattern.MatchString(path) {
+			return fmt.Errorf("Error: %q can't be shared over NFS, its path has whitespace, quotes, backslashes or pipes", path)
+		}
+	}
+	return nil
+}
+
+// Exports is an exports file of the NFS server, in which the machines of a
+// store have their own blocks.
+type Exports struct {
+	Path string
+
+	// Store is the directory of the store the machines belong to. The
+	// blocks of machines of the same name in other stores are left alone.
+	Store string
+}
+
+// Set exports the shares of the machine to its IP, replacing what was
+// exported to it before, and reloads the NFS server. No shares remove the
+// block of the machine.
+func (e Exports) Set(machine, machineIP string, shares []Share) error {
+	if goos != "linux" && goos != "darwin" {
+		return ErrUnsupported
+	}
+	if len(shares) > 0 && machineIP == "" {
+		return errNoMachineIP
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := ioutil.ReadFile(e.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	updated := withBlock(data, e.Store, machine, exportLines(goos, os.Getuid(), os.Getgid(), machineIP, shares))
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+
+	if err := writeFile(e.Path, updated); err != nil {
+		return fmt.Errorf("Error writing %s: %s", e.Path, err)
+	}
+
+	return reload()
+}
+
+// Remove removes the block of the machine and reloads the NFS server.
+func (e Exports) Remove(machine string) error {
+	return e.Set(machine, "", nil)
+}
+
+// exportLines returns the lines exporting the shares to the machine IP in
+// the exports file syntax of the OS. The files are read and written as the
+// user the shares belong to, whichever user the machine uses them as.
+func exportLines(goos string, uid, gid int, machineIP string, shares []Share) []string {
+	lines := []string{}
+	for _, s := range shares {
+		switch goos {
+		case "darwin":
+			lines = append(lines, fmt.Sprintf("%q -alldirs -mapall=%d:%d %s", s.Path, uid, gid, machineIP))
+		default:
+			lines = append(lines, fmt.Sprintf("%q %s(rw,sync,no_subtree_check,all_squash,anonuid=%d,anongid=%d)", s.Path, machineIP, uid, gid))
+		}
+	}
+	return lines
+}
+
+// markers returns the lines the block of the machine of the store begins and
+// ends with. The store is identified by a hash of its directory, which may
+// have spaces.
+func markers(store, machine string) (string, string) {
+	id := fmt.Sprintf("%x", sha1.Sum([]byte(store)))[:12]
+	return "# BEGIN docker-machine " + id + " " + machine, "# END docker-machine " + id + " " + machine
+}
+
+// withBlock returns data with the block of the machine of the store replaced
+// with lines, appended if it had none, or removed when there are no lines.
+func withBlock(data []byte, store, machine string, lines []string) []byte {
+	begin, end := markers(store, machine)
+
+	var buf bytes.Buffer
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		switch {
+		case strings.TrimSpace(line) == begin:
+			inBlock = true
+		case inBlock:
+			inBlock = strings.TrimSpace(line) != end
+		case line != "" || buf.Len() > 0:
+			fmt.Fprintln(&buf, line)
+		}
+	}
+
+	if len(lines) > 0 {
+		fmt.Fprintln(&buf, begin)
+		for _, line := range lines {
+			fmt.Fprintln(&buf, line)
+		}
+		fmt.Fprintln(&buf, end)
+	}
+
+	return buf.Bytes()
+}
+
+// writeFile writes the exports file, with sudo when it belongs to root.
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode()
+	}
+
+	err := ioutil.WriteFile(path, data, mode)
+	if !os.IsPermission(err) || os.Geteuid() == 0 {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sudo", "tee", path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func reloadServer() error {
+	args := []string{"exportfs", "-ra"}
+	if goos == "darwin" {
+		args = []string{"nfsd", "restart"}
+	}
+	if os.Geteuid() != 0 {
+		args = append([]string{"sudo"}, args...)
+	}
+
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Error reloading the NFS server with %s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package nfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const systemExports = `/srv/nfs 10.0.0.0/8(ro)
+`
+
+func testExports(t *testing.T, content string) (Exports, *int, func()) {
+	dir, err := ioutil.TempDir("", "machine-nfs-")
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "exports")
+	if content != "" {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	reloads := 0
+	reload = func() error {
+		reloads++
+		return nil
+	}
+	goos = "linux"
+
+	return Exports{Path: path, Store: "/home/me/.docker/machine"}, &reloads, func() {
+		reload = reloadServer
+		goos = runtime.GOOS
+		os.RemoveAll(dir)
+	}
+}
+
+func read(t *testing.T, e Exports) string {
+	data, err := ioutil.ReadFile(e.Path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestExportLines(t *testing.T) {
+	shares := []Share{{Path: "/home/me/src", MountPoint: "/home/me/src", HostIP: "192.168.99.1"}}
+
+	assert.Equal(t, []string{`"/home/me/src" 192.168.99.100(rw,sync,no_subtree_check,all_squash,anonuid=1000,anongid=100)`},
+		exportLines("linux", 1000, 100, "192.168.99.100", shares))
+	assert.Equal(t, []string{`"/home/me/src" -alldirs -mapall=501:20 192.168.99.100`},
+		exportLines("darwin", 501, 20, "192.168.99.100", shares))
+}
+
+func TestSet(t *testing.T) {
+	e, reloads, cleanup := testExports(t, systemExports)
+	defer cleanup()
+
+	lines := exportLines("linux", os.Getuid(), os.Getgid(), "192.168.99.100", []Share{{Path: "/home/me/src"}})
+
+	begin, end := markers(e.Store, "dev")
+
+	assert.NoError(t, e.Set("dev", "192.168.99.100", []Share{{Path: "/home/me/src"}}))
+	assert.Equal(t, systemExports+begin+"\n"+lines[0]+"\n"+end+"\n", read(t, e))
+	assert.Equal(t, 1, *reloads)
+
+	assert.NoError(t, e.Set("dev", "192.168.99.100", []Share{{Path: "/home/me/src"}}))
+	assert.Equal(t, 1, *reloads, "an unchanged file isn't reloaded")
+
+	assert.NoError(t, e.Set("web", "192.168.99.101", []Share{{Path: "/home/me/www"}}))
+	assert.NoError(t, e.Remove("dev"))
+	assert.NotContains(t, read(t, e), "dev")
+	assert.Contains(t, read(t, e), " web\n")
+
+	assert.NoError(t, e.Remove("web"))
+	assert.Equal(t, systemExports, read(t, e))
+	assert.Equal(t, 4, *reloads)
+}
+
+func TestSetMissingFile(t *testing.T) {
+	e, _, cleanup := testExports(t, "")
+	defer cleanup()
+
+	assert.NoError(t, e.Set("dev", "192.168.99.100", []Share{{Path: "/home/me/src"}}))
+	begin, _ := markers(e.Store, "dev")
+	assert.Contains(t, read(t, e), begin+"\n\"/home/me/src\" 192.168.99.100(")
+}
+
+func TestSetOtherStore(t *testing.T) {
+	e, _, cleanup := testExports(t, systemExports)
+	defer cleanup()
+
+	other := Exports{Path: e.Path, Store: "/srv/ci/machine"}
+
+	assert.NoError(t, e.Set("dev", "192.168.99.100", []Share{{Path: "/home/me/src"}}))
+	assert.NoError(t, other.Set("dev", "10.0.0.5", []Share{{Path: "/srv/ci/src"}}))
+	assert.Contains(t, read(t, e), "/home/me/src")
+
+	assert.NoError(t, e.Remove("dev"))
+	assert.NotContains(t, read(t, e), "/home/me/src")
+	assert.Contains(t, read(t, e), `"/srv/ci/src" 10.0.0.5(`)
+
+	begin, _ := markers(other.Store, "dev")
+	assert.Contains(t, read(t, e), begin+"\n")
+}
+
+func TestSetWithoutMachineIP(t *testing.T) {
+	e, _, cleanup := testExports(t, systemExports)
+	defer cleanup()
+
+	assert.Equal(t, errNoMachineIP, e.Set("dev", "", []Share{{Path: "/home/me/src"}}))
+}
+
+func TestSetUnsupported(t *testing.T) {
+	e, _, cleanup := testExports(t, systemExports)
+	defer cleanup()
+
+	goos = "windows"
+	assert.Equal(t, ErrUnsupported, e.Set("dev", "192.168.99.100", []Share{{Path: `C:\src`}}))
+}
+
+func TestSource(t *testing.T) {
+	assert.Equal(t, "192.168.99.1:/home/me/src", Share{Path: "/home/me/src", HostIP: "192.168.99.1"}.Source())
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Share{Path: "/home/me/src", MountPoint: "/src"}.Validate())
+	assert.Error(t, Share{Path: "src", MountPoint: "/src"}.Validate())
+	assert.Error(t, Share{Path: "/home/me/my src", MountPoint: "/src"}.Validate())
+	assert.Error(t, Share{Path: "/home/me/src", MountPoint: "/it's"}.Validate())
+	assert.Error(t, Share{Path: "/home/me/src"}.Validate())
+}
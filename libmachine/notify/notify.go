@@ -0,0 +1,174 @@
+// Package notify tells other systems, like chatops or inventories, about the
+// lifecycle events of machines.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/net/context"
+)
+
+// The lifecycle events of machines.
+const (
+	Created     = "created"
+	Removed     = "removed"
+	Provisioned = "provisioned"
+	Error       = "error"
+	Drifted     = "drifted"
+	Corrected   = "corrected"
+	Expired     = "expired"
+)
+
+var (
+	// Events are the lifecycle events sinks can be notified of.
+	Events = []string{Created, Removed, Provisioned, Error, Drifted, Corrected, Expired}
+
+	webhookTimeout = 10 * time.Second
+	execTimeout    = 30 * time.Second
+)
+
+// Event is a lifecycle event of a machine, sent to the sinks as JSON.
+type Event struct {
+	Event   string    `json:"event"`
+	Machine string    `json:"machine"`
+	Driver  string    `json:"driver,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Drift   string    `json:"drift,omitempty"`
+}
+
+// Command is the command line of an exec sink. It is given either as the
+// path of a program, or as a list of the program and its arguments.
+type Command []string
+
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var program string
+	if err := json.Unmarshal(data, &program); err == nil {
+		*c = Command{program}
+		return nil
+	}
+
+	var args []string
+	if err := json.Unmarshal(data, &args); err != nil {
+		return errors.New("expected exec to be a program or a list of a program and its arguments")
+	}
+	*c = Command(args)
+	return nil
+}
+
+// Sink is where events are sent: an HTTP webhook the event is POSTed to, or
+// a local command the event is given to on its standard input, with the
+// MACHINE_EVENT and MACHINE_NAME environment variables set.
+type Sink struct {
+	Webhook string  `json:"webhook,omitempty"`
+	Exec    Command `json:"exec,omitempty"`
+
+	// Events are the events sent to the sink, all of them when empty
+	Events []string `json:"events,omitempty"`
+}
+
+// Validate checks that the sink is either a webhook or a command, of known
+// events.
+func (s Sink) Validate() error {
+	if (s.Webhook == "") == (len(s.Exec) == 0) {
+		return errors.New("expected a sink to have either a webhook or an exec")
+	}
+
+	for _, event := range s.Events {
+		if !isEvent(event) {
+			return fmt.Errorf("unknown event %q, expected one of %v", event, Events)
+		}
+	}
+
+	return nil
+}
+
+// Wants reports whether the event is sent to the sink.
+func (s Sink) Wants(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify sends the event to the sink.
+func (s Sink) Notify(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if s.Webhook != "" {
+		return s.post(payload)
+	}
+
+	return s.exec(payload, event)
+}
+
+func (s Sink) post(payload []byte) error {
+	client := &http.Client{Timeout: webhookTimeout}
+
+	resp, err := client.Post(s.Webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("Error notifying %s: %s", s.Webhook, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Error notifying %s: %s", s.Webhook, resp.Status)
+	}
+
+	return nil
+}
+
+func (s Sink) exec(payload []byte, event Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.Exec[0], s.Exec[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "MACHINE_EVENT="+event.Event, "MACHINE_NAME="+event.Machine)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Error notifying %s: %s: %s", s.Exec[0], err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// Send sends the event to the sinks wanting it, one after the other. Failing
+// to notify a sink only warns, the event having happened anyway.
+func Send(sinks []Sink, event Event) {
+	for _, sink := range sinks {
+		if !sink.Wants(event.Event) {
+			continue
+		}
+		if err := sink.Notify(event); err != nil {
+			log.Warnf("%s", err)
+		}
+	}
+}
+
+func isEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = Event{
+	Event:   Created,
+	Machine: "web",
+	Driver:  "amazonec2",
+	IP:      "203.0.113.10",
+	Time:    time.Date(2026, 3, 4, 9, 12, 40, 0, time.UTC),
+	User:    "alice",
+}
+
+func TestWebhook(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		if received.Event == Error {
+			http.Error(w, "nope", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	sink := Sink{Webhook: server.URL}
+
+	assert.NoError(t, sink.Notify(testEvent))
+	assert.Equal(t, testEvent, received)
+
+	failed := testEvent
+	failed.Event = Error
+	assert.EqualError(t, sink.Notify(failed), "Error notifying "+server.URL+": 500 Internal Server Error")
+}
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-notify")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "event")
+	sink := Sink{Exec: Command{"sh", "-c", `echo "$MACHINE_EVENT $MACHINE_NAME" > ` + out + `; cat >> ` + out}}
+
+	assert.NoError(t, sink.Notify(testEvent))
+
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	payload, _ := json.Marshal(testEvent)
+	assert.Equal(t, "created web\n"+string(payload), string(data))
+
+	sink = Sink{Exec: Command{"sh", "-c", "echo inventory is down; exit 3"}}
+	assert.EqualError(t, sink.Notify(testEvent), "Error notifying sh: exit status 3: inventory is down")
+}
+
+func TestSend(t *testing.T) {
+	events := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e.Event)
+	}))
+	defer server.Close()
+
+	sinks := []Sink{
+		{Webhook: server.URL, Events: []string{Removed}},
+		{Webhook: server.URL},
+		{Webhook: "http://127.0.0.1:0/unreachable"},
+	}
+
+	Send(sinks, testEvent)
+
+	assert.Equal(t, []string{Created}, events)
+}
+
+func TestSinkValidate(t *testing.T) {
+	assert.NoError(t, Sink{Webhook: "https://hooks.example.com", Events: []string{Created, Error}}.Validate())
+	assert.NoError(t, Sink{Exec: Command{"/usr/local/bin/inventory"}}.Validate())
+
+	assert.EqualError(t, Sink{}.Validate(), "expected a sink to have either a webhook or an exec")
+	assert.EqualError(t, Sink{Webhook: "https://hooks.example.com", Exec: Command{"true"}}.Validate(), "expected a sink to have either a webhook or an exec")
+	assert.EqualError(t, Sink{Webhook: "https://hooks.example.com", Events: []string{"started"}}.Validate(), `unknown event "started", expected one of [created removed provisioned error drifted corrected expired]`)
+}
+
+func TestCommandUnmarshal(t *testing.T) {
+	var sinks []Sink
+	assert.NoError(t, json.Unmarshal([]byte(`[{"exec": "/usr/local/bin/inventory"}, {"exec": ["logger", "-t", "machine"]}]`), &sinks))
+	assert.Equal(t, Command{"/usr/local/bin/inventory"}, sinks[0].Exec)
+	assert.Equal(t, Command{"logger", "-t", "machine"}, sinks[1].Exec)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"exec": 3}`), &Sink{}), "expected exec to be a program or a list of a program and its arguments")
+}
@@ -1,6 +1,8 @@
 package persist
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -85,6 +87,36 @@ func (s Filestore) List() ([]*host.Host, error) {
 	return hosts, nil
 }
 
+// ID returns the ID of the store, generating it the first time. The cloud
+// resources of its machines are tagged with it, for gc not to collect those
+// of the machines of other stores sharing the account.
+func (s Filestore) ID() (string, error) {
+	path := filepath.Join(s.Path, "store-id")
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return "", err
+	}
+	if err := s.saveToFile([]byte(id+"\n"), path); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
 func (s Filestore) Exists(name string) (bool, error) {
 	_, err := os.Stat(filepath.Join(s.getMachinesDir(), name))
 
@@ -183,3 +183,37 @@ func TestStoreLoad(t *testing.T) {
 		t.Fatalf("GetURL is not %q, got %q", expectedURL, actualURL)
 	}
 }
+
+func TestStoreID(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	id, err := store.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 32 {
+		t.Fatalf("expected an ID of 32 hex digits, got %q", id)
+	}
+
+	again, err := store.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Fatalf("expected the ID %q to be kept, got %q", id, again)
+	}
+
+	other := getTestStore()
+	defer os.RemoveAll(other.Path)
+
+	otherID, err := other.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherID == id {
+		t.Fatal("expected stores to have different IDs")
+	}
+}
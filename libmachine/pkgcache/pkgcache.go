@@ -0,0 +1,269 @@
+// Package pkgcache is an HTTP proxy caching the packages the package managers
+// of machines download, for the machines created together to download the
+// engine and its dependencies once.
+package pkgcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	// packageExtensions are the extensions of the files which are cached,
+	// the packages. The indexes of the repositories change, so they are
+	// always downloaded.
+	packageExtensions = []string{".deb", ".udeb", ".rpm", ".apk", ".pkg.tar.xz", ".pkg.tar.zst"}
+
+	// DefaultHTTPSHosts are the hosts whose repositories are only served
+	// over HTTPS, which the proxy downloads from over HTTPS for the package
+	// managers to get them over HTTP, and the proxy to cache them.
+	DefaultHTTPSHosts = []string{"download.docker.com"}
+
+	dialTimeout = 30 * time.Second
+)
+
+// Proxy is an HTTP proxy caching the packages in Dir. The other requests
+// go through it uncached, like the ones tunneled for HTTPS.
+type Proxy struct {
+	Dir string
+
+	// HTTPSHosts are the hosts the proxy downloads from over HTTPS the
+	// files requested over HTTP.
+	HTTPSHosts []string
+
+	// Transport sends the requests upstream, http.DefaultTransport when
+	// not set.
+	Transport http.RoundTripper
+
+	hits, misses int64
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewProxy returns a proxy caching the packages in dir.
+func NewProxy(dir string) *Proxy {
+	return &Proxy{
+		Dir:        dir,
+		HTTPSHosts: DefaultHTTPSHosts,
+	}
+}
+
+// Stats returns how many packages were served from the cache, and how many
+// were downloaded.
+func (p *Proxy) Stats() (hits, misses int) {
+	return int(atomic.LoadInt64(&p.hits)), int(atomic.LoadInt64(&p.misses))
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "docker-machine package cache: only proxy requests are served", http.StatusBadRequest)
+		return
+	}
+
+	upstream := *r.URL
+	for _, host := range p.HTTPSHosts {
+		if upstream.Scheme == "http" && upstream.Host == host {
+			upstream.Scheme = "https"
+		}
+	}
+
+	if r.Method == http.MethodGet && isPackage(upstream.Path) {
+		p.serveCached(w, r, upstream.String())
+		return
+	}
+
+	p.forward(w, r, upstream.String())
+}
+
+// serveCached serves the package at url from the cache, downloading it
+// first if it isn't. The machines requesting the same package at the same
+// time wait for it to be downloaded once.
+func (p *Proxy) serveCached(w http.ResponseWriter, r *http.Request, url string) {
+	key := cacheKey(url)
+	path := filepath.Join(p.Dir, key)
+
+	lock := p.lock(key)
+	lock.Lock()
+	_, err := os.Stat(path)
+	if err == nil {
+		atomic.AddInt64(&p.hits, 1)
+		log.Debugf("Package cache hit: %s", url)
+	} else {
+		atomic.AddInt64(&p.misses, 1)
+		log.Debugf("Package cache miss: %s", url)
+		err = p.download(w, url, path)
+	}
+	lock.Unlock()
+
+	if err == nil {
+		http.ServeFile(w, r, path)
+	}
+}
+
+// download downloads the package at url to the cache. The failures are
+// written to w, the errors of the upstream server being passed on.
+func (p *Proxy) download(w http.ResponseWriter, url, path string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	resp, err := p.roundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		copyResponse(w, resp)
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	if err := p.store(path, resp.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return err
+	}
+
+	return nil
+}
+
+// store writes the package to the cache, only once it was downloaded whole.
+func (p *Proxy) store(path string, body io.Reader) error {
+	if err := os.MkdirAll(p.Dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(p.Dir, ".download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, url string) {
+	req, err := http.NewRequest(r.Method, url, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name, values := range r.Header {
+		if !strings.HasPrefix(name, "Proxy-") {
+			req.Header[name] = values
+		}
+	}
+
+	resp, err := p.roundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	copyResponse(w, resp)
+}
+
+// tunnel connects the client to the host of a CONNECT request, for HTTPS
+// to go through the proxy, uncached.
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "docker-machine package cache: tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", r.Host, dialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	fmt.Fprint(client, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	go func() {
+		io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+}
+
+func (p *Proxy) roundTrip(req *http.Request) (*http.Response, error) {
+	if p.Transport == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return p.Transport.RoundTrip(req)
+}
+
+func (p *Proxy) lock(key string) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.locks == nil {
+		p.locks = map[string]*sync.Mutex{}
+	}
+	if _, ok := p.locks[key]; !ok {
+		p.locks[key] = &sync.Mutex{}
+	}
+	return p.locks[key]
+}
+
+func copyResponse(w http.ResponseWriter, resp *http.Response) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func isPackage(path string) bool {
+	for _, ext := range packageExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey is the name of the file a package is cached in, the same package
+// being downloaded from the same URL.
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:]) + filepath.Ext(url)
+}
@@ -0,0 +1,137 @@
+package pkgcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+)
+
+func newTestProxy(t *testing.T) (*Proxy, *http.Client, func()) {
+	dir, err := ioutil.TempDir("", "machine-pkgcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewProxy(dir)
+	server := httptest.NewServer(proxy)
+	proxyURL, _ := url.Parse(server.URL)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	return proxy, client, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestProxyCachesPackages(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+	)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		fmt.Fprintf(w, "content of %s", r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	proxy, client, cleanup := newTestProxy(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if body := get(t, client, upstream.URL+"/pool/docker-ce_20.10.deb"); body != "content of /pool/docker-ce_20.10.deb" {
+				t.Errorf("Expected the package, got %q", body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	get(t, client, upstream.URL+"/dists/stable/Release")
+	get(t, client, upstream.URL+"/dists/stable/Release")
+
+	if requests["/pool/docker-ce_20.10.deb"] != 1 {
+		t.Fatalf("Expected the package to be downloaded once, got %d", requests["/pool/docker-ce_20.10.deb"])
+	}
+	if requests["/dists/stable/Release"] != 2 {
+		t.Fatalf("Expected the index not to be cached, got %d downloads", requests["/dists/stable/Release"])
+	}
+
+	if hits, misses := proxy.Stats(); hits != 3 || misses != 1 {
+		t.Fatalf("Expected 3 hits and 1 miss, got %d and %d", hits, misses)
+	}
+}
+
+func TestProxyDoesNotCacheErrors(t *testing.T) {
+	downloads := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		http.NotFound(w, r)
+	}))
+	defer upstream.Close()
+
+	_, client, cleanup := newTestProxy(t)
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(upstream.URL + "/missing.rpm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected the 404 of the upstream server, got %d", resp.StatusCode)
+		}
+	}
+
+	if downloads != 2 {
+		t.Fatalf("Expected the missing package to be requested again, got %d requests", downloads)
+	}
+}
+
+type recordingTransport struct {
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.urls = append(rt.urls, req.URL.String())
+	return httptest.NewRecorder().Result(), nil
+}
+
+func TestProxyUpgradesHTTPSHosts(t *testing.T) {
+	proxy, client, cleanup := newTestProxy(t)
+	defer cleanup()
+
+	transport := &recordingTransport{}
+	proxy.Transport = transport
+
+	get(t, client, "http://download.docker.com/linux/ubuntu/dists/focal/Release")
+
+	if len(transport.urls) != 1 || transport.urls[0] != "https://download.docker.com/linux/ubuntu/dists/focal/Release" {
+		t.Fatalf("Expected the repository to be fetched over HTTPS, got %v", transport.urls)
+	}
+}
@@ -0,0 +1,71 @@
+package pricing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// HTTPProvider asks a price provider of its own for the cost of machines,
+// e.g. one knowing the prices negotiated with a cloud provider. It POSTs
+// the driver name and pricing of the machine as JSON:
+//
+//	{"DriverName": "amazonec2", "Region": "us-east-1", "InstanceType": "t2.micro",
+//	 "Spot": false, "Storage": [{"Type": "gp2", "Size": 16}]}
+//
+// and expects the hourly prices of the instance and storage in USD back:
+//
+//	{"Instance": 0.0116, "Storage": 0.0022}
+//
+// or a 404 when it doesn't know the price.
+type HTTPProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPProvider returns the provider asking the price provider at url.
+func NewHTTPProvider(url string) *HTTPProvider {
+	return &HTTPProvider{
+		URL: url,
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+type httpRequest struct {
+	DriverName string
+	drivers.Pricing
+}
+
+func (p *HTTPProvider) Estimate(driverName string, pricing drivers.Pricing) (Estimate, error) {
+	body, err := json.Marshal(httpRequest{DriverName: driverName, Pricing: pricing})
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	resp, err := p.Client.Post(p.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return Estimate{}, fmt.Errorf("Error asking %s for prices: %s", p.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Estimate{}, NoPriceError{DriverName: driverName, What: pricing.InstanceType}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Estimate{}, fmt.Errorf("Error asking %s for prices: %s", p.URL, resp.Status)
+	}
+
+	estimate := Estimate{}
+	if err := json.NewDecoder(resp.Body).Decode(&estimate); err != nil {
+		return Estimate{}, fmt.Errorf("Error reading prices from %s: %s", p.URL, err)
+	}
+	estimate.Source = p.URL
+
+	return estimate, nil
+}
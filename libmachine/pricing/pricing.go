@@ -0,0 +1,99 @@
+// Package pricing estimates what machines cost, from static price tables of
+// the drivers or from a price provider of their own.
+package pricing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// HoursPerMonth is the average number of hours in a month, which providers
+// bill monthly prices by.
+const HoursPerMonth = 730
+
+// Estimate is the estimated cost of a machine, in USD.
+type Estimate struct {
+	// Instance is the hourly price of the instance
+	Instance float64
+
+	// Storage is the hourly price of the storage billed apart from the
+	// instance, which is still billed when the machine is stopped
+	Storage float64
+
+	// Source tells where the prices come from, e.g. "static"
+	Source string
+}
+
+// Hourly returns the hourly cost of a machine in state s: the storage only
+// when the machine is stopped.
+func (e Estimate) Hourly(s state.State) float64 {
+	if s == state.Stopped {
+		return e.Storage
+	}
+	return e.Instance + e.Storage
+}
+
+// Monthly returns the monthly cost of a machine staying in state s.
+func (e Estimate) Monthly(s state.State) float64 {
+	return e.Hourly(s) * HoursPerMonth
+}
+
+// Provider estimates the cost of machines from what their price depends on.
+type Provider interface {
+	Estimate(driverName string, pricing drivers.Pricing) (Estimate, error)
+}
+
+// NoPriceError is returned by providers which don't know the price of a
+// machine.
+type NoPriceError struct {
+	DriverName string
+	What       string
+}
+
+func (e NoPriceError) Error() string {
+	return fmt.Sprintf("No price known for %s %s", e.DriverName, e.What)
+}
+
+// Chain is a provider asking each of its providers in turn, until one knows
+// the price of the machine.
+type Chain []Provider
+
+func (c Chain) Estimate(driverName string, pricing drivers.Pricing) (Estimate, error) {
+	errs := []string{}
+
+	for _, provider := range c {
+		estimate, err := provider.Estimate(driverName, pricing)
+		if err == nil {
+			return estimate, nil
+		}
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) == 0 {
+		return Estimate{}, NoPriceError{DriverName: driverName, What: pricing.InstanceType}
+	}
+
+	return Estimate{}, fmt.Errorf("%s", strings.Join(errs, ", "))
+}
+
+// NewProvider returns the provider asking the price provider at url, if
+// any, before the static price tables.
+func NewProvider(url string) Provider {
+	if url == "" {
+		return Static
+	}
+	return Chain{NewHTTPProvider(url), Static}
+}
+
+// EstimateMachine estimates the cost of a machine of the driver.
+func EstimateMachine(provider Provider, driverName string, d drivers.Driver) (Estimate, error) {
+	pricing, err := drivers.GetPricing(d)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	return provider.Estimate(driverName, pricing)
+}
@@ -0,0 +1,91 @@
+package pricing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPricing = drivers.Pricing{
+	Region:       "us-east-1",
+	InstanceType: "t2.micro",
+	Storage: []drivers.PricedStorage{
+		{Type: "gp2", Size: 73},
+	},
+}
+
+func TestStaticEstimate(t *testing.T) {
+	estimate, err := Static.Estimate("amazonec2", testPricing)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0116, estimate.Instance)
+	assert.InDelta(t, 0.01, estimate.Storage, 1e-9)
+	assert.Equal(t, "static", estimate.Source)
+
+	assert.InDelta(t, 0.0216, estimate.Hourly(state.Running), 1e-9)
+	assert.InDelta(t, 0.01, estimate.Hourly(state.Stopped), 1e-9)
+	assert.InDelta(t, 7.3, estimate.Monthly(state.Stopped), 1e-9)
+}
+
+func TestStaticEstimateUnknown(t *testing.T) {
+	_, err := Static.Estimate("virtualbox", testPricing)
+	assert.EqualError(t, err, "No price known for virtualbox machines")
+
+	pricing := testPricing
+	pricing.InstanceType = "x1.32xlarge"
+	_, err = Static.Estimate("amazonec2", pricing)
+	assert.EqualError(t, err, "No price known for amazonec2 x1.32xlarge")
+
+	pricing = testPricing
+	pricing.Storage = []drivers.PricedStorage{{Type: "io2", Size: 10}}
+	_, err = Static.Estimate("amazonec2", pricing)
+	assert.EqualError(t, err, "No price known for amazonec2 io2 storage")
+}
+
+func TestHTTPProvider(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request["InstanceType"] != "t2.micro" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Instance": 0.01, "Storage": 0.002}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL)
+
+	estimate, err := provider.Estimate("amazonec2", testPricing)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Estimate{Instance: 0.01, Storage: 0.002, Source: server.URL}, estimate)
+	assert.Equal(t, "amazonec2", request["DriverName"])
+	assert.Equal(t, "us-east-1", request["Region"])
+
+	pricing := testPricing
+	pricing.InstanceType = "m5.large"
+	_, err = provider.Estimate("amazonec2", pricing)
+	assert.EqualError(t, err, "No price known for amazonec2 m5.large")
+}
+
+func TestChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	estimate, err := NewProvider(server.URL).Estimate("amazonec2", testPricing)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "static", estimate.Source)
+
+	_, err = NewProvider(server.URL).Estimate("virtualbox", testPricing)
+
+	assert.EqualError(t, err, "No price known for virtualbox t2.micro, No price known for virtualbox machines")
+}
@@ -0,0 +1,193 @@
+package pricing
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// Table is the price table of a driver.
+type Table struct {
+	// Instances are the hourly prices of instance types
+	Instances map[string]float64
+
+	// Storage are the monthly prices of a GB of storage types
+	Storage map[string]float64
+}
+
+// StaticProvider estimates the cost of machines from the price tables of
+// their drivers, ignoring their region. Spot instances are estimated at
+// their on-demand price, which is what they cost at most.
+type StaticProvider map[string]Table
+
+// Static is the provider of the built-in price tables: the on-demand list
+// prices in USD of a region of the provider of each driver, for the common
+// instance types. They are meant to notice costly machines, not to replace
+// the bill.
+var Static = StaticProvider{
+	// us-east-1
+	"amazonec2": {
+		Instances: map[string]float64{
+			"t2.nano":    0.0058,
+			"t2.micro":   0.0116,
+			"t2.small":   0.023,
+			"t2.medium":  0.0464,
+			"t2.large":   0.0928,
+			"t3.nano":    0.0052,
+			"t3.micro":   0.0104,
+			"t3.small":   0.0208,
+			"t3.medium":  0.0416,
+			"t3.large":   0.0832,
+			"m5.large":   0.096,
+			"m5.xlarge":  0.192,
+			"m5.2xlarge": 0.384,
+			"c5.large":   0.085,
+			"c5.xlarge":  0.17,
+			"c5.2xlarge": 0.34,
+		},
+		Storage: map[string]float64{
+			"gp2":      0.10,
+			"gp3":      0.08,
+			"standard": 0.05,
+		},
+	},
+	// eastus
+	"azure": {
+		Instances: map[string]float64{
+			"Standard_B1s":    0.0104,
+			"Standard_B1ms":   0.0207,
+			"Standard_B2s":    0.0416,
+			"Standard_B2ms":   0.0832,
+			"Standard_D2s_v3": 0.096,
+			"Standard_D4s_v3": 0.192,
+			"Standard_D2_v3":  0.096,
+			"Standard_D4_v3":  0.192,
+			"Standard_A1_v2":  0.043,
+			"Standard_A2_v2":  0.091,
+		},
+		Storage: map[string]float64{
+			"Standard_LRS":    0.045,
+			"StandardSSD_LRS": 0.075,
+			"Premium_LRS":     0.15,
+		},
+	},
+	// nyc1
+	"digitalocean": {
+		Instances: map[string]float64{
+			"512mb":         0.00744,
+			"1gb":           0.01488,
+			"2gb":           0.02976,
+			"4gb":           0.05952,
+			"s-1vcpu-1gb":   0.00893,
+			"s-1vcpu-2gb":   0.01786,
+			"s-2vcpu-2gb":   0.02679,
+			"s-2vcpu-4gb":   0.03571,
+			"s-4vcpu-8gb":   0.07143,
+			"s-8vcpu-16gb":  0.14286,
+			"c-2":           0.0625,
+			"g-2vcpu-8gb":   0.09375,
+			"m-2vcpu-16gb":  0.125,
+			"so-2vcpu-16gb": 0.1939,
+		},
+		Storage: map[string]float64{
+			"volume": 0.10,
+		},
+	},
+	// sv
+	"equinixmetal": {
+		Instances: map[string]float64{
+			"c3.small.x86":  0.75,
+			"c3.medium.x86": 1.50,
+			"m3.small.x86":  1.05,
+			"m3.large.x86":  3.10,
+			"s3.xlarge.x86": 2.95,
+		},
+	},
+	// us-central1
+	"google": {
+		Instances: map[string]float64{
+			"f1-micro":      0.0076,
+			"g1-small":      0.0257,
+			"e2-micro":      0.0084,
+			"e2-small":      0.0168,
+			"e2-medium":     0.0335,
+			"e2-standard-2": 0.067,
+			"e2-standard-4": 0.134,
+			"n1-standard-1": 0.0475,
+			"n1-standard-2": 0.095,
+			"n1-standard-4": 0.19,
+			"n1-standard-8": 0.38,
+			"n2-standard-2": 0.0971,
+			"n2-standard-4": 0.1942,
+		},
+		Storage: map[string]float64{
+			"pd-standard": 0.04,
+			"pd-balanced": 0.10,
+			"pd-ssd":      0.17,
+		},
+	},
+	// fsn1
+	"hetzner": {
+		Instances: map[string]float64{
+			"cx11":  0.0058,
+			"cx21":  0.0095,
+			"cx31":  0.0173,
+			"cx41":  0.0321,
+			"cx22":  0.0071,
+			"cx32":  0.0113,
+			"cx42":  0.0273,
+			"cpx11": 0.0074,
+			"cpx21": 0.0136,
+			"cpx31": 0.0246,
+		},
+	},
+	// fr-par-1
+	"scaleway": {
+		Instances: map[string]float64{
+			"DEV1-S":  0.0088,
+			"DEV1-M":  0.0198,
+			"DEV1-L":  0.042,
+			"DEV1-XL": 0.063,
+			"GP1-XS":  0.091,
+			"GP1-S":   0.187,
+		},
+		Storage: map[string]float64{
+			"b_ssd": 0.08,
+		},
+	},
+	// ewr
+	"vultr": {
+		Instances: map[string]float64{
+			"vc2-1c-1gb":  0.007,
+			"vc2-1c-2gb":  0.015,
+			"vc2-2c-4gb":  0.03,
+			"vc2-4c-8gb":  0.06,
+			"vc2-6c-16gb": 0.119,
+		},
+	},
+}
+
+func (p StaticProvider) Estimate(driverName string, pricing drivers.Pricing) (Estimate, error) {
+	table, ok := p[driverName]
+	if !ok {
+		return Estimate{}, NoPriceError{DriverName: driverName, What: "machines"}
+	}
+
+	instance, ok := table.Instances[pricing.InstanceType]
+	if !ok {
+		return Estimate{}, NoPriceError{DriverName: driverName, What: pricing.InstanceType}
+	}
+
+	estimate := Estimate{
+		Instance: instance,
+		Source:   "static",
+	}
+
+	for _, storage := range pricing.Storage {
+		price, ok := table.Storage[storage.Type]
+		if !ok {
+			return Estimate{}, NoPriceError{DriverName: driverName, What: storage.Type + " storage"}
+		}
+		estimate.Storage += price * float64(storage.Size) / HoursPerMonth
+	}
+
+	return estimate, nil
+}
@@ -3,7 +3,6 @@ package provision
 import (
 	"bytes"
 	"fmt"
-	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -142,7 +141,7 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.SwarmOptions, a
 	}
 
 	log.Debug("Waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitDaemon, mcnutils.WaitSettings{}, provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
@@ -182,7 +181,7 @@ LimitNOFILE=1048576
 LimitNPROC=1048576
 LimitCORE=infinity
 `
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	t, err := parseTemplate(provisioner.OsReleaseInfo, engineConfigTemplateFile, engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -193,10 +192,12 @@ LimitCORE=infinity
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		EngineOptionsPath: provisioner.daemonOptionsFile(),
 	}, nil
 }
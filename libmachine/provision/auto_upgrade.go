@@ -0,0 +1,296 @@
+package provision
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// DefaultUpgradeWindow is when automatic engine upgrades run, unless told
+// otherwise.
+const DefaultUpgradeWindow = "Sun 03:00"
+
+var (
+	// autoUpgradePackages are the packages automatic engine upgrades are
+	// scoped to, the other packages of the host being left alone.
+	autoUpgradePackages = []string{"docker-engine", "docker-ce", "docker-ce-cli", "containerd.io"}
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+const (
+	autoUpgradeCronFile = "/etc/cron.d/docker-machine-upgrades"
+
+	aptUpgradeConfigFile = "/etc/apt/docker-machine-upgrades.conf"
+	aptUpgradeConfig     = `// Automatic engine upgrades of docker-machine, scoped to the engine packages
+Unattended-Upgrade::Origins-Pattern { "origin=Docker"; };
+Unattended-Upgrade::Package-Whitelist { %s };
+Unattended-Upgrade::Package-Whitelist-Strict "true";
+`
+
+	yumCronConfigFile = "/etc/yum/yum-cron-docker-machine.conf"
+	dnfAutomaticFile  = "/etc/dnf/automatic-docker-machine.conf"
+	yumUpgradeConfig  = `# Automatic engine upgrades of docker-machine, scoped to the engine packages
+[commands]
+%s = default
+download_updates = yes
+apply_updates = yes
+random_sleep = 0
+
+[emitters]
+emit_via = stdio
+
+[base]
+includepkgs = %s
+`
+
+	autoUpgradeUnit    = "docker-machine-upgrades"
+	autoUpgradeService = `[Unit]
+Description=Automatic engine upgrades of docker-machine
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`
+	autoUpgradeTimer = `[Unit]
+Description=Automatic engine upgrades of docker-machine
+
+[Timer]
+OnCalendar=%s
+
+[Install]
+WantedBy=timers.target
+`
+)
+
+// MaintenanceWindow is when automatic engine upgrades run, in the time zone
+// of the host: at a time of the day, every day or on some days of the week.
+type MaintenanceWindow struct {
+	Days   []time.Weekday
+	Hour   int
+	Minute int
+}
+
+// ParseMaintenanceWindow parses a maintenance window given as a time, like
+// "03:00", or as days and a time, like "Sun 03:00" or "Sat,Sun 02:30".
+func ParseMaintenanceWindow(s string) (MaintenanceWindow, error) {
+	window := MaintenanceWindow{}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return window, fmt.Errorf("invalid maintenance window %q, expected a time like 03:00 or days and a time like Sat,Sun 03:00", s)
+	}
+
+	if len(fields) == 2 {
+		for _, name := range strings.Split(fields[0], ",") {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return window, fmt.Errorf("invalid day %q in maintenance window %q, expected one of Sun, Mon, Tue, Wed, Thu, Fri or Sat", name, s)
+			}
+			window.Days = append(window.Days, day)
+		}
+	}
+
+	clock := strings.Split(fields[len(fields)-1], ":")
+	if len(clock) != 2 {
+		return window, fmt.Errorf("invalid time in maintenance window %q, expected HH:MM", s)
+	}
+	hour, err := strconv.Atoi(clock[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return window, fmt.Errorf("invalid hour in maintenance window %q", s)
+	}
+	minute, err := strconv.Atoi(clock[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return window, fmt.Errorf("invalid minute in maintenance window %q", s)
+	}
+	window.Hour = hour
+	window.Minute = minute
+
+	return window, nil
+}
+
+func (w MaintenanceWindow) String() string {
+	if len(w.Days) == 0 {
+		return fmt.Sprintf("every day at %02d:%02d", w.Hour, w.Minute)
+	}
+
+	days := []string{}
+	for _, day := range w.Days {
+		days = append(days, day.String()[:3])
+	}
+	return fmt.Sprintf("%s at %02d:%02d", strings.Join(days, ","), w.Hour, w.Minute)
+}
+
+// cronSpec returns the schedule of the window in the form of crontab.
+func (w MaintenanceWindow) cronSpec() string {
+	days := "*"
+	if len(w.Days) > 0 {
+		numbers := []string{}
+		for _, day := range w.Days {
+			numbers = append(numbers, strconv.Itoa(int(day)))
+		}
+		days = strings.Join(numbers, ",")
+	}
+	return fmt.Sprintf("%d %d * * %s", w.Minute, w.Hour, days)
+}
+
+// onCalendar returns the schedule of the window in the form of the
+// OnCalendar of systemd timers.
+func (w MaintenanceWindow) onCalendar() string {
+	days := ""
+	if len(w.Days) > 0 {
+		names := []string{}
+		for _, day := range w.Days {
+			names = append(names, day.String()[:3])
+		}
+		days = strings.Join(names, ",") + " "
+	}
+	return fmt.Sprintf("%s*-*-* %02d:%02d:00", days, w.Hour, w.Minute)
+}
+
+// AutoUpgrader is implemented by provisioners which can have the package
+// manager of the host upgrade the engine unattended.
+type AutoUpgrader interface {
+	ConfigureAutoUpgrades(window MaintenanceWindow) error
+}
+
+// UpgradeWindow returns the maintenance window of the automatic engine
+// upgrades of the engine options.
+func UpgradeWindow(engineOptions engine.EngineOptions) (MaintenanceWindow, error) {
+	if engineOptions.UpgradeWindow == "" {
+		return ParseMaintenanceWindow(DefaultUpgradeWindow)
+	}
+	return ParseMaintenanceWindow(engineOptions.UpgradeWindow)
+}
+
+// ConfigureAutoUpgrades has the host upgrade the engine packages unattended,
+// in the maintenance window of the engine options. Hosts whose engine isn't
+// upgraded by their package manager, like boot2docker, are skipped.
+func ConfigureAutoUpgrades(p Provisioner, engineOptions engine.EngineOptions) error {
+	window, err := UpgradeWindow(engineOptions)
+	if err != nil {
+		return err
+	}
+
+	upgrader, ok := p.(AutoUpgrader)
+	if !ok {
+		log.Warnf("Automatic engine upgrades are not supported on this OS, skipping them")
+		return nil
+	}
+
+	log.Infof("Configuring automatic engine upgrades %s...", window)
+	return upgrader.ConfigureAutoUpgrades(window)
+}
+
+// writeFile writes the content to the file at path on the host. The content
+// is sent base64 encoded, not to have to quote it.
+func writeFile(p Provisioner, path, content string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	command := fmt.Sprintf("sh -c 'echo %s | base64 -d > %s'", encoded, path)
+	_, err := p.SSHCommand(p.GetDriver().SSHSudo(command))
+	return err
+}
+
+// autoUpgradeCronEntry returns the crontab of /etc/cron.d running the command
+// in the window.
+func autoUpgradeCronEntry(window MaintenanceWindow, command string) string {
+	return fmt.Sprintf("# Automatic engine upgrades of docker-machine\nPATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin\n%s root %s\n", window.cronSpec(), command)
+}
+
+func aptAutoUpgradeConfig() string {
+	packages := []string{}
+	for _, pkg := range autoUpgradePackages {
+		packages = append(packages, fmt.Sprintf("%q;", pkg))
+	}
+	return fmt.Sprintf(aptUpgradeConfig, strings.Join(packages, " "))
+}
+
+// yumAutoUpgradeConfig returns the configuration of yum-cron, or of
+// dnf-automatic whose option naming the kind of upgrades differs.
+func yumAutoUpgradeConfig(upgradeOption string) string {
+	packages := []string{}
+	for _, pkg := range autoUpgradePackages {
+		packages = append(packages, pkg+"*")
+	}
+	return fmt.Sprintf(yumUpgradeConfig, upgradeOption, strings.Join(packages, " "))
+}
+
+// aptConfigureAutoUpgrades has unattended-upgrades upgrade the engine
+// packages from cron, with a configuration of its own not to change which
+// other packages the host upgrades.
+func aptConfigureAutoUpgrades(p Provisioner, window MaintenanceWindow) error {
+	if err := p.Package("unattended-upgrades", pkgaction.Install); err != nil {
+		return err
+	}
+
+	if err := writeFile(p, aptUpgradeConfigFile, aptAutoUpgradeConfig()); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("apt-get update -qq && APT_CONFIG=%s unattended-upgrade", aptUpgradeConfigFile)
+	return writeFile(p, autoUpgradeCronFile, autoUpgradeCronEntry(window, command))
+}
+
+func (provisioner *UbuntuProvisioner) ConfigureAutoUpgrades(window MaintenanceWindow) error {
+	return aptConfigureAutoUpgrades(provisioner, window)
+}
+
+func (provisioner *DebianProvisioner) ConfigureAutoUpgrades(window MaintenanceWindow) error {
+	return aptConfigureAutoUpgrades(provisioner, window)
+}
+
+// ConfigureAutoUpgrades has yum-cron upgrade the engine packages from cron,
+// with a configuration of its own not to change which other packages the
+// host upgrades.
+func (provisioner *RedHatProvisioner) ConfigureAutoUpgrades(window MaintenanceWindow) error {
+	if err := provisioner.Package("yum-cron", pkgaction.Install); err != nil {
+		return err
+	}
+
+	if err := writeFile(provisioner, yumCronConfigFile, yumAutoUpgradeConfig("update_cmd")); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("yum-cron %s", yumCronConfigFile)
+	return writeFile(provisioner, autoUpgradeCronFile, autoUpgradeCronEntry(window, command))
+}
+
+// ConfigureAutoUpgrades has dnf-automatic upgrade the engine packages from a
+// systemd timer, Fedora not having cron by default.
+func (provisioner *FedoraProvisioner) ConfigureAutoUpgrades(window MaintenanceWindow) error {
+	if err := provisioner.Package("dnf-automatic", pkgaction.Install); err != nil {
+		return err
+	}
+
+	if err := writeFile(provisioner, dnfAutomaticFile, yumAutoUpgradeConfig("upgrade_type")); err != nil {
+		return err
+	}
+
+	service := fmt.Sprintf(autoUpgradeService, "/usr/bin/dnf-automatic "+dnfAutomaticFile)
+	if err := writeFile(provisioner, "/etc/systemd/system/"+autoUpgradeUnit+".service", service); err != nil {
+		return err
+	}
+
+	timer := fmt.Sprintf(autoUpgradeTimer, window.onCalendar())
+	if err := writeFile(provisioner, "/etc/systemd/system/"+autoUpgradeUnit+".timer", timer); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("sh -c 'systemctl daemon-reload && systemctl enable %[1]s.timer && systemctl restart %[1]s.timer'", autoUpgradeUnit)
+	_, err := provisioner.SSHCommand(provisioner.Driver.SSHSudo(command))
+	return err
+}
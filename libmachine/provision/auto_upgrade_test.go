@@ -0,0 +1,86 @@
+package provision
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	cases := []struct {
+		window     string
+		expected   MaintenanceWindow
+		cron       string
+		onCalendar string
+	}{
+		{"03:00", MaintenanceWindow{Hour: 3}, "0 3 * * *", "*-*-* 03:00:00"},
+		{"Sun 03:30", MaintenanceWindow{Days: []time.Weekday{time.Sunday}, Hour: 3, Minute: 30}, "30 3 * * 0", "Sun *-*-* 03:30:00"},
+		{"sat,SUN 23:05", MaintenanceWindow{Days: []time.Weekday{time.Saturday, time.Sunday}, Hour: 23, Minute: 5}, "5 23 * * 6,0", "Sat,Sun *-*-* 23:05:00"},
+	}
+
+	for _, c := range cases {
+		window, err := ParseMaintenanceWindow(c.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(window, c.expected) {
+			t.Fatalf("expected %q to be %+v, got %+v", c.window, c.expected, window)
+		}
+		if cron := window.cronSpec(); cron != c.cron {
+			t.Fatalf("expected cron %q for %q, got %q", c.cron, c.window, cron)
+		}
+		if onCalendar := window.onCalendar(); onCalendar != c.onCalendar {
+			t.Fatalf("expected OnCalendar %q for %q, got %q", c.onCalendar, c.window, onCalendar)
+		}
+	}
+}
+
+func TestParseMaintenanceWindowErrors(t *testing.T) {
+	for _, window := range []string{"", "Sunday 03:00", "Sun", "24:00", "03:60", "3", "Sun 03:00 UTC"} {
+		if _, err := ParseMaintenanceWindow(window); err == nil {
+			t.Fatalf("expected %q to be invalid", window)
+		}
+	}
+}
+
+func TestUpgradeWindowDefault(t *testing.T) {
+	window, err := UpgradeWindow(engine.EngineOptions{AutoUpgrade: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.String() != "Sun at 03:00" {
+		t.Fatalf("expected the default window, got %s", window)
+	}
+}
+
+func TestAutoUpgradeConfigs(t *testing.T) {
+	apt := aptAutoUpgradeConfig()
+	if !strings.Contains(apt, `Unattended-Upgrade::Package-Whitelist { "docker-engine"; "docker-ce"; "docker-ce-cli"; "containerd.io"; };`) {
+		t.Fatalf("expected the engine packages to be whitelisted, got %s", apt)
+	}
+
+	yum := yumAutoUpgradeConfig("upgrade_type")
+	if !strings.Contains(yum, "upgrade_type = default\n") || !strings.Contains(yum, "includepkgs = docker-engine* docker-ce* docker-ce-cli* containerd.io*\n") {
+		t.Fatalf("expected the upgrades to be scoped to the engine packages, got %s", yum)
+	}
+
+	window, _ := ParseMaintenanceWindow("Sun 03:00")
+	entry := autoUpgradeCronEntry(window, "yum-cron "+yumCronConfigFile)
+	if !strings.HasSuffix(entry, "\n0 3 * * 0 root yum-cron /etc/yum/yum-cron-docker-machine.conf\n") {
+		t.Fatalf("expected a cron entry in the window, got %s", entry)
+	}
+}
+
+func TestConfigureAutoUpgradesSkipsBoot2Docker(t *testing.T) {
+	p := &Boot2DockerProvisioner{
+		Driver: &fakedriver.Driver{},
+	}
+
+	if err := ConfigureAutoUpgrades(p, engine.EngineOptions{AutoUpgrade: true}); err != nil {
+		t.Fatalf("expected boot2docker to be skipped, got %s", err)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"net"
 	"path"
-	"text/template"
+	"strconv"
 	"time"
 
 	"github.com/docker/machine/commands/mcndirs"
@@ -54,13 +54,19 @@ func (provisioner *Boot2DockerProvisioner) Service(name string, action serviceac
 }
 
 func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
+	return provisioner.upgradeIsoTo("")
+}
+
+// upgradeIsoTo replaces the ISO of the machine with the one at isoURL, or
+// with the latest release if isoURL is empty.
+func (provisioner *Boot2DockerProvisioner) upgradeIsoTo(isoURL string) error {
 	log.Info("Stopping machine to do the upgrade...")
 
 	if err := provisioner.Driver.Stop(); err != nil {
 		return err
 	}
 
-	if err := mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
 		return err
 	}
 
@@ -83,6 +89,10 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 	}
 	json.Unmarshal(jsonDriver, &d)
 
+	if isoURL != "" {
+		d.Boot2DockerURL = isoURL
+	}
+
 	// Usually we call this implicitly, but call it here explicitly to get
 	// the latest default boot2docker ISO.
 	if d.Boot2DockerURL == "" {
@@ -102,7 +112,7 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Running))
+	return drivers.WaitForStage(provisioner.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(provisioner.Driver, state.Running))
 }
 
 func (provisioner *Boot2DockerProvisioner) Package(name string, action pkgaction.PackageAction) error {
@@ -110,6 +120,15 @@ func (provisioner *Boot2DockerProvisioner) Package(name string, action pkgaction
 		if err := provisioner.upgradeIso(); err != nil {
 			return err
 		}
+		return nil
+	}
+
+	// Other packages come from the Tiny Core extension repository. They
+	// live in memory, so they have to be installed again after a reboot.
+	if name != "docker" && action == pkgaction.Install {
+		if _, err := provisioner.SSHCommand(fmt.Sprintf("tce-load -wi %s", name)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -138,6 +157,10 @@ func (provisioner *Boot2DockerProvisioner) GetAuthOptions() auth.AuthOptions {
 	return provisioner.AuthOptions
 }
 
+func (provisioner *Boot2DockerProvisioner) GetEngineOptions() engine.EngineOptions {
+	return provisioner.EngineOptions
+}
+
 func (provisioner *Boot2DockerProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
@@ -164,7 +187,7 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 {{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
 {{end}}
 `
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	t, err := parseTemplate(provisioner.OsReleaseInfo, engineConfigTemplateFile, engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +198,9 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	daemonOptsDir := path.Join(provisioner.GetDockerOptionsDir(), "profile")
 	return &DockerOptions{
@@ -203,7 +228,7 @@ func (provisioner *Boot2DockerProvisioner) AttemptIPContact(dockerPort int) {
 		return
 	}
 
-	if conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, dockerPort), 5*time.Second); err != nil {
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(dockerPort)), 5*time.Second); err != nil {
 		log.Warn(`
 This machine has been allocated an IP address, but Docker Machine could not
 reach it successfully.
@@ -240,6 +265,10 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.SwarmOpt
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 
+	if err = checkFixedOptionsPaths("boot2docker", engineOptions); err != nil {
+		return err
+	}
+
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "aufs"
 	}
@@ -272,7 +301,7 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.SwarmOpt
 }
 
 func (provisioner *Boot2DockerProvisioner) SSHCommand(args string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
+	return drivers.RunCommandFromDriver(provisioner.Driver, args)
 }
 
 func (provisioner *Boot2DockerProvisioner) GetDriver() drivers.Driver {
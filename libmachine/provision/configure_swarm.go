@@ -3,8 +3,8 @@ package provision
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"net/url"
-	"strings"
 	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
@@ -63,8 +63,10 @@ func configureSwarm(p Provisioner, swarmOptions swarm.SwarmOptions, authOptions
 		return err
 	}
 
-	parts := strings.Split(u.Host, ":")
-	port := parts[1]
+	_, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return err
+	}
 
 	dockerDir := p.GetDockerOptionsDir()
 
@@ -127,3 +129,18 @@ join --advertise {{.Ip}}:{{.DockerPort}} {{.SwarmOptions.Discovery}}
 
 	return nil
 }
+
+// RejoinSwarm replaces the swarm containers of a machine, e.g. after they
+// stopped or lost track of the cluster.
+func RejoinSwarm(p Provisioner, swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions) error {
+	if !swarmOptions.IsSwarm {
+		return nil
+	}
+
+	rmCommand := p.GetDriver().SSHSudo("docker rm -f swarm-agent swarm-agent-master || true")
+	if _, err := p.SSHCommand(rmCommand); err != nil {
+		return err
+	}
+
+	return configureSwarm(p, swarmOptions, authOptions)
+}
@@ -0,0 +1,135 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// Local node states of an engine in swarm mode.
+const (
+	SwarmNodeInactive = "inactive"
+	SwarmNodeActive   = "active"
+)
+
+// SwarmAdvertiseAddr returns the address a machine advertises to a swarm
+// mode cluster: the one in its options, or else the IP of the machine.
+func SwarmAdvertiseAddr(p Provisioner, swarmOptions swarm.SwarmOptions) (string, error) {
+	if swarmOptions.Address != "" {
+		return swarmOptions.Address, nil
+	}
+
+	return p.GetDriver().GetIP()
+}
+
+// SwarmNodeState returns the swarm mode state of the engine, e.g. "inactive"
+// or "active".
+func SwarmNodeState(p Provisioner) (string, error) {
+	command := p.GetDriver().SSHSudo("docker info --format '{{.Swarm.LocalNodeState}}'")
+	out, err := p.SSHCommand(command)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// SwarmInit initializes a swarm mode cluster with the machine as its first
+// manager, and returns the tokens to join it with. A machine which is
+// already part of a cluster is left as it is.
+func SwarmInit(p Provisioner, swarmOptions swarm.SwarmOptions) (swarm.JoinTokens, error) {
+	state, err := SwarmNodeState(p)
+	if err != nil {
+		return swarm.JoinTokens{}, err
+	}
+
+	if state != SwarmNodeActive {
+		addr, err := SwarmAdvertiseAddr(p, swarmOptions)
+		if err != nil {
+			return swarm.JoinTokens{}, err
+		}
+
+		log.Info("Initializing swarm mode cluster...")
+		command := p.GetDriver().SSHSudo(fmt.Sprintf("docker swarm init --listen-addr 0.0.0.0:%d --advertise-addr %s", swarm.ModePort, addr))
+		if _, err := p.SSHCommand(command); err != nil {
+			return swarm.JoinTokens{}, err
+		}
+	}
+
+	return SwarmJoinTokens(p)
+}
+
+// SwarmJoinTokens asks a manager for the tokens to join its cluster with.
+func SwarmJoinTokens(p Provisioner) (swarm.JoinTokens, error) {
+	tokens := swarm.JoinTokens{}
+
+	for _, role := range []string{swarm.RoleManager, swarm.RoleWorker} {
+		command := p.GetDriver().SSHSudo(fmt.Sprintf("docker swarm join-token -q %s", role))
+		out, err := p.SSHCommand(command)
+		if err != nil {
+			return tokens, err
+		}
+
+		if role == swarm.RoleManager {
+			tokens.Manager = strings.TrimSpace(out)
+		} else {
+			tokens.Worker = strings.TrimSpace(out)
+		}
+	}
+
+	return tokens, nil
+}
+
+// SwarmJoin joins the machine to the cluster of the manager at managerAddr.
+// A machine which is already part of a cluster is left as it is.
+func SwarmJoin(p Provisioner, swarmOptions swarm.SwarmOptions, token, managerAddr string) error {
+	state, err := SwarmNodeState(p)
+	if err != nil {
+		return err
+	}
+
+	if state == SwarmNodeActive {
+		log.Debug("Engine is already part of a swarm mode cluster")
+		return nil
+	}
+
+	addr, err := SwarmAdvertiseAddr(p, swarmOptions)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Joining swarm mode cluster at %s as a %s...", managerAddr, swarmOptions.Role)
+	command := p.GetDriver().SSHSudo(fmt.Sprintf("docker swarm join --token %s --listen-addr 0.0.0.0:%d --advertise-addr %s %s:%d", token, swarm.ModePort, addr, managerAddr, swarm.ModePort))
+	if _, err := p.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SwarmLeave takes the machine out of its swarm mode cluster. Managers
+// only leave with force, which may cost the cluster its quorum.
+func SwarmLeave(p Provisioner, force bool) error {
+	command := "docker swarm leave"
+	if force {
+		command += " --force"
+	}
+
+	if _, err := p.SSHCommand(p.GetDriver().SSHSudo(command)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SwarmRotateJoinToken replaces the token to join the cluster of a manager
+// with role, so that the old one can no longer be used.
+func SwarmRotateJoinToken(p Provisioner, role string) error {
+	command := p.GetDriver().SSHSudo(fmt.Sprintf("docker swarm join-token --rotate -q %s", role))
+	if _, err := p.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
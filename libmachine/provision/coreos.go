@@ -3,7 +3,6 @@ package provision
 import (
 	"bytes"
 	"fmt"
-	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -105,7 +104,7 @@ ExecStart=/usr/lib/coreos/dockerd --daemon --host=unix:///var/run/docker.sock --
 WantedBy=multi-user.target
 `
 
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	t, err := parseTemplate(provisioner.OsReleaseInfo, engineConfigTemplateFile, engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -116,11 +115,13 @@ WantedBy=multi-user.target
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		EngineOptionsPath: provisioner.daemonOptionsFile(),
 	}, nil
 }
 
@@ -3,7 +3,6 @@ package provision
 import (
 	"bytes"
 	"fmt"
-	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -160,7 +159,7 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 	}
 
 	log.Debug("waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitDaemon, mcnutils.WaitSettings{}, provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
@@ -204,7 +203,7 @@ Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 [Install]
 WantedBy=multi-user.target
 `
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	t, err := parseTemplate(provisioner.OsReleaseInfo, engineConfigTemplateFile, engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -215,10 +214,12 @@ WantedBy=multi-user.target
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		EngineOptionsPath: provisioner.daemonOptionsFile(),
 	}, nil
 }
@@ -0,0 +1,39 @@
+package provision
+
+import (
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// EngineOptionsGetter is implemented by provisioners which keep the engine
+// options they provision the engine with.
+type EngineOptionsGetter interface {
+	GetEngineOptions() engine.EngineOptions
+}
+
+// CheckEngineOptions fails when the engine options of the provisioner can't
+// work with the engine installed on the host and its kernel, before the
+// engine config is written, rather than leaving an engine which doesn't
+// start. Versions which can't be found out aren't checked against.
+func CheckEngineOptions(p Provisioner) error {
+	getter, ok := p.(EngineOptionsGetter)
+	if !ok {
+		return nil
+	}
+
+	engineVersion, err := EngineVersion(p)
+	if err != nil {
+		log.Debugf("Could not get the engine version to check the engine options against: %s", err)
+		engineVersion = ""
+	}
+
+	kernelVersion, err := p.SSHCommand("uname -r")
+	if err != nil {
+		log.Debugf("Could not get the kernel version to check the engine options against: %s", err)
+		kernelVersion = ""
+	}
+
+	return engine.CheckOptions(getter.GetEngineOptions(), engineVersion, strings.TrimSpace(kernelVersion))
+}
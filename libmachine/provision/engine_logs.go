@@ -0,0 +1,55 @@
+package provision
+
+import (
+	"fmt"
+)
+
+// engineLogsScript prints the logs of the engine from the journal of
+// systemd, or else from the log file of the init system.
+const engineLogsScript = `sh -c 'if [ -d /run/systemd/system ]; then exec journalctl -u docker --no-pager %s; fi
+for file in /var/log/upstart/docker.log /var/log/docker.log; do
+	if [ -f $file ]; then exec tail %s $file; fi
+done
+echo "No logs of the engine found in the journal, /var/log/upstart/docker.log or /var/log/docker.log" >&2
+exit 1'`
+
+// EngineLogger is implemented by provisioners whose engine logs neither to
+// the journal of systemd nor to /var/log.
+type EngineLogger interface {
+	EngineLogsCommand(follow bool, lines int) string
+}
+
+// EngineLogsCommand returns the command printing the logs of the engine of
+// the host, starting with their last lines unless lines is 0, and following
+// them if follow is true.
+func EngineLogsCommand(p Provisioner, follow bool, lines int) string {
+	if logger, ok := p.(EngineLogger); ok {
+		return logger.EngineLogsCommand(follow, lines)
+	}
+
+	journalctlArgs := ""
+	tailArgs := "-n +1"
+	if lines > 0 {
+		journalctlArgs = fmt.Sprintf("-n %d", lines)
+		tailArgs = fmt.Sprintf("-n %d", lines)
+	}
+	if follow {
+		journalctlArgs += " -f"
+		tailArgs += " -f"
+	}
+
+	return p.GetDriver().SSHSudo(fmt.Sprintf(engineLogsScript, journalctlArgs, tailArgs))
+}
+
+// EngineLogsCommand returns the command printing the logs of the engine, a
+// system container of RancherOS.
+func (provisioner *RancherProvisioner) EngineLogsCommand(follow bool, lines int) string {
+	command := "system-docker logs"
+	if lines > 0 {
+		command += fmt.Sprintf(" --tail %d", lines)
+	}
+	if follow {
+		command += " -f"
+	}
+	return provisioner.Driver.SSHSudo(command + " docker")
+}
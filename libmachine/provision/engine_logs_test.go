@@ -0,0 +1,43 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func TestEngineLogsCommand(t *testing.T) {
+	p := &UbuntuProvisioner{
+		GenericProvisioner{
+			Driver: &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}},
+		},
+	}
+
+	command := EngineLogsCommand(p, true, 50)
+	if !strings.Contains(command, "exec journalctl -u docker --no-pager -n 50 -f;") {
+		t.Fatalf("expected journalctl to follow the last lines, got %s", command)
+	}
+	if !strings.Contains(command, "exec tail -n 50 -f $file;") {
+		t.Fatalf("expected tail to follow the last lines, got %s", command)
+	}
+
+	command = EngineLogsCommand(p, false, 0)
+	if !strings.Contains(command, "exec journalctl -u docker --no-pager ;") || !strings.Contains(command, "exec tail -n +1 $file;") {
+		t.Fatalf("expected all the logs, got %s", command)
+	}
+}
+
+func TestRancherEngineLogsCommand(t *testing.T) {
+	p := &RancherProvisioner{
+		GenericProvisioner{
+			Driver: &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}},
+		},
+	}
+
+	command := EngineLogsCommand(p, true, 10)
+	if !strings.HasSuffix(command, "system-docker logs --tail 10 -f docker") {
+		t.Fatalf("expected the logs of the docker system container, got %s", command)
+	}
+}
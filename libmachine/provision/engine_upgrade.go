@@ -0,0 +1,270 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Release channels of the Docker engine.
+const (
+	EngineChannelStable       = "stable"
+	EngineChannelTest         = "test"
+	EngineChannelExperimental = "experimental"
+)
+
+var (
+	ErrUnknownEngineChannel = errors.New("unknown engine channel, expected stable, test or experimental")
+
+	engineInstallURLs = map[string]string{
+		EngineChannelStable:       "https://get.docker.com",
+		EngineChannelTest:         "https://test.docker.com",
+		EngineChannelExperimental: "https://experimental.docker.com",
+	}
+)
+
+// EngineTarget is the engine an upgrade installs: a specific version, the
+// latest version of a release channel, or a specific version from a
+// channel.
+type EngineTarget struct {
+	Version string
+	Channel string
+}
+
+func (t EngineTarget) Validate() error {
+	if _, ok := engineInstallURLs[t.Channel]; !ok && t.Channel != "" {
+		return ErrUnknownEngineChannel
+	}
+	return nil
+}
+
+// IsLatestStable reports whether the target is simply the latest stable
+// engine, which every provisioner installs by upgrading the docker package.
+func (t EngineTarget) IsLatestStable() bool {
+	return t.Version == "" && (t.Channel == "" || t.Channel == EngineChannelStable)
+}
+
+func (t EngineTarget) String() string {
+	s := t.Version
+	if s == "" {
+		s = "latest"
+	}
+	if t.Channel != "" {
+		s += " from the " + t.Channel + " channel"
+	}
+	return s
+}
+
+// Matches reports whether the engine version running satisfies the target.
+// A version of "1.9" is satisfied by any 1.9.x release.
+func (t EngineTarget) Matches(running string) bool {
+	if t.Version == "" {
+		return true
+	}
+	return running == t.Version ||
+		strings.HasPrefix(running, t.Version+".") ||
+		strings.HasPrefix(running, t.Version+"-")
+}
+
+// EngineUpgrader is implemented by provisioners which can install a specific
+// engine version or channel, upgrading or downgrading as needed.
+type EngineUpgrader interface {
+	UpgradeEngine(target EngineTarget) error
+}
+
+// EngineVersion returns the version of the engine running on the machine.
+func EngineVersion(p Provisioner) (string, error) {
+	command := p.GetDriver().SSHSudo("docker version --format '{{.Server.Version}}'")
+	out, err := p.SSHCommand(command)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// findPackageVersion returns the first of the package versions available
+// which is the engine version wanted, e.g. "1.9.1-0~trusty" for "1.9.1".
+// Package managers list the newest versions first.
+func findPackageVersion(available []string, version string) (string, error) {
+	target := EngineTarget{Version: version}
+	for _, v := range available {
+		if target.Matches(v) {
+			return v, nil
+		}
+	}
+
+	return "", fmt.Errorf("engine version %s is not available, available versions are: %s", version, strings.Join(available, ", "))
+}
+
+// parseAptMadison returns the versions in the output of apt-cache madison.
+func parseAptMadison(out string) []string {
+	versions := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		versions = append(versions, strings.TrimSpace(fields[1]))
+	}
+	return versions
+}
+
+// parseYumList returns the versions of pkg in the output of
+// "yum list --showduplicates", newest first.
+func parseYumList(out, pkg string) []string {
+	versions := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], pkg+".") {
+			continue
+		}
+		versions = append([]string{fields[1]}, versions...)
+	}
+	return versions
+}
+
+// compareVersions compares two dotted versions number by number, ignoring
+// anything after the first character which is neither a digit nor a dot.
+func compareVersions(a, b string) int {
+	as := versionNumbers(a)
+	bs := versionNumbers(b)
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func versionNumbers(v string) []int {
+	if i := strings.IndexFunc(v, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		v = v[:i]
+	}
+
+	numbers := []int{}
+	for _, part := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// aptUpgradeEngine installs the target engine on Debian based systems.
+func aptUpgradeEngine(p Provisioner, target EngineTarget) error {
+	// The install script of a channel points apt at its repository and
+	// installs its latest version.
+	if target.Channel != "" {
+		command := fmt.Sprintf("curl -sSL %s | sudo DEBIAN_FRONTEND=noninteractive sh", engineInstallURLs[target.Channel])
+		if _, err := p.SSHCommand(command); err != nil {
+			return err
+		}
+	}
+
+	if target.Version == "" {
+		return nil
+	}
+
+	if _, err := p.SSHCommand(p.GetDriver().SSHSudo("apt-get update")); err != nil {
+		return err
+	}
+
+	out, err := p.SSHCommand("apt-cache madison docker-engine")
+	if err != nil {
+		return err
+	}
+
+	version, err := findPackageVersion(parseAptMadison(out), target.Version)
+	if err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("sudo DEBIAN_FRONTEND=noninteractive apt-get install -y --force-yes docker-engine=%s", version)
+	if _, err := p.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *UbuntuProvisioner) UpgradeEngine(target EngineTarget) error {
+	return aptUpgradeEngine(provisioner, target)
+}
+
+func (provisioner *DebianProvisioner) UpgradeEngine(target EngineTarget) error {
+	return aptUpgradeEngine(provisioner, target)
+}
+
+func (provisioner *RedHatProvisioner) UpgradeEngine(target EngineTarget) error {
+	channel := target.Channel
+	if channel == "" {
+		channel = EngineChannelStable
+	}
+
+	if err := provisioner.configurePackageList(channel); err != nil {
+		return err
+	}
+
+	if target.Version == "" {
+		_, err := provisioner.SSHCommand(provisioner.Driver.SSHSudo("yum upgrade -y docker-engine"))
+		return err
+	}
+
+	out, err := provisioner.SSHCommand(provisioner.Driver.SSHSudo("yum list --showduplicates docker-engine"))
+	if err != nil {
+		return err
+	}
+
+	version, err := findPackageVersion(parseYumList(out, "docker-engine"), target.Version)
+	if err != nil {
+		return err
+	}
+
+	installed, err := provisioner.SSHCommand("rpm -q --qf '%{VERSION}-%{RELEASE}' docker-engine")
+	if err != nil {
+		return err
+	}
+
+	// yum install refuses to go back to an older version.
+	yumAction := "install"
+	if compareVersions(version, strings.TrimSpace(installed)) < 0 {
+		yumAction = "downgrade"
+	}
+
+	command := fmt.Sprintf(provisioner.Driver.SSHSudo("yum %s -y docker-engine-%s"), yumAction, version)
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Boot2Docker ships the engine in the ISO, whose releases follow the engine
+// version.
+func (provisioner *Boot2DockerProvisioner) UpgradeEngine(target EngineTarget) error {
+	if target.Channel != "" && target.Channel != EngineChannelStable {
+		return fmt.Errorf("boot2docker only provides the %s channel", EngineChannelStable)
+	}
+
+	isoURL := ""
+	if target.Version != "" {
+		isoURL = fmt.Sprintf("https://github.com/boot2docker/boot2docker/releases/download/v%s/boot2docker.iso", target.Version)
+	}
+
+	return provisioner.upgradeIsoTo(isoURL)
+}
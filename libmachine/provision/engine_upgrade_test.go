@@ -0,0 +1,107 @@
+package provision
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEngineTargetValidate(t *testing.T) {
+	valid := []EngineTarget{
+		{},
+		{Version: "1.9.1"},
+		{Channel: EngineChannelTest},
+		{Version: "1.10.0", Channel: EngineChannelExperimental},
+	}
+	for _, target := range valid {
+		if err := target.Validate(); err != nil {
+			t.Fatalf("expected %+v to be valid, got %s", target, err)
+		}
+	}
+
+	if err := (EngineTarget{Channel: "nightly"}).Validate(); err != ErrUnknownEngineChannel {
+		t.Fatalf("expected ErrUnknownEngineChannel, got %v", err)
+	}
+}
+
+func TestEngineTargetMatches(t *testing.T) {
+	cases := []struct {
+		version string
+		running string
+		matches bool
+	}{
+		{"", "1.9.1", true},
+		{"1.9.1", "1.9.1", true},
+		{"1.9", "1.9.1", true},
+		{"1.9", "1.10.0", false},
+		{"1.10.0", "1.10.0-rc1", true},
+		{"1.9.1", "1.9.10", false},
+	}
+
+	for _, c := range cases {
+		if m := (EngineTarget{Version: c.version}).Matches(c.running); m != c.matches {
+			t.Fatalf("expected %q matching %q to be %t", c.version, c.running, c.matches)
+		}
+	}
+}
+
+func TestParseAptMadison(t *testing.T) {
+	out := ` docker-engine | 1.9.1-0~trusty | https://apt.dockerproject.org/repo/ ubuntu-trusty/main amd64 Packages
+ docker-engine | 1.9.0-0~trusty | https://apt.dockerproject.org/repo/ ubuntu-trusty/main amd64 Packages
+`
+
+	versions := parseAptMadison(out)
+	expected := []string{"1.9.1-0~trusty", "1.9.0-0~trusty"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("expected %v, got %v", expected, versions)
+	}
+}
+
+func TestParseYumList(t *testing.T) {
+	out := `Loaded plugins: fastestmirror
+Available Packages
+docker-engine.x86_64                 1.8.3-1.el7.centos               docker
+docker-engine.x86_64                 1.9.0-1.el7.centos               docker
+docker-engine.x86_64                 1.9.1-1.el7.centos               docker
+docker-engine-selinux.noarch         1.9.1-1.el7.centos               docker
+`
+
+	versions := parseYumList(out, "docker-engine")
+	expected := []string{"1.9.1-1.el7.centos", "1.9.0-1.el7.centos", "1.8.3-1.el7.centos"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("expected %v, got %v", expected, versions)
+	}
+}
+
+func TestFindPackageVersion(t *testing.T) {
+	available := []string{"1.9.1-0~trusty", "1.9.0-0~trusty", "1.8.3-0~trusty"}
+
+	version, err := findPackageVersion(available, "1.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "1.9.1-0~trusty" {
+		t.Fatalf("expected the newest 1.9 release, got %s", version)
+	}
+
+	if _, err := findPackageVersion(available, "1.7.1"); err == nil {
+		t.Fatal("expected an error for a version which is not available")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.9.1", "1.9.1", 0},
+		{"1.9.1-1.el7.centos", "1.10.0-1.el7.centos", -1},
+		{"1.10.0", "1.9.1", 1},
+		{"1.9", "1.9.0", 0},
+	}
+
+	for _, c := range cases {
+		if r := compareVersions(c.a, c.b); r != c.expected {
+			t.Fatalf("expected comparing %q to %q to give %d, got %d", c.a, c.b, c.expected, r)
+		}
+	}
+}
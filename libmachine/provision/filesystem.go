@@ -0,0 +1,99 @@
+package provision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// FilesystemGrower is implemented by provisioners which grow the filesystem
+// of the engine their own way after the disk of the machine was resized.
+type FilesystemGrower interface {
+	GrowFilesystem() error
+}
+
+// growFilesystemScript grows the partition and the filesystem the engine
+// data is on to the end of their disk. growpart comes with cloud-utils:
+// without it, or when the filesystem is on the whole disk, only the
+// filesystem is grown.
+const growFilesystemScript = `set -e
+source=$(findmnt -n -o SOURCE -T /var/lib/docker)
+fstype=$(findmnt -n -o FSTYPE -T /var/lib/docker)
+disk=$(lsblk -n -o PKNAME "$source" 2>/dev/null | head -n 1)
+part=$(cat "/sys/class/block/$(basename "$source")/partition" 2>/dev/null || true)
+if [ -n "$disk" ] && [ -n "$part" ] && command -v growpart >/dev/null; then
+	growpart "/dev/$disk" "$part" || true
+fi
+case "$fstype" in
+	ext*) resize2fs "$source" ;;
+	xfs) xfs_growfs -d /var/lib/docker ;;
+	btrfs) btrfs filesystem resize max /var/lib/docker ;;
+	*) echo "Cannot grow $fstype filesystem $source" >&2; exit 1 ;;
+esac`
+
+// GrowFilesystem grows the filesystem of the engine to the disk of the
+// machine, after the disk was resized.
+func GrowFilesystem(p Provisioner) error {
+	if grower, ok := p.(FilesystemGrower); ok {
+		return grower.GrowFilesystem()
+	}
+
+	command := p.GetDriver().SSHSudo(fmt.Sprintf("sh -c '%s'", growFilesystemScript))
+	if _, err := p.SSHCommand(command); err != nil {
+		return fmt.Errorf("Error growing the filesystem: %s", err)
+	}
+	return nil
+}
+
+// GrowFilesystem grows the boot2docker-data partition, /dev/sda1, and its
+// filesystem to the end of the disk. The partition is the last one of the
+// disk, after the swap one, so it is recreated from the same sector up to the
+// end. The kernel can't reread the partition table of a disk in use, so the
+// machine is restarted before the filesystem is grown.
+func (provisioner *Boot2DockerProvisioner) GrowFilesystem() error {
+	out, err := provisioner.SSHCommand("cat /sys/block/sda/sda1/start")
+	if err != nil {
+		return err
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return fmt.Errorf("Error reading the first sector of /dev/sda1: %s", err)
+	}
+
+	// fdisk fails to have the new table reread, which the restart takes
+	// care of.
+	if _, err := provisioner.SSHCommand(boot2dockerRepartitionCommand(start) + " || true"); err != nil {
+		return err
+	}
+
+	log.Info("Restarting machine to grow its filesystem...")
+
+	if err := provisioner.Driver.Restart(); err != nil {
+		return err
+	}
+
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(provisioner.Driver, state.Running)); err != nil {
+		return err
+	}
+
+	if err := drivers.WaitForTransport(provisioner.Driver); err != nil {
+		return err
+	}
+
+	if _, err := provisioner.SSHCommand("sudo resize2fs /dev/sda1"); err != nil {
+		return fmt.Errorf("Error growing the filesystem: %s", err)
+	}
+	return nil
+}
+
+// boot2dockerRepartitionCommand recreates /dev/sda1 from the sector start to
+// the end of the disk.
+func boot2dockerRepartitionCommand(start int) string {
+	return fmt.Sprintf(`sudo sh -c "printf 'd\n1\nn\np\n1\n%d\n\nw\n' | fdisk -u /dev/sda"`, start)
+}
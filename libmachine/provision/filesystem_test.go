@@ -0,0 +1,10 @@
+package provision
+
+import "testing"
+
+func TestBoot2dockerRepartitionCommand(t *testing.T) {
+	expected := `sudo sh -c "printf 'd\n1\nn\np\n1\n2048001\n\nw\n' | fdisk -u /dev/sda"`
+	if command := boot2dockerRepartitionCommand(2048001); command != expected {
+		t.Fatalf("expected %s, got %s", expected, command)
+	}
+}
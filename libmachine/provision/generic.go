@@ -3,7 +3,6 @@ package provision
 import (
 	"bytes"
 	"fmt"
-	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -60,11 +59,23 @@ func (provisioner *GenericProvisioner) SetHostname(hostname string) error {
 }
 
 func (provisioner *GenericProvisioner) GetDockerOptionsDir() string {
+	if provisioner.EngineOptions.OptionsDir != "" {
+		return provisioner.EngineOptions.OptionsDir
+	}
 	return provisioner.DockerOptionsDir
 }
 
+// daemonOptionsFile returns the file the engine options are written to,
+// the one of the engine options, if any, else the one of the provisioner.
+func (provisioner *GenericProvisioner) daemonOptionsFile() string {
+	if provisioner.EngineOptions.OptionsFile != "" {
+		return provisioner.EngineOptions.OptionsFile
+	}
+	return provisioner.DaemonOptionsFile
+}
+
 func (provisioner *GenericProvisioner) SSHCommand(args string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
+	return drivers.RunCommandFromDriver(provisioner.Driver, args)
 }
 
 func (provisioner *GenericProvisioner) CompatibleWithHost() bool {
@@ -75,6 +86,10 @@ func (provisioner *GenericProvisioner) GetAuthOptions() auth.AuthOptions {
 	return provisioner.AuthOptions
 }
 
+func (provisioner *GenericProvisioner) GetEngineOptions() engine.EngineOptions {
+	return provisioner.EngineOptions
+}
+
 func (provisioner *GenericProvisioner) SetOsReleaseInfo(info *OsRelease) {
 	provisioner.OsReleaseInfo = info
 }
@@ -109,7 +124,7 @@ DOCKER_OPTS='
 {{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
 {{end}}
 `
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	t, err := parseTemplate(provisioner.OsReleaseInfo, engineConfigTemplateFile, engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -120,11 +135,13 @@ DOCKER_OPTS='
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		EngineOptionsPath: provisioner.daemonOptionsFile(),
 	}, nil
 }
 
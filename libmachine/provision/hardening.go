@@ -0,0 +1,242 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// HardeningCIS is the hardening profile applying the remediations of the
+// CIS Docker Benchmark, as checked by Docker Bench for Security.
+const HardeningCIS = "cis"
+
+// Statuses of the items of a hardening report.
+const (
+	HardeningApplied = "applied"
+	HardeningSkipped = "skipped"
+)
+
+var (
+	errNoAuditd = errors.New("the OS has no auditd")
+
+	// cisEngineFlags are the engine flags of the CIS profile, in the form
+	// of EngineOptions.ArbitraryFlags.
+	cisEngineFlags = []string{
+		"userns-remap=default",
+		"no-new-privileges",
+		"icc=false",
+	}
+
+	// auditedDockerPaths are the Docker files and directories the CIS
+	// profile has auditd watch, the missing ones being skipped.
+	auditedDockerPaths = []string{
+		"/usr/bin/docker",
+		"/usr/bin/dockerd",
+		"/usr/bin/containerd",
+		"/usr/bin/runc",
+		"/var/lib/docker",
+		"/etc/docker",
+		"/etc/default/docker",
+		"/etc/sysconfig/docker",
+		"/lib/systemd/system/docker.service",
+		"/lib/systemd/system/docker.socket",
+		"/usr/lib/systemd/system/docker.service",
+		"/usr/lib/systemd/system/docker.socket",
+	}
+)
+
+// auditRulesScript writes an auditd rule for each of the paths which exist,
+// and loads the rules. It is idempotent, for provisioning to be run again.
+const auditRulesScript = `set -e
+rules=/etc/audit/rules.d/docker.rules
+mkdir -p /etc/audit/rules.d
+: > $rules.tmp
+for path in %s; do
+	if [ -e "$path" ]; then echo "-w $path -p wa -k docker" >> $rules.tmp; fi
+done
+mv $rules.tmp $rules
+augenrules --load >/dev/null 2>&1 || auditctl -R $rules >/dev/null`
+
+// HardeningItem is a remediation of a hardening profile, and whether it was
+// applied.
+type HardeningItem struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// HardeningReport tells what a hardening profile applied to a host, saved
+// with the host.
+type HardeningReport struct {
+	Profile   string
+	AppliedAt time.Time
+	Items     []HardeningItem
+}
+
+// AuditRulesInstaller is implemented by provisioners which install auditd
+// rules their own way, or can't.
+type AuditRulesInstaller interface {
+	InstallAuditRules(paths []string) error
+}
+
+// HardenEngineOptions returns the engine options with the engine flags of
+// the hardening profile added, unless they are already given. Options the
+// profile forbids, like an engine listening without TLS, are refused.
+func HardenEngineOptions(engineOptions engine.EngineOptions) (engine.EngineOptions, error) {
+	if engineOptions.Hardening != HardeningCIS {
+		return engineOptions, fmt.Errorf("unknown hardening profile %q, expected %q", engineOptions.Hardening, HardeningCIS)
+	}
+
+	if len(engineOptions.InsecureRegistry) > 0 {
+		return engineOptions, errors.New("the cis profile forbids insecure registries")
+	}
+
+	for _, flag := range engineOptions.ArbitraryFlags {
+		name, value := splitEngineFlag(flag)
+		if (name == "host" || name == "H") && strings.HasPrefix(value, "tcp://") {
+			return engineOptions, fmt.Errorf("the cis profile only allows the TLS listener of the engine, not --%s", flag)
+		}
+		if (name == "tlsverify" || name == "no-new-privileges") && value == "false" {
+			return engineOptions, fmt.Errorf("the cis profile forbids --%s", flag)
+		}
+		if name == "icc" && value != "false" {
+			return engineOptions, fmt.Errorf("the cis profile forbids --%s", flag)
+		}
+	}
+
+	flags := append([]string{}, engineOptions.ArbitraryFlags...)
+	for _, flag := range cisEngineFlags {
+		name, _ := splitEngineFlag(flag)
+		if !hasEngineFlag(flags, name) {
+			flags = append(flags, flag)
+		}
+	}
+	engineOptions.ArbitraryFlags = flags
+
+	return engineOptions, nil
+}
+
+// Harden applies the remediations of the hardening profile of the engine
+// options which aren't engine flags, once the engine is provisioned, and
+// returns what was applied. Remediations the OS of the host can't have are
+// skipped.
+func Harden(p Provisioner, engineOptions engine.EngineOptions) (*HardeningReport, error) {
+	report := &HardeningReport{
+		Profile:   engineOptions.Hardening,
+		AppliedAt: time.Now(),
+	}
+
+	for _, flag := range cisEngineFlags {
+		name, _ := splitEngineFlag(flag)
+		for _, given := range engineOptions.ArbitraryFlags {
+			if givenName, _ := splitEngineFlag(given); givenName == name {
+				report.Items = append(report.Items, HardeningItem{Name: name, Status: HardeningApplied, Detail: "--" + given})
+			}
+		}
+	}
+
+	report.Items = append(report.Items, HardeningItem{
+		Name:   "tls-listener",
+		Status: HardeningApplied,
+		Detail: "the engine only listens on TCP with --tlsverify",
+	})
+
+	log.Info("Installing auditd rules for the Docker files...")
+	item := HardeningItem{Name: "auditd", Status: HardeningApplied, Detail: "/etc/audit/rules.d/docker.rules"}
+	if err := installAuditRules(p); err == errNoAuditd {
+		item.Status = HardeningSkipped
+		item.Detail = err.Error()
+	} else if err != nil {
+		return nil, fmt.Errorf("Error installing auditd rules: %s", err)
+	}
+	report.Items = append(report.Items, item)
+
+	report.Items = append(report.Items, HardeningItem{
+		Name:   "content-trust",
+		Status: HardeningApplied,
+		Detail: "docker-machine env sets DOCKER_CONTENT_TRUST=1",
+	})
+
+	return report, nil
+}
+
+func installAuditRules(p Provisioner) error {
+	paths := auditedPaths(p)
+
+	if installer, ok := p.(AuditRulesInstaller); ok {
+		return installer.InstallAuditRules(paths)
+	}
+
+	if err := p.Package(auditPackage(p), pkgaction.Install); err != nil {
+		return err
+	}
+
+	_, err := p.SSHCommand(p.GetDriver().SSHSudo(auditRulesCommand(paths)))
+	return err
+}
+
+// auditedPaths returns the Docker paths auditd watches on the host, with
+// the docker options dir of the provisioner when it isn't /etc/docker.
+func auditedPaths(p Provisioner) []string {
+	paths := append([]string{}, auditedDockerPaths...)
+	for _, audited := range paths {
+		if audited == p.GetDockerOptionsDir() {
+			return paths
+		}
+	}
+	return append(paths, p.GetDockerOptionsDir())
+}
+
+func auditRulesCommand(paths []string) string {
+	return fmt.Sprintf("sh -c '"+auditRulesScript+"'", strings.Join(paths, " "))
+}
+
+// auditPackage returns the name of the package of auditd on the OS of the
+// host.
+func auditPackage(p Provisioner) string {
+	info, err := p.GetOsReleaseInfo()
+	if err == nil && (info.Id == "debian" || info.Id == "ubuntu" || strings.Contains(info.IdLike, "debian")) {
+		return "auditd"
+	}
+	return "audit"
+}
+
+// splitEngineFlag splits an engine flag of EngineOptions.ArbitraryFlags,
+// like "icc=false", into its name and value.
+func splitEngineFlag(flag string) (string, string) {
+	parts := strings.SplitN(flag, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func hasEngineFlag(flags []string, name string) bool {
+	for _, flag := range flags {
+		if flagName, _ := splitEngineFlag(flag); flagName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallAuditRules skips the auditd rules, boot2docker having no auditd.
+func (provisioner *Boot2DockerProvisioner) InstallAuditRules(paths []string) error {
+	return errNoAuditd
+}
+
+// InstallAuditRules skips the auditd rules, RancherOS having no auditd.
+func (provisioner *RancherProvisioner) InstallAuditRules(paths []string) error {
+	return errNoAuditd
+}
+
+// InstallAuditRules skips the auditd rules, CoreOS having no package manager
+// to install auditd with.
+func (provisioner *CoreOSProvisioner) InstallAuditRules(paths []string) error {
+	return errNoAuditd
+}
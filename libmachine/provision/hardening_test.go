@@ -0,0 +1,87 @@
+package provision
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+func TestHardenEngineOptions(t *testing.T) {
+	hardened, err := HardenEngineOptions(engine.EngineOptions{
+		Hardening:      HardeningCIS,
+		ArbitraryFlags: []string{"log-driver=journald", "userns-remap=dockremap"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"log-driver=journald", "userns-remap=dockremap", "no-new-privileges", "icc=false"}
+	if !reflect.DeepEqual(hardened.ArbitraryFlags, expected) {
+		t.Fatalf("expected flags %v, got %v", expected, hardened.ArbitraryFlags)
+	}
+}
+
+func TestHardenEngineOptionsRefuses(t *testing.T) {
+	for _, test := range []struct {
+		engineOptions engine.EngineOptions
+		err           string
+	}{
+		{engine.EngineOptions{Hardening: "stig"}, `unknown hardening profile "stig", expected "cis"`},
+		{engine.EngineOptions{Hardening: HardeningCIS, InsecureRegistry: []string{"registry:5000"}}, "the cis profile forbids insecure registries"},
+		{engine.EngineOptions{Hardening: HardeningCIS, ArbitraryFlags: []string{"host=tcp://0.0.0.0:2375"}}, "the cis profile only allows the TLS listener of the engine, not --host=tcp://0.0.0.0:2375"},
+		{engine.EngineOptions{Hardening: HardeningCIS, ArbitraryFlags: []string{"icc=true"}}, "the cis profile forbids --icc=true"},
+		{engine.EngineOptions{Hardening: HardeningCIS, ArbitraryFlags: []string{"no-new-privileges=false"}}, "the cis profile forbids --no-new-privileges=false"},
+	} {
+		_, err := HardenEngineOptions(test.engineOptions)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("expected error %q, got %v", test.err, err)
+		}
+	}
+}
+
+func TestHardenBoot2Docker(t *testing.T) {
+	p := &Boot2DockerProvisioner{
+		Driver: &fakedriver.Driver{},
+	}
+
+	engineOptions, _ := HardenEngineOptions(engine.EngineOptions{Hardening: HardeningCIS})
+
+	report, err := Harden(p, engineOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{}
+	for _, item := range report.Items {
+		statuses = append(statuses, item.Name+"="+item.Status)
+	}
+
+	expected := []string{
+		"userns-remap=applied",
+		"no-new-privileges=applied",
+		"icc=applied",
+		"tls-listener=applied",
+		"auditd=skipped",
+		"content-trust=applied",
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("expected %v, got %v", expected, statuses)
+	}
+	if report.Profile != HardeningCIS {
+		t.Fatalf("expected profile %q, got %q", HardeningCIS, report.Profile)
+	}
+}
+
+func TestAuditRulesCommand(t *testing.T) {
+	command := auditRulesCommand([]string{"/usr/bin/dockerd", "/etc/docker"})
+
+	if !strings.Contains(command, "for path in /usr/bin/dockerd /etc/docker; do") {
+		t.Fatalf("expected the paths in %s", command)
+	}
+	if !strings.HasPrefix(command, "sh -c '") || strings.Count(command, "'") != 2 {
+		t.Fatalf("expected the script to be single quoted, got %s", command)
+	}
+}
@@ -0,0 +1,211 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func init() {
+	Register("openSUSE MicroOS", &RegisteredProvisioner{
+		New: NewMicroOSProvisioner,
+	})
+	Register("openSUSE Leap Micro", &RegisteredProvisioner{
+		New: NewLeapMicroProvisioner,
+	})
+	Register("SLE Micro", &RegisteredProvisioner{
+		New: NewSLEMicroProvisioner,
+	})
+}
+
+func NewMicroOSProvisioner(d drivers.Driver) Provisioner {
+	return newTransactionalProvisioner(d, "opensuse-microos")
+}
+
+func NewLeapMicroProvisioner(d drivers.Driver) Provisioner {
+	return newTransactionalProvisioner(d, "opensuse-leap-micro")
+}
+
+func NewSLEMicroProvisioner(d drivers.Driver) Provisioner {
+	return newTransactionalProvisioner(d, "sle-micro")
+}
+
+func newTransactionalProvisioner(d drivers.Driver, osReleaseID string) *TransactionalProvisioner {
+	return &TransactionalProvisioner{
+		SUSEProvisioner{
+			GenericProvisioner{
+				DockerOptionsDir:  "/etc/docker",
+				DaemonOptionsFile: "/etc/sysconfig/docker",
+				OsReleaseId:       osReleaseID,
+				Packages: []string{
+					"curl",
+				},
+				Driver: d,
+			},
+		},
+	}
+}
+
+// TransactionalProvisioner provisions the SUSE systems with a read-only root
+// filesystem, openSUSE MicroOS and SLE Micro. Their packages are installed
+// with transactional-update in a new snapshot of the root filesystem, which
+// is only used once the host reboots into it.
+type TransactionalProvisioner struct {
+	SUSEProvisioner
+}
+
+// transactionalUpdateCommand installs, removes or updates the packages in a
+// new snapshot.
+func transactionalUpdateCommand(action pkgaction.PackageAction, packages []string) string {
+	packageAction := "install"
+	switch action {
+	case pkgaction.Remove:
+		packageAction = "remove"
+	case pkgaction.Upgrade:
+		packageAction = "update"
+	}
+
+	return fmt.Sprintf("sudo transactional-update --non-interactive pkg %s %s", packageAction, strings.Join(packages, " "))
+}
+
+// rebootCommand reboots the host once the SSH command returned, for the
+// command not to fail with the connection closing.
+const rebootCommand = "sudo sh -c '(sleep 1; systemctl reboot) >/dev/null 2>&1 &'"
+
+// missingPackages returns the packages which aren't installed.
+func (provisioner *TransactionalProvisioner) missingPackages(packages []string) []string {
+	missing := []string{}
+	for _, pkg := range packages {
+		if _, err := provisioner.SSHCommand("rpm -q " + pkg); err != nil {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing
+}
+
+func (provisioner *TransactionalProvisioner) bootID() (string, error) {
+	output, err := provisioner.SSHCommand("cat /proc/sys/kernel/random/boot_id")
+	return strings.TrimSpace(output), err
+}
+
+// reboot reboots the host into the snapshot transactional-update created,
+// and waits for it to be back.
+func (provisioner *TransactionalProvisioner) reboot() error {
+	before, err := provisioner.bootID()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Rebooting into the new snapshot...")
+	if _, err := provisioner.SSHCommand(rebootCommand); err != nil {
+		return err
+	}
+
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitSSH, mcnutils.WaitSettings{Timeout: drivers.GetWaitTimeouts(provisioner.Driver).SSH}, func() bool {
+		id, err := provisioner.bootID()
+		return err == nil && id != "" && id != before
+	}); err != nil {
+		return fmt.Errorf("Error waiting for the host to reboot into the new snapshot: %s", err)
+	}
+
+	return nil
+}
+
+// transactionalUpdate changes the packages in a new snapshot, reboots into it
+// and checks the change took, as transactional-update succeeds without
+// changing anything when a package doesn't exist.
+func (provisioner *TransactionalProvisioner) transactionalUpdate(action pkgaction.PackageAction, packages []string) error {
+	if _, err := provisioner.SSHCommand(transactionalUpdateCommand(action, packages)); err != nil {
+		return err
+	}
+
+	if err := provisioner.reboot(); err != nil {
+		return err
+	}
+
+	if action != pkgaction.Install {
+		return nil
+	}
+
+	if missing := provisioner.missingPackages(packages); len(missing) > 0 {
+		return fmt.Errorf("%s not installed after rebooting into the new snapshot", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Package installs, removes or upgrades the package in a new snapshot and
+// reboots into it. Installing an installed package doesn't reboot.
+func (provisioner *TransactionalProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if action == pkgaction.Install && len(provisioner.missingPackages([]string{name})) == 0 {
+		return nil
+	}
+
+	return provisioner.transactionalUpdate(action, []string{name})
+}
+
+func (provisioner *TransactionalProvisioner) Provision(swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	// The engine comes from the repositories of the distribution, the
+	// script of the install URL can't install it on a read-only root
+	// filesystem.
+	packages := append([]string{}, provisioner.Packages...)
+	if engineOptions.InstallURL != "none" {
+		packages = append(packages, "docker")
+	}
+
+	// Every package is installed in the same snapshot, for the host to
+	// reboot once.
+	if missing := provisioner.missingPackages(packages); len(missing) > 0 {
+		log.Infof("Installing %s in a new snapshot...", strings.Join(missing, ", "))
+		if err := provisioner.transactionalUpdate(pkgaction.Install, missing); err != nil {
+			return err
+		}
+	}
+
+	if _, err := provisioner.SSHCommand("sudo systemctl enable --now docker"); err != nil {
+		return err
+	}
+
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitDaemon, mcnutils.WaitSettings{}, provisioner.dockerDaemonResponding); err != nil {
+		return err
+	}
+
+	if _, err := provisioner.SSHCommand("sudo systemctl stop docker"); err != nil {
+		return err
+	}
+
+	// open the firewall port required by docker, when there is a firewall
+	if _, err := provisioner.SSHCommand("if command -v firewall-cmd >/dev/null && sudo firewall-cmd --state >/dev/null 2>&1; then sudo firewall-cmd --permanent --add-port=2376/tcp && sudo firewall-cmd --reload; fi"); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+		return err
+	}
+
+	return nil
+}
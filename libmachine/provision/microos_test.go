@@ -0,0 +1,46 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+func TestTransactionalProvisionerCompatibleWithHost(t *testing.T) {
+	for _, tc := range []struct {
+		info     OsRelease
+		new      func(d drivers.Driver) Provisioner
+		expected bool
+	}{
+		{OsRelease{Id: "opensuse-microos", IdLike: "suse opensuse opensuse-tumbleweed"}, NewMicroOSProvisioner, true},
+		{OsRelease{Id: "opensuse-leap-micro", IdLike: "suse opensuse"}, NewLeapMicroProvisioner, true},
+		{OsRelease{Id: "sle-micro", IdLike: "suse"}, NewSLEMicroProvisioner, true},
+		{OsRelease{Id: "opensuse-microos"}, NewOpenSUSEProvisioner, false},
+		{OsRelease{Id: "sles"}, NewSLEMicroProvisioner, false},
+	} {
+		info := tc.info
+		p := tc.new(nil)
+		p.SetOsReleaseInfo(&info)
+
+		if p.CompatibleWithHost() != tc.expected {
+			t.Fatalf("expected compatibility %v with %s", tc.expected, tc.info.Id)
+		}
+	}
+}
+
+func TestTransactionalUpdateCommand(t *testing.T) {
+	for _, tc := range []struct {
+		action   pkgaction.PackageAction
+		packages []string
+		expected string
+	}{
+		{pkgaction.Install, []string{"curl", "docker"}, "sudo transactional-update --non-interactive pkg install curl docker"},
+		{pkgaction.Remove, []string{"docker"}, "sudo transactional-update --non-interactive pkg remove docker"},
+		{pkgaction.Upgrade, []string{"docker"}, "sudo transactional-update --non-interactive pkg update docker"},
+	} {
+		if actual := transactionalUpdateCommand(tc.action, tc.packages); actual != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// nfsMountOptions mount the shares for local development: without locks,
+// which would need the rpc.statd of the machine running, and without
+// waiting for the writes to reach the disk of the local host.
+const nfsMountOptions = "rw,async,noatime,nfsvers=3,tcp,nolock"
+
+// NFSMounter is implemented by provisioners which mount NFS shares their own
+// way.
+type NFSMounter interface {
+	MountNFS(source, target string) error
+	UnmountNFS(target string) error
+}
+
+// mountNFSScript mounts the share with an fstab entry for it to be mounted
+// again on boot, replacing the entry and the mount of a previous share on
+// the same target.
+const mountNFSScript = `set -e
+mkdir -p %[2]s
+sed -i "\| %[2]s nfs |d" /etc/fstab
+echo "%[1]s %[2]s nfs %[3]s,nofail,_netdev 0 0" >> /etc/fstab
+if mountpoint -q %[2]s; then umount %[2]s; fi
+mount %[2]s`
+
+const unmountNFSScript = `sed -i "\| %[1]s nfs |d" /etc/fstab
+if mountpoint -q %[1]s; then umount %[1]s; fi`
+
+func mountNFSCommand(source, target string) string {
+	return fmt.Sprintf("sh -c '"+mountNFSScript+"'", source, target, nfsMountOptions)
+}
+
+func unmountNFSCommand(target string) string {
+	return fmt.Sprintf("sh -c '"+unmountNFSScript+"'", target)
+}
+
+// nfsClientPackage returns the package of the NFS client of the
+// distribution.
+func nfsClientPackage(info *OsRelease) string {
+	for _, id := range append([]string{info.Id}, strings.Fields(info.IdLike)...) {
+		switch id {
+		case "debian", "ubuntu":
+			return "nfs-common"
+		case "opensuse", "suse", "sles":
+			return "nfs-client"
+		}
+	}
+	return "nfs-utils"
+}
+
+// MountNFS mounts the NFS share at source, like 192.168.99.1:/home/me/src,
+// on target in the host, installing the NFS client if needed.
+func MountNFS(p Provisioner, source, target string) error {
+	log.Infof("Mounting %s on %s...", source, target)
+
+	if mounter, ok := p.(NFSMounter); ok {
+		return mounter.MountNFS(source, target)
+	}
+
+	driver := p.GetDriver()
+
+	if _, err := p.SSHCommand(driver.SSHSudo("sh -c 'command -v mount.nfs'")); err != nil {
+		info, err := p.GetOsReleaseInfo()
+		if err != nil {
+			return err
+		}
+
+		pkg := nfsClientPackage(info)
+		log.Infof("Installing %s...", pkg)
+		if err := p.Package(pkg, pkgaction.Install); err != nil {
+			return fmt.Errorf("Error installing the NFS client: %s", err)
+		}
+	}
+
+	if _, err := p.SSHCommand(driver.SSHSudo(mountNFSCommand(source, target))); err != nil {
+		return fmt.Errorf("Error mounting %s: %s", source, err)
+	}
+	return nil
+}
+
+// UnmountNFS unmounts the NFS share mounted on target in the host, for it
+// not to be mounted again on boot.
+func UnmountNFS(p Provisioner, target string) error {
+	log.Infof("Unmounting %s...", target)
+
+	if mounter, ok := p.(NFSMounter); ok {
+		return mounter.UnmountNFS(target)
+	}
+
+	if _, err := p.SSHCommand(p.GetDriver().SSHSudo(unmountNFSCommand(target))); err != nil {
+		return fmt.Errorf("Error unmounting %s: %s", target, err)
+	}
+	return nil
+}
+
+// boot2dockerBootlocal is run by boot2docker on boot, its root filesystem
+// being in memory.
+const boot2dockerBootlocal = "/var/lib/boot2docker/bootlocal.sh"
+
+// boot2dockerMountNFSScript starts the NFS client and mounts the share,
+// adding the commands doing so to bootlocal.sh in a block of the target for
+// the share to be mounted again on boot.
+const boot2dockerMountNFSScript = `set -e
+sed -i "\|^# BEGIN docker-machine nfs %[2]s$|,\|^# END docker-machine nfs %[2]s$|d" %[4]s 2>/dev/null || true
+echo "# BEGIN docker-machine nfs %[2]s" >> %[4]s
+echo "/usr/local/etc/init.d/nfs-client start" >> %[4]s
+echo "mkdir -p %[2]s" >> %[4]s
+echo "mountpoint -q %[2]s || mount -t nfs -o %[3]s %[1]s %[2]s" >> %[4]s
+echo "# END docker-machine nfs %[2]s" >> %[4]s
+chmod +x %[4]s
+/usr/local/etc/init.d/nfs-client start
+mkdir -p %[2]s
+if mountpoint -q %[2]s; then umount %[2]s; fi
+mount -t nfs -o %[3]s %[1]s %[2]s`
+
+const boot2dockerUnmountNFSScript = `sed -i "\|^# BEGIN docker-machine nfs %[1]s$|,\|^# END docker-machine nfs %[1]s$|d" %[2]s 2>/dev/null
+if mountpoint -q %[1]s; then umount %[1]s; fi`
+
+// MountNFS mounts the share from bootlocal.sh, boot2docker having no fstab
+// surviving a reboot.
+func (provisioner *Boot2DockerProvisioner) MountNFS(source, target string) error {
+	command := fmt.Sprintf("sudo sh -c '"+boot2dockerMountNFSScript+"'", source, target, nfsMountOptions, boot2dockerBootlocal)
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return fmt.Errorf("Error mounting %s: %s", source, err)
+	}
+	return nil
+}
+
+// UnmountNFS unmounts the share and removes it from bootlocal.sh.
+func (provisioner *Boot2DockerProvisioner) UnmountNFS(target string) error {
+	command := fmt.Sprintf("sudo sh -c '"+boot2dockerUnmountNFSScript+"'", target, boot2dockerBootlocal)
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return fmt.Errorf("Error unmounting %s: %s", target, err)
+	}
+	return nil
+}
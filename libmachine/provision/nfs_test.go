@@ -0,0 +1,52 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMountNFSCommand(t *testing.T) {
+	command := mountNFSCommand("192.168.99.1:/home/me/src", "/src")
+
+	for _, expected := range []string{
+		"mkdir -p /src",
+		`sed -i "\| /src nfs |d" /etc/fstab`,
+		`echo "192.168.99.1:/home/me/src /src nfs ` + nfsMountOptions + `,nofail,_netdev 0 0" >> /etc/fstab`,
+		"if mountpoint -q /src; then umount /src; fi",
+		"mount /src",
+	} {
+		if !strings.Contains(command, expected) {
+			t.Fatalf("expected %q in %s", expected, command)
+		}
+	}
+
+	if !strings.HasPrefix(command, "sh -c '") || strings.Count(command, "'") != 2 {
+		t.Fatalf("expected the script to be single quoted, got %s", command)
+	}
+}
+
+func TestUnmountNFSCommand(t *testing.T) {
+	command := unmountNFSCommand("/src")
+
+	if !strings.Contains(command, `sed -i "\| /src nfs |d" /etc/fstab`) || !strings.Contains(command, "umount /src") {
+		t.Fatalf("expected the fstab entry of /src to be removed and /src unmounted, got %s", command)
+	}
+}
+
+func TestNFSClientPackage(t *testing.T) {
+	for _, tc := range []struct {
+		info     OsRelease
+		expected string
+	}{
+		{OsRelease{Id: "ubuntu", IdLike: "debian"}, "nfs-common"},
+		{OsRelease{Id: "raspbian", IdLike: "debian"}, "nfs-common"},
+		{OsRelease{Id: "opensuse-leap", IdLike: "suse opensuse"}, "nfs-client"},
+		{OsRelease{Id: "centos", IdLike: "rhel fedora"}, "nfs-utils"},
+		{OsRelease{Id: "arch"}, "nfs-utils"},
+	} {
+		info := tc.info
+		if actual := nfsClientPackage(&info); actual != tc.expected {
+			t.Fatalf("expected %s for %s, got %s", tc.expected, tc.info.Id, actual)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package provision
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// packageCacheFile holds the URL of the package cache the host uses,
+	// for the cache to be removed once the host is provisioned.
+	packageCacheFile    = "/etc/docker-machine-package-cache"
+	aptPackageCacheFile = "/etc/apt/apt.conf.d/01docker-machine-package-cache"
+
+	// dockerDownloadURL is the repository the engine is installed from
+	// while the package cache is used, over HTTP for the cache to see the
+	// packages, the cache downloading them over HTTPS.
+	dockerDownloadURL = "http://download.docker.com"
+)
+
+// yumConfigFiles are the configs of yum and dnf, either of which the hosts
+// of the Red Hat family have.
+var yumConfigFiles = []string{"/etc/yum.conf", "/etc/dnf/dnf.conf"}
+
+// ConfigurePackageCache has apt, yum and dnf download the packages through
+// the caching proxy at address, until RemovePackageCache. The host of an
+// address without one is the host the SSH connections come from, where
+// docker-machine runs.
+func ConfigurePackageCache(p Provisioner, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("Invalid package cache address %q: %s", address, err)
+	}
+
+	if host == "" {
+		output, err := p.SSHCommand(`echo "${SSH_CLIENT%% *}"`)
+		if err != nil {
+			return err
+		}
+		host = strings.TrimSpace(output)
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("Unexpected SSH client IP %q", host)
+		}
+	}
+
+	url := "http://" + net.JoinHostPort(host, port)
+	_, err = p.SSHCommand(p.GetDriver().SSHSudo(packageCacheCommand(url)))
+	return err
+}
+
+// RemovePackageCache has the package managers download the packages
+// directly again.
+func RemovePackageCache(p Provisioner) error {
+	_, err := p.SSHCommand(p.GetDriver().SSHSudo(removePackageCacheCommand()))
+	return err
+}
+
+func packageCacheCommand(url string) string {
+	return fmt.Sprintf(`sh -c 'echo %[1]s > %[2]s; if [ -d /etc/apt/apt.conf.d ]; then echo "Acquire::http::Proxy \"%[1]s\";" > %[3]s; fi; for conf in %[4]s; do if [ -f $conf ]; then echo "proxy=%[1]s" >> $conf; fi; done'`,
+		url, packageCacheFile, aptPackageCacheFile, strings.Join(yumConfigFiles, " "))
+}
+
+func removePackageCacheCommand() string {
+	return fmt.Sprintf(`sh -c 'if [ -f %[1]s ]; then url=$(cat %[1]s); for conf in %[3]s; do if [ -f $conf ]; then sed -i "\|^proxy=$url\$|d" $conf; fi; done; rm -f %[1]s %[2]s; fi'`,
+		packageCacheFile, aptPackageCacheFile, strings.Join(yumConfigFiles, " "))
+}
+
+// dockerDownloadURLCommand is the shell expression of the repository the
+// install script installs the engine from, the default one unless the
+// package cache is used.
+func dockerDownloadURLCommand() string {
+	return fmt.Sprintf("$(test -f %s && echo %s)", packageCacheFile, dockerDownloadURL)
+}
@@ -0,0 +1,39 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackageCacheCommand(t *testing.T) {
+	command := packageCacheCommand("http://192.168.99.1:3142")
+
+	for _, expected := range []string{
+		"echo http://192.168.99.1:3142 > " + packageCacheFile,
+		`echo "Acquire::http::Proxy \"http://192.168.99.1:3142\";" > ` + aptPackageCacheFile,
+		"for conf in /etc/yum.conf /etc/dnf/dnf.conf",
+		`echo "proxy=http://192.168.99.1:3142" >> $conf`,
+	} {
+		if !strings.Contains(command, expected) {
+			t.Fatalf("expected %q in %s", expected, command)
+		}
+	}
+
+	if !strings.HasPrefix(command, "sh -c '") || strings.Count(command, "'") != 2 {
+		t.Fatalf("expected the script to be single quoted, got %s", command)
+	}
+}
+
+func TestRemovePackageCacheCommand(t *testing.T) {
+	command := removePackageCacheCommand()
+
+	for _, expected := range []string{
+		"url=$(cat " + packageCacheFile + ")",
+		`sed -i "\|^proxy=$url\$|d" $conf`,
+		"rm -f " + packageCacheFile + " " + aptPackageCacheFile,
+	} {
+		if !strings.Contains(command, expected) {
+			t.Fatalf("expected %q in %s", expected, command)
+		}
+	}
+}
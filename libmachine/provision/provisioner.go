@@ -51,7 +51,8 @@ type Provisioner interface {
 	// Get the driver which is contained in the provisioner.
 	GetDriver() drivers.Driver
 
-	// Short-hand for accessing an SSH command from the driver.
+	// Short-hand for running a command with the transport of the driver,
+	// SSH unless the driver is a drivers.Transporter.
 	SSHCommand(args string) (string, error)
 
 	// Set the OS Release info depending on how it's represented
@@ -72,7 +73,7 @@ func Register(name string, p *RegisteredProvisioner) {
 }
 
 func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
-	osReleaseOut, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
+	osReleaseOut, err := drivers.RunCommandFromDriver(d, "cat /etc/os-release")
 	if err != nil {
 		return nil, fmt.Errorf("Error getting SSH command: %s", err)
 	}
@@ -0,0 +1,83 @@
+package provision
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// mockTransportDriver runs the commands of the provisioners with a mock
+// transport, recording them, and answering those it has an output for.
+type mockTransportDriver struct {
+	*fakedriver.Driver
+	outputs  map[string]string
+	commands []string
+}
+
+func newMockTransportDriver(outputs map[string]string) *mockTransportDriver {
+	return &mockTransportDriver{
+		Driver:  &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}},
+		outputs: outputs,
+	}
+}
+
+func (d *mockTransportDriver) RunCommand(command string) (string, error) {
+	d.commands = append(d.commands, command)
+	if output, ok := d.outputs[command]; ok {
+		return output, nil
+	}
+	return "", nil
+}
+
+func (d *mockTransportDriver) RunCommandWithInput(command string, input []byte) (string, error) {
+	return d.RunCommand(command)
+}
+
+func TestDetectProvisionerWithTransport(t *testing.T) {
+	d := newMockTransportDriver(map[string]string{
+		"cat /etc/os-release": "NAME=\"CentOS Linux\"\nID=\"centos\"\nID_LIKE=\"rhel fedora\"\nVERSION_ID=\"7\"\n",
+	})
+
+	provisioner, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := provisioner.(*CentosProvisioner); !ok {
+		t.Fatalf("Expected a CentOS provisioner, got %T", provisioner)
+	}
+
+	// Red Hat provisioners allocate a TTY over SSH, not with a transport.
+	if err := provisioner.SetHostname("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.commands) < 2 {
+		t.Fatalf("Expected the hostname to be set with the transport, got %q", d.commands)
+	}
+	if expected := "sudo sh -c 'hostname test && echo \"test\" | tee /etc/hostname'"; d.commands[1] != expected {
+		t.Fatalf("Expected %q, got %q", expected, d.commands[1])
+	}
+}
+
+func TestDetectProvisionerWithTransportError(t *testing.T) {
+	d := &failingTransportDriver{newMockTransportDriver(nil)}
+
+	if _, err := DetectProvisioner(d); err == nil {
+		t.Fatal("Expected the failing transport to fail the detection")
+	}
+}
+
+type failingTransportDriver struct {
+	*mockTransportDriver
+}
+
+func (d *failingTransportDriver) RunCommand(command string) (string, error) {
+	return "", fmt.Errorf("no route to host")
+}
+
+func (d *failingTransportDriver) RunCommandWithInput(command string, input []byte) (string, error) {
+	return d.RunCommand(command)
+}
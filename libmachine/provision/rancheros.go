@@ -92,6 +92,10 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.SwarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 
+	if err := checkFixedOptionsPaths("RancherOS", engineOptions); err != nil {
+		return err
+	}
+
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "overlay"
 	} else if provisioner.EngineOptions.StorageDriver != "overlay" {
@@ -169,7 +173,7 @@ func (provisioner *RancherProvisioner) upgradeIso() error {
 		return err
 	}
 
-	if err := mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
 		return err
 	}
 
@@ -202,7 +206,7 @@ func (provisioner *RancherProvisioner) upgradeIso() error {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Running))
+	return drivers.WaitForStage(provisioner.Driver, mcnutils.WaitState, mcnutils.WaitSettings{}, drivers.MachineInState(provisioner.Driver, state.Running))
 }
 
 func (provisioner *RancherProvisioner) getLatestISOURL() (string, error) {
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"text/template"
+	"time"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -20,9 +20,16 @@ import (
 var (
 	ErrUnknownYumOsRelease = errors.New("unknown OS for Yum repository")
 
+	// yumRepos maps the engine channels to their repository.
+	yumRepos = map[string]string{
+		EngineChannelStable:       "main",
+		EngineChannelTest:         "testing",
+		EngineChannelExperimental: "experimental",
+	}
+
 	packageListTemplate = `[docker]
-name=Docker Stable Repository
-baseurl=https://yum.dockerproject.org/repo/main/{{.OsRelease}}/{{.OsReleaseVersion}}
+name=Docker {{.Repo}} Repository
+baseurl=https://yum.dockerproject.org/repo/{{.Repo}}/{{.OsRelease}}/{{.OsReleaseVersion}}
 priority=1
 enabled=1
 gpgkey=https://yum.dockerproject.org/gpg
@@ -40,6 +47,7 @@ Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 type PackageListInfo struct {
 	OsRelease        string
 	OsReleaseVersion string
+	Repo             string
 }
 
 func init() {
@@ -67,6 +75,10 @@ type RedHatProvisioner struct {
 }
 
 func (provisioner *RedHatProvisioner) SSHCommand(args string) (string, error) {
+	if drivers.SupportsTransport(provisioner.Driver) {
+		return drivers.RunCommandFromDriver(provisioner.Driver, args)
+	}
+
 	client, err := drivers.GetSSHClientFromDriver(provisioner.Driver)
 	if err != nil {
 		return "", err
@@ -76,15 +88,21 @@ func (provisioner *RedHatProvisioner) SSHCommand(args string) (string, error) {
 	// external client and add as needed.
 	// Note: CentOS 7.0 needs multiple "-tt" to force tty allocation when ssh has
 	// no local tty.
+	var output string
+	start := time.Now()
 	switch c := client.(type) {
 	case ssh.ExternalClient:
 		c.BaseArgs = append(c.BaseArgs, "-tt")
-		client = c
+		output, err = c.Output(args)
 	case ssh.NativeClient:
-		return c.OutputWithPty(args)
+		output, err = c.OutputWithPty(args)
+	default:
+		output, err = client.Output(args)
 	}
 
-	return client.Output(args)
+	drivers.TranscribeSSHCommand(provisioner.Driver, args, output, err, time.Since(start))
+
+	return output, err
 }
 
 func (provisioner *RedHatProvisioner) SetHostname(hostname string) error {
@@ -242,7 +260,7 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		return err
 	}
 
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := drivers.WaitForStage(provisioner.Driver, mcnutils.WaitDaemon, mcnutils.WaitSettings{}, provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
@@ -266,7 +284,7 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
 	var (
 		engineCfg  bytes.Buffer
-		configPath = provisioner.DaemonOptionsFile
+		configPath = provisioner.daemonOptionsFile()
 	)
 
 	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
@@ -274,7 +292,7 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 
 	// systemd / redhat will not load options if they are on newlines
 	// instead, it just continues with a different set of options; yeah...
-	t, err := template.New("engineConfig").Parse(engineConfigTemplate)
+	t, err := parseTemplate(provisioner.OsReleaseInfo, engineConfigTemplateFile, engineConfigTemplate)
 	if err != nil {
 		return nil, err
 	}
@@ -283,10 +301,12 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
 		EngineOptions:    provisioner.EngineOptions,
-		DockerOptionsDir: provisioner.DockerOptionsDir,
+		DockerOptionsDir: provisioner.GetDockerOptionsDir(),
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	daemonOptsDir := configPath
 	return &DockerOptions{
@@ -296,7 +316,13 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 }
 
 func generateYumRepoList(provisioner Provisioner) (*bytes.Buffer, error) {
-	packageListInfo := &PackageListInfo{}
+	return generateYumRepoListForChannel(provisioner, EngineChannelStable)
+}
+
+func generateYumRepoListForChannel(provisioner Provisioner, channel string) (*bytes.Buffer, error) {
+	packageListInfo := &PackageListInfo{
+		Repo: yumRepos[channel],
+	}
 
 	releaseInfo, err := provisioner.GetOsReleaseInfo()
 	if err != nil {
@@ -315,7 +341,7 @@ func generateYumRepoList(provisioner Provisioner) (*bytes.Buffer, error) {
 		return nil, ErrUnknownYumOsRelease
 	}
 
-	t, err := template.New("packageList").Parse(packageListTemplate)
+	t, err := parseTemplate(releaseInfo, packageListTemplateFile, packageListTemplate)
 	if err != nil {
 		return nil, err
 	}
@@ -330,7 +356,11 @@ func generateYumRepoList(provisioner Provisioner) (*bytes.Buffer, error) {
 }
 
 func (provisioner *RedHatProvisioner) ConfigurePackageList() error {
-	buf, err := generateYumRepoList(provisioner)
+	return provisioner.configurePackageList(EngineChannelStable)
+}
+
+func (provisioner *RedHatProvisioner) configurePackageList(channel string) error {
+	buf, err := generateYumRepoListForChannel(provisioner, channel)
 	if err != nil {
 		return err
 	}
@@ -26,3 +26,25 @@ func TestRedHatGenerateYumRepoList(t *testing.T) {
 		t.Fatalf("expected match for centos/7")
 	}
 }
+
+func TestRedHatGenerateYumRepoListForChannel(t *testing.T) {
+	info := &OsRelease{
+		Id: "rhel",
+	}
+	p := NewRedHatProvisioner(nil)
+	p.SetOsReleaseInfo(info)
+
+	buf, err := generateYumRepoListForChannel(p, EngineChannelExperimental)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := regexp.MatchString("baseurl=.*/repo/experimental/centos/7", buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m {
+		t.Fatalf("expected match for the experimental repository, got %s", buf.String())
+	}
+}
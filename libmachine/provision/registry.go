@@ -0,0 +1,238 @@
+package provision
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// registryCertsDir is where the engine looks for the CA certificate of a
+// registry, in the directory named after its host.
+const registryCertsDir = "/etc/docker/certs.d"
+
+// RegistryHost returns the host, and port if any, of a registry given as a
+// host, like mirror.example.com:5000, or as a URL, like the registry mirrors.
+func RegistryHost(registry string) string {
+	if u, err := url.Parse(registry); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return strings.TrimSuffix(registry, "/")
+}
+
+// splitRegistryOption splits a registry option of the engine options, as
+// registry=value, the registry being given as in RegistryHost.
+func splitRegistryOption(option string) (string, string, error) {
+	i := strings.Index(option, "=")
+	if i <= 0 || i == len(option)-1 {
+		return "", "", fmt.Errorf("%q is not registry=value", option)
+	}
+	return RegistryHost(option[:i]), option[i+1:], nil
+}
+
+// RegistryEngineOptions returns the engine options with the registry CA
+// certificates given by their absolute path, so that the machine can be
+// provisioned again from another directory, and the registries given by
+// their host. The certificates are read, for an unreadable one to fail now
+// rather than while provisioning.
+func RegistryEngineOptions(engineOptions engine.EngineOptions) (engine.EngineOptions, error) {
+	cas := []string{}
+	for _, option := range engineOptions.RegistryCA {
+		host, caPath, err := splitRegistryOption(option)
+		if err != nil {
+			return engineOptions, fmt.Errorf("invalid registry CA certificate: %s", err)
+		}
+
+		if caPath, err = filepath.Abs(caPath); err != nil {
+			return engineOptions, err
+		}
+		if _, err := readRegistryCA(caPath); err != nil {
+			return engineOptions, err
+		}
+
+		cas = append(cas, host+"="+caPath)
+	}
+	engineOptions.RegistryCA = cas
+
+	auths := []string{}
+	for _, option := range engineOptions.RegistryAuth {
+		host, credentials, err := splitRegistryOption(option)
+		if err != nil {
+			return engineOptions, fmt.Errorf("invalid registry credentials: %s", err)
+		}
+		if i := strings.Index(credentials, ":"); i <= 0 || i == len(credentials)-1 {
+			return engineOptions, fmt.Errorf("invalid registry credentials of %s: expected user:password", host)
+		}
+
+		auths = append(auths, host+"="+credentials)
+	}
+	engineOptions.RegistryAuth = auths
+
+	return engineOptions, nil
+}
+
+// readRegistryCA reads the CA certificate at path, in PEM.
+func readRegistryCA(path string) ([]byte, error) {
+	ca, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the registry CA certificate: %s", err)
+	}
+
+	if block, _ := pem.Decode(ca); block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("Error reading the registry CA certificate: %s is not a PEM certificate", path)
+	}
+
+	return ca, nil
+}
+
+// ConfigureRegistries installs the CA certificates of the registries of the
+// engine options in /etc/docker/certs.d, for the engine to trust them, like
+// registry mirrors with a private CA, and logs the docker CLI of root and of
+// the SSH user in to the registries with credentials. It is run every time
+// the engine is provisioned or upgraded, for them not to be lost on hosts
+// whose /etc doesn't persist, like boot2docker.
+func ConfigureRegistries(p Provisioner, engineOptions engine.EngineOptions) error {
+	if len(engineOptions.RegistryCA) == 0 && len(engineOptions.RegistryAuth) == 0 {
+		return nil
+	}
+
+	driver := p.GetDriver()
+
+	for _, option := range engineOptions.RegistryCA {
+		host, caPath, err := splitRegistryOption(option)
+		if err != nil {
+			return err
+		}
+
+		ca, err := readRegistryCA(caPath)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Installing the CA certificate of the registry %s...", host)
+
+		dir := path.Join(registryCertsDir, host)
+		if _, err := p.SSHCommand(driver.SSHSudo("mkdir -p " + dir)); err != nil {
+			return err
+		}
+		if err := writeFile(p, path.Join(dir, "ca.crt"), string(ca)); err != nil {
+			return err
+		}
+	}
+
+	if len(engineOptions.RegistryAuth) == 0 {
+		return nil
+	}
+
+	auths := map[string]string{}
+	for _, option := range engineOptions.RegistryAuth {
+		host, credentials, err := splitRegistryOption(option)
+		if err != nil {
+			return err
+		}
+		auths[host] = credentials
+	}
+
+	log.Infof("Logging the docker CLI in to the registries %s...", strings.Join(sortedKeys(auths), ", "))
+
+	// The config of root is the one of the commands run with sudo, the other
+	// is the one of the commands run over SSH.
+	configs := []struct {
+		dir string
+		run func(string) string
+	}{
+		{"/root/.docker", driver.SSHSudo},
+		{"$HOME/.docker", func(command string) string { return command }},
+	}
+	for _, c := range configs {
+		if err := writeDockerConfig(p, c.dir, c.run, auths); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDockerConfig adds the credentials to the docker CLI config in dir,
+// run with run. The config, which holds credentials, is neither logged nor
+// transcribed, nor given on the command line: it is written by the SSH user
+// on stdin to a file only that user can read, and copied in place from
+// there, for it not to be piped to sudo, which may read its password on
+// stdin.
+func writeDockerConfig(p Provisioner, dir string, run func(string) string, credentials map[string]string) error {
+	driver := p.GetDriver()
+	configPath := dir + "/config.json"
+
+	existing, err := drivers.RunSecretCommandFromDriver(driver, run(fmt.Sprintf("sh -c 'cat %s 2>/dev/null || true'", configPath)), nil)
+	if err != nil {
+		return err
+	}
+
+	config, err := loginDockerConfig(existing, credentials)
+	if err != nil {
+		return fmt.Errorf("Error updating %s: %s", configPath, err)
+	}
+
+	tmp, err := p.SSHCommand("umask 077 && mktemp")
+	if err != nil {
+		return err
+	}
+	tmp = strings.TrimSpace(tmp)
+	defer p.SSHCommand("rm -f " + tmp)
+
+	if _, err := drivers.RunSecretCommandFromDriver(driver, "cat > "+tmp, []byte(config)); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("sh -c 'mkdir -p %s && cp %s %s && chmod 600 %s'", dir, tmp, configPath, configPath)
+	_, err = p.SSHCommand(run(command))
+	return err
+}
+
+// loginDockerConfig returns the docker CLI config with the credentials of
+// the registries, as user:password by registry host, added to the other
+// settings and logins of the existing config, if any.
+func loginDockerConfig(existing string, credentials map[string]string) (string, error) {
+	config := map[string]interface{}{}
+	if strings.TrimSpace(existing) != "" {
+		if err := json.Unmarshal([]byte(existing), &config); err != nil {
+			return "", err
+		}
+	}
+
+	auths, ok := config["auths"].(map[string]interface{})
+	if !ok {
+		auths = map[string]interface{}{}
+	}
+	for host, userPassword := range credentials {
+		auths[host] = map[string]interface{}{
+			"auth": base64.StdEncoding.EncodeToString([]byte(userPassword)),
+		}
+	}
+	config["auths"] = auths
+
+	out, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
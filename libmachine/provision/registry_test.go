@@ -0,0 +1,206 @@
+package provision
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"golang.org/x/net/context"
+)
+
+const testRegistryCA = "-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIJAKc=\n-----END CERTIFICATE-----\n"
+
+func TestRegistryHost(t *testing.T) {
+	cases := map[string]string{
+		"mirror.example.com":               "mirror.example.com",
+		"mirror.example.com:5000":          "mirror.example.com:5000",
+		"https://mirror.example.com":       "mirror.example.com",
+		"https://mirror.example.com:5000/": "mirror.example.com:5000",
+	}
+
+	for registry, expected := range cases {
+		if host := RegistryHost(registry); host != expected {
+			t.Fatalf("expected the host of %q to be %q, got %q", registry, expected, host)
+		}
+	}
+}
+
+func TestRegistryEngineOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caPath, []byte(testRegistryCA), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := RegistryEngineOptions(engine.EngineOptions{
+		RegistryCA:   []string{"https://mirror.example.com:5000=" + caPath},
+		RegistryAuth: []string{"mirror.example.com:5000=ci:s3cr=t"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"mirror.example.com:5000=" + caPath}; !reflect.DeepEqual(options.RegistryCA, expected) {
+		t.Fatalf("expected the registry CA certificates %v, got %v", expected, options.RegistryCA)
+	}
+	if expected := []string{"mirror.example.com:5000=ci:s3cr=t"}; !reflect.DeepEqual(options.RegistryAuth, expected) {
+		t.Fatalf("expected the registry credentials %v, got %v", expected, options.RegistryAuth)
+	}
+}
+
+func TestRegistryEngineOptionsErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range []engine.EngineOptions{
+		{RegistryCA: []string{"mirror.example.com"}},
+		{RegistryCA: []string{"mirror.example.com=" + filepath.Join(dir, "missing.pem")}},
+		{RegistryCA: []string{"mirror.example.com=" + notPEM}},
+		{RegistryAuth: []string{"mirror.example.com=ci"}},
+		{RegistryAuth: []string{"=ci:secret"}},
+	} {
+		if _, err := RegistryEngineOptions(options); err == nil {
+			t.Fatalf("expected %+v to be invalid", options)
+		}
+	}
+}
+
+func TestLoginDockerConfig(t *testing.T) {
+	existing := `{"auths": {"registry.example.com": {"auth": "b2xkOm9sZA=="}}, "detachKeys": "ctrl-e,e"}`
+
+	out, err := loginDockerConfig(existing, map[string]string{"mirror.example.com:5000": "ci:secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(out), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"auths": map[string]interface{}{
+			"registry.example.com":    map[string]interface{}{"auth": "b2xkOm9sZA=="},
+			"mirror.example.com:5000": map[string]interface{}{"auth": "Y2k6c2VjcmV0"},
+		},
+		"detachKeys": "ctrl-e,e",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected the config %v, got %v", expected, config)
+	}
+
+	if _, err := loginDockerConfig("", map[string]string{"mirror.example.com": "ci:secret"}); err != nil {
+		t.Fatalf("expected a config to be created, got %s", err)
+	}
+}
+
+func TestConfigureRegistriesWithoutRegistries(t *testing.T) {
+	// Without registry options, nothing is run on the host.
+	p := &Boot2DockerProvisioner{Driver: &fakedriver.Driver{}}
+
+	if err := ConfigureRegistries(p, engine.EngineOptions{RegistryMirror: []string{"https://mirror.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commandDriver is a fake driver running the commands on its host with its
+// own transport, answering them with the output of the first command
+// prefix they start with.
+type commandDriver struct {
+	*fakedriver.Driver
+	outputs  map[string]string
+	commands []string
+	inputs   map[string]string
+}
+
+func (d *commandDriver) RunCommand(command string) (string, error) {
+	d.commands = append(d.commands, command)
+	for prefix, output := range d.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return output, nil
+		}
+	}
+	return "", nil
+}
+
+func (d *commandDriver) RunCommandWithInput(command string, input []byte) (string, error) {
+	if input != nil {
+		d.inputs[command] = string(input)
+	}
+	return d.RunCommand(command)
+}
+
+func TestConfigureRegistriesLeavesCredentialsOut(t *testing.T) {
+	var transcript bytes.Buffer
+	d := &commandDriver{
+		Driver: &fakedriver.Driver{BaseDriver: &drivers.BaseDriver{}},
+		outputs: map[string]string{
+			"umask 077 && mktemp": "/tmp/tmp.abc\n",
+			"sudo sh -c 'cat":     `{"auths": {"other.example.com": {"auth": "b3RoZXI6cGFzc3dvcmQ="}}}`,
+		},
+		inputs: map[string]string{},
+	}
+	p := &Boot2DockerProvisioner{Driver: drivers.WithContext(drivers.WithTranscript(context.Background(), &transcript), d)}
+
+	if err := ConfigureRegistries(p, engine.EngineOptions{RegistryAuth: []string{"mirror.example.com=ci:secret"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config is copied in place from the file it was written to, which
+	// is removed.
+	for _, expected := range []string{
+		"sudo sh -c 'mkdir -p /root/.docker && cp /tmp/tmp.abc /root/.docker/config.json && chmod 600 /root/.docker/config.json'",
+		"rm -f /tmp/tmp.abc",
+	} {
+		found := false
+		for _, command := range d.commands {
+			found = found || command == expected
+		}
+		if !found {
+			t.Fatalf("expected %q to be run, got %q", expected, d.commands)
+		}
+	}
+
+	// The config is given on the stdin of the command writing it, never
+	// on a command line.
+	if !strings.Contains(d.inputs["cat > /tmp/tmp.abc"], "Y2k6c2VjcmV0") {
+		t.Fatalf("expected the config to be written on stdin, got %q", d.inputs)
+	}
+	for _, command := range d.commands {
+		if strings.Contains(command, "Y2k6c2VjcmV0") {
+			t.Fatalf("expected the credentials to be left out of the commands, got %q", command)
+		}
+	}
+
+	// Neither the credentials nor those of the existing config are
+	// transcribed.
+	for _, secret := range []string{"Y2k6c2VjcmV0", "b3RoZXI6cGFzc3dvcmQ="} {
+		if strings.Contains(transcript.String(), secret) {
+			t.Fatalf("expected %s to be left out of the transcript, got %s", secret, transcript.String())
+		}
+	}
+	if !strings.Contains(transcript.String(), "$ cat > /tmp/tmp.abc") {
+		t.Fatalf("expected the commands to be transcribed, got %s", transcript.String())
+	}
+}
@@ -0,0 +1,91 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestSetRemoteAuthOptionsDefaults(t *testing.T) {
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+
+	authOptions := setRemoteAuthOptions(p)
+
+	if authOptions.CaCertRemotePath != "/etc/docker/ca.pem" ||
+		authOptions.ServerCertRemotePath != "/etc/docker/server.pem" ||
+		authOptions.ServerKeyRemotePath != "/etc/docker/server-key.pem" {
+		t.Fatalf("expected the certificates in /etc/docker, got %+v", authOptions)
+	}
+}
+
+func TestSetRemoteAuthOptionsOverrides(t *testing.T) {
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+	p.EngineOptions = engine.EngineOptions{OptionsDir: "/var/lib/docker-etc"}
+	p.AuthOptions = auth.AuthOptions{ServerKeyRemotePath: "/secure/server-key.pem"}
+
+	authOptions := setRemoteAuthOptions(p)
+
+	if authOptions.CaCertRemotePath != "/var/lib/docker-etc/ca.pem" ||
+		authOptions.ServerCertRemotePath != "/var/lib/docker-etc/server.pem" ||
+		authOptions.ServerKeyRemotePath != "/secure/server-key.pem" {
+		t.Fatalf("expected the certificates in /var/lib/docker-etc but the key, got %+v", authOptions)
+	}
+}
+
+func TestGenerateDockerOptionsOptionsFile(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+
+	dockerOptions, err := p.GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dockerOptions.EngineOptionsPath != "/etc/systemd/system/docker.service" {
+		t.Fatalf("expected the options file of Debian, got %s", dockerOptions.EngineOptionsPath)
+	}
+
+	p.EngineOptions.OptionsFile = "/run/systemd/system/docker.service"
+
+	dockerOptions, err = p.GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dockerOptions.EngineOptionsPath != "/run/systemd/system/docker.service" {
+		t.Fatalf("expected the options file of the engine options, got %s", dockerOptions.EngineOptionsPath)
+	}
+}
+
+func TestCheckRemotePaths(t *testing.T) {
+	if err := CheckRemotePaths(engine.EngineOptions{OptionsDir: "/var/lib/docker-etc"}, auth.AuthOptions{CaCertRemotePath: "/secure/ca.pem"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CheckRemotePaths(engine.EngineOptions{}, auth.AuthOptions{ServerCertRemotePath: "certs/server.pem"})
+	if err == nil || err.Error() != `the server certificate of the host must be an absolute path, got "certs/server.pem"` {
+		t.Fatalf("expected a relative path to be refused, got %v", err)
+	}
+}
+
+func TestFixedOptionsPaths(t *testing.T) {
+	p := &Boot2DockerProvisioner{Driver: &fakedriver.Driver{}}
+
+	err := p.Provision(swarm.SwarmOptions{}, auth.AuthOptions{}, engine.EngineOptions{OptionsDir: "/mnt/docker"})
+	if err == nil || err.Error() != "The engine of boot2docker reads its options from fixed paths, they cannot be changed" {
+		t.Fatalf("expected the options dir to be refused, got %v", err)
+	}
+}
+
+func TestAuditedPaths(t *testing.T) {
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+	if len(auditedPaths(p)) != len(auditedDockerPaths) {
+		t.Fatalf("expected /etc/docker to be audited once, got %v", auditedPaths(p))
+	}
+
+	p.EngineOptions.OptionsDir = "/var/lib/docker-etc"
+	paths := auditedPaths(p)
+	if len(paths) != len(auditedDockerPaths)+1 || paths[len(paths)-1] != "/var/lib/docker-etc" {
+		t.Fatalf("expected /var/lib/docker-etc to be audited, got %v", paths)
+	}
+}
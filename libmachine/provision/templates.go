@@ -0,0 +1,67 @@
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	// engineConfigTemplateFile replaces the template of the engine
+	// options, like the docker.service unit or /etc/default/docker.
+	engineConfigTemplateFile = "engine-config.tmpl"
+
+	// packageListTemplateFile replaces the template of the repository the
+	// engine is installed from, like the yum repo file.
+	packageListTemplateFile = "package-list.tmpl"
+)
+
+// templatesDir returns the directory of the templates replacing the
+// compiled-in ones, which has a directory by OS ID, like
+// ~/.docker/machine/templates/ubuntu.
+func templatesDir() string {
+	return filepath.Join(mcndirs.GetBaseDir(), "templates")
+}
+
+// parseTemplate parses the file of the templates directory of the OS when
+// there's one, or else the compiled-in template.
+func parseTemplate(info *OsRelease, file, compiled string) (*template.Template, error) {
+	path := overrideTemplatePath(info, file)
+	if path == "" {
+		return template.New(file).Parse(compiled)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return template.New(file).Parse(compiled)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading template %s: %s", path, err)
+	}
+
+	log.Debugf("Using template %s instead of the compiled-in one", path)
+
+	// Naming the template after its file makes the errors of executing it
+	// tell which file is wrong.
+	t, err := template.New(path).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing template %s: %s", path, err)
+	}
+
+	return t, nil
+}
+
+// overrideTemplatePath returns where the file replacing a template is looked
+// for, or "" when the OS isn't known yet.
+func overrideTemplatePath(info *OsRelease, file string) string {
+	if info == nil || info.Id == "" {
+		return ""
+	}
+
+	return filepath.Join(templatesDir(), info.Id, file)
+}
@@ -0,0 +1,149 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+func withTemplatesDir(t *testing.T) func() {
+	tmpDir, err := ioutil.TempDir("", "machine-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseDir := mcndirs.BaseDir
+	mcndirs.BaseDir = tmpDir
+
+	return func() {
+		mcndirs.BaseDir = baseDir
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func writeTemplate(t *testing.T, osID, file, content string) string {
+	dir := filepath.Join(templatesDir(), osID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, file)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTemplateProvisioner(osID string) *RedHatProvisioner {
+	p := NewRedHatProvisioner(&fakedriver.Driver{}).(*RedHatProvisioner)
+	p.SetOsReleaseInfo(&OsRelease{Id: osID})
+	p.AuthOptions = auth.AuthOptions{CaCertRemotePath: "/etc/docker/ca.pem"}
+	p.EngineOptions = engine.EngineOptions{StorageDriver: "overlay2"}
+	return p
+}
+
+func TestGenerateDockerOptionsCompiledInTemplate(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	options, err := newTemplateProvisioner("centos").GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(options.EngineOptions, "--storage-driver overlay2") {
+		t.Fatalf("expected the compiled-in template, got %q", options.EngineOptions)
+	}
+}
+
+func TestGenerateDockerOptionsOverrideTemplate(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	writeTemplate(t, "centos", engineConfigTemplateFile, "port={{.DockerPort}} ca={{.AuthOptions.CaCertRemotePath}} storage={{.EngineOptions.StorageDriver}}\n")
+
+	options, err := newTemplateProvisioner("centos").GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "port=2376 ca=/etc/docker/ca.pem storage=overlay2\n"
+	if options.EngineOptions != expected {
+		t.Fatalf("expected %q, got %q", expected, options.EngineOptions)
+	}
+}
+
+func TestGenerateDockerOptionsOverrideTemplateOfOtherOS(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	writeTemplate(t, "fedora", engineConfigTemplateFile, "fedora\n")
+
+	options, err := newTemplateProvisioner("centos").GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.EngineOptions == "fedora\n" {
+		t.Fatal("expected the template of fedora not to be used for centos")
+	}
+}
+
+func TestGenerateDockerOptionsInvalidOverrideTemplate(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	path := writeTemplate(t, "centos", engineConfigTemplateFile, "{{.DockerPort")
+
+	_, err := newTemplateProvisioner("centos").GenerateDockerOptions(2376)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Fatalf("expected an error naming %s, got %v", path, err)
+	}
+}
+
+func TestGenerateDockerOptionsOverrideTemplateUnknownField(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	path := writeTemplate(t, "centos", engineConfigTemplateFile, "{{.Unknown}}")
+
+	_, err := newTemplateProvisioner("centos").GenerateDockerOptions(2376)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Fatalf("expected an error naming %s, got %v", path, err)
+	}
+}
+
+func TestGenerateYumRepoListOverrideTemplate(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	writeTemplate(t, "rhel", packageListTemplateFile, "[docker]\nbaseurl=https://mirror.example.com/{{.Repo}}/{{.OsRelease}}/{{.OsReleaseVersion}}\n")
+
+	buf, err := generateYumRepoListForChannel(newTemplateProvisioner("rhel"), EngineChannelTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[docker]\nbaseurl=https://mirror.example.com/testing/centos/7\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestParseTemplateUnknownOS(t *testing.T) {
+	defer withTemplatesDir(t)()
+
+	tmpl, err := parseTemplate(nil, engineConfigTemplateFile, "compiled-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "compiled-in" {
+		t.Fatalf("expected the compiled-in template, got %q", out.String())
+	}
+}
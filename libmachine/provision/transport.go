@@ -0,0 +1,42 @@
+package provision
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DockerSocket is the unix socket of the engine, which the SSH tunnels of the
+// machines with the SSH transport forward to.
+const DockerSocket = "/var/run/docker.sock"
+
+// reDaemonSocket matches the netstat line of the engine listening on its
+// unix socket, /run being where /var/run points on most hosts.
+const reDaemonSocket = `LISTEN.*/run/docker\.sock`
+
+// sshTransport tells whether the provisioner provisions an engine the clients
+// reach through SSH tunnels, which doesn't listen on TCP.
+func sshTransport(p Provisioner) bool {
+	getter, ok := p.(EngineOptionsGetter)
+	if !ok {
+		return false
+	}
+
+	engineOptions := getter.GetEngineOptions()
+	return engineOptions.SSHTransport()
+}
+
+// withoutTCPListener returns the engine config without the TCP listener on
+// dockerPort the templates of the provisioners add, the engine listening on
+// its unix socket instead when the config doesn't have it listen there
+// already.
+func withoutTCPListener(engineConfig string, dockerPort int) string {
+	reListener := regexp.MustCompile(fmt.Sprintf(`(-H[ =]|--host[ =])tcp://0\.0\.0\.0:%d\b`, dockerPort))
+
+	if strings.Contains(engineConfig, "unix://"+DockerSocket) {
+		withoutListener := reListener.ReplaceAllString(engineConfig, "")
+		return regexp.MustCompile(`[ \t]+\n`).ReplaceAllString(withoutListener, "\n")
+	}
+
+	return reListener.ReplaceAllString(engineConfig, "-H unix://"+DockerSocket)
+}
@@ -0,0 +1,53 @@
+package provision
+
+import (
+	"testing"
+)
+
+func TestWithoutTCPListener(t *testing.T) {
+	cases := []struct {
+		config   string
+		expected string
+	}{
+		{
+			config:   "DOCKER_OPTS='\n-H tcp://0.0.0.0:2376\n-H unix:///var/run/docker.sock\n--tlsverify\n'",
+			expected: "DOCKER_OPTS='\n\n-H unix:///var/run/docker.sock\n--tlsverify\n'",
+		},
+		{
+			config:   "ExecStart=/usr/bin/docker -d -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock --tlsverify\n",
+			expected: "ExecStart=/usr/bin/docker -d  -H unix:///var/run/docker.sock --tlsverify\n",
+		},
+		{
+			config:   "ExecStart=/usr/lib/coreos/dockerd --daemon --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:2376 --tlsverify\n",
+			expected: "ExecStart=/usr/lib/coreos/dockerd --daemon --host=unix:///var/run/docker.sock  --tlsverify\n",
+		},
+		{
+			config:   "CACERT=/var/lib/boot2docker/ca.pem\nDOCKER_HOST='-H tcp://0.0.0.0:2376'\n",
+			expected: "CACERT=/var/lib/boot2docker/ca.pem\nDOCKER_HOST='-H unix:///var/run/docker.sock'\n",
+		},
+		{
+			config:   "DOCKER_OPTS='-H tcp://0.0.0.0:23760 -H unix:///var/run/docker.sock'",
+			expected: "DOCKER_OPTS='-H tcp://0.0.0.0:23760 -H unix:///var/run/docker.sock'",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := withoutTCPListener(c.config, 2376); actual != c.expected {
+			t.Fatalf("Expected %q without its TCP listener to be %q, got %q", c.config, c.expected, actual)
+		}
+	}
+}
+
+func TestMatchNetstatOutSocket(t *testing.T) {
+	nsOut := `Active UNIX domain sockets (servers and established)
+Proto RefCnt Flags       Type       State         I-Node Path
+unix  2      [ ACC ]     STREAM     LISTENING      17990 /var/run/acpid.socket
+unix  2      [ ACC ]     STREAM     LISTENING      18131 /var/run/docker.sock`
+	if !matchNetstatOut(reDaemonSocket, nsOut) {
+		t.Fatal("Expected to match the netstat output as showing the daemon listening on its socket")
+	}
+
+	if matchNetstatOut(reDaemonSocket, "unix  2      [ ACC ]     STREAM     LISTENING      17990 /var/run/acpid.socket") {
+		t.Fatal("Expected not to match the netstat output as showing the daemon listening on its socket")
+	}
+}
@@ -13,7 +13,10 @@ import (
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
 )
@@ -26,8 +29,8 @@ type DockerOptions struct {
 func installDockerGeneric(p Provisioner, baseURL string) error {
 	// install docker - until cloudinit we use ubuntu everywhere so we
 	// just install it using the docker repos
-	if output, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", baseURL)); err != nil {
-		return fmt.Errorf("error installing docker: %s\n", output)
+	if _, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | DOWNLOAD_URL=%s sh -; fi", baseURL, dockerDownloadURLCommand())); err != nil {
+		return mcnerror.NewErrProvisionStep("installing docker", err)
 	}
 
 	return nil
@@ -43,24 +46,76 @@ func makeDockerOptionsDir(p Provisioner) error {
 	return nil
 }
 
+// setRemoteAuthOptions returns the auth options with the paths of the
+// certificates on the host, those given in the auth options, if any, else
+// those in the docker options dir.
 func setRemoteAuthOptions(p Provisioner) auth.AuthOptions {
 	dockerDir := p.GetDockerOptionsDir()
 	authOptions := p.GetAuthOptions()
 
 	// due to windows clients, we cannot use filepath.Join as the paths
 	// will be mucked on the linux hosts
-	authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
-	authOptions.ServerCertRemotePath = path.Join(dockerDir, "server.pem")
-	authOptions.ServerKeyRemotePath = path.Join(dockerDir, "server-key.pem")
+	if authOptions.CaCertRemotePath == "" {
+		authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
+	}
+	if authOptions.ServerCertRemotePath == "" {
+		authOptions.ServerCertRemotePath = path.Join(dockerDir, "server.pem")
+	}
+	if authOptions.ServerKeyRemotePath == "" {
+		authOptions.ServerKeyRemotePath = path.Join(dockerDir, "server-key.pem")
+	}
 
 	return authOptions
 }
 
+// CheckRemotePaths checks that the paths of the host the engine options and
+// certificates are written to, as given in the engine and auth options, are
+// absolute.
+func CheckRemotePaths(engineOptions engine.EngineOptions, authOptions auth.AuthOptions) error {
+	paths := []struct {
+		name, value string
+	}{
+		{"engine options dir", engineOptions.OptionsDir},
+		{"engine options file", engineOptions.OptionsFile},
+		{"CA certificate", authOptions.CaCertRemotePath},
+		{"server certificate", authOptions.ServerCertRemotePath},
+		{"server key", authOptions.ServerKeyRemotePath},
+	}
+
+	for _, p := range paths {
+		if p.value != "" && !path.IsAbs(p.value) {
+			return fmt.Errorf("the %s of the host must be an absolute path, got %q", p.name, p.value)
+		}
+	}
+
+	return nil
+}
+
+// checkFixedOptionsPaths refuses the engine options dir and file of the
+// engine options on the OS osName, whose engine reads its options from
+// fixed paths.
+func checkFixedOptionsPaths(osName string, engineOptions engine.EngineOptions) error {
+	if engineOptions.OptionsDir != "" || engineOptions.OptionsFile != "" {
+		return fmt.Errorf("The engine of %s reads its options from fixed paths, they cannot be changed", osName)
+	}
+	return nil
+}
+
+// ConfigureAuth generates the server certificate of the engine, copies the
+// certificates to the host and restarts the engine to use them.
 func ConfigureAuth(p Provisioner) error {
+	return mcnerror.NewErrProvisionStep("configuring auth", configureAuth(p))
+}
+
+func configureAuth(p Provisioner) error {
 	var (
 		err error
 	)
 
+	if err := CheckEngineOptions(p); err != nil {
+		return err
+	}
+
 	driver := p.GetDriver()
 	machineName := driver.GetMachineName()
 	authOptions := p.GetAuthOptions()
@@ -72,6 +127,14 @@ func ConfigureAuth(p Provisioner) error {
 		return err
 	}
 
+	// Dual-stack hosts are reached on either address.
+	hosts := []string{ip, "localhost"}
+	if ipv6, err := drivers.GetIPv6(driver); err != nil {
+		log.Warnf("Could not get the IPv6 address of the machine: %s", err)
+	} else if ipv6 != "" && ipv6 != ip {
+		hosts = append(hosts, ipv6)
+	}
+
 	log.Info("Copying certs to the local machine directory...")
 
 	if err := mcnutils.CopyFile(authOptions.CaCertPath, filepath.Join(authOptions.StorePath, "ca.pem")); err != nil {
@@ -96,7 +159,7 @@ func ConfigureAuth(p Provisioner) error {
 	// TODO: Switch to passing just authOptions to this func
 	// instead of all these individual fields
 	err = cert.GenerateCert(
-		[]string{ip, "localhost"},
+		hosts,
 		authOptions.ServerCertPath,
 		authOptions.ServerKeyPath,
 		authOptions.CaCertPath,
@@ -159,9 +222,8 @@ func ConfigureAuth(p Provisioner) error {
 		return err
 	}
 	dockerPort := 2376
-	parts := strings.Split(u.Host, ":")
-	if len(parts) == 2 {
-		dPort, err := strconv.Atoi(parts[1])
+	if port := u.Port(); port != "" {
+		dPort, err := strconv.Atoi(port)
 		if err != nil {
 			return err
 		}
@@ -173,21 +235,34 @@ func ConfigureAuth(p Provisioner) error {
 		return err
 	}
 
+	if sshTransport(p) {
+		dkrcfg.EngineOptions = withoutTCPListener(dkrcfg.EngineOptions, dockerPort)
+	}
+
 	log.Info("Setting Docker configuration on the remote daemon...")
 
-	// Create the file with echo then move it to its proper location
+	// Create the file with echo then move it to its proper location, whose
+	// directory may not exist when it isn't the one of the provisioner
 	move_config_command := fmt.Sprintf(
-		"echo -e %q > /tmp/docker_defaults && %s",
+		"echo -e %q > /tmp/docker_defaults && %s && %s",
 		dkrcfg.EngineOptions,
+		p.GetDriver().SSHSudo("mkdir -p %s"),
 		p.GetDriver().SSHSudo("mv /tmp/docker_defaults %s"),
 	)
 	if _, err = p.SSHCommand(fmt.Sprintf(
 		move_config_command,
+		path.Dir(dkrcfg.EngineOptionsPath),
 		dkrcfg.EngineOptionsPath,
 	)); err != nil {
 		return err
 	}
 
+	if getter, ok := p.(EngineOptionsGetter); ok {
+		if err := ConfigureRegistries(p, getter.GetEngineOptions()); err != nil {
+			return err
+		}
+	}
+
 	if err := p.Service("docker", serviceaction.Start); err != nil {
 		return err
 	}
@@ -216,8 +291,12 @@ func matchNetstatOut(reDaemonListening, netstatOut string) bool {
 
 func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
 	reDaemonListening := fmt.Sprintf(":%d.*LISTEN", dockerPort)
+	if sshTransport(p) {
+		reDaemonListening = reDaemonSocket
+	}
 	return func() bool {
-		// HACK: Check netstat's output to see if anyone's listening on the Docker API port.
+		// HACK: Check netstat's output to see if anyone's listening on the Docker API port,
+		// or on its socket with the SSH transport.
 		netstatOut, err := p.SSHCommand("netstat -a")
 		if err != nil {
 			log.Warnf("Error running SSH command: %s", err)
@@ -229,7 +308,7 @@ func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
 }
 
 func waitForDocker(p Provisioner, dockerPort int) error {
-	if err := mcnutils.WaitForSpecific(checkDaemonUp(p, dockerPort), 5, 3*time.Second); err != nil {
+	if err := drivers.WaitForStage(p.GetDriver(), mcnutils.WaitDaemon, mcnutils.WaitSettings{Timeout: 15 * time.Second}, checkDaemonUp(p, dockerPort)); err != nil {
 		return NewErrDaemonAvailable(err)
 	}
 
@@ -0,0 +1,81 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var errVolumesBoot2Docker = errors.New("boot2docker keeps its engine data on its own disk, volumes can't be mounted on it")
+
+// VolumeMounter is implemented by provisioners which mount the volume
+// attached to the host their own way, or can't.
+type VolumeMounter interface {
+	MountVolume(volume *drivers.Volume, devices []string) error
+}
+
+// mountVolumeScript waits for one of the block devices the volume may have to
+// show up, formats it unless it already has a filesystem, and mounts it with
+// an fstab entry for it to be mounted again on boot. It is idempotent, for
+// the provisioning of a host to be resumed.
+const mountVolumeScript = `set -e
+device=""
+for i in $(seq 1 60); do
+	for candidate in %s; do
+		if [ -b "$candidate" ]; then
+			device=$(readlink -f "$candidate")
+			break 2
+		fi
+	done
+	sleep 1
+done
+if [ -z "$device" ]; then
+	echo "No block device found for the volume" >&2
+	exit 1
+fi
+blkid "$device" >/dev/null || mkfs.ext4 -F "$device"
+uuid=$(blkid -s UUID -o value "$device")
+mkdir -p %s
+grep -q "^UUID=$uuid " /etc/fstab || echo "UUID=$uuid %s ext4 defaults,nofail 0 2" >> /etc/fstab
+mountpoint -q %s || mount %s`
+
+func mountVolumeCommand(volume *drivers.Volume, devices []string) string {
+	return fmt.Sprintf("sh -c '"+mountVolumeScript+"'",
+		strings.Join(devices, " "), volume.Mount, volume.Mount, volume.Mount, volume.Mount)
+}
+
+// MountVolume formats and mounts the volume attached to the host, if any,
+// before the engine is installed for its data to land on the volume.
+func MountVolume(p Provisioner) error {
+	driver := p.GetDriver()
+
+	volume := drivers.GetVolume(driver)
+	if volume == nil {
+		return nil
+	}
+
+	devices, err := drivers.GetVolumeDevices(driver)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Mounting %dGB volume on %s...", volume.Size, volume.Mount)
+
+	if mounter, ok := p.(VolumeMounter); ok {
+		return mounter.MountVolume(volume, devices)
+	}
+
+	if _, err := p.SSHCommand(driver.SSHSudo(mountVolumeCommand(volume, devices))); err != nil {
+		return fmt.Errorf("Error mounting the volume: %s", err)
+	}
+	return nil
+}
+
+// MountVolume refuses to mount volumes, as the engine data of boot2docker is
+// on the boot2docker-data partition of its own disk.
+func (provisioner *Boot2DockerProvisioner) MountVolume(volume *drivers.Volume, devices []string) error {
+	return errVolumesBoot2Docker
+}
@@ -0,0 +1,38 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func TestMountVolumeCommand(t *testing.T) {
+	command := mountVolumeCommand(&drivers.Volume{Size: 100, Type: "ssd", Mount: "/data"}, []string{"/dev/sdf", "/dev/xvdf"})
+
+	for _, expected := range []string{
+		"for candidate in /dev/sdf /dev/xvdf; do",
+		"mkdir -p /data",
+		`echo "UUID=$uuid /data ext4 defaults,nofail 0 2" >> /etc/fstab`,
+		"mountpoint -q /data || mount /data",
+	} {
+		if !strings.Contains(command, expected) {
+			t.Fatalf("expected %q in %s", expected, command)
+		}
+	}
+
+	if !strings.HasPrefix(command, "sh -c '") || strings.Count(command, "'") != 2 {
+		t.Fatalf("expected the script to be single quoted, got %s", command)
+	}
+}
+
+func TestMountVolumeWithoutVolume(t *testing.T) {
+	p := &Boot2DockerProvisioner{
+		Driver: &fakedriver.Driver{},
+	}
+
+	if err := MountVolume(p); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package reconcile compares the machines with what the store recorded for
+// them, like the state they were last started or stopped into, and corrects
+// the drift it knows how to.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/health"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/metrics"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// The kinds of drift.
+const (
+	DriftState         = "state"
+	DriftEngineVersion = "engine-version"
+	DriftCert          = "cert"
+)
+
+var errNotCorrectable = errors.New("this drift is not corrected automatically")
+
+// Drift is a difference between a machine and what the store recorded for
+// it.
+type Drift struct {
+	Machine string `json:"machine"`
+	Kind    string `json:"kind"`
+	Desired string `json:"desired"`
+	Actual  string `json:"actual"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s is %s, expected %s", d.Kind, d.Actual, d.Desired)
+}
+
+// Correctable tells whether Correct knows how to correct the drift: starting
+// a machine which stopped, or regenerating a certificate about to expire.
+// Stopping a machine, or changing its engine, is left to its owner.
+func (d Drift) Correctable() bool {
+	switch d.Kind {
+	case DriftState:
+		return d.Desired == state.Running.String() && d.Actual == state.Stopped.String()
+	case DriftCert:
+		return true
+	}
+	return false
+}
+
+// Detect returns the drift of the machine, as observed in m, from its
+// recorded state, its recorded engine version, and a server certificate
+// valid for longer than health.CertExpiryThreshold. What wasn't recorded,
+// like the state of machines started before it was, or couldn't be
+// observed, isn't compared.
+func Detect(h *host.Host, m metrics.Machine, now time.Time) []Drift {
+	drifts := []Drift{}
+
+	switch m.State {
+	case state.None, state.Error, state.Starting, state.Stopping, state.Timeout:
+	default:
+		if h.DesiredState != state.None && m.State != h.DesiredState {
+			drifts = append(drifts, Drift{Machine: h.Name, Kind: DriftState, Desired: h.DesiredState.String(), Actual: m.State.String()})
+		}
+	}
+
+	if h.EngineVersion != "" && m.EngineVersion != "" && m.EngineVersion != h.EngineVersion {
+		drifts = append(drifts, Drift{Machine: h.Name, Kind: DriftEngineVersion, Desired: h.EngineVersion, Actual: m.EngineVersion})
+	}
+
+	if !m.CertExpiry.IsZero() && m.CertExpiry.Sub(now) < health.CertExpiryThreshold {
+		drifts = append(drifts, Drift{
+			Machine: h.Name,
+			Kind:    DriftCert,
+			Desired: fmt.Sprintf("valid for %d days", int(health.CertExpiryThreshold.Hours()/24)),
+			Actual:  fmt.Sprintf("expiring on %s", m.CertExpiry.Format("2006-01-02")),
+		})
+	}
+
+	return drifts
+}
+
+// Correct corrects the drift of the machine, when it is Correctable, unless
+// the machine was adopted.
+func Correct(h *host.Host, d Drift) error {
+	if !d.Correctable() {
+		return errNotCorrectable
+	}
+
+	if h.Adopted() {
+		return host.ErrAdopted
+	}
+
+	if d.Kind == DriftState {
+		return h.Start()
+	}
+
+	return h.ConfigureAuth()
+}
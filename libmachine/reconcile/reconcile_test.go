@@ -0,0 +1,93 @@
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/metrics"
+	"github.com/docker/machine/libmachine/state"
+)
+
+func TestDetect(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	h := &host.Host{Name: "dev", DesiredState: state.Running, EngineVersion: "24.0.5"}
+
+	drifts := Detect(h, metrics.Machine{
+		State:         state.Stopped,
+		EngineVersion: "24.0.7",
+		CertExpiry:    now.Add(48 * time.Hour),
+	}, now)
+
+	expected := []Drift{
+		{Machine: "dev", Kind: DriftState, Desired: "Running", Actual: "Stopped"},
+		{Machine: "dev", Kind: DriftEngineVersion, Desired: "24.0.5", Actual: "24.0.7"},
+		{Machine: "dev", Kind: DriftCert, Desired: "valid for 7 days", Actual: "expiring on 2026-10-19"},
+	}
+	if !reflect.DeepEqual(drifts, expected) {
+		t.Fatalf("expected the drifts %v, got %v", expected, drifts)
+	}
+}
+
+func TestDetectWithoutDrift(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		h *host.Host
+		m metrics.Machine
+	}{
+		{&host.Host{DesiredState: state.Running, EngineVersion: "24.0.5"}, metrics.Machine{State: state.Running, EngineVersion: "24.0.5", CertExpiry: now.AddDate(1, 0, 0)}},
+		// Nothing recorded, nothing compared.
+		{&host.Host{}, metrics.Machine{State: state.Stopped, EngineVersion: "24.0.5"}},
+		// Nothing observed, nothing compared.
+		{&host.Host{DesiredState: state.Running, EngineVersion: "24.0.5"}, metrics.Machine{State: state.Error}},
+		{&host.Host{DesiredState: state.Stopped}, metrics.Machine{State: state.Stopping}},
+	}
+
+	for _, c := range cases {
+		if drifts := Detect(c.h, c.m, now); len(drifts) != 0 {
+			t.Fatalf("expected no drift, got %v", drifts)
+		}
+	}
+}
+
+func TestCorrectable(t *testing.T) {
+	cases := []struct {
+		drift       Drift
+		correctable bool
+	}{
+		{Drift{Kind: DriftState, Desired: "Running", Actual: "Stopped"}, true},
+		{Drift{Kind: DriftState, Desired: "Stopped", Actual: "Running"}, false},
+		{Drift{Kind: DriftState, Desired: "Running", Actual: "Saved"}, false},
+		{Drift{Kind: DriftEngineVersion, Desired: "24.0.5", Actual: "24.0.7"}, false},
+		{Drift{Kind: DriftCert}, true},
+	}
+
+	for _, c := range cases {
+		if c.drift.Correctable() != c.correctable {
+			t.Fatalf("expected %v to be correctable: %t", c.drift, c.correctable)
+		}
+	}
+}
+
+func TestCorrectStartsStoppedMachine(t *testing.T) {
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Stopped}, DesiredState: state.Running}
+
+	if err := Correct(h, Drift{Machine: "dev", Kind: DriftState, Desired: "Running", Actual: "Stopped"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := h.Driver.GetState(); s != state.Running {
+		t.Fatalf("expected the machine to be started, got %s", s)
+	}
+}
+
+func TestCorrectRefusesOtherDrift(t *testing.T) {
+	h := &host.Host{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}
+
+	if err := Correct(h, Drift{Kind: DriftState, Desired: "Stopped", Actual: "Running"}); err != errNotCorrectable {
+		t.Fatalf("expected the drift not to be corrected, got %v", err)
+	}
+}
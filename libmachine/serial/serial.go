@@ -0,0 +1,171 @@
+// Package serial runs commands in the shell of the serial console of a host,
+// for hosts which can't be reached over the network.
+package serial
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// inputLineLength is the length of the lines the input of the commands is
+// written to the console in, short of the limit of the terminals.
+const inputLineLength = 76
+
+// Dial connects to the serial console at address: unix:PATH for a socket,
+// like the ones of QEMU and libvirt, tcp:HOST:PORT for a console server, or
+// else the path of a terminal device.
+func Dial(address string) (io.ReadWriteCloser, error) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		return net.Dial("unix", strings.TrimPrefix(address, "unix:"))
+	case strings.HasPrefix(address, "tcp:"):
+		return net.Dial("tcp", strings.TrimPrefix(address, "tcp:"))
+	}
+
+	return os.OpenFile(address, os.O_RDWR, 0)
+}
+
+// ExitError is the error of a command which exited with a non-zero status.
+type ExitError struct {
+	Status int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Status)
+}
+
+// Console runs commands in the shell a serial console is logged in to. The
+// shell must be a POSIX shell, waiting for a command.
+type Console struct {
+	rw     io.ReadWriter
+	reader *bufio.Reader
+}
+
+func NewConsole(rw io.ReadWriter) *Console {
+	return &Console{rw: rw, reader: bufio.NewReader(rw)}
+}
+
+// Run runs the command, writing input to its stdin when not nil, and returns
+// its output, stdout and stderr together. Echo is turned off before the
+// input is written, for secrets not to be echoed back, and the output is
+// told apart from the echo of the command by markers the command prints.
+func (c *Console) Run(command string, input []byte) (string, error) {
+	marker, err := newMarker()
+	if err != nil {
+		return "", err
+	}
+
+	// The markers are printed joined, for the echo of the command, in
+	// which they are split, not to be mistaken for them. The input is
+	// read from the console up to the eof line, once echo is off.
+	begin, end, eof := marker+"-begin", marker+"-end", marker+"-eof"
+	run := fmt.Sprintf("( %s\n) </dev/null", command)
+	if input != nil {
+		run = fmt.Sprintf(`while IFS= read -r l && [ "$l" != %s ]; do printf '%%s\n' "$l"; done | base64 -d | ( %s
+)`, eof, command)
+	}
+	line := fmt.Sprintf("stty -echo 2>/dev/null; printf '%%s-%%s\\n' %s begin; %s 2>&1; printf '\\n%%s-%%s %%s\\n' %s end $?; stty echo 2>/dev/null\n", marker, run, marker)
+
+	if _, err := io.WriteString(c.rw, line); err != nil {
+		return "", err
+	}
+
+	if err := c.skipTo(begin); err != nil {
+		return "", err
+	}
+
+	if input != nil {
+		if err := c.writeInput(input, eof); err != nil {
+			return "", err
+		}
+	}
+
+	return c.readOutput(end)
+}
+
+// skipTo reads the console up to the line marker.
+func (c *Console) skipTo(marker string) error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == marker {
+			return nil
+		}
+	}
+}
+
+// writeInput writes the input encoded in base64, in lines the terminal
+// takes, followed by the eof line.
+func (c *Console) writeInput(input []byte, eof string) error {
+	encoded := base64.StdEncoding.EncodeToString(input)
+
+	var lines []string
+	for len(encoded) > inputLineLength {
+		lines = append(lines, encoded[:inputLineLength])
+		encoded = encoded[inputLineLength:]
+	}
+	lines = append(lines, encoded, eof)
+
+	_, err := io.WriteString(c.rw, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// readOutput reads the output of the command up to the end marker, and
+// returns it with the error of its exit status.
+func (c *Console) readOutput(end string) (string, error) {
+	var output []string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return strings.Join(output, "\n"), err
+		}
+
+		if strings.HasPrefix(line, end+" ") {
+			status, err := strconv.Atoi(strings.TrimPrefix(line, end+" "))
+			if err != nil {
+				return "", fmt.Errorf("Invalid exit status on the serial console: %q", line)
+			}
+
+			// The last line is the one the newline printed
+			// before the marker ends, for it to start a line.
+			result := strings.Join(output, "\n")
+			if status != 0 {
+				return result, ExitError{Status: status}
+			}
+			return result, nil
+		}
+
+		output = append(output, line)
+	}
+}
+
+// readLine reads a line of the console, without the carriage returns
+// terminals add.
+func (c *Console) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("The serial console closed before the command completed")
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func newMarker() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("machine-%x", b), nil
+}
@@ -0,0 +1,74 @@
+package serial
+
+import (
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pipes struct {
+	io.Reader
+	io.Writer
+}
+
+// shellConsole returns a console of a shell reading its commands from the
+// console, as the shell of a serial console does.
+func shellConsole(t *testing.T) (*Console, func()) {
+	cmd := exec.Command("sh")
+	stdin, err := cmd.StdinPipe()
+	assert.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+
+	return NewConsole(pipes{Reader: stdout, Writer: stdin}), func() {
+		stdin.Close()
+		cmd.Wait()
+	}
+}
+
+func TestRun(t *testing.T) {
+	console, stop := shellConsole(t)
+	defer stop()
+
+	output, err := console.Run("echo hello; echo world >&2", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\nworld\n", output)
+
+	output, err = console.Run("printf partial", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "partial", output)
+
+	output, err = console.Run("echo failed; exit 3", nil)
+	assert.Equal(t, ExitError{Status: 3}, err)
+	assert.Equal(t, "failed\n", output)
+}
+
+func TestRunWithInput(t *testing.T) {
+	console, stop := shellConsole(t)
+	defer stop()
+
+	input := []byte("secret\n" + string(make([]byte, 200)) + "end")
+	output, err := console.Run("wc -c", input)
+	assert.NoError(t, err)
+	assert.Equal(t, "210\n", output)
+
+	output, err = console.Run("cat", []byte("a\nb"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb", output)
+
+	output, err = console.Run("echo after", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "after\n", output)
+}
+
+func TestRunClosedConsole(t *testing.T) {
+	console := NewConsole(pipes{Reader: strings.NewReader("login: "), Writer: ioutil.Discard})
+
+	_, err := console.Run("exit 0", nil)
+	assert.EqualError(t, err, "The serial console closed before the command completed")
+}
@@ -0,0 +1,182 @@
+// Package spec reads files describing a set of machines, which
+// "docker-machine apply" reconciles the store towards.
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnyaml"
+)
+
+const (
+	SwarmRoleMaster = "master"
+	SwarmRoleAgent  = "agent"
+)
+
+var ErrNoMachines = errors.New("spec does not describe any machines")
+
+// Spec is a set of machines.
+type Spec struct {
+	Machines []Machine `json:"machines"`
+}
+
+// Machine describes a single machine. DriverOpts are keyed by the name of
+// the driver's create flag, e.g. "virtualbox-memory".
+type Machine struct {
+	Name       string                 `json:"name"`
+	Driver     string                 `json:"driver"`
+	DriverOpts map[string]interface{} `json:"driver-opts"`
+	Labels     map[string]interface{} `json:"labels"`
+	Engine     Engine                 `json:"engine"`
+	Swarm      Swarm                  `json:"swarm"`
+
+	// UserData is the file of the user data template of the machine,
+	// as given to "create" with --user-data.
+	UserData string `json:"user-data"`
+}
+
+// Engine holds the options of the Docker engine installed on a machine.
+type Engine struct {
+	Opts               []string `json:"opts"`
+	Env                []string `json:"env"`
+	InsecureRegistries []string `json:"insecure-registries"`
+	RegistryMirrors    []string `json:"registry-mirrors"`
+	StorageDriver      string   `json:"storage-driver"`
+	InstallURL         string   `json:"install-url"`
+
+	// OptionsDir, OptionsFile and the paths of the certificates are where
+	// the engine options and certificates are written on the machine, as
+	// given to "create" with --engine-options-dir and the like.
+	OptionsDir     string `json:"options-dir"`
+	OptionsFile    string `json:"options-file"`
+	CaCertPath     string `json:"ca-cert-path"`
+	ServerCertPath string `json:"server-cert-path"`
+	ServerKeyPath  string `json:"server-key-path"`
+}
+
+// Swarm holds the Swarm configuration of a machine. A machine without a
+// role is not part of a Swarm.
+type Swarm struct {
+	Role      string   `json:"role"`
+	Discovery string   `json:"discovery"`
+	Image     string   `json:"image"`
+	Strategy  string   `json:"strategy"`
+	Host      string   `json:"host"`
+	Addr      string   `json:"addr"`
+	Opts      []string `json:"opts"`
+}
+
+// EngineLabels returns the labels of the machine in the key=value form the
+// engine expects, sorted by key.
+func (m Machine) EngineLabels() []string {
+	keys := []string{}
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	labels := []string{}
+	for _, k := range keys {
+		labels = append(labels, fmt.Sprintf("%s=%v", k, m.Labels[k]))
+	}
+
+	return labels
+}
+
+// Parse reads a spec written in YAML or JSON.
+func Parse(data []byte) (*Spec, error) {
+	var raw interface{}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("Error parsing spec: %s", err)
+		}
+	} else {
+		v, err := mcnyaml.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing spec: %s", err)
+		}
+		raw = v
+	}
+
+	// Going through JSON gives us the struct decoding for free.
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing spec: %s", err)
+	}
+
+	s := &Spec{}
+	if err := json.Unmarshal(encoded, s); err != nil {
+		return nil, fmt.Errorf("Error parsing spec: %s", err)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ReadFile reads and parses the spec file at path.
+func ReadFile(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Validate checks that every machine has a valid, unique name, a driver and
+// a known Swarm role.
+func (s *Spec) Validate() error {
+	if len(s.Machines) == 0 {
+		return ErrNoMachines
+	}
+
+	seen := map[string]bool{}
+	for i, m := range s.Machines {
+		if m.Name == "" {
+			return fmt.Errorf("machine #%d has no name", i+1)
+		}
+
+		if !host.ValidateHostName(m.Name) {
+			return fmt.Errorf("machine %q: %s", m.Name, mcnerror.ErrInvalidHostname)
+		}
+
+		if seen[m.Name] {
+			return fmt.Errorf("machine %q is described more than once", m.Name)
+		}
+		seen[m.Name] = true
+
+		if m.Driver == "" {
+			return fmt.Errorf("machine %q has no driver", m.Name)
+		}
+
+		switch m.Swarm.Role {
+		case "", SwarmRoleMaster, SwarmRoleAgent:
+		default:
+			return fmt.Errorf("machine %q has unknown swarm role %q, expected %q or %q", m.Name, m.Swarm.Role, SwarmRoleMaster, SwarmRoleAgent)
+		}
+	}
+
+	return nil
+}
+
+// Machine returns the machine called name, or nil if there is none.
+func (s *Spec) Machine(name string) *Machine {
+	for i := range s.Machines {
+		if s.Machines[i].Name == name {
+			return &s.Machines[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testSpec = `
+# Two machines in a Swarm
+machines:
+  - name: master
+    driver: virtualbox
+    driver-opts:
+      virtualbox-memory: 2048
+      virtualbox-no-share: true
+    labels:
+      env: dev
+      tier: 1
+    engine:
+      opts: [log-driver=syslog, "dns=8.8.8.8"]
+      storage-driver: overlay
+    swarm:
+      role: master
+      discovery: "token://cafe#beef"
+
+  - name: agent
+    driver: digitalocean
+    driver-opts: {digitalocean-region: nyc3, digitalocean-size: 1gb}
+    swarm:
+      role: agent
+      opts:
+      - heartbeat=5s
+`
+
+func TestParseYAML(t *testing.T) {
+	s, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Machines) != 2 {
+		t.Fatalf("Expected 2 machines, got %d", len(s.Machines))
+	}
+
+	master := s.Machine("master")
+	if master == nil {
+		t.Fatal("Expected to find machine master")
+	}
+
+	if master.DriverOpts["virtualbox-memory"] != float64(2048) || master.DriverOpts["virtualbox-no-share"] != true {
+		t.Fatalf("Unexpected driver options: %v", master.DriverOpts)
+	}
+
+	if labels := master.EngineLabels(); !reflect.DeepEqual(labels, []string{"env=dev", "tier=1"}) {
+		t.Fatalf("Unexpected labels: %v", labels)
+	}
+
+	if !reflect.DeepEqual(master.Engine.Opts, []string{"log-driver=syslog", "dns=8.8.8.8"}) {
+		t.Fatalf("Unexpected engine options: %v", master.Engine.Opts)
+	}
+
+	if master.Swarm.Role != SwarmRoleMaster || master.Swarm.Discovery != "token://cafe#beef" {
+		t.Fatalf("Unexpected swarm options: %+v", master.Swarm)
+	}
+
+	agent := s.Machine("agent")
+	if agent.DriverOpts["digitalocean-region"] != "nyc3" {
+		t.Fatalf("Unexpected driver options: %v", agent.DriverOpts)
+	}
+
+	if !reflect.DeepEqual(agent.Swarm.Opts, []string{"heartbeat=5s"}) {
+		t.Fatalf("Unexpected swarm options: %v", agent.Swarm.Opts)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	s, err := Parse([]byte(`{"machines": [{"name": "dev", "driver": "none"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Machines) != 1 || s.Machines[0].Name != "dev" {
+		t.Fatalf("Unexpected machines: %+v", s.Machines)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := map[string]string{
+		"duplicate key":    "machines:\nmachines:\n",
+		"bad indentation":  "machines:\n  - name: a\n   driver: b\n",
+		"no machines":      "machines: []\n",
+		"no name":          "machines:\n- driver: none\n",
+		"no driver":        "machines:\n- name: dev\n",
+		"duplicate name":   "machines:\n- {name: dev, driver: none}\n- {name: dev, driver: none}\n",
+		"invalid name":     "machines:\n- {name: dev_1!, driver: none}\n",
+		"unknown role":     "machines:\n- {name: dev, driver: none, swarm: {role: leader}}\n",
+		"unterminated seq": "machines: [\n",
+	}
+
+	for desc, data := range invalid {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %s", desc)
+		}
+	}
+}
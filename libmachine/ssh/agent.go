@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var errNoAgent = errors.New("ssh-agent is not running, SSH_AUTH_SOCK is not set")
+
+// sshAgent connects to the ssh-agent of the user.
+func sshAgent() (agent.Agent, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errNoAgent
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.NewClient(conn), nil
+}
+
+// forwardAgent forwards the ssh-agent of the user to the session.
+func forwardAgent(conn *ssh.Client, session *ssh.Session) error {
+	keyring, err := sshAgent()
+	if err != nil {
+		return fmt.Errorf("Error forwarding ssh-agent: %s", err)
+	}
+
+	if err := agent.ForwardToAgent(conn, keyring); err != nil {
+		return fmt.Errorf("Error forwarding ssh-agent: %s", err)
+	}
+
+	return agent.RequestAgentForwarding(session)
+}
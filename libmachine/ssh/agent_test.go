@@ -0,0 +1,202 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// serveSSH runs an SSH server accepting the given key only, which answers
+// every command with "ok", and returns its address and the number of
+// connections it accepted.
+func serveSSH(t *testing.T, authorized ssh.PublicKey) (string, *int32, func()) {
+	hostKey, err := ssh.NewSignerFromKey(generateTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connections := new(int32)
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(connections, 1)
+
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nConn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range requests {
+							req.Reply(req.Type == "exec", nil)
+							var exec struct{ Command string }
+							ssh.Unmarshal(req.Payload, &exec)
+							if req.Type == "exec" && exec.Command != "hang" {
+								channel.Write([]byte("ok"))
+								channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+								channel.Close()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), connections, func() { listener.Close() }
+}
+
+// serveAgent runs an ssh-agent holding the given key, and points
+// SSH_AUTH_SOCK to it.
+func serveAgent(t *testing.T, key *rsa.PrivateKey) func() {
+	dir, err := ioutil.TempDir("", "machine-agent-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(key, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	oldSocket := os.Getenv("SSH_AUTH_SOCK")
+	os.Setenv("SSH_AUTH_SOCK", socket)
+
+	return func() {
+		os.Setenv("SSH_AUTH_SOCK", oldSocket)
+		listener.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func newTestNativeClient(t *testing.T, addr string, keys []string) Client {
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+
+	client, err := NewNativeClient("docker", host, p, &Auth{Keys: keys})
+	assert.NoError(t, err)
+	return client
+}
+
+func TestNativeClientAgent(t *testing.T) {
+	agentKey := generateTestKey(t)
+	authorized, err := ssh.NewPublicKey(&agentKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, _, stopSSH := serveSSH(t, authorized)
+	defer stopSSH()
+	defer serveAgent(t, agentKey)()
+
+	// Each client authenticates on its own connection.
+	SetConnectionReuse(false)
+	defer SetConnectionReuse(true)
+
+	dir, err := ioutil.TempDir("", "machine-keys-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The key of the store is missing, the one of the agent is used.
+	client := newTestNativeClient(t, addr, []string{filepath.Join(dir, "id_rsa")})
+	output, err := client.Output("true")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", output)
+
+	// The key of the store is offered first, then the one of the agent.
+	otherKey := filepath.Join(dir, "other_rsa")
+	ioutil.WriteFile(otherKey, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(generateTestKey(t)),
+	}), 0600)
+
+	client = newTestNativeClient(t, addr, []string{otherKey})
+	output, err = client.Output("true")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", output)
+}
+
+func TestNewNativeConfigWithoutAgent(t *testing.T) {
+	oldSocket := os.Getenv("SSH_AUTH_SOCK")
+	os.Unsetenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", oldSocket)
+
+	_, err := NewNativeConfig("docker", &Auth{Keys: []string{"/does/not/exist"}})
+	assert.Error(t, err)
+
+	config, err := NewNativeConfig("docker", &Auth{Passwords: []string{"secret"}})
+	assert.NoError(t, err)
+	assert.Len(t, config.Auth, 1)
+}
+
+func TestNewExternalClientForwardAgent(t *testing.T) {
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "10.0.0.5", 22, &Auth{ForwardAgent: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-A", "docker@10.0.0.5", "-p", "22"}, argsFrom(client.BaseArgs, "-A"))
+}
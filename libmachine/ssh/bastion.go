@@ -0,0 +1,132 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Bastion is a jump host through which hosts without a reachable address,
+// such as the ones on private subnets, are connected to.
+type Bastion struct {
+	User string
+	Host string
+	Port int
+
+	// KeyPath is the private key to authenticate to the bastion with,
+	// before the keys of the host
+	KeyPath string
+}
+
+// ParseBastion parses a bastion given as [user@]host[:port]. The user
+// defaults to the one of the host connected to through the bastion, and
+// the port to 22.
+func ParseBastion(bastion string) (*Bastion, error) {
+	b := &Bastion{Port: 22}
+
+	hostPort := bastion
+	if i := strings.LastIndex(bastion, "@"); i >= 0 {
+		b.User = bastion[:i]
+		hostPort = bastion[i+1:]
+	}
+
+	b.Host = unbracketHost(hostPort)
+	if host, port, err := net.SplitHostPort(hostPort); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("Invalid SSH bastion %q, bad port %q", bastion, port)
+		}
+		b.Host = host
+		b.Port = p
+	}
+
+	if b.Host == "" || strings.ContainsAny(b.Host, " []") {
+		return nil, fmt.Errorf("Invalid SSH bastion %q, expected [user@]host[:port]", bastion)
+	}
+
+	return b, nil
+}
+
+func (b *Bastion) String() string {
+	s := net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+	if b.User != "" {
+		s = b.User + "@" + s
+	}
+	return s
+}
+
+// dial connects to addr through the bastion, authenticating to the bastion
+// with bastionConfig and to addr with config. The connection to the bastion
+// is closed along with the returned client.
+func (b *Bastion) dial(addr string, config, bastionConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	bastion, err := sshDial(net.JoinHostPort(b.Host, strconv.Itoa(b.Port)), bastionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error dialing SSH bastion %s: %s", b, err)
+	}
+	keepAlive(bastion)
+
+	conn, err := bastion.Dial("tcp", addr)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("Error dialing %s through SSH bastion %s: %s", addr, b, err)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		bastion.Close()
+		return nil, err
+	}
+
+	client := ssh.NewClient(c, chans, reqs)
+	go func() {
+		client.Wait()
+		bastion.Close()
+	}()
+
+	return client, nil
+}
+
+// proxyCommand returns the ssh options making the external client connect
+// through the bastion to host, with the keys of auth, as user unless the
+// bastion has its own, and with the proxyArgs options to reach the bastion.
+func (b *Bastion) proxyCommand(sshBinaryPath, user, host string, auth *Auth, proxyArgs []string) []string {
+	if b.User != "" {
+		user = b.User
+	}
+
+	command := []string{quoteProxyArg(sshBinaryPath)}
+	for _, arg := range baseSSHArgs {
+		command = append(command, quoteProxyArg(arg))
+	}
+	// The tokens of the inner ProxyCommand are escaped from the outer one.
+	for _, arg := range proxyArgs {
+		command = append(command, quoteProxyArg(strings.Replace(arg, "%", "%%", -1)))
+	}
+	if b.KeyPath != "" {
+		command = append(command, "-i", quoteProxyArg(b.KeyPath))
+	}
+	for _, privateKeyPath := range auth.Keys {
+		command = append(command, "-i", quoteProxyArg(privateKeyPath))
+	}
+	// IPv6 literals are bracketed for -W to tell the address from the port.
+	forward := "%h:%p"
+	if strings.Contains(host, ":") {
+		forward = "[%h]:%p"
+	}
+	command = append(command, "-p", strconv.Itoa(b.Port), "-W", forward, quoteProxyArg(user+"@"+b.Host))
+
+	return []string{"-o", "ProxyCommand=" + strings.Join(command, " ")}
+}
+
+// quoteProxyArg quotes arg for the shell the ProxyCommand is run with when
+// it has to, e.g. key paths with spaces.
+func quoteProxyArg(arg string) string {
+	if !strings.ContainsAny(arg, " \t'\"\\$`;&|<>()*?") {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,101 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBastion(t *testing.T) {
+	cases := []struct {
+		bastion  string
+		expected Bastion
+	}{
+		{"bastion.example.com", Bastion{Host: "bastion.example.com", Port: 22}},
+		{"ec2-user@10.0.0.5", Bastion{User: "ec2-user", Host: "10.0.0.5", Port: 22}},
+		{"jump@bastion.example.com:2222", Bastion{User: "jump", Host: "bastion.example.com", Port: 2222}},
+		{"[2001:db8::1]:2222", Bastion{Host: "2001:db8::1", Port: 2222}},
+		{"2001:db8::1", Bastion{Host: "2001:db8::1", Port: 22}},
+		{"jump@[2001:db8::1]", Bastion{User: "jump", Host: "2001:db8::1", Port: 22}},
+	}
+
+	for _, c := range cases {
+		bastion, err := ParseBastion(c.bastion)
+
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, *bastion)
+	}
+
+	_, err := ParseBastion("jump@bastion:ssh")
+	assert.EqualError(t, err, `Invalid SSH bastion "jump@bastion:ssh", bad port "ssh"`)
+
+	_, err = ParseBastion("jump@")
+	assert.EqualError(t, err, `Invalid SSH bastion "jump@", expected [user@]host[:port]`)
+}
+
+func TestBastionString(t *testing.T) {
+	assert.Equal(t, "jump@bastion:2222", (&Bastion{User: "jump", Host: "bastion", Port: 2222}).String())
+	assert.Equal(t, "[2001:db8::1]:22", (&Bastion{Host: "2001:db8::1", Port: 22}).String())
+}
+
+func TestNewExternalClientBastion(t *testing.T) {
+	bastion, err := ParseBastion("jump@bastion:2222")
+	assert.NoError(t, err)
+	bastion.KeyPath = "/keys/bastion key"
+
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "10.0.0.5", 22, &Auth{
+		Keys:    []string{"/machines/default/id_rsa"},
+		Bastion: bastion,
+	})
+	assert.NoError(t, err)
+
+	proxy := proxyCommandArg(client.BaseArgs)
+	assert.True(t, strings.HasPrefix(proxy, "ProxyCommand=/usr/bin/ssh -o PasswordAuthentication=no "))
+	assert.True(t, strings.HasSuffix(proxy, " -i '/keys/bastion key' -i /machines/default/id_rsa -p 2222 -W %h:%p jump@bastion"))
+	assert.Equal(t, []string{"docker@10.0.0.5", "-i", "/machines/default/id_rsa", "-p", "22"}, argsFrom(client.BaseArgs, "docker@10.0.0.5"))
+
+	// Without a user of its own, the bastion is logged into as the user of
+	// the host.
+	client, err = NewExternalClient("/usr/bin/ssh", "docker", "10.0.0.5", 22, &Auth{
+		Bastion: &Bastion{Host: "bastion", Port: 22},
+	})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(proxyCommandArg(client.BaseArgs), " -p 22 -W %h:%p docker@bastion"))
+}
+
+func TestNewExternalClientIPv6(t *testing.T) {
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "[2001:db8::5]", 22, &Auth{
+		Bastion: &Bastion{Host: "bastion", Port: 22},
+	})
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasSuffix(proxyCommandArg(client.BaseArgs), " -p 22 -W [%h]:%p docker@bastion"))
+	assert.Equal(t, []string{"docker@2001:db8::5", "-p", "22"}, argsFrom(client.BaseArgs, "docker@2001:db8::5"))
+}
+
+// proxyCommandArg returns the ProxyCommand option of the ssh arguments.
+func proxyCommandArg(args []string) string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "ProxyCommand=") && args[i-1] == "-o" {
+			return arg
+		}
+	}
+	return ""
+}
+
+// argsFrom returns the ssh arguments from first on.
+func argsFrom(args []string, first string) []string {
+	for i, arg := range args {
+		if arg == first {
+			return args[i:]
+		}
+	}
+	return nil
+}
+
+func TestQuoteProxyArg(t *testing.T) {
+	assert.Equal(t, "/usr/bin/ssh", quoteProxyArg("/usr/bin/ssh"))
+	assert.Equal(t, "'C:\\Program Files\\Git\\bin\\ssh.exe'", quoteProxyArg("C:\\Program Files\\Git\\bin\\ssh.exe"))
+	assert.Equal(t, `'it'\''s'`, quoteProxyArg("it's"))
+}
@@ -1,38 +1,76 @@
 package ssh
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/context"
 )
 
 type Client interface {
 	Output(command string) (string, error)
+	// OutputContext is Output, killing the command as soon as ctx is done.
+	OutputContext(ctx context.Context, command string) (string, error)
 	Shell(args ...string) error
+	Stream(command string, stdin io.Reader, stdout io.Writer) error
 }
 
 type ExternalClient struct {
 	BaseArgs   []string
 	BinaryPath string
+
+	// ControlPath is the socket of the connection shared by the
+	// commands, if they share one
+	ControlPath string
+
+	// TTY tells whether Shell allocates a terminal
+	TTY TTYMode
 }
 
 type NativeClient struct {
-	Config   ssh.ClientConfig
-	Hostname string
-	Port     int
+	Config        ssh.ClientConfig
+	Hostname      string
+	Port          int
+	Bastion       *Bastion
+	BastionConfig ssh.ClientConfig
+	ForwardAgent  bool
+
+	// Identity identifies the credentials the connections are opened
+	// with, for the ones opened with others not to be reused
+	Identity string
+
+	// TTY tells whether Shell allocates a terminal
+	TTY TTYMode
 }
 
 type Auth struct {
 	Passwords []string
 	Keys      []string
+
+	// Bastion is the jump host to connect through, authenticated with the
+	// same passwords and keys, when the host cannot be reached directly.
+	Bastion *Bastion
+
+	// ForwardAgent forwards the ssh-agent of the user to the host, for
+	// its keys to be used from there.
+	ForwardAgent bool
+
+	// Options are options of ssh, as Key=Value, taking precedence over the
+	// ones Machine sets. Only the external client takes them.
+	Options []string
 }
 
 type SSHClientType string
@@ -90,35 +128,84 @@ func NewClient(user string, host string, port int, auth *Auth) (Client, error) {
 }
 
 func NewNativeClient(user, host string, port int, auth *Auth) (Client, error) {
+	host = unbracketHost(host)
+
 	config, err := NewNativeConfig(user, auth)
 	if err != nil {
 		return nil, fmt.Errorf("Error getting config for native Go SSH: %s", err)
 	}
 
-	return NativeClient{
-		Config:   config,
-		Hostname: host,
-		Port:     port,
-	}, nil
+	if len(auth.Options) > 0 {
+		log.Debugf("The native Go SSH client ignores the SSH options %s", strings.Join(auth.Options, " "))
+	}
+
+	client := NativeClient{
+		Config:       config,
+		Hostname:     host,
+		Port:         port,
+		Bastion:      auth.Bastion,
+		ForwardAgent: auth.ForwardAgent,
+		Identity:     authIdentity(auth),
+	}
+
+	if auth.Bastion != nil {
+		bastionUser := user
+		if auth.Bastion.User != "" {
+			bastionUser = auth.Bastion.User
+		}
+
+		bastionAuth := *auth
+		if auth.Bastion.KeyPath != "" {
+			bastionAuth.Keys = append([]string{auth.Bastion.KeyPath}, auth.Keys...)
+		}
+
+		client.BastionConfig, err = NewNativeConfig(bastionUser, &bastionAuth)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting config for native Go SSH to the bastion: %s", err)
+		}
+	}
+
+	return client, nil
 }
 
 func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 	var (
 		authMethods []ssh.AuthMethod
+		signers     []ssh.Signer
 	)
 
+	// Keys which cannot be read, such as the ones protected by a
+	// passphrase, are left to ssh-agent when it is running.
+	keyring, agentErr := sshAgent()
+
 	for _, k := range auth.Keys {
-		key, err := ioutil.ReadFile(k)
+		privateKey, err := readPrivateKey(k)
 		if err != nil {
+			if agentErr == nil {
+				log.Debugf("Using the keys of ssh-agent, could not use %s: %s", k, err)
+				continue
+			}
 			return ssh.ClientConfig{}, err
 		}
 
-		privateKey, err := ssh.ParsePrivateKey(key)
-		if err != nil {
-			return ssh.ClientConfig{}, err
-		}
+		signers = append(signers, privateKey)
+	}
 
-		authMethods = append(authMethods, ssh.PublicKeys(privateKey))
+	// The keys are all offered by a single method, since each kind of
+	// method is only tried once.
+	if len(signers) > 0 || agentErr == nil {
+		authMethods = append(authMethods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if agentErr != nil {
+				return signers, nil
+			}
+
+			agentSigners, err := keyring.Signers()
+			if err != nil {
+				log.Debugf("Error getting the keys of ssh-agent: %s", err)
+				return signers, nil
+			}
+			return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+		}))
 	}
 
 	for _, p := range auth.Passwords {
@@ -131,20 +218,82 @@ func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 	}, nil
 }
 
-func (client NativeClient) dialSuccess() bool {
-	if _, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", client.Hostname, client.Port), &client.Config); err != nil {
-		log.Debugf("Error dialing TCP: %s", err)
-		return false
+// dial connects to the host, through the bastion and the proxy if there are.
+func (client NativeClient) dial() (*ssh.Client, error) {
+	addr := net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port))
+
+	dial := sshDial
+	if client.Bastion != nil {
+		dial = func(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+			return client.Bastion.dial(addr, config, &client.BastionConfig)
+		}
+	}
+
+	conn, err := dial(addr, &client.Config)
+	if err != nil {
+		return nil, err
 	}
-	return true
+
+	keepAlive(conn)
+	return conn, nil
+}
+
+// connect returns a connection to the host, the one of the previous
+// commands when connections are reused, and the function to call once done
+// with it, telling whether it can still be used.
+func (client NativeClient) connect() (*ssh.Client, func(keep bool), error) {
+	if !reuseConnections {
+		conn, err := client.dial()
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, func(bool) { conn.Close() }, nil
+	}
+
+	return pool.get(connectionKey(client.Config.User, client.Hostname, client.Port, client.Bastion, client.Identity), client.dial)
+}
+
+func readPrivateKey(path string) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePrivateKey(key)
+}
+
+// waitForDial waits for the host to accept connections. Giving up is an
+// mcnerror.ErrAuth when the host refused the credentials, and an
+// mcnerror.ErrSSHUnreachable otherwise, unless ctx is done.
+func (client NativeClient) waitForDial(ctx context.Context) error {
+	var lastErr error
+	err := mcnutils.WaitForContext(ctx, func() bool {
+		_, release, err := client.connect()
+		if err != nil {
+			log.Debugf("Error dialing TCP: %s", err)
+			lastErr = err
+			return false
+		}
+		release(true)
+		return true
+	})
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("Error attempting SSH client dial: %s", err)
+	if ctx.Err() != nil {
+		return err
+	}
+	return dialError(client.Hostname, err, lastErr)
 }
 
 func (client NativeClient) session(command string) (*ssh.Session, error) {
-	if err := mcnutils.WaitFor(client.dialSuccess); err != nil {
-		return nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
+	if err := client.waitForDial(context.Background()); err != nil {
+		return nil, err
 	}
 
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", client.Hostname, client.Port), &client.Config)
+	conn, err := client.dial()
 	if err != nil {
 		return nil, fmt.Errorf("Mysterious error dialing TCP for SSH (we already succeeded at least once) : %s", err)
 	}
@@ -153,15 +302,54 @@ func (client NativeClient) session(command string) (*ssh.Session, error) {
 }
 
 func (client NativeClient) Output(command string) (string, error) {
-	session, err := client.session(command)
+	return client.OutputContext(context.Background(), command)
+}
+
+func (client NativeClient) OutputContext(ctx context.Context, command string) (string, error) {
+	if err := client.waitForDial(ctx); err != nil {
+		return "", err
+	}
+
+	conn, release, err := client.connect()
 	if err != nil {
-		return "", nil
+		return "", fmt.Errorf("Mysterious error dialing TCP for SSH (we already succeeded at least once) : %s", err)
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		// The host refusing a session, such as past its MaxSessions,
+		// leaves the connection usable by the other commands.
+		_, refused := err.(*ssh.OpenChannelError)
+		release(refused)
+		return "", err
 	}
 
-	output, err := session.CombinedOutput(command)
 	defer session.Close()
 
-	return string(output), err
+	type result struct {
+		output []byte
+		err    error
+	}
+
+	resultCh := make(chan result, 1)
+	go func() {
+		output, err := session.CombinedOutput(command)
+		resultCh <- result{output, err}
+	}()
+
+	select {
+	case r := <-resultCh:
+		release(true)
+		return string(r.output), r.err
+	case <-ctx.Done():
+		// Closing the session makes CombinedOutput return, leaving the
+		// connection to the other commands sharing it. The pool checks
+		// that it still answers before reusing it.
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		release(true)
+		return "", ctx.Err()
+	}
 }
 
 func (client NativeClient) OutputWithPty(command string) (string, error) {
@@ -196,14 +384,13 @@ func (client NativeClient) OutputWithPty(command string) (string, error) {
 }
 
 func (client NativeClient) Shell(args ...string) error {
-	var (
-		termWidth, termHeight int
-	)
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", client.Hostname, client.Port), &client.Config)
+	conn, release, err := client.connect()
 	if err != nil {
 		return err
 	}
 
+	defer release(true)
+
 	session, err := conn.NewSession()
 	if err != nil {
 		return err
@@ -211,56 +398,124 @@ func (client NativeClient) Shell(args ...string) error {
 
 	defer session.Close()
 
+	if client.ForwardAgent {
+		if err := forwardAgent(conn, session); err != nil {
+			return err
+		}
+	}
+
 	session.Stdout = os.Stdout
 	session.Stderr = os.Stderr
 	session.Stdin = os.Stdin
 
-	modes := ssh.TerminalModes{
-		ssh.ECHO: 1,
-	}
+	if client.TTY.allocate() {
+		termWidth, termHeight := 80, 24
 
-	fd := os.Stdin.Fd()
+		fd := os.Stdin.Fd()
+		if term.IsTerminal(fd) {
+			oldState, err := term.MakeRaw(fd)
+			if err != nil {
+				return err
+			}
 
-	if term.IsTerminal(fd) {
-		oldState, err := term.MakeRaw(fd)
-		if err != nil {
-			return err
-		}
+			defer term.RestoreTerminal(fd, oldState)
 
-		defer term.RestoreTerminal(fd, oldState)
+			if winsize, err := term.GetWinsize(fd); err == nil {
+				termWidth = int(winsize.Width)
+				termHeight = int(winsize.Height)
+			}
+		}
 
-		winsize, err := term.GetWinsize(fd)
-		if err != nil {
-			termWidth = 80
-			termHeight = 24
-		} else {
-			termWidth = int(winsize.Width)
-			termHeight = int(winsize.Height)
+		modes := ssh.TerminalModes{
+			ssh.ECHO: 1,
 		}
-	}
 
-	if err := session.RequestPty("xterm", termHeight, termWidth, modes); err != nil {
-		return err
+		if err := session.RequestPty("xterm", termHeight, termWidth, modes); err != nil {
+			return err
+		}
 	}
 
 	if len(args) == 0 {
 		if err := session.Shell(); err != nil {
 			return err
 		}
-		session.Wait()
-	} else {
-		session.Run(strings.Join(args, " "))
+		return exitError(session.Wait())
+	}
+
+	return exitError(session.Run(strings.Join(args, " ")))
+}
+
+// Stream runs command on the remote host, feeding it stdin and copying its
+// standard output to stdout. It is meant for moving large amounts of data
+// (e.g. tar archives) without buffering them in memory.
+func (client NativeClient) Stream(command string, stdin io.Reader, stdout io.Writer) error {
+	conn, release, err := client.connect()
+	if err != nil {
+		return err
+	}
+
+	defer release(true)
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = os.Stderr
+
+	return session.Run(command)
 }
 
 func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (ExternalClient, error) {
+	host = unbracketHost(host)
+
 	client := ExternalClient{
-		BinaryPath: sshBinaryPath,
+		BinaryPath:  sshBinaryPath,
+		ControlPath: controlPath(user, host, port, auth.Bastion, authIdentity(auth)),
 	}
 
-	args := append(baseSSHArgs, fmt.Sprintf("%s@%s", user, host))
+	// ssh takes the first value given for an option, so the ones of the
+	// machine come first to take precedence.
+	args := append(optionArgs(auth.Options), baseSSHArgs...)
+
+	// Share the connection of the master started by the first command.
+	if client.ControlPath != "" {
+		for i, arg := range args {
+			if arg == "ControlPath=no" {
+				args[i] = "ControlPath=" + client.ControlPath
+			}
+		}
+	}
+
+	if keepAliveInterval > 0 {
+		args = append(args,
+			"-o", fmt.Sprintf("ServerAliveInterval=%d", int((keepAliveInterval+time.Second-1)/time.Second)),
+			"-o", fmt.Sprintf("ServerAliveCountMax=%d", keepAliveCountMax),
+		)
+	}
+
+	proxyArgs, err := proxyCommandArgs()
+	if err != nil {
+		return client, err
+	}
+
+	// Jump through the bastion, if any, with the same keys, else go
+	// through the proxy, if any.
+	if auth.Bastion != nil {
+		args = append(args, auth.Bastion.proxyCommand(sshBinaryPath, user, host, auth, proxyArgs)...)
+	} else {
+		args = append(args, proxyArgs...)
+	}
+
+	if auth.ForwardAgent {
+		args = append(args, "-A")
+	}
+
+	args = append(args, fmt.Sprintf("%s@%s", user, host))
 
 	// Specify which private keys to use to authorize the SSH request.
 	for _, privateKeyPath := range auth.Keys {
@@ -275,20 +530,59 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 	return client, nil
 }
 
+// unbracketHost returns host without the brackets of an IPv6 literal, e.g.
+// [2001:db8::1], which ssh doesn't take and net.JoinHostPort adds.
+func unbracketHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
 func getSSHCmd(binaryPath string, args ...string) *exec.Cmd {
 	return exec.Command(binaryPath, args...)
 }
 
 func (client ExternalClient) Output(command string) (string, error) {
-	args := append(client.BaseArgs, command)
+	return client.OutputContext(context.Background(), command)
+}
+
+func (client ExternalClient) OutputContext(ctx context.Context, command string) (string, error) {
+	client.startMaster()
+
+	args := append([]string{}, client.BaseArgs...)
+	args = append(args, command)
 	cmd := getSSHCmd(client.BinaryPath, args...)
-	output, err := cmd.CombinedOutput()
-	return string(output), err
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-waitCh:
+		return output.String(), externalError(output.String(), err)
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-waitCh
+		return output.String(), ctx.Err()
+	}
 }
 
 func (client ExternalClient) Shell(args ...string) error {
-	args = append(client.BaseArgs, args...)
-	cmd := getSSHCmd(client.BinaryPath, args...)
+	client.startMaster()
+
+	sshArgs := append([]string{}, client.BaseArgs...)
+	sshArgs = append(sshArgs, client.TTY.sshArgs()...)
+	cmd := getSSHCmd(client.BinaryPath, append(sshArgs, args...)...)
 
 	log.Debug(cmd)
 
@@ -296,5 +590,21 @@ func (client ExternalClient) Shell(args ...string) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	return exitError(cmd.Run())
+}
+
+func (client ExternalClient) Stream(command string, stdin io.Reader, stdout io.Writer) error {
+	client.startMaster()
+
+	args := append([]string{}, client.BaseArgs...)
+	args = append(args, command)
+	cmd := getSSHCmd(client.BinaryPath, args...)
+
+	log.Debug(cmd)
+
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
 	return cmd.Run()
 }
@@ -1,9 +1,12 @@
 package ssh
 
 import (
+	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestGetSSHCmdArgs(t *testing.T) {
@@ -43,3 +46,23 @@ func TestGetSSHCmdArgs(t *testing.T) {
 		assert.Equal(t, cmd.Args, c.expectedArgs)
 	}
 }
+
+func TestExternalClientOutputContextCanceled(t *testing.T) {
+	sleepPath, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep binary not found")
+	}
+
+	// The command is handed to the binary as its last argument, so sleep
+	// stands in for a hung SSH command.
+	client := ExternalClient{BinaryPath: sleepPath}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.OutputContext(ctx, "60")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// The vendored SSH package knows nothing of ed25519 keys, so they are
+// implemented here: their wire format and signatures, see RFC 8709, and the
+// private key files of OpenSSH they are stored in.
+
+const (
+	ed25519KeyAlgo = "ssh-ed25519"
+
+	openSSHKeyMagic   = "openssh-key-v1\x00"
+	openSSHPEMType    = "OPENSSH PRIVATE KEY"
+	openSSHBlockSize  = 8
+	openSSHNoneCipher = "none"
+)
+
+type ed25519PublicKey ed25519.PublicKey
+
+func (k ed25519PublicKey) Type() string {
+	return ed25519KeyAlgo
+}
+
+func (k ed25519PublicKey) Marshal() []byte {
+	return gossh.Marshal(&struct {
+		Name string
+		Key  []byte
+	}{ed25519KeyAlgo, k})
+}
+
+func (k ed25519PublicKey) Verify(data []byte, sig *gossh.Signature) error {
+	if sig.Format != ed25519KeyAlgo {
+		return fmt.Errorf("signature type %s for key type %s", sig.Format, ed25519KeyAlgo)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(k), data, sig.Blob) {
+		return errors.New("ed25519 signature verification failed")
+	}
+	return nil
+}
+
+type ed25519Signer ed25519.PrivateKey
+
+func (s ed25519Signer) PublicKey() gossh.PublicKey {
+	return ed25519PublicKey(ed25519.PrivateKey(s).Public().(ed25519.PublicKey))
+}
+
+func (s ed25519Signer) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	return &gossh.Signature{
+		Format: ed25519KeyAlgo,
+		Blob:   ed25519.Sign(ed25519.PrivateKey(s), data),
+	}, nil
+}
+
+// openSSHKey is the content of the private key files of OpenSSH.
+type openSSHKey struct {
+	CipherName   string
+	KdfName      string
+	KdfOpts      string
+	NumKeys      uint32
+	PubKey       []byte
+	PrivKeyBlock []byte
+}
+
+// openSSHEd25519Key is the private key block of an ed25519 key.
+type openSSHEd25519Key struct {
+	Check1  uint32
+	Check2  uint32
+	Type    string
+	Pub     []byte
+	Priv    []byte
+	Comment string
+	Pad     []byte `ssh:"rest"`
+}
+
+// NewEd25519KeyPair generates a new ed25519 SSH keypair. Its private key is
+// in the format of OpenSSH.
+func NewEd25519KeyPair() (*KeyPair, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, ErrKeyGeneration
+	}
+
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, ErrKeyGeneration
+	}
+
+	block := gossh.Marshal(&openSSHEd25519Key{
+		Check1: binary.BigEndian.Uint32(check[:]),
+		Check2: binary.BigEndian.Uint32(check[:]),
+		Type:   ed25519KeyAlgo,
+		Pub:    pub,
+		Priv:   priv,
+	})
+	for i := byte(1); len(block)%openSSHBlockSize != 0; i++ {
+		block = append(block, i)
+	}
+
+	pubSSH := ed25519PublicKey(pub)
+	privateKey := append([]byte(openSSHKeyMagic), gossh.Marshal(&openSSHKey{
+		CipherName:   openSSHNoneCipher,
+		KdfName:      openSSHNoneCipher,
+		NumKeys:      1,
+		PubKey:       pubSSH.Marshal(),
+		PrivKeyBlock: block,
+	})...)
+
+	return &KeyPair{
+		PrivateKey: privateKey,
+		PublicKey:  gossh.MarshalAuthorizedKey(pubSSH),
+		pemType:    openSSHPEMType,
+	}, nil
+}
+
+// parsePrivateKey returns a signer for the PEM encoded private key, which
+// may be an ed25519 key in the format of OpenSSH.
+func parsePrivateKey(pemBytes []byte) (gossh.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != openSSHPEMType {
+		return gossh.ParsePrivateKey(pemBytes)
+	}
+
+	if !bytes.HasPrefix(block.Bytes, []byte(openSSHKeyMagic)) {
+		return nil, errors.New("invalid OpenSSH private key")
+	}
+
+	var key openSSHKey
+	if err := gossh.Unmarshal(block.Bytes[len(openSSHKeyMagic):], &key); err != nil {
+		return nil, err
+	}
+	if key.CipherName != openSSHNoneCipher || key.KdfName != openSSHNoneCipher {
+		return nil, errors.New("encrypted OpenSSH private keys are not supported")
+	}
+	if key.NumKeys != 1 {
+		return nil, fmt.Errorf("OpenSSH private key holds %d keys, expected 1", key.NumKeys)
+	}
+
+	var priv openSSHEd25519Key
+	if err := gossh.Unmarshal(key.PrivKeyBlock, &priv); err != nil {
+		return nil, err
+	}
+	if priv.Check1 != priv.Check2 {
+		return nil, errors.New("invalid OpenSSH private key")
+	}
+	if priv.Type != ed25519KeyAlgo {
+		return nil, fmt.Errorf("OpenSSH private keys of type %s are not supported", priv.Type)
+	}
+	if len(priv.Priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+
+	return ed25519Signer(priv.Priv), nil
+}
@@ -0,0 +1,51 @@
+package ssh
+
+import (
+	"strings"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+// unreachableMessages are the messages of the ssh binary failing to connect
+// to a host.
+var unreachableMessages = []string{
+	"Connection refused",
+	"Connection timed out",
+	"Operation timed out",
+	"No route to host",
+	"Network is unreachable",
+	"Could not resolve hostname",
+	"Connection closed by",
+	"Connection reset by",
+}
+
+// dialError returns err, the error of giving up connecting to the host, as
+// an mcnerror.ErrAuth when the last attempt failed on the credentials, and
+// as an mcnerror.ErrSSHUnreachable otherwise.
+func dialError(host string, err, lastAttemptErr error) error {
+	if lastAttemptErr != nil && strings.Contains(lastAttemptErr.Error(), "unable to authenticate") {
+		return mcnerror.ErrAuth{Err: err}
+	}
+	return mcnerror.ErrSSHUnreachable{Host: host, Err: err}
+}
+
+// externalError returns the error of a command run with the ssh binary as
+// an mcnerror.ErrAuth or an mcnerror.ErrSSHUnreachable when ssh itself
+// failed, telling so with its exit status 255 and its output.
+func externalError(output string, err error) error {
+	if e, ok := exitError(err).(ExitError); !ok || e.Status != 255 {
+		return err
+	}
+
+	if strings.Contains(output, "Permission denied") {
+		return mcnerror.ErrAuth{Err: err}
+	}
+
+	for _, msg := range unreachableMessages {
+		if strings.Contains(output, msg) {
+			return mcnerror.ErrSSHUnreachable{Err: err}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,28 @@
+package ssh
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialError(t *testing.T) {
+	err := errors.New("Error waiting for SSH")
+
+	assert.Equal(t, mcnerror.CategoryAuth, mcnerror.CategoryOf(dialError("web", err, errors.New("ssh: unable to authenticate, attempted methods [none publickey]"))))
+	assert.Equal(t, mcnerror.CategorySSHUnreachable, mcnerror.CategoryOf(dialError("web", err, errors.New("dial tcp: connection refused"))))
+	assert.Equal(t, mcnerror.CategorySSHUnreachable, mcnerror.CategoryOf(dialError("web", err, nil)))
+}
+
+func TestExternalError(t *testing.T) {
+	sshFailed := exec.Command("sh", "-c", "exit 255").Run()
+	commandFailed := exec.Command("sh", "-c", "exit 1").Run()
+
+	assert.Equal(t, mcnerror.CategoryAuth, mcnerror.CategoryOf(externalError("docker@1.2.3.4: Permission denied (publickey).", sshFailed)))
+	assert.Equal(t, mcnerror.CategorySSHUnreachable, mcnerror.CategoryOf(externalError("ssh: connect to host 1.2.3.4 port 22: Connection refused", sshFailed)))
+	assert.Equal(t, mcnerror.CategoryUnknown, mcnerror.CategoryOf(externalError("Connection refused", commandFailed)))
+	assert.Equal(t, commandFailed, externalError("Connection refused", commandFailed))
+}
@@ -10,12 +10,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
 
 	gossh "golang.org/x/crypto/ssh"
 )
 
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
 var (
 	ErrKeyGeneration     = errors.New("Unable to generate key")
 	ErrValidation        = errors.New("Unable to validate key")
@@ -26,6 +32,32 @@ var (
 type KeyPair struct {
 	PrivateKey []byte
 	PublicKey  []byte
+
+	// pemType is the PEM type the private key is written with, RSA
+	// PRIVATE KEY if empty
+	pemType string
+}
+
+// ValidateKeyType returns an error if keyType isn't a type of SSH key
+// which can be generated.
+func ValidateKeyType(keyType string) error {
+	switch keyType {
+	case KeyTypeRSA, KeyTypeEd25519:
+		return nil
+	}
+	return fmt.Errorf("Unsupported SSH key type %q, expected %s or %s", keyType, KeyTypeRSA, KeyTypeEd25519)
+}
+
+// NewKeyPairOfType generates a new SSH keypair of the given type, rsa when
+// empty.
+func NewKeyPairOfType(keyType string) (*KeyPair, error) {
+	switch keyType {
+	case "", KeyTypeRSA:
+		return NewKeyPair()
+	case KeyTypeEd25519:
+		return NewEd25519KeyPair()
+	}
+	return nil, ValidateKeyType(keyType)
 }
 
 // Generate a new SSH keypair
@@ -55,6 +87,11 @@ func NewKeyPair() (keyPair *KeyPair, err error) {
 
 // Write keypair to files
 func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) error {
+	pemType := kp.pemType
+	if pemType == "" {
+		pemType = "RSA PRIVATE KEY"
+	}
+
 	files := []struct {
 		File  string
 		Type  string
@@ -62,7 +99,7 @@ func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) erro
 	}{
 		{
 			File:  privateKeyPath,
-			Value: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Headers: nil, Bytes: kp.PrivateKey}),
+			Value: pem.EncodeToMemory(&pem.Block{Type: pemType, Headers: nil, Bytes: kp.PrivateKey}),
 		},
 		{
 			File:  publicKeyPath,
@@ -105,12 +142,18 @@ func (kp *KeyPair) Fingerprint() string {
 // Generate SSH keypair based on path of the private key
 // The public key would be generated to the same path with ".pub" added
 func GenerateSSHKey(path string) error {
+	return GenerateSSHKeyOfType(path, KeyTypeRSA)
+}
+
+// GenerateSSHKeyOfType is GenerateSSHKey for keys of the given type, rsa
+// when empty.
+func GenerateSSHKeyOfType(path, keyType string) error {
 	if _, err := os.Stat(path); err != nil {
 		if !os.IsNotExist(err) {
 			return fmt.Errorf("Desired directory for SSH keys does not exist: %s", err)
 		}
 
-		kp, err := NewKeyPair()
+		kp, err := NewKeyPairOfType(keyType)
 		if err != nil {
 			return fmt.Errorf("Error generating key pair: %s", err)
 		}
@@ -122,3 +165,18 @@ func GenerateSSHKey(path string) error {
 
 	return nil
 }
+
+// ReadPublicKey returns the public key of the private key at path, in the
+// authorized_keys format, from the ".pub" file next to it if there is one.
+func ReadPublicKey(privateKeyPath string) ([]byte, error) {
+	if publicKey, err := ioutil.ReadFile(privateKeyPath + ".pub"); err == nil {
+		return publicKey, nil
+	}
+
+	signer, err := readPrivateKey(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return gossh.MarshalAuthorizedKey(signer.PublicKey()), nil
+}
@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var optionKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// ValidateOption returns an error if opt isn't an option of ssh as given to
+// its -o flag, Key=Value. An empty value is taken, for MergeOptions to
+// remove the option of the key.
+func ValidateOption(opt string) error {
+	parts := strings.SplitN(opt, "=", 2)
+	if len(parts) != 2 || !optionKeyPattern.MatchString(parts[0]) || strings.ContainsAny(parts[1], "\r\n") {
+		return fmt.Errorf("Invalid SSH option %q, expected Key=Value", opt)
+	}
+	return nil
+}
+
+// MergeOptions returns opts with the options of overrides, an option of
+// overrides replacing the one of opts with the same key, and removing it
+// when its value is empty.
+func MergeOptions(opts, overrides []string) []string {
+	merged := append([]string{}, opts...)
+
+	for _, override := range overrides {
+		key := optionKey(override)
+
+		kept := merged[:0]
+		for _, opt := range merged {
+			if optionKey(opt) != key {
+				kept = append(kept, opt)
+			}
+		}
+		merged = kept
+
+		if !strings.HasSuffix(override, "=") {
+			merged = append(merged, override)
+		}
+	}
+
+	return merged
+}
+
+// optionKey returns the key of an option, which ssh matches regardless of
+// the case.
+func optionKey(opt string) string {
+	return strings.ToLower(strings.SplitN(opt, "=", 2)[0])
+}
+
+// optionArgs returns the arguments of ssh giving it the options.
+func optionArgs(opts []string) []string {
+	args := []string{}
+	for _, opt := range opts {
+		args = append(args, "-o", opt)
+	}
+	return args
+}
@@ -0,0 +1,34 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOption(t *testing.T) {
+	assert.NoError(t, ValidateOption("Compression=yes"))
+	assert.NoError(t, ValidateOption("ProxyCommand=nc -x proxy:1080 %h %p"))
+	assert.NoError(t, ValidateOption("Compression="))
+
+	assert.EqualError(t, ValidateOption("Compression"), `Invalid SSH option "Compression", expected Key=Value`)
+	assert.Error(t, ValidateOption("=yes"))
+	assert.Error(t, ValidateOption("Com pression=yes"))
+	assert.Error(t, ValidateOption("Compression=yes\nProxyCommand=sh"))
+}
+
+func TestMergeOptions(t *testing.T) {
+	opts := []string{"Compression=no", "Ciphers=aes256-ctr"}
+
+	merged := MergeOptions(opts, []string{"compression=yes", "Ciphers=", "ServerAliveInterval=5"})
+
+	assert.Equal(t, []string{"compression=yes", "ServerAliveInterval=5"}, merged)
+	assert.Equal(t, []string{"Compression=no", "Ciphers=aes256-ctr"}, opts)
+}
+
+func TestExternalClientOptions(t *testing.T) {
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "10.0.0.5", 22, &Auth{Options: []string{"StrictHostKeyChecking=yes"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-o", "StrictHostKeyChecking=yes", "-o", "PasswordAuthentication=no"}, client.BaseArgs[:4])
+}
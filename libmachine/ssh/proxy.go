@@ -0,0 +1,268 @@
+package ssh
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshProxy is the proxy the clients connect to the hosts through, if any.
+var sshProxy *url.URL
+
+// SetProxy makes the clients connect to the hosts, or to their bastions,
+// through a SOCKS5 or HTTP proxy, given as socks5://[user:password@]host:port
+// or http://[user:password@]host:port, or directly when proxy is empty. The
+// external client goes through it with nc, which cannot authenticate to the
+// proxy.
+func SetProxy(proxy string) error {
+	if proxy == "" {
+		sshProxy = nil
+		return nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("Invalid SSH proxy %q, expected socks5://host:port or http://host:port", proxy)
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Host, "1080")
+		}
+	case "http":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Host, "80")
+		}
+	default:
+		return fmt.Errorf("Invalid SSH proxy %q, only socks5 and http proxies are supported", proxy)
+	}
+
+	sshProxy = u
+	return nil
+}
+
+// sshDial connects to the SSH server at addr, through the proxy if there is
+// one.
+func sshDial(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if sshProxy == nil {
+		return ssh.Dial("tcp", addr, config)
+	}
+
+	conn, err := proxyDial(sshProxy, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// proxyDial connects to addr through the proxy.
+func proxyDial(proxy *url.URL, addr string) (net.Conn, error) {
+	conn, err := net.Dial("tcp", proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("Error dialing SSH proxy %s: %s", proxy.Host, err)
+	}
+
+	if proxy.Scheme == "http" {
+		conn, err = httpConnect(conn, addr, proxy.User)
+	} else {
+		err = socks5Connect(conn, addr, proxy.User)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error connecting to %s through SSH proxy %s: %s", addr, proxy.Host, err)
+	}
+
+	return conn, nil
+}
+
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socks5Connect asks a SOCKS5 proxy to connect conn to addr, see RFC 1928
+// and RFC 1929. Host names are resolved by the proxy.
+func socks5Connect(conn net.Conn, addr string, user *url.Userinfo) error {
+	host, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portString)
+	}
+
+	methods := []byte{0}
+	if user != nil {
+		methods = append(methods, 2)
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 {
+		return errors.New("not a SOCKS5 proxy")
+	}
+
+	switch reply[1] {
+	case 0:
+	case 2:
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return errors.New("SOCKS5 credentials too long")
+		}
+
+		request := []byte{1, byte(len(user.Username()))}
+		request = append(request, user.Username()...)
+		request = append(request, byte(len(password)))
+		request = append(request, password...)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("SOCKS5 proxy rejected the credentials")
+		}
+	default:
+		return errors.New("SOCKS5 proxy accepts none of the authentication methods offered")
+	}
+
+	request := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		request = append(request, 3, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, 1)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, 4)
+		request = append(request, ip.To16()...)
+	}
+	request = append(request, 0, 0)
+	binary.BigEndian.PutUint16(request[len(request)-2:], uint16(port))
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		if message, ok := socks5Errors[header[1]]; ok {
+			return errors.New("SOCKS5 proxy: " + message)
+		}
+		return fmt.Errorf("SOCKS5 proxy error %d", header[1])
+	}
+
+	// Skip the address the proxy bound.
+	var length int
+	switch header[3] {
+	case 1:
+		length = net.IPv4len
+	case 4:
+		length = net.IPv6len
+	case 3:
+		if _, err := io.ReadFull(conn, header[:1]); err != nil {
+			return err
+		}
+		length = int(header[0])
+	default:
+		return fmt.Errorf("SOCKS5 proxy answered with address type %d", header[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, length+2))
+	return err
+}
+
+// bufferedConn is a connection whose first bytes were read ahead.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// httpConnect asks an HTTP proxy to connect conn to addr with a CONNECT
+// request.
+func httpConnect(conn net.Conn, addr string, user *url.Userinfo) (net.Conn, error) {
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		return conn, err
+	}
+
+	// The SSH server may speak first, so what the reader buffered past the
+	// response has to be read from it afterwards.
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return conn, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("HTTP proxy answered %s", resp.Status)
+	}
+
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// proxyCommandArgs returns the ssh options making the external client
+// connect through the proxy, with nc.
+func proxyCommandArgs() ([]string, error) {
+	if sshProxy == nil {
+		return nil, nil
+	}
+
+	if sshProxy.User != nil {
+		return nil, errors.New("The external SSH client cannot authenticate to the SSH proxy, use --native-ssh")
+	}
+
+	protocol := "5"
+	if sshProxy.Scheme == "http" {
+		protocol = "connect"
+	}
+
+	command := []string{"nc", "-X", protocol, "-x", quoteProxyArg(sshProxy.Host), "%h", "%p"}
+	return []string{"-o", "ProxyCommand=" + strings.Join(command, " ")}, nil
+}
@@ -0,0 +1,330 @@
+package ssh
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultKeepAliveInterval = 30 * time.Second
+
+	// keepAliveCountMax is how many keep-alives in a row may go unanswered
+	// before the connection is given up on, like ServerAliveCountMax.
+	keepAliveCountMax = 3
+
+	// connectionIdleTimeout is how long connections are kept open for the
+	// next commands once they are not used anymore.
+	connectionIdleTimeout = 1 * time.Minute
+
+	// pingTimeout is how long to wait for the answer to a keep-alive.
+	pingTimeout = 10 * time.Second
+)
+
+var (
+	keepAliveInterval = defaultKeepAliveInterval
+	reuseConnections  = true
+
+	errPingTimeout = errors.New("no answer to the SSH keep-alive")
+)
+
+// SetKeepAlive makes the clients send keep-alives on the connections which
+// have been idle for interval, like the ServerAliveInterval of ssh, so that
+// long silent commands don't get their connection dropped by NAT gateways,
+// and dead connections are noticed. A zero interval disables them.
+func SetKeepAlive(interval time.Duration) {
+	keepAliveInterval = interval
+}
+
+// SetConnectionReuse sets whether the commands run on a host share a
+// connection to it, instead of each opening its own. The native client
+// keeps a pool of connections, the external one shares them with
+// ControlMaster, except on Windows.
+func SetConnectionReuse(reuse bool) {
+	reuseConnections = reuse
+}
+
+// ping sends a keep-alive on the connection, and waits for the answer.
+func ping(conn ssh.Conn, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		return errPingTimeout
+	}
+}
+
+// keepAlive sends keep-alives on the connection until it is closed, and
+// closes it when too many go unanswered.
+func keepAlive(conn ssh.Conn) {
+	interval := keepAliveInterval
+	if interval <= 0 {
+		return
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(closed)
+	}()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		missed := 0
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+			}
+
+			if err := ping(conn, interval); err != nil {
+				missed++
+				log.Debugf("SSH keep-alive %d/%d failed: %s", missed, keepAliveCountMax, err)
+				if missed >= keepAliveCountMax {
+					conn.Close()
+					return
+				}
+				continue
+			}
+			missed = 0
+		}
+	}()
+}
+
+// connPool holds the connections of the native client to reuse them, by
+// user, address, bastion and credentials.
+type connPool struct {
+	sync.Mutex
+	conns map[string]*pooledConn
+}
+
+type pooledConn struct {
+	*ssh.Client
+	users int
+	idle  *time.Timer
+}
+
+var pool = &connPool{conns: map[string]*pooledConn{}}
+
+// get returns the pooled connection for key, or a new one from dial when
+// there is none or it doesn't answer anymore, and the function to give it
+// back with, telling whether it can still be used.
+func (p *connPool) get(key string, dial func() (*ssh.Client, error)) (*ssh.Client, func(keep bool), error) {
+	p.Lock()
+	pc, ok := p.conns[key]
+	if ok {
+		pc.users++
+		if pc.idle != nil {
+			pc.idle.Stop()
+		}
+	}
+	p.Unlock()
+
+	if ok {
+		if err := ping(pc, pingTimeout); err == nil {
+			return pc.Client, p.releaser(key, pc), nil
+		}
+		log.Debugf("Reconnecting, the pooled SSH connection to %s is broken", key)
+		p.release(key, pc, false)
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pc = &pooledConn{Client: conn, users: 1}
+
+	p.Lock()
+	defer p.Unlock()
+
+	// Another command may have pooled a connection meanwhile.
+	if _, ok := p.conns[key]; ok {
+		return conn, func(bool) { conn.Close() }, nil
+	}
+	p.conns[key] = pc
+
+	return conn, p.releaser(key, pc), nil
+}
+
+func (p *connPool) releaser(key string, pc *pooledConn) func(keep bool) {
+	var once sync.Once
+	return func(keep bool) {
+		once.Do(func() {
+			p.release(key, pc, keep)
+		})
+	}
+}
+
+// release gives a connection back, closing it if it cannot be used anymore
+// or once it has been idle for connectionIdleTimeout.
+func (p *connPool) release(key string, pc *pooledConn, keep bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	pc.users--
+
+	if !keep {
+		pc.Close()
+		if p.conns[key] == pc {
+			delete(p.conns, key)
+		}
+		return
+	}
+
+	if pc.users > 0 {
+		return
+	}
+
+	pc.idle = time.AfterFunc(connectionIdleTimeout, func() {
+		p.Lock()
+		defer p.Unlock()
+
+		if pc.users == 0 && p.conns[key] == pc {
+			delete(p.conns, key)
+			pc.Close()
+		}
+	})
+}
+
+// authIdentity identifies the credentials and the options connections are
+// opened with: the paths and the contents of the keys, the passwords and
+// the options. The connections opened with other ones, such as a key since
+// rotated, are not reused.
+func authIdentity(auth *Auth) string {
+	if auth == nil {
+		return ""
+	}
+
+	keys := auth.Keys
+	if auth.Bastion != nil && auth.Bastion.KeyPath != "" {
+		keys = append([]string{auth.Bastion.KeyPath}, keys...)
+	}
+
+	h := sha1.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "key %s\n", key)
+		if data, err := ioutil.ReadFile(key); err == nil {
+			h.Write(data)
+		}
+	}
+	for _, password := range auth.Passwords {
+		fmt.Fprintf(h, "password %s\n", password)
+	}
+	for _, option := range auth.Options {
+		fmt.Fprintf(h, "option %s\n", option)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+// connectionKey identifies the connections to a host for reuse, with the
+// authIdentity of their credentials.
+func connectionKey(user, host string, port int, bastion *Bastion, identity string) string {
+	key := fmt.Sprintf("%s@%s:%d", user, host, port)
+	if bastion != nil {
+		key += " via " + bastion.String()
+	}
+	if identity != "" {
+		key += " as " + identity
+	}
+	return key
+}
+
+// controlPath returns the socket through which the external client shares
+// the connections to a host, or an empty string when they aren't shared.
+func controlPath(user, host string, port int, bastion *Bastion, identity string) string {
+	if !reuseConnections || runtime.GOOS == "windows" {
+		return ""
+	}
+
+	// Sockets paths are short, so they are named after a hash of the
+	// connection in a directory of the user.
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("docker-machine-ssh-%d", os.Getuid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Debugf("Not sharing SSH connections: %s", err)
+		return ""
+	}
+	if err := checkControlDir(dir); err != nil {
+		log.Warnf("Not sharing SSH connections: %s", err)
+		return ""
+	}
+
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(connectionKey(user, host, port, bastion, identity))))
+	return filepath.Join(dir, hash[:16])
+}
+
+// checkControlDir makes sure that only the user can reach the sockets in dir.
+// It is in a directory shared with the other users, one of which could have
+// created it, or a link to one of theirs, before.
+func checkControlDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if uid, ok := fileOwner(fi); ok && uid != os.Getuid() {
+		return fmt.Errorf("%s is owned by the user %d rather than %d", dir, uid, os.Getuid())
+	}
+	if perm := fi.Mode().Perm(); perm != 0700 {
+		return fmt.Errorf("%s has the mode %o rather than 700", dir, perm)
+	}
+
+	return nil
+}
+
+var masterLock sync.Mutex
+
+// startMaster starts the connection shared by the commands of the external
+// client in the background, unless it is running already. A connection
+// which cannot be started only means that the commands connect on their
+// own.
+func (client ExternalClient) startMaster() {
+	if client.ControlPath == "" {
+		return
+	}
+
+	masterLock.Lock()
+	defer masterLock.Unlock()
+
+	if _, err := os.Stat(client.ControlPath); err == nil {
+		check := append([]string{"-O", "check"}, client.BaseArgs...)
+		if err := getSSHCmd(client.BinaryPath, check...).Run(); err == nil {
+			return
+		}
+		os.Remove(client.ControlPath)
+	}
+
+	// The output of ssh is left out, since the background process would
+	// keep it open for as long as it runs.
+	args := []string{
+		"-o", "ControlMaster=yes",
+		"-o", fmt.Sprintf("ControlPersist=%d", int(connectionIdleTimeout.Seconds())),
+		"-N", "-f",
+	}
+	args = append(args, client.BaseArgs...)
+	if err := getSSHCmd(client.BinaryPath, args...).Run(); err != nil {
+		log.Debugf("Error starting the shared SSH connection: %s", err)
+	}
+}
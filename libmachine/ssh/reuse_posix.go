@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package ssh
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the user owning the file.
+func fileOwner(fi os.FileInfo) (int, bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
@@ -0,0 +1,190 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
+)
+
+func TestNativeClientReusesConnections(t *testing.T) {
+	key := generateTestKey(t)
+	authorized, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, connections, stopSSH := serveSSH(t, authorized)
+	defer stopSSH()
+	defer serveAgent(t, key)()
+
+	for i := 0; i < 3; i++ {
+		output, err := newTestNativeClient(t, addr, nil).Output("true")
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", output)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(connections))
+
+	// A broken pooled connection is replaced.
+	pool.Lock()
+	for _, pc := range pool.conns {
+		pc.Close()
+	}
+	pool.Unlock()
+
+	_, err = newTestNativeClient(t, addr, nil).Output("true")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(connections))
+
+	SetConnectionReuse(false)
+	defer SetConnectionReuse(true)
+
+	// Without reuse, the check that SSH is available connects too.
+	_, err = newTestNativeClient(t, addr, nil).Output("true")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(connections))
+}
+
+func TestNativeClientCancelKeepsConnection(t *testing.T) {
+	key := generateTestKey(t)
+	authorized, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, connections, stopSSH := serveSSH(t, authorized)
+	defer stopSSH()
+	defer serveAgent(t, key)()
+
+	client := newTestNativeClient(t, addr, nil)
+	_, err = client.Output("true")
+	assert.NoError(t, err)
+
+	// Canceling a command closes its session only, the other commands
+	// keep sharing the connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.OutputContext(ctx, "hang")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	output, err := client.Output("true")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", output)
+	assert.Equal(t, int32(1), atomic.LoadInt32(connections))
+}
+
+func TestAuthIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-keys-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := filepath.Join(dir, "id_rsa")
+	assert.NoError(t, ioutil.WriteFile(key, []byte("old key"), 0600))
+	old := authIdentity(&Auth{Keys: []string{key}})
+
+	// A key rotated in place, another key or other options are another
+	// identity, which the connections of the old one are not reused for.
+	assert.NoError(t, ioutil.WriteFile(key, []byte("new key"), 0600))
+	rotated := authIdentity(&Auth{Keys: []string{key}})
+	assert.NotEqual(t, old, rotated)
+	assert.NotEqual(t, rotated, authIdentity(&Auth{Keys: []string{key + ".new"}}))
+	assert.NotEqual(t, rotated, authIdentity(&Auth{Keys: []string{key}, Options: []string{"Ciphers=aes128-ctr"}}))
+	assert.Equal(t, rotated, authIdentity(&Auth{Keys: []string{key}}))
+
+	assert.Equal(t, "docker@10.0.0.5:22 as "+rotated, connectionKey("docker", "10.0.0.5", 22, nil, rotated))
+}
+
+func TestConnPoolIdleTimeout(t *testing.T) {
+	p := &connPool{conns: map[string]*pooledConn{}}
+	pc := &pooledConn{users: 2}
+	p.conns["key"] = pc
+
+	// The connection is in use until it is released by all its users.
+	p.release("key", pc, true)
+	assert.Nil(t, pc.idle)
+
+	p.release("key", pc, true)
+	assert.NotNil(t, pc.idle)
+	assert.True(t, pc.idle.Stop())
+	assert.Equal(t, pc, p.conns["key"])
+}
+
+func TestNewExternalClientKeepAliveAndControlPath(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmpDir)
+
+	SetKeepAlive(45 * time.Second)
+	defer SetKeepAlive(defaultKeepAliveInterval)
+
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "10.0.0.5", 22, &Auth{})
+	assert.NoError(t, err)
+
+	args := strings.Join(client.BaseArgs, " ")
+	assert.Contains(t, args, "-o ServerAliveInterval=45 -o ServerAliveCountMax=3")
+	if client.ControlPath != "" {
+		assert.Equal(t, tmpDir, filepath.Dir(filepath.Dir(client.ControlPath)))
+		assert.Contains(t, args, "-o ControlMaster=no -o ControlPath="+client.ControlPath)
+		assert.NotContains(t, args, "ControlPath=no")
+	}
+
+	SetKeepAlive(0)
+	SetConnectionReuse(false)
+	defer SetConnectionReuse(true)
+
+	client, err = NewExternalClient("/usr/bin/ssh", "docker", "10.0.0.5", 22, &Auth{})
+	assert.NoError(t, err)
+
+	args = strings.Join(client.BaseArgs, " ")
+	assert.Equal(t, "", client.ControlPath)
+	assert.Contains(t, args, "-o ControlPath=no")
+	assert.NotContains(t, args, "ServerAlive")
+}
+
+func TestControlPathRefusesUnsafeDirectories(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Connections aren't shared on Windows")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmpDir)
+
+	dir := filepath.Join(tmpDir, fmt.Sprintf("docker-machine-ssh-%d", os.Getuid()))
+
+	assert.NoError(t, os.Mkdir(dir, 0777))
+	assert.NoError(t, os.Chmod(dir, 0777))
+	assert.Equal(t, "", controlPath("docker", "10.0.0.5", 22, nil, ""), "a directory others can write to")
+	assert.NoError(t, os.Remove(dir))
+
+	other := filepath.Join(tmpDir, "other")
+	assert.NoError(t, os.Mkdir(other, 0700))
+	assert.NoError(t, os.Symlink(other, dir))
+	assert.Equal(t, "", controlPath("docker", "10.0.0.5", 22, nil, ""), "a link to another directory")
+	assert.NoError(t, os.Remove(dir))
+
+	path := controlPath("docker", "10.0.0.5", 22, nil, "")
+	assert.Equal(t, dir, filepath.Dir(path))
+	fi, err := os.Lstat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+}
@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/docker/docker/pkg/term"
+	"golang.org/x/crypto/ssh"
+)
+
+// TTYMode tells whether Shell allocates a terminal on the remote host.
+type TTYMode int
+
+const (
+	// TTYAuto allocates a terminal when the standard input and output are
+	// terminals, not to mangle the data piped to or from the command.
+	TTYAuto TTYMode = iota
+
+	// TTYForce always allocates a terminal.
+	TTYForce
+
+	// TTYNone never allocates a terminal.
+	TTYNone
+)
+
+// allocate reports whether a terminal is allocated in the mode.
+func (mode TTYMode) allocate() bool {
+	switch mode {
+	case TTYForce:
+		return true
+	case TTYNone:
+		return false
+	}
+	return term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stdout.Fd())
+}
+
+// sshArgs returns the arguments of the ssh binary allocating a terminal in
+// the mode. -tt forces it even when the standard input isn't a terminal.
+func (mode TTYMode) sshArgs() []string {
+	switch {
+	case mode == TTYForce:
+		return []string{"-tt"}
+	case mode.allocate():
+		return []string{"-t"}
+	}
+	return []string{"-T"}
+}
+
+// WithTTY returns the client with Shell allocating a terminal as the mode
+// tells.
+func WithTTY(client Client, mode TTYMode) Client {
+	switch c := client.(type) {
+	case ExternalClient:
+		c.TTY = mode
+		return c
+	case NativeClient:
+		c.TTY = mode
+		return c
+	}
+	return client
+}
+
+// ExitError is returned by Shell when the command exits with a non-zero
+// status on the remote host.
+type ExitError struct {
+	Status int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Status)
+}
+
+// exitError returns the ExitError of the error of a command exiting with a
+// non-zero status, whether it was run by the native or the external client.
+func exitError(err error) error {
+	switch e := err.(type) {
+	case *ssh.ExitError:
+		return ExitError{Status: e.ExitStatus()}
+	case *exec.ExitError:
+		if status, ok := e.Sys().(syscall.WaitStatus); ok {
+			return ExitError{Status: status.ExitStatus()}
+		}
+	}
+	return err
+}
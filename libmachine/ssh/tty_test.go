@@ -0,0 +1,29 @@
+package ssh
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTYModeSSHArgs(t *testing.T) {
+	assert.Equal(t, []string{"-tt"}, TTYForce.sshArgs())
+	assert.Equal(t, []string{"-T"}, TTYNone.sshArgs())
+}
+
+func TestWithTTY(t *testing.T) {
+	client := WithTTY(ExternalClient{BinaryPath: "/usr/bin/ssh"}, TTYNone)
+	assert.Equal(t, ExternalClient{BinaryPath: "/usr/bin/ssh", TTY: TTYNone}, client)
+
+	client = WithTTY(NativeClient{Hostname: "1.2.3.4"}, TTYForce)
+	assert.Equal(t, TTYForce, client.(NativeClient).TTY)
+}
+
+func TestExitError(t *testing.T) {
+	err := exitError(exec.Command("sh", "-c", "exit 3").Run())
+	assert.Equal(t, ExitError{Status: 3}, err)
+	assert.EqualError(t, err, "exit status 3")
+
+	assert.NoError(t, exitError(nil))
+}
@@ -0,0 +1,29 @@
+package ssh
+
+import (
+	"os/exec"
+)
+
+// Forward returns the command of the ssh binary forwarding local, a unix
+// socket path or an address like 127.0.0.1:12376, to remote on the host, a
+// unix socket path or a host:port. The forward lasts until the command is
+// killed, or when background is true, ssh goes in the background once the
+// forward is up, and the command returns.
+func (client ExternalClient) Forward(local, remote string, background bool) *exec.Cmd {
+	// The forward has its own connection rather than the one shared by the
+	// other commands, which closes with the master. ssh takes the first
+	// value given for an option.
+	args := []string{
+		"-o", "ControlMaster=no",
+		"-o", "ControlPath=none",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "StreamLocalBindUnlink=yes",
+	}
+	args = append(args, client.BaseArgs...)
+	args = append(args, "-N", "-L", local+":"+remote)
+	if background {
+		args = append(args, "-f", "-n")
+	}
+
+	return getSSHCmd(client.BinaryPath, args...)
+}
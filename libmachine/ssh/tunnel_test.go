@@ -0,0 +1,34 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalClientForward(t *testing.T) {
+	client := ExternalClient{
+		BinaryPath: "/usr/bin/ssh",
+		BaseArgs:   []string{"-o", "ControlPath=/tmp/master", "docker@192.168.99.100", "-p", "22"},
+	}
+
+	cmd := client.Forward("/tmp/docker.sock", "/var/run/docker.sock", false)
+
+	assert.Equal(t, []string{
+		"/usr/bin/ssh",
+		"-o", "ControlMaster=no",
+		"-o", "ControlPath=none",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "StreamLocalBindUnlink=yes",
+		"-o", "ControlPath=/tmp/master", "docker@192.168.99.100", "-p", "22",
+		"-N", "-L", "/tmp/docker.sock:/var/run/docker.sock",
+	}, cmd.Args)
+}
+
+func TestExternalClientForwardInBackground(t *testing.T) {
+	client := ExternalClient{BinaryPath: "/usr/bin/ssh", BaseArgs: []string{"docker@192.168.99.100"}}
+
+	cmd := client.Forward("127.0.0.1:12376", "/var/run/docker.sock", true)
+
+	assert.Equal(t, []string{"-N", "-L", "127.0.0.1:12376:/var/run/docker.sock", "-f", "-n"}, cmd.Args[len(cmd.Args)-5:])
+}
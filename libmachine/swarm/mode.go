@@ -0,0 +1,163 @@
+package swarm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Roles of a machine in swarm mode.
+const (
+	RoleManager = "manager"
+	RoleWorker  = "worker"
+)
+
+// ModePort is the port managers listen on for other nodes to join.
+const ModePort = 2377
+
+const joinTokensFile = "swarm-tokens"
+
+var (
+	ErrModeAndLegacy = errors.New("a machine cannot use both swarm mode and the swarm containers")
+	ErrUnknownRole   = fmt.Errorf("unknown swarm mode role, expected %q or %q", RoleManager, RoleWorker)
+	ErrInitAsWorker  = errors.New("the machine initializing a swarm mode cluster must be a manager")
+	ErrCorruptTokens = errors.New("join tokens cannot be decrypted, they are corrupt or were saved with another CA")
+)
+
+// JoinTokens are the secrets other machines join a swarm mode cluster
+// with, as managers or as workers.
+type JoinTokens struct {
+	Manager string
+	Worker  string
+}
+
+// Token returns the token to join the cluster with role.
+func (t JoinTokens) Token(role string) string {
+	if role == RoleManager {
+		return t.Manager
+	}
+	return t.Worker
+}
+
+// ValidateMode checks the swarm mode options, filling in the default role:
+// manager for a machine initializing a cluster, worker for one joining it.
+func (o *SwarmOptions) ValidateMode() error {
+	if !o.Mode {
+		return nil
+	}
+
+	if o.IsSwarm {
+		return ErrModeAndLegacy
+	}
+
+	switch o.Role {
+	case "":
+		o.Role = RoleWorker
+		if o.JoinManager == "" {
+			o.Role = RoleManager
+		}
+	case RoleManager, RoleWorker:
+	default:
+		return ErrUnknownRole
+	}
+
+	if o.JoinManager == "" && o.Role != RoleManager {
+		return ErrInitAsWorker
+	}
+
+	return nil
+}
+
+// TokensKey derives the key join tokens are encrypted with in the store
+// from the private key of the CA, so that the tokens are as well protected
+// as the certificates giving access to the machines.
+func TokensKey(caPrivateKeyPath string) ([]byte, error) {
+	caKey, err := ioutil.ReadFile(caPrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CA private key: %s", err)
+	}
+
+	mac := hmac.New(sha256.New, caKey)
+	mac.Write([]byte("docker-machine swarm join tokens"))
+	return mac.Sum(nil), nil
+}
+
+// ReadJoinTokens reads the join tokens of the cluster saved in the
+// directory of a machine.
+func ReadJoinTokens(dir string, key []byte) (JoinTokens, error) {
+	tokens := JoinTokens{}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, joinTokensFile))
+	if err != nil {
+		return tokens, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return tokens, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return tokens, ErrCorruptTokens
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return tokens, ErrCorruptTokens
+	}
+
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return tokens, err
+	}
+
+	return tokens, nil
+}
+
+// WriteJoinTokens saves join tokens in the directory of a machine. They are
+// kept out of the machine's configuration, encrypted, and readable by the
+// user only.
+func WriteJoinTokens(dir string, key []byte, tokens JoinTokens) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data := gcm.Seal(nonce, nonce, plaintext, nil)
+
+	return ioutil.WriteFile(filepath.Join(dir, joinTokensFile), data, 0600)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RemoveJoinTokens removes the join tokens saved in the directory of a
+// machine, if any.
+func RemoveJoinTokens(dir string) error {
+	if err := os.Remove(filepath.Join(dir, joinTokensFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package swarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateMode(t *testing.T) {
+	o := SwarmOptions{Mode: true}
+	if err := o.ValidateMode(); err != nil || o.Role != RoleManager {
+		t.Fatalf("Expected a machine initializing a cluster to be a manager, got %q, %v", o.Role, err)
+	}
+
+	o = SwarmOptions{Mode: true, JoinManager: "manager-1"}
+	if err := o.ValidateMode(); err != nil || o.Role != RoleWorker {
+		t.Fatalf("Expected a machine joining a cluster to be a worker, got %q, %v", o.Role, err)
+	}
+
+	o = SwarmOptions{Mode: true, Role: RoleWorker}
+	if err := o.ValidateMode(); err != ErrInitAsWorker {
+		t.Fatalf("Expected %v, got %v", ErrInitAsWorker, err)
+	}
+
+	o = SwarmOptions{Mode: true, Role: "leader", JoinManager: "manager-1"}
+	if err := o.ValidateMode(); err != ErrUnknownRole {
+		t.Fatalf("Expected %v, got %v", ErrUnknownRole, err)
+	}
+
+	o = SwarmOptions{Mode: true, IsSwarm: true}
+	if err := o.ValidateMode(); err != ErrModeAndLegacy {
+		t.Fatalf("Expected %v, got %v", ErrModeAndLegacy, err)
+	}
+
+	o = SwarmOptions{Role: "leader"}
+	if err := o.ValidateMode(); err != nil {
+		t.Fatalf("Expected options without swarm mode to be valid, got %v", err)
+	}
+}
+
+func TestJoinTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-swarm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caKeyPath := filepath.Join(dir, "ca-key.pem")
+	if err := ioutil.WriteFile(caKeyPath, []byte("not really a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := TokensKey(caKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadJoinTokens(dir, key); !os.IsNotExist(err) {
+		t.Fatalf("Expected no tokens to be saved, got %v", err)
+	}
+
+	tokens := JoinTokens{Manager: "SWMTKN-1-manager", Worker: "SWMTKN-1-worker"}
+	if err := WriteJoinTokens(dir, key, tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, joinTokensFile)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("Expected the tokens to be readable by the user only, got %s", fi.Mode())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "SWMTKN") {
+		t.Fatal("Expected the tokens to be encrypted")
+	}
+
+	read, err := ReadJoinTokens(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != tokens {
+		t.Fatalf("Expected %+v, got %+v", tokens, read)
+	}
+
+	if read.Token(RoleWorker) != "SWMTKN-1-worker" || read.Token(RoleManager) != "SWMTKN-1-manager" {
+		t.Fatalf("Unexpected tokens by role: %+v", read)
+	}
+
+	otherKey := append([]byte{}, key...)
+	otherKey[0]++
+	if _, err := ReadJoinTokens(dir, otherKey); err != ErrCorruptTokens {
+		t.Fatalf("Expected %v reading with another key, got %v", ErrCorruptTokens, err)
+	}
+
+	if err := RemoveJoinTokens(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveJoinTokens(dir); err != nil {
+		t.Fatalf("Expected removing missing tokens to succeed, got %v", err)
+	}
+}
@@ -15,4 +15,15 @@ type SwarmOptions struct {
 	Heartbeat      int
 	Overcommit     float64
 	ArbitraryFlags []string
+
+	// Mode is set for machines in a cluster using the swarm mode built
+	// into the engine rather than the swarm containers. Address is the
+	// address the machine advertises to the cluster, its IP by default.
+	Mode bool
+	// Role is RoleManager or RoleWorker, for swarm mode.
+	Role string
+	// JoinManager is the name of the manager machine whose cluster the
+	// machine joins in swarm mode. The machine initializes a new cluster
+	// when there is none.
+	JoinManager string
 }
@@ -0,0 +1,173 @@
+package libmachine
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// ConfigureSwarmMode makes a machine with swarm mode options part of its
+// cluster. A machine without a manager to join initializes a new cluster,
+// whose join tokens are saved next to the machine in the store. Others join
+// the cluster of their manager as a manager or a worker, managers saving the
+// join tokens too.
+func ConfigureSwarmMode(store persist.Store, h *host.Host) error {
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return fmt.Errorf("Error detecting OS: %s", err)
+	}
+
+	return configureSwarmMode(store, h, provisioner)
+}
+
+func configureSwarmMode(store persist.Store, h *host.Host, p provision.Provisioner) error {
+	swarmOptions := h.HostOptions.SwarmOptions
+	if swarmOptions == nil || !swarmOptions.Mode {
+		return nil
+	}
+
+	if err := swarmOptions.ValidateMode(); err != nil {
+		return err
+	}
+
+	if swarmOptions.JoinManager == "" {
+		tokens, err := provision.SwarmInit(p, *swarmOptions)
+		if err != nil {
+			return fmt.Errorf("Error initializing swarm mode cluster: %s", err)
+		}
+		return saveJoinTokens(h, tokens)
+	}
+
+	manager, err := store.Load(swarmOptions.JoinManager)
+	if err != nil {
+		return fmt.Errorf("Error loading swarm mode manager %q: %s", swarmOptions.JoinManager, err)
+	}
+
+	tokens, err := managerJoinTokens(manager)
+	if err != nil {
+		return fmt.Errorf("Error getting the join tokens of swarm mode manager %q: %s", manager.Name, err)
+	}
+
+	managerAddr := ""
+	if manager.HostOptions.SwarmOptions != nil {
+		managerAddr = manager.HostOptions.SwarmOptions.Address
+	}
+	if managerAddr == "" {
+		if managerAddr, err = manager.Driver.GetIP(); err != nil {
+			return fmt.Errorf("Error getting the IP address of swarm mode manager %q: %s", manager.Name, err)
+		}
+	}
+
+	if err := provision.SwarmJoin(p, *swarmOptions, tokens.Token(swarmOptions.Role), managerAddr); err != nil {
+		return fmt.Errorf("Error joining swarm mode cluster: %s", err)
+	}
+
+	// Every manager keeps the tokens, so that machines can join the
+	// cluster through any of them.
+	if swarmOptions.Role == swarm.RoleManager {
+		return saveJoinTokens(h, tokens)
+	}
+
+	return nil
+}
+
+// managerJoinTokens returns the join tokens saved for a manager, asking the
+// manager for them if there are none or they cannot be read, e.g. for a
+// manager which joined the cluster before they were saved.
+func managerJoinTokens(manager *host.Host) (swarm.JoinTokens, error) {
+	tokens, err := readJoinTokens(manager)
+	if err == nil {
+		return tokens, nil
+	}
+	log.Debugf("No join tokens saved for %s, asking it: %s", manager.Name, err)
+
+	p, err := provision.DetectProvisioner(manager.Driver)
+	if err != nil {
+		return tokens, err
+	}
+
+	tokens, err = provision.SwarmJoinTokens(p)
+	if err != nil {
+		return tokens, err
+	}
+
+	return tokens, saveJoinTokens(manager, tokens)
+}
+
+func readJoinTokens(h *host.Host) (swarm.JoinTokens, error) {
+	key, err := swarm.TokensKey(h.HostOptions.AuthOptions.CaPrivateKeyPath)
+	if err != nil {
+		return swarm.JoinTokens{}, err
+	}
+
+	return swarm.ReadJoinTokens(h.HostOptions.AuthOptions.StorePath, key)
+}
+
+func saveJoinTokens(h *host.Host, tokens swarm.JoinTokens) error {
+	key, err := swarm.TokensKey(h.HostOptions.AuthOptions.CaPrivateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	return swarm.WriteJoinTokens(h.HostOptions.AuthOptions.StorePath, key, tokens)
+}
+
+// RotateSwarmJoinTokens replaces the tokens to join the swarm mode cluster
+// of manager with the roles given, both by default, and saves the new tokens
+// with every manager of the cluster in managers. Machines already in the
+// cluster are not affected.
+func RotateSwarmJoinTokens(manager *host.Host, managers []*host.Host, roles ...string) error {
+	if len(roles) == 0 {
+		roles = []string{swarm.RoleManager, swarm.RoleWorker}
+	}
+
+	p, err := provision.DetectProvisioner(manager.Driver)
+	if err != nil {
+		return fmt.Errorf("Error detecting OS: %s", err)
+	}
+
+	for _, role := range roles {
+		log.Infof("Rotating the %s join token of the swarm mode cluster...", role)
+		if err := provision.SwarmRotateJoinToken(p, role); err != nil {
+			return fmt.Errorf("Error rotating the %s join token: %s", role, err)
+		}
+	}
+
+	tokens, err := provision.SwarmJoinTokens(p)
+	if err != nil {
+		return fmt.Errorf("Error getting the new join tokens: %s", err)
+	}
+
+	for _, h := range managers {
+		if err := saveJoinTokens(h, tokens); err != nil {
+			return fmt.Errorf("Error saving the new join tokens of %s: %s", h.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// LeaveSwarmMode takes a machine out of its swarm mode cluster, and clears
+// its swarm mode options. Managers only leave with force.
+func LeaveSwarmMode(h *host.Host, force bool) error {
+	p, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return fmt.Errorf("Error detecting OS: %s", err)
+	}
+
+	if err := provision.SwarmLeave(p, force); err != nil {
+		return fmt.Errorf("Error leaving swarm mode cluster: %s", err)
+	}
+
+	if h.HostOptions.SwarmOptions != nil {
+		h.HostOptions.SwarmOptions.Mode = false
+		h.HostOptions.SwarmOptions.Role = ""
+		h.HostOptions.SwarmOptions.JoinManager = ""
+	}
+
+	return swarm.RemoveJoinTokens(h.HostOptions.AuthOptions.StorePath)
+}
@@ -0,0 +1,79 @@
+// Package userdata renders the user data machines are created with, such as
+// cloud-init configurations, from templates of the variables of the machines,
+// for one template to serve every machine of a fleet.
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// Vars are the variables of a machine its user data is rendered with.
+type Vars struct {
+	MachineName string
+
+	// SSHPublicKey is the public key of the machine, in the
+	// authorized_keys format.
+	SSHPublicKey string
+
+	EngineOptions *engine.EngineOptions
+
+	// SwarmRole is the role of the machine in its swarm mode cluster,
+	// manager or worker, or in its Swarm, master or agent, and empty
+	// when the machine is in neither.
+	SwarmRole string
+}
+
+// funcs are the functions the templates can use besides the builtin ones.
+var funcs = template.FuncMap{
+	// join joins strings, such as the engine labels, with a separator.
+	"join": func(sep string, s []string) string {
+		return strings.Join(s, sep)
+	},
+	// indent indents the lines of s but the first one, for multi-line
+	// values to be embedded in YAML.
+	"indent": func(spaces int, s string) string {
+		return strings.Replace(s, "\n", "\n"+strings.Repeat(" ", spaces), -1)
+	},
+}
+
+// SwarmRole returns the role of a machine with the swarm options o, see
+// Vars.
+func SwarmRole(o *swarm.SwarmOptions) string {
+	switch {
+	case o == nil:
+		return ""
+	case o.Mode:
+		return o.Role
+	case o.Master:
+		return "master"
+	case o.IsSwarm:
+		return "agent"
+	}
+	return ""
+}
+
+// Render renders the template tmpl, read from the file name, with vars.
+func Render(name string, tmpl []byte, vars Vars) ([]byte, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(string(tmpl))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the user data template: %s", err)
+	}
+
+	if vars.EngineOptions == nil {
+		vars.EngineOptions = &engine.EngineOptions{}
+	}
+	vars.SSHPublicKey = strings.TrimSpace(vars.SSHPublicKey)
+
+	var out bytes.Buffer
+	if err := t.Execute(&out, vars); err != nil {
+		return nil, fmt.Errorf("Error rendering the user data template: %s", err)
+	}
+
+	return out.Bytes(), nil
+}
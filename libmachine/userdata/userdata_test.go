@@ -0,0 +1,88 @@
+package userdata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestRender(t *testing.T) {
+	tmpl := `#cloud-config
+hostname: {{.MachineName}}
+ssh_authorized_keys:
+  - {{.SSHPublicKey}}
+write_files:
+  - path: /etc/docker-labels
+    content: {{join "," .EngineOptions.Labels}}
+{{- if eq .SwarmRole "manager"}}
+  - path: /etc/swarm-manager
+{{- end}}
+`
+	vars := Vars{
+		MachineName:   "worker-01",
+		SSHPublicKey:  "ssh-ed25519 AAAA machine\n",
+		EngineOptions: &engine.EngineOptions{Labels: []string{"tier=web", "zone=a"}},
+		SwarmRole:     swarm.RoleManager,
+	}
+
+	out, err := Render("user-data.tmpl", []byte(tmpl), vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `#cloud-config
+hostname: worker-01
+ssh_authorized_keys:
+  - ssh-ed25519 AAAA machine
+write_files:
+  - path: /etc/docker-labels
+    content: tier=web,zone=a
+  - path: /etc/swarm-manager
+`
+	if string(out) != expected {
+		t.Fatalf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := Render("user-data.tmpl", []byte("{{.MachineName"), Vars{}); err == nil || !strings.HasPrefix(err.Error(), "Error parsing the user data template") {
+		t.Fatalf("Expected a parsing error, got %v", err)
+	}
+
+	if _, err := Render("user-data.tmpl", []byte("{{.Hostname}}"), Vars{}); err == nil || !strings.HasPrefix(err.Error(), "Error rendering the user data template") {
+		t.Fatalf("Expected a rendering error, got %v", err)
+	}
+}
+
+func TestRenderIndent(t *testing.T) {
+	out, err := Render("user-data.tmpl", []byte(`key: |
+  {{indent 2 "line 1\nline 2"}}`), Vars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != "key: |\n  line 1\n  line 2" {
+		t.Fatalf("Unexpected output %q", out)
+	}
+}
+
+func TestSwarmRole(t *testing.T) {
+	cases := []struct {
+		options  *swarm.SwarmOptions
+		expected string
+	}{
+		{nil, ""},
+		{&swarm.SwarmOptions{}, ""},
+		{&swarm.SwarmOptions{Mode: true, Role: swarm.RoleWorker}, "worker"},
+		{&swarm.SwarmOptions{IsSwarm: true, Master: true}, "master"},
+		{&swarm.SwarmOptions{IsSwarm: true}, "agent"},
+	}
+
+	for _, c := range cases {
+		if role := SwarmRole(c.options); role != c.expected {
+			t.Errorf("Expected role %q for %+v, got %q", c.expected, c.options, role)
+		}
+	}
+}
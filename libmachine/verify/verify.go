@@ -0,0 +1,299 @@
+// Package verify validates a provisioned machine end to end: the client
+// reaches its engine over TLS, the engine runs with a usable storage driver
+// and cgroups, and it pulls and runs an image, measuring how long it takes.
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+)
+
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+const (
+	CheckTLSHandshake = "tls-handshake"
+	CheckStorage      = "storage-driver"
+	CheckCgroups      = "cgroups"
+	CheckPull         = "pull"
+	CheckRun          = "run"
+)
+
+// DefaultImage is the image pulled and run when the options have none.
+const DefaultImage = "hello-world"
+
+// helloWorldGreeting is printed by the hello-world image when it runs.
+const helloWorldGreeting = "Hello from Docker!"
+
+// Machine is what the checks need of a machine, as a *host.Host provides.
+type Machine interface {
+	RunSSHCommand(command string) (string, error)
+	GetURL() (string, error)
+}
+
+// Options tell how to verify a machine.
+type Options struct {
+	// Image is pulled and run, DefaultImage when empty.
+	Image string
+
+	// StorageDriver is the storage driver the engine was provisioned
+	// with, any when empty.
+	StorageDriver string
+
+	// AuthOptions are the certificates the client reaches the engine
+	// with, the TLS handshake being skipped without them, as for the
+	// machines reached through SSH tunnels.
+	AuthOptions *auth.AuthOptions
+
+	// Sudo returns the command run as root, for the docker CLI of the
+	// machine.
+	Sudo func(command string) string
+
+	// MaxPull and MaxRun fail the pull and the run of the image when
+	// they take longer, when they aren't 0.
+	MaxPull time.Duration
+	MaxRun  time.Duration
+}
+
+// Result is the outcome of a single check, with how long it took for the
+// checks which are measured.
+type Result struct {
+	Check    string        `json:"check"`
+	Status   Status        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Report holds the results of every check run against a machine, in the
+// order they were run, and when they were.
+type Report struct {
+	Time    time.Time `json:"time"`
+	Results []Result  `json:"results"`
+}
+
+// Passed reports whether none of the checks failed.
+func (r *Report) Passed() bool {
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// Result returns the result of the named check, or nil if it was not run.
+func (r *Report) Result(check string) *Result {
+	for i := range r.Results {
+		if r.Results[i].Check == check {
+			return &r.Results[i]
+		}
+	}
+	return nil
+}
+
+// Run verifies the machine. The pull and the run of the image are measured
+// from the client, less the round trip of an empty SSH command, the image
+// being removed first for the pull to download it again.
+func Run(m Machine, opts Options) *Report {
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	if opts.Sudo == nil {
+		opts.Sudo = func(command string) string { return command }
+	}
+
+	report := &Report{Time: time.Now()}
+	add := func(res Result) {
+		report.Results = append(report.Results, res)
+	}
+
+	add(checkTLSHandshake(m, opts))
+
+	info, err := readEngineInfo(m, opts)
+	if err != nil {
+		add(Result{Check: CheckStorage, Status: StatusFailed, Message: err.Error()})
+		add(Result{Check: CheckCgroups, Status: StatusSkipped, Message: "the engine info couldn't be read"})
+	} else {
+		add(checkStorage(info, opts))
+		add(checkCgroups(info))
+	}
+
+	roundTrip, err := sshRoundTrip(m)
+	if err != nil {
+		add(Result{Check: CheckPull, Status: StatusFailed, Message: err.Error()})
+		add(Result{Check: CheckRun, Status: StatusSkipped, Message: "the image wasn't pulled"})
+		return report
+	}
+
+	m.RunSSHCommand(opts.Sudo(fmt.Sprintf("docker rmi -f %s", opts.Image)))
+
+	pull, _ := measure(m, opts.Sudo(fmt.Sprintf("docker pull %s", opts.Image)), roundTrip)
+	pull.Check = CheckPull
+	checkDuration(&pull, opts.MaxPull)
+	add(pull)
+
+	if pull.Status != StatusPassed {
+		add(Result{Check: CheckRun, Status: StatusSkipped, Message: "the image wasn't pulled"})
+		return report
+	}
+
+	run, out := measure(m, opts.Sudo(fmt.Sprintf("docker run --rm %s", opts.Image)), roundTrip)
+	run.Check = CheckRun
+	if run.Status == StatusPassed && opts.Image == DefaultImage && !strings.Contains(out, helloWorldGreeting) {
+		run.Status = StatusFailed
+		run.Message = fmt.Sprintf("%s didn't print its greeting", DefaultImage)
+	}
+	checkDuration(&run, opts.MaxRun)
+	add(run)
+
+	return report
+}
+
+// checkTLSHandshake makes sure the client reaches the engine with the
+// certificates of the machine, and measures the handshake.
+func checkTLSHandshake(m Machine, opts Options) Result {
+	res := Result{Check: CheckTLSHandshake}
+
+	if opts.AuthOptions == nil {
+		res.Status = StatusSkipped
+		res.Message = "the engine isn't reached over TLS"
+		return res
+	}
+
+	rawURL, err := m.GetURL()
+	if err != nil {
+		res.Status = StatusFailed
+		res.Message = err.Error()
+		return res
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		res.Status = StatusFailed
+		res.Message = err.Error()
+		return res
+	}
+
+	start := time.Now()
+	if _, err := cert.ValidateCertificate(u.Host, opts.AuthOptions); err != nil {
+		res.Status = StatusFailed
+		res.Message = fmt.Sprintf("the engine doesn't accept the certificates of the machine: %s", err)
+		return res
+	}
+	res.Duration = time.Since(start)
+	res.Status = StatusPassed
+
+	return res
+}
+
+// engineInfo is what the checks read of docker info, CgroupVersion being
+// empty for engines older than 20.10, which only know cgroup v1.
+type engineInfo struct {
+	StorageDriver string `json:"Driver"`
+	CgroupDriver  string `json:"CgroupDriver"`
+	CgroupVersion string `json:"CgroupVersion"`
+	MemoryLimit   bool   `json:"MemoryLimit"`
+}
+
+func readEngineInfo(m Machine, opts Options) (engineInfo, error) {
+	out, err := m.RunSSHCommand(opts.Sudo("docker info --format '{{json .}}'"))
+	if err != nil {
+		return engineInfo{}, fmt.Errorf("Error reading the engine info: %s", err)
+	}
+	return parseEngineInfo(out)
+}
+
+func parseEngineInfo(out string) (engineInfo, error) {
+	var info engineInfo
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &info); err != nil {
+		return engineInfo{}, fmt.Errorf("Error reading the engine info: %s", err)
+	}
+	return info, nil
+}
+
+// checkStorage makes sure the engine uses the storage driver it was
+// provisioned with, and not vfs, which copies every layer.
+func checkStorage(info engineInfo, opts Options) Result {
+	res := Result{Check: CheckStorage, Status: StatusPassed, Message: info.StorageDriver}
+
+	switch {
+	case opts.StorageDriver != "" && info.StorageDriver != opts.StorageDriver:
+		res.Status = StatusFailed
+		res.Message = fmt.Sprintf("the engine uses %s, expected %s", info.StorageDriver, opts.StorageDriver)
+	case info.StorageDriver == "vfs":
+		res.Status = StatusFailed
+		res.Message = "the engine uses vfs, which copies every layer"
+	}
+
+	return res
+}
+
+// checkCgroups makes sure the engine limits the resources of containers.
+func checkCgroups(info engineInfo) Result {
+	res := Result{Check: CheckCgroups, Status: StatusPassed}
+
+	version := "cgroup v1"
+	if info.CgroupVersion != "" && info.CgroupVersion != "1" {
+		version = "cgroup v" + info.CgroupVersion
+	}
+	res.Message = fmt.Sprintf("%s driver, %s", info.CgroupDriver, version)
+
+	switch {
+	case info.CgroupDriver == "" || info.CgroupDriver == "none":
+		res.Status = StatusFailed
+		res.Message = "the engine runs containers without cgroups"
+	case !info.MemoryLimit:
+		res.Status = StatusFailed
+		res.Message += ", without memory limits"
+	}
+
+	return res
+}
+
+// sshRoundTrip measures an empty SSH command.
+func sshRoundTrip(m Machine) (time.Duration, error) {
+	start := time.Now()
+	if _, err := m.RunSSHCommand("true"); err != nil {
+		return 0, fmt.Errorf("the machine doesn't answer over SSH: %s", err)
+	}
+	return time.Since(start), nil
+}
+
+// measure runs the command, and returns its result, with how long it took
+// less the round trip, and its output.
+func measure(m Machine, command string, roundTrip time.Duration) (Result, string) {
+	start := time.Now()
+	out, err := m.RunSSHCommand(command)
+	elapsed := time.Since(start) - roundTrip
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	if err != nil {
+		return Result{Status: StatusFailed, Message: err.Error()}, out
+	}
+
+	return Result{Status: StatusPassed, Duration: elapsed}, out
+}
+
+// checkDuration fails the result when it took longer than max, when it
+// isn't 0.
+func checkDuration(res *Result, max time.Duration) {
+	if res.Status != StatusPassed || max == 0 || res.Duration <= max {
+		return
+	}
+
+	res.Status = StatusFailed
+	res.Message = fmt.Sprintf("took %s, more than %s", res.Duration.Round(time.Millisecond), max)
+}
@@ -0,0 +1,171 @@
+package verify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMachine answers the commands starting with the keys of outputs, and
+// fails the others.
+type fakeMachine struct {
+	outputs  map[string]string
+	commands []string
+}
+
+func (m *fakeMachine) RunSSHCommand(command string) (string, error) {
+	m.commands = append(m.commands, command)
+	for prefix, out := range m.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return out, nil
+		}
+	}
+	return "", errors.New("exit status 1")
+}
+
+func (m *fakeMachine) GetURL() (string, error) {
+	return "tcp://192.168.99.100:2376", nil
+}
+
+const overlayInfo = `{"Driver":"overlay2","CgroupDriver":"systemd","CgroupVersion":"2","MemoryLimit":true}`
+
+func newFakeMachine() *fakeMachine {
+	return &fakeMachine{outputs: map[string]string{
+		"true":            "",
+		"docker info":     overlayInfo,
+		"docker rmi":      "",
+		"docker pull":     "Status: Downloaded newer image for hello-world:latest",
+		"docker run --rm": "\nHello from Docker!\nThis message shows that your installation appears to be working correctly.\n",
+	}}
+}
+
+func statuses(r *Report) map[string]Status {
+	s := map[string]Status{}
+	for _, res := range r.Results {
+		s[res.Check] = res.Status
+	}
+	return s
+}
+
+func TestRun(t *testing.T) {
+	m := newFakeMachine()
+
+	report := Run(m, Options{StorageDriver: "overlay2"})
+
+	if !report.Passed() {
+		t.Fatalf("expected the machine to pass, got %+v", report.Results)
+	}
+
+	expected := map[string]Status{
+		CheckTLSHandshake: StatusSkipped,
+		CheckStorage:      StatusPassed,
+		CheckCgroups:      StatusPassed,
+		CheckPull:         StatusPassed,
+		CheckRun:          StatusPassed,
+	}
+	for check, status := range expected {
+		if statuses(report)[check] != status {
+			t.Fatalf("expected %s to be %s, got %+v", check, status, report.Results)
+		}
+	}
+
+	if msg := report.Result(CheckCgroups).Message; msg != "systemd driver, cgroup v2" {
+		t.Fatalf("unexpected cgroups message %q", msg)
+	}
+
+	if m.commands[2] != "docker rmi -f hello-world" || m.commands[3] != "docker pull hello-world" {
+		t.Fatalf("expected the image to be removed before it is pulled, got %v", m.commands)
+	}
+}
+
+func TestRunPullFails(t *testing.T) {
+	m := newFakeMachine()
+	delete(m.outputs, "docker pull")
+
+	report := Run(m, Options{Image: "busybox"})
+
+	if report.Passed() {
+		t.Fatal("expected the machine to fail")
+	}
+	if statuses(report)[CheckPull] != StatusFailed || statuses(report)[CheckRun] != StatusSkipped {
+		t.Fatalf("expected the pull to fail and the run to be skipped, got %+v", report.Results)
+	}
+}
+
+func TestRunWithoutGreeting(t *testing.T) {
+	m := newFakeMachine()
+	m.outputs["docker run --rm"] = ""
+
+	if statuses(Run(m, Options{}))[CheckRun] != StatusFailed {
+		t.Fatal("expected hello-world to fail without its greeting")
+	}
+	if statuses(Run(m, Options{Image: "busybox"}))[CheckRun] != StatusPassed {
+		t.Fatal("expected other images not to have to greet")
+	}
+}
+
+func TestCheckStorage(t *testing.T) {
+	cases := []struct {
+		driver   string
+		expected string
+		status   Status
+	}{
+		{"overlay2", "", StatusPassed},
+		{"overlay2", "overlay2", StatusPassed},
+		{"overlay2", "aufs", StatusFailed},
+		{"vfs", "", StatusFailed},
+	}
+
+	for _, c := range cases {
+		if res := checkStorage(engineInfo{StorageDriver: c.driver}, Options{StorageDriver: c.expected}); res.Status != c.status {
+			t.Fatalf("expected %s for %s expecting %q, got %+v", c.status, c.driver, c.expected, res)
+		}
+	}
+}
+
+func TestCheckCgroups(t *testing.T) {
+	cases := []struct {
+		info    engineInfo
+		status  Status
+		message string
+	}{
+		{engineInfo{CgroupDriver: "cgroupfs", MemoryLimit: true}, StatusPassed, "cgroupfs driver, cgroup v1"},
+		{engineInfo{CgroupDriver: "cgroupfs", CgroupVersion: "1"}, StatusFailed, "cgroupfs driver, cgroup v1, without memory limits"},
+		{engineInfo{CgroupDriver: "none"}, StatusFailed, "the engine runs containers without cgroups"},
+	}
+
+	for _, c := range cases {
+		res := checkCgroups(c.info)
+		if res.Status != c.status || res.Message != c.message {
+			t.Fatalf("expected %s %q for %+v, got %+v", c.status, c.message, c.info, res)
+		}
+	}
+}
+
+func TestParseEngineInfo(t *testing.T) {
+	info, err := parseEngineInfo(overlayInfo + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != (engineInfo{StorageDriver: "overlay2", CgroupDriver: "systemd", CgroupVersion: "2", MemoryLimit: true}) {
+		t.Fatalf("unexpected engine info %+v", info)
+	}
+
+	if _, err := parseEngineInfo("Cannot connect to the Docker daemon"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestCheckDuration(t *testing.T) {
+	res := Result{Status: StatusPassed, Duration: 1500 * time.Millisecond}
+	checkDuration(&res, 2*time.Second)
+	if res.Status != StatusPassed {
+		t.Fatalf("expected the result to pass, got %+v", res)
+	}
+
+	checkDuration(&res, time.Second)
+	if res.Status != StatusFailed || res.Message != "took 1.5s, more than 1s" {
+		t.Fatalf("expected the result to fail, got %+v", res)
+	}
+}
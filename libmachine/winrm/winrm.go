@@ -0,0 +1,321 @@
+// Package winrm runs commands on hosts over WinRM, the WS-Management remote
+// shell, authenticating with HTTP basic authentication.
+package winrm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPort      = 5985
+	DefaultHTTPSPort = 5986
+
+	// operationTimeout is how long the host holds a Receive before
+	// answering that no output is ready yet.
+	operationTimeout = 60 * time.Second
+
+	// maxEnvelopeSize is the size of the messages the host may send.
+	maxEnvelopeSize = 153600
+
+	shellURI   = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell"
+	cmdURI     = shellURI + "/cmd"
+	doneState  = shellURI + "/CommandState/Done"
+	terminate  = shellURI + "/signal/terminate"
+	createURI  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	deleteURI  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	commandURI = shellURI + "/Command"
+	sendURI    = shellURI + "/Send"
+	receiveURI = shellURI + "/Receive"
+	signalURI  = shellURI + "/Signal"
+
+	// timedOutCode is the WS-Management fault of a Receive for which no
+	// output was ready.
+	timedOutCode = "2150858793"
+)
+
+// Client runs commands on a host over WinRM.
+type Client struct {
+	// Endpoint is the URL of the WS-Management service, such as
+	// https://host:5986/wsman.
+	Endpoint string
+	User     string
+	Password string
+	HTTP     *http.Client
+}
+
+// NewClient returns a client of the WinRM service of host, on port, over
+// HTTPS when https, without checking the certificate of the host when
+// insecure.
+func NewClient(host string, port int, https, insecure bool, user, password string) *Client {
+	scheme := "http"
+	transport := &http.Transport{}
+	if https {
+		scheme = "https"
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+	}
+
+	return &Client{
+		Endpoint: fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(host, strconv.Itoa(port))),
+		User:     user,
+		Password: password,
+		HTTP:     &http.Client{Transport: transport, Timeout: operationTimeout + 30*time.Second},
+	}
+}
+
+// ExitError is the error of a command which exited with a non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Run runs the command in a new shell of the host, writing stdin to it when
+// not nil, and its output to stdout and stderr. It returns an ExitError when
+// the command exits with a non-zero code.
+func (c *Client) Run(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	shellID, err := c.createShell()
+	if err != nil {
+		return err
+	}
+	defer c.request(deleteURI, shellID, nil, "")
+
+	var reply commandResponse
+	body := fmt.Sprintf("<rsp:CommandLine><rsp:Command>%s</rsp:Command></rsp:CommandLine>", escape(command))
+	options := []option{{"WINRS_CONSOLEMODE_STDIN", "TRUE"}, {"WINRS_SKIP_CMD_SHELL", "FALSE"}}
+	if err := c.call(commandURI, shellID, options, body, &reply); err != nil {
+		return err
+	}
+	commandID := reply.Body.CommandID
+	defer c.request(signalURI, shellID, nil, fmt.Sprintf(`<rsp:Signal CommandId="%s"><rsp:Code>%s</rsp:Code></rsp:Signal>`, escape(commandID), terminate))
+
+	if stdin != nil {
+		input, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		body := fmt.Sprintf(`<rsp:Send><rsp:Stream Name="stdin" CommandId="%s" End="true">%s</rsp:Stream></rsp:Send>`, escape(commandID), base64.StdEncoding.EncodeToString(input))
+		if err := c.call(sendURI, shellID, nil, body, nil); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var reply receiveResponse
+		body := fmt.Sprintf(`<rsp:Receive><rsp:DesiredStream CommandId="%s">stdout stderr</rsp:DesiredStream></rsp:Receive>`, escape(commandID))
+		if err := c.call(receiveURI, shellID, nil, body, &reply); err != nil {
+			if fault, ok := err.(Fault); ok && fault.Code == timedOutCode {
+				continue
+			}
+			return err
+		}
+
+		for _, stream := range reply.Body.Streams {
+			data, err := base64.StdEncoding.DecodeString(stream.Data)
+			if err != nil {
+				return fmt.Errorf("Invalid %s stream from WinRM: %s", stream.Name, err)
+			}
+			out := stdout
+			if stream.Name == "stderr" {
+				out = stderr
+			}
+			if out != nil {
+				out.Write(data)
+			}
+		}
+
+		if reply.Body.State.State == doneState {
+			if reply.Body.State.ExitCode != 0 {
+				return ExitError{Code: reply.Body.State.ExitCode}
+			}
+			return nil
+		}
+	}
+}
+
+func (c *Client) createShell() (string, error) {
+	var reply createResponse
+	body := "<rsp:Shell><rsp:InputStreams>stdin</rsp:InputStreams><rsp:OutputStreams>stdout stderr</rsp:OutputStreams></rsp:Shell>"
+	options := []option{{"WINRS_NOPROFILE", "FALSE"}, {"WINRS_CODEPAGE", "65001"}}
+	if err := c.call(createURI, "", options, body, &reply); err != nil {
+		return "", err
+	}
+
+	// Windows answers with the reference of the shell, other services
+	// with the shell itself.
+	for _, selector := range reply.Body.ResourceCreated.Selectors {
+		if selector.Name == "ShellId" {
+			return selector.Value, nil
+		}
+	}
+	if reply.Body.Shell.ShellID != "" {
+		return reply.Body.Shell.ShellID, nil
+	}
+
+	return "", fmt.Errorf("WinRM created a shell without an ID")
+}
+
+type option struct {
+	Name  string
+	Value string
+}
+
+// Fault is a SOAP fault returned by the WinRM service.
+type Fault struct {
+	Code   string
+	Reason string
+}
+
+func (f Fault) Error() string {
+	if f.Code != "" {
+		return fmt.Sprintf("WinRM error %s: %s", f.Code, f.Reason)
+	}
+	return fmt.Sprintf("WinRM error: %s", f.Reason)
+}
+
+func (c *Client) request(action, shellID string, options []option, body string) error {
+	return c.call(action, shellID, options, body, nil)
+}
+
+// call sends the action to the service, in the shell when shellID is not
+// empty, and decodes the reply into out when not nil.
+func (c *Client) call(action, shellID string, options []option, body string, out interface{}) error {
+	messageID, err := newMessageID()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd" xmlns:rsp="` + shellURI + `"><env:Header>`)
+	fmt.Fprintf(&buf, "<a:To>%s</a:To>", escape(c.Endpoint))
+	buf.WriteString(`<a:ReplyTo><a:Address mustUnderstand="true">http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>`)
+	fmt.Fprintf(&buf, `<w:MaxEnvelopeSize mustUnderstand="true">%d</w:MaxEnvelopeSize>`, maxEnvelopeSize)
+	fmt.Fprintf(&buf, "<a:MessageID>uuid:%s</a:MessageID>", messageID)
+	fmt.Fprintf(&buf, "<w:OperationTimeout>PT%dS</w:OperationTimeout>", int(operationTimeout.Seconds()))
+	fmt.Fprintf(&buf, `<w:ResourceURI mustUnderstand="true">%s</w:ResourceURI>`, cmdURI)
+	fmt.Fprintf(&buf, `<a:Action mustUnderstand="true">%s</a:Action>`, action)
+	if shellID != "" {
+		fmt.Fprintf(&buf, `<w:SelectorSet><w:Selector Name="ShellId">%s</w:Selector></w:SelectorSet>`, escape(shellID))
+	}
+	if len(options) > 0 {
+		buf.WriteString("<w:OptionSet>")
+		for _, o := range options {
+			fmt.Fprintf(&buf, `<w:Option Name="%s">%s</w:Option>`, o.Name, o.Value)
+		}
+		buf.WriteString("</w:OptionSet>")
+	}
+	fmt.Fprintf(&buf, "</env:Header><env:Body>%s</env:Body></env:Envelope>", body)
+
+	req, err := http.NewRequest("POST", c.Endpoint, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(c.User, c.Password)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error connecting to WinRM: %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("WinRM refused the credentials of %q, only basic authentication is supported", c.User)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var reply faultResponse
+		if err := xml.Unmarshal(data, &reply); err == nil && reply.Body.Fault.Reason != "" {
+			return Fault{Code: reply.Body.Fault.Detail.WSManFault.Code, Reason: strings.TrimSpace(reply.Body.Fault.Reason)}
+		}
+		return fmt.Errorf("WinRM answered %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
+
+type selector struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type createResponse struct {
+	Body struct {
+		ResourceCreated struct {
+			Selectors []selector `xml:"ReferenceParameters>SelectorSet>Selector"`
+		}
+		Shell struct {
+			ShellID string `xml:"ShellId"`
+		}
+	}
+}
+
+type commandResponse struct {
+	Body struct {
+		CommandID string `xml:"CommandResponse>CommandId"`
+	}
+}
+
+type receiveResponse struct {
+	Body struct {
+		Streams []struct {
+			Name string `xml:"Name,attr"`
+			Data string `xml:",chardata"`
+		} `xml:"ReceiveResponse>Stream"`
+		State struct {
+			State    string `xml:"State,attr"`
+			ExitCode int
+		} `xml:"ReceiveResponse>CommandState"`
+	}
+}
+
+type faultResponse struct {
+	Body struct {
+		Fault struct {
+			Reason string `xml:"Reason>Text"`
+			Detail struct {
+				WSManFault struct {
+					Code string `xml:"Code,attr"`
+				}
+			}
+		}
+	}
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// newMessageID returns a random UUID identifying a message.
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
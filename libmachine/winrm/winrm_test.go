@@ -0,0 +1,107 @@
+package winrm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	actionRegexp  = regexp.MustCompile(`<a:Action[^>]*>([^<]+)</a:Action>`)
+	commandRegexp = regexp.MustCompile(`<rsp:Command>([^<]*)</rsp:Command>`)
+	stdinRegexp   = regexp.MustCompile(`<rsp:Stream Name="stdin"[^>]*>([^<]*)</rsp:Stream>`)
+)
+
+// fakeWinRM is a WinRM service running a command which answers with the
+// output and exit code given, after a Receive which times out.
+type fakeWinRM struct {
+	output   string
+	exitCode int
+
+	actions  []string
+	command  string
+	stdin    string
+	receives int
+}
+
+func (f *fakeWinRM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	data, _ := ioutil.ReadAll(r.Body)
+	action := actionRegexp.FindSubmatch(data)[1]
+	f.actions = append(f.actions, string(action[bytes.LastIndexByte(action, '/')+1:]))
+
+	var body string
+	switch string(action) {
+	case createURI:
+		body = `<x:ResourceCreated xmlns:x="http://schemas.xmlsoap.org/ws/2004/09/transfer"><a:ReferenceParameters><w:SelectorSet><w:Selector Name="ShellId">shell-1</w:Selector></w:SelectorSet></a:ReferenceParameters></x:ResourceCreated>`
+	case commandURI:
+		f.command = string(commandRegexp.FindSubmatch(data)[1])
+		body = `<rsp:CommandResponse><rsp:CommandId>command-1</rsp:CommandId></rsp:CommandResponse>`
+	case sendURI:
+		stdin, _ := base64.StdEncoding.DecodeString(string(stdinRegexp.FindSubmatch(data)[1]))
+		f.stdin = string(stdin)
+	case receiveURI:
+		f.receives++
+		if f.receives == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault><s:Reason><s:Text>The WS-Management service cannot complete the operation within the time specified in OperationTimeout.</s:Text></s:Reason><s:Detail><f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="%s"/></s:Detail></s:Fault></s:Body></s:Envelope>`, timedOutCode)
+			return
+		}
+		body = fmt.Sprintf(`<rsp:ReceiveResponse><rsp:Stream Name="stdout" CommandId="command-1">%s</rsp:Stream><rsp:Stream Name="stderr" CommandId="command-1">%s</rsp:Stream><rsp:CommandState CommandId="command-1" State="%s"><rsp:ExitCode>%d</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`,
+			base64.StdEncoding.EncodeToString([]byte(f.output)), base64.StdEncoding.EncodeToString([]byte("warning")), doneState, f.exitCode)
+	}
+
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd" xmlns:rsp="%s"><s:Body>%s</s:Body></s:Envelope>`, shellURI, body)
+}
+
+func TestRun(t *testing.T) {
+	fake := &fakeWinRM{output: "hello"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := &Client{Endpoint: server.URL + "/wsman", User: "admin", Password: "secret", HTTP: http.DefaultClient}
+
+	var stdout, stderr bytes.Buffer
+	assert.NoError(t, client.Run("echo <hello>", bytes.NewReader([]byte("input")), &stdout, &stderr))
+	assert.Equal(t, "hello", stdout.String())
+	assert.Equal(t, "warning", stderr.String())
+	assert.Equal(t, "echo &lt;hello&gt;", fake.command)
+	assert.Equal(t, "input", fake.stdin)
+	assert.Equal(t, []string{"Create", "Command", "Send", "Receive", "Receive", "Signal", "Delete"}, fake.actions)
+}
+
+func TestRunExitCode(t *testing.T) {
+	fake := &fakeWinRM{exitCode: 2}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := &Client{Endpoint: server.URL + "/wsman", User: "admin", Password: "secret", HTTP: http.DefaultClient}
+
+	assert.Equal(t, ExitError{Code: 2}, client.Run("false", nil, nil, nil))
+	assert.Equal(t, []string{"Create", "Command", "Receive", "Receive", "Signal", "Delete"}, fake.actions)
+}
+
+func TestRunUnauthorized(t *testing.T) {
+	server := httptest.NewServer(&fakeWinRM{})
+	defer server.Close()
+
+	client := &Client{Endpoint: server.URL + "/wsman", User: "admin", Password: "wrong", HTTP: http.DefaultClient}
+
+	assert.EqualError(t, client.Run("true", nil, nil, nil), `WinRM refused the credentials of "admin", only basic authentication is supported`)
+}
+
+func TestNewClient(t *testing.T) {
+	assert.Equal(t, "http://10.0.0.5:5985/wsman", NewClient("10.0.0.5", DefaultPort, false, false, "admin", "").Endpoint)
+	assert.Equal(t, "https://[fd00::5]:5986/wsman", NewClient("fd00::5", DefaultHTTPSPort, true, true, "admin", "").Endpoint)
+}